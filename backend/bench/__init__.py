@@ -0,0 +1,23 @@
+"""
+Load generation and benchmarking
+
+Drives a configurable synthetic workload against a target and reports
+throughput and latency percentiles per operation, to check that a
+performance-motivated change (a read model, an index, a cache) pays off.
+
+- `workload`: the mix of operations, how skewed reads are towards hot
+  articles, and the synthetic users and articles a run seeds
+- `targets`: where operations go: the HTTP API of a running instance, or
+  the repositories (shared/repositories.py) directly on PostgreSQL or in
+  memory, which leaves out HTTP, auth and serialization
+- `runner`: runs the workload from concurrent workers and collects the report
+
+Run it with `python scripts/bench.py`. Seeded data is tagged `bench` and is
+not cleaned up, so point it at a database you can throw away.
+"""
+
+from bench.runner import Report, run
+from bench.targets import TARGETS, ApiTarget, RepositoryTarget
+from bench.workload import Workload, parse_mix
+
+__all__ = ['Report', 'run', 'TARGETS', 'ApiTarget', 'RepositoryTarget', 'Workload', 'parse_mix']
@@ -0,0 +1,160 @@
+"""
+Running a workload and reporting on it
+
+The runner is closed-loop: each of `concurrency` workers sends its next
+operation as soon as the last one returns, so throughput is what the target
+sustains at that concurrency rather than an offered rate.
+"""
+
+import logging
+import math
+import random
+import threading
+import time
+from concurrent.futures import ThreadPoolExecutor
+from dataclasses import dataclass, field
+from typing import Any, Dict, List, Optional
+
+from bench.workload import HotSet, OperationPicker, Workload
+
+logger = logging.getLogger(__name__)
+
+PERCENTILES = (50, 90, 95, 99)
+
+
+def percentile(sorted_values: List[float], pct: float) -> float:
+    """Nearest-rank percentile of values already sorted"""
+    if not sorted_values:
+        return 0.0
+    rank = max(1, math.ceil(pct / 100 * len(sorted_values)))
+    return sorted_values[rank - 1]
+
+
+@dataclass
+class OperationStats:
+    latencies_ms: List[float] = field(default_factory=list)
+    errors: int = 0
+    first_error: Optional[str] = None
+
+    def summary(self, elapsed: float) -> Dict[str, Any]:
+        latencies = sorted(self.latencies_ms)
+        summary = {
+            'count': len(latencies),
+            'errors': self.errors,
+            'throughput': round(len(latencies) / elapsed, 1) if elapsed else 0.0,
+        }
+        for pct in PERCENTILES:
+            summary[f"p{pct}_ms"] = round(percentile(latencies, pct), 2)
+        summary['max_ms'] = round(latencies[-1], 2) if latencies else 0.0
+        if self.first_error:
+            summary['first_error'] = self.first_error
+        return summary
+
+
+@dataclass
+class Report:
+    target: str
+    workload: Workload
+    elapsed_seconds: float
+    operations: Dict[str, OperationStats]
+    articles: int
+    hot_share: float
+
+    def as_dict(self) -> Dict[str, Any]:
+        ops = {name: stats.summary(self.elapsed_seconds) for name, stats in sorted(self.operations.items())}
+        everything = OperationStats(
+            latencies_ms=[latency for stats in self.operations.values() for latency in stats.latencies_ms],
+            errors=sum(stats.errors for stats in self.operations.values()),
+        )
+        return {
+            'target': self.target,
+            'concurrency': self.workload.concurrency,
+            'skew': self.workload.skew,
+            'articles': self.articles,
+            'hot_share': round(self.hot_share, 3),
+            'elapsed_seconds': round(self.elapsed_seconds, 2),
+            'total': everything.summary(self.elapsed_seconds),
+            'operations': ops,
+        }
+
+    def table(self) -> str:
+        report = self.as_dict()
+        lines = [
+            f"Target {report['target']}: {report['concurrency']} workers for {report['elapsed_seconds']} s "
+            f"over {report['articles']} articles ({report['hot_share']:.0%} of picks on the top 1%)",
+            f"{'operation':<10} {'count':>8} {'errors':>7} {'ops/s':>9} "
+            + ' '.join(f"{'p' + str(pct):>8}" for pct in PERCENTILES) + f" {'max':>8}",
+        ]
+        for name, summary in list(report['operations'].items()) + [('total', report['total'])]:
+            lines.append(
+                f"{name:<10} {summary['count']:>8} {summary['errors']:>7} {summary['throughput']:>9} "
+                + ' '.join(f"{summary[f'p{pct}_ms']:>8}" for pct in PERCENTILES) + f" {summary['max_ms']:>8}"
+            )
+        lines.append("Latencies in milliseconds")
+        return '\n'.join(lines)
+
+
+def run(target, workload: Workload) -> Report:
+    """Seed the target, then run the workload against it until the duration or operation budget runs out"""
+    rng = random.Random(workload.random_seed)
+    picker = OperationPicker(workload.ratio(target.operations))
+    skipped = sorted(name for name in workload.mix if name not in target.operations)
+    if skipped:
+        logger.info(f"Leaving {', '.join(skipped)} out of the mix: {target.name} targets don't support them")
+
+    logger.info(f"Seeding {target.describe()}")
+    target.setup(workload, rng)
+    if not target.article_ids:
+        raise RuntimeError("No published articles to benchmark against; seed some with --seed-articles")
+    hot = HotSet(target.article_ids, workload.skew, rng)
+
+    operations: Dict[str, OperationStats] = {name: OperationStats() for name in picker.names}
+    lock = threading.Lock()
+    remaining = [workload.max_operations]
+    started = time.monotonic()
+    deadline = started + workload.duration_seconds
+
+    def claim() -> bool:
+        if time.monotonic() >= deadline:
+            return False
+        if remaining[0] is None:
+            return True
+        with lock:
+            if remaining[0] <= 0:
+                return False
+            remaining[0] -= 1
+            return True
+
+    def worker(index: int):
+        worker_rng = random.Random(rng.random() + index)
+        while claim():
+            operation = picker.pick(worker_rng)
+            article_id = hot.pick(worker_rng)
+            user = worker_rng.randrange(workload.users)
+            began = time.perf_counter()
+            try:
+                target.perform(operation, article_id, user, worker_rng)
+                failed = None
+            except Exception as e:
+                failed = f"{type(e).__name__}: {e}"[:300]
+            latency = (time.perf_counter() - began) * 1000
+            with lock:
+                stats = operations[operation]
+                if failed:
+                    stats.errors += 1
+                    stats.first_error = stats.first_error or failed
+                else:
+                    stats.latencies_ms.append(latency)
+
+    with ThreadPoolExecutor(max_workers=workload.concurrency) as pool:
+        for future in [pool.submit(worker, index) for index in range(workload.concurrency)]:
+            future.result()
+
+    return Report(
+        target=target.describe(),
+        workload=workload,
+        elapsed_seconds=time.monotonic() - started,
+        operations=operations,
+        articles=len(target.article_ids),
+        hot_share=hot.hot_share(),
+    )
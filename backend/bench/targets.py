@@ -0,0 +1,200 @@
+"""
+Where a benchmark sends its operations
+
+A target seeds the users and articles a run needs, then performs operations
+by name. Each supports a subset of the operations in workload.OPERATIONS;
+the runner leaves the rest out of the mix. `perform` raises on failure,
+which counts as an error for that operation.
+"""
+
+import random
+import threading
+import uuid
+from contextlib import contextmanager
+from datetime import datetime
+from typing import Any, List, Optional
+
+from bench.workload import WORDS, Workload, synthetic_article, synthetic_user
+
+
+class ApiTarget:
+    """A running instance over HTTP, through the same endpoints the frontend calls
+
+    Latencies include the network, auth, rate limiting and serialization;
+    raise the rate limits on the instance or expect 429s.
+    """
+    name = 'api'
+    operations = ('read', 'stats', 'list', 'feed', 'search', 'view', 'like', 'bookmark')
+
+    def __init__(self, base_url: str, timeout: float = 15):
+        import requests
+
+        self.requests = requests
+        self.base_url = base_url.rstrip('/')
+        self.timeout = timeout
+        self.run_id = uuid.uuid4().hex[:8]
+        self.tokens: List[str] = []
+        self.article_ids: List[str] = []
+        self._local = threading.local()
+
+    def _session(self):
+        # Sessions aren't thread-safe; each worker keeps its own connections
+        if not hasattr(self._local, 'session'):
+            self._local.session = self.requests.Session()
+            self._local.session.headers['User-Agent'] = 'news-app-bench'
+        return self._local.session
+
+    def call(self, method: str, path: str, token: Optional[str] = None, expect: int = 200, **kwargs) -> Any:
+        headers = {'Authorization': f"Bearer {token}"} if token else {}
+        response = self._session().request(method, f"{self.base_url}{path}", headers=headers,
+                                           timeout=self.timeout, **kwargs)
+        if response.status_code != expect:
+            raise RuntimeError(f"{method} {path} answered {response.status_code}")
+        return response.json() if response.content else None
+
+    def setup(self, workload: Workload, rng: random.Random):
+        for index in range(workload.users):
+            user = synthetic_user(self.run_id, index)
+            body = self.call('POST', '/api/v1/auth/register', expect=201, json={**user, 'role': 'author'})
+            self.tokens.append(body['access_token'])
+
+        for index in range(workload.seed_articles):
+            token = self.tokens[index % len(self.tokens)]
+            values = synthetic_article(rng)
+            values.pop('created_at')
+            article = self.call('POST', '/api/v1/articles/', token=token, expect=201, json=values)
+            self.call('PUT', f"/api/v1/articles/{article['id']}", token=token, json={'status': 'published'})
+            self.article_ids.append(article['id'])
+
+        page = 1
+        while not workload.seed_articles and len(self.article_ids) < workload.pool_size:
+            body = self.call('GET', '/api/v1/articles/', params={'page': page, 'per_page': 100})
+            self.article_ids += [article['id'] for article in body['data']]
+            if len(body['data']) < 100:
+                break
+            page += 1
+        del self.article_ids[workload.pool_size:]
+
+    def perform(self, operation: str, article_id: str, user: int, rng: random.Random):
+        token = self.tokens[user]
+        if operation == 'read':
+            self.call('GET', f"/api/v1/articles/{article_id}", token=token)
+        elif operation == 'stats':
+            self.call('GET', f"/api/v1/interactions/{article_id}/status", token=token)
+        elif operation == 'list':
+            self.call('GET', '/api/v1/articles/', params={'page': rng.randint(1, 5), 'per_page': 20})
+        elif operation == 'feed':
+            self.call('GET', '/api/v1/feed/', token=token, params={'limit': 20})
+        elif operation == 'search':
+            self.call('POST', '/api/v1/search/', token=token, json={'query': rng.choice(WORDS), 'limit': 20})
+        elif operation == 'view':
+            self.call('POST', f"/api/v1/articles/{article_id}/view", token=token)
+        elif operation == 'like':
+            self.call('POST', f"/api/v1/interactions/{article_id}/like", token=token)
+        elif operation == 'bookmark':
+            self.call('POST', f"/api/v1/interactions/{article_id}/bookmark", token=token)
+
+    def describe(self) -> str:
+        return self.base_url
+
+
+class RepositoryTarget:
+    """The repositories of shared/repositories.py, without the HTTP stack
+
+    On PostgreSQL each operation is its own transaction, as in a request.
+    With `memory` the repositories are the in-memory ones, which measures
+    the harness and the handlers' logic rather than the database.
+    """
+    name = 'repository'
+    operations = ('read', 'stats', 'view', 'like', 'bookmark')
+
+    def __init__(self, memory: bool = False):
+        from shared.repositories import in_memory_repositories
+
+        self.memory = memory
+        self.run_id = uuid.uuid4().hex[:8]
+        self.user_ids: List[str] = []
+        self.article_ids: List[str] = []
+        self._repos = in_memory_repositories() if memory else None
+        # The in-memory repositories aren't thread-safe, so their operations take turns
+        self._memory_lock = threading.Lock()
+
+    @contextmanager
+    def transaction(self):
+        """The repositories one operation works with"""
+        if self.memory:
+            with self._memory_lock:
+                yield self._repos
+            return
+
+        from shared.database import get_postgres_cursor
+        from shared.repositories import repositories
+
+        with get_postgres_cursor() as cursor:
+            yield repositories(cursor)
+
+    def setup(self, workload: Workload, rng: random.Random):
+        password_hash = None
+        if not self.memory:
+            from shared.auth import hash_password
+
+            # Hashing is slow on purpose and nobody logs in as these users, so they share one
+            password_hash = hash_password(uuid.uuid4().hex)
+        with self.transaction() as repos:
+            for index in range(workload.users):
+                user = synthetic_user(self.run_id, index)
+                created = repos.users.create({
+                    'username': user['username'], 'email': user['email'], 'password_hash': password_hash,
+                    'role': 'author', 'created_at': datetime.now(),
+                })
+                self.user_ids.append(str(created['id']))
+
+            seed = workload.seed_articles or (workload.pool_size if self.memory else 0)
+            for index in range(seed):
+                values = synthetic_article(rng)
+                article = repos.articles.create({
+                    **values, 'author_id': self.user_ids[index % len(self.user_ids)], 'status': 'published',
+                    'published_at': values['created_at'], 'language': 'en',
+                })
+                self.article_ids.append(str(article['id']))
+
+            if not seed:
+                repos.cursor.execute("""
+                    SELECT id FROM articles WHERE status = 'published'
+                    ORDER BY published_at DESC LIMIT %s
+                """, (workload.pool_size,))
+                self.article_ids = [str(row['id']) for row in repos.cursor.fetchall()]
+
+    def perform(self, operation: str, article_id: str, user: int, rng: random.Random):
+        user_id = self.user_ids[user]
+        with self.transaction() as repos:
+            if operation == 'read':
+                if repos.articles.get(article_id, published_only=True) is None:
+                    raise LookupError(f"Article {article_id} not found")
+            elif operation == 'stats':
+                repos.articles.get_stats(article_id)
+            elif operation == 'view':
+                repos.interactions.record(user_id, article_id, 'view')
+                repos.articles.increment_counter(article_id, 'view_count')
+            elif operation == 'like':
+                # The toggle the like endpoint does
+                if repos.interactions.has(user_id, article_id, 'like'):
+                    repos.interactions.remove(user_id, article_id, 'like')
+                    repos.articles.increment_counter(article_id, 'like_count', -1)
+                else:
+                    repos.interactions.record(user_id, article_id, 'like')
+                    repos.articles.increment_counter(article_id, 'like_count')
+            elif operation == 'bookmark':
+                if repos.interactions.is_saved(user_id, article_id):
+                    repos.interactions.unsave(user_id, article_id)
+                else:
+                    repos.interactions.save(user_id, article_id)
+
+    def describe(self) -> str:
+        return 'in-memory repositories' if self.memory else 'PostgreSQL repositories'
+
+
+TARGETS = {
+    ApiTarget.name: ApiTarget,
+    RepositoryTarget.name: RepositoryTarget,
+}
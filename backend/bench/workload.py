@@ -0,0 +1,116 @@
+"""
+What a benchmark run does: the operation mix and which articles it hits
+"""
+
+import bisect
+import random
+import uuid
+from dataclasses import dataclass, field
+from datetime import datetime
+from typing import Any, Dict, List, Optional
+
+# Operations a target may support; reads don't change anything
+READS = ('read', 'stats', 'list', 'feed', 'search')
+WRITES = ('view', 'like', 'bookmark')
+OPERATIONS = READS + WRITES
+
+DEFAULT_MIX = {'read': 60, 'list': 10, 'feed': 10, 'view': 15, 'like': 3, 'bookmark': 2}
+
+CATEGORIES = ('technology', 'politics', 'business', 'science', 'health', 'sports', 'culture')
+WORDS = (
+    'network', 'ledger', 'policy', 'market', 'climate', 'research', 'election', 'privacy', 'energy', 'health',
+    'startup', 'protocol', 'community', 'report', 'analysis', 'governance', 'security', 'economy', 'science', 'data',
+)
+
+
+def parse_mix(spec: Optional[str]) -> Dict[str, float]:
+    """`read=60,view=30,like=10` as relative weights; the default mix when empty"""
+    if not spec:
+        return dict(DEFAULT_MIX)
+    mix = {}
+    for part in spec.split(','):
+        name, _, weight = part.partition('=')
+        name = name.strip()
+        if name not in OPERATIONS:
+            raise ValueError(f"Unknown operation {name!r}; operations are {', '.join(OPERATIONS)}")
+        mix[name] = float(weight or 1)
+        if mix[name] < 0:
+            raise ValueError(f"Weight of {name} must not be negative")
+    return mix
+
+
+@dataclass
+class Workload:
+    mix: Dict[str, float] = field(default_factory=lambda: dict(DEFAULT_MIX))
+    concurrency: int = 8
+    duration_seconds: float = 30.0
+    max_operations: Optional[int] = None  # Stop after this many, whichever comes first
+    skew: float = 1.1  # Zipf exponent of article popularity; 0 hits every article equally
+    users: int = 20  # Synthetic readers doing the writes
+    seed_articles: int = 0  # Synthetic articles to publish first; 0 uses existing published articles
+    pool_size: int = 1000  # Most articles the run picks from
+    random_seed: Optional[int] = None
+
+    def ratio(self, supported) -> Dict[str, float]:
+        """The mix restricted to the operations a target supports, as fractions"""
+        weights = {name: weight for name, weight in self.mix.items() if name in supported and weight > 0}
+        total = sum(weights.values())
+        if not total:
+            raise ValueError("None of the operations in the mix are supported by this target")
+        return {name: weight / total for name, weight in weights.items()}
+
+
+class HotSet:
+    """Picks articles with Zipf-distributed popularity: the first few get most of the traffic"""
+
+    def __init__(self, article_ids: List[str], skew: float, rng: random.Random):
+        self.article_ids = list(article_ids)
+        rng.shuffle(self.article_ids)
+        weights = [1 / (rank ** skew) for rank in range(1, len(self.article_ids) + 1)]
+        total = sum(weights)
+        self.cumulative = []
+        running = 0.0
+        for weight in weights:
+            running += weight / total
+            self.cumulative.append(running)
+
+    def pick(self, rng: random.Random) -> str:
+        index = bisect.bisect_left(self.cumulative, rng.random())
+        return self.article_ids[min(index, len(self.article_ids) - 1)]
+
+    def hot_share(self, top: float = 0.01) -> float:
+        """The share of picks that land on the top `top` fraction of articles"""
+        count = max(1, int(len(self.cumulative) * top))
+        return self.cumulative[count - 1]
+
+
+class OperationPicker:
+    def __init__(self, ratio: Dict[str, float]):
+        self.names = list(ratio)
+        self.cumulative = []
+        running = 0.0
+        for name in self.names:
+            running += ratio[name]
+            self.cumulative.append(running)
+
+    def pick(self, rng: random.Random) -> str:
+        index = bisect.bisect_left(self.cumulative, rng.random() * self.cumulative[-1])
+        return self.names[min(index, len(self.names) - 1)]
+
+
+def synthetic_user(run_id: str, index: int) -> Dict[str, Any]:
+    name = f"bench-{run_id}-{index}"
+    return {'username': name, 'email': f"{name}@example.com", 'password': f"Bench-{uuid.uuid4().hex}"}
+
+
+def synthetic_article(rng: random.Random) -> Dict[str, Any]:
+    words = lambda count: ' '.join(rng.choice(WORDS) for _ in range(count))  # noqa: E731
+    paragraphs = ''.join(f"<p>{words(rng.randint(40, 120)).capitalize()}.</p>" for _ in range(rng.randint(3, 8)))
+    return {
+        'title': words(rng.randint(4, 9)).capitalize(),
+        'summary': words(20).capitalize() + '.',
+        'content': paragraphs,
+        'category': rng.choice(CATEGORIES),
+        'tags': ['bench'] + rng.sample(WORDS, 2),
+        'created_at': datetime.now(),
+    }
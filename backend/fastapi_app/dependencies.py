@@ -4,9 +4,10 @@ FastAPI dependencies for authentication and common functionality
 
 import sys
 import os
-from typing import Optional
-from fastapi import HTTPException, Depends, status
-from fastapi.security import HTTPBearer, HTTPAuthorizationCredentials
+import math
+from typing import Generator, Optional, Sequence
+from fastapi import HTTPException, Depends, Request, status
+from fastapi.security import HTTPBearer, HTTPAuthorizationCredentials, APIKeyHeader
 
 # Add parent directory to path for imports
 sys.path.append(os.path.join(os.path.dirname(__file__), '..'))
@@ -14,12 +15,24 @@ sys.path.append(os.path.join(os.path.dirname(__file__), '..'))
 from shared.database import get_postgres_cursor
 from shared.auth import auth_manager
 from shared.models import UserResponse
+from shared.syndication import authenticate_partner
+from shared.api_keys import authenticate_api_key, crawl_delay_remaining
+from shared.oauth import has_delegated_access
+from shared.repositories import Repositories, repositories
+from shared.audit import note_actor
+from shared import permissions
 
 security = HTTPBearer()
+api_key_header = APIKeyHeader(name="X-API-Key", auto_error=False)
 
 
-async def get_current_user(credentials: HTTPAuthorizationCredentials = Depends(security)) -> dict:
-    """Get current authenticated user"""
+async def _authenticate(request: Request, credentials: Optional[HTTPAuthorizationCredentials],
+                        scopes: Optional[Sequence[str]] = None) -> dict:
+    """Resolve the user behind a bearer token
+    
+    Delegated OAuth tokens are only accepted when `scopes` is given, and must
+    carry every one of them under a grant the user hasn't revoked.
+    """
     if not credentials:
         raise HTTPException(
             status_code=status.HTTP_401_UNAUTHORIZED,
@@ -27,29 +40,61 @@ async def get_current_user(credentials: HTTPAuthorizationCredentials = Depends(s
             headers={"WWW-Authenticate": "Bearer"},
         )
     
-    user_data = auth_manager.get_user_from_token(credentials.credentials)
-    if not user_data:
+    claims = auth_manager.verify_token(credentials.credentials)
+    user_data = auth_manager.get_user_from_token(credentials.credentials, allow_delegated=True)
+    if not claims or not user_data:
         raise HTTPException(
             status_code=status.HTTP_401_UNAUTHORIZED,
             detail="Invalid or expired token",
             headers={"WWW-Authenticate": "Bearer"},
         )
     
+    delegated = auth_manager.is_delegated(claims)
+    if delegated and scopes is None:
+        raise HTTPException(
+            status_code=status.HTTP_403_FORBIDDEN,
+            detail="This endpoint is not available to third-party applications"
+        )
+    
     # Get fresh user data from database
     with get_postgres_cursor() as cursor:
-        cursor.execute(
-            "SELECT * FROM users WHERE id = %s AND is_active = true",
-            (user_data['id'],)
-        )
-        
-        user_record = cursor.fetchone()
+        user_record = repositories(cursor).users.get_by_id(user_data['id'])
         if not user_record:
             raise HTTPException(
                 status_code=status.HTTP_404_NOT_FOUND,
                 detail="User not found"
             )
+        if delegated and not has_delegated_access(cursor, claims, scopes):
+            required = ' '.join(scopes)
+            raise HTTPException(
+                status_code=status.HTTP_403_FORBIDDEN,
+                detail=f"Token lacks the required scope: {required}",
+                headers={"WWW-Authenticate": f'Bearer error="insufficient_scope", scope="{required}"'},
+            )
     
-    return dict(user_record)
+    # Picked up by the API usage middleware once the response is sent
+    request.state.token_claims = claims
+    # Changes by administrators and auditors are audited, whichever handler makes them
+    note_actor(user_record)
+    return user_record
+
+
+async def get_current_user(request: Request, credentials: HTTPAuthorizationCredentials = Depends(security)) -> dict:
+    """Get current authenticated user (full-session tokens only)"""
+    return await _authenticate(request, credentials)
+
+
+def require_scopes(*scopes: str):
+    """Dependency accepting a full-session token, or a delegated OAuth token granted all of `scopes`"""
+    async def scoped_user(request: Request, credentials: HTTPAuthorizationCredentials = Depends(security)) -> dict:
+        return await _authenticate(request, credentials, scopes)
+    return scoped_user
+
+
+def get_repositories() -> Generator[Repositories, None, None]:
+    """Repositories sharing one transaction for the request; override to use in-memory ones"""
+    with get_postgres_cursor() as cursor:
+        yield repositories(cursor)
 
 
 async def get_admin_user(current_user: dict = Depends(get_current_user)) -> dict:
@@ -62,6 +107,42 @@ async def get_admin_user(current_user: dict = Depends(get_current_user)) -> dict
     return current_user
 
 
+async def get_auditor_user(current_user: dict = Depends(get_current_user)) -> dict:
+    """Require auditor (or admin) privileges"""
+    if current_user.get('role') not in ('auditor', 'administrator'):
+        raise HTTPException(
+            status_code=status.HTTP_403_FORBIDDEN,
+            detail="Auditor privileges required"
+        )
+    return current_user
+
+
+def require_permission(*required: str):
+    """Dependency accepting a signed-in user who holds every one of `required` (shared/permissions.py)"""
+    async def permitted_user(current_user: dict = Depends(get_current_user)) -> dict:
+        held = permissions.effective(current_user)
+        missing = [permission for permission in required if permission not in held]
+        if missing:
+            raise HTTPException(
+                status_code=status.HTTP_403_FORBIDDEN,
+                detail=f"Missing permission: {', '.join(missing)}"
+            )
+        return current_user
+    return permitted_user
+
+
+async def get_deployment_admin(admin_user: dict = Depends(get_admin_user)) -> dict:
+    """Require an administrator of the default publication, for work spanning every publication"""
+    from shared import tenancy
+
+    if tenancy.current():
+        raise HTTPException(
+            status_code=status.HTTP_403_FORBIDDEN,
+            detail="Only administrators of the default publication can do this"
+        )
+    return admin_user
+
+
 async def get_optional_user(credentials: Optional[HTTPAuthorizationCredentials] = Depends(HTTPBearer(auto_error=False))) -> Optional[dict]:
     """Get current user if authenticated, None otherwise"""
     if not credentials:
@@ -73,15 +154,81 @@ async def get_optional_user(credentials: Optional[HTTPAuthorizationCredentials]
             return None
         
         with get_postgres_cursor() as cursor:
-            cursor.execute(
-                "SELECT * FROM users WHERE id = %s AND is_active = true",
-                (user_data['id'],)
+            return repositories(cursor).users.get_by_id(user_data['id'])
+    except Exception:
+        return None
+
+
+async def get_syndication_partner(api_key: Optional[str] = Depends(api_key_header)) -> dict:
+    """Require a valid syndication partner API key"""
+    if not api_key:
+        raise HTTPException(
+            status_code=status.HTTP_401_UNAUTHORIZED,
+            detail="X-API-Key header required"
+        )
+
+    with get_postgres_cursor() as cursor:
+        partner = authenticate_partner(cursor, api_key)
+
+    if not partner:
+        raise HTTPException(
+            status_code=status.HTTP_401_UNAUTHORIZED,
+            detail="Invalid or revoked API key"
+        )
+    return partner
+
+
+def require_api_key(*scopes: str):
+    """Dependency requiring a service API key issued for all of `scopes`, enforcing its crawl delay"""
+    async def api_key_auth(request: Request, api_key: Optional[str] = Depends(api_key_header)) -> dict:
+        if not api_key:
+            raise HTTPException(
+                status_code=status.HTTP_401_UNAUTHORIZED,
+                detail="X-API-Key header required"
             )
-            
-            user_record = cursor.fetchone()
-            if not user_record:
-                return None
-        
-        return dict(user_record)
+
+        with get_postgres_cursor() as cursor:
+            key = authenticate_api_key(cursor, api_key, request.client.host if request.client else None)
+
+        if not key:
+            raise HTTPException(
+                status_code=status.HTTP_401_UNAUTHORIZED,
+                detail="Invalid, expired or revoked API key"
+            )
+        missing = sorted(set(scopes) - set(key['scopes']))
+        if missing:
+            raise HTTPException(
+                status_code=status.HTTP_403_FORBIDDEN,
+                detail=f"API key lacks the required scope: {' '.join(missing)}"
+            )
+
+        wait = crawl_delay_remaining(key)
+        if wait:
+            raise HTTPException(
+                status_code=status.HTTP_429_TOO_MANY_REQUESTS,
+                detail=f"Crawl delay for this key is {float(key['crawl_delay_seconds']):g} seconds",
+                headers={"Retry-After": str(math.ceil(wait))}
+            )
+
+        request.state.api_key = key
+        return key
+    return api_key_auth
+
+
+def get_websocket_user(token: Optional[str]) -> Optional[dict]:
+    """Resolve the user behind a WebSocket's `token` query parameter (full-session tokens only)
+
+    Browsers can't set an Authorization header on a WebSocket handshake.
+    """
+    if not token:
+        return None
+
+    try:
+        user_data = auth_manager.get_user_from_token(token)
+        if not user_data:
+            return None
+
+        with get_postgres_cursor() as cursor:
+            return repositories(cursor).users.get_by_id(user_data['id'])
     except Exception:
-        return None
\ No newline at end of file
+        return None
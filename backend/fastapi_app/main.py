@@ -5,14 +5,19 @@ Production-ready FastAPI application with comprehensive route handlers
 
 import os
 import sys
+import asyncio
 from datetime import datetime
 from contextlib import asynccontextmanager
 import logging
 
 from fastapi import FastAPI, Request, HTTPException
+from fastapi.encoders import jsonable_encoder
+from fastapi.exceptions import RequestValidationError
+from fastapi.routing import APIRoute
 from fastapi.middleware.cors import CORSMiddleware
 from fastapi.middleware.trustedhost import TrustedHostMiddleware
 from fastapi.responses import JSONResponse
+from fastapi.staticfiles import StaticFiles
 from starlette.exceptions import HTTPException as StarletteHTTPException
 import uvicorn
 from dotenv import load_dotenv
@@ -21,7 +26,17 @@ from dotenv import load_dotenv
 sys.path.append(os.path.join(os.path.dirname(__file__), '..'))
 
 from shared.database import db_manager
+from shared.request_context import QueryCancellationMiddleware, RequestDeadlineExceeded
+from shared.paywall import PaywallMiddleware
+from shared.bot_detection import BotDetectionMiddleware
+from shared.lite import LiteResponseMiddleware
+from shared.sandbox import SandboxMiddleware
+from shared.tenancy import TenancyMiddleware
+from shared.audit import PrivilegedAuditMiddleware
+from shared.permissions import PermissionMiddleware
 from shared.models import ErrorResponse
+from shared.media import MEDIA_ROOT
+from shared.pii import install_log_scrubbing, redact, scrub
 
 # Load environment variables
 load_dotenv()
@@ -31,27 +46,85 @@ logging.basicConfig(
     level=logging.INFO,
     format='%(asctime)s - %(name)s - %(levelname)s - %(message)s'
 )
+install_log_scrubbing()
 logger = logging.getLogger(__name__)
 
 
+async def run_webhook_worker():
+    """Deliver due webhooks in the background until shutdown"""
+    from shared.webhooks import webhook_dispatcher
+    
+    interval = float(os.getenv('WEBHOOK_POLL_SECONDS', 5))
+    while True:
+        try:
+            delivered = await asyncio.to_thread(webhook_dispatcher.deliver_due)
+            if delivered:
+                continue
+        except Exception as e:
+            logger.error(f"Webhook worker error: {e}")
+        await asyncio.sleep(interval)
+
+
 @asynccontextmanager
 async def lifespan(app: FastAPI):
     """Application lifespan events"""
     # Startup
     logger.info("FastAPI application starting up...")
+
+    # Startup work and the background workers started here serve every publication; requests are held to their own
+    from shared import tenancy
+    tenancy.begin_unscoped()
     
-    # Test database connections on startup
-    try:
-        from shared.database import test_all_connections
-        connection_results = test_all_connections()
-        logger.info(f"Database connection test results: {connection_results}")
-    except Exception as e:
-        logger.error(f"Database connection test failed: {e}")
+    # Wait for the databases; under docker-compose they may still be starting
+    from shared.database import wait_for_dependencies
+    connection_results = await asyncio.to_thread(wait_for_dependencies)
+    logger.info(f"Database connection test results: {connection_results}")
+    if not connection_results['postgresql']:
+        raise RuntimeError("PostgreSQL did not become available within POSTGRES_STARTUP_MAX_WAIT_SECONDS")
+    
+    # Apply pending schema migrations when AUTO_MIGRATE is enabled
+    from shared.migrations import auto_migrate
+    await asyncio.to_thread(auto_migrate)
+
+    # Publications are only kept apart if the database enforces row-level security for this connection's role
+    if tenancy.enabled():
+        await asyncio.to_thread(tenancy.check_isolation)
+    
+    webhook_worker = None
+    if os.getenv('WEBHOOK_WORKER_ENABLED', 'true').lower() == 'true':
+        webhook_worker = asyncio.create_task(run_webhook_worker())
+    
+    from shared.events import outbox_relay
+    event_relay = asyncio.create_task(outbox_relay.run()) if outbox_relay.enabled else None
+
+    # Event contracts go to the schema registry in the background; a registry that's down only logs errors
+    from shared import event_schemas
+    if event_schemas.REGISTRY_URL:
+        asyncio.create_task(asyncio.to_thread(event_schemas.register_all))
+
+    # Analytics background sinks only run when the analytics module is enabled
+    from shared import clickstream, telemetry
+    from shared.modules import is_enabled
+    analytics_enabled = is_enabled('analytics')
+    clickstream_sink = asyncio.create_task(clickstream.run()) if analytics_enabled and clickstream.enabled() else None
+    telemetry_flusher = asyncio.create_task(telemetry.run()) if analytics_enabled else None
     
     yield
     
     # Shutdown
     logger.info("FastAPI application shutting down...")
+    if webhook_worker:
+        webhook_worker.cancel()
+    if event_relay:
+        event_relay.cancel()
+    if clickstream_sink:
+        clickstream_sink.cancel()
+    if telemetry_flusher:
+        telemetry_flusher.cancel()
+        try:
+            await telemetry_flusher
+        except asyncio.CancelledError:
+            pass
     try:
         db_manager.close_connections()
         logger.info("Database connections closed successfully")
@@ -59,18 +132,57 @@ async def lifespan(app: FastAPI):
         logger.error(f"Error closing database connections: {e}")
 
 
+def generate_operation_id(route: APIRoute) -> str:
+    """Stable operation ids (tag + handler name) so generated SDK method names don't churn"""
+    tag = route.tags[0].lower().replace(' ', '_') if route.tags else 'default'
+    return f"{tag}_{route.name}"
+
+
 def create_app() -> FastAPI:
     """Application factory"""
+    # Interactive docs are only served outside production; the spec itself stays
+    # available so clients can generate typed SDKs against a live deployment
+    is_production = os.getenv('ENVIRONMENT', 'development') == 'production'
+
     app = FastAPI(
         title="Decentralized News Platform API",
         description="FastAPI backend for decentralized news application with ML-powered recommendations",
         version="1.0.0",
-        docs_url="/api/v1/docs",
-        redoc_url="/api/v1/redoc",
+        docs_url=None if is_production else "/docs",
+        redoc_url=None if is_production else "/redoc",
         openapi_url="/api/v1/openapi.json",
+        generate_unique_id_function=generate_operation_id,
         lifespan=lifespan
     )
     
+    # Audits changes made by administrators and auditors; innermost so it runs in the request's tenant and sandbox
+    app.add_middleware(PrivilegedAuditMiddleware)
+
+    # Works out each user's permissions once per request
+    app.add_middleware(PermissionMiddleware)
+
+    # Request deadlines; cancels in-flight queries when the client disconnects
+    app.add_middleware(QueryCancellationMiddleware)
+
+    # Who is reading, so premium article content is only returned to entitled readers
+    app.add_middleware(PaywallMiddleware)
+
+    # Classifies bots, trips honeypots and serves bots cached responses before anything else runs
+    app.add_middleware(BotDetectionMiddleware)
+
+    # Strips heavy fields and shrinks pages for low-bandwidth clients; outermost so bot-cached responses are shaped too
+    app.add_middleware(LiteResponseMiddleware)
+
+    from shared.modules import is_enabled
+
+    # Serves each request for the publication its X-Tenant header or host names, applying its request quota
+    if is_enabled('tenancy'):
+        app.add_middleware(TenancyMiddleware)
+
+    # Serves requests carrying a sandbox key from the sandbox tenant; outermost so everything after sees the tenant
+    if is_enabled('sandbox'):
+        app.add_middleware(SandboxMiddleware)
+
     # Security middleware
     app.add_middleware(TrustedHostMiddleware, allowed_hosts=["*"])
     
@@ -82,6 +194,8 @@ def create_app() -> FastAPI:
         allow_credentials=True,
         allow_methods=["*"],
         allow_headers=["*"],
+        # Lets browser clients verify signed responses and poll feeds conditionally
+        expose_headers=["Content-Digest", "Signature", "Signature-Input", "ETag"],
     )
     
     # Custom middleware with proper error handling
@@ -105,6 +219,23 @@ def create_app() -> FastAPI:
                 }
             )
     
+    @app.middleware("http")
+    async def record_api_usage(request: Request, call_next):
+        start_time = datetime.now()
+        response = await call_next(request)
+        claims = getattr(request.state, 'token_claims', None)
+        if claims:
+            from shared.tokens import record_request
+            from shared.cohorts import record_visit
+            record_request(
+                claims, request.method, request.url.path, response.status_code,
+                request.client.host if request.client else None,
+                request.headers.get('user-agent'),
+                (datetime.now() - start_time).total_seconds() * 1000
+            )
+            record_visit(claims.get('user_id'))
+        return response
+    
     @app.middleware("http")
     async def security_headers(request: Request, call_next):
         try:
@@ -122,19 +253,85 @@ def create_app() -> FastAPI:
     
     # Import and include routers
     try:
-        from .routers import auth, users, articles, interactions, recommendations, search, analytics, health, donations
+        from shared.modules import router_enabled, summary as module_summary
+
+        def mount(router_module, **kwargs):
+            name = router_module.__name__.rsplit('.', 1)[-1]
+            if router_enabled(name):
+                app.include_router(router_module.router, **kwargs)
+            else:
+                logger.info(f"Routes of {name} not mounted: its module is disabled")
+
+        from .routers import auth, users, articles, interactions, recommendations, search, analytics, health, donations, settings, feed, curation, collections, syndication, webhooks, discussion, jobs, corrections, oauth, public_feeds, organizations, branding, reviews, node, api_keys, federation, collab, ingest, draft_comments, feed_imports, activitypub, experiments, live_readers, paywall, subscriptions, cohorts, tips, payouts, funnels, reports, warehouse, fact_checks, governance, robots, events, notifications, stream, push, newsletters, media, taxonomy, consistency, sandbox, screening, tenants, audit, replays, permissions, dead_letters, publication, editorial, event_schemas, coauthors
         
-        app.include_router(auth.router, prefix="/api/v1/auth", tags=["Authentication"])
-        app.include_router(users.router, prefix="/api/v1/users", tags=["Users"])
-        app.include_router(articles.router, prefix="/api/v1/articles", tags=["Articles"])
-        app.include_router(interactions.router, prefix="/api/v1/interactions", tags=["Interactions"])
-        app.include_router(recommendations.router, prefix="/api/v1/recommendations", tags=["Recommendations"])
-        app.include_router(search.router, prefix="/api/v1/search", tags=["Search"])
-        app.include_router(analytics.router, prefix="/api/v1/analytics", tags=["Analytics"])
-        app.include_router(health.router, prefix="/api/v1/health", tags=["Health"])
-        app.include_router(donations.router, prefix="/api/v1/donations", tags=["Donations"])
+        mount(auth, prefix="/api/v1/auth", tags=["Authentication"])
+        mount(users, prefix="/api/v1/users", tags=["Users"])
+        mount(articles, prefix="/api/v1/articles", tags=["Articles"])
+        mount(interactions, prefix="/api/v1/interactions", tags=["Interactions"])
+        mount(recommendations, prefix="/api/v1/recommendations", tags=["Recommendations"])
+        mount(search, prefix="/api/v1/search", tags=["Search"])
+        mount(analytics, prefix="/api/v1/analytics", tags=["Analytics"])
+        mount(events, prefix="/api/v1/events", tags=["Telemetry"])
+        mount(health, prefix="/api/v1/health", tags=["Health"])
+        mount(donations, prefix="/api/v1/donations", tags=["Donations"])
+        mount(settings, prefix="/api/v1/settings", tags=["Settings"])
+        mount(feed, prefix="/api/v1/feed", tags=["Feed"])
+        mount(stream, prefix="/api/v1/stream", tags=["Stream"])
+        mount(curation, prefix="/api/v1/curation", tags=["Curation"])
+        mount(collections, prefix="/api/v1/collections", tags=["Collections"])
+        mount(syndication, prefix="/api/v1/syndication", tags=["Syndication"])
+        mount(webhooks, prefix="/api/v1/webhooks", tags=["Webhooks"])
+        mount(discussion, prefix="/api/v1/articles", tags=["Discussion"])
+        mount(jobs, prefix="/api/v1/admin/jobs", tags=["Jobs"])
+        mount(consistency, prefix="/api/v1/admin/consistency", tags=["Consistency"])
+        mount(corrections, prefix="/api/v1/corrections", tags=["Corrections"])
+        mount(fact_checks, prefix="/api/v1/fact-checks", tags=["Fact Checks"])
+        mount(governance, prefix="/api/v1/governance", tags=["Governance"])
+        mount(oauth, prefix="/api/v1/oauth", tags=["OAuth"])
+        mount(public_feeds, prefix="/feeds", tags=["Feeds"])
+        mount(organizations, prefix="/api/v1/admin/organizations", tags=["Organizations"])
+        mount(tenants, prefix="/api/v1/admin/tenants", tags=["Tenants"])
+        mount(branding, prefix="/api/v1/branding", tags=["Branding"])
+        mount(reviews, prefix="/api/v1/admin/reviews", tags=["Reviews"])
+        mount(reports, prefix="/api/v1/admin/reports", tags=["Reports"])
+        mount(screening, prefix="/api/v1/admin/screening", tags=["Screening"])
+        mount(audit, prefix="/api/v1/admin/audit", tags=["Audit"])
+        mount(replays, prefix="/api/v1/admin/replays", tags=["Replays"])
+        mount(permissions, prefix="/api/v1/admin/permissions", tags=["Permissions"])
+        mount(dead_letters, prefix="/api/v1/admin/dead-letters", tags=["Dead Letters"])
+        mount(publication, prefix="/api/v1/publication", tags=["Publication"])
+        mount(editorial, prefix="/api/v1", tags=["Editorial"])
+        mount(coauthors, prefix="/api/v1", tags=["Co-authors"])
+        mount(event_schemas, prefix="/api/v1/event-schemas", tags=["Event Schemas"])
+        mount(node, prefix="/api/v1/node", tags=["Node"])
+        mount(api_keys, prefix="/api/v1/admin/api-keys", tags=["API Keys"])
+        mount(federation, prefix="/api/v1/admin/federation", tags=["Federation"])
+        mount(feed_imports, prefix="/api/v1/admin/feeds", tags=["Feed Import"])
+        mount(collab, prefix="/api/v1/articles", tags=["Collaborative Editing"])
+        mount(draft_comments, prefix="/api/v1/articles", tags=["Draft Comments"])
+        mount(experiments, prefix="/api/v1/articles", tags=["Headline Tests"])
+        mount(live_readers, prefix="/api/v1/articles", tags=["Live Readers"])
+        mount(paywall, prefix="/api/v1/articles", tags=["Paywall"])
+        mount(subscriptions, prefix="/api/v1/admin/subscriptions", tags=["Subscriptions"])
+        mount(cohorts, prefix="/api/v1/admin/analytics", tags=["Cohorts"])
+        mount(funnels, prefix="/api/v1/admin/analytics", tags=["Funnels"])
+        mount(warehouse, prefix="/api/v1/admin/warehouse", tags=["Warehouse"])
+        mount(tips, prefix="/api/v1/tips", tags=["Tips"])
+        mount(payouts, prefix="/api/v1/admin/payouts", tags=["Payouts"])
+        mount(ingest, prefix="/api/v1/ingest", tags=["Ingestion"])
+        mount(activitypub, tags=["ActivityPub"])
+        mount(robots, tags=["Robots"])
+        mount(notifications, tags=["Notifications"])
+        mount(push, prefix="/api/v1/push", tags=["Push Notifications"])
+        mount(newsletters, prefix="/api/v1/newsletters", tags=["Newsletters"])
+        mount(media, prefix="/api/v1/media", tags=["Media"])
+        mount(taxonomy, prefix="/api/v1", tags=["Tags and Categories"])
+        mount(sandbox, prefix="/api/v1", tags=["Sandbox"])
         
-        logger.info("All routers included successfully")
+        logger.info(f"All routers included successfully; modules: {module_summary()}")
+
+        # Files from the media store, such as generated share cards
+        app.mount("/media", StaticFiles(directory=MEDIA_ROOT, check_dir=False), name="media")
     except ImportError as e:
         logger.error(f"Failed to import routers: {e}")
         # You might want to include only the routers that exist
@@ -152,10 +349,11 @@ def create_app() -> FastAPI:
             status_code=exc.status_code,
             content={
                 "success": False,
-                "message": str(exc.detail),
+                "message": scrub(str(exc.detail)),
                 "error_code": f"HTTP_{exc.status_code}",
                 "timestamp": datetime.now().isoformat()
-            }
+            },
+            headers=getattr(exc, 'headers', None)
         )
     
     @app.exception_handler(HTTPException)
@@ -165,9 +363,39 @@ def create_app() -> FastAPI:
             status_code=exc.status_code,
             content={
                 "success": False,
-                "message": str(exc.detail),
+                "message": scrub(str(exc.detail)),
                 "error_code": f"HTTP_{exc.status_code}",
                 "timestamp": datetime.now().isoformat()
+            },
+            headers=getattr(exc, 'headers', None)
+        )
+    
+    @app.exception_handler(RequestValidationError)
+    async def validation_exception_handler(request: Request, exc: RequestValidationError):
+        """FastAPI's 422 body, without echoing passwords, tokens or other people's addresses back"""
+        errors = []
+        for error in jsonable_encoder(exc.errors()):
+            if 'input' in error:
+                # Redact under the field's name so a `password` or `email` input is masked by key
+                field = error['loc'][-1] if error.get('loc') else None
+                if isinstance(field, str):
+                    error['input'] = redact({field: error['input']})[field]
+                else:
+                    error['input'] = redact(error['input'])
+            errors.append(error)
+        return JSONResponse(status_code=422, content={"detail": errors})
+    
+    @app.exception_handler(RequestDeadlineExceeded)
+    async def deadline_exception_handler(request: Request, exc: RequestDeadlineExceeded):
+        """Request ran out of time before its next query"""
+        logger.warning(f"{request.method} {request.url.path}: {exc}")
+        return JSONResponse(
+            status_code=504,
+            content={
+                "success": False,
+                "message": "Request timed out",
+                "error_code": "REQUEST_TIMEOUT",
+                "timestamp": datetime.now().isoformat()
             }
         )
     
@@ -191,7 +419,7 @@ def create_app() -> FastAPI:
         return {
             "message": "Decentralized News Platform FastAPI",
             "version": "1.0.0",
-            "docs": "/api/v1/docs",
+            "docs": app.docs_url,
             "health": "/api/v1/health",
             "timestamp": datetime.now().isoformat()
         }
@@ -0,0 +1,177 @@
+"""
+ActivityPub and WebFinger routes for FastAPI backend
+"""
+
+import sys
+import os
+import json
+from typing import Optional
+from fastapi import APIRouter, HTTPException, Request, Query, status
+from fastapi.responses import JSONResponse, RedirectResponse
+import logging
+
+sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
+
+from shared.database import get_postgres_cursor
+from shared.activitypub import (
+    CONTENT_TYPE, SignatureError, actor_document, actor_keys, actor_url, article_object, followers_collection,
+    get_actor_user, handle_activity, outbox, verify_request, webfinger
+)
+from shared.aliases import AliasLoop, resolve_user
+from shared.jobs import deliver_activity
+
+router = APIRouter()
+logger = logging.getLogger(__name__)
+
+MAX_ACTIVITY_BYTES = 256 * 1024
+
+
+def activity_response(document: dict, status_code: int = 200) -> JSONResponse:
+    return JSONResponse(
+        content=json.loads(json.dumps(document, default=str)), status_code=status_code, media_type=CONTENT_TYPE
+    )
+
+
+def get_actor_or_404(cursor, username: str) -> dict:
+    user = get_actor_user(cursor, username)
+    if not user:
+        raise HTTPException(status_code=404, detail="Actor not found")
+    return user
+
+
+@router.get("/.well-known/webfinger")
+async def get_webfinger(resource: str = Query(...)):
+    """Resolve `acct:username@domain` to the author's actor"""
+    try:
+        with get_postgres_cursor() as cursor:
+            document = webfinger(cursor, resource)
+        if not document:
+            raise HTTPException(status_code=404, detail="Resource not found")
+        return JSONResponse(content=document, media_type="application/jrd+json")
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"WebFinger error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to resolve resource")
+
+
+@router.get("/ap/users/{username}")
+async def get_actor(username: str):
+    """The author as an ActivityPub Person; an old username answers with a 301 to the current actor"""
+    try:
+        with get_postgres_cursor() as cursor:
+            user = get_actor_user(cursor, username)
+            if not user:
+                resolved = resolve_user(cursor, username, follow_moves=False)
+                if resolved and resolved['username'] != username:
+                    return RedirectResponse(actor_url(resolved['username']), status_code=301)
+                raise HTTPException(status_code=404, detail="Actor not found")
+            keys = actor_keys(cursor, user['id'])
+        return activity_response(actor_document(user, keys['public_key_pem']))
+    except HTTPException:
+        raise
+    except AliasLoop as e:
+        logger.warning(f"Redirect loop resolving actor {username}: {e}")
+        raise HTTPException(status_code=508, detail="Redirect loop")
+    except Exception as e:
+        logger.error(f"Get actor error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve actor")
+
+
+@router.get("/ap/users/{username}/outbox")
+async def get_outbox(username: str, page: Optional[int] = Query(None, ge=1)):
+    """The author's published articles as Create activities, paged"""
+    try:
+        with get_postgres_cursor() as cursor:
+            user = get_actor_or_404(cursor, username)
+            return activity_response(outbox(cursor, user, page))
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Get outbox error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve outbox")
+
+
+@router.get("/ap/users/{username}/followers")
+async def get_followers(username: str):
+    """How many Fediverse accounts follow the author"""
+    try:
+        with get_postgres_cursor() as cursor:
+            user = get_actor_or_404(cursor, username)
+            return activity_response(followers_collection(cursor, user))
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Get followers error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve followers")
+
+
+@router.get("/ap/articles/{article_id}")
+async def get_article_object(article_id: str):
+    """A published article as an ActivityPub Article"""
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute("""
+                SELECT a.*, u.username FROM articles a
+                JOIN users u ON a.author_id = u.id
+                WHERE a.id = %s AND a.status = 'published' AND a.anonymous_author = false
+                AND COALESCE(u.anonymous_mode, false) = false
+            """, (article_id,))
+            article = cursor.fetchone()
+        if not article:
+            raise HTTPException(status_code=404, detail="Article not found")
+        document = article_object(dict(article), article['username'])
+        document['@context'] = 'https://www.w3.org/ns/activitystreams'
+        return activity_response(document)
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Get article object error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve article")
+
+
+async def receive_activity(request: Request, username: Optional[str] = None) -> JSONResponse:
+    body = await request.body()
+    if len(body) > MAX_ACTIVITY_BYTES:
+        raise HTTPException(status_code=413, detail="Activity too large")
+    try:
+        activity = json.loads(body)
+    except ValueError:
+        raise HTTPException(status_code=400, detail="Activity must be JSON")
+    if not isinstance(activity, dict) or not activity.get('type'):
+        raise HTTPException(status_code=400, detail="Not an activity")
+
+    path = request.url.path + (f"?{request.url.query}" if request.url.query else '')
+    try:
+        signer = verify_request(request.method, path, dict(request.headers), body)
+    except SignatureError as e:
+        logger.info(f"Rejected activity {activity.get('id')}: {e}")
+        raise HTTPException(status_code=401, detail="Invalid signature")
+
+    try:
+        with get_postgres_cursor() as cursor:
+            user = get_actor_or_404(cursor, username) if username else None
+            accept = handle_activity(cursor, activity, signer, user)
+        if accept:
+            inbox, accept_activity = accept
+            deliver_activity.delay(str(user['id']), inbox, accept_activity)
+        return activity_response({}, status_code=status.HTTP_202_ACCEPTED)
+    except HTTPException:
+        raise
+    except SignatureError:
+        raise HTTPException(status_code=403, detail="Activity actor does not match the signature")
+    except Exception as e:
+        logger.error(f"Inbox error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to process activity")
+
+
+@router.post("/ap/users/{username}/inbox")
+async def post_inbox(username: str, request: Request):
+    """Receive a signed activity addressed to the author: Follow, Like, Announce and their Undo"""
+    return await receive_activity(request, username)
+
+
+@router.post("/ap/inbox")
+async def post_shared_inbox(request: Request):
+    """Receive a signed activity for any local actor; Follows must go to the author's own inbox"""
+    return await receive_activity(request)
@@ -4,15 +4,19 @@ Analytics routes for FastAPI backend
 
 import sys
 import os
-from fastapi import APIRouter, HTTPException, Depends, status
+from typing import List, Optional
+from fastapi import APIRouter, HTTPException, Depends, Query, status
 import logging
-from datetime import datetime, timedelta
+from datetime import date, datetime, timedelta, timezone
 
 sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
 
-from shared.database import get_postgres_cursor
-from shared.models import AnalyticsRequest, AnalyticsResponse
-from ..dependencies import get_current_user
+from shared.database import get_postgres_cursor, query_timeout
+from shared.models import AnalyticsRequest, AnalyticsResponse, ModelPerformanceReport
+from shared.claps import author_clap_metrics
+from shared.live_readers import top_articles, total_reading_now
+from shared import bot_detection, clickstream, permissions, trends
+from ..dependencies import get_current_user, require_api_key
 
 router = APIRouter()
 logger = logging.getLogger(__name__)
@@ -22,27 +26,37 @@ logger = logging.getLogger(__name__)
 async def get_user_analytics(user_id: str, analytics_data: AnalyticsRequest, current_user: dict = Depends(get_current_user)):
     """Get user analytics data"""
     try:
-        if user_id != current_user.get('id') and current_user.get('role') != 'administrator':
+        if user_id != current_user.get('id') and not permissions.allowed(current_user, permissions.ANALYTICS_READ):
             raise HTTPException(status_code=403, detail="Access denied")
         
-        with get_postgres_cursor() as cursor:
+        with get_postgres_cursor(timeout_ms=query_timeout('analytics')) as cursor:
             metrics = {}
             date_from = analytics_data.date_from or (datetime.now() - timedelta(days=30))
             date_to = analytics_data.date_to or datetime.now()
+            counts = clickstream.count_interactions(['view', 'like'], date_from, date_to, user_id=user_id) or {}
             
             if 'views' in analytics_data.metrics:
-                cursor.execute("""
-                    SELECT COUNT(*) as view_count FROM user_interactions 
-                    WHERE user_id = %s AND interaction_type = 'view' AND created_at BETWEEN %s AND %s
-                """, (user_id, date_from, date_to))
-                metrics['views'] = cursor.fetchone()['view_count']
+                if 'view' in counts:
+                    metrics['views'] = counts['view']
+                else:
+                    cursor.execute("""
+                        SELECT COUNT(*) as view_count FROM user_interactions 
+                        WHERE user_id = %s AND interaction_type = 'view' AND created_at BETWEEN %s AND %s
+                    """, (user_id, date_from, date_to))
+                    metrics['views'] = cursor.fetchone()['view_count']
             
             if 'likes' in analytics_data.metrics:
-                cursor.execute("""
-                    SELECT COUNT(*) as like_count FROM user_interactions 
-                    WHERE user_id = %s AND interaction_type = 'like' AND created_at BETWEEN %s AND %s
-                """, (user_id, date_from, date_to))
-                metrics['likes'] = cursor.fetchone()['like_count']
+                if 'like' in counts:
+                    metrics['likes'] = counts['like']
+                else:
+                    cursor.execute("""
+                        SELECT COUNT(*) as like_count FROM user_interactions 
+                        WHERE user_id = %s AND interaction_type = 'like' AND created_at BETWEEN %s AND %s
+                    """, (user_id, date_from, date_to))
+                    metrics['likes'] = cursor.fetchone()['like_count']
+            
+            if 'claps' in analytics_data.metrics:
+                metrics['claps_received'] = author_clap_metrics(cursor, user_id, date_from, date_to)
         
         return AnalyticsResponse(
             metrics=metrics,
@@ -59,10 +73,10 @@ async def get_user_analytics(user_id: str, analytics_data: AnalyticsRequest, cur
 async def get_admin_stats(current_user: dict = Depends(get_current_user)):
     """Get admin dashboard statistics"""
     try:
-        if current_user.get('role') != 'administrator':
-            raise HTTPException(status_code=403, detail="Admin access required")
+        if not permissions.allowed(current_user, permissions.ANALYTICS_READ):
+            raise HTTPException(status_code=403, detail=f"Missing permission: {permissions.ANALYTICS_READ}")
         
-        with get_postgres_cursor() as cursor:
+        with get_postgres_cursor(timeout_ms=query_timeout('analytics')) as cursor:
             # Get total users
             cursor.execute("SELECT COUNT(*) as total FROM users WHERE is_active = true")
             total_users = cursor.fetchone()['total'] or 0
@@ -86,11 +100,13 @@ async def get_admin_stats(current_user: dict = Depends(get_current_user)):
             flagged_content = 0
             
             # Get active users (users with activity in last 24h)
-            cursor.execute("""
-                SELECT COUNT(DISTINCT user_id) as active FROM user_interactions 
-                WHERE created_at >= NOW() - INTERVAL '24 hours'
-            """)
-            active_users = cursor.fetchone()['active'] or 0
+            active_users = clickstream.active_users(datetime.now(timezone.utc) - timedelta(hours=24))
+            if active_users is None:
+                cursor.execute("""
+                    SELECT COUNT(DISTINCT user_id) as active FROM user_interactions 
+                    WHERE created_at >= NOW() - INTERVAL '24 hours'
+                """)
+                active_users = cursor.fetchone()['active'] or 0
             
             # Get recent activity stats
             cursor.execute("""
@@ -108,7 +124,9 @@ async def get_admin_stats(current_user: dict = Depends(get_current_user)):
                     "flaggedContent": flagged_content,
                     "activeUsers": active_users,
                     "newUsersToday": new_users_today,
-                    "articlesThisWeek": articles_this_week
+                    "articlesThisWeek": articles_this_week,
+                    "readingNow": total_reading_now(),
+                    "botShareToday": bot_detection.bot_share_today()
                 }
             }
     
@@ -119,14 +137,40 @@ async def get_admin_stats(current_user: dict = Depends(get_current_user)):
         raise HTTPException(status_code=500, detail="Failed to get admin statistics")
 
 
+@router.get("/admin/traffic")
+async def get_traffic_split(
+    days: int = Query(7, ge=1, le=bot_detection.STATS_RETENTION_DAYS),
+    current_user: dict = Depends(get_current_user)
+):
+    """Requests per day from humans, suspects and bots, with the signals behind each classification"""
+    try:
+        if not permissions.allowed(current_user, permissions.ANALYTICS_READ):
+            raise HTTPException(status_code=403, detail=f"Missing permission: {permissions.ANALYTICS_READ}")
+
+        split = bot_detection.traffic_split(days)
+        totals = {kind: sum(day[kind] for day in split) for kind in bot_detection.KINDS}
+        requests = sum(totals.values())
+        return {
+            "success": True,
+            "days": split,
+            "totals": {**totals, "bot_share": round(totals['bot'] / requests, 4) if requests else 0.0}
+        }
+
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Get traffic split error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to get traffic split")
+
+
 @router.get("/admin/recent-users")
 async def get_recent_users(current_user: dict = Depends(get_current_user)):
     """Get recent user registrations"""
     try:
-        if current_user.get('role') != 'administrator':
-            raise HTTPException(status_code=403, detail="Admin access required")
+        if not permissions.allowed(current_user, permissions.ANALYTICS_READ):
+            raise HTTPException(status_code=403, detail=f"Missing permission: {permissions.ANALYTICS_READ}")
         
-        with get_postgres_cursor() as cursor:
+        with get_postgres_cursor(timeout_ms=query_timeout('analytics')) as cursor:
             cursor.execute("""
                 SELECT id, username, email, role, created_at, is_active
                 FROM users 
@@ -159,10 +203,10 @@ async def get_recent_users(current_user: dict = Depends(get_current_user)):
 async def get_flagged_content(current_user: dict = Depends(get_current_user)):
     """Get flagged content for moderation"""
     try:
-        if current_user.get('role') != 'administrator':
-            raise HTTPException(status_code=403, detail="Admin access required")
+        if not permissions.allowed(current_user, permissions.ARTICLE_MODERATE):
+            raise HTTPException(status_code=403, detail=f"Missing permission: {permissions.ARTICLE_MODERATE}")
         
-        with get_postgres_cursor() as cursor:
+        with get_postgres_cursor(timeout_ms=query_timeout('analytics')) as cursor:
             # For now, return articles with low quality scores as "flagged"
             cursor.execute("""
                 SELECT a.id, a.title, u.username as author, a.created_at, a.quality_score
@@ -190,4 +234,120 @@ async def get_flagged_content(current_user: dict = Depends(get_current_user)):
         raise
     except Exception as e:
         logger.error(f"Get flagged content error: {e}")
-        raise HTTPException(status_code=500, detail="Failed to get flagged content")
\ No newline at end of file
+        raise HTTPException(status_code=500, detail="Failed to get flagged content")
+
+
+@router.get("/admin/live-articles")
+async def get_live_articles(current_user: dict = Depends(get_current_user)):
+    """Get the articles with the most people reading them right now"""
+    try:
+        if not permissions.allowed(current_user, permissions.ANALYTICS_READ):
+            raise HTTPException(status_code=403, detail=f"Missing permission: {permissions.ANALYTICS_READ}")
+
+        live = top_articles()
+        if not live:
+            return {"success": True, "articles": []}
+
+        with get_postgres_cursor(timeout_ms=query_timeout('analytics')) as cursor:
+            cursor.execute("""
+                SELECT a.id, a.title, u.username as author
+                FROM articles a
+                JOIN users u ON a.author_id = u.id
+                WHERE a.id = ANY(%s::uuid[])
+            """, ([entry['article_id'] for entry in live],))
+            articles = {str(row['id']): row for row in cursor.fetchall()}
+
+        return {"success": True, "articles": [
+            {
+                "id": entry['article_id'],
+                "title": articles[entry['article_id']]['title'],
+                "author": articles[entry['article_id']]['author'],
+                "readingNow": entry['reading_now']
+            }
+            for entry in live if entry['article_id'] in articles
+        ]}
+
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Get live articles error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to get live articles")
+
+
+@router.get("/trends")
+async def get_trends(
+    tag: List[str] = Query([], description="Tags to chart; repeat for several"),
+    category: List[str] = Query([], description="Categories to chart; repeat for several"),
+    granularity: str = Query("day", pattern="^(day|week|month)$"),
+    start: Optional[date] = Query(None, description="First day (defaults to 30 days, 26 weeks or 12 months back)"),
+    end: Optional[date] = Query(None, description="Last day (defaults to today, UTC)"),
+    current_user: dict = Depends(get_current_user)
+):
+    """Publication volume and engagement over time per tag and category"""
+    try:
+        if current_user.get('role') not in ('author', 'administrator'):
+            raise HTTPException(status_code=403, detail="Newsroom access required")
+        if not tag and not category:
+            raise HTTPException(status_code=400, detail="Pass at least one tag or category")
+        if len(tag) + len(category) > 20:
+            raise HTTPException(status_code=400, detail="At most 20 tags and categories at once")
+
+        end = end or datetime.now(timezone.utc).date()
+        start = start or trends.default_start(granularity, end)
+        if start > end:
+            raise HTTPException(status_code=400, detail="start must not be after end")
+        if trends.point_count(granularity, start, end) > trends.MAX_POINTS:
+            raise HTTPException(status_code=400, detail=f"Range too long for {granularity} granularity")
+
+        with get_postgres_cursor(timeout_ms=query_timeout('analytics')) as cursor:
+            series = []
+            if tag:
+                series.extend(trends.series(cursor, 'tag', tag, granularity, start, end))
+            if category:
+                series.extend(trends.series(cursor, 'category', category, granularity, start, end))
+            rolled_up_at = trends.rolled_up_at(cursor)
+
+        return {
+            "success": True,
+            "granularity": granularity,
+            "start": start.isoformat(),
+            "end": end.isoformat(),
+            "rolled_up_at": rolled_up_at.isoformat() if rolled_up_at else None,
+            "series": series
+        }
+
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Get trends error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to get trends")
+
+
+@router.post("/model-performance", status_code=status.HTTP_201_CREATED)
+async def report_model_performance(
+    report: ModelPerformanceReport,
+    api_key: dict = Depends(require_api_key('write:analytics'))
+):
+    """Record a training run reported by the ML service"""
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute("""
+                INSERT INTO model_performance (
+                    model_type, model_version, validation_metrics, test_metrics, hyperparameters,
+                    training_data_size, validation_data_size, test_data_size, training_duration_minutes,
+                    model_size_mb, inference_time_ms, is_production_ready, deployment_status, notes
+                ) VALUES (%s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s)
+                RETURNING id, training_date
+            """, (
+                report.model_type.value, report.model_version, report.validation_metrics, report.test_metrics,
+                report.hyperparameters, report.training_data_size, report.validation_data_size,
+                report.test_data_size, report.training_duration_minutes, report.model_size_mb,
+                report.inference_time_ms, report.is_production_ready, report.deployment_status, report.notes
+            ))
+            record = cursor.fetchone()
+
+        logger.info(f"Model performance for {report.model_type.value} {report.model_version} reported by key {api_key['key_prefix']}")
+        return {"success": True, "id": str(record['id']), "training_date": record['training_date'].isoformat()}
+    except Exception as e:
+        logger.error(f"Report model performance error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to record model performance")
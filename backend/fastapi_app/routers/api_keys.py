@@ -0,0 +1,144 @@
+"""
+Service API key administration routes for FastAPI backend
+"""
+
+import sys
+import os
+from typing import List
+from fastapi import APIRouter, HTTPException, Depends, Query, Request, status
+import logging
+
+sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
+
+from shared.database import get_postgres_cursor
+from shared.models import ApiKeyCreate, ApiKeyRotate, ApiKeyResponse, CrawlerPolicy
+from shared.api_keys import (
+    SCOPES, create_api_key, get_api_key, invalid_scopes, list_api_keys, revoke_api_key, rotate_api_key,
+    set_crawler_policy
+)
+from shared.audit import client_of, record_security_event
+from ..dependencies import get_admin_user
+
+router = APIRouter()
+logger = logging.getLogger(__name__)
+
+
+@router.get("/scopes")
+async def list_scopes(admin_user: dict = Depends(get_admin_user)):
+    """Scopes a service API key can be issued for (admin only)"""
+    return {"scopes": [{"scope": scope, "description": description} for scope, description in SCOPES.items()]}
+
+
+@router.get("/", response_model=List[ApiKeyResponse])
+async def list_keys(
+    include_inactive: bool = Query(False, description="Include revoked and expired keys"),
+    admin_user: dict = Depends(get_admin_user)
+):
+    """List service API keys with when and where each was last used (admin only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            return [ApiKeyResponse(**key) for key in list_api_keys(cursor, include_inactive)]
+    except Exception as e:
+        logger.error(f"List API keys error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve API keys")
+
+
+@router.post("/", response_model=ApiKeyResponse, status_code=status.HTTP_201_CREATED)
+async def create_key(key_data: ApiKeyCreate, request: Request, admin_user: dict = Depends(get_admin_user)):
+    """Issue a service API key (admin only)
+
+    The key is returned once and only its hash is stored.
+    """
+    unknown = invalid_scopes(key_data.scopes)
+    if unknown:
+        raise HTTPException(status_code=400, detail=f"Unknown scopes: {', '.join(unknown)}")
+
+    try:
+        with get_postgres_cursor() as cursor:
+            key = create_api_key(
+                cursor, key_data.name, key_data.scopes, admin_user['id'], key_data.description, key_data.expires_at
+            )
+
+        record_security_event(
+            admin_user['id'], 'api_key_created', *client_of(request),
+            details={'kind': 'service', 'name': key_data.name, 'key_prefix': key['key_prefix']},
+            resource_type='api_key', resource_id=str(key['id'])
+        )
+        logger.info(f"API key {key['key_prefix']} ({key_data.name}) issued by admin {admin_user['id']}")
+        return ApiKeyResponse(**key)
+    except Exception as e:
+        logger.error(f"Create API key error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to create API key")
+
+
+@router.get("/{key_id}", response_model=ApiKeyResponse)
+async def get_key(key_id: str, admin_user: dict = Depends(get_admin_user)):
+    """Get one service API key (admin only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            key = get_api_key(cursor, key_id)
+        if not key:
+            raise HTTPException(status_code=404, detail="API key not found")
+        return ApiKeyResponse(**key)
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Get API key error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve API key")
+
+
+@router.post("/{key_id}/rotate", response_model=ApiKeyResponse, status_code=status.HTTP_201_CREATED)
+async def rotate_key(key_id: str, rotation: ApiKeyRotate, request: Request,
+                     admin_user: dict = Depends(get_admin_user)):
+    """Issue a replacement key; the old one keeps working for the grace period (admin only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            key = rotate_api_key(cursor, key_id, rotation.grace_period_seconds, admin_user['id'])
+        if not key:
+            raise HTTPException(status_code=404, detail="API key not found or revoked")
+        record_security_event(
+            admin_user['id'], 'api_key_rotated', *client_of(request),
+            details={'kind': 'service', 'name': key['name'], 'key_prefix': key['key_prefix']},
+            resource_type='api_key', resource_id=str(key['id'])
+        )
+        return ApiKeyResponse(**key)
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Rotate API key error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to rotate API key")
+
+
+@router.put("/{key_id}/crawler-policy", response_model=ApiKeyResponse)
+async def update_crawler_policy(key_id: str, policy: CrawlerPolicy, admin_user: dict = Depends(get_admin_user)):
+    """Set a key's crawl delay and excluded sections without reissuing it (admin only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            key = set_crawler_policy(cursor, key_id, policy.crawl_delay_seconds, policy.excluded_sections)
+        if not key:
+            raise HTTPException(status_code=404, detail="API key not found")
+
+        logger.info(f"Crawler policy for API key {key['key_prefix']} updated by admin {admin_user['id']}")
+        return ApiKeyResponse(**key)
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Update crawler policy error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to update crawler policy")
+
+
+@router.delete("/{key_id}")
+async def revoke_key(key_id: str, admin_user: dict = Depends(get_admin_user)):
+    """Revoke a service API key immediately (admin only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            if not revoke_api_key(cursor, key_id):
+                raise HTTPException(status_code=404, detail="API key not found")
+
+        logger.info(f"API key {key_id} revoked by admin {admin_user['id']}")
+        return {"success": True, "message": "API key revoked"}
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Revoke API key error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to revoke API key")
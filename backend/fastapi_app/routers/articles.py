@@ -4,24 +4,71 @@ Article management routes for FastAPI backend
 
 import sys
 import os
+import asyncio
+from urllib.parse import quote
 from typing import List, Optional
-from fastapi import APIRouter, HTTPException, Depends, status, Query
+from fastapi import APIRouter, HTTPException, Depends, Request, Response, status, Query
+from fastapi.responses import JSONResponse, RedirectResponse
 import logging
+import psycopg2
 from datetime import datetime
 
 sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
 
 from shared.database import get_postgres_cursor
-from shared.models import ArticleCreate, ArticleUpdate, ArticleResponse, PaginatedResponse
+from shared.models import (
+    ArticleCreate, ArticleUpdate, ArticleResponse, ArticleRevisionResponse, PaginatedResponse, CursorPaginatedResponse,
+    SpamReportCreate, ArticleReportCreate, ProofreadRequest, ArticleSignatureCreate, ArticleScheduleCreate,
+    FactCheckResponse
+)
 from shared.utils import (
-    generate_uuid, calculate_reading_time, calculate_word_count,
-    extract_keywords, calculate_quality_score, paginate_query_results, sanitize_html
+    generate_uuid, extract_keywords, calculate_quality_score, paginate_query_results,
+    encode_cursor, decode_cursor, deserialize_datetime
+)
+from shared.publishing import on_article_published
+from shared.content import UnsafeContent, prepare
+from shared.translation import (
+    TranslationUnavailable, apply_translation, choose_language, get_translation, normalize_language,
+    preferred_languages, store_translation, translate_article
+)
+from shared.licensing import LICENSES, license_info, reusable_licenses
+from shared.corrections import record_revision
+from shared.instance_policy import REJECT, REVIEW, check_category, check_publish, request_review
+from shared.screening import article_submission, screen, screens_articles
+from shared.tenancy import QuotaExceeded, check_quota
+from shared.visibility import hidden_authors
+from shared.peer_reputation import report_spam
+from shared.http_signatures import sign_response, signing_enabled
+from shared.wire_formats import respond
+from shared.draft_collab import reset_document
+from shared.link_previews import enqueue_link_previews
+from shared.experiments import record_conversion
+from shared.jobs import generate_og_image, archive_article as archive_article_job
+from shared.content_signatures import SignatureInvalid, payload, sign_article, signature_document
+from shared.anchoring import inclusion_proof
+from shared.aliases import AliasLoop, resolve_article
+from shared import embeddings
+from shared import archival
+from shared import certificates
+from shared import fact_checks
+from shared.view_counts import record_view, viewer_key
+from shared import article_cache, coauthors, editorial, feed_versions, permissions
+from shared.paywall import can_read, gate, unrestricted
+from shared.scheduled_publishing import schedule, unschedule
+from shared.readability import (
+    LEVEL_NAMES, improvement_hints, parse_reading_levels, readability_columns, store_readability
 )
-from ..dependencies import get_current_user, get_optional_user
+from shared.languagecheck import (
+    LanguageCheckUnavailable, ProofreadLimitExceeded, apply_fixes, check_rate_limit, check_text
+)
+from ..dependencies import get_current_user, get_optional_user, require_scopes, require_api_key
 
 router = APIRouter()
 logger = logging.getLogger(__name__)
 
+# Related resources `GET /articles/{id}?include=` can embed
+ARTICLE_INCLUDES = ('fact_checks',)
+
 def prepare_array_for_postgres(data):
     """
     Prepare array data for PostgreSQL insertion
@@ -52,16 +99,22 @@ def prepare_json_for_postgres(data):
 
 @router.get("/", response_model=PaginatedResponse)
 async def get_articles(
+    request: Request,
     page: int = Query(1, ge=1),
     per_page: int = Query(20, ge=1, le=100),
     category: str = Query(""),
     language: str = Query(""),
     author_id: str = Query(""),
     status: str = Query("published"),
+    license: str = Query("", description="Comma-separated license ids"),
+    reusable: bool = Query(False, description="Only content licensed for republishing"),
+    commercial: bool = Query(False, description="With reusable, only licenses allowing commercial reuse"),
+    reading_level: str = Query("", description="Comma-separated reading levels, e.g. elementary,middle_school"),
     sort_by: str = Query("created_at"),
-    sort_order: str = Query("desc")
+    sort_order: str = Query("desc"),
+    current_user: Optional[dict] = Depends(get_optional_user)
 ):
-    """Get articles with filtering and pagination"""
+    """Get articles with filtering and pagination, leaving out shadow-banned and blocked authors"""
     try:
         query = "SELECT * FROM articles WHERE status = %s"
         params = [status]
@@ -73,8 +126,21 @@ async def get_articles(
             query += " AND language = %s"
             params.append(language)
         if author_id:
-            query += " AND author_id = %s"
-            params.append(author_id)
+            # Articles the author leads or co-authors
+            query += " AND (author_id = %s OR %s = ANY(coauthor_ids))"
+            params.extend([author_id, author_id])
+        if license:
+            query += " AND license = ANY(%s)"
+            params.append([value.strip() for value in license.split(',') if value.strip()])
+        if reusable:
+            query += " AND license = ANY(%s)"
+            params.append(reusable_licenses(commercial=commercial))
+        if reading_level:
+            levels = parse_reading_levels(reading_level)
+            if not levels:
+                raise HTTPException(status_code=400, detail=f"reading_level must be among {', '.join(LEVEL_NAMES)}")
+            query += " AND reading_level = ANY(%s)"
+            params.append(levels)
         
         valid_sort_fields = ['created_at', 'published_at', 'title', 'view_count', 'like_count', 'trending_score']
         if sort_by not in valid_sort_fields:
@@ -82,35 +148,215 @@ async def get_articles(
         if sort_order.lower() not in ['asc', 'desc']:
             sort_order = 'desc'
         
-        query += f" ORDER BY {sort_by} {sort_order.upper()}"
-        
         with get_postgres_cursor() as cursor:
+            hidden = hidden_authors(cursor, current_user)
+            if hidden:
+                query += " AND NOT (author_id::text = ANY(%s))"
+                params.append(hidden)
+            query += f" ORDER BY {sort_by} {sort_order.upper()}"
             cursor.execute(query, params)
-            articles = cursor.fetchall()
+            articles = coauthors.attach(cursor, cursor.fetchall())
         
-        article_responses = [ArticleResponse(**dict(article)) for article in articles]
+        article_responses = [ArticleResponse(**article) for article in articles]
         paginated = paginate_query_results([a.dict() for a in article_responses], page, per_page)
         
-        return PaginatedResponse(**paginated)
+        return respond(request, PaginatedResponse(**paginated), 'ArticlePage')
+    except HTTPException:
+        raise
     except Exception as e:
         logger.error(f"Get articles error: {e}")
         raise HTTPException(status_code=500, detail="Failed to retrieve articles")
 
 
+@router.get("/licenses")
+async def get_licenses():
+    """List the licenses authors can choose and what each permits"""
+    return {
+        "licenses": [license_info({'license': key}) for key in LICENSES],
+        "reusable": reusable_licenses()
+    }
+
+
+@router.get("/drafts", response_model=PaginatedResponse)
+async def get_my_drafts(
+    page: int = Query(1, ge=1),
+    per_page: int = Query(20, ge=1, le=100),
+    current_user: dict = Depends(require_scopes('drafts:read'))
+):
+    """List the caller's unpublished drafts, with those they co-author"""
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute("""
+                SELECT * FROM articles
+                WHERE (author_id = %s OR %s = ANY(coauthor_ids)) AND status = 'draft'
+                ORDER BY updated_at DESC
+            """, (current_user['id'], current_user['id']))
+            drafts = coauthors.attach(cursor, cursor.fetchall())
+
+        draft_responses = [ArticleResponse(**draft).dict() for draft in drafts]
+        return PaginatedResponse(**paginate_query_results(draft_responses, page, per_page))
+    except Exception as e:
+        logger.error(f"Get drafts error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve drafts")
+
+
+@router.get("/corpus", response_model=CursorPaginatedResponse)
+async def get_article_corpus(
+    cursor: Optional[str] = Query(None, description="Cursor from the previous page; omit to start from the beginning"),
+    limit: int = Query(100, ge=1, le=500),
+    api_key: dict = Depends(require_api_key('read:articles'))
+):
+    """Published articles in update order for services that index or embed the whole corpus"""
+    try:
+        query = "SELECT * FROM articles WHERE status = 'published'"
+        params = []
+
+        # Sections excluded by the key's crawler policy
+        if api_key['excluded_sections']:
+            query += " AND NOT (LOWER(category) = ANY(%s))"
+            params.append(api_key['excluded_sections'])
+
+        if cursor:
+            position = decode_cursor(cursor)
+            updated_at = deserialize_datetime(position.get('updated_at')) if position else None
+            if not updated_at or not position.get('id'):
+                raise HTTPException(status_code=400, detail="Invalid cursor")
+            query += " AND (updated_at, id) > (%s, %s)"
+            params.extend([updated_at, position['id']])
+
+        query += " ORDER BY updated_at ASC, id ASC LIMIT %s"
+        params.append(limit + 1)
+
+        with get_postgres_cursor() as db_cursor:
+            db_cursor.execute(query, params)
+            articles = db_cursor.fetchall()
+
+        has_more = len(articles) > limit
+        articles = articles[:limit]
+
+        # Always returned, so a caller that reached the end can resume from there later
+        next_cursor = None
+        if articles:
+            last = articles[-1]
+            next_cursor = encode_cursor({'updated_at': last['updated_at'].isoformat(), 'id': str(last['id'])})
+        elif cursor:
+            next_cursor = cursor
+
+        # Services with read:articles index the whole corpus, premium articles included
+        with unrestricted():
+            data = [ArticleResponse(**dict(article)).dict() for article in articles]
+        return CursorPaginatedResponse(
+            data=data,
+            next_cursor=next_cursor,
+            has_more=has_more
+        )
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Get article corpus error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve articles")
+
+
+@router.get("/by-slug/{slug}", response_model=ArticleResponse)
+async def get_article_by_slug(
+    slug: str,
+    request: Request,
+    include: Optional[str] = Query(None, description="Comma-separated related resources to embed: fact_checks"),
+    lang: Optional[str] = Query(None, max_length=10, description="Language to read the article in"),
+    current_user: Optional[dict] = Depends(get_optional_user)
+):
+    """Get a published article by slug, like GET /{article_id}
+
+    A slug the article had before its title changed answers with a 301 to the current one.
+    """
+    try:
+        with get_postgres_cursor() as cursor:
+            resolved = resolve_article(cursor, slug)
+        if not resolved:
+            raise HTTPException(status_code=404, detail="Article not found")
+        if 'slug' in resolved:
+            location = f"{request.url.path.rsplit('/', 1)[0]}/{quote(resolved['slug'])}"
+            if request.url.query:
+                location += f"?{request.url.query}"
+            return RedirectResponse(location, status_code=status.HTTP_301_MOVED_PERMANENTLY)
+    except HTTPException:
+        raise
+    except AliasLoop as e:
+        logger.warning(f"Redirect loop resolving article slug {slug}: {e}")
+        raise HTTPException(status_code=status.HTTP_508_LOOP_DETECTED, detail="Redirect loop")
+    except Exception as e:
+        logger.error(f"Get article by slug error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve article")
+    return await get_article(str(resolved['article']['id']), request, include, lang, current_user)
+
+
 @router.get("/{article_id}", response_model=ArticleResponse)
-async def get_article(article_id: str):
-    """Get article by ID and increment view count"""
+async def get_article(
+    article_id: str,
+    request: Request,
+    include: Optional[str] = Query(None, description="Comma-separated related resources to embed: fact_checks"),
+    lang: Optional[str] = Query(None, max_length=10, description="Language to read the article in"),
+    current_user: Optional[dict] = Depends(get_optional_user)
+):
+    """Get article by ID, counting a view for signed-in readers
+
+    Signed with the node key when one is configured, so mirrors can prove the origin.
+    Opening an article counts as a click for any headline test the viewer saw it in.
+    Published articles are served from the rendered article cache.
+    `include=fact_checks` adds auditors' fact checks, limited to the text the viewer can read.
+    Articles with machine translations are served in the first language the reader accepts
+    (`lang`, then their `languages` preference, then Accept-Language), with its provenance in `translation`.
+    """
+    includes = {name.strip() for name in (include or '').split(',') if name.strip()}
+    unknown = includes - set(ARTICLE_INCLUDES)
+    if unknown:
+        raise HTTPException(status_code=400, detail=f"Unknown include: {', '.join(sorted(unknown))}")
+
     try:
+        article = article_cache.get(article_id)
         with get_postgres_cursor() as cursor:
-            cursor.execute("SELECT * FROM articles WHERE id = %s", (article_id,))
-            article_record = cursor.fetchone()
-            
-            if not article_record:
+            if article is None:
+                cursor.execute("SELECT * FROM articles WHERE id = %s", (article_id,))
+                article_record = cursor.fetchone()
+
+                if not article_record:
+                    raise HTTPException(status_code=404, detail="Article not found")
+                article = article_cache.render(coauthors.attach(cursor, [article_record])[0])
+                if article_record['status'] == 'published':
+                    article_cache.put(article_id, article)
+
+            # The cache is shared by every reader, so shadow-banned and blocked authors are checked per request
+            if article.get('author_id') and article['author_id'] in hidden_authors(cursor, current_user):
                 raise HTTPException(status_code=404, detail="Article not found")
-            
-            cursor.execute("UPDATE articles SET view_count = view_count + 1 WHERE id = %s", (article_id,))
+
+            language = None
+            if article.get('translations'):
+                wanted = preferred_languages(
+                    cursor, str(current_user['id']) if current_user else None, lang,
+                    request.headers.get('accept-language')
+                )
+                language = choose_language(article, wanted)
+            translation = get_translation(cursor, article_id, language) if language else None
+            served = gate(apply_translation(article, translation) if translation else article)
+            if 'fact_checks' in includes:
+                served['fact_checks'] = [
+                    FactCheckResponse(**fact_check).model_dump(mode='json')
+                    for fact_check in fact_checks.for_article(
+                        cursor, article, served['content'] if served['paywalled'] else None
+                    )
+                ]
         
-        return ArticleResponse(**dict(article_record))
+        if current_user:
+            record_view(
+                article, viewer_key(user_id=str(current_user['id'])), request.headers.get('user-agent'),
+                str(current_user['id'])
+            )
+            record_conversion(article_id, str(current_user['id']))
+        response = JSONResponse(content=served)
+        if article.get('translations'):
+            response.headers['Content-Language'] = served['language']
+            response.headers['Vary'] = 'Accept-Language'
+        return sign_response(response, request)
     except HTTPException:
         raise
     except Exception as e:
@@ -118,17 +364,63 @@ async def get_article(article_id: str):
         raise HTTPException(status_code=500, detail="Failed to retrieve article")
 
 
+@router.post("/{article_id}/view")
+async def count_view(article_id: str, request: Request, current_user: Optional[dict] = Depends(get_optional_user)):
+    """Count a view of a published article, once per reader or session within the dedup window
+
+    Signed-out clients should send a stable `X-Session-Id`; bots are never counted.
+    """
+    try:
+        article = article_cache.get(article_id)
+        if article is None:
+            with get_postgres_cursor() as cursor:
+                cursor.execute("SELECT id, author_id, status FROM articles WHERE id = %s", (article_id,))
+                article = cursor.fetchone()
+        if not article or article['status'] != 'published':
+            raise HTTPException(status_code=404, detail="Article not found")
+
+        user_id = str(current_user['id']) if current_user else None
+        viewer = viewer_key(
+            user_id=user_id,
+            session_id=request.headers.get('x-session-id'),
+            ip_address=request.client.host if request.client else None,
+            user_agent=request.headers.get('user-agent')
+        )
+        counted = record_view(dict(article), viewer, request.headers.get('user-agent'), user_id)
+        return {"success": True, "counted": counted}
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Count view error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to count view")
+
+
 @router.get("/{article_id}/related", response_model=List[ArticleResponse])
-async def get_related_articles(article_id: str):
-    """Get articles related to the given article by tags and category"""
+async def get_related_articles(
+    article_id: str,
+    limit: int = Query(6, ge=1, le=embeddings.MAX_RELATED),
+    lang: Optional[str] = Query(None, max_length=10, description="Language of the related articles; "
+                                                                 "the article's own by default, `any` for all"),
+    current_user: Optional[dict] = Depends(get_optional_user)
+):
+    """Get the articles nearest to the given article by embedding, or sharing its tags and category
+    until it has been embedded"""
     try:
         with get_postgres_cursor() as cursor:
-            # First get the current article's tags and category
-            cursor.execute("SELECT tags, category FROM articles WHERE id = %s", (article_id,))
+            cursor.execute("SELECT tags, category, language FROM articles WHERE id = %s", (article_id,))
             current_article = cursor.fetchone()
             
             if not current_article:
                 raise HTTPException(status_code=404, detail="Article not found")
+            hidden = set(hidden_authors(cursor, current_user))
+
+            if embeddings.enabled():
+                language = None if lang == 'any' else (lang or current_article['language'])
+                nearest = embeddings.related(cursor, article_id, language, limit)
+                if nearest is not None:
+                    return [
+                        ArticleResponse(**article) for article in nearest if str(article['author_id']) not in hidden
+                    ]
             
             current_tags = current_article['tags'] or []
             current_category = current_article['category']
@@ -149,14 +441,17 @@ async def get_related_articles(article_id: str):
                 AND status = 'published'
                 AND (category = %s OR tags && %s)
                 ORDER BY relevance_score DESC, created_at DESC
-                LIMIT 6
+                LIMIT %s
             """, (
                 current_category, current_tags, current_tags, article_id, 
-                current_category, current_tags
+                current_category, current_tags, limit
             ))
             
             related_articles = cursor.fetchall()
-            return [ArticleResponse(**dict(article)) for article in related_articles]
+            return [
+                ArticleResponse(**dict(article))
+                for article in related_articles if str(article['author_id']) not in hidden
+            ]
     
     except HTTPException:
         raise
@@ -165,16 +460,615 @@ async def get_related_articles(article_id: str):
         raise HTTPException(status_code=500, detail="Failed to retrieve related articles")
 
 
+@router.get("/{article_id}/revisions", response_model=List[ArticleRevisionResponse])
+async def list_revisions(article_id: str):
+    """Revision history of a published article, newest first (without content)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute("SELECT status FROM articles WHERE id = %s", (article_id,))
+            article = cursor.fetchone()
+            if not article or article['status'] != 'published':
+                raise HTTPException(status_code=404, detail="Article not found")
+
+            cursor.execute("""
+                SELECT id, revision_number, title, summary, edited_by, change_note, correction_id, created_at
+                FROM article_revisions
+                WHERE article_id = %s
+                ORDER BY revision_number DESC
+            """, (article_id,))
+            revisions = cursor.fetchall()
+
+        return [ArticleRevisionResponse(**dict(revision)) for revision in revisions]
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"List revisions error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve revisions")
+
+
+@router.get("/{article_id}/revisions/{revision_number}", response_model=ArticleRevisionResponse)
+async def get_revision(article_id: str, revision_number: int):
+    """A single revision of a published article, including its content"""
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute("""
+                SELECT r.*, a.author_id, a.access_policy FROM article_revisions r
+                JOIN articles a ON a.id = r.article_id
+                WHERE r.article_id = %s AND r.revision_number = %s AND a.status = 'published'
+            """, (article_id, revision_number))
+            revision = cursor.fetchone()
+            if not revision:
+                raise HTTPException(status_code=404, detail="Revision not found")
+            if not can_read({**revision, 'id': article_id}):
+                raise HTTPException(status_code=402, detail="Unlock this premium article to see its revisions")
+
+        return ArticleRevisionResponse(**dict(revision))
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Get revision error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve revision")
+
+
+@router.get("/{article_id}/signature")
+async def get_article_signature(article_id: str, current_user: Optional[dict] = Depends(get_optional_user)):
+    """The author's signature with the key and payload needed to verify it independently
+
+    Drafts are only visible to their author and to holders of `article:edit_any`. The author is
+    omitted for anonymously published articles. Premium articles need the
+    reader to be entitled to them.
+    """
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute("SELECT * FROM articles WHERE id = %s", (article_id,))
+            article = cursor.fetchone()
+            if not article:
+                raise HTTPException(status_code=404, detail="Article not found")
+            if article['status'] != 'published' and not permissions.can_edit_article(current_user, article):
+                raise HTTPException(status_code=404, detail="Article not found")
+            # The payload holds the full content
+            if not can_read(dict(article)):
+                raise HTTPException(status_code=402, detail="Unlock this premium article to verify its signature")
+            document = signature_document(cursor, dict(article))
+
+        if not document:
+            raise HTTPException(status_code=404, detail="Article is not signed")
+        return {"success": True, **document}
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Get article signature error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve signature")
+
+
+@router.get("/{article_id}/signature/payload")
+async def get_signing_payload(article_id: str, current_user: dict = Depends(get_current_user)):
+    """The exact payload to sign for the article as stored (author only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute("SELECT * FROM articles WHERE id = %s", (article_id,))
+            article = cursor.fetchone()
+        if not article:
+            raise HTTPException(status_code=404, detail="Article not found")
+        if str(article['author_id']) != str(current_user['id']):
+            raise HTTPException(status_code=403, detail="Only the author can sign an article")
+        return {"success": True, "payload": payload(dict(article))}
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Get signing payload error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to build signing payload")
+
+
+@router.post("/{article_id}/signature")
+async def sign_article_content(article_id: str, signature_data: ArticleSignatureCreate,
+                               current_user: dict = Depends(require_scopes('articles:write'))):
+    """Attach the author's detached signature of the article's payload, replacing any earlier one (author only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute("SELECT * FROM articles WHERE id = %s", (article_id,))
+            article = cursor.fetchone()
+            if not article:
+                raise HTTPException(status_code=404, detail="Article not found")
+            if str(article['author_id']) != str(current_user['id']):
+                raise HTTPException(status_code=403, detail="Only the author can sign an article")
+
+            sign_article(cursor, dict(article), str(current_user['id']),
+                         str(signature_data.key_id), signature_data.signature)
+            document = signature_document(cursor, dict(article))
+
+        return {"success": True, **document}
+    except HTTPException:
+        raise
+    except SignatureInvalid as e:
+        raise HTTPException(status_code=400, detail=f"Invalid signature: {e}")
+    except Exception as e:
+        logger.error(f"Sign article error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to store signature")
+
+
+@router.get("/{article_id}/snapshots")
+async def get_article_snapshots(article_id: str):
+    """Where the article's published versions were stored, newest first"""
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute("SELECT id FROM articles WHERE id = %s AND status = 'published'", (article_id,))
+            if not cursor.fetchone():
+                raise HTTPException(status_code=404, detail="Article not found")
+            cursor.execute("""
+                SELECT driver, ref, url, content_sha256, size, created_at
+                FROM article_snapshots WHERE article_id = %s
+                ORDER BY created_at DESC
+            """, (article_id,))
+            snapshots = [dict(row) for row in cursor.fetchall()]
+        return {"success": True, "article_id": article_id, "snapshots": snapshots}
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Get article snapshots error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve snapshots")
+
+
+@router.get("/{article_id}/archives")
+async def get_article_archives(article_id: str):
+    """Where the article was archived for the long term, with the cost and status of each archive, newest first"""
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute("SELECT id FROM articles WHERE id = %s AND status = 'published'", (article_id,))
+            if not cursor.fetchone():
+                raise HTTPException(status_code=404, detail="Article not found")
+            archives = archival.archives_for(cursor, article_id)
+        return {"success": True, "article_id": article_id, "archives": archives}
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Get article archives error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve archives")
+
+
+@router.post("/{article_id}/archive", status_code=status.HTTP_202_ACCEPTED)
+async def archive_article(
+    article_id: str,
+    provider: Optional[str] = Query(None, description="Archive with one provider only, e.g. arweave"),
+    current_user: dict = Depends(get_current_user)
+):
+    """Archive the article now with each configured provider, or `provider` (author or `article:edit_any`)
+
+    An archive already waiting to be submitted is submitted rather than queued again.
+    """
+    try:
+        if not archival.ARCHIVE_ENABLED:
+            raise HTTPException(status_code=503, detail="Archival is not enabled on this node")
+        providers = [provider] if provider else archival.PROVIDER_NAMES
+        for name in providers:
+            archival.provider(name)
+
+        with get_postgres_cursor() as cursor:
+            cursor.execute(
+                "SELECT author_id, coauthor_ids, status, access_policy FROM articles WHERE id = %s", (article_id,)
+            )
+            article = cursor.fetchone()
+            if not article:
+                raise HTTPException(status_code=404, detail="Article not found")
+            if not permissions.can_edit_article(current_user, article):
+                raise HTTPException(status_code=403, detail="Access denied")
+            if article['status'] != 'published':
+                raise HTTPException(status_code=409, detail="Only published articles can be archived")
+            if article['access_policy']:
+                raise HTTPException(status_code=409, detail="Premium articles are not archived")
+            archives = [
+                archival.request_archive(cursor, article_id, name, str(current_user['id'])) for name in providers
+            ]
+
+        for archive in archives:
+            archive_article_job.delay(str(archive['id']))
+        return {"success": True, "article_id": article_id, "archives": [
+            {'id': archive['id'], 'provider': archive['provider'], 'status': archive['status']} for archive in archives
+        ]}
+    except HTTPException:
+        raise
+    except archival.ArchiveUnavailable as e:
+        raise HTTPException(status_code=503, detail=str(e))
+    except Exception as e:
+        logger.error(f"Archive article error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to queue archive")
+
+
+@router.get("/{article_id}/merkle-proof")
+async def get_merkle_proof(article_id: str):
+    """The article's Merkle inclusion proof and the on-chain anchor of its batch
+
+    Recompute the article hash from its id, title, summary and content, fold
+    the proof up to the root and check the root with the ArticleAnchor
+    contract's `anchors` or `verifyInclusion`.
+    """
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute(
+                "SELECT id, title, summary, content FROM articles WHERE id = %s AND status = 'published'",
+                (article_id,)
+            )
+            article = cursor.fetchone()
+            if not article:
+                raise HTTPException(status_code=404, detail="Article not found")
+            document = inclusion_proof(cursor, dict(article))
+
+        if not document:
+            raise HTTPException(status_code=404, detail="Article has not been anchored yet")
+        return {"success": True, **document}
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Get Merkle proof error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve Merkle proof")
+
+
+@router.get("/{article_id}/certificate")
+async def get_certificate(article_id: str, format: str = Query("json", pattern="^(json|pdf)$"),
+                          current_user: dict = Depends(get_current_user)):
+    """A signed proof-of-publication certificate for the article (author or `article:edit_any`)
+
+    Covers the article hash, the transaction that first anchored it, when it
+    was published and the author's identity, signed with the node key; see
+    shared/certificates.py for how to verify it. `format=pdf` gives a
+    printable copy.
+    """
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute("SELECT * FROM articles WHERE id = %s", (article_id,))
+            article = cursor.fetchone()
+            if not article:
+                raise HTTPException(status_code=404, detail="Article not found")
+            if not permissions.can_edit_article(current_user, article):
+                raise HTTPException(status_code=403, detail="Not authorized to get a certificate for this article")
+            if not signing_enabled():
+                raise HTTPException(status_code=503, detail="Certificates are not available on this node")
+            document = certificates.issue(cursor, dict(article))
+
+        if format == 'pdf':
+            return Response(
+                content=certificates.render_pdf(document), media_type="application/pdf",
+                headers={"Content-Disposition": f'attachment; filename="certificate-{article_id}.pdf"'}
+            )
+        return {"success": True, "certificate": document}
+    except HTTPException:
+        raise
+    except certificates.CertificateUnavailable as e:
+        raise HTTPException(status_code=409, detail=str(e))
+    except Exception as e:
+        logger.error(f"Get certificate error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to issue certificate")
+
+
+@router.post("/{article_id}/schedule", response_model=ArticleResponse)
+async def schedule_article(article_id: str, schedule_data: ArticleScheduleCreate,
+                           current_user: dict = Depends(require_scopes('articles:write'))):
+    """Schedule a draft to be published at `publish_at` (author or `article:edit_any`)
+
+    The instance policy is checked now: blocked categories are refused and
+    drafts that need moderation review, or editorial approval when the
+    publication requires it, can't be scheduled until approved.
+    Caches are primed shortly before the article goes live.
+    """
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute("SELECT * FROM articles WHERE id = %s FOR UPDATE", (article_id,))
+            article = cursor.fetchone()
+            if not article:
+                raise HTTPException(status_code=404, detail="Article not found")
+            if not permissions.can_edit_article(current_user, article):
+                raise HTTPException(status_code=403, detail="Access denied")
+            if not permissions.allowed(current_user, permissions.ARTICLE_PUBLISH):
+                raise HTTPException(status_code=403, detail=f"Missing permission: {permissions.ARTICLE_PUBLISH}")
+            if article['status'] != 'draft':
+                raise HTTPException(status_code=409, detail="Only drafts can be scheduled")
+            if not editorial.may_publish(cursor, article_id, current_user):
+                raise HTTPException(status_code=403, detail="Needs editorial approval before publishing")
+
+            is_moderator = permissions.allowed(current_user, permissions.ARTICLE_MODERATE)
+            decision = check_publish(dict(article))
+            if decision.action == REJECT:
+                raise HTTPException(status_code=403, detail=decision.reason)
+            if decision.action == REVIEW and not is_moderator:
+                raise HTTPException(status_code=403, detail=f"Needs moderation review before publishing: {decision.reason}")
+            if not is_moderator and screens_articles():
+                screening = screen(article_submission(dict(article), current_user['id']))
+                if screening.held:
+                    raise HTTPException(
+                        status_code=403, detail=f"Needs moderation review before publishing: {screening.reason}"
+                    )
+
+            scheduled = schedule(cursor, article_id, schedule_data.publish_at)
+        logger.info(f"Article {article_id} scheduled for {schedule_data.publish_at.isoformat()} by {current_user['id']}")
+        return ArticleResponse(**scheduled)
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Schedule article error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to schedule article")
+
+
+@router.delete("/{article_id}/schedule", response_model=ArticleResponse)
+async def unschedule_article(article_id: str, current_user: dict = Depends(require_scopes('articles:write'))):
+    """Keep a scheduled draft as a draft (author or `article:edit_any`)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute("SELECT * FROM articles WHERE id = %s FOR UPDATE", (article_id,))
+            article = cursor.fetchone()
+            if not article:
+                raise HTTPException(status_code=404, detail="Article not found")
+            if not permissions.can_edit_article(current_user, article):
+                raise HTTPException(status_code=403, detail="Access denied")
+            if article['status'] != 'draft' or not article['scheduled_publish_at']:
+                raise HTTPException(status_code=409, detail="Article is not scheduled")
+
+            return ArticleResponse(**unschedule(cursor, article_id))
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Unschedule article error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to unschedule article")
+
+
+@router.post("/{article_id}/og-image", status_code=status.HTTP_202_ACCEPTED)
+async def regenerate_og_image(article_id: str, current_user: dict = Depends(get_current_user)):
+    """Render the article's share card again, e.g. after a new title (author or `article:edit_any`)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute("SELECT author_id, coauthor_ids FROM articles WHERE id = %s", (article_id,))
+            article = cursor.fetchone()
+        if not article:
+            raise HTTPException(status_code=404, detail="Article not found")
+        if not permissions.can_edit_article(current_user, article):
+            raise HTTPException(status_code=403, detail="Access denied")
+
+        result = generate_og_image.delay(article_id)
+        return {"success": True, "task_id": result.id}
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Regenerate share card error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to queue share card")
+
+
+@router.post("/{article_id}/translate")
+async def create_translation(
+    article_id: str,
+    lang: str = Query(..., max_length=10, description="Language to translate into, e.g. de or pt-BR"),
+    current_user: dict = Depends(get_current_user)
+):
+    """Machine-translate the article and store it as its `lang` variant (author or `article:edit_any`)
+
+    Translating again replaces the variant, e.g. after the original was edited.
+    """
+    language = normalize_language(lang)
+    if not language:
+        raise HTTPException(status_code=400, detail="lang must be a language tag such as de or pt-BR")
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute("SELECT * FROM articles WHERE id = %s", (article_id,))
+            article = cursor.fetchone()
+        if not article:
+            raise HTTPException(status_code=404, detail="Article not found")
+        article = dict(article)
+        if not permissions.can_edit_article(current_user, article):
+            raise HTTPException(status_code=403, detail="Access denied")
+        if normalize_language(article['language']) == language:
+            raise HTTPException(status_code=400, detail="The article is already in this language")
+
+        # The provider call can take a while; no transaction is held open for it
+        translated = await asyncio.to_thread(translate_article, article, language)
+        with get_postgres_cursor() as cursor:
+            translation = store_translation(cursor, article, language, translated, str(current_user['id']))
+            cursor.execute("SELECT * FROM articles WHERE id = %s", (article_id,))
+            article = dict(cursor.fetchone())
+
+        article_cache.invalidate(article_id)
+        return apply_translation(article_cache.render(article), translation)
+    except HTTPException:
+        raise
+    except TranslationUnavailable as e:
+        raise HTTPException(status_code=503, detail=str(e))
+    except Exception as e:
+        logger.error(f"Translate article error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to translate article")
+
+
+@router.post("/{article_id}/report-spam")
+async def report_article_spam(
+    article_id: str,
+    report: Optional[SpamReportCreate] = None,
+    current_user: dict = Depends(get_current_user)
+):
+    """Report an article pulled from a federation peer as spam; reports lower the peer's reputation"""
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute("SELECT 1 FROM articles WHERE id = %s AND status = 'published'", (article_id,))
+            if not cursor.fetchone():
+                raise HTTPException(status_code=404, detail="Article not found")
+
+            result = report_spam(cursor, article_id, current_user['id'], report.reason if report else None)
+            if result is None:
+                raise HTTPException(status_code=400, detail="Only federated articles can be reported as spam")
+
+        if result['recorded']:
+            logger.info(f"Article {article_id} from {result['peer']} reported as spam by {current_user['id']}")
+        return {"success": True, "message": "Report received"}
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Report spam error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to report article")
+
+
+@router.post("/{article_id}/report", status_code=status.HTTP_201_CREATED)
+async def report_article(article_id: str, report: ArticleReportCreate, current_user: dict = Depends(get_current_user)):
+    """Report a published article to moderators; upheld reports cost the author reputation"""
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute("SELECT author_id FROM articles WHERE id = %s AND status = 'published'", (article_id,))
+            article = cursor.fetchone()
+            if not article:
+                raise HTTPException(status_code=404, detail="Article not found")
+            if str(article['author_id']) == str(current_user['id']):
+                raise HTTPException(status_code=400, detail="You can't report your own article")
+
+            cursor.execute("""
+                INSERT INTO article_reports (article_id, reported_by, reason, details)
+                VALUES (%s, %s, %s, %s)
+                RETURNING id, status, created_at
+            """, (article_id, current_user['id'], report.reason.value, report.details))
+            created = cursor.fetchone()
+
+        logger.info(f"Article {article_id} reported for {report.reason.value} by {current_user['id']}")
+        return {"success": True, "report": {**dict(created), 'id': str(created['id'])}}
+    except HTTPException:
+        raise
+    except psycopg2.errors.UniqueViolation:
+        raise HTTPException(status_code=409, detail="You already have an open report on this article")
+    except Exception as e:
+        logger.error(f"Report article error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to report article")
+
+
+@router.get("/{article_id}/readability")
+async def get_article_readability(
+    article_id: str,
+    target_level: Optional[str] = Query(None, pattern="^(elementary|middle_school|high_school|college|graduate)$"),
+    current_user: dict = Depends(get_current_user)
+):
+    """Readability of the article's current text with hints for improving it (author or `article:edit_any`)
+
+    `target_level` is the audience the author is writing for; hints say when
+    the text reads harder than that.
+    """
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute(
+                "SELECT id, author_id, coauthor_ids, content, language FROM articles WHERE id = %s", (article_id,)
+            )
+            article = cursor.fetchone()
+            if not article:
+                raise HTTPException(status_code=404, detail="Article not found")
+            if not permissions.can_edit_article(current_user, article):
+                raise HTTPException(status_code=403, detail="Access denied")
+            metrics = store_readability(cursor, article_id, article['content'], article['language'])
+
+        return {"success": True, "readability": metrics, "hints": improvement_hints(metrics, target_level)}
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Article readability error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to score readability")
+
+
+@router.post("/{article_id}/proofread")
+async def proofread_article(article_id: str, proofread: Optional[ProofreadRequest] = None,
+                            current_user: dict = Depends(require_scopes('articles:write'))):
+    """Check an article's spelling and grammar, optionally applying the suggested fixes to a draft
+
+    Issues are grouped by field, with offsets into the stored text. With
+    `apply_fixes`, the first suggestion of each issue (limited to `rule_ids`
+    if given) is applied and the result recorded as a revision.
+    """
+    proofread = proofread or ProofreadRequest()
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute("SELECT * FROM articles WHERE id = %s", (article_id,))
+            article = cursor.fetchone()
+        if not article:
+            raise HTTPException(status_code=404, detail="Article not found")
+        if not permissions.can_edit_article(current_user, article):
+            raise HTTPException(status_code=403, detail="Access denied")
+        if proofread.apply_fixes and article['status'] != 'draft':
+            raise HTTPException(status_code=400, detail="Fixes can only be applied to drafts")
+
+        try:
+            check_rate_limit(current_user['id'])
+        except ProofreadLimitExceeded as e:
+            raise HTTPException(status_code=429, detail=str(e))
+
+        language = proofread.language or article.get('language') or 'en'
+        try:
+            issues = {
+                field: check_text(article[field] or '', language, html=field == 'content')
+                for field in proofread.fields
+            }
+        except LanguageCheckUnavailable as e:
+            raise HTTPException(status_code=503, detail=str(e))
+
+        result = {
+            "success": True,
+            "language": language,
+            "issues": issues,
+            "issue_count": sum(len(found) for found in issues.values()),
+        }
+        if not proofread.apply_fixes:
+            return result
+
+        fixed = {}
+        applied = 0
+        for field, found in issues.items():
+            text, count = apply_fixes(article[field] or '', found, proofread.rule_ids)
+            if count:
+                fixed[field] = text
+                applied += count
+        result['applied'] = applied
+        if not fixed:
+            return result
+
+        with get_postgres_cursor() as cursor:
+            cursor.execute("SELECT * FROM articles WHERE id = %s FOR UPDATE", (article_id,))
+            current = cursor.fetchone()
+            # Offsets are only valid for the text that was checked
+            if current['status'] != 'draft' or any(current[field] != article[field] for field in fixed):
+                raise HTTPException(status_code=409, detail="The draft changed while it was being checked")
+
+            prepared = prepare(fixed['content'], current['content_format']) if 'content' in fixed else None
+            if prepared:
+                fixed['content'] = prepared['content']
+            assignments = [f"{field} = %s" for field in fixed]
+            params = list(fixed.values())
+            if prepared:
+                assignments.extend(["reading_time = %s", "word_count = %s"])
+                params.extend([prepared['reading_time'], prepared['word_count']])
+            cursor.execute(
+                f"UPDATE articles SET {', '.join(assignments)}, updated_at = NOW() WHERE id = %s RETURNING *",
+                params + [article_id]
+            )
+            updated_article = dict(cursor.fetchone())
+            updated_article['readability'] = store_readability(
+                cursor, article_id, updated_article['content'], updated_article['language']
+            )
+            updated_article['reading_level'] = updated_article['readability']['reading_level']
+            revision = record_revision(
+                cursor, updated_article, current_user['id'],
+                change_note=f"Applied {applied} proofreading fixes", previous=dict(current)
+            )
+            reset_document(cursor, article_id)
+
+        result['revision_number'] = revision['revision_number']
+        result['article'] = ArticleResponse(**updated_article)
+        return result
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Proofread article error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to proofread article")
+
+
 @router.post("/", response_model=ArticleResponse, status_code=status.HTTP_201_CREATED)
-async def create_article(article_data: ArticleCreate, current_user: dict = Depends(get_current_user)):
+async def create_article(article_data: ArticleCreate, current_user: dict = Depends(require_scopes('articles:write'))):
     """Create new article with proper array/JSON handling"""
     try:
         # Process article content
-        sanitized_content = sanitize_html(article_data.content)
-        reading_time = calculate_reading_time(sanitized_content)
-        word_count = calculate_word_count(sanitized_content)
+        prepared = prepare(article_data.content, article_data.content_format.value)
+        sanitized_content = prepared['content']
+        reading_time = prepared['reading_time']
+        word_count = prepared['word_count']
         seo_keywords = extract_keywords(sanitized_content)
         quality_score = calculate_quality_score(sanitized_content, article_data.title, article_data.summary)
+        readability = readability_columns(sanitized_content, article_data.language)
         
         article_id = generate_uuid()
         author_id = current_user['id']
@@ -184,17 +1078,20 @@ async def create_article(article_data: ArticleCreate, current_user: dict = Depen
         seo_keywords_data = prepare_array_for_postgres(seo_keywords)  # For array columns
         
         with get_postgres_cursor() as cursor:
+            check_quota(cursor, 'articles')
             cursor.execute("""
                 INSERT INTO articles (
-                    id, title, content, summary, author_id, anonymous_author,
+                    id, title, content, content_format, summary, author_id, anonymous_author,
                     category, subcategory, tags, language, reading_time, word_count,
-                    status, metadata, seo_keywords, quality_score, created_at, updated_at
-                ) VALUES (%s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s)
+                    status, metadata, seo_keywords, quality_score, license, license_terms,
+                    readability, reading_level, created_at, updated_at
+                ) VALUES (%s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s)
                 RETURNING *
             """, (
                 article_id, 
                 article_data.title, 
                 sanitized_content, 
+                article_data.content_format.value,
                 article_data.summary,
                 author_id, 
                 article_data.anonymous_author, 
@@ -208,6 +1105,10 @@ async def create_article(article_data: ArticleCreate, current_user: dict = Depen
                 metadata_data,  # Prepared for JSON column
                 seo_keywords_data,  # Prepared for array column
                 quality_score, 
+                article_data.license.value,
+                article_data.license_terms,
+                readability['readability'],
+                readability['reading_level'],
                 datetime.now(),
                 datetime.now()
             ))
@@ -216,12 +1117,164 @@ async def create_article(article_data: ArticleCreate, current_user: dict = Depen
             
             if not article_record:
                 raise HTTPException(status_code=500, detail="Failed to create article")
+
+            if article_data.signature:
+                # Rolls the article back too when the signature doesn't verify
+                sign_article(
+                    cursor, dict(article_record), author_id,
+                    str(article_data.signature.key_id), article_data.signature.signature
+                )
         
         logger.info(f"Article created successfully: {article_id} by user {author_id}")
         return ArticleResponse(**dict(article_record))
         
     except HTTPException:
         raise
+    except SignatureInvalid as e:
+        raise HTTPException(status_code=400, detail=f"Invalid signature: {e}")
+    except UnsafeContent as e:
+        raise HTTPException(status_code=400, detail=f"Unsafe content: {e}")
+    except QuotaExceeded as e:
+        raise HTTPException(status_code=403, detail=str(e))
     except Exception as e:
         logger.error(f"Create article error: {e}", exc_info=True)
-        raise HTTPException(status_code=500, detail="Failed to create article")
\ No newline at end of file
+        raise HTTPException(status_code=500, detail="Failed to create article")
+
+@router.put("/{article_id}", response_model=ArticleResponse)
+async def update_article(article_id: str, article_update: ArticleUpdate, response: Response,
+                         current_user: dict = Depends(require_scopes('articles:write'))):
+    """Update an existing article and run publish hooks when it goes live
+    
+    Publishing is subject to the instance policy: blocked categories are
+    refused, and moderated tags hold the article as a draft pending review
+    (202 with `X-Moderation-Review: pending`). When the `editorial` setting
+    requires approval, drafts an editor hasn't approved are refused.
+    """
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute("SELECT * FROM articles WHERE id = %s", (article_id,))
+            article = cursor.fetchone()
+            if not article:
+                raise HTTPException(status_code=404, detail="Article not found")
+
+            if not permissions.can_edit_article(current_user, article):
+                raise HTTPException(status_code=403, detail="Access denied")
+
+            update_data = article_update.dict(exclude_unset=True)
+
+            resulting = {
+                'category': update_data.get('category') or article['category'],
+                'tags': update_data['tags'] if update_data.get('tags') is not None else article['tags'],
+            }
+            review_reason = None
+            if update_data.get('status') == 'published' and article['status'] != 'published':
+                if not permissions.allowed(current_user, permissions.ARTICLE_PUBLISH):
+                    raise HTTPException(status_code=403, detail=f"Missing permission: {permissions.ARTICLE_PUBLISH}")
+                if not editorial.may_publish(cursor, article_id, current_user):
+                    raise HTTPException(status_code=403, detail="Needs editorial approval before publishing")
+                decision = check_publish(resulting)
+                if decision.action == REJECT:
+                    raise HTTPException(status_code=403, detail=decision.reason)
+                # Moderators' own publishes go straight through
+                is_moderator = permissions.allowed(current_user, permissions.ARTICLE_MODERATE)
+                if decision.action == REVIEW and not is_moderator:
+                    review_reason = decision.reason
+                elif not is_moderator and screens_articles():
+                    screening = screen(article_submission({
+                        field: update_data.get(field) or article[field] for field in ('title', 'summary', 'content')
+                    }, current_user['id']))
+                    if screening.held:
+                        review_reason = f"Screening: {screening.reason}"
+                if review_reason:
+                    update_data.pop('status')
+            elif article['status'] == 'published' and 'category' in update_data:
+                decision = check_category(resulting['category'])
+                if not decision.allowed:
+                    raise HTTPException(status_code=403, detail=decision.reason)
+
+            update_fields = []
+            params = []
+
+            content_format = getattr(update_data.get('content_format'), 'value', None) or article['content_format']
+            if update_data.get('content') or content_format != article['content_format']:
+                # Switching formats re-reads the stored source in the new one
+                prepared = prepare(update_data.get('content') or article['content'], content_format)
+                update_fields.extend(["content = %s", "content_format = %s", "reading_time = %s", "word_count = %s"])
+                params.extend([prepared['content'], content_format, prepared['reading_time'], prepared['word_count']])
+
+            for field, value in update_data.items():
+                if field in ('content', 'content_format'):
+                    continue
+                elif field == 'tags':
+                    update_fields.append("tags = %s")
+                    params.append(prepare_array_for_postgres(value))
+                elif field == 'metadata':
+                    update_fields.append("metadata = %s")
+                    params.append(prepare_json_for_postgres(value))
+                elif field in ('status', 'license') and value:
+                    update_fields.append(f"{field} = %s")
+                    params.append(value.value)
+                elif field in ['title', 'summary', 'category', 'subcategory', 'language', 'anonymous_author', 'license_terms']:
+                    update_fields.append(f"{field} = %s")
+                    params.append(value)
+
+            if review_reason:
+                request_review(cursor, article_id, review_reason, current_user['id'])
+                response.status_code = status.HTTP_202_ACCEPTED
+                response.headers['X-Moderation-Review'] = 'pending'
+                if not update_fields:
+                    return ArticleResponse(**dict(article))
+
+            if not update_fields:
+                raise HTTPException(status_code=400, detail="No valid fields to update")
+
+            update_fields.append("updated_at = NOW()")
+
+            publishing = update_data.get('status') == 'published' and article['status'] != 'published'
+            if publishing:
+                update_fields.append("published_at = NOW()")
+            if article['scheduled_publish_at']:
+                # Publishing by hand replaces the schedule; edits make the primed caches stale
+                update_fields.append("scheduled_publish_at = NULL" if publishing else "cache_primed_at = NULL")
+
+            params.append(article_id)
+            cursor.execute(
+                f"UPDATE articles SET {', '.join(update_fields)} WHERE id = %s RETURNING *",
+                params
+            )
+            updated_article = dict(cursor.fetchone())
+
+            if updated_article['content'] != article['content'] or updated_article['language'] != article['language']:
+                updated_article['readability'] = store_readability(
+                    cursor, article_id, updated_article['content'], updated_article['language']
+                )
+                updated_article['reading_level'] = updated_article['readability']['reading_level']
+
+            if publishing:
+                on_article_published(cursor, updated_article)
+
+            # Edits to the text of a live article are kept as revisions
+            text_changed = any(
+                updated_article[field] != article[field] for field in ('title', 'summary', 'content')
+            )
+            if article['status'] == 'published' and text_changed:
+                record_revision(cursor, updated_article, current_user['id'], previous=dict(article))
+            # Text saved outside the collaborative document would be overwritten by its next save
+            if article['status'] == 'draft' and text_changed:
+                reset_document(cursor, article_id)
+            # New links in a live article need previews too
+            if article['status'] == 'published' and updated_article['content'] != article['content']:
+                enqueue_link_previews(cursor, updated_article)
+
+        article_cache.invalidate(article_id)
+        if 'published' in (article['status'], updated_article['status']):
+            feed_versions.bump()
+        return ArticleResponse(**updated_article)
+
+    except HTTPException:
+        raise
+    except UnsafeContent as e:
+        raise HTTPException(status_code=400, detail=f"Unsafe content: {e}")
+    except Exception as e:
+        logger.error(f"Update article error: {e}", exc_info=True)
+        raise HTTPException(status_code=500, detail="Failed to update article")
@@ -0,0 +1,66 @@
+"""
+Admin audit log routes for FastAPI backend
+"""
+
+import sys
+import os
+from datetime import datetime
+from typing import Optional
+from fastapi import APIRouter, HTTPException, Depends, Query
+import logging
+
+sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
+
+from shared.database import get_postgres_cursor
+from shared.models import AuditEntryResponse, AuditPageResponse, AuditVerificationResponse
+from shared.audit import privileged_actions, verify_chain
+from shared.permissions import AUDIT_READ
+from ..dependencies import require_permission
+
+router = APIRouter()
+logger = logging.getLogger(__name__)
+
+
+@router.get("/", response_model=AuditPageResponse)
+async def list_audit_entries(
+    actor_id: Optional[str] = Query(None),
+    action: Optional[str] = Query(None, description="e.g. user_role_changed, setting_updated, http.post"),
+    resource_type: Optional[str] = Query(None),
+    resource_id: Optional[str] = Query(None),
+    since: Optional[datetime] = Query(None),
+    until: Optional[datetime] = Query(None),
+    before: Optional[int] = Query(None, ge=1, description="Only entries older than this sequence number"),
+    limit: int = Query(50, ge=1, le=200),
+    auditor: dict = Depends(require_permission(AUDIT_READ))
+):
+    """What administrators and auditors did, newest first (`audit:read`)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            entries = privileged_actions(
+                cursor, actor_id, action, resource_type, resource_id, since, until, before, limit
+            )
+        return AuditPageResponse(
+            entries=[AuditEntryResponse(**entry) for entry in entries],
+            next_before=entries[-1]['sequence'] if len(entries) == limit else None
+        )
+    except Exception as e:
+        logger.error(f"List audit entries error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve audit log")
+
+
+@router.get("/verify", response_model=AuditVerificationResponse)
+async def verify_audit_log(
+    after: int = Query(0, ge=0, description="Start after this sequence number, trusting it and the entries before"),
+    limit: int = Query(10000, ge=1, le=100000),
+    auditor: dict = Depends(require_permission(AUDIT_READ))
+):
+    """Recompute the hash chain to detect entries altered or removed behind the database's back (`audit:read`)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            result = verify_chain(cursor, after, limit)
+        if not result['valid']:
+            logger.warning(f"Admin audit log chain broken at {result['broken_at']}: {result['problem']}")
+        return AuditVerificationResponse(**result)
+    except Exception as e:
+        logger.error(f"Verify audit log error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to verify audit log")
@@ -4,79 +4,68 @@ Authentication routes for FastAPI backend
 
 import sys
 import os
-from fastapi import APIRouter, HTTPException, Depends, status
+from typing import Optional
+from fastapi import APIRouter, HTTPException, Depends, Form, Header, Request, Response, status
 import logging
 from datetime import datetime
 
 # Add parent directory to path for imports
 sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
 
-from shared.database import get_postgres_cursor, prepare_json_data
+from shared.database import get_postgres_cursor
 from shared.auth import auth_manager, hash_password, verify_password
-from shared.models import UserCreate, UserLogin, UserResponse, TokenResponse, BaseResponse
+from shared.models import UserCreate, UserLogin, UserResponse, TokenResponse, BaseResponse, SocialLoginCallback
 from shared.utils import generate_uuid, validate_email
-from ..dependencies import get_current_user
+from shared.webhooks import emit_user_registered
+from shared.events import user_registered
+from shared.tokens import introspect_token, is_introspection_client
+from shared.sessions import SessionStoreUnavailable, issue_session_token
+from shared.account_deletion import cancel_deletion, in_grace_period
+from shared.social_login import SocialLoginError, complete_login, enabled_providers, start_login
+from shared.repositories import Repositories
+from shared.tenancy import QuotaExceeded
+from shared.audit import client_of, record_security_event
+from ..dependencies import get_current_user, get_repositories
 
 router = APIRouter()
 logger = logging.getLogger(__name__)
 
 
 @router.post("/register", response_model=TokenResponse, status_code=status.HTTP_201_CREATED)
-async def register(user_data: UserCreate):
+async def register(user_data: UserCreate, request: Request, repos: Repositories = Depends(get_repositories)):
     """Register a new user"""
     try:
         # Check if user already exists
-        with get_postgres_cursor() as cursor:
-            cursor.execute(
-                "SELECT id FROM users WHERE email = %s OR username = %s",
-                (user_data.email, user_data.username)
+        if repos.users.exists(user_data.email, user_data.username):
+            raise HTTPException(
+                status_code=status.HTTP_409_CONFLICT,
+                detail="User with this email or username already exists"
             )
-            
-            if cursor.fetchone():
-                raise HTTPException(
-                    status_code=status.HTTP_409_CONFLICT,
-                    detail="User with this email or username already exists"
-                )
-            
-            # Create new user
-            user_id = generate_uuid()
-            hashed_password = hash_password(user_data.password)
-            
-            # Prepare JSON data properly for PostgreSQL
-            profile_data = prepare_json_data(user_data.profile_data or {})
-            preferences = prepare_json_data(user_data.preferences or {})
-            
-            cursor.execute("""
-                INSERT INTO users (
-                    id, username, email, password_hash, role, anonymous_mode,
-                    profile_data, preferences, created_at, updated_at, last_active
-                ) VALUES (%s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s)
-                RETURNING *
-            """, (
-                user_id, 
-                user_data.username, 
-                user_data.email, 
-                hashed_password,
-                user_data.role.value, 
-                user_data.anonymous_mode,
-                profile_data,  # Now properly prepared for PostgreSQL
-                preferences,   # Now properly prepared for PostgreSQL
-                datetime.now(), 
-                datetime.now(), 
-                datetime.now()
-            ))
-            
-            user_record = cursor.fetchone()
-            
-            if not user_record:
-                raise HTTPException(
-                    status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
-                    detail="Failed to create user"
-                )
+        
+        # Create new user
+        now = datetime.now()
+        user_record = repos.users.create({
+            'id': generate_uuid(),
+            'username': user_data.username,
+            'email': user_data.email,
+            'password_hash': hash_password(user_data.password),
+            'role': user_data.role.value,
+            'anonymous_mode': user_data.anonymous_mode,
+            'profile_data': user_data.profile_data or {},
+            'preferences': user_data.preferences or {},
+            'created_at': now,
+            'updated_at': now,
+            'last_active': now,
+        })
+        
+        emit_user_registered(repos.cursor, user_record)
+        user_registered(repos.cursor, user_record)
         
         # Create response
-        user_response = UserResponse(**dict(user_record))
-        access_token = auth_manager.create_access_token(dict(user_record))
+        user_response = UserResponse(**user_record)
+        access_token = issue_session_token(
+            user_record, request.client.host if request.client else None, request.headers.get('user-agent')
+        )
         
         logger.info(f"User registered successfully: {user_data.username} ({user_data.email})")
         
@@ -88,6 +77,13 @@ async def register(user_data: UserCreate):
     
     except HTTPException:
         raise
+    except QuotaExceeded as e:
+        raise HTTPException(status_code=status.HTTP_403_FORBIDDEN, detail=str(e))
+    except SessionStoreUnavailable:
+        raise HTTPException(
+            status_code=status.HTTP_503_SERVICE_UNAVAILABLE,
+            detail="Sign-in is temporarily unavailable"
+        )
     except Exception as e:
         logger.error(f"Registration error: {e}", exc_info=True)
         raise HTTPException(
@@ -97,34 +93,35 @@ async def register(user_data: UserCreate):
 
 
 @router.post("/login", response_model=TokenResponse)
-async def login(login_data: UserLogin):
+async def login(login_data: UserLogin, request: Request, repos: Repositories = Depends(get_repositories)):
     """Login user and return JWT token"""
     try:
         # Check user credentials
-        with get_postgres_cursor() as cursor:
-            cursor.execute(
-                "SELECT * FROM users WHERE email = %s AND is_active = true",
-                (login_data.email,)
-            )
-            
-            user_record = cursor.fetchone()
-            
-            if not user_record or not verify_password(login_data.password, user_record['password_hash']):
-                raise HTTPException(
-                    status_code=status.HTTP_401_UNAUTHORIZED,
-                    detail="Invalid credentials"
-                )
-            
-            # Update last active
-            cursor.execute(
-                "UPDATE users SET last_active = %s WHERE id = %s",
-                (datetime.now(), user_record['id'])
+        user_record = repos.users.get_by_email(login_data.email, active_only=False)
+        usable = user_record and (user_record['is_active'] or in_grace_period(user_record))
+        
+        if not usable or not verify_password(login_data.password, user_record['password_hash']):
+            if user_record:
+                record_security_event(user_record['id'], 'login_failed', *client_of(request), details={'method': 'password'})
+            raise HTTPException(
+                status_code=status.HTTP_401_UNAUTHORIZED,
+                detail="Invalid credentials"
             )
         
+        # Signing in during the grace period cancels a requested deletion
+        if not user_record['is_active']:
+            user_record = cancel_deletion(repos.users, user_record)
+        
+        # Update last active
+        repos.users.touch_last_active(user_record['id'])
+        
         # Create response
-        user_response = UserResponse(**dict(user_record))
-        access_token = auth_manager.create_access_token(dict(user_record))
+        user_response = UserResponse(**user_record)
+        access_token = issue_session_token(
+            user_record, request.client.host if request.client else None, request.headers.get('user-agent')
+        )
         
+        record_security_event(user_record['id'], 'login', *client_of(request), details={'method': 'password'})
         logger.info(f"User logged in successfully: {user_record['username']}")
         
         return TokenResponse(
@@ -135,6 +132,11 @@ async def login(login_data: UserLogin):
     
     except HTTPException:
         raise
+    except SessionStoreUnavailable:
+        raise HTTPException(
+            status_code=status.HTTP_503_SERVICE_UNAVAILABLE,
+            detail="Sign-in is temporarily unavailable"
+        )
     except Exception as e:
         logger.error(f"Login error: {e}", exc_info=True)
         raise HTTPException(
@@ -150,11 +152,14 @@ async def get_current_user_info(current_user: dict = Depends(get_current_user)):
 
 
 @router.post("/refresh", response_model=BaseResponse)
-async def refresh_token(current_user: dict = Depends(get_current_user)):
+async def refresh_token(request: Request, current_user: dict = Depends(get_current_user)):
     """Refresh JWT token"""
     try:
-        # Create new token
-        new_token = auth_manager.create_access_token(current_user)
+        # Create new token within the same session
+        new_token = issue_session_token(
+            current_user, request.client.host if request.client else None, request.headers.get('user-agent'),
+            session_id=request.state.token_claims.get('sid')
+        )
         
         return {
             'success': True,
@@ -163,6 +168,11 @@ async def refresh_token(current_user: dict = Depends(get_current_user)):
             'expires_in': auth_manager.access_token_expires
         }
     
+    except SessionStoreUnavailable:
+        raise HTTPException(
+            status_code=status.HTTP_503_SERVICE_UNAVAILABLE,
+            detail="Sign-in is temporarily unavailable"
+        )
     except Exception as e:
         logger.error(f"Token refresh error: {e}", exc_info=True)
         raise HTTPException(
@@ -171,38 +181,102 @@ async def refresh_token(current_user: dict = Depends(get_current_user)):
         )
 
 
+@router.post("/introspect")
+async def introspect(
+    token: str = Form(...),
+    token_type_hint: Optional[str] = Form(None),
+    x_introspection_secret: Optional[str] = Header(None)
+):
+    """RFC 7662 token introspection for resource servers holding the introspection secret"""
+    if not is_introspection_client(x_introspection_secret):
+        raise HTTPException(status_code=status.HTTP_401_UNAUTHORIZED, detail="Invalid introspection credentials")
+
+    try:
+        return introspect_token(token)
+    except Exception as e:
+        logger.error(f"Token introspection error: {e}", exc_info=True)
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail="Token introspection failed"
+        )
+
+
+@router.get("/social/providers")
+async def social_providers():
+    """Social login providers enabled on this instance"""
+    return {"success": True, "providers": enabled_providers()}
+
+
+@router.get("/social/{provider}/authorize")
+async def social_authorize(provider: str):
+    """Start a social login: the frontend sends the browser to `authorization_url`"""
+    try:
+        return {"success": True, **start_login(provider)}
+    except SocialLoginError as e:
+        raise HTTPException(status_code=e.status_code, detail=e.message)
+    except Exception as e:
+        logger.error(f"Social login start error: {e}", exc_info=True)
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail="Failed to start sign-in"
+        )
+
+
+@router.post("/social/{provider}/callback", response_model=TokenResponse)
+async def social_callback(provider: str, callback: SocialLoginCallback, request: Request, response: Response):
+    """Finish a social login with the code and state the provider redirected back with"""
+    try:
+        with get_postgres_cursor() as cursor:
+            user_record, created = complete_login(cursor, provider, callback.code, callback.state)
+        
+        if created:
+            response.status_code = status.HTTP_201_CREATED
+        access_token = issue_session_token(
+            user_record, request.client.host if request.client else None, request.headers.get('user-agent')
+        )
+        record_security_event(user_record['id'], 'login', *client_of(request), details={'method': provider})
+        
+        return TokenResponse(
+            access_token=access_token,
+            expires_in=auth_manager.access_token_expires,
+            user=UserResponse(**user_record)
+        )
+    
+    except SocialLoginError as e:
+        raise HTTPException(status_code=e.status_code, detail=e.message)
+    except QuotaExceeded as e:
+        raise HTTPException(status_code=status.HTTP_403_FORBIDDEN, detail=str(e))
+    except SessionStoreUnavailable:
+        raise HTTPException(
+            status_code=status.HTTP_503_SERVICE_UNAVAILABLE,
+            detail="Sign-in is temporarily unavailable"
+        )
+    except Exception as e:
+        logger.error(f"Social login error: {e}", exc_info=True)
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail="Sign-in failed"
+        )
+
+
 @router.put("/profile", response_model=UserResponse)
 async def update_profile(
     profile_data: dict, 
-    current_user: dict = Depends(get_current_user)
+    current_user: dict = Depends(get_current_user),
+    repos: Repositories = Depends(get_repositories)
 ):
     """Update user profile data"""
     try:
-        with get_postgres_cursor() as cursor:
-            # Prepare JSON data properly
-            prepared_profile_data = prepare_json_data(profile_data)
-            
-            cursor.execute("""
-                UPDATE users 
-                SET profile_data = %s, updated_at = %s 
-                WHERE id = %s
-                RETURNING *
-            """, (
-                prepared_profile_data,
-                datetime.now(),
-                current_user['id']
-            ))
-            
-            updated_user = cursor.fetchone()
-            
-            if not updated_user:
-                raise HTTPException(
-                    status_code=status.HTTP_404_NOT_FOUND,
-                    detail="User not found"
-                )
+        updated_user = repos.users.update(current_user['id'], {'profile_data': profile_data})
+        
+        if not updated_user:
+            raise HTTPException(
+                status_code=status.HTTP_404_NOT_FOUND,
+                detail="User not found"
+            )
         
         logger.info(f"Profile updated for user: {current_user['username']}")
-        return UserResponse(**dict(updated_user))
+        return UserResponse(**updated_user)
     
     except HTTPException:
         raise
@@ -217,35 +291,21 @@ async def update_profile(
 @router.put("/preferences", response_model=UserResponse)
 async def update_preferences(
     preferences_data: dict, 
-    current_user: dict = Depends(get_current_user)
+    current_user: dict = Depends(get_current_user),
+    repos: Repositories = Depends(get_repositories)
 ):
     """Update user preferences"""
     try:
-        with get_postgres_cursor() as cursor:
-            # Prepare JSON data properly
-            prepared_preferences = prepare_json_data(preferences_data)
-            
-            cursor.execute("""
-                UPDATE users 
-                SET preferences = %s, updated_at = %s 
-                WHERE id = %s
-                RETURNING *
-            """, (
-                prepared_preferences,
-                datetime.now(),
-                current_user['id']
-            ))
-            
-            updated_user = cursor.fetchone()
-            
-            if not updated_user:
-                raise HTTPException(
-                    status_code=status.HTTP_404_NOT_FOUND,
-                    detail="User not found"
-                )
+        updated_user = repos.users.update(current_user['id'], {'preferences': preferences_data})
+        
+        if not updated_user:
+            raise HTTPException(
+                status_code=status.HTTP_404_NOT_FOUND,
+                detail="User not found"
+            )
         
         logger.info(f"Preferences updated for user: {current_user['username']}")
-        return UserResponse(**dict(updated_user))
+        return UserResponse(**updated_user)
     
     except HTTPException:
         raise
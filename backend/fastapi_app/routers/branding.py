@@ -0,0 +1,40 @@
+"""
+Public branding routes for FastAPI backend
+"""
+
+import sys
+import os
+from fastapi import APIRouter, HTTPException, Request
+from fastapi.responses import JSONResponse
+import logging
+
+sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
+
+from shared.database import get_postgres_cursor
+from shared.models import BrandingResponse
+from shared.branding import BROWSER_MAX_AGE, EDGE_MAX_AGE, resolve_branding
+from shared.edge_cache import surrogate_headers
+
+router = APIRouter()
+logger = logging.getLogger(__name__)
+
+
+@router.get("/", response_model=BrandingResponse)
+async def get_branding(request: Request):
+    """Branding for the domain the site is served on
+
+    Resolved from the Host header only: the edge caches per host, so honouring
+    a client-supplied forwarding header would let one host's entry be poisoned.
+    """
+    try:
+        with get_postgres_cursor() as cursor:
+            resolved = resolve_branding(cursor, request.headers.get('host'))
+
+        body = BrandingResponse(**resolved['branding'])
+        return JSONResponse(
+            content=body.model_dump(mode='json'),
+            headers=surrogate_headers(resolved['surrogate_keys'], BROWSER_MAX_AGE, EDGE_MAX_AGE)
+        )
+    except Exception as e:
+        logger.error(f"Get branding error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve branding")
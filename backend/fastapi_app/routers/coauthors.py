@@ -0,0 +1,185 @@
+"""
+Co-authorship routes for FastAPI backend
+"""
+
+import sys
+import os
+from typing import List, Optional
+from fastapi import APIRouter, HTTPException, Depends, status
+import logging
+
+sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
+
+from shared.database import get_postgres_cursor
+from shared.models import (
+    ArticleAuthorInvite, ArticleAuthorResponse, ArticleAuthorUpdate, CoauthorInvitationResponse
+)
+from shared import article_cache, coauthors, permissions
+from ..dependencies import get_current_user, get_optional_user
+
+router = APIRouter()
+logger = logging.getLogger(__name__)
+
+
+def get_article(cursor, article_id: str) -> dict:
+    cursor.execute(
+        "SELECT id, author_id, coauthor_ids, status, anonymous_author FROM articles WHERE id = %s FOR UPDATE",
+        (article_id,)
+    )
+    article = cursor.fetchone()
+    if not article:
+        raise HTTPException(status_code=404, detail="Article not found")
+    return dict(article)
+
+
+def get_managed_article(cursor, article_id: str, user: dict) -> dict:
+    article = get_article(cursor, article_id)
+    if not coauthors.can_manage(user, article):
+        raise HTTPException(status_code=403, detail="Only the lead author can manage the article's authors")
+    return article
+
+
+def credits_changed(article: dict):
+    """Drop the rendered article, whose `authors` no longer match"""
+    if article['status'] == 'published':
+        article_cache.invalidate(str(article['id']))
+
+
+async def respond_to_invitation(article_id: str, current_user: dict, accept: bool) -> ArticleAuthorResponse:
+    try:
+        with get_postgres_cursor() as cursor:
+            article = get_article(cursor, article_id)
+            credit = coauthors.respond(cursor, article, current_user, accept)
+        if accept:
+            credits_changed(article)
+        logger.info(
+            f"User {current_user['id']} {'accepted' if accept else 'declined'} co-authoring article {article_id}"
+        )
+        return ArticleAuthorResponse(**credit)
+    except HTTPException:
+        raise
+    except LookupError as e:
+        raise HTTPException(status_code=404, detail=str(e))
+    except Exception as e:
+        logger.error(f"Respond to co-author invitation error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to answer invitation")
+
+
+@router.get("/articles/{article_id}/authors", response_model=List[ArticleAuthorResponse])
+async def list_article_authors(article_id: str, current_user: Optional[dict] = Depends(get_optional_user)):
+    """Authors credited on an article, lead first
+
+    Its authors and holders of `article:edit_any` also see pending and declined invitations, and drafts.
+    """
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute(
+                "SELECT id, author_id, coauthor_ids, status, anonymous_author FROM articles WHERE id = %s",
+                (article_id,)
+            )
+            article = cursor.fetchone()
+            editing = bool(article) and permissions.can_edit_article(current_user, article)
+            if not article or not editing and (article['status'] != 'published' or article['anonymous_author']):
+                raise HTTPException(status_code=404, detail="Article not found")
+            credits = coauthors.authors(cursor, article_id)
+        if not editing:
+            credits = [credit for credit in credits if credit['status'] == coauthors.ACCEPTED]
+        return [ArticleAuthorResponse(**credit) for credit in credits]
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"List article authors error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve authors")
+
+
+@router.post("/articles/{article_id}/authors", response_model=ArticleAuthorResponse,
+             status_code=status.HTTP_201_CREATED)
+async def invite_article_author(article_id: str, invite: ArticleAuthorInvite,
+                                current_user: dict = Depends(get_current_user)):
+    """Invite a user of the publication to co-author as contributor or translator (lead author or `article:edit_any`)
+
+    They're credited once they accept.
+    """
+    try:
+        with get_postgres_cursor() as cursor:
+            article = get_managed_article(cursor, article_id, current_user)
+            credit = coauthors.invite(cursor, article, str(invite.user_id), invite.role, current_user)
+        logger.info(f"User {invite.user_id} invited to co-author article {article_id} by {current_user['id']}")
+        return ArticleAuthorResponse(**credit)
+    except HTTPException:
+        raise
+    except LookupError as e:
+        raise HTTPException(status_code=400, detail=str(e))
+    except coauthors.CoauthorError as e:
+        raise HTTPException(status_code=409, detail=str(e))
+    except Exception as e:
+        logger.error(f"Invite article author error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to invite author")
+
+
+@router.post("/articles/{article_id}/authors/accept", response_model=ArticleAuthorResponse)
+async def accept_coauthor_invitation(article_id: str, current_user: dict = Depends(get_current_user)):
+    """Accept an invitation to co-author the article"""
+    return await respond_to_invitation(article_id, current_user, accept=True)
+
+
+@router.post("/articles/{article_id}/authors/decline", response_model=ArticleAuthorResponse)
+async def decline_coauthor_invitation(article_id: str, current_user: dict = Depends(get_current_user)):
+    """Decline an invitation to co-author the article"""
+    return await respond_to_invitation(article_id, current_user, accept=False)
+
+
+@router.patch("/articles/{article_id}/authors/{user_id}", response_model=ArticleAuthorResponse)
+async def update_article_author(article_id: str, user_id: str, update: ArticleAuthorUpdate,
+                                current_user: dict = Depends(get_current_user)):
+    """Change a co-author's role (lead author or `article:edit_any`)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            article = get_managed_article(cursor, article_id, current_user)
+            credit = coauthors.set_role(cursor, article, user_id, update.role, current_user)
+        credits_changed(article)
+        return ArticleAuthorResponse(**credit)
+    except HTTPException:
+        raise
+    except LookupError as e:
+        raise HTTPException(status_code=404, detail=str(e))
+    except coauthors.CoauthorError as e:
+        raise HTTPException(status_code=409, detail=str(e))
+    except Exception as e:
+        logger.error(f"Update article author error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to update author")
+
+
+@router.delete("/articles/{article_id}/authors/{user_id}")
+async def remove_article_author(article_id: str, user_id: str, current_user: dict = Depends(get_current_user)):
+    """Take away a co-author's credit or invitation (lead author or `article:edit_any`), or stop co-authoring"""
+    try:
+        with get_postgres_cursor() as cursor:
+            article = get_article(cursor, article_id)
+            if user_id != str(current_user['id']) and not coauthors.can_manage(current_user, article):
+                raise HTTPException(status_code=403, detail="Only the lead author can manage the article's authors")
+            coauthors.remove(cursor, article, user_id, current_user)
+        credits_changed(article)
+        logger.info(f"User {user_id} no longer credited on article {article_id}, by {current_user['id']}")
+        return {"success": True, "message": "Author removed"}
+    except HTTPException:
+        raise
+    except LookupError as e:
+        raise HTTPException(status_code=404, detail=str(e))
+    except coauthors.CoauthorError as e:
+        raise HTTPException(status_code=409, detail=str(e))
+    except Exception as e:
+        logger.error(f"Remove article author error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to remove author")
+
+
+@router.get("/coauthor-invitations", response_model=List[CoauthorInvitationResponse])
+async def list_coauthor_invitations(current_user: dict = Depends(get_current_user)):
+    """Invitations to co-author waiting for the caller's answer"""
+    try:
+        with get_postgres_cursor() as cursor:
+            invitations = coauthors.invitations(cursor, str(current_user['id']))
+        return [CoauthorInvitationResponse(**invitation) for invitation in invitations]
+    except Exception as e:
+        logger.error(f"List co-author invitations error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve invitations")
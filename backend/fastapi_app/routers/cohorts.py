@@ -0,0 +1,53 @@
+"""
+Cohort retention analytics routes for FastAPI backend
+"""
+
+import sys
+import os
+from datetime import datetime, timezone
+from fastapi import APIRouter, HTTPException, Depends, Query
+from fastapi.responses import Response
+import logging
+
+sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
+
+from shared.database import get_postgres_cursor, query_timeout
+from shared import cohorts
+from ..dependencies import get_admin_user
+
+router = APIRouter()
+logger = logging.getLogger(__name__)
+
+
+@router.get("/cohorts")
+async def get_cohorts(
+    weeks: int = Query(12, ge=1, le=cohorts.MAX_WEEKS, description="How many weekly signup cohorts, newest last"),
+    metric: str = Query("active_users", pattern="^(active_users|returning_users|interacting_users)$"),
+    format: str = Query("json", pattern="^(json|csv)$"),
+    value: str = Query("rate", pattern="^(rate|users)$", description="What the CSV cells hold"),
+    admin_user: dict = Depends(get_admin_user)
+):
+    """Retention of weekly signup cohorts: one row per cohort, one cell per week since signup (admin only)"""
+    try:
+        with get_postgres_cursor(timeout_ms=query_timeout('analytics')) as cursor:
+            matrix = cohorts.matrix(cursor, weeks, metric)
+            rolled_up_at = cohorts.rolled_up_at(cursor)
+
+        if format == "csv":
+            filename = f"cohorts-{metric}-{datetime.now(timezone.utc).date().isoformat()}.csv"
+            return Response(
+                content=cohorts.to_csv(matrix, value),
+                media_type="text/csv",
+                headers={"Content-Disposition": f'attachment; filename="{filename}"'}
+            )
+
+        return {
+            "success": True,
+            "metric": metric,
+            "rolled_up_at": rolled_up_at.isoformat() if rolled_up_at else None,
+            "cohorts": matrix
+        }
+
+    except Exception as e:
+        logger.error(f"Get cohorts error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to get cohort retention")
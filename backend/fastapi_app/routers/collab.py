@@ -0,0 +1,186 @@
+"""
+Collaborative draft editing routes for FastAPI backend
+"""
+
+import sys
+import os
+import json
+import uuid
+import asyncio
+from typing import Optional
+from fastapi import APIRouter, HTTPException, Depends, Query, WebSocket, WebSocketDisconnect, status
+import logging
+
+sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
+
+from shared.database import get_postgres_cursor
+from shared.models import ArticleResponse
+from shared.draft_collab import (
+    COMPACT_AFTER_UPDATES, channel, compact, editable_draft, load_state, materialize, pubsub_client,
+    store_update, validate_update
+)
+from shared.draft_presence import list_presence, remove_presence, set_presence
+from ..dependencies import get_current_user, get_websocket_user
+
+router = APIRouter()
+logger = logging.getLogger(__name__)
+
+# Fan-out frames: the sending connection's id (so it doesn't get its own messages back), then a kind byte
+CONNECTION_ID_BYTES = 16
+UPDATE_FRAME = b'u'  # A Yjs update, forwarded as a binary message
+JSON_FRAME = b'j'  # Presence and save notices, forwarded as a text message
+
+
+async def relay_messages(websocket: WebSocket, pubsub, connection_id: bytes):
+    async for message in pubsub.listen():
+        if message['type'] != 'message':
+            continue
+        data = message['data']
+        if data[:CONNECTION_ID_BYTES] == connection_id:
+            continue
+        kind, payload = data[CONNECTION_ID_BYTES:CONNECTION_ID_BYTES + 1], data[CONNECTION_ID_BYTES + 1:]
+        if kind == UPDATE_FRAME:
+            await websocket.send_bytes(payload)
+        elif kind == JSON_FRAME:
+            await websocket.send_text(payload.decode('utf-8'))
+
+
+async def broadcast_json(client, article_id: str, connection_id: bytes, message: dict):
+    await client.publish(channel(article_id), connection_id + JSON_FRAME + json.dumps(message).encode('utf-8'))
+
+
+def save_draft(article_id: str) -> Optional[dict]:
+    with get_postgres_cursor() as cursor:
+        return materialize(cursor, article_id)
+
+
+@router.websocket("/{article_id}/collab")
+async def collaborate(websocket: WebSocket, article_id: str, token: Optional[str] = Query(None)):
+    """Exchange Yjs updates and presence with the other editors of a draft
+
+    The server first sends the whole document as a binary message and the
+    current collaborators as `{"type": "presence", "collaborators": [...]}`.
+    After that, binary messages in both directions are Yjs updates. Text
+    messages from the client are `{"type": "presence", "state", "cursor"}`
+    (also the heartbeat that keeps the client listed) or `{"type": "save"}`,
+    which writes the document into the draft. Others' presence changes arrive
+    as `{"type": "presence", "event": "join" | "update" | "leave", ...}`.
+    """
+    user = get_websocket_user(token)
+    if not user:
+        await websocket.close(code=status.WS_1008_POLICY_VIOLATION)
+        return
+    with get_postgres_cursor() as cursor:
+        article = editable_draft(cursor, article_id, user)
+    if not article:
+        await websocket.close(code=status.WS_1008_POLICY_VIOLATION)
+        return
+
+    await websocket.accept()
+    connection_id = uuid.uuid4().bytes
+    presence_id = connection_id.hex()
+    client = pubsub_client()
+    pubsub = client.pubsub()
+    relay = None
+    try:
+        # Subscribe before loading so nothing published in between is missed; Yjs ignores duplicates
+        await pubsub.subscribe(channel(article_id))
+        with get_postgres_cursor() as cursor:
+            state = load_state(cursor, article)
+        await websocket.send_bytes(state)
+
+        entry = set_presence(article_id, presence_id, user)
+        await websocket.send_json({"type": "presence", "collaborators": list_presence(article_id)})
+        await broadcast_json(client, article_id, connection_id, {"type": "presence", "event": "join", **entry})
+        relay = asyncio.create_task(relay_messages(websocket, pubsub, connection_id))
+
+        while True:
+            message = await websocket.receive()
+            if message['type'] == 'websocket.disconnect':
+                break
+
+            if message.get('bytes') is not None:
+                update = message['bytes']
+                if not validate_update(update):
+                    await websocket.send_json({"type": "error", "detail": "Invalid update"})
+                    continue
+                with get_postgres_cursor() as cursor:
+                    if store_update(cursor, article_id, update, user['id']) >= COMPACT_AFTER_UPDATES:
+                        compact(cursor, article_id)
+                await client.publish(channel(article_id), connection_id + UPDATE_FRAME + update)
+                continue
+
+            try:
+                command = json.loads(message.get('text') or '')
+            except ValueError:
+                command = None
+            if not isinstance(command, dict):
+                command = {}
+
+            if command.get('type') == 'presence':
+                entry = set_presence(
+                    article_id, presence_id, user, command.get('state') or entry['state'], command.get('cursor')
+                )
+                await broadcast_json(client, article_id, connection_id, {"type": "presence", "event": "update", **entry})
+            elif command.get('type') == 'save':
+                saved = save_draft(article_id)
+                if not saved:
+                    await websocket.send_json({"type": "error", "detail": "Article is no longer a draft"})
+                    await websocket.close(code=status.WS_1008_POLICY_VIOLATION)
+                    break
+                notice = {"type": "saved", "updated_at": saved['updated_at'].isoformat(), "by": user['username']}
+                await websocket.send_json(notice)
+                await broadcast_json(client, article_id, connection_id, notice)
+            else:
+                await websocket.send_json({"type": "error", "detail": "Unknown command"})
+    except WebSocketDisconnect:
+        pass
+    except Exception as e:
+        logger.error(f"Draft collaboration error on {article_id}: {e}")
+        await websocket.close(code=status.WS_1011_INTERNAL_ERROR)
+    finally:
+        if relay:
+            relay.cancel()
+        remove_presence(article_id, presence_id)
+        try:
+            await broadcast_json(client, article_id, connection_id, {
+                "type": "presence", "event": "leave", "connection_id": presence_id, "user_id": str(user['id'])
+            })
+        except Exception as e:
+            logger.warning(f"Could not announce leaving {article_id}: {e}")
+        await pubsub.unsubscribe(channel(article_id))
+        await client.aclose()
+
+
+@router.get("/{article_id}/collab/presence")
+async def get_draft_presence(article_id: str, current_user: dict = Depends(get_current_user)):
+    """Who is viewing or editing a draft right now, with their cursor positions"""
+    try:
+        with get_postgres_cursor() as cursor:
+            if not editable_draft(cursor, article_id, current_user):
+                raise HTTPException(status_code=404, detail="Draft not found")
+        return {"collaborators": list_presence(article_id)}
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Draft presence error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve presence")
+
+
+@router.post("/{article_id}/collab/save", response_model=ArticleResponse)
+async def save_collaborative_draft(article_id: str, current_user: dict = Depends(get_current_user)):
+    """Write the collaborative document into the draft's title, summary and content"""
+    try:
+        with get_postgres_cursor() as cursor:
+            if not editable_draft(cursor, article_id, current_user):
+                raise HTTPException(status_code=404, detail="Draft not found")
+
+        saved = save_draft(article_id)
+        if not saved:
+            raise HTTPException(status_code=404, detail="Draft has no collaborative document")
+        return ArticleResponse(**saved)
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Save collaborative draft error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to save draft")
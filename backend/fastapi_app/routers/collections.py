@@ -0,0 +1,370 @@
+"""
+Editorial collection routes for FastAPI backend
+"""
+
+import sys
+import os
+from typing import List
+from fastapi import APIRouter, HTTPException, Depends, status, Query, Request
+from fastapi.responses import Response
+import logging
+import psycopg2
+
+sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
+
+from shared.database import get_postgres_cursor
+from shared.models import (
+    ArticleResponse, CollectionCreate, CollectionUpdate, CollectionSectionCreate,
+    CollectionArticleAdd, CollectionRuleCreate, CollectionResponse, CollectionSectionResponse
+)
+from shared.editorial_collections import (
+    find_collection, load_collection_articles, backfill_collection_rules
+)
+from shared.feed_formats import FEED_FORMATS, PUBLIC_BASE_URL
+from ..dependencies import get_admin_user
+
+router = APIRouter()
+logger = logging.getLogger(__name__)
+
+
+def build_collection_response(cursor, collection: dict, include_rules: bool = False) -> CollectionResponse:
+    """Assemble a collection with its sections and articles"""
+    cursor.execute(
+        "SELECT * FROM collection_sections WHERE collection_id = %s ORDER BY position ASC, created_at ASC",
+        (collection['id'],)
+    )
+    sections = {
+        str(row['id']): CollectionSectionResponse(
+            id=row['id'], title=row['title'], description=row['description'], position=row['position']
+        )
+        for row in cursor.fetchall()
+    }
+    unsectioned = CollectionSectionResponse(title=collection['title'], position=-1)
+
+    for row in load_collection_articles(cursor, collection['id']):
+        row = dict(row)
+        section_id = row.pop('collection_section_id')
+        row.pop('collection_position')
+        row.pop('collection_source')
+        section = sections.get(str(section_id)) if section_id else None
+        (section or unsectioned).articles.append(ArticleResponse(**row))
+
+    ordered = list(sections.values())
+    if unsectioned.articles:
+        ordered.insert(0, unsectioned)
+
+    rules = []
+    if include_rules:
+        cursor.execute(
+            "SELECT id, rule_type, value, section_id FROM collection_rules WHERE collection_id = %s ORDER BY created_at",
+            (collection['id'],)
+        )
+        rules = [dict(row) for row in cursor.fetchall()]
+
+    return CollectionResponse(**collection, sections=ordered, rules=rules)
+
+
+@router.get("/", response_model=List[CollectionResponse])
+async def list_collections(
+    limit: int = Query(20, ge=1, le=100),
+    offset: int = Query(0, ge=0)
+):
+    """List published collections"""
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute("""
+                SELECT * FROM collections WHERE is_published = true
+                ORDER BY updated_at DESC LIMIT %s OFFSET %s
+            """, (limit, offset))
+            collections = cursor.fetchall()
+
+        return [CollectionResponse(**dict(collection)) for collection in collections]
+    except Exception as e:
+        logger.error(f"List collections error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve collections")
+
+
+@router.get("/{slug}", response_model=CollectionResponse)
+async def get_collection(slug: str):
+    """Get a published collection with its sections and articles"""
+    try:
+        with get_postgres_cursor() as cursor:
+            collection = find_collection(cursor, slug)
+            if not collection:
+                raise HTTPException(status_code=404, detail="Collection not found")
+            return build_collection_response(cursor, collection)
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Get collection error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve collection")
+
+
+def render_collection_feed(slug: str, request: Request, feed_format: str) -> Response:
+    try:
+        with get_postgres_cursor() as cursor:
+            collection = find_collection(cursor, slug)
+            if not collection:
+                raise HTTPException(status_code=404, detail="Collection not found")
+            articles = [dict(row) for row in load_collection_articles(cursor, collection['id'], limit=50)]
+
+        articles.sort(key=lambda article: article['published_at'], reverse=True)
+        media_type, render = FEED_FORMATS[feed_format]
+        body = render(
+            title=collection['title'],
+            link=f"{PUBLIC_BASE_URL.rstrip('/')}/collections/{collection['slug']}",
+            description=collection['description'] or '',
+            articles=articles,
+            self_url=str(request.url)
+        )
+        return Response(content=body, media_type=media_type)
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Get collection {feed_format} feed error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to render collection feed")
+
+
+@router.get("/{slug}/rss")
+async def get_collection_rss(slug: str, request: Request):
+    """RSS 2.0 feed of a published collection"""
+    return render_collection_feed(slug, request, 'rss')
+
+
+@router.get("/{slug}/feed.json")
+async def get_collection_json_feed(slug: str, request: Request):
+    """JSON Feed 1.1 of a published collection"""
+    return render_collection_feed(slug, request, 'json')
+
+
+@router.post("/", response_model=CollectionResponse, status_code=status.HTTP_201_CREATED)
+async def create_collection(collection_data: CollectionCreate, admin_user: dict = Depends(get_admin_user)):
+    """Create a collection (admin only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute("""
+                INSERT INTO collections (slug, title, description, cover_image_url, is_published, created_by)
+                VALUES (%s, %s, %s, %s, %s, %s)
+                RETURNING *
+            """, (
+                collection_data.slug, collection_data.title, collection_data.description,
+                collection_data.cover_image_url, collection_data.is_published, str(admin_user['id'])
+            ))
+            collection = cursor.fetchone()
+
+        return CollectionResponse(**dict(collection))
+    except psycopg2.errors.UniqueViolation:
+        raise HTTPException(status_code=409, detail="A collection with this slug already exists")
+    except Exception as e:
+        logger.error(f"Create collection error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to create collection")
+
+
+@router.get("/{collection_id}/admin", response_model=CollectionResponse)
+async def get_collection_admin(collection_id: str, admin_user: dict = Depends(get_admin_user)):
+    """Get a collection including unpublished state and rules (admin only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            collection = find_collection(cursor, collection_id, published_only=False)
+            if not collection:
+                raise HTTPException(status_code=404, detail="Collection not found")
+            return build_collection_response(cursor, collection, include_rules=True)
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Get collection admin error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve collection")
+
+
+@router.patch("/{collection_id}", response_model=CollectionResponse)
+async def update_collection(collection_id: str, collection_update: CollectionUpdate,
+                            admin_user: dict = Depends(get_admin_user)):
+    """Update collection details (admin only)"""
+    update_data = collection_update.dict(exclude_unset=True)
+    if not update_data:
+        raise HTTPException(status_code=400, detail="No valid fields to update")
+
+    update_fields = [f"{field} = %s" for field in update_data]
+    params = list(update_data.values()) + [collection_id]
+
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute(
+                f"UPDATE collections SET {', '.join(update_fields)} WHERE id = %s RETURNING *",
+                params
+            )
+            collection = cursor.fetchone()
+            if not collection:
+                raise HTTPException(status_code=404, detail="Collection not found")
+
+        return CollectionResponse(**dict(collection))
+    except HTTPException:
+        raise
+    except psycopg2.errors.UniqueViolation:
+        raise HTTPException(status_code=409, detail="A collection with this slug already exists")
+    except Exception as e:
+        logger.error(f"Update collection error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to update collection")
+
+
+@router.delete("/{collection_id}")
+async def delete_collection(collection_id: str, admin_user: dict = Depends(get_admin_user)):
+    """Delete a collection (admin only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute("DELETE FROM collections WHERE id = %s RETURNING id", (collection_id,))
+            if not cursor.fetchone():
+                raise HTTPException(status_code=404, detail="Collection not found")
+
+        return {"success": True, "message": "Collection deleted"}
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Delete collection error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to delete collection")
+
+
+@router.post("/{collection_id}/sections", response_model=CollectionSectionResponse, status_code=status.HTTP_201_CREATED)
+async def create_section(collection_id: str, section_data: CollectionSectionCreate,
+                         admin_user: dict = Depends(get_admin_user)):
+    """Add a section to a collection (admin only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute("""
+                INSERT INTO collection_sections (collection_id, title, description, position)
+                VALUES (%s, %s, %s, %s)
+                RETURNING id, title, description, position
+            """, (collection_id, section_data.title, section_data.description, section_data.position))
+            section = cursor.fetchone()
+
+        return CollectionSectionResponse(**dict(section))
+    except psycopg2.errors.ForeignKeyViolation:
+        raise HTTPException(status_code=404, detail="Collection not found")
+    except Exception as e:
+        logger.error(f"Create section error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to create section")
+
+
+@router.delete("/{collection_id}/sections/{section_id}")
+async def delete_section(collection_id: str, section_id: str, admin_user: dict = Depends(get_admin_user)):
+    """Remove a section; its articles fall back to the unsectioned list (admin only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute(
+                "DELETE FROM collection_sections WHERE id = %s AND collection_id = %s RETURNING id",
+                (section_id, collection_id)
+            )
+            if not cursor.fetchone():
+                raise HTTPException(status_code=404, detail="Section not found")
+
+        return {"success": True, "message": "Section deleted"}
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Delete section error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to delete section")
+
+
+@router.post("/{collection_id}/articles", status_code=status.HTTP_201_CREATED)
+async def add_collection_article(collection_id: str, article_data: CollectionArticleAdd,
+                                 admin_user: dict = Depends(get_admin_user)):
+    """Manually add an article to a collection (admin only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute("""
+                INSERT INTO collection_articles (collection_id, article_id, section_id, position, source, added_by)
+                VALUES (%s, %s, %s, %s, 'manual', %s)
+                ON CONFLICT (collection_id, article_id) DO UPDATE
+                SET section_id = EXCLUDED.section_id, position = EXCLUDED.position,
+                    source = 'manual', rule_id = NULL, added_by = EXCLUDED.added_by
+                RETURNING id
+            """, (
+                collection_id, str(article_data.article_id),
+                str(article_data.section_id) if article_data.section_id else None,
+                article_data.position, str(admin_user['id'])
+            ))
+
+        return {"success": True, "message": "Article added to collection"}
+    except psycopg2.errors.ForeignKeyViolation:
+        raise HTTPException(status_code=404, detail="Collection, section or article not found")
+    except Exception as e:
+        logger.error(f"Add collection article error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to add article to collection")
+
+
+@router.delete("/{collection_id}/articles/{article_id}")
+async def remove_collection_article(collection_id: str, article_id: str, admin_user: dict = Depends(get_admin_user)):
+    """Remove an article from a collection (admin only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute(
+                "DELETE FROM collection_articles WHERE collection_id = %s AND article_id = %s RETURNING id",
+                (collection_id, article_id)
+            )
+            if not cursor.fetchone():
+                raise HTTPException(status_code=404, detail="Article is not in this collection")
+
+        return {"success": True, "message": "Article removed from collection"}
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Remove collection article error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to remove article from collection")
+
+
+@router.post("/{collection_id}/rules", status_code=status.HTTP_201_CREATED)
+async def create_rule(collection_id: str, rule_data: CollectionRuleCreate, admin_user: dict = Depends(get_admin_user)):
+    """Add a membership rule to a collection (admin only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute("""
+                INSERT INTO collection_rules (collection_id, section_id, rule_type, value)
+                VALUES (%s, %s, %s, %s)
+                RETURNING id, rule_type, value, section_id
+            """, (
+                collection_id, str(rule_data.section_id) if rule_data.section_id else None,
+                rule_data.rule_type.value, rule_data.value
+            ))
+            rule = cursor.fetchone()
+
+        return {"success": True, "rule": dict(rule)}
+    except psycopg2.errors.UniqueViolation:
+        raise HTTPException(status_code=409, detail="This rule already exists")
+    except psycopg2.errors.ForeignKeyViolation:
+        raise HTTPException(status_code=404, detail="Collection or section not found")
+    except Exception as e:
+        logger.error(f"Create rule error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to create rule")
+
+
+@router.delete("/{collection_id}/rules/{rule_id}")
+async def delete_rule(collection_id: str, rule_id: str, admin_user: dict = Depends(get_admin_user)):
+    """Remove a membership rule; articles it already added stay (admin only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute(
+                "DELETE FROM collection_rules WHERE id = %s AND collection_id = %s RETURNING id",
+                (rule_id, collection_id)
+            )
+            if not cursor.fetchone():
+                raise HTTPException(status_code=404, detail="Rule not found")
+
+        return {"success": True, "message": "Rule deleted"}
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Delete rule error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to delete rule")
+
+
+@router.post("/{collection_id}/rules/apply")
+async def apply_rules(collection_id: str, admin_user: dict = Depends(get_admin_user)):
+    """Apply a collection's rules to already published articles (admin only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            added = backfill_collection_rules(cursor, collection_id)
+
+        return {"success": True, "added": added}
+    except Exception as e:
+        logger.error(f"Apply rules error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to apply collection rules")
@@ -0,0 +1,106 @@
+"""
+Database consistency check routes for FastAPI backend
+"""
+
+import sys
+import os
+from typing import List, Optional
+from fastapi import APIRouter, HTTPException, Depends, Query, status
+import logging
+
+sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
+
+from shared.database import get_postgres_cursor
+from shared import consistency
+from shared.jobs import check_consistency
+from ..dependencies import get_admin_user
+
+router = APIRouter()
+logger = logging.getLogger(__name__)
+
+
+@router.get("/checks")
+async def list_checks(admin_user: dict = Depends(get_admin_user)):
+    """The registered checks and whether each can repair what it finds (admin only)"""
+    return {
+        "success": True,
+        "auto_repair": consistency.AUTO_REPAIR,
+        "checks": [
+            {"name": check.name, "description": check.description, "repairable": check.repair is not None}
+            for check in consistency.checks()
+        ],
+    }
+
+
+@router.get("/reports")
+async def list_reports(
+    limit: int = Query(20, ge=1, le=100),
+    admin_user: dict = Depends(get_admin_user)
+):
+    """Recent runs, newest first, without their per-check results (admin only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            reports = consistency.list_reports(cursor, limit)
+        return {"success": True, "reports": reports}
+    except Exception as e:
+        logger.error(f"List consistency reports error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve consistency reports")
+
+
+@router.get("/reports/latest")
+async def latest_report(admin_user: dict = Depends(get_admin_user)):
+    """The most recent run with the violations each check found (admin only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            reports = consistency.list_reports(cursor, 1)
+            report = consistency.get_report(cursor, str(reports[0]['id'])) if reports else None
+        if not report:
+            raise HTTPException(status_code=404, detail="No consistency checks have run yet")
+        return {"success": True, "report": report}
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Latest consistency report error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve consistency report")
+
+
+@router.get("/reports/{report_id}")
+async def get_report(report_id: str, admin_user: dict = Depends(get_admin_user)):
+    """One run with the violations each check found (admin only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            report = consistency.get_report(cursor, report_id)
+        if not report:
+            raise HTTPException(status_code=404, detail="Report not found")
+        return {"success": True, "report": report}
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Get consistency report error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve consistency report")
+
+
+@router.post("/run", status_code=status.HTTP_202_ACCEPTED)
+async def run_checks(
+    repair: bool = Query(False, description="Repair the violations of checks that can"),
+    check: Optional[List[str]] = Query(None, description="Run only these checks; repeat for several"),
+    admin_user: dict = Depends(get_admin_user)
+):
+    """Run the checks now in the background; the report appears under /reports (admin only)"""
+    known = {registered.name for registered in consistency.checks()}
+    unknown = sorted(set(check or []) - known)
+    if unknown:
+        raise HTTPException(
+            status_code=400,
+            detail=f"Unknown checks: {', '.join(unknown)}. Available: {', '.join(sorted(known))}"
+        )
+
+    try:
+        result = check_consistency.apply_async(kwargs={
+            'trigger': 'manual', 'repair': repair, 'names': check, 'requested_by': str(admin_user['id']),
+        })
+        logger.info(f"Consistency check ({result.id}, repair={repair}) requested by {admin_user['id']}")
+        return {"success": True, "job_id": result.id, "repair": repair, "checks": check or sorted(known)}
+    except Exception as e:
+        logger.error(f"Run consistency checks error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to start consistency checks")
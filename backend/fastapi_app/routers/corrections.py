@@ -0,0 +1,206 @@
+"""
+Reader correction routes for FastAPI backend
+"""
+
+import sys
+import os
+from typing import List, Optional
+from fastapi import APIRouter, HTTPException, Depends, status, Query
+import logging
+
+sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
+
+from shared.database import get_postgres_cursor
+from shared.models import CorrectionCreate, CorrectionReview, CorrectionResponse, ArticleResponse
+from shared.content import UnsafeContent
+from shared.corrections import MAX_PENDING_PER_ARTICLE, CorrectionConflict, apply_correction
+from shared import permissions
+from ..dependencies import get_current_user
+
+router = APIRouter()
+logger = logging.getLogger(__name__)
+
+CORRECTION_SELECT = """
+    SELECT c.*, a.title AS article_title, u.username AS contributor
+    FROM article_corrections c
+    JOIN articles a ON a.id = c.article_id
+    LEFT JOIN users u ON u.id = c.submitted_by
+"""
+
+
+def get_reviewable_correction(cursor, correction_id: str, user: dict) -> dict:
+    """Load a pending correction on an article the caller may edit"""
+    cursor.execute(
+        CORRECTION_SELECT + " WHERE c.id = %s FOR UPDATE OF c",
+        (correction_id,)
+    )
+    correction = cursor.fetchone()
+    if not correction:
+        raise HTTPException(status_code=404, detail="Correction not found")
+
+    cursor.execute("SELECT author_id, coauthor_ids FROM articles WHERE id = %s", (correction['article_id'],))
+    article = cursor.fetchone()
+    if not permissions.can_edit_article(user, article):
+        raise HTTPException(status_code=404, detail="Correction not found")
+    if correction['status'] != 'pending':
+        raise HTTPException(status_code=409, detail=f"Correction already {correction['status']}")
+    return dict(correction)
+
+
+@router.post("/", response_model=CorrectionResponse, status_code=status.HTTP_201_CREATED)
+async def suggest_correction(correction: CorrectionCreate, current_user: dict = Depends(get_current_user)):
+    """Suggest an edit to a passage of a published article"""
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute(
+                "SELECT id, author_id, content FROM articles WHERE id = %s AND status = 'published'",
+                (str(correction.article_id),)
+            )
+            article = cursor.fetchone()
+            if not article:
+                raise HTTPException(status_code=404, detail="Article not found")
+
+            if str(article['author_id']) == str(current_user['id']):
+                raise HTTPException(status_code=400, detail="Edit your own article directly")
+            if correction.passage not in article['content']:
+                raise HTTPException(status_code=400, detail="Passage not found in the article")
+
+            cursor.execute("""
+                SELECT COUNT(*) AS count FROM article_corrections
+                WHERE article_id = %s AND submitted_by = %s AND status = 'pending'
+            """, (str(correction.article_id), current_user['id']))
+            if cursor.fetchone()['count'] >= MAX_PENDING_PER_ARTICLE:
+                raise HTTPException(
+                    status_code=429,
+                    detail=f"At most {MAX_PENDING_PER_ARTICLE} pending suggestions per article"
+                )
+
+            cursor.execute("""
+                INSERT INTO article_corrections (article_id, submitted_by, kind, passage, suggestion, note)
+                VALUES (%s, %s, %s, %s, %s, %s)
+                RETURNING id
+            """, (
+                str(correction.article_id), current_user['id'], correction.kind,
+                correction.passage, correction.suggestion, correction.note
+            ))
+            correction_id = cursor.fetchone()['id']
+
+            cursor.execute(CORRECTION_SELECT + " WHERE c.id = %s", (correction_id,))
+            created = cursor.fetchone()
+
+        return CorrectionResponse(**dict(created))
+
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Suggest correction error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to submit correction")
+
+
+@router.get("/mine", response_model=List[CorrectionResponse])
+async def my_corrections(
+    limit: int = Query(50, ge=1, le=100),
+    current_user: dict = Depends(get_current_user)
+):
+    """Corrections the caller has suggested, newest first"""
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute(
+                CORRECTION_SELECT + " WHERE c.submitted_by = %s ORDER BY c.created_at DESC LIMIT %s",
+                (current_user['id'], limit)
+            )
+            corrections = cursor.fetchall()
+
+        return [CorrectionResponse(**dict(correction)) for correction in corrections]
+    except Exception as e:
+        logger.error(f"My corrections error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to get corrections")
+
+
+@router.get("/queue", response_model=List[CorrectionResponse])
+async def correction_queue(
+    correction_status: str = Query('pending', alias='status', pattern=r'^(pending|accepted|dismissed)$'),
+    article_id: Optional[str] = Query(None),
+    limit: int = Query(50, ge=1, le=100),
+    current_user: dict = Depends(get_current_user)
+):
+    """Suggestions on the caller's articles (holders of `article:edit_any` see every article)"""
+    try:
+        query = CORRECTION_SELECT + " WHERE c.status = %s"
+        params = [correction_status]
+
+        if not permissions.allowed(current_user, permissions.ARTICLE_EDIT_ANY):
+            query += " AND a.author_id = %s"
+            params.append(current_user['id'])
+        if article_id:
+            query += " AND c.article_id = %s"
+            params.append(article_id)
+
+        query += " ORDER BY c.created_at ASC LIMIT %s"
+        params.append(limit)
+
+        with get_postgres_cursor() as cursor:
+            cursor.execute(query, params)
+            corrections = cursor.fetchall()
+
+        return [CorrectionResponse(**dict(correction)) for correction in corrections]
+    except Exception as e:
+        logger.error(f"Correction queue error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to get correction queue")
+
+
+@router.post("/{correction_id}/accept", response_model=ArticleResponse)
+async def accept_correction(
+    correction_id: str,
+    review: CorrectionReview = CorrectionReview(),
+    current_user: dict = Depends(get_current_user)
+):
+    """Apply a suggestion to the article, creating a revision"""
+    try:
+        with get_postgres_cursor() as cursor:
+            correction = get_reviewable_correction(cursor, correction_id, current_user)
+            try:
+                article = apply_correction(
+                    cursor, correction, current_user['id'],
+                    replacement=review.replacement, review_note=review.note
+                )
+            except CorrectionConflict as e:
+                raise HTTPException(status_code=409, detail=str(e))
+            except UnsafeContent as e:
+                raise HTTPException(status_code=400, detail=f"Unsafe content: {e}")
+
+        return ArticleResponse(**article)
+
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Accept correction error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to accept correction")
+
+
+@router.post("/{correction_id}/dismiss", response_model=CorrectionResponse)
+async def dismiss_correction(
+    correction_id: str,
+    review: CorrectionReview = CorrectionReview(),
+    current_user: dict = Depends(get_current_user)
+):
+    """Dismiss a suggestion without changing the article"""
+    try:
+        with get_postgres_cursor() as cursor:
+            get_reviewable_correction(cursor, correction_id, current_user)
+            cursor.execute("""
+                UPDATE article_corrections
+                SET status = 'dismissed', reviewed_by = %s, reviewed_at = NOW(), review_note = %s
+                WHERE id = %s
+            """, (current_user['id'], review.note, correction_id))
+
+            cursor.execute(CORRECTION_SELECT + " WHERE c.id = %s", (correction_id,))
+            correction = cursor.fetchone()
+
+        return CorrectionResponse(**dict(correction))
+
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Dismiss correction error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to dismiss correction")
@@ -0,0 +1,207 @@
+"""
+Editorial curation routes for FastAPI backend
+"""
+
+import sys
+import os
+from typing import List, Optional
+from fastapi import APIRouter, HTTPException, Depends, status, Query
+import logging
+from datetime import datetime
+
+sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
+
+from shared.database import get_postgres_cursor
+from shared.models import PinCreate, PinUpdate, PinResponse, PinnedArticle, PinScope
+from shared.curation import get_active_pins, purge_expired_pins, ACTIVE_PIN_CONDITION
+from shared import feed_versions, permissions
+from ..dependencies import require_permission
+
+router = APIRouter()
+logger = logging.getLogger(__name__)
+
+PIN_COLUMNS = f"""
+    p.id, p.article_id, p.scope, p.category, p.pin_type, p.position, p.starts_at,
+    p.ends_at, p.pinned_by, p.note, p.created_at, ({ACTIVE_PIN_CONDITION}) as is_active
+"""
+
+
+@router.get("/home/pinned", response_model=List[PinnedArticle])
+async def get_home_pins():
+    """Get active pinned articles for the home feed"""
+    try:
+        with get_postgres_cursor() as cursor:
+            return get_active_pins(cursor, PinScope.HOME.value)
+    except Exception as e:
+        logger.error(f"Get home pins error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve pinned articles")
+
+
+@router.get("/categories/{category}/pinned", response_model=List[PinnedArticle])
+async def get_category_pins(category: str):
+    """Get active pinned articles for a category page"""
+    try:
+        with get_postgres_cursor() as cursor:
+            return get_active_pins(cursor, PinScope.CATEGORY.value, category)
+    except Exception as e:
+        logger.error(f"Get category pins error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve pinned articles")
+
+
+@router.get("/pins", response_model=List[PinResponse])
+async def list_pins(
+    scope: Optional[PinScope] = Query(None),
+    category: str = Query(""),
+    include_expired: bool = Query(False),
+    editor: dict = Depends(require_permission(permissions.ARTICLE_CURATE))
+):
+    """List pins for editors (`article:curate`)"""
+    try:
+        query = f"SELECT {PIN_COLUMNS} FROM article_pins p WHERE 1=1"
+        params = []
+
+        if scope:
+            query += " AND p.scope = %s"
+            params.append(scope.value)
+        if category:
+            query += " AND p.category = %s"
+            params.append(category)
+        if not include_expired:
+            query += " AND (p.ends_at IS NULL OR p.ends_at > NOW())"
+
+        query += " ORDER BY p.scope, p.category NULLS FIRST, p.position ASC"
+
+        with get_postgres_cursor() as cursor:
+            cursor.execute(query, params)
+            pins = cursor.fetchall()
+
+        return [PinResponse(**dict(pin)) for pin in pins]
+    except Exception as e:
+        logger.error(f"List pins error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve pins")
+
+
+@router.post("/pins", response_model=PinResponse, status_code=status.HTTP_201_CREATED)
+async def create_pin(
+    pin_data: PinCreate,
+    editor: dict = Depends(require_permission(permissions.ARTICLE_CURATE))
+):
+    """Pin an article to the home feed or a category page (`article:curate`)"""
+    if pin_data.scope == PinScope.CATEGORY and not pin_data.category:
+        raise HTTPException(status_code=400, detail="Category pins require a category")
+
+    starts_at = pin_data.starts_at or datetime.now()
+    if pin_data.ends_at and pin_data.ends_at <= starts_at:
+        raise HTTPException(status_code=400, detail="ends_at must be after starts_at")
+
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute(
+                "SELECT id FROM articles WHERE id = %s AND status = 'published'",
+                (str(pin_data.article_id),)
+            )
+            if not cursor.fetchone():
+                raise HTTPException(status_code=404, detail="Article not found or not published")
+
+            cursor.execute("""
+                INSERT INTO article_pins (
+                    article_id, scope, category, pin_type, position, starts_at, ends_at, pinned_by, note
+                ) VALUES (%s, %s, %s, %s, %s, %s, %s, %s, %s)
+                RETURNING id
+            """, (
+                str(pin_data.article_id),
+                pin_data.scope.value,
+                pin_data.category if pin_data.scope == PinScope.CATEGORY else None,
+                pin_data.pin_type.value,
+                pin_data.position,
+                starts_at,
+                pin_data.ends_at,
+                str(editor['id']),
+                pin_data.note
+            ))
+            pin_id = cursor.fetchone()['id']
+
+            cursor.execute(f"SELECT {PIN_COLUMNS} FROM article_pins p WHERE p.id = %s", (pin_id,))
+            pin = cursor.fetchone()
+
+        feed_versions.bump()
+        logger.info(f"Article {pin_data.article_id} pinned to {pin_data.scope.value} by {editor['id']}")
+        return PinResponse(**dict(pin))
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Create pin error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to pin article")
+
+
+@router.patch("/pins/{pin_id}", response_model=PinResponse)
+async def update_pin(
+    pin_id: str,
+    pin_update: PinUpdate,
+    editor: dict = Depends(require_permission(permissions.ARTICLE_CURATE))
+):
+    """Change a pin's position, type or time window (`article:curate`)"""
+    update_data = pin_update.dict(exclude_unset=True)
+    if not update_data:
+        raise HTTPException(status_code=400, detail="No valid fields to update")
+
+    update_fields = []
+    params = []
+    for field, value in update_data.items():
+        update_fields.append(f"{field} = %s")
+        params.append(value.value if hasattr(value, 'value') else value)
+    params.append(pin_id)
+
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute(
+                f"UPDATE article_pins SET {', '.join(update_fields)} WHERE id = %s RETURNING id",
+                params
+            )
+            if not cursor.fetchone():
+                raise HTTPException(status_code=404, detail="Pin not found")
+
+            cursor.execute(f"SELECT {PIN_COLUMNS} FROM article_pins p WHERE p.id = %s", (pin_id,))
+            pin = cursor.fetchone()
+
+        feed_versions.bump()
+        return PinResponse(**dict(pin))
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Update pin error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to update pin")
+
+
+@router.delete("/pins/{pin_id}")
+async def delete_pin(pin_id: str, editor: dict = Depends(require_permission(permissions.ARTICLE_CURATE))):
+    """Unpin an article (`article:curate`)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute("DELETE FROM article_pins WHERE id = %s RETURNING id", (pin_id,))
+            if not cursor.fetchone():
+                raise HTTPException(status_code=404, detail="Pin not found")
+
+        feed_versions.bump()
+        return {"success": True, "message": "Pin removed"}
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Delete pin error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to remove pin")
+
+
+@router.post("/pins/purge-expired")
+async def purge_pins(
+    older_than_days: int = Query(30, ge=0),
+    editor: dict = Depends(require_permission(permissions.ARTICLE_CURATE))
+):
+    """Delete pins that expired long ago (`article:curate`)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            removed = purge_expired_pins(cursor, older_than_days)
+
+        return {"success": True, "removed": removed}
+    except Exception as e:
+        logger.error(f"Purge pins error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to purge expired pins")
@@ -0,0 +1,124 @@
+"""
+Event dead letter routes for FastAPI backend
+"""
+
+import sys
+import os
+from typing import List, Optional
+from fastapi import APIRouter, HTTPException, Depends, Query
+import logging
+
+sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
+
+from shared.database import get_postgres_cursor
+from shared.models import DeadLetterDiscard, DeadLetterResponse, DeadLetterRetry
+from shared import dead_letters
+from ..dependencies import get_deployment_admin
+
+router = APIRouter()
+logger = logging.getLogger(__name__)
+
+DEAD_LETTER_STATUSES = ('quarantined', 'retried', 'discarded')
+
+
+@router.get("/consumers")
+async def list_consumers(admin_user: dict = Depends(get_deployment_admin)):
+    """The consumers that quarantine events (admin only)"""
+    return {
+        "success": True,
+        "consumers": [
+            {"name": consumer.name, "description": consumer.description} for consumer in dead_letters.consumers()
+        ],
+    }
+
+
+@router.get("/stats")
+async def dead_letter_stats(admin_user: dict = Depends(get_deployment_admin)):
+    """Quarantined events per consumer, against their alert thresholds (admin only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            stats = dead_letters.depth(cursor)
+        return {"success": True, "consumers": stats}
+    except Exception as e:
+        logger.error(f"Dead letter stats error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve dead letter stats")
+
+
+@router.get("/", response_model=List[DeadLetterResponse])
+async def list_dead_letters(
+    consumer: Optional[str] = None,
+    dead_letter_status: Optional[str] = Query(
+        'quarantined', alias="status", pattern=f"^({'|'.join(DEAD_LETTER_STATUSES)})$"
+    ),
+    event_type: Optional[str] = None,
+    limit: int = Query(50, ge=1, le=200),
+    offset: int = Query(0, ge=0),
+    admin_user: dict = Depends(get_deployment_admin)
+):
+    """Dead letters, newest first; quarantined ones unless another status is asked for (admin only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            rows = dead_letters.list_dead_letters(cursor, consumer, dead_letter_status, event_type, limit, offset)
+        return [DeadLetterResponse(**row) for row in rows]
+    except Exception as e:
+        logger.error(f"List dead letters error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve dead letters")
+
+
+@router.get("/{dead_letter_id}", response_model=DeadLetterResponse)
+async def get_dead_letter(dead_letter_id: str, admin_user: dict = Depends(get_deployment_admin)):
+    """A dead letter with its payload and the error that quarantined it (admin only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            found = dead_letters.get(cursor, dead_letter_id)
+        if not found:
+            raise HTTPException(status_code=404, detail="Dead letter not found")
+        return DeadLetterResponse(**found)
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Get dead letter error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve dead letter")
+
+
+@router.post("/{dead_letter_id}/retry", response_model=DeadLetterResponse)
+async def retry_dead_letter(dead_letter_id: str, retry: Optional[DeadLetterRetry] = None,
+                            admin_user: dict = Depends(get_deployment_admin)):
+    """Hand a quarantined event to its consumer again, optionally with an edited payload (admin only)
+
+    A retry that fails leaves the dead letter quarantined, with the new error.
+    """
+    payload = retry.payload if retry else None
+    try:
+        with get_postgres_cursor() as cursor:
+            retried = dead_letters.retry(cursor, dead_letter_id, admin_user, payload)
+        if retried['status'] == 'retried':
+            logger.info(f"Dead letter {dead_letter_id} retried by {admin_user['id']}")
+        return DeadLetterResponse(**retried)
+    except LookupError:
+        raise HTTPException(status_code=404, detail="Dead letter not found")
+    except dead_letters.InvalidPayload as e:
+        raise HTTPException(status_code=400, detail=str(e))
+    except dead_letters.DeadLetterError as e:
+        raise HTTPException(status_code=409, detail=str(e))
+    except Exception as e:
+        logger.error(f"Retry dead letter error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retry dead letter")
+
+
+@router.post("/{dead_letter_id}/discard", response_model=DeadLetterResponse)
+async def discard_dead_letter(dead_letter_id: str, discard: Optional[DeadLetterDiscard] = None,
+                              admin_user: dict = Depends(get_deployment_admin)):
+    """Give up on a quarantined event, keeping it for reference (admin only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            discarded = dead_letters.discard(cursor, dead_letter_id, admin_user, discard.note if discard else None)
+        logger.info(f"Dead letter {dead_letter_id} discarded by {admin_user['id']}")
+        return DeadLetterResponse(**discarded)
+    except LookupError:
+        raise HTTPException(status_code=404, detail="Dead letter not found")
+    except dead_letters.DeadLetterError as e:
+        raise HTTPException(status_code=409, detail=str(e))
+    except Exception as e:
+        logger.error(f"Discard dead letter error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to discard dead letter")
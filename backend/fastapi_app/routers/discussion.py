@@ -0,0 +1,360 @@
+"""
+Article discussion and author Q&A routes for FastAPI backend
+"""
+
+import sys
+import os
+from datetime import datetime, timedelta, timezone
+from typing import Optional
+from fastapi import APIRouter, HTTPException, Depends, Response, status, Query
+import logging
+
+sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
+
+from shared.database import get_postgres_cursor
+from shared.models import (
+    QASessionCreate, QAQuestionCreate, QAAnswerCreate, QASessionResponse, QAQuestionResponse,
+    CommentCreate, CommentResponse, DiscussionResponse
+)
+from shared.events import comment_posted
+from shared.webhooks import emit_comment_created
+from shared.qa import open_session_condition, get_current_session, list_questions, qa_highlights
+from shared.reputation import upvote_received
+from shared.screening import COMMENT, Submission, screen, hold_comment
+from shared.visibility import hidden_authors, is_shadow_banned
+from shared import permissions
+from ..dependencies import get_current_user, get_optional_user
+
+router = APIRouter()
+logger = logging.getLogger(__name__)
+
+
+def get_article_author(cursor, article_id: str) -> dict:
+    cursor.execute(
+        "SELECT id, author_id, coauthor_ids, tenant_id FROM articles WHERE id = %s AND status = 'published'",
+        (article_id,)
+    )
+    article = cursor.fetchone()
+    if not article:
+        raise HTTPException(status_code=404, detail="Article not found")
+    return article
+
+
+def require_article_author(article: dict, user: dict):
+    if not permissions.can_edit_article(user, article):
+        raise HTTPException(status_code=403, detail="Only the article's authors can manage its Q&A")
+
+
+def build_session_response(cursor, session: dict, user_id: Optional[str]) -> QASessionResponse:
+    questions = list_questions(cursor, session['id'], user_id)
+    return QASessionResponse(**session, questions=[QAQuestionResponse(**question) for question in questions])
+
+
+@router.get("/{article_id}/discussion", response_model=DiscussionResponse)
+async def get_discussion(
+    article_id: str,
+    highlights: int = Query(5, ge=0, le=20),
+    current_user: Optional[dict] = Depends(get_optional_user)
+):
+    """Comments on an article together with the current Q&A session and answered-question highlights"""
+    try:
+        with get_postgres_cursor() as cursor:
+            article = get_article_author(cursor, article_id)
+            # Shadow-banned and blocked users' comments are left out, and so are their articles' discussions
+            hidden = hidden_authors(cursor, current_user)
+            if str(article['author_id']) in hidden:
+                raise HTTPException(status_code=404, detail="Article not found")
+
+            cursor.execute("""
+                SELECT c.id, c.parent_comment_id, c.content, c.like_count, c.created_at,
+                       CASE WHEN c.is_anonymous THEN NULL ELSE u.username END as author
+                FROM comments c
+                JOIN users u ON u.id = c.user_id
+                WHERE c.article_id = %s AND c.is_deleted = false AND c.moderation_status = 'approved'
+                AND NOT (c.user_id::text = ANY(%s))
+                ORDER BY c.created_at ASC
+            """, (article_id, hidden))
+            rows = cursor.fetchall()
+
+            session = get_current_session(cursor, article_id)
+            session_response = (
+                build_session_response(cursor, session, current_user['id'] if current_user else None)
+                if session else None
+            )
+            highlighted = qa_highlights(cursor, article_id, highlights) if highlights else []
+
+        comments = {str(row['id']): CommentResponse(**dict(row)) for row in rows}
+        threads = []
+        for comment in comments.values():
+            parent = comments.get(str(comment.parent_comment_id)) if comment.parent_comment_id else None
+            (parent.replies if parent else threads).append(comment)
+
+        return DiscussionResponse(
+            article_id=article_id,
+            comments=threads,
+            comment_count=len(comments),
+            qa_session=session_response,
+            qa_highlights=[QAQuestionResponse(**question) for question in highlighted]
+        )
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Get discussion error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve discussion")
+
+
+@router.post("/{article_id}/comments", response_model=CommentResponse, status_code=status.HTTP_201_CREATED)
+async def post_comment(article_id: str, comment_data: CommentCreate, response: Response,
+                       current_user: dict = Depends(get_current_user)):
+    """Comment on an article, or reply to a comment with `parent_comment_id`; flagged comments wait for review"""
+    content = comment_data.content.strip()
+    # Moderators' comments go straight through
+    screening = None
+    if not permissions.allowed(current_user, permissions.COMMENT_MODERATE):
+        screening = screen(Submission(COMMENT, str(current_user['id']), content))
+    held = bool(screening and screening.held)
+    try:
+        with get_postgres_cursor() as cursor:
+            article = get_article_author(cursor, article_id)
+
+            parent_author_id = None
+            if comment_data.parent_comment_id:
+                cursor.execute("""
+                    SELECT user_id FROM comments
+                    WHERE id = %s AND article_id = %s AND is_deleted = false AND moderation_status = 'approved'
+                """, (str(comment_data.parent_comment_id), article_id))
+                parent = cursor.fetchone()
+                if not parent:
+                    raise HTTPException(status_code=404, detail="Parent comment not found")
+                parent_author_id = parent['user_id']
+
+            cursor.execute("""
+                INSERT INTO comments (article_id, user_id, parent_comment_id, content, is_anonymous, moderation_status)
+                VALUES (%s, %s, %s, %s, %s, %s)
+                RETURNING *
+            """, (
+                article_id, current_user['id'],
+                str(comment_data.parent_comment_id) if comment_data.parent_comment_id else None,
+                content, comment_data.is_anonymous, 'pending' if held else 'approved'
+            ))
+            comment = dict(cursor.fetchone())
+            if held:
+                hold_comment(cursor, comment, screening)
+                response.status_code = status.HTTP_202_ACCEPTED
+                response.headers['X-Moderation-Review'] = 'pending'
+            elif not is_shadow_banned(cursor, str(current_user['id'])):
+                # A shadow-banned user's comment looks posted to them, but isn't counted and notifies no one
+                cursor.execute("UPDATE articles SET comment_count = comment_count + 1 WHERE id = %s", (article_id,))
+                comment_posted(cursor, comment, article['author_id'], parent_author_id)
+                emit_comment_created(cursor, comment, article)
+
+        if held:
+            logger.info(f"Comment {comment['id']} held for review: {screening.reason}")
+
+        return CommentResponse(**comment, author=None if comment['is_anonymous'] else current_user['username'])
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Post comment error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to post comment")
+
+
+@router.get("/{article_id}/qa", response_model=QASessionResponse)
+async def get_qa_session(article_id: str, current_user: Optional[dict] = Depends(get_optional_user)):
+    """The article's open or upcoming Q&A session, or its most recent one"""
+    try:
+        with get_postgres_cursor() as cursor:
+            session = get_current_session(cursor, article_id)
+            if not session:
+                raise HTTPException(status_code=404, detail="No Q&A session for this article")
+            return build_session_response(cursor, session, current_user['id'] if current_user else None)
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Get Q&A session error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve Q&A session")
+
+
+@router.post("/{article_id}/qa", response_model=QASessionResponse, status_code=status.HTTP_201_CREATED)
+async def open_qa_session(article_id: str, session_data: QASessionCreate, current_user: dict = Depends(get_current_user)):
+    """Open a time-boxed Q&A session (article author only)"""
+    opens_at = session_data.opens_at or datetime.now(timezone.utc)
+    if opens_at.tzinfo is None:
+        opens_at = opens_at.replace(tzinfo=timezone.utc)
+    closes_at = opens_at + timedelta(minutes=session_data.duration_minutes)
+    if closes_at <= datetime.now(timezone.utc):
+        raise HTTPException(status_code=400, detail="The session would already be over")
+
+    try:
+        with get_postgres_cursor() as cursor:
+            article = get_article_author(cursor, article_id)
+            require_article_author(article, current_user)
+
+            cursor.execute("""
+                SELECT id FROM qa_sessions
+                WHERE article_id = %s AND closed_at IS NULL AND closes_at > %s AND opens_at < %s
+            """, (article_id, opens_at, closes_at))
+            if cursor.fetchone():
+                raise HTTPException(status_code=409, detail="Another Q&A session overlaps this time window")
+
+            cursor.execute("""
+                INSERT INTO qa_sessions (article_id, author_id, title, opens_at, closes_at)
+                VALUES (%s, %s, %s, %s, %s)
+                RETURNING id
+            """, (article_id, str(article['author_id']), session_data.title, opens_at, closes_at))
+            cursor.fetchone()
+
+            session = get_current_session(cursor, article_id)
+            return build_session_response(cursor, session, current_user['id'])
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Open Q&A session error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to open Q&A session")
+
+
+@router.post("/{article_id}/qa/close", response_model=QASessionResponse)
+async def close_qa_session(article_id: str, current_user: dict = Depends(get_current_user)):
+    """Close the current Q&A session early (article author only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            article = get_article_author(cursor, article_id)
+            require_article_author(article, current_user)
+
+            cursor.execute("""
+                UPDATE qa_sessions SET closed_at = NOW()
+                WHERE article_id = %s AND closed_at IS NULL AND closes_at > NOW()
+                RETURNING id
+            """, (article_id,))
+            if not cursor.fetchone():
+                raise HTTPException(status_code=404, detail="No open or scheduled Q&A session")
+
+            session = get_current_session(cursor, article_id)
+            return build_session_response(cursor, session, current_user['id'])
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Close Q&A session error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to close Q&A session")
+
+
+@router.post("/{article_id}/qa/questions", response_model=QAQuestionResponse, status_code=status.HTTP_201_CREATED)
+async def ask_question(article_id: str, question_data: QAQuestionCreate, current_user: dict = Depends(get_current_user)):
+    """Submit a question to the article's open Q&A session"""
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute(
+                f"SELECT id FROM qa_sessions WHERE article_id = %s AND {open_session_condition()}",
+                (article_id,)
+            )
+            session = cursor.fetchone()
+            if not session:
+                raise HTTPException(status_code=409, detail="Q&A is not open for this article")
+
+            cursor.execute("""
+                INSERT INTO qa_questions (session_id, user_id, body)
+                VALUES (%s, %s, %s)
+                RETURNING id, session_id, body, upvote_count, answer, answered_at, created_at
+            """, (session['id'], current_user['id'], question_data.body.strip()))
+            question = cursor.fetchone()
+
+        return QAQuestionResponse(**dict(question), asked_by=current_user['username'])
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Ask question error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to submit question")
+
+
+@router.post("/{article_id}/qa/questions/{question_id}/upvote")
+async def upvote_question(article_id: str, question_id: str, current_user: dict = Depends(get_current_user)):
+    """Toggle an upvote on a question while its session is open"""
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute(f"""
+                SELECT q.id, q.user_id FROM qa_questions q
+                JOIN qa_sessions s ON s.id = q.session_id
+                WHERE q.id = %s AND s.article_id = %s AND q.is_hidden = false
+                AND {open_session_condition('s')}
+            """, (question_id, article_id))
+            question = cursor.fetchone()
+            if not question:
+                raise HTTPException(status_code=409, detail="Question not found or Q&A is closed")
+
+            cursor.execute(
+                "DELETE FROM qa_question_votes WHERE question_id = %s AND user_id = %s RETURNING question_id",
+                (question_id, current_user['id'])
+            )
+            removed = cursor.fetchone() is not None
+            if not removed:
+                cursor.execute(
+                    "INSERT INTO qa_question_votes (question_id, user_id) VALUES (%s, %s)",
+                    (question_id, current_user['id'])
+                )
+                upvote_received(cursor, question['user_id'], current_user['id'], f"question:{question_id}")
+
+            cursor.execute("""
+                UPDATE qa_questions SET upvote_count = GREATEST(upvote_count + %s, 0)
+                WHERE id = %s RETURNING upvote_count
+            """, (-1 if removed else 1, question_id))
+            upvote_count = cursor.fetchone()['upvote_count']
+
+        return {"success": True, "upvoted": not removed, "upvote_count": upvote_count}
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Upvote question error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to upvote question")
+
+
+@router.post("/{article_id}/qa/questions/{question_id}/answer", response_model=QAQuestionResponse)
+async def answer_question(article_id: str, question_id: str, answer_data: QAAnswerCreate,
+                          current_user: dict = Depends(get_current_user)):
+    """Answer a question (article author only; allowed after the session closes)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            article = get_article_author(cursor, article_id)
+            require_article_author(article, current_user)
+
+            cursor.execute("""
+                UPDATE qa_questions q SET answer = %s, answered_at = NOW()
+                FROM qa_sessions s
+                WHERE q.id = %s AND s.id = q.session_id AND s.article_id = %s
+                RETURNING q.id, q.session_id, q.body, q.upvote_count, q.answer, q.answered_at, q.created_at
+            """, (answer_data.answer.strip(), question_id, article_id))
+            question = cursor.fetchone()
+            if not question:
+                raise HTTPException(status_code=404, detail="Question not found")
+
+        return QAQuestionResponse(**dict(question))
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Answer question error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to answer question")
+
+
+@router.delete("/{article_id}/qa/questions/{question_id}")
+async def hide_question(article_id: str, question_id: str, current_user: dict = Depends(get_current_user)):
+    """Hide a question (article author or the asker)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            article = get_article_author(cursor, article_id)
+            cursor.execute("""
+                SELECT q.user_id FROM qa_questions q JOIN qa_sessions s ON s.id = q.session_id
+                WHERE q.id = %s AND s.article_id = %s
+            """, (question_id, article_id))
+            question = cursor.fetchone()
+            if not question:
+                raise HTTPException(status_code=404, detail="Question not found")
+            if str(question['user_id']) != str(current_user['id']):
+                require_article_author(article, current_user)
+
+            cursor.execute("UPDATE qa_questions SET is_hidden = true WHERE id = %s", (question_id,))
+
+        return {"success": True, "message": "Question hidden"}
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Hide question error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to hide question")
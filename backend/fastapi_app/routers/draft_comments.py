@@ -0,0 +1,198 @@
+"""
+Draft comment and suggestion routes for FastAPI backend
+"""
+
+import sys
+import os
+from typing import Optional
+from fastapi import APIRouter, HTTPException, Depends, Query
+import logging
+
+sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
+
+from shared.database import get_postgres_cursor
+from shared.models import ArticleResponse, DraftCommentCreate, DraftCommentReply
+from shared.content import UnsafeContent
+from shared.draft_collab import editable_draft
+from shared.draft_comments import (
+    ACCEPTED, OPEN, REJECTED, RESOLVED, AnchorConflict, add_comment, add_reply, apply_suggestion,
+    get_comment, list_comments, notify, set_status
+)
+from ..dependencies import get_current_user
+
+router = APIRouter()
+logger = logging.getLogger(__name__)
+
+
+def get_draft(article_id: str, user: dict) -> dict:
+    with get_postgres_cursor() as cursor:
+        article = editable_draft(cursor, article_id, user)
+    if not article:
+        raise HTTPException(status_code=404, detail="Draft not found")
+    return article
+
+
+def get_draft_comment(article_id: str, comment_id: str) -> dict:
+    comment = get_comment(article_id, comment_id)
+    if not comment:
+        raise HTTPException(status_code=404, detail="Comment not found")
+    return comment
+
+
+def record_notification(comment: dict, action: str, user: dict, article: dict):
+    with get_postgres_cursor() as cursor:
+        notify(cursor, comment, action, user['id'], article['author_id'])
+
+
+@router.get("/{article_id}/draft-comments")
+async def list_draft_comments(
+    article_id: str,
+    comment_status: Optional[str] = Query(None, alias="status", pattern="^(open|resolved|accepted|rejected)$"),
+    current_user: dict = Depends(get_current_user)
+):
+    """Comments and suggestions on a draft, oldest first"""
+    try:
+        get_draft(article_id, current_user)
+        return {"success": True, "comments": list_comments(article_id, comment_status)}
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"List draft comments error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve comments")
+
+
+@router.post("/{article_id}/draft-comments", status_code=201)
+async def create_draft_comment(article_id: str, comment_data: DraftCommentCreate,
+                               current_user: dict = Depends(get_current_user)):
+    """Comment on a range of the draft, or suggest replacement text for it"""
+    try:
+        article = get_draft(article_id, current_user)
+        if comment_data.suggestion == comment_data.anchor.quote:
+            raise HTTPException(status_code=400, detail="Suggestion must differ from the quoted text")
+        try:
+            comment = add_comment(
+                article, current_user, comment_data.anchor.dict(), comment_data.body, comment_data.suggestion
+            )
+        except AnchorConflict as e:
+            raise HTTPException(status_code=409, detail=str(e))
+
+        record_notification(comment, 'created', current_user, article)
+        return {"success": True, "comment": comment}
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Create draft comment error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to add comment")
+
+
+@router.post("/{article_id}/draft-comments/{comment_id}/replies", status_code=201)
+async def reply_to_draft_comment(article_id: str, comment_id: str, reply: DraftCommentReply,
+                                 current_user: dict = Depends(get_current_user)):
+    """Answer a comment in its thread"""
+    try:
+        article = get_draft(article_id, current_user)
+        comment = add_reply(get_draft_comment(article_id, comment_id), current_user, reply.body)
+        if not comment:
+            raise HTTPException(status_code=404, detail="Comment not found")
+
+        record_notification(comment, 'replied', current_user, article)
+        return {"success": True, "comment": comment}
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Reply to draft comment error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to add reply")
+
+
+@router.post("/{article_id}/draft-comments/{comment_id}/resolve")
+async def resolve_draft_comment(article_id: str, comment_id: str, current_user: dict = Depends(get_current_user)):
+    """Mark an open comment as resolved"""
+    try:
+        article = get_draft(article_id, current_user)
+        comment = get_draft_comment(article_id, comment_id)
+        if comment['kind'] == 'suggestion':
+            raise HTTPException(status_code=400, detail="Suggestions are accepted or rejected")
+        updated = set_status(comment, RESOLVED, current_user['id'])
+        if not updated:
+            raise HTTPException(status_code=409, detail=f"Comment is already {comment['status']}")
+
+        record_notification(updated, 'resolved', current_user, article)
+        return {"success": True, "comment": updated}
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Resolve draft comment error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to resolve comment")
+
+
+@router.post("/{article_id}/draft-comments/{comment_id}/reopen")
+async def reopen_draft_comment(article_id: str, comment_id: str, current_user: dict = Depends(get_current_user)):
+    """Reopen a resolved comment"""
+    try:
+        article = get_draft(article_id, current_user)
+        comment = get_draft_comment(article_id, comment_id)
+        updated = set_status(comment, OPEN, current_user['id'])
+        if not updated:
+            raise HTTPException(status_code=409, detail="Only resolved comments can be reopened")
+
+        record_notification(updated, 'reopened', current_user, article)
+        return {"success": True, "comment": updated}
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Reopen draft comment error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to reopen comment")
+
+
+@router.post("/{article_id}/draft-comments/{comment_id}/accept", response_model=ArticleResponse)
+async def accept_draft_suggestion(article_id: str, comment_id: str, current_user: dict = Depends(get_current_user)):
+    """Apply a suggestion to the draft, recording a revision"""
+    try:
+        article = get_draft(article_id, current_user)
+        comment = get_draft_comment(article_id, comment_id)
+        if comment['kind'] != 'suggestion':
+            raise HTTPException(status_code=400, detail="Only suggestions can be accepted")
+        if comment['status'] != OPEN:
+            raise HTTPException(status_code=409, detail=f"Suggestion is already {comment['status']}")
+
+        with get_postgres_cursor() as cursor:
+            try:
+                updated_article = apply_suggestion(cursor, comment, current_user['id'])
+            except AnchorConflict as e:
+                raise HTTPException(status_code=409, detail=str(e))
+            except UnsafeContent as e:
+                raise HTTPException(status_code=400, detail=f"Unsafe content: {e}")
+            # Settled inside the transaction so a concurrent accept rolls this one back
+            updated = set_status(comment, ACCEPTED, current_user['id'], updated_article['revision_number'])
+            if not updated:
+                raise HTTPException(status_code=409, detail="Suggestion was settled by someone else")
+            notify(cursor, updated, 'accepted', current_user['id'], article['author_id'])
+
+        logger.info(f"Suggestion {comment_id} on {article_id} accepted by {current_user['username']}")
+        return ArticleResponse(**updated_article)
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Accept draft suggestion error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to accept suggestion")
+
+
+@router.post("/{article_id}/draft-comments/{comment_id}/reject")
+async def reject_draft_suggestion(article_id: str, comment_id: str, current_user: dict = Depends(get_current_user)):
+    """Decline a suggestion, leaving the draft unchanged"""
+    try:
+        article = get_draft(article_id, current_user)
+        comment = get_draft_comment(article_id, comment_id)
+        if comment['kind'] != 'suggestion':
+            raise HTTPException(status_code=400, detail="Only suggestions can be rejected")
+        updated = set_status(comment, REJECTED, current_user['id'])
+        if not updated:
+            raise HTTPException(status_code=409, detail=f"Suggestion is already {comment['status']}")
+
+        record_notification(updated, 'rejected', current_user, article)
+        return {"success": True, "comment": updated}
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Reject draft suggestion error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to reject suggestion")
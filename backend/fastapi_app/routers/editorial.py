@@ -0,0 +1,272 @@
+"""
+Editorial review workflow routes for FastAPI backend
+"""
+
+import sys
+import os
+from typing import List, Optional
+from fastapi import APIRouter, HTTPException, Depends, Query
+import logging
+
+sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
+
+from shared.database import get_postgres_cursor
+from shared.models import (
+    EditorialApproval, EditorialAssignment, EditorialChangesRequest, EditorialDecision,
+    EditorialEventResponse, EditorialReviewResponse, EditorialSubmission
+)
+from shared.draft_collab import editable_draft
+from shared.draft_comments import AnchorConflict
+from shared.instance_policy import REJECT, REVIEW, check_publish
+from shared.modules import is_enabled
+from shared.publishing import on_article_published
+from shared import article_cache, editorial, feed_versions, permissions
+from ..dependencies import get_current_user, require_permission
+
+router = APIRouter()
+logger = logging.getLogger(__name__)
+
+REVIEW_STATUS_PATTERN = f"^({'|'.join(editorial.STATUSES)})$"
+
+
+def get_review(cursor, review_id: str) -> dict:
+    review = editorial.get_review(cursor, review_id, for_update=True)
+    if not review:
+        raise HTTPException(status_code=404, detail="Review not found")
+    return review
+
+
+def get_draft(cursor, article_id: str, user: dict) -> dict:
+    article = editable_draft(cursor, article_id, user)
+    if not article:
+        raise HTTPException(status_code=404, detail="Draft not found")
+    return article
+
+
+@router.get("/editorial/queue", response_model=List[EditorialReviewResponse])
+async def editorial_queue(
+    review_status: Optional[str] = Query(None, alias="status", pattern=REVIEW_STATUS_PATTERN),
+    assignee: Optional[str] = Query(None, pattern=r'^(me|unassigned|[0-9a-fA-F-]{36})$'),  # Or an editor's id
+    limit: int = Query(50, ge=1, le=200),
+    offset: int = Query(0, ge=0),
+    editor: dict = Depends(require_permission(permissions.ARTICLE_REVIEW))
+):
+    """Drafts under review, longest waiting first; open ones unless another status is asked for (`article:review`)"""
+    assigned_to = str(editor['id']) if assignee == 'me' else None if assignee == 'unassigned' else assignee
+    try:
+        with get_postgres_cursor() as cursor:
+            reviews = editorial.queue(
+                cursor, review_status, assigned_to, unassigned=assignee == 'unassigned', limit=limit, offset=offset
+            )
+        return [EditorialReviewResponse(**review) for review in reviews]
+    except Exception as e:
+        logger.error(f"Editorial queue error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve editorial queue")
+
+
+@router.get("/editorial/reviews/{review_id}", response_model=EditorialReviewResponse)
+async def get_editorial_review(review_id: str, editor: dict = Depends(require_permission(permissions.ARTICLE_REVIEW))):
+    """One review (`article:review`)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            review = editorial.get_review(cursor, review_id)
+        if not review:
+            raise HTTPException(status_code=404, detail="Review not found")
+        return EditorialReviewResponse(**review)
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Get editorial review error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve review")
+
+
+@router.post("/editorial/reviews/{review_id}/assign", response_model=EditorialReviewResponse)
+async def assign_editorial_review(review_id: str, assignment: Optional[EditorialAssignment] = None,
+                                  editor: dict = Depends(require_permission(permissions.ARTICLE_REVIEW))):
+    """Take an open review, or give it to another editor with `assignee_id` (`article:review`)"""
+    assignee_id = assignment.assignee_id if assignment else None
+    try:
+        with get_postgres_cursor() as cursor:
+            review = editorial.assign(cursor, get_review(cursor, review_id), editor, assignee_id)
+        logger.info(f"Editorial review {review_id} assigned to {review['assigned_to']} by {editor['id']}")
+        return EditorialReviewResponse(**review)
+    except HTTPException:
+        raise
+    except LookupError as e:
+        raise HTTPException(status_code=400, detail=str(e))
+    except PermissionError as e:
+        raise HTTPException(status_code=403, detail=str(e))
+    except editorial.EditorialError as e:
+        raise HTTPException(status_code=409, detail=str(e))
+    except Exception as e:
+        logger.error(f"Assign editorial review error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to assign review")
+
+
+@router.post("/editorial/reviews/{review_id}/request-changes", response_model=EditorialReviewResponse)
+async def request_editorial_changes(review_id: str, changes: EditorialChangesRequest,
+                                    editor: dict = Depends(require_permission(permissions.ARTICLE_REVIEW))):
+    """Send a submitted draft back to its author with a note and inline comments on it (`article:review`)
+
+    Every comment is checked against the draft before any is made; one whose
+    quote no longer matches fails the request with 409.
+    """
+    if not changes.note and not changes.comments:
+        raise HTTPException(status_code=400, detail="Say what should change, in a note or inline comments")
+    if changes.comments and not is_enabled('collaboration'):
+        raise HTTPException(status_code=400, detail="Inline comments are unavailable on this node")
+    try:
+        with get_postgres_cursor() as cursor:
+            review = get_review(cursor, review_id)
+            cursor.execute(
+                "SELECT id, author_id, status, title, summary, content FROM articles WHERE id = %s",
+                (review['article_id'],)
+            )
+            article = dict(cursor.fetchone())
+            if article['status'] != 'draft':
+                raise HTTPException(status_code=409, detail="The article is no longer a draft")
+            review = editorial.request_changes(
+                cursor, review, article, editor, changes.note, [comment.dict() for comment in changes.comments]
+            )
+        logger.info(f"Changes requested on editorial review {review_id} by {editor['id']}")
+        return EditorialReviewResponse(**review)
+    except HTTPException:
+        raise
+    except AnchorConflict as e:
+        raise HTTPException(status_code=409, detail=str(e))
+    except PermissionError as e:
+        raise HTTPException(status_code=403, detail=str(e))
+    except editorial.EditorialError as e:
+        raise HTTPException(status_code=409, detail=str(e))
+    except Exception as e:
+        logger.error(f"Request editorial changes error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to request changes")
+
+
+@router.post("/editorial/reviews/{review_id}/approve", response_model=EditorialReviewResponse)
+async def approve_editorial_review(review_id: str, approval: Optional[EditorialApproval] = None,
+                                   editor: dict = Depends(require_permission(permissions.ARTICLE_REVIEW))):
+    """Approve a submitted draft, and with `publish` publish it now (`article:review`)
+
+    Publishing is subject to the instance policy, as when the author publishes.
+    """
+    approval = approval or EditorialApproval()
+    publishing = False
+    try:
+        with get_postgres_cursor() as cursor:
+            review = get_review(cursor, review_id)
+            review = editorial.decide(cursor, review, editor, editorial.APPROVED, approval.note)
+
+            if approval.publish:
+                cursor.execute("SELECT * FROM articles WHERE id = %s FOR UPDATE", (review['article_id'],))
+                article = cursor.fetchone()
+                if article['status'] != 'draft':
+                    raise HTTPException(status_code=409, detail="The article is no longer a draft")
+                decision = check_publish(dict(article))
+                if decision.action == REJECT:
+                    raise HTTPException(status_code=403, detail=decision.reason)
+                if decision.action == REVIEW and not permissions.allowed(editor, permissions.ARTICLE_MODERATE):
+                    raise HTTPException(
+                        status_code=403, detail=f"Needs moderation review before publishing: {decision.reason}"
+                    )
+                # Publishing now replaces any schedule
+                cursor.execute("""
+                    UPDATE articles
+                    SET status = 'published', published_at = NOW(), scheduled_publish_at = NULL, updated_at = NOW()
+                    WHERE id = %s
+                    RETURNING *
+                """, (article['id'],))
+                on_article_published(cursor, dict(cursor.fetchone()))
+                publishing = True
+
+        if publishing:
+            article_cache.invalidate(str(review['article_id']))
+            feed_versions.bump()
+        logger.info(f"Editorial review {review_id} approved by {editor['id']}{' and published' if publishing else ''}")
+        return EditorialReviewResponse(**review)
+    except HTTPException:
+        raise
+    except PermissionError as e:
+        raise HTTPException(status_code=403, detail=str(e))
+    except editorial.EditorialError as e:
+        raise HTTPException(status_code=409, detail=str(e))
+    except Exception as e:
+        logger.error(f"Approve editorial review error: {e}", exc_info=True)
+        raise HTTPException(status_code=500, detail="Failed to approve draft")
+
+
+@router.post("/editorial/reviews/{review_id}/reject", response_model=EditorialReviewResponse)
+async def reject_editorial_review(review_id: str, decision: Optional[EditorialDecision] = None,
+                                  editor: dict = Depends(require_permission(permissions.ARTICLE_REVIEW))):
+    """Turn a draft down; it stays a draft and can be submitted again (`article:review`)"""
+    note = decision.note if decision else None
+    try:
+        with get_postgres_cursor() as cursor:
+            review = editorial.decide(cursor, get_review(cursor, review_id), editor, editorial.REJECTED, note)
+        logger.info(f"Editorial review {review_id} rejected by {editor['id']}")
+        return EditorialReviewResponse(**review)
+    except HTTPException:
+        raise
+    except PermissionError as e:
+        raise HTTPException(status_code=403, detail=str(e))
+    except editorial.EditorialError as e:
+        raise HTTPException(status_code=409, detail=str(e))
+    except Exception as e:
+        logger.error(f"Reject editorial review error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to reject draft")
+
+
+@router.post("/articles/{article_id}/submit", response_model=EditorialReviewResponse)
+async def submit_for_review(article_id: str, submission: Optional[EditorialSubmission] = None,
+                            current_user: dict = Depends(get_current_user)):
+    """Send a draft to the editors, or back to them after changes were requested (authors or `article:edit_any`)"""
+    note = submission.note if submission else None
+    try:
+        with get_postgres_cursor() as cursor:
+            review = editorial.submit(cursor, get_draft(cursor, article_id, current_user), current_user, note)
+        logger.info(f"Article {article_id} submitted for editorial review by {current_user['id']}")
+        return EditorialReviewResponse(**review)
+    except HTTPException:
+        raise
+    except editorial.EditorialError as e:
+        raise HTTPException(status_code=409, detail=str(e))
+    except Exception as e:
+        logger.error(f"Submit for review error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to submit draft")
+
+
+@router.post("/articles/{article_id}/withdraw", response_model=EditorialReviewResponse)
+async def withdraw_from_review(article_id: str, current_user: dict = Depends(get_current_user)):
+    """Take a draft out of editorial review (authors or `article:edit_any`)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            review = editorial.withdraw(cursor, get_draft(cursor, article_id, current_user), current_user)
+        return EditorialReviewResponse(**review)
+    except HTTPException:
+        raise
+    except LookupError as e:
+        raise HTTPException(status_code=404, detail=str(e))
+    except Exception as e:
+        logger.error(f"Withdraw from review error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to withdraw draft")
+
+
+@router.get("/articles/{article_id}/timeline", response_model=List[EditorialEventResponse])
+async def article_timeline(article_id: str, current_user: dict = Depends(get_current_user)):
+    """Every editorial transition of an article, oldest first (authors, `article:edit_any` or `article:review`)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute("SELECT id, author_id, coauthor_ids FROM articles WHERE id = %s", (article_id,))
+            article = cursor.fetchone()
+            if not article or not (
+                permissions.can_edit_article(current_user, article)
+                or permissions.allowed(current_user, permissions.ARTICLE_REVIEW)
+            ):
+                raise HTTPException(status_code=404, detail="Article not found")
+            events = editorial.timeline(cursor, article_id)
+        return [EditorialEventResponse(**event) for event in events]
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Article timeline error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve timeline")
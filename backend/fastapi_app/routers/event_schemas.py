@@ -0,0 +1,70 @@
+"""
+Domain event contract routes for FastAPI backend
+"""
+
+import sys
+import os
+from typing import Optional
+from fastapi import APIRouter, HTTPException, Query
+import logging
+
+sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
+
+from shared import event_schemas
+
+router = APIRouter()
+logger = logging.getLogger(__name__)
+
+
+@router.get("/")
+async def list_event_schemas():
+    """Every event type with its versions, the current one and the one the broker carries"""
+    return {
+        "success": True,
+        "event_types": [
+            {
+                "type": event_type,
+                "versions": event_schemas.versions(event_type),
+                "current_version": event_schemas.current_version(event_type),
+                "published_version": event_schemas.published_version(event_type),
+                "subject": event_schemas.subject(event_type),
+            }
+            for event_type in event_schemas.CONTRACTS
+        ],
+    }
+
+
+@router.get("/{event_type}")
+async def negotiate_event_schema(
+    event_type: str,
+    accept: Optional[str] = Query(None, pattern=r'^\d+(,\d+)*$', description="Versions the consumer reads, e.g. 1,2")
+):
+    """The newest version of an event type that both this producer and the consumer know, with its schema
+
+    Without `accept`, the version the broker carries. 406 when there's no version in common.
+    """
+    if event_type not in event_schemas.CONTRACTS:
+        raise HTTPException(status_code=404, detail="Unknown event type")
+    try:
+        if accept:
+            version = event_schemas.negotiate(event_type, [int(version) for version in accept.split(',')])
+        else:
+            version = event_schemas.published_version(event_type)
+    except event_schemas.UnsupportedVersion as e:
+        raise HTTPException(status_code=406, detail=str(e))
+    return {
+        "success": True,
+        "type": event_type,
+        "version": version,
+        "published_version": event_schemas.published_version(event_type),
+        "schema": event_schemas.json_schema(event_type, version),
+    }
+
+
+@router.get("/{event_type}/versions/{version}")
+async def get_event_schema(event_type: str, version: int):
+    """One version of an event type's contract as JSON Schema"""
+    try:
+        return event_schemas.json_schema(event_type, version)
+    except event_schemas.UnsupportedVersion:
+        raise HTTPException(status_code=404, detail="Unknown event type or version")
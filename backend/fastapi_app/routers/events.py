@@ -0,0 +1,41 @@
+"""
+Client telemetry routes for FastAPI backend
+"""
+
+import sys
+import os
+from typing import Optional
+from fastapi import APIRouter, HTTPException, Depends, status
+import logging
+
+sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
+
+from shared.models import TelemetryBatch
+from shared.bot_detection import is_bot_request
+from shared import telemetry
+from ..dependencies import get_optional_user
+
+router = APIRouter()
+logger = logging.getLogger(__name__)
+
+
+@router.post("/batch", status_code=status.HTTP_202_ACCEPTED)
+async def record_event_batch(batch: TelemetryBatch, current_user: Optional[dict] = Depends(get_optional_user)):
+    """Record scroll depth, dwell time and impression events from a reader's session
+
+    Events are buffered and written in bulk, so they may take a few seconds to appear.
+    """
+    try:
+        # Answered the same way so bots can't tell they're ignored
+        if is_bot_request():
+            return {"success": True, "accepted": 0, "sampled_out": len(batch.events), "rejected": 0}
+
+        counts = telemetry.accept(
+            batch.session_id,
+            current_user['id'] if current_user else None,
+            [{**event.model_dump(), 'type': event.type.value} for event in batch.events]
+        )
+        return {"success": True, **counts}
+    except Exception as e:
+        logger.error(f"Record event batch error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to record events")
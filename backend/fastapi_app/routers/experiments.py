@@ -0,0 +1,192 @@
+"""
+Headline and thumbnail test routes for FastAPI backend
+"""
+
+import sys
+import os
+from typing import List
+from fastapi import APIRouter, HTTPException, Depends, status
+import logging
+import psycopg2
+
+sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
+
+from shared.database import get_postgres_cursor
+from shared.models import HeadlineTestCreate, ThumbnailTestCreate, ExperimentResponse
+from shared.experiments import CANCELLED, CONTROL, RUNNING, VARIANT_KEYS, get_results, sync_results
+from shared import permissions
+from ..dependencies import get_current_user
+
+router = APIRouter()
+logger = logging.getLogger(__name__)
+
+
+def get_own_article(cursor, article_id: str, user: dict) -> dict:
+    """The article, if the user is one of its authors or holds `article:edit_any`"""
+    cursor.execute(
+        "SELECT id, author_id, coauthor_ids, title, image_urls, og_image_url, status FROM articles WHERE id = %s",
+        (article_id,)
+    )
+    article = cursor.fetchone()
+    if not article:
+        raise HTTPException(status_code=404, detail="Article not found")
+    if not permissions.can_edit_article(user, article):
+        raise HTTPException(status_code=403, detail="Access denied")
+    return dict(article)
+
+
+def latest_experiment(cursor, article_id: str, kind: str) -> dict:
+    cursor.execute("""
+        SELECT * FROM experiments WHERE article_id = %s AND kind = %s
+        ORDER BY started_at DESC LIMIT 1
+    """, (article_id, kind))
+    experiment = cursor.fetchone()
+    if not experiment:
+        raise HTTPException(status_code=404, detail=f"No {kind} test for this article")
+    return dict(experiment)
+
+
+def experiment_response(cursor, experiment: dict) -> ExperimentResponse:
+    return ExperimentResponse(**experiment, results=get_results(cursor, experiment))
+
+
+def start_experiment(cursor, article_id: str, kind: str, values: List[str], user: dict) -> ExperimentResponse:
+    """Start testing `values` against the control, the first of them"""
+    variants = [{'key': key, 'value': value} for key, value in zip(VARIANT_KEYS, values)]
+    try:
+        cursor.execute("SAVEPOINT start_experiment")
+        cursor.execute("""
+            INSERT INTO experiments (article_id, kind, variants, created_by)
+            VALUES (%s, %s, %s, %s)
+            RETURNING *
+        """, (article_id, kind, variants, user['id']))
+    except psycopg2.errors.UniqueViolation:
+        cursor.execute("ROLLBACK TO SAVEPOINT start_experiment")
+        raise HTTPException(status_code=409, detail=f"A {kind} test is already running for this article")
+    experiment = dict(cursor.fetchone())
+    logger.info(f"{kind.capitalize()} test {experiment['id']} started on article {article_id} by {user['id']}")
+    return experiment_response(cursor, experiment)
+
+
+def get_experiment(article_id: str, kind: str, user: dict) -> ExperimentResponse:
+    with get_postgres_cursor() as cursor:
+        get_own_article(cursor, article_id, user)
+        experiment = latest_experiment(cursor, article_id, kind)
+        if experiment['status'] == RUNNING:
+            sync_results(cursor, experiment)
+        return experiment_response(cursor, experiment)
+
+
+def cancel_experiment(article_id: str, kind: str, user: dict) -> ExperimentResponse:
+    """End the running test, keeping the control"""
+    with get_postgres_cursor() as cursor:
+        get_own_article(cursor, article_id, user)
+        experiment = latest_experiment(cursor, article_id, kind)
+        if experiment['status'] != RUNNING:
+            raise HTTPException(status_code=409, detail=f"The {kind} test has already ended")
+
+        sync_results(cursor, experiment)
+        decision = {
+            'results': get_results(cursor, experiment),
+            'winner': CONTROL,
+            'reason': f"Cancelled by {user['username']}",
+        }
+        cursor.execute("""
+            UPDATE experiments SET status = %s, winner = %s, decision = %s, concluded_at = NOW()
+            WHERE id = %s
+            RETURNING *
+        """, (CANCELLED, CONTROL, decision, experiment['id']))
+        return experiment_response(cursor, dict(cursor.fetchone()))
+
+
+@router.post("/{article_id}/headline-test", response_model=ExperimentResponse, status_code=status.HTTP_201_CREATED)
+async def start_headline_test(article_id: str, test: HeadlineTestCreate, current_user: dict = Depends(get_current_user)):
+    """Test up to three alternative headlines against the current title (author or `article:edit_any`)
+
+    Signed-in readers see one headline per article in their feeds; the one
+    with the best click-through replaces the title once the difference is
+    significant.
+    """
+    try:
+        with get_postgres_cursor() as cursor:
+            article = get_own_article(cursor, article_id, current_user)
+            if article['title'].strip().lower() in {headline.lower() for headline in test.headlines}:
+                raise HTTPException(status_code=400, detail="Headlines must differ from the current title")
+            return start_experiment(cursor, article_id, 'headline', [article['title']] + test.headlines, current_user)
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Start headline test error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to start headline test")
+
+
+@router.get("/{article_id}/headline-test", response_model=ExperimentResponse)
+async def get_headline_test(article_id: str, current_user: dict = Depends(get_current_user)):
+    """The article's latest headline test with click-through per headline (author or `article:edit_any`)"""
+    try:
+        return get_experiment(article_id, 'headline', current_user)
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Get headline test error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve headline test")
+
+
+@router.delete("/{article_id}/headline-test", response_model=ExperimentResponse)
+async def cancel_headline_test(article_id: str, current_user: dict = Depends(get_current_user)):
+    """Stop the running headline test and keep the current title (author or `article:edit_any`)"""
+    try:
+        return cancel_experiment(article_id, 'headline', current_user)
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Cancel headline test error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to cancel headline test")
+
+
+@router.post("/{article_id}/thumbnail-test", response_model=ExperimentResponse, status_code=status.HTTP_201_CREATED)
+async def start_thumbnail_test(article_id: str, test: ThumbnailTestCreate,
+                               current_user: dict = Depends(get_current_user)):
+    """Test up to three alternative thumbnails against the current one (author or `article:edit_any`)
+
+    The current thumbnail is the article's first image, or its generated card
+    when it has none. The winner becomes the first image.
+    """
+    try:
+        with get_postgres_cursor() as cursor:
+            article = get_own_article(cursor, article_id, current_user)
+            current = (article['image_urls'] or [None])[0] or article['og_image_url']
+            if not current:
+                raise HTTPException(status_code=400, detail="The article has no thumbnail to test against yet")
+            if current in test.images:
+                raise HTTPException(status_code=400, detail="Images must differ from the current thumbnail")
+            return start_experiment(cursor, article_id, 'thumbnail', [current] + test.images, current_user)
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Start thumbnail test error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to start thumbnail test")
+
+
+@router.get("/{article_id}/thumbnail-test", response_model=ExperimentResponse)
+async def get_thumbnail_test(article_id: str, current_user: dict = Depends(get_current_user)):
+    """The article's latest thumbnail test with click-through per image (author or `article:edit_any`)"""
+    try:
+        return get_experiment(article_id, 'thumbnail', current_user)
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Get thumbnail test error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve thumbnail test")
+
+
+@router.delete("/{article_id}/thumbnail-test", response_model=ExperimentResponse)
+async def cancel_thumbnail_test(article_id: str, current_user: dict = Depends(get_current_user)):
+    """Stop the running thumbnail test and keep the current thumbnail (author or `article:edit_any`)"""
+    try:
+        return cancel_experiment(article_id, 'thumbnail', current_user)
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Cancel thumbnail test error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to cancel thumbnail test")
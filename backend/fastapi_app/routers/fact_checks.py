@@ -0,0 +1,167 @@
+"""
+Fact-check annotation routes for FastAPI backend
+"""
+
+import sys
+import os
+from typing import List, Optional
+from fastapi import APIRouter, HTTPException, Depends, status, Query
+import logging
+
+sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
+
+from shared.database import get_postgres_cursor, prepare_json_data
+from shared.models import FactCheckCreate, FactCheckUpdate, FactCheckResponse
+from shared.fact_checks import FACT_CHECK_SELECT, VERDICTS, SpanInvalid, mark_stale, quote
+from ..dependencies import get_auditor_user
+
+router = APIRouter()
+logger = logging.getLogger(__name__)
+
+
+def get_editable_fact_check(cursor, fact_check_id: str, user: dict) -> dict:
+    """Load a fact check the caller wrote, or any for an administrator"""
+    cursor.execute(FACT_CHECK_SELECT + " WHERE f.id = %s FOR UPDATE OF f", (fact_check_id,))
+    fact_check = cursor.fetchone()
+    if not fact_check:
+        raise HTTPException(status_code=404, detail="Fact check not found")
+    if str(fact_check['auditor_id']) != str(user['id']) and user.get('role') != 'administrator':
+        raise HTTPException(status_code=403, detail="Only the auditor who wrote a fact check can change it")
+    return dict(fact_check)
+
+
+def article_content(cursor, article_id: str) -> str:
+    cursor.execute("SELECT content FROM articles WHERE id = %s", (article_id,))
+    return cursor.fetchone()['content']
+
+
+@router.post("/", response_model=FactCheckResponse, status_code=status.HTTP_201_CREATED)
+async def create_fact_check(fact_check: FactCheckCreate, auditor: dict = Depends(get_auditor_user)):
+    """Annotate a span of a published article with a verdict and sources (auditors only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute(
+                "SELECT id, content FROM articles WHERE id = %s AND status = 'published'",
+                (str(fact_check.article_id),)
+            )
+            article = cursor.fetchone()
+            if not article:
+                raise HTTPException(status_code=404, detail="Article not found")
+
+            try:
+                checked_text = quote(
+                    article['content'], fact_check.paragraph, fact_check.start_offset, fact_check.end_offset
+                )
+            except SpanInvalid as e:
+                raise HTTPException(status_code=400, detail=str(e))
+
+            cursor.execute("""
+                INSERT INTO fact_checks
+                (article_id, auditor_id, paragraph, start_offset, end_offset, quote, claim, verdict, sources, note)
+                VALUES (%s, %s, %s, %s, %s, %s, %s, %s, %s, %s)
+                RETURNING id
+            """, (
+                str(fact_check.article_id), auditor['id'], fact_check.paragraph, fact_check.start_offset,
+                fact_check.end_offset, checked_text, fact_check.claim, fact_check.verdict.value,
+                prepare_json_data([source.model_dump() for source in fact_check.sources]), fact_check.note
+            ))
+            cursor.execute(FACT_CHECK_SELECT + " WHERE f.id = %s", (cursor.fetchone()['id'],))
+            created = dict(cursor.fetchone())
+
+        logger.info(f"Fact check {created['id']} ({created['verdict']}) added to article {fact_check.article_id}")
+        return FactCheckResponse(**created)
+
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Create fact check error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to create fact check")
+
+
+@router.get("/", response_model=List[FactCheckResponse])
+async def list_fact_checks(
+    article_id: Optional[str] = Query(None),
+    verdict: Optional[str] = Query(None, pattern=f"^({'|'.join(VERDICTS)})$"),
+    mine: bool = Query(False, description="Only fact checks the caller wrote"),
+    limit: int = Query(50, ge=1, le=200),
+    auditor: dict = Depends(get_auditor_user)
+):
+    """Fact checks across articles, newest first (auditors only)"""
+    try:
+        query = """
+            SELECT f.*, u.username AS auditor, a.content AS article_content
+            FROM fact_checks f
+            JOIN articles a ON a.id = f.article_id
+            LEFT JOIN users u ON u.id = f.auditor_id
+            WHERE TRUE
+        """
+        params = []
+        if article_id:
+            query += " AND f.article_id = %s"
+            params.append(article_id)
+        if verdict:
+            query += " AND f.verdict = %s"
+            params.append(verdict)
+        if mine:
+            query += " AND f.auditor_id = %s"
+            params.append(auditor['id'])
+
+        with get_postgres_cursor() as cursor:
+            cursor.execute(query + " ORDER BY f.created_at DESC LIMIT %s", params + [limit])
+            rows = cursor.fetchall()
+
+        return [FactCheckResponse(**mark_stale(dict(row), row['article_content'])) for row in rows]
+    except Exception as e:
+        logger.error(f"List fact checks error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve fact checks")
+
+
+@router.put("/{fact_check_id}", response_model=FactCheckResponse)
+async def update_fact_check(fact_check_id: str, update: FactCheckUpdate, auditor: dict = Depends(get_auditor_user)):
+    """Revise a fact check's claim, verdict, sources or note; its span stays fixed"""
+    try:
+        changes = update.model_dump(exclude_unset=True)
+        if not changes:
+            raise HTTPException(status_code=400, detail="Nothing to update")
+        if 'verdict' in changes:
+            if changes['verdict'] is None:
+                raise HTTPException(status_code=400, detail="verdict cannot be removed")
+            changes['verdict'] = changes['verdict'].value
+        if 'sources' in changes:
+            changes['sources'] = prepare_json_data(changes['sources'] or [])
+
+        with get_postgres_cursor() as cursor:
+            existing = get_editable_fact_check(cursor, fact_check_id, auditor)
+            assignments = ', '.join(f"{field} = %s" for field in changes)
+            cursor.execute(
+                f"UPDATE fact_checks SET {assignments}, updated_at = NOW() WHERE id = %s",
+                list(changes.values()) + [fact_check_id]
+            )
+            cursor.execute(FACT_CHECK_SELECT + " WHERE f.id = %s", (fact_check_id,))
+            updated = mark_stale(dict(cursor.fetchone()), article_content(cursor, existing['article_id']))
+
+        return FactCheckResponse(**updated)
+
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Update fact check error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to update fact check")
+
+
+@router.delete("/{fact_check_id}")
+async def delete_fact_check(fact_check_id: str, auditor: dict = Depends(get_auditor_user)):
+    """Remove a fact check"""
+    try:
+        with get_postgres_cursor() as cursor:
+            get_editable_fact_check(cursor, fact_check_id, auditor)
+            cursor.execute("DELETE FROM fact_checks WHERE id = %s", (fact_check_id,))
+
+        logger.info(f"Fact check {fact_check_id} deleted by {auditor['id']}")
+        return {"success": True, "message": "Fact check deleted"}
+
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Delete fact check error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to delete fact check")
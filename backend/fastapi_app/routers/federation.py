@@ -0,0 +1,195 @@
+"""
+Federation peer administration routes for FastAPI backend
+"""
+
+import sys
+import os
+from typing import List, Optional
+from fastapi import APIRouter, HTTPException, Depends, Query, status
+import logging
+import psycopg2
+
+sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
+
+from shared.database import get_postgres_cursor
+from shared.models import (
+    FederationPeerCreate, FederationPeerUpdate, FederationPeerResponse, FederationTombstoneResponse
+)
+from shared.branding import normalize_host
+from shared.federation import get_peer, list_tombstones, remove_peer_content
+from shared.peer_reputation import reputation_report
+from shared.jobs import sync_federation_peer
+from shared.webhooks import is_allowed_target
+from ..dependencies import get_admin_user
+
+router = APIRouter()
+logger = logging.getLogger(__name__)
+
+PEER_QUERY = """
+    SELECT p.*, (SELECT COUNT(*) FROM federated_articles f WHERE f.peer_id = p.id) AS article_count
+    FROM federation_peers p
+"""
+
+
+def get_peer_or_404(cursor, peer_id: str) -> dict:
+    cursor.execute(PEER_QUERY + " WHERE p.id = %s", (peer_id,))
+    peer = cursor.fetchone()
+    if not peer:
+        raise HTTPException(status_code=404, detail="Peer not found")
+    return dict(peer)
+
+
+def check_base_url(base_url: str):
+    if not is_allowed_target(base_url):
+        raise HTTPException(status_code=400, detail="Peer URL must be a public HTTP(S) address")
+
+
+@router.get("/peers", response_model=List[FederationPeerResponse])
+async def list_peers(admin_user: dict = Depends(get_admin_user)):
+    """List peers this instance pulls content from (admin only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute(PEER_QUERY + " ORDER BY p.domain")
+            return [FederationPeerResponse(**dict(peer)) for peer in cursor.fetchall()]
+    except Exception as e:
+        logger.error(f"List peers error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve peers")
+
+
+@router.post("/peers", response_model=FederationPeerResponse, status_code=status.HTTP_201_CREATED)
+async def create_peer(peer_data: FederationPeerCreate, admin_user: dict = Depends(get_admin_user)):
+    """Start federating with a peer; its content is pulled on the next scheduler run (admin only)"""
+    check_base_url(peer_data.base_url)
+
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute("""
+                INSERT INTO federation_peers (domain, base_url, name, refresh_interval_minutes, retention_days, created_by)
+                VALUES (%s, %s, %s, %s, %s, %s)
+                RETURNING id
+            """, (
+                normalize_host(peer_data.domain), peer_data.base_url.rstrip('/'), peer_data.name,
+                peer_data.refresh_interval_minutes, peer_data.retention_days, admin_user['id']
+            ))
+            peer = get_peer_or_404(cursor, cursor.fetchone()['id'])
+
+        logger.info(f"Federation peer {peer['domain']} added by admin {admin_user['id']}")
+        return FederationPeerResponse(**peer)
+    except psycopg2.errors.UniqueViolation:
+        raise HTTPException(status_code=409, detail="Peer already exists")
+    except Exception as e:
+        logger.error(f"Create peer error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to add peer")
+
+
+@router.get("/peers/{peer_id}", response_model=FederationPeerResponse)
+async def get_peer_details(peer_id: str, admin_user: dict = Depends(get_admin_user)):
+    """Get a peer with its sync status (admin only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            return FederationPeerResponse(**get_peer_or_404(cursor, peer_id))
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Get peer error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve peer")
+
+
+@router.patch("/peers/{peer_id}", response_model=FederationPeerResponse)
+async def update_peer(peer_id: str, update: FederationPeerUpdate, admin_user: dict = Depends(get_admin_user)):
+    """Change a peer's refresh interval, retention or URL, pause it, or pin its ingestion state (admin only)
+
+    A shorter retention takes effect on the peer's next sync. Setting
+    reputation_override to null hands the peer back to its reputation score.
+    """
+    update_data = update.dict(exclude_unset=True)
+    if not update_data:
+        raise HTTPException(status_code=400, detail="No valid fields to update")
+    if update_data.get('base_url'):
+        check_base_url(update_data['base_url'])
+        update_data['base_url'] = update_data['base_url'].rstrip('/')
+
+    try:
+        with get_postgres_cursor() as cursor:
+            get_peer_or_404(cursor, peer_id)
+            assignments = ', '.join(f"{field} = %s" for field in update_data)
+            cursor.execute(
+                f"UPDATE federation_peers SET {assignments}, updated_at = NOW() WHERE id = %s",
+                list(update_data.values()) + [peer_id]
+            )
+            peer = get_peer_or_404(cursor, peer_id)
+
+        if 'reputation_override' in update_data:
+            logger.info(
+                f"Peer {peer['domain']} ingestion override set to {update_data['reputation_override']} "
+                f"by admin {admin_user['id']}"
+            )
+        return FederationPeerResponse(**peer)
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Update peer error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to update peer")
+
+
+@router.delete("/peers/{peer_id}")
+async def delete_peer(peer_id: str, admin_user: dict = Depends(get_admin_user)):
+    """Stop federating with a peer and remove its content, leaving tombstones (admin only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            peer = get_peer(cursor, peer_id)
+            if not peer:
+                raise HTTPException(status_code=404, detail="Peer not found")
+            removed = remove_peer_content(cursor, peer)
+            cursor.execute("DELETE FROM federation_peers WHERE id = %s", (peer_id,))
+
+        logger.info(f"Federation peer {peer['domain']} removed by admin {admin_user['id']} ({removed} articles)")
+        return {"success": True, "message": "Peer removed", "articles_removed": removed}
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Delete peer error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to remove peer")
+
+
+@router.post("/peers/{peer_id}/sync", status_code=status.HTTP_202_ACCEPTED)
+async def sync_peer_now(peer_id: str, admin_user: dict = Depends(get_admin_user)):
+    """Pull and revalidate a peer's content now instead of waiting for its refresh interval (admin only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            get_peer_or_404(cursor, peer_id)
+        result = sync_federation_peer.delay(peer_id)
+        return {"success": True, "task_id": result.id}
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Sync peer error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to queue peer sync")
+
+
+@router.get("/peers/{peer_id}/reputation")
+async def get_peer_reputation(peer_id: str, admin_user: dict = Depends(get_admin_user)):
+    """A peer's reputation score, the signals behind it and its ingestion state (admin only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            return reputation_report(cursor, get_peer_or_404(cursor, peer_id))
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Peer reputation error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve peer reputation")
+
+
+@router.get("/tombstones", response_model=List[FederationTombstoneResponse])
+async def get_tombstones(
+    peer_id: Optional[str] = Query(None),
+    limit: int = Query(100, ge=1, le=500),
+    admin_user: dict = Depends(get_admin_user)
+):
+    """Federated content removed from this instance (admin only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            return [FederationTombstoneResponse(**row) for row in list_tombstones(cursor, limit, peer_id)]
+    except Exception as e:
+        logger.error(f"List tombstones error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve tombstones")
@@ -0,0 +1,213 @@
+"""
+Feed routes for FastAPI backend
+"""
+
+import sys
+import os
+import json
+from typing import Optional
+from fastapi import APIRouter, HTTPException, Depends, Query, Request, Response
+import logging
+from datetime import datetime
+
+sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
+
+from shared.database import get_postgres_cursor, get_redis
+from shared.models import HomeFeedResponse, CursorPaginatedResponse, FeedPageResponse, ArticleResponse
+from shared.curation import get_active_pins
+from shared.feed_composer import feed_composer, home_feed_cache_key, personalized_feed
+from shared.visibility import hidden_authors
+from shared.readability import LEVEL_NAMES, parse_reading_levels
+from shared.experiments import serve_variants
+from shared.wire_formats import negotiate, respond
+from shared import feed_versions, lite
+from shared.utils import encode_cursor, decode_cursor, deserialize_datetime
+from ..dependencies import get_current_user, get_optional_user
+
+router = APIRouter()
+logger = logging.getLogger(__name__)
+
+
+def feed_etag(request: Request, user: Optional[dict], *params) -> Optional[str]:
+    """ETag for the first page of a feed, or None when versions are unavailable"""
+    version = feed_versions.token(str(user['id']) if user else None)
+    if version is None:
+        return None
+    return feed_versions.etag(version, *params, negotiate(request.headers.get('accept')), lite.is_lite())
+
+
+def conditional(request: Request, etag: Optional[str]) -> Optional[Response]:
+    """A 304 when the client already holds the current feed version"""
+    if etag and feed_versions.not_modified(request.headers.get('if-none-match'), etag):
+        return Response(status_code=304, headers={'ETag': etag, 'Cache-Control': 'private, no-cache'})
+    return None
+
+
+def tagged(result, response: Response, etag: Optional[str]):
+    """Attach the feed version to a response from `respond`, whether it returned a model or a Response"""
+    if etag:
+        headers = result.headers if isinstance(result, Response) else response.headers
+        headers['ETag'] = etag
+        headers['Cache-Control'] = 'private, no-cache'
+    return result
+
+
+@router.get("/", response_model=FeedPageResponse)
+async def get_personalized_feed(
+    request: Request,
+    response: Response,
+    cursor: Optional[str] = Query(None),
+    limit: int = Query(20, ge=1, le=100),
+    current_user: Optional[dict] = Depends(get_optional_user)
+):
+    """Get the personalized feed merging followed authors, preferred categories and trending content
+
+    The first page carries an ETag of the reader's feed version; sending it
+    back in `If-None-Match` gets a 304 until the feed changes.
+    """
+    etag = None if cursor else feed_etag(request, current_user, limit)
+    unchanged = conditional(request, etag)
+    if unchanged:
+        return unchanged
+
+    try:
+        articles, next_cursor = personalized_feed.get_page(current_user, cursor, limit)
+
+        # Pins lead the first page only; the ranking leaves them out of every page
+        pinned = []
+        if not cursor:
+            with get_postgres_cursor() as db_cursor:
+                pinned = get_active_pins(db_cursor, 'home')
+    except ValueError:
+        raise HTTPException(status_code=400, detail="Invalid cursor")
+    except Exception as e:
+        logger.error(f"Get personalized feed error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve feed")
+
+    ranked = [ArticleResponse(**dict(article)) for article in articles]
+    serve_variants(ranked + [pin.article for pin in pinned], str(current_user['id']) if current_user else None)
+    return tagged(respond(request, FeedPageResponse(
+        pinned=pinned,
+        data=[article.dict() for article in ranked],
+        next_cursor=next_cursor,
+        has_more=next_cursor is not None
+    ), 'FeedPage'), response, etag)
+
+
+def with_variants(response: HomeFeedResponse, user: Optional[dict]) -> HomeFeedResponse:
+    """Apply the viewer's experiment variants after caching, so the cached feed holds the originals"""
+    articles = [pin.article for pin in response.pinned]
+    for shelf in response.shelves:
+        articles.extend(shelf.items)
+    serve_variants(articles, str(user['id']) if user else None)
+    return response
+
+
+@router.get("/home", response_model=HomeFeedResponse)
+async def get_home_feed(request: Request, current_user: Optional[dict] = Depends(get_optional_user)):
+    """Get the home feed assembled from configured shelves"""
+    try:
+        viewer = str(current_user['id']) if current_user else 'anonymous'
+        cache_key = home_feed_cache_key(viewer)
+        cache_ttl = feed_composer.load_config().cache_ttl_seconds
+
+        if cache_ttl:
+            try:
+                cached = get_redis().get(cache_key)
+                if cached:
+                    return respond(request, with_variants(HomeFeedResponse(**json.loads(cached)), current_user), 'HomeFeed')
+            except Exception as redis_error:
+                logger.warning(f"Redis cache error: {redis_error}")
+
+        pinned, shelves = feed_composer.compose(current_user)
+        response = HomeFeedResponse(
+            pinned=pinned,
+            shelves=shelves,
+            generated_at=datetime.now()
+        )
+
+        if cache_ttl:
+            try:
+                get_redis().setex(cache_key, cache_ttl, json.dumps(response.dict(), default=str))
+            except Exception as redis_error:
+                logger.warning(f"Redis cache set error: {redis_error}")
+
+        return respond(request, with_variants(response, current_user), 'HomeFeed')
+    except Exception as e:
+        logger.error(f"Get home feed error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to build home feed")
+
+
+@router.get("/following", response_model=CursorPaginatedResponse)
+async def get_following_feed(
+    request: Request,
+    response: Response,
+    cursor: Optional[str] = Query(None),
+    limit: int = Query(20, ge=1, le=100),
+    reading_level: Optional[str] = Query(None, description="Comma-separated reading levels, e.g. elementary,middle_school"),
+    current_user: dict = Depends(get_current_user)
+):
+    """Get recent articles from followed authors, paginated by cursor, optionally only at some reading levels
+
+    Like the personalized feed, the first page is conditional on the reader's feed version.
+    """
+    etag = None if cursor else feed_etag(request, current_user, limit, reading_level)
+    unchanged = conditional(request, etag)
+    if unchanged:
+        return unchanged
+
+    try:
+        query = """
+            SELECT a.* FROM articles a
+            JOIN user_follows uf ON uf.following_id = a.author_id
+            WHERE uf.follower_id = %s
+            AND a.status = 'published'
+            AND a.anonymous_author = false
+            AND a.published_at IS NOT NULL
+            AND NOT (a.author_id::text = ANY(%s))
+        """
+        with get_postgres_cursor() as db_cursor:
+            params = [str(current_user['id']), hidden_authors(db_cursor, current_user)]
+
+        if reading_level:
+            levels = parse_reading_levels(reading_level)
+            if not levels:
+                raise HTTPException(status_code=400, detail=f"reading_level must be among {', '.join(LEVEL_NAMES)}")
+            query += " AND a.reading_level = ANY(%s)"
+            params.append(levels)
+
+        if cursor:
+            position = decode_cursor(cursor)
+            published_at = deserialize_datetime(position.get('published_at')) if position else None
+            if not published_at or not position.get('id'):
+                raise HTTPException(status_code=400, detail="Invalid cursor")
+            query += " AND (a.published_at, a.id) < (%s, %s)"
+            params.extend([published_at, position['id']])
+
+        query += " ORDER BY a.published_at DESC, a.id DESC LIMIT %s"
+        params.append(limit + 1)
+
+        with get_postgres_cursor() as db_cursor:
+            db_cursor.execute(query, params)
+            articles = db_cursor.fetchall()
+
+        has_more = len(articles) > limit
+        articles = articles[:limit]
+
+        next_cursor = None
+        if has_more:
+            last = articles[-1]
+            next_cursor = encode_cursor({'published_at': last['published_at'].isoformat(), 'id': str(last['id'])})
+
+        page = [ArticleResponse(**dict(article)) for article in articles]
+        serve_variants(page, str(current_user['id']))
+        return tagged(respond(request, CursorPaginatedResponse(
+            data=[article.dict() for article in page],
+            next_cursor=next_cursor,
+            has_more=has_more
+        ), 'FeedPage'), response, etag)
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Get following feed error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve following feed")
@@ -0,0 +1,181 @@
+"""
+Imported feed administration routes for FastAPI backend
+"""
+
+import sys
+import os
+from typing import List
+from fastapi import APIRouter, HTTPException, Depends, status
+import logging
+import psycopg2
+
+sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
+
+from shared.database import get_postgres_cursor
+from shared.models import FeedSourceCreate, FeedSourceUpdate, FeedSourceResponse
+from shared.feed_import import health
+from shared.jobs import fetch_imported_feed
+from shared.webhooks import is_allowed_target
+from ..dependencies import get_admin_user
+
+router = APIRouter()
+logger = logging.getLogger(__name__)
+
+
+def get_feed_or_404(cursor, feed_id: str) -> dict:
+    cursor.execute("SELECT * FROM feed_sources WHERE id = %s", (feed_id,))
+    feed = cursor.fetchone()
+    if not feed:
+        raise HTTPException(status_code=404, detail="Feed not found")
+    return dict(feed)
+
+
+def feed_response(feed: dict) -> FeedSourceResponse:
+    return FeedSourceResponse(**feed, health=health(feed))
+
+
+def check_feed_url(url: str):
+    if not is_allowed_target(url):
+        raise HTTPException(status_code=400, detail="Feed URL must be a public HTTP(S) address")
+
+
+@router.get("/", response_model=List[FeedSourceResponse])
+async def list_feeds(admin_user: dict = Depends(get_admin_user)):
+    """List imported feeds with their fetch health (admin only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute("SELECT * FROM feed_sources ORDER BY created_at")
+            return [feed_response(dict(feed)) for feed in cursor.fetchall()]
+    except Exception as e:
+        logger.error(f"List feeds error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve feeds")
+
+
+@router.get("/health")
+async def get_feed_health(admin_user: dict = Depends(get_admin_user)):
+    """Fetch health across active feeds, with the feeds that need attention (admin only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute("SELECT * FROM feed_sources WHERE is_active = true ORDER BY consecutive_failures DESC, url")
+            feeds = [dict(feed) for feed in cursor.fetchall()]
+
+        counts = {'ok': 0, 'degraded': 0, 'failing': 0, 'pending': 0}
+        unhealthy = []
+        for feed in feeds:
+            state = health(feed)
+            counts[state] += 1
+            if state in ('degraded', 'failing'):
+                unhealthy.append(feed_response(feed))
+        return {"success": True, "counts": counts, "unhealthy": unhealthy}
+    except Exception as e:
+        logger.error(f"Feed health error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve feed health")
+
+
+@router.post("/", response_model=FeedSourceResponse, status_code=status.HTTP_201_CREATED)
+async def create_feed(feed_data: FeedSourceCreate, admin_user: dict = Depends(get_admin_user)):
+    """Register an RSS or Atom feed; it is fetched on the next scheduler run (admin only)"""
+    check_feed_url(feed_data.url)
+
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute("""
+                INSERT INTO feed_sources (url, name, author_id, category, language, refresh_interval_minutes, created_by)
+                VALUES (%s, %s, %s, %s, %s, %s, %s)
+                RETURNING *
+            """, (
+                feed_data.url, feed_data.name, str(feed_data.author_id or admin_user['id']), feed_data.category,
+                feed_data.language, feed_data.refresh_interval_minutes, admin_user['id']
+            ))
+            feed = dict(cursor.fetchone())
+
+        logger.info(f"Feed {feed['url']} added by admin {admin_user['id']}")
+        return feed_response(feed)
+    except psycopg2.errors.UniqueViolation:
+        raise HTTPException(status_code=409, detail="Feed already exists")
+    except psycopg2.errors.ForeignKeyViolation:
+        raise HTTPException(status_code=400, detail="Author not found")
+    except Exception as e:
+        logger.error(f"Create feed error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to add feed")
+
+
+@router.get("/{feed_id}", response_model=FeedSourceResponse)
+async def get_feed(feed_id: str, admin_user: dict = Depends(get_admin_user)):
+    """Get a feed with its fetch health (admin only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            return feed_response(get_feed_or_404(cursor, feed_id))
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Get feed error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve feed")
+
+
+@router.patch("/{feed_id}", response_model=FeedSourceResponse)
+async def update_feed(feed_id: str, update: FeedSourceUpdate, admin_user: dict = Depends(get_admin_user)):
+    """Change a feed's URL, author, defaults or refresh interval, or pause it (admin only)"""
+    update_data = update.dict(exclude_unset=True)
+    if not update_data:
+        raise HTTPException(status_code=400, detail="No valid fields to update")
+    if update_data.get('url'):
+        check_feed_url(update_data['url'])
+    if update_data.get('author_id'):
+        update_data['author_id'] = str(update_data['author_id'])
+
+    try:
+        with get_postgres_cursor() as cursor:
+            feed = get_feed_or_404(cursor, feed_id)
+            assignments = [f"{field} = %s" for field in update_data]
+            if update_data.get('url') and update_data['url'] != feed['url']:
+                # Validators from the old URL mean nothing to the new one
+                assignments.extend(["etag = NULL", "last_modified = NULL"])
+            cursor.execute(
+                f"UPDATE feed_sources SET {', '.join(assignments)}, updated_at = NOW() WHERE id = %s RETURNING *",
+                list(update_data.values()) + [feed_id]
+            )
+            feed = dict(cursor.fetchone())
+
+        return feed_response(feed)
+    except HTTPException:
+        raise
+    except psycopg2.errors.UniqueViolation:
+        raise HTTPException(status_code=409, detail="Feed already exists")
+    except psycopg2.errors.ForeignKeyViolation:
+        raise HTTPException(status_code=400, detail="Author not found")
+    except Exception as e:
+        logger.error(f"Update feed error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to update feed")
+
+
+@router.delete("/{feed_id}")
+async def delete_feed(feed_id: str, admin_user: dict = Depends(get_admin_user)):
+    """Stop importing a feed; drafts already created from it are kept (admin only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            feed = get_feed_or_404(cursor, feed_id)
+            cursor.execute("DELETE FROM feed_sources WHERE id = %s", (feed_id,))
+
+        logger.info(f"Feed {feed['url']} removed by admin {admin_user['id']}")
+        return {"success": True, "message": "Feed removed"}
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Delete feed error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to remove feed")
+
+
+@router.post("/{feed_id}/fetch", status_code=status.HTTP_202_ACCEPTED)
+async def fetch_feed_now(feed_id: str, admin_user: dict = Depends(get_admin_user)):
+    """Fetch a feed now instead of waiting for its refresh interval (admin only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            get_feed_or_404(cursor, feed_id)
+        result = fetch_imported_feed.delay(feed_id)
+        return {"success": True, "task_id": result.id}
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Fetch feed error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to queue feed fetch")
@@ -0,0 +1,148 @@
+"""
+Funnel analytics routes for FastAPI backend
+"""
+
+import sys
+import os
+from datetime import date, datetime, timedelta, timezone
+from typing import Optional
+from fastapi import APIRouter, HTTPException, Depends, Query, status
+import logging
+import psycopg2
+
+sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
+
+from shared.database import get_postgres_cursor, prepare_json_data, query_timeout
+from shared.models import FunnelCreate, FunnelUpdate
+from shared import funnels
+from ..dependencies import get_admin_user
+
+router = APIRouter()
+logger = logging.getLogger(__name__)
+
+
+@router.get("/funnels")
+async def get_funnels(admin_user: dict = Depends(get_admin_user)):
+    """Funnel definitions (admin only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            return {"success": True, "funnels": funnels.list_funnels(cursor)}
+    except Exception as e:
+        logger.error(f"List funnels error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve funnels")
+
+
+@router.post("/funnels", status_code=status.HTTP_201_CREATED)
+async def create_funnel(funnel: FunnelCreate, admin_user: dict = Depends(get_admin_user)):
+    """Define a funnel; it is counted from the next run of the funnel job (admin only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute("""
+                INSERT INTO funnels (key, name, description, steps, window_hours, created_by)
+                VALUES (%s, %s, %s, %s, %s, %s)
+                RETURNING *
+            """, (funnel.key, funnel.name, funnel.description,
+                  prepare_json_data([step.model_dump(mode='json', exclude_none=True) for step in funnel.steps]),
+                  funnel.window_hours, admin_user['id']))
+            return {"success": True, "funnel": dict(cursor.fetchone())}
+    except psycopg2.errors.UniqueViolation:
+        raise HTTPException(status_code=409, detail="A funnel with this key already exists")
+    except Exception as e:
+        logger.error(f"Create funnel error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to create funnel")
+
+
+@router.put("/funnels/{key}")
+async def update_funnel(key: str, update: FunnelUpdate, admin_user: dict = Depends(get_admin_user)):
+    """Change a funnel; changing its steps or window drops its counts until the next run (admin only)"""
+    try:
+        changes = update.model_dump(mode='json', exclude_none=True)
+        if not changes:
+            raise HTTPException(status_code=400, detail="No changes given")
+
+        with get_postgres_cursor() as cursor:
+            funnel = funnels.get_funnel(cursor, key)
+            if not funnel:
+                raise HTTPException(status_code=404, detail="Funnel not found")
+
+            assignments = ', '.join(f"{column} = %s" for column in changes)
+            cursor.execute(
+                f"UPDATE funnels SET {assignments}, updated_at = NOW() WHERE id = %s RETURNING *",
+                [prepare_json_data(value) for value in changes.values()] + [funnel['id']]
+            )
+            updated = dict(cursor.fetchone())
+            if 'steps' in changes or 'window_hours' in changes:
+                funnels.clear_counts(cursor, funnel['id'])
+
+        return {"success": True, "funnel": updated}
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Update funnel error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to update funnel")
+
+
+@router.delete("/funnels/{key}")
+async def delete_funnel(key: str, admin_user: dict = Depends(get_admin_user)):
+    """Delete a funnel and its counts (admin only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute("DELETE FROM funnels WHERE key = %s RETURNING id", (key,))
+            if not cursor.fetchone():
+                raise HTTPException(status_code=404, detail="Funnel not found")
+        return {"success": True, "message": "Funnel deleted"}
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Delete funnel error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to delete funnel")
+
+
+@router.get("/funnels/{key}/results")
+async def get_funnel_results(
+    key: str,
+    by: str = Query("total", pattern="^(total|article|category|variant)$"),
+    start: Optional[date] = Query(None, description="First day readers entered (defaults to 30 days back)"),
+    end: Optional[date] = Query(None, description="Last day readers entered (defaults to today, UTC)"),
+    experiment_id: Optional[str] = Query(None, description="Only readers in this experiment; required for by=variant"),
+    category: Optional[str] = Query(None),
+    article_id: Optional[str] = Query(None),
+    limit: int = Query(50, ge=1, le=500),
+    admin_user: dict = Depends(get_admin_user)
+):
+    """Readers reaching each step of the funnel and the conversion between steps (admin only)"""
+    try:
+        if by == "variant" and not experiment_id:
+            raise HTTPException(status_code=400, detail="Pass experiment_id to break results down by variant")
+        end = end or datetime.now(timezone.utc).date()
+        start = start or end - timedelta(days=29)
+        if start > end:
+            raise HTTPException(status_code=400, detail="start must not be after end")
+        if (end - start).days + 1 > funnels.MAX_RANGE_DAYS:
+            raise HTTPException(status_code=400, detail=f"At most {funnels.MAX_RANGE_DAYS} days at once")
+
+        with get_postgres_cursor(timeout_ms=query_timeout('analytics')) as cursor:
+            funnel = funnels.get_funnel(cursor, key)
+            if not funnel:
+                raise HTTPException(status_code=404, detail="Funnel not found")
+            segments = funnels.results(
+                cursor, funnel, by, start, end,
+                experiment_id=experiment_id, category=category, article_id=article_id, limit=limit
+            )
+            computed_at = funnels.computed_at(cursor, funnel['id'])
+
+        return {
+            "success": True,
+            "funnel": {field: funnel[field] for field in ('key', 'name', 'steps', 'window_hours')},
+            "by": by,
+            "start": start.isoformat(),
+            "end": end.isoformat(),
+            "computed_at": computed_at.isoformat() if computed_at else None,
+            "segments": segments
+        }
+
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Get funnel results error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to get funnel results")
@@ -0,0 +1,224 @@
+"""
+Governance proposal and voting routes for FastAPI backend
+"""
+
+import sys
+import os
+from typing import Optional
+from fastapi import APIRouter, HTTPException, Depends, status, Query
+import logging
+
+sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
+
+from shared.database import get_postgres_cursor
+from shared.models import ProposalCreate, VoteCast, GovernanceProposalResponse
+from shared import governance
+from shared.governance import NotEligible, VotingClosed
+from ..dependencies import get_current_user, get_optional_user, get_admin_user
+
+router = APIRouter()
+logger = logging.getLogger(__name__)
+
+# Open proposals count votes as they come in; closed ones keep their final tally
+PROPOSAL_SELECT = """
+    SELECT p.*, u.username AS proposer,
+           live.yes AS live_yes, live.no AS live_no, live.abstain AS live_abstain
+    FROM governance_proposals p
+    LEFT JOIN users u ON u.id = p.proposer_id
+    LEFT JOIN LATERAL (
+        SELECT COUNT(*) FILTER (WHERE choice = 'yes') AS yes,
+               COUNT(*) FILTER (WHERE choice = 'no') AS no,
+               COUNT(*) FILTER (WHERE choice = 'abstain') AS abstain
+        FROM governance_votes WHERE proposal_id = p.id
+    ) live ON p.status = 'open'
+"""
+
+
+def build_proposal_response(proposal: dict, my_vote: Optional[str] = None) -> GovernanceProposalResponse:
+    if proposal['status'] == governance.OPEN:
+        votes = {'yes': proposal['live_yes'] or 0, 'no': proposal['live_no'] or 0, 'abstain': proposal['live_abstain'] or 0}
+    else:
+        votes = {'yes': proposal['yes_votes'], 'no': proposal['no_votes'], 'abstain': proposal['abstain_votes']}
+    return GovernanceProposalResponse(**{**proposal, 'pass_threshold': float(proposal['pass_threshold'])},
+                                      votes=votes, my_vote=my_vote)
+
+
+def get_proposal(cursor, proposal_id: str, lock: bool = False) -> dict:
+    cursor.execute(PROPOSAL_SELECT + " WHERE p.id = %s" + (" FOR UPDATE OF p" if lock else ""), (proposal_id,))
+    proposal = cursor.fetchone()
+    if not proposal:
+        raise HTTPException(status_code=404, detail="Proposal not found")
+    return dict(proposal)
+
+
+def my_votes(cursor, user: Optional[dict], proposal_ids: list) -> dict:
+    if not user or not proposal_ids:
+        return {}
+    cursor.execute(
+        "SELECT proposal_id, choice FROM governance_votes WHERE voter_id = %s AND proposal_id = ANY(%s::uuid[])",
+        (user['id'], [str(proposal_id) for proposal_id in proposal_ids])
+    )
+    return {str(row['proposal_id']): row['choice'] for row in cursor.fetchall()}
+
+
+@router.get("/proposals")
+async def list_proposals(
+    proposal_status: Optional[str] = Query(None, alias="status", pattern=f"^({'|'.join(governance.STATUSES)})$"),
+    kind: Optional[str] = Query(None, pattern=f"^({'|'.join(governance.KINDS)})$"),
+    limit: int = Query(20, ge=1, le=100),
+    offset: int = Query(0, ge=0),
+    current_user: Optional[dict] = Depends(get_optional_user)
+):
+    """Governance proposals, open ones closing soonest first, then the most recently closed"""
+    try:
+        query = PROPOSAL_SELECT + " WHERE TRUE"
+        params = []
+        if proposal_status:
+            query += " AND p.status = %s"
+            params.append(proposal_status)
+        if kind:
+            query += " AND p.kind = %s"
+            params.append(kind)
+
+        with get_postgres_cursor() as cursor:
+            cursor.execute(
+                query + """
+                    ORDER BY (p.status = 'open') DESC,
+                             CASE WHEN p.status = 'open' THEN p.voting_ends_at END ASC,
+                             p.voting_ends_at DESC
+                    LIMIT %s OFFSET %s
+                """,
+                params + [limit, offset]
+            )
+            proposals = [dict(row) for row in cursor.fetchall()]
+            votes = my_votes(cursor, current_user, [proposal['id'] for proposal in proposals])
+
+        return {
+            "success": True,
+            "proposals": [
+                build_proposal_response(proposal, votes.get(str(proposal['id']))) for proposal in proposals
+            ]
+        }
+    except Exception as e:
+        logger.error(f"List proposals error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve proposals")
+
+
+@router.get("/proposals/{proposal_id}", response_model=GovernanceProposalResponse)
+async def get_proposal_detail(proposal_id: str, current_user: Optional[dict] = Depends(get_optional_user)):
+    """A proposal with its vote counts and the caller's vote"""
+    try:
+        with get_postgres_cursor() as cursor:
+            proposal = get_proposal(cursor, proposal_id)
+            votes = my_votes(cursor, current_user, [proposal['id']])
+        return build_proposal_response(proposal, votes.get(str(proposal['id'])))
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Get proposal error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve proposal")
+
+
+@router.post("/proposals", response_model=GovernanceProposalResponse, status_code=status.HTTP_201_CREATED)
+async def create_proposal(proposal: ProposalCreate, current_user: dict = Depends(get_current_user)):
+    """Open a proposal for voting (needs the configured reputation)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            try:
+                created = governance.create_proposal(
+                    cursor, current_user, proposal.kind.value, proposal.title, proposal.description,
+                    proposal.details, proposal.voting_days
+                )
+            except NotEligible as e:
+                raise HTTPException(status_code=403, detail=str(e))
+            return build_proposal_response(get_proposal(cursor, created['id']))
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Create proposal error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to create proposal")
+
+
+@router.post("/proposals/{proposal_id}/votes", response_model=GovernanceProposalResponse)
+async def vote(proposal_id: str, ballot: VoteCast, current_user: dict = Depends(get_current_user)):
+    """Vote on an open proposal, or change your vote (needs the configured reputation)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            try:
+                cast = governance.cast_vote(cursor, proposal_id, current_user, ballot.choice.value)
+            except LookupError:
+                raise HTTPException(status_code=404, detail="Proposal not found")
+            except NotEligible as e:
+                raise HTTPException(status_code=403, detail=str(e))
+            except VotingClosed as e:
+                raise HTTPException(status_code=409, detail=str(e))
+            return build_proposal_response(get_proposal(cursor, proposal_id), cast['choice'])
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Vote error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to record vote")
+
+
+@router.get("/proposals/{proposal_id}/vote-proof")
+async def get_vote_proof(proposal_id: str, current_user: dict = Depends(get_current_user)):
+    """Proof that the caller's vote is included in the proposal's tallied votes root"""
+    try:
+        with get_postgres_cursor() as cursor:
+            proposal = get_proposal(cursor, proposal_id)
+            if not proposal['tallied_at']:
+                raise HTTPException(status_code=409, detail="The proposal has not been tallied yet")
+            proof = governance.vote_proof(cursor, proposal, current_user['id'])
+        if not proof:
+            raise HTTPException(status_code=404, detail="You did not vote on this proposal")
+        return {"success": True, **proof}
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Vote proof error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to build vote proof")
+
+
+@router.post("/proposals/{proposal_id}/cancel", response_model=GovernanceProposalResponse)
+async def cancel_proposal(proposal_id: str, current_user: dict = Depends(get_current_user)):
+    """Withdraw an open proposal (its proposer or an administrator)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            proposal = get_proposal(cursor, proposal_id, lock=True)
+            if str(proposal['proposer_id']) != str(current_user['id']) and current_user.get('role') != 'administrator':
+                raise HTTPException(status_code=403, detail="Only the proposer can cancel a proposal")
+            if proposal['status'] != governance.OPEN:
+                raise HTTPException(status_code=409, detail=f"Proposal already {proposal['status']}")
+            cursor.execute("UPDATE governance_proposals SET status = 'cancelled' WHERE id = %s", (proposal_id,))
+            cancelled = get_proposal(cursor, proposal_id)
+
+        logger.info(f"Governance proposal {proposal_id} cancelled by {current_user['id']}")
+        return build_proposal_response(cancelled)
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Cancel proposal error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to cancel proposal")
+
+
+@router.post("/proposals/{proposal_id}/enact", response_model=GovernanceProposalResponse)
+async def enact_proposal(proposal_id: str, admin_user: dict = Depends(get_admin_user)):
+    """Mark a passed proposal as carried out (admin only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            proposal = get_proposal(cursor, proposal_id, lock=True)
+            if proposal['status'] != governance.PASSED:
+                raise HTTPException(status_code=409, detail="Only passed proposals can be enacted")
+            cursor.execute("""
+                UPDATE governance_proposals SET status = 'enacted', enacted_by = %s, enacted_at = NOW()
+                WHERE id = %s
+            """, (admin_user['id'], proposal_id))
+            enacted = get_proposal(cursor, proposal_id)
+
+        logger.info(f"Governance proposal {proposal_id} enacted by {admin_user['id']}")
+        return build_proposal_response(enacted)
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Enact proposal error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to enact proposal")
@@ -11,6 +11,8 @@ sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
 
 from shared.database import get_postgres_cursor, get_mongodb, get_redis
 from shared.models import HealthResponse
+from shared.modules import health_checks as module_health_checks, required_services, summary as module_summary
+from shared.storage import health_checks as storage_health_checks
 from shared.utils import health_check_service
 
 router = APIRouter()
@@ -34,7 +36,8 @@ async def health_check():
             db = get_mongodb()
             db.command('ping')
         
-        services.update(health_check_service('mongodb', check_mongodb))
+        if 'mongodb' in required_services():
+            services.update(health_check_service('mongodb', check_mongodb))
         
         def check_redis():
             redis_client = get_redis()
@@ -42,12 +45,19 @@ async def health_check():
         
         services.update(health_check_service('redis', check_redis))
         
+        for name, check_storage in storage_health_checks().items():
+            services.update(health_check_service(name, check_storage))
+        
+        for name, check_module in module_health_checks().items():
+            services.update(health_check_service(name, check_module))
+        
         all_healthy = all(status == "healthy" for status in services.values())
         status_code = "healthy" if all_healthy else "degraded"
         
         response = HealthResponse(
             status=status_code,
-            services=services
+            services=services,
+            modules=module_summary()
         )
         
         if not all_healthy:
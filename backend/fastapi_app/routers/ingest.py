@@ -0,0 +1,46 @@
+"""
+Article ingestion routes for FastAPI backend
+"""
+
+import sys
+import os
+from fastapi import APIRouter, HTTPException, Depends
+import logging
+
+sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
+
+from shared.database import get_postgres_cursor
+from shared.models import IngestBatch
+from shared.ingestion import ingest_article
+from ..dependencies import require_api_key
+
+router = APIRouter()
+logger = logging.getLogger(__name__)
+
+
+@router.post("/articles")
+async def ingest_articles(batch: IngestBatch, api_key: dict = Depends(require_api_key('write:articles'))):
+    """Submit a batch of scraped or syndicated articles
+
+    Each article is stored in its own transaction, so one failure doesn't
+    affect the rest; the result for every article is returned in order.
+    """
+    try:
+        results = []
+        for index, item in enumerate(batch.articles):
+            try:
+                with get_postgres_cursor() as cursor:
+                    result = ingest_article(cursor, item.dict(), api_key)
+            except Exception as e:
+                logger.warning(f"Ingesting {item.url} with key {api_key['key_prefix']} failed: {e}")
+                result = {'status': 'failed', 'reason': 'Could not store article'}
+            results.append({'index': index, 'url': item.url, **result})
+
+        counts = {}
+        for result in results:
+            counts[result['status']] = counts.get(result['status'], 0) + 1
+        logger.info(f"Ingestion batch from {api_key['name']}: {counts}")
+        return {"success": True, "counts": counts, "results": results}
+    except Exception as e:
+        logger.error(f"Ingest articles error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to ingest articles")
@@ -4,158 +4,235 @@ User interactions routes for FastAPI backend
 
 import sys
 import os
-import json
+from typing import Optional
 from fastapi import APIRouter, HTTPException, Depends, status
 import logging
 
 sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
 
 from shared.database import get_postgres_cursor
-from shared.models import InteractionCreate, InteractionResponse
-from shared.utils import generate_uuid, generate_session_id
-from ..dependencies import get_current_user
+from shared.models import InteractionCreate, InteractionResponse, InteractionType, ArticleReactionsResponse, ClapCreate
+from shared.reactions import get_reaction_types, toggle_reaction, get_user_reactions, update_engagement_score
+from shared.events import interaction_recorded
+from shared.reputation import article_liked
+from shared.claps import ClapLimitExceeded, MAX_CLAPS_PER_USER, add_claps, check_rate_limit, get_user_claps
+from shared.repositories import Repositories
+from ..dependencies import get_current_user, get_optional_user, get_repositories
 
 router = APIRouter()
 logger = logging.getLogger(__name__)
 
 
 @router.post("/", response_model=InteractionResponse, status_code=status.HTTP_201_CREATED)
-async def create_interaction(interaction_data: InteractionCreate, current_user: dict = Depends(get_current_user)):
+async def create_interaction(
+    interaction_data: InteractionCreate,
+    current_user: dict = Depends(get_current_user),
+    repos: Repositories = Depends(get_repositories)
+):
     """Record user interaction with article"""
+    if interaction_data.interaction_type == InteractionType.REACTION:
+        raise HTTPException(status_code=400, detail="Use the article reactions endpoint to react")
+
     try:
         user_id = current_user['id']
-        interaction_id = generate_uuid()
-        session_id = generate_session_id(user_id)
         
-        with get_postgres_cursor() as cursor:
-            cursor.execute("""
-                INSERT INTO user_interactions (
-                    id, user_id, article_id, interaction_type, interaction_strength,
-                    reading_progress, time_spent, device_type, context_data, session_id, created_at
-                ) VALUES (%s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s) RETURNING *
-            """, (
-                interaction_id, user_id, str(interaction_data.article_id),
-                interaction_data.interaction_type.value, interaction_data.interaction_strength,
-                interaction_data.reading_progress, interaction_data.time_spent,
-                interaction_data.device_type, json.dumps(interaction_data.context_data or {}),
-                session_id, 'now()'
-            ))
-            
-            interaction_record = cursor.fetchone()
+        interaction_record = repos.interactions.record(
+            user_id, interaction_data.article_id, interaction_data.interaction_type.value, {
+                'interaction_strength': interaction_data.interaction_strength,
+                'reading_progress': interaction_data.reading_progress,
+                'time_spent': interaction_data.time_spent,
+                'device_type': interaction_data.device_type,
+                'context_data': interaction_data.context_data or {},
+            }
+        )
+        interaction_recorded(
+            repos.cursor, user_id, interaction_data.article_id, interaction_data.interaction_type.value,
+            interaction_strength=interaction_data.interaction_strength,
+            reading_progress=interaction_data.reading_progress,
+            time_spent=interaction_data.time_spent,
+            device_type=interaction_data.device_type
+        )
         
-        return InteractionResponse(**dict(interaction_record))
+        return InteractionResponse(**interaction_record)
     except Exception as e:
         logger.error(f"Create interaction error: {e}")
         raise HTTPException(status_code=500, detail="Failed to record interaction")
 
 
 @router.post("/{article_id}/like")
-async def like_article(article_id: str, current_user: dict = Depends(get_current_user)):
+async def like_article(
+    article_id: str,
+    current_user: dict = Depends(get_current_user),
+    repos: Repositories = Depends(get_repositories)
+):
     """Like/unlike an article"""
     try:
         user_id = current_user['id']
         
-        with get_postgres_cursor() as cursor:
-            # Check if user already liked this article
-            cursor.execute("""
-                SELECT id FROM user_interactions 
-                WHERE user_id = %s AND article_id = %s AND interaction_type = 'like'
-            """, (user_id, article_id))
-            
-            existing_like = cursor.fetchone()
+        if repos.interactions.has(user_id, article_id, 'like'):
+            # Remove like
+            repos.interactions.remove(user_id, article_id, 'like')
+            repos.articles.increment_counter(article_id, 'like_count', -1)
+            update_engagement_score(repos.cursor, article_id)
             
-            if existing_like:
-                # Remove like
-                cursor.execute("""
-                    DELETE FROM user_interactions 
-                    WHERE user_id = %s AND article_id = %s AND interaction_type = 'like'
-                """, (user_id, article_id))
-                
-                # Update article like count
-                cursor.execute("""
-                    UPDATE articles SET like_count = like_count - 1 
-                    WHERE id = %s AND like_count > 0
-                """, (article_id,))
-                
-                return {"success": True, "liked": False, "message": "Article unliked"}
-            else:
-                # Add like
-                interaction_id = generate_uuid()
-                session_id = generate_session_id(user_id)
-                
-                cursor.execute("""
-                    INSERT INTO user_interactions (
-                        id, user_id, article_id, interaction_type, interaction_strength,
-                        context_data, session_id, created_at
-                    ) VALUES (%s, %s, %s, %s, %s, %s, %s, %s)
-                """, (
-                    interaction_id, user_id, article_id, 'like', 1.0,
-                    json.dumps({}), session_id, 'now()'
-                ))
-                
-                # Update article like count
-                cursor.execute("""
-                    UPDATE articles SET like_count = like_count + 1 
-                    WHERE id = %s
-                """, (article_id,))
-                
-                return {"success": True, "liked": True, "message": "Article liked"}
+            return {"success": True, "liked": False, "message": "Article unliked"}
+        
+        # Add like
+        repos.interactions.record(user_id, article_id, 'like')
+        repos.articles.increment_counter(article_id, 'like_count')
+        update_engagement_score(repos.cursor, article_id)
+        interaction_recorded(repos.cursor, user_id, article_id, 'like')
+        article_liked(repos.cursor, article_id, user_id)
+        
+        return {"success": True, "liked": True, "message": "Article liked"}
                 
     except Exception as e:
         logger.error(f"Like article error: {e}")
         raise HTTPException(status_code=500, detail="Failed to like article")
 
 
+@router.get("/reactions")
+async def list_reaction_types():
+    """List the reactions readers can use"""
+    try:
+        return {"success": True, "types": [reaction.dict() for reaction in get_reaction_types()]}
+    except Exception as e:
+        logger.error(f"List reaction types error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve reaction types")
+
+
+@router.get("/{article_id}/reactions", response_model=ArticleReactionsResponse)
+async def get_article_reactions(article_id: str, current_user: Optional[dict] = Depends(get_optional_user)):
+    """Reaction distribution for an article, plus the caller's own reactions"""
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute("SELECT reaction_counts FROM articles WHERE id = %s", (article_id,))
+            article = cursor.fetchone()
+            if not article:
+                raise HTTPException(status_code=404, detail="Article not found")
+
+            user_reactions = get_user_reactions(cursor, current_user['id'] if current_user else None, article_id)
+
+        types = get_reaction_types()
+        counts = article['reaction_counts'] or {}
+        return ArticleReactionsResponse(
+            article_id=article_id,
+            counts={reaction.key: counts.get(reaction.key, 0) for reaction in types},
+            user_reactions=user_reactions,
+            types=types
+        )
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Get article reactions error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve reactions")
+
+
+@router.post("/{article_id}/reactions/{reaction}")
+async def react_to_article(article_id: str, reaction: str, current_user: dict = Depends(get_current_user)):
+    """Toggle a reaction on an article"""
+    if reaction not in {reaction_type.key for reaction_type in get_reaction_types()}:
+        raise HTTPException(status_code=400, detail=f"Unknown reaction '{reaction}'")
+
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute("SELECT id FROM articles WHERE id = %s", (article_id,))
+            if not cursor.fetchone():
+                raise HTTPException(status_code=404, detail="Article not found")
+
+            reacted = toggle_reaction(cursor, current_user['id'], article_id, reaction)
+            if reacted:
+                interaction_recorded(cursor, current_user['id'], article_id, 'reaction', reaction=reaction)
+
+        return {
+            "success": True,
+            "reaction": reaction,
+            "reacted": reacted,
+            "message": "Reaction added" if reacted else "Reaction removed"
+        }
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"React to article error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to update reaction")
+
+
+@router.get("/{article_id}/claps")
+async def get_article_claps(article_id: str, current_user: Optional[dict] = Depends(get_optional_user)):
+    """Total claps on an article and the caller's own claps"""
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute("SELECT clap_count FROM articles WHERE id = %s", (article_id,))
+            article = cursor.fetchone()
+            if not article:
+                raise HTTPException(status_code=404, detail="Article not found")
+
+            user_claps = get_user_claps(cursor, current_user['id'] if current_user else None, article_id)
+
+        return {
+            "success": True,
+            "total_claps": article['clap_count'] or 0,
+            "user_claps": user_claps,
+            "max_claps": MAX_CLAPS_PER_USER
+        }
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Get article claps error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve claps")
+
+
+@router.post("/{article_id}/claps")
+async def clap_article(article_id: str, clap_data: ClapCreate, current_user: dict = Depends(get_current_user)):
+    """Send claps to an article (up to 50 per reader in total)"""
+    try:
+        check_rate_limit(current_user['id'])
+
+        with get_postgres_cursor() as cursor:
+            cursor.execute("SELECT author_id FROM articles WHERE id = %s AND status = 'published'", (article_id,))
+            article = cursor.fetchone()
+            if not article:
+                raise HTTPException(status_code=404, detail="Article not found")
+            if str(article['author_id']) == str(current_user['id']):
+                raise HTTPException(status_code=400, detail="You cannot clap for your own article")
+
+            result = add_claps(cursor, current_user['id'], article_id, clap_data.claps)
+            update_engagement_score(cursor, article_id)
+            if result['added']:
+                interaction_recorded(cursor, current_user['id'], article_id, 'clap', claps=result['added'])
+
+        return {"success": True, **result}
+    except ClapLimitExceeded as e:
+        raise HTTPException(status_code=429, detail=str(e))
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Clap article error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to record claps")
+
+
 @router.post("/{article_id}/bookmark")
-async def bookmark_article(article_id: str, current_user: dict = Depends(get_current_user)):
+async def bookmark_article(
+    article_id: str,
+    current_user: dict = Depends(get_current_user),
+    repos: Repositories = Depends(get_repositories)
+):
     """Bookmark/unbookmark an article"""
     try:
         user_id = current_user['id']
         
-        with get_postgres_cursor() as cursor:
-            # Check if user already bookmarked this article
-            cursor.execute("""
-                SELECT id FROM saved_articles 
-                WHERE user_id = %s AND article_id = %s
-            """, (user_id, article_id))
+        if repos.interactions.is_saved(user_id, article_id):
+            # Remove bookmark
+            repos.interactions.unsave(user_id, article_id)
             
-            existing_bookmark = cursor.fetchone()
-            
-            if existing_bookmark:
-                # Remove bookmark
-                cursor.execute("""
-                    DELETE FROM saved_articles 
-                    WHERE user_id = %s AND article_id = %s
-                """, (user_id, article_id))
-                
-                return {"success": True, "bookmarked": False, "message": "Article unbookmarked"}
-            else:
-                # Add bookmark
-                bookmark_id = generate_uuid()
-                
-                cursor.execute("""
-                    INSERT INTO saved_articles (
-                        id, user_id, article_id, collection_name, created_at
-                    ) VALUES (%s, %s, %s, %s, %s)
-                """, (
-                    bookmark_id, user_id, article_id, 'default', 'now()'
-                ))
-                
-                # Record interaction
-                interaction_id = generate_uuid()
-                session_id = generate_session_id(user_id)
-                
-                cursor.execute("""
-                    INSERT INTO user_interactions (
-                        id, user_id, article_id, interaction_type, interaction_strength,
-                        context_data, session_id, created_at
-                    ) VALUES (%s, %s, %s, %s, %s, %s, %s, %s)
-                """, (
-                    interaction_id, user_id, article_id, 'save', 1.0,
-                    json.dumps({}), session_id, 'now()'
-                ))
-                
-                return {"success": True, "bookmarked": True, "message": "Article bookmarked"}
+            return {"success": True, "bookmarked": False, "message": "Article unbookmarked"}
+        
+        # Add bookmark and record the interaction
+        repos.interactions.save(user_id, article_id)
+        repos.interactions.record(user_id, article_id, 'save')
+        interaction_recorded(repos.cursor, user_id, article_id, 'save')
+        
+        return {"success": True, "bookmarked": True, "message": "Article bookmarked"}
                 
     except Exception as e:
         logger.error(f"Bookmark article error: {e}")
@@ -163,33 +240,22 @@ async def bookmark_article(article_id: str, current_user: dict = Depends(get_cur
 
 
 @router.post("/{article_id}/share")
-async def share_article(article_id: str, share_data: dict, current_user: dict = Depends(get_current_user)):
+async def share_article(
+    article_id: str,
+    share_data: dict,
+    current_user: dict = Depends(get_current_user),
+    repos: Repositories = Depends(get_repositories)
+):
     """Record article share"""
     try:
         user_id = current_user['id']
         platform = share_data.get('platform', 'unknown')
-        interaction_id = generate_uuid()
-        session_id = generate_session_id(user_id)
         
-        with get_postgres_cursor() as cursor:
-            # Record share interaction
-            cursor.execute("""
-                INSERT INTO user_interactions (
-                    id, user_id, article_id, interaction_type, interaction_strength,
-                    context_data, session_id, created_at
-                ) VALUES (%s, %s, %s, %s, %s, %s, %s, %s)
-            """, (
-                interaction_id, user_id, article_id, 'share', 1.0,
-                json.dumps({"platform": platform}), session_id, 'now()'
-            ))
-            
-            # Update article share count
-            cursor.execute("""
-                UPDATE articles SET share_count = share_count + 1 
-                WHERE id = %s
-            """, (article_id,))
-            
-            return {"success": True, "message": f"Article shared to {platform}"}
+        repos.interactions.record(user_id, article_id, 'share', {'context_data': {"platform": platform}})
+        repos.articles.increment_counter(article_id, 'share_count')
+        interaction_recorded(repos.cursor, user_id, article_id, 'share', platform=platform)
+        
+        return {"success": True, "message": f"Article shared to {platform}"}
                 
     except Exception as e:
         logger.error(f"Share article error: {e}")
@@ -197,65 +263,36 @@ async def share_article(article_id: str, share_data: dict, current_user: dict =
 
 
 @router.get("/{article_id}/status")
-async def get_article_interaction_status(article_id: str, current_user: dict = Depends(get_current_user)):
+async def get_article_interaction_status(
+    article_id: str,
+    current_user: dict = Depends(get_current_user),
+    repos: Repositories = Depends(get_repositories)
+):
     """Get user's interaction status with article"""
     try:
         user_id = current_user['id']
         logger.info(f"Getting interaction status for article {article_id} and user {user_id}")
         
-        with get_postgres_cursor() as cursor:
-            # First check if article exists
-            cursor.execute("SELECT id FROM articles WHERE id = %s", (article_id,))
-            article_exists = cursor.fetchone()
-            
-            if not article_exists:
-                logger.warning(f"Article {article_id} not found")
-                raise HTTPException(status_code=404, detail="Article not found")
-            
-            # Check if user liked the article
-            cursor.execute("""
-                SELECT EXISTS(
-                    SELECT 1 FROM user_interactions 
-                    WHERE user_id = %s AND article_id = %s AND interaction_type = 'like'
-                )
-            """, (user_id, article_id))
-            liked_result = cursor.fetchone()
-            liked = liked_result['exists'] if liked_result else False
-            
-            # Check if user bookmarked the article
-            cursor.execute("""
-                SELECT EXISTS(
-                    SELECT 1 FROM saved_articles 
-                    WHERE user_id = %s AND article_id = %s
-                )
-            """, (user_id, article_id))
-            bookmarked_result = cursor.fetchone()
-            bookmarked = bookmarked_result['exists'] if bookmarked_result else False
-            
-            # Get article stats
-            cursor.execute("""
-                SELECT like_count, view_count, share_count, comment_count
-                FROM articles WHERE id = %s
-            """, (article_id,))
-            stats = cursor.fetchone()
-            
-            if not stats:
-                logger.warning(f"No stats found for article {article_id}")
-                stats_dict = {"likes": 0, "views": 0, "shares": 0, "comments": 0}
-            else:
-                stats_dict = {
-                    "likes": stats['like_count'] or 0,
-                    "views": stats['view_count'] or 0,
-                    "shares": stats['share_count'] or 0,
-                    "comments": stats['comment_count'] or 0
-                }
-            
-            return {
-                "success": True,
-                "liked": liked,
-                "bookmarked": bookmarked,
-                "stats": stats_dict
+        stats = repos.articles.get_stats(article_id)
+        if not stats:
+            logger.warning(f"Article {article_id} not found")
+            raise HTTPException(status_code=404, detail="Article not found")
+        
+        return {
+            "success": True,
+            "liked": repos.interactions.has(user_id, article_id, 'like'),
+            "bookmarked": repos.interactions.is_saved(user_id, article_id),
+            "reactions": get_user_reactions(repos.cursor, user_id, article_id),
+            "claps": get_user_claps(repos.cursor, user_id, article_id),
+            "stats": {
+                "likes": stats['like_count'] or 0,
+                "views": stats['view_count'] or 0,
+                "shares": stats['share_count'] or 0,
+                "comments": stats['comment_count'] or 0,
+                "reactions": stats['reaction_counts'] or {},
+                "claps": stats['clap_count'] or 0
             }
+        }
                 
     except HTTPException:
         raise
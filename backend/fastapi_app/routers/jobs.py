@@ -0,0 +1,129 @@
+"""
+Background job inspection routes for FastAPI backend
+"""
+
+import sys
+import os
+import asyncio
+from fastapi import APIRouter, HTTPException, Depends, Query
+import logging
+
+sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
+
+from shared.models import JobEnqueueRequest
+from shared.jobs import celery_app, ENQUEUEABLE_JOBS, recent_failures
+from ..dependencies import get_admin_user
+
+router = APIRouter()
+logger = logging.getLogger(__name__)
+
+QUEUES = ['default', 'email', 'storage']
+
+
+def inspect_workers() -> dict:
+    inspector = celery_app.control.inspect(timeout=1.0)
+    return {
+        'stats': inspector.stats() or {},
+        'active': inspector.active() or {},
+        'reserved': inspector.reserved() or {},
+        'scheduled': inspector.scheduled() or {},
+    }
+
+
+@router.get("/")
+async def jobs_overview(admin_user: dict = Depends(get_admin_user)):
+    """Worker pool state, queue depths and recent failures (admin only)"""
+    try:
+        workers = await asyncio.to_thread(inspect_workers)
+
+        # Celery's Redis transport keeps each queue as a list named after it
+        broker = celery_app.connection_for_write()
+        try:
+            client = broker.default_channel.client
+            queue_depths = {queue: client.llen(queue) for queue in QUEUES}
+        finally:
+            broker.release()
+
+        return {
+            "success": True,
+            "workers": {
+                name: {
+                    "concurrency": stats.get('pool', {}).get('max-concurrency'),
+                    "processes": stats.get('pool', {}).get('processes', []),
+                    "total": stats.get('total', {}),
+                    "active": len(workers['active'].get(name, [])),
+                    "reserved": len(workers['reserved'].get(name, [])),
+                    "scheduled": len(workers['scheduled'].get(name, [])),
+                }
+                for name, stats in workers['stats'].items()
+            },
+            "queues": queue_depths,
+            "recent_failures": recent_failures(20),
+        }
+    except Exception as e:
+        logger.error(f"Jobs overview error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to inspect job workers")
+
+
+@router.get("/active")
+async def active_jobs(admin_user: dict = Depends(get_admin_user)):
+    """Jobs currently running, reserved or scheduled for a retry, per worker (admin only)"""
+    try:
+        workers = await asyncio.to_thread(inspect_workers)
+        workers.pop('stats')
+        return {"success": True, **workers}
+    except Exception as e:
+        logger.error(f"Active jobs error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to inspect job workers")
+
+
+@router.get("/failures")
+async def job_failures(
+    limit: int = Query(50, ge=1, le=200),
+    admin_user: dict = Depends(get_admin_user)
+):
+    """Most recent job failures and retries (admin only)"""
+    try:
+        return {"success": True, "failures": recent_failures(limit)}
+    except Exception as e:
+        logger.error(f"Job failures error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to get job failures")
+
+
+@router.get("/{task_id}")
+async def get_job(task_id: str, admin_user: dict = Depends(get_admin_user)):
+    """State and result of a single job (admin only)"""
+    try:
+        result = celery_app.AsyncResult(task_id)
+        return {
+            "success": True,
+            "id": task_id,
+            "name": result.name,
+            "state": result.state,
+            "retries": result.retries,
+            "result": result.result if result.successful() else None,
+            "error": str(result.result) if result.failed() else None,
+            "date_done": result.date_done,
+        }
+    except Exception as e:
+        logger.error(f"Get job error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to get job")
+
+
+@router.post("/")
+async def enqueue_job(request: JobEnqueueRequest, admin_user: dict = Depends(get_admin_user)):
+    """Enqueue a maintenance job by hand (admin only)"""
+    task = ENQUEUEABLE_JOBS.get(request.job)
+    if not task:
+        raise HTTPException(
+            status_code=400,
+            detail=f"Unknown job. Available: {', '.join(sorted(ENQUEUEABLE_JOBS))}"
+        )
+
+    try:
+        result = task.apply_async(args=request.args, countdown=request.countdown)
+        logger.info(f"Job {request.job} ({result.id}) enqueued by {admin_user['id']}")
+        return {"success": True, "id": result.id, "job": request.job}
+    except Exception as e:
+        logger.error(f"Enqueue job error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to enqueue job")
@@ -0,0 +1,97 @@
+"""
+Live reader routes for FastAPI backend
+"""
+
+import sys
+import os
+import json
+import uuid
+import asyncio
+from typing import Optional
+from fastapi import APIRouter, HTTPException, Query, Request, WebSocket, WebSocketDisconnect, status
+from fastapi.responses import StreamingResponse
+import logging
+
+sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
+
+from shared.database import get_postgres_cursor
+from shared.live_readers import HEARTBEAT_SECONDS, WINDOW_SECONDS, clean_reader_id, heartbeat, leave, live_stats
+
+router = APIRouter()
+logger = logging.getLogger(__name__)
+
+
+def is_published(article_id: str) -> bool:
+    with get_postgres_cursor() as cursor:
+        cursor.execute("SELECT 1 FROM articles WHERE id = %s AND status = 'published'", (article_id,))
+        return cursor.fetchone() is not None
+
+
+@router.websocket("/{article_id}/live")
+async def live_reader_socket(websocket: WebSocket, article_id: str, reader: Optional[str] = Query(None)):
+    """Count the connection as reading the article while it sends heartbeats
+
+    Send any text message (e.g. `ping`) at least every `heartbeat_seconds`;
+    each is answered with `{"type": "live", "reading_now": N}`. A connection
+    silent for longer than the window is closed. Pass the same `reader` id
+    from every tab to be counted once.
+    """
+    if not is_published(article_id):
+        await websocket.close(code=status.WS_1008_POLICY_VIOLATION)
+        return
+
+    await websocket.accept()
+    reader_id = clean_reader_id(reader) or uuid.uuid4().hex
+    try:
+        await websocket.send_json({
+            "type": "live", "reading_now": heartbeat(article_id, reader_id), "heartbeat_seconds": HEARTBEAT_SECONDS
+        })
+        while True:
+            await asyncio.wait_for(websocket.receive_text(), timeout=WINDOW_SECONDS)
+            await websocket.send_json({"type": "live", "reading_now": heartbeat(article_id, reader_id)})
+    except (WebSocketDisconnect, asyncio.TimeoutError):
+        pass
+    except Exception as e:
+        logger.error(f"Live reader socket error on {article_id}: {e}")
+        await websocket.close(code=status.WS_1011_INTERNAL_ERROR)
+    finally:
+        leave(article_id, reader_id)
+
+
+@router.get("/{article_id}/live/stream")
+async def live_reader_stream(article_id: str, request: Request, reader: Optional[str] = Query(None)):
+    """Server-sent events counting the stream as a reader while it stays open
+
+    Emits a `live` event with `{"reading_now": N}` every `heartbeat_seconds`.
+    """
+    if not is_published(article_id):
+        raise HTTPException(status_code=404, detail="Article not found")
+    reader_id = clean_reader_id(reader) or uuid.uuid4().hex
+
+    async def events():
+        try:
+            while not await request.is_disconnected():
+                count = heartbeat(article_id, reader_id)
+                yield f"event: live\ndata: {json.dumps({'reading_now': count})}\n\n"
+                await asyncio.sleep(HEARTBEAT_SECONDS)
+        finally:
+            leave(article_id, reader_id)
+
+    return StreamingResponse(events(), media_type="text/event-stream", headers={
+        "Cache-Control": "no-cache",
+        "X-Accel-Buffering": "no",
+    })
+
+
+@router.get("/{article_id}/live-stats")
+async def get_live_stats(article_id: str):
+    """How many people are reading the article now, and the most there have been at once"""
+    try:
+        if not is_published(article_id):
+            raise HTTPException(status_code=404, detail="Article not found")
+        return {"success": True, **live_stats(article_id)}
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Get live stats error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve live stats")
@@ -0,0 +1,143 @@
+"""
+Image upload routes for FastAPI backend
+"""
+
+import sys
+import os
+import asyncio
+from typing import Optional
+from fastapi import APIRouter, HTTPException, Depends, File, Form, Query, UploadFile, status
+from fastapi.responses import RedirectResponse, Response
+import logging
+
+sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
+
+from shared.database import get_postgres_cursor
+from shared.draft_collab import editable_draft
+from shared.models import MediaUploadResponse
+from shared.media_uploads import (
+    MAX_UPLOAD_BYTES, PRIVATE, PUBLIC, VARIANTS, InvalidImage,
+    create_upload, read_variant, urls, verify_signature,
+)
+from ..dependencies import get_current_user, get_optional_user
+
+router = APIRouter()
+logger = logging.getLogger(__name__)
+
+READ_CHUNK_BYTES = 64 * 1024
+
+
+def response(upload: dict) -> MediaUploadResponse:
+    return MediaUploadResponse(**upload, urls=urls(upload))
+
+
+async def read_capped(file: UploadFile) -> bytes:
+    """Read an upload, refusing it as soon as it passes the size cap rather than after buffering it all"""
+    data = bytearray()
+    while chunk := await file.read(READ_CHUNK_BYTES):
+        data.extend(chunk)
+        if len(data) > MAX_UPLOAD_BYTES:
+            raise HTTPException(
+                status_code=status.HTTP_413_REQUEST_ENTITY_TOO_LARGE,
+                detail=f"Images are limited to {MAX_UPLOAD_BYTES // (1024 * 1024)} MB"
+            )
+    return bytes(data)
+
+
+@router.post("/", response_model=MediaUploadResponse, status_code=status.HTTP_201_CREATED)
+async def upload_media(
+    file: UploadFile = File(...),
+    article_id: Optional[str] = Form(None),
+    visibility: Optional[str] = Form(None, pattern="^(public|private)$"),
+    alt_text: Optional[str] = Form(None, max_length=500),
+    current_user: dict = Depends(get_current_user)
+):
+    """Upload a JPEG, PNG, GIF or WebP image
+
+    Images attached to a draft (`article_id`) are private until the draft is
+    published, unless `visibility=public` is given; private images are
+    served through signed URLs that expire.
+    """
+    try:
+        if article_id:
+            with get_postgres_cursor() as cursor:
+                draft = editable_draft(cursor, article_id, current_user)
+                if not draft:
+                    cursor.execute("SELECT author_id FROM articles WHERE id = %s", (article_id,))
+                    article = cursor.fetchone()
+                    if not article or str(article['author_id']) != str(current_user['id']):
+                        raise HTTPException(status_code=404, detail="Article not found")
+            visibility = visibility or (PRIVATE if draft else PUBLIC)
+        visibility = visibility or PUBLIC
+
+        data = await read_capped(file)
+
+        def save():
+            with get_postgres_cursor() as cursor:
+                return create_upload(
+                    cursor, str(current_user['id']), data, file.filename, visibility, article_id, alt_text
+                )
+
+        # Decoding and re-encoding is CPU-bound; keep it off the event loop
+        return response(await asyncio.to_thread(save))
+    except HTTPException:
+        raise
+    except InvalidImage as e:
+        raise HTTPException(status_code=status.HTTP_415_UNSUPPORTED_MEDIA_TYPE, detail=str(e))
+    except Exception as e:
+        logger.error(f"Upload media error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to upload image")
+
+
+@router.get("/{media_id}", response_model=MediaUploadResponse)
+async def get_media(media_id: str, current_user: Optional[dict] = Depends(get_optional_user)):
+    """An upload's details and variant URLs; private uploads are only shown to their owner"""
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute("SELECT * FROM media_uploads WHERE id = %s", (media_id,))
+            upload = cursor.fetchone()
+        if not upload or (upload['visibility'] == PRIVATE and (
+            not current_user or str(upload['owner_id']) != str(current_user['id'])
+        )):
+            raise HTTPException(status_code=404, detail="Image not found")
+        return response(dict(upload))
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Get media error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to get image")
+
+
+@router.get("/{media_id}/{variant}")
+async def get_media_variant(
+    media_id: str,
+    variant: str,
+    expires: Optional[int] = Query(None),
+    signature: Optional[str] = Query(None)
+):
+    """One variant of an upload: a redirect to public storage, or the file itself for a valid signed URL"""
+    if variant not in VARIANTS:
+        raise HTTPException(status_code=404, detail="Image not found")
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute("SELECT * FROM media_uploads WHERE id = %s", (media_id,))
+            upload = cursor.fetchone()
+        if not upload or variant not in upload['variants']:
+            raise HTTPException(status_code=404, detail="Image not found")
+        upload = dict(upload)
+        if upload['visibility'] == PUBLIC:
+            return RedirectResponse(upload['variants'][variant]['url'], status_code=status.HTTP_302_FOUND)
+
+        if not verify_signature(media_id, variant, expires, signature):
+            raise HTTPException(status_code=403, detail="Invalid or expired image link")
+        data = await asyncio.to_thread(read_variant, upload, variant)
+        return Response(
+            content=data,
+            media_type=upload['variants'][variant]['content_type'],
+            headers={'Cache-Control': 'private, max-age=300', 'X-Content-Type-Options': 'nosniff'},
+        )
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Get media variant error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to get image")
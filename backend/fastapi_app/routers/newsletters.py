@@ -0,0 +1,103 @@
+"""
+Email digest newsletter routes for FastAPI backend
+"""
+
+import sys
+import os
+from html import escape
+from fastapi import APIRouter, HTTPException, Depends, Query
+from fastapi.responses import HTMLResponse
+import logging
+
+sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
+
+from shared.database import get_postgres_cursor
+from shared.models import NewsletterSubscriptionUpdate
+from shared.newsletters import SITE_NAME, subscribe, unsubscribe, verify_unsubscribe_token
+from ..dependencies import get_current_user
+
+router = APIRouter()
+logger = logging.getLogger(__name__)
+
+
+def page(title: str, body: str, status_code: int = 200) -> HTMLResponse:
+    return HTMLResponse(
+        f"<!DOCTYPE html><html lang=\"en\"><head><meta charset=\"utf-8\"><title>{escape(title)}</title></head>"
+        f"<body style=\"font-family:Helvetica,Arial,sans-serif;max-width:480px;margin:64px auto;\">"
+        f"<h1 style=\"font-size:20px;\">{escape(title)}</h1>{body}</body></html>",
+        status_code=status_code,
+    )
+
+
+@router.get("/me")
+async def get_newsletter_subscription(current_user: dict = Depends(get_current_user)):
+    """The caller's digest subscription, if any"""
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute("""
+                SELECT frequency, last_sent_at FROM newsletter_subscriptions
+                WHERE user_id = %s AND unsubscribed_at IS NULL
+            """, (str(current_user['id']),))
+            subscription = cursor.fetchone()
+        return {"success": True, "subscription": dict(subscription) if subscription else None}
+    except Exception as e:
+        logger.error(f"Get newsletter subscription error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to get newsletter subscription")
+
+
+@router.put("/me")
+async def update_newsletter_subscription(
+    update: NewsletterSubscriptionUpdate,
+    current_user: dict = Depends(get_current_user)
+):
+    """Subscribe to the daily or weekly digest, or switch between them"""
+    try:
+        with get_postgres_cursor() as cursor:
+            subscription = subscribe(cursor, str(current_user['id']), update.frequency)
+        return {"success": True, "subscription": subscription}
+    except Exception as e:
+        logger.error(f"Update newsletter subscription error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to update newsletter subscription")
+
+
+@router.delete("/me")
+async def delete_newsletter_subscription(current_user: dict = Depends(get_current_user)):
+    """Stop receiving digests"""
+    try:
+        with get_postgres_cursor() as cursor:
+            unsubscribe(cursor, str(current_user['id']))
+        return {"success": True, "message": "Unsubscribed from newsletters"}
+    except Exception as e:
+        logger.error(f"Delete newsletter subscription error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to unsubscribe")
+
+
+@router.get("/unsubscribe", response_class=HTMLResponse)
+async def unsubscribe_page(token: str = Query(...)):
+    """Confirmation page behind the link in every digest
+
+    Unsubscribing takes a POST, so link scanners that follow the link don't
+    unsubscribe anyone.
+    """
+    if not verify_unsubscribe_token(token):
+        return page("Invalid link", "<p>This unsubscribe link is not valid.</p>", 400)
+    return page(
+        f"Unsubscribe from {SITE_NAME}",
+        f"<p>You will no longer receive digest emails.</p>"
+        f"<form method=\"post\" action=\"?token={escape(token)}\"><button type=\"submit\">Unsubscribe</button></form>",
+    )
+
+
+@router.post("/unsubscribe", response_class=HTMLResponse)
+async def unsubscribe_with_token(token: str = Query(...)):
+    """Unsubscribe with a signed link token; mail clients POST here for one-click unsubscribe"""
+    user_id = verify_unsubscribe_token(token)
+    if not user_id:
+        return page("Invalid link", "<p>This unsubscribe link is not valid.</p>", 400)
+    try:
+        with get_postgres_cursor() as cursor:
+            unsubscribe(cursor, user_id)
+    except Exception as e:
+        logger.error(f"Newsletter unsubscribe error: {e}")
+        return page("Something went wrong", "<p>We could not unsubscribe you. Please try again.</p>", 500)
+    return page("You are unsubscribed", f"<p>You will no longer receive digest emails from {escape(SITE_NAME)}.</p>")
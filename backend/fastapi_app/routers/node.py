@@ -0,0 +1,89 @@
+"""
+Node metadata routes for FastAPI backend
+"""
+
+import sys
+import os
+from typing import Optional
+from fastapi import APIRouter, HTTPException, Query, Request
+from fastapi.responses import JSONResponse
+import logging
+
+sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
+
+from shared.database import get_postgres_cursor
+from shared.feed_formats import PUBLIC_BASE_URL
+from shared.federation import federation_payload, list_tombstones, manifest_page
+from shared.http_signatures import public_keys, sign_response
+from shared.instance_policy import public_policy
+from shared.public_feeds import SITE_NAME
+
+router = APIRouter()
+logger = logging.getLogger(__name__)
+
+
+@router.get("/")
+async def get_node_metadata():
+    """Describe this instance, including the content policy it enforces"""
+    try:
+        return JSONResponse(
+            content={
+                "name": SITE_NAME,
+                "base_url": PUBLIC_BASE_URL,
+                "software": {"name": "decentralized-news", "version": "1.0.0"},
+                "open_registrations": True,
+                "policy": public_policy(),
+                "signing_keys": public_keys(),
+            },
+            headers={"Cache-Control": "public, max-age=300"}
+        )
+    except Exception as e:
+        logger.error(f"Node metadata error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve node metadata")
+
+
+@router.get("/keys")
+async def get_signing_keys():
+    """Public keys that verify this instance's signed responses (RFC 9421)"""
+    return JSONResponse(content={"keys": public_keys()}, headers={"Cache-Control": "public, max-age=300"})
+
+
+@router.get("/manifest")
+async def get_manifest(request: Request, cursor: Optional[str] = Query(None, description="Cursor from the previous page")):
+    """This instance's published articles with content hashes, for peers to pull and revalidate"""
+    try:
+        with get_postgres_cursor() as db_cursor:
+            page = manifest_page(db_cursor, cursor)
+        return sign_response(JSONResponse(content=page), request)
+    except ValueError as e:
+        raise HTTPException(status_code=400, detail=str(e))
+    except Exception as e:
+        logger.error(f"Node manifest error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve manifest")
+
+
+@router.get("/articles/{article_id}")
+async def get_federated_article(article_id: str, request: Request):
+    """Federation payload of one of this instance's published articles"""
+    try:
+        with get_postgres_cursor() as cursor:
+            payload = federation_payload(cursor, article_id)
+        if not payload:
+            raise HTTPException(status_code=404, detail="Article not found")
+        return sign_response(JSONResponse(content=payload), request)
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Node article error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve article")
+
+
+@router.get("/tombstones")
+async def get_tombstones(limit: int = Query(50, ge=1, le=200)):
+    """Federated content this instance removed, and why"""
+    try:
+        with get_postgres_cursor() as cursor:
+            return {"tombstones": list_tombstones(cursor, limit)}
+    except Exception as e:
+        logger.error(f"Node tombstones error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve tombstones")
@@ -0,0 +1,86 @@
+"""
+Real-time notification routes for FastAPI backend
+"""
+
+import sys
+import os
+import json
+import asyncio
+from typing import Optional
+from fastapi import APIRouter, Query, WebSocket, WebSocketDisconnect, status
+import logging
+
+sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
+
+from shared.draft_collab import pubsub_client
+from shared.notifications import channel
+from ..dependencies import get_websocket_user
+
+router = APIRouter()
+logger = logging.getLogger(__name__)
+
+AUTH_TIMEOUT_SECONDS = 10
+
+
+async def relay_notifications(websocket: WebSocket, pubsub):
+    async for message in pubsub.listen():
+        if message['type'] == 'message':
+            await websocket.send_text(message['data'].decode('utf-8'))
+
+
+async def authenticate(websocket: WebSocket, token: Optional[str]) -> Optional[dict]:
+    """The user behind `token`, or behind an `{"type": "auth", "token"}` first message when no token was given"""
+    if token:
+        return get_websocket_user(token)
+    try:
+        message = json.loads(await asyncio.wait_for(websocket.receive_text(), AUTH_TIMEOUT_SECONDS))
+    except (asyncio.TimeoutError, ValueError, KeyError):
+        return None
+    if not isinstance(message, dict) or message.get('type') != 'auth':
+        return None
+    return get_websocket_user(message.get('token'))
+
+
+@router.websocket("/ws")
+async def notifications_socket(websocket: WebSocket, token: Optional[str] = Query(None)):
+    """Receive notifications as they happen
+
+    Authenticate with `?token=` or, to keep the token out of URLs, with
+    `{"type": "auth", "token": "..."}` as the first message within ten
+    seconds. The server answers `{"type": "ready"}` and then sends
+    `{"type": "notification", "id", "kind", "created_at", "data"}` messages.
+    Send `{"type": "ping"}` to get `{"type": "pong"}`.
+    """
+    await websocket.accept()
+    user = await authenticate(websocket, token)
+    if not user:
+        await websocket.close(code=status.WS_1008_POLICY_VIOLATION)
+        return
+
+    client = pubsub_client()
+    pubsub = client.pubsub()
+    relay = None
+    try:
+        await pubsub.subscribe(channel(str(user['id'])))
+        relay = asyncio.create_task(relay_notifications(websocket, pubsub))
+        await websocket.send_json({"type": "ready", "user_id": str(user['id'])})
+
+        while True:
+            try:
+                command = json.loads(await websocket.receive_text())
+            except ValueError:
+                command = None
+            if isinstance(command, dict) and command.get('type') == 'ping':
+                await websocket.send_json({"type": "pong"})
+            else:
+                await websocket.send_json({"type": "error", "detail": "Unknown command"})
+    except WebSocketDisconnect:
+        pass
+    except Exception as e:
+        logger.error(f"Notification socket error for {user['id']}: {e}")
+        await websocket.close(code=status.WS_1011_INTERNAL_ERROR)
+    finally:
+        if relay:
+            relay.cancel()
+        await pubsub.unsubscribe(channel(str(user['id'])))
+        await client.aclose()
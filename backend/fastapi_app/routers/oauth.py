@@ -0,0 +1,212 @@
+"""
+OAuth2 authorization server routes for FastAPI backend
+"""
+
+import sys
+import os
+from typing import List, Optional
+from fastapi import APIRouter, HTTPException, Depends, Form, Query, status
+from fastapi.responses import JSONResponse
+import logging
+
+sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
+
+from shared.database import get_postgres_cursor
+from shared.models import OAuthClientCreate, OAuthClientResponse, OAuthConsentDecision, OAuthGrantResponse
+from shared.oauth import (
+    SCOPES, OAuthError, approve, consent_screen, exchange_code, generate_client_credentials,
+    hash_secret, is_allowed_redirect_uri, list_grants, parse_scope, redirect_url, revoke_grant,
+    validate_authorization_request
+)
+from ..dependencies import get_current_user
+
+router = APIRouter()
+logger = logging.getLogger(__name__)
+
+
+def oauth_error_response(error: OAuthError) -> JSONResponse:
+    """RFC 6749 error body, which clients parse instead of the platform's error envelope"""
+    return JSONResponse(
+        status_code=error.status_code,
+        content={"error": error.error, "error_description": error.description},
+        headers={"Cache-Control": "no-store"}
+    )
+
+
+@router.get("/scopes")
+async def list_scopes():
+    """Scopes third-party applications can request"""
+    return {"scopes": [{"scope": scope, "description": description} for scope, description in SCOPES.items()]}
+
+
+@router.post("/clients", response_model=OAuthClientResponse, status_code=status.HTTP_201_CREATED)
+async def register_client(client_data: OAuthClientCreate, current_user: dict = Depends(get_current_user)):
+    """Register a third-party application; the client secret is only returned in this response"""
+    try:
+        scopes = parse_scope(' '.join(client_data.scopes))
+    except OAuthError as e:
+        raise HTTPException(status_code=400, detail=e.description)
+    invalid_uris = [uri for uri in client_data.redirect_uris if not is_allowed_redirect_uri(uri)]
+    if invalid_uris:
+        raise HTTPException(
+            status_code=400,
+            detail=f"Redirect URIs must be HTTPS (or HTTP to localhost): {', '.join(invalid_uris)}"
+        )
+
+    try:
+        client_id, client_secret = generate_client_credentials()
+        with get_postgres_cursor() as cursor:
+            cursor.execute("""
+                INSERT INTO oauth_clients
+                (client_id, client_secret_hash, name, description, website_url, redirect_uris, allowed_scopes, owner_id)
+                VALUES (%s, %s, %s, %s, %s, %s, %s, %s)
+                RETURNING *
+            """, (
+                client_id, hash_secret(client_secret), client_data.name, client_data.description,
+                client_data.website_url, list(dict.fromkeys(client_data.redirect_uris)), scopes, current_user['id']
+            ))
+            client = cursor.fetchone()
+
+        logger.info(f"OAuth client {client_id} registered by user {current_user['id']}")
+        return OAuthClientResponse(**dict(client), client_secret=client_secret)
+    except Exception as e:
+        logger.error(f"Register OAuth client error: {e}", exc_info=True)
+        raise HTTPException(status_code=500, detail="Failed to register application")
+
+
+@router.get("/clients", response_model=List[OAuthClientResponse])
+async def list_clients(current_user: dict = Depends(get_current_user)):
+    """List applications the caller has registered"""
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute(
+                "SELECT * FROM oauth_clients WHERE owner_id = %s ORDER BY created_at DESC",
+                (current_user['id'],)
+            )
+            clients = cursor.fetchall()
+
+        return [OAuthClientResponse(**dict(client)) for client in clients]
+    except Exception as e:
+        logger.error(f"List OAuth clients error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve applications")
+
+
+@router.delete("/clients/{client_id}")
+async def disable_client(client_id: str, current_user: dict = Depends(get_current_user)):
+    """Disable an application; every token issued to it stops working"""
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute("""
+                UPDATE oauth_clients SET is_active = false
+                WHERE client_id = %s AND (owner_id = %s OR %s)
+                RETURNING id
+            """, (client_id, current_user['id'], current_user.get('role') == 'administrator'))
+            if not cursor.fetchone():
+                raise HTTPException(status_code=404, detail="Application not found")
+
+        return {"success": True, "message": "Application disabled"}
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Disable OAuth client error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to disable application")
+
+
+@router.get("/authorize")
+async def get_consent_screen(
+    client_id: str = Query(...),
+    redirect_uri: str = Query(...),
+    response_type: str = Query("code"),
+    scope: Optional[str] = Query(None),
+    code_challenge: Optional[str] = Query(None),
+    code_challenge_method: Optional[str] = Query(None),
+    current_user: dict = Depends(get_current_user)
+):
+    """Validate an authorization request and describe what the consent screen should show"""
+    try:
+        with get_postgres_cursor() as cursor:
+            authorization = validate_authorization_request(
+                cursor, client_id, redirect_uri, scope, response_type, code_challenge, code_challenge_method
+            )
+            return consent_screen(cursor, current_user['id'], authorization)
+    except OAuthError as e:
+        return oauth_error_response(e)
+    except Exception as e:
+        logger.error(f"OAuth consent screen error: {e}", exc_info=True)
+        raise HTTPException(status_code=500, detail="Failed to load authorization request")
+
+
+@router.post("/authorize")
+async def submit_consent(decision: OAuthConsentDecision, current_user: dict = Depends(get_current_user)):
+    """Record the user's decision; the frontend sends the browser to `redirect_to`"""
+    try:
+        with get_postgres_cursor() as cursor:
+            authorization = validate_authorization_request(
+                cursor, decision.client_id, decision.redirect_uri, decision.scope,
+                code_challenge=decision.code_challenge, code_challenge_method=decision.code_challenge_method
+            )
+            if not decision.approve:
+                return {"redirect_to": redirect_url(decision.redirect_uri, {
+                    "error": "access_denied", "state": decision.state
+                })}
+
+            code = approve(cursor, current_user['id'], authorization, decision.code_challenge)
+
+        logger.info(f"User {current_user['id']} authorized OAuth client {decision.client_id}")
+        return {"redirect_to": redirect_url(decision.redirect_uri, {"code": code, "state": decision.state})}
+    except OAuthError as e:
+        return oauth_error_response(e)
+    except Exception as e:
+        logger.error(f"OAuth consent error: {e}", exc_info=True)
+        raise HTTPException(status_code=500, detail="Failed to record authorization")
+
+
+@router.post("/token")
+async def issue_token(
+    grant_type: str = Form(...),
+    code: str = Form(...),
+    redirect_uri: str = Form(...),
+    client_id: str = Form(...),
+    client_secret: str = Form(...),
+    code_verifier: Optional[str] = Form(None)
+):
+    """Token endpoint: exchange an authorization code for a scoped access token"""
+    if grant_type != 'authorization_code':
+        return oauth_error_response(OAuthError('unsupported_grant_type', "Only authorization_code is supported"))
+
+    try:
+        with get_postgres_cursor() as cursor:
+            token = exchange_code(cursor, client_id, client_secret, code, redirect_uri, code_verifier)
+        return JSONResponse(content=token, headers={"Cache-Control": "no-store"})
+    except OAuthError as e:
+        return oauth_error_response(e)
+    except Exception as e:
+        logger.error(f"OAuth token error: {e}", exc_info=True)
+        raise HTTPException(status_code=500, detail="Failed to issue token")
+
+
+@router.get("/grants", response_model=List[OAuthGrantResponse])
+async def get_grants(current_user: dict = Depends(get_current_user)):
+    """Applications the caller has authorized"""
+    try:
+        with get_postgres_cursor() as cursor:
+            return [OAuthGrantResponse(**grant) for grant in list_grants(cursor, current_user['id'])]
+    except Exception as e:
+        logger.error(f"List OAuth grants error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve authorized applications")
+
+
+@router.delete("/grants/{client_id}")
+async def revoke_application(client_id: str, current_user: dict = Depends(get_current_user)):
+    """Revoke an application's access to the caller's account"""
+    try:
+        with get_postgres_cursor() as cursor:
+            if not revoke_grant(cursor, current_user['id'], client_id):
+                raise HTTPException(status_code=404, detail="Authorization not found")
+
+        return {"success": True, "message": "Access revoked"}
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Revoke OAuth grant error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to revoke access")
@@ -0,0 +1,216 @@
+"""
+Organization and branding administration routes for FastAPI backend
+"""
+
+import sys
+import os
+from typing import List
+from fastapi import APIRouter, HTTPException, Depends, status
+import logging
+import psycopg2
+from psycopg2.extras import Json
+
+sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
+
+from shared.database import get_postgres_cursor
+from shared.models import (
+    OrganizationCreate, OrganizationUpdate, OrganizationResponse, BrandingUpdate, BrandingResponse
+)
+from shared.branding import normalize_host, purge_organization
+from ..dependencies import get_admin_user
+
+router = APIRouter()
+logger = logging.getLogger(__name__)
+
+
+def get_organization_or_404(cursor, organization_id: str) -> dict:
+    cursor.execute("SELECT * FROM organizations WHERE id = %s", (organization_id,))
+    organization = cursor.fetchone()
+    if not organization:
+        raise HTTPException(status_code=404, detail="Organization not found")
+    return dict(organization)
+
+
+@router.get("/", response_model=List[OrganizationResponse])
+async def list_organizations(admin_user: dict = Depends(get_admin_user)):
+    """List organizations (admin only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute("SELECT * FROM organizations ORDER BY name")
+            return [OrganizationResponse(**dict(row)) for row in cursor.fetchall()]
+    except Exception as e:
+        logger.error(f"List organizations error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve organizations")
+
+
+@router.post("/", response_model=OrganizationResponse, status_code=status.HTTP_201_CREATED)
+async def create_organization(organization_data: OrganizationCreate, admin_user: dict = Depends(get_admin_user)):
+    """Create an organization, optionally on a custom domain (admin only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute("""
+                INSERT INTO organizations (slug, name, custom_domain, created_by)
+                VALUES (%s, %s, %s, %s)
+                RETURNING *
+            """, (
+                organization_data.slug, organization_data.name,
+                normalize_host(organization_data.custom_domain), admin_user['id']
+            ))
+            organization = cursor.fetchone()
+
+        if organization['custom_domain']:
+            purge_organization(str(organization['id']), domain_changed=True)
+        return OrganizationResponse(**dict(organization))
+    except psycopg2.errors.UniqueViolation:
+        raise HTTPException(status_code=409, detail="Slug or custom domain already in use")
+    except Exception as e:
+        logger.error(f"Create organization error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to create organization")
+
+
+@router.get("/{organization_id}", response_model=OrganizationResponse)
+async def get_organization(organization_id: str, admin_user: dict = Depends(get_admin_user)):
+    """Get an organization (admin only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            return OrganizationResponse(**get_organization_or_404(cursor, organization_id))
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Get organization error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve organization")
+
+
+@router.patch("/{organization_id}", response_model=OrganizationResponse)
+async def update_organization(organization_id: str, update: OrganizationUpdate,
+                              admin_user: dict = Depends(get_admin_user)):
+    """Rename, move or deactivate an organization (admin only)"""
+    update_data = update.dict(exclude_unset=True)
+    if not update_data:
+        raise HTTPException(status_code=400, detail="No valid fields to update")
+    if 'custom_domain' in update_data:
+        update_data['custom_domain'] = normalize_host(update_data['custom_domain'])
+
+    try:
+        with get_postgres_cursor() as cursor:
+            existing = get_organization_or_404(cursor, organization_id)
+            assignments = ', '.join(f"{field} = %s" for field in update_data)
+            cursor.execute(
+                f"UPDATE organizations SET {assignments} WHERE id = %s RETURNING *",
+                list(update_data.values()) + [organization_id]
+            )
+            organization = cursor.fetchone()
+
+        purge_organization(
+            organization_id,
+            domain_changed=existing['custom_domain'] != organization['custom_domain']
+            or existing['is_active'] != organization['is_active']
+        )
+        return OrganizationResponse(**dict(organization))
+    except HTTPException:
+        raise
+    except psycopg2.errors.UniqueViolation:
+        raise HTTPException(status_code=409, detail="Custom domain already in use")
+    except Exception as e:
+        logger.error(f"Update organization error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to update organization")
+
+
+@router.delete("/{organization_id}")
+async def delete_organization(organization_id: str, admin_user: dict = Depends(get_admin_user)):
+    """Delete an organization and its branding (admin only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute("DELETE FROM organizations WHERE id = %s RETURNING id", (organization_id,))
+            if not cursor.fetchone():
+                raise HTTPException(status_code=404, detail="Organization not found")
+
+        purge_organization(organization_id, domain_changed=True)
+        return {"success": True, "message": "Organization deleted"}
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Delete organization error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to delete organization")
+
+
+@router.get("/{organization_id}/branding", response_model=BrandingResponse)
+async def get_organization_branding(organization_id: str, admin_user: dict = Depends(get_admin_user)):
+    """Get an organization's branding (admin only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            organization = get_organization_or_404(cursor, organization_id)
+            cursor.execute("SELECT * FROM organization_branding WHERE organization_id = %s", (organization_id,))
+            branding = cursor.fetchone()
+
+        return BrandingResponse(
+            organization={'slug': organization['slug'], 'name': organization['name']},
+            **({
+                key: branding[key] for key in ('logo_url', 'favicon_url', 'colors', 'footer_links', 'updated_at')
+            } if branding else {})
+        )
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Get branding error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve branding")
+
+
+@router.put("/{organization_id}/branding", response_model=BrandingResponse)
+async def update_organization_branding(organization_id: str, branding_data: BrandingUpdate,
+                                       admin_user: dict = Depends(get_admin_user)):
+    """Replace an organization's branding (admin only)"""
+    colors = branding_data.colors.dict(exclude_none=True)
+    footer_links = [link.dict() for link in branding_data.footer_links]
+
+    try:
+        with get_postgres_cursor() as cursor:
+            organization = get_organization_or_404(cursor, organization_id)
+            cursor.execute("""
+                INSERT INTO organization_branding
+                (organization_id, logo_url, favicon_url, colors, footer_links, updated_by)
+                VALUES (%s, %s, %s, %s, %s, %s)
+                ON CONFLICT (organization_id) DO UPDATE SET
+                    logo_url = EXCLUDED.logo_url,
+                    favicon_url = EXCLUDED.favicon_url,
+                    colors = EXCLUDED.colors,
+                    footer_links = EXCLUDED.footer_links,
+                    updated_by = EXCLUDED.updated_by
+                RETURNING *
+            """, (
+                organization_id, branding_data.logo_url, branding_data.favicon_url,
+                colors, Json(footer_links), admin_user['id']
+            ))
+            branding = cursor.fetchone()
+
+        purge_organization(organization_id)
+        return BrandingResponse(
+            organization={'slug': organization['slug'], 'name': organization['name']},
+            logo_url=branding['logo_url'],
+            favicon_url=branding['favicon_url'],
+            colors=branding['colors'],
+            footer_links=branding['footer_links'],
+            updated_at=branding['updated_at']
+        )
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Update branding error: {e}", exc_info=True)
+        raise HTTPException(status_code=500, detail="Failed to update branding")
+
+
+@router.delete("/{organization_id}/branding")
+async def reset_organization_branding(organization_id: str, admin_user: dict = Depends(get_admin_user)):
+    """Remove an organization's branding so it falls back to the default (admin only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            get_organization_or_404(cursor, organization_id)
+            cursor.execute("DELETE FROM organization_branding WHERE organization_id = %s", (organization_id,))
+
+        purge_organization(organization_id)
+        return {"success": True, "message": "Branding reset"}
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Reset branding error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to reset branding")
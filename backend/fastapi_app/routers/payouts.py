@@ -0,0 +1,86 @@
+"""
+Payout administration routes for FastAPI backend
+"""
+
+import sys
+import os
+from typing import Optional
+from fastapi import APIRouter, HTTPException, Depends, Query
+import logging
+
+sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
+
+from shared.database import get_postgres_cursor
+from shared.ledger import trial_balance
+from shared.models import PayoutReview
+from shared.tipping import list_payouts, review_payout
+from ..dependencies import get_admin_user
+
+router = APIRouter()
+logger = logging.getLogger(__name__)
+
+
+@router.get("/")
+async def get_payouts(
+    payout_status: Optional[str] = Query("requested", alias="status", pattern="^(requested|paid|rejected)$"),
+    author_id: Optional[str] = Query(None),
+    limit: int = Query(50, ge=1, le=200),
+    offset: int = Query(0, ge=0),
+    admin_user: dict = Depends(get_admin_user)
+):
+    """Payout requests, newest first; outstanding ones by default (admin only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            payouts = list_payouts(cursor, author_id=author_id, status=payout_status, limit=limit, offset=offset)
+        return {"success": True, "payouts": payouts}
+    except Exception as e:
+        logger.error(f"List payouts error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve payouts")
+
+
+@router.post("/{payout_id}/paid")
+async def mark_payout_paid(payout_id: str, review: PayoutReview, admin_user: dict = Depends(get_admin_user)):
+    """Record that a requested payout was sent, with the transfer's reference (admin only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            payout = review_payout(cursor, payout_id, True, str(admin_user['id']), review.external_ref, review.note)
+        if not payout:
+            raise HTTPException(status_code=404, detail="No outstanding payout request with this id")
+
+        logger.info(f"Payout {payout_id} marked paid by {admin_user['id']}")
+        return {"success": True, "payout": payout}
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Mark payout paid error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to update payout")
+
+
+@router.post("/{payout_id}/reject")
+async def reject_payout(payout_id: str, review: PayoutReview, admin_user: dict = Depends(get_admin_user)):
+    """Reject a requested payout, returning the amount to the author's available balance (admin only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            payout = review_payout(cursor, payout_id, False, str(admin_user['id']), None, review.note)
+        if not payout:
+            raise HTTPException(status_code=404, detail="No outstanding payout request with this id")
+
+        logger.info(f"Payout {payout_id} rejected by {admin_user['id']}")
+        return {"success": True, "payout": payout}
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Reject payout error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to update payout")
+
+
+@router.get("/ledger")
+async def get_ledger_balance(admin_user: dict = Depends(get_admin_user)):
+    """Ledger totals per currency and account kind, and whether each currency nets to zero (admin only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            currencies = trial_balance(cursor)
+        return {"success": True, "balanced": all(c['balanced'] for c in currencies), "currencies": currencies}
+    except Exception as e:
+        logger.error(f"Ledger trial balance error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to compute ledger balance")
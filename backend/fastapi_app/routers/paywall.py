@@ -0,0 +1,127 @@
+"""
+Premium article routes for FastAPI backend
+"""
+
+import sys
+import os
+from typing import Optional
+from fastapi import APIRouter, HTTPException, Depends
+import logging
+
+sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
+
+from shared.database import get_postgres_cursor
+from shared.models import ArticleAccessPolicy, ArticlePurchaseCreate
+from shared.paywall import (
+    PaymentInvalid, Viewer, access_status, current_viewer, has_purchased, record_purchase, refresh_public_copies,
+    set_policy
+)
+from shared import permissions
+from ..dependencies import get_current_user, get_optional_user, require_scopes
+
+router = APIRouter()
+logger = logging.getLogger(__name__)
+
+
+def get_article_for_author(cursor, article_id: str, current_user: dict) -> dict:
+    cursor.execute("SELECT * FROM articles WHERE id = %s FOR UPDATE", (article_id,))
+    article = cursor.fetchone()
+    if not article:
+        raise HTTPException(status_code=404, detail="Article not found")
+    if not permissions.can_edit_article(current_user, article):
+        raise HTTPException(status_code=403, detail="Not authorized to change this article's access")
+    return dict(article)
+
+
+@router.get("/{article_id}/access")
+async def get_article_access(article_id: str, current_user: Optional[dict] = Depends(get_optional_user)):
+    """Whether the article is premium, what unlocks it and whether the caller has access"""
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute(
+                "SELECT id, author_id, access_policy FROM articles WHERE id = %s AND status = 'published'",
+                (article_id,)
+            )
+            article = cursor.fetchone()
+        if not article:
+            raise HTTPException(status_code=404, detail="Article not found")
+        return {"success": True, **access_status(dict(article), current_viewer())}
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Get article access error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to check article access")
+
+
+@router.put("/{article_id}/access-policy")
+async def set_access_policy(article_id: str, policy: ArticleAccessPolicy,
+                            current_user: dict = Depends(require_scopes('articles:write'))):
+    """Make the article premium (author or `article:edit_any`)
+
+    Any of a subscription tier, token balance or purchase unlocks it.
+    """
+    try:
+        with get_postgres_cursor() as cursor:
+            get_article_for_author(cursor, article_id, current_user)
+            article = set_policy(cursor, article_id, policy.model_dump(mode='json', exclude_none=True))
+        refresh_public_copies(article)
+
+        logger.info(f"Article {article_id} made premium by {current_user['id']}")
+        return {"success": True, "article_id": article_id, "access_policy": article['access_policy']}
+    except HTTPException:
+        raise
+    except ValueError as e:
+        raise HTTPException(status_code=400, detail=str(e))
+    except Exception as e:
+        logger.error(f"Set access policy error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to set access policy")
+
+
+@router.delete("/{article_id}/access-policy")
+async def remove_access_policy(article_id: str, current_user: dict = Depends(require_scopes('articles:write'))):
+    """Make the article free to read again (author or `article:edit_any`)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            get_article_for_author(cursor, article_id, current_user)
+            article = set_policy(cursor, article_id, None)
+        refresh_public_copies(article)
+
+        return {"success": True, "article_id": article_id, "access_policy": None}
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Remove access policy error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to remove access policy")
+
+
+@router.post("/{article_id}/purchase")
+async def purchase_article(article_id: str, purchase: ArticlePurchaseCreate,
+                           current_user: dict = Depends(get_current_user)):
+    """Unlock the article with an on-chain payment of its price from the caller's wallet
+
+    Send the article's `price` in the chain's native currency from your
+    account's wallet address to the author's, then submit the transaction
+    hash once it has PAYWALL_MIN_CONFIRMATIONS confirmations.
+    """
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute("SELECT * FROM articles WHERE id = %s AND status = 'published'", (article_id,))
+            article = cursor.fetchone()
+            if not article:
+                raise HTTPException(status_code=404, detail="Article not found")
+            if has_purchased(str(current_user['id']), article_id):
+                raise HTTPException(status_code=409, detail="You have already bought this article")
+            recorded = record_purchase(cursor, dict(article), current_user, purchase.transaction_hash)
+
+        return {
+            "success": True,
+            "purchase": {**recorded, "amount_wei": str(recorded['amount_wei'])},
+            **access_status(dict(article), Viewer(current_user))
+        }
+    except HTTPException:
+        raise
+    except PaymentInvalid as e:
+        raise HTTPException(status_code=400, detail=str(e))
+    except Exception as e:
+        logger.error(f"Purchase article error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to record purchase")
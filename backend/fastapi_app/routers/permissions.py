@@ -0,0 +1,153 @@
+"""
+Permission management routes for FastAPI backend
+"""
+
+import sys
+import os
+from fastapi import APIRouter, HTTPException, Depends, Path
+import logging
+
+sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
+
+from shared.database import get_postgres_cursor
+from shared.models import UserPermissionUpdate, UserPermissionsResponse
+from shared.permissions import (
+    PERMISSIONS, PERMISSIONS_MANAGE, ROLES, UnknownPermission, role_grants, set_role_permission, set_user_permission,
+    user_grants
+)
+from shared import tenancy
+from ..dependencies import require_permission
+
+router = APIRouter()
+logger = logging.getLogger(__name__)
+
+ROLE_PATTERN = f"^({'|'.join(ROLES)})$"
+
+
+async def get_role_manager(manager: dict = Depends(require_permission(PERMISSIONS_MANAGE))) -> dict:
+    """Role permissions apply to every publication, so only the default publication's managers change them"""
+    if tenancy.current():
+        raise HTTPException(status_code=403, detail="Only the default publication can change role permissions")
+    return manager
+
+
+def refuse_self_lockout(permission: str):
+    if permission == PERMISSIONS_MANAGE:
+        raise HTTPException(status_code=400, detail="Cannot take away your own permission to manage permissions")
+
+
+@router.get("/")
+async def list_permissions(manager: dict = Depends(require_permission(PERMISSIONS_MANAGE))):
+    """Every permission, and which each role holds (`permissions:manage`)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            roles = role_grants(cursor)
+        return {
+            "success": True,
+            "permissions": [{"name": name, "description": description} for name, description in PERMISSIONS.items()],
+            "roles": roles,
+        }
+    except Exception as e:
+        logger.error(f"List permissions error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve permissions")
+
+
+@router.put("/roles/{role}/{permission}")
+async def grant_role_permission(role: str = Path(..., pattern=ROLE_PATTERN), permission: str = Path(...),
+                                manager: dict = Depends(get_role_manager)):
+    """Give every user with a role a permission (`permissions:manage`, default publication)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            changed = set_role_permission(cursor, role, permission, True, manager)
+            roles = role_grants(cursor)
+        if changed:
+            logger.info(f"Permission {permission} granted to role {role} by {manager['id']}")
+        return {"success": True, "changed": changed, "roles": roles}
+    except UnknownPermission as e:
+        raise HTTPException(status_code=400, detail=str(e))
+    except Exception as e:
+        logger.error(f"Grant role permission error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to grant permission")
+
+
+@router.delete("/roles/{role}/{permission}")
+async def revoke_role_permission(role: str = Path(..., pattern=ROLE_PATTERN), permission: str = Path(...),
+                                 manager: dict = Depends(get_role_manager)):
+    """Take a permission away from a role; users granted it themselves keep it
+
+    Needs `permissions:manage` in the default publication.
+    """
+    if role == manager.get('role'):
+        refuse_self_lockout(permission)
+    try:
+        with get_postgres_cursor() as cursor:
+            changed = set_role_permission(cursor, role, permission, False, manager)
+            roles = role_grants(cursor)
+        if changed:
+            logger.info(f"Permission {permission} revoked from role {role} by {manager['id']}")
+        return {"success": True, "changed": changed, "roles": roles}
+    except UnknownPermission as e:
+        raise HTTPException(status_code=400, detail=str(e))
+    except Exception as e:
+        logger.error(f"Revoke role permission error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to revoke permission")
+
+
+@router.get("/users/{user_id}", response_model=UserPermissionsResponse)
+async def get_user_permissions(user_id: str, manager: dict = Depends(require_permission(PERMISSIONS_MANAGE))):
+    """A user's role, their own grants and revocations, and what they hold in the end (`permissions:manage`)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            grants = user_grants(cursor, user_id)
+        if not grants:
+            raise HTTPException(status_code=404, detail="User not found")
+        return UserPermissionsResponse(**grants)
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Get user permissions error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve user permissions")
+
+
+@router.put("/users/{user_id}/{permission}", response_model=UserPermissionsResponse)
+async def set_user_permission_override(user_id: str, permission: str, update: UserPermissionUpdate,
+                                       manager: dict = Depends(require_permission(PERMISSIONS_MANAGE))):
+    """Grant a user a permission their role lacks, or revoke one it gives them (`permissions:manage`)"""
+    if not update.granted and user_id == str(manager['id']):
+        refuse_self_lockout(permission)
+    try:
+        with get_postgres_cursor() as cursor:
+            if not set_user_permission(cursor, user_id, permission, update.granted, manager):
+                raise HTTPException(status_code=404, detail="User not found")
+            grants = user_grants(cursor, user_id)
+        logger.info(f"Permission {permission} {'granted to' if update.granted else 'revoked from'} "
+                    f"user {user_id} by {manager['id']}")
+        return UserPermissionsResponse(**grants)
+    except HTTPException:
+        raise
+    except UnknownPermission as e:
+        raise HTTPException(status_code=400, detail=str(e))
+    except Exception as e:
+        logger.error(f"Set user permission error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to update user permissions")
+
+
+@router.delete("/users/{user_id}/{permission}", response_model=UserPermissionsResponse)
+async def clear_user_permission_override(user_id: str, permission: str,
+                                         manager: dict = Depends(require_permission(PERMISSIONS_MANAGE))):
+    """Drop a user's own grant or revocation, so their role decides again (`permissions:manage`)"""
+    if user_id == str(manager['id']):
+        refuse_self_lockout(permission)
+    try:
+        with get_postgres_cursor() as cursor:
+            if not set_user_permission(cursor, user_id, permission, None, manager):
+                raise HTTPException(status_code=404, detail="User not found")
+            grants = user_grants(cursor, user_id)
+        return UserPermissionsResponse(**grants)
+    except HTTPException:
+        raise
+    except UnknownPermission as e:
+        raise HTTPException(status_code=400, detail=str(e))
+    except Exception as e:
+        logger.error(f"Clear user permission error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to update user permissions")
@@ -0,0 +1,82 @@
+"""
+Public RSS and JSON Feed routes for FastAPI backend
+"""
+
+import sys
+import os
+from typing import Optional
+from fastapi import APIRouter, HTTPException, Request
+from fastapi.responses import Response
+import logging
+
+sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
+
+from shared.edge_cache import FEED_SURROGATE_KEY, surrogate_headers
+from shared.feed_formats import FEED_FORMATS
+from shared.public_feeds import CACHE_TTL_SECONDS, load_feed
+
+router = APIRouter()
+logger = logging.getLogger(__name__)
+
+
+def feed_response(request: Request, feed_format: str, scope: str, value: Optional[str] = None) -> Response:
+    """Render one of the public feeds in the requested format"""
+    try:
+        feed = load_feed(scope, value)
+        if feed is None:
+            raise HTTPException(status_code=404, detail="Feed not found")
+
+        media_type, render = FEED_FORMATS[feed_format]
+        body = render(
+            title=feed['title'],
+            link=feed['link'],
+            description=feed['description'],
+            articles=feed['articles'],
+            self_url=str(request.url)
+        )
+        return Response(
+            content=body,
+            media_type=media_type,
+            headers=surrogate_headers([FEED_SURROGATE_KEY], CACHE_TTL_SECONDS, CACHE_TTL_SECONDS)
+        )
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Render {scope} feed error: {e}", exc_info=True)
+        raise HTTPException(status_code=500, detail="Failed to render feed")
+
+
+@router.get("/feed.json")
+async def site_json_feed(request: Request):
+    """JSON Feed 1.1 of the latest articles"""
+    return feed_response(request, 'json', 'all')
+
+
+@router.get("/rss.xml")
+async def site_rss_feed(request: Request):
+    """RSS 2.0 feed of the latest articles"""
+    return feed_response(request, 'rss', 'all')
+
+
+@router.get("/tags/{tag}/feed.json")
+async def tag_json_feed(tag: str, request: Request):
+    """JSON Feed 1.1 of the latest articles with a tag"""
+    return feed_response(request, 'json', 'tag', tag)
+
+
+@router.get("/tags/{tag}/rss.xml")
+async def tag_rss_feed(tag: str, request: Request):
+    """RSS 2.0 feed of the latest articles with a tag"""
+    return feed_response(request, 'rss', 'tag', tag)
+
+
+@router.get("/authors/{author_id}/feed.json")
+async def author_json_feed(author_id: str, request: Request):
+    """JSON Feed 1.1 of an author's latest articles"""
+    return feed_response(request, 'json', 'author', author_id)
+
+
+@router.get("/authors/{author_id}/rss.xml")
+async def author_rss_feed(author_id: str, request: Request):
+    """RSS 2.0 feed of an author's latest articles"""
+    return feed_response(request, 'rss', 'author', author_id)
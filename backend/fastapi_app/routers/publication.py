@@ -0,0 +1,178 @@
+"""
+Publication member, invitation, analytics and branding routes for FastAPI backend
+"""
+
+import sys
+import os
+from typing import List, Optional
+from fastapi import APIRouter, HTTPException, Depends, Query, status
+import logging
+from psycopg2.extras import Json
+
+sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
+
+from shared.database import get_postgres_cursor, query_timeout
+from shared.models import (
+    BrandingUpdate, InvitationAccept, InvitationCreate, InvitationResponse, MemberResponse, MemberRoleUpdate,
+    UserResponse
+)
+from shared.permissions import ANALYTICS_READ, MEMBERS_MANAGE, SETTINGS_MANAGE
+from shared.repositories import Repositories
+from shared.audit import record_privileged
+from shared.branding import purge_tenant
+from shared import members, tenancy
+from ..dependencies import get_current_user, get_repositories, require_permission
+
+router = APIRouter()
+logger = logging.getLogger(__name__)
+
+
+def refuse_own_membership(user_id: str, manager: dict):
+    if user_id == str(manager['id']):
+        raise HTTPException(status_code=400, detail="Cannot change your own membership")
+
+
+@router.get("/members", response_model=List[MemberResponse])
+async def list_members(role: Optional[str] = Query(None, pattern=r'^(editor|contributor)$'),
+                       repos: Repositories = Depends(get_repositories),
+                       manager: dict = Depends(require_permission(MEMBERS_MANAGE))):
+    """The publication's editors and contributors (`members:manage`)"""
+    try:
+        return [MemberResponse(**member) for member in repos.users.members(role)]
+    except Exception as e:
+        logger.error(f"List members error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve members")
+
+
+@router.put("/members/{user_id}", response_model=MemberResponse)
+async def set_member_role(user_id: str, update: MemberRoleUpdate, repos: Repositories = Depends(get_repositories),
+                          manager: dict = Depends(require_permission(MEMBERS_MANAGE))):
+    """Make one of the publication's users an editor or contributor (`members:manage`)"""
+    refuse_own_membership(user_id, manager)
+    try:
+        member = members.set_member_role(repos, user_id, update.role, manager)
+        if not member:
+            raise HTTPException(status_code=404, detail="User not found")
+        logger.info(f"User {user_id} made {update.role} by {manager['id']}")
+        return MemberResponse(**member)
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Set member role error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to update member")
+
+
+@router.delete("/members/{user_id}")
+async def remove_member(user_id: str, repos: Repositories = Depends(get_repositories),
+                        manager: dict = Depends(require_permission(MEMBERS_MANAGE))):
+    """End a user's membership; their account and articles stay (`members:manage`)"""
+    refuse_own_membership(user_id, manager)
+    try:
+        if not members.set_member_role(repos, user_id, None, manager):
+            raise HTTPException(status_code=404, detail="User not found")
+        logger.info(f"Membership of {user_id} ended by {manager['id']}")
+        return {"success": True, "message": "Membership ended"}
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Remove member error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to remove member")
+
+
+@router.get("/invitations", response_model=List[InvitationResponse])
+async def list_invitations(pending_only: bool = True, manager: dict = Depends(require_permission(MEMBERS_MANAGE))):
+    """Invitations to the publication, newest first; pending ones unless `pending_only` is false (`members:manage`)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            return [InvitationResponse(**row) for row in members.list_invitations(cursor, pending_only)]
+    except Exception as e:
+        logger.error(f"List invitations error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve invitations")
+
+
+@router.post("/invitations", response_model=InvitationResponse, status_code=status.HTTP_201_CREATED)
+async def create_invitation(invitation_data: InvitationCreate,
+                            manager: dict = Depends(require_permission(MEMBERS_MANAGE))):
+    """Email an invitation to join the publication as an editor or contributor (`members:manage`)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            invitation, token = members.invite(cursor, invitation_data.email, invitation_data.role, manager)
+        members.send_invitation(invitation, token, manager)
+        logger.info(f"Invitation {invitation['id']} as {invitation['role']} sent by {manager['id']}")
+        return InvitationResponse(**invitation)
+    except members.MemberError as e:
+        raise HTTPException(status_code=409, detail=str(e))
+    except Exception as e:
+        logger.error(f"Create invitation error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to send invitation")
+
+
+@router.delete("/invitations/{invitation_id}", response_model=InvitationResponse)
+async def revoke_invitation(invitation_id: str, manager: dict = Depends(require_permission(MEMBERS_MANAGE))):
+    """Withdraw a pending invitation (`members:manage`)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            revoked = members.revoke_invitation(cursor, invitation_id, manager)
+        if not revoked:
+            raise HTTPException(status_code=404, detail="Pending invitation not found")
+        return InvitationResponse(**revoked)
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Revoke invitation error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to revoke invitation")
+
+
+@router.post("/invitations/accept", response_model=UserResponse)
+async def accept_invitation(acceptance: InvitationAccept, repos: Repositories = Depends(get_repositories),
+                            current_user: dict = Depends(get_current_user)):
+    """Join the publication with the role an invitation to your email address offers"""
+    try:
+        return UserResponse(**members.accept(repos, acceptance.token, current_user))
+    except LookupError as e:
+        raise HTTPException(status_code=404, detail=str(e))
+    except members.MemberError as e:
+        raise HTTPException(status_code=403, detail=str(e))
+    except Exception as e:
+        logger.error(f"Accept invitation error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to accept invitation")
+
+
+@router.get("/analytics")
+async def publication_analytics(days: int = Query(30, ge=1, le=365),
+                                current_user: dict = Depends(require_permission(ANALYTICS_READ))):
+    """The publication's members, articles, readership and top articles and authors (`analytics:read`)"""
+    try:
+        with get_postgres_cursor(timeout_ms=query_timeout('analytics')) as cursor:
+            return {"success": True, "analytics": members.analytics(cursor, days)}
+    except Exception as e:
+        logger.error(f"Publication analytics error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to get publication analytics")
+
+
+@router.put("/branding")
+async def update_branding(branding_data: BrandingUpdate,
+                          manager: dict = Depends(require_permission(SETTINGS_MANAGE))):
+    """Replace the publication's logo, colors and footer links (`settings:manage`)"""
+    tenant = tenancy.current()
+    if not tenant:
+        raise HTTPException(status_code=400, detail="The default publication uses the platform's branding")
+    branding = branding_data.model_dump()
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute(
+                "UPDATE tenants SET branding = %s, updated_at = NOW() WHERE id = %s RETURNING branding",
+                (Json(branding), tenant['id'])
+            )
+            updated = cursor.fetchone()
+            record_privileged(
+                cursor, manager, 'tenant_updated', 'tenant', tenant['id'],
+                before={'branding': tenant.get('branding')}, after={'branding': updated['branding']}
+            )
+        tenancy.invalidate()
+        purge_tenant(str(tenant['id']))
+        logger.info(f"Branding of tenant {tenant['slug']} updated by {manager['id']}")
+        return {"success": True, "branding": updated['branding']}
+    except Exception as e:
+        logger.error(f"Update publication branding error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to update branding")
@@ -0,0 +1,139 @@
+"""
+Push notification device and topic routes for FastAPI backend
+"""
+
+import sys
+import os
+from typing import List
+from fastapi import APIRouter, HTTPException, Depends, Path, status
+import logging
+
+sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
+
+from shared.database import get_postgres_cursor
+from shared.models import PushDeviceCreate, PushDeviceResponse
+from shared.push import VAPID_PUBLIC_KEY, WEBPUSH, PushNotConfigured, platforms, register_device
+from ..dependencies import get_current_user
+
+router = APIRouter()
+logger = logging.getLogger(__name__)
+
+DEVICE_COLUMNS = "id, platform, label, failure_count, last_success_at, last_seen_at, created_at"
+
+
+@router.get("/config")
+async def push_config():
+    """Platforms this node delivers to and the VAPID public key browsers subscribe with"""
+    configured = platforms()
+    return {
+        "success": True,
+        "platforms": configured,
+        "vapid_public_key": VAPID_PUBLIC_KEY if WEBPUSH in configured else None,
+    }
+
+
+@router.get("/devices", response_model=List[PushDeviceResponse])
+async def list_devices(current_user: dict = Depends(get_current_user)):
+    """Devices registered to receive the caller's notifications"""
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute(
+                f"SELECT {DEVICE_COLUMNS} FROM push_devices WHERE user_id = %s ORDER BY last_seen_at DESC",
+                (str(current_user['id']),)
+            )
+            return [PushDeviceResponse(**dict(row)) for row in cursor.fetchall()]
+    except Exception as e:
+        logger.error(f"List push devices error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to get push devices")
+
+
+@router.post("/devices", response_model=PushDeviceResponse, status_code=status.HTTP_201_CREATED)
+async def add_device(device_data: PushDeviceCreate, current_user: dict = Depends(get_current_user)):
+    """Register a browser push subscription or an FCM token
+
+    Clients should register again on every start, and whenever the token or
+    subscription changes; devices not registered for PUSH_DEVICE_MAX_AGE_DAYS
+    are dropped.
+    """
+    if device_data.platform == WEBPUSH:
+        token = device_data.subscription.endpoint
+        keys = device_data.subscription.keys.dict()
+    else:
+        token, keys = device_data.token, None
+    try:
+        with get_postgres_cursor() as cursor:
+            device = register_device(cursor, str(current_user['id']), device_data.platform, token, keys, device_data.label)
+        return PushDeviceResponse(**device)
+    except PushNotConfigured as e:
+        raise HTTPException(status_code=400, detail=str(e))
+    except Exception as e:
+        logger.error(f"Register push device error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to register push device")
+
+
+@router.delete("/devices/{device_id}")
+async def remove_device(device_id: str, current_user: dict = Depends(get_current_user)):
+    """Stop pushing to a device, e.g. on sign-out"""
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute(
+                "DELETE FROM push_devices WHERE id = %s AND user_id = %s RETURNING id",
+                (device_id, str(current_user['id']))
+            )
+            if not cursor.fetchone():
+                raise HTTPException(status_code=404, detail="Push device not found")
+        return {"success": True, "message": "Push device removed"}
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Remove push device error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to remove push device")
+
+
+@router.get("/topics")
+async def list_topics(current_user: dict = Depends(get_current_user)):
+    """Categories the caller gets a push for when an article is published in them"""
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute(
+                "SELECT category, created_at FROM push_topic_subscriptions WHERE user_id = %s ORDER BY category",
+                (str(current_user['id']),)
+            )
+            return {"success": True, "topics": [dict(row) for row in cursor.fetchall()]}
+    except Exception as e:
+        logger.error(f"List push topics error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to get push topics")
+
+
+@router.put("/topics/{category}")
+async def subscribe_topic(
+    category: str = Path(..., min_length=1, max_length=100),
+    current_user: dict = Depends(get_current_user)
+):
+    """Get a push for every article published in a category"""
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute("""
+                INSERT INTO push_topic_subscriptions (user_id, category)
+                VALUES (%s, %s)
+                ON CONFLICT (user_id, category) DO NOTHING
+            """, (str(current_user['id']), category.lower()))
+        return {"success": True, "subscribed": True, "category": category.lower()}
+    except Exception as e:
+        logger.error(f"Subscribe push topic error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to subscribe to topic")
+
+
+@router.delete("/topics/{category}")
+async def unsubscribe_topic(category: str, current_user: dict = Depends(get_current_user)):
+    """Stop pushes for a category"""
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute(
+                "DELETE FROM push_topic_subscriptions WHERE user_id = %s AND category = %s",
+                (str(current_user['id']), category.lower())
+            )
+        return {"success": True, "subscribed": False, "category": category.lower()}
+    except Exception as e:
+        logger.error(f"Unsubscribe push topic error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to unsubscribe from topic")
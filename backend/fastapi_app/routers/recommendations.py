@@ -11,7 +11,7 @@ import json
 
 sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
 
-from shared.database import get_postgres_cursor, get_redis
+from shared.database import get_postgres_cursor, query_timeout, get_redis
 from shared.models import RecommendationRequest, RecommendationResponse, ArticleResponse
 from shared.utils import cache_key_generator
 from ..dependencies import get_current_user
@@ -40,7 +40,7 @@ async def get_recommendations(req_data: RecommendationRequest, current_user: dic
             logger.warning(f"Redis cache error: {redis_error}")
         
         # Get recommendations from database
-        with get_postgres_cursor() as cursor:
+        with get_postgres_cursor(timeout_ms=query_timeout('recommendations')) as cursor:
             # Check cached recommendations
             cursor.execute("""
                 SELECT recommended_articles, recommendation_scores, model_ensemble, cache_timestamp, expiry_timestamp
@@ -120,7 +120,7 @@ async def get_recommendations(req_data: RecommendationRequest, current_user: dic
 async def get_trending_topics():
     """Get trending topics and tags"""
     try:
-        with get_postgres_cursor() as cursor:
+        with get_postgres_cursor(timeout_ms=query_timeout('recommendations')) as cursor:
             # Get trending tags from recent articles
             cursor.execute("""
                 SELECT 
@@ -164,7 +164,7 @@ async def get_reading_history(current_user: dict = Depends(get_current_user)):
     try:
         user_id = current_user['id']
         
-        with get_postgres_cursor() as cursor:
+        with get_postgres_cursor(timeout_ms=query_timeout('recommendations')) as cursor:
             cursor.execute("""
                 SELECT a.*, MAX(ui.created_at) as last_interaction
                 FROM articles a
@@ -193,7 +193,7 @@ async def get_user_recommendation_stats(current_user: dict = Depends(get_current
     try:
         user_id = current_user['id']
         
-        with get_postgres_cursor() as cursor:
+        with get_postgres_cursor(timeout_ms=query_timeout('recommendations')) as cursor:
             # Get articles read count
             cursor.execute("""
                 SELECT COUNT(DISTINCT article_id) as articles_read
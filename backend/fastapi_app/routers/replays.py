@@ -0,0 +1,179 @@
+"""
+Event replay routes for FastAPI backend
+"""
+
+import sys
+import os
+from typing import List, Optional
+from fastapi import APIRouter, HTTPException, Depends, Query, status
+import logging
+import psycopg2
+
+sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
+
+from shared.database import get_postgres_cursor
+from shared.models import ReplayCreate, ReplayUpdate, ReplayResponse
+from shared.jobs import replay_events
+from shared import replay, tenancy
+from ..dependencies import get_deployment_admin
+
+router = APIRouter()
+logger = logging.getLogger(__name__)
+
+REPLAY_STATUSES = ('pending', 'running', 'paused', 'completed', 'failed', 'cancelled')
+
+
+def missing_or_conflict(cursor, replay_id: str, detail: str) -> HTTPException:
+    if not replay.get(cursor, replay_id):
+        return HTTPException(status_code=404, detail="Replay not found")
+    return HTTPException(status_code=409, detail=detail)
+
+
+def enqueue(replay_id: str):
+    # Replays rebuild deployment-wide models, so the job sees every publication
+    with tenancy.unscoped():
+        replay_events.apply_async(args=[replay_id])
+
+
+@router.get("/projections")
+async def list_projections(admin_user: dict = Depends(get_deployment_admin)):
+    """The projections a replay can rebuild and whether each is configured here (admin only)"""
+    return {
+        "success": True,
+        "projections": [
+            {
+                "name": projection.name, "description": projection.description,
+                "event_types": projection.event_types, "available": projection.available(),
+                "resettable": projection.reset is not None,
+            }
+            for projection in replay.projections()
+        ],
+    }
+
+
+@router.post("/", response_model=ReplayResponse, status_code=status.HTTP_202_ACCEPTED)
+async def start_replay(replay_data: ReplayCreate, admin_user: dict = Depends(get_deployment_admin)):
+    """Replay historical events into a projection in the background (admin only)"""
+    if replay_data.since and replay_data.until and replay_data.since > replay_data.until:
+        raise HTTPException(status_code=400, detail="since must be before until")
+
+    try:
+        with get_postgres_cursor() as cursor:
+            created = replay.create(
+                cursor, replay_data.projection, str(admin_user['id']), replay_data.event_types, replay_data.since,
+                replay_data.until, replay_data.rate_per_second, replay_data.batch_size, replay_data.reset
+            )
+        enqueue(str(created['id']))
+        logger.info(f"Replay {created['id']} into {created['projection']} requested by {admin_user['id']}")
+        return ReplayResponse(**created)
+    except replay.ReplayError as e:
+        raise HTTPException(status_code=400, detail=str(e))
+    except psycopg2.errors.UniqueViolation:
+        raise HTTPException(status_code=409, detail="A replay into this projection is already running")
+    except Exception as e:
+        logger.error(f"Start replay error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to start replay")
+
+
+@router.get("/", response_model=List[ReplayResponse])
+async def list_replays(
+    replay_status: Optional[str] = Query(None, alias="status", pattern=f"^({'|'.join(REPLAY_STATUSES)})$"),
+    limit: int = Query(20, ge=1, le=100),
+    admin_user: dict = Depends(get_deployment_admin)
+):
+    """Replays with their progress, newest first (admin only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            return [ReplayResponse(**row) for row in replay.list_replays(cursor, replay_status, limit)]
+    except Exception as e:
+        logger.error(f"List replays error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve replays")
+
+
+@router.get("/{replay_id}", response_model=ReplayResponse)
+async def get_replay(replay_id: str, admin_user: dict = Depends(get_deployment_admin)):
+    """A replay's progress: events applied of the total, rate and time remaining (admin only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            found = replay.get(cursor, replay_id)
+        if not found:
+            raise HTTPException(status_code=404, detail="Replay not found")
+        return ReplayResponse(**found)
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Get replay error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve replay")
+
+
+@router.patch("/{replay_id}", response_model=ReplayResponse)
+async def update_replay(replay_id: str, update: ReplayUpdate, admin_user: dict = Depends(get_deployment_admin)):
+    """Change a replay's rate or batch size; a running replay applies them from its next batch (admin only)"""
+    changes = update.model_dump(exclude_unset=True)
+    if not changes:
+        raise HTTPException(status_code=400, detail="No valid fields to update")
+    try:
+        with get_postgres_cursor() as cursor:
+            updated = replay.update(cursor, replay_id, changes)
+        if not updated:
+            raise HTTPException(status_code=404, detail="Replay not found")
+        return ReplayResponse(**updated)
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Update replay error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to update replay")
+
+
+@router.post("/{replay_id}/pause", response_model=ReplayResponse)
+async def pause_replay(replay_id: str, admin_user: dict = Depends(get_deployment_admin)):
+    """Stop a replay after its current batch, keeping its checkpoint (admin only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            paused = replay.pause(cursor, replay_id)
+            if not paused:
+                raise missing_or_conflict(cursor, replay_id, "Only a pending or running replay can be paused")
+        return ReplayResponse(**paused)
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Pause replay error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to pause replay")
+
+
+@router.post("/{replay_id}/resume", response_model=ReplayResponse, status_code=status.HTTP_202_ACCEPTED)
+async def resume_replay(replay_id: str, admin_user: dict = Depends(get_deployment_admin)):
+    """Continue a paused or failed replay, or one whose worker died, from its checkpoint (admin only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            resumed = replay.resume(cursor, replay_id)
+            if not resumed:
+                raise missing_or_conflict(
+                    cursor, replay_id, "Only a paused or failed replay, or one that stopped checkpointing, can resume"
+                )
+        enqueue(replay_id)
+        logger.info(f"Replay {replay_id} resumed by {admin_user['id']}")
+        return ReplayResponse(**resumed)
+    except HTTPException:
+        raise
+    except psycopg2.errors.UniqueViolation:
+        raise HTTPException(status_code=409, detail="Another replay into this projection is already running")
+    except Exception as e:
+        logger.error(f"Resume replay error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to resume replay")
+
+
+@router.post("/{replay_id}/cancel", response_model=ReplayResponse)
+async def cancel_replay(replay_id: str, admin_user: dict = Depends(get_deployment_admin)):
+    """Stop a replay for good; what it applied stays applied (admin only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            cancelled = replay.cancel(cursor, replay_id)
+            if not cancelled:
+                raise missing_or_conflict(cursor, replay_id, "The replay has already finished")
+        return ReplayResponse(**cancelled)
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Cancel replay error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to cancel replay")
@@ -0,0 +1,110 @@
+"""
+Article report moderation routes for FastAPI backend
+"""
+
+import sys
+import os
+from typing import Optional
+from fastapi import APIRouter, HTTPException, Depends, Query
+import logging
+
+sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
+
+from shared.database import get_postgres_cursor
+from shared.models import ReportDecision
+from shared.reputation import report_resolved
+from shared.events import moderation_decided
+from shared.permissions import ARTICLE_MODERATE
+from ..dependencies import require_permission
+
+router = APIRouter()
+logger = logging.getLogger(__name__)
+
+
+def get_open_report(cursor, report_id: str) -> dict:
+    cursor.execute("SELECT * FROM article_reports WHERE id = %s FOR UPDATE", (report_id,))
+    report = cursor.fetchone()
+    if not report:
+        raise HTTPException(status_code=404, detail="Report not found")
+    if report['status'] != 'open':
+        raise HTTPException(status_code=409, detail=f"Report already {report['status']}")
+    return dict(report)
+
+
+@router.get("/")
+async def list_reports(
+    report_status: str = Query("open", alias="status", pattern="^(open|upheld|dismissed)$"),
+    article_id: Optional[str] = Query(None),
+    limit: int = Query(50, ge=1, le=200),
+    admin_user: dict = Depends(require_permission(ARTICLE_MODERATE))
+):
+    """Reader reports on articles, oldest first (`article:moderate`)"""
+    try:
+        query = """
+            SELECT r.*, a.title AS article_title, author.username AS author, reporter.username AS reporter
+            FROM article_reports r
+            JOIN articles a ON a.id = r.article_id
+            LEFT JOIN users author ON author.id = a.author_id
+            LEFT JOIN users reporter ON reporter.id = r.reported_by
+            WHERE r.status = %s
+        """
+        params = [report_status]
+        if article_id:
+            query += " AND r.article_id = %s"
+            params.append(article_id)
+
+        with get_postgres_cursor() as cursor:
+            cursor.execute(query + " ORDER BY r.created_at LIMIT %s", params + [limit])
+            reports = [dict(row) for row in cursor.fetchall()]
+
+        return {"success": True, "reports": reports}
+    except Exception as e:
+        logger.error(f"List reports error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve reports")
+
+
+async def resolve_report(report_id: str, upheld: bool, decision: Optional[ReportDecision], admin_user: dict) -> dict:
+    with get_postgres_cursor() as cursor:
+        report = get_open_report(cursor, report_id)
+        cursor.execute("""
+            UPDATE article_reports
+            SET status = %s, resolved_by = %s, resolution_note = %s, resolved_at = NOW()
+            WHERE id = %s
+            RETURNING *
+        """, ('upheld' if upheld else 'dismissed', admin_user['id'], decision.note if decision else None, report_id))
+        resolved = dict(cursor.fetchone())
+        report_resolved(cursor, report, upheld)
+        if report['reported_by']:
+            moderation_decided(
+                cursor, 'report', report_id, report['article_id'], resolved['status'],
+                [report['reported_by']], resolved['resolution_note']
+            )
+
+    logger.info(f"Report {report_id} {resolved['status']} by {admin_user['id']}")
+    return {"success": True, "report": resolved}
+
+
+@router.post("/{report_id}/uphold")
+async def uphold_report(report_id: str, decision: Optional[ReportDecision] = None,
+                        admin_user: dict = Depends(require_permission(ARTICLE_MODERATE))):
+    """Uphold a report: the author loses and the reporter gains reputation (`article:moderate`)"""
+    try:
+        return await resolve_report(report_id, True, decision, admin_user)
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Uphold report error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to uphold report")
+
+
+@router.post("/{report_id}/dismiss")
+async def dismiss_report(report_id: str, decision: Optional[ReportDecision] = None,
+                         admin_user: dict = Depends(require_permission(ARTICLE_MODERATE))):
+    """Dismiss a report: the reporter loses reputation (`article:moderate`)"""
+    try:
+        return await resolve_report(report_id, False, decision, admin_user)
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Dismiss report error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to dismiss report")
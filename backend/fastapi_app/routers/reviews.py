@@ -0,0 +1,129 @@
+"""
+Article review queue routes for FastAPI backend
+"""
+
+import sys
+import os
+from typing import Optional
+from fastapi import APIRouter, HTTPException, Depends, Query
+import logging
+
+sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
+
+from shared.database import get_postgres_cursor
+from shared.models import ArticleResponse, ArticleReviewDecision
+from shared.instance_policy import check_category
+from shared.publishing import on_article_published
+from shared.events import moderation_decided
+from ..dependencies import get_admin_user
+
+router = APIRouter()
+logger = logging.getLogger(__name__)
+
+
+def get_pending_review(cursor, review_id: str) -> dict:
+    cursor.execute("SELECT * FROM article_reviews WHERE id = %s FOR UPDATE", (review_id,))
+    review = cursor.fetchone()
+    if not review:
+        raise HTTPException(status_code=404, detail="Review not found")
+    if review['status'] != 'pending':
+        raise HTTPException(status_code=409, detail=f"Review already {review['status']}")
+    return dict(review)
+
+
+@router.get("/")
+async def list_reviews(
+    review_status: str = Query("pending", alias="status", pattern="^(pending|approved|rejected)$"),
+    limit: int = Query(50, ge=1, le=200),
+    admin_user: dict = Depends(get_admin_user)
+):
+    """Articles held for review by the instance policy (admin only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute("""
+                SELECT r.*, a.title AS article_title, a.category, a.tags, u.username AS author
+                FROM article_reviews r
+                JOIN articles a ON a.id = r.article_id
+                LEFT JOIN users u ON u.id = a.author_id
+                WHERE r.status = %s
+                ORDER BY r.created_at
+                LIMIT %s
+            """, (review_status, limit))
+            reviews = [dict(row) for row in cursor.fetchall()]
+
+        return {"success": True, "reviews": reviews}
+    except Exception as e:
+        logger.error(f"List reviews error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve reviews")
+
+
+@router.post("/{review_id}/approve", response_model=ArticleResponse)
+async def approve_review(review_id: str, decision: Optional[ArticleReviewDecision] = None,
+                         admin_user: dict = Depends(get_admin_user)):
+    """Approve a held article and publish it (admin only)"""
+    note = decision.note if decision else None
+    try:
+        with get_postgres_cursor() as cursor:
+            review = get_pending_review(cursor, review_id)
+
+            cursor.execute("SELECT * FROM articles WHERE id = %s FOR UPDATE", (review['article_id'],))
+            article = cursor.fetchone()
+            # The policy may have changed while the article waited
+            category_decision = check_category(article['category'])
+            if not category_decision.allowed:
+                raise HTTPException(status_code=403, detail=category_decision.reason)
+
+            if article['status'] != 'published':
+                cursor.execute("""
+                    UPDATE articles SET status = 'published', published_at = NOW(), updated_at = NOW()
+                    WHERE id = %s
+                    RETURNING *
+                """, (review['article_id'],))
+                article = cursor.fetchone()
+                on_article_published(cursor, dict(article))
+
+            cursor.execute("""
+                UPDATE article_reviews
+                SET status = 'approved', reviewed_by = %s, review_note = %s, reviewed_at = NOW()
+                WHERE id = %s
+            """, (admin_user['id'], note, review_id))
+            if article['author_id']:
+                moderation_decided(
+                    cursor, 'article_review', review_id, article['id'], 'approved', [article['author_id']], note
+                )
+
+        logger.info(f"Review {review_id} approved by {admin_user['id']}")
+        return ArticleResponse(**dict(article))
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Approve review error: {e}", exc_info=True)
+        raise HTTPException(status_code=500, detail="Failed to approve article")
+
+
+@router.post("/{review_id}/reject")
+async def reject_review(review_id: str, decision: Optional[ArticleReviewDecision] = None,
+                        admin_user: dict = Depends(get_admin_user)):
+    """Reject a held article; it stays a draft (admin only)"""
+    note = decision.note if decision else None
+    try:
+        with get_postgres_cursor() as cursor:
+            review = get_pending_review(cursor, review_id)
+            cursor.execute("""
+                UPDATE article_reviews
+                SET status = 'rejected', reviewed_by = %s, review_note = %s, reviewed_at = NOW()
+                WHERE id = %s
+            """, (admin_user['id'], note, review_id))
+            cursor.execute("SELECT author_id FROM articles WHERE id = %s", (review['article_id'],))
+            article = cursor.fetchone()
+            if article and article['author_id']:
+                moderation_decided(
+                    cursor, 'article_review', review_id, review['article_id'], 'rejected', [article['author_id']], note
+                )
+
+        return {"success": True, "message": "Article rejected"}
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Reject review error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to reject article")
@@ -0,0 +1,26 @@
+"""
+robots.txt route for FastAPI backend
+"""
+
+import sys
+import os
+from fastapi import APIRouter, HTTPException
+from fastapi.responses import PlainTextResponse
+import logging
+
+sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
+
+from shared.robots import robots_txt
+
+router = APIRouter()
+logger = logging.getLogger(__name__)
+
+
+@router.get("/robots.txt", response_class=PlainTextResponse, include_in_schema=False)
+async def get_robots_txt():
+    """Crawler rules from the `robots` setting, plus the bot-detection honeypots"""
+    try:
+        return PlainTextResponse(robots_txt(), headers={"Cache-Control": "public, max-age=3600"})
+    except Exception as e:
+        logger.error(f"Get robots.txt error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to render robots.txt")
@@ -0,0 +1,113 @@
+"""
+Sandbox tenant routes for FastAPI backend
+"""
+
+import sys
+import os
+from fastapi import APIRouter, HTTPException, Depends, Query, status
+from fastapi.responses import HTMLResponse
+import logging
+
+sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
+
+from shared.database import get_postgres_cursor
+from shared import sandbox
+from shared.jobs import reset_sandbox
+from ..dependencies import get_admin_user
+
+router = APIRouter()
+logger = logging.getLogger(__name__)
+
+# Swagger UI over the live OpenAPI document, sending the key from the box at the top with every request
+CONSOLE_HTML = """<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>API sandbox console</title>
+<link rel="stylesheet" href="https://cdn.jsdelivr.net/npm/swagger-ui-dist@5/swagger-ui.css">
+<style>
+  body { margin: 0; font-family: Helvetica, Arial, sans-serif; }
+  #sandbox-key { padding: 12px 20px; background: #fdf6e3; border-bottom: 1px solid #e0d8c0; }
+  #sandbox-key input { width: 420px; padding: 4px; font-family: monospace; }
+</style>
+</head>
+<body>
+<div id="sandbox-key">
+  <label>Sandbox key <input id="key" type="password" placeholder="svc_..." autocomplete="off"></label>
+  <small>Requests go to the sandbox tenant, which is reset every night. Sign in as a demo account with
+  <code>POST /api/v1/auth/login</code>, then paste the token under Authorize.</small>
+</div>
+<div id="swagger-ui"></div>
+<script src="https://cdn.jsdelivr.net/npm/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+<script>
+  const field = document.getElementById('key');
+  field.value = sessionStorage.getItem('sandboxKey') || '';
+  field.addEventListener('change', () => sessionStorage.setItem('sandboxKey', field.value));
+  SwaggerUIBundle({
+    url: '/api/v1/openapi.json',
+    dom_id: '#swagger-ui',
+    persistAuthorization: true,
+    requestInterceptor: (request) => {
+      if (field.value) {
+        request.headers['X-Sandbox-Key'] = field.value;
+      }
+      return request;
+    },
+  });
+</script>
+</body>
+</html>
+"""
+
+
+@router.get("/sandbox/console", response_class=HTMLResponse, include_in_schema=False)
+async def console():
+    """Interactive console for trying the API against the sandbox"""
+    return HTMLResponse(CONSOLE_HTML)
+
+
+@router.get("/sandbox")
+async def sandbox_status():
+    """The sandbox's demo accounts and reset times (sandbox key required)"""
+    if not sandbox.active():
+        raise HTTPException(status_code=400, detail=f"Send a sandbox key in the {sandbox.HEADER} header")
+    try:
+        with get_postgres_cursor() as cursor:
+            return {"success": True, **sandbox.describe(cursor)}
+    except Exception as e:
+        logger.error(f"Sandbox status error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve sandbox status")
+
+
+def production_admin(admin_user: dict = Depends(get_admin_user)) -> dict:
+    """An administrator of the production tenant; the sandbox's demo administrator doesn't manage it"""
+    if sandbox.active():
+        raise HTTPException(status_code=403, detail="Not available in the sandbox")
+    return admin_user
+
+
+@router.get("/admin/sandbox/resets")
+async def list_resets(
+    limit: int = Query(20, ge=1, le=100),
+    admin_user: dict = Depends(production_admin)
+):
+    """Recent sandbox resets, newest first (admin only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            resets = sandbox.list_resets(cursor, limit)
+        return {"success": True, "resets": resets}
+    except Exception as e:
+        logger.error(f"List sandbox resets error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve sandbox resets")
+
+
+@router.post("/admin/sandbox/reset", status_code=status.HTTP_202_ACCEPTED)
+async def reset(admin_user: dict = Depends(production_admin)):
+    """Rebuild and reseed the sandbox now in the background (admin only)"""
+    try:
+        result = reset_sandbox.apply_async(kwargs={'trigger': 'manual', 'requested_by': str(admin_user['id'])})
+        logger.info(f"Sandbox reset ({result.id}) requested by {admin_user['id']}")
+        return {"success": True, "job_id": result.id}
+    except Exception as e:
+        logger.error(f"Reset sandbox error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to start sandbox reset")
@@ -0,0 +1,134 @@
+"""
+Comment screening queue routes for FastAPI backend
+"""
+
+import sys
+import os
+from typing import Optional
+from fastapi import APIRouter, HTTPException, Depends, Query
+import logging
+
+sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
+
+from shared.database import get_postgres_cursor
+from shared.models import ScreeningDecision
+from shared.screening import checks, get_screening_settings, list_held_comments
+from shared.events import comment_posted, moderation_decided
+from shared.visibility import is_shadow_banned
+from shared.webhooks import emit_comment_created
+from shared.permissions import COMMENT_MODERATE
+from ..dependencies import require_permission
+
+router = APIRouter()
+logger = logging.getLogger(__name__)
+
+
+def get_pending_screening(cursor, screening_id: str) -> dict:
+    cursor.execute("SELECT * FROM comment_screenings WHERE id = %s FOR UPDATE", (screening_id,))
+    screening = cursor.fetchone()
+    if not screening:
+        raise HTTPException(status_code=404, detail="Held comment not found")
+    if screening['status'] != 'pending':
+        raise HTTPException(status_code=409, detail=f"Comment already {screening['status']}")
+    return dict(screening)
+
+
+def decide(cursor, screening: dict, decision: str, admin_user: dict, note: Optional[str]) -> dict:
+    cursor.execute("""
+        UPDATE comment_screenings
+        SET status = %s, reviewed_by = %s, review_note = %s, reviewed_at = NOW()
+        WHERE id = %s
+    """, (decision, admin_user['id'], note, screening['id']))
+    cursor.execute(
+        "UPDATE comments SET moderation_status = %s, updated_at = NOW() WHERE id = %s RETURNING *",
+        (decision, screening['comment_id'])
+    )
+    comment = dict(cursor.fetchone())
+    moderation_decided(cursor, 'comment', comment['id'], comment['article_id'], decision, [comment['user_id']], note)
+    return comment
+
+
+@router.get("/checks")
+async def list_checks(admin_user: dict = Depends(require_permission(COMMENT_MODERATE))):
+    """The screening checks and which are enabled (`comment:moderate`)"""
+    try:
+        enabled = set(get_screening_settings().get('checks') or [])
+        return {"success": True, "checks": [
+            {'name': check.name, 'description': check.description, 'applies_to': list(check.kinds),
+             'enabled': check.name in enabled}
+            for check in checks()
+        ]}
+    except Exception as e:
+        logger.error(f"List screening checks error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve screening checks")
+
+
+@router.get("/")
+async def list_held(
+    screening_status: str = Query("pending", alias="status", pattern="^(pending|approved|rejected)$"),
+    article_id: Optional[str] = Query(None),
+    limit: int = Query(50, ge=1, le=200),
+    admin_user: dict = Depends(require_permission(COMMENT_MODERATE))
+):
+    """Comments held by screening with what flagged them, oldest first (`comment:moderate`)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            comments = list_held_comments(cursor, screening_status, article_id, limit)
+        return {"success": True, "comments": comments}
+    except Exception as e:
+        logger.error(f"List held comments error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve held comments")
+
+
+@router.post("/{screening_id}/approve")
+async def approve_comment(screening_id: str, decision: Optional[ScreeningDecision] = None,
+                          admin_user: dict = Depends(require_permission(COMMENT_MODERATE))):
+    """Publish a held comment (`comment:moderate`)"""
+    note = decision.note if decision else None
+    try:
+        with get_postgres_cursor() as cursor:
+            screening = get_pending_screening(cursor, screening_id)
+            comment = decide(cursor, screening, 'approved', admin_user, note)
+
+            # A shadow-banned commenter's comment stays uncounted and silent, as if it had been posted unheld
+            if not is_shadow_banned(cursor, str(comment['user_id'])):
+                cursor.execute("""
+                    UPDATE articles SET comment_count = comment_count + 1 WHERE id = %s
+                    RETURNING author_id, tenant_id
+                """, (comment['article_id'],))
+                article = cursor.fetchone()
+                parent_author_id = None
+                if comment['parent_comment_id']:
+                    cursor.execute("SELECT user_id FROM comments WHERE id = %s", (comment['parent_comment_id'],))
+                    parent = cursor.fetchone()
+                    parent_author_id = parent['user_id'] if parent else None
+                # The article's author and the replied-to commenter hear about it now, as if it had just been posted
+                comment_posted(cursor, comment, article['author_id'] if article else None, parent_author_id)
+                emit_comment_created(cursor, comment, article)
+
+        logger.info(f"Held comment {comment['id']} approved by {admin_user['id']}")
+        return {"success": True, "message": "Comment approved", "comment_id": str(comment['id'])}
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Approve comment error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to approve comment")
+
+
+@router.post("/{screening_id}/reject")
+async def reject_comment(screening_id: str, decision: Optional[ScreeningDecision] = None,
+                         admin_user: dict = Depends(require_permission(COMMENT_MODERATE))):
+    """Reject a held comment; it is never shown (`comment:moderate`)"""
+    note = decision.note if decision else None
+    try:
+        with get_postgres_cursor() as cursor:
+            screening = get_pending_screening(cursor, screening_id)
+            comment = decide(cursor, screening, 'rejected', admin_user, note)
+
+        logger.info(f"Held comment {comment['id']} rejected by {admin_user['id']}")
+        return {"success": True, "message": "Comment rejected", "comment_id": str(comment['id'])}
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Reject comment error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to reject comment")
@@ -4,96 +4,148 @@ Search routes for FastAPI backend
 
 import sys
 import os
-from fastapi import APIRouter, HTTPException, status
+from typing import List, Optional
+from fastapi import APIRouter, HTTPException, Depends, status
 import logging
 
 sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
 
-from shared.database import get_postgres_cursor
+from shared.database import get_postgres_cursor, query_timeout
 from shared.models import SearchRequest, SearchResponse, ArticleResponse
 from shared.utils import TimingContext
+from shared.visibility import hidden_authors
+from shared import coauthors, embeddings, lite
+from ..dependencies import get_optional_user
 
 router = APIRouter()
 logger = logging.getLogger(__name__)
 
 
+# Full-text document of an article, as indexed for keyword search
+DOCUMENT = "to_tsvector('english', title || ' ' || content || ' ' || summary)"
+ORDERINGS = {
+    'relevance': "relevance_score DESC",
+    'date': "published_at DESC",
+    'popularity': "engagement_score DESC",
+}
+
+
+def search_filters(search_data: SearchRequest, hidden: List[str]):
+    """The WHERE conditions besides the match itself, with their parameters"""
+    conditions, params = ["status = 'published'"], []
+    if hidden:
+        conditions.append("NOT (author_id::text = ANY(%s))")
+        params.append(hidden)
+    if search_data.categories:
+        conditions.append("category = ANY(%s)")
+        params.append(search_data.categories)
+    if search_data.languages:
+        conditions.append("language = ANY(%s)")
+        params.append(search_data.languages)
+    if search_data.author_id:
+        conditions.append("(author_id = %s OR %s = ANY(coauthor_ids))")
+        params.extend([str(search_data.author_id), str(search_data.author_id)])
+    if search_data.date_from:
+        conditions.append("published_at >= %s")
+        params.append(search_data.date_from)
+    if search_data.date_to:
+        conditions.append("published_at <= %s")
+        params.append(search_data.date_to)
+    return conditions, params
+
+
+def keyword_search(cursor, search_data: SearchRequest, limit: int, hidden: List[str]):
+    conditions, params = search_filters(search_data, hidden)
+    where = ' AND '.join(conditions + [f"{DOCUMENT} @@ plainto_tsquery('english', %s)"])
+    params = params + [search_data.query]
+
+    cursor.execute(f"""
+        SELECT *, ts_rank({DOCUMENT}, plainto_tsquery('english', %s)) as relevance_score
+        FROM articles
+        WHERE {where}
+        ORDER BY {ORDERINGS.get(search_data.sort_by, ORDERINGS['relevance'])}
+        LIMIT %s OFFSET %s
+    """, [search_data.query] + params + [limit, search_data.offset])
+    articles = cursor.fetchall()
+
+    cursor.execute(f"SELECT COUNT(*) as total FROM articles WHERE {where}", params)
+    return articles, cursor.fetchone()['total']
+
+
+def semantic_search(cursor, search_data: SearchRequest, limit: int, hidden: List[str]):
+    """Articles near the query's embedding or matching its words, ranked by a blend of both"""
+    vector = embeddings.query_vector(search_data.query)
+    conditions, params = search_filters(search_data, hidden)
+    # Either among the nearest neighbours of the query or a keyword match
+    where = ' AND '.join(conditions + [f"""(
+        id IN (
+            SELECT article_id FROM article_vectors
+            ORDER BY embedding <=> %s::vector
+            LIMIT %s
+        )
+        OR {DOCUMENT} @@ plainto_tsquery('english', %s)
+    )"""])
+    params = params + [vector, embeddings.SEARCH_CANDIDATES, search_data.query]
+
+    cursor.execute(f"""
+        SELECT *, %s * similarity + (1 - %s) * keyword_rank / (1 + keyword_rank) AS relevance_score
+        FROM (
+            SELECT a.*,
+                   COALESCE(1 - (v.embedding <=> %s::vector), 0) AS similarity,
+                   ts_rank({DOCUMENT}, plainto_tsquery('english', %s)) AS keyword_rank
+            FROM articles a
+            LEFT JOIN article_vectors v ON v.article_id = a.id
+            WHERE {where}
+        ) matches
+        ORDER BY {ORDERINGS.get(search_data.sort_by, ORDERINGS['relevance'])}
+        LIMIT %s OFFSET %s
+    """, [embeddings.SEARCH_WEIGHT, embeddings.SEARCH_WEIGHT, vector, search_data.query]
+        + params + [limit, search_data.offset])
+    articles = cursor.fetchall()
+
+    cursor.execute(f"SELECT COUNT(*) as total FROM articles WHERE {where}", params)
+    return articles, cursor.fetchone()['total']
+
+
 @router.post("/", response_model=SearchResponse)
-async def search_articles(search_data: SearchRequest):
-    """Search articles with full-text search"""
+async def search_articles(search_data: SearchRequest, current_user: Optional[dict] = Depends(get_optional_user)):
+    """Search articles with full-text search, or blended with embedding similarity with `mode: semantic`
+
+    Shadow-banned authors, and authors the reader blocked, are left out.
+
+    Semantic search falls back to keyword search when embeddings aren't
+    configured or the provider or vector index can't be reached; `mode` in
+    the response says which ran.
+    """
+    limit = lite.page_size(search_data.limit, 'limit' in search_data.model_fields_set)
     try:
         with TimingContext() as timer:
-            with get_postgres_cursor() as cursor:
-                query = """
-                    SELECT *, ts_rank(
-                        to_tsvector('english', title || ' ' || content || ' ' || summary), 
-                        plainto_tsquery('english', %s)
-                    ) as relevance_score
-                    FROM articles 
-                    WHERE status = 'published'
-                    AND (to_tsvector('english', title || ' ' || content || ' ' || summary) @@ plainto_tsquery('english', %s))
-                """
-                params = [search_data.query, search_data.query]
-                
-                if search_data.categories:
-                    query += " AND category = ANY(%s)"
-                    params.append(search_data.categories)
-                
-                if search_data.languages:
-                    query += " AND language = ANY(%s)"
-                    params.append(search_data.languages)
-                
-                if search_data.author_id:
-                    query += " AND author_id = %s"
-                    params.append(str(search_data.author_id))
-                
-                if search_data.date_from:
-                    query += " AND published_at >= %s"
-                    params.append(search_data.date_from)
-                
-                if search_data.date_to:
-                    query += " AND published_at <= %s"
-                    params.append(search_data.date_to)
-                
-                # Sorting
-                if search_data.sort_by == 'relevance':
-                    query += " ORDER BY relevance_score DESC"
-                elif search_data.sort_by == 'date':
-                    query += " ORDER BY published_at DESC"
-                elif search_data.sort_by == 'popularity':
-                    query += " ORDER BY engagement_score DESC"
-                else:
-                    query += " ORDER BY relevance_score DESC"
-                
-                query += " LIMIT %s OFFSET %s"
-                params.extend([search_data.limit, search_data.offset])
-                
-                cursor.execute(query, params)
-                articles = cursor.fetchall()
-                
-                # Get total count
-                count_query = """
-                    SELECT COUNT(*) as total FROM articles 
-                    WHERE status = 'published'
-                    AND (to_tsvector('english', title || ' ' || content || ' ' || summary) @@ plainto_tsquery('english', %s))
-                """
-                count_params = [search_data.query]
-                
-                if search_data.categories:
-                    count_query += " AND category = ANY(%s)"
-                    count_params.append(search_data.categories)
-                
-                cursor.execute(count_query, count_params)
-                total_count = cursor.fetchone()['total']
+            with get_postgres_cursor(timeout_ms=query_timeout('search')) as cursor:
+                hidden = hidden_authors(cursor, current_user)
+                mode = 'keyword'
+                if search_data.mode == 'semantic' and embeddings.enabled():
+                    cursor.execute("SAVEPOINT semantic_search")
+                    try:
+                        articles, total_count = semantic_search(cursor, search_data, limit, hidden)
+                        cursor.execute("RELEASE SAVEPOINT semantic_search")
+                        mode = 'semantic'
+                    except Exception as e:
+                        cursor.execute("ROLLBACK TO SAVEPOINT semantic_search")
+                        logger.warning(f"Semantic search unavailable, searching by keyword: {e}")
+                if mode == 'keyword':
+                    articles, total_count = keyword_search(cursor, search_data, limit, hidden)
+                articles = coauthors.attach(cursor, articles)
         
-        article_responses = [ArticleResponse(**dict(article)) for article in articles]
+        article_responses = [ArticleResponse(**article) for article in articles]
         
         return SearchResponse(
             results=article_responses,
             total_count=total_count,
             query=search_data.query,
+            mode=mode,
             execution_time_ms=timer.get_duration_ms()
         )
     
     except Exception as e:
         logger.error(f"Search articles error: {e}")
-        raise HTTPException(status_code=500, detail="Search failed")
\ No newline at end of file
+        raise HTTPException(status_code=500, detail="Search failed")
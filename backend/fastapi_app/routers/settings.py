@@ -0,0 +1,100 @@
+"""
+Platform settings routes for FastAPI backend
+"""
+
+import sys
+import os
+from fastapi import APIRouter, HTTPException, Depends, status
+from pydantic import ValidationError
+import logging
+
+sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
+
+from shared.models import (
+    SettingUpdate, SettingResponse, HomeFeedConfig, ReactionsConfig, InstancePolicy, OgImageConfig, ReputationConfig,
+    GovernanceConfig, RobotsConfig, TelemetryConfig, ScreeningConfig, EditorialConfig
+)
+from shared.settings import settings_manager, DEFAULT_SETTINGS
+from shared.permissions import SETTINGS_MANAGE
+from ..dependencies import require_permission
+
+router = APIRouter()
+logger = logging.getLogger(__name__)
+
+# Validators for settings keys with a known shape
+SETTINGS_SCHEMAS = {
+    'home_feed': HomeFeedConfig,
+    'reactions': ReactionsConfig,
+    'instance_policy': InstancePolicy,
+    'og_image': OgImageConfig,
+    'reputation': ReputationConfig,
+    'governance': GovernanceConfig,
+    'robots': RobotsConfig,
+    'telemetry': TelemetryConfig,
+    'screening': ScreeningConfig,
+    'editorial': EditorialConfig,
+}
+
+
+@router.get("/")
+async def list_settings(admin_user: dict = Depends(require_permission(SETTINGS_MANAGE))):
+    """List all known settings with their effective values (`settings:manage`)"""
+    try:
+        return {
+            "success": True,
+            "settings": {key: settings_manager.get(key) for key in DEFAULT_SETTINGS}
+        }
+    except Exception as e:
+        logger.error(f"List settings error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve settings")
+
+
+@router.get("/{key}", response_model=SettingResponse)
+async def get_setting(key: str, admin_user: dict = Depends(require_permission(SETTINGS_MANAGE))):
+    """Get the effective value of a settings key (`settings:manage`)"""
+    if key not in DEFAULT_SETTINGS:
+        raise HTTPException(status_code=404, detail="Unknown settings key")
+
+    try:
+        return SettingResponse(key=key, value=settings_manager.get(key))
+    except Exception as e:
+        logger.error(f"Get setting error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve setting")
+
+
+@router.put("/{key}", response_model=SettingResponse)
+async def update_setting(key: str, update: SettingUpdate,
+                         admin_user: dict = Depends(require_permission(SETTINGS_MANAGE))):
+    """Replace the value of a settings key (`settings:manage`)"""
+    if key not in DEFAULT_SETTINGS:
+        raise HTTPException(status_code=404, detail="Unknown settings key")
+
+    value = update.value
+    schema = SETTINGS_SCHEMAS.get(key)
+    if schema:
+        try:
+            value = schema(**value).dict()
+        except (ValidationError, TypeError) as e:
+            raise HTTPException(status_code=status.HTTP_400_BAD_REQUEST, detail=f"Invalid value for {key}: {e}")
+
+    try:
+        value = settings_manager.set(key, value, str(admin_user['id']), actor=admin_user)
+        logger.info(f"Setting {key} updated by {admin_user['id']}")
+        return SettingResponse(key=key, value=value, message="Setting updated successfully")
+    except Exception as e:
+        logger.error(f"Update setting error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to update setting")
+
+
+@router.delete("/{key}", response_model=SettingResponse)
+async def reset_setting(key: str, admin_user: dict = Depends(require_permission(SETTINGS_MANAGE))):
+    """Reset a settings key to its default (`settings:manage`)"""
+    if key not in DEFAULT_SETTINGS:
+        raise HTTPException(status_code=404, detail="Unknown settings key")
+
+    try:
+        value = settings_manager.reset(key, actor=admin_user)
+        return SettingResponse(key=key, value=value, message="Setting reset to default")
+    except Exception as e:
+        logger.error(f"Reset setting error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to reset setting")
@@ -0,0 +1,74 @@
+"""
+Live article stream routes for FastAPI backend
+"""
+
+import sys
+import os
+import json
+import logging
+from typing import Optional
+from fastapi import APIRouter, Header, HTTPException, Query, Request
+from fastapi.responses import StreamingResponse
+
+sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
+
+from shared import article_stream, tenancy
+
+router = APIRouter()
+logger = logging.getLogger(__name__)
+
+KEEPALIVE_SECONDS = 15
+RETRY_MS = 5000
+
+
+@router.get("/articles")
+async def stream_articles(
+    request: Request,
+    category: Optional[str] = Query(None, description="Comma-separated categories to receive, e.g. technology,science"),
+    last_event_id: Optional[str] = Query(None, description="Resume after this event id"),
+    last_event_id_header: Optional[str] = Header(None, alias="Last-Event-ID"),
+):
+    """Server-sent events announcing newly published articles and trending changes
+
+    Emits `article` events with a summary of each article as it goes live and
+    `trending` events with the ranked trending list whenever it changes.
+    Reconnecting browsers resume from `Last-Event-ID` on their own; clients
+    that can't set the header pass `last_event_id` instead. Only the
+    publication's own articles are announced.
+    """
+    resume_from = last_event_id_header or last_event_id
+    if resume_from and not article_stream.EVENT_ID.match(resume_from):
+        raise HTTPException(status_code=400, detail="Invalid Last-Event-ID")
+    categories = sorted({part.strip().lower() for part in (category or '').split(',') if part.strip()})
+    subscriber_tenant = tenancy.current_id()
+
+    client = article_stream.stream_client()
+    try:
+        position = resume_from or await article_stream.latest_id(client)
+    except Exception as e:
+        await client.aclose()
+        logger.error(f"Article stream unavailable: {e}")
+        raise HTTPException(status_code=503, detail="Article stream unavailable")
+
+    async def events():
+        nonlocal position
+        try:
+            yield f"retry: {RETRY_MS}\n\n"
+            while not await request.is_disconnected():
+                position, entries = await article_stream.read(client, position, KEEPALIVE_SECONDS * 1000)
+                if not entries:
+                    yield ": keepalive\n\n"
+                    continue
+                for entry_id, event, tenant, data in entries:
+                    visible = article_stream.matches(event, data, tenant, subscriber_tenant, categories)
+                    if visible is not None:
+                        yield f"id: {entry_id}\nevent: {event}\ndata: {json.dumps(visible, default=str)}\n\n"
+        except Exception as e:
+            logger.error(f"Article stream error: {e}")
+        finally:
+            await client.aclose()
+
+    return StreamingResponse(events(), media_type="text/event-stream", headers={
+        "Cache-Control": "no-cache",
+        "X-Accel-Buffering": "no",
+    })
@@ -0,0 +1,102 @@
+"""
+Subscription administration routes for FastAPI backend
+"""
+
+import sys
+import os
+from typing import Optional
+from fastapi import APIRouter, HTTPException, Depends, Query, status
+import logging
+import psycopg2
+
+sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
+
+from shared.database import get_postgres_cursor
+from shared.models import SubscriptionGrant, SubscriptionTierCreate
+from shared.paywall import grant_subscription, list_subscriptions, list_tiers, revoke_subscription
+from ..dependencies import get_admin_user
+
+router = APIRouter()
+logger = logging.getLogger(__name__)
+
+
+@router.get("/tiers")
+async def get_tiers(admin_user: dict = Depends(get_admin_user)):
+    """Subscription tiers, lowest rank first (admin only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            return {"success": True, "tiers": list_tiers(cursor)}
+    except Exception as e:
+        logger.error(f"List subscription tiers error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve subscription tiers")
+
+
+@router.post("/tiers", status_code=status.HTTP_201_CREATED)
+async def create_tier(tier: SubscriptionTierCreate, admin_user: dict = Depends(get_admin_user)):
+    """Add a subscription tier; subscribers to it can read articles requiring its rank or lower (admin only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute("""
+                INSERT INTO subscription_tiers (key, name, rank, description)
+                VALUES (%s, %s, %s, %s)
+                RETURNING *
+            """, (tier.key, tier.name, tier.rank, tier.description))
+            return {"success": True, "tier": dict(cursor.fetchone())}
+    except psycopg2.errors.UniqueViolation:
+        raise HTTPException(status_code=409, detail="A tier with this key or rank already exists")
+    except Exception as e:
+        logger.error(f"Create subscription tier error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to create subscription tier")
+
+
+@router.get("/")
+async def get_subscriptions(
+    user_id: Optional[str] = Query(None),
+    active: bool = Query(False, description="Only subscriptions in effect now"),
+    admin_user: dict = Depends(get_admin_user)
+):
+    """Subscriptions, newest first (admin only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            return {"success": True, "subscriptions": list_subscriptions(cursor, user_id, active)}
+    except Exception as e:
+        logger.error(f"List subscriptions error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve subscriptions")
+
+
+@router.post("/", status_code=status.HTTP_201_CREATED)
+async def create_subscription(grant: SubscriptionGrant, admin_user: dict = Depends(get_admin_user)):
+    """Subscribe a user to a tier, e.g. when a billing provider confirms a payment (admin only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute("SELECT 1 FROM users WHERE id = %s", (str(grant.user_id),))
+            if not cursor.fetchone():
+                raise HTTPException(status_code=404, detail="User not found")
+            cursor.execute("SELECT 1 FROM subscription_tiers WHERE key = %s", (grant.tier,))
+            if not cursor.fetchone():
+                raise HTTPException(status_code=400, detail=f"Unknown subscription tier: {grant.tier}")
+            subscription = grant_subscription(cursor, grant.model_dump(), admin_user['id'])
+
+        logger.info(f"User {grant.user_id} subscribed to {grant.tier} by admin {admin_user['id']}")
+        return {"success": True, "subscription": subscription}
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Create subscription error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to create subscription")
+
+
+@router.delete("/{subscription_id}")
+async def delete_subscription(subscription_id: str, admin_user: dict = Depends(get_admin_user)):
+    """End a subscription now (admin only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            subscription = revoke_subscription(cursor, subscription_id)
+        if not subscription:
+            raise HTTPException(status_code=404, detail="Subscription not found or already revoked")
+        return {"success": True, "subscription": subscription}
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Revoke subscription error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to revoke subscription")
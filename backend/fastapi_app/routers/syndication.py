@@ -0,0 +1,233 @@
+"""
+Partner syndication routes for FastAPI backend
+"""
+
+import sys
+import os
+from typing import List, Optional
+from fastapi import APIRouter, HTTPException, Depends, Request, status, Query
+import logging
+
+sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
+
+from shared.database import get_postgres_cursor, prepare_json_data
+from shared.models import (
+    SyndicationPartnerCreate, SyndicationPartnerUpdate, SyndicationPartnerResponse,
+    SyndicationDeltaResponse, SyndicationUsage
+)
+from shared.syndication import generate_api_key, pull_delta, get_syndicated_article, record_usage
+from shared.audit import client_of, record_security_event
+from ..dependencies import get_admin_user, get_syndication_partner
+
+router = APIRouter()
+logger = logging.getLogger(__name__)
+
+
+def get_usage(cursor, partner_id: str, days: int) -> List[SyndicationUsage]:
+    cursor.execute("""
+        SELECT usage_date, endpoint, request_count, article_count
+        FROM syndication_usage
+        WHERE partner_id = %s AND usage_date > CURRENT_DATE - %s
+        ORDER BY usage_date DESC, endpoint ASC
+    """, (partner_id, days))
+    return [
+        SyndicationUsage(
+            usage_date=row['usage_date'].isoformat(),
+            endpoint=row['endpoint'],
+            request_count=row['request_count'],
+            article_count=row['article_count']
+        )
+        for row in cursor.fetchall()
+    ]
+
+
+@router.get("/articles", response_model=SyndicationDeltaResponse)
+async def pull_articles(
+    delta_token: Optional[str] = Query(None, description="Token from the previous pull; omit for a full sync"),
+    limit: int = Query(100, ge=1, le=500),
+    partner: dict = Depends(get_syndication_partner)
+):
+    """Incrementally pull syndicated articles changed since the last delta token"""
+    try:
+        with get_postgres_cursor() as cursor:
+            delta = pull_delta(cursor, partner, delta_token, limit)
+            record_usage(cursor, partner['id'], 'articles', len(delta['items']))
+
+        return SyndicationDeltaResponse(**delta)
+    except ValueError as e:
+        raise HTTPException(status_code=400, detail=str(e))
+    except Exception as e:
+        logger.error(f"Syndication pull error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to pull syndicated articles")
+
+
+@router.get("/articles/{article_id}")
+async def get_article(article_id: str, partner: dict = Depends(get_syndication_partner)):
+    """Fetch a single syndicated article"""
+    try:
+        with get_postgres_cursor() as cursor:
+            article = get_syndicated_article(cursor, partner, article_id)
+            if not article:
+                raise HTTPException(status_code=404, detail="Article not found or not licensed for syndication")
+            record_usage(cursor, partner['id'], 'article', 1)
+
+        return article
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Syndication article error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve syndicated article")
+
+
+@router.get("/usage", response_model=List[SyndicationUsage])
+async def get_own_usage(days: int = Query(30, ge=1, le=365), partner: dict = Depends(get_syndication_partner)):
+    """Usage report for the calling partner"""
+    try:
+        with get_postgres_cursor() as cursor:
+            return get_usage(cursor, partner['id'], days)
+    except Exception as e:
+        logger.error(f"Syndication usage error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve usage")
+
+
+@router.get("/partners", response_model=List[SyndicationPartnerResponse])
+async def list_partners(admin_user: dict = Depends(get_admin_user)):
+    """List syndication partners (admin only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute("SELECT * FROM syndication_partners ORDER BY created_at DESC")
+            partners = cursor.fetchall()
+
+        return [SyndicationPartnerResponse(**dict(partner)) for partner in partners]
+    except Exception as e:
+        logger.error(f"List partners error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve partners")
+
+
+@router.post("/partners", response_model=SyndicationPartnerResponse, status_code=status.HTTP_201_CREATED)
+async def create_partner(partner_data: SyndicationPartnerCreate, request: Request,
+                         admin_user: dict = Depends(get_admin_user)):
+    """Register a partner and issue its API key (admin only)
+
+    The key is returned once and only its hash is stored.
+    """
+    try:
+        api_key, key_hash, key_prefix = generate_api_key()
+        with get_postgres_cursor() as cursor:
+            cursor.execute("""
+                INSERT INTO syndication_partners (
+                    name, contact_email, api_key_hash, api_key_prefix,
+                    allowed_licenses, commercial_use, redaction_policy, created_by
+                ) VALUES (%s, %s, %s, %s, %s, %s, %s, %s)
+                RETURNING *
+            """, (
+                partner_data.name, partner_data.contact_email, key_hash, key_prefix,
+                [license.value for license in partner_data.allowed_licenses],
+                partner_data.commercial_use, prepare_json_data(partner_data.redaction_policy.dict()),
+                str(admin_user['id'])
+            ))
+            partner = cursor.fetchone()
+
+        record_security_event(
+            admin_user['id'], 'api_key_created', *client_of(request),
+            details={'kind': 'syndication', 'name': partner['name'], 'key_prefix': key_prefix},
+            resource_type='syndication_partner', resource_id=str(partner['id'])
+        )
+        return SyndicationPartnerResponse(**dict(partner), api_key=api_key)
+    except Exception as e:
+        logger.error(f"Create partner error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to create partner")
+
+
+@router.patch("/partners/{partner_id}", response_model=SyndicationPartnerResponse)
+async def update_partner(partner_id: str, partner_update: SyndicationPartnerUpdate,
+                         admin_user: dict = Depends(get_admin_user)):
+    """Update a partner's scope or revoke access (admin only)"""
+    update_data = partner_update.dict(exclude_unset=True)
+    if not update_data:
+        raise HTTPException(status_code=400, detail="No valid fields to update")
+    if 'allowed_licenses' in update_data:
+        update_data['allowed_licenses'] = [license.value for license in update_data['allowed_licenses'] or []]
+    if 'redaction_policy' in update_data:
+        update_data['redaction_policy'] = prepare_json_data(update_data['redaction_policy'] or {})
+
+    update_fields = [f"{field} = %s" for field in update_data]
+    params = list(update_data.values()) + [partner_id]
+
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute(
+                f"UPDATE syndication_partners SET {', '.join(update_fields)} WHERE id = %s RETURNING *",
+                params
+            )
+            partner = cursor.fetchone()
+            if not partner:
+                raise HTTPException(status_code=404, detail="Partner not found")
+
+        return SyndicationPartnerResponse(**dict(partner))
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Update partner error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to update partner")
+
+
+@router.post("/partners/{partner_id}/rotate-key", response_model=SyndicationPartnerResponse)
+async def rotate_partner_key(partner_id: str, request: Request, admin_user: dict = Depends(get_admin_user)):
+    """Issue a new API key, invalidating the previous one (admin only)"""
+    try:
+        api_key, key_hash, key_prefix = generate_api_key()
+        with get_postgres_cursor() as cursor:
+            cursor.execute("""
+                UPDATE syndication_partners SET api_key_hash = %s, api_key_prefix = %s
+                WHERE id = %s RETURNING *
+            """, (key_hash, key_prefix, partner_id))
+            partner = cursor.fetchone()
+            if not partner:
+                raise HTTPException(status_code=404, detail="Partner not found")
+
+        record_security_event(
+            admin_user['id'], 'api_key_rotated', *client_of(request),
+            details={'kind': 'syndication', 'name': partner['name'], 'key_prefix': key_prefix},
+            resource_type='syndication_partner', resource_id=partner_id
+        )
+        return SyndicationPartnerResponse(**dict(partner), api_key=api_key)
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Rotate partner key error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to rotate partner key")
+
+
+@router.get("/partners/{partner_id}/usage", response_model=List[SyndicationUsage])
+async def get_partner_usage(partner_id: str, days: int = Query(30, ge=1, le=365),
+                            admin_user: dict = Depends(get_admin_user)):
+    """Usage report for a partner (admin only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            return get_usage(cursor, partner_id, days)
+    except Exception as e:
+        logger.error(f"Partner usage error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve usage")
+
+
+@router.get("/partners/{partner_id}/preview/{article_id}")
+async def preview_partner_payload(partner_id: str, article_id: str, admin_user: dict = Depends(get_admin_user)):
+    """Show exactly what a partner would receive for an article, after redaction (admin only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute("SELECT * FROM syndication_partners WHERE id = %s", (partner_id,))
+            partner = cursor.fetchone()
+            if not partner:
+                raise HTTPException(status_code=404, detail="Partner not found")
+
+            article = get_syndicated_article(cursor, dict(partner), article_id)
+            if not article:
+                raise HTTPException(status_code=404, detail="Article not found or not licensed for this partner")
+
+        return article
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Partner preview error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to build partner preview")
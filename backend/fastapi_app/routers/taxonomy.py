@@ -0,0 +1,44 @@
+"""
+Tag and category suggestion routes for FastAPI backend
+"""
+
+import sys
+import os
+from fastapi import APIRouter, HTTPException, Query
+import logging
+
+sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
+
+from shared.database import get_postgres_cursor, query_timeout
+from shared import taxonomy
+
+router = APIRouter()
+logger = logging.getLogger(__name__)
+
+
+def _suggest(dimension: str, q: str, limit: int):
+    try:
+        with get_postgres_cursor(timeout_ms=query_timeout('search')) as cursor:
+            suggestions = taxonomy.suggest(cursor, dimension, q, limit)
+        return {"success": True, "query": q.strip(), "suggestions": suggestions}
+    except Exception as e:
+        logger.error(f"Suggest {dimension} error: {e}")
+        raise HTTPException(status_code=500, detail=f"Failed to suggest {dimension} values")
+
+
+@router.get("/tags/suggest")
+async def suggest_tags(
+    q: str = Query('', max_length=100, description="What the author has typed so far"),
+    limit: int = Query(10, ge=1, le=taxonomy.MAX_SUGGESTIONS)
+):
+    """Tags in use that match `q`, most used first; the most used tags when `q` is empty"""
+    return _suggest('tag', q, limit)
+
+
+@router.get("/categories/suggest")
+async def suggest_categories(
+    q: str = Query('', max_length=100, description="What the author has typed so far"),
+    limit: int = Query(10, ge=1, le=taxonomy.MAX_SUGGESTIONS)
+):
+    """Categories in use that match `q`, most used first; the most used categories when `q` is empty"""
+    return _suggest('category', q, limit)
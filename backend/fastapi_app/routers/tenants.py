@@ -0,0 +1,168 @@
+"""
+Tenant (publication) administration routes for FastAPI backend
+"""
+
+import sys
+import os
+from typing import List
+from fastapi import APIRouter, HTTPException, Depends, status
+import logging
+import psycopg2
+from psycopg2.extras import Json
+
+sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
+
+from shared.database import get_postgres_cursor
+from shared.audit import record_privileged
+from shared.models import TenantCreate, TenantUpdate, TenantResponse, TenantIsolationResponse
+from shared.branding import normalize_host, purge_tenant
+from shared import tenancy
+from ..dependencies import get_deployment_admin
+
+router = APIRouter()
+logger = logging.getLogger(__name__)
+
+
+def normalize_domains(domains: List[str]) -> List[str]:
+    normalized = [normalize_host(domain) for domain in domains]
+    if None in normalized:
+        raise HTTPException(status_code=400, detail="Domains must be host names")
+    return sorted(set(normalized))
+
+
+def check_domains_free(cursor, domains: List[str], tenant_id: str = None):
+    if not domains:
+        return
+    cursor.execute(
+        "SELECT slug FROM tenants WHERE domains && %s AND id IS DISTINCT FROM %s",
+        (domains, tenant_id)
+    )
+    taken = cursor.fetchone()
+    if taken:
+        raise HTTPException(status_code=409, detail=f"A domain is already used by tenant {taken['slug']}")
+
+
+def get_tenant_or_404(cursor, tenant_id: str) -> dict:
+    cursor.execute("SELECT * FROM tenants WHERE id = %s", (tenant_id,))
+    tenant = cursor.fetchone()
+    if not tenant:
+        raise HTTPException(status_code=404, detail="Tenant not found")
+    return dict(tenant)
+
+
+@router.get("/", response_model=List[TenantResponse])
+async def list_tenants(admin_user: dict = Depends(get_deployment_admin)):
+    """List publications hosted on this deployment (admin only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute("SELECT * FROM tenants ORDER BY name")
+            return [TenantResponse(**dict(row)) for row in cursor.fetchall()]
+    except Exception as e:
+        logger.error(f"List tenants error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve tenants")
+
+
+@router.post("/", response_model=TenantResponse, status_code=status.HTTP_201_CREATED)
+async def create_tenant(tenant_data: TenantCreate, admin_user: dict = Depends(get_deployment_admin)):
+    """Create a publication on its own domains, with quotas and branding (admin only)"""
+    domains = normalize_domains(tenant_data.domains)
+    try:
+        with get_postgres_cursor() as cursor:
+            check_domains_free(cursor, domains)
+            cursor.execute("""
+                INSERT INTO tenants (slug, name, domains, quotas, branding, created_by)
+                VALUES (%s, %s, %s, %s, %s, %s)
+                RETURNING *
+            """, (
+                tenant_data.slug, tenant_data.name, domains, Json(tenant_data.quotas.model_dump()),
+                Json(tenant_data.branding.model_dump()), admin_user['id']
+            ))
+            tenant = dict(cursor.fetchone())
+            record_privileged(cursor, admin_user, 'tenant_created', 'tenant', tenant['id'], after=tenant)
+
+        tenancy.invalidate()
+        if domains:
+            purge_tenant(str(tenant['id']), domains_changed=True)
+        logger.info(f"Tenant {tenant['slug']} created by {admin_user['id']}")
+        return TenantResponse(**tenant)
+    except HTTPException:
+        raise
+    except psycopg2.errors.UniqueViolation:
+        raise HTTPException(status_code=409, detail="Slug already in use")
+    except Exception as e:
+        logger.error(f"Create tenant error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to create tenant")
+
+
+@router.get("/isolation", response_model=TenantIsolationResponse)
+async def check_isolation(admin_user: dict = Depends(get_deployment_admin)):
+    """Check that publications can't read each other's rows or decrypt each other's values (admin only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            report = tenancy.isolation_report(cursor)
+        if not report['isolated']:
+            logger.warning(f"Tenant isolation problems: {'; '.join(report['problems'])}")
+        return TenantIsolationResponse(**report)
+    except Exception as e:
+        logger.error(f"Check tenant isolation error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to check tenant isolation")
+
+
+@router.get("/{tenant_id}", response_model=TenantResponse)
+async def get_tenant(tenant_id: str, admin_user: dict = Depends(get_deployment_admin)):
+    """Get a publication with its usage against its quotas (admin only)"""
+    try:
+        # Counting another publication's rows takes a connection that sees past row-level security
+        with tenancy.unscoped(), get_postgres_cursor() as cursor:
+            tenant = get_tenant_or_404(cursor, tenant_id)
+            usage = tenancy.usage(cursor, tenant_id)
+        usage['requests_this_minute'] = tenancy.requests_this_minute(tenant_id)
+        return TenantResponse(**tenant, usage=usage)
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Get tenant error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve tenant")
+
+
+@router.patch("/{tenant_id}", response_model=TenantResponse)
+async def update_tenant(tenant_id: str, update: TenantUpdate, admin_user: dict = Depends(get_deployment_admin)):
+    """Rename a publication, change its domains, quotas or branding, or deactivate it (admin only)"""
+    update_data = update.model_dump(exclude_unset=True)
+    if not update_data:
+        raise HTTPException(status_code=400, detail="No valid fields to update")
+    if 'domains' in update_data:
+        update_data['domains'] = normalize_domains(update_data['domains'] or [])
+    for field in ('quotas', 'branding'):
+        if field in update_data:
+            update_data[field] = Json(update_data[field] or {})
+
+    try:
+        with get_postgres_cursor() as cursor:
+            existing = get_tenant_or_404(cursor, tenant_id)
+            if 'domains' in update_data:
+                check_domains_free(cursor, update_data['domains'], tenant_id)
+            assignments = ', '.join(f"{field} = %s" for field in update_data)
+            cursor.execute(
+                f"UPDATE tenants SET {assignments}, updated_at = NOW() WHERE id = %s RETURNING *",
+                list(update_data.values()) + [tenant_id]
+            )
+            tenant = dict(cursor.fetchone())
+            record_privileged(
+                cursor, admin_user, 'tenant_updated', 'tenant', tenant_id,
+                before={field: existing[field] for field in update_data},
+                after={field: tenant[field] for field in update_data}
+            )
+
+        tenancy.invalidate()
+        purge_tenant(
+            tenant_id,
+            domains_changed=existing['domains'] != tenant['domains'] or existing['is_active'] != tenant['is_active']
+        )
+        logger.info(f"Tenant {tenant['slug']} updated by {admin_user['id']}: {', '.join(update_data)}")
+        return TenantResponse(**tenant)
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Update tenant error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to update tenant")
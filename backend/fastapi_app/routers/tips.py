@@ -0,0 +1,163 @@
+"""
+Tipping routes for FastAPI backend
+"""
+
+import sys
+import os
+from typing import Optional
+from fastapi import APIRouter, HTTPException, Depends, Query, Request, status
+import logging
+import requests
+
+sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
+
+from shared.database import get_postgres_cursor
+from shared.models import PayoutRequestCreate, TipCreate, TipRail
+from shared.tipping import (
+    TipInvalid, WebhookInvalid, author_balance, create_card_tip, handle_stripe_event, list_payouts, list_tips,
+    record_onchain_tip, recipient, request_payout, serialize, verify_stripe_event
+)
+from ..dependencies import get_current_user
+
+router = APIRouter()
+logger = logging.getLogger(__name__)
+
+
+@router.post("/", status_code=status.HTTP_201_CREATED)
+async def create_tip(tip: TipCreate, current_user: dict = Depends(get_current_user)):
+    """Tip an author by card or on-chain
+
+    Card tips return a Stripe `client_secret` to confirm the payment with;
+    the tip settles when Stripe reports the payment. On-chain tips take the
+    hash of a transfer from your wallet to the author's and settle once it
+    has PAYWALL_MIN_CONFIRMATIONS confirmations.
+    """
+    try:
+        article_id = str(tip.article_id) if tip.article_id else None
+        with get_postgres_cursor() as cursor:
+            author = recipient(cursor, str(tip.author_id) if tip.author_id else None, article_id)
+            if str(author['id']) == str(current_user['id']):
+                raise HTTPException(status_code=400, detail="You can't tip yourself")
+
+            if tip.rail == TipRail.ONCHAIN:
+                created = record_onchain_tip(
+                    cursor, author, current_user, article_id, tip.transaction_hash, tip.message, tip.anonymous
+                )
+            else:
+                created = create_card_tip(
+                    cursor, author, current_user, article_id, tip.amount, tip.currency, tip.message, tip.anonymous
+                )
+
+        return {"success": True, "tip": serialize(created)}
+    except HTTPException:
+        raise
+    except TipInvalid as e:
+        raise HTTPException(status_code=400, detail=str(e))
+    except requests.RequestException as e:
+        logger.error(f"Stripe request for tip failed: {e}")
+        raise HTTPException(status_code=502, detail="The payment provider could not be reached")
+    except Exception as e:
+        logger.error(f"Create tip error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to create tip")
+
+
+@router.get("/received")
+async def get_tips_received(
+    limit: int = Query(50, ge=1, le=200),
+    offset: int = Query(0, ge=0),
+    current_user: dict = Depends(get_current_user)
+):
+    """Tips the caller has received, newest first; anonymous tippers are not shown"""
+    try:
+        with get_postgres_cursor() as cursor:
+            tips = list_tips(cursor, author_id=str(current_user['id']), limit=limit, offset=offset)
+        return {"success": True, "tips": tips}
+    except Exception as e:
+        logger.error(f"List tips received error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve tips")
+
+
+@router.get("/sent")
+async def get_tips_sent(
+    limit: int = Query(50, ge=1, le=200),
+    offset: int = Query(0, ge=0),
+    current_user: dict = Depends(get_current_user)
+):
+    """Tips the caller has sent, newest first"""
+    try:
+        with get_postgres_cursor() as cursor:
+            tips = list_tips(cursor, tipper_id=str(current_user['id']), limit=limit, offset=offset)
+        return {"success": True, "tips": tips}
+    except Exception as e:
+        logger.error(f"List tips sent error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve tips")
+
+
+@router.get("/balance")
+async def get_balance(current_user: dict = Depends(get_current_user)):
+    """The caller's earnings per currency: available to pay out, in payouts, paid out and received on-chain"""
+    try:
+        with get_postgres_cursor() as cursor:
+            balances = author_balance(cursor, str(current_user['id']))
+        return {"success": True, "balances": balances}
+    except Exception as e:
+        logger.error(f"Get tip balance error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve balance")
+
+
+@router.post("/payouts", status_code=status.HTTP_201_CREATED)
+async def create_payout(payout: PayoutRequestCreate, current_user: dict = Depends(get_current_user)):
+    """Ask for part of the available balance to be paid out; an administrator sends it"""
+    try:
+        with get_postgres_cursor() as cursor:
+            created = request_payout(
+                cursor, str(current_user['id']), payout.amount, payout.currency, payout.destination
+            )
+        logger.info(f"Payout {created['id']} requested by {current_user['id']}")
+        return {"success": True, "payout": created}
+    except TipInvalid as e:
+        raise HTTPException(status_code=400, detail=str(e))
+    except Exception as e:
+        logger.error(f"Request payout error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to request payout")
+
+
+@router.get("/payouts")
+async def get_payouts(
+    payout_status: Optional[str] = Query(None, alias="status", pattern="^(requested|paid|rejected)$"),
+    current_user: dict = Depends(get_current_user)
+):
+    """The caller's payout requests, newest first"""
+    try:
+        with get_postgres_cursor() as cursor:
+            payouts = list_payouts(cursor, author_id=str(current_user['id']), status=payout_status)
+        return {"success": True, "payouts": payouts}
+    except Exception as e:
+        logger.error(f"List payouts error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve payouts")
+
+
+@router.post("/webhooks/stripe")
+async def stripe_webhook(request: Request):
+    """Stripe payment events: settle, fail or refund the tips they concern
+
+    Configure the endpoint in Stripe for `payment_intent.succeeded`,
+    `payment_intent.payment_failed`, `payment_intent.canceled` and
+    `charge.refunded`, signed with STRIPE_WEBHOOK_SECRET.
+    """
+    payload = await request.body()
+    try:
+        event = verify_stripe_event(payload, request.headers.get('stripe-signature'))
+    except (WebhookInvalid, ValueError) as e:
+        logger.warning(f"Rejected Stripe webhook: {e}")
+        raise HTTPException(status_code=400, detail="Invalid webhook signature")
+
+    try:
+        with get_postgres_cursor() as cursor:
+            outcome = handle_stripe_event(cursor, event)
+        logger.info(f"Stripe event {event.get('id')} ({event.get('type')}): {outcome}")
+        return {"received": True, "outcome": outcome}
+    except Exception as e:
+        # A non-2xx response makes Stripe retry, which is safe since events apply once
+        logger.error(f"Stripe webhook error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to process webhook")
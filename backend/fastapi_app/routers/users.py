@@ -4,20 +4,42 @@ User management routes for FastAPI backend
 
 import sys
 import os
-from typing import List
-from fastapi import APIRouter, HTTPException, Depends, status, Query
+from datetime import datetime
+from typing import List, Optional
+from urllib.parse import quote
+from fastapi import APIRouter, HTTPException, Depends, Request, status, Query
+from fastapi.responses import RedirectResponse
 import logging
+import psycopg2
 
 sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
 
 from shared.database import get_postgres_cursor
-from shared.models import UserUpdate, UserResponse, PaginatedResponse
+from shared.models import (
+    UserUpdate, UserResponse, PaginatedResponse, FollowListResponse, FollowedAuthor, AccountDeletionRequest,
+    AuthorKeyCreate, AuthorKeyResponse, WalletChallengeRequest, WalletChallengeResponse, WalletLinkCreate,
+    WalletResponse, AccountMigrationImport, AccountMigrationComplete, BlockedUser, ShadowBanCreate, ShadowBannedUser
+)
 from shared.utils import paginate_query_results
-from ..dependencies import get_current_user, get_admin_user
+from shared.tokens import get_usage, get_issued_api_keys
+from shared.sessions import list_sessions, revoke_session, revoke_other_sessions
+from shared.social_login import SocialLoginError, list_identities, unlink_identity
+from shared.auth import verify_password
+from shared.account_deletion import GRACE_DAYS, end_sessions, request_deletion
+from shared.content_signatures import SignatureInvalid, register_key
+from shared.paywall import list_purchases, list_subscriptions
+from shared.wallets import WalletError, create_challenge, link_wallet, list_wallets, set_primary, unlink_wallet
+from shared.account_migration import MigrationError, complete_move, export_account, import_account, migrations_for
+from shared.aliases import AliasLoop, resolve_user
+from shared.audit import client_of, record_privileged, record_security_event, security_events
+from shared import coauthors, feed_versions, permissions, reputation, visibility
+from ..dependencies import get_current_user, require_permission
 
 router = APIRouter()
 logger = logging.getLogger(__name__)
 
+USER_UPDATE_FIELDS = ('username', 'email', 'role', 'anonymous_mode', 'profile_data', 'preferences')
+
 
 @router.get("/", response_model=PaginatedResponse)
 async def get_users(
@@ -25,9 +47,9 @@ async def get_users(
     per_page: int = Query(20, ge=1, le=100),
     search: str = Query(""),
     role: str = Query(""),
-    admin_user: dict = Depends(get_admin_user)
+    admin_user: dict = Depends(require_permission(permissions.USER_MANAGE))
 ):
-    """Get list of users (admin only)"""
+    """Get list of users (`user:manage`)"""
     try:
         # Build query
         query = "SELECT * FROM users WHERE is_active = true"
@@ -64,12 +86,81 @@ async def get_users(
         )
 
 
+@router.get("/by-username/{username}")
+async def get_profile_by_username(username: str, request: Request):
+    """An author's public profile
+
+    Old usernames answer with a 301 to the current one, and accounts that
+    moved to another instance with a 301 to where they went.
+    """
+    try:
+        with get_postgres_cursor() as cursor:
+            resolved = resolve_user(cursor, username)
+            if not resolved:
+                raise HTTPException(status_code=404, detail="User not found")
+            if 'moved_to' in resolved:
+                return RedirectResponse(resolved['moved_to'], status_code=status.HTTP_301_MOVED_PERMANENTLY)
+            if resolved['username'] != username:
+                location = f"{request.url.path.rsplit('/', 1)[0]}/{quote(resolved['username'])}"
+                return RedirectResponse(location, status_code=status.HTTP_301_MOVED_PERMANENTLY)
+
+            user = resolved['user']
+            cursor.execute("""
+                SELECT COUNT(*) AS count FROM articles
+                WHERE (author_id = %s OR %s = ANY(coauthor_ids)) AND status = 'published'
+                  AND NOT COALESCE(anonymous_author, false)
+            """, (user['id'], user['id']))
+            article_count = cursor.fetchone()['count']
+
+        profile = user.get('profile_data') or {}
+        return {
+            "success": True,
+            "id": str(user['id']),
+            "username": user['username'],
+            "display_name": profile.get('display_name'),
+            "bio": profile.get('bio'),
+            "avatar_url": profile.get('avatar_url'),
+            "reputation_score": float(user['reputation_score'] or 0),
+            "article_count": article_count,
+            "created_at": user['created_at'],
+        }
+    except HTTPException:
+        raise
+    except AliasLoop as e:
+        logger.warning(f"Redirect loop resolving user {username}: {e}")
+        raise HTTPException(status_code=status.HTTP_508_LOOP_DETECTED, detail="Redirect loop")
+    except Exception as e:
+        logger.error(f"Get profile error: {e}")
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail="Failed to retrieve profile"
+        )
+
+
+@router.get("/shadow-banned", response_model=List[ShadowBannedUser])
+async def get_shadow_banned_users(
+    limit: int = Query(50, ge=1, le=200),
+    offset: int = Query(0, ge=0),
+    moderator: dict = Depends(require_permission(permissions.USER_BAN))
+):
+    """Shadow-banned users, most recently banned first (`user:ban`)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            return [ShadowBannedUser(**row) for row in visibility.list_shadow_banned(cursor, limit, offset)]
+    except Exception as e:
+        logger.error(f"Get shadow-banned users error: {e}")
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail="Failed to retrieve shadow-banned users"
+        )
+
+
 @router.get("/{user_id}", response_model=UserResponse)
 async def get_user(user_id: str, current_user: dict = Depends(get_current_user)):
     """Get user by ID"""
     try:
-        # Users can only view their own profile unless they're admin
-        if user_id != current_user.get('id') and current_user.get('role') != 'administrator':
+        # Users can only view their own profile unless they manage users
+        if user_id != current_user.get('id') and not permissions.allowed(current_user, permissions.USER_MANAGE):
             raise HTTPException(
                 status_code=status.HTTP_403_FORBIDDEN,
                 detail="Access denied"
@@ -104,13 +195,14 @@ async def get_user(user_id: str, current_user: dict = Depends(get_current_user))
 async def update_user(
     user_id: str, 
     user_update: UserUpdate, 
+    request: Request,
     current_user: dict = Depends(get_current_user)
 ):
     """Update user information"""
     try:
-        # Users can only update their own profile unless they're admin
-        is_admin = current_user.get('role') == 'administrator'
-        if user_id != current_user.get('id') and not is_admin:
+        # Users can only update their own profile unless they manage users
+        can_manage = permissions.allowed(current_user, permissions.USER_MANAGE)
+        if user_id != current_user.get('id') and not can_manage:
             raise HTTPException(
                 status_code=status.HTTP_403_FORBIDDEN,
                 detail="Access denied"
@@ -122,7 +214,7 @@ async def update_user(
         
         update_data = user_update.dict(exclude_unset=True)
         for field, value in update_data.items():
-            if field in ['username', 'email', 'role', 'anonymous_mode', 'profile_data', 'preferences']:
+            if field in USER_UPDATE_FIELDS:
                 update_fields.append(f"{field} = %s")
                 params.append(value)
         
@@ -132,8 +224,8 @@ async def update_user(
                 detail="No valid fields to update"
             )
         
-        # Non-admin users cannot change role
-        if 'role' in update_data and not is_admin:
+        # A role carries permissions, so changing one takes permission to change those
+        if 'role' in update_data and not permissions.allowed(current_user, permissions.PERMISSIONS_MANAGE):
             raise HTTPException(
                 status_code=status.HTTP_403_FORBIDDEN,
                 detail="Cannot change role"
@@ -145,7 +237,11 @@ async def update_user(
         
         query = f"UPDATE users SET {', '.join(update_fields)} WHERE id = %s RETURNING *"
         
+        changed = [field for field in update_data if field in USER_UPDATE_FIELDS]
         with get_postgres_cursor() as cursor:
+            cursor.execute(f"SELECT {', '.join(changed)} FROM users WHERE id = %s", (user_id,))
+            previous = cursor.fetchone()
+            previous_email = previous['email'] if previous and 'email' in update_data else None
             cursor.execute(query, params)
             updated_user = cursor.fetchone()
             
@@ -154,7 +250,20 @@ async def update_user(
                     status_code=status.HTTP_404_NOT_FOUND,
                     detail="User not found"
                 )
+
+            role_changed = 'role' in update_data and previous['role'] != updated_user['role']
+            if can_manage or role_changed:
+                ip_address, user_agent = client_of(request)
+                record_privileged(
+                    cursor, current_user, 'user_role_changed' if role_changed else 'user_updated', 'user', user_id,
+                    before=dict(previous), after={field: updated_user[field] for field in changed},
+                    ip_address=ip_address, user_agent=user_agent
+                )
         
+        if previous_email and previous_email != updated_user['email']:
+            record_security_event(user_id, 'email_changed', *client_of(request), details={
+                'previous_email': previous_email, 'changed_by': str(current_user['id'])
+            })
         return UserResponse(**dict(updated_user))
     
     except HTTPException:
@@ -182,12 +291,20 @@ async def get_user_articles(
             status_filter = "published"  # Force published for other users
         
         with get_postgres_cursor() as cursor:
-            query = "SELECT * FROM articles WHERE author_id = %s AND status = %s ORDER BY created_at DESC"
-            cursor.execute(query, (user_id, status_filter))
-            articles = cursor.fetchall()
+            # A shadow-banned or blocked author appears to have published nothing
+            if user_id in visibility.hidden_authors(cursor, current_user):
+                articles = []
+            else:
+                # With the articles they co-author
+                query = """
+                    SELECT * FROM articles WHERE (author_id = %s OR %s = ANY(coauthor_ids)) AND status = %s
+                    ORDER BY created_at DESC
+                """
+                cursor.execute(query, (user_id, user_id, status_filter))
+                articles = coauthors.attach(cursor, cursor.fetchall())
         
         from shared.models import ArticleResponse
-        article_responses = [ArticleResponse(**dict(article)) for article in articles]
+        article_responses = [ArticleResponse(**article) for article in articles]
         paginated = paginate_query_results([a.dict() for a in article_responses], page, per_page)
         
         return PaginatedResponse(**paginated)
@@ -255,13 +372,16 @@ async def get_user_stats(user_id: str, current_user: dict = Depends(get_current_
                     COALESCE(SUM(like_count), 0) as total_likes,
                     COALESCE(SUM(view_count), 0) as total_views
                 FROM articles 
-                WHERE author_id = %s AND status = 'published'
-            """, (user_id,))
+                WHERE (author_id = %s OR %s = ANY(coauthor_ids)) AND status = 'published'
+            """, (user_id, user_id))
             
             article_stats = cursor.fetchone()
             
-            # Get follower count (placeholder - not implemented in schema)
-            followers = 0
+            cursor.execute(
+                "SELECT COUNT(*) as followers FROM user_follows WHERE following_id = %s",
+                (user_id,)
+            )
+            followers = cursor.fetchone()['followers'] or 0
             
             return {
                 "success": True,
@@ -281,12 +401,95 @@ async def get_user_stats(user_id: str, current_user: dict = Depends(get_current_
         )
 
 
+@router.get("/{user_id}/reputation/history")
+async def get_reputation_history(
+    user_id: str,
+    event: Optional[str] = Query(
+        None, pattern="^(article_published|upvote_received|report_upheld|report_confirmed|report_dismissed|fact_verified|decay)$"
+    ),
+    limit: int = Query(50, ge=1, le=200),
+    offset: int = Query(0, ge=0),
+    current_user: dict = Depends(get_current_user)
+):
+    """A user's reputation score and every change to it, newest first, with totals per event"""
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute("SELECT reputation_score FROM users WHERE id = %s AND is_active = true", (user_id,))
+            user = cursor.fetchone()
+            if not user:
+                raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="User not found")
+
+            changes = reputation.history(cursor, user_id, event=event, limit=limit, offset=offset)
+            totals = reputation.summary(cursor, user_id)
+
+        return {
+            "success": True,
+            "reputation_score": float(user['reputation_score'] or 0),
+            "totals": totals,
+            "history": changes
+        }
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Get reputation history error: {e}")
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail="Failed to retrieve reputation history"
+        )
+
+
+@router.delete("/me")
+async def delete_own_account(
+    request: Request,
+    deletion: Optional[AccountDeletionRequest] = None,
+    current_user: dict = Depends(get_current_user)
+):
+    """Delete your account after a grace period
+
+    The account is deactivated and signed out everywhere at once; signing in
+    again before the grace period ends cancels the deletion. Afterwards your
+    drafts and personal data are deleted and your published articles and
+    comments remain without your name.
+    """
+    if current_user.get('password_hash') and not verify_password(
+        deletion.password if deletion else '', current_user['password_hash']
+    ):
+        raise HTTPException(status_code=status.HTTP_403_FORBIDDEN, detail="Password confirmation required")
+
+    try:
+        user_id = str(current_user['id'])
+        with get_postgres_cursor() as cursor:
+            scheduled_for = request_deletion(cursor, user_id)
+        if not scheduled_for:
+            raise HTTPException(status_code=status.HTTP_404_NOT_FOUND, detail="User not found")
+        end_sessions(user_id)
+        record_security_event(user_id, 'account_deletion_requested', *client_of(request), details={
+            'deletion_scheduled_for': scheduled_for.isoformat()
+        })
+
+        logger.info(f"User {user_id} requested account deletion, scheduled for {scheduled_for.isoformat()}")
+        return {
+            "success": True,
+            "message": f"Account deactivated and will be deleted in {GRACE_DAYS} days; sign in before then to cancel",
+            "deletion_scheduled_for": scheduled_for.isoformat(),
+        }
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Delete account error: {e}")
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail="Failed to delete account"
+        )
+
+
 @router.delete("/{user_id}")
 async def delete_user(user_id: str, current_user: dict = Depends(get_current_user)):
     """Delete user (soft delete)"""
     try:
-        # Users can delete their own account, admins can delete any
-        if user_id != current_user.get('id') and current_user.get('role') != 'administrator':
+        # Users can delete their own account, those managing users can delete any
+        can_manage = permissions.allowed(current_user, permissions.USER_MANAGE)
+        if user_id != current_user.get('id') and not can_manage:
             raise HTTPException(
                 status_code=status.HTTP_403_FORBIDDEN,
                 detail="Access denied"
@@ -294,7 +497,7 @@ async def delete_user(user_id: str, current_user: dict = Depends(get_current_use
         
         with get_postgres_cursor() as cursor:
             cursor.execute(
-                "UPDATE users SET is_active = false, updated_at = %s WHERE id = %s RETURNING id",
+                "UPDATE users SET is_active = false, updated_at = %s WHERE id = %s RETURNING id, username, role",
                 ('now()', user_id)
             )
             
@@ -304,6 +507,13 @@ async def delete_user(user_id: str, current_user: dict = Depends(get_current_use
                     status_code=status.HTTP_404_NOT_FOUND,
                     detail="User not found"
                 )
+
+            if can_manage:
+                record_privileged(
+                    cursor, current_user, 'user_deleted', 'user', user_id,
+                    before={'username': result['username'], 'role': result['role'], 'is_active': True},
+                    after={'is_active': False}
+                )
         
         return {"success": True, "message": "User deleted successfully"}
     
@@ -314,4 +524,749 @@ async def delete_user(user_id: str, current_user: dict = Depends(get_current_use
         raise HTTPException(
             status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
             detail="Failed to delete user"
-        )
\ No newline at end of file
+        )
+
+@router.post("/{user_id}/follow")
+async def follow_user(user_id: str, current_user: dict = Depends(get_current_user)):
+    """Follow an author"""
+    try:
+        follower_id = str(current_user['id'])
+        if user_id == follower_id:
+            raise HTTPException(
+                status_code=status.HTTP_400_BAD_REQUEST,
+                detail="Cannot follow yourself"
+            )
+        
+        with get_postgres_cursor() as cursor:
+            cursor.execute(
+                "SELECT id FROM users WHERE id = %s AND is_active = true",
+                (user_id,)
+            )
+            if not cursor.fetchone():
+                raise HTTPException(
+                    status_code=status.HTTP_404_NOT_FOUND,
+                    detail="User not found"
+                )
+            
+            cursor.execute("""
+                INSERT INTO user_follows (follower_id, following_id)
+                VALUES (%s, %s)
+                ON CONFLICT (follower_id, following_id) DO NOTHING
+            """, (follower_id, user_id))
+        
+        feed_versions.bump(follower_id)
+        return {"success": True, "following": True, "message": "User followed"}
+    
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Follow user error: {e}")
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail="Failed to follow user"
+        )
+
+
+@router.delete("/{user_id}/follow")
+async def unfollow_user(user_id: str, current_user: dict = Depends(get_current_user)):
+    """Unfollow an author"""
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute(
+                "DELETE FROM user_follows WHERE follower_id = %s AND following_id = %s",
+                (str(current_user['id']), user_id)
+            )
+        
+        feed_versions.bump(str(current_user['id']))
+        return {"success": True, "following": False, "message": "User unfollowed"}
+    
+    except Exception as e:
+        logger.error(f"Unfollow user error: {e}")
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail="Failed to unfollow user"
+        )
+
+
+@router.post("/{user_id}/block")
+async def block_user(user_id: str, current_user: dict = Depends(get_current_user)):
+    """Block a user: their articles and comments are hidden from you, and you stop following them"""
+    try:
+        blocker_id = str(current_user['id'])
+        if user_id == blocker_id:
+            raise HTTPException(
+                status_code=status.HTTP_400_BAD_REQUEST,
+                detail="Cannot block yourself"
+            )
+
+        with get_postgres_cursor() as cursor:
+            cursor.execute("SELECT id FROM users WHERE id = %s", (user_id,))
+            if not cursor.fetchone():
+                raise HTTPException(
+                    status_code=status.HTTP_404_NOT_FOUND,
+                    detail="User not found"
+                )
+            visibility.block(cursor, blocker_id, user_id)
+
+        return {"success": True, "blocked": True, "message": "User blocked"}
+
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Block user error: {e}")
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail="Failed to block user"
+        )
+
+
+@router.delete("/{user_id}/block")
+async def unblock_user(user_id: str, current_user: dict = Depends(get_current_user)):
+    """Unblock a user"""
+    try:
+        with get_postgres_cursor() as cursor:
+            visibility.unblock(cursor, str(current_user['id']), user_id)
+
+        return {"success": True, "blocked": False, "message": "User unblocked"}
+
+    except Exception as e:
+        logger.error(f"Unblock user error: {e}")
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail="Failed to unblock user"
+        )
+
+
+@router.get("/me/blocks", response_model=List[BlockedUser])
+async def get_blocked_users(
+    limit: int = Query(50, ge=1, le=200),
+    offset: int = Query(0, ge=0),
+    current_user: dict = Depends(get_current_user)
+):
+    """Users you have blocked, most recent first"""
+    try:
+        with get_postgres_cursor() as cursor:
+            blocks = visibility.list_blocks(cursor, str(current_user['id']), limit, offset)
+        return [BlockedUser(**row) for row in blocks]
+    except Exception as e:
+        logger.error(f"Get blocked users error: {e}")
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail="Failed to retrieve blocked users"
+        )
+
+
+@router.post("/{user_id}/shadow-ban", response_model=ShadowBannedUser)
+async def shadow_ban_user(user_id: str, ban: Optional[ShadowBanCreate] = None,
+                          moderator: dict = Depends(require_permission(permissions.USER_BAN))):
+    """Shadow-ban a user: their content stays visible to them but to no one else (`user:ban`)"""
+    try:
+        if user_id == str(moderator['id']):
+            raise HTTPException(
+                status_code=status.HTTP_400_BAD_REQUEST,
+                detail="Cannot shadow-ban yourself"
+            )
+
+        with get_postgres_cursor() as cursor:
+            cursor.execute("SELECT role FROM users WHERE id = %s", (user_id,))
+            target = cursor.fetchone()
+            if not target:
+                raise HTTPException(
+                    status_code=status.HTTP_404_NOT_FOUND,
+                    detail="User not found"
+                )
+            if target['role'] == 'administrator':
+                raise HTTPException(
+                    status_code=status.HTTP_403_FORBIDDEN,
+                    detail="Administrators cannot be shadow-banned"
+                )
+            banned = visibility.shadow_ban(cursor, user_id, moderator, ban.reason if ban else None)
+
+        logger.info(f"User {user_id} shadow-banned by {moderator['id']}")
+        return ShadowBannedUser(**banned, shadow_banned_by_username=moderator['username'])
+
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Shadow-ban user error: {e}")
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail="Failed to shadow-ban user"
+        )
+
+
+@router.delete("/{user_id}/shadow-ban")
+async def lift_shadow_ban(user_id: str, moderator: dict = Depends(require_permission(permissions.USER_BAN))):
+    """Lift a shadow ban, making the user's content visible again (`user:ban`)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            if not visibility.lift_shadow_ban(cursor, user_id, moderator):
+                raise HTTPException(
+                    status_code=status.HTTP_404_NOT_FOUND,
+                    detail="User is not shadow-banned"
+                )
+
+        logger.info(f"Shadow ban on user {user_id} lifted by {moderator['id']}")
+        return {"success": True, "shadow_banned": False, "message": "Shadow ban lifted"}
+
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Lift shadow ban error: {e}")
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail="Failed to lift shadow ban"
+        )
+
+
+@router.get("/me/api-usage")
+async def get_api_usage(
+    limit: int = Query(50, ge=1, le=200),
+    current_user: dict = Depends(get_current_user)
+):
+    """Recent authenticated requests, active sessions and issued API keys for the caller"""
+    try:
+        usage = get_usage(str(current_user['id']), limit)
+        with get_postgres_cursor() as cursor:
+            api_keys = get_issued_api_keys(cursor, str(current_user['id']))
+        
+        return {"success": True, **usage, "api_keys": api_keys}
+    
+    except Exception as e:
+        logger.error(f"API usage error: {e}")
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail="Failed to get API usage"
+        )
+
+
+@router.get("/me/permissions")
+async def get_my_permissions(current_user: dict = Depends(get_current_user)):
+    """The permissions the caller holds, through their role and their own grants"""
+    try:
+        return {
+            "success": True,
+            "role": current_user.get('role'),
+            "permissions": sorted(permissions.effective(current_user)),
+        }
+    except Exception as e:
+        logger.error(f"Get permissions error: {e}")
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail="Failed to get permissions"
+        )
+
+
+@router.get("/me/security-events")
+async def get_security_events(
+    limit: int = Query(50, ge=1, le=200),
+    before: Optional[datetime] = Query(None, description="Only events before this time, for paging"),
+    current_user: dict = Depends(get_current_user)
+):
+    """Recent sign-ins, failed sign-in attempts and changes to the caller's account security, with device and IP"""
+    try:
+        with get_postgres_cursor() as cursor:
+            events = security_events(cursor, str(current_user['id']), limit, before)
+        return {
+            "success": True,
+            "events": events,
+            "next_before": events[-1]['created_at'].isoformat() if len(events) == limit else None,
+        }
+    except Exception as e:
+        logger.error(f"Security events error: {e}")
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail="Failed to get security events"
+        )
+
+
+@router.get("/me/sessions")
+async def get_sessions(request: Request, current_user: dict = Depends(get_current_user)):
+    """Devices the caller is signed in on"""
+    try:
+        sessions = list_sessions(str(current_user['id']), request.state.token_claims.get('sid'))
+        return {"success": True, "sessions": sessions}
+    except Exception as e:
+        logger.error(f"List sessions error: {e}")
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail="Failed to get sessions"
+        )
+
+
+@router.delete("/me/sessions/{session_id}")
+async def delete_session(session_id: str, request: Request, current_user: dict = Depends(get_current_user)):
+    """Sign out one session; its tokens stop working immediately"""
+    try:
+        if not revoke_session(str(current_user['id']), session_id):
+            raise HTTPException(status_code=404, detail="Session not found")
+        record_security_event(current_user['id'], 'session_revoked', *client_of(request), details={'session_id': session_id})
+        return {"success": True, "message": "Session revoked"}
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Revoke session error: {e}")
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail="Failed to revoke session"
+        )
+
+
+@router.delete("/me/sessions")
+async def delete_other_sessions(request: Request, current_user: dict = Depends(get_current_user)):
+    """Sign out every session except the current one"""
+    try:
+        revoked = revoke_other_sessions(str(current_user['id']), request.state.token_claims.get('sid'))
+        record_security_event(current_user['id'], 'sessions_revoked', *client_of(request), details={'count': revoked})
+        return {"success": True, "revoked": revoked}
+    except Exception as e:
+        logger.error(f"Revoke sessions error: {e}")
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail="Failed to revoke sessions"
+        )
+
+
+@router.post("/me/followed-categories/{category}")
+async def follow_category(category: str, current_user: dict = Depends(get_current_user)):
+    """Subscribe to a category"""
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute("""
+                INSERT INTO category_follows (user_id, category)
+                VALUES (%s, %s)
+                ON CONFLICT (user_id, category) DO NOTHING
+            """, (str(current_user['id']), category))
+        
+        feed_versions.bump(str(current_user['id']))
+        return {"success": True, "following": True, "message": f"Following category {category}"}
+    
+    except Exception as e:
+        logger.error(f"Follow category error: {e}")
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail="Failed to follow category"
+        )
+
+
+@router.delete("/me/followed-categories/{category}")
+async def unfollow_category(category: str, current_user: dict = Depends(get_current_user)):
+    """Unsubscribe from a category"""
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute(
+                "DELETE FROM category_follows WHERE user_id = %s AND category = %s",
+                (str(current_user['id']), category)
+            )
+        
+        feed_versions.bump(str(current_user['id']))
+        return {"success": True, "following": False, "message": f"Unfollowed category {category}"}
+    
+    except Exception as e:
+        logger.error(f"Unfollow category error: {e}")
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail="Failed to unfollow category"
+        )
+
+
+@router.get("/{user_id}/followers", response_model=FollowListResponse)
+async def get_followers(
+    user_id: str,
+    limit: int = Query(50, ge=1, le=200),
+    offset: int = Query(0, ge=0),
+    current_user: dict = Depends(get_current_user)
+):
+    """Get users following the given user"""
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute("""
+                SELECT u.id, u.username, uf.created_at as followed_at
+                FROM user_follows uf
+                JOIN users u ON u.id = uf.follower_id
+                WHERE uf.following_id = %s AND u.is_active = true
+                ORDER BY uf.created_at DESC
+                LIMIT %s OFFSET %s
+            """, (user_id, limit, offset))
+            followers = cursor.fetchall()
+            
+            cursor.execute("""
+                SELECT COUNT(*) as total FROM user_follows uf
+                JOIN users u ON u.id = uf.follower_id
+                WHERE uf.following_id = %s AND u.is_active = true
+            """, (user_id,))
+            total = cursor.fetchone()['total']
+        
+        return FollowListResponse(
+            user_id=user_id,
+            users=[FollowedAuthor(**dict(row)) for row in followers],
+            total=total
+        )
+    
+    except Exception as e:
+        logger.error(f"Get followers error: {e}")
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail="Failed to retrieve followers"
+        )
+
+
+@router.get("/{user_id}/following", response_model=FollowListResponse)
+async def get_following(
+    user_id: str,
+    limit: int = Query(50, ge=1, le=200),
+    offset: int = Query(0, ge=0),
+    current_user: dict = Depends(get_current_user)
+):
+    """Get authors and categories the given user follows"""
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute("""
+                SELECT u.id, u.username, uf.created_at as followed_at
+                FROM user_follows uf
+                JOIN users u ON u.id = uf.following_id
+                WHERE uf.follower_id = %s AND u.is_active = true
+                ORDER BY uf.created_at DESC
+                LIMIT %s OFFSET %s
+            """, (user_id, limit, offset))
+            following = cursor.fetchall()
+            
+            cursor.execute("""
+                SELECT COUNT(*) as total FROM user_follows uf
+                JOIN users u ON u.id = uf.following_id
+                WHERE uf.follower_id = %s AND u.is_active = true
+            """, (user_id,))
+            total = cursor.fetchone()['total']
+            
+            cursor.execute(
+                "SELECT category FROM category_follows WHERE user_id = %s ORDER BY created_at DESC",
+                (user_id,)
+            )
+            categories = [row['category'] for row in cursor.fetchall()]
+        
+        return FollowListResponse(
+            user_id=user_id,
+            users=[FollowedAuthor(**dict(row)) for row in following],
+            categories=categories,
+            total=total
+        )
+    
+    except Exception as e:
+        logger.error(f"Get following error: {e}")
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail="Failed to retrieve following"
+        )
+
+
+@router.get("/me/identities")
+async def get_identities(current_user: dict = Depends(get_current_user)):
+    """Google and GitHub accounts linked for social login"""
+    try:
+        with get_postgres_cursor() as cursor:
+            identities = list_identities(cursor, str(current_user['id']))
+        return {"success": True, "identities": identities}
+    except Exception as e:
+        logger.error(f"List identities error: {e}")
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail="Failed to get linked accounts"
+        )
+
+
+@router.delete("/me/identities/{provider}")
+async def delete_identity(provider: str, request: Request, current_user: dict = Depends(get_current_user)):
+    """Unlink a social login provider"""
+    try:
+        with get_postgres_cursor() as cursor:
+            if not unlink_identity(cursor, str(current_user['id']), provider):
+                raise HTTPException(status_code=404, detail="Linked account not found")
+        record_security_event(current_user['id'], 'identity_unlinked', *client_of(request), details={'provider': provider})
+        return {"success": True, "message": "Linked account removed"}
+    except HTTPException:
+        raise
+    except SocialLoginError as e:
+        raise HTTPException(status_code=e.status_code, detail=e.message)
+    except Exception as e:
+        logger.error(f"Unlink identity error: {e}")
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail="Failed to remove linked account"
+        )
+
+
+@router.get("/me/signing-keys", response_model=List[AuthorKeyResponse])
+async def get_signing_keys(current_user: dict = Depends(get_current_user)):
+    """Public keys the caller signs articles with, including revoked ones"""
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute("""
+                SELECT id, algorithm, public_key, fingerprint, label, created_at, revoked_at
+                FROM author_keys WHERE user_id = %s ORDER BY created_at
+            """, (str(current_user['id']),))
+            return [AuthorKeyResponse(**dict(key)) for key in cursor.fetchall()]
+    except Exception as e:
+        logger.error(f"List signing keys error: {e}")
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail="Failed to get signing keys"
+        )
+
+
+@router.post("/me/signing-keys", response_model=AuthorKeyResponse, status_code=status.HTTP_201_CREATED)
+async def add_signing_key(key_data: AuthorKeyCreate, request: Request, current_user: dict = Depends(get_current_user)):
+    """Register an Ed25519 or secp256k1 public key for signing articles"""
+    try:
+        with get_postgres_cursor() as cursor:
+            key = register_key(cursor, str(current_user['id']), key_data.algorithm, key_data.public_key, key_data.label)
+        record_security_event(
+            current_user['id'], 'signing_key_added', *client_of(request),
+            details={'algorithm': key['algorithm'], 'fingerprint': key['fingerprint']},
+            resource_type='author_key', resource_id=str(key['id'])
+        )
+        return AuthorKeyResponse(**key)
+    except SignatureInvalid as e:
+        raise HTTPException(status_code=400, detail=str(e))
+    except psycopg2.errors.UniqueViolation:
+        raise HTTPException(status_code=409, detail="Key already registered")
+    except Exception as e:
+        logger.error(f"Add signing key error: {e}")
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail="Failed to add signing key"
+        )
+
+
+@router.delete("/me/signing-keys/{key_id}")
+async def revoke_signing_key(key_id: str, request: Request, current_user: dict = Depends(get_current_user)):
+    """Revoke a signing key; signatures already made with it stay verifiable and are shown as by a revoked key"""
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute("""
+                UPDATE author_keys SET revoked_at = NOW()
+                WHERE id = %s AND user_id = %s AND revoked_at IS NULL
+                RETURNING id
+            """, (key_id, str(current_user['id'])))
+            if not cursor.fetchone():
+                raise HTTPException(status_code=404, detail="Signing key not found")
+        record_security_event(
+            current_user['id'], 'signing_key_revoked', *client_of(request),
+            resource_type='author_key', resource_id=key_id
+        )
+        return {"success": True, "message": "Signing key revoked"}
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Revoke signing key error: {e}")
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail="Failed to revoke signing key"
+        )
+
+
+@router.get("/me/wallets", response_model=List[WalletResponse])
+async def get_wallets(current_user: dict = Depends(get_current_user)):
+    """The caller's linked wallets, primary first"""
+    try:
+        with get_postgres_cursor() as cursor:
+            return [WalletResponse(**wallet) for wallet in list_wallets(cursor, str(current_user['id']))]
+    except Exception as e:
+        logger.error(f"List wallets error: {e}")
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail="Failed to get wallets"
+        )
+
+
+@router.post("/me/wallets/challenge", response_model=WalletChallengeResponse)
+async def get_wallet_challenge(challenge: WalletChallengeRequest, current_user: dict = Depends(get_current_user)):
+    """A single-use message for the wallet at `address` to sign with personal_sign"""
+    try:
+        return WalletChallengeResponse(
+            **create_challenge(str(current_user['id']), current_user['username'], challenge.address)
+        )
+    except WalletError as e:
+        raise HTTPException(status_code=400, detail=str(e))
+    except Exception as e:
+        logger.error(f"Wallet challenge error: {e}")
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail="Failed to create wallet challenge"
+        )
+
+
+@router.post("/me/wallets", response_model=WalletResponse, status_code=status.HTTP_201_CREATED)
+async def add_wallet(wallet_data: WalletLinkCreate, request: Request, current_user: dict = Depends(get_current_user)):
+    """Link a wallet by submitting its signature of the challenge; the first wallet becomes the primary"""
+    try:
+        with get_postgres_cursor() as cursor:
+            wallet = link_wallet(
+                cursor, str(current_user['id']), wallet_data.address, wallet_data.signature,
+                wallet_data.label, wallet_data.primary
+            )
+        record_security_event(
+            current_user['id'], 'wallet_linked', *client_of(request),
+            details={'address': wallet['address'], 'primary': wallet['is_primary']},
+            resource_type='user_wallet', resource_id=str(wallet['id'])
+        )
+        return WalletResponse(**wallet)
+    except WalletError as e:
+        raise HTTPException(status_code=400, detail=str(e))
+    except psycopg2.errors.UniqueViolation:
+        raise HTTPException(status_code=409, detail="Wallet already linked")
+    except Exception as e:
+        logger.error(f"Link wallet error: {e}")
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail="Failed to link wallet"
+        )
+
+
+@router.post("/me/wallets/{wallet_id}/primary", response_model=WalletResponse)
+async def make_primary_wallet(wallet_id: str, request: Request, current_user: dict = Depends(get_current_user)):
+    """Make a linked wallet the primary, where tips and purchases are paid to"""
+    try:
+        with get_postgres_cursor() as cursor:
+            wallet = set_primary(cursor, str(current_user['id']), wallet_id)
+        if not wallet:
+            raise HTTPException(status_code=404, detail="Wallet not found")
+        record_security_event(
+            current_user['id'], 'wallet_primary_changed', *client_of(request),
+            details={'address': wallet['address']}, resource_type='user_wallet', resource_id=wallet_id
+        )
+        return WalletResponse(**wallet)
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Set primary wallet error: {e}")
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail="Failed to set primary wallet"
+        )
+
+
+@router.delete("/me/wallets/{wallet_id}")
+async def remove_wallet(wallet_id: str, request: Request, current_user: dict = Depends(get_current_user)):
+    """Unlink a wallet; when it was the primary, the most recently linked remaining wallet takes over"""
+    try:
+        with get_postgres_cursor() as cursor:
+            removed = unlink_wallet(cursor, str(current_user['id']), wallet_id)
+        if not removed:
+            raise HTTPException(status_code=404, detail="Wallet not found")
+        record_security_event(
+            current_user['id'], 'wallet_unlinked', *client_of(request),
+            details={'address': removed['address']}, resource_type='user_wallet', resource_id=wallet_id
+        )
+        return {"success": True, "message": "Wallet unlinked"}
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Unlink wallet error: {e}")
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail="Failed to unlink wallet"
+        )
+
+
+@router.get("/me/migration/export")
+async def export_for_migration(
+    request: Request,
+    destination: str = Query(..., max_length=255, description="Domain of the instance you are moving to"),
+    current_user: dict = Depends(get_current_user)
+):
+    """A signed export of your account to import on the `destination` instance"""
+    try:
+        with get_postgres_cursor() as cursor:
+            document = export_account(cursor, current_user, destination)
+        record_security_event(
+            current_user['id'], 'account_migration_exported', *client_of(request),
+            details={'destination': document['destination'], 'export_id': document['export_id']}
+        )
+        return document
+    except MigrationError as e:
+        raise HTTPException(status_code=400, detail=str(e))
+    except Exception as e:
+        logger.error(f"Account export error: {e}")
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail="Failed to export account"
+        )
+
+
+@router.post("/me/migration/import")
+async def import_from_migration(migration: AccountMigrationImport, request: Request,
+                                current_user: dict = Depends(get_current_user)):
+    """Import an account exported from a peer instance into yours
+
+    Returns a receipt to complete the move on the origin with.
+    """
+    try:
+        with get_postgres_cursor() as cursor:
+            result = import_account(cursor, current_user, migration.export)
+        record_security_event(
+            current_user['id'], 'account_migration_imported', *client_of(request),
+            details={'origin': result['receipt']['origin']['domain'], 'export_id': result['receipt']['export_id']}
+        )
+        return {"success": True, **result}
+    except MigrationError as e:
+        raise HTTPException(status_code=400, detail=str(e))
+    except psycopg2.errors.UniqueViolation:
+        raise HTTPException(status_code=409, detail="This export has already been imported")
+    except Exception as e:
+        logger.error(f"Account import error: {e}")
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail="Failed to import account"
+        )
+
+
+@router.post("/me/migration/complete")
+async def complete_migration(migration: AccountMigrationComplete, request: Request,
+                             current_user: dict = Depends(get_current_user)):
+    """Mark your account moved with the receipt the destination gave you on import"""
+    try:
+        with get_postgres_cursor() as cursor:
+            result = complete_move(cursor, current_user, migration.receipt)
+        record_security_event(
+            current_user['id'], 'account_moved', *client_of(request),
+            details={'moved_to': result['moved_to'], 'export_id': result['export_id']}
+        )
+        return {"success": True, **result}
+    except MigrationError as e:
+        raise HTTPException(status_code=400, detail=str(e))
+    except Exception as e:
+        logger.error(f"Account move error: {e}")
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail="Failed to complete move"
+        )
+
+
+@router.get("/me/migrations")
+async def get_my_migrations(current_user: dict = Depends(get_current_user)):
+    """Your exports and imports between instances, with the article references each carried"""
+    try:
+        with get_postgres_cursor() as cursor:
+            return {"success": True, "migrations": migrations_for(cursor, str(current_user['id']))}
+    except Exception as e:
+        logger.error(f"List migrations error: {e}")
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail="Failed to get migrations"
+        )
+
+
+@router.get("/me/subscriptions")
+async def get_my_subscriptions(current_user: dict = Depends(get_current_user)):
+    """The caller's subscriptions and the premium articles they have bought"""
+    try:
+        with get_postgres_cursor() as cursor:
+            subscriptions = list_subscriptions(cursor, str(current_user['id']))
+            purchases = list_purchases(cursor, str(current_user['id']))
+        return {"success": True, "subscriptions": subscriptions, "purchases": purchases}
+    except Exception as e:
+        logger.error(f"List subscriptions error: {e}")
+        raise HTTPException(
+            status_code=status.HTTP_500_INTERNAL_SERVER_ERROR,
+            detail="Failed to get subscriptions"
+        )
@@ -0,0 +1,53 @@
+"""
+Data warehouse export routes for FastAPI backend
+"""
+
+import sys
+import os
+from fastapi import APIRouter, HTTPException, Depends
+import logging
+
+sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
+
+from shared.database import get_postgres_cursor
+from shared import warehouse
+from ..dependencies import get_admin_user
+
+router = APIRouter()
+logger = logging.getLogger(__name__)
+
+
+@router.get("/")
+async def get_warehouse_status(admin_user: dict = Depends(get_admin_user)):
+    """Sink, and per dataset the current schema and how far it has been exported (admin only)"""
+    try:
+        with get_postgres_cursor() as cursor:
+            datasets = warehouse.status(cursor)
+        return {
+            "success": True,
+            "enabled": warehouse.enabled(),
+            "sink": warehouse.SINK,
+            "datasets": datasets
+        }
+    except Exception as e:
+        logger.error(f"Warehouse status error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve warehouse export status")
+
+
+@router.post("/{dataset}/reset")
+async def reset_dataset(dataset: str, admin_user: dict = Depends(get_admin_user)):
+    """Export a dataset in full again on the next run (admin only)"""
+    if dataset not in warehouse.DATASETS:
+        raise HTTPException(status_code=404, detail="Unknown dataset")
+    try:
+        with get_postgres_cursor() as cursor:
+            if not warehouse.reset(cursor, dataset):
+                raise HTTPException(status_code=409, detail="Dataset has not been exported yet")
+
+        logger.info(f"Warehouse export of {dataset} reset by {admin_user['id']}")
+        return {"success": True, "message": f"{dataset} will be exported in full on the next run"}
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Reset warehouse dataset error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to reset dataset")
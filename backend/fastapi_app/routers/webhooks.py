@@ -0,0 +1,230 @@
+"""
+Webhook subscription routes for FastAPI backend
+"""
+
+import sys
+import os
+from typing import List, Optional
+from fastapi import APIRouter, HTTPException, Depends, status, Query
+import logging
+
+sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
+
+from shared.database import get_postgres_cursor
+from shared.models import WebhookCreate, WebhookUpdate, WebhookResponse, WebhookDeliveryResponse
+from shared.webhooks import WEBHOOK_EVENTS, generate_secret, is_allowed_target, webhook_dispatcher
+from shared import tenant_keys
+from ..dependencies import get_current_user
+
+router = APIRouter()
+logger = logging.getLogger(__name__)
+
+
+def validate_subscription(url: Optional[str], events: Optional[List[str]]):
+    if events is not None:
+        unknown = sorted(set(events) - set(WEBHOOK_EVENTS))
+        if unknown:
+            raise HTTPException(status_code=400, detail=f"Unknown events: {', '.join(unknown)}")
+    if url is not None and not is_allowed_target(url):
+        raise HTTPException(status_code=400, detail="Webhook URL must be a publicly reachable HTTP(S) address")
+
+
+def get_owned_webhook(cursor, webhook_id: str, user: dict) -> dict:
+    """Load a webhook the caller owns (administrators may access any webhook of their publication)"""
+    # Joined to its owner, so row-level security hides other publications' webhooks
+    cursor.execute("""
+        SELECT w.*, u.tenant_id AS owner_tenant_id FROM webhooks w
+        JOIN users u ON u.id = w.owner_id
+        WHERE w.id = %s
+    """, (webhook_id,))
+    webhook = cursor.fetchone()
+    if not webhook:
+        raise HTTPException(status_code=404, detail="Webhook not found")
+    if str(webhook['owner_id']) != str(user['id']) and user.get('role') != 'administrator':
+        raise HTTPException(status_code=404, detail="Webhook not found")
+    return dict(webhook)
+
+
+@router.get("/events")
+async def list_events():
+    """List events that webhooks can subscribe to"""
+    return {"success": True, "events": WEBHOOK_EVENTS}
+
+
+@router.get("/", response_model=List[WebhookResponse])
+async def list_webhooks(current_user: dict = Depends(get_current_user)):
+    """List the caller's webhooks"""
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute(
+                "SELECT * FROM webhooks WHERE owner_id = %s ORDER BY created_at DESC",
+                (current_user['id'],)
+            )
+            webhooks = cursor.fetchall()
+
+        return [WebhookResponse(**{**dict(webhook), 'secret': None}) for webhook in webhooks]
+    except Exception as e:
+        logger.error(f"List webhooks error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve webhooks")
+
+
+@router.post("/", response_model=WebhookResponse, status_code=status.HTTP_201_CREATED)
+async def create_webhook(webhook_data: WebhookCreate, current_user: dict = Depends(get_current_user)):
+    """Register a webhook; the signing secret is only returned in this response"""
+    validate_subscription(webhook_data.url, webhook_data.events)
+
+    secret = generate_secret()
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute("""
+                INSERT INTO webhooks (owner_id, url, description, events, secret, tenant_id)
+                VALUES (%s, %s, %s, %s, %s, %s)
+                RETURNING *
+            """, (
+                current_user['id'], webhook_data.url, webhook_data.description,
+                sorted(set(webhook_data.events)), tenant_keys.encrypt(secret, current_user.get('tenant_id')),
+                current_user.get('tenant_id')
+            ))
+            webhook = cursor.fetchone()
+
+        return WebhookResponse(**{**dict(webhook), 'secret': secret})
+    except Exception as e:
+        logger.error(f"Create webhook error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to create webhook")
+
+
+@router.patch("/{webhook_id}", response_model=WebhookResponse)
+async def update_webhook(webhook_id: str, webhook_update: WebhookUpdate, current_user: dict = Depends(get_current_user)):
+    """Update a webhook's target, events or active state"""
+    update_data = webhook_update.dict(exclude_unset=True)
+    if not update_data:
+        raise HTTPException(status_code=400, detail="No valid fields to update")
+    validate_subscription(update_data.get('url'), update_data.get('events'))
+    if 'events' in update_data:
+        update_data['events'] = sorted(set(update_data['events']))
+
+    try:
+        with get_postgres_cursor() as cursor:
+            get_owned_webhook(cursor, webhook_id, current_user)
+
+            update_fields = [f"{field} = %s" for field in update_data]
+            cursor.execute(
+                f"UPDATE webhooks SET {', '.join(update_fields)} WHERE id = %s RETURNING *",
+                list(update_data.values()) + [webhook_id]
+            )
+            webhook = cursor.fetchone()
+
+        return WebhookResponse(**{**dict(webhook), 'secret': None})
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Update webhook error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to update webhook")
+
+
+@router.delete("/{webhook_id}")
+async def delete_webhook(webhook_id: str, current_user: dict = Depends(get_current_user)):
+    """Delete a webhook and its delivery history"""
+    try:
+        with get_postgres_cursor() as cursor:
+            get_owned_webhook(cursor, webhook_id, current_user)
+            cursor.execute("DELETE FROM webhooks WHERE id = %s", (webhook_id,))
+
+        return {"success": True, "message": "Webhook deleted"}
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Delete webhook error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to delete webhook")
+
+
+@router.post("/{webhook_id}/rotate-secret", response_model=WebhookResponse)
+async def rotate_webhook_secret(webhook_id: str, current_user: dict = Depends(get_current_user)):
+    """Issue a new signing secret; the old one stops being used immediately"""
+    try:
+        with get_postgres_cursor() as cursor:
+            owner_tenant_id = get_owned_webhook(cursor, webhook_id, current_user)['owner_tenant_id']
+            secret = generate_secret()
+            cursor.execute(
+                "UPDATE webhooks SET secret = %s WHERE id = %s RETURNING *",
+                (tenant_keys.encrypt(secret, owner_tenant_id), webhook_id)
+            )
+            webhook = cursor.fetchone()
+
+        return WebhookResponse(**{**dict(webhook), 'secret': secret})
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Rotate webhook secret error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to rotate webhook secret")
+
+
+@router.get("/{webhook_id}/deliveries", response_model=List[WebhookDeliveryResponse])
+async def list_deliveries(
+    webhook_id: str,
+    delivery_status: str = Query("", alias="status", description="pending, succeeded or dead"),
+    limit: int = Query(50, ge=1, le=200),
+    current_user: dict = Depends(get_current_user)
+):
+    """Delivery log for a webhook, newest first"""
+    try:
+        with get_postgres_cursor() as cursor:
+            get_owned_webhook(cursor, webhook_id, current_user)
+
+            query = "SELECT * FROM webhook_deliveries WHERE webhook_id = %s"
+            params = [webhook_id]
+            if delivery_status:
+                query += " AND status = %s"
+                params.append(delivery_status)
+            query += " ORDER BY created_at DESC LIMIT %s"
+            params.append(limit)
+
+            cursor.execute(query, params)
+            deliveries = cursor.fetchall()
+
+        return [WebhookDeliveryResponse(**dict(delivery)) for delivery in deliveries]
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"List webhook deliveries error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve deliveries")
+
+
+@router.get("/{webhook_id}/dead-letters")
+async def list_dead_letters(webhook_id: str, limit: int = Query(50, ge=1, le=200),
+                            current_user: dict = Depends(get_current_user)):
+    """Deliveries that exhausted their retries"""
+    try:
+        with get_postgres_cursor() as cursor:
+            get_owned_webhook(cursor, webhook_id, current_user)
+            cursor.execute("""
+                SELECT id, delivery_id, event_type, payload, attempt_count, last_status_code,
+                       last_error, failed_at, replayed_at
+                FROM webhook_dead_letters WHERE webhook_id = %s
+                ORDER BY failed_at DESC LIMIT %s
+            """, (webhook_id, limit))
+            dead_letters = [dict(row) for row in cursor.fetchall()]
+
+        return {"success": True, "dead_letters": dead_letters}
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"List webhook dead letters error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to retrieve dead letters")
+
+
+@router.post("/{webhook_id}/dead-letters/{dead_letter_id}/replay")
+async def replay_dead_letter(webhook_id: str, dead_letter_id: str, current_user: dict = Depends(get_current_user)):
+    """Queue a dead-lettered delivery for another round of attempts"""
+    try:
+        with get_postgres_cursor() as cursor:
+            get_owned_webhook(cursor, webhook_id, current_user)
+            if not webhook_dispatcher.replay_dead_letter(cursor, dead_letter_id, webhook_id):
+                raise HTTPException(status_code=404, detail="Dead letter not found or already replayed")
+
+        return {"success": True, "message": "Delivery queued for replay"}
+    except HTTPException:
+        raise
+    except Exception as e:
+        logger.error(f"Replay dead letter error: {e}")
+        raise HTTPException(status_code=500, detail="Failed to replay delivery")
@@ -15,6 +15,7 @@ import sys
 sys.path.append(os.path.join(os.path.dirname(__file__), '..'))
 
 from shared.models import *
+from shared.pii import install_log_scrubbing
 
 # Load environment variables
 load_dotenv()
@@ -24,6 +25,7 @@ logging.basicConfig(
     level=logging.INFO,
     format='%(asctime)s - %(name)s - %(levelname)s - %(message)s'
 )
+install_log_scrubbing()
 logger = logging.getLogger(__name__)
 
 
@@ -40,7 +42,7 @@ def create_app():
     CORS(app, 
          origins=allowed_origins,
          methods=['GET', 'POST', 'PUT', 'DELETE', 'OPTIONS', 'PATCH'],
-         allow_headers=['Content-Type', 'Authorization', 'X-Requested-With', 'Accept'],
+         allow_headers=['Content-Type', 'Authorization', 'X-Requested-With', 'Accept', 'X-Sandbox-Key', 'X-Tenant'],
          expose_headers=['X-Response-Time'],
          supports_credentials=True,
          max_age=86400
@@ -51,7 +53,8 @@ def create_app():
         if request.method == "OPTIONS":
             response = jsonify({'message': 'OK'})
             response.headers.add("Access-Control-Allow-Origin", request.headers.get('Origin', '*'))
-            response.headers.add('Access-Control-Allow-Headers', "Content-Type,Authorization,X-Requested-With,Accept")
+            response.headers.add('Access-Control-Allow-Headers',
+                                 "Content-Type,Authorization,X-Requested-With,Accept,X-Sandbox-Key,X-Tenant")
             response.headers.add('Access-Control-Allow-Methods', "GET,PUT,POST,DELETE,OPTIONS,PATCH")
             response.headers.add('Access-Control-Allow-Credentials', 'true')
             return response
@@ -65,6 +68,7 @@ def create_app():
     from .routes.search import search_bp
     from .routes.analytics import analytics_bp
     from .routes.health import health_bp
+    from shared.modules import is_enabled
     
     # Register blueprints with explicit trailing slash handling
     app.register_blueprint(auth_bp, url_prefix='/api/v1/auth')
@@ -73,7 +77,8 @@ def create_app():
     app.register_blueprint(interactions_bp, url_prefix='/api/v1/interactions')
     app.register_blueprint(recommendations_bp, url_prefix='/api/v1/recommendations')
     app.register_blueprint(search_bp, url_prefix='/api/v1/search')
-    app.register_blueprint(analytics_bp, url_prefix='/api/v1/analytics')
+    if is_enabled('analytics'):
+        app.register_blueprint(analytics_bp, url_prefix='/api/v1/analytics')
     app.register_blueprint(health_bp, url_prefix='/api/v1/health')
     
     # Disable automatic trailing slash redirects (common cause of preflight issues)
@@ -118,6 +123,112 @@ def create_app():
         return response, 400
     
     # Request/Response middleware
+    @app.before_request
+    def begin_sandbox():
+        # Before anything reads a token or the database: a sandbox key switches the tenant
+        api_key = request.headers.get('X-Sandbox-Key')
+        if not api_key:
+            return None
+        from shared import sandbox
+        from shared.modules import is_enabled
+        if not is_enabled('sandbox'):
+            return jsonify({'success': False, 'message': 'The sandbox is not enabled', 'error_code': 'SANDBOX'}), 404
+        if not sandbox.authenticate(api_key, request.remote_addr):
+            return jsonify({'success': False, 'message': 'Invalid sandbox key', 'error_code': 'SANDBOX'}), 401
+        if not sandbox.ready():
+            return jsonify({
+                'success': False, 'message': 'The sandbox is being set up; try again after its first reset',
+                'error_code': 'SANDBOX'
+            }), 503
+        sandbox.begin()
+        return None
+    
+    @app.teardown_request
+    def end_sandbox(exc):
+        from shared.sandbox import end
+        end()
+    
+    @app.before_request
+    def begin_tenant():
+        # After the sandbox, whose copy of the tenants is used for sandbox requests
+        from shared import tenancy
+        if not tenancy.enabled():
+            return None
+        try:
+            tenant = tenancy.resolve(request.headers.get(tenancy.HEADER, '').strip(), request.host)
+        except tenancy.UnknownTenant as e:
+            return jsonify({'success': False, 'message': str(e), 'error_code': 'TENANT'}), 404
+        if tenant and not tenancy.request_allowed(tenant):
+            return jsonify({
+                'success': False, 'message': "This publication's request quota is used up; try again next minute",
+                'error_code': 'TENANT'
+            }), 429
+        tenancy.begin(tenant)
+        return None
+    
+    @app.teardown_request
+    def end_tenant(exc):
+        from shared.tenancy import end
+        end()
+    
+    @app.before_request
+    def begin_request_scope():
+        from shared.request_context import begin_request
+        begin_request()
+    
+    @app.teardown_request
+    def end_request_scope(exc):
+        from shared.request_context import end_request
+        end_request()
+    
+    @app.before_request
+    def begin_paywall_viewer():
+        from shared.auth import auth_manager
+        from shared.paywall import begin_viewer
+        token = auth_manager.extract_token_from_header(request.headers.get('Authorization', ''))
+        begin_viewer(auth_manager.get_user_from_token(token) if token else None)
+    
+    @app.teardown_request
+    def end_paywall_viewer(exc):
+        from shared.paywall import end_viewer
+        end_viewer()
+    
+    @app.before_request
+    def classify_traffic():
+        from shared import bot_detection
+        if not bot_detection.ENABLED:
+            return None
+        headers = {name.lower(): value for name, value in request.headers.items()}
+        traffic = bot_detection.classify(headers, request.remote_addr)
+        if bot_detection.is_honeypot(request.path):
+            bot_detection.flag(traffic, request.path)
+            bot_detection.record(traffic)
+            return jsonify({'success': False, 'message': 'Not found'}), 404
+        bot_detection.record(traffic)
+        bot_detection.begin_traffic(traffic)
+        if traffic.is_bot and bot_detection.cacheable(request.method, request.path, headers):
+            cached = bot_detection.cached_response(request.path, request.query_string.decode('latin-1'))
+            if cached:
+                response = app.response_class(cached[1], status=200, content_type=cached[0])
+                response.headers['X-Bot-Cache'] = 'HIT'
+                return response
+            request.cache_for_bots = True
+        return None
+    
+    @app.after_request
+    def cache_bot_response(response):
+        if getattr(request, 'cache_for_bots', False) and response.status_code == 200 and not response.is_streamed:
+            from shared import bot_detection
+            bot_detection.store_response(
+                request.path, request.query_string.decode('latin-1'), response.content_type, response.get_data()
+            )
+        return response
+    
+    @app.teardown_request
+    def end_traffic_classification(exc):
+        from shared.bot_detection import end_traffic
+        end_traffic()
+    
     @app.before_request
     def before_request_logging():
         # Skip for OPTIONS requests to avoid interfering with preflight
@@ -135,6 +246,16 @@ def create_app():
         if hasattr(request, 'start_time'):
             duration = (datetime.now() - request.start_time).total_seconds() * 1000
             response.headers['X-Response-Time'] = f"{duration:.2f}ms"
+            
+            # Set by auth_required for authenticated requests
+            if hasattr(request, 'token_claims') and request.token_claims:
+                from shared.tokens import record_request
+                from shared.cohorts import record_visit
+                record_request(
+                    request.token_claims, request.method, request.path, response.status_code,
+                    request.remote_addr, request.headers.get('User-Agent'), duration
+                )
+                record_visit(request.token_claims.get('user_id'))
         
         # Add security headers
         response.headers['X-Content-Type-Options'] = 'nosniff'
@@ -180,6 +301,15 @@ def create_app():
 
 
 if __name__ == '__main__':
+    from shared.database import wait_for_dependencies
+    logger.info(f"Database connection test results: {wait_for_dependencies()}")
+
+    # Publications are only kept apart if the database enforces row-level security for this connection's role
+    from shared import tenancy
+    if tenancy.enabled():
+        with tenancy.unscoped():
+            tenancy.check_isolation()
+    
     app = create_app()
     port = int(os.getenv('FLASK_PORT', 5000))
     
@@ -14,6 +14,8 @@ sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
 from shared.database import get_postgres_cursor
 from shared.auth import auth_required
 from shared.models import AnalyticsRequest, AnalyticsResponse
+from shared.claps import author_clap_metrics
+from shared import clickstream
 
 analytics_bp = Blueprint('analytics', __name__)
 logger = logging.getLogger(__name__)
@@ -46,32 +48,31 @@ def get_user_analytics(user_id):
             date_from = analytics_data.date_from or (datetime.now() - timedelta(days=30))
             date_to = analytics_data.date_to or datetime.now()
             
-            if 'views' in analytics_data.metrics:
-                cursor.execute("""
-                    SELECT COUNT(*) as view_count
-                    FROM user_interactions 
-                    WHERE user_id = %s AND interaction_type = 'view'
-                    AND created_at BETWEEN %s AND %s
-                """, (user_id, date_from, date_to))
-                metrics['views'] = cursor.fetchone()['view_count']
+            # Interaction counts come from ClickHouse when it serves analytics
+            interaction_metrics = {
+                metric: kind for metric, kind in (('views', 'view'), ('likes', 'like'), ('shares', 'share'))
+                if metric in analytics_data.metrics
+            }
+            counts = None
+            if interaction_metrics:
+                counts = clickstream.count_interactions(
+                    list(interaction_metrics.values()), date_from, date_to, user_id=user_id
+                )
             
-            if 'likes' in analytics_data.metrics:
+            for metric, kind in interaction_metrics.items():
+                if counts is not None:
+                    metrics[metric] = counts[kind]
+                    continue
                 cursor.execute("""
-                    SELECT COUNT(*) as like_count
+                    SELECT COUNT(*) as interaction_count
                     FROM user_interactions 
-                    WHERE user_id = %s AND interaction_type = 'like'
+                    WHERE user_id = %s AND interaction_type = %s
                     AND created_at BETWEEN %s AND %s
-                """, (user_id, date_from, date_to))
-                metrics['likes'] = cursor.fetchone()['like_count']
+                """, (user_id, kind, date_from, date_to))
+                metrics[metric] = cursor.fetchone()['interaction_count']
             
-            if 'shares' in analytics_data.metrics:
-                cursor.execute("""
-                    SELECT COUNT(*) as share_count
-                    FROM user_interactions 
-                    WHERE user_id = %s AND interaction_type = 'share'
-                    AND created_at BETWEEN %s AND %s
-                """, (user_id, date_from, date_to))
-                metrics['shares'] = cursor.fetchone()['share_count']
+            if 'claps' in analytics_data.metrics:
+                metrics['claps_received'] = author_clap_metrics(cursor, user_id, date_from, date_to)
         
         response = AnalyticsResponse(
             metrics=metrics,
@@ -114,7 +115,7 @@ def get_article_analytics(article_id):
             
             # Basic metrics
             cursor.execute("""
-                SELECT view_count, like_count, share_count, comment_count,
+                SELECT view_count, like_count, share_count, comment_count, clap_count,
                        engagement_score, quality_score, trending_score
                 FROM articles WHERE id = %s
             """, (article_id,))
@@ -125,13 +126,17 @@ def get_article_analytics(article_id):
             
             # Interaction metrics over time period
             if 'views' in analytics_data.metrics:
-                cursor.execute("""
-                    SELECT COUNT(*) as period_views
-                    FROM user_interactions 
-                    WHERE article_id = %s AND interaction_type = 'view'
-                    AND created_at BETWEEN %s AND %s
-                """, (article_id, date_from, date_to))
-                metrics['period_views'] = cursor.fetchone()['period_views']
+                counts = clickstream.count_interactions(['view'], date_from, date_to, article_id=article_id)
+                if counts is not None:
+                    metrics['period_views'] = counts['view']
+                else:
+                    cursor.execute("""
+                        SELECT COUNT(*) as period_views
+                        FROM user_interactions 
+                        WHERE article_id = %s AND interaction_type = 'view'
+                        AND created_at BETWEEN %s AND %s
+                    """, (article_id, date_from, date_to))
+                    metrics['period_views'] = cursor.fetchone()['period_views']
         
         response = AnalyticsResponse(
             metrics=metrics,
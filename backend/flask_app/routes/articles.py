@@ -12,13 +12,18 @@ from datetime import datetime
 sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
 
 from shared.database import get_postgres_cursor
-from shared.auth import auth_required
+from shared.auth import auth_required, auth_manager
 from shared.models import ArticleCreate, ArticleUpdate, ArticleResponse
+from shared.content_signatures import SignatureInvalid, sign_article
 from shared.utils import (
-    generate_uuid, calculate_reading_time, calculate_word_count,
-    extract_keywords, calculate_quality_score, paginate_query_results,
-    sanitize_html
+    generate_uuid, extract_keywords, calculate_quality_score, paginate_query_results
 )
+from shared.content import UnsafeContent, prepare
+from shared.publishing import on_article_published
+from shared.reputation import article_liked
+from shared import article_cache, feed_versions
+from shared.readability import readability_columns, store_readability
+from shared.view_counts import record_view, viewer_key
 
 articles_bp = Blueprint('articles', __name__)
 logger = logging.getLogger(__name__)
@@ -91,7 +96,7 @@ def get_articles():
 
 @articles_bp.route('/<article_id>', methods=['GET'])
 def get_article(article_id):
-    """Get article by ID"""
+    """Get article by ID, counting a view for signed-in readers"""
     try:
         with get_postgres_cursor() as cursor:
             cursor.execute(
@@ -105,11 +110,13 @@ def get_article(article_id):
                     'success': False,
                     'message': 'Article not found'
                 }), 404
-            
-            # Increment view count
-            cursor.execute(
-                "UPDATE articles SET view_count = view_count + 1 WHERE id = %s",
-                (article_id,)
+        
+        token = auth_manager.extract_token_from_header(request.headers.get('Authorization', ''))
+        user_data = auth_manager.get_user_from_token(token) if token else None
+        if user_data:
+            record_view(
+                dict(article_record), viewer_key(user_id=str(user_data['id'])), request.headers.get('User-Agent'),
+                str(user_data['id'])
             )
         
         article_response = ArticleResponse(**dict(article_record))
@@ -145,18 +152,18 @@ def create_article():
                 'details': e.errors()
             }), 400
         
-        # Sanitize content
-        sanitized_content = sanitize_html(article_data.content)
-        
-        # Calculate metrics
-        reading_time = calculate_reading_time(sanitized_content)
-        word_count = calculate_word_count(sanitized_content)
+        # Sanitize content and calculate metrics
+        prepared = prepare(article_data.content, article_data.content_format.value)
+        sanitized_content = prepared['content']
+        reading_time = prepared['reading_time']
+        word_count = prepared['word_count']
         seo_keywords = extract_keywords(sanitized_content)
         quality_score = calculate_quality_score(
             sanitized_content, 
             article_data.title, 
             article_data.summary
         )
+        readability = readability_columns(sanitized_content, article_data.language)
         
         # Create article
         article_id = generate_uuid()
@@ -165,21 +172,32 @@ def create_article():
         with get_postgres_cursor() as cursor:
             cursor.execute("""
                 INSERT INTO articles (
-                    id, title, content, summary, author_id, anonymous_author,
+                    id, title, content, content_format, summary, author_id, anonymous_author,
                     category, subcategory, tags, language, reading_time, word_count,
-                    status, metadata, seo_keywords, quality_score, created_at, updated_at
+                    status, metadata, seo_keywords, quality_score, license, license_terms,
+                    readability, reading_level, created_at, updated_at
                 ) VALUES (
-                    %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s
+                    %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s
                 ) RETURNING *
             """, (
-                article_id, article_data.title, sanitized_content, article_data.summary,
+                article_id, article_data.title, sanitized_content, article_data.content_format.value,
+                article_data.summary,
                 author_id, article_data.anonymous_author, article_data.category,
                 article_data.subcategory, article_data.tags, article_data.language,
                 reading_time, word_count, 'draft', article_data.metadata or {},
-                seo_keywords, quality_score, 'now()', 'now()'
+                seo_keywords, quality_score, article_data.license.value,
+                article_data.license_terms, readability['readability'], readability['reading_level'],
+                'now()', 'now()'
             ))
             
             article_record = cursor.fetchone()
+
+            if article_data.signature:
+                # Rolls the article back too when the signature doesn't verify
+                sign_article(
+                    cursor, dict(article_record), author_id,
+                    str(article_data.signature.key_id), article_data.signature.signature
+                )
         
         article_response = ArticleResponse(**dict(article_record))
         return jsonify({
@@ -188,6 +206,10 @@ def create_article():
             'article': article_response.dict()
         }), 201
     
+    except SignatureInvalid as e:
+        return jsonify({'success': False, 'message': f'Invalid signature: {e}'}), 400
+    except UnsafeContent as e:
+        return jsonify({'success': False, 'message': f'Unsafe content: {e}'}), 400
     except Exception as e:
         logger.error(f"Create article error: {e}")
         return jsonify({
@@ -218,7 +240,7 @@ def update_article(article_id):
         # Check if user owns the article or is admin
         with get_postgres_cursor() as cursor:
             cursor.execute(
-                "SELECT author_id FROM articles WHERE id = %s",
+                "SELECT author_id, status, content, language FROM articles WHERE id = %s",
                 (article_id,)
             )
             
@@ -243,21 +265,26 @@ def update_article(article_id):
             params = []
             
             update_data = article_update.dict(exclude_unset=True)
+            content_format = getattr(update_data.get('content_format'), 'value', None) or article['content_format']
+            if update_data.get('content') or content_format != article['content_format']:
+                # Sanitize and recalculate metrics for content updates; a new format re-reads the stored source
+                prepared = prepare(update_data.get('content') or article['content'], content_format)
+                update_fields.extend([
+                    "content = %s",
+                    "content_format = %s",
+                    "reading_time = %s",
+                    "word_count = %s"
+                ])
+                params.extend([
+                    prepared['content'],
+                    content_format,
+                    prepared['reading_time'],
+                    prepared['word_count']
+                ])
             for field, value in update_data.items():
-                if field == 'content' and value:
-                    # Sanitize and recalculate metrics for content updates
-                    sanitized_content = sanitize_html(value)
-                    update_fields.extend([
-                        "content = %s",
-                        "reading_time = %s",
-                        "word_count = %s"
-                    ])
-                    params.extend([
-                        sanitized_content,
-                        calculate_reading_time(sanitized_content),
-                        calculate_word_count(sanitized_content)
-                    ])
-                elif field in ['title', 'summary', 'category', 'subcategory', 'tags', 'language', 'status', 'anonymous_author', 'metadata']:
+                if field in ('content', 'content_format'):
+                    continue
+                elif field in ['title', 'summary', 'category', 'subcategory', 'tags', 'language', 'status', 'anonymous_author', 'metadata', 'license', 'license_terms']:
                     update_fields.append(f"{field} = %s")
                     params.append(value)
             
@@ -271,6 +298,7 @@ def update_article(article_id):
             params.append('now()')
             
             # If publishing, set published_at
+            publishing = update_data.get('status') == 'published' and article['status'] != 'published'
             if 'status' in update_data and update_data['status'] == 'published':
                 update_fields.append("published_at = %s")
                 params.append('now()')
@@ -279,15 +307,29 @@ def update_article(article_id):
             
             query = f"UPDATE articles SET {', '.join(update_fields)} WHERE id = %s RETURNING *"
             cursor.execute(query, params)
-            updated_article = cursor.fetchone()
+            updated_article = dict(cursor.fetchone())
+            
+            if updated_article['content'] != article['content'] or updated_article['language'] != article['language']:
+                updated_article['readability'] = store_readability(
+                    cursor, article_id, updated_article['content'], updated_article['language']
+                )
+                updated_article['reading_level'] = updated_article['readability']['reading_level']
+            
+            if publishing:
+                on_article_published(cursor, updated_article)
         
-        article_response = ArticleResponse(**dict(updated_article))
+        article_cache.invalidate(article_id)
+        if 'published' in (article['status'], updated_article['status']):
+            feed_versions.bump()
+        article_response = ArticleResponse(**updated_article)
         return jsonify({
             'success': True,
             'message': 'Article updated successfully',
             'article': article_response.dict()
         }), 200
     
+    except UnsafeContent as e:
+        return jsonify({'success': False, 'message': f'Unsafe content: {e}'}), 400
     except Exception as e:
         logger.error(f"Update article error: {e}")
         return jsonify({
@@ -330,6 +372,8 @@ def delete_article(article_id):
                 ('now()', article_id)
             )
         
+        article_cache.invalidate(article_id)
+        feed_versions.bump()
         return jsonify({
             'success': True,
             'message': 'Article deleted successfully'
@@ -377,6 +421,7 @@ def like_article(article_id):
                 "UPDATE articles SET like_count = like_count + 1 WHERE id = %s",
                 (article_id,)
             )
+            article_liked(cursor, article_id, user_id)
         
         return jsonify({
             'success': True,
@@ -7,15 +7,24 @@ from pydantic import ValidationError
 import logging
 import sys
 import os
-import json
+from datetime import datetime
 
 # Add parent directory to path for imports
 sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
 
 from shared.database import get_postgres_cursor
 from shared.auth import auth_manager, hash_password, verify_password
-from shared.models import UserCreate, UserLogin, UserResponse, TokenResponse
+from shared.models import UserCreate, UserLogin, UserResponse, TokenResponse, SocialLoginCallback
 from shared.utils import generate_uuid, validate_email
+from shared.webhooks import emit_user_registered
+from shared.events import user_registered
+from shared.tokens import introspect_token, is_introspection_client
+from shared.sessions import SessionStoreUnavailable, issue_session_token
+from shared.account_deletion import cancel_deletion, in_grace_period
+from shared.social_login import SocialLoginError, complete_login, enabled_providers, start_login
+from shared.repositories import repositories
+from shared.tenancy import QuotaExceeded
+from shared.audit import record_security_event
 
 auth_bp = Blueprint('auth', __name__)
 logger = logging.getLogger(__name__)
@@ -41,39 +50,35 @@ def register():
         
         # Check if user already exists
         with get_postgres_cursor() as cursor:
-            cursor.execute(
-                "SELECT id FROM users WHERE email = %s OR username = %s",
-                (user_data.email, user_data.username)
-            )
-            
-            if cursor.fetchone():
+            repos = repositories(cursor)
+            if repos.users.exists(user_data.email, user_data.username):
                 return jsonify({
                     'success': False,
                     'message': 'User with this email or username already exists'
                 }), 409
             
             # Create new user
-            user_id = generate_uuid()
-            hashed_password = hash_password(user_data.password)
+            now = datetime.now()
+            user_record = repos.users.create({
+                'id': generate_uuid(),
+                'username': user_data.username,
+                'email': user_data.email,
+                'password_hash': hash_password(user_data.password),
+                'role': user_data.role.value,
+                'anonymous_mode': user_data.anonymous_mode,
+                'profile_data': user_data.profile_data or {},
+                'preferences': user_data.preferences or {},
+                'created_at': now,
+                'updated_at': now,
+                'last_active': now,
+            })
             
-            cursor.execute("""
-                INSERT INTO users (
-                    id, username, email, password_hash, role, anonymous_mode,
-                    profile_data, preferences, created_at, updated_at, last_active
-                ) VALUES (%s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s)
-                RETURNING *
-            """, (
-                user_id, user_data.username, user_data.email, hashed_password,
-                user_data.role.value, user_data.anonymous_mode,
-                json.dumps(user_data.profile_data or {}), json.dumps(user_data.preferences or {}),
-                'now()', 'now()', 'now()'
-            ))
-            
-            user_record = cursor.fetchone()
+            emit_user_registered(cursor, user_record)
+            user_registered(cursor, user_record)
         
         # Create response
-        user_response = UserResponse(**dict(user_record))
-        access_token = auth_manager.create_access_token(dict(user_record))
+        user_response = UserResponse(**user_record)
+        access_token = issue_session_token(user_record, request.remote_addr, request.headers.get('User-Agent'))
         
         return jsonify(TokenResponse(
             access_token=access_token,
@@ -81,6 +86,14 @@ def register():
             user=user_response
         ).dict()), 201
     
+    except QuotaExceeded as e:
+        return jsonify({'success': False, 'message': str(e), 'error_code': 'QUOTA_EXCEEDED'}), 403
+    except SessionStoreUnavailable:
+        return jsonify({
+            'success': False,
+            'message': 'Sign-in is temporarily unavailable',
+            'error_code': 'SESSION_STORE_UNAVAILABLE'
+        }), 503
     except Exception as e:
         logger.error(f"Registration error: {e}")
         return jsonify({
@@ -110,28 +123,35 @@ def login():
         
         # Check user credentials
         with get_postgres_cursor() as cursor:
-            cursor.execute(
-                "SELECT * FROM users WHERE email = %s AND is_active = true",
-                (login_data.email,)
-            )
-            
-            user_record = cursor.fetchone()
+            repos = repositories(cursor)
+            user_record = repos.users.get_by_email(login_data.email, active_only=False)
+            usable = user_record and (user_record['is_active'] or in_grace_period(user_record))
             
-            if not user_record or not verify_password(login_data.password, user_record['password_hash']):
+            if not usable or not verify_password(login_data.password, user_record['password_hash']):
+                if user_record:
+                    record_security_event(
+                        user_record['id'], 'login_failed', request.remote_addr, request.headers.get('User-Agent'),
+                        details={'method': 'password'}
+                    )
                 return jsonify({
                     'success': False,
                     'message': 'Invalid credentials'
                 }), 401
             
+            # Signing in during the grace period cancels a requested deletion
+            if not user_record['is_active']:
+                user_record = cancel_deletion(repos.users, user_record)
+            
             # Update last active
-            cursor.execute(
-                "UPDATE users SET last_active = %s WHERE id = %s",
-                ('now()', user_record['id'])
-            )
+            repos.users.touch_last_active(user_record['id'])
         
         # Create response
-        user_response = UserResponse(**dict(user_record))
-        access_token = auth_manager.create_access_token(dict(user_record))
+        user_response = UserResponse(**user_record)
+        access_token = issue_session_token(user_record, request.remote_addr, request.headers.get('User-Agent'))
+        record_security_event(
+            user_record['id'], 'login', request.remote_addr, request.headers.get('User-Agent'),
+            details={'method': 'password'}
+        )
         
         return jsonify(TokenResponse(
             access_token=access_token,
@@ -139,6 +159,12 @@ def login():
             user=user_response
         ).dict()), 200
     
+    except SessionStoreUnavailable:
+        return jsonify({
+            'success': False,
+            'message': 'Sign-in is temporarily unavailable',
+            'error_code': 'SESSION_STORE_UNAVAILABLE'
+        }), 503
     except Exception as e:
         logger.error(f"Login error: {e}")
         return jsonify({
@@ -221,8 +247,11 @@ def refresh_token():
             if not user_record:
                 return jsonify({'success': False, 'message': 'User not found'}), 404
         
-        # Create new token
-        new_token = auth_manager.create_access_token(dict(user_record))
+        # Create new token within the same session
+        new_token = issue_session_token(
+            dict(user_record), request.remote_addr, request.headers.get('User-Agent'),
+            session_id=(auth_manager.verify_token(token) or {}).get('sid')
+        )
         
         return jsonify({
             'success': True,
@@ -231,10 +260,111 @@ def refresh_token():
             'expires_in': auth_manager.access_token_expires
         }), 200
     
+    except SessionStoreUnavailable:
+        return jsonify({
+            'success': False,
+            'message': 'Sign-in is temporarily unavailable',
+            'error_code': 'SESSION_STORE_UNAVAILABLE'
+        }), 503
     except Exception as e:
         logger.error(f"Token refresh error: {e}")
         return jsonify({
             'success': False,
             'message': 'Token refresh failed',
             'error_code': 'REFRESH_ERROR'
-        }), 500
\ No newline at end of file
+        }), 500
+
+
+@auth_bp.route('/introspect', methods=['POST'])
+def introspect():
+    """RFC 7662 token introspection for resource servers holding the introspection secret"""
+    if not is_introspection_client(request.headers.get('X-Introspection-Secret')):
+        return jsonify({'success': False, 'message': 'Invalid introspection credentials'}), 401
+
+    token = request.form.get('token')
+    if not token:
+        return jsonify({'success': False, 'message': 'token is required'}), 400
+
+    try:
+        return jsonify(introspect_token(token)), 200
+    except Exception as e:
+        logger.error(f"Token introspection error: {e}")
+        return jsonify({
+            'success': False,
+            'message': 'Token introspection failed',
+            'error_code': 'INTROSPECTION_ERROR'
+        }), 500
+
+
+@auth_bp.route('/social/providers', methods=['GET'])
+def social_providers():
+    """Social login providers enabled on this instance"""
+    return jsonify({'success': True, 'providers': enabled_providers()}), 200
+
+
+@auth_bp.route('/social/<provider>/authorize', methods=['GET'])
+def social_authorize(provider):
+    """Start a social login: the frontend sends the browser to `authorization_url`"""
+    try:
+        return jsonify({'success': True, **start_login(provider)}), 200
+    except SocialLoginError as e:
+        return jsonify({'success': False, 'message': e.message}), e.status_code
+    except Exception as e:
+        logger.error(f"Social login start error: {e}")
+        return jsonify({
+            'success': False,
+            'message': 'Failed to start sign-in',
+            'error_code': 'SOCIAL_LOGIN_ERROR'
+        }), 500
+
+
+@auth_bp.route('/social/<provider>/callback', methods=['POST'])
+def social_callback(provider):
+    """Finish a social login with the code and state the provider redirected back with"""
+    try:
+        data = request.get_json()
+        if not data:
+            return jsonify({'success': False, 'message': 'No data provided'}), 400
+        
+        try:
+            callback = SocialLoginCallback(**data)
+        except ValidationError as e:
+            return jsonify({
+                'success': False,
+                'message': 'Validation error',
+                'details': e.errors()
+            }), 400
+        
+        with get_postgres_cursor() as cursor:
+            user_record, created = complete_login(cursor, provider, callback.code, callback.state)
+        
+        user_response = UserResponse(**user_record)
+        access_token = issue_session_token(user_record, request.remote_addr, request.headers.get('User-Agent'))
+        record_security_event(
+            user_record['id'], 'login', request.remote_addr, request.headers.get('User-Agent'),
+            details={'method': provider}
+        )
+        
+        return jsonify(TokenResponse(
+            access_token=access_token,
+            expires_in=auth_manager.access_token_expires,
+            user=user_response
+        ).dict()), 201 if created else 200
+    
+    except SocialLoginError as e:
+        return jsonify({'success': False, 'message': e.message}), e.status_code
+    except QuotaExceeded as e:
+        return jsonify({'success': False, 'message': str(e), 'error_code': 'QUOTA_EXCEEDED'}), 403
+    except SessionStoreUnavailable:
+        return jsonify({
+            'success': False,
+            'message': 'Sign-in is temporarily unavailable',
+            'error_code': 'SESSION_STORE_UNAVAILABLE'
+        }), 503
+    except Exception as e:
+        logger.error(f"Social login error: {e}")
+        return jsonify({
+            'success': False,
+            'message': 'Sign-in failed',
+            'error_code': 'SOCIAL_LOGIN_ERROR'
+        }), 500
@@ -11,6 +11,8 @@ sys.path.append(os.path.join(os.path.dirname(__file__), '../..'))
 
 from shared.database import get_postgres_cursor, get_mongodb, get_redis
 from shared.models import HealthResponse
+from shared.modules import health_checks as module_health_checks, required_services, summary as module_summary
+from shared.storage import health_checks as storage_health_checks
 from shared.utils import health_check_service
 
 health_bp = Blueprint('health', __name__)
@@ -31,12 +33,13 @@ def health_check():
         
         services.update(health_check_service('postgresql', check_postgres))
         
-        # MongoDB health check
+        # MongoDB health check, when an enabled module uses it
         def check_mongodb():
             db = get_mongodb()
             db.command('ping')
         
-        services.update(health_check_service('mongodb', check_mongodb))
+        if 'mongodb' in required_services():
+            services.update(health_check_service('mongodb', check_mongodb))
         
         # Redis health check
         def check_redis():
@@ -45,13 +48,22 @@ def health_check():
         
         services.update(health_check_service('redis', check_redis))
         
+        # Storage drivers in use for media and snapshots
+        for name, check_storage in storage_health_checks().items():
+            services.update(health_check_service(name, check_storage))
+        
+        # Services of enabled modules, such as the anchoring RPC node
+        for name, check_module in module_health_checks().items():
+            services.update(health_check_service(name, check_module))
+        
         # Overall status
         all_healthy = all(status == "healthy" for status in services.values())
         status = "healthy" if all_healthy else "degraded"
         
         response = HealthResponse(
             status=status,
-            services=services
+            services=services,
+            modules=module_summary()
         )
         
         return jsonify(response.dict()), 200 if all_healthy else 503
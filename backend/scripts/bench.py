@@ -0,0 +1,90 @@
+#!/usr/bin/env python3
+"""
+Benchmark the API or the repositories under synthetic load
+
+Usage:
+    python scripts/bench.py api [--base-url URL] [options]
+        # load a running instance over HTTP
+    python scripts/bench.py repository [--memory] [options]
+        # call the repositories directly on the database in .env (or in memory)
+
+Options shared by both:
+    --mix read=60,view=30,like=10   relative weights of the operations
+    --skew 1.1                      Zipf exponent of article popularity (0 = uniform)
+    --concurrency 8 --duration 30   workers, and seconds to run
+    --requests N                    stop after N operations
+    --seed-articles N               publish N synthetic articles first
+    --json                          print the report as JSON
+
+It registers synthetic users, and with --seed-articles publishes synthetic
+articles tagged `bench`; nothing is cleaned up afterwards, so run it against
+a disposable database. Compare runs before and after a change with the same
+--mix, --skew, --concurrency and --random-seed.
+"""
+
+import argparse
+import json
+import logging
+import os
+import sys
+
+BACKEND_DIR = os.path.abspath(os.path.join(os.path.dirname(__file__), '..'))
+sys.path.insert(0, BACKEND_DIR)
+
+from dotenv import load_dotenv
+
+load_dotenv(os.path.join(BACKEND_DIR, '.env'))
+
+from bench import ApiTarget, RepositoryTarget, Workload, parse_mix, run
+from bench.workload import OPERATIONS
+
+
+def main() -> int:
+    parser = argparse.ArgumentParser(description="Benchmark under synthetic load")
+    subcommands = parser.add_subparsers(dest='target', required=True)
+
+    api = subcommands.add_parser('api', help="Load a running instance over HTTP")
+    api.add_argument('--base-url', default=os.getenv('BENCH_BASE_URL', 'http://localhost:8000'),
+                     help="The instance's API base URL (default $BENCH_BASE_URL or http://localhost:8000)")
+    api.add_argument('--timeout', type=float, default=15, help="Seconds to wait for each response")
+
+    repository = subcommands.add_parser('repository', help="Call the repositories directly")
+    repository.add_argument('--memory', action='store_true', help="Use the in-memory repositories")
+
+    for subcommand in (api, repository):
+        subcommand.add_argument('--mix', help=f"Weights of {', '.join(OPERATIONS)}, e.g. read=60,view=30,like=10")
+        subcommand.add_argument('--skew', type=float, default=1.1, help="Zipf exponent of article popularity")
+        subcommand.add_argument('--concurrency', type=int, default=8, help="Concurrent workers")
+        subcommand.add_argument('--duration', type=float, default=30, help="Seconds to run")
+        subcommand.add_argument('--requests', type=int, help="Stop after this many operations")
+        subcommand.add_argument('--users', type=int, default=20, help="Synthetic users doing the operations")
+        subcommand.add_argument('--seed-articles', type=int, default=0,
+                                help="Publish this many synthetic articles first instead of using existing ones")
+        subcommand.add_argument('--pool', type=int, default=1000, help="Most articles to pick from")
+        subcommand.add_argument('--random-seed', type=int, help="Seed for a reproducible sequence of operations")
+        subcommand.add_argument('--json', action='store_true', help="Print the report as JSON")
+
+    args = parser.parse_args()
+    logging.basicConfig(level=logging.INFO, format='%(message)s')
+
+    try:
+        workload = Workload(
+            mix=parse_mix(args.mix), concurrency=args.concurrency, duration_seconds=args.duration,
+            max_operations=args.requests, skew=args.skew, users=max(args.users, 1),
+            seed_articles=args.seed_articles, pool_size=args.pool, random_seed=args.random_seed,
+        )
+        if args.target == 'api':
+            target = ApiTarget(args.base_url, args.timeout)
+        else:
+            target = RepositoryTarget(memory=args.memory)
+        report = run(target, workload)
+    except (ValueError, RuntimeError) as e:
+        print(f"error: {e}", file=sys.stderr)
+        return 1
+
+    print(json.dumps(report.as_dict(), indent=2) if args.json else report.table())
+    return 1 if any(stats.errors for stats in report.operations.values()) else 0
+
+
+if __name__ == '__main__':
+    sys.exit(main())
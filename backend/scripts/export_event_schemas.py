@@ -0,0 +1,128 @@
+#!/usr/bin/env python3
+"""
+Export the domain event contracts to schemas/events/ as JSON Schema
+
+The contracts are declared in shared/event_schemas.py, which producers and
+consumers in this repository validate and convert with; services in other
+languages generate their types from the exported files, one per version
+(schemas/events/<type>/v<version>.json). CI runs this script with --check,
+which fails when the declaration changed without the files being
+regenerated, and when a committed version changed other than by gaining
+optional fields. --register also registers every version with the schema
+registry at SCHEMA_REGISTRY_URL.
+
+Usage:
+    python scripts/export_event_schemas.py             # write the schemas
+    python scripts/export_event_schemas.py --check     # verify the committed schemas are current and compatible
+    python scripts/export_event_schemas.py --register  # write the schemas and register them
+"""
+
+import argparse
+import json
+import os
+import sys
+from collections import Counter
+from typing import Dict
+
+BACKEND_DIR = os.path.abspath(os.path.join(os.path.dirname(__file__), '..'))
+SCHEMAS_DIR = os.path.join(BACKEND_DIR, 'schemas', 'events')
+
+sys.path.insert(0, BACKEND_DIR)
+
+
+def schema_path(event_type: str, version: int) -> str:
+    return os.path.join(SCHEMAS_DIR, event_type, f"v{version}.json")
+
+
+def render(schema: dict) -> str:
+    return json.dumps(schema, indent=2) + '\n'
+
+
+def build_schemas() -> Dict[str, str]:
+    from shared.event_schemas import CONTRACTS, CONVERTERS, json_schema
+    from shared.events import EVENT_TYPES
+
+    undeclared = [event_type for event_type in EVENT_TYPES if event_type not in CONTRACTS]
+    if undeclared:
+        raise SystemExit(f"Event types without a contract: {', '.join(undeclared)}")
+
+    rendered = {}
+    for event_type, contract in CONTRACTS.items():
+        if sorted(contract) != list(range(1, len(contract) + 1)):
+            raise SystemExit(f"Versions of {event_type} must run from 1 without gaps")
+        for version, fields in contract.items():
+            duplicates = [name for name, count in Counter(field[0] for field in fields).items() if count > 1]
+            if duplicates:
+                raise SystemExit(f"Duplicate field in {event_type} v{version}: {', '.join(duplicates)}")
+            if version > 1 and (event_type, version) not in CONVERTERS:
+                raise SystemExit(f"{event_type} v{version} needs converters from and to v{version - 1}")
+            rendered[schema_path(event_type, version)] = render(json_schema(event_type, version))
+    return rendered
+
+
+def check(rendered: Dict[str, str]) -> bool:
+    from shared.event_schemas import compatibility_problems
+
+    current = True
+    for path, schema in rendered.items():
+        name = os.path.relpath(path, BACKEND_DIR)
+        if not os.path.exists(path):
+            print(f"{name} is missing")
+            current = False
+            continue
+        with open(path) as f:
+            committed = f.read()
+        if committed == schema:
+            continue
+        problems = compatibility_problems(json.loads(committed), json.loads(schema))
+        if problems:
+            print(f"{name} changed incompatibly ({'; '.join(problems)}); declare a new version instead")
+        else:
+            print(f"{name} is out of date")
+        current = False
+
+    if os.path.isdir(SCHEMAS_DIR):
+        for directory, _, files in os.walk(SCHEMAS_DIR):
+            for file in files:
+                path = os.path.join(directory, file)
+                if path not in rendered:
+                    print(f"{os.path.relpath(path, BACKEND_DIR)} is no longer declared; versions can't be removed")
+                    current = False
+    return current
+
+
+def main():
+    parser = argparse.ArgumentParser(description=__doc__, formatter_class=argparse.RawDescriptionHelpFormatter)
+    parser.add_argument('--check', action='store_true', help='fail if the committed schemas are out of date')
+    parser.add_argument('--register', action='store_true', help='register the schemas with SCHEMA_REGISTRY_URL')
+    args = parser.parse_args()
+
+    rendered = build_schemas()
+
+    if args.check:
+        if not check(rendered):
+            print("Event schemas are out of date; run scripts/export_event_schemas.py and commit the result")
+            sys.exit(1)
+        print("Event schemas are up to date")
+        return
+
+    for path, schema in rendered.items():
+        os.makedirs(os.path.dirname(path), exist_ok=True)
+        with open(path, 'w') as f:
+            f.write(schema)
+    print(f"Wrote {len(rendered)} schemas to {SCHEMAS_DIR}")
+
+    if args.register:
+        from shared.event_schemas import REGISTRY_URL, register_all
+
+        if not REGISTRY_URL:
+            raise SystemExit("SCHEMA_REGISTRY_URL is not set")
+        results = register_all()
+        for key, result in results.items():
+            print(f"{key}: {result}")
+        if any(isinstance(result, dict) for result in results.values()):
+            sys.exit(1)
+
+
+if __name__ == '__main__':
+    main()
@@ -0,0 +1,63 @@
+#!/usr/bin/env python3
+"""
+Export the binary feed schema to proto/news.proto
+
+The schema is declared in shared/wire_formats.py, which builds the message
+classes the API encodes with; mobile clients generate their Protocol Buffers
+code from the exported file. CI runs this script with --check and fails when
+the declaration changed without the file being regenerated.
+
+Usage:
+    python scripts/export_proto.py          # write the schema
+    python scripts/export_proto.py --check  # verify the committed schema is current
+"""
+
+import argparse
+import os
+import sys
+from collections import Counter
+
+BACKEND_DIR = os.path.abspath(os.path.join(os.path.dirname(__file__), '..'))
+PROTO_PATH = os.path.join(BACKEND_DIR, 'proto', 'news.proto')
+
+sys.path.insert(0, BACKEND_DIR)
+
+
+def build_schema() -> str:
+    from shared.wire_formats import MESSAGES, proto_source
+
+    for message, fields in MESSAGES.items():
+        for label, values in (('field number', [field[0] for field in fields]), ('field name', [field[1] for field in fields])):
+            duplicates = [str(value) for value, count in Counter(values).items() if count > 1]
+            if duplicates:
+                raise SystemExit(f"Duplicate {label} in {message}: {', '.join(duplicates)}")
+
+    return proto_source()
+
+
+def main():
+    parser = argparse.ArgumentParser(description=__doc__, formatter_class=argparse.RawDescriptionHelpFormatter)
+    parser.add_argument('--check', action='store_true', help='fail if the committed schema is out of date')
+    args = parser.parse_args()
+
+    rendered = build_schema()
+
+    if args.check:
+        if not os.path.exists(PROTO_PATH):
+            print(f"{PROTO_PATH} is missing; run scripts/export_proto.py and commit the result")
+            sys.exit(1)
+        with open(PROTO_PATH) as f:
+            if f.read() != rendered:
+                print("Protocol Buffers schema is out of date; run scripts/export_proto.py and commit the result")
+                sys.exit(1)
+        print("Protocol Buffers schema is up to date")
+        return
+
+    os.makedirs(os.path.dirname(PROTO_PATH), exist_ok=True)
+    with open(PROTO_PATH, 'w') as f:
+        f.write(rendered)
+    print(f"Wrote {PROTO_PATH}")
+
+
+if __name__ == '__main__':
+    main()
@@ -0,0 +1,72 @@
+#!/usr/bin/env python3
+"""
+Apply, revert and inspect PostgreSQL schema migrations
+
+Usage:
+    python scripts/migrate.py up [--to VERSION]     # apply pending migrations
+    python scripts/migrate.py down [--steps N]      # revert the last N migrations (default 1)
+    python scripts/migrate.py status                # list migrations and their state
+    python scripts/migrate.py baseline [--to VERSION]
+        # mark migrations as applied without running them (databases created
+        # by the postgres container's init scripts)
+"""
+
+import argparse
+import logging
+import os
+import sys
+
+BACKEND_DIR = os.path.abspath(os.path.join(os.path.dirname(__file__), '..'))
+sys.path.insert(0, BACKEND_DIR)
+
+from dotenv import load_dotenv
+
+load_dotenv(os.path.join(BACKEND_DIR, '.env'))
+
+from shared.migrations import MigrationError, migration_runner
+
+
+def main() -> int:
+    parser = argparse.ArgumentParser(description="PostgreSQL schema migrations")
+    subcommands = parser.add_subparsers(dest='command', required=True)
+
+    up = subcommands.add_parser('up', help="Apply pending migrations")
+    up.add_argument('--to', type=int, help="Stop after this version")
+
+    down = subcommands.add_parser('down', help="Revert applied migrations")
+    down.add_argument('--steps', type=int, default=1, help="Number of migrations to revert")
+
+    subcommands.add_parser('status', help="Show migration state")
+
+    baseline = subcommands.add_parser('baseline', help="Mark migrations as applied without running them")
+    baseline.add_argument('--to', type=int, help="Mark up to this version")
+
+    args = parser.parse_args()
+    logging.basicConfig(level=logging.INFO, format='%(message)s')
+
+    try:
+        if args.command == 'up':
+            applied = migration_runner.up(args.to)
+            print(f"Applied {len(applied)} migration(s)")
+        elif args.command == 'down':
+            reverted = migration_runner.down(args.steps)
+            print(f"Reverted {len(reverted)} migration(s)")
+        elif args.command == 'baseline':
+            marked = migration_runner.baseline(args.to)
+            print(f"Marked {len(marked)} migration(s) as applied")
+        else:
+            entries = migration_runner.status()
+            for entry in entries:
+                applied_at = entry['applied_at'].isoformat() if entry['applied_at'] else '-'
+                print(f"{entry['version']:>4}  {entry['state']:<9} {applied_at:<32} {entry['name']}")
+            if any(entry['state'] in ('modified', 'missing') for entry in entries):
+                return 1
+    except MigrationError as e:
+        print(f"Error: {e}", file=sys.stderr)
+        return 1
+
+    return 0
+
+
+if __name__ == '__main__':
+    sys.exit(main())
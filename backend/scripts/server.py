@@ -0,0 +1,179 @@
+#!/usr/bin/env python3
+"""
+Operate on a running instance
+
+Usage:
+    python scripts/server.py smoke [--base-url URL] [--keep]
+        # run an end-to-end scenario against the instance: register, log in,
+        # publish, interact, search and read the feed, asserting each response
+
+The smoke test is for verifying a staging or production deploy. It creates
+an author account and a published article with unique names. At the end it
+archives the article and deletes the account (scheduled for deletion, as any
+user's would be) unless --keep is given. It exits 1 at the first step that
+fails.
+"""
+
+import argparse
+import os
+import sys
+import time
+import uuid
+from typing import Any, Callable, Dict, List, Optional
+
+import requests
+
+
+class SmokeFailure(Exception):
+    """A response that isn't what the scenario expects"""
+
+
+class SmokeTest:
+    def __init__(self, base_url: str, timeout: float, category: str):
+        self.base_url = base_url.rstrip('/')
+        self.timeout = timeout
+        self.category = category
+        self.session = requests.Session()
+        self.session.headers['User-Agent'] = 'news-app-smoke-test'
+
+        self.token = uuid.uuid4().hex[:12]
+        self.username = f"smoke-{self.token}"
+        self.email = f"smoke-{self.token}@example.com"
+        self.password = f"Smoke-{uuid.uuid4().hex}"
+        self.keyword = f"smoke{self.token}"
+        self.user: Optional[Dict[str, Any]] = None
+        self.article: Optional[Dict[str, Any]] = None
+
+    def call(self, method: str, path: str, expect: int = 200, **kwargs) -> Any:
+        response = self.session.request(method, f"{self.base_url}{path}", timeout=self.timeout, **kwargs)
+        if response.status_code != expect:
+            raise SmokeFailure(f"{method} {path} answered {response.status_code}, expected {expect}: "
+                               f"{response.text[:300]}")
+        return response.json() if response.content else None
+
+    @staticmethod
+    def check(condition: bool, message: str):
+        if not condition:
+            raise SmokeFailure(message)
+
+    # Steps
+    def health(self):
+        self.call('GET', '/api/v1/health/ready')
+
+    def register(self):
+        body = self.call('POST', '/api/v1/auth/register', expect=201, json={
+            'username': self.username, 'email': self.email, 'password': self.password, 'role': 'author',
+        })
+        self.check(body['user']['username'] == self.username, "Registration returned another user")
+        self.check(bool(body.get('access_token')), "Registration returned no access token")
+        self.user = body['user']
+
+    def login(self):
+        body = self.call('POST', '/api/v1/auth/login', json={'email': self.email, 'password': self.password})
+        self.check(bool(body.get('access_token')), "Login returned no access token")
+        self.session.headers['Authorization'] = f"Bearer {body['access_token']}"
+        me = self.call('GET', '/api/v1/auth/me')
+        # Behind nginx, auth is served by Flask, which wraps the user
+        me = me.get('user', me)
+        self.check(me['id'] == self.user['id'], "The token belongs to another user")
+
+    def create_draft(self):
+        self.article = self.call('POST', '/api/v1/articles/', expect=201, json={
+            'title': f"Smoke test {self.keyword}",
+            'summary': "Written by the deploy smoke test",
+            'content': f"<p>This article checks that publishing works. {self.keyword}</p>",
+            'category': self.category,
+            'tags': ['smoke-test'],
+        })
+        self.check(self.article['status'] == 'draft', f"New article is {self.article['status']}, not a draft")
+
+    def publish(self):
+        article = self.call('PUT', f"/api/v1/articles/{self.article['id']}", json={'status': 'published'})
+        self.check(article['status'] == 'published', f"Article is {article['status']} after publishing; "
+                                                     f"is category {self.category} moderated?")
+
+    def read(self):
+        article = self.call('GET', f"/api/v1/articles/{self.article['id']}")
+        self.check(article['title'] == self.article['title'], "Read back another title")
+
+    def interact(self):
+        article_id = self.article['id']
+        liked = self.call('POST', f"/api/v1/interactions/{article_id}/like")
+        self.check(liked['liked'] is True, "Like was not recorded")
+        bookmarked = self.call('POST', f"/api/v1/interactions/{article_id}/bookmark")
+        self.check(bookmarked['bookmarked'] is True, "Bookmark was not recorded")
+        state = self.call('GET', f"/api/v1/interactions/{article_id}/status")
+        self.check(state['liked'] and state['bookmarked'], "Interaction status doesn't show the like and bookmark")
+        self.check(state['stats']['likes'] >= 1, "The article's like count wasn't incremented")
+
+    def search(self):
+        body = self.call('POST', '/api/v1/search/', json={'query': self.keyword, 'limit': 5})
+        ids = [result['id'] for result in body['results']]
+        self.check(self.article['id'] in ids, f"Searching for {self.keyword} didn't find the article")
+
+    def feed(self):
+        body = self.call('GET', '/api/v1/feed/', params={'limit': 20})
+        self.check(isinstance(body.get('data'), list), "The feed has no list of articles")
+
+    def cleanup(self):
+        if self.article:
+            # Archiving is how articles are taken down
+            self.call('PUT', f"/api/v1/articles/{self.article['id']}", json={'status': 'archived'})
+        if self.user and 'Authorization' in self.session.headers:
+            self.call('DELETE', '/api/v1/users/me', json={'password': self.password})
+
+    def steps(self) -> List[Callable[[], None]]:
+        return [self.health, self.register, self.login, self.create_draft, self.publish, self.read,
+                self.interact, self.search, self.feed]
+
+
+def run_step(name: str, step: Callable[[], None]) -> bool:
+    started = time.monotonic()
+    try:
+        step()
+    except (SmokeFailure, requests.RequestException, KeyError, TypeError, ValueError) as e:
+        print(f"FAIL  {name:<14} {e}")
+        return False
+    print(f"ok    {name:<14} {(time.monotonic() - started) * 1000:.0f} ms")
+    return True
+
+
+def smoke(args) -> int:
+    test = SmokeTest(args.base_url, args.timeout, args.category)
+    print(f"Smoke testing {test.base_url} as {test.username}")
+
+    passed = True
+    try:
+        for step in test.steps():
+            if not run_step(step.__name__, step):
+                passed = False
+                break
+    finally:
+        if args.keep and test.user:
+            print(f"Kept user {test.username}" + (f" and article {test.article['id']}" if test.article else ''))
+        elif not args.keep and not run_step('cleanup', test.cleanup):
+            passed = False
+
+    print("Smoke test passed" if passed else "Smoke test failed")
+    return 0 if passed else 1
+
+
+def main() -> int:
+    parser = argparse.ArgumentParser(description="Operate on a running instance")
+    subcommands = parser.add_subparsers(dest='command', required=True)
+
+    smoke_test = subcommands.add_parser('smoke', help="Run an end-to-end smoke test against an instance")
+    smoke_test.add_argument('--base-url', default=os.getenv('SMOKE_BASE_URL', 'http://localhost:8000'),
+                            help="The instance's API base URL (default $SMOKE_BASE_URL or http://localhost:8000)")
+    smoke_test.add_argument('--timeout', type=float, default=15, help="Seconds to wait for each response")
+    smoke_test.add_argument('--category', default='technology', help="Category of the test article")
+    smoke_test.add_argument('--keep', action='store_true', help="Keep the test user and article")
+
+    args = parser.parse_args()
+    if args.command == 'smoke':
+        return smoke(args)
+    return 0
+
+
+if __name__ == '__main__':
+    sys.exit(main())
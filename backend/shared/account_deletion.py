@@ -0,0 +1,135 @@
+"""
+Account deletion
+
+Deleting an account is a soft delete first: the account is deactivated, every
+session is signed out and the purge is scheduled ACCOUNT_DELETION_GRACE_DAYS
+out. Signing in again before then cancels the deletion.
+
+The purge removes what identifies the person and keeps what others rely on.
+Drafts, preferences, follows, bookmarks, reading history, linked identities
+and webhooks are deleted. Published articles and comments stay up without a
+byline, exactly as if they had been posted anonymously, so articles already
+published with AnonymousAuthor are unaffected. The user row is scrubbed to a
+tombstone so foreign keys and payment records stay valid.
+"""
+
+import os
+import logging
+from datetime import datetime, timezone
+from typing import Any, Dict, List, Optional
+
+from shared.database import get_redis
+from shared.sessions import revoke_other_sessions
+
+logger = logging.getLogger(__name__)
+
+GRACE_DAYS = int(os.getenv('ACCOUNT_DELETION_GRACE_DAYS', 30))
+PURGE_BATCH_SIZE = 100
+
+# Personal data deleted outright on purge, all keyed by user_id
+PERSONAL_DATA_TABLES = (
+    'user_preferences', 'user_interactions', 'saved_articles', 'category_follows', 'did_identities', 'user_wallets',
+    'user_auth_providers', 'oauth_grants', 'user_embeddings', 'two_tower_interactions', 'rnn_user_sequences',
+    'attention_features', 'candidate_generation', 'reranking_results', 'recommendation_cache',
+    'push_devices', 'push_topic_subscriptions', 'newsletter_subscriptions', 'newsletter_sends', 'audit_logs',
+    'account_migrations', 'account_aliases',
+)
+
+
+def in_grace_period(user: Dict[str, Any]) -> bool:
+    """Whether a deactivated account is awaiting deletion and can still be restored"""
+    scheduled_for = user.get('deletion_scheduled_for')
+    if user.get('is_active', True) or user.get('deleted_at') or not scheduled_for:
+        return False
+    if scheduled_for.tzinfo is None:
+        scheduled_for = scheduled_for.replace(tzinfo=timezone.utc)
+    return scheduled_for > datetime.now(timezone.utc)
+
+
+def cancel_deletion(users, user: Dict[str, Any]) -> Dict[str, Any]:
+    """Reactivate an account in its grace period; `users` is a user repository"""
+    logger.info(f"Deletion of user {user['id']} cancelled by signing in")
+    return users.update(user['id'], {
+        'is_active': True, 'deletion_requested_at': None, 'deletion_scheduled_for': None,
+    })
+
+
+def request_deletion(cursor, user_id: str) -> Optional[datetime]:
+    """Deactivate an account and schedule its purge, returning when it will happen"""
+    cursor.execute("""
+        UPDATE users
+        SET is_active = false, deletion_requested_at = NOW(),
+            deletion_scheduled_for = NOW() + make_interval(days => %s), updated_at = NOW()
+        WHERE id = %s AND deleted_at IS NULL
+        RETURNING deletion_scheduled_for
+    """, (GRACE_DAYS, user_id))
+    row = cursor.fetchone()
+    return row['deletion_scheduled_for'] if row else None
+
+
+def end_sessions(user_id: str) -> int:
+    """Sign the account out everywhere; called once the deactivation has committed"""
+    try:
+        return revoke_other_sessions(user_id)
+    except Exception as e:
+        logger.warning(f"Could not revoke sessions of user {user_id}: {e}")
+        return 0
+
+
+def accounts_due_for_purge(cursor, limit: int = PURGE_BATCH_SIZE) -> List[str]:
+    cursor.execute("""
+        SELECT id FROM users
+        WHERE is_active = false AND deleted_at IS NULL AND deletion_scheduled_for <= NOW()
+        ORDER BY deletion_scheduled_for
+        LIMIT %s
+    """, (limit,))
+    return [str(row['id']) for row in cursor.fetchall()]
+
+
+def purge_account(cursor, user_id: str) -> Optional[Dict[str, int]]:
+    """Anonymize an account whose grace period is over; None if it is no longer due"""
+    cursor.execute("""
+        SELECT id FROM users
+        WHERE id = %s AND is_active = false AND deleted_at IS NULL AND deletion_scheduled_for <= NOW()
+        FOR UPDATE
+    """, (user_id,))
+    if not cursor.fetchone():
+        return None
+
+    summary = {}
+    # Unpublished work was never seen by anyone else
+    cursor.execute("DELETE FROM articles WHERE author_id = %s AND status = 'draft'", (user_id,))
+    summary['drafts_deleted'] = cursor.rowcount
+    cursor.execute(
+        "UPDATE articles SET anonymous_author = true WHERE author_id = %s AND anonymous_author = false",
+        (user_id,)
+    )
+    summary['articles_anonymized'] = cursor.rowcount
+    # Their credits on others' articles go; the lead's follow the articles above
+    cursor.execute("DELETE FROM article_authors WHERE user_id = %s AND role <> 'lead'", (user_id,))
+    summary['coauthor_credits_removed'] = cursor.rowcount
+    cursor.execute(
+        "UPDATE comments SET is_anonymous = true WHERE user_id = %s AND is_anonymous = false", (user_id,)
+    )
+    summary['comments_anonymized'] = cursor.rowcount
+
+    for table in PERSONAL_DATA_TABLES:
+        cursor.execute(f"DELETE FROM {table} WHERE user_id = %s", (user_id,))
+    cursor.execute("DELETE FROM user_follows WHERE follower_id = %s OR following_id = %s", (user_id, user_id))
+    cursor.execute("DELETE FROM webhooks WHERE owner_id = %s", (user_id,))
+
+    cursor.execute("""
+        UPDATE users
+        SET username = 'deleted-' || replace(id::text, '-', ''), email = 'deleted-' || id || '@deleted.invalid',
+            password_hash = NULL, did_address = NULL, profile_data = '{}', preferences = '{}',
+            anonymous_mode = true, deleted_at = NOW(), updated_at = NOW()
+        WHERE id = %s
+    """, (user_id,))
+
+    try:
+        get_redis().delete(f"sessions:{user_id}", f"api_usage:{user_id}:requests", f"api_usage:{user_id}:tokens")
+    except Exception as e:
+        logger.warning(f"Could not clear cached data of user {user_id}: {e}")
+
+    logger.info(f"Purged user {user_id}: {summary}")
+    return summary
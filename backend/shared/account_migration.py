@@ -0,0 +1,349 @@
+"""
+Moving an account to another instance
+
+An account leaves in three steps:
+
+1. On the origin, `GET /users/me/migration/export?destination=<domain>`
+   returns an export: the profile, followed authors and categories, and
+   references to the account's published articles (id, URL, publish date
+   and hash), signed with the origin's node key. Exports name their
+   destination and expire after ACCOUNT_MIGRATION_EXPORT_TTL_SECONDS.
+2. On the destination, signed in to the new account, `POST
+   /users/me/migration/import` with the export. The destination only
+   accepts exports from active federation peers, checks the signature
+   against the keys the origin publishes at /api/v1/node/keys, fills in
+   profile fields the new account has left empty, follows the categories
+   and the authors it can resolve (those that moved here themselves), and
+   records the old account as an alias of the new one. It answers with a
+   receipt signed by its own node key.
+3. Back on the origin, `POST /users/me/migration/complete` with the
+   receipt. Once it verifies, the old account is marked moved to the new
+   one and Fediverse followers are sent a Move.
+
+Articles stay where they were published; the new account lists them as
+references. Wallets are listed in the export for information only: the
+destination asks for a fresh signature before linking one.
+"""
+
+import os
+import json
+import uuid
+import logging
+from datetime import datetime, timedelta, timezone
+from typing import Any, Dict, List, Optional
+from urllib.parse import urlsplit
+
+from shared import http_signatures, merkle
+from shared.database import prepare_json_data
+from shared.feed_formats import PUBLIC_BASE_URL, article_url
+
+logger = logging.getLogger(__name__)
+
+DOMAIN = (os.getenv('NODE_DOMAIN') or urlsplit(PUBLIC_BASE_URL).hostname or '').lower()
+EXPORT_TTL_SECONDS = int(os.getenv('ACCOUNT_MIGRATION_EXPORT_TTL_SECONDS', 7 * 24 * 60 * 60))
+
+EXPORT_TYPE = 'account-migration'
+RECEIPT_TYPE = 'account-migration-receipt'
+VERSION = 1
+
+EXPORTED = 'exported'
+IMPORTED = 'imported'
+COMPLETED = 'completed'
+
+# Profile fields carried over; the rest of profile_data stays behind
+PROFILE_FIELDS = ('display_name', 'bio', 'avatar_url', 'website', 'location')
+
+
+class MigrationError(Exception):
+    """An export, import or receipt that can't be accepted"""
+
+
+def _issuer() -> Dict[str, Any]:
+    return {
+        'url': PUBLIC_BASE_URL,
+        'domain': DOMAIN,
+        'key_id': http_signatures.key_id(),
+        'algorithm': http_signatures.ALGORITHM,
+    }
+
+
+def _plain(document: Dict[str, Any]) -> Dict[str, Any]:
+    """The document as it reads back from JSON, which is what gets signed and verified"""
+    return json.loads(json.dumps(
+        document, default=lambda value: value.isoformat() if hasattr(value, 'isoformat') else str(value)
+    ))
+
+
+def _sign(document: Dict[str, Any]) -> Dict[str, Any]:
+    signed = http_signatures.sign_document(_plain(document))
+    if signed is None:
+        raise MigrationError("This node has no signing key to sign migration documents with")
+    return signed
+
+
+def account_url(username: str) -> str:
+    from shared.activitypub import actor_url
+    return actor_url(username)
+
+
+def export_account(cursor, user: Dict[str, Any], destination: str) -> Dict[str, Any]:
+    """A signed export of the account for `destination`, recorded so its receipt can be matched"""
+    destination = (destination or '').strip().lower()
+    if not destination or destination == DOMAIN:
+        raise MigrationError("Name the domain of the instance you are moving to")
+    if user.get('moved_to'):
+        raise MigrationError(f"This account has already moved to {user['moved_to']}")
+    user_id = str(user['id'])
+
+    cursor.execute("""
+        SELECT u.id, u.username FROM user_follows f JOIN users u ON f.following_id = u.id
+        WHERE f.follower_id = %s AND u.is_active = true
+        ORDER BY f.created_at
+    """, (user_id,))
+    followed = [
+        {'id': str(row['id']), 'username': row['username'], 'url': account_url(row['username'])}
+        for row in cursor.fetchall()
+    ]
+    cursor.execute("SELECT category FROM category_follows WHERE user_id = %s ORDER BY category", (user_id,))
+    categories = [row['category'] for row in cursor.fetchall()]
+    cursor.execute("SELECT COUNT(*) AS count FROM user_follows WHERE following_id = %s", (user_id,))
+    followers = cursor.fetchone()['count']
+    cursor.execute("""
+        SELECT id, title, summary, content, published_at FROM articles
+        WHERE author_id = %s AND status = 'published' AND NOT COALESCE(anonymous_author, false)
+        ORDER BY published_at
+    """, (user_id,))
+    articles = [
+        {
+            'id': str(row['id']), 'title': row['title'], 'url': article_url(row),
+            'published_at': row['published_at'], 'article_hash': merkle.article_hash(row),
+        }
+        for row in cursor.fetchall()
+    ]
+    cursor.execute("SELECT address, did, is_primary FROM user_wallets WHERE user_id = %s ORDER BY created_at",
+                   (user_id,))
+    wallets = [dict(row) for row in cursor.fetchall()]
+
+    issued_at = datetime.now(timezone.utc)
+    profile = user.get('profile_data') or {}
+    document = _sign({
+        'type': EXPORT_TYPE,
+        'version': VERSION,
+        'export_id': str(uuid.uuid4()),
+        'issued_at': issued_at,
+        'expires_at': issued_at + timedelta(seconds=EXPORT_TTL_SECONDS),
+        'origin': _issuer(),
+        'destination': destination,
+        'account': {
+            'id': user_id,
+            'username': user['username'],
+            'url': account_url(user['username']),
+            'created_at': user['created_at'],
+            'profile': {name: profile[name] for name in PROFILE_FIELDS if profile.get(name)},
+            'wallets': wallets,
+        },
+        'follows': {'authors': followed, 'categories': categories},
+        'followers_count': followers,
+        'articles': articles,
+    })
+    cursor.execute("""
+        INSERT INTO account_migrations (user_id, direction, export_id, domain, status, document)
+        VALUES (%s, 'export', %s, %s, %s, %s)
+    """, (user_id, document['export_id'], destination, EXPORTED, prepare_json_data(document)))
+    return document
+
+
+def _peer(cursor, domain: str) -> Dict[str, Any]:
+    cursor.execute(
+        "SELECT * FROM federation_peers WHERE lower(domain) = %s AND is_active = true", ((domain or '').lower(),)
+    )
+    peer = cursor.fetchone()
+    if not peer:
+        raise MigrationError(f"{domain} is not a federation peer of this instance")
+    return dict(peer)
+
+
+def _verify(peer: Dict[str, Any], document: Dict[str, Any], key_id: Optional[str]):
+    """Raise MigrationError unless the document is signed by the peer's node key `key_id`"""
+    from shared.federation import peer_keys
+
+    try:
+        keys = peer_keys(peer)
+    except Exception as e:
+        raise MigrationError(f"Could not fetch the signing keys of {peer['domain']}: {e}")
+    if key_id not in keys or not http_signatures.verify_document(document, keys[key_id]):
+        raise MigrationError(f"The signature does not match a signing key of {peer['domain']}")
+
+
+def _expired(value: Optional[str]) -> bool:
+    try:
+        return datetime.fromisoformat(value) <= datetime.now(timezone.utc)
+    except (TypeError, ValueError):
+        return True
+
+
+def import_account(cursor, user: Dict[str, Any], document: Dict[str, Any]) -> Dict[str, Any]:
+    """Apply a verified export to the signed-in account; returns the receipt for the origin and what was imported"""
+    if document.get('type') != EXPORT_TYPE or document.get('version') != VERSION:
+        raise MigrationError("Not an account migration export")
+    if (document.get('destination') or '').lower() != DOMAIN:
+        raise MigrationError("This export was made for another instance")
+    if _expired(document.get('expires_at')):
+        raise MigrationError("This export has expired; export the account again")
+    origin = document.get('origin') or {}
+    account = document.get('account') or {}
+    peer = _peer(cursor, origin.get('domain'))
+    _verify(peer, document, origin.get('key_id'))
+
+    user_id = str(user['id'])
+    cursor.execute(
+        "SELECT 1 FROM account_migrations WHERE direction = 'import' AND export_id = %s", (document['export_id'],)
+    )
+    if cursor.fetchone():
+        raise MigrationError("This export has already been imported")
+    cursor.execute(
+        "SELECT user_id FROM account_aliases WHERE domain = %s AND remote_user_id = %s",
+        (peer['domain'], account['id'])
+    )
+    alias = cursor.fetchone()
+    if alias and str(alias['user_id']) != user_id:
+        raise MigrationError("This account has already moved to another account here")
+
+    # Fields the new account has already filled in win over imported ones. An account
+    # that had moved away and is now taking an account in has come back, so it stops
+    # redirecting, or the two would redirect to each other.
+    profile = {name: value for name, value in (account.get('profile') or {}).items() if name in PROFILE_FIELDS}
+    cursor.execute("""
+        UPDATE users SET profile_data = %s::jsonb || COALESCE(profile_data, '{}'::jsonb),
+                         moved_to = NULL, moved_at = NULL, updated_at = NOW()
+        WHERE id = %s
+    """, (prepare_json_data(profile), user_id))
+
+    categories = [category for category in (document.get('follows') or {}).get('categories', [])
+                  if isinstance(category, str)]
+    for category in categories:
+        cursor.execute("""
+            INSERT INTO category_follows (user_id, category) VALUES (%s, %s)
+            ON CONFLICT (user_id, category) DO NOTHING
+        """, (user_id, category[:100]))
+
+    authors = (document.get('follows') or {}).get('authors', [])
+    cursor.execute("""
+        SELECT remote_user_id, user_id FROM account_aliases
+        WHERE domain = %s AND remote_user_id = ANY(%s) AND user_id != %s
+    """, (peer['domain'], [str(author.get('id')) for author in authors], user_id))
+    resolved = {row['remote_user_id']: str(row['user_id']) for row in cursor.fetchall()}
+    for following_id in resolved.values():
+        cursor.execute("""
+            INSERT INTO user_follows (follower_id, following_id) VALUES (%s, %s)
+            ON CONFLICT (follower_id, following_id) DO NOTHING
+        """, (user_id, following_id))
+
+    cursor.execute("""
+        INSERT INTO account_migrations (user_id, direction, export_id, domain, status, document)
+        VALUES (%s, 'import', %s, %s, %s, %s)
+        RETURNING id
+    """, (user_id, document['export_id'], peer['domain'], IMPORTED, prepare_json_data(document)))
+    migration_id = cursor.fetchone()['id']
+    cursor.execute("""
+        INSERT INTO account_aliases (user_id, domain, remote_user_id, remote_username, remote_url, migration_id)
+        VALUES (%s, %s, %s, %s, %s, %s)
+        ON CONFLICT (domain, remote_user_id) DO UPDATE SET migration_id = EXCLUDED.migration_id
+    """, (user_id, peer['domain'], account['id'], account['username'], account['url'], migration_id))
+
+    receipt = _sign({
+        'type': RECEIPT_TYPE,
+        'version': VERSION,
+        'export_id': document['export_id'],
+        'issued_at': datetime.now(timezone.utc),
+        'issuer': _issuer(),
+        'origin': {'domain': peer['domain'], 'account_id': account['id']},
+        'account': {'id': user_id, 'username': user['username'], 'url': account_url(user['username'])},
+    })
+    cursor.execute(
+        "UPDATE account_migrations SET receipt = %s WHERE id = %s", (prepare_json_data(receipt), migration_id)
+    )
+    return {
+        'receipt': receipt,
+        'imported': {
+            'profile_fields': sorted(profile),
+            'categories': len(categories),
+            'authors_followed': len(resolved),
+            'authors_not_found': len(authors) - len(resolved),
+            'articles': len(document.get('articles') or []),
+        },
+    }
+
+
+def complete_move(cursor, user: Dict[str, Any], receipt: Dict[str, Any]) -> Dict[str, Any]:
+    """Mark the account moved once the destination's receipt for one of its exports verifies"""
+    if receipt.get('type') != RECEIPT_TYPE or receipt.get('version') != VERSION:
+        raise MigrationError("Not an account migration receipt")
+    user_id = str(user['id'])
+    cursor.execute("""
+        SELECT * FROM account_migrations
+        WHERE direction = 'export' AND export_id::text = %s AND user_id = %s
+    """, (str(receipt.get('export_id')), user_id))
+    migration = cursor.fetchone()
+    if not migration:
+        raise MigrationError("This receipt is not for an export of this account")
+    if migration['status'] == COMPLETED:
+        raise MigrationError("This move has already been completed")
+
+    issuer = receipt.get('issuer') or {}
+    origin = receipt.get('origin') or {}
+    if (issuer.get('domain') or '').lower() != migration['domain']:
+        raise MigrationError("The receipt was not issued by the instance the account was exported to")
+    if (origin.get('domain') or '').lower() != DOMAIN or str(origin.get('account_id')) != user_id:
+        raise MigrationError("The receipt is for another account")
+    peer = _peer(cursor, migration['domain'])
+    _verify(peer, receipt, issuer.get('key_id'))
+
+    moved_to = (receipt.get('account') or {}).get('url')
+    if not moved_to:
+        raise MigrationError("The receipt does not say where the account moved to")
+    cursor.execute("UPDATE users SET moved_to = %s, moved_at = NOW(), updated_at = NOW() WHERE id = %s",
+                   (moved_to, user_id))
+    cursor.execute("""
+        UPDATE account_migrations SET status = %s, receipt = %s, completed_at = NOW() WHERE id = %s
+    """, (COMPLETED, prepare_json_data(receipt), migration['id']))
+    announce_move(cursor, user, moved_to)
+    return {'moved_to': moved_to, 'export_id': str(migration['export_id'])}
+
+
+def announce_move(cursor, user: Dict[str, Any], moved_to: str):
+    """Send the account's Fediverse followers a Move to the new account"""
+    if os.getenv('ACTIVITYPUB_ENABLED', 'false').lower() != 'true':
+        return
+    from shared.activitypub import PUBLIC, follower_inboxes
+    from shared.jobs import deliver_activity
+
+    old = account_url(user['username'])
+    activity = {
+        '@context': 'https://www.w3.org/ns/activitystreams',
+        'id': f"{old}#moves/{uuid.uuid4()}",
+        'type': 'Move',
+        'actor': old,
+        'object': old,
+        'target': moved_to,
+        'to': [PUBLIC],
+    }
+    for inbox in follower_inboxes(cursor, str(user['id'])):
+        deliver_activity.apply_async(args=[str(user['id']), inbox, activity], countdown=5)
+
+
+def migrations_for(cursor, user_id: str) -> List[Dict[str, Any]]:
+    """The account's exports and imports, newest first"""
+    cursor.execute("""
+        SELECT id, direction, export_id, domain, status, created_at, completed_at,
+               document->'account'->>'url' AS account_url, receipt->'account'->>'url' AS moved_to,
+               document->'articles' AS articles
+        FROM account_migrations WHERE user_id = %s
+        ORDER BY created_at DESC
+    """, (user_id,))
+    return [dict(row) for row in cursor.fetchall()]
+
+
+def aliases(cursor, user_id: str) -> List[str]:
+    cursor.execute("SELECT remote_url FROM account_aliases WHERE user_id = %s ORDER BY created_at", (user_id,))
+    return [row['remote_url'] for row in cursor.fetchall()]
@@ -0,0 +1,519 @@
+"""
+ActivityPub federation with the Fediverse
+
+Every author is an ActivityPub actor (`acct:username@ACTIVITYPUB_DOMAIN`
+through WebFinger) that Mastodon and other Fediverse accounts can follow.
+When an article is published, a Create activity is delivered to the author's
+followers, once per server where the follower has a shared inbox. Likes and
+Announces of an article coming back to an inbox count as likes and shares of
+that article. Requests in both directions are authenticated with HTTP
+Signatures (draft-cavage, rsa-sha256), the scheme Mastodon uses; each actor
+has its own RSA key, created when the actor is first fetched.
+"""
+
+import os
+import re
+import json
+import base64
+import hashlib
+import logging
+from datetime import datetime, timezone
+from email.utils import format_datetime, parsedate_to_datetime
+from typing import Any, Dict, List, Optional, Tuple
+from urllib.parse import urlsplit
+
+import requests
+from cryptography.exceptions import InvalidSignature
+from cryptography.hazmat.primitives import hashes, serialization
+from cryptography.hazmat.primitives.asymmetric import padding, rsa
+
+from shared import tenant_keys
+from shared.database import get_redis
+from shared.feed_formats import article_url
+from shared.paywall import public_copy
+from shared.reactions import update_engagement_score
+from shared.webhooks import is_allowed_target
+
+logger = logging.getLogger(__name__)
+
+BASE_URL = os.getenv('ACTIVITYPUB_BASE_URL', 'http://localhost').rstrip('/')
+DOMAIN = os.getenv('ACTIVITYPUB_DOMAIN') or urlsplit(BASE_URL).hostname
+CONTENT_TYPE = 'application/activity+json'
+PUBLIC = 'https://www.w3.org/ns/activitystreams#Public'
+CONTEXT = ['https://www.w3.org/ns/activitystreams', 'https://w3id.org/security/v1']
+ACCEPTED_TYPES = ('application/activity+json', 'application/ld+json')
+
+OUTBOX_PAGE_SIZE = 20
+REQUEST_TIMEOUT_SECONDS = int(os.getenv('ACTIVITYPUB_REQUEST_TIMEOUT_SECONDS', 10))
+ACTOR_CACHE_SECONDS = 60 * 60
+MAX_CLOCK_SKEW_SECONDS = 12 * 60 * 60
+SIGNED_HEADERS = '(request-target) host date digest'
+
+INTERACTION_COUNTERS = {'like': 'like_count', 'announce': 'share_count'}
+
+
+class SignatureError(Exception):
+    """Raised when an incoming request's HTTP signature can't be verified"""
+
+
+def actor_url(username: str) -> str:
+    return f"{BASE_URL}/ap/users/{username}"
+
+
+def object_url(article_id: str) -> str:
+    return f"{BASE_URL}/ap/articles/{article_id}"
+
+
+def wants_activity_json(accept: Optional[str]) -> bool:
+    return any(kind in (accept or '') for kind in ACCEPTED_TYPES)
+
+
+def get_actor_user(cursor, username: str) -> Optional[Dict[str, Any]]:
+    """The local user behind an actor; anonymous-mode and deactivated accounts aren't exposed"""
+    cursor.execute("""
+        SELECT id, username, profile_data, moved_to, created_at,
+               ARRAY(SELECT remote_url FROM account_aliases WHERE user_id = users.id) AS aliases
+        FROM users
+        WHERE username = %s AND is_active = true AND COALESCE(anonymous_mode, false) = false
+    """, (username,))
+    user = cursor.fetchone()
+    return dict(user) if user else None
+
+
+def actor_keys(cursor, user_id: str) -> Dict[str, str]:
+    """The actor's keypair, generated on first use; the private key is stored encrypted for its publication"""
+    keys = _stored_keys(cursor, user_id)
+    if not keys:
+        _generate_keys(cursor, user_id)
+        keys = _stored_keys(cursor, user_id)
+
+    private_pem = tenant_keys.decrypt(keys['private_key_pem'], keys['tenant_id'])
+    if tenant_keys.needs_rotation(keys['private_key_pem']):
+        cursor.execute(
+            "UPDATE ap_actor_keys SET private_key_pem = %s WHERE user_id = %s",
+            (tenant_keys.encrypt(private_pem, keys['tenant_id']), user_id)
+        )
+    return {'public_key_pem': keys['public_key_pem'], 'private_key_pem': private_pem}
+
+
+def _stored_keys(cursor, user_id: str) -> Optional[Dict[str, Any]]:
+    cursor.execute(
+        "SELECT public_key_pem, private_key_pem, tenant_id FROM ap_actor_keys WHERE user_id = %s", (user_id,)
+    )
+    keys = cursor.fetchone()
+    return dict(keys) if keys else None
+
+
+def _generate_keys(cursor, user_id: str):
+    cursor.execute("SELECT tenant_id FROM users WHERE id = %s", (user_id,))
+    owner = cursor.fetchone()
+    tenant_id = owner['tenant_id'] if owner else None
+    private_key = rsa.generate_private_key(public_exponent=65537, key_size=2048)
+    private_pem = private_key.private_bytes(
+        serialization.Encoding.PEM, serialization.PrivateFormat.PKCS8, serialization.NoEncryption()
+    ).decode()
+    public_pem = private_key.public_key().public_bytes(
+        serialization.Encoding.PEM, serialization.PublicFormat.SubjectPublicKeyInfo
+    ).decode()
+    cursor.execute("""
+        INSERT INTO ap_actor_keys (user_id, public_key_pem, private_key_pem, tenant_id) VALUES (%s, %s, %s, %s)
+        ON CONFLICT (user_id) DO NOTHING
+    """, (user_id, public_pem, tenant_keys.encrypt(private_pem, tenant_id), tenant_id))
+
+
+def actor_document(user: Dict[str, Any], public_key_pem: str) -> Dict[str, Any]:
+    url = actor_url(user['username'])
+    profile = user.get('profile_data') or {}
+    document = {
+        '@context': CONTEXT,
+        'id': url,
+        'type': 'Person',
+        'preferredUsername': user['username'],
+        'name': profile.get('display_name') or user['username'],
+        'summary': profile.get('bio') or '',
+        'inbox': f"{url}/inbox",
+        'outbox': f"{url}/outbox",
+        'followers': f"{url}/followers",
+        'endpoints': {'sharedInbox': f"{BASE_URL}/ap/inbox"},
+        'published': user['created_at'].isoformat(),
+        'publicKey': {'id': f"{url}#main-key", 'owner': url, 'publicKeyPem': public_key_pem},
+    }
+    if profile.get('avatar_url'):
+        document['icon'] = {'type': 'Image', 'url': profile['avatar_url']}
+    # Accounts moved here from, and to, other instances (shared/account_migration.py)
+    if user.get('aliases'):
+        document['alsoKnownAs'] = user['aliases']
+    if user.get('moved_to'):
+        document['movedTo'] = user['moved_to']
+    return document
+
+
+def webfinger(cursor, resource: str) -> Optional[Dict[str, Any]]:
+    """WebFinger document for `acct:username@domain` or an actor URL"""
+    match = re.fullmatch(r'(?:acct:)?@?([^@/]+)@(.+)', resource or '')
+    if match:
+        username, domain = match.groups()
+        if domain.lower() != (DOMAIN or '').lower():
+            return None
+    elif (resource or '').startswith(f"{BASE_URL}/ap/users/"):
+        username = resource.rsplit('/', 1)[-1]
+    else:
+        return None
+
+    user = get_actor_user(cursor, username)
+    if not user:
+        return None
+    return {
+        'subject': f"acct:{user['username']}@{DOMAIN}",
+        'aliases': [actor_url(user['username'])],
+        'links': [
+            {'rel': 'self', 'type': CONTENT_TYPE, 'href': actor_url(user['username'])},
+        ],
+    }
+
+
+def _hashtags(tags: List[str]) -> List[Dict[str, str]]:
+    return [
+        {'type': 'Hashtag', 'name': f"#{re.sub(r'[^0-9A-Za-z_]', '', tag)}",
+         'href': f"{BASE_URL}/api/v1/search?tags={tag}"}
+        for tag in tags if re.sub(r'[^0-9A-Za-z_]', '', tag)
+    ]
+
+
+def article_object(article: Dict[str, Any], username: str) -> Dict[str, Any]:
+    # Followers on other servers read without an account here, so premium articles go out as previews
+    article = public_copy(article)
+    actor = actor_url(username)
+    published = article.get('published_at') or article['created_at']
+    document = {
+        'id': object_url(article['id']),
+        'type': 'Article',
+        'attributedTo': actor,
+        'name': article['title'],
+        'summary': article.get('summary'),
+        'content': article['content_html'],
+        'url': article_url(article),
+        'published': published.isoformat(),
+        'to': [PUBLIC],
+        'cc': [f"{actor}/followers"],
+        'tag': _hashtags(list(article.get('tags') or [])),
+    }
+    image = (article.get('image_urls') or [None])[0] or article.get('og_image_url')
+    if image:
+        document['image'] = {'type': 'Image', 'url': image}
+    if article.get('language'):
+        document['contentMap'] = {article['language']: article['content_html']}
+    if article.get('updated_at') and article['updated_at'] > published:
+        document['updated'] = article['updated_at'].isoformat()
+    return document
+
+
+def create_activity(article: Dict[str, Any], username: str) -> Dict[str, Any]:
+    obj = article_object(article, username)
+    return {
+        '@context': CONTEXT,
+        'id': f"{obj['id']}/activity",
+        'type': 'Create',
+        'actor': obj['attributedTo'],
+        'published': obj['published'],
+        'to': obj['to'],
+        'cc': obj['cc'],
+        'object': obj,
+    }
+
+
+def published_articles(cursor, user_id: str, limit: Optional[int] = None, offset: int = 0) -> List[Dict[str, Any]]:
+    query = """
+        SELECT * FROM articles
+        WHERE author_id = %s AND status = 'published' AND anonymous_author = false
+        ORDER BY published_at DESC NULLS LAST, id DESC
+    """
+    params = [user_id]
+    if limit is not None:
+        query += " LIMIT %s OFFSET %s"
+        params.extend([limit, offset])
+    cursor.execute(query, params)
+    return [dict(row) for row in cursor.fetchall()]
+
+
+def outbox(cursor, user: Dict[str, Any], page: Optional[int] = None) -> Dict[str, Any]:
+    """The author's Create activities as an OrderedCollection, or one page of it"""
+    url = f"{actor_url(user['username'])}/outbox"
+    cursor.execute(
+        "SELECT COUNT(*) AS total FROM articles WHERE author_id = %s AND status = 'published' AND anonymous_author = false",
+        (user['id'],)
+    )
+    total = cursor.fetchone()['total']
+    if page is None:
+        return {
+            '@context': CONTEXT, 'id': url, 'type': 'OrderedCollection', 'totalItems': total,
+            'first': f"{url}?page=1", 'last': f"{url}?page={max(1, -(-total // OUTBOX_PAGE_SIZE))}",
+        }
+
+    articles = published_articles(cursor, user['id'], OUTBOX_PAGE_SIZE, (page - 1) * OUTBOX_PAGE_SIZE)
+    collection_page = {
+        '@context': CONTEXT, 'id': f"{url}?page={page}", 'type': 'OrderedCollectionPage', 'partOf': url,
+        'totalItems': total, 'orderedItems': [create_activity(article, user['username']) for article in articles],
+    }
+    if page * OUTBOX_PAGE_SIZE < total:
+        collection_page['next'] = f"{url}?page={page + 1}"
+    if page > 1:
+        collection_page['prev'] = f"{url}?page={page - 1}"
+    return collection_page
+
+
+def followers_collection(cursor, user: Dict[str, Any]) -> Dict[str, Any]:
+    """Follower count only; the followers themselves aren't published"""
+    cursor.execute("SELECT COUNT(*) AS total FROM ap_followers WHERE user_id = %s", (user['id'],))
+    return {
+        '@context': CONTEXT, 'id': f"{actor_url(user['username'])}/followers",
+        'type': 'OrderedCollection', 'totalItems': cursor.fetchone()['total'],
+    }
+
+
+def _digest(body: bytes) -> str:
+    return 'SHA-256=' + base64.b64encode(hashlib.sha256(body).digest()).decode()
+
+
+def signed_headers(method: str, url: str, body: bytes, key_id: str, private_key_pem: str) -> Dict[str, str]:
+    """Date, Digest and Signature headers for an outgoing request"""
+    parts = urlsplit(url)
+    target = parts.path + (f"?{parts.query}" if parts.query else '')
+    headers = {
+        'Host': parts.netloc,
+        'Date': format_datetime(datetime.now(timezone.utc), usegmt=True),
+        'Digest': _digest(body),
+    }
+    signing_string = '\n'.join([
+        f"(request-target): {method.lower()} {target}",
+        f"host: {headers['Host']}",
+        f"date: {headers['Date']}",
+        f"digest: {headers['Digest']}",
+    ])
+    private_key = serialization.load_pem_private_key(private_key_pem.encode(), password=None)
+    signature = private_key.sign(signing_string.encode(), padding.PKCS1v15(), hashes.SHA256())
+    headers['Signature'] = (
+        f'keyId="{key_id}",algorithm="rsa-sha256",headers="{SIGNED_HEADERS}",'
+        f'signature="{base64.b64encode(signature).decode()}"'
+    )
+    return headers
+
+
+def fetch_actor(url: str) -> Dict[str, Any]:
+    """A remote actor document, cached for an hour"""
+    cache_key = f"ap_actor:{url}"
+    try:
+        cached = get_redis().get(cache_key)
+        if cached:
+            return json.loads(cached)
+    except Exception as e:
+        logger.warning(f"Actor cache unavailable: {e}")
+
+    if not is_allowed_target(url):
+        raise SignatureError(f"Refusing to fetch actor {url}")
+    response = requests.get(url, headers={'Accept': CONTENT_TYPE}, timeout=REQUEST_TIMEOUT_SECONDS)
+    response.raise_for_status()
+    actor = response.json()
+    try:
+        get_redis().setex(cache_key, ACTOR_CACHE_SECONDS, json.dumps(actor))
+    except Exception:
+        pass
+    return actor
+
+
+def verify_request(method: str, path: str, headers: Dict[str, str], body: bytes) -> str:
+    """Check an incoming request's signature and digest, returning the signing actor's id"""
+    headers = {name.lower(): value for name, value in headers.items()}
+    params = dict(re.findall(r'(\w+)="([^"]*)"', headers.get('signature', '')))
+    if not params.get('keyId') or not params.get('signature'):
+        raise SignatureError("Missing signature")
+
+    signed = params.get('headers', 'date').split()
+    if '(request-target)' not in signed or 'digest' not in signed:
+        raise SignatureError("Signature must cover the request target and digest")
+    if headers.get('digest') != _digest(body):
+        raise SignatureError("Digest does not match the body")
+    try:
+        sent_at = parsedate_to_datetime(headers.get('date', ''))
+    except (TypeError, ValueError):
+        raise SignatureError("Missing or invalid Date")
+    if abs((datetime.now(timezone.utc) - sent_at).total_seconds()) > MAX_CLOCK_SKEW_SECONDS:
+        raise SignatureError("Date is too far from now")
+
+    lines = []
+    for name in signed:
+        if name == '(request-target)':
+            lines.append(f"(request-target): {method.lower()} {path}")
+        elif name in headers:
+            lines.append(f"{name}: {headers[name]}")
+        else:
+            raise SignatureError(f"Signed header {name} is missing")
+
+    try:
+        actor = fetch_actor(params['keyId'].split('#')[0])
+        key = actor['publicKey']
+        if key.get('id') != params['keyId'] and key.get('owner') != actor['id']:
+            raise SignatureError("Key does not belong to the actor")
+        public_key = serialization.load_pem_public_key(key['publicKeyPem'].encode())
+        public_key.verify(
+            base64.b64decode(params['signature']), '\n'.join(lines).encode(), padding.PKCS1v15(), hashes.SHA256()
+        )
+    except (InvalidSignature, KeyError, ValueError, requests.RequestException) as e:
+        raise SignatureError(f"Signature verification failed: {e}")
+    return actor['id']
+
+
+def deliver(user_id: str, inbox_url: str, activity: Dict[str, Any]):
+    """POST an activity to a remote inbox, signed with the actor's key; raises on failure so the job retries"""
+    from shared.database import get_postgres_cursor
+
+    if not is_allowed_target(inbox_url):
+        logger.warning(f"Skipping delivery to disallowed inbox {inbox_url}")
+        return
+    with get_postgres_cursor() as cursor:
+        keys = actor_keys(cursor, user_id)
+    body = json.dumps(activity).encode()
+    headers = signed_headers('POST', inbox_url, body, f"{activity['actor']}#main-key", keys['private_key_pem'])
+    headers['Content-Type'] = CONTENT_TYPE
+    response = requests.post(inbox_url, data=body, headers=headers, timeout=REQUEST_TIMEOUT_SECONDS)
+    response.raise_for_status()
+
+
+def follower_inboxes(cursor, user_id: str) -> List[str]:
+    """One inbox per server where followers share one"""
+    cursor.execute(
+        "SELECT DISTINCT COALESCE(shared_inbox_url, inbox_url) AS inbox FROM ap_followers WHERE user_id = %s",
+        (user_id,)
+    )
+    return [row['inbox'] for row in cursor.fetchall()]
+
+
+def _article_id_from(object_ref: Any) -> Optional[str]:
+    """The local article an activity's object points at, by ActivityPub id or public URL"""
+    if isinstance(object_ref, dict):
+        object_ref = object_ref.get('id')
+    if not isinstance(object_ref, str):
+        return None
+    match = re.search(r'/(?:ap/)?articles/([0-9a-f-]{36})(?:/activity)?$', object_ref)
+    return match.group(1) if match else None
+
+
+def _record_interaction(cursor, article_id: str, actor_id: str, kind: str, activity_id: Optional[str]) -> bool:
+    cursor.execute("SELECT 1 FROM articles WHERE id = %s AND status = 'published'", (article_id,))
+    if not cursor.fetchone():
+        return False
+    cursor.execute("""
+        INSERT INTO ap_interactions (article_id, actor_id, activity_type, activity_id)
+        VALUES (%s, %s, %s, %s)
+        ON CONFLICT (article_id, actor_id, activity_type) DO NOTHING
+        RETURNING id
+    """, (article_id, actor_id, kind, activity_id))
+    if not cursor.fetchone():
+        return False
+    counter = INTERACTION_COUNTERS[kind]
+    cursor.execute(f"UPDATE articles SET {counter} = {counter} + 1 WHERE id = %s", (article_id,))
+    update_engagement_score(cursor, article_id)
+    return True
+
+
+def _remove_interaction(cursor, actor_id: str, kind: str, activity: Dict[str, Any]) -> bool:
+    article_id = _article_id_from(activity.get('object'))
+    if article_id:
+        cursor.execute(
+            "DELETE FROM ap_interactions WHERE article_id = %s AND actor_id = %s AND activity_type = %s RETURNING article_id",
+            (article_id, actor_id, kind)
+        )
+    else:
+        cursor.execute(
+            "DELETE FROM ap_interactions WHERE activity_id = %s AND actor_id = %s AND activity_type = %s RETURNING article_id",
+            (activity.get('id'), actor_id, kind)
+        )
+    removed = cursor.fetchone()
+    if not removed:
+        return False
+    counter = INTERACTION_COUNTERS[kind]
+    cursor.execute(
+        f"UPDATE articles SET {counter} = GREATEST({counter} - 1, 0) WHERE id = %s", (removed['article_id'],)
+    )
+    update_engagement_score(cursor, str(removed['article_id']))
+    return True
+
+
+def handle_activity(cursor, activity: Dict[str, Any], signer: str,
+                    user: Optional[Dict[str, Any]] = None) -> Optional[Tuple[str, Dict[str, Any]]]:
+    """Apply an incoming activity; for a Follow, returns the inbox and Accept to deliver back
+
+    `user` is the inbox owner for personal inboxes and None for the shared inbox.
+    """
+    actor_id = activity.get('actor')
+    if isinstance(actor_id, dict):
+        actor_id = actor_id.get('id')
+    if actor_id != signer:
+        raise SignatureError("Activity actor is not the signer")
+
+    kind = activity.get('type')
+    if kind == 'Follow':
+        target = activity.get('object')
+        if not user or target != actor_url(user['username']):
+            return None
+        remote = fetch_actor(actor_id)
+        cursor.execute("""
+            INSERT INTO ap_followers (user_id, actor_id, inbox_url, shared_inbox_url, follow_activity_id)
+            VALUES (%s, %s, %s, %s, %s)
+            ON CONFLICT (user_id, actor_id) DO UPDATE
+            SET inbox_url = EXCLUDED.inbox_url, shared_inbox_url = EXCLUDED.shared_inbox_url,
+                follow_activity_id = EXCLUDED.follow_activity_id
+        """, (
+            user['id'], actor_id, remote['inbox'], (remote.get('endpoints') or {}).get('sharedInbox'),
+            activity.get('id')
+        ))
+        logger.info(f"{actor_id} followed {user['username']}")
+        return remote['inbox'], {
+            '@context': CONTEXT,
+            'id': f"{actor_url(user['username'])}#accepts/{hashlib.sha256(actor_id.encode()).hexdigest()[:16]}",
+            'type': 'Accept',
+            'actor': actor_url(user['username']),
+            'object': activity,
+        }
+
+    if kind in ('Like', 'Announce'):
+        article_id = _article_id_from(activity.get('object'))
+        if article_id:
+            _record_interaction(cursor, article_id, actor_id, kind.lower(), activity.get('id'))
+        return None
+
+    if kind == 'Undo' and isinstance(activity.get('object'), dict):
+        undone = activity['object']
+        undone_type = undone.get('type')
+        if undone_type == 'Follow' and user:
+            cursor.execute("DELETE FROM ap_followers WHERE user_id = %s AND actor_id = %s", (user['id'], actor_id))
+        elif undone_type == 'Follow':
+            cursor.execute("DELETE FROM ap_followers WHERE actor_id = %s AND follow_activity_id = %s",
+                           (actor_id, undone.get('id')))
+        elif undone_type in ('Like', 'Announce'):
+            _remove_interaction(cursor, actor_id, undone_type.lower(), undone)
+        return None
+
+    if kind == 'Delete' and activity.get('object') == actor_id:
+        # The remote account is gone
+        cursor.execute("DELETE FROM ap_followers WHERE actor_id = %s", (actor_id,))
+    return None
+
+
+def deliver_published_article(cursor, article: Dict[str, Any]):
+    """Publish hook: deliver the new article to the author's Fediverse followers"""
+    if os.getenv('ACTIVITYPUB_ENABLED', 'false').lower() != 'true':
+        return
+    if article.get('anonymous_author') or not article.get('author_id'):
+        return
+    cursor.execute("SELECT username FROM users WHERE id = %s AND COALESCE(anonymous_mode, false) = false",
+                   (article['author_id'],))
+    author = cursor.fetchone()
+    if not author:
+        return
+
+    from shared.jobs import deliver_activity
+
+    activity = json.loads(json.dumps(create_activity(article, author['username']), default=str))
+    for inbox in follower_inboxes(cursor, article['author_id']):
+        deliver_activity.apply_async(args=[str(article['author_id']), inbox, activity], countdown=5)
@@ -0,0 +1,98 @@
+"""
+Redirects for renamed accounts, moved accounts and retitled articles
+
+Old profile and article addresses keep working. `url_aliases` maps each
+username an account gave up and each slug a published article had to the
+account or article it belonged to; the database records them when a
+username or title changes (56_url_aliases.sql), so every code path that
+renames gets them for free. A name someone else takes stops redirecting.
+
+Resolution follows the chain to the current address: an alias to the
+account's current username, a moved account to its `moved_to` (read on
+this instance when it points back here). Callers answer with a 301 to
+where it ends up. A chain that comes back to a name it already passed
+through, or runs longer than MAX_HOPS, is a loop and raises AliasLoop
+rather than redirecting forever.
+"""
+
+import logging
+from typing import Any, Dict, Optional
+
+logger = logging.getLogger(__name__)
+
+MAX_HOPS = 5
+
+
+class AliasLoop(Exception):
+    """A redirect chain that never reaches a profile or article"""
+
+
+def _local_username(url: str) -> Optional[str]:
+    """The username behind a profile URL on this instance, or None for anywhere else"""
+    from shared.activitypub import BASE_URL
+
+    prefix = f"{BASE_URL}/ap/users/"
+    if url and url.startswith(prefix) and '/' not in url[len(prefix):]:
+        return url[len(prefix):]
+    return None
+
+
+def resolve_user(cursor, username: str, follow_moves: bool = True) -> Optional[Dict[str, Any]]:
+    """Where a username leads: `{'user': ...}` with the current `username`, `{'moved_to': url}`, or None
+
+    Accounts in anonymous mode and deactivated accounts are never found.
+    With `follow_moves` off, a moved account resolves to itself, for
+    ActivityPub, where the actor announces its move with `movedTo`.
+    """
+    seen = []
+    name = username
+    while True:
+        if name in seen or len(seen) >= MAX_HOPS:
+            raise AliasLoop(' -> '.join(seen + [name]))
+        seen.append(name)
+
+        cursor.execute("""
+            SELECT * FROM users
+            WHERE username = %s AND is_active = true AND COALESCE(anonymous_mode, false) = false
+        """, (name,))
+        user = cursor.fetchone()
+        if user is None:
+            cursor.execute("""
+                SELECT u.username FROM url_aliases a
+                JOIN users u ON a.user_id = u.id
+                WHERE a.kind = 'user' AND a.alias = %s
+                  AND u.is_active = true AND COALESCE(u.anonymous_mode, false) = false
+            """, (name,))
+            alias = cursor.fetchone()
+            if not alias:
+                return None
+            name = alias['username']
+            continue
+
+        if follow_moves and user['moved_to']:
+            local = _local_username(user['moved_to'])
+            if local is None:
+                return {'moved_to': user['moved_to']}
+            name = local
+            continue
+        return {'user': dict(user), 'username': name}
+
+
+def resolve_article(cursor, slug: str) -> Optional[Dict[str, Any]]:
+    """Where a slug leads: `{'article': ...}` for a published article's current slug, `{'slug': current}`, or None"""
+    cursor.execute("SELECT * FROM articles WHERE slug = %s AND status = 'published'", (slug,))
+    article = cursor.fetchone()
+    if article:
+        return {'article': dict(article)}
+
+    cursor.execute("""
+        SELECT a.slug FROM url_aliases x
+        JOIN articles a ON x.article_id = a.id
+        WHERE x.kind = 'article' AND x.alias = %s AND a.status = 'published'
+    """, (slug,))
+    alias = cursor.fetchone()
+    if not alias:
+        return None
+    if alias['slug'] == slug:
+        raise AliasLoop(f"{slug} -> {slug}")
+    return {'slug': alias['slug']}
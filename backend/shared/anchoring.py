@@ -0,0 +1,260 @@
+"""
+Merkle-tree batch anchoring of published articles
+
+Every ANCHOR_INTERVAL_SECONDS a worker collects the hashes of articles
+published, or revised, since they were last anchored, builds a Merkle tree
+over them and sends only the root to the ArticleAnchor contract: one
+transaction per batch instead of one per article. Each article keeps its
+inclusion proof, so anyone can recompute its hash, fold the proof up to the
+root and check the root on-chain without trusting the platform.
+
+Batches move from pending to submitted when the transaction is sent and to
+anchored once it is mined. A batch whose transaction reverts, fails to send
+or is dropped is sent again on the next run; the contract is asked first so
+a root is never anchored twice.
+"""
+
+import os
+import logging
+from datetime import datetime, timedelta, timezone
+from typing import Any, Dict, List, Optional
+
+from shared import merkle
+from shared.database import get_postgres_cursor, get_redis
+
+logger = logging.getLogger(__name__)
+
+ANCHOR_ENABLED = os.getenv('ANCHOR_ENABLED', 'false').lower() == 'true'
+RPC_URL = os.getenv('ANCHOR_RPC_URL', 'http://localhost:8545')
+CONTRACT_ADDRESS = os.getenv('ANCHOR_CONTRACT_ADDRESS', '')
+PRIVATE_KEY = os.getenv('ANCHOR_PRIVATE_KEY', '')
+CHAIN_ID = int(os.getenv('ANCHOR_CHAIN_ID', 31337))
+MAX_BATCH_SIZE = int(os.getenv('ANCHOR_MAX_BATCH_SIZE', 5000))
+RESUBMIT_AFTER_SECONDS = int(os.getenv('ANCHOR_RESUBMIT_AFTER_SECONDS', 60 * 60))
+LOCK_SECONDS = 10 * 60
+
+PENDING = 'pending'
+SUBMITTED = 'submitted'
+ANCHORED = 'anchored'
+FAILED = 'failed'
+
+CONTRACT_ABI = [
+    {
+        'name': 'anchorRoot', 'type': 'function', 'stateMutability': 'nonpayable',
+        'inputs': [
+            {'name': 'root', 'type': 'bytes32'},
+            {'name': 'leafCount', 'type': 'uint32'},
+            {'name': 'batchId', 'type': 'string'},
+        ],
+        'outputs': [],
+    },
+    {
+        'name': 'anchors', 'type': 'function', 'stateMutability': 'view',
+        'inputs': [{'name': '', 'type': 'bytes32'}],
+        'outputs': [{'name': 'timestamp', 'type': 'uint64'}, {'name': 'leafCount', 'type': 'uint32'}],
+    },
+]
+
+
+def articles_to_anchor(cursor, limit: int = MAX_BATCH_SIZE) -> List[Dict[str, Any]]:
+    """Published articles never anchored, or revised since their last anchor"""
+    cursor.execute("""
+        SELECT a.id, a.title, a.summary, a.content
+        FROM articles a
+        LEFT JOIN LATERAL (
+            SELECT created_at FROM article_anchors WHERE article_id = a.id ORDER BY created_at DESC LIMIT 1
+        ) latest ON TRUE
+        WHERE a.status = 'published'
+          AND (latest.created_at IS NULL OR EXISTS (
+              SELECT 1 FROM article_revisions r WHERE r.article_id = a.id AND r.created_at > latest.created_at
+          ))
+        ORDER BY a.published_at
+        LIMIT %s
+    """, (limit,))
+    return [dict(row) for row in cursor.fetchall()]
+
+
+def build_batch(cursor) -> Optional[Dict[str, Any]]:
+    """Build a batch over the articles waiting to be anchored, storing each one's proof"""
+    articles = articles_to_anchor(cursor)
+    if not articles:
+        return None
+
+    hashes = [merkle.article_hash(article) for article in articles]
+    levels = merkle.build_tree(hashes)
+    root = merkle.root(levels)
+    cursor.execute("""
+        INSERT INTO anchor_batches (merkle_root, leaf_count, chain_id, contract_address)
+        VALUES (%s, %s, %s, %s)
+        ON CONFLICT (merkle_root) DO NOTHING
+        RETURNING *
+    """, (root, len(hashes), CHAIN_ID, CONTRACT_ADDRESS or None))
+    batch = cursor.fetchone()
+    if not batch:
+        # The same articles with the same content are already in a batch
+        return None
+
+    for index, (article, article_hash) in enumerate(zip(articles, hashes)):
+        cursor.execute("""
+            INSERT INTO article_anchors (article_id, batch_id, leaf_index, article_hash, proof)
+            VALUES (%s, %s, %s, %s, %s)
+        """, (article['id'], batch['id'], index, article_hash, merkle.proof(levels, index)))
+    logger.info(f"Built anchor batch {batch['id']} over {len(hashes)} articles, root {root}")
+    return dict(batch)
+
+
+def _contract():
+    from web3 import Web3
+
+    if not CONTRACT_ADDRESS or not PRIVATE_KEY:
+        raise RuntimeError("ANCHOR_CONTRACT_ADDRESS and ANCHOR_PRIVATE_KEY must be set")
+    web3 = Web3(Web3.HTTPProvider(RPC_URL, request_kwargs={'timeout': 30}))
+    contract = web3.eth.contract(address=Web3.to_checksum_address(CONTRACT_ADDRESS), abi=CONTRACT_ABI)
+    return web3, contract
+
+
+def health():
+    """Raises unless the contract is configured and the RPC node answers"""
+    web3, _ = _contract()
+    web3.eth.block_number
+
+
+def submit_batch(batch: Dict[str, Any]) -> Dict[str, Any]:
+    """Send the batch's root to the contract, unless it is already there"""
+    web3, contract = _contract()
+    root = bytes.fromhex(batch['merkle_root'])
+
+    timestamp, _ = contract.functions.anchors(root).call()
+    if timestamp:
+        return {'status': ANCHORED, 'anchored_at': datetime.fromtimestamp(timestamp, timezone.utc)}
+
+    account = web3.eth.account.from_key(PRIVATE_KEY)
+    transaction = contract.functions.anchorRoot(root, batch['leaf_count'], str(batch['id'])).build_transaction({
+        'from': account.address,
+        'chainId': CHAIN_ID,
+        'nonce': web3.eth.get_transaction_count(account.address, 'pending'),
+    })
+    signed = account.sign_transaction(transaction)
+    tx_hash = web3.eth.send_raw_transaction(signed.raw_transaction)
+    return {'status': SUBMITTED, 'tx_hash': web3.to_hex(tx_hash)}
+
+
+def confirm_batch(batch: Dict[str, Any]) -> Optional[Dict[str, Any]]:
+    """The batch's new state once its transaction is mined, or None while it is still waiting"""
+    from web3.exceptions import TransactionNotFound
+
+    web3, _ = _contract()
+    try:
+        receipt = web3.eth.get_transaction_receipt(batch['tx_hash'])
+    except TransactionNotFound:
+        return None
+    if receipt['status'] != 1:
+        return {'status': FAILED, 'error': f"Transaction {batch['tx_hash']} reverted"}
+    block = web3.eth.get_block(receipt['blockNumber'])
+    return {
+        'status': ANCHORED,
+        'block_number': receipt['blockNumber'],
+        'anchored_at': datetime.fromtimestamp(block['timestamp'], timezone.utc),
+    }
+
+
+def _update_batch(batch_id: str, changes: Dict[str, Any]):
+    columns = ', '.join(f"{column} = %s" for column in changes)
+    with get_postgres_cursor() as cursor:
+        cursor.execute(f"UPDATE anchor_batches SET {columns} WHERE id = %s", (*changes.values(), batch_id))
+
+
+def _batches(status: List[str]) -> List[Dict[str, Any]]:
+    with get_postgres_cursor() as cursor:
+        cursor.execute(
+            "SELECT * FROM anchor_batches WHERE status = ANY(%s) ORDER BY created_at", (status,)
+        )
+        return [dict(row) for row in cursor.fetchall()]
+
+
+def anchor_articles() -> Dict[str, int]:
+    """Confirm sent batches, batch articles waiting to be anchored and send every unanchored root"""
+    summary = {'built': 0, 'submitted': 0, 'anchored': 0, 'failed': 0}
+    if not ANCHOR_ENABLED:
+        return summary
+
+    # Only one run may send transactions at a time, or nonces would collide
+    lock_key = 'anchor_articles_lock'
+    if not get_redis().set(lock_key, 1, nx=True, ex=LOCK_SECONDS):
+        return summary
+    try:
+        stale_before = datetime.now(timezone.utc) - timedelta(seconds=RESUBMIT_AFTER_SECONDS)
+        for batch in _batches([SUBMITTED]):
+            try:
+                result = confirm_batch(batch)
+            except Exception as e:
+                logger.warning(f"Checking anchor batch {batch['id']} failed: {e}")
+                continue
+            if result is None and batch['submitted_at'] < stale_before:
+                result = {'status': FAILED, 'error': f"Transaction {batch['tx_hash']} was not mined in time"}
+            if result:
+                _update_batch(batch['id'], result)
+                summary[result['status']] += 1
+
+        with get_postgres_cursor() as cursor:
+            if build_batch(cursor):
+                summary['built'] += 1
+
+        for batch in _batches([PENDING, FAILED]):
+            try:
+                result = submit_batch(batch)
+                result.update({'submitted_at': datetime.now(timezone.utc), 'error': None})
+            except Exception as e:
+                logger.error(f"Submitting anchor batch {batch['id']} failed: {e}")
+                result = {'status': FAILED, 'error': str(e)[:1000]}
+            _update_batch(batch['id'], result)
+            summary[result['status']] += 1
+        return summary
+    finally:
+        get_redis().delete(lock_key)
+
+
+def inclusion_proof(cursor, article: Dict[str, Any], earliest: bool = False) -> Optional[Dict[str, Any]]:
+    """The article's latest inclusion proof with its batch, or None if it hasn't been batched yet
+
+    Anchored batches are preferred over newer ones still waiting for the chain.
+    `earliest` picks the first anchored proof instead, the one that shows
+    when the article was first published.
+    """
+    cursor.execute("""
+        SELECT aa.leaf_index, aa.article_hash, aa.proof, aa.created_at,
+               b.id AS batch_id, b.merkle_root, b.leaf_count, b.status, b.chain_id, b.contract_address,
+               b.tx_hash, b.block_number, b.anchored_at
+        FROM article_anchors aa
+        JOIN anchor_batches b ON aa.batch_id = b.id
+        WHERE aa.article_id = %s
+        ORDER BY (b.status = 'anchored') DESC,
+                 CASE WHEN %s THEN aa.created_at END ASC, aa.created_at DESC
+        LIMIT 1
+    """, (article['id'], earliest))
+    anchor = cursor.fetchone()
+    if not anchor:
+        return None
+
+    current_hash = merkle.article_hash(article)
+    return {
+        'article_id': str(article['id']),
+        'article_hash': anchor['article_hash'],
+        # False when the article changed after this proof was made; a newer batch will cover the change
+        'current': anchor['article_hash'] == current_hash,
+        'current_article_hash': current_hash,
+        'leaf_index': anchor['leaf_index'],
+        'leaf': merkle.leaf_hash(anchor['article_hash']).hex(),
+        'proof': anchor['proof'],
+        'merkle_root': anchor['merkle_root'],
+        'batch': {
+            'id': str(anchor['batch_id']),
+            'status': anchor['status'],
+            'leaf_count': anchor['leaf_count'],
+            'chain_id': anchor['chain_id'],
+            'contract_address': anchor['contract_address'],
+            'tx_hash': anchor['tx_hash'],
+            'block_number': anchor['block_number'],
+            'anchored_at': anchor['anchored_at'],
+        },
+    }
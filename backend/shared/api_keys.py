@@ -0,0 +1,163 @@
+"""
+Scoped API keys for service-to-service calls
+
+The ML service, crawlers and partners call service endpoints with an
+`X-API-Key` header instead of a user token. Each key carries the scopes it was
+issued for and only its hash is stored. Rotating a key issues a successor and
+lets the old key keep working for a grace period so callers can switch over
+without downtime. Last use is recorded at most once a minute per key.
+
+Crawler policies are set per key: a crawl delay is the minimum interval
+between the key's requests, and excluded sections are categories the key
+never receives.
+"""
+
+import hashlib
+import logging
+import secrets
+from datetime import datetime, timedelta
+from typing import Any, Dict, List, Optional, Sequence, Tuple
+
+from shared.database import get_redis
+
+logger = logging.getLogger(__name__)
+
+API_KEY_PREFIX = 'svc_'
+
+SCOPES = {
+    'read:articles': 'Read published articles, including full content, in bulk',
+    'write:analytics': 'Report analytics such as model training results',
+    'write:articles': 'Submit scraped or syndicated articles through the ingestion API',
+    'sandbox': 'Use the whole API against the sandbox tenant, sent as X-Sandbox-Key',
+}
+
+KEY_COLUMNS = """
+    id, name, description, key_prefix, scopes, is_active, expires_at, rotated_from,
+    last_used_at, last_used_ip, created_by, created_at, crawl_delay_seconds, excluded_sections
+"""
+
+
+def generate_api_key() -> Tuple[str, str, str]:
+    """Create a new service key, returning (key, sha256 hash, display prefix)"""
+    api_key = f"{API_KEY_PREFIX}{secrets.token_urlsafe(32)}"
+    return api_key, hash_api_key(api_key), api_key[:12]
+
+
+def hash_api_key(api_key: str) -> str:
+    return hashlib.sha256(api_key.encode()).hexdigest()
+
+
+def invalid_scopes(scopes: Sequence[str]) -> List[str]:
+    return sorted(set(scopes) - set(SCOPES))
+
+
+def create_api_key(cursor, name: str, scopes: Sequence[str], created_by: Optional[str],
+                   description: Optional[str] = None, expires_at: Optional[datetime] = None,
+                   rotated_from: Optional[str] = None, crawl_delay_seconds: Optional[float] = None,
+                   excluded_sections: Sequence[str] = ()) -> Dict[str, Any]:
+    """Issue a key; the plaintext is returned once under `api_key`"""
+    api_key, key_hash, key_prefix = generate_api_key()
+    cursor.execute(f"""
+        INSERT INTO api_keys (
+            name, description, key_hash, key_prefix, scopes, expires_at, rotated_from, created_by,
+            crawl_delay_seconds, excluded_sections
+        )
+        VALUES (%s, %s, %s, %s, %s, %s, %s, %s, %s, %s)
+        RETURNING {KEY_COLUMNS}
+    """, (
+        name, description, key_hash, key_prefix, sorted(set(scopes)), expires_at, rotated_from, created_by,
+        crawl_delay_seconds, list(excluded_sections)
+    ))
+    return {**dict(cursor.fetchone()), 'api_key': api_key}
+
+
+def authenticate_api_key(cursor, api_key: str, ip_address: Optional[str] = None) -> Optional[Dict[str, Any]]:
+    """Resolve an active, unexpired key and record its use"""
+    if not api_key or not api_key.startswith(API_KEY_PREFIX):
+        return None
+
+    cursor.execute(f"""
+        SELECT {KEY_COLUMNS} FROM api_keys
+        WHERE key_hash = %s AND is_active = true AND (expires_at IS NULL OR expires_at > NOW())
+    """, (hash_api_key(api_key),))
+    key = cursor.fetchone()
+    if not key:
+        return None
+
+    # Throttled so a busy service doesn't turn every request into a row update
+    cursor.execute("""
+        UPDATE api_keys SET last_used_at = NOW(), last_used_ip = %s
+        WHERE id = %s AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute')
+    """, (ip_address, key['id']))
+    return dict(key)
+
+
+def list_api_keys(cursor, include_inactive: bool = False) -> List[Dict[str, Any]]:
+    query = f"SELECT {KEY_COLUMNS} FROM api_keys"
+    if not include_inactive:
+        query += " WHERE is_active = true AND (expires_at IS NULL OR expires_at > NOW())"
+    cursor.execute(query + " ORDER BY created_at DESC")
+    return [dict(key) for key in cursor.fetchall()]
+
+
+def get_api_key(cursor, key_id: str) -> Optional[Dict[str, Any]]:
+    cursor.execute(f"SELECT {KEY_COLUMNS} FROM api_keys WHERE id = %s", (key_id,))
+    key = cursor.fetchone()
+    return dict(key) if key else None
+
+
+def rotate_api_key(cursor, key_id: str, grace_period_seconds: int, rotated_by: Optional[str]) -> Optional[Dict[str, Any]]:
+    """Issue a successor with the same name, scopes and crawler policy; the old key expires after the grace period"""
+    old = get_api_key(cursor, key_id)
+    if not old or not old['is_active']:
+        return None
+
+    grace_ends = datetime.now() + timedelta(seconds=grace_period_seconds)
+    cursor.execute("""
+        UPDATE api_keys SET expires_at = LEAST(COALESCE(expires_at, %s), %s), updated_at = NOW()
+        WHERE id = %s
+    """, (grace_ends, grace_ends, key_id))
+
+    logger.info(f"API key {old['key_prefix']} rotated by {rotated_by}; old key expires at {grace_ends.isoformat()}")
+    return create_api_key(
+        cursor, old['name'], old['scopes'], rotated_by, old['description'], rotated_from=str(old['id']),
+        crawl_delay_seconds=old['crawl_delay_seconds'], excluded_sections=old['excluded_sections']
+    )
+
+
+def set_crawler_policy(cursor, key_id: str, crawl_delay_seconds: Optional[float],
+                       excluded_sections: Sequence[str]) -> Optional[Dict[str, Any]]:
+    """Replace a key's crawler policy; takes effect on its next request"""
+    cursor.execute(f"""
+        UPDATE api_keys SET crawl_delay_seconds = %s, excluded_sections = %s, updated_at = NOW()
+        WHERE id = %s
+        RETURNING {KEY_COLUMNS}
+    """, (crawl_delay_seconds, list(excluded_sections), key_id))
+    key = cursor.fetchone()
+    return dict(key) if key else None
+
+
+def crawl_delay_remaining(key: Dict[str, Any]) -> float:
+    """Seconds until the key may make another request, claiming the slot if it may; fails open if Redis is down"""
+    delay = key.get('crawl_delay_seconds')
+    if not delay:
+        return 0.0
+
+    slot = f"crawl:{key['id']}"
+    try:
+        redis_client = get_redis()
+        if redis_client.set(slot, 1, nx=True, px=int(float(delay) * 1000)):
+            return 0.0
+        remaining_ms = redis_client.pttl(slot)
+    except Exception as e:
+        logger.warning(f"Crawl delay check skipped: {e}")
+        return 0.0
+    return max(remaining_ms, 0) / 1000
+
+
+def revoke_api_key(cursor, key_id: str) -> bool:
+    cursor.execute(
+        "UPDATE api_keys SET is_active = false, updated_at = NOW() WHERE id = %s AND is_active = true RETURNING id",
+        (key_id,)
+    )
+    return cursor.fetchone() is not None
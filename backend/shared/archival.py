@@ -0,0 +1,363 @@
+"""
+Long-term archival of published articles to permanent storage
+
+Snapshots (shared/storage.py) keep a copy of each published version where
+the node stores files, usually a pinned IPFS node the operator runs. Archives
+go further: a bundle of the article - its source and rendered HTML, metadata,
+hash and on-chain anchor - is committed to a storage network that keeps it
+after this node is gone. Providers are listed in ARCHIVE_PROVIDERS:
+
+- `arweave`: a permanent transaction paid once by the wallet at
+  ARWEAVE_WALLET_PATH; the cost is the network's price for the bundle size,
+  in AR
+- `filecoin`: uploaded through Lighthouse (FILECOIN_LIGHTHOUSE_API_KEY),
+  which makes Filecoin storage deals for it; Lighthouse bills by plan, so
+  the cost is estimated from FILECOIN_PRICE_PER_GIB when that is set
+
+Every ARCHIVE_INTERVAL_SECONDS a worker archives articles published or
+revised since their last archive (unless ARCHIVE_AUTOMATIC is off) and
+checks on earlier ones; authors can also archive an article on demand.
+Archives go from `pending` to `submitted` once the provider has the bundle
+and to `confirmed` once it is mined into a block or in a storage deal. A
+submission that fails is retried on the next run, up to ARCHIVE_MAX_ATTEMPTS
+times. Premium articles are never archived: archives are public forever.
+"""
+
+import os
+import json
+import hashlib
+import logging
+from datetime import datetime, timezone
+from decimal import Decimal
+from typing import Any, Dict, List, Optional, Protocol
+
+import requests
+
+from shared.database import get_postgres_cursor, get_redis
+
+logger = logging.getLogger(__name__)
+
+ARCHIVE_ENABLED = os.getenv('ARCHIVE_ENABLED', 'false').lower() == 'true'
+ARCHIVE_AUTOMATIC = os.getenv('ARCHIVE_AUTOMATIC', 'true').lower() == 'true'
+PROVIDER_NAMES = [name.strip() for name in os.getenv('ARCHIVE_PROVIDERS', 'arweave').split(',') if name.strip()]
+BATCH_SIZE = int(os.getenv('ARCHIVE_BATCH_SIZE', 50))
+MAX_ATTEMPTS = int(os.getenv('ARCHIVE_MAX_ATTEMPTS', 5))
+REQUEST_TIMEOUT_SECONDS = int(os.getenv('STORAGE_REQUEST_TIMEOUT_SECONDS', 60))
+LOCK_SECONDS = 30 * 60
+BUNDLE_VERSION = 1
+
+PENDING = 'pending'
+SUBMITTED = 'submitted'
+CONFIRMED = 'confirmed'
+FAILED = 'failed'
+
+
+class ArchiveUnavailable(Exception):
+    """Raised when archival is off, a provider isn't configured, or an article can't be archived"""
+
+
+# Interface
+class ArchiveProvider(Protocol):
+    name: str
+
+    def submit(self, key: str, data: bytes) -> Dict[str, Any]:
+        """Commit the bundle and return its `archive_id`, `url`, `cost` and `currency`"""
+        ...
+
+    def confirmed(self, archive_id: str) -> bool:
+        """Whether the network has durably taken the bundle"""
+        ...
+
+    def health(self) -> None: ...
+
+
+# Implementations
+class ArweaveArchive:
+    name = 'arweave'
+    WINSTON_PER_AR = Decimal(10) ** 12
+
+    def __init__(self):
+        from shared.storage import get_driver
+
+        self.driver = get_driver('arweave')
+        self.gateway_url = self.driver.gateway_url
+        self.min_confirmations = int(os.getenv('ARWEAVE_MIN_CONFIRMATIONS', 10))
+
+    def submit(self, key: str, data: bytes) -> Dict[str, Any]:
+        price = requests.get(f"{self.gateway_url}/price/{len(data)}", timeout=REQUEST_TIMEOUT_SECONDS)
+        price.raise_for_status()
+        stored = self.driver.put(key, data, 'application/json')
+        return {
+            'archive_id': stored['ref'],
+            'url': stored['url'],
+            'cost': Decimal(price.text.strip()) / self.WINSTON_PER_AR,
+            'currency': 'AR',
+        }
+
+    def confirmed(self, archive_id: str) -> bool:
+        response = requests.get(f"{self.gateway_url}/tx/{archive_id}/status", timeout=REQUEST_TIMEOUT_SECONDS)
+        if response.status_code in (202, 404):
+            # Pending, or not yet propagated to this gateway
+            return False
+        response.raise_for_status()
+        return response.json().get('number_of_confirmations', 0) >= self.min_confirmations
+
+    def health(self) -> None:
+        self.driver.health()
+
+
+class FilecoinArchive:
+    name = 'filecoin'
+
+    def __init__(self):
+        self.api_key = os.getenv('FILECOIN_LIGHTHOUSE_API_KEY', '')
+        self.upload_url = os.getenv('FILECOIN_LIGHTHOUSE_UPLOAD_URL', 'https://node.lighthouse.storage').rstrip('/')
+        self.api_url = os.getenv('FILECOIN_LIGHTHOUSE_API_URL', 'https://api.lighthouse.storage').rstrip('/')
+        self.gateway_url = os.getenv('FILECOIN_GATEWAY_URL', 'https://gateway.lighthouse.storage/ipfs').rstrip('/')
+        price = os.getenv('FILECOIN_PRICE_PER_GIB', '')
+        self.price_per_gib = Decimal(price) if price else None
+        self.currency = os.getenv('FILECOIN_PRICE_CURRENCY', 'USD')
+
+    def _headers(self) -> Dict[str, str]:
+        if not self.api_key:
+            raise ArchiveUnavailable("FILECOIN_LIGHTHOUSE_API_KEY must be set")
+        return {'Authorization': f"Bearer {self.api_key}"}
+
+    def submit(self, key: str, data: bytes) -> Dict[str, Any]:
+        response = requests.post(
+            f"{self.upload_url}/api/v0/add", headers=self._headers(),
+            files={'file': (os.path.basename(key), data, 'application/json')},
+            timeout=REQUEST_TIMEOUT_SECONDS
+        )
+        response.raise_for_status()
+        cid = response.json()['Hash']
+        cost = self.price_per_gib * len(data) / (1024 ** 3) if self.price_per_gib is not None else None
+        return {
+            'archive_id': cid,
+            'url': f"{self.gateway_url}/{cid}",
+            'cost': cost,
+            'currency': self.currency if cost is not None else None,
+        }
+
+    def confirmed(self, archive_id: str) -> bool:
+        response = requests.get(
+            f"{self.api_url}/api/lighthouse/deal_status", params={'cid': archive_id},
+            headers=self._headers(), timeout=REQUEST_TIMEOUT_SECONDS
+        )
+        response.raise_for_status()
+        deals = response.json() or []
+        return any(deal.get('chainDealID') or deal.get('dealId') for deal in deals)
+
+    def health(self) -> None:
+        self._headers()
+        requests.get(self.api_url, timeout=5)
+
+
+PROVIDERS = {
+    'arweave': ArweaveArchive,
+    'filecoin': FilecoinArchive,
+}
+
+_providers: Dict[str, ArchiveProvider] = {}
+
+
+def provider(name: str) -> ArchiveProvider:
+    if name not in PROVIDER_NAMES or name not in PROVIDERS:
+        raise ArchiveUnavailable(f"Archival provider {name} is not configured")
+    if name not in _providers:
+        _providers[name] = PROVIDERS[name]()
+    return _providers[name]
+
+
+def health_checks() -> Dict[str, Any]:
+    return {f"archive:{name}": provider(name).health for name in PROVIDER_NAMES if name in PROVIDERS}
+
+
+def bundle(cursor, article_id: str) -> Optional[Dict[str, Any]]:
+    """What is archived for a published article, or None if it is unpublished or premium"""
+    from shared import merkle
+    from shared.anchoring import inclusion_proof
+    from shared.content import HTML, render
+    from shared.feed_formats import PUBLIC_BASE_URL, article_url
+
+    cursor.execute("""
+        SELECT a.id, a.title, a.summary, a.content, a.content_format, a.category, a.tags, a.language,
+               a.license, a.published_at, a.updated_at, a.anonymous_author, u.username
+        FROM articles a
+        JOIN users u ON a.author_id = u.id
+        WHERE a.id = %s AND a.status = 'published' AND a.access_policy IS NULL
+    """, (article_id,))
+    article = cursor.fetchone()
+    if not article:
+        return None
+
+    article = dict(article)
+    username = article.pop('username')
+    author = None if article.pop('anonymous_author') else username
+    proof = inclusion_proof(cursor, article)
+    anchored = proof and proof['batch']['status'] == 'anchored'
+    return {
+        'type': 'article-archive',
+        'version': BUNDLE_VERSION,
+        'source': PUBLIC_BASE_URL,
+        'url': article_url(article),
+        'article': {
+            **article,
+            'id': str(article['id']),
+            'content_html': render(article['content'], article.get('content_format') or HTML),
+        },
+        'author': author,
+        'article_hash': merkle.article_hash(article),
+        'anchor': {
+            'chain_id': proof['batch']['chain_id'],
+            'contract_address': proof['batch']['contract_address'],
+            'tx_hash': proof['batch']['tx_hash'],
+            'merkle_root': proof['merkle_root'],
+            'article_hash': proof['article_hash'],
+        } if anchored else None,
+    }
+
+
+def request_archive(cursor, article_id: str, provider_name: str,
+                    requested_by: Optional[str] = None) -> Dict[str, Any]:
+    """Queue the article for archival with a provider, or return the archive already waiting"""
+    cursor.execute("""
+        SELECT * FROM article_archives
+        WHERE article_id = %s AND provider = %s AND status IN ('pending', 'failed')
+        ORDER BY created_at DESC LIMIT 1
+    """, (article_id, provider_name))
+    waiting = cursor.fetchone()
+    if waiting:
+        return dict(waiting)
+    cursor.execute("""
+        INSERT INTO article_archives (article_id, provider, requested_by)
+        VALUES (%s, %s, %s)
+        RETURNING *
+    """, (article_id, provider_name, requested_by))
+    return dict(cursor.fetchone())
+
+
+def articles_due(cursor, provider_name: str, limit: int = BATCH_SIZE) -> List[str]:
+    """Published free articles never archived with the provider, or revised since they last were"""
+    cursor.execute("""
+        SELECT a.id
+        FROM articles a
+        LEFT JOIN LATERAL (
+            SELECT created_at FROM article_archives
+            WHERE article_id = a.id AND provider = %s
+            ORDER BY created_at DESC LIMIT 1
+        ) latest ON TRUE
+        WHERE a.status = 'published' AND a.access_policy IS NULL
+          AND (latest.created_at IS NULL OR EXISTS (
+              SELECT 1 FROM article_revisions r WHERE r.article_id = a.id AND r.created_at > latest.created_at
+          ))
+        ORDER BY a.published_at
+        LIMIT %s
+    """, (provider_name, limit))
+    return [str(row['id']) for row in cursor.fetchall()]
+
+
+def _update(archive_id: str, changes: Dict[str, Any]):
+    columns = ', '.join(f"{column} = %s" for column in changes)
+    with get_postgres_cursor() as cursor:
+        cursor.execute(
+            f"UPDATE article_archives SET {columns}, updated_at = NOW() WHERE id = %s",
+            (*changes.values(), archive_id)
+        )
+
+
+def submit(archive: Dict[str, Any]) -> Dict[str, Any]:
+    """Build the article's bundle and commit it to the archive's provider"""
+    lock_key = f"archive_lock:{archive['id']}"
+    if not get_redis().set(lock_key, 1, nx=True, ex=LOCK_SECONDS):
+        return archive
+    try:
+        with get_postgres_cursor() as cursor:
+            document = bundle(cursor, archive['article_id'])
+        if document is None:
+            changes = {'status': FAILED, 'attempts': MAX_ATTEMPTS,
+                       'error': "The article is no longer published or is premium"}
+        else:
+            data = json.dumps(document, sort_keys=True, default=str).encode()
+            digest = hashlib.sha256(data).hexdigest()
+            try:
+                key = f"articles/{archive['article_id']}/{digest[:32]}.json"
+                stored = provider(archive['provider']).submit(key, data)
+                changes = {
+                    'status': SUBMITTED, 'archive_id': stored['archive_id'], 'url': stored['url'],
+                    'cost': stored['cost'], 'currency': stored['currency'], 'content_sha256': digest,
+                    'size': len(data), 'submitted_at': datetime.now(timezone.utc), 'error': None,
+                    'attempts': archive['attempts'] + 1,
+                }
+            except Exception as e:
+                logger.error(f"Archiving article {archive['article_id']} with {archive['provider']} failed: {e}")
+                changes = {'status': FAILED, 'error': str(e)[:1000], 'attempts': archive['attempts'] + 1}
+        _update(archive['id'], changes)
+        return {**archive, **changes}
+    finally:
+        get_redis().delete(lock_key)
+
+
+def _archives(status: List[str]) -> List[Dict[str, Any]]:
+    with get_postgres_cursor() as cursor:
+        cursor.execute("""
+            SELECT * FROM article_archives
+            WHERE status = ANY(%s) AND (status != 'failed' OR attempts < %s)
+            ORDER BY created_at
+            LIMIT %s
+        """, (status, MAX_ATTEMPTS, BATCH_SIZE))
+        return [dict(row) for row in cursor.fetchall()]
+
+
+def archive_article(archive_id: str) -> Optional[Dict[str, Any]]:
+    """Submit one queued archive now, for on-demand requests"""
+    with get_postgres_cursor() as cursor:
+        cursor.execute("SELECT * FROM article_archives WHERE id = %s AND status IN ('pending', 'failed')",
+                       (archive_id,))
+        archive = cursor.fetchone()
+    return submit(dict(archive)) if archive else None
+
+
+def archive_articles() -> Dict[str, int]:
+    """Confirm submitted archives, queue articles due for archival and submit everything queued"""
+    summary = {'queued': 0, 'submitted': 0, 'confirmed': 0, 'failed': 0}
+    if not ARCHIVE_ENABLED:
+        return summary
+
+    lock_key = 'archive_articles_lock'
+    if not get_redis().set(lock_key, 1, nx=True, ex=LOCK_SECONDS):
+        return summary
+    try:
+        for archive in _archives([SUBMITTED]):
+            try:
+                if provider(archive['provider']).confirmed(archive['archive_id']):
+                    _update(archive['id'], {'status': CONFIRMED, 'confirmed_at': datetime.now(timezone.utc)})
+                    summary['confirmed'] += 1
+            except Exception as e:
+                logger.warning(f"Checking archive {archive['id']} failed: {e}")
+
+        if ARCHIVE_AUTOMATIC:
+            with get_postgres_cursor() as cursor:
+                for name in PROVIDER_NAMES:
+                    for article_id in articles_due(cursor, name):
+                        request_archive(cursor, article_id, name)
+                        summary['queued'] += 1
+
+        for archive in _archives([PENDING, FAILED]):
+            result = submit(archive)
+            if result['status'] in summary:
+                summary[result['status']] += 1
+        return summary
+    finally:
+        get_redis().delete(lock_key)
+
+
+def archives_for(cursor, article_id: str) -> List[Dict[str, Any]]:
+    """The article's archives, newest first"""
+    cursor.execute("""
+        SELECT id, provider, status, archive_id, url, cost, currency, content_sha256, size,
+               submitted_at, confirmed_at, created_at
+        FROM article_archives WHERE article_id = %s
+        ORDER BY created_at DESC
+    """, (article_id,))
+    return [dict(row) for row in cursor.fetchall()]
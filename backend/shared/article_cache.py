@@ -0,0 +1,89 @@
+"""
+Rendered article cache
+
+Published articles are kept in Redis as the JSON the article endpoint
+returns, for ARTICLE_CACHE_TTL_SECONDS or until an edit drops them. Entries
+hold the full content; premium articles are gated for each viewer on the
+way out. Articles scheduled for publishing are rendered ahead of time under
+a pending key that only becomes the live entry once the article goes live,
+so embargoed text is never served early. Entries are kept per publication,
+since they are served before the article is read from PostgreSQL.
+"""
+
+import os
+import json
+import logging
+from typing import Any, Dict, Optional
+
+from shared import tenancy
+from shared.database import get_postgres_cursor, get_redis
+from shared.models import ArticleResponse
+from shared.paywall import unrestricted
+
+logger = logging.getLogger(__name__)
+
+TTL_SECONDS = int(os.getenv('ARTICLE_CACHE_TTL_SECONDS', 60))
+
+
+def _key(article_id: str) -> str:
+    return f"article_json:{tenancy.cache_namespace()}:{article_id}"
+
+
+def _pending_key(article_id: str) -> str:
+    return f"article_json_pending:{tenancy.cache_namespace()}:{article_id}"
+
+
+def render(article: Dict[str, Any]) -> Dict[str, Any]:
+    """The full article response, whoever is asking; gate it per viewer before serving
+
+    Credited authors are looked up unless the article already carries them in `authors`.
+    """
+    if 'authors' not in article:
+        from shared.coauthors import attach
+
+        with get_postgres_cursor() as cursor:
+            article = attach(cursor, [article])[0]
+    with unrestricted():
+        return ArticleResponse(**article).model_dump(mode='json')
+
+
+def get(article_id: str) -> Optional[Dict[str, Any]]:
+    try:
+        cached = get_redis().get(_key(article_id))
+        return json.loads(cached) if cached else None
+    except Exception as e:
+        logger.warning(f"Article cache read failed for {article_id}: {e}")
+        return None
+
+
+def put(article_id: str, rendered: Dict[str, Any]):
+    try:
+        get_redis().setex(_key(article_id), TTL_SECONDS, json.dumps(rendered))
+    except Exception as e:
+        logger.warning(f"Article cache write failed for {article_id}: {e}")
+
+
+def invalidate(article_id: str):
+    try:
+        get_redis().delete(_key(article_id), _pending_key(article_id))
+    except Exception as e:
+        logger.warning(f"Article cache invalidation failed for {article_id}: {e}")
+
+
+def stage(article: Dict[str, Any], ttl_seconds: int):
+    """Render an article as it will look once published, without serving it yet"""
+    get_redis().setex(_pending_key(str(article['id'])), ttl_seconds, json.dumps(render(article)))
+
+
+def promote(article_id: str) -> bool:
+    """Make the staged rendering the live entry, returning False if nothing was staged"""
+    redis_client = get_redis()
+    pipeline = redis_client.pipeline()
+    pipeline.rename(_pending_key(article_id), _key(article_id))
+    pipeline.expire(_key(article_id), TTL_SECONDS)
+    try:
+        pipeline.execute()
+        return True
+    except Exception:
+        # RENAME fails when the pending key expired or was dropped by an edit
+        return False
@@ -0,0 +1,139 @@
+"""
+Live article stream for server-sent events
+
+Newly published articles and changes to the trending list are appended to
+a capped Redis stream (ARTICLE_STREAM_MAX_LENGTH entries). Every
+`GET /api/v1/stream/articles` connection reads it from its own position.
+Stream entry ids double as SSE event ids, so a client reconnecting with
+`Last-Event-ID` picks up where it left off, as long as the entry hasn't been
+trimmed yet.
+
+    article   - an article went live (appended by a publish hook)
+    trending  - the top ARTICLE_STREAM_TRENDING_SIZE trending articles changed
+                (checked after each trending recomputation)
+
+Every entry is tagged with the publication it belongs to, and subscribers
+only receive their own publication's events. The trending list is ranked
+and announced per publication.
+"""
+
+import os
+import re
+import json
+import logging
+from collections import defaultdict
+from typing import Any, Dict, List, Optional, Tuple
+
+import redis.asyncio as aioredis
+
+from shared.database import db_manager, get_redis
+from shared.tenancy import DEFAULT
+
+logger = logging.getLogger(__name__)
+
+STREAM_KEY = 'article_stream'
+TRENDING_KEY = 'article_stream:trending'
+ARTICLE = 'article'
+TRENDING = 'trending'
+
+MAX_LENGTH = int(os.getenv('ARTICLE_STREAM_MAX_LENGTH', 10000))
+TRENDING_SIZE = int(os.getenv('ARTICLE_STREAM_TRENDING_SIZE', 20))
+TRENDING_WINDOW_DAYS = 7
+
+EVENT_ID = re.compile(r'^\d+-\d+$')
+
+
+def append(event: str, data: Dict[str, Any], tenant_id: Optional[str]):
+    """Add an event for the publication `tenant_id` (None for the default one)"""
+    get_redis().xadd(
+        STREAM_KEY, {'event': event, 'tenant': str(tenant_id) if tenant_id else DEFAULT,
+                     'data': json.dumps(data, default=str)},
+        maxlen=MAX_LENGTH, approximate=True
+    )
+
+
+def article_published(cursor, article: Dict[str, Any]):
+    """Publish hook: announce the article to live streams"""
+    append(ARTICLE, {
+        'id': str(article['id']),
+        'title': article.get('title'),
+        'summary': article.get('summary'),
+        'category': article.get('category'),
+        'tags': list(article.get('tags') or []),
+        'language': article.get('language'),
+        'author_id': None if article.get('anonymous_author') else str(article.get('author_id')),
+        'published_at': article.get('published_at'),
+    }, article.get('tenant_id'))
+
+
+def announce_trending(cursor) -> bool:
+    """Append a trending event for each publication whose top trending articles differ from the last ones announced"""
+    cursor.execute("""
+        SELECT tenant_id, id, title, category, trending_score, rank FROM (
+            SELECT tenant_id, id, title, category, trending_score,
+                   ROW_NUMBER() OVER (PARTITION BY tenant_id ORDER BY trending_score DESC NULLS LAST, id DESC) AS rank
+            FROM articles
+            WHERE status = 'published' AND published_at >= NOW() - make_interval(days => %s)
+        ) ranked
+        WHERE rank <= %s
+        ORDER BY rank
+    """, (TRENDING_WINDOW_DAYS, TRENDING_SIZE))
+    by_tenant = defaultdict(list)
+    for row in cursor.fetchall():
+        by_tenant[str(row['tenant_id']) if row['tenant_id'] else None].append({
+            'rank': row['rank'], 'id': str(row['id']), 'title': row['title'], 'category': row['category'],
+            'trending_score': float(row['trending_score'] or 0),
+        })
+
+    announced = False
+    redis_client = get_redis()
+    for tenant_id, items in by_tenant.items():
+        ranking = json.dumps([item['id'] for item in items])
+        if redis_client.getset(f"{TRENDING_KEY}:{tenant_id or DEFAULT}", ranking) == ranking:
+            continue
+        append(TRENDING, {'items': items}, tenant_id)
+        announced = True
+    return announced
+
+
+def matches(event: str, data: Dict[str, Any], tenant: Optional[str], subscriber_tenant: Optional[str],
+            categories: Optional[List[str]]) -> Optional[Dict[str, Any]]:
+    """The event data as a subscriber of `subscriber_tenant` filtering on `categories` sees it, or None to skip it
+
+    Events of other publications, and untagged ones, are always skipped.
+    """
+    if not tenant or tenant != (subscriber_tenant or DEFAULT):
+        return None
+    if not categories:
+        return data
+    if event == TRENDING:
+        items = [item for item in data['items'] if (item.get('category') or '').lower() in categories]
+        return {'items': items} if items else None
+    return data if (data.get('category') or '').lower() in categories else None
+
+
+def stream_client() -> aioredis.Redis:
+    """Async Redis client for one subscriber; it blocks on XREAD, so it has no socket timeout"""
+    config = {k: v for k, v in db_manager.redis_config.items() if v is not None}
+    config['socket_timeout'] = None
+    return aioredis.Redis(**config)
+
+
+async def latest_id(client: aioredis.Redis) -> str:
+    entries = await client.xrevrange(STREAM_KEY, count=1)
+    return entries[0][0] if entries else '0-0'
+
+
+async def read(client: aioredis.Redis, after: str,
+               block_ms: int) -> Tuple[str, List[Tuple[str, str, Optional[str], Dict[str, Any]]]]:
+    """The position read up to and (id, event, tenant, data) for entries after `after`, waiting up to `block_ms`"""
+    response = await client.xread({STREAM_KEY: after}, count=100, block=block_ms)
+    entries = []
+    for _, messages in response or []:
+        for entry_id, fields in messages:
+            after = entry_id
+            try:
+                entries.append((entry_id, fields['event'], fields.get('tenant'), json.loads(fields['data'])))
+            except (KeyError, ValueError) as e:
+                logger.warning(f"Skipping malformed article stream entry {entry_id}: {e}")
+    return after, entries
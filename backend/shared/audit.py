@@ -0,0 +1,320 @@
+"""
+Audit log and account security events
+
+Security-relevant changes to an account are written to `audit_logs` with
+the IP address and user agent of the request that made them: sign-ins and
+failed sign-in attempts, email changes, signed-out sessions, linked
+accounts and wallets, signing keys, API keys, moves between instances
+and deletion requests. Users read their own through
+GET /api/v1/users/me/security-events to spot activity they don't recognise.
+
+Details are stored through `pii.redact`, so passwords, tokens and other
+people's addresses never end up in the log. Events are recorded on their
+own transaction and a failure to record one is logged, not raised, so
+auditing never breaks the request it describes.
+
+What administrators and auditors do goes to a separate, append-only
+`admin_audit_log`, read through GET /api/v1/admin/audit. Handlers record
+role changes, deletions, shadow bans and configuration changes with
+`record_privileged`, with snapshots of the resource before and after, on the
+transaction that makes the change: if the entry can't be written, the change
+doesn't happen. Any other change a privileged user makes is recorded by
+PrivilegedAuditMiddleware with its method and path. Each entry carries the
+hash of the one before it, so an entry altered or removed behind the
+database's back breaks the chain, which `verify_chain` reports. The table
+refuses updates and deletes, and purging an account leaves it alone.
+"""
+
+import json
+import hashlib
+import ipaddress
+import logging
+from contextvars import ContextVar
+from datetime import datetime, timezone
+from typing import Any, Dict, List, Optional, Tuple
+
+from shared.database import get_postgres_cursor, prepare_json_data
+from shared.pii import redact
+from shared.sessions import describe_device
+
+logger = logging.getLogger(__name__)
+
+# Security event actions and how they read to the account owner
+SECURITY_EVENTS = {
+    'login': "Signed in",
+    'login_failed': "Failed sign-in attempt",
+    'password_changed': "Password changed",
+    'email_changed': "Email address changed",
+    'two_factor_enabled': "Two-factor authentication turned on",
+    'two_factor_disabled': "Two-factor authentication turned off",
+    'session_revoked': "Signed out a session",
+    'sessions_revoked': "Signed out all other sessions",
+    'identity_unlinked': "Unlinked a social login",
+    'signing_key_added': "Added an article signing key",
+    'signing_key_revoked': "Revoked an article signing key",
+    'wallet_linked': "Linked a wallet",
+    'wallet_primary_changed': "Changed the primary wallet",
+    'wallet_unlinked': "Unlinked a wallet",
+    'api_key_created': "Issued an API key",
+    'api_key_rotated': "Rotated an API key",
+    'account_deletion_requested': "Requested account deletion",
+    'account_migration_exported': "Exported the account to move it to another instance",
+    'account_migration_imported': "Imported an account from another instance",
+    'account_moved': "Moved the account to another instance",
+}
+
+
+def client_of(request) -> Tuple[Optional[str], Optional[str]]:
+    """IP address and user agent of a Starlette request"""
+    return request.client.host if request.client else None, request.headers.get('user-agent')
+
+
+def _ip(address: Optional[str]) -> Optional[str]:
+    try:
+        return str(ipaddress.ip_address(address)) if address else None
+    except ValueError:
+        return None
+
+
+def record(cursor, user_id: str, action: str, resource_type: str = 'user', resource_id: Optional[str] = None,
+           details: Optional[Dict[str, Any]] = None, ip_address: Optional[str] = None,
+           user_agent: Optional[str] = None):
+    """Write an audit log entry with the caller's cursor"""
+    cursor.execute("""
+        INSERT INTO audit_logs (user_id, action, resource_type, resource_id, new_values, ip_address, user_agent)
+        VALUES (%s, %s, %s, %s, %s, %s, %s)
+    """, (
+        user_id, action, resource_type, resource_id,
+        prepare_json_data(redact(details)) if details else None,
+        _ip(ip_address), (user_agent or '')[:500] or None,
+    ))
+
+
+def record_security_event(user_id: str, action: str, ip_address: Optional[str] = None,
+                          user_agent: Optional[str] = None, details: Optional[Dict[str, Any]] = None,
+                          resource_type: str = 'user', resource_id: Optional[str] = None):
+    """Record a security event for `user_id` on its own transaction; failures are logged, not raised"""
+    try:
+        with get_postgres_cursor() as cursor:
+            record(cursor, str(user_id), action, resource_type, resource_id, details, ip_address, user_agent)
+    except Exception as e:
+        logger.warning(f"Could not record security event {action} for user {user_id}: {e}")
+
+
+def security_events(cursor, user_id: str, limit: int = 50, before: Optional[datetime] = None) -> List[Dict[str, Any]]:
+    """A user's security events, newest first"""
+    cursor.execute("""
+        SELECT id, action, resource_type, resource_id, new_values AS details,
+               host(ip_address) AS ip_address, user_agent, created_at
+        FROM audit_logs
+        WHERE user_id = %s AND action = ANY(%s) AND (%s::timestamptz IS NULL OR created_at < %s)
+        ORDER BY created_at DESC
+        LIMIT %s
+    """, (user_id, list(SECURITY_EVENTS), before, before, limit))
+    events = []
+    for row in cursor.fetchall():
+        event = dict(row)
+        event['description'] = SECURITY_EVENTS[event['action']]
+        event['device'] = describe_device(event['user_agent']) if event['user_agent'] else None
+        events.append(event)
+    return events
+
+
+# Privileged actions
+PRIVILEGED_ROLES = ('administrator', 'auditor')
+MUTATING_METHODS = ('POST', 'PUT', 'PATCH', 'DELETE')
+GENESIS_HASH = '0' * 64
+
+# Fields covered by an entry's hash, in addition to the previous entry's hash
+CHAINED_FIELDS = (
+    'sequence', 'actor_id', 'actor_username', 'actor_role', 'action', 'resource_type', 'resource_id',
+    'before', 'after', 'ip_address', 'user_agent', 'tenant_id', 'created_at',
+)
+
+# The privileged user behind the current request and whether a handler recorded what they did
+_trail: ContextVar[Optional[Dict[str, Any]]] = ContextVar('audit_trail', default=None)
+
+
+def note_actor(user: Dict[str, Any]):
+    """Remember that the request is made by an administrator or auditor; called when a request is authenticated"""
+    trail = _trail.get()
+    if trail is not None and user.get('role') in PRIVILEGED_ROLES:
+        trail['actor'] = user
+
+
+def _snapshot(value: Any) -> Any:
+    """The JSON form of a snapshot as it will read back from the database, with sensitive fields masked"""
+    if value is None:
+        return None
+    return json.loads(json.dumps(redact(value), default=str))
+
+
+def _utc(moment: datetime) -> str:
+    return moment.astimezone(timezone.utc).isoformat()
+
+
+def entry_hash(entry: Dict[str, Any]) -> str:
+    chained = {field: entry.get(field) for field in CHAINED_FIELDS}
+    chained['created_at'] = _utc(entry['created_at'])
+    canonical = json.dumps(chained, sort_keys=True, separators=(',', ':'), default=str)
+    return hashlib.sha256((entry['previous_hash'] + canonical).encode()).hexdigest()
+
+
+def record_privileged(cursor, actor: Optional[Dict[str, Any]], action: str, resource_type: str,
+                      resource_id: Optional[Any] = None, before: Any = None, after: Any = None,
+                      ip_address: Optional[str] = None, user_agent: Optional[str] = None) -> Dict[str, Any]:
+    """Append an entry for a privileged action on the caller's transaction; `actor` is None for the system"""
+    from shared.tenancy import current_id
+
+    trail = _trail.get()
+    if trail is not None:
+        trail['recorded'] = True
+        ip_address = ip_address or trail.get('ip_address')
+        user_agent = user_agent or trail.get('user_agent')
+
+    # One writer at a time, until its transaction ends, so every entry follows the last committed one
+    cursor.execute("SELECT pg_advisory_xact_lock(hashtext('admin_audit_log'))")
+    cursor.execute("SELECT sequence, entry_hash FROM admin_audit_log ORDER BY sequence DESC LIMIT 1")
+    last = cursor.fetchone()
+
+    entry = {
+        'sequence': last['sequence'] + 1 if last else 1,
+        'previous_hash': last['entry_hash'] if last else GENESIS_HASH,
+        'actor_id': str(actor['id']) if actor else None,
+        'actor_username': actor.get('username') if actor else None,
+        'actor_role': actor.get('role') if actor else None,
+        'action': action,
+        'resource_type': resource_type,
+        'resource_id': str(resource_id) if resource_id is not None else None,
+        'before': _snapshot(before),
+        'after': _snapshot(after),
+        'ip_address': _ip(ip_address),
+        'user_agent': (user_agent or '')[:500] or None,
+        'tenant_id': current_id(),
+        'created_at': datetime.now(timezone.utc),
+    }
+    entry['entry_hash'] = entry_hash(entry)
+    cursor.execute("""
+        INSERT INTO admin_audit_log (
+            sequence, previous_hash, entry_hash, actor_id, actor_username, actor_role, action, resource_type,
+            resource_id, before, after, ip_address, user_agent, tenant_id, created_at
+        )
+        VALUES (%s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s)
+    """, (
+        entry['sequence'], entry['previous_hash'], entry['entry_hash'], entry['actor_id'], entry['actor_username'],
+        entry['actor_role'], action, resource_type, entry['resource_id'],
+        json.dumps(entry['before']) if entry['before'] is not None else None,
+        json.dumps(entry['after']) if entry['after'] is not None else None,
+        entry['ip_address'], entry['user_agent'], entry['tenant_id'], entry['created_at'],
+    ))
+    return entry
+
+
+def privileged_actions(cursor, actor_id: Optional[str] = None, action: Optional[str] = None,
+                       resource_type: Optional[str] = None, resource_id: Optional[str] = None,
+                       since: Optional[datetime] = None, until: Optional[datetime] = None,
+                       before_sequence: Optional[int] = None, limit: int = 50) -> List[Dict[str, Any]]:
+    """Entries matching the filters, newest first; a publication's administrators only see their publication's"""
+    from shared.tenancy import current_id
+
+    conditions, params = [], []
+    tenant_id = current_id()
+    if tenant_id:
+        conditions.append("tenant_id = %s")
+        params.append(tenant_id)
+    for column, value in (('actor_id', actor_id), ('action', action), ('resource_type', resource_type),
+                          ('resource_id', resource_id)):
+        if value:
+            conditions.append(f"{column} = %s")
+            params.append(value)
+    if since:
+        conditions.append("created_at >= %s")
+        params.append(since)
+    if until:
+        conditions.append("created_at < %s")
+        params.append(until)
+    if before_sequence:
+        conditions.append("sequence < %s")
+        params.append(before_sequence)
+
+    where = f"WHERE {' AND '.join(conditions)}" if conditions else ''
+    cursor.execute(f"SELECT * FROM admin_audit_log {where} ORDER BY sequence DESC LIMIT %s", params + [limit])
+    return [dict(row) for row in cursor.fetchall()]
+
+
+def verify_chain(cursor, after_sequence: int = 0, limit: int = 10000) -> Dict[str, Any]:
+    """Recompute the hashes of up to `limit` entries after `after_sequence`, stopping at the first that doesn't match"""
+    previous_hash = GENESIS_HASH
+    if after_sequence:
+        cursor.execute("SELECT entry_hash FROM admin_audit_log WHERE sequence = %s", (after_sequence,))
+        start = cursor.fetchone()
+        if not start:
+            return {'valid': False, 'checked': 0, 'broken_at': after_sequence, 'problem': "Starting entry is missing"}
+        previous_hash = start['entry_hash']
+
+    cursor.execute(
+        "SELECT * FROM admin_audit_log WHERE sequence > %s ORDER BY sequence LIMIT %s", (after_sequence, limit)
+    )
+    expected_sequence = after_sequence + 1
+    checked = 0
+    for row in cursor.fetchall():
+        entry = dict(row)
+        problem = None
+        if entry['sequence'] != expected_sequence:
+            problem = f"Entries {expected_sequence} to {entry['sequence'] - 1} are missing"
+        elif entry['previous_hash'] != previous_hash:
+            problem = "Doesn't follow the entry before it"
+        elif entry_hash(entry) != entry['entry_hash']:
+            problem = "Contents don't match the entry's hash"
+        if problem:
+            return {'valid': False, 'checked': checked, 'broken_at': expected_sequence, 'problem': problem}
+        previous_hash = entry['entry_hash']
+        expected_sequence += 1
+        checked += 1
+
+    return {
+        'valid': True, 'checked': checked, 'last_sequence': expected_sequence - 1,
+        'last_hash': previous_hash, 'broken_at': None, 'problem': None,
+    }
+
+
+class PrivilegedAuditMiddleware:
+    """ASGI middleware recording successful changes by administrators and auditors that their handler didn't record"""
+
+    def __init__(self, app):
+        self.app = app
+
+    async def __call__(self, scope, receive, send):
+        if scope['type'] != 'http' or scope['method'] not in MUTATING_METHODS:
+            await self.app(scope, receive, send)
+            return
+
+        headers = dict(scope.get('headers') or [])
+        client = scope.get('client')
+        trail = {
+            'actor': None, 'recorded': False, 'ip_address': client[0] if client else None,
+            'user_agent': headers.get(b'user-agent', b'').decode('latin-1') or None,
+        }
+        response = {}
+
+        async def capture_status(message):
+            if message['type'] == 'http.response.start':
+                response['status'] = message['status']
+            await send(message)
+
+        context_token = _trail.set(trail)
+        try:
+            await self.app(scope, receive, capture_status)
+        finally:
+            _trail.reset(context_token)
+
+        if trail['actor'] and not trail['recorded'] and response.get('status', 500) < 400:
+            try:
+                with get_postgres_cursor() as cursor:
+                    record_privileged(
+                        cursor, trail['actor'], f"http.{scope['method'].lower()}", 'request', None,
+                        after={'path': scope['path'], 'status': response['status']},
+                        ip_address=trail['ip_address'], user_agent=trail['user_agent'],
+                    )
+            except Exception as e:
+                logger.error(f"Could not record {scope['method']} {scope['path']} by {trail['actor']['id']}: {e}")
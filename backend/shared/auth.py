@@ -6,7 +6,7 @@ import os
 import jwt
 import bcrypt
 from datetime import datetime, timedelta
-from typing import Optional, Dict, Any
+from typing import Optional, Dict, Any, List
 from functools import wraps
 import uuid
 
@@ -18,6 +18,7 @@ class AuthManager:
         self.jwt_secret = os.getenv('JWT_SECRET_KEY', 'your-super-secret-jwt-key')
         self.jwt_algorithm = 'HS256'
         self.access_token_expires = int(os.getenv('JWT_ACCESS_TOKEN_EXPIRES', 60 * 60 * 24 * 365))
+        self.delegated_token_expires = int(os.getenv('OAUTH_ACCESS_TOKEN_EXPIRES', 60 * 60))
         self.bcrypt_rounds = int(os.getenv('BCRYPT_ROUNDS', 12))
     
     def hash_password(self, password: str) -> str:
@@ -25,12 +26,20 @@ class AuthManager:
         salt = bcrypt.gensalt(rounds=self.bcrypt_rounds)
         return bcrypt.hashpw(password.encode('utf-8'), salt).decode('utf-8')
     
-    def verify_password(self, password: str, hashed: str) -> bool:
-        """Verify password against hash"""
-        return bcrypt.checkpw(password.encode('utf-8'), hashed.encode('utf-8'))
+    def verify_password(self, password: str, hashed: Optional[str]) -> bool:
+        """Verify password against hash; accounts without a usable password never match"""
+        if not hashed:
+            return False
+        try:
+            return bcrypt.checkpw(password.encode('utf-8'), hashed.encode('utf-8'))
+        except ValueError:
+            return False
     
-    def create_access_token(self, user_data: Dict[str, Any]) -> str:
-        """Create JWT access token"""
+    def create_access_token(self, user_data: Dict[str, Any], session_id: Optional[str] = None) -> str:
+        """Create JWT access token
+        
+        Use shared.sessions.issue_session_token so the session is registered.
+        """
         payload = {
             'user_id': str(user_data['id']),
             'username': user_data['username'],
@@ -38,14 +47,49 @@ class AuthManager:
             'role': user_data['role'],
             'exp': datetime.now() + timedelta(seconds=self.access_token_expires),
             'iat': datetime.now(),
-            'jti': str(uuid.uuid4())  # JWT ID for token revocation
+            'jti': str(uuid.uuid4()),  # JWT ID for token revocation
+            'sid': session_id or str(uuid.uuid4())  # Session the token belongs to
+        }
+        return jwt.encode(self._with_tenant(payload), self.jwt_secret, algorithm=self.jwt_algorithm)
+    
+    def create_delegated_token(self, user_data: Dict[str, Any], client_id: str, scopes: List[str]) -> str:
+        """Create a scoped OAuth access token for a third-party client acting for the user"""
+        payload = {
+            'user_id': str(user_data['id']),
+            'username': user_data['username'],
+            'email': user_data['email'],
+            'role': user_data['role'],
+            'client_id': client_id,
+            'scope': ' '.join(sorted(scopes)),
+            'exp': datetime.now() + timedelta(seconds=self.delegated_token_expires),
+            'iat': datetime.now(),
+            'jti': str(uuid.uuid4())
         }
-        return jwt.encode(payload, self.jwt_secret, algorithm=self.jwt_algorithm)
+        return jwt.encode(self._with_tenant(payload), self.jwt_secret, algorithm=self.jwt_algorithm)
+
+    @staticmethod
+    def _with_tenant(payload: Dict[str, Any]) -> Dict[str, Any]:
+        """Tokens issued in the sandbox name it, so they are only accepted there"""
+        from shared.sandbox import tenant
+        if tenant():
+            payload['tenant'] = tenant()
+        return payload
+    
+    @staticmethod
+    def is_delegated(payload: Optional[Dict[str, Any]]) -> bool:
+        """Delegated OAuth tokens carry a scope; full-session tokens never do"""
+        return bool(payload and 'scope' in payload)
     
     def verify_token(self, token: str) -> Optional[Dict[str, Any]]:
         """Verify and decode JWT token"""
         try:
             payload = jwt.decode(token, self.jwt_secret, algorithms=[self.jwt_algorithm])
+            from shared.sandbox import tenant
+            if payload.get('tenant') != tenant():
+                return None
+            from shared.sessions import is_session_revoked
+            if is_session_revoked(payload.get('sid')):
+                return None
             return payload
         except jwt.ExpiredSignatureError:
             return None
@@ -63,11 +107,16 @@ class AuthManager:
         
         return parts[1]
     
-    def get_user_from_token(self, token: str) -> Optional[Dict[str, Any]]:
-        """Get user data from token"""
+    def get_user_from_token(self, token: str, allow_delegated: bool = False) -> Optional[Dict[str, Any]]:
+        """Get user data from token
+        
+        Delegated OAuth tokens are rejected unless the caller checks their scopes.
+        """
         payload = self.verify_token(token)
         if not payload:
             return None
+        if self.is_delegated(payload) and not allow_delegated:
+            return None
         
         return {
             'id': payload.get('user_id'),
@@ -85,11 +134,11 @@ auth_manager = AuthManager()
 def hash_password(password: str) -> str:
     return auth_manager.hash_password(password)
 
-def verify_password(password: str, hashed: str) -> bool:
+def verify_password(password: str, hashed: Optional[str]) -> bool:
     return auth_manager.verify_password(password, hashed)
 
-def create_access_token(user_data: Dict[str, Any]) -> str:
-    return auth_manager.create_access_token(user_data)
+def create_access_token(user_data: Dict[str, Any], session_id: Optional[str] = None) -> str:
+    return auth_manager.create_access_token(user_data, session_id)
 
 def verify_token(token: str) -> Optional[Dict[str, Any]]:
     return auth_manager.verify_token(token)
@@ -119,6 +168,7 @@ def auth_required(f):
         
         # Add user data to request context
         request.current_user = user_data
+        request.token_claims = auth_manager.verify_token(token)
         return f(*args, **kwargs)
     
     return decorated_function
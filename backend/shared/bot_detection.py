@@ -0,0 +1,321 @@
+"""
+Bot and scraper detection
+
+Every request is classified as `human`, `suspect` or `bot` from:
+
+- its user agent: known bots, crawlers, link previewers and HTTP libraries
+  (the same list view counting uses) are bots outright
+- header fingerprinting: browsers send Accept-Language, Accept-Encoding and,
+  in current Chrome, Edge and Firefox, Sec-Fetch-* headers; each one missing
+  adds to a score
+- request cadence: more than BOT_MAX_REQUESTS_PER_WINDOW requests from one
+  client within BOT_CADENCE_WINDOW_SECONDS adds to the score
+- honeypots: paths nothing legitimate links to (and robots.txt disallows);
+  a client requesting one is flagged as a bot for BOT_FLAG_TTL_SECONDS
+
+A score of BOT_SCORE_THRESHOLD or more is a bot, half of it a suspect. A
+client is its IP address (X-Real-IP behind nginx) and user agent.
+
+Bots don't count toward views or engagement events. Their anonymous GET
+requests for article lists, feeds and search are served from a shared
+response cache for BOT_CACHE_TTL_SECONDS so scrapers don't reach the
+database. Daily human/suspect/bot request counts, with the reasons behind
+them, are kept for analytics.
+"""
+
+import os
+import hashlib
+import logging
+from contextvars import ContextVar
+from dataclasses import dataclass, field
+from datetime import datetime, timedelta, timezone
+from typing import Any, Dict, List, Mapping, Optional, Tuple
+
+from shared import tenancy
+from shared.database import get_redis
+from shared.view_counts import is_bot as known_bot_user_agent
+
+logger = logging.getLogger(__name__)
+
+ENABLED = os.getenv('BOT_DETECTION_ENABLED', 'true').lower() == 'true'
+SCORE_THRESHOLD = int(os.getenv('BOT_SCORE_THRESHOLD', 4))
+CADENCE_WINDOW_SECONDS = int(os.getenv('BOT_CADENCE_WINDOW_SECONDS', 10))
+MAX_REQUESTS_PER_WINDOW = int(os.getenv('BOT_MAX_REQUESTS_PER_WINDOW', 30))
+FLAG_TTL_SECONDS = int(os.getenv('BOT_FLAG_TTL_SECONDS', 24 * 60 * 60))
+CACHE_TTL_SECONDS = int(os.getenv('BOT_CACHE_TTL_SECONDS', 10 * 60))
+CACHE_MAX_BYTES = int(os.getenv('BOT_CACHE_MAX_BYTES', 1024 * 1024))
+STATS_RETENTION_DAYS = 90
+
+HONEYPOT_PATHS = [
+    path.strip() for path in os.getenv(
+        'BOT_HONEYPOT_PATHS', '/api/v1/articles/export/all,/api/v1/internal/dump,/wp-login.php,/.env'
+    ).split(',') if path.strip()
+]
+# Anonymous GETs under these prefixes are served to bots from the response cache
+CACHED_PATH_PREFIXES = ('/api/v1/articles', '/api/v1/feed', '/api/v1/search', '/feeds/')
+
+HUMAN = 'human'
+SUSPECT = 'suspect'
+BOT = 'bot'
+KINDS = [HUMAN, SUSPECT, BOT]
+
+MODERN_BROWSERS = ('Chrome/', 'Edg/', 'Firefox/')
+
+
+@dataclass
+class Traffic:
+    client: str
+    kind: str = HUMAN
+    score: int = 0
+    reasons: List[str] = field(default_factory=list)
+
+    @property
+    def is_bot(self) -> bool:
+        return self.kind == BOT
+
+
+_traffic: ContextVar[Optional[Traffic]] = ContextVar('bot_detection_traffic', default=None)
+
+
+def current_traffic() -> Optional[Traffic]:
+    return _traffic.get()
+
+
+def is_bot_request() -> bool:
+    """Whether the request being handled was classified as a bot"""
+    traffic = _traffic.get()
+    return bool(traffic and traffic.is_bot)
+
+
+def begin_traffic(traffic: Optional[Traffic]):
+    """Record the request's classification (Flask)"""
+    _traffic.set(traffic)
+
+
+def end_traffic():
+    _traffic.set(None)
+
+
+def client_id(headers: Mapping[str, str], remote_addr: Optional[str]) -> str:
+    ip_address = headers.get('x-real-ip') or remote_addr or ''
+    return hashlib.sha256(f"{ip_address}:{headers.get('user-agent', '')}".encode()).hexdigest()[:32]
+
+
+def _flag_key(client: str) -> str:
+    return f"bots:flagged:{client}"
+
+
+def _cadence(client: str) -> int:
+    key = f"bots:cadence:{client}:{int(datetime.now(timezone.utc).timestamp()) // CADENCE_WINDOW_SECONDS}"
+    pipeline = get_redis().pipeline()
+    pipeline.incr(key)
+    pipeline.expire(key, CADENCE_WINDOW_SECONDS * 2)
+    count, _ = pipeline.execute()
+    return count
+
+
+def classify(headers: Mapping[str, str], remote_addr: Optional[str]) -> Traffic:
+    """Classify a request from its lower-cased headers and the peer address"""
+    user_agent = headers.get('user-agent', '')
+    traffic = Traffic(client=client_id(headers, remote_addr))
+
+    if known_bot_user_agent(user_agent):
+        traffic.kind, traffic.score, traffic.reasons = BOT, SCORE_THRESHOLD, ['user_agent']
+        return traffic
+
+    if not headers.get('accept-language'):
+        traffic.score += 2
+        traffic.reasons.append('no_accept_language')
+    if not headers.get('accept-encoding'):
+        traffic.score += 1
+        traffic.reasons.append('no_accept_encoding')
+    if any(token in user_agent for token in MODERN_BROWSERS) and not headers.get('sec-fetch-mode'):
+        traffic.score += 2
+        traffic.reasons.append('no_fetch_metadata')
+
+    try:
+        redis = get_redis()
+        if redis.exists(_flag_key(traffic.client)):
+            traffic.kind, traffic.score = BOT, max(traffic.score, SCORE_THRESHOLD)
+            traffic.reasons.append('honeypot')
+            return traffic
+        if _cadence(traffic.client) > MAX_REQUESTS_PER_WINDOW:
+            traffic.score += 3
+            traffic.reasons.append('request_rate')
+    except Exception as e:
+        logger.warning(f"Bot detection state unavailable: {e}")
+
+    if traffic.score >= SCORE_THRESHOLD:
+        traffic.kind = BOT
+    elif traffic.score >= (SCORE_THRESHOLD + 1) // 2:
+        traffic.kind = SUSPECT
+    return traffic
+
+
+def is_honeypot(path: str) -> bool:
+    return path in HONEYPOT_PATHS
+
+
+def flag(traffic: Traffic, path: str):
+    """Mark the client as a bot after it requested a honeypot"""
+    traffic.kind = BOT
+    traffic.reasons.append('honeypot')
+    try:
+        get_redis().set(_flag_key(traffic.client), path, ex=FLAG_TTL_SECONDS)
+    except Exception as e:
+        logger.warning(f"Could not flag bot client: {e}")
+    logger.info(f"Client {traffic.client} flagged as a bot after requesting honeypot {path}")
+
+
+def record(traffic: Traffic):
+    """Count the request toward today's traffic split"""
+    key = f"traffic:{datetime.now(timezone.utc).date().isoformat()}"
+    try:
+        pipeline = get_redis().pipeline()
+        pipeline.hincrby(key, traffic.kind, 1)
+        for reason in traffic.reasons:
+            pipeline.hincrby(key, f"reason:{reason}", 1)
+        pipeline.expire(key, STATS_RETENTION_DAYS * 24 * 60 * 60)
+        pipeline.execute()
+    except Exception as e:
+        logger.warning(f"Could not record traffic classification: {e}")
+
+
+def traffic_split(days: int = 7) -> List[Dict[str, Any]]:
+    """Requests per day by classification, with why bots and suspects were classified so, oldest first"""
+    today = datetime.now(timezone.utc).date()
+    dates = [today - timedelta(days=offset) for offset in range(days - 1, -1, -1)]
+    pipeline = get_redis().pipeline()
+    for day in dates:
+        pipeline.hgetall(f"traffic:{day.isoformat()}")
+
+    split = []
+    for day, counts in zip(dates, pipeline.execute()):
+        counts = {
+            (name.decode() if isinstance(name, bytes) else name): int(value) for name, value in counts.items()
+        }
+        total = sum(counts.get(kind, 0) for kind in KINDS)
+        split.append({
+            'date': day.isoformat(),
+            **{kind: counts.get(kind, 0) for kind in KINDS},
+            'bot_share': round(counts.get(BOT, 0) / total, 4) if total else 0.0,
+            'reasons': {name.split(':', 1)[1]: value for name, value in counts.items() if name.startswith('reason:')},
+        })
+    return split
+
+
+def bot_share_today() -> Optional[float]:
+    try:
+        return traffic_split(1)[0]['bot_share']
+    except Exception as e:
+        logger.warning(f"Traffic split unavailable: {e}")
+        return None
+
+
+# Response cache for bots
+def cacheable(method: str, path: str, headers: Mapping[str, str]) -> bool:
+    from shared.wire_formats import JSON, negotiate
+
+    # Only JSON is cached, since the cache isn't keyed on Accept
+    return (
+        method == 'GET' and not headers.get('authorization') and path.startswith(CACHED_PATH_PREFIXES)
+        and not path.endswith('/stream') and negotiate(headers.get('accept')) == JSON
+    )
+
+
+def _cache_key(path: str, query: str) -> str:
+    return f"bots:cache:{tenancy.cache_namespace()}:" + hashlib.sha256(f"{path}?{query}".encode()).hexdigest()
+
+
+def cached_response(path: str, query: str) -> Optional[Tuple[str, bytes]]:
+    """Content type and body of a cached response, if there is one"""
+    try:
+        cached = get_redis().hgetall(_cache_key(path, query))
+    except Exception as e:
+        logger.warning(f"Bot response cache unavailable: {e}")
+        return None
+    if not cached:
+        return None
+    content_type = cached.get(b'content_type', cached.get('content_type', b'application/json'))
+    body = cached.get(b'body', cached.get('body', b''))
+    return (content_type.decode() if isinstance(content_type, bytes) else content_type,
+            body if isinstance(body, bytes) else body.encode())
+
+
+def store_response(path: str, query: str, content_type: str, body: bytes):
+    if len(body) > CACHE_MAX_BYTES:
+        return
+    try:
+        key = _cache_key(path, query)
+        pipeline = get_redis().pipeline()
+        pipeline.hset(key, mapping={'content_type': content_type, 'body': body})
+        pipeline.expire(key, CACHE_TTL_SECONDS)
+        pipeline.execute()
+    except Exception as e:
+        logger.warning(f"Could not cache response for bots: {e}")
+
+
+class BotDetectionMiddleware:
+    """ASGI middleware classifying each request, trapping honeypot hits and serving bots cached responses"""
+
+    def __init__(self, app):
+        self.app = app
+
+    async def __call__(self, scope, receive, send):
+        if scope['type'] != 'http' or not ENABLED:
+            await self.app(scope, receive, send)
+            return
+
+        headers = {name.decode('latin-1').lower(): value.decode('latin-1') for name, value in scope.get('headers') or []}
+        traffic = classify(headers, scope['client'][0] if scope.get('client') else None)
+        path, query = scope['path'], scope.get('query_string', b'').decode('latin-1')
+
+        if is_honeypot(path):
+            flag(traffic, path)
+            record(traffic)
+            await _send_body(send, 404, 'application/json', b'{"success": false, "message": "Not found"}')
+            return
+        record(traffic)
+
+        if traffic.is_bot and cacheable(scope['method'], path, headers):
+            cached = cached_response(path, query)
+            if cached:
+                await _send_body(send, 200, cached[0], cached[1], cache_status=b'HIT')
+                return
+            send = _capturing(send, path, query)
+
+        context_token = _traffic.set(traffic)
+        try:
+            await self.app(scope, receive, send)
+        finally:
+            _traffic.reset(context_token)
+
+
+async def _send_body(send, status: int, content_type: str, body: bytes, cache_status: Optional[bytes] = None):
+    headers = [(b'content-type', content_type.encode('latin-1')), (b'content-length', str(len(body)).encode())]
+    if cache_status:
+        headers.append((b'x-bot-cache', cache_status))
+    await send({'type': 'http.response.start', 'status': status, 'headers': headers})
+    await send({'type': 'http.response.body', 'body': body})
+
+
+def _capturing(send, path: str, query: str):
+    """Wrap `send` to store a successful response in the bot cache once it is complete"""
+    response: Dict[str, Any] = {'status': None, 'content_type': 'application/json', 'body': bytearray(), 'too_large': False}
+
+    async def capture(message):
+        if message['type'] == 'http.response.start':
+            response['status'] = message['status']
+            for name, value in message.get('headers', []):
+                if name.lower() == b'content-type':
+                    response['content_type'] = value.decode('latin-1')
+        elif message['type'] == 'http.response.body' and response['status'] == 200 and not response['too_large']:
+            response['body'] += message.get('body', b'')
+            if len(response['body']) > CACHE_MAX_BYTES:
+                response['too_large'] = True
+                response['body'] = bytearray()
+            elif not message.get('more_body'):
+                store_response(path, query, response['content_type'], bytes(response['body']))
+        await send(message)
+
+    return capture
@@ -0,0 +1,117 @@
+"""
+Per-organization branding resolved from the request host
+
+Organizations on custom domains get their own logo, colors and footer links,
+and so do publications (shared/tenancy.py) on their domains. Other hosts get
+the platform's default branding. Responses are cached at the
+edge under surrogate keys and purged when an organization or its branding
+changes.
+"""
+
+import os
+import logging
+from typing import Any, Dict, List, Optional
+
+from shared.edge_cache import purge_surrogate_keys
+
+logger = logging.getLogger(__name__)
+
+# How long browsers and the edge may keep a branding response
+BROWSER_MAX_AGE = int(os.getenv('BRANDING_BROWSER_MAX_AGE', 60))
+EDGE_MAX_AGE = int(os.getenv('BRANDING_EDGE_MAX_AGE', 86400))
+
+DEFAULT_SURROGATE_KEY = 'branding'
+
+
+def normalize_host(host: Optional[str]) -> Optional[str]:
+    """Lowercase host name without port or trailing dot"""
+    if not host:
+        return None
+    host = host.strip().lower()
+    if host.startswith('['):
+        # IPv6 literal, never a custom domain
+        return None
+    return host.split(':', 1)[0].rstrip('.') or None
+
+
+def organization_surrogate_key(organization_id: str) -> str:
+    return f"branding-org-{organization_id}"
+
+
+def tenant_surrogate_key(tenant_id: str) -> str:
+    return f"branding-tenant-{tenant_id}"
+
+
+def resolve_branding(cursor, host: Optional[str]) -> Dict[str, Any]:
+    """Branding for a request host, with the surrogate keys its response depends on"""
+    domain = normalize_host(host)
+    organization = None
+    if domain:
+        cursor.execute("""
+            SELECT o.id, o.slug, o.name, b.logo_url, b.favicon_url, b.colors, b.footer_links, b.updated_at
+            FROM organizations o
+            LEFT JOIN organization_branding b ON b.organization_id = o.id
+            WHERE o.custom_domain = %s AND o.is_active = true
+        """, (domain,))
+        organization = cursor.fetchone()
+
+    if not organization:
+        tenant = _tenant_for(domain)
+        if tenant:
+            return tenant_branding(tenant)
+        # Unknown hosts share the default entry; purging 'branding' drops them all
+        return {'branding': {}, 'surrogate_keys': [DEFAULT_SURROGATE_KEY]}
+
+    return {
+        'branding': {
+            'organization': {'slug': organization['slug'], 'name': organization['name']},
+            'logo_url': organization['logo_url'],
+            'favicon_url': organization['favicon_url'],
+            'colors': organization['colors'] or {},
+            'footer_links': organization['footer_links'] or [],
+            'updated_at': organization['updated_at'],
+        },
+        'surrogate_keys': [DEFAULT_SURROGATE_KEY, organization_surrogate_key(str(organization['id']))],
+    }
+
+
+def _tenant_for(domain: Optional[str]) -> Optional[Dict[str, Any]]:
+    from shared import tenancy
+
+    return tenancy.by_domain(domain) if domain and tenancy.enabled() else None
+
+
+def tenant_branding(tenant: Dict[str, Any]) -> Dict[str, Any]:
+    """A publication's branding, with the surrogate keys its response depends on"""
+    branding = tenant.get('branding') or {}
+    return {
+        'branding': {
+            'publication': {'slug': tenant['slug'], 'name': tenant['name']},
+            'logo_url': branding.get('logo_url'),
+            'favicon_url': branding.get('favicon_url'),
+            'colors': branding.get('colors') or {},
+            'footer_links': branding.get('footer_links') or [],
+            'updated_at': tenant['updated_at'],
+        },
+        'surrogate_keys': [DEFAULT_SURROGATE_KEY, tenant_surrogate_key(str(tenant['id']))],
+    }
+
+
+def purge_tenant(tenant_id: str, domains_changed: bool = False):
+    """Drop cached branding for a publication; a change of domains also purges the default entry"""
+    keys: List[str] = [tenant_surrogate_key(tenant_id)]
+    if domains_changed:
+        keys.append(DEFAULT_SURROGATE_KEY)
+    purge_surrogate_keys(keys)
+
+
+def purge_organization(organization_id: str, domain_changed: bool = False):
+    """Drop cached branding for an organization
+
+    A domain change also affects responses cached for hosts that resolved to
+    the default branding, so the shared key is purged too.
+    """
+    keys: List[str] = [organization_surrogate_key(organization_id)]
+    if domain_changed:
+        keys.append(DEFAULT_SURROGATE_KEY)
+    purge_surrogate_keys(keys)
@@ -0,0 +1,208 @@
+"""
+Proof-of-publication certificates
+
+`GET /api/v1/articles/{id}/certificate` gives the author a certificate that
+the article was published here: its hash, the transaction that anchored it
+on chain (the earliest anchor, which is what proves first publication),
+when it was published and who wrote it (username, primary wallet, linked
+DIDs and the author's own signature on the article, if any).
+
+The certificate is signed with the node key (NODE_SIGNING_KEY, the key that
+signs responses). What is signed is its canonical JSON: sorted keys, no
+whitespace, non-ASCII escaped. To check one, drop `signature` from the
+document, serialize the rest the same way and verify the Ed25519 signature
+against the key with the certificate's `key_id` at /api/v1/node/keys
+(`http_signatures.verify_document` does this); the anchor is checked
+independently with the inclusion proof against the ArticleAnchor contract.
+
+The PDF is a printable copy of the same certificate: the fields, then the
+signed canonical JSON and its signature, so the PDF alone is enough to
+verify it.
+"""
+
+import textwrap
+from datetime import datetime, timezone
+from typing import Any, Dict, List, Optional, Tuple
+
+from shared import http_signatures
+from shared.anchoring import inclusion_proof
+from shared.content_signatures import signature_document
+from shared.feed_formats import PUBLIC_BASE_URL, article_url
+from shared.wallets import list_wallets
+
+CERTIFICATE_TYPE = 'proof-of-publication'
+CERTIFICATE_VERSION = 1
+
+
+class CertificateUnavailable(Exception):
+    """Raised when a certificate can't be issued (yet)"""
+
+
+def _iso(value: Optional[datetime]) -> Optional[str]:
+    return value.isoformat() if value else None
+
+
+def build(cursor, article: Dict[str, Any]) -> Dict[str, Any]:
+    """The unsigned certificate for a published article"""
+    if article['status'] != 'published':
+        raise CertificateUnavailable("Only published articles have certificates")
+    proof = inclusion_proof(cursor, article, earliest=True)
+    if not proof or proof['batch']['status'] != 'anchored':
+        raise CertificateUnavailable("The article has not been anchored on chain yet")
+
+    cursor.execute("SELECT id, username, did_address FROM users WHERE id = %s", (article['author_id'],))
+    author = cursor.fetchone()
+    signature = signature_document(cursor, article)
+    batch = proof['batch']
+
+    return {
+        'type': CERTIFICATE_TYPE,
+        'version': CERTIFICATE_VERSION,
+        'issued_at': datetime.now(timezone.utc).isoformat(),
+        'issuer': {
+            'url': PUBLIC_BASE_URL,
+            'key_id': http_signatures.key_id(),
+            'algorithm': http_signatures.ALGORITHM,
+        },
+        'article': {
+            'id': str(article['id']),
+            'title': article['title'],
+            'url': article_url(article),
+            'published_at': _iso(article['published_at']),
+            'language': article.get('language'),
+            'hash_algorithm': 'sha256',
+            # Hash of the id, title, summary and content as first anchored (shared/merkle.py)
+            'hash': proof['article_hash'],
+            'unchanged_since_anchored': proof['current'],
+        },
+        'author': {
+            'id': str(author['id']) if author else None,
+            'username': author['username'] if author else None,
+            'did_address': author['did_address'] if author else None,
+            'dids': [wallet['did'] for wallet in list_wallets(cursor, str(author['id']))] if author else [],
+            'signature': {
+                'algorithm': signature['algorithm'],
+                'public_key': signature['public_key'],
+                'fingerprint': signature['fingerprint'],
+                'signature': signature['signature'],
+                'signed_at': _iso(signature['signed_at']),
+                'status': signature['status'],
+            } if signature else None,
+        },
+        'anchor': {
+            'chain_id': batch['chain_id'],
+            'contract_address': batch['contract_address'],
+            'tx_hash': batch['tx_hash'],
+            'block_number': batch['block_number'],
+            'anchored_at': _iso(batch['anchored_at']),
+            'merkle_root': proof['merkle_root'],
+            'leaf_index': proof['leaf_index'],
+            'proof': proof['proof'],
+        },
+    }
+
+
+def issue(cursor, article: Dict[str, Any]) -> Dict[str, Any]:
+    """The certificate signed with the node key"""
+    if not http_signatures.signing_enabled():
+        raise CertificateUnavailable("This node has no signing key to sign certificates with")
+    return http_signatures.sign_document(build(cursor, article))
+
+
+# PDF
+PAGE_WIDTH, PAGE_HEIGHT = 595, 842  # A4 in points
+MARGIN = 50
+FONTS = {'regular': 'Helvetica', 'bold': 'Helvetica-Bold', 'mono': 'Courier'}
+
+
+def _pdf_text(text: str) -> str:
+    text = text.encode('latin-1', errors='replace').decode('latin-1')
+    return text.replace('\\', '\\\\').replace('(', '\\(').replace(')', '\\)')
+
+
+def _pdf(lines: List[Tuple[str, int, str]]) -> bytes:
+    """A minimal PDF of (font, size, text) lines, flowing onto as many pages as needed"""
+    pages: List[List[str]] = [[]]
+    y = PAGE_HEIGHT - MARGIN
+    for font, size, text in lines:
+        leading = size * 1.4
+        if y - leading < MARGIN:
+            pages.append([])
+            y = PAGE_HEIGHT - MARGIN
+        y -= leading
+        if text:
+            font_name = f"F{list(FONTS).index(font) + 1}"
+            pages[-1].append(f"BT /{font_name} {size} Tf {MARGIN} {y:.1f} Td ({_pdf_text(text)}) Tj ET")
+
+    objects = [
+        "<< /Type /Catalog /Pages 2 0 R >>",
+        None,  # Pages, once the page objects are numbered
+    ]
+    font_refs = []
+    for base_font in FONTS.values():
+        objects.append(f"<< /Type /Font /Subtype /Type1 /BaseFont /{base_font} /Encoding /WinAnsiEncoding >>")
+        font_refs.append(len(objects))
+    fonts = ' '.join(f"/F{i + 1} {ref} 0 R" for i, ref in enumerate(font_refs))
+    page_refs = []
+    for page in pages:
+        stream = '\n'.join(page).encode('latin-1')
+        objects.append(f"<< /Length {len(stream)} >>\nstream\n{stream.decode('latin-1')}\nendstream")
+        objects.append(
+            f"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 {PAGE_WIDTH} {PAGE_HEIGHT}] "
+            f"/Resources << /Font << {fonts} >> >> /Contents {len(objects)} 0 R >>"
+        )
+        page_refs.append(len(objects))
+    objects[1] = f"<< /Type /Pages /Kids [{' '.join(f'{ref} 0 R' for ref in page_refs)}] /Count {len(page_refs)} >>"
+
+    output = bytearray(b"%PDF-1.4\n")
+    offsets = []
+    for number, body in enumerate(objects, start=1):
+        offsets.append(len(output))
+        output += f"{number} 0 obj\n{body}\nendobj\n".encode('latin-1')
+    xref = len(output)
+    output += f"xref\n0 {len(objects) + 1}\n0000000000 65535 f \n".encode()
+    output += ''.join(f"{offset:010d} 00000 n \n" for offset in offsets).encode()
+    output += f"trailer\n<< /Size {len(objects) + 1} /Root 1 0 R >>\nstartxref\n{xref}\n%%EOF\n".encode()
+    return bytes(output)
+
+
+def render_pdf(document: Dict[str, Any]) -> bytes:
+    """A printable copy of a signed certificate"""
+    article, author, anchor = document['article'], document['author'], document['anchor']
+    lines: List[Tuple[str, int, str]] = [
+        ('bold', 18, "Certificate of Publication"),
+        ('regular', 10, f"Issued {document['issued_at']} by {document['issuer']['url']}"),
+        ('regular', 10, ''),
+    ]
+
+    def field(label: str, value: Any):
+        wrapped = textwrap.wrap(str(value if value is not None else '-'), 70) or ['-']
+        lines.append(('bold', 10, label))
+        lines.extend(('regular', 10, f"    {part}") for part in wrapped)
+
+    field("Title", article['title'])
+    field("Article", article['url'])
+    field("Published", article['published_at'])
+    field("Article hash (SHA-256)", article['hash'])
+    field("Unchanged since anchored", "yes" if article['unchanged_since_anchored'] else "no, edited later")
+    field("Author", author['username'])
+    field("Author wallet", author['did_address'])
+    for did in author['dids']:
+        field("Author DID", did)
+    if author['signature']:
+        field("Author signature", f"{author['signature']['algorithm']} key {author['signature']['fingerprint']}, "
+                                  f"signed {author['signature']['signed_at']} ({author['signature']['status']})")
+    field("Anchor transaction", f"{anchor['tx_hash']} (chain {anchor['chain_id']}, block {anchor['block_number']})")
+    field("Anchor contract", anchor['contract_address'])
+    field("Anchored at", anchor['anchored_at'])
+    field("Merkle root", anchor['merkle_root'])
+
+    certificate = {key: value for key, value in document.items() if key != 'signature'}
+    lines.append(('regular', 10, ''))
+    lines.append(('bold', 10, f"Signature ({document['issuer']['algorithm']}, key {document['issuer']['key_id']})"))
+    lines.append(('mono', 8, document['signature']))
+    lines.append(('regular', 10, ''))
+    lines.append(('bold', 10, "Signed document (canonical JSON, lines joined without breaks)"))
+    signed = http_signatures.canonical_json(certificate).decode()
+    lines.extend(('mono', 7, signed[i:i + 110]) for i in range(0, len(signed), 110))
+    return _pdf(lines)
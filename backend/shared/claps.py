@@ -0,0 +1,112 @@
+"""
+Article claps for both Flask and FastAPI backends
+
+A reader can send up to MAX_CLAPS_PER_USER claps to an article across any
+number of requests. Claps feed like_count with diminishing returns per reader,
+so one enthusiastic reader cannot outweigh many readers who liked the piece.
+"""
+
+import os
+import math
+import logging
+from datetime import datetime
+from typing import Any, Dict, Optional
+
+from shared.database import get_redis
+
+logger = logging.getLogger(__name__)
+
+MAX_CLAPS_PER_USER = 50
+
+
+class ClapLimitExceeded(Exception):
+    """Raised when a reader sends claps faster than the rate limit allows"""
+
+
+def clap_weight(claps: int) -> int:
+    """like_count contribution of one reader's claps: 1, 2-3 -> 2, 4-7 -> 3, ... 32-50 -> 6"""
+    if claps <= 0:
+        return 0
+    return 1 + int(math.log2(claps))
+
+
+def check_rate_limit(user_id: str):
+    """Allow CLAP_RATE_LIMIT_PER_MINUTE clap requests per reader; fails open if Redis is down"""
+    limit = int(os.getenv('CLAP_RATE_LIMIT_PER_MINUTE', 30))
+    key = f"claps:rate:{user_id}:{datetime.now().strftime('%Y%m%d%H%M')}"
+
+    try:
+        redis_client = get_redis()
+        count = redis_client.incr(key)
+        if count == 1:
+            redis_client.expire(key, 60)
+    except Exception as e:
+        logger.warning(f"Clap rate limit check skipped: {e}")
+        return
+
+    if count > limit:
+        raise ClapLimitExceeded(f"Clap rate limit of {limit} requests per minute exceeded")
+
+
+def add_claps(cursor, user_id: str, article_id: str, claps: int) -> Dict[str, Any]:
+    """Add claps for a reader, capped at MAX_CLAPS_PER_USER, and update the article aggregates"""
+    # Inserts the reader's first claps, or locks their existing total; a plain SELECT ... FOR UPDATE
+    # locks nothing when there's no row yet, so two first sends could both start from zero
+    cursor.execute("""
+        INSERT INTO article_claps (user_id, article_id, clap_count)
+        VALUES (%s, %s, %s)
+        ON CONFLICT (user_id, article_id) DO UPDATE SET clap_count = article_claps.clap_count
+        RETURNING clap_count, (xmax = 0) AS inserted
+    """, (user_id, article_id, min(claps, MAX_CLAPS_PER_USER)))
+    row = cursor.fetchone()
+    previous = 0 if row['inserted'] else row['clap_count']
+
+    total = min(previous + claps, MAX_CLAPS_PER_USER)
+    added = total - previous
+
+    if added > 0:
+        if not row['inserted']:
+            cursor.execute(
+                "UPDATE article_claps SET clap_count = %s WHERE user_id = %s AND article_id = %s",
+                (total, user_id, article_id)
+            )
+        cursor.execute(
+            "INSERT INTO article_clap_events (user_id, article_id, claps) VALUES (%s, %s, %s)",
+            (user_id, article_id, added)
+        )
+        cursor.execute("""
+            UPDATE articles
+            SET clap_count = COALESCE(clap_count, 0) + %s, like_count = like_count + %s
+            WHERE id = %s
+        """, (added, clap_weight(total) - clap_weight(previous), article_id))
+
+    return {'added': added, 'user_claps': total, 'remaining': MAX_CLAPS_PER_USER - total}
+
+
+def get_user_claps(cursor, user_id: Optional[str], article_id: str) -> int:
+    if not user_id:
+        return 0
+    cursor.execute(
+        "SELECT clap_count FROM article_claps WHERE user_id = %s AND article_id = %s",
+        (user_id, article_id)
+    )
+    row = cursor.fetchone()
+    return row['clap_count'] if row else 0
+
+
+def author_clap_metrics(cursor, author_id: str, date_from: datetime, date_to: datetime) -> Dict[str, Any]:
+    """Claps received on articles the author leads or co-authors; intensity is the average claps per clapping reader"""
+    cursor.execute("""
+        SELECT COALESCE(SUM(e.claps), 0) as claps, COUNT(DISTINCT e.user_id) as clappers
+        FROM article_clap_events e
+        JOIN articles a ON a.id = e.article_id
+        WHERE (a.author_id = %s OR %s = ANY(a.coauthor_ids)) AND e.created_at BETWEEN %s AND %s
+    """, (author_id, author_id, date_from, date_to))
+    row = cursor.fetchone()
+    claps, clappers = int(row['claps']), int(row['clappers'])
+
+    return {
+        'claps': claps,
+        'clappers': clappers,
+        'intensity': round(claps / clappers, 2) if clappers else 0.0,
+    }
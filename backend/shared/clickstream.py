@@ -0,0 +1,332 @@
+"""
+ClickHouse clickstream sink
+
+When CLICKHOUSE_URL is set, the outbox relay hands every `interaction.recorded`
+event it relays to this sink. Events are queued in a Redis list and a flusher
+inserts them into ClickHouse in batches with asynchronous inserts. While
+ClickHouse is unreachable the queue keeps filling, up to
+CLICKHOUSE_BUFFER_MAX_EVENTS (oldest dropped first), and drains once it is
+back; if Redis is down too, events wait in memory.
+
+With CLICKHOUSE_ANALYTICS_ENABLED the interaction counts behind the analytics
+endpoints are read from ClickHouse. Counting helpers return None when the
+sink is off or a query fails, and callers fall back to PostgreSQL. Enqueue
+`backfill_clickstream` first so interactions from before the sink was
+enabled are counted too.
+"""
+
+import os
+import json
+import uuid
+import asyncio
+import logging
+from collections import deque
+from datetime import datetime, timezone
+from typing import Any, Deque, Dict, Iterable, List, Optional
+
+import requests
+
+from shared.database import get_postgres_cursor, get_redis, query_timeout
+
+logger = logging.getLogger(__name__)
+
+CLICKHOUSE_URL = os.getenv('CLICKHOUSE_URL', '').rstrip('/')
+DATABASE = os.getenv('CLICKHOUSE_DATABASE', 'news_analytics')
+TABLE = f"{DATABASE}.clickstream_events"
+BATCH_SIZE = int(os.getenv('CLICKHOUSE_BATCH_SIZE', 5000))
+FLUSH_SECONDS = float(os.getenv('CLICKHOUSE_FLUSH_SECONDS', 2))
+MAX_BACKOFF_SECONDS = float(os.getenv('CLICKHOUSE_MAX_BACKOFF_SECONDS', 60))
+BUFFER_MAX_EVENTS = int(os.getenv('CLICKHOUSE_BUFFER_MAX_EVENTS', 1000000))
+REQUEST_TIMEOUT_SECONDS = int(os.getenv('CLICKHOUSE_TIMEOUT_SECONDS', 10))
+
+BUFFER_KEY = 'clickstream:buffer'
+
+# Used only while Redis is unavailable
+_memory_buffer: Deque[str] = deque(maxlen=int(os.getenv('CLICKHOUSE_MEMORY_BUFFER_EVENTS', 10000)))
+
+
+def enabled() -> bool:
+    return bool(CLICKHOUSE_URL)
+
+
+def analytics_enabled() -> bool:
+    return enabled() and os.getenv('CLICKHOUSE_ANALYTICS_ENABLED', 'false').lower() == 'true'
+
+
+# ClickHouse HTTP interface
+def _query(query: str, data: Optional[bytes] = None, params: Optional[Dict[str, Any]] = None,
+           settings: Optional[Dict[str, Any]] = None) -> requests.Response:
+    """Run a query; `params` fill `{name:Type}` placeholders"""
+    response = requests.post(
+        CLICKHOUSE_URL,
+        params={
+            'query': query,
+            **{f"param_{name}": value for name, value in (params or {}).items()},
+            **(settings or {}),
+        },
+        data=data,
+        auth=(os.getenv('CLICKHOUSE_USER', 'default'), os.getenv('CLICKHOUSE_PASSWORD', '')),
+        timeout=REQUEST_TIMEOUT_SECONDS,
+    )
+    response.raise_for_status()
+    return response
+
+
+def _select(query: str, params: Optional[Dict[str, Any]] = None) -> List[Dict[str, Any]]:
+    return _query(f"{query} FORMAT JSON", params=params, settings={'output_format_json_quote_64bit_integers': 0}).json()['data']
+
+
+_table_ready = False
+
+
+def ensure_table():
+    global _table_ready
+    if _table_ready:
+        return
+    _query(f"CREATE DATABASE IF NOT EXISTS {DATABASE}")
+    # Re-sent events share an event_id and collapse when parts merge
+    _query(f"""
+        CREATE TABLE IF NOT EXISTS {TABLE} (
+            event_id UUID,
+            event_type LowCardinality(String),
+            occurred_at DateTime64(3, 'UTC'),
+            user_id Nullable(UUID),
+            article_id Nullable(UUID),
+            interaction_type LowCardinality(String),
+            interaction_strength Nullable(Float32),
+            reading_progress Nullable(Float32),
+            time_spent Nullable(UInt32),
+            device_type LowCardinality(Nullable(String)),
+            properties String DEFAULT '{{}}',
+            source LowCardinality(String) DEFAULT 'relay'
+        )
+        ENGINE = ReplacingMergeTree
+        PARTITION BY toYYYYMM(occurred_at)
+        ORDER BY (interaction_type, toDate(occurred_at), event_id)
+    """)
+    _table_ready = True
+
+
+# Buffering
+def _row(event: Dict[str, Any]) -> Dict[str, Any]:
+    payload = event['payload']
+    data = dict(payload.get('data') or {})
+    return {
+        'event_id': payload['id'],
+        'event_type': payload['type'],
+        'occurred_at': payload['occurred_at'],
+        'user_id': data.pop('user_id', None),
+        'article_id': data.pop('article_id', None),
+        'interaction_type': data.pop('interaction_type', ''),
+        'interaction_strength': data.pop('interaction_strength', None),
+        'reading_progress': data.pop('reading_progress', None),
+        'time_spent': data.pop('time_spent', None),
+        'device_type': data.pop('device_type', None),
+        'properties': json.dumps(data, default=str),
+        'source': 'relay',
+    }
+
+
+def encode(event: Dict[str, Any]) -> str:
+    """An interaction event as a queued ClickHouse row; raises for a malformed event"""
+    return json.dumps(_row(event), default=str)
+
+
+def buffer(events: Iterable[Dict[str, Any]]) -> int:
+    """Queue relayed outbox events for ClickHouse; only interaction events are kept
+
+    Events that can't be made into a row are quarantined (shared/dead_letters.py).
+    """
+    from shared.events import INTERACTION_RECORDED
+    from shared.dead_letters import CLICKSTREAM, quarantine
+
+    rows = []
+    for event in events:
+        if event['event_type'] != INTERACTION_RECORDED:
+            continue
+        try:
+            rows.append(encode(event))
+        except Exception as e:
+            quarantine(CLICKSTREAM, event, f"Not a clickstream row: {e!r}")
+    return push_rows(rows)
+
+
+def push_rows(rows: List[str]) -> int:
+    """Queue encoded rows for the flusher, in memory while Redis is down"""
+    if not rows:
+        return 0
+    try:
+        pipeline = get_redis().pipeline()
+        pipeline.rpush(BUFFER_KEY, *rows)
+        pipeline.ltrim(BUFFER_KEY, -BUFFER_MAX_EVENTS, -1)
+        pipeline.execute()
+    except Exception as e:
+        logger.warning(f"Clickstream buffer unavailable, holding {len(rows)} events in memory: {e}")
+        _memory_buffer.extend(rows)
+    return len(rows)
+
+
+def _take(limit: int) -> List[str]:
+    rows: List[str] = []
+    while _memory_buffer and len(rows) < limit:
+        rows.append(_memory_buffer.popleft())
+    if len(rows) < limit:
+        try:
+            pipeline = get_redis().pipeline()
+            pipeline.lrange(BUFFER_KEY, 0, limit - len(rows) - 1)
+            pipeline.ltrim(BUFFER_KEY, limit - len(rows), -1)
+            taken, _ = pipeline.execute()
+        except Exception:
+            if rows:
+                return rows
+            raise
+        rows += [row.decode() if isinstance(row, bytes) else row for row in taken]
+    return rows
+
+
+def _put_back(rows: List[str]):
+    try:
+        get_redis().lpush(BUFFER_KEY, *reversed(rows))
+    except Exception:
+        _memory_buffer.extendleft(reversed(rows))
+
+
+def insert_rows(rows: List[str]):
+    ensure_table()
+    # Asynchronous inserts let ClickHouse merge small batches server-side; waiting keeps failures visible
+    _query(
+        f"INSERT INTO {TABLE} FORMAT JSONEachRow", '\n'.join(rows).encode(),
+        settings={'async_insert': 1, 'wait_for_async_insert': 1, 'date_time_input_format': 'best_effort'}
+    )
+
+
+def flush() -> int:
+    """Insert one batch from the buffer; on failure the batch goes back to the front"""
+    rows = _take(BATCH_SIZE)
+    if not rows:
+        return 0
+    try:
+        insert_rows(rows)
+    except Exception:
+        _put_back(rows)
+        raise
+    return len(rows)
+
+
+def buffered() -> int:
+    try:
+        return get_redis().llen(BUFFER_KEY) + len(_memory_buffer)
+    except Exception:
+        return len(_memory_buffer)
+
+
+async def run():
+    """Flush the buffer until cancelled, backing off while ClickHouse is down"""
+    if not enabled():
+        return
+    logger.info(f"Clickstream sink writing to ClickHouse table {TABLE}")
+    backoff = FLUSH_SECONDS
+    while True:
+        try:
+            if await asyncio.to_thread(flush) >= BATCH_SIZE:
+                continue
+            backoff = FLUSH_SECONDS
+        except Exception as e:
+            backoff = min(backoff * 2, MAX_BACKOFF_SECONDS)
+            logger.warning(f"Clickstream flush failed, {buffered()} events buffered; retrying in {backoff:.0f}s: {e}")
+        await asyncio.sleep(backoff)
+
+
+def backfill(batch_size: int = 50000) -> int:
+    """Copy interactions from before the first relayed event into ClickHouse, keyed by interaction id"""
+    if not enabled():
+        return 0
+    ensure_table()
+    first = _select(f"SELECT min(occurred_at) AS first FROM {TABLE} WHERE source = 'relay'")
+    # min() of no rows is the epoch
+    cutoff = first[0]['first'] if first and not str(first[0]['first']).startswith('1970') else None
+    cutoff = datetime.fromisoformat(cutoff).replace(tzinfo=timezone.utc) if cutoff else datetime.now(timezone.utc)
+
+    copied, last = 0, (datetime.min.replace(tzinfo=timezone.utc), uuid.UUID(int=0))
+    while True:
+        with get_postgres_cursor(timeout_ms=query_timeout('analytics')) as cursor:
+            cursor.execute("""
+                SELECT id, user_id, article_id, interaction_type::text AS interaction_type, interaction_strength,
+                       reading_progress, time_spent, device_type, created_at
+                FROM user_interactions
+                WHERE created_at < %s AND (created_at, id) > (%s, %s)
+                ORDER BY created_at, id
+                LIMIT %s
+            """, (cutoff, last[0], str(last[1]), batch_size))
+            rows = cursor.fetchall()
+        if not rows:
+            break
+        insert_rows([json.dumps({
+            'event_id': str(row['id']),
+            'event_type': 'interaction.recorded',
+            'occurred_at': row['created_at'].isoformat(),
+            'user_id': str(row['user_id']),
+            'article_id': str(row['article_id']),
+            'interaction_type': row['interaction_type'],
+            'interaction_strength': float(row['interaction_strength']) if row['interaction_strength'] is not None else None,
+            'reading_progress': float(row['reading_progress']) if row['reading_progress'] is not None else None,
+            'time_spent': row['time_spent'],
+            'device_type': row['device_type'],
+            'source': 'backfill',
+        }) for row in rows])
+        copied += len(rows)
+        last = (rows[-1]['created_at'], rows[-1]['id'])
+    logger.info(f"Backfilled {copied} interactions into ClickHouse before {cutoff.isoformat()}")
+    return copied
+
+
+# Analytics queries
+def count_interactions(types: List[str], date_from: datetime, date_to: datetime,
+                       user_id: Optional[str] = None, article_id: Optional[str] = None) -> Optional[Dict[str, int]]:
+    """Interactions of each type in the period, or None to fall back to PostgreSQL"""
+    if not analytics_enabled():
+        return None
+    query = f"""
+        SELECT interaction_type, uniqExact(event_id) AS count FROM {TABLE}
+        WHERE interaction_type IN {{types:Array(String)}}
+          AND occurred_at BETWEEN {{date_from:DateTime64(3, 'UTC')}} AND {{date_to:DateTime64(3, 'UTC')}}
+    """
+    params: Dict[str, Any] = {
+        'types': '[' + ','.join(f"'{kind}'" for kind in types) + ']',
+        'date_from': _param_time(date_from),
+        'date_to': _param_time(date_to),
+    }
+    if user_id:
+        query += " AND user_id = {user_id:UUID}"
+        params['user_id'] = user_id
+    if article_id:
+        query += " AND article_id = {article_id:UUID}"
+        params['article_id'] = article_id
+    try:
+        counts = {row['interaction_type']: int(row['count']) for row in _select(query + " GROUP BY interaction_type", params)}
+    except Exception as e:
+        logger.warning(f"ClickHouse interaction count failed, using PostgreSQL: {e}")
+        return None
+    return {kind: counts.get(kind, 0) for kind in types}
+
+
+def active_users(since: datetime) -> Optional[int]:
+    """Distinct readers with an interaction since `since`, or None to fall back to PostgreSQL"""
+    if not analytics_enabled():
+        return None
+    try:
+        rows = _select(
+            f"SELECT uniqExact(user_id) AS active FROM {TABLE} WHERE occurred_at >= {{since:DateTime64(3, 'UTC')}}",
+            {'since': _param_time(since)}
+        )
+    except Exception as e:
+        logger.warning(f"ClickHouse active users query failed, using PostgreSQL: {e}")
+        return None
+    return int(rows[0]['active']) if rows else 0
+
+
+def _param_time(value: datetime) -> str:
+    # Naive datetimes are the server's local time, as in the PostgreSQL queries these replace
+    if value.tzinfo is None:
+        value = value.astimezone()
+    return value.astimezone(timezone.utc).strftime('%Y-%m-%d %H:%M:%S.%f')[:-3]
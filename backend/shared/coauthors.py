@@ -0,0 +1,240 @@
+"""
+Article authors: co-authorship and contributor credits
+
+An article's `author_id` is its lead author. The lead (or anyone holding
+`article:edit_any`) credits others on it as a `contributor` or
+`translator` by invitation: the invitee is notified and accepts or
+declines, and only accepted authors are credited. Accepted co-authors can
+edit the article like its lead and leave it whenever they like, but only
+the lead changes their roles or takes their credit away.
+
+Every credit is a row of `article_authors`; the lead's row follows
+`author_id` through a trigger, and accepted co-authors are mirrored into
+`articles.coauthor_ids` so permission checks and author filters read them
+off the article itself. Responses carry the credited authors in `authors`,
+lead first then in byline order, except on anonymously published articles.
+Changes record an `article.credited` domain event naming who to notify: the
+invitee on an invitation, the lead when it's answered or a co-author
+leaves, and the co-author when the lead changes or removes their credit.
+
+Credits carry the publication's `tenant_id` under row-level security, and
+only users of the article's publication can be invited.
+"""
+
+import os
+import logging
+from collections import defaultdict
+from datetime import datetime, timezone
+from typing import Any, Dict, Iterable, List, Optional
+
+from shared import permissions
+from shared.events import article_credited
+from shared.members import in_publication
+
+logger = logging.getLogger(__name__)
+
+LEAD = 'lead'
+CONTRIBUTOR = 'contributor'
+TRANSLATOR = 'translator'
+
+ROLES = (LEAD, CONTRIBUTOR, TRANSLATOR)
+CREDIT_ROLES = (CONTRIBUTOR, TRANSLATOR)  # Roles the lead credits others with
+
+INVITED = 'invited'
+ACCEPTED = 'accepted'
+DECLINED = 'declined'
+
+# Most authors credited on one article, counting the lead and pending invitations
+MAX_AUTHORS = int(os.getenv('ARTICLE_MAX_AUTHORS', 20))
+
+AUTHOR_COLUMNS = """
+    c.article_id, c.user_id, u.username, u.profile_data->>'display_name' AS display_name, c.role, c.status,
+    c.position, c.invited_by, c.responded_at, c.created_at
+"""
+
+
+class CoauthorError(Exception):
+    """The credit isn't in a state that allows the change"""
+
+
+def can_manage(user: Optional[Dict[str, Any]], article: Dict[str, Any]) -> bool:
+    """Whether `user` leads the article or holds `article:edit_any`"""
+    return bool(user) and (
+        str(article['author_id']) == str(user['id']) or permissions.allowed(user, permissions.ARTICLE_EDIT_ANY)
+    )
+
+
+# Reading
+def credits(cursor, article_ids: Iterable[str]) -> Dict[str, List[Dict[str, Any]]]:
+    """Accepted authors of each article, lead first then in byline order"""
+    ids = sorted({str(article_id) for article_id in article_ids})
+    if not ids:
+        return {}
+    cursor.execute("""
+        SELECT c.article_id, c.user_id, u.username, u.profile_data->>'display_name' AS display_name, c.role
+        FROM article_authors c
+        JOIN users u ON u.id = c.user_id
+        WHERE c.article_id = ANY(%s::uuid[]) AND c.status = 'accepted' AND u.deleted_at IS NULL
+        ORDER BY c.article_id, c.role <> 'lead', c.position, c.created_at
+    """, (ids,))
+    by_article = defaultdict(list)
+    for row in cursor.fetchall():
+        by_article[str(row['article_id'])].append({
+            'user_id': str(row['user_id']), 'username': row['username'],
+            'display_name': row['display_name'], 'role': row['role'],
+        })
+    return dict(by_article)
+
+
+def attach(cursor, articles: Iterable[Dict[str, Any]]) -> List[Dict[str, Any]]:
+    """The articles with their credited authors in `authors`; none for anonymous ones"""
+    articles = [dict(article) for article in articles]
+    credited = credits(cursor, [article['id'] for article in articles if not article.get('anonymous_author')])
+    for article in articles:
+        article['authors'] = [] if article.get('anonymous_author') else credited.get(str(article['id']), [])
+    return articles
+
+
+def authors(cursor, article_id: str) -> List[Dict[str, Any]]:
+    """Every credit of an article, with pending and declined invitations"""
+    cursor.execute(f"""
+        SELECT {AUTHOR_COLUMNS}
+        FROM article_authors c
+        JOIN users u ON u.id = c.user_id
+        WHERE c.article_id = %s
+        ORDER BY c.role <> 'lead', c.position, c.created_at
+    """, (article_id,))
+    return [dict(row) for row in cursor.fetchall()]
+
+
+def invitations(cursor, user_id: str) -> List[Dict[str, Any]]:
+    """Invitations waiting for the user's answer, newest first"""
+    cursor.execute(f"""
+        SELECT {AUTHOR_COLUMNS}, a.title AS article_title, a.status AS article_status,
+               inviter.username AS invited_by_username
+        FROM article_authors c
+        JOIN users u ON u.id = c.user_id
+        JOIN articles a ON a.id = c.article_id
+        LEFT JOIN users inviter ON inviter.id = c.invited_by
+        WHERE c.user_id = %s AND c.status = 'invited'
+        ORDER BY c.created_at DESC
+    """, (user_id,))
+    return [dict(row) for row in cursor.fetchall()]
+
+
+def _get_credit(cursor, article_id: str, user_id: str) -> Optional[Dict[str, Any]]:
+    cursor.execute(f"""
+        SELECT {AUTHOR_COLUMNS}
+        FROM article_authors c
+        JOIN users u ON u.id = c.user_id
+        WHERE c.article_id = %s AND c.user_id = %s
+        FOR UPDATE OF c
+    """, (article_id, user_id))
+    row = cursor.fetchone()
+    return dict(row) if row else None
+
+
+# Changes
+def _notify(cursor, article: Dict[str, Any], credit: Dict[str, Any], action: str, actor: Dict[str, Any],
+            recipients: List[Optional[str]]):
+    recipients = {str(user_id) for user_id in recipients if user_id} - {str(actor['id'])}
+    article_credited(cursor, str(article['id']), str(credit['user_id']), credit['role'], action, str(actor['id']),
+                     sorted(recipients))
+
+
+def invite(cursor, article: Dict[str, Any], user_id: str, role: str, actor: Dict[str, Any]) -> Dict[str, Any]:
+    """Invite a user of the publication to be credited on the article; they're credited once they accept
+
+    Raises LookupError when there's no such user, and CoauthorError when
+    they're already credited or invited, or the article has MAX_AUTHORS.
+    Someone who declined can be invited again.
+    """
+    if role not in CREDIT_ROLES:
+        raise CoauthorError(f"Authors can be credited as {' or '.join(CREDIT_ROLES)}")
+    condition, params = in_publication()
+    cursor.execute(
+        f"SELECT id FROM users WHERE id = %s AND deleted_at IS NULL AND is_active AND {condition}",
+        [user_id] + params
+    )
+    if not cursor.fetchone():
+        raise LookupError("No such user in this publication")
+
+    existing = _get_credit(cursor, article['id'], user_id)
+    if existing and existing['status'] != DECLINED:
+        raise CoauthorError(
+            "That user already leads this article" if existing['role'] == LEAD
+            else f"That user is already {'credited' if existing['status'] == ACCEPTED else 'invited'}"
+        )
+    cursor.execute(
+        "SELECT COUNT(*) AS count, COALESCE(MAX(position), 0) AS last FROM article_authors "
+        "WHERE article_id = %s AND status <> 'declined'",
+        (article['id'],)
+    )
+    counts = cursor.fetchone()
+    if counts['count'] >= MAX_AUTHORS:
+        raise CoauthorError(f"An article can credit at most {MAX_AUTHORS} authors")
+
+    cursor.execute("""
+        INSERT INTO article_authors (article_id, user_id, role, position, invited_by)
+        VALUES (%s, %s, %s, %s, %s)
+        ON CONFLICT (article_id, user_id) DO UPDATE
+            SET role = EXCLUDED.role, status = 'invited', position = EXCLUDED.position,
+                invited_by = EXCLUDED.invited_by, responded_at = NULL, created_at = CURRENT_TIMESTAMP
+    """, (article['id'], user_id, role, counts['last'] + 1, actor['id']))
+    credit = _get_credit(cursor, article['id'], user_id)
+    _notify(cursor, article, credit, 'invited', actor, [user_id])
+    return credit
+
+
+def respond(cursor, article: Dict[str, Any], user: Dict[str, Any], accept: bool) -> Dict[str, Any]:
+    """Accept or decline an invitation to the article; raises LookupError when there's none pending"""
+    credit = _get_credit(cursor, article['id'], user['id'])
+    if not credit or credit['status'] != INVITED:
+        raise LookupError("No pending invitation to this article")
+    cursor.execute(
+        "UPDATE article_authors SET status = %s, responded_at = %s WHERE article_id = %s AND user_id = %s",
+        (ACCEPTED if accept else DECLINED, datetime.now(timezone.utc), article['id'], user['id'])
+    )
+    credit = _get_credit(cursor, article['id'], user['id'])
+    _notify(cursor, article, credit, 'accepted' if accept else 'declined', user, [article['author_id']])
+    return credit
+
+
+def set_role(cursor, article: Dict[str, Any], user_id: str, role: str, actor: Dict[str, Any]) -> Dict[str, Any]:
+    """Change a co-author's role; raises LookupError when they aren't credited or invited"""
+    if role not in CREDIT_ROLES:
+        raise CoauthorError(f"Authors can be credited as {' or '.join(CREDIT_ROLES)}")
+    credit = _get_credit(cursor, article['id'], user_id)
+    if not credit or credit['status'] == DECLINED:
+        raise LookupError("That user isn't an author of this article")
+    if credit['role'] == LEAD:
+        raise CoauthorError("The lead author's role follows the article's author")
+    if credit['role'] == role:
+        return credit
+    cursor.execute(
+        "UPDATE article_authors SET role = %s WHERE article_id = %s AND user_id = %s", (role, article['id'], user_id)
+    )
+    credit = {**credit, 'role': role}
+    if credit['status'] == ACCEPTED:
+        _notify(cursor, article, credit, 'role_changed', actor, [user_id])
+    return credit
+
+
+def remove(cursor, article: Dict[str, Any], user_id: str, actor: Dict[str, Any]) -> Dict[str, Any]:
+    """Take a credit or invitation away, or leave the article when `actor` is the co-author
+
+    Raises LookupError when the user isn't an author, and CoauthorError for the lead.
+    """
+    credit = _get_credit(cursor, article['id'], user_id)
+    if not credit:
+        raise LookupError("That user isn't an author of this article")
+    if credit['role'] == LEAD:
+        raise CoauthorError("The lead author can't be removed")
+    cursor.execute("DELETE FROM article_authors WHERE article_id = %s AND user_id = %s", (article['id'], user_id))
+    if credit['status'] != DECLINED:
+        leaving = str(user_id) == str(actor['id'])
+        _notify(
+            cursor, article, credit, 'left' if leaving else 'removed', actor,
+            [article['author_id']] if leaving else [user_id]
+        )
+    return credit
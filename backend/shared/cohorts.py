@@ -0,0 +1,159 @@
+"""
+Weekly signup cohorts and their retention
+
+Users belong to the cohort of the week (Monday, UTC) they signed up in. For
+every later week, a worker counts how many of each cohort came back:
+`returning_users` made a signed-in request or viewed an article that week,
+`interacting_users` liked, shared, saved or commented, and `active_users` did
+either. Signed-in requests are recorded once per user per day as they happen,
+since sessions themselves only live in Redis.
+
+Each run recomputes the cohorts of the last COHORT_ROLLUP_WEEKS weeks, so the
+matrix only reads the rollups.
+"""
+
+import os
+import csv
+import io
+import logging
+from datetime import date, datetime, timedelta, timezone
+from typing import Any, Dict, List, Optional
+
+from shared.database import get_postgres_cursor, get_redis
+
+logger = logging.getLogger(__name__)
+
+ROLLUP_WEEKS = int(os.getenv('COHORT_ROLLUP_WEEKS', 12))
+MAX_WEEKS = int(os.getenv('COHORT_MAX_WEEKS', 52))
+
+METRICS = ('active_users', 'returning_users', 'interacting_users')
+
+
+def week_start(day: date) -> date:
+    return day - timedelta(days=day.weekday())
+
+
+def record_visit(user_id: Optional[str]):
+    """Note that the user was active today; cheap to call on every signed-in request"""
+    if not user_id:
+        return
+    today = datetime.now(timezone.utc).date()
+    try:
+        # The Redis key only lets the first request of the day reach the database
+        if not get_redis().set(f"activity_day:{user_id}:{today.isoformat()}", 1, nx=True, ex=2 * 24 * 3600):
+            return
+        with get_postgres_cursor() as cursor:
+            cursor.execute("""
+                INSERT INTO user_activity_days (user_id, day) VALUES (%s, %s)
+                ON CONFLICT DO NOTHING
+            """, (user_id, today))
+    except Exception as e:
+        logger.warning(f"Could not record activity for user {user_id}: {e}")
+
+
+def roll_up(cursor, since: date) -> int:
+    """Recompute retention for every cohort from the week of `since` on"""
+    since = week_start(since)
+    cursor.execute("DELETE FROM cohort_retention WHERE cohort_week >= %s", (since,))
+    cursor.execute("""
+        WITH cohorts AS (
+            SELECT id AS user_id, date_trunc('week', created_at AT TIME ZONE 'UTC')::date AS cohort_week
+            FROM users
+            WHERE created_at >= %(since)s
+        ),
+        sizes AS (
+            SELECT cohort_week, COUNT(*) AS cohort_size FROM cohorts GROUP BY cohort_week
+        ),
+        activity AS (
+            SELECT user_id, date_trunc('week', day)::date AS week, TRUE AS returned, FALSE AS interacted
+            FROM user_activity_days
+            WHERE day >= %(since)s
+
+            UNION ALL
+
+            SELECT user_id, date_trunc('week', created_at AT TIME ZONE 'UTC')::date,
+                   interaction_type = 'view', interaction_type != 'view'
+            FROM user_interactions
+            WHERE created_at >= %(since)s
+
+            UNION ALL
+
+            SELECT user_id, date_trunc('week', created_at AT TIME ZONE 'UTC')::date, FALSE, TRUE
+            FROM comments
+            WHERE created_at >= %(since)s AND NOT is_deleted
+        ),
+        weekly AS (
+            SELECT user_id, week, bool_or(returned) AS returned, bool_or(interacted) AS interacted
+            FROM activity
+            GROUP BY user_id, week
+        ),
+        offsets AS (
+            SELECT s.cohort_week, s.cohort_size, (w.week::date - s.cohort_week) / 7 AS week_offset
+            FROM sizes s
+            CROSS JOIN LATERAL generate_series(
+                s.cohort_week, date_trunc('week', NOW() AT TIME ZONE 'UTC')::date, INTERVAL '1 week'
+            ) w(week)
+        )
+        INSERT INTO cohort_retention (
+            cohort_week, week_offset, cohort_size, active_users, returning_users, interacting_users
+        )
+        SELECT o.cohort_week, o.week_offset, o.cohort_size,
+               COUNT(w.user_id),
+               COUNT(w.user_id) FILTER (WHERE w.returned),
+               COUNT(w.user_id) FILTER (WHERE w.interacted)
+        FROM offsets o
+        LEFT JOIN cohorts c ON c.cohort_week = o.cohort_week
+        LEFT JOIN weekly w ON w.user_id = c.user_id AND w.week = o.cohort_week + o.week_offset * 7
+        GROUP BY o.cohort_week, o.week_offset, o.cohort_size
+    """, {'since': since})
+    return cursor.rowcount
+
+
+def roll_up_recent(cursor, weeks: Optional[int] = None) -> int:
+    today = datetime.now(timezone.utc).date()
+    since = week_start(today) - timedelta(weeks=(weeks or ROLLUP_WEEKS) - 1)
+    rows = roll_up(cursor, since)
+    logger.info(f"Rolled up cohort retention from {since.isoformat()}: {rows} rows")
+    return rows
+
+
+def matrix(cursor, weeks: int, metric: str) -> List[Dict[str, Any]]:
+    """The last `weeks` cohorts, oldest first, each with its `metric` count and rate per week since signup"""
+    since = week_start(datetime.now(timezone.utc).date()) - timedelta(weeks=weeks - 1)
+    cursor.execute(f"""
+        SELECT cohort_week, week_offset, cohort_size, {metric} AS users
+        FROM cohort_retention
+        WHERE cohort_week >= %s
+        ORDER BY cohort_week, week_offset
+    """, (since,))
+
+    cohorts: Dict[date, Dict[str, Any]] = {}
+    for row in cursor.fetchall():
+        cohort = cohorts.setdefault(row['cohort_week'], {
+            'cohort_week': row['cohort_week'].isoformat(),
+            'cohort_size': row['cohort_size'],
+            'retention': [],
+        })
+        cohort['retention'].append({
+            'week': row['week_offset'],
+            'users': row['users'],
+            'rate': round(row['users'] / row['cohort_size'], 4) if row['cohort_size'] else 0.0,
+        })
+    return list(cohorts.values())
+
+
+def to_csv(cohorts: List[Dict[str, Any]], value: str = 'rate') -> str:
+    """The matrix as CSV: one row per cohort, one column per week since signup"""
+    width = max((len(cohort['retention']) for cohort in cohorts), default=0)
+    output = io.StringIO()
+    writer = csv.writer(output)
+    writer.writerow(['cohort_week', 'cohort_size'] + [f"week_{offset}" for offset in range(width)])
+    for cohort in cohorts:
+        cells = [point[value] for point in cohort['retention']]
+        writer.writerow([cohort['cohort_week'], cohort['cohort_size']] + cells + [''] * (width - len(cells)))
+    return output.getvalue()
+
+
+def rolled_up_at(cursor) -> Optional[datetime]:
+    cursor.execute("SELECT MAX(updated_at) AS at FROM cohort_retention")
+    return cursor.fetchone()['at']
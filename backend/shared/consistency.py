@@ -0,0 +1,261 @@
+"""
+Database consistency checks
+
+Denormalized counters drift when a request dies between its two writes,
+purges get interrupted and rows outlive what they belong to, and nothing
+notices. A nightly job (every CONSISTENCY_CHECK_INTERVAL_SECONDS) runs each
+registered check and stores a report in `consistency_reports`:
+administrators read the reports and run the checks on demand through
+/api/v1/admin/consistency.
+
+A check is a query returning one row per violation, with the violating row's
+id as `subject_id`. A check may also carry a repair: a statement that fixes
+every violation, run with the violations available as the CTE `violations`.
+Only inconsistencies with one right answer get a repair, such as a counter
+that can be recounted from the rows it counts; everything else is reported
+for a person to decide. Repairs run when a run asks for them, and on the
+scheduled run when CONSISTENCY_AUTO_REPAIR is set.
+
+More checks are added with `register_check`.
+"""
+
+import os
+import json
+import logging
+from dataclasses import dataclass
+from typing import Any, Dict, List, Optional
+
+from shared.database import get_postgres_cursor, get_redis
+
+logger = logging.getLogger(__name__)
+
+AUTO_REPAIR = os.getenv('CONSISTENCY_AUTO_REPAIR', 'false').lower() == 'true'
+SAMPLE_SIZE = int(os.getenv('CONSISTENCY_SAMPLE_SIZE', 50))
+LOCK_SECONDS = 60 * 60
+
+
+@dataclass(frozen=True)
+class Check:
+    name: str
+    description: str
+    query: str
+    repair: Optional[str] = None
+
+
+_checks: Dict[str, Check] = {}
+
+
+def register_check(check: Check) -> Check:
+    """Add a check to every run; a check registered again under its name replaces the earlier one"""
+    _checks[check.name] = check
+    return check
+
+
+def checks() -> List[Check]:
+    return list(_checks.values())
+
+
+def _plain(rows: List[Dict[str, Any]]) -> List[Dict[str, Any]]:
+    return json.loads(json.dumps([dict(row) for row in rows], default=str))
+
+
+def run_check(cursor, check: Check, repair: bool = False) -> Dict[str, Any]:
+    """Count a check's violations, with a sample, and repair them if asked and it can"""
+    result = {'name': check.name, 'description': check.description, 'repairable': check.repair is not None}
+    # A check that fails is reported as failed and doesn't stop the others
+    cursor.execute("SAVEPOINT consistency_check")
+    try:
+        cursor.execute(f"SELECT COUNT(*) AS total FROM ({check.query}) violations")
+        result['violations'] = cursor.fetchone()['total']
+        cursor.execute(f"SELECT * FROM ({check.query}) violations LIMIT %s", (SAMPLE_SIZE,))
+        result['sample'] = _plain(cursor.fetchall())
+        result['repaired'] = 0
+        if repair and check.repair and result['violations']:
+            cursor.execute(f"WITH violations AS ({check.query}) {check.repair}")
+            result['repaired'] = cursor.rowcount
+        cursor.execute("RELEASE SAVEPOINT consistency_check")
+    except Exception as e:
+        cursor.execute("ROLLBACK TO SAVEPOINT consistency_check")
+        logger.error(f"Consistency check {check.name} failed: {e}")
+        result['error'] = str(e)[:500]
+    return result
+
+
+def run(trigger: str = 'scheduled', repair: Optional[bool] = None, names: Optional[List[str]] = None,
+        requested_by: Optional[str] = None) -> Optional[Dict[str, Any]]:
+    """Run the checks (all, or those named) and store the report; None if a run is already going"""
+    repair = AUTO_REPAIR if repair is None else repair
+    selected = [check for check in checks() if not names or check.name in names]
+
+    lock_key = 'consistency_check_lock'
+    if not get_redis().set(lock_key, 1, nx=True, ex=LOCK_SECONDS):
+        logger.info("Consistency check skipped: another run is in progress")
+        return None
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute("""
+                INSERT INTO consistency_reports (trigger, repair, requested_by)
+                VALUES (%s, %s, %s)
+                RETURNING id
+            """, (trigger, repair, requested_by))
+            report_id = cursor.fetchone()['id']
+
+        results = []
+        for check in selected:
+            # Each check commits on its own, so a repair isn't held back by a slow check after it
+            with get_postgres_cursor() as cursor:
+                results.append(run_check(cursor, check, repair))
+
+        with get_postgres_cursor() as cursor:
+            cursor.execute("""
+                UPDATE consistency_reports
+                SET status = %s, results = %s::jsonb, violations = %s, repaired = %s, finished_at = NOW()
+                WHERE id = %s
+                RETURNING *
+            """, (
+                'failed' if any('error' in result for result in results) else 'completed',
+                json.dumps(results), sum(result.get('violations', 0) for result in results),
+                sum(result.get('repaired', 0) for result in results), report_id,
+            ))
+            report = dict(cursor.fetchone())
+    finally:
+        get_redis().delete(lock_key)
+
+    logger.info(f"Consistency check {report['id']}: {report['violations']} violations, {report['repaired']} repaired")
+    return report
+
+
+def list_reports(cursor, limit: int = 20) -> List[Dict[str, Any]]:
+    cursor.execute("""
+        SELECT id, trigger, repair, requested_by, status, violations, repaired, started_at, finished_at
+        FROM consistency_reports
+        ORDER BY started_at DESC
+        LIMIT %s
+    """, (limit,))
+    return [dict(row) for row in cursor.fetchall()]
+
+
+def get_report(cursor, report_id: str) -> Optional[Dict[str, Any]]:
+    cursor.execute("SELECT * FROM consistency_reports WHERE id = %s", (report_id,))
+    report = cursor.fetchone()
+    return dict(report) if report else None
+
+
+# Counters, recounted from the rows they count
+register_check(Check(
+    'comment_count', "Articles whose comment_count differs from their number of approved comments",
+    # Shadow-banned users' comments aren't counted (shared/visibility.py)
+    """
+        SELECT a.id AS subject_id, a.comment_count AS recorded, COALESCE(c.actual, 0) AS actual
+        FROM articles a
+        LEFT JOIN (
+            SELECT c.article_id, COUNT(*) AS actual
+            FROM comments c
+            JOIN users u ON u.id = c.user_id
+            WHERE c.moderation_status = 'approved' AND u.shadow_banned_at IS NULL
+            GROUP BY c.article_id
+        ) c ON c.article_id = a.id
+        WHERE a.comment_count IS DISTINCT FROM COALESCE(c.actual, 0)
+    """,
+    "UPDATE articles a SET comment_count = v.actual FROM violations v WHERE a.id = v.subject_id",
+))
+
+register_check(Check(
+    'clap_count', "Articles whose clap_count differs from the claps readers sent",
+    """
+        SELECT a.id AS subject_id, a.clap_count AS recorded, COALESCE(c.actual, 0) AS actual
+        FROM articles a
+        LEFT JOIN (SELECT article_id, SUM(clap_count) AS actual FROM article_claps GROUP BY article_id) c
+            ON c.article_id = a.id
+        WHERE a.clap_count IS DISTINCT FROM COALESCE(c.actual, 0)
+    """,
+    "UPDATE articles a SET clap_count = v.actual FROM violations v WHERE a.id = v.subject_id",
+))
+
+register_check(Check(
+    'reaction_counts', "Articles whose reaction_counts differ from their readers' reactions",
+    """
+        SELECT a.id AS subject_id, a.reaction_counts AS recorded, COALESCE(r.actual, '{}'::jsonb) AS actual
+        FROM articles a
+        LEFT JOIN (
+            SELECT article_id, jsonb_object_agg(reaction, total) AS actual
+            FROM (
+                SELECT article_id, reaction, COUNT(*) AS total FROM user_interactions
+                WHERE interaction_type = 'reaction' AND reaction IS NOT NULL
+                GROUP BY article_id, reaction
+            ) counted
+            GROUP BY article_id
+        ) r ON r.article_id = a.id
+        -- Reactions everyone took back stay behind as zeros
+        WHERE (
+            SELECT COALESCE(jsonb_object_agg(key, value), '{}'::jsonb)
+            FROM jsonb_each(COALESCE(a.reaction_counts, '{}'::jsonb)) WHERE value != '0'::jsonb
+        ) != COALESCE(r.actual, '{}'::jsonb)
+    """,
+    "UPDATE articles a SET reaction_counts = v.actual FROM violations v WHERE a.id = v.subject_id",
+))
+
+register_check(Check(
+    'negative_counters', "Articles with a negative view, like, comment, share or clap count",
+    """
+        SELECT id AS subject_id, view_count, like_count, comment_count, share_count, clap_count
+        FROM articles
+        WHERE LEAST(view_count, like_count, comment_count, share_count, clap_count) < 0
+    """,
+    """
+        UPDATE articles a SET view_count = GREATEST(a.view_count, 0), like_count = GREATEST(a.like_count, 0),
+            comment_count = GREATEST(a.comment_count, 0), share_count = GREATEST(a.share_count, 0),
+            clap_count = GREATEST(a.clap_count, 0)
+        FROM violations v WHERE a.id = v.subject_id
+    """,
+))
+
+# Leftovers of account purges (shared/account_deletion.py), which delete them
+register_check(Check(
+    'orphaned_interactions', "Reading history and interactions of deleted accounts",
+    """
+        SELECT i.id AS subject_id, i.user_id, i.article_id, i.interaction_type
+        FROM user_interactions i
+        JOIN users u ON u.id = i.user_id
+        WHERE u.deleted_at IS NOT NULL
+    """,
+    "DELETE FROM user_interactions i USING violations v WHERE i.id = v.subject_id",
+))
+
+register_check(Check(
+    'orphaned_bookmarks', "Saved articles of deleted accounts",
+    """
+        SELECT s.id AS subject_id, s.user_id, s.article_id
+        FROM saved_articles s
+        JOIN users u ON u.id = s.user_id
+        WHERE u.deleted_at IS NOT NULL
+    """,
+    "DELETE FROM saved_articles s USING violations v WHERE s.id = v.subject_id",
+))
+
+register_check(Check(
+    'orphaned_follows', "Follows from or of deleted accounts",
+    """
+        SELECT f.id AS subject_id, f.follower_id, f.following_id
+        FROM user_follows f
+        JOIN users u ON u.id IN (f.follower_id, f.following_id)
+        WHERE u.deleted_at IS NOT NULL
+    """,
+    "DELETE FROM user_follows f USING violations v WHERE f.id = v.subject_id",
+))
+
+# Only published work gets the byline dropped, as a purge would; drafts are left for an administrator
+register_check(Check(
+    'articles_of_deleted_authors', "Articles with a byline whose author was deleted or no longer exists",
+    """
+        SELECT a.id AS subject_id, a.status, a.author_id,
+               CASE WHEN u.id IS NULL THEN 'author missing' ELSE 'author deleted' END AS problem
+        FROM articles a
+        LEFT JOIN users u ON u.id = a.author_id
+        WHERE NOT COALESCE(a.anonymous_author, false) AND (u.id IS NULL OR u.deleted_at IS NOT NULL)
+    """,
+    """
+        UPDATE articles a SET anonymous_author = true
+        FROM violations v WHERE a.id = v.subject_id AND a.status != 'draft'
+    """,
+))
@@ -0,0 +1,166 @@
+"""
+Article content pipeline
+
+Articles are written as HTML or Markdown (`content_format`). The source is
+what is stored and what authors edit; readers get `content_html`, rendered
+from it on read:
+
+    source -> Markdown to HTML (markdown articles) -> sanitized HTML
+
+Sanitizing uses an allowlist (nh3): only formatting, links, images, lists,
+tables and code are kept, links get rel="noopener noreferrer", and only
+http(s) and mailto URLs survive. Renderings are cached in Redis by a hash of
+the source for CONTENT_RENDER_CACHE_TTL_SECONDS, so unchanged articles are
+rendered once however many times they are read.
+
+Content that tries to run script - <script>, <iframe>, <object> or <embed>
+elements, on* event handler attributes, or javascript:, vbscript: and
+data:text/html URLs, whether written as HTML or as Markdown links - is
+rejected on create and update with UnsafeContent rather than quietly
+stripped, so authors see what was wrong. Word count and reading time are
+computed from the rendered text, not the markup.
+"""
+
+import os
+import re
+import hashlib
+import logging
+from html import escape, unescape
+from html.parser import HTMLParser
+from typing import Any, Dict, List
+
+import markdown
+import nh3
+
+from shared.database import get_redis
+from shared.utils import calculate_reading_time, calculate_word_count
+
+logger = logging.getLogger(__name__)
+
+HTML = 'html'
+MARKDOWN = 'markdown'
+FORMATS = (HTML, MARKDOWN)
+
+RENDER_CACHE_TTL_SECONDS = int(os.getenv('CONTENT_RENDER_CACHE_TTL_SECONDS', 24 * 60 * 60))
+MARKDOWN_EXTENSIONS = ['extra', 'sane_lists']
+
+ALLOWED_TAGS = {
+    'a', 'abbr', 'b', 'blockquote', 'br', 'caption', 'cite', 'code', 'dd', 'del', 'dl', 'dt', 'em',
+    'figcaption', 'figure', 'h1', 'h2', 'h3', 'h4', 'h5', 'h6', 'hr', 'i', 'img', 'ins', 'kbd', 'li',
+    'mark', 'ol', 'p', 'pre', 'q', 's', 'small', 'span', 'strong', 'sub', 'sup', 'table', 'tbody',
+    'td', 'tfoot', 'th', 'thead', 'tr', 'u', 'ul',
+}
+ALLOWED_ATTRIBUTES = {
+    'a': {'href', 'title'},
+    'abbr': {'title'},
+    'code': {'class'},  # language-* from fenced code blocks, for highlighting
+    'img': {'src', 'alt', 'title', 'width', 'height'},
+    'ol': {'start'},
+    'td': {'colspan', 'rowspan', 'align'},
+    'th': {'colspan', 'rowspan', 'align', 'scope'},
+}
+URL_SCHEMES = {'http', 'https', 'mailto'}
+
+SCRIPT_TAGS = {'script', 'iframe', 'object', 'embed', 'frame', 'frameset', 'applet'}
+URL_ATTRIBUTES = {'href', 'src', 'action', 'formaction', 'data', 'xlink:href', 'poster', 'background'}
+SCRIPT_URL = re.compile(r'^(?:javascript|vbscript|data:text/html)', re.IGNORECASE)
+# Browsers ignore whitespace and control characters inside a URL scheme
+URL_NOISE = re.compile(r'[\x00-\x20]+')
+
+
+class UnsafeContent(ValueError):
+    """Content that tries to run script"""
+    pass
+
+
+class _ScriptFinder(HTMLParser):
+    def __init__(self):
+        super().__init__(convert_charrefs=True)
+        self.problems: List[str] = []
+
+    def handle_starttag(self, tag, attrs):
+        if tag in SCRIPT_TAGS:
+            self.problems.append(f"<{tag}> elements are not allowed")
+        for name, value in attrs:
+            if name.startswith('on'):
+                self.problems.append(f"event handler attributes ({name}) are not allowed")
+            elif name in URL_ATTRIBUTES and value and SCRIPT_URL.match(URL_NOISE.sub('', unescape(value))):
+                self.problems.append(f"script URLs are not allowed ({name} on <{tag}>)")
+
+    handle_startendtag = handle_starttag
+
+
+def to_html(source: str, content_format: str = HTML) -> str:
+    """The source as HTML, before sanitizing"""
+    if content_format == MARKDOWN:
+        return markdown.markdown(source or '', extensions=MARKDOWN_EXTENSIONS, output_format='html')
+    return source or ''
+
+
+def sanitize(html: str) -> str:
+    """HTML with everything outside the allowlist removed"""
+    return nh3.clean(
+        html or '', tags=ALLOWED_TAGS, attributes=ALLOWED_ATTRIBUTES, url_schemes=URL_SCHEMES,
+        link_rel='noopener noreferrer', strip_comments=True,
+    )
+
+
+def check(source: str, content_format: str = HTML):
+    """Raise UnsafeContent when the content tries to run script"""
+    finder = _ScriptFinder()
+    finder.feed(to_html(source, content_format))
+    finder.close()
+    if finder.problems:
+        raise UnsafeContent("; ".join(dict.fromkeys(finder.problems)))
+
+
+def text_of(html: str) -> str:
+    return unescape(re.sub(r'<[^>]+>', ' ', html or ''))
+
+
+def prepare(source: str, content_format: str = HTML, strict: bool = True) -> Dict[str, Any]:
+    """What to store for submitted content: the source to keep, its word count and reading time
+
+    HTML is stored sanitized; Markdown is stored as written, since
+    sanitizing would escape its syntax, and is sanitized when rendered.
+    `strict=False` strips script instead of rejecting it, for text saved
+    with nobody to answer, like collaborative drafts.
+    """
+    if content_format not in FORMATS:
+        raise ValueError(f"Unknown content format: {content_format}")
+    if strict:
+        check(source, content_format)
+    stored = sanitize(source) if content_format == HTML else source
+    text = text_of(render(stored, content_format))
+    return {
+        'content': stored,
+        'word_count': calculate_word_count(text),
+        'reading_time': calculate_reading_time(text),
+    }
+
+
+def _cache_key(source: str, content_format: str) -> str:
+    return f"content_html:{content_format}:{hashlib.sha256((source or '').encode()).hexdigest()}"
+
+
+def render(source: str, content_format: str = HTML) -> str:
+    """Safe HTML for the content, from the render cache when it was rendered before"""
+    key = _cache_key(source, content_format)
+    try:
+        cached = get_redis().get(key)
+        if cached:
+            return cached.decode() if isinstance(cached, bytes) else cached
+    except Exception as e:
+        logger.warning(f"Content render cache read failed: {e}")
+
+    html = sanitize(to_html(source, content_format))
+    try:
+        get_redis().setex(key, RENDER_CACHE_TTL_SECONDS, html)
+    except Exception as e:
+        logger.warning(f"Content render cache write failed: {e}")
+    return html
+
+
+def preview_html(text: str) -> str:
+    """Plain preview text (a summary or the first words) as HTML"""
+    return f"<p>{escape(text)}</p>" if text else ''
@@ -0,0 +1,171 @@
+"""
+Author signatures on articles
+
+Authors register Ed25519 or secp256k1 public keys and sign their articles
+with the private key, which never reaches the server. What is signed is the
+article's canonical payload: compact JSON with sorted keys of the version,
+title, summary and content as stored (content after HTML sanitizing, so
+authors should sign the payload the server returns for their article). The
+server checks the signature before storing it; anyone can fetch the payload,
+signature and public key and check them again without trusting the server.
+
+Ed25519 signatures are the 64-byte signature of the payload. secp256k1
+signatures are ECDSA over the SHA-256 of the payload, either DER-encoded or
+as 64 bytes of r and s, as Ethereum and Bitcoin wallets produce.
+
+Signatures on anonymously published articles are served without the author,
+so the key fingerprint works as a pseudonym: readers can tell that two
+anonymous articles came from the same key but not whose key it is.
+"""
+
+import json
+import base64
+import binascii
+import hashlib
+import logging
+from typing import Any, Dict, Optional
+
+from cryptography.exceptions import InvalidSignature, UnsupportedAlgorithm
+from cryptography.hazmat.primitives import hashes
+from cryptography.hazmat.primitives.asymmetric import ec
+from cryptography.hazmat.primitives.asymmetric.ed25519 import Ed25519PublicKey
+from cryptography.hazmat.primitives.asymmetric.utils import encode_dss_signature
+
+logger = logging.getLogger(__name__)
+
+PAYLOAD_VERSION = 1
+ALGORITHMS = ('ed25519', 'secp256k1')
+
+VALID = 'valid'
+OUTDATED = 'outdated'  # The article changed after it was signed
+
+
+class SignatureInvalid(Exception):
+    """Raised when a key or signature is malformed or doesn't verify"""
+
+
+def decode(value: str) -> bytes:
+    """Bytes from base64 (standard or URL-safe) or 0x-prefixed hex"""
+    value = (value or '').strip()
+    try:
+        if value.startswith('0x'):
+            return bytes.fromhex(value[2:])
+        return base64.urlsafe_b64decode(value.replace('+', '-').replace('/', '_') + '=' * (-len(value) % 4))
+    except (ValueError, binascii.Error):
+        raise SignatureInvalid("Not valid base64 or 0x-prefixed hex")
+
+
+def load_public_key(algorithm: str, key_bytes: bytes):
+    try:
+        if algorithm == 'ed25519':
+            return Ed25519PublicKey.from_public_bytes(key_bytes)
+        if algorithm == 'secp256k1':
+            return ec.EllipticCurvePublicKey.from_encoded_point(ec.SECP256K1(), key_bytes)
+    except (ValueError, UnsupportedAlgorithm) as e:
+        raise SignatureInvalid(f"Not a valid {algorithm} public key: {e}")
+    raise SignatureInvalid(f"Unsupported algorithm {algorithm}")
+
+
+def fingerprint(key_bytes: bytes) -> str:
+    return hashlib.sha256(key_bytes).hexdigest()
+
+
+def register_key(cursor, user_id: str, algorithm: str, public_key: str, label: Optional[str] = None) -> Dict[str, Any]:
+    """Check and store a public key for the user"""
+    key_bytes = decode(public_key)
+    load_public_key(algorithm, key_bytes)
+    cursor.execute("""
+        INSERT INTO author_keys (user_id, algorithm, public_key, fingerprint, label)
+        VALUES (%s, %s, %s, %s, %s)
+        RETURNING id, algorithm, public_key, fingerprint, label, created_at, revoked_at
+    """, (user_id, algorithm, base64.b64encode(key_bytes).decode(), fingerprint(key_bytes), label))
+    return dict(cursor.fetchone())
+
+
+def payload(article: Dict[str, Any]) -> str:
+    """The exact text an author signs for an article"""
+    return json.dumps({
+        'v': PAYLOAD_VERSION,
+        'title': article['title'],
+        'summary': article.get('summary') or '',
+        'content': article['content'],
+    }, sort_keys=True, separators=(',', ':'), ensure_ascii=False)
+
+
+def payload_digest(article: Dict[str, Any]) -> str:
+    return hashlib.sha256(payload(article).encode()).hexdigest()
+
+
+def verify(algorithm: str, key_bytes: bytes, message: bytes, signature: bytes):
+    public_key = load_public_key(algorithm, key_bytes)
+    try:
+        if algorithm == 'ed25519':
+            public_key.verify(signature, message)
+            return
+        if len(signature) == 64:
+            signature = encode_dss_signature(
+                int.from_bytes(signature[:32], 'big'), int.from_bytes(signature[32:], 'big')
+            )
+        public_key.verify(signature, message, ec.ECDSA(hashes.SHA256()))
+    except (InvalidSignature, ValueError):
+        raise SignatureInvalid("The signature does not match the article and key")
+
+
+def sign_article(cursor, article: Dict[str, Any], user_id: str, key_id: str, signature: str) -> Dict[str, Any]:
+    """Verify the author's signature over the article as stored and keep it, replacing any earlier one"""
+    cursor.execute(
+        "SELECT * FROM author_keys WHERE id = %s AND user_id = %s AND revoked_at IS NULL", (key_id, user_id)
+    )
+    key = cursor.fetchone()
+    if not key:
+        raise SignatureInvalid("Unknown or revoked key")
+
+    signature_bytes = decode(signature)
+    verify(key['algorithm'], base64.b64decode(key['public_key']), payload(article).encode(), signature_bytes)
+    cursor.execute("""
+        INSERT INTO article_signatures (article_id, key_id, signature, payload_sha256, signed_at)
+        VALUES (%s, %s, %s, %s, NOW())
+        ON CONFLICT (article_id) DO UPDATE
+        SET key_id = EXCLUDED.key_id, signature = EXCLUDED.signature,
+            payload_sha256 = EXCLUDED.payload_sha256, signed_at = NOW()
+        RETURNING *
+    """, (article['id'], key_id, base64.b64encode(signature_bytes).decode(), payload_digest(article)))
+    logger.info(f"Article {article['id']} signed with key {key['fingerprint']}")
+    return dict(cursor.fetchone())
+
+
+def signature_document(cursor, article: Dict[str, Any]) -> Optional[Dict[str, Any]]:
+    """Everything a reader needs to verify the article's signature, or None if it isn't signed
+
+    The author is left out of anonymously published articles.
+    """
+    cursor.execute("""
+        SELECT s.signature, s.payload_sha256, s.signed_at, k.algorithm, k.public_key, k.fingerprint,
+               k.revoked_at, u.username
+        FROM article_signatures s
+        JOIN author_keys k ON s.key_id = k.id
+        JOIN users u ON k.user_id = u.id
+        WHERE s.article_id = %s
+    """, (article['id'],))
+    signed = cursor.fetchone()
+    if not signed:
+        return None
+
+    current = payload_digest(article)
+    anonymous = article.get('anonymous_author')
+    return {
+        'article_id': str(article['id']),
+        'status': VALID if signed['payload_sha256'] == current else OUTDATED,
+        'algorithm': signed['algorithm'],
+        'public_key': signed['public_key'],
+        'fingerprint': signed['fingerprint'],
+        'key_revoked': signed['revoked_at'] is not None,
+        'signature': signed['signature'],
+        'signed_at': signed['signed_at'],
+        'author': None if anonymous else signed['username'],
+        'payload_version': PAYLOAD_VERSION,
+        # The current payload; when outdated, the signature covers an earlier version of the article
+        'payload': payload(article),
+        'payload_sha256': current,
+        'signed_payload_sha256': signed['payload_sha256'],
+    }
@@ -0,0 +1,118 @@
+"""
+Reader-submitted corrections and article revisions
+
+Readers point at a passage and propose replacement text. The article's author
+(or an administrator) accepts the suggestion, which rewrites the passage and
+records a new revision, or dismisses it. Accepted corrections earn the
+contributor reputation.
+"""
+
+import logging
+from typing import Any, Dict, Optional
+
+from shared import article_cache
+from shared.content import prepare
+from shared.events import article_corrected
+from shared.readability import store_readability
+from shared import reputation
+
+logger = logging.getLogger(__name__)
+
+MAX_PENDING_PER_ARTICLE = 5
+
+
+class CorrectionConflict(Exception):
+    """Raised when the corrected passage no longer appears in the article"""
+
+
+def record_revision(cursor, article: Dict[str, Any], edited_by: Optional[str],
+                    change_note: Optional[str] = None, correction_id: Optional[str] = None,
+                    previous: Optional[Dict[str, Any]] = None) -> Dict[str, Any]:
+    """Store the current state of an article as its next revision
+
+    The first time an article is revised, `previous` (the state before the
+    edit) is stored as revision 1 so the original text is never lost.
+    """
+    cursor.execute(
+        "SELECT COALESCE(MAX(revision_number), 0) AS latest FROM article_revisions WHERE article_id = %s",
+        (article['id'],)
+    )
+    latest = cursor.fetchone()['latest']
+
+    if latest == 0 and previous:
+        cursor.execute("""
+            INSERT INTO article_revisions (article_id, revision_number, title, summary, content, edited_by, change_note)
+            VALUES (%s, 1, %s, %s, %s, %s, 'Original version')
+        """, (article['id'], previous['title'], previous.get('summary'), previous['content'], previous.get('author_id')))
+        latest = 1
+
+    cursor.execute("""
+        INSERT INTO article_revisions
+        (article_id, revision_number, title, summary, content, edited_by, change_note, correction_id)
+        VALUES (%s, %s, %s, %s, %s, %s, %s, %s)
+        RETURNING *
+    """, (
+        article['id'], latest + 1, article['title'], article.get('summary'), article['content'],
+        edited_by, change_note, correction_id
+    ))
+    article_cache.invalidate(str(article['id']))
+    return cursor.fetchone()
+
+
+def apply_correction(cursor, correction: Dict[str, Any], reviewer_id: str,
+                     replacement: Optional[str] = None, review_note: Optional[str] = None) -> Dict[str, Any]:
+    """Rewrite the corrected passage, record a revision and credit the contributor
+
+    `replacement` lets the reviewer adjust the suggested text before accepting.
+    Returns the updated article.
+    """
+    cursor.execute("SELECT * FROM articles WHERE id = %s FOR UPDATE", (correction['article_id'],))
+    article = dict(cursor.fetchone())
+
+    passage = correction['passage']
+    if passage not in article['content']:
+        raise CorrectionConflict("The passage no longer appears in the article")
+
+    new_text = replacement if replacement is not None else correction['suggestion']
+    prepared = prepare(article['content'].replace(passage, new_text, 1), article['content_format'])
+    content = prepared['content']
+
+    cursor.execute("""
+        UPDATE articles
+        SET content = %s, reading_time = %s, word_count = %s, updated_at = NOW()
+        WHERE id = %s
+        RETURNING *
+    """, (content, prepared['reading_time'], prepared['word_count'], article['id']))
+    updated = dict(cursor.fetchone())
+    updated['readability'] = store_readability(cursor, article['id'], content, updated['language'])
+    updated['reading_level'] = updated['readability']['reading_level']
+
+    revision = record_revision(
+        cursor, updated, reviewer_id,
+        change_note=review_note or f"Accepted {correction['kind']} correction",
+        correction_id=str(correction['id']),
+        previous=article
+    )
+
+    cursor.execute("""
+        UPDATE article_corrections
+        SET status = 'accepted', reviewed_by = %s, reviewed_at = NOW(), review_note = %s, revision_id = %s
+        WHERE id = %s
+    """, (reviewer_id, review_note, revision['id'], correction['id']))
+
+    if correction.get('submitted_by'):
+        credit_contributor(cursor, str(correction['submitted_by']), correction['id'])
+
+    article_corrected(
+        cursor, article['id'], correction['id'], revision['revision_number'],
+        str(correction['submitted_by']) if correction.get('submitted_by') else None
+    )
+    return updated
+
+
+def credit_contributor(cursor, user_id: str, correction_id: str):
+    """Count an accepted correction toward the contributor's reputation"""
+    cursor.execute(
+        "UPDATE users SET accepted_corrections = COALESCE(accepted_corrections, 0) + 1 WHERE id = %s", (user_id,)
+    )
+    reputation.record(cursor, user_id, reputation.FACT_VERIFIED, str(correction_id))
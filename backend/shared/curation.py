@@ -0,0 +1,65 @@
+"""
+Editorial curation helpers shared by feed and curation routes
+
+Pins are only active inside their [starts_at, ends_at) window, so expiry is
+automatic: nothing needs to run for an expired pin to disappear from feeds.
+"""
+
+import logging
+from typing import List, Optional
+
+from shared.models import ArticleResponse, PinnedArticle
+
+logger = logging.getLogger(__name__)
+
+ACTIVE_PIN_CONDITION = "p.starts_at <= NOW() AND (p.ends_at IS NULL OR p.ends_at > NOW())"
+
+
+def get_active_pins(cursor, scope: str, category: Optional[str] = None) -> List[PinnedArticle]:
+    """Get currently active pins for the home feed or a category page"""
+    query = f"""
+        SELECT p.id as pin_id, p.pin_type, p.position, p.ends_at, a.*
+        FROM article_pins p
+        JOIN articles a ON a.id = p.article_id
+        WHERE p.scope = %s AND a.status = 'published' AND {ACTIVE_PIN_CONDITION}
+    """
+    params = [scope]
+
+    if category:
+        query += " AND p.category = %s"
+        params.append(category)
+
+    query += " ORDER BY p.position ASC, p.starts_at DESC"
+    cursor.execute(query, params)
+
+    pinned = []
+    seen = set()
+    for row in cursor.fetchall():
+        row = dict(row)
+        # An article pinned twice in the same scope is shown once, at its best position
+        if row['id'] in seen:
+            continue
+        seen.add(row['id'])
+
+        pin_id = row.pop('pin_id')
+        pin_type = row.pop('pin_type')
+        position = row.pop('position')
+        ends_at = row.pop('ends_at')
+        pinned.append(PinnedArticle(
+            pin_id=pin_id,
+            pin_type=pin_type,
+            position=position,
+            ends_at=ends_at,
+            article=ArticleResponse(**row)
+        ))
+
+    return pinned
+
+
+def purge_expired_pins(cursor, older_than_days: int = 30) -> int:
+    """Delete pins that expired more than the given number of days ago"""
+    cursor.execute(
+        "DELETE FROM article_pins WHERE ends_at IS NOT NULL AND ends_at < NOW() - make_interval(days => %s)",
+        (older_than_days,)
+    )
+    return cursor.rowcount
@@ -3,6 +3,7 @@ Shared database connection utilities for both Flask and FastAPI backends
 """
 
 import os
+import time
 import psycopg2
 from psycopg2.extras import RealDictCursor, Json
 import psycopg2.extras
@@ -13,11 +14,16 @@ from typing import Generator, Optional, Dict, Any
 import logging
 import json
 
+from shared.request_context import current_request, effective_timeout_ms
+
 logger = logging.getLogger(__name__)
 
 # Register JSON adapter for PostgreSQL
 psycopg2.extras.register_default_json(globally=True)
 psycopg2.extras.register_default_jsonb(globally=True)
+# Dicts bound as query parameters are written as JSON, so JSONB columns
+# (profile_data, preferences, metadata, context_data) take plain dicts
+psycopg2.extensions.register_adapter(dict, Json)
 
 
 class DatabaseManager:
@@ -31,6 +37,14 @@ class DatabaseManager:
             'user': os.getenv('POSTGRES_USER', 'postgres'),
             'password': os.getenv('POSTGRES_PASSWORD', 'password'),
         }
+        # Default per-query limit; blocks can override it with get_postgres_cursor(timeout_ms=...)
+        self.statement_timeout_ms = int(os.getenv('POSTGRES_STATEMENT_TIMEOUT_MS', 15000))
+        self.lock_timeout_ms = int(os.getenv('POSTGRES_LOCK_TIMEOUT_MS', 5000))
+        self.query_timeouts = {
+            'analytics': int(os.getenv('POSTGRES_ANALYTICS_TIMEOUT_MS', 60000)),
+            'recommendations': int(os.getenv('POSTGRES_RECOMMENDATIONS_TIMEOUT_MS', 60000)),
+            'search': int(os.getenv('POSTGRES_SEARCH_TIMEOUT_MS', 10000)),
+        }
         
         self.mongodb_config = {
             'host': os.getenv('MONGODB_HOST', 'localhost'),
@@ -50,22 +64,56 @@ class DatabaseManager:
             'socket_connect_timeout': 5,
             'retry_on_timeout': True
         }
+        # Sandbox requests (shared/sandbox.py) get their own Redis database
+        self.sandbox_redis_db = int(os.getenv('SANDBOX_REDIS_DB', 2))
+        
+        # Startup waits: backoff between connection attempts and how long to wait for each dependency
+        self.startup_retry = {
+            'initial_delay': float(os.getenv('STARTUP_RETRY_INITIAL_DELAY_SECONDS', 1)),
+            'max_delay': float(os.getenv('STARTUP_RETRY_MAX_DELAY_SECONDS', 15)),
+        }
+        default_wait = float(os.getenv('STARTUP_MAX_WAIT_SECONDS', 60))
+        self.startup_max_wait = {
+            'postgresql': float(os.getenv('POSTGRES_STARTUP_MAX_WAIT_SECONDS', default_wait)),
+            'mongodb': float(os.getenv('MONGODB_STARTUP_MAX_WAIT_SECONDS', default_wait)),
+            'redis': float(os.getenv('REDIS_STARTUP_MAX_WAIT_SECONDS', default_wait)),
+        }
         
         # Initialize connections
         self._postgres_pool = None
         self._mongodb_client = None
         self._redis_client = None
+        self._sandbox_redis_client = None
     
     @contextmanager
-    def get_postgres_connection(self) -> Generator[psycopg2.extensions.connection, None, None]:
-        """Get PostgreSQL connection with automatic cleanup"""
+    def get_postgres_connection(self, timeout_ms: Optional[int] = None) -> Generator[psycopg2.extensions.connection, None, None]:
+        """Get PostgreSQL connection with automatic cleanup
+
+        Queries are limited to `timeout_ms` (default POSTGRES_STATEMENT_TIMEOUT_MS,
+        0 for no limit) and never outlive the current request's deadline.
+        Connections made for sandbox requests see the sandbox schema, and
+        those made for a publication's requests insert rows into it.
+        """
+        from shared import sandbox, tenancy
+
         conn = None
+        request = current_request()
         try:
+            statement_timeout = effective_timeout_ms(
+                self.statement_timeout_ms if timeout_ms is None else timeout_ms
+            )
             conn = psycopg2.connect(**self.postgres_config)
             conn.autocommit = False
-            # Set session timezone
+            # Set session timezone and query limits
             with conn.cursor() as cursor:
                 cursor.execute("SET timezone = 'UTC'")
+                cursor.execute("SET statement_timeout = %s", (statement_timeout or 0,))
+                cursor.execute("SET lock_timeout = %s", (self.lock_timeout_ms,))
+                if sandbox.active():
+                    sandbox.use_schema(cursor)
+                tenancy.use_tenant(cursor)
+            if request:
+                request.register(conn)
             yield conn
         except psycopg2.Error as e:
             if conn:
@@ -84,13 +132,15 @@ class DatabaseManager:
             logger.error(f"PostgreSQL connection error: {e}")
             raise
         finally:
+            if request and conn:
+                request.unregister(conn)
             if conn and not conn.closed:
                 conn.close()
     
     @contextmanager
-    def get_postgres_cursor(self) -> Generator[RealDictCursor, None, None]:
+    def get_postgres_cursor(self, timeout_ms: Optional[int] = None) -> Generator[RealDictCursor, None, None]:
         """Get PostgreSQL cursor with automatic cleanup"""
-        with self.get_postgres_connection() as conn:
+        with self.get_postgres_connection(timeout_ms) as conn:
             cursor = None
             try:
                 cursor = conn.cursor(cursor_factory=RealDictCursor)
@@ -211,12 +261,21 @@ class DatabaseManager:
         return self._mongodb_client
     
     def get_mongodb_database(self):
-        """Get MongoDB database"""
+        """Get MongoDB database; the sandbox's for sandbox requests"""
+        from shared import sandbox
+
         client = self.get_mongodb_client()
-        return client[self.mongodb_config['database']]
+        return client[sandbox.mongodb_name() if sandbox.active() else self.mongodb_config['database']]
     
     def get_redis_client(self) -> redis.Redis:
-        """Get Redis client (singleton pattern)"""
+        """Get Redis client (singleton pattern); the sandbox's database for sandbox requests"""
+        from shared import sandbox
+
+        if sandbox.active():
+            if self._sandbox_redis_client is None:
+                config = {k: v for k, v in self.redis_config.items() if v is not None}
+                self._sandbox_redis_client = redis.Redis(**{**config, 'db': self.sandbox_redis_db})
+            return self._sandbox_redis_client
         if self._redis_client is None:
             try:
                 # Filter out None password
@@ -268,6 +327,57 @@ class DatabaseManager:
         
         return results
     
+    def _ping_postgres(self):
+        conn = psycopg2.connect(connect_timeout=5, **self.postgres_config)
+        try:
+            with conn.cursor() as cursor:
+                cursor.execute("SELECT 1")
+        finally:
+            conn.close()
+    
+    def _ping_mongodb(self):
+        self.get_mongodb_client().admin.command('ping')
+    
+    def _ping_redis(self):
+        self.get_redis_client().ping()
+    
+    def wait_for_dependency(self, name: str, ping) -> bool:
+        """Retry `ping` with exponential backoff until it succeeds or the dependency's max wait runs out"""
+        max_wait = self.startup_max_wait.get(name, 0)
+        delay = self.startup_retry['initial_delay']
+        deadline = time.monotonic() + max_wait
+        attempt = 1
+        while True:
+            try:
+                ping()
+                if attempt > 1:
+                    logger.info(f"{name} is ready after {attempt} attempts")
+                return True
+            except Exception as e:
+                remaining = deadline - time.monotonic()
+                if remaining <= 0:
+                    logger.error(f"{name} still unavailable after {max_wait:.0f}s ({attempt} attempts): {e}")
+                    return False
+                wait = min(delay, remaining)
+                logger.warning(
+                    f"{name} not ready (attempt {attempt}, {remaining:.0f}s left), retrying in {wait:.1f}s: {e}"
+                )
+                time.sleep(wait)
+                delay = min(delay * 2, self.startup_retry['max_delay'])
+                attempt += 1
+    
+    def wait_for_dependencies(self) -> Dict[str, bool]:
+        """Wait for PostgreSQL, MongoDB and Redis to accept connections
+
+        Each dependency gets its own *_STARTUP_MAX_WAIT_SECONDS budget
+        (STARTUP_MAX_WAIT_SECONDS by default, 0 to try once). Returns which
+        ones came up; the caller decides whether to start without the rest.
+        MongoDB is skipped when no enabled module uses it.
+        """
+        from shared.modules import required_services
+        pings = {'postgresql': self._ping_postgres, 'mongodb': self._ping_mongodb, 'redis': self._ping_redis}
+        return {name: self.wait_for_dependency(name, ping) for name, ping in pings.items() if name in required_services()}
+    
     def close_connections(self):
         """Close all database connections"""
         if self._mongodb_client:
@@ -288,19 +398,31 @@ class DatabaseManager:
             finally:
                 self._redis_client = None
 
+        if self._sandbox_redis_client:
+            try:
+                self._sandbox_redis_client.close()
+            except Exception as e:
+                logger.error(f"Error closing sandbox Redis connection: {e}")
+            finally:
+                self._sandbox_redis_client = None
+
 
 # Global database manager instance
 db_manager = DatabaseManager()
 
 
 # Convenience functions for direct access
-def get_postgres_connection():
+def get_postgres_connection(timeout_ms: Optional[int] = None):
     """Get PostgreSQL connection"""
-    return db_manager.get_postgres_connection()
+    return db_manager.get_postgres_connection(timeout_ms)
 
-def get_postgres_cursor():
+def get_postgres_cursor(timeout_ms: Optional[int] = None):
     """Get PostgreSQL cursor"""
-    return db_manager.get_postgres_cursor()
+    return db_manager.get_postgres_cursor(timeout_ms)
+
+def query_timeout(workload: str) -> int:
+    """Statement timeout (ms) configured for a workload, e.g. 'analytics'"""
+    return db_manager.query_timeouts.get(workload, db_manager.statement_timeout_ms)
 
 def get_mongodb():
     """Get MongoDB database"""
@@ -322,6 +444,10 @@ def test_all_connections():
     """Test all database connections"""
     return db_manager.test_connections()
 
+def wait_for_dependencies():
+    """Block until the databases are reachable or their startup waits run out"""
+    return db_manager.wait_for_dependencies()
+
 
 # Example usage functions
 def create_user_example(username: str, email: str, password_hash: str, 
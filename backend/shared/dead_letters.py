@@ -0,0 +1,355 @@
+"""
+Dead letters for event consumers
+
+An event a consumer can't process shouldn't hold up the events behind it,
+nor vanish. It is quarantined in `event_dead_letters` with the error, for an
+administrator to inspect through /api/v1/admin/dead-letters, then edit and
+retry or discard. The consumers:
+
+    outbox         - the outbox relay: events with a malformed payload, and
+                     events the broker refused EVENT_DEAD_LETTER_MAX_ATTEMPTS
+                     times while taking the event after them. The relay
+                     stops trying them, so the events behind go out
+    clickstream    - interaction events that can't be made into a
+                     ClickHouse row
+    notifications  - events whose notifications fail on their own while the
+                     rest of their batch goes out
+
+A failure every event shares, such as the broker or Redis being down, is an
+outage rather than a poison message: nothing is quarantined and delivery is
+retried as before.
+
+Retrying an `outbox` dead letter puts the event back in the outbox with its
+payload, edited or not, for the relay's next poll. The other consumers get
+the event again at once. A retry that fails leaves the dead letter
+quarantined with the new error.
+
+The `dead_letters` setting holds the alert thresholds. Every
+DEAD_LETTER_CHECK_INTERVAL_SECONDS a job compares the number of events each
+consumer has quarantined with its threshold (`thresholds`, or
+`alert_threshold`). Once it is reached the job logs an error and emails the
+`alert_emails`, and does so again every `alert_cooldown_minutes` while it
+stays there.
+
+More consumers are added with `register_consumer`.
+"""
+
+import os
+import logging
+from dataclasses import dataclass
+from typing import Any, Callable, Dict, List, Optional
+
+from shared.database import get_postgres_cursor, get_redis, prepare_json_data
+from shared.audit import record_privileged
+from shared import event_schemas
+from shared.settings import get_setting
+
+logger = logging.getLogger(__name__)
+
+MAX_ATTEMPTS = int(os.getenv('EVENT_DEAD_LETTER_MAX_ATTEMPTS', 5))
+
+OUTBOX = 'outbox'
+CLICKSTREAM = 'clickstream'
+NOTIFICATIONS = 'notifications'
+
+DEAD_LETTER_COLUMNS = """
+    id, consumer, event_id, event_type, aggregate_id, payload, original_payload, error, status, retry_count,
+    resolved_by, resolution_note, created_at, updated_at, resolved_at
+"""
+
+
+class DeadLetterError(Exception):
+    pass
+
+
+class InvalidPayload(DeadLetterError):
+    pass
+
+
+@dataclass(frozen=True)
+class Consumer:
+    name: str
+    description: str
+    # Hand one event to the consumer again, raising when it still fails
+    deliver: Callable[[Any, Dict[str, Any]], None]
+
+
+_consumers: Dict[str, Consumer] = {}
+
+
+def register_consumer(consumer: Consumer) -> Consumer:
+    _consumers[consumer.name] = consumer
+    return consumer
+
+
+def consumers() -> List[Consumer]:
+    return list(_consumers.values())
+
+
+def _deliver_outbox(cursor, event: Dict[str, Any]):
+    cursor.execute("""
+        UPDATE event_outbox
+        SET payload = %s, dead_lettered_at = NULL, claimed_until = NULL, publish_attempts = 0, last_error = NULL
+        WHERE id = %s
+        RETURNING id
+    """, (prepare_json_data(event['payload']), event['id']))
+    if not cursor.fetchone():
+        raise DeadLetterError("The event is no longer in the outbox")
+
+
+def _deliver_clickstream(cursor, event: Dict[str, Any]):
+    from shared import clickstream
+
+    clickstream.push_rows([clickstream.encode(event)])
+
+
+def _deliver_notifications(cursor, event: Dict[str, Any]):
+    from shared import notifications
+
+    notifications.deliver([event])
+
+
+register_consumer(Consumer(
+    name=OUTBOX,
+    description="The outbox relay; a retried event is put back in the outbox for every consumer",
+    deliver=_deliver_outbox,
+))
+
+register_consumer(Consumer(
+    name=CLICKSTREAM,
+    description="The ClickHouse clickstream sink",
+    deliver=_deliver_clickstream,
+))
+
+register_consumer(Consumer(
+    name=NOTIFICATIONS,
+    description="Real-time and push notifications",
+    deliver=_deliver_notifications,
+))
+
+
+# Quarantine
+def malformed(event: Dict[str, Any]) -> Optional[str]:
+    """Why an outbox event's payload can't be consumed, or None when it's well-formed"""
+    from shared.events import EVENT_TYPES
+
+    payload = event.get('payload')
+    if not isinstance(payload, dict):
+        return "Payload is not an object"
+    missing = [field for field in ('id', 'type', 'data') if field not in payload]
+    if missing:
+        return f"Payload lacks {', '.join(missing)}"
+    if payload['type'] != event['event_type']:
+        return f"Payload type {payload['type']} doesn't match the event type {event['event_type']}"
+    if payload['type'] not in EVENT_TYPES:
+        return f"Unknown event type {payload['type']}"
+    if not isinstance(payload['data'], dict):
+        return "Payload data is not an object"
+    try:
+        version = event_schemas.version_of(payload)
+    except (TypeError, ValueError):
+        return f"Schema version {payload.get('schema_version')!r} is not a number"
+    problems = event_schemas.problems(payload['type'], payload['data'], version)
+    if problems:
+        return f"Data breaks the {payload['type']} v{version} contract: {'; '.join(problems)}"
+    return None
+
+
+def quarantine(consumer: str, event: Dict[str, Any], error: str, cursor=None) -> Optional[str]:
+    """Set an event aside for `consumer`; on the caller's transaction when `cursor` is given"""
+    if cursor is None:
+        try:
+            with get_postgres_cursor() as own_cursor:
+                return quarantine(consumer, event, error, own_cursor)
+        except Exception as e:
+            logger.error(f"Could not quarantine event {event.get('id')} for {consumer}: {e}")
+            return None
+
+    cursor.execute("""
+        INSERT INTO event_dead_letters (consumer, event_id, event_type, aggregate_id, payload, error)
+        VALUES (%s, %s, %s, %s, %s, %s)
+        RETURNING id
+    """, (
+        consumer, str(event['id']), event['event_type'], event.get('aggregate_id'),
+        prepare_json_data(event['payload']), str(error)[:1000]
+    ))
+    dead_letter_id = str(cursor.fetchone()['id'])
+    logger.warning(f"Event {event['id']} ({event['event_type']}) quarantined for {consumer}: {error}")
+    return dead_letter_id
+
+
+# Inspection
+def list_dead_letters(cursor, consumer: Optional[str] = None, status: Optional[str] = 'quarantined',
+                      event_type: Optional[str] = None, limit: int = 50, offset: int = 0) -> List[Dict[str, Any]]:
+    conditions, params = [], []
+    for column, value in (('consumer', consumer), ('status', status), ('event_type', event_type)):
+        if value:
+            conditions.append(f"{column} = %s")
+            params.append(value)
+    where = f"WHERE {' AND '.join(conditions)}" if conditions else ""
+    cursor.execute(f"""
+        SELECT {DEAD_LETTER_COLUMNS} FROM event_dead_letters
+        {where}
+        ORDER BY created_at DESC
+        LIMIT %s OFFSET %s
+    """, params + [limit, offset])
+    return [dict(row) for row in cursor.fetchall()]
+
+
+def get(cursor, dead_letter_id: str, for_update: bool = False) -> Optional[Dict[str, Any]]:
+    cursor.execute(
+        f"SELECT {DEAD_LETTER_COLUMNS} FROM event_dead_letters WHERE id = %s{' FOR UPDATE' if for_update else ''}",
+        (dead_letter_id,)
+    )
+    row = cursor.fetchone()
+    return dict(row) if row else None
+
+
+def _threshold(settings: Dict[str, Any], consumer: str) -> int:
+    return int((settings.get('thresholds') or {}).get(consumer, settings.get('alert_threshold') or 0))
+
+
+def depth(cursor) -> List[Dict[str, Any]]:
+    """Quarantined events per consumer, with the oldest and the alert threshold"""
+    cursor.execute("""
+        SELECT consumer, COUNT(*) AS quarantined, MIN(created_at) AS oldest_at
+        FROM event_dead_letters
+        WHERE status = 'quarantined'
+        GROUP BY consumer
+    """)
+    counts = {row['consumer']: dict(row) for row in cursor.fetchall()}
+    settings = get_setting('dead_letters')
+    stats = []
+    for name in sorted(set(_consumers) | set(counts)):
+        row = counts.get(name) or {'consumer': name, 'quarantined': 0, 'oldest_at': None}
+        threshold = _threshold(settings, name)
+        stats.append({
+            **row, 'threshold': threshold or None,
+            'over_threshold': bool(threshold) and row['quarantined'] >= threshold,
+        })
+    return stats
+
+
+# Resolution
+def _quarantined(cursor, dead_letter_id: str) -> Dict[str, Any]:
+    dead_letter = get(cursor, dead_letter_id, for_update=True)
+    if not dead_letter:
+        raise LookupError(dead_letter_id)
+    if dead_letter['status'] != 'quarantined':
+        raise DeadLetterError(f"The dead letter was already {dead_letter['status']}")
+    return dead_letter
+
+
+def retry(cursor, dead_letter_id: str, actor: Dict[str, Any],
+          payload: Optional[Dict[str, Any]] = None) -> Dict[str, Any]:
+    """Hand a quarantined event to its consumer again, with `payload` in place of its own when given
+
+    Raises LookupError for an unknown dead letter, InvalidPayload for a bad
+    `payload` and DeadLetterError when it can't be retried; a retry that fails
+    comes back quarantined with its error.
+    """
+    dead_letter = _quarantined(cursor, dead_letter_id)
+    consumer = _consumers.get(dead_letter['consumer'])
+    if not consumer:
+        raise DeadLetterError(f"No consumer named {dead_letter['consumer']} is registered")
+
+    before = {'payload': dead_letter['payload'], 'error': dead_letter['error']}
+    if payload is not None:
+        problem = malformed({'event_type': dead_letter['event_type'], 'payload': payload})
+        if problem:
+            raise InvalidPayload(problem)
+        if str(payload['id']) != str(dead_letter['event_id']):
+            raise InvalidPayload("The payload's id must stay the event's id")
+        cursor.execute("""
+            UPDATE event_dead_letters
+            SET original_payload = COALESCE(original_payload, payload), payload = %s
+            WHERE id = %s
+        """, (prepare_json_data(payload), dead_letter_id))
+
+    event = {
+        'id': str(dead_letter['event_id']), 'event_type': dead_letter['event_type'],
+        'aggregate_id': dead_letter['aggregate_id'],
+        'payload': payload if payload is not None else dead_letter['payload'],
+    }
+    cursor.execute("SAVEPOINT dead_letter_retry")
+    try:
+        consumer.deliver(cursor, event)
+        cursor.execute("RELEASE SAVEPOINT dead_letter_retry")
+        error = None
+    except Exception as e:
+        cursor.execute("ROLLBACK TO SAVEPOINT dead_letter_retry")
+        error = str(e)[:1000]
+        logger.warning(f"Retrying dead letter {dead_letter_id} for {consumer.name} failed: {e}")
+
+    if error is None:
+        cursor.execute(f"""
+            UPDATE event_dead_letters
+            SET status = 'retried', retry_count = retry_count + 1, resolved_by = %s, resolved_at = NOW(),
+                updated_at = NOW()
+            WHERE id = %s
+            RETURNING {DEAD_LETTER_COLUMNS}
+        """, (actor['id'], dead_letter_id))
+    else:
+        cursor.execute(f"""
+            UPDATE event_dead_letters SET error = %s, retry_count = retry_count + 1, updated_at = NOW()
+            WHERE id = %s
+            RETURNING {DEAD_LETTER_COLUMNS}
+        """, (error, dead_letter_id))
+    retried = dict(cursor.fetchone())
+    record_privileged(
+        cursor, actor, 'dead_letter_retried' if error is None else 'dead_letter_retry_failed', 'dead_letter',
+        dead_letter_id, before=before, after={'payload': retried['payload'], 'error': error}
+    )
+    return retried
+
+
+def discard(cursor, dead_letter_id: str, actor: Dict[str, Any], note: Optional[str] = None) -> Dict[str, Any]:
+    """Give up on a quarantined event; it stays here for reference"""
+    dead_letter = _quarantined(cursor, dead_letter_id)
+    cursor.execute(f"""
+        UPDATE event_dead_letters
+        SET status = 'discarded', resolved_by = %s, resolution_note = %s, resolved_at = NOW(), updated_at = NOW()
+        WHERE id = %s
+        RETURNING {DEAD_LETTER_COLUMNS}
+    """, (actor['id'], note, dead_letter_id))
+    discarded = dict(cursor.fetchone())
+    record_privileged(
+        cursor, actor, 'dead_letter_discarded', 'dead_letter', dead_letter_id,
+        before={'status': dead_letter['status']}, after={'status': 'discarded', 'note': note}
+    )
+    return discarded
+
+
+# Alerts
+def _alert_key(consumer: str) -> str:
+    return f"dead_letters:alerted:{consumer}"
+
+
+def check_depth() -> List[str]:
+    """Alert on every consumer whose quarantined events reached their threshold; returns those consumers"""
+    from shared.jobs import send_email
+
+    settings = get_setting('dead_letters')
+    cooldown = max(int(settings.get('alert_cooldown_minutes') or 0) * 60, 60)
+    with get_postgres_cursor() as cursor:
+        stats = depth(cursor)
+
+    alerting = []
+    redis_client = get_redis()
+    for row in stats:
+        if not row['over_threshold']:
+            # Alert straight away should it climb back
+            redis_client.delete(_alert_key(row['consumer']))
+            continue
+        alerting.append(row['consumer'])
+        if not redis_client.set(_alert_key(row['consumer']), 1, nx=True, ex=cooldown):
+            continue
+        message = (
+            f"{row['quarantined']} events are quarantined for the {row['consumer']} consumer "
+            f"(threshold {row['threshold']}), the oldest since {row['oldest_at'].isoformat()}. "
+            f"Inspect them at /api/v1/admin/dead-letters?consumer={row['consumer']}."
+        )
+        logger.error(f"Dead letter alert: {message}")
+        for address in settings.get('alert_emails') or []:
+            send_email.delay(address, f"Dead letters piling up for {row['consumer']}", message)
+    return alerting
@@ -0,0 +1,195 @@
+"""
+Collaborative draft editing with a CRDT
+
+A draft being edited together is a Yjs document with three text fields
+(title, summary, content). Editors exchange binary Yjs updates over a
+WebSocket: each update is stored as it arrives and fanned out to the other
+editors through Redis, so editors connected to different workers see each
+other's changes. Because Yjs updates commute and are idempotent, the server
+never has to order or transform them; it only merges them.
+
+Stored updates are folded into a snapshot periodically. Saving compacts the
+document and materializes its text into the article's own columns, which is
+what the rest of the application reads.
+"""
+
+import os
+import logging
+from typing import Any, Dict, List, Optional
+
+import psycopg2
+import redis.asyncio as aioredis
+from pycrdt import Doc, Text
+
+from shared.database import db_manager
+from shared.readability import store_readability
+from shared import permissions
+from shared.content import prepare
+
+logger = logging.getLogger(__name__)
+
+DOC_FIELDS = ('title', 'summary', 'content')
+MAX_UPDATE_BYTES = int(os.getenv('DRAFT_COLLAB_MAX_UPDATE_BYTES', 1024 * 1024))
+# Compact once this many updates are waiting, without waiting for the periodic job
+COMPACT_AFTER_UPDATES = int(os.getenv('DRAFT_COLLAB_COMPACT_AFTER_UPDATES', 500))
+
+
+def channel(article_id: str) -> str:
+    return f"draft_collab:{article_id}"
+
+
+def pubsub_client() -> aioredis.Redis:
+    """Async Redis client for the fan-out; updates are binary, so responses aren't decoded"""
+    config = {k: v for k, v in db_manager.redis_config.items() if v is not None}
+    config['decode_responses'] = False
+    config['socket_timeout'] = None  # Subscribers block waiting for messages
+    return aioredis.Redis(**config)
+
+
+def editable_draft(cursor, article_id: str, user: Dict[str, Any]) -> Optional[Dict[str, Any]]:
+    """The draft if `user` may edit it collaboratively: its authors or a holder of `article:edit_any`"""
+    cursor.execute(
+        "SELECT id, author_id, coauthor_ids, status, title, summary, content FROM articles WHERE id = %s", (article_id,)
+    )
+    article = cursor.fetchone()
+    if not article or article['status'] != 'draft':
+        return None
+    if not permissions.can_edit_article(user, article):
+        return None
+    return dict(article)
+
+
+def _doc_from(state: bytes) -> Doc:
+    doc = Doc()
+    doc.apply_update(state)
+    return doc
+
+
+def _seed_state(article: Dict[str, Any]) -> bytes:
+    """A document holding the draft's saved text, the starting point for every editor"""
+    doc = Doc()
+    for field in DOC_FIELDS:
+        doc[field] = Text(article.get(field) or '')
+    return doc.get_update()
+
+
+def load_state(cursor, article: Dict[str, Any]) -> bytes:
+    """The whole document as one update: the snapshot plus every update stored since"""
+    cursor.execute("""
+        INSERT INTO draft_crdt_snapshots (article_id, state) VALUES (%s, %s)
+        ON CONFLICT (article_id) DO NOTHING
+    """, (article['id'], psycopg2.Binary(_seed_state(article))))
+    # Seeded once, so concurrent first editors all start from the same operations
+
+    cursor.execute("SELECT state FROM draft_crdt_snapshots WHERE article_id = %s", (article['id'],))
+    doc = _doc_from(bytes(cursor.fetchone()['state']))
+    cursor.execute(
+        "SELECT update_data FROM draft_crdt_updates WHERE article_id = %s ORDER BY id", (article['id'],)
+    )
+    for row in cursor.fetchall():
+        doc.apply_update(bytes(row['update_data']))
+    return doc.get_update()
+
+
+def validate_update(update: bytes) -> bool:
+    """Reject oversized or undecodable updates before they reach storage"""
+    if not update or len(update) > MAX_UPDATE_BYTES:
+        return False
+    try:
+        Doc().apply_update(update)
+        return True
+    except Exception:
+        return False
+
+
+def store_update(cursor, article_id: str, update: bytes, user_id: Optional[str]) -> int:
+    """Store an update, returning how many are waiting to be compacted"""
+    cursor.execute("""
+        INSERT INTO draft_crdt_updates (article_id, update_data, user_id) VALUES (%s, %s, %s)
+    """, (article_id, psycopg2.Binary(update), user_id))
+    cursor.execute("SELECT COUNT(*) AS pending FROM draft_crdt_updates WHERE article_id = %s", (article_id,))
+    return cursor.fetchone()['pending']
+
+
+def compact(cursor, article_id: str) -> int:
+    """Fold stored updates into the snapshot, returning how many were folded"""
+    cursor.execute(
+        "SELECT state FROM draft_crdt_snapshots WHERE article_id = %s FOR UPDATE", (article_id,)
+    )
+    snapshot = cursor.fetchone()
+    if not snapshot:
+        return 0
+
+    cursor.execute(
+        "SELECT id, update_data FROM draft_crdt_updates WHERE article_id = %s ORDER BY id", (article_id,)
+    )
+    updates = cursor.fetchall()
+    if not updates:
+        return 0
+
+    doc = _doc_from(bytes(snapshot['state']))
+    for row in updates:
+        doc.apply_update(bytes(row['update_data']))
+
+    cursor.execute("""
+        UPDATE draft_crdt_snapshots
+        SET state = %s, updates_compacted = updates_compacted + %s, updated_at = NOW()
+        WHERE article_id = %s
+    """, (psycopg2.Binary(doc.get_update()), len(updates), article_id))
+    # Updates that arrived meanwhile have higher ids and wait for the next run
+    cursor.execute(
+        "DELETE FROM draft_crdt_updates WHERE article_id = %s AND id <= %s", (article_id, updates[-1]['id'])
+    )
+    return len(updates)
+
+
+def reset_document(cursor, article_id: str):
+    """Drop the document after the draft's text was changed outside it; the next editor reseeds it"""
+    cursor.execute("DELETE FROM draft_crdt_updates WHERE article_id = %s", (article_id,))
+    cursor.execute("DELETE FROM draft_crdt_snapshots WHERE article_id = %s", (article_id,))
+
+
+def materialize(cursor, article_id: str) -> Optional[Dict[str, Any]]:
+    """Write the document's text into the draft's columns, returning the updated article"""
+    compact(cursor, article_id)
+    cursor.execute("SELECT state FROM draft_crdt_snapshots WHERE article_id = %s", (article_id,))
+    snapshot = cursor.fetchone()
+    if not snapshot:
+        return None
+
+    doc = _doc_from(bytes(snapshot['state']))
+    values = {field: str(doc.get(field, type=Text)) for field in DOC_FIELDS}
+    cursor.execute("SELECT content_format FROM articles WHERE id = %s", (article_id,))
+    draft = cursor.fetchone()
+    if not draft:
+        return None
+    # Edits arrive from the shared document with nobody to refuse them to, so script is stripped
+    prepared = prepare(values['content'], draft['content_format'], strict=False)
+    content = prepared['content']
+
+    # A title is required, so an emptied title keeps the saved one
+    cursor.execute("""
+        UPDATE articles
+        SET title = COALESCE(NULLIF(%s, ''), title), summary = NULLIF(%s, ''), content = %s,
+            reading_time = %s, word_count = %s, updated_at = NOW()
+        WHERE id = %s AND status = 'draft'
+        RETURNING *
+    """, (
+        values['title'][:500], values['summary'], content,
+        prepared['reading_time'], prepared['word_count'], article_id
+    ))
+    article = cursor.fetchone()
+    if not article:
+        return None
+    cursor.execute(
+        "UPDATE draft_crdt_snapshots SET materialized_at = NOW() WHERE article_id = %s", (article_id,)
+    )
+    article = dict(article)
+    article['readability'] = store_readability(cursor, article_id, content, article['language'])
+    article['reading_level'] = article['readability']['reading_level']
+    return article
+
+
+def drafts_with_pending_updates(cursor) -> List[str]:
+    cursor.execute("SELECT DISTINCT article_id FROM draft_crdt_updates")
+    return [str(row['article_id']) for row in cursor.fetchall()]
@@ -0,0 +1,207 @@
+"""
+Editorial comments and suggestions on drafts
+
+Comments live in the MongoDB `draft_comments` collection and are anchored to
+a character range of the draft's title, summary or content, together with the
+text that range held (`quote`) so the anchor can be found again after the
+draft is edited. A suggestion is a comment carrying replacement text for its
+range; when the author accepts it the draft is rewritten and a revision is
+recorded. Comments are resolved and reopened as a thread; replies are kept
+on the comment itself.
+
+Each change records a `draft.commented` domain event naming the users to
+notify (the author and everyone in the thread except whoever acted), which
+the notification consumers pick up from the event bus.
+"""
+
+import logging
+from datetime import datetime, timezone
+from typing import Any, Dict, List, Optional
+
+from bson import ObjectId
+from bson.errors import InvalidId
+from pymongo import ASCENDING, ReturnDocument
+
+from shared.database import get_mongodb
+from shared.content import prepare
+from shared.corrections import record_revision
+from shared.draft_collab import DOC_FIELDS, reset_document
+from shared.events import draft_commented
+from shared.readability import store_readability
+
+logger = logging.getLogger(__name__)
+
+COLLECTION = 'draft_comments'
+OPEN = 'open'
+RESOLVED = 'resolved'
+ACCEPTED = 'accepted'
+REJECTED = 'rejected'
+
+
+class AnchorConflict(Exception):
+    """Raised when a suggestion's quoted text no longer appears in the draft"""
+
+
+def _collection():
+    collection = get_mongodb()[COLLECTION]
+    collection.create_index([('article_id', ASCENDING), ('status', ASCENDING), ('created_at', ASCENDING)])
+    return collection
+
+
+def _object_id(comment_id: str) -> Optional[ObjectId]:
+    try:
+        return ObjectId(comment_id)
+    except (InvalidId, TypeError):
+        return None
+
+
+def _serialize(comment: Dict[str, Any]) -> Dict[str, Any]:
+    comment = dict(comment)
+    comment['id'] = str(comment.pop('_id'))
+    return comment
+
+
+def participants(comment: Dict[str, Any], author_id: str) -> List[str]:
+    """The draft's author, the comment's author and everyone who replied"""
+    users = {str(author_id), comment['user_id']}
+    users.update(reply['user_id'] for reply in comment.get('replies', []))
+    return sorted(users)
+
+
+def locate(text: str, start: int, end: int, quote: str) -> Optional[int]:
+    """Where the quoted range starts now: its original offset if unchanged, else the nearest occurrence"""
+    if text[start:end] == quote:
+        return start
+    occurrences = []
+    position = text.find(quote)
+    while position != -1:
+        occurrences.append(position)
+        position = text.find(quote, position + 1)
+    if not occurrences:
+        return None
+    return min(occurrences, key=lambda offset: abs(offset - start))
+
+
+def list_comments(article_id: str, status: Optional[str] = None) -> List[Dict[str, Any]]:
+    query = {'article_id': str(article_id)}
+    if status:
+        query['status'] = status
+    return [_serialize(comment) for comment in _collection().find(query).sort('created_at', ASCENDING)]
+
+
+def get_comment(article_id: str, comment_id: str) -> Optional[Dict[str, Any]]:
+    object_id = _object_id(comment_id)
+    if not object_id:
+        return None
+    comment = _collection().find_one({'_id': object_id, 'article_id': str(article_id)})
+    return _serialize(comment) if comment else None
+
+
+def check_anchor(article: Dict[str, Any], anchor: Dict[str, Any]):
+    """Raise AnchorConflict unless the anchor's range of the draft holds its quote"""
+    text = article.get(anchor['field']) or ''
+    if anchor['field'] not in DOC_FIELDS or text[anchor['start']:anchor['end']] != anchor['quote']:
+        raise AnchorConflict("The quoted text does not match the draft at that range")
+
+
+def add_comment(article: Dict[str, Any], user: Dict[str, Any], anchor: Dict[str, Any],
+                body: str, suggestion: Optional[str] = None) -> Dict[str, Any]:
+    """Anchor a comment, or a suggestion when `suggestion` is given, to a range of the draft"""
+    check_anchor(article, anchor)
+
+    now = datetime.now(timezone.utc)
+    comment = {
+        'article_id': str(article['id']),
+        'user_id': str(user['id']),
+        'username': user['username'],
+        'kind': 'suggestion' if suggestion is not None else 'comment',
+        'anchor': {key: anchor[key] for key in ('field', 'start', 'end', 'quote')},
+        'body': body,
+        'suggestion': suggestion,
+        'status': OPEN,
+        'replies': [],
+        'resolved_by': None,
+        'resolved_at': None,
+        'revision_number': None,
+        'created_at': now,
+        'updated_at': now,
+    }
+    result = _collection().insert_one(comment)
+    comment['_id'] = result.inserted_id
+    return _serialize(comment)
+
+
+def add_reply(comment: Dict[str, Any], user: Dict[str, Any], body: str) -> Optional[Dict[str, Any]]:
+    now = datetime.now(timezone.utc)
+    reply = {'user_id': str(user['id']), 'username': user['username'], 'body': body, 'created_at': now}
+    updated = _collection().find_one_and_update(
+        {'_id': ObjectId(comment['id'])},
+        {'$push': {'replies': reply}, '$set': {'updated_at': now}},
+        return_document=ReturnDocument.AFTER
+    )
+    return _serialize(updated) if updated else None
+
+
+def set_status(comment: Dict[str, Any], status: str, user_id: str,
+               revision_number: Optional[int] = None) -> Optional[Dict[str, Any]]:
+    """Move a comment to `status`, only from the state that transition is allowed from"""
+    allowed_from = {RESOLVED: OPEN, OPEN: RESOLVED, ACCEPTED: OPEN, REJECTED: OPEN}[status]
+    now = datetime.now(timezone.utc)
+    closing = status != OPEN
+    updated = _collection().find_one_and_update(
+        {'_id': ObjectId(comment['id']), 'status': allowed_from},
+        {'$set': {
+            'status': status,
+            'resolved_by': str(user_id) if closing else None,
+            'resolved_at': now if closing else None,
+            'revision_number': revision_number,
+            'updated_at': now,
+        }},
+        return_document=ReturnDocument.AFTER
+    )
+    return _serialize(updated) if updated else None
+
+
+def apply_suggestion(cursor, comment: Dict[str, Any], user_id: str) -> Dict[str, Any]:
+    """Rewrite the suggested range of the draft and record a revision; returns the updated article"""
+    cursor.execute("SELECT * FROM articles WHERE id = %s FOR UPDATE", (comment['article_id'],))
+    article = dict(cursor.fetchone())
+
+    anchor = comment['anchor']
+    field = anchor['field']
+    text = article.get(field) or ''
+    start = locate(text, anchor['start'], anchor['end'], anchor['quote'])
+    if start is None:
+        raise AnchorConflict("The suggested passage no longer appears in the draft")
+
+    new_text = text[:start] + comment['suggestion'] + text[start + len(anchor['quote']):]
+    if field == 'content':
+        prepared = prepare(new_text, article['content_format'])
+        cursor.execute("""
+            UPDATE articles
+            SET content = %s, reading_time = %s, word_count = %s, updated_at = NOW()
+            WHERE id = %s
+            RETURNING *
+        """, (prepared['content'], prepared['reading_time'], prepared['word_count'], article['id']))
+    else:
+        cursor.execute(
+            f"UPDATE articles SET {field} = %s, updated_at = NOW() WHERE id = %s RETURNING *",
+            (new_text, article['id'])
+        )
+    updated = dict(cursor.fetchone())
+    updated['readability'] = store_readability(cursor, article['id'], updated['content'], updated['language'])
+    updated['reading_level'] = updated['readability']['reading_level']
+
+    revision = record_revision(
+        cursor, updated, user_id, change_note=f"Accepted suggestion from {comment['username']}", previous=article
+    )
+    # The collaborative document still holds the old text
+    reset_document(cursor, article['id'])
+    updated['revision_number'] = revision['revision_number']
+    return updated
+
+
+def notify(cursor, comment: Dict[str, Any], action: str, actor_id: str, author_id: str):
+    """Record the change for the notification consumers"""
+    recipients = [user_id for user_id in participants(comment, author_id) if user_id != str(actor_id)]
+    draft_commented(cursor, comment['article_id'], comment['id'], comment['kind'], action, str(actor_id), recipients)
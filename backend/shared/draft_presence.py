@@ -0,0 +1,85 @@
+"""
+Presence of collaborators on a draft
+
+Each open editing connection has an entry in a Redis hash per draft: who it
+is, whether they are viewing or editing, and where their cursor is. Entries
+expire unless the client refreshes them, so a worker that dies without
+cleaning up doesn't leave ghosts behind; clients send a presence message at
+least every DRAFT_PRESENCE_TTL_SECONDS / 2, which cursor movement usually does
+on its own.
+"""
+
+import os
+import json
+import time
+import logging
+from typing import Any, Dict, List, Optional
+
+from shared.database import get_redis
+from shared.draft_collab import DOC_FIELDS
+
+logger = logging.getLogger(__name__)
+
+PRESENCE_TTL_SECONDS = int(os.getenv('DRAFT_PRESENCE_TTL_SECONDS', 60))
+STATES = ('viewing', 'editing', 'idle')
+MAX_POSITION_LENGTH = 200
+
+
+def _key(article_id: str) -> str:
+    return f"draft_presence:{article_id}"
+
+
+def clean_cursor(cursor: Any) -> Optional[Dict[str, Any]]:
+    """Keep a cursor only if it names a document field and has small anchor/head positions
+
+    Positions are indexes or encoded Yjs relative positions; either is passed through untouched.
+    """
+    if not isinstance(cursor, dict) or cursor.get('field') not in DOC_FIELDS:
+        return None
+    cleaned = {'field': cursor['field']}
+    for name in ('anchor', 'head'):
+        value = cursor.get(name)
+        if isinstance(value, bool) or not isinstance(value, (int, str)):
+            return None
+        if isinstance(value, str) and len(value) > MAX_POSITION_LENGTH:
+            return None
+        cleaned[name] = value
+    return cleaned
+
+
+def set_presence(article_id: str, connection_id: str, user: Dict[str, Any], state: str = 'viewing',
+                 cursor: Any = None) -> Dict[str, Any]:
+    entry = {
+        'connection_id': connection_id,
+        'user_id': str(user['id']),
+        'username': user['username'],
+        'state': state if state in STATES else 'viewing',
+        'cursor': clean_cursor(cursor),
+        'updated_at': time.time(),
+    }
+    redis_client = get_redis()
+    redis_client.hset(_key(article_id), connection_id, json.dumps(entry))
+    redis_client.expire(_key(article_id), PRESENCE_TTL_SECONDS)
+    return entry
+
+
+def remove_presence(article_id: str, connection_id: str):
+    try:
+        get_redis().hdel(_key(article_id), connection_id)
+    except Exception as e:
+        logger.warning(f"Could not remove presence {connection_id} on {article_id}: {e}")
+
+
+def list_presence(article_id: str) -> List[Dict[str, Any]]:
+    """Live collaborators on a draft, dropping entries that weren't refreshed in time"""
+    redis_client = get_redis()
+    cutoff = time.time() - PRESENCE_TTL_SECONDS
+    collaborators = []
+    for connection_id, raw in redis_client.hgetall(_key(article_id)).items():
+        entry = json.loads(raw)
+        if entry['updated_at'] < cutoff:
+            redis_client.hdel(_key(article_id), connection_id)
+            continue
+        collaborators.append(entry)
+    collaborators.sort(key=lambda entry: entry['username'])
+    return collaborators
@@ -0,0 +1,79 @@
+"""
+Edge (CDN) cache purging by surrogate key
+
+Cacheable responses are tagged with a `Surrogate-Key` header listing the
+objects they were built from. When one of those objects changes, its key is
+purged so every cached response that depends on it is dropped together. The
+purge API follows Fastly's (a POST with the keys in a `Surrogate-Key`
+header); with EDGE_PURGE_URL unset there is no edge cache and purging is a
+no-op.
+
+Responses can also be warmed by requesting them through the edge, so the
+edge holds them before readers ask; EDGE_WARM_BASE_URL is the public URL the
+edge serves the API from.
+"""
+
+import os
+import logging
+from typing import Iterable, List
+
+import requests
+
+logger = logging.getLogger(__name__)
+
+
+def surrogate_headers(keys: Iterable[str], max_age: int, edge_max_age: int) -> dict:
+    """Headers letting browsers cache briefly and the edge cache until purged"""
+    return {
+        'Cache-Control': f"public, max-age={max_age}",
+        'Surrogate-Control': f"max-age={edge_max_age}",
+        'Surrogate-Key': ' '.join(keys),
+    }
+
+
+def purge_surrogate_keys(keys: Iterable[str]) -> bool:
+    """Ask the edge to drop every response tagged with any of `keys`"""
+    purge_url = os.getenv('EDGE_PURGE_URL')
+    keys = list(keys)
+    if not purge_url or not keys:
+        return False
+
+    headers = {'Surrogate-Key': ' '.join(keys)}
+    token = os.getenv('EDGE_PURGE_TOKEN')
+    if token:
+        headers[os.getenv('EDGE_PURGE_AUTH_HEADER', 'Fastly-Key')] = token
+
+    try:
+        response = requests.post(purge_url, headers=headers, timeout=5)
+        response.raise_for_status()
+        logger.info(f"Purged edge cache for {', '.join(keys)}")
+        return True
+    except requests.RequestException as e:
+        # Responses expire on their own after Surrogate-Control max-age
+        logger.warning(f"Edge purge failed for {', '.join(keys)}: {e}")
+        return False
+
+
+FEED_SURROGATE_KEY = 'feeds'
+
+
+def article_surrogate_key(article_id: str) -> str:
+    return f"article-{article_id}"
+
+
+def warm_urls(paths: Iterable[str]) -> List[str]:
+    """Request each path (or absolute URL) through the edge, returning those that answered"""
+    base_url = os.getenv('EDGE_WARM_BASE_URL', '').rstrip('/')
+    if not base_url:
+        return []
+
+    warmed = []
+    for path in paths:
+        url = path if path.startswith(('http://', 'https://')) else f"{base_url}{path}"
+        try:
+            response = requests.get(url, timeout=10)
+            if response.ok:
+                warmed.append(url)
+        except requests.RequestException as e:
+            logger.warning(f"Edge warm-up failed for {url}: {e}")
+    return warmed
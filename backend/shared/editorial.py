@@ -0,0 +1,289 @@
+"""
+Editorial review of drafts
+
+An author submits a draft to the publication's editors, anyone holding
+`article:review`. Submitted drafts wait in the editorial queue until an
+editor takes them or assigns them to another editor; the assigned editor
+(or any other) then requests changes, approves or rejects. Changes are
+requested with an optional set of inline comments, made as draft comments
+(shared/draft_comments.py) so the author answers them in place. Resubmitting
+puts the draft back in the queue with its assignee; the author can withdraw
+it while it is open. An article has at most one open review, and editors
+don't review their own drafts.
+
+    submitted  --request_changes-->  changes_requested  --submit-->  submitted
+    submitted | changes_requested  --approve | reject | withdraw-->  (closed)
+
+Approving can also publish the draft. With the `editorial` setting's
+`require_approval` on, drafts can only be published or scheduled once their
+latest review is approved, except by editors. Every transition is kept in
+`editorial_events`, which is the article's editorial timeline, and records
+an `editorial.transitioned` domain event naming who to notify: the
+assignee when a draft is assigned, resubmitted or withdrawn, the author
+when it is assigned or decided.
+
+Reviews carry the publication's `tenant_id` under row-level security like
+invitations do.
+"""
+
+import logging
+from datetime import datetime, timezone
+from typing import Any, Dict, List, Optional
+
+from shared import permissions
+from shared.draft_comments import add_comment, check_anchor
+from shared.events import editorial_transitioned
+from shared.members import in_publication
+from shared.settings import get_setting
+
+logger = logging.getLogger(__name__)
+
+SUBMITTED = 'submitted'
+CHANGES_REQUESTED = 'changes_requested'
+APPROVED = 'approved'
+REJECTED = 'rejected'
+WITHDRAWN = 'withdrawn'
+
+OPEN_STATUSES = (SUBMITTED, CHANGES_REQUESTED)
+STATUSES = OPEN_STATUSES + (APPROVED, REJECTED, WITHDRAWN)
+
+REVIEW_COLUMNS = """
+    r.id, r.article_id, r.submitted_by, r.assigned_to, r.status, r.note, r.decided_by, r.decision_note,
+    r.decided_at, r.created_at, r.updated_at
+"""
+
+
+class EditorialError(Exception):
+    """The review isn't in a state that allows the transition"""
+
+
+def requires_approval() -> bool:
+    return bool((get_setting('editorial') or {}).get('require_approval'))
+
+
+def approved(cursor, article_id: str) -> bool:
+    """Whether the draft's latest review approved it"""
+    cursor.execute(
+        "SELECT status FROM editorial_reviews WHERE article_id = %s ORDER BY created_at DESC LIMIT 1", (article_id,)
+    )
+    latest = cursor.fetchone()
+    return bool(latest) and latest['status'] == APPROVED
+
+
+def may_publish(cursor, article_id: str, user: Dict[str, Any]) -> bool:
+    """Whether publishing the draft needs no further editorial approval"""
+    if not requires_approval() or permissions.allowed(user, permissions.ARTICLE_REVIEW):
+        return True
+    return approved(cursor, article_id)
+
+
+# Reading
+def get_review(cursor, review_id: str, for_update: bool = False) -> Optional[Dict[str, Any]]:
+    condition, params = in_publication('r.tenant_id')
+    cursor.execute(f"""
+        SELECT {REVIEW_COLUMNS}, a.title AS article_title, a.author_id, a.coauthor_ids
+        FROM editorial_reviews r
+        JOIN articles a ON a.id = r.article_id
+        WHERE r.id = %s AND {condition}
+        {'FOR UPDATE OF r' if for_update else ''}
+    """, [review_id] + params)
+    row = cursor.fetchone()
+    return dict(row) if row else None
+
+
+def open_review(cursor, article_id: str, for_update: bool = False) -> Optional[Dict[str, Any]]:
+    condition, params = in_publication('r.tenant_id')
+    cursor.execute(f"""
+        SELECT {REVIEW_COLUMNS}, a.title AS article_title, a.author_id, a.coauthor_ids
+        FROM editorial_reviews r
+        JOIN articles a ON a.id = r.article_id
+        WHERE r.article_id = %s AND r.status IN %s AND {condition}
+        {'FOR UPDATE OF r' if for_update else ''}
+    """, [article_id, OPEN_STATUSES] + params)
+    row = cursor.fetchone()
+    return dict(row) if row else None
+
+
+def queue(cursor, status: Optional[str] = None, assigned_to: Optional[str] = None, unassigned: bool = False,
+          limit: int = 50, offset: int = 0) -> List[Dict[str, Any]]:
+    """Reviews oldest first; the open ones unless `status` is given"""
+    condition, params = in_publication('r.tenant_id')
+    if status:
+        condition += " AND r.status = %s"
+        params.append(status)
+    else:
+        condition += " AND r.status IN %s"
+        params.append(OPEN_STATUSES)
+    if assigned_to:
+        condition += " AND r.assigned_to = %s"
+        params.append(assigned_to)
+    elif unassigned:
+        condition += " AND r.assigned_to IS NULL"
+    cursor.execute(f"""
+        SELECT {REVIEW_COLUMNS}, a.title AS article_title, a.author_id, a.category,
+               author.username AS author_username, assignee.username AS assignee_username
+        FROM editorial_reviews r
+        JOIN articles a ON a.id = r.article_id
+        LEFT JOIN users author ON author.id = a.author_id
+        LEFT JOIN users assignee ON assignee.id = r.assigned_to
+        WHERE {condition}
+        ORDER BY r.updated_at, r.created_at
+        LIMIT %s OFFSET %s
+    """, params + [limit, offset])
+    return [dict(row) for row in cursor.fetchall()]
+
+
+def timeline(cursor, article_id: str) -> List[Dict[str, Any]]:
+    """Every editorial transition of an article, oldest first"""
+    condition, params = in_publication('e.tenant_id')
+    cursor.execute(f"""
+        SELECT e.id, e.review_id, e.article_id, e.actor_id, actor.username AS actor_username, e.action,
+               e.from_status, e.to_status, e.assigned_to, assignee.username AS assignee_username, e.note,
+               e.comment_ids, e.created_at
+        FROM editorial_events e
+        LEFT JOIN users actor ON actor.id = e.actor_id
+        LEFT JOIN users assignee ON assignee.id = e.assigned_to
+        WHERE e.article_id = %s AND {condition}
+        ORDER BY e.created_at, e.id
+    """, [article_id] + params)
+    return [dict(row) for row in cursor.fetchall()]
+
+
+# Transitions
+def _transition(cursor, review: Dict[str, Any], action: str, actor: Dict[str, Any], status: str,
+                recipients: List[str], note: Optional[str] = None, comment_ids: Optional[List[str]] = None,
+                columns: Optional[Dict[str, Any]] = None) -> Dict[str, Any]:
+    """Move a review to `status`, keeping the transition on the timeline and telling `recipients`"""
+    updates = {'status': status, **(columns or {})}
+    cursor.execute(f"""
+        UPDATE editorial_reviews SET {', '.join(f'{column} = %s' for column in updates)}, updated_at = NOW()
+        WHERE id = %s
+        RETURNING id, article_id, submitted_by, assigned_to, status, note, decided_by, decision_note,
+                  decided_at, created_at, updated_at
+    """, list(updates.values()) + [review['id']])
+    updated = {**review, **dict(cursor.fetchone())}
+    _record(cursor, updated, action, actor, review['status'], note, comment_ids)
+    _notify(cursor, updated, action, actor, recipients, note)
+    return updated
+
+
+def _record(cursor, review: Dict[str, Any], action: str, actor: Dict[str, Any], from_status: Optional[str],
+            note: Optional[str] = None, comment_ids: Optional[List[str]] = None):
+    cursor.execute("""
+        INSERT INTO editorial_events
+            (review_id, article_id, actor_id, action, from_status, to_status, assigned_to, note, comment_ids)
+        VALUES (%s, %s, %s, %s, %s, %s, %s, %s, %s)
+    """, (
+        review['id'], review['article_id'], actor['id'], action, from_status, review['status'],
+        review.get('assigned_to'), note, list(comment_ids or []),
+    ))
+
+
+def _notify(cursor, review: Dict[str, Any], action: str, actor: Dict[str, Any], recipients: List[str],
+            note: Optional[str]):
+    recipients = sorted({str(user_id) for user_id in recipients if user_id and str(user_id) != str(actor['id'])})
+    editorial_transitioned(cursor, review, action, str(actor['id']), recipients, note)
+
+
+def _require_open(review: Dict[str, Any], *statuses: str):
+    """Refuse a transition from any status but `statuses`, by default the open ones"""
+    if review['status'] not in (statuses or OPEN_STATUSES):
+        raise EditorialError(f"Review is {review['status'].replace('_', ' ')}")
+
+
+def _require_other_author(review: Dict[str, Any], editor: Dict[str, Any]):
+    authors = {str(review['author_id'])} | {str(user_id) for user_id in review.get('coauthor_ids') or ()}
+    if str(editor['id']) in authors:
+        raise PermissionError("Editors can't review their own drafts")
+
+
+def submit(cursor, article: Dict[str, Any], author: Dict[str, Any], note: Optional[str] = None) -> Dict[str, Any]:
+    """Send a draft to the editors, or back to them once changes were requested"""
+    if article['status'] != 'draft':
+        raise EditorialError("Only drafts can be submitted for review")
+    review = open_review(cursor, article['id'], for_update=True)
+    if review:
+        if review['status'] == SUBMITTED:
+            raise EditorialError("The draft is already waiting for review")
+        return _transition(
+            cursor, review, 'resubmitted', author, SUBMITTED, [review['assigned_to']], note,
+            columns={'note': note or review['note']},
+        )
+
+    cursor.execute("""
+        INSERT INTO editorial_reviews (article_id, submitted_by, note)
+        VALUES (%s, %s, %s)
+        RETURNING id, article_id, submitted_by, assigned_to, status, note, decided_by, decision_note,
+                  decided_at, created_at, updated_at
+    """, (article['id'], author['id'], note))
+    review = {**dict(cursor.fetchone()), 'article_title': article['title'], 'author_id': article['author_id']}
+    _record(cursor, review, 'submitted', author, None, note)
+    _notify(cursor, review, 'submitted', author, [], note)
+    return review
+
+
+def withdraw(cursor, article: Dict[str, Any], author: Dict[str, Any]) -> Dict[str, Any]:
+    """Take a draft out of review; raises LookupError when it has no open review"""
+    review = open_review(cursor, article['id'], for_update=True)
+    if not review:
+        raise LookupError("The draft has no open review")
+    return _transition(cursor, review, 'withdrawn', author, WITHDRAWN, [review['assigned_to']])
+
+
+def assign(cursor, review: Dict[str, Any], editor: Dict[str, Any], assignee_id: Optional[str] = None) -> Dict[str, Any]:
+    """Give an open review to an editor: `assignee_id`, or whoever assigns it
+
+    Raises LookupError when the assignee isn't an editor of the publication.
+    """
+    _require_open(review)
+    assignee_id = str(assignee_id or editor['id'])
+    if assignee_id != str(editor['id']):
+        condition, params = in_publication()
+        cursor.execute(
+            f"SELECT * FROM users WHERE id = %s AND is_active = true AND deleted_at IS NULL AND {condition}",
+            [assignee_id] + params
+        )
+        assignee = cursor.fetchone()
+        if not assignee or not permissions.allowed(dict(assignee), permissions.ARTICLE_REVIEW):
+            raise LookupError("No editor of this publication has that id")
+    if str(review['author_id']) == assignee_id:
+        raise PermissionError("Editors can't review their own drafts")
+    if str(review.get('assigned_to')) == assignee_id:
+        return review
+    return _transition(
+        cursor, review, 'assigned', editor, review['status'], [assignee_id, review['author_id']],
+        columns={'assigned_to': assignee_id},
+    )
+
+
+def request_changes(cursor, review: Dict[str, Any], article: Dict[str, Any], editor: Dict[str, Any],
+                    note: Optional[str], comments: List[Dict[str, Any]]) -> Dict[str, Any]:
+    """Send a submitted draft back to its author with a note and inline comments (`anchor`, `body`, `suggestion`)
+
+    Every comment's anchor is checked before any is made, raising AnchorConflict.
+    """
+    _require_open(review, SUBMITTED)
+    _require_other_author(review, editor)
+    for comment in comments:
+        check_anchor(article, comment['anchor'])
+    comment_ids = [
+        add_comment(article, editor, comment['anchor'], comment['body'], comment.get('suggestion'))['id']
+        for comment in comments
+    ]
+    return _transition(
+        cursor, review, 'changes_requested', editor, CHANGES_REQUESTED, [review['author_id']], note, comment_ids,
+        columns={'assigned_to': review['assigned_to'] or editor['id']},
+    )
+
+
+def decide(cursor, review: Dict[str, Any], editor: Dict[str, Any], decision: str,
+           note: Optional[str] = None) -> Dict[str, Any]:
+    """Approve or reject a draft under review; only submitted drafts, not ones awaiting changes, are approved"""
+    if decision not in (APPROVED, REJECTED):
+        raise ValueError(f"Unknown editorial decision: {decision}")
+    _require_open(review, *((SUBMITTED,) if decision == APPROVED else OPEN_STATUSES))
+    _require_other_author(review, editor)
+    return _transition(
+        cursor, review, decision, editor, decision, [review['author_id']], note,
+        columns={'decided_by': editor['id'], 'decision_note': note, 'decided_at': datetime.now(timezone.utc)},
+    )
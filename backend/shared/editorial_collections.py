@@ -0,0 +1,83 @@
+"""
+Editorial collections (curated topic hubs)
+
+Articles enter a collection either manually or through tag/category rules.
+Rules are evaluated when an article is published and can be re-applied to
+the existing archive on demand.
+"""
+
+import logging
+from typing import Any, Dict, Optional
+
+logger = logging.getLogger(__name__)
+
+
+def apply_collection_rules(cursor, article: Dict[str, Any]) -> int:
+    """Add a newly published article to every collection whose rules match it"""
+    tags = list(article.get('tags') or [])
+
+    cursor.execute("""
+        SELECT id, collection_id, section_id FROM collection_rules
+        WHERE (rule_type = 'category' AND value = %s)
+        OR (rule_type = 'tag' AND value = ANY(%s))
+        ORDER BY created_at ASC
+    """, (article.get('category'), tags))
+    rules = cursor.fetchall()
+
+    added = 0
+    for rule in rules:
+        cursor.execute("""
+            INSERT INTO collection_articles (collection_id, article_id, section_id, position, source, rule_id)
+            VALUES (%s, %s, %s, 0, 'rule', %s)
+            ON CONFLICT (collection_id, article_id) DO NOTHING
+        """, (rule['collection_id'], str(article['id']), rule['section_id'], rule['id']))
+        added += cursor.rowcount
+
+    if added:
+        logger.info(f"Article {article['id']} added to {added} collection(s) by rule")
+    return added
+
+
+def backfill_collection_rules(cursor, collection_id: str) -> int:
+    """Apply a collection's rules to all already published articles"""
+    cursor.execute("""
+        INSERT INTO collection_articles (collection_id, article_id, section_id, position, source, rule_id)
+        SELECT DISTINCT ON (a.id) r.collection_id, a.id, r.section_id, 0, 'rule', r.id
+        FROM collection_rules r
+        JOIN articles a ON a.status = 'published' AND (
+            (r.rule_type = 'category' AND a.category = r.value)
+            OR (r.rule_type = 'tag' AND r.value = ANY(a.tags))
+        )
+        WHERE r.collection_id = %s
+        ORDER BY a.id, r.created_at ASC
+        ON CONFLICT (collection_id, article_id) DO NOTHING
+    """, (collection_id,))
+    return cursor.rowcount
+
+
+def find_collection(cursor, slug_or_id: str, published_only: bool = True) -> Optional[Dict[str, Any]]:
+    """Look up a collection by slug or id"""
+    query = "SELECT * FROM collections WHERE (slug = %s OR id::text = %s)"
+    if published_only:
+        query += " AND is_published = true"
+    cursor.execute(query, (slug_or_id, slug_or_id))
+    row = cursor.fetchone()
+    return dict(row) if row else None
+
+
+def load_collection_articles(cursor, collection_id: str, limit: Optional[int] = None):
+    """Load a collection's published articles in display order"""
+    query = """
+        SELECT a.*, ca.section_id as collection_section_id, ca.position as collection_position,
+               ca.source as collection_source
+        FROM collection_articles ca
+        JOIN articles a ON a.id = ca.article_id
+        WHERE ca.collection_id = %s AND a.status = 'published'
+        ORDER BY ca.position ASC, a.published_at DESC NULLS LAST
+    """
+    params = [collection_id]
+    if limit:
+        query += " LIMIT %s"
+        params.append(limit)
+    cursor.execute(query, params)
+    return cursor.fetchall()
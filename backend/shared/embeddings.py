@@ -0,0 +1,299 @@
+"""
+Related articles by embedding similarity
+
+Published articles are embedded with the provider named by
+EMBEDDING_PROVIDER, and `GET /articles/{id}/related` returns the nearest
+neighbours by cosine distance, in the article's language unless the reader
+asks for another:
+
+- `openai`: any OpenAI-compatible `/embeddings` API at EMBEDDING_API_URL
+  with EMBEDDING_API_KEY and EMBEDDING_MODEL
+- `ollama`: a local Ollama server at OLLAMA_URL running EMBEDDING_MODEL
+- `none`: embeddings are off and related articles fall back to shared tags
+  and category
+
+What is embedded is the title, summary and the text of the content, cut at
+EMBEDDING_MAX_CHARS. Vectors live in `article_vectors` (pgvector, with an
+HNSW index) and always have DIMENSIONS components, fixed by the column
+type; OpenAI models are asked for that many, and an Ollama model must
+produce that many. Articles are embedded when published, and a worker
+embeds articles that were edited since or were published before
+embeddings were set up, EMBEDDING_BATCH_SIZE per run.
+
+Neighbours are cached in Redis for RELATED_CACHE_TTL_SECONDS as article ids,
+so readers still get each article gated by the paywall for them.
+
+Search with `mode: semantic` embeds the query (cached for
+QUERY_CACHE_TTL_SECONDS) and ranks the SEARCH_CANDIDATES nearest articles
+together with the keyword matches by SEARCH_SEMANTIC_WEIGHT times the
+similarity plus the rest times the normalized keyword rank.
+"""
+
+import os
+import json
+import hashlib
+import logging
+from typing import Any, Dict, List, Optional, Protocol
+
+import requests
+
+from shared.content import HTML, render, text_of
+from shared.database import get_redis
+
+logger = logging.getLogger(__name__)
+
+PROVIDER = os.getenv('EMBEDDING_PROVIDER', 'none')
+DIMENSIONS = 768  # The size of article_vectors.embedding (58_article_vectors.sql)
+MAX_CHARS = int(os.getenv('EMBEDDING_MAX_CHARS', 8000))
+BATCH_SIZE = int(os.getenv('EMBEDDING_BATCH_SIZE', 100))
+REQUEST_TIMEOUT_SECONDS = int(os.getenv('EMBEDDING_TIMEOUT_SECONDS', 30))
+CACHE_TTL_SECONDS = int(os.getenv('RELATED_CACHE_TTL_SECONDS', 60 * 60))
+MAX_RELATED = 20
+QUERY_CACHE_TTL_SECONDS = int(os.getenv('EMBEDDING_QUERY_CACHE_TTL_SECONDS', 60 * 60))
+SEARCH_WEIGHT = min(max(float(os.getenv('SEARCH_SEMANTIC_WEIGHT', 0.7)), 0.0), 1.0)
+SEARCH_CANDIDATES = int(os.getenv('SEARCH_SEMANTIC_CANDIDATES', 200))
+
+
+class EmbeddingUnavailable(Exception):
+    """Raised when no provider is configured or the provider fails"""
+
+
+# Interface
+class EmbeddingProvider(Protocol):
+    name: str
+    model: str
+
+    def embed(self, texts: List[str]) -> List[List[float]]:
+        """One vector of DIMENSIONS components per text"""
+        ...
+
+
+# Implementations
+class OpenAIEmbeddings:
+    name = 'openai'
+
+    def __init__(self):
+        self.url = os.getenv('EMBEDDING_API_URL', 'https://api.openai.com/v1').rstrip('/')
+        self.api_key = os.getenv('EMBEDDING_API_KEY', '')
+        self.model = os.getenv('EMBEDDING_MODEL', 'text-embedding-3-small')
+
+    def embed(self, texts: List[str]) -> List[List[float]]:
+        if not self.api_key:
+            raise EmbeddingUnavailable("EMBEDDING_API_KEY must be set")
+        response = requests.post(
+            f"{self.url}/embeddings",
+            json={'model': self.model, 'input': texts, 'dimensions': DIMENSIONS},
+            headers={'Authorization': f"Bearer {self.api_key}"},
+            timeout=REQUEST_TIMEOUT_SECONDS,
+        )
+        response.raise_for_status()
+        return [item['embedding'] for item in sorted(response.json()['data'], key=lambda item: item['index'])]
+
+
+class OllamaEmbeddings:
+    name = 'ollama'
+
+    def __init__(self):
+        self.url = os.getenv('OLLAMA_URL', 'http://localhost:11434').rstrip('/')
+        self.model = os.getenv('EMBEDDING_MODEL', 'nomic-embed-text')
+
+    def embed(self, texts: List[str]) -> List[List[float]]:
+        response = requests.post(
+            f"{self.url}/api/embed", json={'model': self.model, 'input': texts}, timeout=REQUEST_TIMEOUT_SECONDS,
+        )
+        response.raise_for_status()
+        return response.json()['embeddings']
+
+
+PROVIDERS = {
+    'openai': OpenAIEmbeddings,
+    'ollama': OllamaEmbeddings,
+}
+
+_provider: Optional[EmbeddingProvider] = None
+
+
+def enabled() -> bool:
+    return PROVIDER in PROVIDERS
+
+
+def provider() -> EmbeddingProvider:
+    global _provider
+    if _provider is None:
+        if not enabled():
+            raise EmbeddingUnavailable("Embeddings are not configured")
+        _provider = PROVIDERS[PROVIDER]()
+    return _provider
+
+
+def embedding_text(article: Dict[str, Any]) -> str:
+    content = text_of(render(article['content'], article.get('content_format') or HTML))
+    text = '\n\n'.join(part for part in (article['title'], article.get('summary'), ' '.join(content.split())) if part)
+    return text[:MAX_CHARS]
+
+
+def _hash(text: str) -> str:
+    return hashlib.sha256(text.encode()).hexdigest()
+
+
+def _vector(values: List[float]) -> str:
+    """A vector literal pgvector parses, so no driver adapter is needed"""
+    if len(values) != DIMENSIONS:
+        raise EmbeddingUnavailable(f"The model returned {len(values)} dimensions, not {DIMENSIONS}")
+    return '[' + ','.join(repr(float(value)) for value in values) + ']'
+
+
+def query_vector(query: str) -> str:
+    """The embedding of a search query as a vector literal"""
+    embedder = provider()
+    key = f"embedding_query:{embedder.model}:{_hash(query.strip().lower())}"
+    try:
+        cached = get_redis().get(key)
+        if cached:
+            return cached.decode() if isinstance(cached, bytes) else cached
+    except Exception as e:
+        logger.warning(f"Query embedding cache read failed: {e}")
+
+    vector = _vector(embedder.embed([query.strip()])[0])
+    try:
+        get_redis().setex(key, QUERY_CACHE_TTL_SECONDS, vector)
+    except Exception as e:
+        logger.warning(f"Query embedding cache write failed: {e}")
+    return vector
+
+
+def embed_articles(cursor, articles: List[Dict[str, Any]]) -> int:
+    """Embed published articles whose text changed since they were last embedded; returns how many were"""
+    embedder = provider()
+    texts = {str(article['id']): embedding_text(article) for article in articles if article['status'] == 'published'}
+    if not texts:
+        return 0
+    cursor.execute("""
+        SELECT article_id, content_hash FROM article_vectors
+        WHERE article_id = ANY(%s::uuid[]) AND provider = %s AND model = %s
+    """, (list(texts), embedder.name, embedder.model))
+    stored = {str(row['article_id']): row['content_hash'] for row in cursor.fetchall()}
+    unchanged = {article_id for article_id, content_hash in stored.items() if content_hash == _hash(texts[article_id])}
+    changed = [article_id for article_id in texts if article_id not in unchanged]
+
+    if unchanged:
+        # Edits that didn't change the embedded text don't need embedding again
+        cursor.execute("UPDATE article_vectors SET updated_at = NOW() WHERE article_id = ANY(%s::uuid[])",
+                       (list(unchanged),))
+    if not changed:
+        return 0
+
+    vectors = embedder.embed([texts[article_id] for article_id in changed])
+    for article_id, vector in zip(changed, vectors):
+        cursor.execute("""
+            INSERT INTO article_vectors (article_id, provider, model, embedding, content_hash)
+            VALUES (%s, %s, %s, %s::vector, %s)
+            ON CONFLICT (article_id) DO UPDATE SET
+                provider = EXCLUDED.provider, model = EXCLUDED.model, embedding = EXCLUDED.embedding,
+                content_hash = EXCLUDED.content_hash, updated_at = NOW()
+        """, (article_id, embedder.name, embedder.model, _vector(vector), _hash(texts[article_id])))
+        invalidate(article_id)
+    return len(changed)
+
+
+def embed_article(cursor, article_id: str) -> bool:
+    cursor.execute("SELECT * FROM articles WHERE id = %s", (article_id,))
+    article = cursor.fetchone()
+    return bool(article) and embed_articles(cursor, [dict(article)]) > 0
+
+
+def articles_due(cursor, limit: int = BATCH_SIZE) -> List[Dict[str, Any]]:
+    """Published articles never embedded, embedded by another model, or edited since"""
+    embedder = provider()
+    cursor.execute("""
+        SELECT a.* FROM articles a
+        LEFT JOIN article_vectors v ON v.article_id = a.id
+        WHERE a.status = 'published'
+          AND (v.article_id IS NULL OR v.provider != %s OR v.model != %s OR a.updated_at > v.updated_at)
+        ORDER BY a.published_at DESC
+        LIMIT %s
+    """, (embedder.name, embedder.model, limit))
+    return [dict(row) for row in cursor.fetchall()]
+
+
+def embed_pending() -> int:
+    """Periodic: embed a batch of the articles due"""
+    from shared.database import get_postgres_cursor
+
+    if not enabled():
+        return 0
+    with get_postgres_cursor() as cursor:
+        embedded = embed_articles(cursor, articles_due(cursor))
+    if embedded:
+        logger.info(f"Embedded {embedded} articles")
+    return embedded
+
+
+def _cache_key(article_id: str, language: Optional[str], limit: int) -> str:
+    return f"related:{article_id}:{language or '*'}:{limit}"
+
+
+def invalidate(article_id: str):
+    try:
+        redis_client = get_redis()
+        keys = list(redis_client.scan_iter(match=f"related:{article_id}:*", count=100))
+        if keys:
+            redis_client.delete(*keys)
+    except Exception as e:
+        logger.warning(f"Related articles cache invalidation failed for {article_id}: {e}")
+
+
+def related(cursor, article_id: str, language: Optional[str], limit: int) -> Optional[List[Dict[str, Any]]]:
+    """The published articles nearest to an article, with their `similarity`; None when it has no embedding
+
+    `language` None matches every language.
+    """
+    key = _cache_key(article_id, language, limit)
+    try:
+        cached = get_redis().get(key)
+    except Exception as e:
+        logger.warning(f"Related articles cache read failed for {article_id}: {e}")
+        cached = None
+
+    if cached:
+        neighbours = json.loads(cached)
+    else:
+        cursor.execute("SELECT embedding::text AS embedding FROM article_vectors WHERE article_id = %s", (article_id,))
+        source = cursor.fetchone()
+        if not source:
+            return None
+        # Filtering happens after the index scan, so look further than usual for enough matches
+        cursor.execute("SET LOCAL hnsw.ef_search = %s", (max(100, limit * 10),))
+        cursor.execute("""
+            SELECT v.article_id, 1 - (v.embedding <=> %(embedding)s::vector) AS similarity
+            FROM article_vectors v
+            JOIN articles a ON a.id = v.article_id
+            WHERE v.article_id != %(article_id)s AND a.status = 'published'
+              AND (%(language)s::text IS NULL OR a.language = %(language)s)
+            ORDER BY v.embedding <=> %(embedding)s::vector
+            LIMIT %(limit)s
+        """, {'embedding': source['embedding'], 'article_id': article_id, 'language': language, 'limit': limit})
+        neighbours = [[str(row['article_id']), round(float(row['similarity']), 4)] for row in cursor.fetchall()]
+        try:
+            get_redis().setex(key, CACHE_TTL_SECONDS, json.dumps(neighbours))
+        except Exception as e:
+            logger.warning(f"Related articles cache write failed for {article_id}: {e}")
+
+    if not neighbours:
+        return []
+    similarity = dict(neighbours)
+    cursor.execute("SELECT * FROM articles WHERE id = ANY(%s::uuid[]) AND status = 'published'", (list(similarity),))
+    articles = {str(row['id']): dict(row) for row in cursor.fetchall()}
+    return [
+        {**articles[neighbour_id], 'similarity': similarity[neighbour_id]}
+        for neighbour_id, _ in neighbours if neighbour_id in articles
+    ]
+
+
+def enqueue_embedding(cursor, article: Dict[str, Any]):
+    """Publish hook: embed newly published articles"""
+    if not enabled():
+        return
+    from shared.jobs import embed_article as embed_article_job
+
+    embed_article_job.apply_async(args=[str(article['id'])], countdown=5)
@@ -0,0 +1,414 @@
+"""
+Versioned contracts for domain events
+
+Domain events leave this process for consumers in other languages (the Go
+services read them off the broker), so the `data` of each event type has a
+contract: numbered versions declaring its fields. CONTRACTS holds every
+version of every type. `scripts/export_event_schemas.py` renders them as
+JSON Schema under schemas/events/ for the other side to generate its types
+from and, with SCHEMA_REGISTRY_URL, registers them with a
+Confluent-compatible schema registry (Confluent, Redpanda, Apicurio's ccompat
+API), whose compatibility rules then guard the subjects too. The FastAPI
+process registers them at startup as well.
+
+Producers: `record_event` stamps each envelope with its type's current
+`schema_version` and checks the data against that version.
+EVENT_SCHEMA_VALIDATION decides what a mismatch does: `warn` (default) logs
+it, `enforce` quarantines the event for the relay instead of publishing it,
+without failing the change that recorded it (for development and CI), and
+`off` skips the check. The relay quarantines events whose data breaks their
+version's contract rather than publish them.
+
+Evolving a contract: a version may gain optional fields, which tolerant
+readers ignore. Anything else (removing, renaming or retyping a field, or
+making one required) is a new version, with converters to it from the
+version before and back in CONVERTERS. `export_event_schemas.py --check`
+fails when a committed version changed in any other way.
+
+Version negotiation: the relay publishes each type at its current version
+unless EVENT_SCHEMA_PUBLISH_VERSIONS pins an older one
+(`article.published=1`), so producers can move ahead of their consumers.
+A consumer states the versions it reads and gets the newest both sides know
+from `negotiate` or /api/v1/event-schemas/{type}?accept=1,2. Python consumers
+read through `read`, which converts an event to the version they ask for and
+fills in optional fields it lacks, keeping the ones it doesn't know.
+Events recorded before contracts existed count as version 1.
+"""
+
+import os
+import json
+import uuid
+import logging
+from datetime import datetime
+from functools import lru_cache
+from typing import Any, Callable, Dict, Iterable, List, Optional, Tuple
+
+logger = logging.getLogger(__name__)
+
+VALIDATION = os.getenv('EVENT_SCHEMA_VALIDATION', 'warn').lower()
+REGISTRY_URL = os.getenv('SCHEMA_REGISTRY_URL', '').rstrip('/')
+REGISTRY_TIMEOUT_SECONDS = float(os.getenv('SCHEMA_REGISTRY_TIMEOUT_SECONDS', 5))
+SUBJECT_PREFIX = os.getenv('EVENT_BUS_SUBJECT_PREFIX', 'news')
+
+JSON_SCHEMA_DIALECT = 'https://json-schema.org/draft/2020-12/schema'
+
+# Event type -> version -> fields as (name, type, required); types are `string`, `uuid`,
+# `date-time`, `integer`, `number`, `boolean`, `object` or `array<type>`, and end in `?` when null
+# is allowed. Required fields are always present; optional ones may be missing.
+CONTRACTS: Dict[str, Dict[int, List[Tuple[str, str, bool]]]] = {
+    'article.published': {
+        1: [
+            ('id', 'uuid', True),
+            ('author_id', 'uuid?', True),  # Null for anonymous articles
+            ('title', 'string?', True),
+            ('category', 'string?', True),
+            ('tags', 'array<string>', True),
+            ('language', 'string?', True),
+            ('published_at', 'date-time?', True),
+        ],
+    },
+    'interaction.recorded': {
+        1: [
+            ('user_id', 'uuid', True),
+            ('article_id', 'uuid', True),
+            ('interaction_type', 'string', True),
+            ('interaction_strength', 'number?', False),
+            ('reading_progress', 'number?', False),
+            ('time_spent', 'integer?', False),
+            ('device_type', 'string?', False),
+            ('reaction', 'string', False),
+            ('claps', 'integer', False),
+            ('platform', 'string?', False),
+        ],
+    },
+    'user.registered': {
+        1: [
+            ('id', 'uuid', True),
+            ('username', 'string', True),
+            ('role', 'string?', True),
+        ],
+    },
+    'article.corrected': {
+        1: [
+            ('article_id', 'uuid', True),
+            ('correction_id', 'uuid', True),
+            ('revision_number', 'integer', True),
+            ('contributor_id', 'uuid?', True),
+        ],
+    },
+    'draft.commented': {
+        1: [
+            ('article_id', 'uuid', True),
+            ('comment_id', 'string', True),
+            ('kind', 'string', True),
+            ('action', 'string', True),
+            ('actor_id', 'uuid', True),
+            ('recipients', 'array<uuid>', True),
+        ],
+    },
+    'comment.posted': {
+        1: [
+            ('article_id', 'uuid', True),
+            ('comment_id', 'uuid', True),
+            ('parent_comment_id', 'uuid?', True),
+            ('user_id', 'uuid', True),
+            ('anonymous', 'boolean', True),
+            ('excerpt', 'string', True),
+            ('article_author_id', 'uuid?', True),
+            ('parent_author_id', 'uuid?', True),
+        ],
+    },
+    'moderation.decided': {
+        1: [
+            ('subject', 'string', True),
+            ('subject_id', 'string', True),
+            ('article_id', 'uuid', True),
+            ('decision', 'string', True),
+            ('note', 'string?', True),
+            ('recipients', 'array<uuid>', True),
+        ],
+    },
+    'editorial.transitioned': {
+        1: [
+            ('review_id', 'uuid', True),
+            ('article_id', 'uuid', True),
+            ('action', 'string', True),
+            ('status', 'string', True),
+            ('actor_id', 'uuid', True),
+            ('assigned_to', 'uuid?', True),
+            ('note', 'string?', True),
+            ('recipients', 'array<uuid>', True),
+        ],
+    },
+    'article.credited': {
+        1: [
+            ('article_id', 'uuid', True),
+            ('user_id', 'uuid', True),
+            ('role', 'string', True),
+            ('action', 'string', True),
+            ('actor_id', 'uuid', True),
+            ('recipients', 'array<uuid>', True),
+        ],
+    },
+}
+
+# (event type, version) -> (upgrade from version - 1, downgrade to version - 1), each taking and returning `data`
+CONVERTERS: Dict[Tuple[str, int], Tuple[Callable[[Dict[str, Any]], Dict[str, Any]],
+                                        Callable[[Dict[str, Any]], Dict[str, Any]]]] = {}
+
+
+class SchemaViolation(ValueError):
+    """Event data that breaks its version's contract"""
+
+
+class UnsupportedVersion(ValueError):
+    """No version both sides know, or no way to convert between them"""
+
+
+# Versions
+def versions(event_type: str) -> List[int]:
+    if event_type not in CONTRACTS:
+        raise UnsupportedVersion(f"Unknown event type '{event_type}'")
+    return sorted(CONTRACTS[event_type])
+
+
+def current_version(event_type: str) -> int:
+    return versions(event_type)[-1]
+
+
+@lru_cache(maxsize=1)
+def _pins() -> Dict[str, int]:
+    pins = {}
+    for entry in os.getenv('EVENT_SCHEMA_PUBLISH_VERSIONS', '').split(','):
+        event_type, _, version = entry.strip().partition('=')
+        if not event_type:
+            continue
+        if not version.isdigit() or int(version) not in CONTRACTS.get(event_type, {}):
+            logger.warning(f"Ignoring EVENT_SCHEMA_PUBLISH_VERSIONS entry '{entry.strip()}': no such version")
+            continue
+        pins[event_type] = int(version)
+    return pins
+
+
+def published_version(event_type: str) -> int:
+    """The version the relay publishes a type at: its pin, or the current version"""
+    return _pins().get(event_type) or current_version(event_type)
+
+
+def negotiate(event_type: str, accepted: Iterable[int]) -> int:
+    """The newest version of a type that a consumer reading `accepted` and this producer both know"""
+    common = set(versions(event_type)) & {int(version) for version in accepted}
+    if not common:
+        raise UnsupportedVersion(f"No version of {event_type} in common; this producer has {versions(event_type)}")
+    return max(common)
+
+
+def version_of(payload: Dict[str, Any]) -> int:
+    return int(payload.get('schema_version') or 1)
+
+
+# Validation
+def _type_problem(value: Any, field_type: str) -> Optional[str]:
+    """Why a value isn't of a declared type, or None"""
+    if field_type.endswith('?'):
+        if value is None:
+            return None
+        field_type = field_type[:-1]
+    if value is None:
+        return "is null"
+    if field_type.startswith('array<'):
+        if not isinstance(value, list):
+            return "is not an array"
+        for index, item in enumerate(value):
+            problem = _type_problem(item, field_type[len('array<'):-1])
+            if problem:
+                return f"item {index} {problem}"
+        return None
+    if field_type in ('string', 'uuid', 'date-time'):
+        if not isinstance(value, str):
+            return "is not a string"
+        try:
+            if field_type == 'uuid':
+                uuid.UUID(value)
+            elif field_type == 'date-time':
+                datetime.fromisoformat(value.replace('Z', '+00:00'))
+        except ValueError:
+            return f"is not a {field_type}"
+        return None
+    if field_type == 'integer':
+        return None if isinstance(value, int) and not isinstance(value, bool) else "is not an integer"
+    if field_type == 'number':
+        return None if isinstance(value, (int, float)) and not isinstance(value, bool) else "is not a number"
+    if field_type == 'boolean':
+        return None if isinstance(value, bool) else "is not a boolean"
+    if field_type == 'object':
+        return None if isinstance(value, dict) else "is not an object"
+    return f"has unknown type {field_type}"
+
+
+def problems(event_type: str, data: Any, version: Optional[int] = None) -> List[str]:
+    """Where `data` breaks the contract of `version` (default current); fields it doesn't declare are fine"""
+    version = version or current_version(event_type)
+    fields = CONTRACTS.get(event_type, {}).get(version)
+    if fields is None:
+        return [f"{event_type} has no version {version}"]
+    if not isinstance(data, dict):
+        return ["data is not an object"]
+    found = []
+    for name, field_type, required in fields:
+        if name not in data:
+            if required:
+                found.append(f"{name} is missing")
+            continue
+        problem = _type_problem(data[name], field_type)
+        if problem:
+            found.append(f"{name} {problem}")
+    return found
+
+
+def check(event_type: str, data: Dict[str, Any]):
+    """Producer-side check of new event data against the current version, as EVENT_SCHEMA_VALIDATION says"""
+    if VALIDATION == 'off':
+        return
+    found = problems(event_type, data)
+    if not found:
+        return
+    message = f"{event_type} v{current_version(event_type)} contract broken: {'; '.join(found)}"
+    if VALIDATION == 'enforce':
+        raise SchemaViolation(message)
+    logger.warning(message)
+
+
+# Conversion and tolerant reading
+def convert(payload: Dict[str, Any], version: int) -> Dict[str, Any]:
+    """The event with its data moved to `version` through CONVERTERS, one version at a time"""
+    event_type, at = payload['type'], version_of(payload)
+    if version not in CONTRACTS.get(event_type, {}):
+        raise UnsupportedVersion(f"{event_type} has no version {version}")
+    data = dict(payload['data'])
+    while at != version:
+        step = at + 1 if version > at else at
+        converters = CONVERTERS.get((event_type, step))
+        if not converters:
+            raise UnsupportedVersion(f"No converter between {event_type} v{step - 1} and v{step}")
+        data = converters[0](data) if version > at else converters[1](data)
+        at = step if version > at else step - 1
+    return {**payload, 'schema_version': version, 'data': data}
+
+
+def outgoing(payload: Dict[str, Any]) -> Dict[str, Any]:
+    """The event as the broker should carry it: at its type's published version"""
+    version = published_version(payload['type'])
+    return payload if version == version_of(payload) else convert(payload, version)
+
+
+def read(payload: Dict[str, Any], accepted: Optional[Iterable[int]] = None) -> Dict[str, Any]:
+    """The event as a consumer reading `accepted` versions (default the current one) should see it
+
+    The data is converted to the newest version the consumer knows, and
+    optional fields it lacks are present as None. Fields the contract
+    doesn't declare are kept; the reader is expected to ignore them.
+    """
+    event_type = payload['type']
+    version = negotiate(event_type, accepted or [current_version(event_type)])
+    converted = convert(payload, version) if version != version_of(payload) else dict(payload)
+    data = dict(converted['data'])
+    for name, _, required in CONTRACTS[event_type][version]:
+        if not required:
+            data.setdefault(name, None)
+    return {**converted, 'schema_version': version, 'data': data}
+
+
+# JSON Schema
+def _json_type(field_type: str) -> Dict[str, Any]:
+    nullable = field_type.endswith('?')
+    field_type = field_type.rstrip('?')
+    if field_type.startswith('array<'):
+        schema = {'type': 'array', 'items': _json_type(field_type[len('array<'):-1])}
+    elif field_type in ('uuid', 'date-time'):
+        schema = {'type': 'string', 'format': field_type}
+    else:
+        schema = {'type': field_type}
+    if nullable:
+        schema['type'] = [schema['type'], 'null']
+    return schema
+
+
+def json_schema(event_type: str, version: int) -> Dict[str, Any]:
+    """The whole event envelope at a version as JSON Schema; unknown properties are allowed everywhere"""
+    fields = CONTRACTS.get(event_type, {}).get(version)
+    if fields is None:
+        raise UnsupportedVersion(f"{event_type} has no version {version}")
+    return {
+        '$schema': JSON_SCHEMA_DIALECT,
+        '$id': f"urn:news:events:{event_type}:v{version}",
+        'title': f"{event_type} v{version}",
+        'type': 'object',
+        'properties': {
+            'id': {'type': 'string', 'format': 'uuid'},
+            'type': {'const': event_type},
+            'schema_version': {'const': version},
+            'aggregate_id': {'type': ['string', 'null']},
+            'occurred_at': {'type': 'string', 'format': 'date-time'},
+            'data': {
+                'type': 'object',
+                'properties': {name: _json_type(field_type) for name, field_type, _ in fields},
+                'required': [name for name, _, required in fields if required],
+                'additionalProperties': True,
+            },
+        },
+        'required': ['id', 'type', 'occurred_at', 'data'],
+        'additionalProperties': True,
+    }
+
+
+def compatibility_problems(committed: Dict[str, Any], rendered: Dict[str, Any]) -> List[str]:
+    """How a rendered version differs from its committed schema beyond adding optional fields"""
+    before, after = committed['properties']['data'], rendered['properties']['data']
+    found = [f"{name} was removed" for name in before['properties'] if name not in after['properties']]
+    found += [
+        f"{name} changed type" for name, schema in before['properties'].items()
+        if name in after['properties'] and after['properties'][name] != schema
+    ]
+    if set(after['required']) != set(before['required']):
+        found.append("required fields changed")
+    return found
+
+
+# Schema registry
+def subject(event_type: str) -> str:
+    """The registry subject of a type: its broker topic, value side"""
+    return f"{SUBJECT_PREFIX}.{event_type}-value"
+
+
+def register_all(url: Optional[str] = None) -> Dict[str, Any]:
+    """Register every version of every type with the schema registry, oldest first
+
+    Returns the registry's id per `<type> v<version>`, or the error it gave.
+    The registry refuses versions its compatibility rules don't allow.
+    """
+    import requests
+
+    url = (url or REGISTRY_URL).rstrip('/')
+    if not url:
+        return {}
+    results: Dict[str, Any] = {}
+    for event_type in CONTRACTS:
+        for version in versions(event_type):
+            key = f"{event_type} v{version}"
+            try:
+                response = requests.post(
+                    f"{url}/subjects/{subject(event_type)}/versions",
+                    json={'schemaType': 'JSON', 'schema': json.dumps(json_schema(event_type, version))},
+                    headers={'Content-Type': 'application/vnd.schemaregistry.v1+json'},
+                    timeout=REGISTRY_TIMEOUT_SECONDS,
+                )
+                if response.ok:
+                    results[key] = response.json().get('id')
+                else:
+                    results[key] = {'error': response.text[:500], 'status': response.status_code}
+                    logger.error(f"Schema registry refused {key}: {response.status_code} {response.text[:200]}")
+            except requests.RequestException as e:
+                results[key] = {'error': str(e)}
+                logger.error(f"Could not register {key} with the schema registry: {e}")
+    return results
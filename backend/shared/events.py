@@ -0,0 +1,420 @@
+"""
+Domain event bus for both Flask and FastAPI backends
+
+Request handlers record domain events in the `event_outbox` table using the
+same cursor as the change itself, so an event exists exactly when its change
+commits and publishing never sits on the request path. The relay drains the
+outbox to the broker selected by EVENT_BUS_BACKEND:
+
+    none   - events stay in the outbox (default)
+    log    - events are written to the application log
+    nats   - NATS JetStream, subject <prefix>.<event type>
+    kafka  - Kafka, topic <prefix>.<event type>, keyed by aggregate id
+
+Consumers such as analytics, recommendations and notifications subscribe to
+the broker instead of being called from handlers. Each type's data follows a
+versioned contract (see `shared.event_schemas`), and the envelope says which
+version it carries in `schema_version`. Interaction events also go
+to the ClickHouse clickstream sink when it is configured (see
+`shared.clickstream`), and events that concern particular users become
+real-time notifications (see `shared.notifications`), with or without a
+broker.
+"""
+
+import os
+import json
+import asyncio
+import logging
+from datetime import datetime
+from typing import Any, Dict, List, Optional, Tuple
+
+from shared.database import get_postgres_cursor, prepare_json_data
+from shared import event_schemas
+from shared.utils import generate_uuid, safe_json_dumps
+
+logger = logging.getLogger(__name__)
+
+# Domain event types
+ARTICLE_PUBLISHED = 'article.published'
+INTERACTION_RECORDED = 'interaction.recorded'
+USER_REGISTERED = 'user.registered'
+ARTICLE_CORRECTED = 'article.corrected'
+DRAFT_COMMENTED = 'draft.commented'
+COMMENT_POSTED = 'comment.posted'
+MODERATION_DECIDED = 'moderation.decided'
+EDITORIAL_TRANSITIONED = 'editorial.transitioned'
+ARTICLE_CREDITED = 'article.credited'
+
+EVENT_TYPES = [
+    ARTICLE_PUBLISHED, INTERACTION_RECORDED, USER_REGISTERED, ARTICLE_CORRECTED, DRAFT_COMMENTED,
+    COMMENT_POSTED, MODERATION_DECIDED, EDITORIAL_TRANSITIONED, ARTICLE_CREDITED,
+]
+
+
+def record_event(cursor, event_type: str, aggregate_id: Optional[str], data: Dict[str, Any]) -> str:
+    """Write a domain event to the outbox inside the caller's transaction
+
+    Neither a failed write nor an event breaking its contract aborts the
+    caller's change: under EVENT_SCHEMA_VALIDATION=enforce the event is
+    quarantined for the relay (shared/dead_letters.py) rather than published.
+    """
+    if event_type not in EVENT_TYPES:
+        raise ValueError(f"Unknown event type '{event_type}'")
+
+    event_id = generate_uuid()
+    payload = json.loads(safe_json_dumps({
+        'id': event_id,
+        'type': event_type,
+        'schema_version': event_schemas.current_version(event_type),
+        'aggregate_id': aggregate_id,
+        'occurred_at': datetime.now().isoformat(),
+        'data': data,
+    }))
+
+    try:
+        cursor.execute("SAVEPOINT record_event")
+        # Checked as consumers will see it, after encoding
+        try:
+            event_schemas.check(event_type, payload['data'])
+            violation = None
+        except event_schemas.SchemaViolation as e:
+            violation = str(e)
+        cursor.execute("""
+            INSERT INTO event_outbox (id, event_type, aggregate_id, payload, dead_lettered_at)
+            VALUES (%s, %s, %s, %s, %s)
+        """, (event_id, event_type, aggregate_id, prepare_json_data(payload), datetime.now() if violation else None))
+        if violation:
+            from shared.dead_letters import OUTBOX, quarantine
+            quarantine(OUTBOX, {
+                'id': event_id, 'event_type': event_type, 'aggregate_id': aggregate_id, 'payload': payload,
+            }, violation, cursor)
+        cursor.execute("RELEASE SAVEPOINT record_event")
+    except Exception as e:
+        cursor.execute("ROLLBACK TO SAVEPOINT record_event")
+        logger.error(f"Failed to record {event_type} event: {e}")
+    return event_id
+
+
+class EventPublisher:
+    """Broker publisher interface"""
+
+    async def connect(self):
+        pass
+
+    async def publish(self, topic: str, key: Optional[str], body: bytes):
+        raise NotImplementedError
+
+    async def close(self):
+        pass
+
+
+class LogPublisher(EventPublisher):
+    async def publish(self, topic: str, key: Optional[str], body: bytes):
+        logger.info(f"Event {topic} [{key}]: {body.decode()}")
+
+
+class NatsPublisher(EventPublisher):
+    """Publishes to NATS JetStream; the stream must cover the subject prefix"""
+
+    def __init__(self):
+        self.servers = os.getenv('NATS_URL', 'nats://localhost:4222').split(',')
+        self.stream = os.getenv('NATS_STREAM', 'NEWS_EVENTS')
+        self._client = None
+        self._jetstream = None
+
+    async def connect(self):
+        import nats
+
+        self._client = await nats.connect(servers=self.servers)
+        self._jetstream = self._client.jetstream()
+
+    async def publish(self, topic: str, key: Optional[str], body: bytes):
+        # The event id doubles as the JetStream message id so redelivered outbox rows are deduplicated
+        message_id = json.loads(body)['id']
+        await self._jetstream.publish(topic, body, stream=self.stream, headers={'Nats-Msg-Id': message_id})
+
+    async def close(self):
+        if self._client:
+            await self._client.drain()
+
+
+class KafkaPublisher(EventPublisher):
+    def __init__(self):
+        self.bootstrap_servers = os.getenv('KAFKA_BOOTSTRAP_SERVERS', 'localhost:9092').split(',')
+        self._producer = None
+
+    async def connect(self):
+        from kafka import KafkaProducer
+
+        self._producer = await asyncio.to_thread(
+            KafkaProducer, bootstrap_servers=self.bootstrap_servers, acks='all', retries=3
+        )
+
+    async def publish(self, topic: str, key: Optional[str], body: bytes):
+        future = self._producer.send(topic, value=body, key=key.encode() if key else None)
+        await asyncio.to_thread(future.get, 10)
+
+    async def close(self):
+        if self._producer:
+            await asyncio.to_thread(self._producer.close)
+
+
+PUBLISHERS = {
+    'log': LogPublisher,
+    'nats': NatsPublisher,
+    'kafka': KafkaPublisher,
+}
+
+
+class OutboxRelay:
+    """Drains the event outbox to the configured broker"""
+
+    def __init__(self):
+        self.backend = os.getenv('EVENT_BUS_BACKEND', 'none').lower()
+        self.prefix = os.getenv('EVENT_BUS_SUBJECT_PREFIX', 'news')
+        self.batch_size = int(os.getenv('EVENT_BUS_BATCH_SIZE', 100))
+        self.poll_seconds = float(os.getenv('EVENT_BUS_POLL_SECONDS', 1))
+        self.publisher: Optional[EventPublisher] = None
+
+    @property
+    def enabled(self) -> bool:
+        from shared import clickstream, notifications
+
+        return self.backend in PUBLISHERS or clickstream.enabled() or notifications.enabled()
+
+    def topic_for(self, event_type: str) -> str:
+        return f"{self.prefix}.{event_type}"
+
+    async def run(self):
+        """Relay until cancelled"""
+        if not self.enabled:
+            logger.info(f"Event bus backend '{self.backend}' does not publish; events stay in the outbox")
+            return
+
+        if self.backend in PUBLISHERS:
+            self.publisher = PUBLISHERS[self.backend]()
+            await self.publisher.connect()
+            logger.info(f"Event bus relay publishing to {self.backend}")
+        else:
+            logger.info("Event bus relay feeding the clickstream sink and notifications only")
+
+        try:
+            while True:
+                try:
+                    relayed = await self.relay_batch()
+                    if relayed:
+                        continue
+                except Exception as e:
+                    logger.error(f"Event relay error: {e}")
+                await asyncio.sleep(self.poll_seconds)
+        finally:
+            if self.publisher:
+                await self.publisher.close()
+
+    async def relay_batch(self) -> int:
+        from shared import clickstream, notifications
+        from shared.dead_letters import MAX_ATTEMPTS, malformed
+
+        events = await asyncio.to_thread(self._claim_batch)
+        published: List[str] = []
+        failed: Dict[str, str] = {}
+        # Events to quarantine (shared/dead_letters.py), with why
+        poisoned: Dict[str, str] = {}
+        # An event out of attempts, quarantined only if the broker takes the next event
+        suspect: Optional[str] = None
+
+        for event in events:
+            event_id = str(event['id'])
+            problem = malformed(event)
+            if problem:
+                poisoned[event_id] = problem
+                continue
+            try:
+                if self.publisher:
+                    body = json.dumps(event_schemas.outgoing(event['payload']), separators=(',', ':')).encode()
+                    await self.publisher.publish(self.topic_for(event['event_type']), event['aggregate_id'], body)
+                published.append(event_id)
+                if suspect:
+                    poisoned[suspect] = f"Broker refused it {MAX_ATTEMPTS} times: {failed.pop(suspect)}"
+                    suspect = None
+            except Exception as e:
+                failed[event_id] = str(e)[:1000]
+                if suspect is None and event['publish_attempts'] + 1 >= MAX_ATTEMPTS:
+                    suspect = event_id
+                    continue
+                # Keep ordering: stop at the first failure and retry it on the next poll
+                break
+
+        if clickstream.enabled() and published:
+            relayed = set(published)
+            await asyncio.to_thread(clickstream.buffer, [event for event in events if str(event['id']) in relayed])
+        if notifications.enabled() and published:
+            relayed = set(published)
+            await asyncio.to_thread(notifications.fan_out, [event for event in events if str(event['id']) in relayed])
+
+        done = set(published) | set(failed) | set(poisoned)
+        skipped = [str(event['id']) for event in events if str(event['id']) not in done]
+        quarantined = [(event, poisoned[str(event['id'])]) for event in events if str(event['id']) in poisoned]
+        await asyncio.to_thread(self._mark, published, failed, skipped, quarantined)
+        return len(published) + len(quarantined)
+
+    def _claim_batch(self) -> List[Dict[str, Any]]:
+        # Lease the batch so relays in other worker processes skip it
+        with get_postgres_cursor() as cursor:
+            cursor.execute("""
+                UPDATE event_outbox SET claimed_until = NOW() + INTERVAL '60 seconds'
+                WHERE id IN (
+                    SELECT id FROM event_outbox
+                    WHERE published_at IS NULL AND dead_lettered_at IS NULL
+                    AND (claimed_until IS NULL OR claimed_until < NOW())
+                    ORDER BY occurred_at ASC
+                    LIMIT %s
+                    FOR UPDATE SKIP LOCKED
+                )
+                RETURNING id, event_type, aggregate_id, payload, occurred_at, publish_attempts
+            """, (self.batch_size,))
+            return sorted((dict(row) for row in cursor.fetchall()), key=lambda event: event['occurred_at'])
+
+    def _mark(self, published: List[str], failed: Dict[str, str], skipped: List[str],
+              quarantined: List[Tuple[Dict[str, Any], str]]):
+        from shared.dead_letters import OUTBOX, quarantine
+
+        with get_postgres_cursor() as cursor:
+            if published:
+                cursor.execute("""
+                    UPDATE event_outbox SET published_at = NOW(), publish_attempts = publish_attempts + 1, last_error = NULL
+                    WHERE id::text = ANY(%s)
+                """, (published,))
+            for event_id, error in failed.items():
+                cursor.execute("""
+                    UPDATE event_outbox SET publish_attempts = publish_attempts + 1, last_error = %s, claimed_until = NULL
+                    WHERE id = %s
+                """, (error, event_id))
+            if skipped:
+                # Release events left unsent behind a failure so the next poll retries them in order
+                cursor.execute("UPDATE event_outbox SET claimed_until = NULL WHERE id::text = ANY(%s)", (skipped,))
+            for event, error in quarantined:
+                quarantine(OUTBOX, event, error, cursor)
+                cursor.execute("""
+                    UPDATE event_outbox
+                    SET dead_lettered_at = NOW(), publish_attempts = publish_attempts + 1, last_error = %s,
+                        claimed_until = NULL
+                    WHERE id = %s
+                """, (error[:1000], event['id']))
+
+
+# Global relay instance
+outbox_relay = OutboxRelay()
+
+
+# Convenience functions for the domain events
+def article_published(cursor, article: Dict[str, Any]):
+    """Publish hook: record that an article went live"""
+    record_event(cursor, ARTICLE_PUBLISHED, str(article['id']), {
+        'id': str(article['id']),
+        'author_id': None if article.get('anonymous_author') else str(article.get('author_id')),
+        'title': article.get('title'),
+        'category': article.get('category'),
+        'tags': list(article.get('tags') or []),
+        'language': article.get('language'),
+        'published_at': article.get('published_at'),
+    })
+
+
+def interaction_recorded(cursor, user_id: str, article_id: str, interaction_type: str, **details):
+    from shared.bot_detection import is_bot_request
+
+    # Bot traffic isn't engagement; consumers never see it
+    if is_bot_request():
+        return
+    record_event(cursor, INTERACTION_RECORDED, str(article_id), {
+        'user_id': str(user_id),
+        'article_id': str(article_id),
+        'interaction_type': interaction_type,
+        **details,
+    })
+
+
+def user_registered(cursor, user: Dict[str, Any]):
+    record_event(cursor, USER_REGISTERED, str(user['id']), {
+        'id': str(user['id']),
+        'username': user['username'],
+        'role': user.get('role'),
+    })
+
+
+def article_corrected(cursor, article_id: str, correction_id: str, revision_number: int, contributor_id: Optional[str]):
+    record_event(cursor, ARTICLE_CORRECTED, str(article_id), {
+        'article_id': str(article_id),
+        'correction_id': str(correction_id),
+        'revision_number': revision_number,
+        'contributor_id': contributor_id,
+    })
+
+
+def draft_commented(cursor, article_id: str, comment_id: str, kind: str, action: str,
+                    actor_id: str, recipients: List[str]):
+    """A comment or suggestion on a draft was added, answered or settled; `recipients` should be notified"""
+    record_event(cursor, DRAFT_COMMENTED, str(article_id), {
+        'article_id': str(article_id),
+        'comment_id': comment_id,
+        'kind': kind,
+        'action': action,
+        'actor_id': actor_id,
+        'recipients': recipients,
+    })
+
+
+def comment_posted(cursor, comment: Dict[str, Any], article_author_id: Optional[str], parent_author_id: Optional[str]):
+    """A reader commented on an article, or replied to a comment"""
+    record_event(cursor, COMMENT_POSTED, str(comment['article_id']), {
+        'article_id': str(comment['article_id']),
+        'comment_id': str(comment['id']),
+        'parent_comment_id': str(comment['parent_comment_id']) if comment.get('parent_comment_id') else None,
+        'user_id': str(comment['user_id']),
+        'anonymous': bool(comment.get('is_anonymous')),
+        'excerpt': comment['content'][:200],
+        'article_author_id': str(article_author_id) if article_author_id else None,
+        'parent_author_id': str(parent_author_id) if parent_author_id else None,
+    })
+
+
+def moderation_decided(cursor, subject: str, subject_id: str, article_id: str, decision: str,
+                       recipients: List[str], note: Optional[str] = None):
+    """A moderator decided an article review or a report; `recipients` should be told"""
+    record_event(cursor, MODERATION_DECIDED, str(article_id), {
+        'subject': subject,
+        'subject_id': str(subject_id),
+        'article_id': str(article_id),
+        'decision': decision,
+        'note': note,
+        'recipients': [str(user_id) for user_id in recipients],
+    })
+
+
+def editorial_transitioned(cursor, review: Dict[str, Any], action: str, actor_id: str,
+                           recipients: List[str], note: Optional[str] = None):
+    """A draft's editorial review moved on: submitted, assigned, decided or withdrawn"""
+    record_event(cursor, EDITORIAL_TRANSITIONED, str(review['article_id']), {
+        'review_id': str(review['id']),
+        'article_id': str(review['article_id']),
+        'action': action,
+        'status': review['status'],
+        'actor_id': str(actor_id),
+        'assigned_to': str(review['assigned_to']) if review.get('assigned_to') else None,
+        'note': note,
+        'recipients': [str(user_id) for user_id in recipients],
+    })
+
+
+def article_credited(cursor, article_id: str, user_id: str, role: str, action: str, actor_id: str,
+                     recipients: List[str]):
+    """An author's credit on an article changed: invited, accepted, declined, role changed, removed or left"""
+    record_event(cursor, ARTICLE_CREDITED, article_id, {
+        'article_id': article_id,
+        'user_id': user_id,
+        'role': role,
+        'action': action,
+        'actor_id': actor_id,
+        'recipients': recipients,
+    })
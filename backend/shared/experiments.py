@@ -0,0 +1,263 @@
+"""
+Article experiments
+
+An experiment tests alternative values of one aspect of an article, its
+headline or its thumbnail, against the original (the `control` variant).
+Signed-in viewers are assigned a variant deterministically from the
+experiment and their user id, so a reader sees the same variant on every
+page and every device; anonymous viewers always see the control and aren't
+counted.
+
+Each feed impression adds the viewer to the variant's impressions in Redis
+and opening the article after seeing it adds them to its conversions, so
+both count unique viewers. The evaluation job copies the counts to
+`experiment_results` and runs a two-proportion z-test of the best variant
+against each of the others. Once every comparison is significant the winner
+is promoted onto the article and the decision, with the numbers behind it,
+is recorded on the experiment. An experiment that runs out of time without a
+clear winner keeps the control.
+"""
+
+import os
+import math
+import hashlib
+import logging
+from datetime import datetime, timezone
+from typing import Any, Callable, Dict, List, Optional
+
+from shared.database import get_postgres_cursor, get_redis
+from shared.corrections import record_revision
+
+logger = logging.getLogger(__name__)
+
+RUNNING = 'running'
+CONCLUDED = 'concluded'
+CANCELLED = 'cancelled'
+CONTROL = 'control'
+VARIANT_KEYS = [CONTROL, 'b', 'c', 'd']
+
+SIGNIFICANCE_LEVEL = float(os.getenv('EXPERIMENT_SIGNIFICANCE_LEVEL', 0.05))
+MIN_IMPRESSIONS = int(os.getenv('EXPERIMENT_MIN_IMPRESSIONS', 500))
+MAX_DAYS = int(os.getenv('EXPERIMENT_MAX_DAYS', 14))
+COUNTER_TTL_SECONDS = (MAX_DAYS + 7) * 24 * 60 * 60
+
+
+def _show_headline(article: Any, value: str):
+    article.title = value
+
+
+def _promote_headline(cursor, experiment: Dict[str, Any], value: str, previous: Dict[str, Any]):
+    cursor.execute(
+        "UPDATE articles SET title = %s, updated_at = NOW() WHERE id = %s RETURNING *",
+        (value, experiment['article_id'])
+    )
+    record_revision(
+        cursor, dict(cursor.fetchone()), experiment['created_by'],
+        change_note="Winning headline from headline test", previous=previous
+    )
+
+
+def _show_thumbnail(article: Any, value: str):
+    article.image_urls = [value] + [url for url in article.image_urls if url != value]
+
+
+def _promote_thumbnail(cursor, experiment: Dict[str, Any], value: str, previous: Dict[str, Any]):
+    images = [value] + [url for url in previous.get('image_urls') or [] if url != value]
+    cursor.execute(
+        "UPDATE articles SET image_urls = %s, updated_at = NOW() WHERE id = %s", (images, experiment['article_id'])
+    )
+
+
+# How each kind of experiment shows a variant on an ArticleResponse, and how its winner is made permanent
+KINDS: Dict[str, Dict[str, Callable[..., None]]] = {
+    'headline': {'show': _show_headline, 'promote': _promote_headline},
+    'thumbnail': {'show': _show_thumbnail, 'promote': _promote_thumbnail},
+}
+
+
+def _counter_key(experiment_id: str, variant: str, counter: str) -> str:
+    return f"experiment:{experiment_id}:{variant}:{counter}"
+
+
+def assign(experiment: Dict[str, Any], viewer_id: str) -> Dict[str, Any]:
+    """The viewer's variant, the same for the life of the experiment"""
+    digest = hashlib.sha256(f"{experiment['id']}:{viewer_id}".encode()).hexdigest()
+    variants = experiment['variants']
+    return variants[int(digest[:8], 16) % len(variants)]
+
+
+def running_experiments(cursor, article_ids: List[str]) -> List[Dict[str, Any]]:
+    if not article_ids:
+        return []
+    cursor.execute(
+        "SELECT * FROM experiments WHERE article_id = ANY(%s::uuid[]) AND status = %s",
+        ([str(article_id) for article_id in article_ids], RUNNING)
+    )
+    return [dict(row) for row in cursor.fetchall()]
+
+
+def serve_variants(articles: List[Any], viewer_id: Optional[str]):
+    """Show the viewer their variants of the given ArticleResponse objects and count the impressions"""
+    if not viewer_id or not articles:
+        return
+    try:
+        with get_postgres_cursor() as cursor:
+            experiments = running_experiments(cursor, list({str(article.id) for article in articles}))
+        if not experiments:
+            return
+
+        by_article: Dict[str, List[Dict[str, Any]]] = {}
+        for experiment in experiments:
+            by_article.setdefault(str(experiment['article_id']), []).append(experiment)
+
+        pipeline = get_redis().pipeline()
+        for article in articles:
+            for experiment in by_article.get(str(article.id), []):
+                variant = assign(experiment, viewer_id)
+                if variant['key'] != CONTROL:
+                    # The control is whatever the article holds now, even if edited since the start
+                    KINDS[experiment['kind']]['show'](article, variant['value'])
+                article.experiment_variants[experiment['kind']] = variant['key']
+                key = _counter_key(experiment['id'], variant['key'], 'impressions')
+                pipeline.sadd(key, viewer_id)
+                pipeline.expire(key, COUNTER_TTL_SECONDS)
+        pipeline.execute()
+    except Exception as e:
+        # Experiments must never break a feed; viewers just see the originals
+        logger.warning(f"Serving experiment variants failed: {e}")
+
+
+def record_conversion(article_id: str, viewer_id: Optional[str]):
+    """Count the viewer opening an article whose variant they were shown"""
+    if not viewer_id:
+        return
+    try:
+        with get_postgres_cursor() as cursor:
+            experiments = running_experiments(cursor, [article_id])
+        redis_client = get_redis()
+        for experiment in experiments:
+            variant = assign(experiment, viewer_id)['key']
+            if redis_client.sismember(_counter_key(experiment['id'], variant, 'impressions'), viewer_id):
+                key = _counter_key(experiment['id'], variant, 'conversions')
+                redis_client.sadd(key, viewer_id)
+                redis_client.expire(key, COUNTER_TTL_SECONDS)
+    except Exception as e:
+        logger.warning(f"Recording experiment conversion for article {article_id} failed: {e}")
+
+
+def sync_results(cursor, experiment: Dict[str, Any]) -> List[Dict[str, Any]]:
+    """Copy the experiment's counts from Redis and return them per variant"""
+    redis_client = get_redis()
+    results = []
+    for variant in experiment['variants']:
+        impressions = redis_client.scard(_counter_key(experiment['id'], variant['key'], 'impressions'))
+        conversions = redis_client.scard(_counter_key(experiment['id'], variant['key'], 'conversions'))
+        cursor.execute("""
+            INSERT INTO experiment_results (experiment_id, variant, impressions, conversions, updated_at)
+            VALUES (%s, %s, %s, %s, NOW())
+            ON CONFLICT (experiment_id, variant) DO UPDATE
+            SET impressions = EXCLUDED.impressions, conversions = EXCLUDED.conversions, updated_at = NOW()
+        """, (experiment['id'], variant['key'], impressions, conversions))
+        results.append({'variant': variant['key'], 'impressions': impressions, 'conversions': conversions})
+    return results
+
+
+def get_results(cursor, experiment: Dict[str, Any]) -> List[Dict[str, Any]]:
+    """Stored counts per variant with their conversion rates"""
+    cursor.execute(
+        "SELECT variant, impressions, conversions FROM experiment_results WHERE experiment_id = %s",
+        (experiment['id'],)
+    )
+    stored = {row['variant']: row for row in cursor.fetchall()}
+    results = []
+    for variant in experiment['variants']:
+        row = stored.get(variant['key']) or {'impressions': 0, 'conversions': 0}
+        results.append({
+            'variant': variant['key'],
+            'value': variant['value'],
+            'impressions': row['impressions'],
+            'conversions': row['conversions'],
+            'rate': round(row['conversions'] / row['impressions'], 4) if row['impressions'] else 0.0,
+        })
+    return results
+
+
+def z_test(conversions_a: int, impressions_a: int, conversions_b: int, impressions_b: int) -> Dict[str, float]:
+    """Two-sided two-proportion z-test"""
+    rate_a = conversions_a / impressions_a
+    rate_b = conversions_b / impressions_b
+    pooled = (conversions_a + conversions_b) / (impressions_a + impressions_b)
+    error = math.sqrt(pooled * (1 - pooled) * (1 / impressions_a + 1 / impressions_b))
+    if error == 0:
+        return {'z': 0.0, 'p_value': 1.0}
+    z = (rate_a - rate_b) / error
+    return {'z': round(z, 4), 'p_value': math.erfc(abs(z) / math.sqrt(2))}
+
+
+def evaluate(results: List[Dict[str, Any]]) -> Dict[str, Any]:
+    """Whether one variant beats every other, with the comparisons behind the answer
+
+    The significance level is split across the comparisons (Bonferroni), since
+    the best of several variants is compared against each of the rest.
+    """
+    if any(result['impressions'] < MIN_IMPRESSIONS for result in results):
+        return {'significant': False, 'reason': f"Fewer than {MIN_IMPRESSIONS} impressions on some variants"}
+
+    best = max(results, key=lambda result: result['conversions'] / result['impressions'])
+    threshold = SIGNIFICANCE_LEVEL / (len(results) - 1)
+    comparisons = []
+    for other in results:
+        if other is best:
+            continue
+        test = z_test(best['conversions'], best['impressions'], other['conversions'], other['impressions'])
+        comparisons.append({'against': other['variant'], **test})
+
+    significant = all(comparison['p_value'] < threshold for comparison in comparisons)
+    return {
+        'significant': significant,
+        'best': best['variant'],
+        'threshold': threshold,
+        'comparisons': comparisons,
+        'reason': (f"{best['variant']} has the highest click-through and beats every other variant"
+                   if significant else "No variant is significantly better yet"),
+    }
+
+
+def conclude(cursor, experiment: Dict[str, Any], winner: str, decision: Dict[str, Any]) -> Dict[str, Any]:
+    """End the experiment, apply the winning value to the article unless it's the control, and record why"""
+    cursor.execute("SELECT * FROM articles WHERE id = %s FOR UPDATE", (experiment['article_id'],))
+    article = cursor.fetchone()
+    value = next(variant['value'] for variant in experiment['variants'] if variant['key'] == winner)
+    if article and winner != CONTROL:
+        KINDS[experiment['kind']]['promote'](cursor, experiment, value, dict(article))
+
+    decision = {**decision, 'winner': winner, 'decided_at': datetime.now(timezone.utc).isoformat()}
+    cursor.execute("""
+        UPDATE experiments SET status = %s, winner = %s, decision = %s, concluded_at = NOW()
+        WHERE id = %s AND status = %s
+        RETURNING *
+    """, (CONCLUDED, winner, decision, experiment['id'], RUNNING))
+    concluded = cursor.fetchone()
+    logger.info(f"Experiment {experiment['id']} on article {experiment['article_id']} concluded: {winner}")
+    return dict(concluded) if concluded else experiment
+
+
+def evaluate_experiment(cursor, experiment: Dict[str, Any]) -> Optional[str]:
+    """Sync an experiment's counts and conclude it if there's a winner or it has run out of time"""
+    sync_results(cursor, experiment)
+    results = get_results(cursor, experiment)
+    outcome = evaluate(results)
+    decision = {'results': results, **outcome}
+
+    if outcome['significant']:
+        return conclude(cursor, experiment, outcome['best'], decision)['winner']
+    age = datetime.now(timezone.utc) - experiment['started_at']
+    if age.days >= MAX_DAYS:
+        decision['reason'] = f"No significant winner after {MAX_DAYS} days; keeping the original"
+        return conclude(cursor, experiment, CONTROL, decision)['winner']
+    return None
+
+
+def running_experiment_ids(cursor) -> List[str]:
+    cursor.execute("SELECT id FROM experiments WHERE status = %s", (RUNNING,))
+    return [str(row['id']) for row in cursor.fetchall()]
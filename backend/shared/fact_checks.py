@@ -0,0 +1,85 @@
+"""
+Fact-check annotations
+
+Auditors attach a claim, a verdict and source citations to a span of text in a
+published article. A span is addressed by paragraph index and character
+offsets within that paragraph's text, where paragraphs are the article's
+block elements as plain text with whitespace collapsed, so markup changes
+don't move them.
+
+The checked text is stored with the fact check. If the article is later
+edited so the span no longer reads the same, the fact check is kept but
+flagged `stale`.
+"""
+
+import re
+from typing import Any, Dict, List, Optional
+
+from shared.readability import WORD, plain_text
+
+VERDICTS = ('true', 'false', 'misleading')
+
+FACT_CHECK_SELECT = """
+    SELECT f.*, u.username AS auditor
+    FROM fact_checks f
+    LEFT JOIN users u ON u.id = f.auditor_id
+"""
+
+
+class SpanInvalid(Exception):
+    """Raised when a paragraph or offset range doesn't exist in the article"""
+
+
+def paragraphs(content: str) -> List[str]:
+    """The article's text paragraphs that fact checks are anchored to"""
+    return [
+        ' '.join(paragraph.split())
+        for paragraph in re.split(r'\n\s*\n', plain_text(content))
+        if WORD.search(paragraph)
+    ]
+
+
+def quote(content: str, paragraph: int, start_offset: int, end_offset: int) -> str:
+    """The text a span covers"""
+    texts = paragraphs(content)
+    if paragraph >= len(texts):
+        raise SpanInvalid(f"The article has {len(texts)} paragraphs")
+    if end_offset > len(texts[paragraph]):
+        raise SpanInvalid(f"Paragraph {paragraph} is {len(texts[paragraph])} characters long")
+    text = texts[paragraph][start_offset:end_offset]
+    if not text.strip():
+        raise SpanInvalid("The range covers no text")
+    return text
+
+
+def _current_quote(texts: List[str], fact_check: Dict[str, Any]) -> Optional[str]:
+    if fact_check['paragraph'] >= len(texts):
+        return None
+    return texts[fact_check['paragraph']][fact_check['start_offset']:fact_check['end_offset']]
+
+
+def for_article(cursor, article: Dict[str, Any], visible_content: Optional[str] = None) -> List[Dict[str, Any]]:
+    """Fact checks on an article in reading order, each flagged stale if its text was edited
+
+    With `visible_content` (a paywall preview), only fact checks on text the
+    viewer can see are returned.
+    """
+    cursor.execute(
+        FACT_CHECK_SELECT + " WHERE f.article_id = %s ORDER BY f.paragraph, f.start_offset, f.created_at",
+        (str(article['id']),)
+    )
+    texts = paragraphs(article['content'])
+    visible = ' '.join(paragraphs(visible_content)) if visible_content is not None else None
+
+    fact_checks = []
+    for row in cursor.fetchall():
+        fact_check = dict(row)
+        if visible is not None and fact_check['quote'] not in visible:
+            continue
+        fact_check['stale'] = _current_quote(texts, fact_check) != fact_check['quote']
+        fact_checks.append(fact_check)
+    return fact_checks
+
+
+def mark_stale(fact_check: Dict[str, Any], content: str) -> Dict[str, Any]:
+    return {**fact_check, 'stale': _current_quote(paragraphs(content), fact_check) != fact_check['quote']}
@@ -0,0 +1,407 @@
+"""
+Federation with peer instances
+
+Every instance publishes a manifest of its own published articles (id, URL,
+timestamps and a content hash) and serves each article's federation payload.
+Peers pull the manifest on their configured refresh interval, fetch articles
+that are new or whose hash changed, and store them as local articles linked
+back to their origin.
+
+Each pull also revalidates what was pulled before: an article the origin no
+longer lists was retracted and is removed, and articles older than the peer's
+retention period expire. Removed content leaves a tombstone that is published
+so readers can see what was taken down and why.
+"""
+
+import os
+import base64
+import hashlib
+import logging
+from datetime import datetime, timedelta, timezone
+from typing import Any, Dict, List, Optional
+
+import requests
+
+from shared.database import get_postgres_cursor, get_redis
+from shared.feed_formats import article_url
+from shared.instance_policy import check_federated
+from shared.licensing import LICENSES
+from shared.readability import readability_columns
+from shared.peer_reputation import (
+    ACCEPTED_ARTICLE, PAUSED, THROTTLED, THROTTLED_INTERVAL_MULTIPLIER, THROTTLED_MAX_ARTICLES, VERIFICATION_FAILURE,
+    effective_state, record_signal, update_reputation
+)
+from shared.utils import calculate_reading_time, calculate_word_count, deserialize_datetime, encode_cursor, decode_cursor
+
+logger = logging.getLogger(__name__)
+
+MANIFEST_PAGE_SIZE = 500
+SYNC_LOCK_SECONDS = 30 * 60
+REQUEST_TIMEOUT_SECONDS = int(os.getenv('FEDERATION_REQUEST_TIMEOUT_SECONDS', 15))
+
+RETRACTED = 'retracted'
+EXPIRED = 'expired'
+POLICY = 'policy'
+PEER_REMOVED = 'peer_removed'
+
+# Hash over the fields a reader sees; the manifest computes the same value in SQL
+CONTENT_HASH_SQL = "encode(sha256(convert_to(concat_ws(E'\\n', a.title, COALESCE(a.summary, ''), a.content), 'UTF8')), 'hex')"
+
+# Only this instance's own free articles are federated onwards, never premium ones or copies pulled from peers
+LOCAL_PUBLISHED = """
+    a.status = 'published' AND a.access_policy IS NULL
+    AND NOT EXISTS (SELECT 1 FROM federated_articles f WHERE f.article_id = a.id)
+"""
+
+
+class ManifestMismatch(ValueError):
+    """A peer served an article that doesn't match its own manifest entry"""
+
+
+def content_hash(article: Dict[str, Any]) -> str:
+    text = '\n'.join([article.get('title') or '', article.get('summary') or '', article.get('content') or ''])
+    return hashlib.sha256(text.encode('utf-8')).hexdigest()
+
+
+def _utc(value: Any) -> Optional[datetime]:
+    if isinstance(value, str):
+        value = deserialize_datetime(value)
+    if value is None:
+        return None
+    return value if value.tzinfo else value.replace(tzinfo=timezone.utc)
+
+
+# Serving side
+def manifest_page(cursor, page_cursor: Optional[str], limit: int = MANIFEST_PAGE_SIZE) -> Dict[str, Any]:
+    """One page of this instance's manifest, in update order"""
+    query = f"""
+        SELECT a.id, a.published_at, a.updated_at, {CONTENT_HASH_SQL} AS content_hash
+        FROM articles a
+        WHERE {LOCAL_PUBLISHED}
+    """
+    params: List[Any] = []
+    if page_cursor:
+        position = decode_cursor(page_cursor)
+        updated_at = deserialize_datetime(position.get('updated_at')) if position else None
+        if not updated_at or not position.get('id'):
+            raise ValueError("Invalid cursor")
+        query += " AND (a.updated_at, a.id) > (%s, %s)"
+        params.extend([updated_at, position['id']])
+    query += " ORDER BY a.updated_at, a.id LIMIT %s"
+    params.append(limit + 1)
+
+    cursor.execute(query, params)
+    rows = cursor.fetchall()
+    has_more = len(rows) > limit
+    rows = rows[:limit]
+
+    return {
+        'articles': [
+            {
+                'id': str(row['id']),
+                'url': article_url(row),
+                'published_at': row['published_at'].isoformat() if row['published_at'] else None,
+                'updated_at': row['updated_at'].isoformat(),
+                'content_hash': row['content_hash'],
+            }
+            for row in rows
+        ],
+        'next_cursor': encode_cursor({'updated_at': rows[-1]['updated_at'].isoformat(), 'id': str(rows[-1]['id'])})
+        if has_more else None,
+        'has_more': has_more,
+    }
+
+
+def federation_payload(cursor, article_id: str) -> Optional[Dict[str, Any]]:
+    """What peers store for one of this instance's articles"""
+    cursor.execute(f"""
+        SELECT a.id, a.title, a.summary, a.content, a.category, a.tags, a.language, a.license,
+               a.published_at, a.updated_at,
+               CASE WHEN a.anonymous_author THEN NULL ELSE u.username END AS author_name
+        FROM articles a
+        LEFT JOIN users u ON u.id = a.author_id
+        WHERE a.id::text = %s AND {LOCAL_PUBLISHED}
+    """, (article_id,))
+    article = cursor.fetchone()
+    if not article:
+        return None
+
+    article = dict(article)
+    return {
+        **article,
+        'id': str(article['id']),
+        'url': article_url(article),
+        'tags': list(article['tags'] or []),
+        'published_at': article['published_at'].isoformat() if article['published_at'] else None,
+        'updated_at': article['updated_at'].isoformat(),
+        'content_hash': content_hash(article),
+    }
+
+
+def list_tombstones(cursor, limit: int = 50, peer_id: Optional[str] = None) -> List[Dict[str, Any]]:
+    query = """
+        SELECT id, peer_domain, remote_id, origin_url, title, reason, detail, removed_at
+        FROM federation_tombstones
+    """
+    params: List[Any] = []
+    if peer_id:
+        query += " WHERE peer_id = %s"
+        params.append(peer_id)
+    query += " ORDER BY removed_at DESC LIMIT %s"
+    params.append(limit)
+    cursor.execute(query, params)
+    return [dict(row) for row in cursor.fetchall()]
+
+
+# Pulling side
+def _get_json(peer: Dict[str, Any], path: str, params: Optional[Dict[str, Any]] = None) -> Dict[str, Any]:
+    response = requests.get(
+        f"{peer['base_url'].rstrip('/')}{path}", params=params,
+        headers={'Accept': 'application/json'}, timeout=REQUEST_TIMEOUT_SECONDS
+    )
+    response.raise_for_status()
+    return response.json()
+
+
+def peer_keys(peer: Dict[str, Any]) -> Dict[str, bytes]:
+    """A peer's node signing keys, raw Ed25519 public keys by key id"""
+    return {
+        key['keyid']: base64.b64decode(key['public_key'])
+        for key in _get_json(peer, '/api/v1/node/keys').get('keys', []) if key.get('alg') == 'ed25519'
+    }
+
+
+def fetch_manifest(peer: Dict[str, Any]) -> Dict[str, Dict[str, Any]]:
+    """A peer's complete manifest keyed by remote article id"""
+    manifest = {}
+    page_cursor = None
+    while True:
+        page = _get_json(peer, '/api/v1/node/manifest', {'cursor': page_cursor} if page_cursor else None)
+        for entry in page.get('articles', []):
+            manifest[str(entry['id'])] = entry
+        page_cursor = page.get('next_cursor')
+        if not page.get('has_more') or not page_cursor:
+            return manifest
+
+
+def record_tombstone(cursor, peer: Dict[str, Any], remote_id: str, reason: str, origin_url: Optional[str] = None,
+                     title: Optional[str] = None, hash_value: Optional[str] = None, detail: Optional[str] = None):
+    cursor.execute("""
+        INSERT INTO federation_tombstones (peer_id, peer_domain, remote_id, origin_url, title, content_hash, reason, detail)
+        VALUES (%s, %s, %s, %s, %s, %s, %s, %s)
+    """, (peer['id'], peer['domain'], remote_id, origin_url, title, hash_value, reason, detail))
+
+
+def remove_federated_article(cursor, peer: Dict[str, Any], link: Dict[str, Any], reason: str,
+                             detail: Optional[str] = None):
+    """Delete a federated copy, leaving a tombstone"""
+    record_tombstone(cursor, peer, link['remote_id'], reason, link['origin_url'], link.get('title'),
+                     link['content_hash'], detail)
+    cursor.execute("DELETE FROM articles WHERE id = %s", (link['article_id'],))
+
+
+def remove_peer_content(cursor, peer: Dict[str, Any], reason: str = PEER_REMOVED) -> int:
+    cursor.execute("""
+        SELECT f.*, a.title FROM federated_articles f
+        JOIN articles a ON a.id = f.article_id
+        WHERE f.peer_id = %s
+    """, (peer['id'],))
+    links = cursor.fetchall()
+    for link in links:
+        remove_federated_article(cursor, peer, dict(link), reason)
+    return len(links)
+
+
+def _is_policy_rejected(cursor, peer: Dict[str, Any], remote_id: str, hash_value: str) -> bool:
+    """Content refused by policy isn't fetched again until it changes"""
+    cursor.execute("""
+        SELECT 1 FROM federation_tombstones
+        WHERE peer_id = %s AND remote_id = %s AND content_hash = %s AND reason = %s
+    """, (peer['id'], remote_id, hash_value, POLICY))
+    return cursor.fetchone() is not None
+
+
+def _store_article(cursor, peer: Dict[str, Any], payload: Dict[str, Any], link: Optional[Dict[str, Any]]) -> str:
+    """Insert or refresh the local copy of a peer's article, returning its local id"""
+    values = {
+        'title': payload['title'][:500],
+        'content': payload['content'],
+        'summary': payload.get('summary'),
+        'category': payload.get('category') or 'general',
+        'tags': list(payload.get('tags') or []),
+        'language': payload.get('language') or 'en',
+        'license': payload.get('license') if payload.get('license') in LICENSES else 'all-rights-reserved',
+        'published_at': _utc(payload.get('published_at')),
+        'source_url': payload['url'],
+        'reading_time': calculate_reading_time(payload['content']),
+        'word_count': calculate_word_count(payload['content']),
+        'metadata': {'federation': {'peer': peer['domain'], 'author_name': payload.get('author_name')}},
+        **readability_columns(payload['content'], payload.get('language')),
+    }
+
+    if link:
+        assignments = ', '.join(f"{column} = %s" for column in values)
+        cursor.execute(
+            f"UPDATE articles SET {assignments}, updated_at = NOW() WHERE id = %s",
+            list(values.values()) + [link['article_id']]
+        )
+        cursor.execute("""
+            UPDATE federated_articles
+            SET content_hash = %s, origin_url = %s, remote_published_at = %s, remote_updated_at = %s,
+                fetched_at = NOW(), last_verified_at = NOW()
+            WHERE article_id = %s
+        """, (payload['content_hash'], payload['url'], values['published_at'],
+              _utc(payload.get('updated_at')), link['article_id']))
+        return str(link['article_id'])
+
+    columns = ', '.join(values)
+    placeholders = ', '.join(['%s'] * len(values))
+    cursor.execute(
+        f"INSERT INTO articles ({columns}, status) VALUES ({placeholders}, 'published') RETURNING id",
+        list(values.values())
+    )
+    article_id = cursor.fetchone()['id']
+    cursor.execute("""
+        INSERT INTO federated_articles
+        (article_id, peer_id, remote_id, origin_url, content_hash, remote_published_at, remote_updated_at)
+        VALUES (%s, %s, %s, %s, %s, %s, %s)
+    """, (article_id, peer['id'], payload['id'], payload['url'], payload['content_hash'],
+          values['published_at'], _utc(payload.get('updated_at'))))
+    return str(article_id)
+
+
+def get_peer(cursor, peer_id: str) -> Optional[Dict[str, Any]]:
+    cursor.execute("SELECT * FROM federation_peers WHERE id = %s", (peer_id,))
+    peer = cursor.fetchone()
+    return dict(peer) if peer else None
+
+
+def peers_due(cursor) -> List[str]:
+    """Peers whose refresh interval has elapsed; throttled peers wait longer"""
+    cursor.execute("""
+        SELECT id FROM federation_peers
+        WHERE is_active = true
+        AND (last_synced_at IS NULL OR last_synced_at + make_interval(
+            mins => refresh_interval_minutes * CASE WHEN COALESCE(reputation_override, ingestion_state) = %s
+                                                    THEN %s ELSE 1 END
+        ) <= NOW())
+    """, (THROTTLED, THROTTLED_INTERVAL_MULTIPLIER))
+    return [str(row['id']) for row in cursor.fetchall()]
+
+
+def sync_peer(peer_id: str) -> Dict[str, Any]:
+    """Pull a peer's manifest, revalidate what was pulled before and fetch new or changed articles"""
+    with get_postgres_cursor() as cursor:
+        peer = get_peer(cursor, peer_id)
+    if not peer or not peer['is_active']:
+        return {'skipped': True}
+
+    # The scheduler may queue a peer again while a long pull is still running
+    lock_key = f"federation_sync_lock:{peer_id}"
+    if not get_redis().set(lock_key, 1, nx=True, ex=SYNC_LOCK_SECONDS):
+        return {'skipped': True}
+    try:
+        return _sync_peer(peer)
+    finally:
+        get_redis().delete(lock_key)
+
+
+def _sync_peer(peer: Dict[str, Any]) -> Dict[str, Any]:
+    peer_id = str(peer['id'])
+    state = effective_state(peer)
+    summary = {'state': state, 'fetched': 0, 'updated': 0, RETRACTED: 0, EXPIRED: 0, POLICY: 0, 'failed': 0}
+    try:
+        manifest = fetch_manifest(peer)
+    except (requests.RequestException, ValueError, KeyError) as e:
+        logger.warning(f"Manifest pull from {peer['domain']} failed: {e}")
+        with get_postgres_cursor() as cursor:
+            cursor.execute(
+                "UPDATE federation_peers SET last_synced_at = NOW(), last_sync_error = %s WHERE id = %s",
+                (str(e)[:1000], peer_id)
+            )
+        return {**summary, 'error': str(e)}
+
+    cutoff = None
+    if peer['retention_days']:
+        cutoff = datetime.now(timezone.utc) - timedelta(days=peer['retention_days'])
+
+    with get_postgres_cursor() as cursor:
+        cursor.execute("""
+            SELECT f.*, a.title FROM federated_articles f
+            JOIN articles a ON a.id = f.article_id
+            WHERE f.peer_id = %s
+        """, (peer_id,))
+        local = {row['remote_id']: dict(row) for row in cursor.fetchall()}
+
+        for remote_id, link in list(local.items()):
+            published_at = _utc(link['remote_published_at'])
+            if remote_id not in manifest:
+                remove_federated_article(cursor, peer, link, RETRACTED, "No longer listed by the origin")
+                summary[RETRACTED] += 1
+            elif cutoff and published_at and published_at < cutoff:
+                remove_federated_article(cursor, peer, link, EXPIRED, f"Older than {peer['retention_days']} days")
+                summary[EXPIRED] += 1
+            else:
+                continue
+            del local[remote_id]
+
+        cursor.execute("UPDATE federated_articles SET last_verified_at = NOW() WHERE peer_id = %s", (peer_id,))
+
+    # A paused peer is still revalidated above, but nothing new is taken from it
+    pending = [] if state == PAUSED else list(manifest.items())
+    fetch_budget = THROTTLED_MAX_ARTICLES if state == THROTTLED else None
+
+    for remote_id, entry in pending:
+        published_at = _utc(entry.get('published_at'))
+        if cutoff and published_at and published_at < cutoff:
+            continue
+        link = local.get(remote_id)
+        if link and link['content_hash'] == entry.get('content_hash'):
+            continue
+        if fetch_budget is not None and summary['fetched'] + summary['updated'] >= fetch_budget:
+            summary['deferred'] = summary.get('deferred', 0) + 1
+            continue
+
+        try:
+            with get_postgres_cursor() as cursor:
+                if not link and _is_policy_rejected(cursor, peer, remote_id, entry.get('content_hash')):
+                    continue
+
+            payload = _get_json(peer, f"/api/v1/node/articles/{remote_id}")
+            if str(payload.get('id')) != remote_id or content_hash(payload) != entry.get('content_hash'):
+                raise ManifestMismatch("Article does not match the manifest entry")
+            payload['content_hash'] = entry['content_hash']
+
+            with get_postgres_cursor() as cursor:
+                decision = check_federated(peer['domain'], payload)
+                if not decision.allowed:
+                    if link:
+                        remove_federated_article(cursor, peer, link, POLICY, decision.reason)
+                    else:
+                        record_tombstone(cursor, peer, remote_id, POLICY, payload['url'], payload['title'],
+                                         entry['content_hash'], decision.reason)
+                    summary[POLICY] += 1
+                    continue
+
+                article_id = _store_article(cursor, peer, payload, link)
+                if not link:
+                    record_signal(cursor, peer_id, ACCEPTED_ARTICLE, article_id=article_id, remote_id=remote_id)
+                summary['updated' if link else 'fetched'] += 1
+        except ManifestMismatch as e:
+            logger.warning(f"Article {remote_id} from {peer['domain']} failed verification: {e}")
+            with get_postgres_cursor() as cursor:
+                record_signal(cursor, peer_id, VERIFICATION_FAILURE, remote_id=remote_id, detail=str(e))
+            summary['failed'] += 1
+        except Exception as e:
+            logger.warning(f"Could not federate article {remote_id} from {peer['domain']}: {e}")
+            summary['failed'] += 1
+
+    with get_postgres_cursor() as cursor:
+        cursor.execute(
+            "UPDATE federation_peers SET last_synced_at = NOW(), last_sync_error = NULL WHERE id = %s",
+            (peer_id,)
+        )
+        summary['reputation'] = update_reputation(cursor, peer)['score']
+
+    logger.info(f"Federation sync with {peer['domain']}: {summary}")
+    return summary
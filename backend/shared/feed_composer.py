@@ -0,0 +1,371 @@
+"""
+Home feed composition for the decentralized news platform
+
+Assembles the home response from named shelves. Which shelves appear, where
+they appear, how many items they hold and which source fills them is read from
+the `home_feed` settings key so editors can reshape the page without a deploy.
+"""
+
+import os
+import json
+import logging
+from datetime import datetime, timedelta
+from typing import Any, Callable, Dict, List, Optional, Set, Tuple
+
+from shared import tenancy
+from shared.database import get_postgres_cursor, get_redis
+from shared.models import ArticleResponse, FeedShelf, FeedShelfConfig, HomeFeedConfig, HomeFeedResponse, PinnedArticle
+from shared.curation import get_active_pins
+from shared.settings import get_setting
+from shared.utils import encode_cursor, decode_cursor, deserialize_datetime, generate_uuid
+from shared.visibility import hidden_authors
+
+logger = logging.getLogger(__name__)
+
+ShelfResult = Tuple[List[Dict[str, Any]], Optional[List[Dict[str, Any]]]]
+
+
+class FeedComposer:
+    """Builds the structured home feed from configured shelves"""
+
+    def __init__(self):
+        self.sources: Dict[str, Callable[..., ShelfResult]] = {
+            'breaking': self._breaking,
+            'following': self._following,
+            'trending': self._trending,
+            'for_you': self._for_you,
+            'topics': self._topics,
+            'latest': self._latest,
+        }
+
+    def load_config(self) -> HomeFeedConfig:
+        """Load the shelf configuration from the settings service"""
+        return HomeFeedConfig(**get_setting('home_feed'))
+
+    def compose(self, user: Optional[dict] = None) -> Tuple[List[PinnedArticle], List[FeedShelf]]:
+        """Compose active home pins and all enabled shelves in configured order"""
+        config = self.load_config()
+        shelves = sorted(
+            (shelf for shelf in config.shelves if shelf.enabled),
+            key=lambda shelf: shelf.position
+        )
+
+        seen_ids: Set[str] = set()
+        result = []
+
+        with get_postgres_cursor() as cursor:
+            pinned = get_active_pins(cursor, 'home')
+            seen_ids.update(str(pin.article.id) for pin in pinned)
+            # Shelves are filled first and shadow-banned or blocked authors taken out after, so a shelf can run short
+            hidden = set(hidden_authors(cursor, user))
+
+            for shelf in shelves:
+                source = self.sources.get(shelf.source)
+                if not source:
+                    logger.warning(f"Unknown feed shelf source '{shelf.source}' for shelf '{shelf.name}'")
+                    continue
+
+                exclude = seen_ids if config.deduplicate else set()
+                try:
+                    articles, topics = source(cursor, user, shelf, exclude)
+                except Exception as e:
+                    logger.error(f"Feed shelf '{shelf.name}' failed: {e}")
+                    continue
+
+                articles = [article for article in articles if str(article['author_id']) not in hidden]
+                for topic in topics or []:
+                    topic['articles'] = [
+                        article for article in topic['articles'] if str(article['author_id']) not in hidden
+                    ]
+                if not articles and not topics:
+                    continue
+
+                items = [ArticleResponse(**dict(article)) for article in articles]
+                seen_ids.update(str(item.id) for item in items)
+                seen_ids.update(str(article['id']) for topic in topics or [] for article in topic['articles'])
+
+                result.append(FeedShelf(
+                    name=shelf.name,
+                    title=shelf.title,
+                    source=shelf.source,
+                    position=shelf.position,
+                    items=items,
+                    topics=topics
+                ))
+
+        return pinned, result
+
+    def _breaking(self, cursor, user, shelf: FeedShelfConfig, exclude: Set[str]) -> ShelfResult:
+        window_hours = int(shelf.options.get('window_hours', 6))
+        cursor.execute("""
+            SELECT * FROM articles
+            WHERE status = 'published'
+            AND published_at >= %s
+            AND (COALESCE((metadata->>'breaking')::boolean, false) OR 'breaking' = ANY(tags))
+            AND NOT (id::text = ANY(%s))
+            ORDER BY published_at DESC
+            LIMIT %s
+        """, (datetime.now() - timedelta(hours=window_hours), list(exclude), shelf.count))
+        return cursor.fetchall(), None
+
+    def _following(self, cursor, user, shelf: FeedShelfConfig, exclude: Set[str]) -> ShelfResult:
+        if not user:
+            return [], None
+
+        cursor.execute("""
+            SELECT a.* FROM articles a
+            JOIN user_follows uf ON uf.following_id = a.author_id
+            WHERE uf.follower_id = %s
+            AND a.status = 'published'
+            AND a.anonymous_author = false
+            AND NOT (a.id::text = ANY(%s))
+            ORDER BY a.published_at DESC NULLS LAST
+            LIMIT %s
+        """, (user['id'], list(exclude), shelf.count))
+        return cursor.fetchall(), None
+
+    def _trending(self, cursor, user, shelf: FeedShelfConfig, exclude: Set[str]) -> ShelfResult:
+        window_days = int(shelf.options.get('window_days', 3))
+        cursor.execute("""
+            SELECT * FROM articles
+            WHERE status = 'published'
+            AND published_at >= %s
+            AND NOT (id::text = ANY(%s))
+            ORDER BY trending_score DESC, engagement_score DESC
+            LIMIT %s
+        """, (datetime.now() - timedelta(days=window_days), list(exclude), shelf.count))
+        return cursor.fetchall(), None
+
+    def _for_you(self, cursor, user, shelf: FeedShelfConfig, exclude: Set[str]) -> ShelfResult:
+        if not user:
+            return [], None
+
+        cursor.execute(
+            "SELECT categories, languages FROM user_preferences WHERE user_id = %s",
+            (user['id'],)
+        )
+        preferences = cursor.fetchone()
+        categories = (preferences or {}).get('categories') or []
+        languages = (preferences or {}).get('languages') or []
+
+        query = """
+            SELECT * FROM articles
+            WHERE status = 'published'
+            AND NOT (id::text = ANY(%s))
+            AND id NOT IN (
+                SELECT article_id FROM user_interactions
+                WHERE user_id = %s AND interaction_type IN ('view', 'like', 'save')
+            )
+        """
+        params: List[Any] = [list(exclude), user['id']]
+
+        if categories:
+            query += " AND category = ANY(%s)"
+            params.append(categories)
+        if languages:
+            query += " AND language = ANY(%s)"
+            params.append(languages)
+
+        query += " ORDER BY engagement_score DESC, published_at DESC NULLS LAST LIMIT %s"
+        params.append(shelf.count)
+
+        cursor.execute(query, params)
+        return cursor.fetchall(), None
+
+    def _topics(self, cursor, user, shelf: FeedShelfConfig, exclude: Set[str]) -> ShelfResult:
+        window_days = int(shelf.options.get('window_days', 7))
+        per_topic = int(shelf.options.get('articles_per_topic', 3))
+
+        cursor.execute("""
+            SELECT tag, COUNT(*) as count
+            FROM (
+                SELECT unnest(tags) as tag FROM articles
+                WHERE status = 'published' AND published_at >= %s
+            ) recent_tags
+            GROUP BY tag
+            ORDER BY count DESC
+            LIMIT %s
+        """, (datetime.now() - timedelta(days=window_days), shelf.count))
+        tags = cursor.fetchall()
+
+        topics = []
+        for tag in tags:
+            cursor.execute("""
+                SELECT * FROM articles
+                WHERE status = 'published' AND %s = ANY(tags)
+                AND NOT (id::text = ANY(%s))
+                ORDER BY trending_score DESC
+                LIMIT %s
+            """, (tag['tag'], list(exclude), per_topic))
+            articles = [ArticleResponse(**dict(article)).dict() for article in cursor.fetchall()]
+            topics.append({'name': tag['tag'], 'count': tag['count'], 'articles': articles})
+
+        return [], topics
+
+    def _latest(self, cursor, user, shelf: FeedShelfConfig, exclude: Set[str]) -> ShelfResult:
+        query = "SELECT * FROM articles WHERE status = 'published' AND NOT (id::text = ANY(%s))"
+        params: List[Any] = [list(exclude)]
+
+        category = shelf.options.get('category')
+        if category:
+            query += " AND category = %s"
+            params.append(category)
+
+        query += " ORDER BY published_at DESC NULLS LAST LIMIT %s"
+        params.append(shelf.count)
+
+        cursor.execute(query, params)
+        return cursor.fetchall(), None
+
+
+class PersonalizedFeed:
+    """Ranked personal feed with opaque keyset cursors
+
+    Every page is ranked against the `as_of` instant pinned by the first page,
+    so articles published while a reader scrolls never shift later pages. The
+    trending scores the first page ranked with are kept in Redis under the
+    cursor's snapshot, so recomputed scores don't reorder later pages either.
+    Articles pinned to the home feed at `as_of` lead the first page, so they're
+    left out of every page's ranking.
+    """
+
+    def __init__(self):
+        self.window_days = int(os.getenv('FEED_WINDOW_DAYS', 14))
+        self.follow_weight = float(os.getenv('FEED_FOLLOW_WEIGHT', 3.0))
+        self.category_weight = float(os.getenv('FEED_CATEGORY_WEIGHT', 2.0))
+        self.trending_weight = float(os.getenv('FEED_TRENDING_WEIGHT', 1.0))
+        self.recency_weight = float(os.getenv('FEED_RECENCY_WEIGHT', 2.0))
+        self.snapshot_ttl = int(os.getenv('FEED_SNAPSHOT_TTL_SECONDS', 3600))
+
+    def get_page(self, user: Optional[dict], cursor: Optional[str], limit: int) -> Tuple[List[Dict[str, Any]], Optional[str]]:
+        """Return one page of ranked articles and the cursor for the next page"""
+        position = decode_cursor(cursor) if cursor else {}
+        if position is None:
+            raise ValueError("Invalid cursor")
+
+        as_of = deserialize_datetime(position.get('as_of')) if position.get('as_of') else datetime.now()
+        if as_of is None:
+            raise ValueError("Invalid cursor")
+
+        with get_postgres_cursor() as db_cursor:
+            followed_authors, categories = self._load_signals(db_cursor, user)
+            snapshot = position.get('snapshot')
+            trending = self._frozen_trending(snapshot) if snapshot else self._current_trending(db_cursor, as_of)
+
+            query = """
+                SELECT * FROM (
+                    SELECT a.*, ROUND((
+                        CASE WHEN a.author_id::text = ANY(%s) AND a.anonymous_author = false THEN %s ELSE 0 END
+                        + CASE WHEN a.category = ANY(%s) THEN %s ELSE 0 END
+                        + %s * LEAST(COALESCE(frozen.trending_score, 0), 100) / 100.0
+                        + %s / (1 + EXTRACT(EPOCH FROM (%s - a.published_at)) / 86400.0)
+                    )::numeric, 6) AS feed_score
+                    FROM articles a
+                    LEFT JOIN unnest(%s::uuid[], %s::float8[]) AS frozen(article_id, trending_score)
+                        ON frozen.article_id = a.id
+                    WHERE a.status = 'published'
+                    AND a.published_at IS NOT NULL
+                    AND a.published_at <= %s
+                    AND a.published_at >= %s
+                    AND NOT (a.author_id::text = ANY(%s))
+                    AND NOT EXISTS (
+                        SELECT 1 FROM article_pins p
+                        WHERE p.article_id = a.id AND p.scope = 'home'
+                        AND p.starts_at <= %s AND (p.ends_at IS NULL OR p.ends_at > %s)
+                    )
+                ) ranked
+            """
+            params: List[Any] = [
+                followed_authors, self.follow_weight,
+                categories, self.category_weight,
+                self.trending_weight,
+                self.recency_weight, as_of,
+                list(trending), list(trending.values()),
+                as_of, as_of - timedelta(days=self.window_days),
+                hidden_authors(db_cursor, user),
+                as_of, as_of,
+            ]
+
+            if position.get('score') is not None and position.get('id'):
+                query += " WHERE (feed_score, id) < (%s::numeric, %s::uuid)"
+                params.extend([position['score'], position['id']])
+
+            query += " ORDER BY feed_score DESC, id DESC LIMIT %s"
+            params.append(limit + 1)
+
+            db_cursor.execute(query, params)
+            articles = db_cursor.fetchall()
+
+        next_cursor = None
+        if len(articles) > limit:
+            articles = articles[:limit]
+            last = articles[-1]
+            next_cursor = encode_cursor({
+                'as_of': as_of.isoformat(),
+                'snapshot': snapshot or self._freeze_trending(trending),
+                'score': str(last['feed_score']),
+                'id': str(last['id']),
+            })
+
+        return articles, next_cursor
+
+    def _current_trending(self, cursor, as_of: datetime) -> Dict[str, float]:
+        """Trending scores of the articles in the window, by article id"""
+        cursor.execute("""
+            SELECT id, trending_score FROM articles
+            WHERE status = 'published' AND published_at <= %s AND published_at >= %s
+            AND COALESCE(trending_score, 0) > 0
+        """, (as_of, as_of - timedelta(days=self.window_days)))
+        return {str(row['id']): float(row['trending_score']) for row in cursor.fetchall()}
+
+    def _freeze_trending(self, trending: Dict[str, float]) -> str:
+        """Keep the scores a first page ranked with for its later pages; returns the snapshot id"""
+        snapshot = generate_uuid()
+        get_redis().setex(trending_snapshot_key(snapshot), self.snapshot_ttl, json.dumps(trending))
+        return snapshot
+
+    def _frozen_trending(self, snapshot: str) -> Dict[str, float]:
+        stored = get_redis().get(trending_snapshot_key(snapshot))
+        if stored is None:
+            raise ValueError("Feed snapshot expired")
+        return json.loads(stored)
+
+    def _load_signals(self, cursor, user: Optional[dict]) -> Tuple[List[str], List[str]]:
+        if not user:
+            return [], []
+
+        cursor.execute("SELECT following_id FROM user_follows WHERE follower_id = %s", (user['id'],))
+        followed_authors = [str(row['following_id']) for row in cursor.fetchall()]
+
+        cursor.execute("SELECT category FROM category_follows WHERE user_id = %s", (user['id'],))
+        categories = {row['category'] for row in cursor.fetchall()}
+
+        cursor.execute("SELECT categories FROM user_preferences WHERE user_id = %s", (user['id'],))
+        preferences = cursor.fetchone()
+        if preferences and preferences.get('categories'):
+            categories.update(preferences['categories'])
+
+        return followed_authors, sorted(categories)
+
+
+# Global feed instances
+feed_composer = FeedComposer()
+personalized_feed = PersonalizedFeed()
+
+
+def home_feed_cache_key(viewer: str) -> str:
+    return f"feed:home:{tenancy.cache_namespace()}:{viewer}"
+
+
+def trending_snapshot_key(snapshot: str) -> str:
+    return f"feed:trending:{tenancy.cache_namespace()}:{snapshot}"
+
+
+def refresh_anonymous_home_feed():
+    """Rebuild the signed-out home feed's cache entry now rather than on the next request"""
+    cache_ttl = feed_composer.load_config().cache_ttl_seconds
+    if not cache_ttl:
+        return
+    pinned, shelves = feed_composer.compose(None)
+    response = HomeFeedResponse(pinned=pinned, shelves=shelves, generated_at=datetime.now())
+    get_redis().setex(home_feed_cache_key('anonymous'), cache_ttl, json.dumps(response.dict(), default=str))
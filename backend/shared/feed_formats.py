@@ -0,0 +1,129 @@
+"""
+Syndication feed rendering shared by all feed endpoints
+
+Routes build a channel description and a list of article rows; this module
+turns them into the wire format (RSS 2.0 or JSON Feed 1.1). Feeds are public,
+so premium articles carry their preview instead of the content.
+"""
+
+import os
+import json
+from datetime import datetime, timezone
+from email.utils import format_datetime
+from typing import Any, Dict, List, Optional
+from xml.sax.saxutils import escape
+
+from shared.licensing import license_info
+from shared.paywall import public_copy
+
+PUBLIC_BASE_URL = os.getenv('PUBLIC_BASE_URL', 'http://localhost:3000')
+
+
+def article_url(article: Dict[str, Any]) -> str:
+    """Public URL of an article on the frontend"""
+    return f"{PUBLIC_BASE_URL.rstrip('/')}/articles/{article['id']}"
+
+
+def _rfc822(value: Optional[datetime]) -> str:
+    value = value or datetime.now(timezone.utc)
+    if value.tzinfo is None:
+        value = value.replace(tzinfo=timezone.utc)
+    return format_datetime(value)
+
+
+def _rfc3339(value: Optional[datetime]) -> Optional[str]:
+    if value is None:
+        return None
+    if value.tzinfo is None:
+        value = value.replace(tzinfo=timezone.utc)
+    return value.isoformat()
+
+
+def render_rss(title: str, link: str, description: str, articles: List[Dict[str, Any]],
+               self_url: Optional[str] = None) -> str:
+    """Render an RSS 2.0 document"""
+    items = []
+    for article in map(public_copy, articles):
+        url = article_url(article)
+        categories = ''.join(
+            f"<category>{escape(tag)}</category>" for tag in [article.get('category')] + list(article.get('tags') or []) if tag
+        )
+        license = license_info(article)
+        rights = license['terms'] or license['name']
+        license_link = (
+            f"<creativeCommons:license>{escape(license['url'])}</creativeCommons:license>" if license['url'] else ''
+        )
+        items.append(
+            "<item>"
+            f"<title>{escape(article['title'])}</title>"
+            f"<link>{escape(url)}</link>"
+            f"<guid isPermaLink=\"true\">{escape(url)}</guid>"
+            f"<description>{escape(article.get('summary') or '')}</description>"
+            f"<pubDate>{_rfc822(article.get('published_at'))}</pubDate>"
+            f"{categories}"
+            f"<dc:rights>{escape(rights)}</dc:rights>"
+            f"{license_link}"
+            "</item>"
+        )
+
+    atom_link = (
+        f"<atom:link href=\"{escape(self_url)}\" rel=\"self\" type=\"application/rss+xml\"/>" if self_url else ''
+    )
+
+    return (
+        '<?xml version="1.0" encoding="UTF-8"?>'
+        '<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom"'
+        ' xmlns:dc="http://purl.org/dc/elements/1.1/"'
+        ' xmlns:creativeCommons="http://backend.userland.com/creativeCommonsRssModule">'
+        "<channel>"
+        f"<title>{escape(title)}</title>"
+        f"<link>{escape(link)}</link>"
+        f"<description>{escape(description or '')}</description>"
+        f"<lastBuildDate>{_rfc822(None)}</lastBuildDate>"
+        f"{atom_link}"
+        f"{''.join(items)}"
+        "</channel>"
+        "</rss>"
+    )
+
+
+def render_json_feed(title: str, link: str, description: str, articles: List[Dict[str, Any]],
+                     self_url: Optional[str] = None) -> str:
+    """Render a JSON Feed 1.1 document (https://jsonfeed.org/version/1.1)"""
+    items = []
+    for article in map(public_copy, articles):
+        url = article_url(article)
+        license = license_info(article)
+        item = {
+            'id': url,
+            'url': url,
+            'title': article['title'],
+            'summary': article.get('summary') or None,
+            'content_html': article.get('content_html'),
+            'date_published': _rfc3339(article.get('published_at')),
+            'date_modified': _rfc3339(article.get('updated_at')),
+            'tags': [tag for tag in [article.get('category')] + list(article.get('tags') or []) if tag],
+            'language': article.get('language'),
+            # Custom extension: JSON Feed has no license field
+            '_license': {'id': license['id'], 'name': license['name'], 'url': license['url']},
+        }
+        if article.get('author_name'):
+            item['authors'] = [{'name': article['author_name']}]
+        items.append({key: value for key, value in item.items() if value not in (None, [], '')})
+
+    feed = {
+        'version': 'https://jsonfeed.org/version/1.1',
+        'title': title,
+        'home_page_url': link,
+        'feed_url': self_url,
+        'description': description or None,
+        'items': items,
+    }
+    return json.dumps({key: value for key, value in feed.items() if value is not None}, ensure_ascii=False)
+
+
+# Format name -> (media type, renderer)
+FEED_FORMATS = {
+    'rss': ('application/rss+xml', render_rss),
+    'json': ('application/feed+json', render_json_feed),
+}
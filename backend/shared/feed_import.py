@@ -0,0 +1,219 @@
+"""
+RSS and Atom feed import
+
+Administrators register external feeds. On each feed's refresh interval the
+crawler fetches it with the ETag and Last-Modified validators from the last
+successful fetch, so an unchanged feed costs a 304, and turns items it hasn't
+seen into drafts owned by the feed's author with `source_url` set to the
+item's link. Drafts go through the normal editorial flow; nothing is
+published automatically. Each fetch updates the feed's health: status code,
+last error and consecutive failures.
+"""
+
+import os
+import calendar
+import logging
+from datetime import datetime, timezone
+from typing import Any, Dict, List, Optional
+
+import feedparser
+import requests
+
+from shared.database import get_postgres_cursor, get_redis
+from shared.ingestion import canonical_url, normalize_language
+from shared.readability import readability_columns
+from shared.utils import calculate_reading_time, calculate_word_count, sanitize_html
+
+logger = logging.getLogger(__name__)
+
+REQUEST_TIMEOUT_SECONDS = int(os.getenv('FEED_IMPORT_TIMEOUT_SECONDS', 20))
+MAX_ITEMS_PER_FETCH = int(os.getenv('FEED_IMPORT_MAX_ITEMS', 50))
+MAX_FEED_BYTES = 5 * 1024 * 1024
+FETCH_LOCK_SECONDS = 10 * 60
+USER_AGENT = os.getenv('FEED_IMPORT_USER_AGENT', 'DecentralizedNewsFeedImporter/1.0')
+
+# After this many failures in a row a feed is reported as failing
+FAILING_AFTER = 3
+
+
+def health(feed: Dict[str, Any]) -> str:
+    """ok, failing (repeated errors), degraded (last fetch failed) or pending (never fetched)"""
+    if not feed.get('last_fetched_at'):
+        return 'pending'
+    if feed.get('consecutive_failures', 0) >= FAILING_AFTER:
+        return 'failing'
+    if feed.get('consecutive_failures', 0) > 0:
+        return 'degraded'
+    return 'ok'
+
+
+def feeds_due(cursor) -> List[str]:
+    cursor.execute("""
+        SELECT id FROM feed_sources
+        WHERE is_active = true
+        AND (last_fetched_at IS NULL OR last_fetched_at + make_interval(mins => refresh_interval_minutes) <= NOW())
+    """)
+    return [str(row['id']) for row in cursor.fetchall()]
+
+
+def _published(entry: Dict[str, Any]) -> Optional[datetime]:
+    parsed = entry.get('published_parsed') or entry.get('updated_parsed')
+    if not parsed:
+        return None
+    return datetime.fromtimestamp(calendar.timegm(parsed), tz=timezone.utc)
+
+
+def _content(entry: Dict[str, Any]) -> str:
+    """The full content when the feed carries it, otherwise the summary"""
+    for content in entry.get('content') or []:
+        if content.get('value'):
+            return content['value']
+    return entry.get('summary') or ''
+
+
+def _import_item(cursor, feed: Dict[str, Any], entry: Dict[str, Any], language: str) -> Optional[str]:
+    """Create a draft from one feed item, or None if it was seen before or has no usable text"""
+    link = entry.get('link')
+    guid = (entry.get('id') or link or '')[:1000]
+    title = (entry.get('title') or '').strip()
+    content = sanitize_html(_content(entry))
+    if not guid or not title or not content.strip():
+        return None
+
+    cursor.execute(
+        "INSERT INTO feed_items (feed_id, guid, link) VALUES (%s, %s, %s) ON CONFLICT DO NOTHING RETURNING guid",
+        (feed['id'], guid, link)
+    )
+    if not cursor.fetchone():
+        return None
+
+    source_url = canonical_url(link) if link else None
+    if source_url:
+        cursor.execute("SELECT id FROM articles WHERE source_url = %s LIMIT 1", (source_url,))
+        existing = cursor.fetchone()
+        if existing:
+            # Already here through another feed or the ingestion API
+            cursor.execute(
+                "UPDATE feed_items SET article_id = %s WHERE feed_id = %s AND guid = %s",
+                (existing['id'], feed['id'], guid)
+            )
+            return None
+
+    summary = entry.get('summary') if entry.get('content') else None
+    published_at = _published(entry)
+    tags = sorted({tag['term'].strip().lower() for tag in entry.get('tags') or [] if (tag.get('term') or '').strip()})
+    provenance = {
+        'feed_id': str(feed['id']),
+        'feed_url': feed['url'],
+        'guid': guid,
+        'author_name': entry.get('author'),
+        'published_at': published_at.isoformat() if published_at else None,
+    }
+    readability = readability_columns(content, language)
+    cursor.execute("""
+        INSERT INTO articles
+        (title, content, summary, author_id, category, tags, language, source_url, reading_time, word_count,
+         readability, reading_level, metadata, status)
+        VALUES (%s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, 'draft')
+        RETURNING id
+    """, (
+        title[:500], content, sanitize_html(summary)[:1000] if summary else None, feed['author_id'],
+        feed['category'] or 'general', tags, language, source_url,
+        calculate_reading_time(content), calculate_word_count(content),
+        readability['readability'], readability['reading_level'], {'feed_import': provenance},
+    ))
+    article_id = cursor.fetchone()['id']
+    cursor.execute(
+        "UPDATE feed_items SET article_id = %s WHERE feed_id = %s AND guid = %s", (article_id, feed['id'], guid)
+    )
+    return str(article_id)
+
+
+def _record_fetch(feed_id: str, status_code: Optional[int], error: Optional[str] = None,
+                  etag: Optional[str] = None, last_modified: Optional[str] = None, imported: int = 0):
+    with get_postgres_cursor() as cursor:
+        if error:
+            cursor.execute("""
+                UPDATE feed_sources
+                SET last_fetched_at = NOW(), last_status_code = %s, last_error = %s,
+                    consecutive_failures = consecutive_failures + 1
+                WHERE id = %s
+            """, (status_code, error[:1000], feed_id))
+            return
+        cursor.execute("""
+            UPDATE feed_sources
+            SET last_fetched_at = NOW(), last_success_at = NOW(), last_status_code = %s, last_error = NULL,
+                consecutive_failures = 0, etag = COALESCE(%s, etag), last_modified = COALESCE(%s, last_modified),
+                items_imported = items_imported + %s
+            WHERE id = %s
+        """, (status_code, etag, last_modified, imported, feed_id))
+
+
+def fetch_feed(feed_id: str) -> Dict[str, Any]:
+    """Fetch one feed and import its new items"""
+    with get_postgres_cursor() as cursor:
+        cursor.execute("SELECT * FROM feed_sources WHERE id = %s", (feed_id,))
+        feed = cursor.fetchone()
+    if not feed or not feed['is_active']:
+        return {'skipped': True}
+
+    lock_key = f"feed_fetch_lock:{feed_id}"
+    if not get_redis().set(lock_key, 1, nx=True, ex=FETCH_LOCK_SECONDS):
+        return {'skipped': True}
+    try:
+        return _fetch_feed(dict(feed))
+    finally:
+        get_redis().delete(lock_key)
+
+
+def _fetch_feed(feed: Dict[str, Any]) -> Dict[str, Any]:
+    feed_id = str(feed['id'])
+    headers = {
+        'User-Agent': USER_AGENT,
+        'Accept': 'application/rss+xml, application/atom+xml, application/xml;q=0.9, */*;q=0.5',
+    }
+    if feed['etag']:
+        headers['If-None-Match'] = feed['etag']
+    if feed['last_modified']:
+        headers['If-Modified-Since'] = feed['last_modified']
+
+    try:
+        response = requests.get(feed['url'], headers=headers, timeout=REQUEST_TIMEOUT_SECONDS, stream=True)
+        if response.status_code == 304:
+            _record_fetch(feed_id, 304)
+            return {'status': 304, 'imported': 0}
+        response.raise_for_status()
+        body = response.raw.read(MAX_FEED_BYTES + 1, decode_content=True)
+        if len(body) > MAX_FEED_BYTES:
+            raise ValueError(f"Feed is larger than {MAX_FEED_BYTES} bytes")
+    except (requests.RequestException, ValueError) as e:
+        status_code = getattr(getattr(e, 'response', None), 'status_code', None)
+        logger.warning(f"Fetching feed {feed['url']} failed: {e}")
+        _record_fetch(feed_id, status_code, str(e))
+        return {'status': status_code, 'error': str(e)}
+
+    parsed = feedparser.parse(body)
+    if parsed.bozo and not parsed.entries:
+        error = f"Not a valid RSS or Atom feed: {parsed.get('bozo_exception')}"
+        _record_fetch(feed_id, response.status_code, error)
+        return {'status': response.status_code, 'error': error}
+
+    language = feed['language'] or normalize_language(parsed.feed.get('language'))
+    imported = 0
+    failed = 0
+    for entry in parsed.entries[:MAX_ITEMS_PER_FETCH]:
+        try:
+            with get_postgres_cursor() as cursor:
+                if _import_item(cursor, feed, entry, language):
+                    imported += 1
+        except Exception as e:
+            logger.warning(f"Could not import item {entry.get('id') or entry.get('link')} of {feed['url']}: {e}")
+            failed += 1
+
+    _record_fetch(
+        feed_id, response.status_code, etag=response.headers.get('ETag'),
+        last_modified=response.headers.get('Last-Modified'), imported=imported
+    )
+    summary = {'status': response.status_code, 'imported': imported, 'failed': failed}
+    logger.info(f"Feed {feed['url']}: {summary}")
+    return summary
@@ -0,0 +1,239 @@
+"""
+Reader funnels
+
+A funnel is an ordered list of steps a signed-in reader takes from an
+article, each one of:
+
+- `view`, `read` (a view with at least `min_progress` of the article read),
+  `like`, `save`, `share`, `comment` or `interact` (any of the last four),
+  all on the same article
+- `follow_author` or `follow_category`: following the article's author or
+  its category
+
+Readers enter on the first step, which must be about the article, and
+reach a later step by taking it after the step before, within the funnel's
+`window_hours` of entering. A reader entering the same article on several
+days counts once per day.
+
+A worker counts how many readers reached each step, per funnel, day of
+entry and article, and again per variant for articles under an experiment
+at the time, assigning variants the way `experiments.assign` does. Each run
+recomputes the days a reader could still be converting on, so results only
+read the counts.
+"""
+
+import os
+import math
+import logging
+from datetime import date, datetime, timedelta, timezone
+from typing import Any, Dict, List, Optional
+
+logger = logging.getLogger(__name__)
+
+MAX_RANGE_DAYS = int(os.getenv('FUNNEL_MAX_RANGE_DAYS', 366))
+
+# Events about the article, as (user_id, article_id, at) rows
+ARTICLE_EVENTS = {
+    'view': "SELECT user_id, article_id, created_at AS at FROM user_interactions WHERE interaction_type = 'view'",
+    'read': """
+        SELECT user_id, article_id, created_at AS at FROM user_interactions
+        WHERE interaction_type = 'view' AND reading_progress >= %(min_progress_{index})s
+    """,
+    'like': "SELECT user_id, article_id, created_at AS at FROM user_interactions WHERE interaction_type = 'like'",
+    'save': "SELECT user_id, article_id, created_at AS at FROM user_interactions WHERE interaction_type = 'save'",
+    'share': "SELECT user_id, article_id, created_at AS at FROM user_interactions WHERE interaction_type = 'share'",
+    'comment': "SELECT user_id, article_id, created_at AS at FROM comments WHERE NOT is_deleted",
+    'interact': """
+        SELECT user_id, article_id, created_at AS at FROM user_interactions
+        WHERE interaction_type IN ('like', 'save', 'share')
+        UNION ALL
+        SELECT user_id, article_id, created_at FROM comments WHERE NOT is_deleted
+    """,
+}
+
+# Follows, with the condition tying them to the reader's article
+FOLLOW_EVENTS = {
+    'follow_author': (
+        "SELECT follower_id AS user_id, following_id AS author_id, created_at AS at FROM user_follows",
+        "e.author_id = p.author_id",
+    ),
+    'follow_category': (
+        "SELECT user_id, LOWER(category) AS category, created_at AS at FROM category_follows",
+        "e.category = LOWER(p.category)",
+    ),
+}
+
+DEFAULT_MIN_PROGRESS = 0.5
+
+
+def _steps_sql(steps: List[Dict[str, Any]]) -> str:
+    """CTEs step_0..step_n, one row per reader, article and day of entry that reached the step"""
+    columns = "p.user_id, p.article_id, p.author_id, p.category, p.day, p.entered_at"
+    ctes = [f"""
+        step_0 AS (
+            SELECT e.user_id, e.article_id, a.author_id, a.category,
+                   (e.at AT TIME ZONE 'UTC')::date AS day, MIN(e.at) AS entered_at, MIN(e.at) AS at
+            FROM ({ARTICLE_EVENTS[steps[0]['event']].format(index=0)}) e
+            JOIN articles a ON a.id = e.article_id AND a.status = 'published'
+            WHERE e.at >= %(start)s AND e.at < %(end)s
+            GROUP BY e.user_id, e.article_id, a.author_id, a.category, day
+        )
+    """]
+    for index, step in enumerate(steps[1:], start=1):
+        if step['event'] in FOLLOW_EVENTS:
+            source, condition = FOLLOW_EVENTS[step['event']]
+        else:
+            source, condition = ARTICLE_EVENTS[step['event']].format(index=index), "e.article_id = p.article_id"
+        ctes.append(f"""
+            step_{index} AS (
+                SELECT {columns}, MIN(e.at) AS at
+                FROM step_{index - 1} p
+                JOIN ({source}) e ON e.user_id = p.user_id AND {condition}
+                 AND e.at >= p.at AND e.at <= p.entered_at + make_interval(hours => %(window_hours)s)
+                GROUP BY {columns}
+            )
+        """)
+    return ',\n'.join(ctes)
+
+
+def compute(cursor, funnel: Dict[str, Any], start: date, end: date) -> int:
+    """Recount the funnel for readers entering from `start` up to, not including, `end`"""
+    steps = funnel['steps']
+    params: Dict[str, Any] = {
+        'funnel_id': funnel['id'], 'start': start, 'end': end, 'window_hours': funnel['window_hours']
+    }
+    for index, step in enumerate(steps):
+        params[f"min_progress_{index}"] = step.get('min_progress', DEFAULT_MIN_PROGRESS)
+
+    reached = '\nUNION ALL\n'.join(
+        f"SELECT {index} AS step_index, user_id, article_id, day FROM step_{index}" for index in range(len(steps))
+    )
+    cursor.execute(
+        "DELETE FROM funnel_counts WHERE funnel_id = %s AND day >= %s AND day < %s", (funnel['id'], start, end)
+    )
+    cursor.execute(f"""
+        WITH {_steps_sql(steps)},
+        reached AS (
+            {reached}
+        ),
+        -- Same hash as experiments.assign: the first 32 bits of sha256("<experiment id>:<user id>")
+        assignments AS (
+            SELECT s.user_id, s.article_id, s.day, x.id AS experiment_id,
+                   x.variants -> (
+                       ('x' || substr(encode(sha256(convert_to(x.id::text || ':' || s.user_id::text, 'UTF8')), 'hex'), 1, 8))
+                       ::bit(32)::bigint %% jsonb_array_length(x.variants)
+                   )::int ->> 'key' AS variant
+            FROM step_0 s
+            JOIN experiments x ON x.article_id = s.article_id
+             AND s.entered_at >= x.started_at AND (x.concluded_at IS NULL OR s.entered_at < x.concluded_at)
+        )
+        INSERT INTO funnel_counts (funnel_id, day, article_id, experiment_id, variant, step_index, users)
+        SELECT %(funnel_id)s, day, article_id, NULL, NULL, step_index, COUNT(*)
+        FROM reached
+        GROUP BY day, article_id, step_index
+        UNION ALL
+        SELECT %(funnel_id)s, r.day, r.article_id, x.experiment_id, x.variant, r.step_index, COUNT(*)
+        FROM reached r
+        JOIN assignments x ON x.user_id = r.user_id AND x.article_id = r.article_id AND x.day = r.day
+        GROUP BY r.day, r.article_id, x.experiment_id, x.variant, r.step_index
+    """, params)
+    return cursor.rowcount
+
+
+def compute_recent(cursor, days: Optional[int] = None) -> Dict[str, int]:
+    """Recount every active funnel over the days its readers may still be converting on, or the last `days`"""
+    cursor.execute("SELECT * FROM funnels WHERE is_active ORDER BY key")
+    today = datetime.now(timezone.utc).date()
+    counts = {}
+    for funnel in cursor.fetchall():
+        funnel = dict(funnel)
+        lookback = days or math.ceil(funnel['window_hours'] / 24) + 1
+        counts[funnel['key']] = compute(cursor, funnel, today - timedelta(days=lookback - 1), today + timedelta(days=1))
+    logger.info(f"Computed funnels: {counts}")
+    return counts
+
+
+def results(cursor, funnel: Dict[str, Any], by: str, start: date, end: date,
+            experiment_id: Optional[str] = None, category: Optional[str] = None,
+            article_id: Optional[str] = None, limit: int = 50) -> List[Dict[str, Any]]:
+    """Readers reaching each step and conversion rates, overall (`by=total`) or per article, category or variant"""
+    segment = {
+        'total': "'total'",
+        'article': "fc.article_id::text",
+        'category': "LOWER(a.category)",
+        'variant': "fc.variant",
+    }[by]
+    query = f"""
+        SELECT {segment} AS segment, fc.step_index, SUM(fc.users) AS users
+        FROM funnel_counts fc
+        JOIN articles a ON a.id = fc.article_id
+        WHERE fc.funnel_id = %(funnel_id)s AND fc.day >= %(start)s AND fc.day <= %(end)s
+    """
+    params: Dict[str, Any] = {'funnel_id': funnel['id'], 'start': start, 'end': end}
+    if experiment_id:
+        query += " AND fc.experiment_id = %(experiment_id)s"
+        params['experiment_id'] = experiment_id
+    else:
+        query += " AND fc.experiment_id IS NULL"
+    if category:
+        query += " AND LOWER(a.category) = LOWER(%(category)s)"
+        params['category'] = category
+    if article_id:
+        query += " AND fc.article_id = %(article_id)s"
+        params['article_id'] = article_id
+    cursor.execute(query + " GROUP BY segment, fc.step_index", params)
+
+    segments: Dict[str, Dict[str, Any]] = {}
+    for row in cursor.fetchall():
+        entry = segments.setdefault(row['segment'], {'segment': row['segment'], 'users': [0] * len(funnel['steps'])})
+        if row['step_index'] < len(funnel['steps']):
+            entry['users'][row['step_index']] = int(row['users'])
+
+    ranked = sorted(segments.values(), key=lambda entry: entry['users'][0], reverse=True)[:limit]
+    if by == 'article' and ranked:
+        cursor.execute(
+            "SELECT id::text AS id, title FROM articles WHERE id = ANY(%s::uuid[])", ([e['segment'] for e in ranked],)
+        )
+        titles = {row['id']: row['title'] for row in cursor.fetchall()}
+        for entry in ranked:
+            entry['title'] = titles.get(entry['segment'])
+    return [_with_rates(funnel, entry) for entry in ranked]
+
+
+def _with_rates(funnel: Dict[str, Any], entry: Dict[str, Any]) -> Dict[str, Any]:
+    users = entry.pop('users')
+    entered = users[0]
+    entry['entered'] = entered
+    entry['steps'] = [
+        {
+            'index': index,
+            'event': step['event'],
+            'users': count,
+            'conversion_from_previous': round(count / users[index - 1], 4) if index and users[index - 1] else None,
+            'conversion_from_entry': round(count / entered, 4) if entered else 0.0,
+        }
+        for index, (step, count) in enumerate(zip(funnel['steps'], users))
+    ]
+    entry['conversion'] = entry['steps'][-1]['conversion_from_entry']
+    return entry
+
+
+def computed_at(cursor, funnel_id: str) -> Optional[datetime]:
+    cursor.execute("SELECT MAX(updated_at) AS at FROM funnel_counts WHERE funnel_id = %s", (funnel_id,))
+    return cursor.fetchone()['at']
+
+
+def get_funnel(cursor, key: str) -> Optional[Dict[str, Any]]:
+    cursor.execute("SELECT * FROM funnels WHERE key = %s", (key,))
+    row = cursor.fetchone()
+    return dict(row) if row else None
+
+
+def list_funnels(cursor) -> List[Dict[str, Any]]:
+    cursor.execute("SELECT * FROM funnels ORDER BY key")
+    return [dict(row) for row in cursor.fetchall()]
+
+
+def clear_counts(cursor, funnel_id: str):
+    """Drop the counts of a funnel whose steps changed; the next run recounts it"""
+    cursor.execute("DELETE FROM funnel_counts WHERE funnel_id = %s", (funnel_id,))
@@ -0,0 +1,362 @@
+"""
+Community governance
+
+Users whose reputation reaches the `governance` settings' thresholds can put
+proposals (a category to add, a moderation policy change, a feature) to a
+vote and vote yes, no or abstain while the proposal's window is open. A voter
+may change their vote until the window closes; every vote counts once.
+
+A periodic job tallies proposals whose window has closed. A proposal passes
+when at least `quorum` votes were cast and yes votes make up more than
+`pass_threshold` of yes and no votes; the quorum and threshold in force when
+it was created apply. Passed proposals are advisory until an administrator
+marks them enacted.
+
+With GOVERNANCE_CHAIN_ENABLED, each tallied proposal's counts and the Merkle
+root of its votes are recorded on the GovernanceMirror contract, one
+transaction per proposal, so any voter can prove their vote was counted.
+Mirroring is retried like article anchoring.
+"""
+
+import os
+import json
+import uuid
+import hashlib
+import logging
+from datetime import datetime, timedelta, timezone
+from decimal import Decimal
+from typing import Any, Dict, List, Optional
+
+from shared import merkle
+from shared.database import get_postgres_cursor, get_redis, prepare_json_data
+from shared.settings import get_setting
+
+logger = logging.getLogger(__name__)
+
+CHAIN_ENABLED = os.getenv('GOVERNANCE_CHAIN_ENABLED', 'false').lower() == 'true'
+RPC_URL = os.getenv('GOVERNANCE_RPC_URL') or os.getenv('ANCHOR_RPC_URL', 'http://localhost:8545')
+CONTRACT_ADDRESS = os.getenv('GOVERNANCE_CONTRACT_ADDRESS', '')
+PRIVATE_KEY = os.getenv('GOVERNANCE_PRIVATE_KEY') or os.getenv('ANCHOR_PRIVATE_KEY', '')
+CHAIN_ID = int(os.getenv('GOVERNANCE_CHAIN_ID') or os.getenv('ANCHOR_CHAIN_ID', 31337))
+RESUBMIT_AFTER_SECONDS = int(os.getenv('GOVERNANCE_RESUBMIT_AFTER_SECONDS', 60 * 60))
+LOCK_SECONDS = 10 * 60
+
+OPEN = 'open'
+PASSED = 'passed'
+REJECTED = 'rejected'
+CANCELLED = 'cancelled'
+ENACTED = 'enacted'
+STATUSES = [OPEN, PASSED, REJECTED, CANCELLED, ENACTED]
+
+KINDS = ['category_addition', 'moderation_policy', 'feature', 'other']
+CHOICES = ['yes', 'no', 'abstain']
+
+# Mirror states
+PENDING = 'pending'
+SUBMITTED = 'submitted'
+MIRRORED = 'mirrored'
+FAILED = 'failed'
+
+CONTRACT_ABI = [
+    {
+        'name': 'recordResult', 'type': 'function', 'stateMutability': 'nonpayable',
+        'inputs': [
+            {'name': 'proposalId', 'type': 'bytes32'},
+            {'name': 'votesRoot', 'type': 'bytes32'},
+            {'name': 'yes', 'type': 'uint32'},
+            {'name': 'no', 'type': 'uint32'},
+            {'name': 'abstain', 'type': 'uint32'},
+            {'name': 'passed', 'type': 'bool'},
+        ],
+        'outputs': [],
+    },
+    {
+        'name': 'results', 'type': 'function', 'stateMutability': 'view',
+        'inputs': [{'name': '', 'type': 'bytes32'}],
+        'outputs': [
+            {'name': 'timestamp', 'type': 'uint64'}, {'name': 'yes', 'type': 'uint32'},
+            {'name': 'no', 'type': 'uint32'}, {'name': 'abstain', 'type': 'uint32'},
+            {'name': 'passed', 'type': 'bool'}, {'name': 'votesRoot', 'type': 'bytes32'},
+        ],
+    },
+]
+
+
+class NotEligible(Exception):
+    """Raised when a user's reputation is below what proposing or voting needs"""
+
+
+class VotingClosed(Exception):
+    """Raised when voting on a proposal that isn't open"""
+
+
+def config() -> Dict[str, Any]:
+    return get_setting('governance')
+
+
+def _check_reputation(user: Dict[str, Any], minimum: float, action: str):
+    score = float(user.get('reputation_score') or 0)
+    if score < minimum:
+        raise NotEligible(f"A reputation of at least {minimum:g} is needed to {action} (yours is {score:g})")
+
+
+# Proposals
+def create_proposal(cursor, user: Dict[str, Any], kind: str, title: str, description: str,
+                    details: Dict[str, Any], voting_days: Optional[float] = None) -> Dict[str, Any]:
+    settings = config()
+    _check_reputation(user, settings['min_reputation_to_propose'], 'create proposals')
+
+    cursor.execute(
+        "SELECT COUNT(*) AS count FROM governance_proposals WHERE proposer_id = %s AND status = 'open'",
+        (user['id'],)
+    )
+    if cursor.fetchone()['count'] >= settings['max_open_per_user']:
+        raise NotEligible(f"At most {settings['max_open_per_user']} of your proposals can be open at once")
+
+    days = voting_days if voting_days is not None else settings['voting_days']
+    days = min(max(days, settings['min_voting_days']), settings['max_voting_days'])
+    now = datetime.now(timezone.utc)
+    cursor.execute("""
+        INSERT INTO governance_proposals
+        (proposer_id, kind, title, description, details, voting_starts_at, voting_ends_at, quorum, pass_threshold)
+        VALUES (%s, %s, %s, %s, %s, %s, %s, %s, %s)
+        RETURNING *
+    """, (
+        user['id'], kind, title, description, prepare_json_data(details or {}), now, now + timedelta(days=days),
+        settings['quorum'], settings['pass_threshold']
+    ))
+    proposal = dict(cursor.fetchone())
+    logger.info(f"Governance proposal {proposal['id']} ({kind}) opened by {user['id']} until {proposal['voting_ends_at']}")
+    return proposal
+
+
+def cast_vote(cursor, proposal_id: str, user: Dict[str, Any], choice: str) -> Dict[str, Any]:
+    """Record or change the user's vote while the proposal's window is open"""
+    if choice not in CHOICES:
+        raise ValueError(f"Unknown choice '{choice}'")
+    _check_reputation(user, config()['min_reputation_to_vote'], 'vote')
+
+    cursor.execute("SELECT status, voting_starts_at, voting_ends_at FROM governance_proposals WHERE id = %s", (proposal_id,))
+    proposal = cursor.fetchone()
+    if not proposal:
+        raise LookupError("Proposal not found")
+    now = datetime.now(timezone.utc)
+    if proposal['status'] != OPEN or not proposal['voting_starts_at'] <= now < proposal['voting_ends_at']:
+        raise VotingClosed("Voting on this proposal is closed")
+
+    cursor.execute("""
+        INSERT INTO governance_votes (proposal_id, voter_id, choice)
+        VALUES (%s, %s, %s)
+        ON CONFLICT (proposal_id, voter_id) DO UPDATE SET choice = EXCLUDED.choice, updated_at = NOW()
+        RETURNING *
+    """, (proposal_id, user['id'], choice))
+    return dict(cursor.fetchone())
+
+
+def vote_counts(cursor, proposal_id: str) -> Dict[str, int]:
+    cursor.execute(
+        "SELECT choice, COUNT(*) AS count FROM governance_votes WHERE proposal_id = %s GROUP BY choice", (proposal_id,)
+    )
+    counts = {row['choice']: row['count'] for row in cursor.fetchall()}
+    return {choice: counts.get(choice, 0) for choice in CHOICES}
+
+
+def outcome(counts: Dict[str, int], quorum: int, pass_threshold: Decimal) -> str:
+    if sum(counts.values()) < quorum or not counts['yes'] + counts['no']:
+        return REJECTED
+    return PASSED if Decimal(counts['yes']) / (counts['yes'] + counts['no']) > Decimal(pass_threshold) else REJECTED
+
+
+def tally_due(cursor) -> List[Dict[str, Any]]:
+    """Close every open proposal whose window has ended and record its result"""
+    cursor.execute("""
+        SELECT * FROM governance_proposals
+        WHERE status = 'open' AND voting_ends_at <= NOW()
+        ORDER BY voting_ends_at
+        FOR UPDATE SKIP LOCKED
+    """)
+    tallied = []
+    for proposal in cursor.fetchall():
+        counts = vote_counts(cursor, proposal['id'])
+        votes_root = _votes_root(cursor, proposal['id'])
+        cursor.execute("""
+            UPDATE governance_proposals
+            SET status = %s, yes_votes = %s, no_votes = %s, abstain_votes = %s, tallied_at = NOW(),
+                votes_root = %s, chain_status = %s
+            WHERE id = %s
+            RETURNING *
+        """, (
+            outcome(counts, proposal['quorum'], proposal['pass_threshold']),
+            counts['yes'], counts['no'], counts['abstain'], votes_root,
+            PENDING if CHAIN_ENABLED else None, proposal['id']
+        ))
+        result = dict(cursor.fetchone())
+        logger.info(f"Governance proposal {result['id']} {result['status']}: {counts}")
+        tallied.append(result)
+    return tallied
+
+
+# Vote proofs
+def vote_hash(vote: Dict[str, Any]) -> str:
+    """SHA-256 of the vote's canonical JSON: proposal_id, voter_id and choice, sorted keys, no whitespace"""
+    canonical = json.dumps({
+        'proposal_id': str(vote['proposal_id']),
+        'voter_id': str(vote['voter_id']),
+        'choice': vote['choice'],
+    }, sort_keys=True, separators=(',', ':'))
+    return hashlib.sha256(canonical.encode()).hexdigest()
+
+
+def _votes(cursor, proposal_id: str) -> List[Dict[str, Any]]:
+    # Ordered by voter so the tree can be rebuilt the same way after the vote
+    cursor.execute(
+        "SELECT proposal_id, voter_id, choice FROM governance_votes WHERE proposal_id = %s ORDER BY voter_id",
+        (proposal_id,)
+    )
+    return [dict(row) for row in cursor.fetchall()]
+
+
+def _votes_root(cursor, proposal_id: str) -> Optional[str]:
+    votes = _votes(cursor, proposal_id)
+    if not votes:
+        return None
+    return merkle.root(merkle.build_tree([vote_hash(vote) for vote in votes]))
+
+
+def vote_proof(cursor, proposal: Dict[str, Any], voter_id: str) -> Optional[Dict[str, Any]]:
+    """A voter's vote with its inclusion proof under the proposal's votes root, once tallied"""
+    votes = _votes(cursor, proposal['id'])
+    index = next((i for i, vote in enumerate(votes) if str(vote['voter_id']) == str(voter_id)), None)
+    if index is None:
+        return None
+    hashes = [vote_hash(vote) for vote in votes]
+    levels = merkle.build_tree(hashes)
+    return {
+        'proposal_id': str(proposal['id']),
+        'choice': votes[index]['choice'],
+        'vote_hash': hashes[index],
+        'leaf': merkle.leaf_hash(hashes[index]).hex(),
+        'proof': merkle.proof(levels, index),
+        'votes_root': proposal['votes_root'],
+        'chain_status': proposal['chain_status'],
+        'tx_hash': proposal['tx_hash'],
+        'chain_id': CHAIN_ID if proposal['chain_status'] else None,
+        'contract_address': (CONTRACT_ADDRESS or None) if proposal['chain_status'] else None,
+    }
+
+
+# On-chain mirror
+def _proposal_key(proposal_id: Any) -> bytes:
+    return uuid.UUID(str(proposal_id)).bytes.ljust(32, b'\x00')
+
+
+def _contract():
+    from web3 import Web3
+
+    if not CONTRACT_ADDRESS or not PRIVATE_KEY:
+        raise RuntimeError("GOVERNANCE_CONTRACT_ADDRESS and GOVERNANCE_PRIVATE_KEY must be set")
+    web3 = Web3(Web3.HTTPProvider(RPC_URL, request_kwargs={'timeout': 30}))
+    contract = web3.eth.contract(address=Web3.to_checksum_address(CONTRACT_ADDRESS), abi=CONTRACT_ABI)
+    return web3, contract
+
+
+def submit_result(proposal: Dict[str, Any]) -> Dict[str, Any]:
+    """Send the proposal's tally to the contract, unless it is already there"""
+    web3, contract = _contract()
+    key = _proposal_key(proposal['id'])
+
+    recorded = contract.functions.results(key).call()
+    if recorded[0]:
+        return {'chain_status': MIRRORED, 'mirrored_at': datetime.fromtimestamp(recorded[0], timezone.utc)}
+
+    account = web3.eth.account.from_key(PRIVATE_KEY)
+    transaction = contract.functions.recordResult(
+        key, bytes.fromhex(proposal['votes_root'] or '00' * 32),
+        proposal['yes_votes'], proposal['no_votes'], proposal['abstain_votes'],
+        proposal['status'] in (PASSED, ENACTED)
+    ).build_transaction({
+        'from': account.address,
+        'chainId': CHAIN_ID,
+        'nonce': web3.eth.get_transaction_count(account.address, 'pending'),
+    })
+    signed = account.sign_transaction(transaction)
+    tx_hash = web3.eth.send_raw_transaction(signed.raw_transaction)
+    return {'chain_status': SUBMITTED, 'tx_hash': web3.to_hex(tx_hash)}
+
+
+def confirm_result(proposal: Dict[str, Any]) -> Optional[Dict[str, Any]]:
+    """The proposal's new mirror state once its transaction is mined, or None while it is still waiting"""
+    from web3.exceptions import TransactionNotFound
+
+    web3, _ = _contract()
+    try:
+        receipt = web3.eth.get_transaction_receipt(proposal['tx_hash'])
+    except TransactionNotFound:
+        return None
+    if receipt['status'] != 1:
+        return {'chain_status': FAILED, 'chain_error': f"Transaction {proposal['tx_hash']} reverted"}
+    block = web3.eth.get_block(receipt['blockNumber'])
+    return {
+        'chain_status': MIRRORED,
+        'block_number': receipt['blockNumber'],
+        'mirrored_at': datetime.fromtimestamp(block['timestamp'], timezone.utc),
+    }
+
+
+def _update_proposal(proposal_id: str, changes: Dict[str, Any]):
+    columns = ', '.join(f"{column} = %s" for column in changes)
+    with get_postgres_cursor() as cursor:
+        cursor.execute(f"UPDATE governance_proposals SET {columns} WHERE id = %s", (*changes.values(), proposal_id))
+
+
+def _to_mirror(states: List[str]) -> List[Dict[str, Any]]:
+    with get_postgres_cursor() as cursor:
+        cursor.execute(
+            "SELECT * FROM governance_proposals WHERE chain_status = ANY(%s) ORDER BY tallied_at", (states,)
+        )
+        return [dict(row) for row in cursor.fetchall()]
+
+
+def mirror_results() -> Dict[str, int]:
+    """Confirm sent tallies and send every tallied proposal not yet on-chain"""
+    summary = {'submitted': 0, 'mirrored': 0, 'failed': 0}
+    if not CHAIN_ENABLED:
+        return summary
+
+    # Only one run may send transactions at a time, or nonces would collide
+    lock_key = 'governance_mirror_lock'
+    if not get_redis().set(lock_key, 1, nx=True, ex=LOCK_SECONDS):
+        return summary
+    try:
+        stale_before = datetime.now(timezone.utc) - timedelta(seconds=RESUBMIT_AFTER_SECONDS)
+        for proposal in _to_mirror([SUBMITTED]):
+            try:
+                result = confirm_result(proposal)
+            except Exception as e:
+                logger.warning(f"Checking governance mirror of {proposal['id']} failed: {e}")
+                continue
+            if result is None and proposal['submitted_at'] < stale_before:
+                result = {'chain_status': FAILED, 'chain_error': f"Transaction {proposal['tx_hash']} was not mined in time"}
+            if result:
+                _update_proposal(proposal['id'], result)
+                summary[result['chain_status']] += 1
+
+        for proposal in _to_mirror([PENDING, FAILED]):
+            try:
+                result = submit_result(proposal)
+                result.update({'submitted_at': datetime.now(timezone.utc), 'chain_error': None})
+            except Exception as e:
+                logger.error(f"Mirroring governance proposal {proposal['id']} failed: {e}")
+                result = {'chain_status': FAILED, 'chain_error': str(e)[:1000]}
+            _update_proposal(proposal['id'], result)
+            summary[result['chain_status']] += 1
+        return summary
+    finally:
+        get_redis().delete(lock_key)
+
+
+def tally_proposals() -> Dict[str, int]:
+    """Close proposals whose voting window ended, then mirror tallies on-chain when enabled"""
+    with get_postgres_cursor() as cursor:
+        tallied = tally_due(cursor)
+    return {'tallied': len(tallied), **mirror_results()}
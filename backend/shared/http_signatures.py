@@ -0,0 +1,145 @@
+"""
+HTTP message signatures (RFC 9421) on responses
+
+When NODE_SIGNING_KEY is set (a base64-encoded 32-byte Ed25519 seed), article
+and manifest responses carry a Content-Digest (RFC 9530) and a signature made
+with the node keypair over the status, content type, digest and the path and
+query of the request. A mirror serving a copy can keep the three headers and
+the exact body, and anyone holding the origin's public key (published at
+/api/v1/node/keys) can check the response came from the origin unchanged.
+
+The same key signs JSON documents the node issues, such as publication
+certificates and account migration exports, over their canonical JSON.
+"""
+
+import os
+import json
+import base64
+import hashlib
+import logging
+import time
+from functools import lru_cache
+from typing import Any, Dict, List, Optional
+
+from cryptography.exceptions import InvalidSignature
+from cryptography.hazmat.primitives import serialization
+from cryptography.hazmat.primitives.asymmetric.ed25519 import Ed25519PrivateKey, Ed25519PublicKey
+
+logger = logging.getLogger(__name__)
+
+SIGNATURE_LABEL = 'sig1'
+ALGORITHM = 'ed25519'
+
+# Response components covered by every signature, as serialized in Signature-Input
+COVERED_COMPONENTS = ('"@status"', '"content-type"', '"content-digest"', '"@path";req', '"@query";req')
+
+
+@lru_cache(maxsize=1)
+def _signing_key() -> Optional[Ed25519PrivateKey]:
+    seed = os.getenv('NODE_SIGNING_KEY')
+    if not seed:
+        return None
+    try:
+        return Ed25519PrivateKey.from_private_bytes(base64.b64decode(seed))
+    except ValueError as e:
+        logger.error(f"NODE_SIGNING_KEY is not a base64-encoded 32-byte Ed25519 seed; responses are unsigned: {e}")
+        return None
+
+
+def _public_key_bytes(key: Ed25519PrivateKey) -> bytes:
+    return key.public_key().public_bytes(serialization.Encoding.Raw, serialization.PublicFormat.Raw)
+
+
+def signing_enabled() -> bool:
+    return _signing_key() is not None
+
+
+def key_id() -> Optional[str]:
+    """NODE_KEY_ID, or a thumbprint of the public key so a rotated key gets a new id"""
+    key = _signing_key()
+    if not key:
+        return None
+    return os.getenv('NODE_KEY_ID') or hashlib.sha256(_public_key_bytes(key)).hexdigest()[:16]
+
+
+def public_keys() -> List[Dict[str, Any]]:
+    """The node's verification keys, raw Ed25519 public keys in base64"""
+    key = _signing_key()
+    if not key:
+        return []
+    return [{
+        'keyid': key_id(),
+        'alg': ALGORITHM,
+        'public_key': base64.b64encode(_public_key_bytes(key)).decode(),
+    }]
+
+
+def canonical_json(document: Dict[str, Any]) -> bytes:
+    """What signed documents are signed over: sorted keys, no whitespace, non-ASCII escaped"""
+    return json.dumps(document, sort_keys=True, separators=(',', ':'), ensure_ascii=True).encode()
+
+
+def sign_document(document: Dict[str, Any]) -> Optional[Dict[str, Any]]:
+    """The document with a `signature` of its canonical JSON made with the node key; None when signing is off"""
+    key = _signing_key()
+    if not key:
+        return None
+    return {**document, 'signature': base64.b64encode(key.sign(canonical_json(document))).decode()}
+
+
+def verify_document(document: Dict[str, Any], public_key: bytes) -> bool:
+    """Whether a signed document's `signature` is valid for the raw Ed25519 `public_key`"""
+    unsigned = {name: value for name, value in document.items() if name != 'signature'}
+    try:
+        Ed25519PublicKey.from_public_bytes(public_key).verify(
+            base64.b64decode(document['signature']), canonical_json(unsigned)
+        )
+        return True
+    except (InvalidSignature, KeyError, TypeError, ValueError):
+        return False
+
+
+def content_digest(body: bytes) -> str:
+    return f"sha-256=:{base64.b64encode(hashlib.sha256(body).digest()).decode()}:"
+
+
+def signature_base(values: Dict[str, str], signature_params: str) -> str:
+    """The signature base of RFC 9421 section 2.5 for the covered components"""
+    lines = [f"{component}: {values[component]}" for component in COVERED_COMPONENTS]
+    lines.append(f'"@signature-params": {signature_params}')
+    return '\n'.join(lines)
+
+
+def signature_headers(status_code: int, content_type: str, body: bytes, path: str, query: str = '') -> Dict[str, str]:
+    """Content-Digest, Signature-Input and Signature for a response; empty when signing is off"""
+    key = _signing_key()
+    if not key:
+        return {}
+
+    digest = content_digest(body)
+    signature_params = (
+        f"({' '.join(COVERED_COMPONENTS)});created={int(time.time())};keyid=\"{key_id()}\";alg=\"{ALGORITHM}\""
+    )
+    base = signature_base({
+        '"@status"': str(status_code),
+        '"content-type"': content_type,
+        '"content-digest"': digest,
+        '"@path";req': path,
+        '"@query";req': f"?{query}",
+    }, signature_params)
+
+    signature = base64.b64encode(key.sign(base.encode('utf-8'))).decode()
+    return {
+        'Content-Digest': digest,
+        'Signature-Input': f"{SIGNATURE_LABEL}={signature_params}",
+        'Signature': f"{SIGNATURE_LABEL}=:{signature}:",
+    }
+
+
+def sign_response(response, request):
+    """Add signature headers to an already rendered response for `request`"""
+    response.headers.update(signature_headers(
+        response.status_code, response.headers.get('content-type', ''), response.body,
+        request.url.path, request.url.query
+    ))
+    return response
@@ -0,0 +1,200 @@
+"""
+Ingestion of articles from external sources
+
+Crawlers and syndication partners push scraped or syndicated articles in
+batches with a service API key. Each article is deduplicated twice: by its
+canonical URL (tracking parameters, fragments and cosmetic differences
+removed), so a re-submission updates the stored copy instead of adding
+another, and by a hash of its normalized text, so the same story arriving
+from a second source under a different URL is recognised. Language and
+category are normalized to the values the rest of the application uses, and
+where the article came from is kept under `metadata.ingestion`.
+"""
+
+import re
+import hashlib
+import logging
+from datetime import datetime, timezone
+from typing import Any, Dict, Optional
+from urllib.parse import parse_qsl, urlencode, urlsplit, urlunsplit
+
+from shared.instance_policy import REJECT, REVIEW, check_publish, request_review
+from shared.licensing import LICENSES
+from shared.publishing import on_article_published
+from shared.readability import readability_columns
+from shared.utils import calculate_reading_time, calculate_word_count, sanitize_html
+
+logger = logging.getLogger(__name__)
+
+CREATED = 'created'
+UPDATED = 'updated'
+DUPLICATE = 'duplicate'
+REJECTED = 'rejected'
+PENDING_REVIEW = 'pending_review'
+
+DEFAULT_CATEGORY = 'general'
+CATEGORIES = {
+    'blockchain', 'business', 'entertainment', 'health', 'journalism',
+    'lifestyle', 'politics', 'science', 'sports', 'technology', DEFAULT_CATEGORY,
+}
+CATEGORY_ALIASES = {
+    'tech': 'technology', 'technology & science': 'technology', 'crypto': 'blockchain', 'web3': 'blockchain',
+    'u.s. news': 'politics', 'us news': 'politics', 'world news': 'politics', 'world': 'politics',
+    'economy': 'business', 'finance': 'business', 'markets': 'business', 'money': 'business',
+    'comedy': 'entertainment', 'culture': 'entertainment', 'arts': 'entertainment', 'movies': 'entertainment',
+    'music': 'entertainment', 'wellness': 'health', 'medicine': 'health', 'parenting': 'lifestyle',
+    'style & beauty': 'lifestyle', 'food & drink': 'lifestyle', 'travel': 'lifestyle', 'sport': 'sports',
+    'media': 'journalism', 'news': DEFAULT_CATEGORY,
+}
+LANGUAGE_NAMES = {
+    'english': 'en', 'spanish': 'es', 'español': 'es', 'french': 'fr', 'français': 'fr', 'german': 'de',
+    'deutsch': 'de', 'italian': 'it', 'portuguese': 'pt', 'dutch': 'nl', 'hindi': 'hi', 'bengali': 'bn',
+    'chinese': 'zh', 'japanese': 'ja', 'korean': 'ko', 'arabic': 'ar', 'russian': 'ru',
+}
+
+# Query parameters that identify a campaign or click rather than the article
+TRACKING_PARAMS = {'fbclid', 'gclid', 'dclid', 'msclkid', 'mc_cid', 'mc_eid', 'igshid', 'ref', 'ref_src', 'cmpid'}
+
+
+def canonical_url(url: str) -> str:
+    """Lowercased scheme and host, no default port, fragment, tracking parameters or trailing slash"""
+    parts = urlsplit(url.strip())
+    scheme = parts.scheme.lower()
+    host = (parts.hostname or '').lower()
+    if host.startswith('www.'):
+        host = host[4:]
+    port = parts.port
+    netloc = host if not port or (scheme, port) in (('http', 80), ('https', 443)) else f"{host}:{port}"
+
+    query = sorted(
+        (key, value) for key, value in parse_qsl(parts.query, keep_blank_values=True)
+        if not key.lower().startswith('utm_') and key.lower() not in TRACKING_PARAMS
+    )
+    path = parts.path or '/'
+    if len(path) > 1:
+        path = path.rstrip('/')
+    return urlunsplit((scheme, netloc, path, urlencode(query), ''))
+
+
+def normalized_text_hash(title: str, content: str) -> str:
+    """Hash of the visible text, insensitive to markup, case and whitespace"""
+    text = re.sub(r'<[^>]+>', ' ', f"{title}\n{content}")
+    text = re.sub(r'\s+', ' ', text).strip().lower()
+    return hashlib.sha256(text.encode('utf-8')).hexdigest()
+
+
+def normalize_language(language: Optional[str]) -> str:
+    """ISO 639-1 code from a tag ("en-US") or a name ("English"), defaulting to English"""
+    value = (language or '').strip().lower().replace('_', '-')
+    if value in LANGUAGE_NAMES:
+        return LANGUAGE_NAMES[value]
+    primary = value.split('-')[0]
+    return primary if re.fullmatch(r'[a-z]{2,3}', primary) else 'en'
+
+
+def normalize_category(category: Optional[str]) -> str:
+    value = re.sub(r'\s+', ' ', (category or '').strip().lower())
+    if value in CATEGORIES:
+        return value
+    return CATEGORY_ALIASES.get(value, DEFAULT_CATEGORY)
+
+
+def _find_duplicate(cursor, url: str, text_hash: str) -> Optional[Dict[str, Any]]:
+    """An existing article with the same canonical URL, or failing that the same text"""
+    cursor.execute("""
+        SELECT a.id, i.content_hash, i.canonical_url, 'url' AS matched_by
+        FROM articles a LEFT JOIN ingested_articles i ON i.article_id = a.id
+        WHERE a.source_url = %s OR i.canonical_url = %s
+        LIMIT 1
+    """, (url, url))
+    match = cursor.fetchone()
+    if match:
+        return dict(match)
+    cursor.execute("""
+        SELECT article_id AS id, content_hash, canonical_url, 'content' AS matched_by
+        FROM ingested_articles WHERE content_hash = %s
+        LIMIT 1
+    """, (text_hash,))
+    match = cursor.fetchone()
+    return dict(match) if match else None
+
+
+def ingest_article(cursor, item: Dict[str, Any], api_key: Dict[str, Any]) -> Dict[str, Any]:
+    """Store one submitted article, returning its outcome and local id"""
+    url = canonical_url(item.get('canonical_url') or item['url'])
+    content = sanitize_html(item['content'])
+    text_hash = normalized_text_hash(item['title'], content)
+    language = normalize_language(item.get('language'))
+    category = normalize_category(item.get('category'))
+    tags = sorted({tag.strip().lower() for tag in item.get('tags') or [] if tag and tag.strip()})
+
+    provenance = {
+        'source_name': item.get('source_name') or api_key['name'],
+        'source_url': item['url'],
+        'canonical_url': url,
+        'external_id': item.get('external_id'),
+        'author_name': item.get('author_name'),
+        'original_language': item.get('language'),
+        'original_category': item.get('category'),
+        'api_key_id': str(api_key['id']),
+        'ingested_at': datetime.now(timezone.utc).isoformat(),
+    }
+
+    duplicate = _find_duplicate(cursor, url, text_hash)
+    if duplicate and (duplicate['matched_by'] == 'content' or duplicate['content_hash'] in (None, text_hash)):
+        # Identical text, or a URL that was published here without going through ingestion
+        return {'status': DUPLICATE, 'article_id': str(duplicate['id']), 'matched_by': duplicate['matched_by']}
+
+    values = {
+        'title': item['title'][:500],
+        'content': content,
+        'summary': item.get('summary'),
+        'category': category,
+        'tags': tags,
+        'language': language,
+        'license': item.get('license') if item.get('license') in LICENSES else 'all-rights-reserved',
+        'image_urls': list(item.get('image_urls') or []),
+        'reading_time': calculate_reading_time(content),
+        'word_count': calculate_word_count(content),
+        **readability_columns(content, language),
+    }
+
+    if duplicate:
+        # Same URL, changed text: the source updated its article
+        assignments = ', '.join(f"{column} = %s" for column in values)
+        cursor.execute(f"""
+            UPDATE articles
+            SET {assignments}, metadata = jsonb_set(COALESCE(metadata, '{{}}'::jsonb), '{{ingestion}}', %s::jsonb),
+                updated_at = NOW()
+            WHERE id = %s
+        """, list(values.values()) + [provenance, duplicate['id']])
+        cursor.execute("""
+            UPDATE ingested_articles SET content_hash = %s, last_ingested_at = NOW() WHERE article_id = %s
+        """, (text_hash, duplicate['id']))
+        return {'status': UPDATED, 'article_id': str(duplicate['id'])}
+
+    decision = check_publish({'category': category, 'tags': tags})
+    if decision.action == REJECT:
+        return {'status': REJECTED, 'reason': decision.reason}
+
+    columns = list(values) + ['source_url', 'metadata', 'status', 'published_at']
+    published_at = item.get('published_at') or datetime.now(timezone.utc)
+    status = 'draft' if decision.action == REVIEW else 'published'
+    cursor.execute(f"""
+        INSERT INTO articles ({', '.join(columns)})
+        VALUES ({', '.join(['%s'] * len(columns))})
+        RETURNING *
+    """, list(values.values()) + [url, {'ingestion': provenance}, status, published_at if status == 'published' else None])
+    article = dict(cursor.fetchone())
+
+    cursor.execute("""
+        INSERT INTO ingested_articles (article_id, canonical_url, content_hash, source_name, external_id, api_key_id)
+        VALUES (%s, %s, %s, %s, %s, %s)
+    """, (article['id'], url, text_hash, provenance['source_name'], item.get('external_id'), api_key['id']))
+
+    if status == 'draft':
+        request_review(cursor, article['id'], decision.reason, None)
+        return {'status': PENDING_REVIEW, 'article_id': str(article['id']), 'reason': decision.reason}
+
+    on_article_published(cursor, article)
+    return {'status': CREATED, 'article_id': str(article['id'])}
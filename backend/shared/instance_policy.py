@@ -0,0 +1,121 @@
+"""
+Instance-level content policy
+
+Node operators in different jurisdictions configure what their instance
+carries through the `instance_policy` settings key: categories that are never
+published, tags that need a moderator's approval before an article goes live,
+and which remote instances and categories federated content is accepted from.
+The same policy is published in the node metadata so peers can see it.
+"""
+
+import logging
+from dataclasses import dataclass
+from typing import Any, Dict, Iterable, List, Optional
+
+from shared.settings import get_setting
+
+logger = logging.getLogger(__name__)
+
+ALLOW = 'allow'
+REVIEW = 'review'
+REJECT = 'reject'
+
+
+@dataclass
+class PolicyDecision:
+    action: str  # allow, review or reject
+    reason: Optional[str] = None
+
+    @property
+    def allowed(self) -> bool:
+        return self.action == ALLOW
+
+
+def get_instance_policy() -> Dict[str, Any]:
+    return get_setting('instance_policy')
+
+
+def _normalized(values: Iterable[str]) -> List[str]:
+    return [value.strip().lower() for value in values or [] if value and value.strip()]
+
+
+def check_category(category: Optional[str], policy: Optional[Dict[str, Any]] = None) -> PolicyDecision:
+    policy = policy or get_instance_policy()
+    if category and category.strip().lower() in _normalized(policy.get('blocked_categories')):
+        return PolicyDecision(REJECT, f"Category '{category}' is not permitted on this instance")
+    return PolicyDecision(ALLOW)
+
+
+def check_publish(article: Dict[str, Any], policy: Optional[Dict[str, Any]] = None) -> PolicyDecision:
+    """Whether a local article may go live now, needs review first, or is refused"""
+    policy = policy or get_instance_policy()
+
+    decision = check_category(article.get('category'), policy)
+    if not decision.allowed:
+        return decision
+
+    moderated = set(_normalized(policy.get('moderated_tags')))
+    flagged = sorted(moderated & set(_normalized(article.get('tags'))))
+    if flagged:
+        return PolicyDecision(REVIEW, f"Tags requiring moderator review: {', '.join(flagged)}")
+    return PolicyDecision(ALLOW)
+
+
+def _domain_matches(domain: str, patterns: Iterable[str]) -> bool:
+    return any(domain == pattern or domain.endswith(f".{pattern}") for pattern in _normalized(patterns))
+
+
+def check_federated(instance_domain: str, article: Dict[str, Any],
+                    policy: Optional[Dict[str, Any]] = None) -> PolicyDecision:
+    """Whether content arriving from a remote instance is accepted
+
+    Remote content is never queued for review: it is accepted or refused.
+    """
+    policy = policy or get_instance_policy()
+    federation = policy.get('federation') or {}
+    domain = (instance_domain or '').strip().lower().rstrip('.')
+
+    if not federation.get('accept_remote_content', True):
+        return PolicyDecision(REJECT, "This instance does not accept remote content")
+    if _domain_matches(domain, federation.get('blocked_instances')):
+        return PolicyDecision(REJECT, f"Instance {domain} is blocked")
+    allowed = federation.get('allowed_instances')
+    if allowed and not _domain_matches(domain, allowed):
+        return PolicyDecision(REJECT, f"Instance {domain} is not on the allow list")
+
+    decision = check_category(article.get('category'), policy)
+    if not decision.allowed:
+        return decision
+    category = (article.get('category') or '').strip().lower()
+    if category and category in _normalized(federation.get('rejected_categories')):
+        return PolicyDecision(REJECT, f"Remote content in category '{article['category']}' is not accepted")
+    return PolicyDecision(ALLOW)
+
+
+def request_review(cursor, article_id: str, reason: str, requested_by: Optional[str]) -> Dict[str, Any]:
+    """Queue an article for moderator review, reusing an open request if there is one"""
+    cursor.execute("""
+        INSERT INTO article_reviews (article_id, reason, requested_by)
+        VALUES (%s, %s, %s)
+        ON CONFLICT (article_id) WHERE status = 'pending'
+        DO UPDATE SET reason = EXCLUDED.reason
+        RETURNING *
+    """, (article_id, reason, requested_by))
+    return dict(cursor.fetchone())
+
+
+def public_policy(policy: Optional[Dict[str, Any]] = None) -> Dict[str, Any]:
+    """The policy as published in node metadata"""
+    policy = policy or get_instance_policy()
+    federation = policy.get('federation') or {}
+    return {
+        'jurisdiction': policy.get('jurisdiction'),
+        'blocked_categories': _normalized(policy.get('blocked_categories')),
+        'moderated_tags': _normalized(policy.get('moderated_tags')),
+        'federation': {
+            'accept_remote_content': federation.get('accept_remote_content', True),
+            'allowed_instances': _normalized(federation.get('allowed_instances')),
+            'blocked_instances': _normalized(federation.get('blocked_instances')),
+            'rejected_categories': _normalized(federation.get('rejected_categories')),
+        },
+    }
@@ -0,0 +1,827 @@
+"""
+Background job queue for deferred work
+
+Jobs run on Celery workers with Redis as broker and result backend. Every job
+retries transient failures with exponential backoff; failures and retries are
+recorded in Redis so the admin jobs endpoint can show them.
+
+Run a worker and the periodic scheduler with:
+
+    celery -A shared.jobs worker --loglevel=info
+    celery -A shared.jobs beat --loglevel=info
+"""
+
+import os
+import json
+import logging
+from datetime import datetime, timedelta
+from typing import Any, Dict, List, Optional
+
+from celery import Celery, Task
+from celery.signals import (
+    after_setup_logger, after_setup_task_logger, beat_init, before_task_publish, task_failure, task_retry
+)
+
+from shared.database import get_postgres_cursor, get_redis
+from shared.modules import job_enabled
+from shared.pii import install_log_scrubbing, redact, scrub
+from shared.utils import calculate_trending_score, safe_json_dumps
+
+logger = logging.getLogger(__name__)
+
+
+def _redis_url() -> str:
+    password = os.getenv('REDIS_PASSWORD', 'redis_password')
+    auth = f":{password}@" if password else ''
+    return (
+        f"redis://{auth}{os.getenv('REDIS_HOST', 'localhost')}:{os.getenv('REDIS_PORT', 6379)}"
+        f"/{os.getenv('JOBS_REDIS_DB', 1)}"
+    )
+
+
+class TenantTask(Task):
+    """Runs a job in the tenant that enqueued it
+
+    Jobs from sandbox requests run against the sandbox, and jobs from a
+    publication's requests run for that publication, seeing only its rows.
+    Periodic jobs see every publication.
+    """
+
+    def __call__(self, *args, **kwargs):
+        from shared import tenancy
+
+        tenant_id = self.request.get('tenant')
+        if tenant_id == tenancy.DEFAULT:
+            with tenancy.scope(None):
+                return self._in_sandbox_or_production(*args, **kwargs)
+        if tenant_id:
+            tenant = tenancy.by_id(tenant_id)
+            if not tenant:
+                logger.info(f"Dropped {self.name} enqueued for inactive tenant {tenant_id}")
+                return None
+            with tenancy.scope(tenant):
+                return self._in_sandbox_or_production(*args, **kwargs)
+        with tenancy.unscoped():
+            return self._in_sandbox_or_production(*args, **kwargs)
+
+    def _in_sandbox_or_production(self, *args, **kwargs):
+        from shared import sandbox
+
+        if not self.request.get('sandbox'):
+            return super().__call__(*args, **kwargs)
+        if self.name in sandbox.OUTBOUND_JOBS:
+            logger.info(f"Dropped {self.name} enqueued from the sandbox")
+            return None
+        with sandbox.scope():
+            return super().__call__(*args, **kwargs)
+
+
+celery_app = Celery('news_app', broker=os.getenv('JOBS_BROKER_URL', _redis_url()),
+                    backend=os.getenv('JOBS_RESULT_BACKEND', _redis_url()), task_cls=TenantTask)
+
+celery_app.conf.update(
+    task_serializer='json',
+    result_serializer='json',
+    accept_content=['json'],
+    task_acks_late=True,  # A job interrupted by a worker crash is redelivered
+    task_reject_on_worker_lost=True,
+    worker_prefetch_multiplier=1,
+    worker_concurrency=int(os.getenv('JOBS_WORKER_CONCURRENCY', 4)),
+    worker_pool=os.getenv('JOBS_WORKER_POOL', 'prefork'),
+    result_expires=int(os.getenv('JOBS_RESULT_TTL_SECONDS', 24 * 60 * 60)),
+    task_default_queue='default',
+    task_routes={
+        'jobs.send_email': {'queue': 'email'},
+        'jobs.snapshot_article': {'queue': 'storage'},
+        'jobs.export_warehouse': {'queue': 'storage'},
+        'jobs.deliver_push': {'queue': 'push'},
+        'jobs.send_newsletter_digest': {'queue': 'email'},
+        'jobs.publish_media': {'queue': 'storage'},
+        'jobs.archive_article': {'queue': 'storage'},
+        'jobs.archive_articles': {'queue': 'storage'},
+    },
+    beat_schedule={
+        'deliver-webhooks': {
+            'task': 'jobs.deliver_webhooks',
+            'schedule': float(os.getenv('WEBHOOK_POLL_SECONDS', 5)),
+        },
+        'recalculate-trending-scores': {
+            'task': 'jobs.recalculate_trending_scores',
+            'schedule': float(os.getenv('JOBS_TRENDING_INTERVAL_SECONDS', 15 * 60)),
+        },
+        'sync-federation-peers': {
+            'task': 'jobs.sync_federation_peers',
+            'schedule': float(os.getenv('FEDERATION_POLL_SECONDS', 60)),
+        },
+        'fetch-imported-feeds': {
+            'task': 'jobs.fetch_imported_feeds',
+            'schedule': float(os.getenv('FEED_IMPORT_POLL_SECONDS', 5 * 60)),
+        },
+        'compact-draft-documents': {
+            'task': 'jobs.compact_draft_documents',
+            'schedule': float(os.getenv('DRAFT_COLLAB_SNAPSHOT_SECONDS', 60)),
+        },
+        'evaluate-experiments': {
+            'task': 'jobs.evaluate_experiments',
+            'schedule': float(os.getenv('EXPERIMENT_EVALUATION_SECONDS', 15 * 60)),
+        },
+        'purge-deleted-accounts': {
+            'task': 'jobs.purge_deleted_accounts',
+            'schedule': float(os.getenv('ACCOUNT_PURGE_INTERVAL_SECONDS', 60 * 60)),
+        },
+        'publish-scheduled-articles': {
+            'task': 'jobs.publish_scheduled_articles',
+            'schedule': float(os.getenv('SCHEDULED_PUBLISH_POLL_SECONDS', 15)),
+        },
+        'anchor-articles': {
+            'task': 'jobs.anchor_articles',
+            'schedule': float(os.getenv('ANCHOR_INTERVAL_SECONDS', 10 * 60)),
+        },
+        'archive-articles': {
+            'task': 'jobs.archive_articles',
+            'schedule': float(os.getenv('ARCHIVE_INTERVAL_SECONDS', 60 * 60)),
+        },
+        'embed-articles': {
+            'task': 'jobs.embed_articles',
+            'schedule': float(os.getenv('EMBEDDING_INTERVAL_SECONDS', 10 * 60)),
+        },
+        'check-consistency': {
+            'task': 'jobs.check_consistency',
+            'schedule': float(os.getenv('CONSISTENCY_CHECK_INTERVAL_SECONDS', 24 * 60 * 60)),
+        },
+        'roll-up-trends': {
+            'task': 'jobs.roll_up_trends',
+            'schedule': float(os.getenv('TRENDS_ROLLUP_INTERVAL_SECONDS', 15 * 60)),
+        },
+        'reset-sandbox': {
+            'task': 'jobs.reset_sandbox',
+            'schedule': float(os.getenv('SANDBOX_RESET_INTERVAL_SECONDS', 24 * 60 * 60)),
+        },
+        'refresh-taxonomy-usage': {
+            'task': 'jobs.refresh_taxonomy_usage',
+            'schedule': float(os.getenv('TAXONOMY_REFRESH_INTERVAL_SECONDS', 5 * 60)),
+        },
+        'roll-up-cohorts': {
+            'task': 'jobs.roll_up_cohorts',
+            'schedule': float(os.getenv('COHORT_ROLLUP_INTERVAL_SECONDS', 60 * 60)),
+        },
+        'compute-funnels': {
+            'task': 'jobs.compute_funnels',
+            'schedule': float(os.getenv('FUNNEL_INTERVAL_SECONDS', 60 * 60)),
+        },
+        'reconcile-tips': {
+            'task': 'jobs.reconcile_tips',
+            'schedule': float(os.getenv('TIPS_RECONCILE_INTERVAL_SECONDS', 5 * 60)),
+        },
+        'decay-reputation': {
+            'task': 'jobs.decay_reputation',
+            'schedule': float(os.getenv('REPUTATION_DECAY_INTERVAL_SECONDS', 24 * 60 * 60)),
+        },
+        'export-warehouse': {
+            'task': 'jobs.export_warehouse',
+            'schedule': float(os.getenv('WAREHOUSE_EXPORT_INTERVAL_SECONDS', 60 * 60)),
+        },
+        'flush-view-counts': {
+            'task': 'jobs.flush_view_counts',
+            'schedule': float(os.getenv('VIEW_FLUSH_INTERVAL_SECONDS', 30)),
+        },
+        'tally-governance': {
+            'task': 'jobs.tally_governance',
+            'schedule': float(os.getenv('GOVERNANCE_TALLY_INTERVAL_SECONDS', 5 * 60)),
+        },
+        'prune-push-devices': {
+            'task': 'jobs.prune_push_devices',
+            'schedule': float(os.getenv('PUSH_PRUNE_INTERVAL_SECONDS', 24 * 60 * 60)),
+        },
+        'send-newsletter-digests': {
+            'task': 'jobs.send_newsletter_digests',
+            'schedule': float(os.getenv('NEWSLETTER_POLL_SECONDS', 15 * 60)),
+        },
+        'check-dead-letters': {
+            'task': 'jobs.check_dead_letters',
+            'schedule': float(os.getenv('DEAD_LETTER_CHECK_INTERVAL_SECONDS', 5 * 60)),
+        },
+    },
+)
+
+# Disabled modules' periodic jobs are not scheduled
+celery_app.conf.beat_schedule = {
+    entry: schedule for entry, schedule in celery_app.conf.beat_schedule.items() if job_enabled(entry)
+}
+
+# Shared retry policy: exponential backoff with jitter, capped
+RETRY_POLICY = {
+    'autoretry_for': (Exception,),
+    'retry_backoff': int(os.getenv('JOBS_RETRY_BACKOFF_SECONDS', 10)),
+    'retry_backoff_max': int(os.getenv('JOBS_RETRY_BACKOFF_MAX_SECONDS', 30 * 60)),
+    'retry_jitter': True,
+    'max_retries': int(os.getenv('JOBS_MAX_RETRIES', 5)),
+}
+
+FAILURES_KEY = 'jobs:failures'
+MAX_RECORDED_FAILURES = 200
+
+
+def _record(kind: str, task_id: Optional[str], task_name: Optional[str], error: Any, args=None, kwargs=None):
+    entry = {
+        'kind': kind,
+        'task_id': task_id,
+        'task': task_name,
+        'error': scrub(str(error))[:1000],
+        'args': redact(args),
+        'kwargs': redact(kwargs),
+        'at': datetime.now().isoformat(),
+    }
+    try:
+        redis_client = get_redis()
+        redis_client.lpush(FAILURES_KEY, safe_json_dumps(entry))
+        redis_client.ltrim(FAILURES_KEY, 0, MAX_RECORDED_FAILURES - 1)
+    except Exception as e:
+        logger.warning(f"Could not record job {kind}: {e}")
+
+
+@after_setup_logger.connect
+@after_setup_task_logger.connect
+def scrub_worker_logs(logger, **extra):
+    install_log_scrubbing(logger)
+
+
+@beat_init.connect
+def schedule_unscoped(**extra):
+    """Periodic jobs are enqueued without a tenant, so they run across every publication"""
+    from shared.tenancy import begin_unscoped
+    begin_unscoped()
+
+
+@before_task_publish.connect
+def mark_tenant_jobs(headers=None, **extra):
+    from shared.sandbox import active
+    from shared.tenancy import DEFAULT, confined, current_id
+
+    if headers is None:
+        return
+    if active():
+        headers['sandbox'] = True
+    if current_id():
+        headers['tenant'] = current_id()
+    elif confined():
+        headers['tenant'] = DEFAULT
+
+
+@task_failure.connect
+def on_task_failure(sender=None, task_id=None, exception=None, args=None, kwargs=None, **extra):
+    _record('failure', task_id, getattr(sender, 'name', None), exception, args, kwargs)
+
+
+@task_retry.connect
+def on_task_retry(sender=None, request=None, reason=None, **extra):
+    _record('retry', getattr(request, 'id', None), getattr(sender, 'name', None), reason,
+            getattr(request, 'args', None), getattr(request, 'kwargs', None))
+
+
+def recent_failures(limit: int = 50) -> List[Dict[str, Any]]:
+    entries = get_redis().lrange(FAILURES_KEY, 0, limit - 1)
+    return [json.loads(entry) for entry in entries]
+
+
+@celery_app.task(name='jobs.send_email', **RETRY_POLICY)
+def send_email(to: str, subject: str, body: str, html: Optional[str] = None,
+               headers: Optional[Dict[str, str]] = None):
+    """Send an email through the configured email provider"""
+    from shared.mailer import provider
+
+    provider().send(to, subject, body, html, headers)
+
+
+@celery_app.task(name='jobs.snapshot_article', **RETRY_POLICY)
+def snapshot_article(article_id: str) -> Optional[str]:
+    """Store a JSON snapshot of a published article with the snapshot storage driver, returning its reference
+
+    Premium articles are not snapshotted; snapshot drivers publish what they store.
+    """
+    from shared.storage import store_snapshot
+
+    with get_postgres_cursor() as cursor:
+        cursor.execute("""
+            SELECT id, title, summary, content, author_id, anonymous_author, category, tags,
+                   language, published_at, license
+            FROM articles WHERE id = %s AND status = 'published' AND access_policy IS NULL
+        """, (article_id,))
+        article = cursor.fetchone()
+    if not article:
+        return None
+
+    article = dict(article)
+    if article.pop('anonymous_author'):
+        article['author_id'] = None
+    return store_snapshot(article_id, safe_json_dumps(article))['ref']
+
+
+@celery_app.task(name='jobs.recalculate_article_scores', **RETRY_POLICY)
+def recalculate_article_scores(article_id: str):
+    """Recompute engagement and trending scores for one article"""
+    from shared.reactions import update_engagement_score
+
+    with get_postgres_cursor() as cursor:
+        update_engagement_score(cursor, article_id)
+        _update_trending_score(cursor, article_id)
+
+
+@celery_app.task(name='jobs.recalculate_trending_scores', **RETRY_POLICY)
+def recalculate_trending_scores(window_days: int = 7) -> int:
+    """Recompute trending scores for recently published articles"""
+    with get_postgres_cursor() as cursor:
+        cursor.execute("""
+            SELECT id FROM articles
+            WHERE status = 'published' AND published_at >= %s
+        """, (datetime.now() - timedelta(days=window_days),))
+        article_ids = [row['id'] for row in cursor.fetchall()]
+
+        for article_id in article_ids:
+            _update_trending_score(cursor, article_id)
+
+    from shared import article_stream, feed_versions
+    feed_versions.bump()
+    try:
+        with get_postgres_cursor() as cursor:
+            article_stream.announce_trending(cursor)
+    except Exception as e:
+        logger.warning(f"Announcing trending changes to the article stream failed: {e}")
+    return len(article_ids)
+
+
+def _update_trending_score(cursor, article_id: str):
+    cursor.execute("""
+        SELECT view_count, like_count, share_count, comment_count, published_at
+        FROM articles WHERE id = %s AND published_at IS NOT NULL
+    """, (article_id,))
+    stats = cursor.fetchone()
+    if not stats:
+        return
+
+    published_at = stats['published_at']
+    now = datetime.now(published_at.tzinfo) if published_at.tzinfo else datetime.now()
+    score = calculate_trending_score(
+        stats['view_count'] or 0, stats['like_count'] or 0,
+        stats['share_count'] or 0, stats['comment_count'] or 0,
+        published_at, now
+    )
+    cursor.execute("UPDATE articles SET trending_score = %s WHERE id = %s", (score, article_id))
+
+
+@celery_app.task(name='jobs.deliver_webhooks', max_retries=0)
+def deliver_webhooks() -> int:
+    """Send due webhook deliveries; the dispatcher handles its own retries"""
+    from shared.webhooks import webhook_dispatcher
+
+    delivered = 0
+    while True:
+        batch = webhook_dispatcher.deliver_due()
+        delivered += batch
+        if batch < webhook_dispatcher.batch_size:
+            return delivered
+
+
+@celery_app.task(name='jobs.sync_federation_peer', **RETRY_POLICY)
+def sync_federation_peer(peer_id: str) -> Dict[str, Any]:
+    """Pull and revalidate one peer's content"""
+    from shared.federation import sync_peer
+
+    return sync_peer(peer_id)
+
+
+@celery_app.task(name='jobs.sync_federation_peers', max_retries=0)
+def sync_federation_peers() -> int:
+    """Queue a sync for every peer whose refresh interval has elapsed"""
+    from shared.federation import peers_due
+
+    with get_postgres_cursor() as cursor:
+        peer_ids = peers_due(cursor)
+    for peer_id in peer_ids:
+        sync_federation_peer.delay(peer_id)
+    return len(peer_ids)
+
+
+@celery_app.task(name='jobs.fetch_imported_feed', **RETRY_POLICY)
+def fetch_imported_feed(feed_id: str) -> Dict[str, Any]:
+    """Fetch one imported feed and turn its new items into drafts"""
+    from shared.feed_import import fetch_feed
+
+    return fetch_feed(feed_id)
+
+
+@celery_app.task(name='jobs.fetch_imported_feeds', max_retries=0)
+def fetch_imported_feeds() -> int:
+    """Queue a fetch for every imported feed whose refresh interval has elapsed"""
+    from shared.feed_import import feeds_due
+
+    with get_postgres_cursor() as cursor:
+        feed_ids = feeds_due(cursor)
+    for feed_id in feed_ids:
+        fetch_imported_feed.delay(feed_id)
+    return len(feed_ids)
+
+
+@celery_app.task(name='jobs.deliver_activity', **RETRY_POLICY)
+def deliver_activity(user_id: str, inbox_url: str, activity: Dict[str, Any]):
+    """Deliver an ActivityPub activity to a remote inbox, signed by the local actor"""
+    from shared.activitypub import deliver
+
+    deliver(user_id, inbox_url, activity)
+
+
+@celery_app.task(name='jobs.evaluate_experiments', max_retries=0)
+def evaluate_experiments() -> int:
+    """Sync running experiments' counts and promote significant winners, one transaction each"""
+    from shared.experiments import evaluate_experiment, running_experiment_ids
+
+    with get_postgres_cursor() as cursor:
+        experiment_ids = running_experiment_ids(cursor)
+    concluded = 0
+    for experiment_id in experiment_ids:
+        try:
+            with get_postgres_cursor() as cursor:
+                cursor.execute("SELECT * FROM experiments WHERE id = %s FOR UPDATE", (experiment_id,))
+                experiment = cursor.fetchone()
+                if experiment and experiment['status'] == 'running' and evaluate_experiment(cursor, dict(experiment)):
+                    concluded += 1
+        except Exception as e:
+            logger.warning(f"Evaluating experiment {experiment_id} failed: {e}")
+    return concluded
+
+
+@celery_app.task(name='jobs.generate_og_image', **RETRY_POLICY)
+def generate_og_image(article_id: str) -> Optional[str]:
+    """Render an article's share card and store it in the media store"""
+    from shared.og_images import generate_for_article
+
+    with get_postgres_cursor() as cursor:
+        return generate_for_article(cursor, article_id)
+
+
+@celery_app.task(name='jobs.fetch_link_previews', **RETRY_POLICY)
+def fetch_link_previews(article_id: str) -> int:
+    """Fetch Open Graph previews of an article's source URL and the links in its content"""
+    from shared.link_previews import refresh_article
+
+    return refresh_article(article_id)
+
+
+@celery_app.task(name='jobs.backfill_readability', **RETRY_POLICY)
+def backfill_readability(batch_size: int = 500) -> int:
+    """Score the readability of articles stored before scoring existed, a batch per transaction"""
+    from shared.readability import backfill_readability as score_batch
+
+    total = 0
+    while True:
+        with get_postgres_cursor() as cursor:
+            scored = score_batch(cursor, batch_size)
+        total += scored
+        if scored < batch_size:
+            return total
+
+
+@celery_app.task(name='jobs.compact_draft_documents', max_retries=0)
+def compact_draft_documents() -> int:
+    """Fold collaborative editing updates into each draft's snapshot"""
+    from shared.draft_collab import compact, drafts_with_pending_updates
+
+    with get_postgres_cursor() as cursor:
+        article_ids = drafts_with_pending_updates(cursor)
+    compacted = 0
+    for article_id in article_ids:
+        try:
+            with get_postgres_cursor() as cursor:
+                compacted += compact(cursor, article_id)
+        except Exception as e:
+            logger.warning(f"Compacting draft document {article_id} failed: {e}")
+    return compacted
+
+
+@celery_app.task(name='jobs.purge_deleted_accounts', max_retries=0)
+def purge_deleted_accounts() -> int:
+    """Anonymize accounts whose deletion grace period has ended, one transaction each"""
+    from shared.account_deletion import accounts_due_for_purge, purge_account
+
+    with get_postgres_cursor() as cursor:
+        user_ids = accounts_due_for_purge(cursor)
+    purged = 0
+    for user_id in user_ids:
+        try:
+            with get_postgres_cursor() as cursor:
+                if purge_account(cursor, user_id) is not None:
+                    purged += 1
+        except Exception as e:
+            logger.error(f"Purging user {user_id} failed: {e}")
+    return purged
+
+
+@celery_app.task(name='jobs.prime_article_caches', **RETRY_POLICY)
+def prime_article_caches(article_id: str) -> bool:
+    """Render a scheduled article's card and stage its JSON and feeds, then queue the publish for its time"""
+    from shared.scheduled_publishing import prime
+
+    if not prime(article_id):
+        return False
+    with get_postgres_cursor() as cursor:
+        cursor.execute("SELECT scheduled_publish_at FROM articles WHERE id = %s", (article_id,))
+        row = cursor.fetchone()
+    if row and row['scheduled_publish_at']:
+        publish_scheduled_article.apply_async(args=[article_id], eta=row['scheduled_publish_at'])
+    return True
+
+
+@celery_app.task(name='jobs.publish_scheduled_article', **RETRY_POLICY)
+def publish_scheduled_article(article_id: str) -> bool:
+    """Publish a scheduled article if its time has come and it is still scheduled"""
+    from shared.scheduled_publishing import publish
+
+    return publish(article_id)
+
+
+@celery_app.task(name='jobs.publish_scheduled_articles', max_retries=0)
+def publish_scheduled_articles() -> Dict[str, int]:
+    """Prime articles going live soon and publish any whose time has passed without a queued publish"""
+    from shared import tenancy
+    from shared.scheduled_publishing import articles_due, articles_to_prime, prime, publish
+
+    with get_postgres_cursor() as cursor:
+        to_prime = articles_to_prime(cursor)
+        due = articles_due(cursor)
+
+    # Each article is handled for its publication, which also tags the publish job queued for it
+    summary = {'primed': 0, 'published': 0}
+    for article in to_prime:
+        try:
+            with tenancy.scope_of(article['tenant_id']):
+                if prime(str(article['id'])):
+                    summary['primed'] += 1
+                    publish_scheduled_article.apply_async(
+                        args=[str(article['id'])], eta=article['scheduled_publish_at']
+                    )
+        except Exception as e:
+            logger.warning(f"Priming caches for article {article['id']} failed: {e}")
+    for article in due:
+        try:
+            with tenancy.scope_of(article['tenant_id']):
+                if publish(str(article['id'])):
+                    summary['published'] += 1
+        except Exception as e:
+            logger.error(f"Publishing scheduled article {article['id']} failed: {e}")
+    return summary
+
+
+@celery_app.task(name='jobs.anchor_articles', max_retries=0)
+def anchor_articles() -> Dict[str, int]:
+    """Anchor a Merkle root over newly published and revised articles on-chain"""
+    from shared.anchoring import anchor_articles as run_anchoring
+
+    return run_anchoring()
+
+
+@celery_app.task(name='jobs.archive_articles', max_retries=0)
+def archive_articles() -> Dict[str, int]:
+    """Commit bundles of newly published and revised articles to permanent storage and confirm earlier ones"""
+    from shared.archival import archive_articles as run_archival
+
+    return run_archival()
+
+
+@celery_app.task(name='jobs.archive_article', **RETRY_POLICY)
+def archive_article(archive_id: str) -> Optional[str]:
+    """Submit one archive requested on demand, returning its status"""
+    from shared.archival import archive_article as run_archive
+
+    archive = run_archive(archive_id)
+    return archive['status'] if archive else None
+
+
+@celery_app.task(name='jobs.embed_articles', **RETRY_POLICY)
+def embed_articles() -> int:
+    """Embed a batch of published articles that have no embedding or were edited since"""
+    from shared.embeddings import embed_pending
+
+    return embed_pending()
+
+
+@celery_app.task(name='jobs.embed_article', **RETRY_POLICY)
+def embed_article(article_id: str) -> bool:
+    """Embed one article for related articles"""
+    from shared.embeddings import embed_article as run_embedding
+
+    with get_postgres_cursor() as cursor:
+        return run_embedding(cursor, article_id)
+
+
+@celery_app.task(name='jobs.check_consistency')
+def check_consistency(trigger: str = 'scheduled', repair: Optional[bool] = None, names: Optional[List[str]] = None,
+                      requested_by: Optional[str] = None) -> Optional[Dict[str, int]]:
+    """Run the database consistency checks and store the report; repairs follow CONSISTENCY_AUTO_REPAIR by default"""
+    from shared.consistency import run
+
+    report = run(trigger, repair, names, requested_by)
+    return {'violations': report['violations'], 'repaired': report['repaired']} if report else None
+
+
+@celery_app.task(name='jobs.reset_sandbox')
+def reset_sandbox(trigger: str = 'scheduled', requested_by: Optional[str] = None) -> Optional[Dict[str, int]]:
+    """Rebuild the sandbox schema from production's structure and reseed its fake data"""
+    from shared.sandbox import reset
+
+    result = reset(trigger, requested_by)
+    return {'tables': result['tables'], 'status': result['status']} if result else None
+
+
+@celery_app.task(name='jobs.roll_up_trends', **RETRY_POLICY)
+def roll_up_trends(days: Optional[int] = None) -> int:
+    """Recompute tag and category trend rollups for the last `days` days (backfill by passing more)"""
+    from shared.trends import roll_up_recent
+
+    with get_postgres_cursor() as cursor:
+        return roll_up_recent(cursor, days)
+
+
+@celery_app.task(name='jobs.refresh_taxonomy_usage', **RETRY_POLICY)
+def refresh_taxonomy_usage() -> int:
+    """Recount how often each tag and category is used, for suggestions"""
+    from shared.taxonomy import refresh_usage
+
+    with get_postgres_cursor() as cursor:
+        return refresh_usage(cursor)
+
+
+@celery_app.task(name='jobs.roll_up_cohorts', **RETRY_POLICY)
+def roll_up_cohorts(weeks: Optional[int] = None) -> int:
+    """Recompute retention for the signup cohorts of the last `weeks` weeks (backfill by passing more)"""
+    from shared.cohorts import roll_up_recent
+
+    with get_postgres_cursor() as cursor:
+        return roll_up_recent(cursor, weeks)
+
+
+@celery_app.task(name='jobs.compute_funnels', **RETRY_POLICY)
+def compute_funnels(days: Optional[int] = None) -> Dict[str, int]:
+    """Recount every active funnel over the days readers may still convert on (backfill by passing `days`)"""
+    from shared.funnels import compute_recent
+
+    with get_postgres_cursor() as cursor:
+        return compute_recent(cursor, days)
+
+
+@celery_app.task(name='jobs.reconcile_tips', **RETRY_POLICY)
+def reconcile_tips() -> Dict[str, int]:
+    """Settle or fail card tips left pending by a missed Stripe webhook"""
+    from shared.tipping import reconcile_pending
+
+    with get_postgres_cursor() as cursor:
+        return reconcile_pending(cursor)
+
+
+@celery_app.task(name='jobs.decay_reputation', **RETRY_POLICY)
+def decay_reputation() -> int:
+    """Decay reputation scores toward zero by the configured half-life"""
+    from shared.reputation import decay_all
+
+    with get_postgres_cursor() as cursor:
+        return decay_all(cursor)
+
+
+@celery_app.task(name='jobs.export_warehouse', **RETRY_POLICY)
+def export_warehouse(datasets: Optional[List[str]] = None) -> Dict[str, Any]:
+    """Export rows changed since the last run to the data warehouse (every dataset, or those named)"""
+    from shared.warehouse import export_all
+
+    return export_all(datasets)
+
+
+@celery_app.task(name='jobs.flush_view_counts', **RETRY_POLICY)
+def flush_view_counts() -> int:
+    """Add views counted since the last run to the articles' view counts"""
+    from shared.view_counts import flush
+
+    return flush()
+
+
+@celery_app.task(name='jobs.tally_governance', max_retries=0)
+def tally_governance() -> Dict[str, int]:
+    """Tally governance proposals whose voting window closed and mirror results on-chain"""
+    from shared.governance import tally_proposals
+
+    return tally_proposals()
+
+
+@celery_app.task(name='jobs.deliver_push', **RETRY_POLICY)
+def deliver_push(device_id: str, notification: Dict[str, Any]) -> str:
+    """Push one notification to one registered device, dropping devices their push service forgot"""
+    from shared.push import deliver
+
+    return deliver(device_id, notification)
+
+
+@celery_app.task(name='jobs.prune_push_devices', max_retries=0)
+def prune_push_devices() -> int:
+    """Remove push devices their clients stopped re-registering"""
+    from shared.push import prune_devices
+
+    return prune_devices()
+
+
+@celery_app.task(name='jobs.send_newsletter_digests', max_retries=0)
+def send_newsletter_digests() -> int:
+    """Queue a digest for every subscriber whose daily or weekly newsletter is due"""
+    from shared.newsletters import subscribers_due
+
+    with get_postgres_cursor() as cursor:
+        user_ids = subscribers_due(cursor)
+    for user_id in user_ids:
+        send_newsletter_digest.delay(user_id)
+    return len(user_ids)
+
+
+@celery_app.task(name='jobs.send_newsletter_digest', **RETRY_POLICY)
+def send_newsletter_digest(user_id: str) -> bool:
+    """Compile and send one subscriber's digest, unless it was already sent this period"""
+    from shared.newsletters import send_digest
+
+    with get_postgres_cursor() as cursor:
+        return send_digest(cursor, user_id)
+
+
+@celery_app.task(name='jobs.publish_media', **RETRY_POLICY)
+def publish_media(media_id: str) -> bool:
+    """Copy an image uploaded to a draft from private storage to the media driver once the draft is published"""
+    from shared.media_uploads import publish_upload
+
+    with get_postgres_cursor() as cursor:
+        return publish_upload(cursor, media_id)
+
+
+@celery_app.task(name='jobs.backfill_clickstream', **RETRY_POLICY)
+def backfill_clickstream() -> int:
+    """Copy interactions recorded before the ClickHouse clickstream sink was enabled into it"""
+    from shared.clickstream import backfill
+
+    return backfill()
+
+
+@celery_app.task(name='jobs.replay_events')
+def replay_events(replay_id: str) -> Optional[Dict[str, Any]]:
+    """Feed a replay's events to its projection from its checkpoint until it completes, pauses or is cancelled"""
+    from shared.replay import run
+
+    replay = run(replay_id)
+    return {'status': replay['status'], 'processed_events': replay['processed_events']} if replay else None
+
+
+@celery_app.task(name='jobs.check_dead_letters', max_retries=0)
+def check_dead_letters() -> List[str]:
+    """Alert on consumers whose quarantined events reached the `dead_letters` thresholds"""
+    from shared.dead_letters import check_depth
+
+    return check_depth()
+
+
+# Jobs an administrator may enqueue by hand
+ENQUEUEABLE_JOBS = {
+    'recalculate_article_scores': recalculate_article_scores,
+    'recalculate_trending_scores': recalculate_trending_scores,
+    'snapshot_article': snapshot_article,
+    'deliver_webhooks': deliver_webhooks,
+    'sync_federation_peer': sync_federation_peer,
+    'fetch_imported_feed': fetch_imported_feed,
+    'backfill_readability': backfill_readability,
+    'evaluate_experiments': evaluate_experiments,
+    'generate_og_image': generate_og_image,
+    'fetch_link_previews': fetch_link_previews,
+    'purge_deleted_accounts': purge_deleted_accounts,
+    'anchor_articles': anchor_articles,
+    'archive_articles': archive_articles,
+    'archive_article': archive_article,
+    'prime_article_caches': prime_article_caches,
+    'check_consistency': check_consistency,
+    'reset_sandbox': reset_sandbox,
+    'embed_articles': embed_articles,
+    'embed_article': embed_article,
+    'roll_up_trends': roll_up_trends,
+    'refresh_taxonomy_usage': refresh_taxonomy_usage,
+    'roll_up_cohorts': roll_up_cohorts,
+    'compute_funnels': compute_funnels,
+    'reconcile_tips': reconcile_tips,
+    'decay_reputation': decay_reputation,
+    'export_warehouse': export_warehouse,
+    'backfill_clickstream': backfill_clickstream,
+    'tally_governance': tally_governance,
+    'flush_view_counts': flush_view_counts,
+    'prune_push_devices': prune_push_devices,
+    'send_newsletter_digests': send_newsletter_digests,
+    'publish_media': publish_media,
+    'check_dead_letters': check_dead_letters,
+}
+
+
+def enqueue_snapshot(cursor, article: Dict[str, Any]):
+    """Publish hook: snapshot newly published articles when snapshots are enabled"""
+    from shared.storage import snapshots_enabled
+
+    if snapshots_enabled():
+        snapshot_article.apply_async(args=[str(article['id'])], countdown=5)
@@ -0,0 +1,139 @@
+"""
+Spelling and grammar checking through a LanguageTool-compatible server
+
+Text is sent to the server's `/v2/check` endpoint at LANGUAGETOOL_URL (a
+self-hosted LanguageTool, or the hosted API with LANGUAGETOOL_USERNAME and
+LANGUAGETOOL_API_KEY). HTML content is sent as annotated data, markup kept
+apart from text, so issue offsets point into the stored content unchanged and
+fixes can be applied to it directly.
+"""
+
+import os
+import re
+import json
+import logging
+from datetime import datetime
+from typing import Any, Dict, Iterable, List, Optional, Tuple
+
+import requests
+
+from shared.database import get_redis
+
+logger = logging.getLogger(__name__)
+
+DEFAULT_URL = 'http://localhost:8010'
+MAX_TEXT_LENGTH = 100000
+
+# Tags that separate blocks of text, so words on either side aren't read as one sentence
+BLOCK_TAGS = re.compile(r'^</?(p|div|h[1-6]|li|ul|ol|blockquote|pre|br|hr|tr|td|th|table|section|article)\b', re.I)
+
+
+class LanguageCheckUnavailable(Exception):
+    """Raised when the checking server can't be reached or rejects the request"""
+
+
+class ProofreadLimitExceeded(Exception):
+    """Raised when a user proofreads more often than the rate limit allows"""
+
+
+def check_rate_limit(user_id: str):
+    """Allow PROOFREAD_RATE_LIMIT_PER_HOUR checks per user; fails open if Redis is down"""
+    limit = int(os.getenv('PROOFREAD_RATE_LIMIT_PER_HOUR', 30))
+    key = f"proofread:rate:{user_id}:{datetime.now().strftime('%Y%m%d%H')}"
+
+    try:
+        redis_client = get_redis()
+        count = redis_client.incr(key)
+        if count == 1:
+            redis_client.expire(key, 3600)
+    except Exception as e:
+        logger.warning(f"Proofread rate limit check skipped: {e}")
+        return
+
+    if count > limit:
+        raise ProofreadLimitExceeded(f"Proofreading is limited to {limit} checks per hour")
+
+
+def annotate(html: str) -> Dict[str, List[Dict[str, str]]]:
+    """LanguageTool annotated data for HTML: tags as markup, entities and text as text"""
+    annotation = []
+    for part in re.split(r'(<[^>]*>)', html):
+        if not part:
+            continue
+        if part.startswith('<'):
+            item = {'markup': part}
+            if BLOCK_TAGS.match(part):
+                item['interpretAs'] = '\n\n'
+            annotation.append(item)
+        else:
+            annotation.append({'text': part})
+    return {'annotation': annotation}
+
+
+def _issue(match: Dict[str, Any]) -> Dict[str, Any]:
+    rule = match.get('rule') or {}
+    return {
+        'offset': match['offset'],
+        'length': match['length'],
+        'message': match.get('message'),
+        'short_message': match.get('shortMessage') or None,
+        'replacements': [r['value'] for r in match.get('replacements', [])[:5]],
+        'rule_id': rule.get('id'),
+        'category': (rule.get('category') or {}).get('id'),
+        'issue_type': rule.get('issueType'),
+        'sentence': match.get('sentence'),
+    }
+
+
+def check_text(text: str, language: Optional[str] = None, html: bool = False) -> List[Dict[str, Any]]:
+    """Issues found in `text`, each with its offset and length in `text` and suggested replacements"""
+    if not text or not text.strip():
+        return []
+    if len(text) > MAX_TEXT_LENGTH:
+        raise LanguageCheckUnavailable(f"Text longer than {MAX_TEXT_LENGTH} characters can't be checked")
+
+    form = {'language': language or 'auto'}
+    if html:
+        form['data'] = json.dumps(annotate(text))
+    else:
+        form['text'] = text
+    if os.getenv('LANGUAGETOOL_API_KEY'):
+        form['username'] = os.getenv('LANGUAGETOOL_USERNAME', '')
+        form['apiKey'] = os.getenv('LANGUAGETOOL_API_KEY')
+
+    url = os.getenv('LANGUAGETOOL_URL', DEFAULT_URL).rstrip('/') + '/v2/check'
+    try:
+        response = requests.post(url, data=form, timeout=float(os.getenv('LANGUAGETOOL_TIMEOUT_SECONDS', 15)))
+        response.raise_for_status()
+        matches = response.json().get('matches', [])
+    except (requests.RequestException, ValueError) as e:
+        logger.warning(f"Language check failed: {e}")
+        raise LanguageCheckUnavailable("The language checking service is unavailable")
+    return [_issue(match) for match in matches]
+
+
+def apply_fixes(text: str, issues: Iterable[Dict[str, Any]],
+                rule_ids: Optional[Iterable[str]] = None) -> Tuple[str, int]:
+    """Apply the first replacement of each issue (of `rule_ids`, if given), skipping overlapping ones
+
+    Returns the fixed text and the number of fixes applied.
+    """
+    rule_ids = set(rule_ids) if rule_ids else None
+    applicable = sorted(
+        (issue for issue in issues
+         if issue['replacements'] and (rule_ids is None or issue['rule_id'] in rule_ids)),
+        key=lambda issue: issue['offset']
+    )
+
+    chosen = []
+    end = -1
+    for issue in applicable:
+        if issue['offset'] >= end:
+            chosen.append(issue)
+            end = issue['offset'] + issue['length']
+
+    # Right to left so earlier offsets stay valid
+    for issue in reversed(chosen):
+        start = issue['offset']
+        text = text[:start] + issue['replacements'][0] + text[start + issue['length']:]
+    return text, len(chosen)
@@ -0,0 +1,125 @@
+"""
+Double-entry payments ledger
+
+Money the platform handles is tracked in accounts per kind, currency and,
+for author accounts, user. Every event that moves money posts one
+transaction whose entries sum to zero (the database refuses to commit one
+that doesn't), so a balance is always the sum of an account's entries and
+the ledger as a whole always nets out per currency. Amounts are integers in
+the currency's smallest unit; a positive entry credits the account.
+
+Transactions are keyed by the event they record, so posting the same event
+twice, as a retried webhook would, does nothing the second time.
+"""
+
+import logging
+from typing import Any, Dict, List, Optional, Tuple
+
+logger = logging.getLogger(__name__)
+
+AUTHOR_AVAILABLE = 'author_available'
+AUTHOR_PAYOUTS_PENDING = 'author_payouts_pending'
+AUTHOR_RECEIVED_DIRECT = 'author_received_direct'
+PAYOUTS_SENT = 'payouts_sent'
+PLATFORM_FEES = 'platform_fees'
+STRIPE_CLEARING = 'stripe_clearing'
+ONCHAIN_CLEARING = 'onchain_clearing'
+
+AUTHOR_KINDS = (AUTHOR_AVAILABLE, AUTHOR_PAYOUTS_PENDING, AUTHOR_RECEIVED_DIRECT, PAYOUTS_SENT)
+
+
+def account(cursor, kind: str, currency: str, user_id: Optional[str] = None) -> str:
+    """The id of an account, opening it on first use"""
+    currency = currency.lower()
+    code = f"{kind}:{user_id}:{currency}" if user_id else f"{kind}:{currency}"
+    cursor.execute("""
+        INSERT INTO ledger_accounts (code, kind, user_id, currency)
+        VALUES (%s, %s, %s, %s)
+        ON CONFLICT (code) DO NOTHING
+    """, (code, kind, user_id, currency))
+    cursor.execute("SELECT id FROM ledger_accounts WHERE code = %s", (code,))
+    return str(cursor.fetchone()['id'])
+
+
+def post(cursor, kind: str, reference_id: str, entries: List[Tuple[str, int]],
+         description: Optional[str] = None) -> Optional[str]:
+    """Post a transaction of (account id, amount) entries, or return None if this event was already posted"""
+    entries = [(account_id, amount) for account_id, amount in entries if amount]
+    if sum(amount for _, amount in entries) != 0:
+        raise ValueError(f"Unbalanced {kind} transaction for {reference_id}")
+
+    cursor.execute("""
+        INSERT INTO ledger_transactions (kind, reference_id, description)
+        VALUES (%s, %s, %s)
+        ON CONFLICT (kind, reference_id) DO NOTHING
+        RETURNING id
+    """, (kind, reference_id, description))
+    row = cursor.fetchone()
+    if not row:
+        logger.info(f"Ledger {kind} transaction for {reference_id} already posted")
+        return None
+
+    for account_id, amount in entries:
+        cursor.execute(
+            "INSERT INTO ledger_entries (transaction_id, account_id, amount) VALUES (%s, %s, %s)",
+            (row['id'], account_id, amount)
+        )
+    return str(row['id'])
+
+
+def reverse(cursor, kind: str, original_kind: str, reference_id: str,
+            description: Optional[str] = None) -> Optional[str]:
+    """Post the mirror image of an earlier transaction, e.g. to refund it"""
+    cursor.execute("""
+        SELECT e.account_id, e.amount
+        FROM ledger_entries e
+        JOIN ledger_transactions t ON t.id = e.transaction_id
+        WHERE t.kind = %s AND t.reference_id = %s
+    """, (original_kind, reference_id))
+    entries = [(str(row['account_id']), -int(row['amount'])) for row in cursor.fetchall()]
+    if not entries:
+        return None
+    return post(cursor, kind, reference_id, entries, description)
+
+
+def balance(cursor, account_id: str, lock: bool = False) -> int:
+    """An account's balance; lock it first when the balance decides whether money may leave it"""
+    if lock:
+        cursor.execute("SELECT id FROM ledger_accounts WHERE id = %s FOR UPDATE", (account_id,))
+    cursor.execute("SELECT COALESCE(SUM(amount), 0) AS balance FROM ledger_entries WHERE account_id = %s", (account_id,))
+    return int(cursor.fetchone()['balance'])
+
+
+def user_balances(cursor, user_id: str) -> Dict[str, Dict[str, int]]:
+    """A user's balance in every account they have, by currency then account kind"""
+    cursor.execute("""
+        SELECT a.currency, a.kind, COALESCE(SUM(e.amount), 0) AS balance
+        FROM ledger_accounts a
+        LEFT JOIN ledger_entries e ON e.account_id = a.id
+        WHERE a.user_id = %s
+        GROUP BY a.currency, a.kind
+    """, (user_id,))
+    balances: Dict[str, Dict[str, int]] = {}
+    for row in cursor.fetchall():
+        balances.setdefault(row['currency'], {kind: 0 for kind in AUTHOR_KINDS})[row['kind']] = int(row['balance'])
+    return balances
+
+
+def trial_balance(cursor) -> List[Dict[str, Any]]:
+    """Totals per currency and account kind; each currency's kinds must sum to zero"""
+    cursor.execute("""
+        SELECT a.currency, a.kind, COALESCE(SUM(e.amount), 0) AS balance
+        FROM ledger_accounts a
+        LEFT JOIN ledger_entries e ON e.account_id = a.id
+        GROUP BY a.currency, a.kind
+        ORDER BY a.currency, a.kind
+    """)
+    currencies: Dict[str, Dict[str, Any]] = {}
+    for row in cursor.fetchall():
+        entry = currencies.setdefault(row['currency'], {'currency': row['currency'], 'accounts': {}, 'net': 0})
+        entry['accounts'][row['kind']] = str(row['balance'])
+        entry['net'] += int(row['balance'])
+    for entry in currencies.values():
+        entry['balanced'] = entry['net'] == 0
+        entry['net'] = str(entry['net'])
+    return list(currencies.values())
@@ -0,0 +1,86 @@
+"""
+Article license catalog shared by both Flask and FastAPI backends
+
+Every license an author can choose is described once here so API filters,
+syndication feeds and exports agree on what each license permits.
+"""
+
+from typing import Any, Dict, List
+
+from shared.models import ArticleLicense
+
+
+LICENSES: Dict[str, Dict[str, Any]] = {
+    ArticleLicense.CC0.value: {
+        'name': 'CC0 1.0 Universal (Public Domain Dedication)',
+        'url': 'https://creativecommons.org/publicdomain/zero/1.0/',
+        'reusable': True, 'commercial': True, 'derivatives': True, 'share_alike': False,
+    },
+    ArticleLicense.CC_BY.value: {
+        'name': 'Creative Commons Attribution 4.0',
+        'url': 'https://creativecommons.org/licenses/by/4.0/',
+        'reusable': True, 'commercial': True, 'derivatives': True, 'share_alike': False,
+    },
+    ArticleLicense.CC_BY_SA.value: {
+        'name': 'Creative Commons Attribution-ShareAlike 4.0',
+        'url': 'https://creativecommons.org/licenses/by-sa/4.0/',
+        'reusable': True, 'commercial': True, 'derivatives': True, 'share_alike': True,
+    },
+    ArticleLicense.CC_BY_ND.value: {
+        'name': 'Creative Commons Attribution-NoDerivatives 4.0',
+        'url': 'https://creativecommons.org/licenses/by-nd/4.0/',
+        'reusable': True, 'commercial': True, 'derivatives': False, 'share_alike': False,
+    },
+    ArticleLicense.CC_BY_NC.value: {
+        'name': 'Creative Commons Attribution-NonCommercial 4.0',
+        'url': 'https://creativecommons.org/licenses/by-nc/4.0/',
+        'reusable': True, 'commercial': False, 'derivatives': True, 'share_alike': False,
+    },
+    ArticleLicense.CC_BY_NC_SA.value: {
+        'name': 'Creative Commons Attribution-NonCommercial-ShareAlike 4.0',
+        'url': 'https://creativecommons.org/licenses/by-nc-sa/4.0/',
+        'reusable': True, 'commercial': False, 'derivatives': True, 'share_alike': True,
+    },
+    ArticleLicense.CC_BY_NC_ND.value: {
+        'name': 'Creative Commons Attribution-NonCommercial-NoDerivatives 4.0',
+        'url': 'https://creativecommons.org/licenses/by-nc-nd/4.0/',
+        'reusable': True, 'commercial': False, 'derivatives': False, 'share_alike': False,
+    },
+    ArticleLicense.ALL_RIGHTS_RESERVED.value: {
+        'name': 'All rights reserved',
+        'url': None,
+        'reusable': False, 'commercial': False, 'derivatives': False, 'share_alike': False,
+    },
+    ArticleLicense.CUSTOM.value: {
+        'name': 'Custom terms',
+        'url': None,
+        'reusable': False, 'commercial': False, 'derivatives': False, 'share_alike': False,
+    },
+}
+
+
+def reusable_licenses(commercial: bool = False) -> List[str]:
+    """Licenses that allow republishing, optionally restricted to commercial reuse"""
+    return [
+        key for key, info in LICENSES.items()
+        if info['reusable'] and (info['commercial'] or not commercial)
+    ]
+
+
+def license_info(article: Dict[str, Any]) -> Dict[str, Any]:
+    """License block for an article, as embedded in feeds and exports"""
+    key = article.get('license') or ArticleLicense.ALL_RIGHTS_RESERVED.value
+    if isinstance(key, ArticleLicense):
+        key = key.value
+    info = LICENSES.get(key, LICENSES[ArticleLicense.ALL_RIGHTS_RESERVED.value])
+
+    return {
+        'id': key,
+        'name': info['name'],
+        'url': info['url'],
+        'terms': article.get('license_terms') if key == ArticleLicense.CUSTOM.value else None,
+        'reusable': info['reusable'],
+        'commercial': info['commercial'],
+        'derivatives': info['derivatives'],
+        'share_alike': info['share_alike'],
+    }
@@ -0,0 +1,275 @@
+"""
+Link previews
+
+When an article is published, or the content of a published article
+changes, the `fetch_link_previews` job fetches its source URL and up to
+LINK_PREVIEW_MAX_LINKS links from its content, extracts their Open Graph
+title, description, image and site name (falling back to Twitter card tags
+and the page <title>), and stores them on the article as `link_previews`,
+so clients can render rich links without scraping pages themselves.
+
+Every URL is fetched at most once per LINK_PREVIEW_TTL_HOURS; results,
+including failures, are cached in `link_previews`. Fetching arbitrary URLs
+from the server is an SSRF risk, so only http(s) on ports 80 and 443 is
+followed, every host (including each redirect hop) must resolve to public
+addresses only, the address actually connected to is checked again against
+DNS rebinding, and only the first LINK_PREVIEW_MAX_BYTES of HTML pages are
+read.
+"""
+
+import os
+import re
+import socket
+import ipaddress
+import logging
+from html import unescape
+from html.parser import HTMLParser
+from typing import Any, Dict, List, Optional
+from urllib.parse import urljoin, urlparse
+
+import requests
+
+from shared.database import get_postgres_cursor, prepare_json_data
+from shared.feed_formats import PUBLIC_BASE_URL
+
+logger = logging.getLogger(__name__)
+
+ENABLED = os.getenv('LINK_PREVIEWS_ENABLED', 'true').lower() == 'true'
+MAX_LINKS = int(os.getenv('LINK_PREVIEW_MAX_LINKS', 5))
+MAX_BYTES = int(os.getenv('LINK_PREVIEW_MAX_BYTES', 1024 * 1024))
+MAX_REDIRECTS = 3
+REQUEST_TIMEOUT_SECONDS = int(os.getenv('LINK_PREVIEW_TIMEOUT_SECONDS', 5))
+TTL_HOURS = int(os.getenv('LINK_PREVIEW_TTL_HOURS', 7 * 24))
+FAILURE_TTL_HOURS = int(os.getenv('LINK_PREVIEW_FAILURE_TTL_HOURS', 24))
+USER_AGENT = os.getenv('LINK_PREVIEW_USER_AGENT', 'DecentralizedNewsLinkPreview/1.0')
+ALLOWED_PORTS = {80, 443}
+MAX_URL_LENGTH = 2000
+
+HREF = re.compile(r'''<a\s[^>]*?href\s*=\s*["']([^"']+)["']''', re.IGNORECASE)
+BARE_URL = re.compile(r'''(?<![="'])\bhttps?://[^\s<>"'()\[\]]+''', re.IGNORECASE)
+
+
+class BlockedURL(Exception):
+    """A URL the SSRF checks refuse to fetch"""
+    pass
+
+
+def _public(address: str) -> bool:
+    ip = ipaddress.ip_address(address.split('%')[0])
+    if ip.version == 6 and ip.ipv4_mapped:
+        ip = ip.ipv4_mapped
+    return ip.is_global and not ip.is_multicast
+
+
+def check_url(url: str):
+    """Raise BlockedURL unless `url` is http(s) on a standard port of a host with only public addresses"""
+    parsed = urlparse(url)
+    if parsed.scheme not in ('http', 'https') or not parsed.hostname:
+        raise BlockedURL("Only http and https URLs are fetched")
+    if parsed.username or parsed.password:
+        raise BlockedURL("URLs with credentials are not fetched")
+    try:
+        port = parsed.port or (443 if parsed.scheme == 'https' else 80)
+    except ValueError:
+        raise BlockedURL("Invalid port")
+    if port not in ALLOWED_PORTS:
+        raise BlockedURL(f"Port {port} is not fetched")
+    try:
+        addresses = {info[4][0] for info in socket.getaddrinfo(parsed.hostname, port, proto=socket.IPPROTO_TCP)}
+    except (socket.gaierror, UnicodeError):
+        raise BlockedURL(f"{parsed.hostname} does not resolve")
+    if not addresses or not all(_public(address) for address in addresses):
+        raise BlockedURL(f"{parsed.hostname} resolves to a non-public address")
+
+
+def _peer_address(response: requests.Response) -> Optional[str]:
+    connection = getattr(response.raw, 'connection', None) or getattr(response.raw, '_connection', None)
+    sock = getattr(connection, 'sock', None)
+    try:
+        return sock.getpeername()[0] if sock else None
+    except OSError:
+        return None
+
+
+class _MetaParser(HTMLParser):
+    def __init__(self):
+        super().__init__(convert_charrefs=True)
+        self.meta: Dict[str, str] = {}
+        self.title = ''
+        self._in_title = False
+
+    def handle_starttag(self, tag, attrs):
+        if tag == 'meta':
+            attributes = dict(attrs)
+            key = (attributes.get('property') or attributes.get('name') or '').strip().lower()
+            content = (attributes.get('content') or '').strip()
+            if key and content:
+                self.meta.setdefault(key, content)
+        elif tag == 'title':
+            self._in_title = True
+
+    def handle_endtag(self, tag):
+        if tag == 'title':
+            self._in_title = False
+
+    def handle_data(self, data):
+        if self._in_title:
+            self.title += data
+
+
+def _first(values: Dict[str, str], *keys: str) -> Optional[str]:
+    for key in keys:
+        if values.get(key):
+            return values[key]
+    return None
+
+
+def _clip(value: Optional[str], length: int) -> Optional[str]:
+    value = ' '.join(value.split()) if value else None
+    return value[:length] if value else None
+
+
+def parse(html: str, page_url: str) -> Dict[str, Optional[str]]:
+    """Open Graph metadata of a page, with Twitter card and plain HTML fallbacks"""
+    parser = _MetaParser()
+    parser.feed(html)
+    meta = parser.meta
+
+    image_url = _first(meta, 'og:image:secure_url', 'og:image', 'og:image:url', 'twitter:image', 'twitter:image:src')
+    if image_url:
+        image_url = urljoin(page_url, image_url)
+        if urlparse(image_url).scheme not in ('http', 'https') or len(image_url) > MAX_URL_LENGTH:
+            image_url = None
+    return {
+        'title': _clip(_first(meta, 'og:title', 'twitter:title') or parser.title, 500),
+        'description': _clip(_first(meta, 'og:description', 'twitter:description', 'description'), 1000),
+        'image_url': image_url,
+        'site_name': _clip(_first(meta, 'og:site_name') or urlparse(page_url).hostname, 200),
+    }
+
+
+def fetch(url: str) -> Dict[str, Optional[str]]:
+    """Fetch a page and extract its preview, following redirects only to URLs that pass the SSRF checks"""
+    current = url
+    for _ in range(MAX_REDIRECTS + 1):
+        check_url(current)
+        response = requests.get(
+            current,
+            headers={'User-Agent': USER_AGENT, 'Accept': 'text/html,application/xhtml+xml;q=0.9'},
+            timeout=REQUEST_TIMEOUT_SECONDS, stream=True, allow_redirects=False,
+        )
+        try:
+            # The host may resolve differently now than when it was checked
+            peer = _peer_address(response)
+            if peer and not _public(peer):
+                raise BlockedURL(f"Connected to non-public address {peer}")
+            if response.is_redirect:
+                current = urljoin(current, response.headers['Location'])
+                continue
+            response.raise_for_status()
+            content_type = response.headers.get('Content-Type', '').lower()
+            if 'html' not in content_type:
+                raise ValueError(f"Not an HTML page: {content_type or 'no content type'}")
+            body = response.raw.read(MAX_BYTES, decode_content=True)
+            html = body.decode(response.encoding or 'utf-8', errors='replace')
+        finally:
+            response.close()
+        return {**parse(html, current), 'final_url': current[:MAX_URL_LENGTH]}
+    raise ValueError(f"More than {MAX_REDIRECTS} redirects")
+
+
+def get_preview(url: str) -> Dict[str, Any]:
+    """The cached preview of `url`, fetching it when there is none or it expired"""
+    with get_postgres_cursor() as cursor:
+        cursor.execute("SELECT * FROM link_previews WHERE url = %s AND expires_at > NOW()", (url,))
+        cached = cursor.fetchone()
+    if cached:
+        return dict(cached)
+
+    try:
+        preview = {'status': 'ok', 'error': None, **fetch(url)}
+    except BlockedURL as e:
+        preview = {'status': 'blocked', 'error': str(e)}
+    except (requests.RequestException, ValueError, UnicodeError, LookupError) as e:
+        preview = {'status': 'failed', 'error': str(e)[:1000]}
+    if preview['status'] != 'ok':
+        logger.info(f"No link preview for {url}: {preview['error']}")
+
+    ttl_hours = TTL_HOURS if preview['status'] == 'ok' else FAILURE_TTL_HOURS
+    with get_postgres_cursor() as cursor:
+        cursor.execute("""
+            INSERT INTO link_previews (url, status, final_url, title, description, image_url, site_name, error,
+                                       fetched_at, expires_at)
+            VALUES (%s, %s, %s, %s, %s, %s, %s, %s, NOW(), NOW() + make_interval(hours => %s))
+            ON CONFLICT (url) DO UPDATE SET
+                status = EXCLUDED.status, final_url = EXCLUDED.final_url, title = EXCLUDED.title,
+                description = EXCLUDED.description, image_url = EXCLUDED.image_url,
+                site_name = EXCLUDED.site_name, error = EXCLUDED.error,
+                fetched_at = EXCLUDED.fetched_at, expires_at = EXCLUDED.expires_at
+            RETURNING *
+        """, (
+            url, preview['status'], preview.get('final_url'), preview.get('title'), preview.get('description'),
+            preview.get('image_url'), preview.get('site_name'), preview['error'], ttl_hours,
+        ))
+        return dict(cursor.fetchone())
+
+
+def article_links(article: Dict[str, Any]) -> List[str]:
+    """URLs to preview for an article: its source URL, then links in its content, skipping this site's own"""
+    own_host = urlparse(PUBLIC_BASE_URL).hostname
+    content = article.get('content') or ''
+    candidates = [article.get('source_url')] + HREF.findall(content) + BARE_URL.findall(content)
+
+    links = []
+    for candidate in candidates:
+        if not candidate:
+            continue
+        url = unescape(candidate).strip().rstrip('.,;:!?')
+        parsed = urlparse(url)
+        if parsed.scheme not in ('http', 'https') or not parsed.hostname or parsed.hostname == own_host:
+            continue
+        url = url.split('#')[0]
+        if len(url) <= MAX_URL_LENGTH and url not in links:
+            links.append(url)
+        if len(links) >= MAX_LINKS:
+            break
+    return links
+
+
+def refresh_article(article_id: str) -> int:
+    """Fetch the previews of an article's links and store the usable ones on it"""
+    from shared import article_cache
+
+    with get_postgres_cursor() as cursor:
+        cursor.execute("SELECT id, source_url, content FROM articles WHERE id = %s", (article_id,))
+        article = cursor.fetchone()
+    if not article:
+        return 0
+
+    previews = []
+    for url in article_links(dict(article)):
+        preview = get_preview(url)
+        if preview['status'] == 'ok' and (preview['title'] or preview['description'] or preview['image_url']):
+            previews.append({
+                'url': url,
+                'title': preview['title'],
+                'description': preview['description'],
+                'image_url': preview['image_url'],
+                'site_name': preview['site_name'],
+            })
+
+    with get_postgres_cursor() as cursor:
+        cursor.execute(
+            "UPDATE articles SET link_previews = %s WHERE id = %s", (prepare_json_data(previews), article_id)
+        )
+    article_cache.invalidate(article_id)
+    return len(previews)
+
+
+def enqueue_link_previews(cursor, article: Dict[str, Any]):
+    """Publish hook: fetch previews for the links of a newly published article"""
+    if not ENABLED or not article_links(article):
+        return
+    from shared.jobs import fetch_link_previews
+
+    fetch_link_previews.apply_async(args=[str(article['id'])], countdown=5)
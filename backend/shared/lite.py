@@ -0,0 +1,171 @@
+"""
+Lite responses for low-bandwidth clients
+
+A request is lite when it passes `?lite=true`, or sends `Save-Data: on` or an
+`ECT` (effective connection type) client hint of 2G or 3G, unless it passes
+`?lite=false`. Responses from the endpoints in LITE_ENDPOINTS are then
+shaped after the handler runs: article lists drop their text, metadata and
+all images but the first, home feed shelves are cut to LITE_SHELF_ITEMS, and
+the default page size is lowered for callers that didn't ask for one.
+Article detail isn't shaped: readers need its text, and it is signed.
+
+Shaped endpoints answer with `Vary: Save-Data, ECT` and `Accept-CH` so
+browsers send the hints and caches keep the variants apart.
+"""
+
+import os
+import re
+import json
+import logging
+from contextvars import ContextVar
+from typing import Any, Dict, List, Optional, Pattern, Tuple
+from urllib.parse import parse_qs, urlencode
+
+logger = logging.getLogger(__name__)
+
+LITE_PAGE_SIZE = int(os.getenv('LITE_PAGE_SIZE', 10))
+LITE_SHELF_ITEMS = int(os.getenv('LITE_SHELF_ITEMS', 5))
+SLOW_CONNECTIONS = ('slow-2g', '2g', '3g')
+
+# Fields dropped from every article in a lite response
+HEAVY_FIELDS = frozenset({
+    'content', 'metadata', 'readability', 'seo_keywords', 'access_policy', 'license_terms', 'fact_checks'
+})
+
+# Path pattern -> page size query parameter, or None when the endpoint has none
+LITE_ENDPOINTS: List[Tuple[Pattern, Optional[str]]] = [
+    (re.compile(r'^/api/v1/articles/?$'), 'per_page'),
+    (re.compile(r'^/api/v1/articles/[^/]+/related/?$'), None),
+    (re.compile(r'^/api/v1/feed/?$'), 'limit'),
+    (re.compile(r'^/api/v1/feed/following/?$'), 'limit'),
+    (re.compile(r'^/api/v1/feed/home/?$'), None),
+    (re.compile(r'^/api/v1/users/[^/]+/(articles|bookmarks)/?$'), 'per_page'),
+    (re.compile(r'^/api/v1/collections/[^/]+/?$'), None),
+    # Page size comes in the request body; the route applies LITE_PAGE_SIZE
+    (re.compile(r'^/api/v1/search/?$'), None),
+]
+
+_lite: ContextVar[bool] = ContextVar('lite', default=False)
+
+
+def is_lite() -> bool:
+    """Whether the request being handled asked for a lite response"""
+    return _lite.get()
+
+
+def page_size(requested: int, explicit: bool) -> int:
+    """The page size for a request: lite requests that didn't pick one get LITE_PAGE_SIZE"""
+    return requested if explicit or not is_lite() else min(requested, LITE_PAGE_SIZE)
+
+
+def wants_lite(query: Dict[str, List[str]], headers: Dict[str, str]) -> bool:
+    requested = (query.get('lite') or [''])[-1].lower()
+    if requested in ('1', 'true', 'yes'):
+        return True
+    if requested in ('0', 'false', 'no'):
+        return False
+    return headers.get('save-data', '').lower() == 'on' or headers.get('ect', '').lower() in SLOW_CONNECTIONS
+
+
+def endpoint(path: str) -> Tuple[bool, Optional[str]]:
+    """Whether responses from the path are shaped, and its page size parameter"""
+    for pattern, page_param in LITE_ENDPOINTS:
+        if pattern.match(path):
+            return True, page_param
+    return False, None
+
+
+def _is_article(value: Dict[str, Any]) -> bool:
+    return 'id' in value and 'title' in value and ('content' in value or 'summary' in value)
+
+
+def shape(value: Any) -> Any:
+    """Strip heavy fields from every article in a response body"""
+    if isinstance(value, list):
+        return [shape(item) for item in value]
+    if not isinstance(value, dict):
+        return value
+    if _is_article(value):
+        value = {key: item for key, item in value.items() if key not in HEAVY_FIELDS}
+        if value.get('image_urls'):
+            value['image_urls'] = value['image_urls'][:1]
+    shaped = {key: shape(item) for key, item in value.items()}
+    for shelf in shaped.get('shelves') or []:
+        if isinstance(shelf, dict) and isinstance(shelf.get('items'), list):
+            shelf['items'] = shelf['items'][:LITE_SHELF_ITEMS]
+    return shaped
+
+
+class LiteResponseMiddleware:
+    """ASGI middleware shaping responses for lite requests to the endpoints in LITE_ENDPOINTS"""
+
+    def __init__(self, app):
+        self.app = app
+
+    async def __call__(self, scope, receive, send):
+        shaped, page_param = endpoint(scope['path']) if scope['type'] == 'http' else (False, None)
+        if not shaped:
+            await self.app(scope, receive, send)
+            return
+
+        headers = {name.decode('latin-1').lower(): value.decode('latin-1') for name, value in scope.get('headers') or []}
+        query = parse_qs(scope.get('query_string', b'').decode('latin-1'), keep_blank_values=True)
+        lite = wants_lite(query, headers)
+
+        if lite and page_param and page_param not in query:
+            query[page_param] = [str(LITE_PAGE_SIZE)]
+            scope = {**scope, 'query_string': urlencode(query, doseq=True).encode('latin-1')}
+
+        context_token = _lite.set(lite)
+        try:
+            await self.app(scope, receive, _shaping(send) if lite else _varying(send))
+        finally:
+            _lite.reset(context_token)
+
+
+VARY_HEADERS = [(b'vary', b'Save-Data, ECT'), (b'accept-ch', b'Save-Data, ECT')]
+
+
+def _varying(send):
+    async def add_headers(message):
+        if message['type'] == 'http.response.start':
+            message = {**message, 'headers': list(message.get('headers', [])) + VARY_HEADERS}
+        await send(message)
+    return add_headers
+
+
+def _shaping(send):
+    """Wrap `send` to hold back a JSON response and send the shaped body instead"""
+    response: Dict[str, Any] = {'start': None, 'body': bytearray(), 'shape': False}
+
+    async def shape_response(message):
+        if message['type'] == 'http.response.start':
+            content_type = next(
+                (value for name, value in message.get('headers', []) if name.lower() == b'content-type'), b''
+            )
+            response['shape'] = message['status'] == 200 and content_type.startswith(b'application/json')
+            if not response['shape']:
+                await _varying(send)(message)
+                return
+            response['start'] = message
+            return
+        if message['type'] != 'http.response.body' or not response['shape']:
+            await send(message)
+            return
+
+        response['body'] += message.get('body', b'')
+        if message.get('more_body'):
+            return
+        body = bytes(response['body'])
+        try:
+            body = json.dumps(shape(json.loads(body)), separators=(',', ':')).encode()
+        except ValueError as e:
+            logger.warning(f"Lite response left unshaped: {e}")
+
+        start = response['start']
+        headers = [(name, value) for name, value in start.get('headers', []) if name.lower() != b'content-length']
+        headers += [(b'content-length', str(len(body)).encode()), (b'x-lite', b'1')] + VARY_HEADERS
+        await send({**start, 'headers': headers})
+        await send({'type': 'http.response.body', 'body': body})
+
+    return shape_response
@@ -0,0 +1,109 @@
+"""
+Live reader counts per article
+
+Readers with an article open send heartbeats over a WebSocket, or hold an
+SSE stream open that heartbeats for them. Each heartbeat scores the reader in
+a Redis sorted set per article with the time it arrived; a reader counts as
+reading for LIVE_READERS_WINDOW_SECONDS after their last heartbeat, so
+closed tabs and dropped connections age out on their own. A second sorted set
+tracks which articles had readers recently, for the editor dashboard.
+"""
+
+import os
+import time
+import logging
+from datetime import datetime, timezone
+from typing import Any, Dict, List, Optional
+
+from shared.database import get_redis
+
+logger = logging.getLogger(__name__)
+
+WINDOW_SECONDS = int(os.getenv('LIVE_READERS_WINDOW_SECONDS', 30))
+# Clients heartbeat a few times per window so one lost message doesn't drop them
+HEARTBEAT_SECONDS = max(WINDOW_SECONDS // 3, 1)
+ACTIVE_ARTICLES_KEY = 'live_articles'
+
+
+def _key(article_id: str) -> str:
+    return f"live_readers:{article_id}"
+
+
+def _peak_key(article_id: str) -> str:
+    return f"live_readers_peak:{article_id}"
+
+
+def heartbeat(article_id: str, reader_id: str) -> int:
+    """Mark a reader as reading the article now and return how many are"""
+    now = time.time()
+    key = _key(article_id)
+    pipeline = get_redis().pipeline()
+    pipeline.zadd(key, {reader_id: now})
+    pipeline.zremrangebyscore(key, '-inf', now - WINDOW_SECONDS)
+    pipeline.zcard(key)
+    pipeline.expire(key, WINDOW_SECONDS * 2)
+    pipeline.zadd(ACTIVE_ARTICLES_KEY, {article_id: now})
+    reading_now = pipeline.execute()[2]
+    _record_peak(article_id, reading_now, now)
+    return reading_now
+
+
+def leave(article_id: str, reader_id: str):
+    try:
+        get_redis().zrem(_key(article_id), reader_id)
+    except Exception as e:
+        logger.warning(f"Could not remove live reader {reader_id} on {article_id}: {e}")
+
+
+def _record_peak(article_id: str, reading_now: int, now: float):
+    # Read-then-write; two heartbeats racing can at worst keep the lower of two new peaks
+    redis_client = get_redis()
+    peak = redis_client.hget(_peak_key(article_id), 'count')
+    if peak is None or reading_now > int(peak):
+        redis_client.hset(_peak_key(article_id), mapping={'count': reading_now, 'at': now})
+
+
+def reading_now(article_id: str) -> int:
+    return get_redis().zcount(_key(article_id), time.time() - WINDOW_SECONDS, '+inf')
+
+
+def live_stats(article_id: str) -> Dict[str, Any]:
+    peak = get_redis().hgetall(_peak_key(article_id))
+    return {
+        'article_id': article_id,
+        'reading_now': reading_now(article_id),
+        'peak': int(peak['count']) if peak else 0,
+        'peak_at': datetime.fromtimestamp(float(peak['at']), timezone.utc) if peak else None,
+        'window_seconds': WINDOW_SECONDS,
+    }
+
+
+def top_articles(limit: Optional[int] = 10) -> List[Dict[str, Any]]:
+    """Articles with the most readers right now"""
+    redis_client = get_redis()
+    cutoff = time.time() - WINDOW_SECONDS
+    redis_client.zremrangebyscore(ACTIVE_ARTICLES_KEY, '-inf', cutoff)
+    article_ids = redis_client.zrange(ACTIVE_ARTICLES_KEY, 0, -1)
+
+    pipeline = redis_client.pipeline()
+    for article_id in article_ids:
+        pipeline.zcount(_key(article_id), cutoff, '+inf')
+    counts = pipeline.execute() if article_ids else []
+
+    live = [
+        {'article_id': article_id, 'reading_now': count}
+        for article_id, count in zip(article_ids, counts) if count
+    ]
+    live.sort(key=lambda entry: entry['reading_now'], reverse=True)
+    return live[:limit]
+
+
+def total_reading_now() -> int:
+    return sum(entry['reading_now'] for entry in top_articles(limit=None))
+
+
+def clean_reader_id(value: Optional[str]) -> Optional[str]:
+    """A client-chosen reader id, so tabs sharing one count once, if it is short and plain"""
+    if value and len(value) <= 64 and value.replace('-', '').replace('_', '').isalnum():
+        return value
+    return None
@@ -0,0 +1,115 @@
+"""
+Email providers
+
+Outgoing email goes through the provider named by EMAIL_PROVIDER:
+
+- `smtp`: any SMTP server (SMTP_HOST, SMTP_PORT, SMTP_USER...)
+- `sendgrid`: the SendGrid v3 mail API with SENDGRID_API_KEY
+- `log`: nothing is sent; messages are logged, for development
+
+Callers don't use providers directly; they queue `jobs.send_email`, which
+retries with the job backoff when a provider fails.
+"""
+
+import os
+import smtplib
+import logging
+from email.message import EmailMessage
+from typing import Dict, Optional, Protocol
+
+import requests
+
+logger = logging.getLogger(__name__)
+
+PROVIDER = os.getenv('EMAIL_PROVIDER', 'smtp')
+FROM_ADDRESS = os.getenv('SMTP_FROM', 'no-reply@localhost')
+
+
+# Interface
+class EmailProvider(Protocol):
+    name: str
+
+    def send(self, to: str, subject: str, body: str, html: Optional[str] = None,
+             headers: Optional[Dict[str, str]] = None) -> None:
+        """Send one message; raise when the provider refuses it"""
+        ...
+
+
+# Implementations
+class SMTPProvider:
+    name = 'smtp'
+
+    def send(self, to: str, subject: str, body: str, html: Optional[str] = None,
+             headers: Optional[Dict[str, str]] = None) -> None:
+        message = EmailMessage()
+        message['From'] = FROM_ADDRESS
+        message['To'] = to
+        message['Subject'] = subject
+        for name, value in (headers or {}).items():
+            message[name] = value
+        message.set_content(body)
+        if html:
+            message.add_alternative(html, subtype='html')
+
+        with smtplib.SMTP(os.getenv('SMTP_HOST', 'localhost'), int(os.getenv('SMTP_PORT', 587)), timeout=30) as smtp:
+            if os.getenv('SMTP_USE_TLS', 'true').lower() == 'true':
+                smtp.starttls()
+            if os.getenv('SMTP_USER'):
+                smtp.login(os.getenv('SMTP_USER'), os.getenv('SMTP_PASSWORD', ''))
+            smtp.send_message(message)
+
+
+class SendGridProvider:
+    name = 'sendgrid'
+
+    def __init__(self):
+        self.api_key = os.getenv('SENDGRID_API_KEY', '')
+
+    def send(self, to: str, subject: str, body: str, html: Optional[str] = None,
+             headers: Optional[Dict[str, str]] = None) -> None:
+        if not self.api_key:
+            raise RuntimeError("SENDGRID_API_KEY must be set")
+        content = [{'type': 'text/plain', 'value': body}]
+        if html:
+            content.append({'type': 'text/html', 'value': html})
+        message = {
+            'personalizations': [{'to': [{'email': to}]}],
+            'from': {'email': FROM_ADDRESS},
+            'subject': subject,
+            'content': content,
+        }
+        if headers:
+            message['headers'] = headers
+        response = requests.post(
+            'https://api.sendgrid.com/v3/mail/send',
+            headers={'Authorization': f"Bearer {self.api_key}"},
+            json=message,
+            timeout=30,
+        )
+        response.raise_for_status()
+
+
+class LogProvider:
+    name = 'log'
+
+    def send(self, to: str, subject: str, body: str, html: Optional[str] = None,
+             headers: Optional[Dict[str, str]] = None) -> None:
+        logger.info(f"Email to {to}: {subject}\n{body}")
+
+
+PROVIDERS = {
+    'smtp': SMTPProvider,
+    'sendgrid': SendGridProvider,
+    'log': LogProvider,
+}
+
+_provider: Optional[EmailProvider] = None
+
+
+def provider() -> EmailProvider:
+    global _provider
+    if _provider is None:
+        if PROVIDER not in PROVIDERS:
+            raise ValueError(f"Unknown email provider: {PROVIDER}")
+        _provider = PROVIDERS[PROVIDER]()
+    return _provider
@@ -0,0 +1,32 @@
+"""
+Media store
+
+Files the platform produces itself, such as generated share cards, are
+stored with the media storage driver (STORAGE_MEDIA_DRIVER, local files under
+MEDIA_ROOT by default). Keys are derived from the content's SHA-256, so
+storing the same bytes twice returns the same URL and a stored file never
+changes.
+"""
+
+import os
+import hashlib
+import logging
+import mimetypes
+from typing import Any, Dict
+
+from shared.storage import media_driver
+
+logger = logging.getLogger(__name__)
+
+# Served by FastAPI under /media when the local driver is in use
+MEDIA_ROOT = os.getenv('MEDIA_ROOT', '/app/media')
+
+
+def store(data: bytes, content_type: str, prefix: str) -> Dict[str, Any]:
+    """Write a file under `prefix` and return its key, driver reference and public URL"""
+    extension = mimetypes.guess_extension(content_type) or ''
+    key = f"{prefix.strip('/')}/{hashlib.sha256(data).hexdigest()[:32]}{extension}"
+    driver = media_driver()
+    stored = driver.put(key, data, content_type)
+    logger.info(f"Stored media {key} ({len(data)} bytes) with the {driver.name} driver")
+    return {**stored, 'driver': driver.name, 'content_type': content_type, 'size': len(data)}
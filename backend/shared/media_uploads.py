@@ -0,0 +1,216 @@
+"""
+Image uploads
+
+`POST /api/v1/media` takes JPEG, PNG, GIF and WebP images up to
+MEDIA_MAX_UPLOAD_BYTES. The type is decided by decoding the file, never by
+its name or declared content type, and every image is re-encoded before it
+is stored: metadata (EXIF, GPS, comments) and anything appended to the
+image data are dropped, so what is served is only pixels. Each upload is
+stored as three variants:
+
+    original   - re-encoded in its own format, at most MEDIA_MAX_DIMENSION on the long side
+    webp       - the same image as WebP
+    thumbnail  - WebP within MEDIA_THUMBNAIL_SIZE
+
+Public uploads go to the media driver (STORAGE_MEDIA_DRIVER). Uploads to a
+draft are private: they go to STORAGE_PRIVATE_MEDIA_DRIVER, are only
+reachable through signed, expiring URLs, and are copied to the media driver
+when the draft is published.
+"""
+
+import io
+import os
+import hmac
+import time
+import hashlib
+import logging
+from typing import Any, Dict, Optional, Tuple
+
+from PIL import Image, ImageOps, UnidentifiedImageError
+
+from shared.media import store
+from shared.storage import get_driver
+
+logger = logging.getLogger(__name__)
+
+PUBLIC = 'public'
+PRIVATE = 'private'
+VARIANTS = ('original', 'webp', 'thumbnail')
+
+MAX_UPLOAD_BYTES = int(os.getenv('MEDIA_MAX_UPLOAD_BYTES', 10 * 1024 * 1024))
+MAX_DIMENSION = int(os.getenv('MEDIA_MAX_DIMENSION', 4096))
+# Larger images are refused before they are decoded, against decompression bombs
+MAX_PIXELS = int(os.getenv('MEDIA_MAX_PIXELS', 50_000_000))
+THUMBNAIL_SIZE = int(os.getenv('MEDIA_THUMBNAIL_SIZE', 400))
+WEBP_QUALITY = int(os.getenv('MEDIA_WEBP_QUALITY', 82))
+PRIVATE_DRIVER = os.getenv('STORAGE_PRIVATE_MEDIA_DRIVER', 'local_private')
+SIGNED_URL_TTL_SECONDS = int(os.getenv('MEDIA_SIGNED_URL_TTL_SECONDS', 60 * 60))
+API_BASE_URL = os.getenv('API_BASE_URL', 'http://localhost')
+
+FORMATS = {'JPEG': 'image/jpeg', 'PNG': 'image/png', 'GIF': 'image/gif', 'WEBP': 'image/webp'}
+
+Image.MAX_IMAGE_PIXELS = MAX_PIXELS
+
+
+class InvalidImage(Exception):
+    pass
+
+
+def _encode(image: Image.Image, image_format: str, animated: bool = False) -> bytes:
+    output = io.BytesIO()
+    if image_format == 'JPEG':
+        image.convert('RGB').save(output, 'JPEG', quality=88, optimize=True, progressive=True)
+    elif image_format == 'WEBP':
+        image.save(output, 'WEBP', quality=WEBP_QUALITY, method=4, save_all=animated)
+    elif image_format == 'GIF':
+        image.save(output, 'GIF', save_all=animated, optimize=True)
+    else:
+        image.save(output, 'PNG', optimize=True)
+    return output.getvalue()
+
+
+def _variant(data: bytes, content_type: str, image: Image.Image) -> Tuple[bytes, Dict[str, Any]]:
+    return data, {'content_type': content_type, 'width': image.width, 'height': image.height, 'size': len(data)}
+
+
+def process(data: bytes) -> Dict[str, Any]:
+    """Validate an uploaded image and build its variants: {'format', 'width', 'height', 'variants': {name: (bytes, info)}}"""
+    if len(data) > MAX_UPLOAD_BYTES:
+        raise InvalidImage(f"Images are limited to {MAX_UPLOAD_BYTES // (1024 * 1024)} MB")
+    try:
+        with Image.open(io.BytesIO(data)) as probe:
+            image_format = probe.format
+            if image_format not in FORMATS:
+                raise InvalidImage("Only JPEG, PNG, GIF and WebP images are accepted")
+            if probe.width * probe.height > MAX_PIXELS:
+                raise InvalidImage("Image dimensions are too large")
+            probe.verify()
+        image = Image.open(io.BytesIO(data))
+        image.load()
+    except InvalidImage:
+        raise
+    except (UnidentifiedImageError, Image.DecompressionBombError, OSError, SyntaxError) as e:
+        raise InvalidImage(f"Not a valid image: {e}")
+
+    animated = getattr(image, 'is_animated', False) and image_format in ('GIF', 'WEBP')
+    if not animated:
+        image = ImageOps.exif_transpose(image)
+        if image.mode not in ('RGB', 'RGBA', 'L', 'LA', 'P'):
+            image = image.convert('RGBA' if 'A' in image.getbands() else 'RGB')
+        if max(image.size) > MAX_DIMENSION:
+            image.thumbnail((MAX_DIMENSION, MAX_DIMENSION), Image.LANCZOS)
+    width, height = image.size
+
+    still = image.convert('RGBA' if image.mode in ('RGBA', 'LA', 'P') else 'RGB')
+    thumbnail = still.copy()
+    thumbnail.thumbnail((THUMBNAIL_SIZE, THUMBNAIL_SIZE), Image.LANCZOS)
+
+    return {
+        'format': image_format,
+        'width': width,
+        'height': height,
+        'variants': {
+            'original': _variant(_encode(image, image_format, animated), FORMATS[image_format], image),
+            'webp': _variant(_encode(image if animated else still, 'WEBP', animated), 'image/webp', image),
+            'thumbnail': _variant(_encode(thumbnail, 'WEBP'), 'image/webp', thumbnail),
+        },
+    }
+
+
+def _store_variants(variants: Dict[str, Tuple[bytes, Dict[str, Any]]], visibility: str) -> Tuple[str, Dict[str, Any]]:
+    stored = {}
+    if visibility == PUBLIC:
+        driver_name = None
+        for name, (data, info) in variants.items():
+            result = store(data, info['content_type'], f"uploads/{name}")
+            driver_name = result['driver']
+            stored[name] = {**info, 'ref': result['ref'], 'url': result['url']}
+        return driver_name, stored
+
+    driver = get_driver(PRIVATE_DRIVER)
+    for name, (data, info) in variants.items():
+        key = f"private/{name}/{hashlib.sha256(data).hexdigest()[:32]}"
+        result = driver.put(key, data, info['content_type'])
+        stored[name] = {**info, 'ref': result['ref'], 'url': None}
+    return driver.name, stored
+
+
+def create_upload(cursor, owner_id: str, data: bytes, filename: Optional[str], visibility: str,
+                  article_id: Optional[str] = None, alt_text: Optional[str] = None) -> Dict[str, Any]:
+    """Process, store and record an uploaded image"""
+    processed = process(data)
+    driver_name, variants = _store_variants(processed['variants'], visibility)
+    cursor.execute("""
+        INSERT INTO media_uploads (owner_id, article_id, visibility, driver, filename, content_type,
+                                   width, height, size, content_sha256, alt_text, variants)
+        VALUES (%s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s)
+        RETURNING *
+    """, (
+        owner_id, article_id, visibility, driver_name, (filename or '')[:255] or None,
+        FORMATS[processed['format']], processed['width'], processed['height'], len(data),
+        hashlib.sha256(data).hexdigest(), alt_text, variants,
+    ))
+    return dict(cursor.fetchone())
+
+
+def _secret() -> bytes:
+    return (os.getenv('MEDIA_SIGNING_SECRET') or os.getenv('JWT_SECRET_KEY', 'dev-secret-key')).encode()
+
+
+def _signature(media_id: str, variant: str, expires: int) -> str:
+    return hmac.new(_secret(), f"{media_id}:{variant}:{expires}".encode(), hashlib.sha256).hexdigest()
+
+
+def signed_url(media_id: str, variant: str, ttl_seconds: int = SIGNED_URL_TTL_SECONDS) -> str:
+    expires = int(time.time()) + ttl_seconds
+    return (
+        f"{API_BASE_URL.rstrip('/')}/api/v1/media/{media_id}/{variant}"
+        f"?expires={expires}&signature={_signature(media_id, variant, expires)}"
+    )
+
+
+def verify_signature(media_id: str, variant: str, expires: Optional[int], signature: Optional[str]) -> bool:
+    if not expires or not signature or expires < time.time():
+        return False
+    return hmac.compare_digest(signature, _signature(media_id, variant, expires))
+
+
+def urls(upload: Dict[str, Any]) -> Dict[str, str]:
+    """Where each variant can be fetched: public storage URLs, or signed URLs for private uploads"""
+    if upload['visibility'] == PUBLIC:
+        return {name: variant['url'] for name, variant in upload['variants'].items()}
+    return {name: signed_url(str(upload['id']), name) for name in upload['variants']}
+
+
+def read_variant(upload: Dict[str, Any], variant: str) -> bytes:
+    return get_driver(upload['driver']).get(upload['variants'][variant]['ref'])
+
+
+def publish_upload(cursor, media_id: str) -> bool:
+    """Copy a private upload's variants to public storage; False when it is already public"""
+    cursor.execute("SELECT * FROM media_uploads WHERE id = %s FOR UPDATE", (media_id,))
+    upload = cursor.fetchone()
+    if not upload or upload['visibility'] == PUBLIC:
+        return False
+    upload = dict(upload)
+    variants = {
+        name: (read_variant(upload, name), {key: value for key, value in info.items() if key not in ('ref', 'url')})
+        for name, info in upload['variants'].items()
+    }
+    driver_name, stored = _store_variants(variants, PUBLIC)
+    cursor.execute("""
+        UPDATE media_uploads SET visibility = %s, driver = %s, variants = %s, published_at = NOW() WHERE id = %s
+    """, (PUBLIC, driver_name, stored, media_id))
+    logger.info(f"Published media upload {media_id} to the {driver_name} driver")
+    return True
+
+
+def publish_article_media(cursor, article: Dict[str, Any]):
+    """Publish hook: make the article's private uploads public"""
+    from shared.jobs import publish_media
+
+    cursor.execute(
+        "SELECT id FROM media_uploads WHERE article_id = %s AND visibility = %s", (article['id'], PRIVATE)
+    )
+    for row in cursor.fetchall():
+        publish_media.apply_async(args=[str(row['id'])], countdown=5)
@@ -0,0 +1,285 @@
+"""
+Publication members: editors, contributors and invitations
+
+Independent newsrooms run as publications on one deployment
+(shared/tenancy.py). Within its publication a user can be an `editor` or a
+`contributor` (`users.publication_role`), which gives them the permissions
+in MEMBER_ROLE_PERMISSIONS (shared/permissions.py) on top of their role:
+contributors publish their own articles, editors also edit and moderate
+everyone's, read the publication's analytics and manage its members. Since
+connections are held to their publication, an editor's reach ends at its
+edge.
+
+Members join by invitation. Someone holding `members:manage` invites an
+email address with a member role; the invitee gets a link with a single-use
+token, valid for PUBLICATION_INVITATION_DAYS, and accepts it signed in to
+the publication with an account on that address. Only the token's hash is
+stored. Inviting an address again replaces its pending invitation.
+
+Members are found and changed through the user repository, and invitations
+carry the publication's `tenant_id` under row-level security, so neither
+can reach another publication's users. Membership changes go to the admin
+audit log and make cached permissions stale.
+"""
+
+import os
+import hashlib
+import logging
+import secrets
+from datetime import datetime, timedelta, timezone
+from typing import Any, Dict, List, Optional, Tuple
+
+from shared.audit import record_privileged
+from shared.repositories import Repositories, current_scope
+from shared import permissions, tenancy
+
+logger = logging.getLogger(__name__)
+
+MEMBER_ROLES = tuple(permissions.MEMBER_ROLE_PERMISSIONS)
+INVITATION_DAYS = int(os.getenv('PUBLICATION_INVITATION_DAYS', 7))
+
+INVITATION_COLUMNS = """
+    id, tenant_id, email, role, invited_by, expires_at, accepted_by, accepted_at, revoked_at, created_at
+"""
+
+
+class MemberError(Exception):
+    pass
+
+
+def _hash(token: str) -> str:
+    return hashlib.sha256(token.encode()).hexdigest()
+
+
+def in_publication(column: str = 'tenant_id') -> Tuple[str, List[Any]]:
+    """The current publication's rows, as the repositories scope them; every row when tenancy is off"""
+    scope = current_scope()
+    return scope.condition(column) if scope else ("TRUE", [])
+
+
+def site_url() -> str:
+    """Where the current publication is read: its first domain, or PUBLIC_BASE_URL"""
+    tenant = tenancy.current()
+    if tenant and tenant.get('domains'):
+        return f"https://{tenant['domains'][0]}"
+    return os.getenv('PUBLIC_BASE_URL', 'http://localhost:3000').rstrip('/')
+
+
+# Members
+def set_member_role(repos: Repositories, user_id: str, role: Optional[str],
+                    actor: Optional[Dict[str, Any]]) -> Optional[Dict[str, Any]]:
+    """Make a user of the publication an editor or contributor, or no longer a member (None)
+
+    None when the publication has no such user.
+    """
+    if role is not None and role not in MEMBER_ROLES:
+        raise MemberError(f"Unknown member role: {role}")
+    user = repos.users.get_by_id(user_id)
+    if not user:
+        return None
+    if user.get('publication_role') == role:
+        return user
+    updated = repos.users.update(user_id, {'publication_role': role})
+    record_privileged(
+        repos.cursor, actor, 'member_role_changed', 'user', user_id,
+        before={'publication_role': user.get('publication_role')}, after={'publication_role': role}
+    )
+    permissions.invalidate()
+    return updated
+
+
+# Invitations
+def invite(cursor, email: str, role: str, actor: Dict[str, Any]) -> Tuple[Dict[str, Any], str]:
+    """Invite an address into the publication with a member role, returning the invitation and its token"""
+    if role not in MEMBER_ROLES:
+        raise MemberError(f"Unknown member role: {role}")
+    email = email.strip().lower()
+    condition, params = in_publication()
+    cursor.execute(
+        f"SELECT publication_role FROM users WHERE lower(email) = %s AND deleted_at IS NULL AND {condition}",
+        [email] + params
+    )
+    existing = cursor.fetchone()
+    if existing and existing['publication_role'] == role:
+        raise MemberError(f"That account is already a {role} here")
+    if not existing:
+        # Emails are unique across publications, and a user can't move to another one
+        cursor.execute("SELECT user_identity_taken(%s, '') AS taken", (email,))
+        if cursor.fetchone()['taken']:
+            raise MemberError("That email belongs to an account on another publication")
+
+    cursor.execute(f"""
+        UPDATE publication_invitations SET revoked_at = NOW()
+        WHERE lower(email) = %s AND accepted_at IS NULL AND revoked_at IS NULL AND {condition}
+    """, [email] + params)
+    token = secrets.token_urlsafe(32)
+    cursor.execute(f"""
+        INSERT INTO publication_invitations (email, role, token_hash, invited_by, expires_at)
+        VALUES (%s, %s, %s, %s, %s)
+        RETURNING {INVITATION_COLUMNS}
+    """, (email, role, _hash(token), actor['id'], datetime.now(timezone.utc) + timedelta(days=INVITATION_DAYS)))
+    invitation = dict(cursor.fetchone())
+    record_privileged(cursor, actor, 'member_invited', 'publication_invitation', invitation['id'], after=invitation)
+    return invitation, token
+
+
+def send_invitation(invitation: Dict[str, Any], token: str, inviter: Dict[str, Any]):
+    from shared.jobs import send_email
+
+    tenant = tenancy.current()
+    publication = tenant['name'] if tenant else os.getenv('PUBLIC_SITE_NAME', 'Decentralized News')
+    link = f"{site_url()}/invitations/accept?token={token}"
+    send_email.delay(
+        invitation['email'],
+        f"Join {publication} as {invitation['role']}",
+        f"{inviter['username']} invited you to join {publication} as {invitation['role']}.\n\n"
+        f"Sign in or create an account with this address, then accept the invitation:\n{link}\n\n"
+        f"The invitation expires on {invitation['expires_at']:%Y-%m-%d}.",
+    )
+
+
+def list_invitations(cursor, pending_only: bool = True) -> List[Dict[str, Any]]:
+    condition, params = in_publication()
+    if pending_only:
+        condition += " AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > NOW()"
+    cursor.execute(
+        f"SELECT {INVITATION_COLUMNS} FROM publication_invitations WHERE {condition} ORDER BY created_at DESC", params
+    )
+    return [dict(row) for row in cursor.fetchall()]
+
+
+def revoke_invitation(cursor, invitation_id: str, actor: Dict[str, Any]) -> Optional[Dict[str, Any]]:
+    """Withdraw a pending invitation; None when the publication has no pending invitation by that id"""
+    condition, params = in_publication()
+    cursor.execute(f"""
+        UPDATE publication_invitations SET revoked_at = NOW()
+        WHERE id = %s AND accepted_at IS NULL AND revoked_at IS NULL AND {condition}
+        RETURNING {INVITATION_COLUMNS}
+    """, [invitation_id] + params)
+    row = cursor.fetchone()
+    if not row:
+        return None
+    record_privileged(
+        cursor, actor, 'member_invitation_revoked', 'publication_invitation', invitation_id,
+        before={'email': row['email'], 'role': row['role']}
+    )
+    return dict(row)
+
+
+def accept(repos: Repositories, token: str, user: Dict[str, Any]) -> Dict[str, Any]:
+    """Make the signed-in user a member with their invitation's role, returning the updated user
+
+    Raises LookupError when the token names no pending invitation here, and
+    MemberError when it was sent to another address.
+    """
+    condition, params = in_publication()
+    repos.cursor.execute(f"""
+        SELECT {INVITATION_COLUMNS} FROM publication_invitations
+        WHERE token_hash = %s AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > NOW() AND {condition}
+        FOR UPDATE
+    """, [_hash(token)] + params)
+    invitation = repos.cursor.fetchone()
+    if not invitation:
+        raise LookupError("Invitation not found or no longer valid")
+    if invitation['email'] != (user.get('email') or '').strip().lower():
+        raise MemberError("The invitation was sent to another email address")
+
+    role = invitation['role']
+    if user.get('publication_role') == 'editor':
+        # Accepting a contributor invitation doesn't demote an editor
+        role = 'editor'
+    updated = set_member_role(repos, str(user['id']), role, user)
+    if not updated:
+        raise LookupError("Invitation not found or no longer valid")
+    repos.cursor.execute(
+        "UPDATE publication_invitations SET accepted_by = %s, accepted_at = NOW() WHERE id = %s",
+        (user['id'], invitation['id'])
+    )
+    logger.info(f"User {user['id']} joined as {role} through invitation {invitation['id']}")
+    return updated
+
+
+# Analytics
+def analytics(cursor, days: int = 30) -> Dict[str, Any]:
+    """The publication's members, articles and readership over the last `days`"""
+    since = datetime.now(timezone.utc) - timedelta(days=days)
+    users, user_params = in_publication('u.tenant_id')
+    articles, article_params = in_publication('a.tenant_id')
+    interactions, interaction_params = in_publication('i.tenant_id')
+
+    cursor.execute(f"""
+        SELECT COALESCE(u.publication_role, 'reader') AS member_role, COUNT(*) AS count
+        FROM users u
+        WHERE u.is_active = true AND u.deleted_at IS NULL AND {users}
+        GROUP BY 1
+    """, user_params)
+    members = {row['member_role']: row['count'] for row in cursor.fetchall()}
+
+    cursor.execute(f"""
+        SELECT a.status::text AS status, COUNT(*) AS count,
+               COUNT(*) FILTER (WHERE a.published_at >= %s) AS published_in_period
+        FROM articles a
+        WHERE {articles}
+        GROUP BY a.status
+    """, [since] + article_params)
+    status_rows = cursor.fetchall()
+
+    cursor.execute(f"""
+        SELECT i.interaction_type::text AS interaction_type, COUNT(*) AS count
+        FROM user_interactions i
+        WHERE i.created_at >= %s AND {interactions}
+        GROUP BY i.interaction_type
+    """, [since] + interaction_params)
+    interaction_counts = {row['interaction_type']: row['count'] for row in cursor.fetchall()}
+
+    cursor.execute(f"""
+        SELECT COUNT(DISTINCT i.user_id) AS readers FROM user_interactions i
+        WHERE i.created_at >= %s AND {interactions}
+    """, [since] + interaction_params)
+    active_readers = cursor.fetchone()['readers']
+
+    cursor.execute(f"""
+        SELECT a.id, a.title, a.author_id, u.username AS author_username,
+               COUNT(*) FILTER (WHERE i.interaction_type = 'view') AS views,
+               COUNT(*) FILTER (WHERE i.interaction_type = 'like') AS likes,
+               COUNT(*) FILTER (WHERE i.interaction_type = 'share') AS shares
+        FROM user_interactions i
+        JOIN articles a ON a.id = i.article_id
+        LEFT JOIN users u ON u.id = a.author_id
+        WHERE i.created_at >= %s AND {interactions} AND {articles}
+        GROUP BY a.id, a.title, a.author_id, u.username
+        ORDER BY views DESC, likes DESC
+        LIMIT 10
+    """, [since] + interaction_params + article_params)
+    top_articles = [dict(row) for row in cursor.fetchall()]
+
+    cursor.execute(f"""
+        SELECT u.id, u.username, u.publication_role,
+               COUNT(DISTINCT a.id) AS articles_published,
+               COALESCE(SUM(a.view_count), 0) AS total_views
+        FROM users u
+        JOIN articles a ON (a.author_id = u.id OR u.id = ANY(a.coauthor_ids))
+            AND a.status = 'published' AND a.published_at >= %s
+        WHERE {users} AND {articles}
+        GROUP BY u.id, u.username, u.publication_role
+        ORDER BY articles_published DESC, total_views DESC
+        LIMIT 10
+    """, [since] + user_params + article_params)
+    top_authors = [dict(row) for row in cursor.fetchall()]
+
+    return {
+        'publication': tenancy.current_id(),
+        'period': {'from': since.isoformat(), 'to': datetime.now(timezone.utc).isoformat(), 'days': days},
+        'members': {
+            'editors': members.get('editor', 0), 'contributors': members.get('contributor', 0),
+            'readers': members.get('reader', 0),
+        },
+        'articles': {
+            'by_status': {row['status']: row['count'] for row in status_rows},
+            'published_in_period': sum(row['published_in_period'] for row in status_rows),
+        },
+        'interactions': interaction_counts,
+        'active_readers': active_readers,
+        'top_articles': top_articles,
+        'top_authors': top_authors,
+    }
@@ -0,0 +1,76 @@
+"""
+Merkle trees over article hashes
+
+Leaves and inner nodes are hashed with different one-byte prefixes, as in
+RFC 6962, so an inner node can never pass as a leaf:
+
+    leaf = sha256(0x00 || article_hash)
+    node = sha256(0x01 || left || right)
+
+A level with an odd number of nodes carries its last node up unchanged
+rather than pairing it with itself. A proof lists the sibling hashes from the
+leaf up to the root, each with the side it sits on.
+"""
+
+import json
+import hashlib
+from typing import Any, Dict, List
+
+LEAF_PREFIX = b'\x00'
+NODE_PREFIX = b'\x01'
+
+
+def article_hash(article: Dict[str, Any]) -> str:
+    """SHA-256 of the article's canonical JSON: id, title, summary and content, sorted keys, no whitespace"""
+    canonical = json.dumps({
+        'id': str(article['id']),
+        'title': article['title'],
+        'summary': article.get('summary') or '',
+        'content': article['content'],
+    }, sort_keys=True, separators=(',', ':'), ensure_ascii=False)
+    return hashlib.sha256(canonical.encode()).hexdigest()
+
+
+def leaf_hash(article_hash_hex: str) -> bytes:
+    return hashlib.sha256(LEAF_PREFIX + bytes.fromhex(article_hash_hex)).digest()
+
+
+def node_hash(left: bytes, right: bytes) -> bytes:
+    return hashlib.sha256(NODE_PREFIX + left + right).digest()
+
+
+def build_tree(article_hashes: List[str]) -> List[List[bytes]]:
+    """Every level of the tree, leaves first and the root last"""
+    if not article_hashes:
+        raise ValueError("A Merkle tree needs at least one leaf")
+    levels = [[leaf_hash(value) for value in article_hashes]]
+    while len(levels[-1]) > 1:
+        level = levels[-1]
+        parents = [node_hash(level[i], level[i + 1]) for i in range(0, len(level) - 1, 2)]
+        if len(level) % 2:
+            parents.append(level[-1])
+        levels.append(parents)
+    return levels
+
+
+def root(levels: List[List[bytes]]) -> str:
+    return levels[-1][0].hex()
+
+
+def proof(levels: List[List[bytes]], index: int) -> List[Dict[str, str]]:
+    """Sibling hashes from leaf `index` up to the root"""
+    path = []
+    for level in levels[:-1]:
+        sibling = index ^ 1
+        if sibling < len(level):
+            path.append({'position': 'left' if sibling < index else 'right', 'hash': level[sibling].hex()})
+        index //= 2
+    return path
+
+
+def verify(article_hash_hex: str, path: List[Dict[str, str]], expected_root: str) -> bool:
+    current = leaf_hash(article_hash_hex)
+    for step in path:
+        sibling = bytes.fromhex(step['hash'])
+        current = node_hash(sibling, current) if step['position'] == 'left' else node_hash(current, sibling)
+    return current.hex() == expected_root
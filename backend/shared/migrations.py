@@ -0,0 +1,238 @@
+"""
+Versioned PostgreSQL schema migrations
+
+Migrations are the numbered files in database/postgresql/schemas
+(`NN_name.sql`); the matching file in schemas/down reverts one. Applied
+versions are recorded in `schema_migrations` together with a checksum of the
+file, so edits to an already-applied migration show up in `status`.
+
+Each migration runs in its own transaction, and the whole run holds a
+PostgreSQL advisory lock so several app instances starting with
+AUTO_MIGRATE enabled never apply the same migration twice.
+"""
+
+import os
+import re
+import hashlib
+import logging
+from dataclasses import dataclass
+from typing import Dict, List, Optional
+
+from psycopg2.extras import RealDictCursor
+
+from shared import tenancy
+from shared.database import get_postgres_connection
+
+logger = logging.getLogger(__name__)
+
+DEFAULT_MIGRATIONS_DIR = os.path.abspath(os.path.join(
+    os.path.dirname(__file__), '..', '..', 'database', 'postgresql', 'schemas'
+))
+MIGRATION_FILE = re.compile(r'^(\d+)_([a-z0-9_]+)\.sql$')
+
+# Arbitrary but fixed key for pg_advisory_lock
+MIGRATION_LOCK_ID = 7274001
+
+
+@dataclass
+class Migration:
+    version: int
+    name: str
+    path: str
+    down_path: Optional[str]
+
+    @property
+    def checksum(self) -> str:
+        with open(self.path, 'rb') as f:
+            return hashlib.sha256(f.read()).hexdigest()
+
+    def read(self, down: bool = False) -> str:
+        with open(self.down_path if down else self.path, encoding='utf-8') as f:
+            return f.read()
+
+
+class MigrationError(Exception):
+    pass
+
+
+class MigrationRunner:
+    """Applies and reverts schema migrations"""
+
+    def __init__(self, migrations_dir: Optional[str] = None):
+        self.migrations_dir = migrations_dir or os.getenv('MIGRATIONS_DIR', DEFAULT_MIGRATIONS_DIR)
+
+    def discover(self) -> List[Migration]:
+        """All migrations on disk, in version order"""
+        if not os.path.isdir(self.migrations_dir):
+            raise MigrationError(f"Migrations directory not found: {self.migrations_dir}")
+
+        migrations = []
+        for filename in os.listdir(self.migrations_dir):
+            match = MIGRATION_FILE.match(filename)
+            if not match:
+                continue
+            down_path = os.path.join(self.migrations_dir, 'down', filename)
+            migrations.append(Migration(
+                version=int(match.group(1)),
+                name=match.group(2),
+                path=os.path.join(self.migrations_dir, filename),
+                down_path=down_path if os.path.exists(down_path) else None
+            ))
+
+        migrations.sort(key=lambda migration: migration.version)
+        versions = [migration.version for migration in migrations]
+        duplicates = sorted({version for version in versions if versions.count(version) > 1})
+        if duplicates:
+            raise MigrationError(f"Duplicate migration versions: {duplicates}")
+        return migrations
+
+    def _ensure_table(self, cursor):
+        cursor.execute("""
+            CREATE TABLE IF NOT EXISTS schema_migrations (
+                version INTEGER PRIMARY KEY,
+                name VARCHAR(255) NOT NULL,
+                checksum VARCHAR(64) NOT NULL,
+                applied_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+            )
+        """)
+
+    def _applied(self, cursor) -> Dict[int, dict]:
+        cursor.execute("SELECT version, name, checksum, applied_at FROM schema_migrations ORDER BY version")
+        return {row['version']: dict(row) for row in cursor.fetchall()}
+
+    def status(self) -> List[dict]:
+        """Every known migration with its state: applied, pending, modified or missing"""
+        with get_postgres_connection() as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cursor:
+                self._ensure_table(cursor)
+                applied = self._applied(cursor)
+            conn.commit()
+
+        result = []
+        on_disk = self.discover()
+        for migration in on_disk:
+            record = applied.get(migration.version)
+            if not record:
+                state = 'pending'
+            elif record['checksum'] != migration.checksum:
+                state = 'modified'
+            else:
+                state = 'applied'
+            result.append({
+                'version': migration.version,
+                'name': migration.name,
+                'state': state,
+                'applied_at': record['applied_at'] if record else None,
+                'reversible': migration.down_path is not None,
+            })
+
+        known = {migration.version for migration in on_disk}
+        for version, record in applied.items():
+            if version not in known:
+                result.append({
+                    'version': version,
+                    'name': record['name'],
+                    'state': 'missing',
+                    'applied_at': record['applied_at'],
+                    'reversible': False,
+                })
+
+        return sorted(result, key=lambda entry: entry['version'])
+
+    def _locked(self, action):
+        # Migrations rewrite every publication's rows
+        with tenancy.unscoped(), get_postgres_connection(timeout_ms=0) as conn:
+            with conn.cursor(cursor_factory=RealDictCursor) as cursor:
+                # Session-level lock: held across the per-migration commits below
+                cursor.execute("SELECT pg_advisory_lock(%s)", (MIGRATION_LOCK_ID,))
+                conn.commit()
+                try:
+                    self._ensure_table(cursor)
+                    conn.commit()
+                    return action(conn, cursor)
+                finally:
+                    conn.rollback()
+                    cursor.execute("SELECT pg_advisory_unlock(%s)", (MIGRATION_LOCK_ID,))
+                    conn.commit()
+
+    def up(self, target: Optional[int] = None) -> List[Migration]:
+        """Apply pending migrations up to and including `target` (default: all)"""
+        def apply(conn, cursor):
+            applied = self._applied(cursor)
+            pending = [
+                migration for migration in self.discover()
+                if migration.version not in applied and (target is None or migration.version <= target)
+            ]
+            for migration in pending:
+                logger.info(f"Applying migration {migration.version}_{migration.name}")
+                try:
+                    cursor.execute(migration.read())
+                    cursor.execute(
+                        "INSERT INTO schema_migrations (version, name, checksum) VALUES (%s, %s, %s)",
+                        (migration.version, migration.name, migration.checksum)
+                    )
+                    conn.commit()
+                except Exception as e:
+                    conn.rollback()
+                    raise MigrationError(f"Migration {migration.version}_{migration.name} failed: {e}") from e
+            return pending
+
+        return self._locked(apply)
+
+    def down(self, steps: int = 1) -> List[Migration]:
+        """Revert the most recently applied `steps` migrations"""
+        def revert(conn, cursor):
+            applied = self._applied(cursor)
+            by_version = {migration.version: migration for migration in self.discover()}
+            reverted = []
+            for version in sorted(applied, reverse=True)[:steps]:
+                migration = by_version.get(version)
+                if not migration or not migration.down_path:
+                    raise MigrationError(f"Migration {version} has no down migration")
+
+                logger.info(f"Reverting migration {migration.version}_{migration.name}")
+                try:
+                    cursor.execute(migration.read(down=True))
+                    cursor.execute("DELETE FROM schema_migrations WHERE version = %s", (version,))
+                    conn.commit()
+                except Exception as e:
+                    conn.rollback()
+                    raise MigrationError(f"Reverting {migration.version}_{migration.name} failed: {e}") from e
+                reverted.append(migration)
+            return reverted
+
+        return self._locked(revert)
+
+    def baseline(self, version: Optional[int] = None) -> List[Migration]:
+        """Record migrations as applied without running them
+
+        For databases created before migrations were tracked, e.g. by the
+        postgres container's init scripts.
+        """
+        def mark(conn, cursor):
+            applied = self._applied(cursor)
+            marked = [
+                migration for migration in self.discover()
+                if migration.version not in applied and (version is None or migration.version <= version)
+            ]
+            for migration in marked:
+                cursor.execute(
+                    "INSERT INTO schema_migrations (version, name, checksum) VALUES (%s, %s, %s)",
+                    (migration.version, migration.name, migration.checksum)
+                )
+            conn.commit()
+            return marked
+
+        return self._locked(mark)
+
+
+# Global migration runner instance
+migration_runner = MigrationRunner()
+
+
+def auto_migrate():
+    """Apply pending migrations at startup when AUTO_MIGRATE is enabled"""
+    if os.getenv('AUTO_MIGRATE', 'false').lower() != 'true':
+        return
+    applied = migration_runner.up()
+    logger.info(f"Auto-migrate applied {len(applied)} migration(s)")
@@ -2,13 +2,43 @@
 Shared data models and schemas for both Flask and FastAPI backends
 """
 
-from datetime import datetime
-from typing import List, Optional, Dict, Any
-from pydantic import BaseModel, EmailStr, Field
+from datetime import datetime, timezone
+from typing import Annotated, List, Optional, Dict, Any
+from pydantic import BaseModel, BeforeValidator, EmailStr, Field, field_validator, model_validator
+from decimal import Decimal
 from enum import Enum
+import json
+import re
 import uuid
 
 
+# JSONB column types
+def _decode_json_map(value: Any) -> Any:
+    """Accept a JSONB object as psycopg2 returns it, as JSON text, or NULL"""
+    if value is None:
+        return {}
+    if isinstance(value, (bytes, bytearray)):
+        value = value.decode('utf-8')
+    # Rows written with json.dumps() into a Json adapter hold the object as a JSON string
+    while isinstance(value, str):
+        value = json.loads(value) if value.strip() else {}
+    if not isinstance(value, dict):
+        raise ValueError("must be a JSON object")
+    return value
+
+
+JSONMap = Annotated[Dict[str, Any], BeforeValidator(_decode_json_map)]
+
+
+# Sensitivity annotations
+def Sensitive(default: Any = ..., *, kind: str = 'secret', **kwargs: Any) -> Any:
+    """A field holding personal data or a credential; logs and error reports mask it (shared/pii.py)
+
+    `kind` picks the masking: `email`, `ip`, or `secret` (replaced entirely).
+    """
+    return Field(default, json_schema_extra={'sensitive': kind}, **kwargs)
+
+
 # Enums
 class UserRole(str, Enum):
     AUTHOR = "author"
@@ -24,6 +54,23 @@ class ArticleStatus(str, Enum):
     BLOCKED = "blocked"
 
 
+class ArticleLicense(str, Enum):
+    CC0 = "cc0-1.0"
+    CC_BY = "cc-by-4.0"
+    CC_BY_SA = "cc-by-sa-4.0"
+    CC_BY_ND = "cc-by-nd-4.0"
+    CC_BY_NC = "cc-by-nc-4.0"
+    CC_BY_NC_SA = "cc-by-nc-sa-4.0"
+    CC_BY_NC_ND = "cc-by-nc-nd-4.0"
+    ALL_RIGHTS_RESERVED = "all-rights-reserved"
+    CUSTOM = "custom"
+
+
+class ContentFormat(str, Enum):
+    HTML = "html"
+    MARKDOWN = "markdown"
+
+
 class InteractionType(str, Enum):
     LIKE = "like"
     DISLIKE = "dislike"
@@ -31,6 +78,7 @@ class InteractionType(str, Enum):
     SHARE = "share"
     VIEW = "view"
     COMMENT = "comment"
+    REACTION = "reaction"
 
 
 class RecommendationModel(str, Enum):
@@ -63,35 +111,89 @@ class ErrorResponse(BaseResponse):
 # User models
 class UserBase(BaseModel):
     username: str = Field(..., min_length=3, max_length=50)
-    email: EmailStr
+    email: EmailStr = Sensitive(kind='email')
     role: UserRole = UserRole.READER
     anonymous_mode: bool = False
-    profile_data: Optional[Dict[str, Any]] = None
-    preferences: Optional[Dict[str, Any]] = None
+    profile_data: JSONMap = Field(default_factory=dict)
+    preferences: JSONMap = Field(default_factory=dict)
 
 
 class UserCreate(UserBase):
-    password: str = Field(..., min_length=8)
+    password: str = Sensitive(min_length=8)
 
 
 class UserUpdate(BaseModel):
     username: Optional[str] = Field(None, min_length=3, max_length=50)
-    email: Optional[EmailStr] = None
+    email: Optional[EmailStr] = Sensitive(None, kind='email')
     role: Optional[UserRole] = None
     anonymous_mode: Optional[bool] = None
-    profile_data: Optional[Dict[str, Any]] = None
-    preferences: Optional[Dict[str, Any]] = None
+    profile_data: Optional[JSONMap] = None
+    preferences: Optional[JSONMap] = None
+
+
+class AccountDeletionRequest(BaseModel):
+    password: Optional[str] = Sensitive(None)  # Required for accounts that have a password
+
+
+class AccountMigrationImport(BaseModel):
+    export: Dict[str, Any]  # The signed export from the origin, as it was downloaded
+
+
+class AccountMigrationComplete(BaseModel):
+    receipt: Dict[str, Any]  # The destination's signed receipt from the import
+
+
+class WalletChallengeRequest(BaseModel):
+    address: str = Field(..., pattern=r'^0x[0-9a-fA-F]{40}$')
+
+
+class WalletChallengeResponse(BaseModel):
+    address: str
+    message: str  # Sign with personal_sign and submit within the TTL
+    expires_at: datetime
+
+
+class WalletLinkCreate(BaseModel):
+    address: str = Field(..., pattern=r'^0x[0-9a-fA-F]{40}$')
+    signature: str = Field(..., min_length=1, max_length=200)  # 0x-prefixed personal_sign signature of the challenge
+    label: Optional[str] = Field(None, max_length=100)
+    primary: bool = False
+
+
+class WalletResponse(BaseModel):
+    id: uuid.UUID
+    address: str
+    did: str  # did:pkh:eip155:<chain id>:<address>
+    chain_id: int
+    label: Optional[str] = None
+    is_primary: bool
+    verified_at: Optional[datetime] = None  # None for an address set before wallets were linked
+    created_at: datetime
 
 
 class UserResponse(UserBase):
     id: uuid.UUID
-    did_address: Optional[str] = None
+    did_address: Optional[str] = None  # The primary wallet
+    wallets: List[WalletResponse] = Field(default_factory=list)
+    moved_to: Optional[str] = None  # The account on another instance this one moved to
+    moved_at: Optional[datetime] = None
     created_at: datetime
     updated_at: datetime
     last_active: datetime
     is_active: bool
     verification_status: bool
     reputation_score: float
+    publication_role: Optional[str] = None  # editor or contributor of the user's publication
+
+    @model_validator(mode='before')
+    @classmethod
+    def load_wallets(cls, data: Any) -> Any:
+        if isinstance(data, dict) and 'wallets' not in data and data.get('id'):
+            from shared.database import get_postgres_cursor
+            from shared.wallets import list_wallets
+            with get_postgres_cursor() as cursor:
+                data = {**data, 'wallets': list_wallets(cursor, str(data['id']))}
+        return data
     
     class Config:
         from_attributes = True
@@ -101,37 +203,210 @@ class UserResponse(UserBase):
 
 
 class UserLogin(BaseModel):
-    email: EmailStr
-    password: str
+    email: EmailStr = Sensitive(kind='email')
+    password: str = Sensitive()
 
 
 class TokenResponse(BaseResponse):
-    access_token: str
+    access_token: str = Sensitive()
     token_type: str = "bearer"
     expires_in: int
     user: UserResponse
 
 
+class SocialLoginCallback(BaseModel):
+    code: str = Sensitive()
+    state: str
+
+
 # Article models
 class ArticleBase(BaseModel):
     title: str = Field(..., min_length=1, max_length=500)
     content: str = Field(..., min_length=1)
+    content_format: ContentFormat = ContentFormat.HTML
     summary: Optional[str] = Field(None, max_length=1000)
     category: str = Field(..., min_length=1, max_length=100)
     subcategory: Optional[str] = Field(None, max_length=100)
     tags: List[str] = Field(default_factory=list)
     language: str = Field(default="en", max_length=10)
     anonymous_author: bool = False
-    metadata: Optional[Dict[str, Any]] = None
+    metadata: JSONMap = Field(default_factory=dict)
+    license: ArticleLicense = ArticleLicense.ALL_RIGHTS_RESERVED
+    license_terms: Optional[str] = Field(None, max_length=5000)
+
+
+class ArticleSignatureCreate(BaseModel):
+    key_id: uuid.UUID
+    signature: str = Field(..., min_length=1, max_length=500)  # Base64 or 0x-prefixed hex
 
 
 class ArticleCreate(ArticleBase):
-    pass
+    signature: Optional[ArticleSignatureCreate] = None  # Detached signature over the stored article's payload
+
+    @model_validator(mode='after')
+    def check_custom_license(self):
+        if self.license == ArticleLicense.CUSTOM and not self.license_terms:
+            raise ValueError("license_terms is required for a custom license")
+        return self
+
+
+class ArticleScheduleCreate(BaseModel):
+    publish_at: datetime  # Read as UTC when no timezone is given
+
+    @model_validator(mode='after')
+    def check_publish_at(self):
+        if self.publish_at.tzinfo is None:
+            self.publish_at = self.publish_at.replace(tzinfo=timezone.utc)
+        if self.publish_at <= datetime.now(timezone.utc):
+            raise ValueError("publish_at must be in the future")
+        return self
+
+
+class TokenStandard(str, Enum):
+    ERC20 = "erc20"
+    ERC721 = "erc721"
+    ERC1155 = "erc1155"
+
+
+class TokenGate(BaseModel):
+    standard: TokenStandard
+    contract: str = Field(..., pattern=r'^0x[0-9a-fA-F]{40}$')
+    token_id: Optional[int] = Field(None, ge=0)  # Required for ERC-1155
+    min_balance: float = Field(default=1, gt=0)  # Whole tokens for ERC-20, units otherwise
+
+    @model_validator(mode='after')
+    def check_token_id(self):
+        if self.standard == TokenStandard.ERC1155 and self.token_id is None:
+            raise ValueError("token_id is required for ERC-1155 tokens")
+        return self
+
+
+class PurchaseOption(BaseModel):
+    price: float = Field(..., gt=0)  # In the paywall chain's native currency, e.g. ETH
+
+
+class ArticleAccessPolicy(BaseModel):
+    """Ways to unlock a premium article; meeting any one is enough"""
+    subscription_tier: Optional[str] = Field(None, max_length=50)
+    token: Optional[TokenGate] = None
+    purchase: Optional[PurchaseOption] = None
+
+    @model_validator(mode='after')
+    def check_any(self):
+        if not (self.subscription_tier or self.token or self.purchase):
+            raise ValueError("Give at least one of subscription_tier, token or purchase")
+        return self
+
+
+class ArticlePurchaseCreate(BaseModel):
+    transaction_hash: str = Field(..., pattern=r'^0x[0-9a-fA-F]{64}$')
+
+
+class SubscriptionTierCreate(BaseModel):
+    key: str = Field(..., min_length=1, max_length=50, pattern=r'^[a-z0-9_]+$')
+    name: str = Field(..., min_length=1, max_length=100)
+    rank: int = Field(..., ge=0)
+    description: Optional[str] = Field(None, max_length=1000)
+
+
+class SubscriptionGrant(BaseModel):
+    user_id: uuid.UUID
+    tier: str = Field(..., max_length=50)
+    starts_at: Optional[datetime] = None
+    expires_at: Optional[datetime] = None  # Never expires when omitted
+    source: str = Field(default='admin', max_length=50)
+    external_ref: Optional[str] = Field(None, max_length=255)
+
+
+class FunnelEvent(str, Enum):
+    VIEW = "view"
+    READ = "read"
+    LIKE = "like"
+    SAVE = "save"
+    SHARE = "share"
+    COMMENT = "comment"
+    INTERACT = "interact"
+    FOLLOW_AUTHOR = "follow_author"
+    FOLLOW_CATEGORY = "follow_category"
+
+
+class FunnelStep(BaseModel):
+    event: FunnelEvent
+    min_progress: Optional[float] = Field(None, ge=0, le=1)  # For `read`; defaults to half the article
+
+
+class FunnelCreate(BaseModel):
+    key: str = Field(..., min_length=1, max_length=50, pattern=r'^[a-z0-9_]+$')
+    name: str = Field(..., min_length=1, max_length=100)
+    description: Optional[str] = Field(None, max_length=1000)
+    steps: List[FunnelStep] = Field(..., min_length=2, max_length=8)
+    window_hours: int = Field(default=168, ge=1, le=720)
+
+    @field_validator('steps')
+    @classmethod
+    def check_entry(cls, steps: List[FunnelStep]) -> List[FunnelStep]:
+        if steps[0].event in (FunnelEvent.FOLLOW_AUTHOR, FunnelEvent.FOLLOW_CATEGORY):
+            raise ValueError("The first step must be about the article, not a follow")
+        return steps
+
+
+class FunnelUpdate(BaseModel):
+    name: Optional[str] = Field(None, min_length=1, max_length=100)
+    description: Optional[str] = Field(None, max_length=1000)
+    steps: Optional[List[FunnelStep]] = Field(None, min_length=2, max_length=8)
+    window_hours: Optional[int] = Field(None, ge=1, le=720)
+    is_active: Optional[bool] = None
+
+    @field_validator('steps')
+    @classmethod
+    def check_entry(cls, steps: Optional[List[FunnelStep]]) -> Optional[List[FunnelStep]]:
+        if steps and steps[0].event in (FunnelEvent.FOLLOW_AUTHOR, FunnelEvent.FOLLOW_CATEGORY):
+            raise ValueError("The first step must be about the article, not a follow")
+        return steps
+
+
+class TipRail(str, Enum):
+    STRIPE = "stripe"
+    ONCHAIN = "onchain"
+
+
+class TipCreate(BaseModel):
+    """A tip for an author, or for the author of `article_id`"""
+    author_id: Optional[uuid.UUID] = None
+    article_id: Optional[uuid.UUID] = None
+    rail: TipRail = TipRail.STRIPE
+    amount: Optional[Decimal] = Field(None, gt=0)  # Card tips, in the currency's major unit, e.g. 5.00
+    currency: str = Field(default='usd', min_length=3, max_length=10)
+    transaction_hash: Optional[str] = Field(None, pattern=r'^0x[0-9a-fA-F]{64}$')  # On-chain tips
+    message: Optional[str] = Field(None, max_length=500)
+    anonymous: bool = False
+
+    @model_validator(mode='after')
+    def check_rail(self):
+        if not (self.author_id or self.article_id):
+            raise ValueError("Give author_id or article_id")
+        if self.rail == TipRail.STRIPE and self.amount is None:
+            raise ValueError("Card tips need an amount")
+        if self.rail == TipRail.ONCHAIN and not self.transaction_hash:
+            raise ValueError("On-chain tips need a transaction_hash")
+        return self
+
+
+class PayoutRequestCreate(BaseModel):
+    amount: Decimal = Field(..., gt=0)
+    currency: str = Field(default='usd', min_length=3, max_length=10)
+    destination: str = Field(..., min_length=1, max_length=255)  # Wallet address or payout account reference
+
+
+class PayoutReview(BaseModel):
+    external_ref: Optional[str] = Field(None, max_length=255)  # Transfer or transaction id, when paid
+    note: Optional[str] = Field(None, max_length=1000)
 
 
 class ArticleUpdate(BaseModel):
     title: Optional[str] = Field(None, min_length=1, max_length=500)
     content: Optional[str] = Field(None, min_length=1)
+    content_format: Optional[ContentFormat] = None
     summary: Optional[str] = Field(None, max_length=1000)
     category: Optional[str] = Field(None, min_length=1, max_length=100)
     subcategory: Optional[str] = Field(None, max_length=100)
@@ -139,13 +414,24 @@ class ArticleUpdate(BaseModel):
     language: Optional[str] = Field(None, max_length=10)
     status: Optional[ArticleStatus] = None
     anonymous_author: Optional[bool] = None
-    metadata: Optional[Dict[str, Any]] = None
+    metadata: Optional[JSONMap] = None
+    license: Optional[ArticleLicense] = None
+    license_terms: Optional[str] = Field(None, max_length=5000)
+
+    @model_validator(mode='after')
+    def check_custom_license(self):
+        if self.license == ArticleLicense.CUSTOM and not self.license_terms:
+            raise ValueError("license_terms is required for a custom license")
+        return self
 
 
 class ArticleResponse(ArticleBase):
     id: uuid.UUID
-    author_id: Optional[uuid.UUID] = None
+    slug: Optional[str] = None  # Follows the title; old slugs redirect (GET /articles/by-slug/{slug})
+    author_id: Optional[uuid.UUID] = None  # The lead author
+    authors: List[Dict[str, Any]] = Field(default_factory=list)  # Credited authors, lead first (shared/coauthors.py)
     status: ArticleStatus
+    content_html: str = ''  # `content` rendered and sanitized (shared/content.py)
     reading_time: int
     word_count: int
     published_at: Optional[datetime] = None
@@ -153,6 +439,11 @@ class ArticleResponse(ArticleBase):
     updated_at: datetime
     source_url: Optional[str] = None
     image_urls: List[str] = Field(default_factory=list)
+    og_image_url: Optional[str] = None  # Generated share card, for articles without images
+    link_previews: List[Dict[str, Any]] = Field(default_factory=list)  # Open Graph previews of the source URL and linked pages
+    translations: List[str] = Field(default_factory=list)  # Languages machine translations are available in
+    translation: Optional[Dict[str, Any]] = None  # Provenance when the text is a translation
+    scheduled_publish_at: Optional[datetime] = None  # When a scheduled draft goes live
     seo_keywords: List[str] = Field(default_factory=list)
     engagement_score: float
     quality_score: float
@@ -161,6 +452,25 @@ class ArticleResponse(ArticleBase):
     like_count: int = 0
     comment_count: int = 0
     share_count: int = 0
+    reaction_counts: Dict[str, int] = Field(default_factory=dict)
+    clap_count: int = 0
+    reading_level: Optional[str] = None
+    readability: Optional[Dict[str, Any]] = None
+    experiment_variants: Dict[str, str] = Field(default_factory=dict)  # Experiment kind -> variant shown to the viewer
+    access_policy: Optional[Dict[str, Any]] = None  # Set for premium articles
+    premium: bool = False
+    paywalled: bool = False  # True when `content` is a preview because the viewer isn't entitled
+
+    @model_validator(mode='before')
+    @classmethod
+    def apply_paywall(cls, data: Any) -> Any:
+        if isinstance(data, dict) and data.get('access_policy'):
+            from shared.paywall import gate
+            data = {**gate(data), 'premium': True}
+        if isinstance(data, dict) and not data.get('content_html') and data.get('content'):
+            from shared.content import render
+            data = {**data, 'content_html': render(data['content'], data.get('content_format') or 'html')}
+        return data
     
     class Config:
         from_attributes = True
@@ -177,7 +487,7 @@ class InteractionCreate(BaseModel):
     reading_progress: float = Field(default=0.0, ge=0.0, le=1.0)
     time_spent: int = Field(default=0, ge=0)
     device_type: str = Field(default="unknown")
-    context_data: Optional[Dict[str, Any]] = None
+    context_data: JSONMap = Field(default_factory=dict)
 
 
 class InteractionResponse(InteractionCreate):
@@ -220,12 +530,14 @@ class SearchRequest(BaseModel):
     limit: int = Field(default=20, ge=1, le=100)
     offset: int = Field(default=0, ge=0)
     sort_by: str = Field(default="relevance")  # relevance, date, popularity
+    mode: str = Field(default="keyword", pattern="^(keyword|semantic)$")  # semantic blends in embedding similarity
 
 
 class SearchResponse(BaseResponse):
     results: List[ArticleResponse]
     total_count: int
     query: str
+    mode: str = 'keyword'  # The mode that ran; semantic falls back to keyword
     execution_time_ms: float
 
 
@@ -235,7 +547,7 @@ class AnalyticsRequest(BaseModel):
     article_id: Optional[uuid.UUID] = None
     date_from: Optional[datetime] = None
     date_to: Optional[datetime] = None
-    metrics: List[str] = Field(default_factory=lambda: ["views", "likes", "shares"])
+    metrics: List[str] = Field(default_factory=lambda: ["views", "likes", "shares", "claps"])
 
 
 class AnalyticsResponse(BaseResponse):
@@ -243,6 +555,23 @@ class AnalyticsResponse(BaseResponse):
     period: Dict[str, Any]
 
 
+class ModelPerformanceReport(BaseModel):
+    model_type: RecommendationModel
+    model_version: str = Field(..., min_length=1, max_length=50)
+    validation_metrics: JSONMap
+    test_metrics: JSONMap
+    hyperparameters: JSONMap
+    training_data_size: int = Field(..., ge=0)
+    validation_data_size: int = Field(..., ge=0)
+    test_data_size: int = Field(..., ge=0)
+    training_duration_minutes: Optional[int] = Field(None, ge=0)
+    model_size_mb: Optional[float] = Field(None, ge=0)
+    inference_time_ms: Optional[float] = Field(None, ge=0)
+    is_production_ready: bool = False
+    deployment_status: str = Field('training', max_length=50)
+    notes: Optional[str] = None
+
+
 # Pagination models
 class PaginatedResponse(BaseResponse):
     data: List[Any]
@@ -254,6 +583,46 @@ class PaginatedResponse(BaseResponse):
     has_prev: bool
 
 
+class CursorPaginatedResponse(BaseResponse):
+    data: List[Any]
+    next_cursor: Optional[str] = None
+    has_more: bool = False
+
+
+# Follow models
+class FollowedAuthor(BaseModel):
+    id: uuid.UUID
+    username: str
+    followed_at: datetime
+
+
+class FollowListResponse(BaseResponse):
+    user_id: uuid.UUID
+    users: List[FollowedAuthor]
+    categories: List[str] = Field(default_factory=list)
+    total: int
+
+
+# Block and shadow ban models
+class BlockedUser(BaseModel):
+    id: uuid.UUID
+    username: str
+    blocked_at: datetime
+
+
+class ShadowBanCreate(BaseModel):
+    reason: Optional[str] = Field(None, max_length=1000)  # Seen by moderators only
+
+
+class ShadowBannedUser(BaseModel):
+    id: uuid.UUID
+    username: str
+    shadow_banned_at: datetime
+    shadow_ban_reason: Optional[str] = None
+    shadow_banned_by: Optional[uuid.UUID] = None
+    shadow_banned_by_username: Optional[str] = None
+
+
 # NFT Donation models
 class PaymentStatus(str, Enum):
     PENDING = "pending"
@@ -369,9 +738,1417 @@ class RegisterArticleRequest(BaseModel):
     author_address: str
 
 
+# Settings models
+class SettingUpdate(BaseModel):
+    value: Any
+
+
+class SettingResponse(BaseResponse):
+    key: str
+    value: Any
+
+
+class FederationPolicy(BaseModel):
+    accept_remote_content: bool = True
+    allowed_instances: List[str] = Field(default_factory=list)  # Empty means any instance not blocked
+    blocked_instances: List[str] = Field(default_factory=list)  # Domains; subdomains are blocked too
+    rejected_categories: List[str] = Field(default_factory=list)  # Refused from remote instances only
+
+
+class InstancePolicy(BaseModel):
+    jurisdiction: Optional[str] = Field(None, max_length=100)  # e.g. "DE", shown in node metadata
+    blocked_categories: List[str] = Field(default_factory=list)  # Never published or accepted
+    moderated_tags: List[str] = Field(default_factory=list)  # Publishing with these tags needs approval
+    federation: FederationPolicy = Field(default_factory=FederationPolicy)
+
+    @field_validator('blocked_categories', 'moderated_tags')
+    @classmethod
+    def normalize_names(cls, values: List[str]) -> List[str]:
+        return sorted({value.strip().lower() for value in values if value.strip()})
+
+
+class ArticleReviewDecision(BaseModel):
+    note: Optional[str] = Field(None, max_length=2000)
+
+
+# Article report models
+class ReportReason(str, Enum):
+    MISINFORMATION = "misinformation"
+    SPAM = "spam"
+    HARASSMENT = "harassment"
+    PLAGIARISM = "plagiarism"
+    OTHER = "other"
+
+
+class ArticleReportCreate(BaseModel):
+    reason: ReportReason
+    details: Optional[str] = Field(None, max_length=2000)
+
+
+class ReportDecision(BaseModel):
+    note: Optional[str] = Field(None, max_length=2000)
+
+
+# Home feed models
+class FeedShelfConfig(BaseModel):
+    name: str = Field(..., min_length=1, max_length=50)
+    title: str = Field(..., min_length=1, max_length=100)
+    source: str = Field(..., min_length=1, max_length=50)
+    count: int = Field(default=10, ge=1, le=50)
+    position: int = Field(default=0, ge=0)
+    enabled: bool = True
+    options: Dict[str, Any] = Field(default_factory=dict)
+
+
+class HomeFeedConfig(BaseModel):
+    shelves: List[FeedShelfConfig]
+    deduplicate: bool = True
+    cache_ttl_seconds: int = Field(default=60, ge=0, le=3600)
+
+
+class FeedShelf(BaseModel):
+    name: str
+    title: str
+    source: str
+    position: int
+    items: List[ArticleResponse] = Field(default_factory=list)
+    topics: Optional[List[Dict[str, Any]]] = None
+
+
+# Curation models
+class PinScope(str, Enum):
+    HOME = "home"
+    CATEGORY = "category"
+
+
+class PinType(str, Enum):
+    PINNED = "pinned"
+    FEATURED = "featured"
+
+
+class PinCreate(BaseModel):
+    article_id: uuid.UUID
+    scope: PinScope = PinScope.HOME
+    category: Optional[str] = Field(None, max_length=100)
+    pin_type: PinType = PinType.PINNED
+    position: int = Field(default=0, ge=0)
+    starts_at: Optional[datetime] = None
+    ends_at: Optional[datetime] = None
+    note: Optional[str] = Field(None, max_length=500)
+
+
+class PinUpdate(BaseModel):
+    pin_type: Optional[PinType] = None
+    position: Optional[int] = Field(None, ge=0)
+    starts_at: Optional[datetime] = None
+    ends_at: Optional[datetime] = None
+    note: Optional[str] = Field(None, max_length=500)
+
+
+class PinResponse(BaseModel):
+    id: uuid.UUID
+    article_id: uuid.UUID
+    scope: PinScope
+    category: Optional[str] = None
+    pin_type: PinType
+    position: int
+    starts_at: datetime
+    ends_at: Optional[datetime] = None
+    pinned_by: Optional[uuid.UUID] = None
+    note: Optional[str] = None
+    created_at: datetime
+    is_active: bool = True
+
+
+class PinnedArticle(BaseModel):
+    pin_id: uuid.UUID
+    pin_type: PinType
+    position: int
+    ends_at: Optional[datetime] = None
+    article: ArticleResponse
+
+
+class HomeFeedResponse(BaseResponse):
+    pinned: List[PinnedArticle] = Field(default_factory=list)
+    shelves: List[FeedShelf]
+    generated_at: datetime
+
+
+class FeedPageResponse(CursorPaginatedResponse):
+    pinned: List[PinnedArticle] = Field(default_factory=list)
+
+
+# Collection models
+class CollectionRuleType(str, Enum):
+    TAG = "tag"
+    CATEGORY = "category"
+
+
+class CollectionCreate(BaseModel):
+    slug: str = Field(..., min_length=1, max_length=150, pattern=r'^[a-z0-9]+(?:-[a-z0-9]+)*$')
+    title: str = Field(..., min_length=1, max_length=300)
+    description: Optional[str] = None
+    cover_image_url: Optional[str] = Field(None, max_length=1000)
+    is_published: bool = False
+
+
+class CollectionUpdate(BaseModel):
+    slug: Optional[str] = Field(None, min_length=1, max_length=150, pattern=r'^[a-z0-9]+(?:-[a-z0-9]+)*$')
+    title: Optional[str] = Field(None, min_length=1, max_length=300)
+    description: Optional[str] = None
+    cover_image_url: Optional[str] = Field(None, max_length=1000)
+    is_published: Optional[bool] = None
+
+
+class CollectionSectionCreate(BaseModel):
+    title: str = Field(..., min_length=1, max_length=300)
+    description: Optional[str] = None
+    position: int = Field(default=0, ge=0)
+
+
+class CollectionArticleAdd(BaseModel):
+    article_id: uuid.UUID
+    section_id: Optional[uuid.UUID] = None
+    position: int = Field(default=0, ge=0)
+
+
+class CollectionRuleCreate(BaseModel):
+    rule_type: CollectionRuleType
+    value: str = Field(..., min_length=1, max_length=100)
+    section_id: Optional[uuid.UUID] = None
+
+
+class CollectionSectionResponse(BaseModel):
+    id: Optional[uuid.UUID] = None
+    title: str
+    description: Optional[str] = None
+    position: int = 0
+    articles: List[ArticleResponse] = Field(default_factory=list)
+
+
+class CollectionResponse(BaseModel):
+    id: uuid.UUID
+    slug: str
+    title: str
+    description: Optional[str] = None
+    cover_image_url: Optional[str] = None
+    is_published: bool
+    created_at: datetime
+    updated_at: datetime
+    sections: List[CollectionSectionResponse] = Field(default_factory=list)
+    rules: List[Dict[str, Any]] = Field(default_factory=list)
+
+
+# Syndication models
+class RedactionPolicy(BaseModel):
+    remove_fields: List[str] = Field(default_factory=list)  # Dotted payload paths, e.g. "author.did_address"
+    allowed_metadata_keys: Optional[List[str]] = None  # None keeps every metadata key
+    scrub_emails: bool = True  # Also scrub emails from title, summary and content
+
+    @field_validator('remove_fields')
+    @classmethod
+    def check_remove_fields(cls, fields: List[str]) -> List[str]:
+        for field in fields:
+            if not re.fullmatch(r'[A-Za-z0-9_*]+(\.[A-Za-z0-9_*]+)*', field):
+                raise ValueError(f"Invalid field path '{field}'")
+            # Partners need these to attribute and honour the license
+            if field.split('.')[0] in ('id', 'canonical_url', 'license', '*'):
+                raise ValueError(f"Field '{field}' cannot be redacted")
+        return fields
+
+
+class SyndicationPartnerCreate(BaseModel):
+    name: str = Field(..., min_length=1, max_length=200)
+    contact_email: Optional[EmailStr] = Sensitive(None, kind='email')
+    allowed_licenses: List[ArticleLicense] = Field(default_factory=list)
+    commercial_use: bool = False
+    redaction_policy: RedactionPolicy = Field(default_factory=RedactionPolicy)
+
+
+class SyndicationPartnerUpdate(BaseModel):
+    name: Optional[str] = Field(None, min_length=1, max_length=200)
+    contact_email: Optional[EmailStr] = Sensitive(None, kind='email')
+    allowed_licenses: Optional[List[ArticleLicense]] = None
+    commercial_use: Optional[bool] = None
+    is_active: Optional[bool] = None
+    redaction_policy: Optional[RedactionPolicy] = None
+
+
+class SyndicationPartnerResponse(BaseModel):
+    id: uuid.UUID
+    name: str
+    contact_email: Optional[str] = Sensitive(None, kind='email')
+    api_key_prefix: str
+    allowed_licenses: List[str] = Field(default_factory=list)
+    commercial_use: bool
+    is_active: bool
+    redaction_policy: Dict[str, Any] = Field(default_factory=dict)
+    last_used_at: Optional[datetime] = None
+    created_at: datetime
+    api_key: Optional[str] = Sensitive(None)  # Only returned when a key is issued
+
+
+class SyndicationDeltaResponse(BaseResponse):
+    items: List[Dict[str, Any]]
+    removed: List[str] = Field(default_factory=list)
+    delta_token: Optional[str] = None
+    has_more: bool = False
+
+
+class SyndicationUsage(BaseModel):
+    usage_date: str
+    endpoint: str
+    request_count: int
+    article_count: int
+
+
+# Reaction models
+class ReactionTypeConfig(BaseModel):
+    key: str = Field(..., min_length=1, max_length=50, pattern=r'^[a-z0-9_]+$')
+    label: str = Field(..., min_length=1, max_length=100)
+    emoji: Optional[str] = Field(None, max_length=16)
+    weight: float = Field(default=1.0, ge=-5.0, le=5.0)  # Contribution to the engagement score
+    enabled: bool = True
+
+
+class ReactionsConfig(BaseModel):
+    types: List[ReactionTypeConfig] = Field(default_factory=list)
+
+
+class OgImageConfig(BaseModel):
+    site_name: str = Field(..., min_length=1, max_length=60)
+    background_color: str = Field(..., pattern="^#[0-9a-fA-F]{6}$")
+    text_color: str = Field(..., pattern="^#[0-9a-fA-F]{6}$")
+    default_color: str = Field(..., pattern="^#[0-9a-fA-F]{6}$")
+    category_colors: Dict[str, str] = Field(default_factory=dict)
+
+    @model_validator(mode='after')
+    def validate_category_colors(self):
+        if any(not re.fullmatch(r'#[0-9a-fA-F]{6}', color) for color in self.category_colors.values()):
+            raise ValueError("Category colors must be #rrggbb")
+        return self
+
+
+class ReputationConfig(BaseModel):
+    weights: Dict[str, float] = Field(default_factory=dict)  # Score change per event, -100 to 100
+    decay_half_life_days: float = Field(default=180, ge=0, le=3650)  # 0 turns decay off
+
+    @model_validator(mode='after')
+    def validate_weights(self):
+        from shared.reputation import EVENTS
+        unknown = set(self.weights) - set(EVENTS)
+        if unknown:
+            raise ValueError(f"Unknown reputation events: {', '.join(sorted(unknown))}")
+        if any(abs(weight) > 100 for weight in self.weights.values()):
+            raise ValueError("Weights must be between -100 and 100")
+        return self
+
+
+class TelemetryEventType(str, Enum):
+    SCROLL_DEPTH = "scroll_depth"  # value: deepest point reached, 0 to 1
+    DWELL = "dwell"  # value: milliseconds the article was visible
+    IMPRESSION = "impression"  # article shown in a list; properties: surface, position
+
+
+class TelemetryEvent(BaseModel):
+    type: TelemetryEventType
+    occurred_at: datetime
+    article_id: Optional[uuid.UUID] = None
+    value: Optional[float] = None
+    properties: Dict[str, Any] = Field(default_factory=dict)
+
+    @model_validator(mode='after')
+    def validate_value(self):
+        if self.type != TelemetryEventType.IMPRESSION and self.value is None:
+            raise ValueError(f"{self.type.value} events need a value")
+        if self.type == TelemetryEventType.SCROLL_DEPTH and not 0 <= self.value <= 1:
+            raise ValueError("Scroll depth must be between 0 and 1")
+        if self.type == TelemetryEventType.DWELL and not 0 <= self.value <= 86400000:
+            raise ValueError("Dwell time must be between 0 and 24 hours in milliseconds")
+        if self.type == TelemetryEventType.IMPRESSION and self.article_id is None:
+            raise ValueError("Impressions need an article_id")
+        if len(self.properties) > 20 or len(json.dumps(self.properties, default=str)) > 2000:
+            raise ValueError("properties is limited to 20 keys and 2000 bytes")
+        return self
+
+
+class TelemetryBatch(BaseModel):
+    session_id: str = Field(..., min_length=8, max_length=64)
+    events: List[TelemetryEvent] = Field(..., min_length=1, max_length=500)
+
+
+class TelemetryConfig(BaseModel):
+    sample_rate: float = Field(default=1.0, ge=0, le=1)
+    type_sample_rates: Dict[str, float] = Field(default_factory=dict)
+
+    @field_validator('type_sample_rates')
+    @classmethod
+    def validate_type_rates(cls, values: Dict[str, float]) -> Dict[str, float]:
+        unknown = set(values) - {event_type.value for event_type in TelemetryEventType}
+        if unknown:
+            raise ValueError(f"Unknown event types: {', '.join(sorted(unknown))}")
+        if any(not 0 <= rate <= 1 for rate in values.values()):
+            raise ValueError("Sample rates must be between 0 and 1")
+        return values
+
+
+class RobotsRule(BaseModel):
+    user_agent: str = Field(..., min_length=1, max_length=200)
+    allow: List[str] = Field(default_factory=list)
+    disallow: List[str] = Field(default_factory=list)
+    crawl_delay: Optional[float] = Field(None, ge=0, le=3600)
+
+    @model_validator(mode='after')
+    def validate_lines(self):
+        values = [self.user_agent] + self.allow + self.disallow
+        if any('\n' in value or '\r' in value for value in values):
+            raise ValueError("robots.txt values can't contain line breaks")
+        if any(not path.startswith('/') for path in self.allow + self.disallow):
+            raise ValueError("Allow and disallow paths must start with /")
+        return self
+
+
+class RobotsConfig(BaseModel):
+    rules: List[RobotsRule] = Field(default_factory=list)
+    sitemaps: List[str] = Field(default_factory=list)
+    extra: str = Field(default='', max_length=10000)  # Appended verbatim
+
+    @field_validator('sitemaps')
+    @classmethod
+    def validate_sitemaps(cls, values: List[str]) -> List[str]:
+        if any(not re.match(r'^https?://\S+$', value) for value in values):
+            raise ValueError("Sitemaps must be absolute URLs")
+        return values
+
+
+class GovernanceConfig(BaseModel):
+    min_reputation_to_propose: float = Field(default=50, ge=0, le=999.99)
+    min_reputation_to_vote: float = Field(default=10, ge=0, le=999.99)
+    max_open_per_user: int = Field(default=3, ge=1, le=100)
+    voting_days: float = Field(default=7, gt=0, le=365)  # Window when the proposer doesn't pick one
+    min_voting_days: float = Field(default=2, gt=0, le=365)
+    max_voting_days: float = Field(default=30, gt=0, le=365)
+    quorum: int = Field(default=10, ge=1)  # Votes cast, abstentions included, for a result to count
+    pass_threshold: float = Field(default=0.5, ge=0, lt=1)  # Yes share of yes and no votes to exceed
+
+    @model_validator(mode='after')
+    def validate_window(self):
+        if not self.min_voting_days <= self.voting_days <= self.max_voting_days:
+            raise ValueError("voting_days must be between min_voting_days and max_voting_days")
+        return self
+
+
+class RateScreening(BaseModel):
+    max_per_window: int = Field(default=5, ge=1, le=1000)
+    window_seconds: int = Field(default=300, ge=1, le=86400)
+    duplicate_window_seconds: int = Field(default=86400, ge=0, le=30 * 86400)
+
+
+class LinkScreening(BaseModel):
+    blocked_domains: List[str] = Field(default_factory=list)
+    max_links: Optional[int] = Field(default=3, ge=0, le=100)  # Per comment; None for no limit
+
+
+class ToxicityScreening(BaseModel):
+    threshold: float = Field(default=0.8, gt=0, le=1)
+
+
+class ScreeningConfig(BaseModel):
+    checks: List[str] = Field(default_factory=lambda: ['rate', 'links', 'toxicity'])  # Enabled, by name
+    screen_articles: bool = False
+    rate: RateScreening = Field(default_factory=RateScreening)
+    links: LinkScreening = Field(default_factory=LinkScreening)
+    toxicity: ToxicityScreening = Field(default_factory=ToxicityScreening)
+
+
+class ScreeningDecision(BaseModel):
+    note: Optional[str] = Field(None, max_length=2000)
+
+
+class ClapCreate(BaseModel):
+    claps: int = Field(default=1, ge=1, le=50)
+
+
+class ArticleReactionsResponse(BaseResponse):
+    article_id: uuid.UUID
+    counts: Dict[str, int] = Field(default_factory=dict)
+    user_reactions: List[str] = Field(default_factory=list)
+    types: List[ReactionTypeConfig] = Field(default_factory=list)
+
+
+# Webhook models
+class WebhookCreate(BaseModel):
+    url: str = Field(..., min_length=1, max_length=2000, pattern=r'^https?://')
+    description: Optional[str] = Field(None, max_length=500)
+    events: List[str] = Field(..., min_length=1)
+
+
+class WebhookUpdate(BaseModel):
+    url: Optional[str] = Field(None, min_length=1, max_length=2000, pattern=r'^https?://')
+    description: Optional[str] = Field(None, max_length=500)
+    events: Optional[List[str]] = Field(None, min_length=1)
+    is_active: Optional[bool] = None
+
+
+class WebhookResponse(BaseModel):
+    id: uuid.UUID
+    url: str
+    description: Optional[str] = None
+    events: List[str]
+    is_active: bool
+    created_at: datetime
+    updated_at: datetime
+    secret: Optional[str] = Sensitive(None)  # Only returned when the webhook is created or its secret rotated
+
+
+class WebhookDeliveryResponse(BaseModel):
+    id: uuid.UUID
+    event_id: uuid.UUID
+    event_type: str
+    status: str
+    attempt_count: int
+    next_attempt_at: Optional[datetime] = None
+    last_attempt_at: Optional[datetime] = None
+    last_status_code: Optional[int] = None
+    last_error: Optional[str] = None
+    delivered_at: Optional[datetime] = None
+    created_at: datetime
+
+
+# Q&A and discussion models
+class QASessionCreate(BaseModel):
+    title: Optional[str] = Field(None, max_length=300)
+    opens_at: Optional[datetime] = None  # Defaults to now
+    duration_minutes: int = Field(default=60, ge=15, le=7 * 24 * 60)
+
+
+class QAQuestionCreate(BaseModel):
+    body: str = Field(..., min_length=5, max_length=1000)
+
+
+class QAAnswerCreate(BaseModel):
+    answer: str = Field(..., min_length=1, max_length=10000)
+
+
+class QAQuestionResponse(BaseModel):
+    id: uuid.UUID
+    session_id: uuid.UUID
+    body: str
+    asked_by: Optional[str] = None
+    upvote_count: int = 0
+    upvoted: bool = False
+    answer: Optional[str] = None
+    answered_at: Optional[datetime] = None
+    created_at: datetime
+
+
+class QASessionResponse(BaseModel):
+    id: uuid.UUID
+    article_id: uuid.UUID
+    title: Optional[str] = None
+    state: str
+    opens_at: datetime
+    closes_at: datetime
+    closed_at: Optional[datetime] = None
+    questions: List[QAQuestionResponse] = Field(default_factory=list)
+
+
+class CommentCreate(BaseModel):
+    content: str = Field(..., min_length=1, max_length=5000)
+    parent_comment_id: Optional[uuid.UUID] = None
+    is_anonymous: bool = False
+
+
+class CommentResponse(BaseModel):
+    id: uuid.UUID
+    parent_comment_id: Optional[uuid.UUID] = None
+    author: Optional[str] = None
+    content: str
+    like_count: int = 0
+    created_at: datetime
+    replies: List['CommentResponse'] = Field(default_factory=list)
+
+
+class DiscussionResponse(BaseResponse):
+    article_id: uuid.UUID
+    comments: List[CommentResponse] = Field(default_factory=list)
+    comment_count: int = 0
+    qa_session: Optional[QASessionResponse] = None
+    qa_highlights: List[QAQuestionResponse] = Field(default_factory=list)
+
+
+# Correction models
+class CorrectionCreate(BaseModel):
+    article_id: uuid.UUID
+    kind: str = Field(default='typo', pattern=r'^(typo|factual|clarity|other)$')
+    passage: str = Field(..., min_length=1, max_length=2000)
+    suggestion: str = Field(..., max_length=4000)
+    note: Optional[str] = Field(None, max_length=1000)
+
+    @model_validator(mode='after')
+    def validate_change(self):
+        if self.passage == self.suggestion:
+            raise ValueError('Suggestion must differ from the passage')
+        return self
+
+
+class CorrectionReview(BaseModel):
+    replacement: Optional[str] = Field(None, max_length=4000)  # Reviewer's edit of the suggested text
+    note: Optional[str] = Field(None, max_length=1000)
+
+
+class CorrectionResponse(BaseModel):
+    id: uuid.UUID
+    article_id: uuid.UUID
+    article_title: Optional[str] = None
+    submitted_by: Optional[uuid.UUID] = None
+    contributor: Optional[str] = None
+    kind: str
+    passage: str
+    suggestion: str
+    note: Optional[str] = None
+    status: str
+    reviewed_at: Optional[datetime] = None
+    review_note: Optional[str] = None
+    revision_id: Optional[uuid.UUID] = None
+    created_at: datetime
+
+
+class ArticleRevisionResponse(BaseModel):
+    id: uuid.UUID
+    revision_number: int
+    title: str
+    summary: Optional[str] = None
+    content: Optional[str] = None
+    edited_by: Optional[uuid.UUID] = None
+    change_note: Optional[str] = None
+    correction_id: Optional[uuid.UUID] = None
+    created_at: datetime
+
+
+# Fact check models
+class FactCheckVerdict(str, Enum):
+    TRUE = "true"
+    FALSE = "false"
+    MISLEADING = "misleading"
+
+
+class FactCheckSource(BaseModel):
+    url: str = Field(..., min_length=1, max_length=2000, pattern=r'^https?://')
+    title: Optional[str] = Field(None, max_length=300)
+
+
+class FactCheckCreate(BaseModel):
+    article_id: uuid.UUID
+    paragraph: int = Field(..., ge=0)
+    start_offset: int = Field(..., ge=0)
+    end_offset: int = Field(..., gt=0)
+    claim: str = Field(..., min_length=1, max_length=2000)
+    verdict: FactCheckVerdict
+    sources: List[FactCheckSource] = Field(default_factory=list, max_length=20)
+    note: Optional[str] = Field(None, max_length=4000)
+
+    @model_validator(mode='after')
+    def validate_range(self):
+        if self.end_offset <= self.start_offset:
+            raise ValueError('end_offset must be after start_offset')
+        return self
+
+
+class FactCheckUpdate(BaseModel):
+    claim: Optional[str] = Field(None, min_length=1, max_length=2000)
+    verdict: Optional[FactCheckVerdict] = None
+    sources: Optional[List[FactCheckSource]] = Field(None, max_length=20)
+    note: Optional[str] = Field(None, max_length=4000)
+
+
+class FactCheckResponse(BaseModel):
+    id: uuid.UUID
+    article_id: uuid.UUID
+    auditor_id: Optional[uuid.UUID] = None
+    auditor: Optional[str] = None
+    paragraph: int
+    start_offset: int
+    end_offset: int
+    quote: str
+    claim: str
+    verdict: FactCheckVerdict
+    sources: List[FactCheckSource] = Field(default_factory=list)
+    note: Optional[str] = None
+    stale: bool = False  # The checked text has since been edited
+    created_at: datetime
+    updated_at: Optional[datetime] = None
+
+
+# Governance models
+class ProposalKind(str, Enum):
+    CATEGORY_ADDITION = "category_addition"
+    MODERATION_POLICY = "moderation_policy"
+    FEATURE = "feature"
+    OTHER = "other"
+
+
+class VoteChoice(str, Enum):
+    YES = "yes"
+    NO = "no"
+    ABSTAIN = "abstain"
+
+
+class ProposalCreate(BaseModel):
+    kind: ProposalKind
+    title: str = Field(..., min_length=5, max_length=200)
+    description: str = Field(..., min_length=20, max_length=20000)
+    details: Dict[str, Any] = Field(default_factory=dict)  # e.g. {"category": "climate"}
+    voting_days: Optional[float] = Field(None, gt=0, le=365)  # Clamped to the configured bounds
+
+    @model_validator(mode='after')
+    def validate_details(self):
+        if self.kind == ProposalKind.CATEGORY_ADDITION:
+            category = str(self.details.get('category') or '').strip().lower()
+            if not category or len(category) > 100:
+                raise ValueError("A category addition needs details.category (up to 100 characters)")
+            self.details['category'] = category
+        if len(json.dumps(self.details)) > 10000:
+            raise ValueError("details is too large")
+        return self
+
+
+class VoteCast(BaseModel):
+    choice: VoteChoice
+
+
+class GovernanceProposalResponse(BaseModel):
+    id: uuid.UUID
+    proposer_id: Optional[uuid.UUID] = None
+    proposer: Optional[str] = None
+    kind: str
+    title: str
+    description: str
+    details: Dict[str, Any] = Field(default_factory=dict)
+    status: str
+    voting_starts_at: datetime
+    voting_ends_at: datetime
+    quorum: int
+    pass_threshold: float
+    votes: Dict[str, int] = Field(default_factory=dict)  # Live while open, final once tallied
+    my_vote: Optional[str] = None
+    tallied_at: Optional[datetime] = None
+    enacted_at: Optional[datetime] = None
+    votes_root: Optional[str] = None
+    chain_status: Optional[str] = None
+    tx_hash: Optional[str] = None
+    block_number: Optional[int] = None
+    mirrored_at: Optional[datetime] = None
+    created_at: datetime
+
+
+# Draft comment models
+class DraftAnchor(BaseModel):
+    field: str = Field(default='content', pattern=r'^(title|summary|content)$')
+    start: int = Field(..., ge=0)
+    end: int = Field(..., ge=0)
+    quote: str = Field(..., min_length=1, max_length=2000)  # The text the range held when commenting
+
+    @model_validator(mode='after')
+    def validate_range(self):
+        if self.end - self.start != len(self.quote):
+            raise ValueError('Range length must match the quoted text')
+        return self
+
+
+class DraftCommentCreate(BaseModel):
+    anchor: DraftAnchor
+    body: str = Field(..., min_length=1, max_length=2000)
+    suggestion: Optional[str] = Field(None, max_length=4000)  # Replacement text, making this a suggestion
+
+
+class DraftCommentReply(BaseModel):
+    body: str = Field(..., min_length=1, max_length=2000)
+
+
+# Proofreading models
+class ProofreadRequest(BaseModel):
+    fields: List[str] = Field(default_factory=lambda: ['title', 'summary', 'content'], min_length=1)
+    language: Optional[str] = Field(None, max_length=10)  # Defaults to the article's language
+    apply_fixes: bool = False  # Apply the first suggestion of each issue to the draft
+    rule_ids: Optional[List[str]] = None  # Only fix issues raised by these rules
+
+    @model_validator(mode='after')
+    def validate_fields(self):
+        unknown = set(self.fields) - {'title', 'summary', 'content'}
+        if unknown:
+            raise ValueError(f"Unknown fields: {', '.join(sorted(unknown))}")
+        return self
+
+
+# Author signing key models
+class AuthorKeyCreate(BaseModel):
+    algorithm: str = Field(..., pattern="^(ed25519|secp256k1)$")
+    public_key: str = Field(..., min_length=1, max_length=500)  # Base64 or 0x-prefixed hex
+    label: Optional[str] = Field(None, max_length=100)
+
+
+class AuthorKeyResponse(BaseModel):
+    id: uuid.UUID
+    algorithm: str
+    public_key: str
+    fingerprint: str
+    label: Optional[str] = None
+    created_at: datetime
+    revoked_at: Optional[datetime] = None
+
+
+# Push notification models
+class WebPushKeys(BaseModel):
+    p256dh: str = Field(..., min_length=1, max_length=200)
+    auth: str = Field(..., min_length=1, max_length=100)
+
+
+class WebPushSubscription(BaseModel):
+    """A browser's PushSubscription, as returned by `pushManager.subscribe()` and its `toJSON()`"""
+    endpoint: str = Field(..., pattern=r'^https://', max_length=2000)
+    keys: WebPushKeys
+
+
+class PushDeviceCreate(BaseModel):
+    platform: str = Field(..., pattern="^(webpush|fcm)$")
+    token: Optional[str] = Sensitive(None, min_length=1, max_length=4096)  # FCM registration token
+    subscription: Optional[WebPushSubscription] = None  # Web Push
+    label: Optional[str] = Field(None, max_length=100)  # e.g. "Pixel 8" or "Firefox on laptop"
+
+    @model_validator(mode='after')
+    def check_platform(self):
+        if self.platform == 'fcm' and not self.token:
+            raise ValueError("FCM devices need a registration token")
+        if self.platform == 'webpush' and not self.subscription:
+            raise ValueError("Web Push devices need a push subscription")
+        return self
+
+
+class PushDeviceResponse(BaseModel):
+    id: uuid.UUID
+    platform: str
+    label: Optional[str] = None
+    failure_count: int = 0
+    last_success_at: Optional[datetime] = None
+    last_seen_at: Optional[datetime] = None
+    created_at: datetime
+
+
+# Media upload models
+class MediaUploadResponse(BaseModel):
+    id: uuid.UUID
+    article_id: Optional[uuid.UUID] = None
+    visibility: str
+    filename: Optional[str] = None
+    content_type: str
+    width: int
+    height: int
+    size: int
+    alt_text: Optional[str] = None
+    urls: Dict[str, str]  # Variant name to URL; signed and expiring for private uploads
+    created_at: datetime
+    published_at: Optional[datetime] = None
+
+
+# Newsletter models
+class NewsletterSubscriptionUpdate(BaseModel):
+    frequency: str = Field(..., pattern="^(daily|weekly)$")
+
+
+# Experiment models
+class HeadlineTestCreate(BaseModel):
+    headlines: List[str] = Field(..., min_length=1, max_length=3)  # Alternatives tested against the current title
+
+    @model_validator(mode='after')
+    def validate_headlines(self):
+        self.headlines = [headline.strip() for headline in self.headlines]
+        if any(not headline or len(headline) > 500 for headline in self.headlines):
+            raise ValueError("Headlines must be between 1 and 500 characters")
+        if len({headline.lower() for headline in self.headlines}) != len(self.headlines):
+            raise ValueError("Headlines must differ from each other")
+        return self
+
+
+class ThumbnailTestCreate(BaseModel):
+    images: List[str] = Field(..., min_length=1, max_length=3)  # Alternative image URLs tested against the current thumbnail
+
+    @model_validator(mode='after')
+    def validate_images(self):
+        self.images = [image.strip() for image in self.images]
+        if any(not re.match(r'^https?://\S+$', image) or len(image) > 1000 for image in self.images):
+            raise ValueError("Images must be http(s) URLs of at most 1000 characters")
+        if len(set(self.images)) != len(self.images):
+            raise ValueError("Images must differ from each other")
+        return self
+
+
+class ExperimentResultResponse(BaseModel):
+    variant: str
+    value: str
+    impressions: int
+    conversions: int
+    rate: float
+
+
+class ExperimentResponse(BaseModel):
+    id: uuid.UUID
+    article_id: uuid.UUID
+    kind: str
+    status: str
+    winner: Optional[str] = None
+    decision: Optional[Dict[str, Any]] = None
+    started_at: datetime
+    concluded_at: Optional[datetime] = None
+    results: List[ExperimentResultResponse] = Field(default_factory=list)
+
+
+# Background job models
+class JobEnqueueRequest(BaseModel):
+    job: str
+    args: List[Any] = Field(default_factory=list)
+    countdown: int = Field(0, ge=0, le=86400)
+
+
+# OAuth models
+class OAuthClientCreate(BaseModel):
+    name: str = Field(..., min_length=1, max_length=200)
+    description: Optional[str] = Field(None, max_length=1000)
+    website_url: Optional[str] = Field(None, max_length=500)
+    redirect_uris: List[str] = Field(..., min_length=1, max_length=10)
+    scopes: List[str] = Field(..., min_length=1)
+
+
+class OAuthClientResponse(BaseModel):
+    id: uuid.UUID
+    client_id: str
+    name: str
+    description: Optional[str] = None
+    website_url: Optional[str] = None
+    redirect_uris: List[str]
+    allowed_scopes: List[str]
+    is_active: bool
+    created_at: datetime
+    client_secret: Optional[str] = Sensitive(None)  # Only returned when the client is registered
+
+
+class OAuthConsentDecision(BaseModel):
+    client_id: str
+    redirect_uri: str
+    scope: Optional[str] = None
+    state: Optional[str] = None
+    code_challenge: Optional[str] = Field(None, max_length=128)
+    code_challenge_method: Optional[str] = None
+    approve: bool
+
+
+class OAuthGrantResponse(BaseModel):
+    client_id: str
+    name: str
+    website_url: Optional[str] = None
+    scopes: List[str]
+    created_at: datetime
+    updated_at: datetime
+
+
+# API key models
+class ApiKeyCreate(BaseModel):
+    name: str = Field(..., min_length=1, max_length=200)
+    description: Optional[str] = Field(None, max_length=1000)
+    scopes: List[str] = Field(..., min_length=1)
+    expires_at: Optional[datetime] = None
+
+
+class CrawlerPolicy(BaseModel):
+    crawl_delay_seconds: Optional[float] = Field(None, gt=0, le=3600)  # Minimum interval between requests
+    excluded_sections: List[str] = Field(default_factory=list)  # Categories the key doesn't receive
+
+    @field_validator('excluded_sections')
+    @classmethod
+    def normalize_sections(cls, values: List[str]) -> List[str]:
+        return sorted({value.strip().lower() for value in values if value.strip()})
+
+
+class ApiKeyRotate(BaseModel):
+    grace_period_seconds: int = Field(86400, ge=0, le=30 * 86400)  # How long the old key keeps working
+
+
+class ApiKeyResponse(BaseModel):
+    id: uuid.UUID
+    name: str
+    description: Optional[str] = None
+    key_prefix: str
+    scopes: List[str]
+    is_active: bool
+    expires_at: Optional[datetime] = None
+    rotated_from: Optional[uuid.UUID] = None
+    last_used_at: Optional[datetime] = None
+    last_used_ip: Optional[str] = Sensitive(None, kind='ip')
+    created_by: Optional[uuid.UUID] = None
+    created_at: datetime
+    crawl_delay_seconds: Optional[float] = None
+    excluded_sections: List[str] = Field(default_factory=list)
+    api_key: Optional[str] = Sensitive(None)  # Only returned when a key is issued
+
+
+# Ingestion models
+class IngestArticle(BaseModel):
+    url: str = Field(..., max_length=1000, pattern=r'^https?://')
+    canonical_url: Optional[str] = Field(None, max_length=1000, pattern=r'^https?://')  # From the page's rel=canonical
+    title: str = Field(..., min_length=1, max_length=500)
+    content: str = Field(..., min_length=1)
+    summary: Optional[str] = Field(None, max_length=1000)
+    category: Optional[str] = Field(None, max_length=100)
+    tags: List[str] = Field(default_factory=list, max_length=30)
+    language: Optional[str] = Field(None, max_length=50)
+    published_at: Optional[datetime] = None
+    author_name: Optional[str] = Field(None, max_length=200)
+    source_name: Optional[str] = Field(None, max_length=200)
+    external_id: Optional[str] = Field(None, max_length=255)
+    license: Optional[str] = Field(None, max_length=50)
+    image_urls: List[str] = Field(default_factory=list, max_length=20)
+
+
+class IngestBatch(BaseModel):
+    articles: List[IngestArticle] = Field(..., min_length=1, max_length=100)
+
+
+# Federation models
+class FederationPeerCreate(BaseModel):
+    domain: str = Field(..., min_length=1, max_length=255)
+    base_url: str = Field(..., max_length=500)
+    name: Optional[str] = Field(None, max_length=200)
+    refresh_interval_minutes: int = Field(60, ge=5, le=7 * 24 * 60)
+    retention_days: Optional[int] = Field(None, ge=1)  # None keeps content while the origin lists it
+
+
+class FederationPeerUpdate(BaseModel):
+    base_url: Optional[str] = Field(None, max_length=500)
+    name: Optional[str] = Field(None, max_length=200)
+    is_active: Optional[bool] = None
+    refresh_interval_minutes: Optional[int] = Field(None, ge=5, le=7 * 24 * 60)
+    retention_days: Optional[int] = Field(None, ge=1)
+    reputation_override: Optional[str] = Field(None, pattern=r'^(normal|throttled|paused)$')  # null follows the score
+
+
+class FederationPeerResponse(BaseModel):
+    id: uuid.UUID
+    domain: str
+    base_url: str
+    name: Optional[str] = None
+    is_active: bool
+    refresh_interval_minutes: int
+    retention_days: Optional[int] = None
+    last_synced_at: Optional[datetime] = None
+    last_sync_error: Optional[str] = None
+    reputation_score: float = 1.0
+    ingestion_state: str = 'normal'
+    reputation_override: Optional[str] = None
+    article_count: int = 0
+    created_at: datetime
+
+
+class SpamReportCreate(BaseModel):
+    reason: Optional[str] = Field(None, max_length=1000)
+
+
+class FederationTombstoneResponse(BaseModel):
+    id: uuid.UUID
+    peer_domain: str
+    remote_id: str
+    origin_url: Optional[str] = None
+    title: Optional[str] = None
+    reason: str
+    detail: Optional[str] = None
+    removed_at: datetime
+
+
+# Feed import models
+class FeedSourceCreate(BaseModel):
+    url: str = Field(..., max_length=1000, pattern=r'^https?://')
+    name: Optional[str] = Field(None, max_length=200)
+    author_id: Optional[uuid.UUID] = None  # Owner of the imported drafts; defaults to the admin adding the feed
+    category: str = Field('general', max_length=100)
+    language: Optional[str] = Field(None, max_length=10)  # None takes the feed's declared language
+    refresh_interval_minutes: int = Field(60, ge=5, le=7 * 24 * 60)
+
+
+class FeedSourceUpdate(BaseModel):
+    url: Optional[str] = Field(None, max_length=1000, pattern=r'^https?://')
+    name: Optional[str] = Field(None, max_length=200)
+    author_id: Optional[uuid.UUID] = None
+    category: Optional[str] = Field(None, max_length=100)
+    language: Optional[str] = Field(None, max_length=10)
+    is_active: Optional[bool] = None
+    refresh_interval_minutes: Optional[int] = Field(None, ge=5, le=7 * 24 * 60)
+
+
+class FeedSourceResponse(BaseModel):
+    id: uuid.UUID
+    url: str
+    name: Optional[str] = None
+    author_id: Optional[uuid.UUID] = None
+    category: Optional[str] = None
+    language: Optional[str] = None
+    is_active: bool
+    refresh_interval_minutes: int
+    health: str
+    last_fetched_at: Optional[datetime] = None
+    last_success_at: Optional[datetime] = None
+    last_status_code: Optional[int] = None
+    last_error: Optional[str] = None
+    consecutive_failures: int = 0
+    items_imported: int = 0
+    created_at: datetime
+
+
+# Organization and branding models
+class OrganizationCreate(BaseModel):
+    slug: str = Field(..., pattern=r'^[a-z0-9]+(?:-[a-z0-9]+)*$', max_length=100)
+    name: str = Field(..., min_length=1, max_length=200)
+    custom_domain: Optional[str] = Field(None, max_length=255)
+
+
+class OrganizationUpdate(BaseModel):
+    name: Optional[str] = Field(None, min_length=1, max_length=200)
+    custom_domain: Optional[str] = Field(None, max_length=255)
+    is_active: Optional[bool] = None
+
+
+class OrganizationResponse(BaseModel):
+    id: uuid.UUID
+    slug: str
+    name: str
+    custom_domain: Optional[str] = None
+    is_active: bool
+    created_at: datetime
+    updated_at: datetime
+
+
+class BrandingColors(BaseModel):
+    primary: Optional[str] = Field(None, pattern=r'^#[0-9a-fA-F]{6}$')
+    secondary: Optional[str] = Field(None, pattern=r'^#[0-9a-fA-F]{6}$')
+    accent: Optional[str] = Field(None, pattern=r'^#[0-9a-fA-F]{6}$')
+    background: Optional[str] = Field(None, pattern=r'^#[0-9a-fA-F]{6}$')
+    text: Optional[str] = Field(None, pattern=r'^#[0-9a-fA-F]{6}$')
+
+
+class FooterLink(BaseModel):
+    label: str = Field(..., min_length=1, max_length=80)
+    url: str = Field(..., pattern=r'^(https?://|/)', max_length=500)
+
+
+class BrandingUpdate(BaseModel):
+    logo_url: Optional[str] = Field(None, pattern=r'^https://', max_length=500)
+    favicon_url: Optional[str] = Field(None, pattern=r'^https://', max_length=500)
+    colors: BrandingColors = Field(default_factory=BrandingColors)
+    footer_links: List[FooterLink] = Field(default_factory=list, max_length=20)
+
+
+class BrandingResponse(BaseModel):
+    organization: Optional[Dict[str, Any]] = None  # None for the platform's default branding
+    publication: Optional[Dict[str, Any]] = None  # The tenant whose domain it is, when tenancy is enabled
+    logo_url: Optional[str] = None
+    favicon_url: Optional[str] = None
+    colors: Dict[str, Any] = Field(default_factory=dict)
+    footer_links: List[Dict[str, Any]] = Field(default_factory=list)
+    updated_at: Optional[datetime] = None
+
+
+# Tenant models
+class TenantQuotas(BaseModel):
+    max_users: Optional[int] = Field(None, ge=0)  # None for no limit
+    max_articles: Optional[int] = Field(None, ge=0)  # Drafts included, archived articles not
+    requests_per_minute: Optional[int] = Field(None, ge=1)
+
+
+class TenantCreate(BaseModel):
+    slug: str = Field(..., pattern=r'^[a-z0-9]+(?:-[a-z0-9]+)*$', max_length=50)
+    name: str = Field(..., min_length=1, max_length=200)
+    domains: List[str] = Field(default_factory=list, max_length=20)
+    quotas: TenantQuotas = Field(default_factory=TenantQuotas)
+    branding: BrandingUpdate = Field(default_factory=BrandingUpdate)
+
+
+class TenantUpdate(BaseModel):
+    name: Optional[str] = Field(None, min_length=1, max_length=200)
+    domains: Optional[List[str]] = Field(None, max_length=20)
+    quotas: Optional[TenantQuotas] = None
+    branding: Optional[BrandingUpdate] = None
+    is_active: Optional[bool] = None
+
+
+class TenantResponse(BaseModel):
+    id: uuid.UUID
+    slug: str
+    name: str
+    domains: List[str] = Field(default_factory=list)
+    quotas: Dict[str, Any] = Field(default_factory=dict)
+    branding: Dict[str, Any] = Field(default_factory=dict)
+    is_active: bool
+    usage: Optional[Dict[str, int]] = None
+    created_at: datetime
+    updated_at: datetime
+
+
+class TenantIsolationResponse(BaseModel):
+    isolated: bool
+    database_role: str
+    problems: List[str] = Field(default_factory=list)
+
+
+# Event replay models
+class ReplayCreate(BaseModel):
+    projection: str
+    event_types: Optional[List[str]] = None  # The projection's event types by default
+    since: Optional[datetime] = None
+    until: Optional[datetime] = None  # Now by default
+    rate_per_second: Optional[float] = Field(None, gt=0)  # None for no limit
+    batch_size: Optional[int] = Field(None, ge=1, le=10000)
+    reset: bool = False  # Clear the projection's model first
+
+
+class ReplayUpdate(BaseModel):
+    rate_per_second: Optional[float] = Field(None, gt=0)
+    batch_size: Optional[int] = Field(None, ge=1, le=10000)
+
+
+class ReplayResponse(BaseModel):
+    id: uuid.UUID
+    projection: str
+    event_types: List[str]
+    since_at: Optional[datetime] = None
+    until_at: datetime
+    reset: bool
+    rate_per_second: Optional[float] = None
+    batch_size: int
+    status: str
+    checkpoint_at: Optional[datetime] = None
+    checkpoint_id: Optional[uuid.UUID] = None
+    total_events: int
+    processed_events: int
+    changed_events: int
+    percent: float
+    events_per_second: Optional[float] = None
+    eta_seconds: Optional[int] = None
+    last_error: Optional[str] = None
+    requested_by: Optional[uuid.UUID] = None
+    created_at: datetime
+    started_at: Optional[datetime] = None
+    updated_at: datetime
+    finished_at: Optional[datetime] = None
+
+
+# Admin audit log models
+class AuditEntryResponse(BaseModel):
+    sequence: int
+    actor_id: Optional[uuid.UUID] = None  # None for changes made by the system
+    actor_username: Optional[str] = None
+    actor_role: Optional[str] = None
+    action: str
+    resource_type: str
+    resource_id: Optional[str] = None
+    before: Optional[Any] = None
+    after: Optional[Any] = None
+    ip_address: Optional[str] = None
+    user_agent: Optional[str] = None
+    tenant_id: Optional[uuid.UUID] = None
+    previous_hash: str
+    entry_hash: str
+    created_at: datetime
+
+
+class AuditPageResponse(BaseResponse):
+    entries: List[AuditEntryResponse]
+    next_before: Optional[int] = None  # Pass as `before` for older entries
+
+
+class AuditVerificationResponse(BaseResponse):
+    valid: bool
+    checked: int
+    last_sequence: Optional[int] = None
+    last_hash: Optional[str] = None  # Note it down: entries removed after it can't be detected otherwise
+    broken_at: Optional[int] = None
+    problem: Optional[str] = None
+
+
+# Permission models
+class UserPermissionUpdate(BaseModel):
+    granted: bool  # False revokes a permission the user's role gives them
+
+
+class PermissionOverride(BaseModel):
+    permission: str
+    granted: bool
+    granted_by: Optional[uuid.UUID] = None
+    created_at: datetime
+
+
+class UserPermissionsResponse(BaseModel):
+    user_id: uuid.UUID
+    username: str
+    role: str
+    publication_role: Optional[str] = None  # editor or contributor of the user's publication
+    overrides: List[PermissionOverride]
+    permissions: List[str]  # What the user holds in the end
+
+
+# Publication member models
+class MemberRoleUpdate(BaseModel):
+    role: str = Field(..., pattern=r'^(editor|contributor)$')
+
+
+class MemberResponse(BaseModel):
+    id: uuid.UUID
+    username: str
+    email: str = Sensitive(kind='email')
+    role: str
+    publication_role: str
+    created_at: datetime
+    last_active: Optional[datetime] = None
+
+
+class InvitationCreate(BaseModel):
+    email: EmailStr = Sensitive(kind='email')
+    role: str = Field(..., pattern=r'^(editor|contributor)$')
+
+
+class InvitationAccept(BaseModel):
+    token: str = Sensitive(min_length=1, max_length=200)
+
+
+class InvitationResponse(BaseModel):
+    id: uuid.UUID
+    email: str = Sensitive(kind='email')
+    role: str
+    invited_by: Optional[uuid.UUID] = None
+    expires_at: datetime
+    accepted_by: Optional[uuid.UUID] = None
+    accepted_at: Optional[datetime] = None
+    revoked_at: Optional[datetime] = None
+    created_at: datetime
+
+
+# Editorial workflow models
+class EditorialSubmission(BaseModel):
+    note: Optional[str] = Field(None, max_length=2000)  # For the editors
+
+
+class EditorialAssignment(BaseModel):
+    assignee_id: Optional[uuid.UUID] = None  # Defaults to whoever assigns
+
+
+class EditorialChangesRequest(BaseModel):
+    note: Optional[str] = Field(None, max_length=2000)
+    comments: List[DraftCommentCreate] = Field(default_factory=list, max_length=50)  # Inline, on the draft
+
+
+class EditorialDecision(BaseModel):
+    note: Optional[str] = Field(None, max_length=2000)
+
+
+class EditorialApproval(EditorialDecision):
+    publish: bool = False  # Publish the draft now, rather than leaving it to the author
+
+
+class EditorialReviewResponse(BaseModel):
+    id: uuid.UUID
+    article_id: uuid.UUID
+    article_title: Optional[str] = None
+    author_id: Optional[uuid.UUID] = None
+    author_username: Optional[str] = None
+    submitted_by: Optional[uuid.UUID] = None
+    assigned_to: Optional[uuid.UUID] = None
+    assignee_username: Optional[str] = None
+    status: str
+    note: Optional[str] = None
+    decided_by: Optional[uuid.UUID] = None
+    decision_note: Optional[str] = None
+    decided_at: Optional[datetime] = None
+    created_at: datetime
+    updated_at: datetime
+
+
+class EditorialEventResponse(BaseModel):
+    id: uuid.UUID
+    review_id: uuid.UUID
+    article_id: uuid.UUID
+    actor_id: Optional[uuid.UUID] = None
+    actor_username: Optional[str] = None
+    action: str
+    from_status: Optional[str] = None
+    to_status: str
+    assigned_to: Optional[uuid.UUID] = None
+    assignee_username: Optional[str] = None
+    note: Optional[str] = None
+    comment_ids: List[str] = Field(default_factory=list)
+    created_at: datetime
+
+
+class EditorialConfig(BaseModel):
+    require_approval: bool = False
+
+
+# Co-authorship models
+class ArticleAuthorInvite(BaseModel):
+    user_id: uuid.UUID
+    role: str = Field('contributor', pattern=r'^(contributor|translator)$')
+
+
+class ArticleAuthorUpdate(BaseModel):
+    role: str = Field(..., pattern=r'^(contributor|translator)$')
+
+
+class ArticleAuthorResponse(BaseModel):
+    article_id: uuid.UUID
+    user_id: uuid.UUID
+    username: str
+    display_name: Optional[str] = None
+    role: str  # lead, contributor or translator
+    status: str  # invited, accepted or declined
+    position: int = 0
+    invited_by: Optional[uuid.UUID] = None
+    responded_at: Optional[datetime] = None
+    created_at: datetime
+
+
+class CoauthorInvitationResponse(ArticleAuthorResponse):
+    article_title: str
+    article_status: str
+    invited_by_username: Optional[str] = None
+
+
+# Event dead letter models
+class DeadLetterRetry(BaseModel):
+    payload: Optional[Dict[str, Any]] = None  # An edited payload to retry with, keeping the event's id
+
+
+class DeadLetterDiscard(BaseModel):
+    note: Optional[str] = Field(None, max_length=1000)
+
+
+class DeadLetterResponse(BaseModel):
+    id: uuid.UUID
+    consumer: str
+    event_id: uuid.UUID
+    event_type: str
+    aggregate_id: Optional[str] = None
+    payload: Any  # Not always an object: a malformed payload is quarantined as it was
+    original_payload: Optional[Any] = None  # The payload as quarantined, once it has been edited
+    error: str
+    status: str
+    retry_count: int
+    resolved_by: Optional[uuid.UUID] = None
+    resolution_note: Optional[str] = None
+    created_at: datetime
+    updated_at: datetime
+    resolved_at: Optional[datetime] = None
+
+
 # Health check model
 class HealthResponse(BaseModel):
     status: str = "healthy"
     timestamp: datetime = Field(default_factory=datetime.now)
     services: Dict[str, str] = Field(default_factory=dict)
+    modules: Dict[str, bool] = Field(default_factory=dict)
     version: str = "1.0.0"
\ No newline at end of file
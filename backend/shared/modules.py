@@ -0,0 +1,158 @@
+"""
+Optional subsystems a deployment can switch off
+
+Each module lists the FastAPI routers it mounts, the scheduled jobs it runs
+and the backing services only it needs. A disabled module's routes are not
+mounted (they 404), its beat entries are dropped and /health stops checking
+its services, so a node without MongoDB or an Ethereum RPC runs with
+analytics, collaboration or anchoring off and still reports healthy.
+
+A module is switched with its own variable (e.g. ANALYTICS_ENABLED=false);
+MODULES_DISABLED=analytics,newsletters turns several off at once. Anchoring,
+archival, the sandbox and tenancy stay off unless ANCHOR_ENABLED,
+ARCHIVE_ENABLED, SANDBOX_ENABLED or TENANCY_ENABLED is set.
+"""
+
+import os
+import logging
+from dataclasses import dataclass, field
+from typing import Callable, Dict, List, Optional, Tuple
+
+logger = logging.getLogger(__name__)
+
+CORE_SERVICES = ('postgresql', 'redis')
+
+
+@dataclass(frozen=True)
+class Module:
+    name: str
+    description: str
+    env: str
+    default: bool = True
+    routers: Tuple[str, ...] = ()
+    jobs: Tuple[str, ...] = ()
+    services: Tuple[str, ...] = ()
+    health: Optional[Callable[[], Dict[str, Callable[[], None]]]] = field(default=None, compare=False)
+
+
+def _anchoring_health() -> Dict[str, Callable[[], None]]:
+    from shared.anchoring import health
+    return {'anchor_rpc': health}
+
+
+def _archival_health() -> Dict[str, Callable[[], None]]:
+    from shared.archival import health_checks
+    return health_checks()
+
+
+MODULES: Dict[str, Module] = {module.name: module for module in [
+    Module(
+        'analytics', "Admin statistics, trends, cohorts, funnels, telemetry and warehouse export",
+        env='ANALYTICS_ENABLED',
+        routers=('analytics', 'events', 'cohorts', 'funnels', 'warehouse'),
+        jobs=('roll-up-trends', 'roll-up-cohorts', 'compute-funnels', 'export-warehouse'),
+        services=('mongodb',),
+    ),
+    Module(
+        'collaboration', "Real-time draft editing and inline draft comments",
+        env='COLLABORATION_ENABLED',
+        routers=('collab', 'draft_comments'),
+        jobs=('compact-draft-documents',),
+        services=('mongodb',),
+    ),
+    Module(
+        'federation', "Peer node sync and ActivityPub",
+        env='FEDERATION_ENABLED',
+        routers=('federation', 'activitypub'),
+        jobs=('sync-federation-peers',),
+    ),
+    Module(
+        'push', "Web Push and Firebase Cloud Messaging delivery of notifications",
+        env='PUSH_ENABLED',
+        routers=('push',),
+        jobs=('prune-push-devices',),
+    ),
+    Module(
+        'newsletters', "Daily and weekly email digests",
+        env='NEWSLETTERS_ENABLED',
+        routers=('newsletters',),
+        jobs=('send-newsletter-digests',),
+    ),
+    Module(
+        'anchoring', "Merkle anchoring of published articles on-chain",
+        env='ANCHOR_ENABLED', default=False,
+        jobs=('anchor-articles',),
+        health=_anchoring_health,
+    ),
+    Module(
+        'archival', "Archival of published articles to Arweave and Filecoin",
+        env='ARCHIVE_ENABLED', default=False,
+        jobs=('archive-articles',),
+        health=_archival_health,
+    ),
+    Module(
+        'sandbox', "An isolated tenant with fake data, reset nightly, for integrators holding a sandbox key",
+        env='SANDBOX_ENABLED', default=False,
+        routers=('sandbox',),
+        jobs=('reset-sandbox',),
+    ),
+    Module(
+        'tenancy', "Several publications on one deployment, each with its own domains, users, branding and quotas",
+        env='TENANCY_ENABLED', default=False,
+        routers=('tenants',),
+    ),
+]}
+
+
+def _disabled_by_list() -> List[str]:
+    return [name.strip().lower() for name in os.getenv('MODULES_DISABLED', '').split(',') if name.strip()]
+
+
+def is_enabled(name: str) -> bool:
+    """Whether module `name` is on; names that aren't modules are part of the core and always are"""
+    module = MODULES.get(name)
+    if module is None:
+        return True
+    if name in _disabled_by_list():
+        return False
+    return os.getenv(module.env, str(module.default)).lower() == 'true'
+
+
+def enabled_modules() -> List[Module]:
+    return [module for module in MODULES.values() if is_enabled(module.name)]
+
+
+def router_enabled(router: str) -> bool:
+    """False for a router that belongs to a disabled module"""
+    return all(is_enabled(module.name) for module in MODULES.values() if router in module.routers)
+
+
+def job_enabled(entry: str) -> bool:
+    """False for a beat schedule entry that belongs to a disabled module"""
+    return all(is_enabled(module.name) for module in MODULES.values() if entry in module.jobs)
+
+
+def required_services() -> List[str]:
+    """Backing services the core and the enabled modules depend on"""
+    services = list(CORE_SERVICES)
+    for module in enabled_modules():
+        services.extend(service for service in module.services if service not in services)
+    return services
+
+
+def health_checks() -> Dict[str, Callable[[], None]]:
+    """Extra health check callables contributed by enabled modules"""
+    checks = {}
+    for module in enabled_modules():
+        if module.health:
+            checks.update(module.health())
+    return checks
+
+
+def summary() -> Dict[str, bool]:
+    return {name: is_enabled(name) for name in MODULES}
+
+
+unknown = set(_disabled_by_list()) - set(MODULES)
+if unknown:
+    logger.warning(f"MODULES_DISABLED names unknown modules: {', '.join(sorted(unknown))}")
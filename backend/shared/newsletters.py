@@ -0,0 +1,218 @@
+"""
+Email digest newsletters
+
+Users opt in to a daily or weekly digest. Every NEWSLETTER_POLL_SECONDS the
+scheduler finds subscribers whose digest is due, at or after
+NEWSLETTER_SEND_HOUR (UTC) and, for weekly digests, on
+NEWSLETTER_WEEKLY_DAY, and queues one `jobs.send_newsletter_digest` per
+user. A digest holds the period's top trending articles and what the
+authors the user follows published in it; an empty one isn't sent.
+
+Digests are rendered from the templates in shared/templates and sent with
+`jobs.send_email`, so they go through the configured email provider. Each
+carries a signed unsubscribe link, and List-Unsubscribe headers for
+one-click unsubscribing from the mail client.
+"""
+
+import os
+import hmac
+import base64
+import hashlib
+import logging
+from datetime import datetime, timedelta, timezone
+from typing import Any, Dict, List, Optional
+
+from jinja2 import Environment, FileSystemLoader, select_autoescape
+
+from shared.feed_formats import PUBLIC_BASE_URL, article_url
+
+logger = logging.getLogger(__name__)
+
+DAILY = 'daily'
+WEEKLY = 'weekly'
+PERIODS = {DAILY: timedelta(days=1), WEEKLY: timedelta(days=7)}
+# A digest is due this long before a full period has passed, so send times don't drift later each run
+DUE_SLACK = timedelta(hours=2)
+
+SEND_HOUR = int(os.getenv('NEWSLETTER_SEND_HOUR', 7))
+WEEKLY_DAY = int(os.getenv('NEWSLETTER_WEEKLY_DAY', 0))  # 0 is Monday
+TRENDING_COUNT = int(os.getenv('NEWSLETTER_TRENDING_COUNT', 5))
+FOLLOWED_COUNT = int(os.getenv('NEWSLETTER_FOLLOWED_COUNT', 10))
+BATCH_SIZE = int(os.getenv('NEWSLETTER_BATCH_SIZE', 500))
+API_BASE_URL = os.getenv('API_BASE_URL', 'http://localhost')
+SITE_NAME = os.getenv('PUBLIC_SITE_NAME', 'Decentralized News')
+
+TEMPLATES = Environment(
+    loader=FileSystemLoader(os.path.join(os.path.dirname(__file__), 'templates')),
+    autoescape=select_autoescape(['html']),
+)
+
+
+def _secret() -> bytes:
+    return (os.getenv('NEWSLETTER_SECRET') or os.getenv('JWT_SECRET_KEY', 'dev-secret-key')).encode()
+
+
+def _signature(user_id: str) -> str:
+    digest = hmac.new(_secret(), f"newsletter-unsubscribe:{user_id}".encode(), hashlib.sha256).digest()
+    return base64.urlsafe_b64encode(digest).decode().rstrip('=')
+
+
+def unsubscribe_token(user_id: str) -> str:
+    """Token for a user's unsubscribe link; it doesn't expire, so links in old digests keep working"""
+    return f"{user_id}.{_signature(user_id)}"
+
+
+def verify_unsubscribe_token(token: str) -> Optional[str]:
+    """The user id a token was issued for, or None if it isn't genuine"""
+    user_id, _, signature = (token or '').partition('.')
+    if not user_id or not signature or not hmac.compare_digest(signature, _signature(user_id)):
+        return None
+    return user_id
+
+
+def unsubscribe_url(user_id: str) -> str:
+    return f"{API_BASE_URL.rstrip('/')}/api/v1/newsletters/unsubscribe?token={unsubscribe_token(user_id)}"
+
+
+def subscribe(cursor, user_id: str, frequency: str) -> Dict[str, Any]:
+    cursor.execute("""
+        INSERT INTO newsletter_subscriptions (user_id, frequency)
+        VALUES (%s, %s)
+        ON CONFLICT (user_id) DO UPDATE SET frequency = EXCLUDED.frequency, unsubscribed_at = NULL
+        RETURNING frequency, unsubscribed_at, last_sent_at
+    """, (user_id, frequency))
+    return dict(cursor.fetchone())
+
+
+def unsubscribe(cursor, user_id: str) -> bool:
+    cursor.execute("""
+        UPDATE newsletter_subscriptions SET unsubscribed_at = NOW()
+        WHERE user_id = %s AND unsubscribed_at IS NULL
+    """, (user_id,))
+    return cursor.rowcount > 0
+
+
+def subscribers_due(cursor, now: Optional[datetime] = None) -> List[str]:
+    """Users whose digest should go out now"""
+    now = now or datetime.now(timezone.utc)
+    if now.hour < SEND_HOUR:
+        return []
+    frequencies = [DAILY] + ([WEEKLY] if now.weekday() == WEEKLY_DAY else [])
+    cursor.execute("""
+        SELECT s.user_id FROM newsletter_subscriptions s
+        JOIN users u ON u.id = s.user_id
+        WHERE s.unsubscribed_at IS NULL AND s.frequency = ANY(%s)
+          AND u.is_active = true AND u.deleted_at IS NULL
+          AND (s.last_sent_at IS NULL
+               OR (s.frequency = %s AND s.last_sent_at < %s)
+               OR (s.frequency = %s AND s.last_sent_at < %s))
+        ORDER BY s.last_sent_at NULLS FIRST
+        LIMIT %s
+    """, (
+        frequencies,
+        DAILY, now - PERIODS[DAILY] + DUE_SLACK,
+        WEEKLY, now - PERIODS[WEEKLY] + DUE_SLACK,
+        BATCH_SIZE,
+    ))
+    return [str(row['user_id']) for row in cursor.fetchall()]
+
+
+def _claim(cursor, user_id: str, now: datetime) -> Optional[Dict[str, Any]]:
+    """Mark the user's digest sent, or None if it isn't due (another run got it, or they unsubscribed)"""
+    cursor.execute("""
+        SELECT s.frequency, s.last_sent_at, u.email, u.username
+        FROM newsletter_subscriptions s JOIN users u ON u.id = s.user_id
+        WHERE s.user_id = %s AND s.unsubscribed_at IS NULL AND u.is_active = true AND u.deleted_at IS NULL
+        FOR UPDATE OF s
+    """, (user_id,))
+    subscription = cursor.fetchone()
+    if not subscription:
+        return None
+    subscription = dict(subscription)
+    period = PERIODS[subscription['frequency']]
+    if subscription['last_sent_at'] and subscription['last_sent_at'] >= now - period + DUE_SLACK:
+        return None
+    cursor.execute("UPDATE newsletter_subscriptions SET last_sent_at = %s WHERE user_id = %s", (now, user_id))
+    # The digest covers the time since the last one, but never more than one period
+    subscription['period_start'] = max(subscription['last_sent_at'] or now - period, now - period)
+    return subscription
+
+
+def _article(row: Dict[str, Any]) -> Dict[str, Any]:
+    article = dict(row)
+    article['url'] = article_url(article)
+    if article.get('anonymous_author'):
+        article['author'] = None
+    return article
+
+
+def compile_digest(cursor, user_id: str, since: datetime) -> Dict[str, List[Dict[str, Any]]]:
+    """The top trending articles and followed authors' articles published since `since`"""
+    cursor.execute("""
+        SELECT a.id, a.title, a.summary, a.category, a.reading_time, a.published_at, a.anonymous_author,
+               u.username AS author
+        FROM articles a LEFT JOIN users u ON u.id = a.author_id
+        WHERE a.status = 'published' AND a.published_at >= %s
+        ORDER BY a.trending_score DESC NULLS LAST, a.published_at DESC
+        LIMIT %s
+    """, (since, TRENDING_COUNT))
+    trending = [_article(row) for row in cursor.fetchall()]
+
+    cursor.execute("""
+        SELECT a.id, a.title, a.summary, a.category, a.reading_time, a.published_at, a.anonymous_author,
+               u.username AS author
+        FROM articles a
+        JOIN user_follows f ON f.following_id = a.author_id AND f.follower_id = %s
+        JOIN users u ON u.id = a.author_id
+        WHERE a.status = 'published' AND a.published_at >= %s AND a.anonymous_author = false
+          AND NOT (a.id = ANY(%s::uuid[]))
+        ORDER BY a.published_at DESC
+        LIMIT %s
+    """, (user_id, since, [str(article['id']) for article in trending], FOLLOWED_COUNT))
+    followed = [_article(row) for row in cursor.fetchall()]
+    return {'trending': trending, 'followed': followed}
+
+
+def render(username: str, frequency: str, digest: Dict[str, List[Dict[str, Any]]], user_id: str) -> Dict[str, str]:
+    """Subject, plain text and HTML of a digest"""
+    context = {
+        'site_name': SITE_NAME,
+        'site_url': PUBLIC_BASE_URL,
+        'username': username,
+        'frequency': frequency,
+        'trending': digest['trending'],
+        'followed': digest['followed'],
+        'unsubscribe_url': unsubscribe_url(user_id),
+    }
+    return {
+        'subject': f"Your {frequency} {SITE_NAME} digest",
+        'body': TEMPLATES.get_template('newsletter_digest.txt').render(context),
+        'html': TEMPLATES.get_template('newsletter_digest.html').render(context),
+    }
+
+
+def send_digest(cursor, user_id: str, now: Optional[datetime] = None) -> bool:
+    """Compile, record and queue a user's digest if it is due; False when there was nothing to send"""
+    from shared.jobs import send_email
+
+    now = now or datetime.now(timezone.utc)
+    subscription = _claim(cursor, user_id, now)
+    if not subscription:
+        return False
+    digest = compile_digest(cursor, user_id, subscription['period_start'])
+    if not digest['trending'] and not digest['followed']:
+        return False
+
+    message = render(subscription['username'], subscription['frequency'], digest, user_id)
+    article_ids = [str(article['id']) for article in digest['trending'] + digest['followed']]
+    cursor.execute("""
+        INSERT INTO newsletter_sends (user_id, frequency, period_start, article_ids)
+        VALUES (%s, %s, %s, %s::uuid[])
+    """, (user_id, subscription['frequency'], subscription['period_start'], article_ids))
+
+    link = unsubscribe_url(user_id)
+    send_email.delay(subscription['email'], message['subject'], message['body'], message['html'], {
+        'List-Unsubscribe': f"<{link}>",
+        'List-Unsubscribe-Post': 'List-Unsubscribe=One-Click',
+    })
+    return True
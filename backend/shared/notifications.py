@@ -0,0 +1,228 @@
+"""
+Real-time notifications
+
+The outbox relay hands relayed domain events to `fan_out`, which turns the
+ones that concern particular users into notifications and publishes each on
+the user's Redis channel. Every open `/ws` connection subscribes to its
+user's channel, so a notification reaches the user whichever FastAPI worker
+their socket is on. Nothing is stored: users who aren't connected only get
+what reaches their registered push devices (see shared.push).
+
+    comment        - someone commented on your article
+    reply          - someone replied to your comment
+    new_article    - an author you follow published
+    moderation     - a moderator decided your article review or your report
+    draft_comment  - activity on a draft comment thread you're part of
+    editorial      - a review assigned to you moved on, or your draft's review
+                     was assigned or decided
+    coauthor       - you were invited to co-author an article or your credit
+                     changed, or a co-author of your article answered or left
+
+Set NOTIFICATIONS_ENABLED=false to stop the fan-out.
+"""
+
+import os
+import json
+import logging
+from datetime import datetime, timezone
+from typing import Any, Dict, Iterable, List, Tuple
+
+from shared.database import get_postgres_cursor, get_redis
+from shared import event_schemas
+from shared.utils import generate_uuid
+
+logger = logging.getLogger(__name__)
+
+# Largest follower list fanned out per article; more followers get nothing rather than a slow relay
+MAX_FOLLOWERS = int(os.getenv('NOTIFICATIONS_MAX_FOLLOWERS', 10000))
+
+
+def enabled() -> bool:
+    return os.getenv('NOTIFICATIONS_ENABLED', 'true').lower() == 'true'
+
+
+def channel(user_id: str) -> str:
+    return f"notifications:{user_id}"
+
+
+def new_notification(kind: str, data: Dict[str, Any]) -> Dict[str, Any]:
+    return {
+        'type': 'notification',
+        'id': generate_uuid(),
+        'kind': kind,
+        'created_at': datetime.now(timezone.utc).isoformat(),
+        'data': data,
+    }
+
+
+def _article_titles(cursor, article_ids: Iterable[str]) -> Dict[str, str]:
+    ids = sorted(set(article_ids))
+    if not ids:
+        return {}
+    cursor.execute("SELECT id, title FROM articles WHERE id = ANY(%s::uuid[])", (ids,))
+    return {str(row['id']): row['title'] for row in cursor.fetchall()}
+
+
+def _usernames(cursor, user_ids: Iterable[str]) -> Dict[str, str]:
+    ids = sorted(set(user_ids))
+    if not ids:
+        return {}
+    cursor.execute("SELECT id, username FROM users WHERE id = ANY(%s::uuid[])", (ids,))
+    return {str(row['id']): row['username'] for row in cursor.fetchall()}
+
+
+def _followers(cursor, author_id: str) -> List[str]:
+    cursor.execute(
+        "SELECT follower_id FROM user_follows WHERE following_id = %s LIMIT %s", (author_id, MAX_FOLLOWERS + 1)
+    )
+    followers = [str(row['follower_id']) for row in cursor.fetchall()]
+    if len(followers) > MAX_FOLLOWERS:
+        logger.warning(f"Author {author_id} has over {MAX_FOLLOWERS} followers; new article notifications skipped")
+        return []
+    return followers
+
+
+def notifications_for(cursor, events: List[Dict[str, Any]]) -> List[Tuple[str, Dict[str, Any]]]:
+    """(user id, notification) for each user the events concern"""
+    from shared.events import (
+        ARTICLE_CREDITED, ARTICLE_PUBLISHED, COMMENT_POSTED, DRAFT_COMMENTED, EDITORIAL_TRANSITIONED,
+        MODERATION_DECIDED
+    )
+
+    # Read at the versions this code knows, whatever version the events were recorded at
+    payloads = [event_schemas.read(event['payload']) for event in events]
+    titles = _article_titles(cursor, [
+        payload['data'].get('article_id') or payload['data'].get('id') for payload in payloads
+        if payload['type'] in (
+            COMMENT_POSTED, MODERATION_DECIDED, DRAFT_COMMENTED, ARTICLE_PUBLISHED, EDITORIAL_TRANSITIONED,
+            ARTICLE_CREDITED
+        )
+    ])
+    names = _usernames(cursor, [
+        user_id for payload in payloads
+        for user_id in (payload['data'].get('user_id'), payload['data'].get('author_id'), payload['data'].get('actor_id'))
+        if user_id and payload['type'] in (
+            COMMENT_POSTED, ARTICLE_PUBLISHED, DRAFT_COMMENTED, EDITORIAL_TRANSITIONED, ARTICLE_CREDITED
+        )
+    ])
+
+    delivered: List[Tuple[str, Dict[str, Any]]] = []
+    for payload in payloads:
+        data = payload['data']
+        if payload['type'] == COMMENT_POSTED:
+            commenter = data['user_id']
+            summary = {
+                'article_id': data['article_id'],
+                'article_title': titles.get(data['article_id']),
+                'comment_id': data['comment_id'],
+                'by': None if data['anonymous'] else names.get(commenter),
+                'excerpt': data['excerpt'],
+            }
+            parent_author = data.get('parent_author_id')
+            if parent_author and parent_author != commenter:
+                delivered.append((parent_author, new_notification('reply', summary)))
+            article_author = data.get('article_author_id')
+            if article_author and article_author not in (commenter, parent_author):
+                delivered.append((article_author, new_notification('comment', summary)))
+        elif payload['type'] == ARTICLE_PUBLISHED and data.get('author_id'):
+            notification = new_notification('new_article', {
+                'article_id': data['id'],
+                'title': data.get('title') or titles.get(data['id']),
+                'author': names.get(data['author_id']),
+                'category': data.get('category'),
+            })
+            delivered.extend((follower, notification) for follower in _followers(cursor, data['author_id']))
+        elif payload['type'] == MODERATION_DECIDED:
+            notification = new_notification('moderation', {
+                'subject': data['subject'],
+                'subject_id': data['subject_id'],
+                'article_id': data['article_id'],
+                'article_title': titles.get(data['article_id']),
+                'decision': data['decision'],
+                'note': data.get('note'),
+            })
+            delivered.extend((recipient, notification) for recipient in data['recipients'])
+        elif payload['type'] == DRAFT_COMMENTED:
+            notification = new_notification('draft_comment', {
+                'article_id': data['article_id'],
+                'article_title': titles.get(data['article_id']),
+                'comment_id': data['comment_id'],
+                'action': data['action'],
+                'by': names.get(data['actor_id']),
+            })
+            delivered.extend((recipient, notification) for recipient in data['recipients'])
+        elif payload['type'] == EDITORIAL_TRANSITIONED:
+            notification = new_notification('editorial', {
+                'review_id': data['review_id'],
+                'article_id': data['article_id'],
+                'article_title': titles.get(data['article_id']),
+                'action': data['action'],
+                'status': data['status'],
+                'by': names.get(data['actor_id']),
+                'note': data.get('note'),
+            })
+            delivered.extend((recipient, notification) for recipient in data['recipients'])
+        elif payload['type'] == ARTICLE_CREDITED:
+            notification = new_notification('coauthor', {
+                'article_id': data['article_id'],
+                'article_title': titles.get(data['article_id']),
+                'user': names.get(data['user_id']),
+                'role': data['role'],
+                'action': data['action'],
+                'by': names.get(data['actor_id']),
+            })
+            delivered.extend((recipient, notification) for recipient in data['recipients'])
+    return delivered
+
+
+def publish(delivered: List[Tuple[str, Dict[str, Any]]]):
+    if not delivered:
+        return
+    pipeline = get_redis().pipeline(transaction=False)
+    for user_id, notification in delivered:
+        pipeline.publish(channel(user_id), json.dumps(notification, default=str))
+    pipeline.execute()
+
+
+def deliver(events: List[Dict[str, Any]]) -> int:
+    """Publish the events' notifications and queue their push notifications, raising when publishing fails"""
+    with get_postgres_cursor() as cursor:
+        delivered = notifications_for(cursor, events)
+    publish(delivered)
+
+    from shared import push
+    try:
+        with get_postgres_cursor() as cursor:
+            push.enqueue(cursor, events, delivered)
+    except Exception as e:
+        logger.warning(f"Queueing push notifications failed for {len(events)} events: {e}")
+    return len(delivered)
+
+
+def fan_out(events: List[Dict[str, Any]]) -> int:
+    """Publish notifications for relayed outbox events; failures are logged, never retried
+
+    When a batch fails, its events are tried one at a time. Should some of
+    them go through, the ones that still fail are the cause and are
+    quarantined (shared/dead_letters.py); should none, the failure is taken
+    for an outage and the batch's notifications are lost.
+    """
+    try:
+        return deliver(events)
+    except Exception as e:
+        logger.warning(f"Notification fan-out failed for {len(events)} events: {e}")
+    if len(events) < 2:
+        return 0
+
+    from shared.dead_letters import NOTIFICATIONS, quarantine
+
+    count, failures = 0, []
+    for event in events:
+        try:
+            count += deliver([event])
+        except Exception as e:
+            failures.append((event, e))
+    if len(failures) < len(events):
+        for event, error in failures:
+            quarantine(NOTIFICATIONS, event, f"Notification fan-out failed: {error!r}")
+    return count
@@ -0,0 +1,251 @@
+"""
+OAuth2 authorization server for delegated posting
+
+Third-party writing tools register as clients and use the authorization code
+flow (RFC 6749, with optional PKCE) to act for a user. The frontend renders the
+consent screen from the authorization request validated here. Access tokens
+carry only the consented scopes, are checked against the user's grant on every
+request so revoking an app takes effect at once, and are never accepted where
+a full session is required.
+"""
+
+import base64
+import hashlib
+import hmac
+import secrets
+import logging
+from datetime import datetime, timedelta
+from typing import Any, Dict, List, Optional, Sequence, Tuple
+from urllib.parse import urlencode, urlparse
+
+from shared.auth import auth_manager
+
+logger = logging.getLogger(__name__)
+
+SCOPES = {
+    'articles:write': 'Create and edit your articles, including publishing them',
+    'drafts:read': 'Read your unpublished drafts',
+}
+
+CLIENT_ID_PREFIX = 'oac_'
+CLIENT_SECRET_PREFIX = 'oas_'
+CODE_TTL_SECONDS = 600
+
+
+class OAuthError(Exception):
+    """An RFC 6749 error: `error` is the protocol error code"""
+
+    def __init__(self, error: str, description: str, status_code: int = 400):
+        super().__init__(description)
+        self.error = error
+        self.description = description
+        self.status_code = status_code
+
+
+def hash_secret(value: str) -> str:
+    return hashlib.sha256(value.encode()).hexdigest()
+
+
+def generate_client_credentials() -> Tuple[str, str]:
+    """Create a (client_id, client_secret) pair"""
+    return (
+        f"{CLIENT_ID_PREFIX}{secrets.token_hex(12)}",
+        f"{CLIENT_SECRET_PREFIX}{secrets.token_urlsafe(32)}"
+    )
+
+
+def parse_scope(scope: Optional[str]) -> List[str]:
+    """Split a space-delimited scope string, rejecting scopes the platform doesn't offer"""
+    scopes = sorted(set(scope.split())) if scope else []
+    unknown = [value for value in scopes if value not in SCOPES]
+    if unknown:
+        raise OAuthError('invalid_scope', f"Unknown scopes: {', '.join(unknown)}")
+    return scopes
+
+
+def is_allowed_redirect_uri(uri: str) -> bool:
+    """HTTPS redirect URIs, or plain HTTP to a loopback address for desktop tools"""
+    parsed = urlparse(uri)
+    if parsed.fragment or not parsed.netloc:
+        return False
+    if parsed.scheme == 'https':
+        return True
+    return parsed.scheme == 'http' and parsed.hostname in ('localhost', '127.0.0.1', '::1')
+
+
+def get_client(cursor, client_id: str) -> Optional[Dict[str, Any]]:
+    cursor.execute("SELECT * FROM oauth_clients WHERE client_id = %s AND is_active = true", (client_id,))
+    client = cursor.fetchone()
+    return dict(client) if client else None
+
+
+def validate_authorization_request(cursor, client_id: str, redirect_uri: str, scope: Optional[str],
+                                   response_type: str = 'code', code_challenge: Optional[str] = None,
+                                   code_challenge_method: Optional[str] = None) -> Dict[str, Any]:
+    """Check an authorization request and resolve the scopes it asks for
+
+    An omitted scope requests everything the client registered for.
+    """
+    client = get_client(cursor, client_id)
+    if not client:
+        raise OAuthError('invalid_client', "Unknown or disabled client")
+    if redirect_uri not in client['redirect_uris']:
+        raise OAuthError('invalid_request', "redirect_uri does not match a registered URI")
+    if response_type != 'code':
+        raise OAuthError('unsupported_response_type', "Only the authorization code flow is supported")
+    if code_challenge and (code_challenge_method or 'plain') != 'S256':
+        raise OAuthError('invalid_request', "Only the S256 code challenge method is supported")
+
+    scopes = parse_scope(scope) or sorted(client['allowed_scopes'])
+    not_allowed = sorted(set(scopes) - set(client['allowed_scopes']))
+    if not_allowed:
+        raise OAuthError('invalid_scope', f"Client is not registered for: {', '.join(not_allowed)}")
+
+    return {'client': client, 'scopes': scopes, 'redirect_uri': redirect_uri}
+
+
+def consent_screen(cursor, user_id: str, authorization: Dict[str, Any]) -> Dict[str, Any]:
+    """What the consent screen shows: the app, each requested scope and what the user already granted"""
+    client = authorization['client']
+    cursor.execute("""
+        SELECT scopes FROM oauth_grants
+        WHERE user_id = %s AND client_id = %s AND revoked_at IS NULL
+    """, (user_id, client['id']))
+    grant = cursor.fetchone()
+    granted = set(grant['scopes']) if grant else set()
+
+    return {
+        'client': {
+            'client_id': client['client_id'],
+            'name': client['name'],
+            'description': client.get('description'),
+            'website_url': client.get('website_url'),
+        },
+        'redirect_uri': authorization['redirect_uri'],
+        'scopes': [
+            {'scope': scope, 'description': SCOPES[scope], 'granted': scope in granted}
+            for scope in authorization['scopes']
+        ],
+        'previously_authorized': set(authorization['scopes']) <= granted,
+    }
+
+
+def approve(cursor, user_id: str, authorization: Dict[str, Any], code_challenge: Optional[str] = None) -> str:
+    """Record the user's consent and issue a single-use authorization code"""
+    cursor.execute("""
+        INSERT INTO oauth_grants (user_id, client_id, scopes)
+        VALUES (%s, %s, %s)
+        ON CONFLICT (user_id, client_id) DO UPDATE SET
+            scopes = CASE
+                WHEN oauth_grants.revoked_at IS NULL
+                THEN ARRAY(SELECT DISTINCT unnest(oauth_grants.scopes || EXCLUDED.scopes) ORDER BY 1)
+                ELSE EXCLUDED.scopes
+            END,
+            revoked_at = NULL
+        RETURNING id
+    """, (user_id, authorization['client']['id'], authorization['scopes']))
+    grant_id = cursor.fetchone()['id']
+
+    code = secrets.token_urlsafe(32)
+    cursor.execute("""
+        INSERT INTO oauth_authorization_codes (code_hash, grant_id, scopes, redirect_uri, code_challenge, expires_at)
+        VALUES (%s, %s, %s, %s, %s, %s)
+    """, (
+        hash_secret(code), grant_id, authorization['scopes'], authorization['redirect_uri'],
+        code_challenge, datetime.now() + timedelta(seconds=CODE_TTL_SECONDS)
+    ))
+    return code
+
+
+def redirect_url(redirect_uri: str, params: Dict[str, Optional[str]]) -> str:
+    """The client's redirect URI with the response parameters appended"""
+    query = urlencode({key: value for key, value in params.items() if value is not None})
+    separator = '&' if urlparse(redirect_uri).query else '?'
+    return f"{redirect_uri}{separator}{query}"
+
+
+def _pkce_matches(code_challenge: str, code_verifier: Optional[str]) -> bool:
+    if not code_verifier:
+        return False
+    digest = hashlib.sha256(code_verifier.encode()).digest()
+    expected = base64.urlsafe_b64encode(digest).rstrip(b'=').decode()
+    return hmac.compare_digest(expected, code_challenge)
+
+
+def exchange_code(cursor, client_id: str, client_secret: str, code: str, redirect_uri: str,
+                  code_verifier: Optional[str] = None) -> Dict[str, Any]:
+    """Trade an authorization code for a scoped access token"""
+    client = get_client(cursor, client_id)
+    if not client or not hmac.compare_digest(hash_secret(client_secret or ''), client['client_secret_hash']):
+        raise OAuthError('invalid_client', "Client authentication failed", status_code=401)
+
+    # Marking the code used in the same statement makes it single-use under concurrency
+    cursor.execute("""
+        UPDATE oauth_authorization_codes SET used_at = NOW()
+        WHERE code_hash = %s AND used_at IS NULL AND expires_at > NOW()
+        RETURNING *
+    """, (hash_secret(code or ''),))
+    authorization = cursor.fetchone()
+    if not authorization:
+        raise OAuthError('invalid_grant', "Authorization code is invalid, expired or already used")
+
+    cursor.execute("""
+        SELECT g.client_id, g.revoked_at, u.id, u.username, u.email, u.role
+        FROM oauth_grants g
+        JOIN users u ON u.id = g.user_id AND u.is_active = true
+        WHERE g.id = %s
+    """, (authorization['grant_id'],))
+    grant = cursor.fetchone()
+    if not grant or grant['revoked_at'] or str(grant['client_id']) != str(client['id']):
+        raise OAuthError('invalid_grant', "Authorization code was not issued to this client")
+    if authorization['redirect_uri'] != redirect_uri:
+        raise OAuthError('invalid_grant', "redirect_uri does not match the authorization request")
+    if authorization['code_challenge'] and not _pkce_matches(authorization['code_challenge'], code_verifier):
+        raise OAuthError('invalid_grant', "code_verifier does not match the code challenge")
+
+    scopes = list(authorization['scopes'])
+    return {
+        'access_token': auth_manager.create_delegated_token(dict(grant), client['client_id'], scopes),
+        'token_type': 'Bearer',
+        'expires_in': auth_manager.delegated_token_expires,
+        'scope': ' '.join(sorted(scopes)),
+    }
+
+
+def has_delegated_access(cursor, claims: Dict[str, Any], required: Sequence[str]) -> bool:
+    """Whether a delegated token carries `required` and its grant is still in force"""
+    token_scopes = set((claims.get('scope') or '').split())
+    if not set(required) <= token_scopes:
+        return False
+
+    cursor.execute("""
+        SELECT 1 FROM oauth_grants g
+        JOIN oauth_clients c ON c.id = g.client_id
+        WHERE g.user_id = %s AND c.client_id = %s
+        AND g.revoked_at IS NULL AND c.is_active = true
+        AND g.scopes @> %s
+    """, (claims.get('user_id'), claims.get('client_id'), list(required)))
+    return cursor.fetchone() is not None
+
+
+def list_grants(cursor, user_id: str) -> List[Dict[str, Any]]:
+    """Apps the user has authorized"""
+    cursor.execute("""
+        SELECT c.client_id, c.name, c.website_url, g.scopes, g.created_at, g.updated_at
+        FROM oauth_grants g
+        JOIN oauth_clients c ON c.id = g.client_id
+        WHERE g.user_id = %s AND g.revoked_at IS NULL
+        ORDER BY g.updated_at DESC
+    """, (user_id,))
+    return [dict(grant) for grant in cursor.fetchall()]
+
+
+def revoke_grant(cursor, user_id: str, client_id: str) -> bool:
+    """Withdraw an app's access; its outstanding tokens and codes stop working"""
+    cursor.execute("""
+        UPDATE oauth_grants g SET revoked_at = NOW()
+        FROM oauth_clients c
+        WHERE c.id = g.client_id AND c.client_id = %s AND g.user_id = %s AND g.revoked_at IS NULL
+        RETURNING g.id
+    """, (client_id, user_id))
+    return cursor.fetchone() is not None
@@ -0,0 +1,150 @@
+"""
+Generated Open Graph cards
+
+Articles published without an image get a 1200x630 PNG card with the title,
+the author and the category, drawn in the category's color from the
+`og_image` settings. A worker renders the card after publishing and stores
+it in the media store; its URL is kept on the article as `og_image_url` for
+link previews and as the control of thumbnail tests.
+"""
+
+import io
+import os
+import logging
+from typing import Any, Dict, List, Optional
+
+from PIL import Image, ImageDraw, ImageFont
+
+from shared import article_cache
+from shared.media import store
+from shared.settings import get_setting
+
+logger = logging.getLogger(__name__)
+
+WIDTH = 1200
+HEIGHT = 630
+MARGIN = 80
+BAND_WIDTH = 24
+MAX_TITLE_LINES = 4
+
+BOLD_FONT = os.getenv('OG_IMAGE_FONT_BOLD', '/usr/share/fonts/truetype/dejavu/DejaVuSans-Bold.ttf')
+REGULAR_FONT = os.getenv('OG_IMAGE_FONT', '/usr/share/fonts/truetype/dejavu/DejaVuSans.ttf')
+
+
+def _font(path: str, size: int):
+    try:
+        return ImageFont.truetype(path, size)
+    except OSError:
+        # Pillow's bundled font, scalable since Pillow 10.1
+        try:
+            return ImageFont.load_default(size=size)
+        except TypeError:
+            return ImageFont.load_default()
+
+
+def _hex_color(value: str, fallback: str = '#1f2937') -> tuple:
+    value = (value or fallback).lstrip('#')
+    try:
+        return tuple(int(value[i:i + 2], 16) for i in (0, 2, 4))
+    except ValueError:
+        return _hex_color(fallback)
+
+
+def _wrap(draw: ImageDraw.ImageDraw, text: str, font, width: int, max_lines: int) -> List[str]:
+    """Break text into lines no wider than `width`, ending the last line with an ellipsis if it doesn't fit"""
+    lines: List[str] = []
+    current = ''
+    for word in text.split():
+        candidate = f"{current} {word}".strip()
+        if draw.textlength(candidate, font=font) <= width:
+            current = candidate
+            continue
+        if current:
+            lines.append(current)
+        current = word
+        if len(lines) == max_lines:
+            break
+    else:
+        if current:
+            lines.append(current)
+        return lines
+
+    last = lines[max_lines - 1]
+    while last and draw.textlength(f"{last}…", font=font) > width:
+        last = last.rsplit(' ', 1)[0] if ' ' in last else last[:-1]
+    lines[max_lines - 1] = f"{last}…"
+    return lines[:max_lines]
+
+
+def render_card(title: str, author: Optional[str], category: str, config: Dict[str, Any]) -> bytes:
+    """The card as PNG bytes"""
+    accent = _hex_color(config['category_colors'].get(category), config['default_color'])
+    background = _hex_color(config['background_color'], '#ffffff')
+    text_color = _hex_color(config['text_color'], '#111827')
+    muted = tuple((channel + 2 * base) // 3 for channel, base in zip(text_color, background))
+
+    image = Image.new('RGB', (WIDTH, HEIGHT), background)
+    draw = ImageDraw.Draw(image)
+    draw.rectangle([0, 0, BAND_WIDTH, HEIGHT], fill=accent)
+
+    text_left = BAND_WIDTH + MARGIN
+    text_width = WIDTH - text_left - MARGIN
+    draw.text((text_left, MARGIN), category.replace('_', ' ').upper(), font=_font(BOLD_FONT, 28), fill=accent)
+
+    # Shrink long titles before truncating them
+    for size in (68, 58, 50):
+        title_font = _font(BOLD_FONT, size)
+        lines = _wrap(draw, title, title_font, text_width, MAX_TITLE_LINES) or ['']
+        if not lines[-1].endswith('…'):
+            break
+    line_height = int(size * 1.2)
+    top = MARGIN + 70
+    for index, line in enumerate(lines):
+        draw.text((text_left, top + index * line_height), line, font=title_font, fill=text_color)
+
+    footer_font = _font(REGULAR_FONT, 30)
+    footer_top = HEIGHT - MARGIN - 30
+    draw.text((text_left, footer_top), f"By {author}" if author else "Anonymous", font=footer_font, fill=muted)
+    site_name = config['site_name']
+    draw.text(
+        (WIDTH - MARGIN - draw.textlength(site_name, font=footer_font), footer_top),
+        site_name, font=footer_font, fill=accent
+    )
+
+    output = io.BytesIO()
+    image.save(output, format='PNG', optimize=True)
+    return output.getvalue()
+
+
+def needs_card(article: Dict[str, Any]) -> bool:
+    return not (article.get('image_urls') or [])
+
+
+def generate_for_article(cursor, article_id: str) -> Optional[str]:
+    """Render and store the article's card, returning its URL"""
+    cursor.execute("""
+        SELECT a.id, a.title, a.category, a.anonymous_author, u.username, u.profile_data, u.anonymous_mode
+        FROM articles a LEFT JOIN users u ON a.author_id = u.id
+        WHERE a.id = %s
+    """, (article_id,))
+    article = cursor.fetchone()
+    if not article:
+        return None
+
+    author = None
+    if not article['anonymous_author'] and not article['anonymous_mode'] and article['username']:
+        author = (article['profile_data'] or {}).get('display_name') or article['username']
+    card = render_card(article['title'], author, article['category'] or 'general', get_setting('og_image'))
+    url = store(card, 'image/png', 'og')['url']
+    cursor.execute("UPDATE articles SET og_image_url = %s WHERE id = %s", (url, article_id))
+    article_cache.invalidate(article_id)
+    return url
+
+
+def enqueue_og_image(cursor, article: Dict[str, Any]):
+    """Publish hook: render a card for articles published without an image"""
+    if os.getenv('OG_IMAGE_ENABLED', 'true').lower() != 'true' or not needs_card(article):
+        return
+    from shared.jobs import generate_og_image
+
+    generate_og_image.apply_async(args=[str(article['id'])], countdown=5)
@@ -0,0 +1,446 @@
+"""
+Premium articles behind a paywall
+
+An article with an `access_policy` is premium. The policy lists the ways in,
+any one of which is enough:
+
+- `subscription_tier`: an active subscription to that tier or a higher-ranked one
+- `token`: holding at least `min_balance` of an ERC-20, ERC-721 or ERC-1155
+  token on PAYWALL_CHAIN_ID, in the wallet at the reader's `did_address`
+- `purchase`: a one-time payment of `price` in the chain's native currency
+  from the reader's wallet to the author's (or PAYWALL_PAYEE_ADDRESS)
+
+Entitlement is checked where article responses are built rather than in each
+handler: a middleware records who is asking for the request, and
+`ArticleResponse` swaps the content of premium articles for a preview unless
+that viewer is entitled. Authors and administrators always are. Code running
+outside a request (workers, jobs) sees full content; feeds and federation
+documents meant for the public use `public_copy` explicitly.
+"""
+
+import os
+import logging
+from contextlib import contextmanager
+from contextvars import ContextVar
+from decimal import Decimal
+from typing import Any, Dict, List, Optional
+
+from shared.database import get_postgres_cursor, get_redis
+from shared.content import HTML, preview_html, render
+from shared.readability import plain_text
+
+logger = logging.getLogger(__name__)
+
+RPC_URL = os.getenv('PAYWALL_RPC_URL', 'http://localhost:8545')
+CHAIN_ID = int(os.getenv('PAYWALL_CHAIN_ID', 31337))
+PAYEE_ADDRESS = os.getenv('PAYWALL_PAYEE_ADDRESS', '')
+MIN_CONFIRMATIONS = int(os.getenv('PAYWALL_MIN_CONFIRMATIONS', 2))
+BALANCE_CACHE_SECONDS = int(os.getenv('PAYWALL_BALANCE_CACHE_SECONDS', 300))
+PREVIEW_WORDS = int(os.getenv('PAYWALL_PREVIEW_WORDS', 60))
+
+TOKEN_ABIS = {
+    'erc20': [
+        {'name': 'balanceOf', 'type': 'function', 'stateMutability': 'view',
+         'inputs': [{'name': 'owner', 'type': 'address'}], 'outputs': [{'name': '', 'type': 'uint256'}]},
+        {'name': 'decimals', 'type': 'function', 'stateMutability': 'view',
+         'inputs': [], 'outputs': [{'name': '', 'type': 'uint8'}]},
+    ],
+    'erc721': [
+        {'name': 'balanceOf', 'type': 'function', 'stateMutability': 'view',
+         'inputs': [{'name': 'owner', 'type': 'address'}], 'outputs': [{'name': '', 'type': 'uint256'}]},
+    ],
+    'erc1155': [
+        {'name': 'balanceOf', 'type': 'function', 'stateMutability': 'view',
+         'inputs': [{'name': 'account', 'type': 'address'}, {'name': 'id', 'type': 'uint256'}],
+         'outputs': [{'name': '', 'type': 'uint256'}]},
+    ],
+}
+
+
+class PaymentInvalid(Exception):
+    """Raised when a transaction doesn't make the payment expected of it"""
+
+
+class PurchaseInvalid(PaymentInvalid):
+    """Raised when a transaction doesn't pay for the article"""
+
+
+class Viewer:
+    """Who is reading during one request, with their entitlements loaded as needed"""
+
+    def __init__(self, user: Optional[Dict[str, Any]]):
+        self.user = user
+        self._tier_rank: Optional[int] = None
+        self._wallet: Optional[str] = None
+        self._loaded = False
+        self._entitled: Dict[str, bool] = {}
+        self._required_ranks: Dict[str, int] = {}
+
+    @property
+    def user_id(self) -> Optional[str]:
+        return str(self.user['id']) if self.user and self.user.get('id') else None
+
+    def _load(self):
+        if self._loaded:
+            return
+        self._loaded = True
+        with get_postgres_cursor() as cursor:
+            cursor.execute("""
+                SELECT u.did_address,
+                       (SELECT MAX(t.rank) FROM user_subscriptions s
+                        JOIN subscription_tiers t ON t.key = s.tier_key
+                        WHERE s.user_id = u.id AND s.revoked_at IS NULL AND s.starts_at <= NOW()
+                          AND (s.expires_at IS NULL OR s.expires_at > NOW())) AS tier_rank
+                FROM users u WHERE u.id = %s
+            """, (self.user_id,))
+            row = cursor.fetchone()
+        if row:
+            self._wallet = row['did_address']
+            self._tier_rank = row['tier_rank']
+
+    def tier_rank(self) -> Optional[int]:
+        self._load()
+        return self._tier_rank
+
+    def wallet(self) -> Optional[str]:
+        self._load()
+        return self._wallet
+
+    def meets_tier(self, tier_key: str) -> bool:
+        if self.tier_rank() is None:
+            return False
+        if tier_key not in self._required_ranks:
+            self._required_ranks[tier_key] = tier_rank(tier_key)
+        return self.tier_rank() >= self._required_ranks[tier_key]
+
+    def can_read(self, article: Dict[str, Any]) -> bool:
+        policy = article.get('access_policy')
+        if not policy:
+            return True
+        if not self.user_id:
+            return False
+        if self.user.get('role') == 'administrator' or is_author(article, self.user_id):
+            return True
+
+        article_id = str(article['id'])
+        if article_id not in self._entitled:
+            try:
+                self._entitled[article_id] = bool(entitlement(self, article_id, policy))
+            except Exception as e:
+                # Fail closed: a reader who is entitled can retry, a leak can't be undone
+                logger.warning(f"Entitlement check for article {article_id} failed: {e}")
+                return False
+        return self._entitled[article_id]
+
+
+_viewer: ContextVar[Optional[Viewer]] = ContextVar('paywall_viewer', default=None)
+
+
+def current_viewer() -> Optional[Viewer]:
+    return _viewer.get()
+
+
+def begin_viewer(user: Optional[Dict[str, Any]]):
+    """Start checking entitlements for `user` (None for signed-out readers) without a with-block"""
+    _viewer.set(Viewer(user))
+
+
+def end_viewer():
+    _viewer.set(None)
+
+
+@contextmanager
+def unrestricted():
+    """Build full article responses regardless of the viewer, e.g. to fill a shared cache"""
+    token = _viewer.set(None)
+    try:
+        yield
+    finally:
+        _viewer.reset(token)
+
+
+class PaywallMiddleware:
+    """ASGI middleware recording the signed-in user, if any, as the request's viewer"""
+
+    def __init__(self, app):
+        self.app = app
+
+    async def __call__(self, scope, receive, send):
+        if scope['type'] != 'http':
+            await self.app(scope, receive, send)
+            return
+
+        from shared.auth import auth_manager
+
+        headers = dict(scope.get('headers') or [])
+        authorization = headers.get(b'authorization', b'').decode('latin-1')
+        user = None
+        if authorization:
+            # Claims only; the database is read if the request touches a premium article
+            bearer = auth_manager.extract_token_from_header(authorization)
+            user = auth_manager.get_user_from_token(bearer) if bearer else None
+
+        context_token = _viewer.set(Viewer(user))
+        try:
+            await self.app(scope, receive, send)
+        finally:
+            _viewer.reset(context_token)
+
+
+# Entitlement
+def is_author(article: Dict[str, Any], user_id: str) -> bool:
+    """Whether the user leads or co-authors the article, whether it's a row or a rendered response"""
+    credited = {str(article.get('author_id'))} | {str(user) for user in article.get('coauthor_ids') or ()}
+    credited |= {author['user_id'] for author in article.get('authors') or ()}
+    return user_id in credited
+
+
+def entitlement(viewer: Viewer, article_id: str, policy: Dict[str, Any]) -> Optional[str]:
+    """How the viewer is entitled to the article (`subscription`, `purchase` or `token`), or None"""
+    if policy.get('subscription_tier') and viewer.meets_tier(policy['subscription_tier']):
+        return 'subscription'
+    if policy.get('purchase') and has_purchased(viewer.user_id, article_id):
+        return 'purchase'
+    if policy.get('token') and viewer.wallet() and holds_token(viewer.wallet(), policy['token']):
+        return 'token'
+    return None
+
+
+def tier_rank(tier_key: str) -> int:
+    with get_postgres_cursor() as cursor:
+        cursor.execute("SELECT rank FROM subscription_tiers WHERE key = %s", (tier_key,))
+        row = cursor.fetchone()
+    # A tier removed since the policy was set can't be satisfied by rank
+    return row['rank'] if row else 2 ** 31
+
+
+def has_purchased(user_id: str, article_id: str) -> bool:
+    with get_postgres_cursor() as cursor:
+        cursor.execute("SELECT 1 FROM article_purchases WHERE user_id = %s AND article_id = %s", (user_id, article_id))
+        return cursor.fetchone() is not None
+
+
+def _web3():
+    from web3 import Web3
+
+    return Web3(Web3.HTTPProvider(RPC_URL, request_kwargs={'timeout': 10}))
+
+
+def token_balance(address: str, gate: Dict[str, Any]) -> Decimal:
+    """The wallet's balance of the gating token, in whole tokens for ERC-20 and units otherwise"""
+    from web3 import Web3
+
+    standard = gate['standard']
+    cache_key = f"paywall_balance:{standard}:{gate['contract'].lower()}:{gate.get('token_id')}:{address.lower()}"
+    redis_client = get_redis()
+    cached = redis_client.get(cache_key)
+    if cached is not None:
+        return Decimal(cached)
+
+    web3 = _web3()
+    contract = web3.eth.contract(address=Web3.to_checksum_address(gate['contract']), abi=TOKEN_ABIS[standard])
+    owner = Web3.to_checksum_address(address)
+    if standard == 'erc1155':
+        balance = Decimal(contract.functions.balanceOf(owner, int(gate['token_id'])).call())
+    else:
+        balance = Decimal(contract.functions.balanceOf(owner).call())
+    if standard == 'erc20':
+        balance = balance / (Decimal(10) ** contract.functions.decimals().call())
+
+    redis_client.setex(cache_key, BALANCE_CACHE_SECONDS, str(balance))
+    return balance
+
+
+def holds_token(address: str, gate: Dict[str, Any]) -> bool:
+    return token_balance(address, gate) >= Decimal(str(gate.get('min_balance', 1)))
+
+
+def access_status(article: Dict[str, Any], viewer: Optional[Viewer]) -> Dict[str, Any]:
+    """What the policy asks for and whether, and how, the viewer meets it"""
+    policy = article.get('access_policy')
+    status = {'article_id': str(article['id']), 'premium': bool(policy), 'policy': policy}
+    if not policy:
+        return {**status, 'entitled': True, 'via': None}
+    if viewer is None or not viewer.user_id:
+        return {**status, 'entitled': False, 'via': None}
+    if viewer.user.get('role') == 'administrator' or is_author(article, viewer.user_id):
+        return {**status, 'entitled': True, 'via': 'author'}
+    via = entitlement(viewer, str(article['id']), policy)
+    return {**status, 'entitled': via is not None, 'via': via}
+
+
+# Previews
+def preview(article: Dict[str, Any]) -> str:
+    if article.get('summary'):
+        return article['summary']
+    words = plain_text(article.get('content') or '').split()
+    text = ' '.join(words[:PREVIEW_WORDS])
+    return f"{text}…" if len(words) > PREVIEW_WORDS else text
+
+
+def can_read(article: Dict[str, Any]) -> bool:
+    """Whether the current viewer may see the article's full content"""
+    viewer = current_viewer()
+    return viewer is None or viewer.can_read(article)
+
+
+def gate(article: Dict[str, Any]) -> Dict[str, Any]:
+    """The article as the current viewer may see it: unchanged, or with a preview in place of the content"""
+    if can_read(article):
+        return {**article, 'paywalled': False}
+    text = preview(article)
+    return {**article, 'content': text, 'content_html': preview_html(text), 'paywalled': True}
+
+
+def public_copy(article: Dict[str, Any]) -> Dict[str, Any]:
+    """The article as a signed-out reader sees it, with `content_html`, for feeds and documents sent to other servers"""
+    if not article.get('access_policy'):
+        return {**article, 'content_html': render(article.get('content') or '', article.get('content_format') or HTML)}
+    text = preview(article)
+    return {**article, 'content': text, 'content_html': preview_html(text)}
+
+
+# Purchases
+def payee_address(cursor, article: Dict[str, Any]) -> str:
+    if PAYEE_ADDRESS:
+        return PAYEE_ADDRESS
+    cursor.execute("SELECT did_address FROM users WHERE id = %s", (article['author_id'],))
+    author = cursor.fetchone()
+    if not author or not author['did_address']:
+        raise PurchaseInvalid("The author has no wallet address to pay")
+    return author['did_address']
+
+
+def verify_transfer(transaction_hash: str, payers: List[str], payees: List[str], min_value_wei: int) -> Dict[str, Any]:
+    """Check that a confirmed transaction sent at least `min_value_wei` from one of `payers` to one of `payees`"""
+    from web3.exceptions import TransactionNotFound
+
+    web3 = _web3()
+    try:
+        transaction = web3.eth.get_transaction(transaction_hash)
+        receipt = web3.eth.get_transaction_receipt(transaction_hash)
+    except TransactionNotFound:
+        raise PaymentInvalid("Transaction not found or not mined yet")
+    if receipt['status'] != 1:
+        raise PaymentInvalid("Transaction failed")
+    if web3.eth.block_number - receipt['blockNumber'] + 1 < MIN_CONFIRMATIONS:
+        raise PaymentInvalid("Transaction needs more confirmations")
+    if (transaction['from'] or '').lower() not in {payer.lower() for payer in payers}:
+        raise PaymentInvalid("Transaction was not sent from your wallet")
+    if (transaction['to'] or '').lower() not in {payee.lower() for payee in payees}:
+        raise PaymentInvalid("Transaction did not pay the expected address")
+    if transaction['value'] < min_value_wei:
+        raise PaymentInvalid("Transaction paid less than the price")
+    return dict(transaction)
+
+
+def record_purchase(cursor, article: Dict[str, Any], user: Dict[str, Any], transaction_hash: str) -> Dict[str, Any]:
+    """Check that the transaction paid the article's price from the reader's wallet, and record it"""
+    from web3 import Web3
+    from shared.wallets import addresses
+
+    purchase = (article.get('access_policy') or {}).get('purchase')
+    if not purchase:
+        raise PurchaseInvalid("This article can't be bought")
+    # Any linked wallet may pay
+    payers = addresses(cursor, str(user['id'])) or ([user['did_address']] if user.get('did_address') else [])
+    if not payers:
+        raise PurchaseInvalid("Add a wallet address to your account first")
+    payee = payee_address(cursor, article)
+    price_wei = Web3.to_wei(Decimal(str(purchase['price'])), 'ether')
+    transaction = verify_transfer(transaction_hash, payers, [payee], price_wei)
+
+    cursor.execute("""
+        INSERT INTO article_purchases (
+            article_id, user_id, chain_id, transaction_hash, payer_address, payee_address, amount_wei
+        ) VALUES (%s, %s, %s, %s, %s, %s, %s)
+        ON CONFLICT (transaction_hash) DO NOTHING
+        RETURNING *
+    """, (article['id'], user['id'], CHAIN_ID, transaction_hash.lower(), transaction['from'], payee,
+          transaction['value']))
+    recorded = cursor.fetchone()
+    if not recorded:
+        raise PurchaseInvalid("Transaction was already used for a purchase")
+    logger.info(f"User {user['id']} bought article {article['id']} with {transaction_hash}")
+    return dict(recorded)
+
+
+# Policies and subscriptions
+def set_policy(cursor, article_id: str, policy: Optional[Dict[str, Any]]) -> Dict[str, Any]:
+    """Make an article premium under `policy`, or free again with None"""
+    if policy and policy.get('subscription_tier'):
+        cursor.execute("SELECT 1 FROM subscription_tiers WHERE key = %s", (policy['subscription_tier'],))
+        if not cursor.fetchone():
+            raise ValueError(f"Unknown subscription tier: {policy['subscription_tier']}")
+    cursor.execute(
+        "UPDATE articles SET access_policy = %s, updated_at = NOW() WHERE id = %s RETURNING *", (policy, article_id)
+    )
+    return dict(cursor.fetchone())
+
+
+def refresh_public_copies(article: Dict[str, Any]):
+    """Rebuild the cached copies of a published article that signed-out readers get, after its policy changed"""
+    from shared import article_cache
+    from shared.edge_cache import FEED_SURROGATE_KEY, purge_surrogate_keys
+    from shared.public_feeds import feeds_for_article, refresh_feed
+
+    article_cache.invalidate(str(article['id']))
+    if article.get('status') != 'published':
+        return
+    for scope, value in feeds_for_article(article):
+        refresh_feed(scope, value)
+    purge_surrogate_keys([FEED_SURROGATE_KEY])
+
+
+def list_tiers(cursor) -> List[Dict[str, Any]]:
+    cursor.execute("SELECT * FROM subscription_tiers ORDER BY rank")
+    return [dict(row) for row in cursor.fetchall()]
+
+
+def list_subscriptions(cursor, user_id: Optional[str] = None, active_only: bool = False) -> List[Dict[str, Any]]:
+    query = """
+        SELECT s.*, t.name AS tier_name, t.rank AS tier_rank,
+               (s.revoked_at IS NULL AND s.starts_at <= NOW() AND (s.expires_at IS NULL OR s.expires_at > NOW())) AS active
+        FROM user_subscriptions s
+        JOIN subscription_tiers t ON t.key = s.tier_key
+        WHERE TRUE
+    """
+    params: List[Any] = []
+    if user_id:
+        query += " AND s.user_id = %s"
+        params.append(user_id)
+    if active_only:
+        query += " AND s.revoked_at IS NULL AND s.starts_at <= NOW() AND (s.expires_at IS NULL OR s.expires_at > NOW())"
+    cursor.execute(query + " ORDER BY s.created_at DESC LIMIT 500", params)
+    return [dict(row) for row in cursor.fetchall()]
+
+
+def grant_subscription(cursor, grant: Dict[str, Any], granted_by: Optional[str]) -> Dict[str, Any]:
+    cursor.execute("""
+        INSERT INTO user_subscriptions (user_id, tier_key, starts_at, expires_at, source, external_ref, granted_by)
+        VALUES (%s, %s, COALESCE(%s, NOW()), %s, %s, %s, %s)
+        RETURNING *
+    """, (grant['user_id'], grant['tier'], grant.get('starts_at'), grant.get('expires_at'),
+          grant.get('source') or 'admin', grant.get('external_ref'), granted_by))
+    return dict(cursor.fetchone())
+
+
+def revoke_subscription(cursor, subscription_id: str) -> Optional[Dict[str, Any]]:
+    cursor.execute("""
+        UPDATE user_subscriptions SET revoked_at = NOW()
+        WHERE id = %s AND revoked_at IS NULL
+        RETURNING *
+    """, (subscription_id,))
+    row = cursor.fetchone()
+    return dict(row) if row else None
+
+
+def list_purchases(cursor, user_id: str) -> List[Dict[str, Any]]:
+    cursor.execute("""
+        SELECT p.article_id, a.title, p.transaction_hash, p.amount_wei::text AS amount_wei, p.chain_id, p.created_at
+        FROM article_purchases p
+        JOIN articles a ON a.id = p.article_id
+        WHERE p.user_id = %s
+        ORDER BY p.created_at DESC
+    """, (user_id,))
+    return [dict(row) for row in cursor.fetchall()]
@@ -0,0 +1,141 @@
+"""
+Peer reputation and federation throttling
+
+Quality signals are recorded per peer: articles accepted from it, spam reports
+readers file against its content and manifest entries whose article didn't
+match the advertised content hash. The reputation score is the share of good
+outcomes over a rolling window, smoothed so a new peer starts trusted and a
+single bad article doesn't sink an established one.
+
+Below FEDERATION_THROTTLE_BELOW a peer is throttled (synced less often, with
+fewer new articles per sync); below FEDERATION_PAUSE_BELOW ingestion pauses and
+only revalidation of already pulled content continues. An administrator can
+pin a peer's state, which overrides the automatic one until cleared.
+"""
+
+import os
+import logging
+from typing import Any, Dict, Optional
+
+logger = logging.getLogger(__name__)
+
+NORMAL = 'normal'
+THROTTLED = 'throttled'
+PAUSED = 'paused'
+STATES = (NORMAL, THROTTLED, PAUSED)
+
+ACCEPTED_ARTICLE = 'accepted_article'
+SPAM_REPORT = 'spam_report'
+VERIFICATION_FAILURE = 'verification_failure'
+
+WINDOW_DAYS = int(os.getenv('FEDERATION_REPUTATION_WINDOW_DAYS', 30))
+THROTTLE_BELOW = float(os.getenv('FEDERATION_THROTTLE_BELOW', 0.7))
+PAUSE_BELOW = float(os.getenv('FEDERATION_PAUSE_BELOW', 0.4))
+THROTTLED_INTERVAL_MULTIPLIER = 4
+THROTTLED_MAX_ARTICLES = int(os.getenv('FEDERATION_THROTTLED_MAX_ARTICLES', 20))
+
+# Smoothing: every peer starts as if it had this many good articles
+PRIOR_GOOD = 10
+# A verification failure counts for less than spam: it may be a broken origin rather than a hostile one
+VERIFICATION_WEIGHT = 0.5
+
+
+def effective_state(peer: Dict[str, Any]) -> str:
+    return peer.get('reputation_override') or peer.get('ingestion_state') or NORMAL
+
+
+def record_signal(cursor, peer_id: str, signal: str, article_id: Optional[str] = None,
+                  remote_id: Optional[str] = None, reported_by: Optional[str] = None,
+                  detail: Optional[str] = None) -> bool:
+    """Record one quality signal; a reader's repeat spam report on the same article is ignored"""
+    cursor.execute("""
+        INSERT INTO federation_peer_signals (peer_id, signal, article_id, remote_id, reported_by, detail)
+        VALUES (%s, %s, %s, %s, %s, %s)
+        ON CONFLICT DO NOTHING
+        RETURNING id
+    """, (peer_id, signal, article_id, remote_id, reported_by, detail))
+    return cursor.fetchone() is not None
+
+
+def signal_counts(cursor, peer_id: str) -> Dict[str, int]:
+    cursor.execute("""
+        SELECT
+            COUNT(*) FILTER (WHERE signal = %s) AS accepted_articles,
+            COUNT(DISTINCT COALESCE(article_id::text, remote_id)) FILTER (WHERE signal = %s) AS spam_reported_articles,
+            COUNT(*) FILTER (WHERE signal = %s) AS spam_reports,
+            COUNT(*) FILTER (WHERE signal = %s) AS verification_failures
+        FROM federation_peer_signals
+        WHERE peer_id = %s AND created_at >= NOW() - make_interval(days => %s)
+    """, (ACCEPTED_ARTICLE, SPAM_REPORT, SPAM_REPORT, VERIFICATION_FAILURE, peer_id, WINDOW_DAYS))
+    return dict(cursor.fetchone())
+
+
+def compute_score(counts: Dict[str, int]) -> float:
+    """Share of good outcomes; spam is counted per reported article, not per report"""
+    bad = counts['spam_reported_articles'] + VERIFICATION_WEIGHT * counts['verification_failures']
+    total = PRIOR_GOOD + counts['accepted_articles'] + VERIFICATION_WEIGHT * counts['verification_failures']
+    return round(max(0.0, min(1.0, 1 - bad / total)), 3)
+
+
+def state_for_score(score: float) -> str:
+    if score < PAUSE_BELOW:
+        return PAUSED
+    if score < THROTTLE_BELOW:
+        return THROTTLED
+    return NORMAL
+
+
+def update_reputation(cursor, peer: Dict[str, Any]) -> Dict[str, Any]:
+    """Recompute a peer's score and automatic state"""
+    counts = signal_counts(cursor, str(peer['id']))
+    score = compute_score(counts)
+    state = state_for_score(score)
+
+    cursor.execute("""
+        UPDATE federation_peers SET reputation_score = %s, ingestion_state = %s, reputation_updated_at = NOW()
+        WHERE id = %s
+    """, (score, state, peer['id']))
+
+    if state != peer.get('ingestion_state'):
+        logger.warning(
+            f"Peer {peer['domain']} reputation {score} moved ingestion from {peer.get('ingestion_state')} to {state}"
+            + (f" (overridden to {peer['reputation_override']})" if peer.get('reputation_override') else '')
+        )
+    return {'score': score, 'ingestion_state': state, 'signals': counts}
+
+
+def report_spam(cursor, article_id: str, reported_by: str, reason: Optional[str] = None) -> Optional[Dict[str, Any]]:
+    """File a reader's spam report against the peer a federated article came from
+
+    Returns None for articles that weren't pulled from a peer.
+    """
+    cursor.execute("""
+        SELECT p.*, f.remote_id FROM federated_articles f
+        JOIN federation_peers p ON p.id = f.peer_id
+        WHERE f.article_id = %s
+    """, (article_id,))
+    peer = cursor.fetchone()
+    if not peer:
+        return None
+
+    peer = dict(peer)
+    recorded = record_signal(cursor, str(peer['id']), SPAM_REPORT, article_id=article_id,
+                             remote_id=peer['remote_id'], reported_by=reported_by, detail=reason)
+    if recorded:
+        update_reputation(cursor, peer)
+    return {'peer': peer['domain'], 'recorded': recorded}
+
+
+def reputation_report(cursor, peer: Dict[str, Any]) -> Dict[str, Any]:
+    counts = signal_counts(cursor, str(peer['id']))
+    return {
+        'peer_id': str(peer['id']),
+        'domain': peer['domain'],
+        'score': compute_score(counts),
+        'ingestion_state': peer.get('ingestion_state') or NORMAL,
+        'override': peer.get('reputation_override'),
+        'effective_state': effective_state(peer),
+        'window_days': WINDOW_DAYS,
+        'thresholds': {'throttle_below': THROTTLE_BELOW, 'pause_below': PAUSE_BELOW},
+        'signals': counts,
+    }
@@ -0,0 +1,270 @@
+"""
+Fine-grained permissions
+
+What a user may do is decided by permissions such as `article:publish`,
+`comment:moderate` or `user:ban` rather than by comparing their role.
+Each role holds a set of permissions (`role_permissions`, which starts out
+with what the roles could do when the checks were hardcoded), and single
+users can be granted a permission their role lacks or have one of their
+role's revoked (`user_permissions`). Role grants apply to every
+publication; user grants are managed by the user's publication. Members of
+a publication (shared/members.py) also hold their member role's
+permissions: editors run its newsroom, contributors write for it.
+
+Handlers depend on `require_permission` (fastapi_app/dependencies.py) or
+ask `allowed`. A user's effective permissions are worked out once per
+request (PermissionMiddleware keeps them for the rest of it) and cached in
+Redis for PERMISSIONS_CACHE_TTL_SECONDS, keyed by the user, their role and
+a version that every grant change bumps, so changes apply on the next
+request. When Redis is down they're read from PostgreSQL each time.
+"""
+
+import os
+import json
+import logging
+from contextvars import ContextVar
+from typing import Any, Dict, FrozenSet, List, Optional
+
+from shared.database import get_postgres_cursor, get_redis
+from shared.audit import record_privileged
+
+logger = logging.getLogger(__name__)
+
+ARTICLE_PUBLISH = 'article:publish'
+ARTICLE_EDIT_ANY = 'article:edit_any'
+ARTICLE_MODERATE = 'article:moderate'
+ARTICLE_REVIEW = 'article:review'
+ARTICLE_CURATE = 'article:curate'
+COMMENT_MODERATE = 'comment:moderate'
+USER_BAN = 'user:ban'
+USER_MANAGE = 'user:manage'
+ANALYTICS_READ = 'analytics:read'
+AUDIT_READ = 'audit:read'
+SETTINGS_MANAGE = 'settings:manage'
+PERMISSIONS_MANAGE = 'permissions:manage'
+MEMBERS_MANAGE = 'members:manage'
+
+PERMISSIONS = {
+    ARTICLE_PUBLISH: "Publish and schedule one's own articles",
+    ARTICLE_EDIT_ANY: "Edit, archive and translate other authors' articles, and see their drafts",
+    ARTICLE_MODERATE: "Review article reports, and publish without moderation review",
+    ARTICLE_REVIEW: "Take, assign and decide editorial reviews of drafts, and publish without one",
+    ARTICLE_CURATE: "Pin and feature articles on the home feed and category pages",
+    COMMENT_MODERATE: "Review held comments and discussions, and comment without screening",
+    USER_BAN: "Shadow-ban users, and see shadow-banned users' content",
+    USER_MANAGE: "List users, and edit or delete other accounts",
+    ANALYTICS_READ: "Read platform-wide and other users' analytics",
+    AUDIT_READ: "Read and verify the admin audit log",
+    SETTINGS_MANAGE: "Read and change platform settings",
+    PERMISSIONS_MANAGE: "Change roles, and what roles and users are permitted",
+    MEMBERS_MANAGE: "Invite editors and contributors to the publication, and change or end their membership",
+}
+
+ROLES = ('reader', 'author', 'administrator', 'auditor')
+
+# What a publication's members hold on top of their role; a user's own revocations still apply
+MEMBER_ROLE_PERMISSIONS = {
+    'editor': frozenset({
+        ARTICLE_PUBLISH, ARTICLE_EDIT_ANY, ARTICLE_MODERATE, ARTICLE_REVIEW, ARTICLE_CURATE, COMMENT_MODERATE,
+        ANALYTICS_READ, MEMBERS_MANAGE,
+    }),
+    'contributor': frozenset({ARTICLE_PUBLISH}),
+}
+
+CACHE_TTL_SECONDS = int(os.getenv('PERMISSIONS_CACHE_TTL_SECONDS', 60))
+VERSION_KEY = 'permissions:version'
+
+# Effective permissions by user id for the current request, kept by PermissionMiddleware
+_request_cache: ContextVar[Optional[Dict[str, FrozenSet[str]]]] = ContextVar('permission_cache', default=None)
+
+
+class UnknownPermission(Exception):
+    pass
+
+
+def _check(permission: str):
+    if permission not in PERMISSIONS:
+        raise UnknownPermission(f"Unknown permission: {permission}")
+
+
+# Evaluation
+def _load(cursor, user_id: str, role: Optional[str]) -> FrozenSet[str]:
+    cursor.execute("SELECT permission FROM role_permissions WHERE role::text = %s", (role,))
+    held = {row['permission'] for row in cursor.fetchall()}
+    cursor.execute("SELECT publication_role FROM users WHERE id = %s", (user_id,))
+    member = cursor.fetchone()
+    if member and member['publication_role']:
+        held |= MEMBER_ROLE_PERMISSIONS.get(member['publication_role'], frozenset())
+    cursor.execute("SELECT permission, granted FROM user_permissions WHERE user_id = %s", (user_id,))
+    for row in cursor.fetchall():
+        (held.add if row['granted'] else held.discard)(row['permission'])
+    return frozenset(held)
+
+
+def _cache_key(redis_client, user_id: str, role: Optional[str]) -> str:
+    version = int(redis_client.get(VERSION_KEY) or 0)
+    return f"permissions:{version}:{user_id}:{role}"
+
+
+def effective(user: Dict[str, Any]) -> FrozenSet[str]:
+    """Every permission a user holds, through their role and their own grants"""
+    user_id, role = str(user['id']), user.get('role')
+    memo = _request_cache.get()
+    if memo is not None and user_id in memo:
+        return memo[user_id]
+
+    held = None
+    try:
+        redis_client = get_redis()
+        key = _cache_key(redis_client, user_id, role)
+        cached = redis_client.get(key)
+        if cached:
+            held = frozenset(json.loads(cached))
+    except Exception as e:
+        logger.warning(f"Permission cache read failed for {user_id}: {e}")
+        redis_client = None
+
+    if held is None:
+        with get_postgres_cursor() as cursor:
+            held = _load(cursor, user_id, role)
+        if redis_client is not None:
+            try:
+                redis_client.setex(key, CACHE_TTL_SECONDS, json.dumps(sorted(held)))
+            except Exception as e:
+                logger.warning(f"Permission cache write failed for {user_id}: {e}")
+
+    if memo is not None:
+        memo[user_id] = held
+    return held
+
+
+def allowed(user: Optional[Dict[str, Any]], permission: str) -> bool:
+    """Whether `user` (None when signed out) holds `permission`"""
+    return bool(user) and permission in effective(user)
+
+
+def can_edit_article(user: Optional[Dict[str, Any]], article: Dict[str, Any]) -> bool:
+    """Whether `user` wrote or co-authors the article, or holds `article:edit_any`
+
+    Co-authors are read from the article's `coauthor_ids` (shared/coauthors.py),
+    so select it along with `author_id`.
+    """
+    return bool(user) and (
+        str(user['id']) in {str(article['author_id'])} | {str(user_id) for user_id in article.get('coauthor_ids') or ()}
+        or allowed(user, ARTICLE_EDIT_ANY)
+    )
+
+
+def invalidate():
+    """Make every cached permission set stale, after a grant changes"""
+    try:
+        get_redis().incr(VERSION_KEY)
+    except Exception as e:
+        logger.warning(f"Permission cache invalidation failed: {e}")
+
+
+class PermissionMiddleware:
+    """ASGI middleware keeping each user's permissions for the rest of the request once they're worked out"""
+
+    def __init__(self, app):
+        self.app = app
+
+    async def __call__(self, scope, receive, send):
+        if scope['type'] != 'http':
+            await self.app(scope, receive, send)
+            return
+        token = _request_cache.set({})
+        try:
+            await self.app(scope, receive, send)
+        finally:
+            _request_cache.reset(token)
+
+
+# Role grants
+def role_grants(cursor) -> Dict[str, List[str]]:
+    """The permissions of every role"""
+    cursor.execute("SELECT role::text AS role, permission FROM role_permissions ORDER BY role, permission")
+    grants = {role: [] for role in ROLES}
+    for row in cursor.fetchall():
+        grants.setdefault(row['role'], []).append(row['permission'])
+    return grants
+
+
+def set_role_permission(cursor, role: str, permission: str, granted: bool, actor: Dict[str, Any]) -> bool:
+    """Give a role a permission or take it away; False when it already was that way"""
+    _check(permission)
+    if granted:
+        cursor.execute("""
+            INSERT INTO role_permissions (role, permission, granted_by) VALUES (%s, %s, %s)
+            ON CONFLICT (role, permission) DO NOTHING
+            RETURNING role
+        """, (role, permission, actor['id']))
+    else:
+        cursor.execute(
+            "DELETE FROM role_permissions WHERE role = %s AND permission = %s RETURNING role", (role, permission)
+        )
+    if not cursor.fetchone():
+        return False
+    record_privileged(
+        cursor, actor, 'role_permission_granted' if granted else 'role_permission_revoked', 'role', role,
+        before={'permission': permission, 'held': not granted}, after={'permission': permission, 'held': granted}
+    )
+    invalidate()
+    return True
+
+
+# User grants
+def user_grants(cursor, user_id: str) -> Optional[Dict[str, Any]]:
+    """A user's roles, their own grants and revocations, and what they hold in the end; None for an unknown user"""
+    cursor.execute("SELECT id, username, role, publication_role FROM users WHERE id = %s", (user_id,))
+    user = cursor.fetchone()
+    if not user:
+        return None
+    cursor.execute("""
+        SELECT p.permission, p.granted, p.granted_by, p.created_at
+        FROM user_permissions p
+        WHERE p.user_id = %s
+        ORDER BY p.permission
+    """, (user_id,))
+    overrides = [dict(row) for row in cursor.fetchall()]
+    return {
+        'user_id': user['id'], 'username': user['username'], 'role': user['role'],
+        'publication_role': user['publication_role'], 'overrides': overrides,
+        'permissions': sorted(_load(cursor, str(user['id']), user['role'])),
+    }
+
+
+def set_user_permission(cursor, user_id: str, permission: str, granted: Optional[bool],
+                        actor: Dict[str, Any]) -> bool:
+    """Grant (True) or revoke (False) a permission for one user, or go back to their role's (None)
+
+    False when the user doesn't exist.
+    """
+    _check(permission)
+    # Row-level security hides other publications' users, so their administrators can't reach them
+    cursor.execute("SELECT id FROM users WHERE id = %s", (user_id,))
+    if not cursor.fetchone():
+        return False
+    cursor.execute("""
+        SELECT granted FROM user_permissions WHERE user_id = %s AND permission = %s FOR UPDATE
+    """, (user_id, permission))
+    row = cursor.fetchone()
+    before = row['granted'] if row else None
+
+    if granted is None:
+        cursor.execute("DELETE FROM user_permissions WHERE user_id = %s AND permission = %s", (user_id, permission))
+        action = 'user_permission_cleared'
+    else:
+        cursor.execute("""
+            INSERT INTO user_permissions (user_id, permission, granted, granted_by) VALUES (%s, %s, %s, %s)
+            ON CONFLICT (user_id, permission) DO UPDATE
+            SET granted = EXCLUDED.granted, granted_by = EXCLUDED.granted_by, created_at = NOW()
+        """, (user_id, permission, granted, actor['id']))
+        action = 'user_permission_granted' if granted else 'user_permission_revoked'
+
+    record_privileged(
+        cursor, actor, action, 'user', user_id,
+        before={'permission': permission, 'granted': before}, after={'permission': permission, 'granted': granted}
+    )
+    invalidate()
+    return True
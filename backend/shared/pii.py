@@ -0,0 +1,161 @@
+"""
+PII scrubbing for logs and error reports
+
+Personal data and credentials are masked before they leave the process in
+a log line, an error response or a recorded job failure:
+
+- email addresses keep their first character and domain: `j***@example.com`
+- IP addresses keep their network: `203.0.113.x`, `2001:db8:1::x`
+- JWTs, bearer tokens, issued keys and secrets (`svc_`, `synd_`, `oas_`,
+  `whsec_`) and `password=`/`token=`-style values become `[redacted]`
+
+Structured values are redacted by field: request models mark their
+sensitive fields with `Sensitive(kind=...)` (see shared/models.py), and
+well-known key names (password, token, email, ip_address...) are treated as
+sensitive wherever they appear. Scrubbing is on unless
+PII_SCRUBBING_ENABLED=false, which is only meant for local debugging.
+"""
+
+import os
+import re
+import logging
+import ipaddress
+from typing import Any, Dict, Optional, Type
+
+from shared.redaction import EMAIL_PATTERN
+
+ENABLED = os.getenv('PII_SCRUBBING_ENABLED', 'true').lower() == 'true'
+
+REDACTED = '[redacted]'
+EMAIL = 'email'
+IP = 'ip'
+SECRET = 'secret'
+
+# Key names treated as sensitive in any structure, with the kind of masking they get
+SENSITIVE_KEYS = {
+    'email': EMAIL, 'contact_email': EMAIL,
+    'ip': IP, 'ip_address': IP, 'client_ip': IP, 'remote_addr': IP,
+    'password': SECRET, 'token': SECRET, 'secret': SECRET, 'authorization': SECRET, 'cookie': SECRET,
+    'api_key': SECRET, 'code_verifier': SECRET, 'private_key': SECRET,
+}
+SENSITIVE_SUFFIXES = {'_password': SECRET, '_secret': SECRET, '_token': SECRET, '_email': EMAIL}
+
+TOKEN_PATTERNS = [
+    re.compile(r'\beyJ[\w-]+\.[\w-]+\.[\w-]*'),  # JWTs
+    re.compile(r'\b(?:svc|synd|oas|whsec)_[\w-]{8,}'),  # Keys and secrets this platform issues
+    re.compile(r'(?i)\bBearer\s+[\w.~+/=-]+'),
+]
+SECRET_ASSIGNMENT = re.compile(
+    r'''(?i)(["']?\b(?:password|passwd|secret|client_secret|token|access_token|refresh_token|api_key|'''
+    r'''code_verifier|authorization)["']?\s*[:=]\s*["']?)([^"'&\s,;}]+)'''
+)
+IPV4 = re.compile(r'\b(?:\d{1,3}\.){3}\d{1,3}\b')
+# Candidates only; anything that doesn't parse as an address (such as a time of day) is left alone
+IPV6 = re.compile(r'(?<![\w:.])[0-9A-Fa-f]{0,4}(?::[0-9A-Fa-f]{0,4}){2,7}(?![\w:])')
+
+# Loggers that don't propagate to the root logger but log client addresses and request paths
+SERVER_LOGGERS = ('uvicorn', 'uvicorn.access', 'uvicorn.error', 'gunicorn.access', 'gunicorn.error')
+
+
+def mask_email(email: str) -> str:
+    local, _, domain = email.partition('@')
+    return f"{local[:1]}***@{domain}" if domain else REDACTED
+
+
+def mask_ip(address: str) -> str:
+    try:
+        ip = ipaddress.ip_address(address.split('%')[0])
+    except ValueError:
+        return address
+    if ip.is_loopback or ip.is_unspecified:
+        return address
+    if ip.version == 4:
+        return '.'.join(address.split('.')[:3]) + '.x'
+    network = ipaddress.ip_network(f"{ip}/48", strict=False).network_address
+    return f"{str(network).rstrip(':')}::x"
+
+
+def scrub(text: str) -> str:
+    """Mask emails, IP addresses, tokens and secret assignments in free text"""
+    if not ENABLED or not text:
+        return text
+    text = SECRET_ASSIGNMENT.sub(lambda match: f"{match.group(1)}{REDACTED}", text)
+    for pattern in TOKEN_PATTERNS:
+        text = pattern.sub(REDACTED, text)
+    text = EMAIL_PATTERN.sub(lambda match: mask_email(match.group(0)), text)
+    text = IPV4.sub(lambda match: mask_ip(match.group(0)), text)
+    return IPV6.sub(lambda match: mask_ip(match.group(0)), text)
+
+
+def _mask(value: Any, kind: str) -> Any:
+    if value is None or value == '':
+        return value
+    if kind == EMAIL and isinstance(value, str):
+        return mask_email(value)
+    if kind == IP and isinstance(value, str):
+        return mask_ip(value)
+    return REDACTED
+
+
+def key_sensitivity(key: Any) -> Optional[str]:
+    """The kind of masking a key name calls for, or None if it isn't sensitive"""
+    if not isinstance(key, str):
+        return None
+    key = key.lower()
+    if key in SENSITIVE_KEYS:
+        return SENSITIVE_KEYS[key]
+    return next((kind for suffix, kind in SENSITIVE_SUFFIXES.items() if key.endswith(suffix)), None)
+
+
+def sensitive_fields(model: Type[Any]) -> Dict[str, str]:
+    """Fields a pydantic model marks with Sensitive(), by name and alias, and their kind"""
+    fields = {}
+    for name, info in getattr(model, 'model_fields', {}).items():
+        extra = info.json_schema_extra if isinstance(info.json_schema_extra, dict) else {}
+        if extra.get('sensitive'):
+            fields[name] = extra['sensitive']
+            if info.alias:
+                fields[info.alias] = extra['sensitive']
+    return fields
+
+
+def redact(value: Any, model: Optional[Type[Any]] = None) -> Any:
+    """A copy of `value` with sensitive fields masked and free text scrubbed, for logs, errors and audit details"""
+    if not ENABLED:
+        return value
+    if hasattr(value, 'model_dump'):
+        model = model or type(value)
+        value = value.model_dump(mode='json')
+    if isinstance(value, dict):
+        annotated = sensitive_fields(model) if model else {}
+        redacted = {}
+        for key, item in value.items():
+            kind = annotated.get(key) or key_sensitivity(key)
+            redacted[key] = _mask(item, kind) if kind and not isinstance(item, (dict, list)) else redact(item)
+        return redacted
+    if isinstance(value, (list, tuple)):
+        return [redact(item) for item in value]
+    if isinstance(value, str):
+        return scrub(value)
+    return value
+
+
+class ScrubbingFormatter(logging.Formatter):
+    """Formats records with the handler's own formatter, then scrubs the message, arguments and traceback together"""
+
+    def __init__(self, inner: Optional[logging.Formatter] = None):
+        super().__init__()
+        self.inner = inner or logging.Formatter()
+
+    def format(self, record: logging.LogRecord) -> str:
+        return scrub(self.inner.format(record))
+
+
+def install_log_scrubbing(*loggers: logging.Logger):
+    """Scrub everything the handlers of `loggers` write; the root and web server loggers by default"""
+    if not ENABLED:
+        return
+    for logger in loggers or [logging.getLogger()] + [logging.getLogger(name) for name in SERVER_LOGGERS]:
+        for handler in logger.handlers:
+            if not isinstance(handler.formatter, ScrubbingFormatter):
+                handler.setFormatter(ScrubbingFormatter(handler.formatter))
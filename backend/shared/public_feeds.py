@@ -0,0 +1,155 @@
+"""
+Public site feeds: latest articles overall, per tag and per author
+
+Each feed is loaded once and cached in Redis as article rows, so the RSS and
+JSON Feed renderings of the same feed share one query and one cache entry.
+"""
+
+import os
+import json
+import logging
+from typing import Any, Dict, List, Optional, Tuple
+
+from shared import tenancy
+from shared.database import get_postgres_cursor, get_redis
+from shared.feed_formats import PUBLIC_BASE_URL
+from shared.utils import deserialize_datetime, safe_json_dumps
+
+logger = logging.getLogger(__name__)
+
+FEED_SIZE = 50
+CACHE_TTL_SECONDS = int(os.getenv('PUBLIC_FEED_CACHE_TTL', 300))
+SITE_NAME = os.getenv('PUBLIC_SITE_NAME', 'Decentralized News')
+
+FEED_COLUMNS = """
+    a.id, a.title, a.summary, a.content, a.category, a.tags, a.language,
+    a.license, a.license_terms, a.published_at, a.updated_at, a.access_policy,
+    CASE WHEN a.anonymous_author THEN NULL ELSE u.username END AS author_name
+"""
+
+FEED_ARTICLES_QUERY = f"""
+    SELECT {FEED_COLUMNS}
+    FROM articles a
+    LEFT JOIN users u ON u.id = a.author_id
+    WHERE a.status = 'published' {{condition}}
+    ORDER BY a.published_at DESC
+    LIMIT %s
+"""
+
+
+def cache_key(scope: str, value: Optional[str]) -> str:
+    return f"public_feed:{tenancy.cache_namespace()}:{scope}:{value or ''}"
+
+
+def pending_cache_key(scope: str, value: Optional[str]) -> str:
+    return f"public_feed_pending:{tenancy.cache_namespace()}:{scope}:{value or ''}"
+
+
+def feeds_for_article(article: Dict[str, Any]) -> List[Tuple[str, Optional[str]]]:
+    """The feeds an article appears in, as (scope, value) pairs"""
+    feeds = [('all', None)] + [('tag', tag) for tag in article.get('tags') or []]
+    if article.get('author_id') and not article.get('anonymous_author'):
+        feeds.append(('author', str(article['author_id'])))
+    return feeds
+
+
+def _build_feed(cursor, scope: str, value: Optional[str]) -> Optional[Dict[str, Any]]:
+    base_url = PUBLIC_BASE_URL.rstrip('/')
+
+    if scope == 'all':
+        channel = {
+            'title': SITE_NAME,
+            'link': base_url,
+            'description': f"Latest articles on {SITE_NAME}",
+        }
+        condition, params = '', []
+    elif scope == 'tag':
+        channel = {
+            'title': f"{SITE_NAME}: #{value}",
+            'link': f"{base_url}/tags/{value}",
+            'description': f"Latest articles tagged {value}",
+        }
+        condition, params = 'AND %s = ANY(a.tags)', [value]
+    elif scope == 'author':
+        cursor.execute("SELECT id, username FROM users WHERE id::text = %s AND is_active = true", (value,))
+        author = cursor.fetchone()
+        if not author:
+            return None
+        channel = {
+            'title': f"{author['username']} on {SITE_NAME}",
+            'link': f"{base_url}/users/{author['id']}",
+            'description': f"Latest articles by {author['username']}",
+        }
+        # Anonymous articles must not be attributable through the author's feed
+        condition, params = 'AND a.author_id = %s AND NOT a.anonymous_author', [author['id']]
+    else:
+        raise ValueError(f"Unknown feed scope: {scope}")
+
+    cursor.execute(FEED_ARTICLES_QUERY.format(condition=condition), params + [FEED_SIZE])
+    return {**channel, 'articles': [dict(row) for row in cursor.fetchall()]}
+
+
+def load_feed(scope: str, value: Optional[str] = None) -> Optional[Dict[str, Any]]:
+    """Channel details and articles for a feed, or None if its author doesn't exist"""
+    key = cache_key(scope, value)
+    try:
+        cached = get_redis().get(key)
+        if cached:
+            feed = json.loads(cached)
+            for article in feed['articles']:
+                article['published_at'] = deserialize_datetime(article.get('published_at'))
+                article['updated_at'] = deserialize_datetime(article.get('updated_at'))
+            return feed
+    except Exception as e:
+        logger.warning(f"Feed cache read failed for {key}: {e}")
+
+    with get_postgres_cursor() as cursor:
+        feed = _build_feed(cursor, scope, value)
+
+    if feed is not None:
+        try:
+            get_redis().setex(key, CACHE_TTL_SECONDS, safe_json_dumps(feed))
+        except Exception as e:
+            logger.warning(f"Feed cache write failed for {key}: {e}")
+    return feed
+
+
+def stage_feed(cursor, scope: str, value: Optional[str], article_id: str, published_at, ttl_seconds: int) -> bool:
+    """Cache a feed as it will look once a scheduled article is published, without serving it yet"""
+    feed = _build_feed(cursor, scope, value)
+    if feed is None:
+        return False
+    cursor.execute(f"""
+        SELECT {FEED_COLUMNS}
+        FROM articles a
+        LEFT JOIN users u ON u.id = a.author_id
+        WHERE a.id = %s
+    """, (article_id,))
+    article = dict(cursor.fetchone())
+    article['published_at'] = published_at
+    feed['articles'] = sorted(
+        [article] + [row for row in feed['articles'] if str(row['id']) != str(article_id)],
+        key=lambda row: row['published_at'], reverse=True
+    )[:FEED_SIZE]
+    get_redis().setex(pending_cache_key(scope, value), ttl_seconds, safe_json_dumps(feed))
+    return True
+
+
+def promote_feed(scope: str, value: Optional[str]) -> bool:
+    """Serve a staged feed, returning False if nothing was staged"""
+    pipeline = get_redis().pipeline()
+    pipeline.rename(pending_cache_key(scope, value), cache_key(scope, value))
+    pipeline.expire(cache_key(scope, value), CACHE_TTL_SECONDS)
+    try:
+        pipeline.execute()
+        return True
+    except Exception:
+        return False
+
+
+def refresh_feed(scope: str, value: Optional[str]):
+    """Rebuild a feed's cache entry now rather than on the next request"""
+    with get_postgres_cursor() as cursor:
+        feed = _build_feed(cursor, scope, value)
+    if feed is not None:
+        get_redis().setex(cache_key(scope, value), CACHE_TTL_SECONDS, safe_json_dumps(feed))
@@ -0,0 +1,70 @@
+"""
+Article publishing side effects for both Flask and FastAPI backends
+
+Hooks run inside the publishing transaction with the same cursor, right after
+an article's status changes to published. A failing hook is logged and never
+blocks the publish itself.
+"""
+
+import logging
+from typing import Any, Callable, Dict, List
+
+logger = logging.getLogger(__name__)
+
+PublishHook = Callable[[Any, Dict[str, Any]], None]
+
+_publish_hooks: List[PublishHook] = []
+
+
+def register_publish_hook(hook: PublishHook) -> PublishHook:
+    """Register a function to run when an article is published"""
+    if hook not in _publish_hooks:
+        _publish_hooks.append(hook)
+    return hook
+
+
+def on_article_published(cursor, article: Dict[str, Any]):
+    """Run all publish hooks for a freshly published article"""
+    for hook in _publish_hooks:
+        try:
+            cursor.execute("SAVEPOINT publish_hook")
+            hook(cursor, article)
+            cursor.execute("RELEASE SAVEPOINT publish_hook")
+        except Exception as e:
+            cursor.execute("ROLLBACK TO SAVEPOINT publish_hook")
+            logger.error(f"Publish hook {hook.__name__} failed for article {article.get('id')}: {e}")
+
+
+def _register_default_hooks():
+    from shared.editorial_collections import apply_collection_rules
+    from shared.webhooks import emit_article_published
+    from shared.events import article_published
+    from shared.jobs import enqueue_snapshot
+    from shared.readability import score_published_article
+    from shared.activitypub import deliver_published_article
+    from shared.og_images import enqueue_og_image
+    from shared.link_previews import enqueue_link_previews
+    from shared.reputation import on_article_published as credit_author
+    from shared.feed_versions import bump_on_publish
+    from shared.article_stream import article_published as stream_article
+    from shared.media_uploads import publish_article_media
+    from shared.embeddings import enqueue_embedding
+    from shared.modules import is_enabled
+
+    register_publish_hook(score_published_article)
+    register_publish_hook(apply_collection_rules)
+    register_publish_hook(emit_article_published)
+    register_publish_hook(article_published)
+    register_publish_hook(enqueue_snapshot)
+    register_publish_hook(enqueue_og_image)
+    register_publish_hook(enqueue_link_previews)
+    register_publish_hook(credit_author)
+    if is_enabled('federation'):
+        register_publish_hook(deliver_published_article)
+    register_publish_hook(bump_on_publish)
+    register_publish_hook(stream_article)
+    register_publish_hook(publish_article_media)
+    register_publish_hook(enqueue_embedding)
+
+
+_register_default_hooks()
@@ -0,0 +1,309 @@
+"""
+Push notification delivery
+
+Users register the browsers (Web Push, signed with the node's VAPID key) and
+app installs (Firebase Cloud Messaging) that should receive their
+notifications. Every notification `shared.notifications` fans out is also
+queued as one `jobs.deliver_push` job per device, so users who aren't
+connected to `/ws` still hear about it. Users can additionally subscribe to
+category topics and get a push whenever an article is published in one.
+
+A device is removed as soon as its push service reports the token or
+subscription gone (Web Push 404/410, FCM UNREGISTERED), after
+PUSH_MAX_FAILURES consecutive failed deliveries, and once its client hasn't
+re-registered it for PUSH_DEVICE_MAX_AGE_DAYS.
+"""
+
+import os
+import json
+import logging
+from typing import Any, Dict, Iterable, List, Optional, Tuple
+
+import requests
+
+from shared.database import get_postgres_cursor
+from shared import event_schemas
+
+logger = logging.getLogger(__name__)
+
+WEBPUSH = 'webpush'
+FCM = 'fcm'
+
+VAPID_PUBLIC_KEY = os.getenv('VAPID_PUBLIC_KEY', '')
+VAPID_PRIVATE_KEY = os.getenv('VAPID_PRIVATE_KEY', '')
+VAPID_SUBJECT = os.getenv('VAPID_SUBJECT', 'mailto:admin@localhost')
+FCM_PROJECT_ID = os.getenv('FCM_PROJECT_ID', '')
+FCM_CREDENTIALS_FILE = os.getenv('FCM_CREDENTIALS_FILE', '')
+
+TTL_SECONDS = int(os.getenv('PUSH_TTL_SECONDS', 24 * 60 * 60))
+MAX_FAILURES = int(os.getenv('PUSH_MAX_FAILURES', 5))
+DEVICE_MAX_AGE_DAYS = int(os.getenv('PUSH_DEVICE_MAX_AGE_DAYS', 90))
+MAX_DEVICES_PER_USER = int(os.getenv('PUSH_MAX_DEVICES_PER_USER', 10))
+# Largest topic audience pushed per article, like NOTIFICATIONS_MAX_FOLLOWERS
+MAX_TOPIC_SUBSCRIBERS = int(os.getenv('PUSH_MAX_TOPIC_SUBSCRIBERS', 10000))
+
+FCM_SCOPE = 'https://www.googleapis.com/auth/firebase.messaging'
+
+
+class TokenGone(Exception):
+    """The push service no longer knows the device; it will never accept a push again"""
+
+
+class PushNotConfigured(Exception):
+    pass
+
+
+def platforms() -> List[str]:
+    """Platforms this node has credentials for"""
+    configured = []
+    if VAPID_PUBLIC_KEY and VAPID_PRIVATE_KEY:
+        configured.append(WEBPUSH)
+    if FCM_PROJECT_ID and FCM_CREDENTIALS_FILE:
+        configured.append(FCM)
+    return configured
+
+
+def register_device(cursor, user_id: str, platform: str, token: str,
+                    keys: Optional[Dict[str, str]] = None, label: Optional[str] = None) -> Dict[str, Any]:
+    """Register, or refresh, a device; a token registered by another user moves to this one"""
+    if platform not in platforms():
+        raise PushNotConfigured(f"{platform} push is not configured on this node")
+    cursor.execute("""
+        INSERT INTO push_devices (user_id, platform, token, keys, label)
+        VALUES (%s, %s, %s, %s, %s)
+        ON CONFLICT (platform, token) DO UPDATE
+        SET user_id = EXCLUDED.user_id, keys = EXCLUDED.keys, label = COALESCE(EXCLUDED.label, push_devices.label),
+            failure_count = 0, last_error = NULL, last_seen_at = NOW()
+        RETURNING *
+    """, (user_id, platform, token, keys or {}, label))
+    device = dict(cursor.fetchone())
+
+    # The oldest devices go once a user has too many; browsers rotate subscriptions without telling anyone
+    cursor.execute("""
+        DELETE FROM push_devices WHERE id IN (
+            SELECT id FROM push_devices WHERE user_id = %s ORDER BY last_seen_at DESC OFFSET %s
+        )
+    """, (user_id, MAX_DEVICES_PER_USER))
+    return device
+
+
+def _titles(kind: str, data: Dict[str, Any]) -> Tuple[str, str]:
+    """Title and body shown for a notification"""
+    if kind == 'comment':
+        return f"New comment on {data.get('article_title') or 'your article'}", data.get('excerpt') or ''
+    if kind == 'reply':
+        return f"{data.get('by') or 'Someone'} replied to your comment", data.get('excerpt') or ''
+    if kind == 'new_article':
+        by = data.get('author') or data.get('category') or 'New article'
+        return by, data.get('title') or ''
+    if kind == 'moderation':
+        return f"Your {data.get('subject', 'content')} was {data.get('decision')}", data.get('article_title') or ''
+    if kind == 'draft_comment':
+        return f"Draft comment {data.get('action')}", data.get('article_title') or ''
+    if kind == 'editorial':
+        action = (data.get('action') or 'updated').replace('_', ' ')
+        return f"Editorial review {action} by {data.get('by') or 'an editor'}", data.get('article_title') or ''
+    if kind == 'coauthor':
+        said = {
+            'invited': f"invited you to co-author as {data.get('role')}",
+            'accepted': 'accepted your co-author invitation', 'declined': 'declined your co-author invitation',
+            'left': 'stopped co-authoring', 'removed': 'removed your co-author credit',
+            'role_changed': f"credited you as {data.get('role')}",
+        }.get(data.get('action'), 'changed a co-author credit')
+        return f"{data.get('by') or 'An author'} {said}", data.get('article_title') or ''
+    return 'Notification', ''
+
+
+def message(notification: Dict[str, Any]) -> Dict[str, Any]:
+    title, body = _titles(notification['kind'], notification['data'])
+    return {
+        'id': notification['id'],
+        'kind': notification['kind'],
+        'title': title,
+        'body': body[:200],
+        'data': notification['data'],
+    }
+
+
+def send_webpush(device: Dict[str, Any], push: Dict[str, Any]):
+    from pywebpush import WebPushException, webpush
+
+    try:
+        webpush(
+            subscription_info={'endpoint': device['token'], 'keys': device['keys']},
+            data=json.dumps(push, default=str),
+            vapid_private_key=VAPID_PRIVATE_KEY,
+            vapid_claims={'sub': VAPID_SUBJECT},
+            ttl=TTL_SECONDS,
+            timeout=15,
+        )
+    except WebPushException as e:
+        if e.response is not None and e.response.status_code in (404, 410):
+            raise TokenGone(f"Subscription expired ({e.response.status_code})")
+        raise
+
+
+_fcm_credentials = None
+
+
+def _fcm_token() -> str:
+    global _fcm_credentials
+    from google.auth.transport.requests import Request
+    from google.oauth2 import service_account
+
+    if _fcm_credentials is None:
+        _fcm_credentials = service_account.Credentials.from_service_account_file(
+            FCM_CREDENTIALS_FILE, scopes=[FCM_SCOPE]
+        )
+    if not _fcm_credentials.valid:
+        _fcm_credentials.refresh(Request())
+    return _fcm_credentials.token
+
+
+def send_fcm(device: Dict[str, Any], push: Dict[str, Any]):
+    # FCM data values must be strings; the client parses `data` back out
+    response = requests.post(
+        f"https://fcm.googleapis.com/v1/projects/{FCM_PROJECT_ID}/messages:send",
+        headers={'Authorization': f"Bearer {_fcm_token()}"},
+        json={'message': {
+            'token': device['token'],
+            'notification': {'title': push['title'], 'body': push['body']},
+            'data': {'id': push['id'], 'kind': push['kind'], 'data': json.dumps(push['data'], default=str)},
+            'android': {'ttl': f"{TTL_SECONDS}s", 'priority': 'high'},
+        }},
+        timeout=15,
+    )
+    if response.ok:
+        return
+    try:
+        error = response.json().get('error', {})
+    except ValueError:
+        error = {}
+    codes = {detail.get('errorCode') for detail in error.get('details', [])}
+    if response.status_code == 404 or 'UNREGISTERED' in codes or (
+        response.status_code == 400 and error.get('status') == 'INVALID_ARGUMENT' and 'token' in error.get('message', '')
+    ):
+        raise TokenGone(f"Token no longer registered ({response.status_code})")
+    response.raise_for_status()
+
+
+SENDERS = {WEBPUSH: send_webpush, FCM: send_fcm}
+
+
+def deliver(device_id: str, notification: Dict[str, Any]) -> str:
+    """Send one notification to one device: 'sent', 'removed' or 'missing'; other failures raise for a retry"""
+    with get_postgres_cursor() as cursor:
+        cursor.execute("SELECT * FROM push_devices WHERE id = %s", (device_id,))
+        device = cursor.fetchone()
+    if not device:
+        return 'missing'
+    device = dict(device)
+
+    try:
+        SENDERS[device['platform']](device, message(notification))
+    except TokenGone as e:
+        remove_device(device_id, str(e))
+        return 'removed'
+    except Exception as e:
+        with get_postgres_cursor() as cursor:
+            cursor.execute("""
+                UPDATE push_devices SET failure_count = failure_count + 1, last_error = %s
+                WHERE id = %s RETURNING failure_count
+            """, (str(e)[:500], device_id))
+            row = cursor.fetchone()
+        if row and row['failure_count'] >= MAX_FAILURES:
+            remove_device(device_id, f"{row['failure_count']} failed deliveries, last: {e}")
+            return 'removed'
+        raise
+
+    with get_postgres_cursor() as cursor:
+        cursor.execute("""
+            UPDATE push_devices SET failure_count = 0, last_error = NULL, last_success_at = NOW() WHERE id = %s
+        """, (device_id,))
+    return 'sent'
+
+
+def remove_device(device_id: str, reason: str):
+    with get_postgres_cursor() as cursor:
+        cursor.execute("DELETE FROM push_devices WHERE id = %s", (device_id,))
+    logger.info(f"Removed push device {device_id}: {reason}")
+
+
+def prune_devices() -> int:
+    """Remove devices their clients haven't re-registered for PUSH_DEVICE_MAX_AGE_DAYS"""
+    with get_postgres_cursor() as cursor:
+        cursor.execute(
+            "DELETE FROM push_devices WHERE last_seen_at < NOW() - make_interval(days => %s)", (DEVICE_MAX_AGE_DAYS,)
+        )
+        return cursor.rowcount
+
+
+def topic_notifications(cursor, events: List[Dict[str, Any]],
+                        delivered: List[Tuple[str, Dict[str, Any]]]) -> List[Tuple[str, Dict[str, Any]]]:
+    """(user id, notification) for topic subscribers of newly published articles' categories
+
+    Followers of the author already got a `new_article` notification for
+    the article and aren't notified twice.
+    """
+    from shared.events import ARTICLE_PUBLISHED
+    from shared.notifications import new_notification
+
+    already = {
+        (user_id, notification['data'].get('article_id'))
+        for user_id, notification in delivered if notification['kind'] == 'new_article'
+    }
+    extra: List[Tuple[str, Dict[str, Any]]] = []
+    for event in events:
+        payload = event_schemas.read(event['payload'])
+        data = payload['data']
+        if payload['type'] != ARTICLE_PUBLISHED or not data.get('category'):
+            continue
+        cursor.execute("""
+            SELECT user_id FROM push_topic_subscriptions WHERE category = lower(%s) LIMIT %s
+        """, (data['category'], MAX_TOPIC_SUBSCRIBERS + 1))
+        subscribers = [str(row['user_id']) for row in cursor.fetchall()]
+        if len(subscribers) > MAX_TOPIC_SUBSCRIBERS:
+            logger.warning(f"Category {data['category']} has over {MAX_TOPIC_SUBSCRIBERS} push subscribers; skipped")
+            continue
+        notification = new_notification('new_article', {
+            'article_id': data['id'],
+            'title': data.get('title'),
+            'category': data['category'],
+            'topic': data['category'],
+        })
+        extra.extend(
+            (user_id, notification) for user_id in subscribers
+            if user_id != data.get('author_id') and (user_id, data['id']) not in already
+        )
+    return extra
+
+
+def _devices(cursor, user_ids: Iterable[str]) -> Dict[str, List[str]]:
+    ids = sorted(set(user_ids))
+    if not ids:
+        return {}
+    cursor.execute("""
+        SELECT id, user_id FROM push_devices WHERE user_id = ANY(%s::uuid[]) AND platform = ANY(%s)
+    """, (ids, platforms()))
+    devices: Dict[str, List[str]] = {}
+    for row in cursor.fetchall():
+        devices.setdefault(str(row['user_id']), []).append(str(row['id']))
+    return devices
+
+
+def enqueue(cursor, events: List[Dict[str, Any]], delivered: List[Tuple[str, Dict[str, Any]]]) -> int:
+    """Queue a push job per registered device for the notifications and any topic pushes the events cause"""
+    from shared.jobs import deliver_push
+    from shared.modules import is_enabled
+
+    if not platforms() or not is_enabled('push'):
+        return 0
+
+    delivered = delivered + topic_notifications(cursor, events, delivered)
+    devices = _devices(cursor, [user_id for user_id, _ in delivered])
+    queued = 0
+    for user_id, notification in delivered:
+        for device_id in devices.get(user_id, []):
+            deliver_push.delay(device_id, notification)
+            queued += 1
+    return queued
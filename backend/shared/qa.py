@@ -0,0 +1,78 @@
+"""
+Author Q&A sessions on articles
+
+Sessions close automatically: a session counts as open only while NOW() is
+between opens_at and closes_at and the author has not closed it early, so no
+background job is needed to end them.
+"""
+
+from typing import Any, Dict, List, Optional
+
+def open_session_condition(alias: str = '') -> str:
+    """SQL condition matching sessions that are currently open"""
+    prefix = f"{alias}." if alias else ''
+    return f"{prefix}opens_at <= NOW() AND {prefix}closes_at > NOW() AND {prefix}closed_at IS NULL"
+
+
+def session_state(session: Dict[str, Any]) -> str:
+    """scheduled, open or closed"""
+    if session.get('closed_at'):
+        return 'closed'
+    if not session.get('is_started'):
+        return 'scheduled'
+    return 'open' if session.get('is_open') else 'closed'
+
+
+def get_current_session(cursor, article_id: str) -> Optional[Dict[str, Any]]:
+    """The open or upcoming session for an article, else the most recent one"""
+    cursor.execute(f"""
+        SELECT *, ({open_session_condition()}) as is_open, (opens_at <= NOW()) as is_started
+        FROM qa_sessions
+        WHERE article_id = %s
+        ORDER BY (closed_at IS NULL AND closes_at > NOW()) DESC, opens_at DESC
+        LIMIT 1
+    """, (article_id,))
+    session = cursor.fetchone()
+    if not session:
+        return None
+    session = dict(session)
+    session['state'] = session_state(session)
+    return session
+
+
+def list_questions(cursor, session_id: str, user_id: Optional[str] = None,
+                   answered_only: bool = False, limit: Optional[int] = None) -> List[Dict[str, Any]]:
+    """Visible questions, answered first, then by upvotes"""
+    query = """
+        SELECT q.id, q.session_id, q.body, q.upvote_count, q.answer, q.answered_at, q.created_at,
+               u.username as asked_by,
+               EXISTS(SELECT 1 FROM qa_question_votes v WHERE v.question_id = q.id AND v.user_id::text = %s) as upvoted
+        FROM qa_questions q
+        JOIN users u ON u.id = q.user_id
+        WHERE q.session_id = %s AND q.is_hidden = false
+    """
+    params: List[Any] = [str(user_id) if user_id else '', session_id]
+    if answered_only:
+        query += " AND q.answer IS NOT NULL"
+    query += " ORDER BY (q.answer IS NOT NULL) DESC, q.upvote_count DESC, q.created_at ASC"
+    if limit:
+        query += " LIMIT %s"
+        params.append(limit)
+
+    cursor.execute(query, params)
+    return [dict(row) for row in cursor.fetchall()]
+
+
+def qa_highlights(cursor, article_id: str, limit: int = 5) -> List[Dict[str, Any]]:
+    """Most upvoted answered questions across all of an article's sessions"""
+    cursor.execute("""
+        SELECT q.id, q.session_id, q.body, q.upvote_count, q.answer, q.answered_at, q.created_at,
+               u.username as asked_by
+        FROM qa_questions q
+        JOIN qa_sessions s ON s.id = q.session_id
+        JOIN users u ON u.id = q.user_id
+        WHERE s.article_id = %s AND q.answer IS NOT NULL AND q.is_hidden = false
+        ORDER BY q.upvote_count DESC, q.answered_at DESC
+        LIMIT %s
+    """, (article_id, limit))
+    return [dict(row) for row in cursor.fetchall()]
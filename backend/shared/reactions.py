@@ -0,0 +1,96 @@
+"""
+Article reactions for both Flask and FastAPI backends
+
+The available reactions come from the `reactions` settings key. Each toggle
+keeps the per-article aggregate in `articles.reaction_counts` current and
+recomputes the engagement score, which weighs reactions by their configured
+weight.
+"""
+
+import logging
+from typing import Dict, List, Optional
+
+from shared.models import ReactionsConfig, ReactionTypeConfig
+from shared.settings import get_setting
+from shared.utils import calculate_engagement_score, generate_uuid, generate_session_id
+
+logger = logging.getLogger(__name__)
+
+
+def get_reaction_types(enabled_only: bool = True) -> List[ReactionTypeConfig]:
+    """Configured reaction types"""
+    config = ReactionsConfig(**get_setting('reactions'))
+    return [reaction for reaction in config.types if reaction.enabled or not enabled_only]
+
+
+def get_reaction_weights() -> Dict[str, float]:
+    return {reaction.key: reaction.weight for reaction in get_reaction_types(enabled_only=False)}
+
+
+def toggle_reaction(cursor, user_id: str, article_id: str, reaction: str) -> bool:
+    """Add or remove a reader's reaction, returning whether it is now set"""
+    cursor.execute("""
+        DELETE FROM user_interactions
+        WHERE user_id = %s AND article_id = %s AND interaction_type = 'reaction' AND reaction = %s
+        RETURNING id
+    """, (user_id, article_id, reaction))
+    removed = cursor.fetchone() is not None
+
+    if not removed:
+        cursor.execute("""
+            INSERT INTO user_interactions (
+                id, user_id, article_id, interaction_type, reaction, interaction_strength,
+                context_data, session_id, created_at
+            ) VALUES (%s, %s, %s, 'reaction', %s, %s, %s, %s, NOW())
+        """, (
+            generate_uuid(), user_id, article_id, reaction, 1.0,
+            {}, generate_session_id(user_id)
+        ))
+
+    cursor.execute("""
+        UPDATE articles SET reaction_counts = jsonb_set(
+            COALESCE(reaction_counts, '{}'::jsonb), ARRAY[%s],
+            to_jsonb(GREATEST(COALESCE((reaction_counts->>%s)::int, 0) + %s, 0))
+        )
+        WHERE id = %s
+    """, (reaction, reaction, -1 if removed else 1, article_id))
+
+    update_engagement_score(cursor, article_id)
+    return not removed
+
+
+def get_user_reactions(cursor, user_id: Optional[str], article_id: str) -> List[str]:
+    if not user_id:
+        return []
+    cursor.execute("""
+        SELECT reaction FROM user_interactions
+        WHERE user_id = %s AND article_id = %s AND interaction_type = 'reaction'
+    """, (user_id, article_id))
+    return [row['reaction'] for row in cursor.fetchall()]
+
+
+def update_engagement_score(cursor, article_id: str) -> Optional[float]:
+    """Recompute an article's engagement score from its counters and reactions"""
+    cursor.execute("""
+        SELECT a.view_count, a.like_count, a.share_count, a.comment_count, a.reading_time,
+               a.reaction_counts,
+               (SELECT AVG(time_spent) FROM user_interactions
+                WHERE article_id = a.id AND interaction_type = 'view') as time_spent_avg
+        FROM articles a WHERE a.id = %s
+    """, (article_id,))
+    stats = cursor.fetchone()
+    if not stats:
+        return None
+
+    score = calculate_engagement_score(
+        views=stats['view_count'] or 0,
+        likes=stats['like_count'] or 0,
+        shares=stats['share_count'] or 0,
+        comments=stats['comment_count'] or 0,
+        reading_time=stats['reading_time'] or 0,
+        time_spent_avg=float(stats['time_spent_avg'] or 0),
+        reaction_counts=stats['reaction_counts'] or {},
+        reaction_weights=get_reaction_weights()
+    )
+    cursor.execute("UPDATE articles SET engagement_score = %s WHERE id = %s", (score, article_id))
+    return score
@@ -0,0 +1,223 @@
+"""
+Readability scoring
+
+Articles are scored with the Flesch reading-ease formula adapted to their
+language (Flesch-Kincaid for English, with its grade level, and the published
+adaptations for Spanish, French, German, Italian, Dutch and Portuguese).
+Other languages get the sentence and word statistics but no score, since the
+formulas don't carry over. The score maps to a reading level that readers
+and education-focused clients can filter feeds by; authors get hints on what
+to change before publishing.
+
+Scores are stored on the article whenever its text changes and on publish;
+`backfill_readability` fills in articles stored before scoring existed.
+"""
+
+import re
+import logging
+from typing import Any, Dict, List, Optional
+
+logger = logging.getLogger(__name__)
+
+# Reading levels from easiest to hardest, with the lowest reading-ease score of each
+READING_LEVELS = [
+    ('elementary', 80),
+    ('middle_school', 60),
+    ('high_school', 50),
+    ('college', 30),
+    ('graduate', float('-inf')),
+]
+LEVEL_NAMES = [name for name, _ in READING_LEVELS]
+
+# Reading ease = base - sentence_weight * words/sentence - syllable_weight * syllables/word
+FORMULAS = {
+    'en': ('flesch_kincaid', 206.835, 1.015, 84.6),
+    'es': ('fernandez_huerta', 206.84, 1.02, 60.0),
+    'fr': ('kandel_moles', 207.0, 1.015, 73.6),
+    'de': ('amstad', 180.0, 1.0, 58.5),
+    'it': ('flesch_vacca', 217.0, 1.3, 60.0),
+    'nl': ('douma', 206.835, 0.93, 77.0),
+    'pt': ('martins', 248.835, 1.015, 84.6),
+}
+
+LONG_SENTENCE_WORDS = 25
+COMPLEX_WORD_SYLLABLES = 3
+LONG_PARAGRAPH_WORDS = 150
+MIN_WORDS_FOR_SCORE = 30
+
+VOWEL_GROUPS = re.compile(r'[aeiouyàáâãäåèéêëìíîïòóôõöùúûüý]+', re.I)
+WORD = re.compile(r"[^\W\d_]+(?:['’-][^\W\d_]+)*", re.UNICODE)
+SENTENCE_END = re.compile(r"[.!?…]+[\"'”’)\]]*(?=\s|$)")
+
+
+def plain_text(content: str) -> str:
+    """Article HTML as text, with block elements ending paragraphs"""
+    text = re.sub(r'(?i)<\s*(br|/p|/div|/li|/h[1-6]|/blockquote)[^>]*>', '\n\n', content or '')
+    text = re.sub(r'<[^>]+>', ' ', text)
+    return re.sub(r'&[a-z]+;|&#\d+;', ' ', text)
+
+
+def count_syllables(word: str, language: str = 'en') -> int:
+    """Vowel groups, with English silent final e dropped; at least one per word"""
+    word = word.lower()
+    count = len(VOWEL_GROUPS.findall(word))
+    if language == 'en' and word.endswith('e') and not word.endswith(('le', 'ee')) and count > 1:
+        count -= 1
+    return max(1, count)
+
+
+def _sentences(paragraph: str) -> List[str]:
+    return [sentence for sentence in SENTENCE_END.split(paragraph) if WORD.search(sentence)]
+
+
+def reading_level(reading_ease: Optional[float]) -> Optional[str]:
+    if reading_ease is None:
+        return None
+    for name, minimum in READING_LEVELS:
+        if reading_ease >= minimum:
+            return name
+    return LEVEL_NAMES[-1]
+
+
+def parse_reading_levels(value: str) -> Optional[List[str]]:
+    """Levels from a comma-separated query parameter, or None if any is unknown"""
+    levels = [level.strip() for level in value.split(',') if level.strip()]
+    if not levels or any(level not in LEVEL_NAMES for level in levels):
+        return None
+    return levels
+
+
+def analyze(content: str, language: Optional[str] = 'en') -> Dict[str, Any]:
+    """Sentence and word statistics of an article, with its reading-ease score where the language has one"""
+    language = (language or 'en').split('-')[0].lower()
+    paragraphs = [p for p in re.split(r'\n\s*\n', plain_text(content)) if WORD.search(p)]
+
+    sentence_count = 0
+    word_count = 0
+    syllable_count = 0
+    complex_words = 0
+    long_sentences = 0
+    long_paragraphs = 0
+    for paragraph in paragraphs:
+        paragraph_words = 0
+        for sentence in _sentences(paragraph):
+            words = WORD.findall(sentence)
+            sentence_count += 1
+            paragraph_words += len(words)
+            if len(words) > LONG_SENTENCE_WORDS:
+                long_sentences += 1
+            for word in words:
+                syllables = count_syllables(word, language)
+                syllable_count += syllables
+                if syllables >= COMPLEX_WORD_SYLLABLES:
+                    complex_words += 1
+        word_count += paragraph_words
+        if paragraph_words > LONG_PARAGRAPH_WORDS:
+            long_paragraphs += 1
+
+    metrics = {
+        'language': language,
+        'formula': None,
+        'reading_ease': None,
+        'grade_level': None,
+        'sentences': sentence_count,
+        'words': word_count,
+        'avg_sentence_length': round(word_count / sentence_count, 1) if sentence_count else 0,
+        'avg_syllables_per_word': round(syllable_count / word_count, 2) if word_count else 0,
+        'complex_word_ratio': round(complex_words / word_count, 3) if word_count else 0,
+        'long_sentences': long_sentences,
+        'long_paragraphs': long_paragraphs,
+    }
+
+    formula = FORMULAS.get(language)
+    if formula and word_count >= MIN_WORDS_FOR_SCORE:
+        name, base, sentence_weight, syllable_weight = formula
+        words_per_sentence = word_count / sentence_count
+        syllables_per_word = syllable_count / word_count
+        reading_ease = base - sentence_weight * words_per_sentence - syllable_weight * syllables_per_word
+        metrics['formula'] = name
+        metrics['reading_ease'] = round(max(0.0, min(100.0, reading_ease)), 1)
+        if language == 'en':
+            grade = 0.39 * words_per_sentence + 11.8 * syllables_per_word - 15.59
+            metrics['grade_level'] = round(max(0.0, grade), 1)
+
+    metrics['reading_level'] = reading_level(metrics['reading_ease'])
+    return metrics
+
+
+def readability_columns(content: str, language: Optional[str]) -> Dict[str, Any]:
+    """Values for the article's readability and reading_level columns"""
+    metrics = analyze(content, language)
+    return {'readability': metrics, 'reading_level': metrics['reading_level']}
+
+
+def store_readability(cursor, article_id: str, content: str, language: Optional[str]) -> Dict[str, Any]:
+    columns = readability_columns(content, language)
+    cursor.execute(
+        "UPDATE articles SET readability = %s, reading_level = %s WHERE id = %s",
+        (columns['readability'], columns['reading_level'], article_id)
+    )
+    return columns['readability']
+
+
+def score_published_article(cursor, article: Dict[str, Any]):
+    """Publish hook: make sure every published article carries its reading level"""
+    store_readability(cursor, article['id'], article['content'], article.get('language'))
+
+
+def improvement_hints(metrics: Dict[str, Any], target_level: Optional[str] = None) -> List[Dict[str, str]]:
+    """What an author could change to make the article easier to read, or reach `target_level`"""
+    hints = []
+    if not metrics['words']:
+        return hints
+
+    if metrics['long_sentences']:
+        hints.append({
+            'kind': 'long_sentences',
+            'message': f"{metrics['long_sentences']} sentences have more than {LONG_SENTENCE_WORDS} words; "
+                       "splitting them makes the article easier to follow.",
+        })
+    if metrics['avg_sentence_length'] > 20:
+        hints.append({
+            'kind': 'sentence_length',
+            'message': f"Sentences average {metrics['avg_sentence_length']} words; 15 to 20 reads comfortably.",
+        })
+    if metrics['complex_word_ratio'] > 0.15:
+        hints.append({
+            'kind': 'complex_words',
+            'message': f"{round(metrics['complex_word_ratio'] * 100)}% of words have three or more syllables; "
+                       "prefer shorter everyday words where they say the same thing.",
+        })
+    if metrics['long_paragraphs']:
+        hints.append({
+            'kind': 'long_paragraphs',
+            'message': f"{metrics['long_paragraphs']} paragraphs run over {LONG_PARAGRAPH_WORDS} words; "
+                       "break them up for readers on small screens.",
+        })
+    if metrics['reading_ease'] is None and metrics['words'] >= MIN_WORDS_FOR_SCORE:
+        hints.append({
+            'kind': 'unscored_language',
+            'message': f"No readability formula is available for '{metrics['language']}'; "
+                       "only sentence statistics are shown.",
+        })
+
+    level = metrics.get('reading_level')
+    if target_level and level and LEVEL_NAMES.index(level) > LEVEL_NAMES.index(target_level):
+        hints.insert(0, {
+            'kind': 'target_level',
+            'message': f"The article reads at {level.replace('_', ' ')} level, harder than the "
+                       f"{target_level.replace('_', ' ')} audience you're aiming for.",
+        })
+    return hints
+
+
+def backfill_readability(cursor, limit: int = 500) -> int:
+    """Score articles stored before readability scoring existed"""
+    cursor.execute(
+        "SELECT id, content, language FROM articles WHERE readability IS NULL ORDER BY created_at LIMIT %s",
+        (limit,)
+    )
+    articles = cursor.fetchall()
+    for article in articles:
+        store_readability(cursor, article['id'], article['content'], article['language'])
+    return len(articles)
@@ -0,0 +1,81 @@
+"""
+Redaction of syndication payloads
+
+Each partner carries a redaction policy naming payload fields it must not
+receive. On top of any policy, a fixed set of sensitive fields is always
+stripped and email addresses are scrubbed from metadata, so a missing or
+empty policy can never leak more than the safe baseline.
+"""
+
+import copy
+import re
+from typing import Any, Dict, List, Optional
+
+from shared.models import RedactionPolicy
+
+EMAIL_PATTERN = re.compile(r'[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}')
+EMAIL_REPLACEMENT = '[redacted email]'
+
+# Never syndicated, whatever the partner policy says
+ALWAYS_REDACTED: List[str] = [
+    'metadata.internal_notes',
+    'metadata.editor_notes',
+    'metadata.pending_corrections',
+    'metadata.contact_email',
+    'metadata.source_contacts',
+]
+
+
+def scrub_emails(value: Any) -> Any:
+    """Replace email addresses in every string nested inside value"""
+    if isinstance(value, str):
+        return EMAIL_PATTERN.sub(EMAIL_REPLACEMENT, value)
+    if isinstance(value, list):
+        return [scrub_emails(item) for item in value]
+    if isinstance(value, dict):
+        return {key: scrub_emails(item) for key, item in value.items()}
+    return value
+
+
+def remove_path(payload: Dict[str, Any], path: str):
+    """Remove a dotted field path; `*` matches every key at that level"""
+    head, _, rest = path.partition('.')
+    targets = list(payload.keys()) if head == '*' else [head]
+
+    for key in targets:
+        if key not in payload:
+            continue
+        if not rest:
+            del payload[key]
+        elif isinstance(payload[key], dict):
+            remove_path(payload[key], rest)
+        elif isinstance(payload[key], list):
+            for item in payload[key]:
+                if isinstance(item, dict):
+                    remove_path(item, rest)
+
+
+def redact_payload(payload: Dict[str, Any], policy: Optional[Dict[str, Any]] = None) -> Dict[str, Any]:
+    """Apply the baseline and a partner's policy to a syndication payload
+
+    An invalid stored policy raises instead of falling back, so the article is
+    withheld rather than sent unredacted.
+    """
+    policy = RedactionPolicy(**(policy or {}))
+    redacted = copy.deepcopy(payload)
+
+    for path in ALWAYS_REDACTED + policy.remove_fields:
+        remove_path(redacted, path)
+
+    metadata = redacted.get('metadata')
+    if isinstance(metadata, dict):
+        if policy.allowed_metadata_keys is not None:
+            metadata = {key: value for key, value in metadata.items() if key in policy.allowed_metadata_keys}
+        redacted['metadata'] = scrub_emails(metadata)
+
+    if policy.scrub_emails:
+        for field in ('title', 'summary', 'content'):
+            if isinstance(redacted.get(field), str):
+                redacted[field] = scrub_emails(redacted[field])
+
+    return redacted
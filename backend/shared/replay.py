@@ -0,0 +1,378 @@
+"""
+Event replay into projections
+
+The event outbox (shared/events.py) keeps every domain event after it is
+relayed, so the read models built from events can be rebuilt from it: a new
+consumer catches up on history, or a model that was lost or built by a
+buggy version is built again. A replay feeds the events of a time range to
+one registered projection, oldest first, in batches:
+
+    clickstream   - interaction events into the ClickHouse clickstream
+    search_index  - published and corrected articles embedded again for
+                    semantic search and related articles
+    broker        - every event published again to the event bus under
+                    EVENT_REPLAY_SUBJECT_PREFIX, for a consumer subscribing there
+
+Replays run as a background job and are stored in `event_replays`. After
+each batch the job records a checkpoint (the last event's time and id), so a
+replay paused, failed or interrupted by a worker crash resumes after the last
+event it applied. Projections must tolerate an event applied twice, since a
+batch that fails part-way is applied again. `rate_per_second` caps how fast
+events are applied, and can be changed while a replay runs, as can the batch
+size. A replay started with `reset` first clears the projection's model, if
+it has one.
+
+More projections are added with `register_projection`.
+"""
+
+import os
+import json
+import time
+import asyncio
+import logging
+from dataclasses import dataclass
+from datetime import datetime, timezone
+from typing import Any, Callable, Dict, List, Optional, Tuple
+
+from shared.database import get_postgres_cursor
+from shared import event_schemas, events as domain_events
+
+logger = logging.getLogger(__name__)
+
+DEFAULT_BATCH_SIZE = int(os.getenv('EVENT_REPLAY_BATCH_SIZE', 500))
+# A running replay that hasn't checkpointed for this long is taken to have lost its worker
+STALE_SECONDS = int(os.getenv('EVENT_REPLAY_STALE_SECONDS', 5 * 60))
+SUBJECT_PREFIX = os.getenv('EVENT_REPLAY_SUBJECT_PREFIX') or f"{domain_events.outbox_relay.prefix}.replay"
+
+
+class ReplayError(Exception):
+    """A replay can't be started or changed as asked"""
+
+
+@dataclass(frozen=True)
+class Projection:
+    name: str
+    description: str
+    event_types: List[str]
+    apply: Callable[[List[Dict[str, Any]]], int]  # Applies a batch of outbox rows; returns how many changed something
+    available: Callable[[], bool] = lambda: True
+    reset: Optional[Callable[[], None]] = None
+
+
+_projections: Dict[str, Projection] = {}
+
+
+def register_projection(projection: Projection) -> Projection:
+    """Make a projection available to replays; one registered again under its name replaces the earlier one"""
+    _projections[projection.name] = projection
+    return projection
+
+
+def projections() -> List[Projection]:
+    return list(_projections.values())
+
+
+def get_projection(name: str) -> Optional[Projection]:
+    return _projections.get(name)
+
+
+# Built-in projections
+def _apply_clickstream(batch: List[Dict[str, Any]]) -> int:
+    from shared import clickstream
+
+    return clickstream.buffer(batch)
+
+
+def _clickstream_available() -> bool:
+    from shared import clickstream
+
+    return clickstream.enabled()
+
+
+def _apply_search_index(batch: List[Dict[str, Any]]) -> int:
+    from shared import embeddings
+
+    article_ids = sorted({str(event['aggregate_id']) for event in batch if event['aggregate_id']})
+    if not article_ids:
+        return 0
+    with get_postgres_cursor() as cursor:
+        cursor.execute("SELECT * FROM articles WHERE id = ANY(%s::uuid[])", (article_ids,))
+        return embeddings.embed_articles(cursor, [dict(row) for row in cursor.fetchall()])
+
+
+def _search_index_available() -> bool:
+    from shared import embeddings
+
+    return embeddings.enabled()
+
+
+def _reset_search_index():
+    with get_postgres_cursor() as cursor:
+        cursor.execute("DELETE FROM article_vectors")
+
+
+async def _publish(batch: List[Dict[str, Any]]):
+    publisher = domain_events.PUBLISHERS[domain_events.outbox_relay.backend]()
+    await publisher.connect()
+    try:
+        for event in batch:
+            body = json.dumps(event_schemas.outgoing(event['payload']), separators=(',', ':')).encode()
+            await publisher.publish(f"{SUBJECT_PREFIX}.{event['event_type']}", event['aggregate_id'], body)
+    finally:
+        await publisher.close()
+
+
+def _apply_broker(batch: List[Dict[str, Any]]) -> int:
+    asyncio.run(_publish(batch))
+    return len(batch)
+
+
+def _broker_available() -> bool:
+    return domain_events.outbox_relay.backend in domain_events.PUBLISHERS
+
+
+register_projection(Projection(
+    name='clickstream',
+    description="Interaction events into the ClickHouse clickstream; events already there are deduplicated",
+    event_types=[domain_events.INTERACTION_RECORDED],
+    apply=_apply_clickstream,
+    available=_clickstream_available,
+))
+
+register_projection(Projection(
+    name='search_index',
+    description="Embed published and corrected articles again for semantic search and related articles",
+    event_types=[domain_events.ARTICLE_PUBLISHED, domain_events.ARTICLE_CORRECTED],
+    apply=_apply_search_index,
+    available=_search_index_available,
+    reset=_reset_search_index,
+))
+
+register_projection(Projection(
+    name='broker',
+    description=f"Publish events again to the event bus under the subject prefix {SUBJECT_PREFIX}",
+    event_types=list(domain_events.EVENT_TYPES),
+    apply=_apply_broker,
+    available=_broker_available,
+))
+
+
+# Replays
+def _with_progress(replay: Dict[str, Any]) -> Dict[str, Any]:
+    replay = dict(replay)
+    total, processed = replay['total_events'], replay['processed_events']
+    replay['percent'] = round(100.0 * processed / total, 1) if total else 100.0
+    elapsed = replay['running_seconds']
+    replay['events_per_second'] = round(processed / elapsed, 1) if elapsed and processed else None
+    remaining = max(total - processed, 0)
+    replay['eta_seconds'] = (
+        round(remaining / replay['events_per_second']) if replay['events_per_second'] and replay['status'] == 'running'
+        else None
+    )
+    return replay
+
+
+def _event_filter(replay: Dict[str, Any]) -> Tuple[str, List[Any]]:
+    conditions = ["event_type = ANY(%s)", "occurred_at <= %s"]
+    params: List[Any] = [replay['event_types'], replay['until_at']]
+    if replay['since_at']:
+        conditions.append("occurred_at >= %s")
+        params.append(replay['since_at'])
+    return ' AND '.join(conditions), params
+
+
+def create(cursor, projection_name: str, requested_by: Optional[str], event_types: Optional[List[str]] = None,
+           since: Optional[datetime] = None, until: Optional[datetime] = None, rate_per_second: Optional[float] = None,
+           batch_size: Optional[int] = None, reset: bool = False) -> Dict[str, Any]:
+    """Store a replay of the events from `since` to `until` (now by default); the caller enqueues it"""
+    projection = get_projection(projection_name)
+    if not projection:
+        raise ReplayError(f"Unknown projection '{projection_name}'")
+    if not projection.available():
+        raise ReplayError(f"Projection '{projection_name}' isn't configured on this deployment")
+    if reset and not projection.reset:
+        raise ReplayError(f"Projection '{projection_name}' can't be reset")
+    selected = [event_type for event_type in projection.event_types if not event_types or event_type in event_types]
+    if not selected:
+        raise ReplayError(f"Projection '{projection_name}' consumes none of {', '.join(event_types)}")
+
+    cursor.execute("""
+        SELECT id FROM event_replays WHERE projection = %s AND status IN ('pending', 'running')
+    """, (projection_name,))
+    if cursor.fetchone():
+        raise ReplayError(f"A replay into '{projection_name}' is already running; pause or cancel it first")
+
+    until = until or datetime.now(timezone.utc)
+    where, params = _event_filter({'event_types': selected, 'since_at': since, 'until_at': until})
+    cursor.execute(f"SELECT COUNT(*) AS total FROM event_outbox WHERE {where}", params)
+    total = cursor.fetchone()['total']
+
+    cursor.execute("""
+        INSERT INTO event_replays (
+            projection, event_types, since_at, until_at, rate_per_second, batch_size, reset, total_events, requested_by
+        )
+        VALUES (%s, %s, %s, %s, %s, %s, %s, %s, %s)
+        RETURNING *
+    """, (
+        projection_name, selected, since, until, rate_per_second, batch_size or DEFAULT_BATCH_SIZE, reset, total,
+        requested_by,
+    ))
+    return _with_progress(cursor.fetchone())
+
+
+def get(cursor, replay_id: str) -> Optional[Dict[str, Any]]:
+    cursor.execute("SELECT * FROM event_replays WHERE id = %s", (replay_id,))
+    replay = cursor.fetchone()
+    return _with_progress(replay) if replay else None
+
+
+def list_replays(cursor, status: Optional[str] = None, limit: int = 20) -> List[Dict[str, Any]]:
+    cursor.execute("""
+        SELECT * FROM event_replays
+        WHERE %s::text IS NULL OR status = %s
+        ORDER BY created_at DESC
+        LIMIT %s
+    """, (status, status, limit))
+    return [_with_progress(row) for row in cursor.fetchall()]
+
+
+def update(cursor, replay_id: str, changes: Dict[str, Any]) -> Optional[Dict[str, Any]]:
+    """Change a replay's `rate_per_second` (None for no limit) or `batch_size`; a running replay picks them up"""
+    changes = {field: value for field, value in changes.items() if field in ('rate_per_second', 'batch_size')}
+    if not changes:
+        return get(cursor, replay_id)
+    assignments = ', '.join(f"{field} = %s" for field in changes)
+    cursor.execute(
+        f"UPDATE event_replays SET {assignments}, updated_at = NOW() WHERE id = %s RETURNING *",
+        list(changes.values()) + [replay_id]
+    )
+    replay = cursor.fetchone()
+    return _with_progress(replay) if replay else None
+
+
+def pause(cursor, replay_id: str) -> Optional[Dict[str, Any]]:
+    """Stop a replay after its current batch; it resumes from its checkpoint"""
+    cursor.execute("""
+        UPDATE event_replays SET status = 'paused', updated_at = NOW()
+        WHERE id = %s AND status IN ('pending', 'running')
+        RETURNING *
+    """, (replay_id,))
+    replay = cursor.fetchone()
+    return _with_progress(replay) if replay else None
+
+
+def resume(cursor, replay_id: str) -> Optional[Dict[str, Any]]:
+    """Queue a paused or failed replay again, or one whose worker stopped checkpointing; the caller enqueues it"""
+    cursor.execute("""
+        UPDATE event_replays SET status = 'pending', last_error = NULL, updated_at = NOW()
+        WHERE id = %s AND (
+            status IN ('paused', 'failed')
+            OR (status = 'running' AND updated_at < NOW() - make_interval(secs => %s))
+        )
+        RETURNING *
+    """, (replay_id, STALE_SECONDS))
+    replay = cursor.fetchone()
+    return _with_progress(replay) if replay else None
+
+
+def cancel(cursor, replay_id: str) -> Optional[Dict[str, Any]]:
+    cursor.execute("""
+        UPDATE event_replays SET status = 'cancelled', finished_at = NOW(), updated_at = NOW()
+        WHERE id = %s AND status IN ('pending', 'running', 'paused', 'failed')
+        RETURNING *
+    """, (replay_id,))
+    replay = cursor.fetchone()
+    return _with_progress(replay) if replay else None
+
+
+def _claim(replay_id: str) -> Optional[Dict[str, Any]]:
+    with get_postgres_cursor() as cursor:
+        cursor.execute("""
+            UPDATE event_replays
+            SET status = 'running', started_at = COALESCE(started_at, NOW()), updated_at = NOW()
+            WHERE id = %s AND status = 'pending'
+            RETURNING *
+        """, (replay_id,))
+        replay = cursor.fetchone()
+    return dict(replay) if replay else None
+
+
+def _next_batch(replay: Dict[str, Any]) -> List[Dict[str, Any]]:
+    where, params = _event_filter(replay)
+    if replay['checkpoint_at']:
+        where += " AND (occurred_at, id) > (%s, %s)"
+        params += [replay['checkpoint_at'], replay['checkpoint_id']]
+    with get_postgres_cursor() as cursor:
+        cursor.execute(f"""
+            SELECT id, event_type, aggregate_id, payload, occurred_at FROM event_outbox
+            WHERE {where}
+            ORDER BY occurred_at, id
+            LIMIT %s
+        """, params + [replay['batch_size']])
+        return [dict(row) for row in cursor.fetchall()]
+
+
+def _checkpoint(replay_id: str, last: Dict[str, Any], applied: int, changed: int,
+                seconds: float) -> Optional[Dict[str, Any]]:
+    """Record a batch; returns the replay as it now stands, with any rate or status change made meanwhile"""
+    with get_postgres_cursor() as cursor:
+        cursor.execute("""
+            UPDATE event_replays
+            SET checkpoint_at = %s, checkpoint_id = %s, processed_events = processed_events + %s,
+                changed_events = changed_events + %s, running_seconds = running_seconds + %s, updated_at = NOW()
+            WHERE id = %s
+            RETURNING *
+        """, (last['occurred_at'], last['id'], applied, changed, seconds, replay_id))
+        replay = cursor.fetchone()
+    return dict(replay) if replay else None
+
+
+def _finish(replay_id: str, status: str, error: Optional[str] = None):
+    with get_postgres_cursor() as cursor:
+        cursor.execute("""
+            UPDATE event_replays SET status = %s, last_error = %s, finished_at = NOW(), updated_at = NOW()
+            WHERE id = %s AND status = 'running'
+        """, (status, error, replay_id))
+
+
+def run(replay_id: str) -> Optional[Dict[str, Any]]:
+    """Apply a pending replay batch by batch until it is done, paused or cancelled; None if it wasn't pending"""
+    replay = _claim(replay_id)
+    if not replay:
+        logger.info(f"Replay {replay_id} isn't pending; nothing to run")
+        return None
+    projection = get_projection(replay['projection'])
+    if not projection:
+        _finish(replay_id, 'failed', f"Projection '{replay['projection']}' is no longer registered")
+        return None
+
+    try:
+        if replay['reset'] and not replay['checkpoint_at']:
+            projection.reset()
+            logger.info(f"Replay {replay_id} reset projection {projection.name}")
+
+        while replay['status'] == 'running':
+            started = time.monotonic()
+            batch = _next_batch(replay)
+            if not batch:
+                _finish(replay_id, 'completed')
+                break
+            changed = projection.apply(batch)
+            # Hold the batch to the rate, so the batch after it starts no sooner than the rate allows
+            if replay['rate_per_second']:
+                time.sleep(max(len(batch) / float(replay['rate_per_second']) - (time.monotonic() - started), 0))
+            replay = _checkpoint(replay_id, batch[-1], len(batch), changed or 0, time.monotonic() - started)
+            if not replay:
+                break
+    except Exception as e:
+        logger.error(f"Replay {replay_id} into {projection.name} failed: {e}")
+        _finish(replay_id, 'failed', str(e)[:1000])
+
+    with get_postgres_cursor() as cursor:
+        final = get(cursor, replay_id)
+    if final:
+        logger.info(
+            f"Replay {replay_id} into {projection.name} {final['status']}: "
+            f"{final['processed_events']}/{final['total_events']} events"
+        )
+    return final
@@ -0,0 +1,553 @@
+"""
+Repository layer for users, articles and interactions
+
+Handlers talk to these interfaces instead of embedding SQL. The PostgreSQL
+implementations run on the caller's cursor, so everything a handler does
+through them (and through publish hooks, events, etc. on the same cursor)
+commits or rolls back together. The in-memory implementations let handlers be
+exercised without a database by overriding the `get_repositories` dependency.
+
+With tenancy enabled (shared/tenancy.py) repositories are scoped to the
+request's publication: they only find, update and count its users, articles
+and interactions, and create rows in it. A row from another publication
+coming back anyway raises CrossTenantAccess rather than reaching the handler.
+`exists` checks usernames and emails across every publication, since they are
+unique deployment-wide, through a database function that row-level security
+lets see them all.
+"""
+
+import uuid
+from dataclasses import dataclass
+from datetime import datetime
+from typing import Any, Dict, Iterable, List, Optional, Protocol, Tuple
+
+from shared.database import prepare_json_data
+from shared.utils import generate_session_id
+
+
+# Query building
+def build_insert(table: str, values: Dict[str, Any], allowed: Iterable[str],
+                 returning: str = '*') -> Tuple[str, List[Any]]:
+    """INSERT for the given columns; column names are checked against `allowed`"""
+    columns = list(values)
+    _check_columns(table, columns, allowed)
+    query = (
+        f"INSERT INTO {table} ({', '.join(columns)}) "
+        f"VALUES ({', '.join(['%s'] * len(columns))})"
+    )
+    if returning:
+        query += f" RETURNING {returning}"
+    return query, [values[column] for column in columns]
+
+
+def build_update(table: str, values: Dict[str, Any], allowed: Iterable[str],
+                 where: Dict[str, Any], returning: str = '*',
+                 scope: Optional['TenantScope'] = None) -> Tuple[str, List[Any]]:
+    """UPDATE ... SET for the given columns, matching every `where` column (and the tenant scope, if given)"""
+    _check_columns(table, values, allowed)
+    _check_columns(table, where, allowed)
+    assignments = [f"{column} = %s" for column in values]
+    conditions = [f"{column} = %s" for column in where]
+    params = list(values.values()) + list(where.values())
+    if scope:
+        condition, scope_params = scope.condition()
+        conditions.append(condition)
+        params.extend(scope_params)
+    query = f"UPDATE {table} SET {', '.join(assignments)} WHERE {' AND '.join(conditions)}"
+    if returning:
+        query += f" RETURNING {returning}"
+    return query, params
+
+
+def _check_columns(table: str, columns: Iterable[str], allowed: Iterable[str]):
+    unknown = set(columns) - set(allowed)
+    if unknown:
+        raise ValueError(f"Unknown {table} columns: {', '.join(sorted(unknown))}")
+
+
+def _json_values(values: Dict[str, Any], json_columns: Iterable[str]) -> Dict[str, Any]:
+    return {
+        column: prepare_json_data(value) if column in json_columns and isinstance(value, (dict, list)) else value
+        for column, value in values.items()
+    }
+
+
+# Tenant scoping
+@dataclass(frozen=True)
+class TenantScope:
+    """The publication whose rows a repository sees; `tenant_id` None is the default publication"""
+    tenant_id: Optional[str] = None
+
+    def condition(self, column: str = 'tenant_id') -> Tuple[str, List[Any]]:
+        if self.tenant_id is None:
+            return f"{column} IS NULL", []
+        return f"{column} = %s", [self.tenant_id]
+
+    def where(self, query: str, params: Iterable[Any]) -> Tuple[str, List[Any]]:
+        """Add the scope to a query whose WHERE clause comes last"""
+        condition, scope_params = self.condition()
+        return f"{query} AND {condition}", list(params) + scope_params
+
+    def contains(self, row: Dict[str, Any]) -> bool:
+        return str(row.get('tenant_id') or '') == str(self.tenant_id or '')
+
+    def stamp(self, values: Dict[str, Any]) -> Dict[str, Any]:
+        """Values for a new row, created in this publication"""
+        return {**values, 'tenant_id': self.tenant_id}
+
+
+class CrossTenantAccess(Exception):
+    """A repository query returned another publication's row"""
+
+
+def _guarded(scope: Optional[TenantScope], row: Optional[Dict[str, Any]]) -> Optional[Dict[str, Any]]:
+    """The row as a dict, refusing one outside the scope"""
+    if row is None:
+        return None
+    row = dict(row)
+    if scope and not scope.contains(row):
+        raise CrossTenantAccess(f"Row {row.get('id')} belongs to another publication")
+    return row
+
+
+def current_scope() -> Optional[TenantScope]:
+    """The request's publication when tenancy is enabled; None leaves repositories unscoped"""
+    from shared import tenancy
+
+    return TenantScope(tenancy.current_id()) if tenancy.enabled() else None
+
+
+def _scoped(scope: Optional[TenantScope], query: str, params: Iterable[Any]) -> Tuple[str, List[Any]]:
+    return scope.where(query, params) if scope else (query, list(params))
+
+
+# Interfaces
+class UserRepository(Protocol):
+    def get_by_id(self, user_id: str, active_only: bool = True) -> Optional[Dict[str, Any]]: ...
+    def get_by_email(self, email: str, active_only: bool = True) -> Optional[Dict[str, Any]]: ...
+    def exists(self, email: str, username: str) -> bool: ...
+    def create(self, values: Dict[str, Any]) -> Dict[str, Any]: ...
+    def update(self, user_id: str, values: Dict[str, Any]) -> Optional[Dict[str, Any]]: ...
+    def touch_last_active(self, user_id: str) -> None: ...
+    def members(self, publication_role: Optional[str] = None) -> List[Dict[str, Any]]: ...
+
+
+class ArticleRepository(Protocol):
+    def get(self, article_id: str, published_only: bool = False) -> Optional[Dict[str, Any]]: ...
+    def exists(self, article_id: str) -> bool: ...
+    def create(self, values: Dict[str, Any]) -> Dict[str, Any]: ...
+    def update(self, article_id: str, values: Dict[str, Any]) -> Optional[Dict[str, Any]]: ...
+    def increment_counter(self, article_id: str, counter: str, amount: int = 1) -> None: ...
+    def get_stats(self, article_id: str) -> Optional[Dict[str, Any]]: ...
+
+
+class InteractionRepository(Protocol):
+    def record(self, user_id: str, article_id: str, interaction_type: str,
+               values: Optional[Dict[str, Any]] = None) -> Dict[str, Any]: ...
+    def has(self, user_id: str, article_id: str, interaction_type: str) -> bool: ...
+    def remove(self, user_id: str, article_id: str, interaction_type: str) -> int: ...
+    def is_saved(self, user_id: str, article_id: str) -> bool: ...
+    def save(self, user_id: str, article_id: str, collection_name: str = 'default') -> None: ...
+    def unsave(self, user_id: str, article_id: str) -> int: ...
+
+
+# PostgreSQL implementations
+USER_COLUMNS = frozenset({
+    'id', 'username', 'email', 'password_hash', 'role', 'anonymous_mode', 'profile_data',
+    'preferences', 'is_active', 'verification_status', 'reputation_score',
+    'created_at', 'updated_at', 'last_active', 'deletion_requested_at', 'deletion_scheduled_for', 'deleted_at',
+    'tenant_id', 'publication_role',
+})
+USER_JSON_COLUMNS = frozenset({'profile_data', 'preferences'})
+
+ARTICLE_COLUMNS = frozenset({
+    'id', 'title', 'content', 'summary', 'author_id', 'anonymous_author', 'category', 'subcategory',
+    'tags', 'language', 'reading_time', 'word_count', 'status', 'published_at', 'created_at',
+    'updated_at', 'metadata', 'source_url', 'image_urls', 'seo_keywords', 'quality_score',
+    'license', 'license_terms', 'readability', 'reading_level', 'og_image_url', 'scheduled_publish_at',
+    'access_policy', 'tenant_id',
+})
+ARTICLE_JSON_COLUMNS = frozenset({'metadata', 'readability', 'access_policy'})
+ARTICLE_COUNTERS = frozenset({'view_count', 'like_count', 'comment_count', 'share_count'})
+
+INTERACTION_COLUMNS = frozenset({
+    'id', 'user_id', 'article_id', 'interaction_type', 'interaction_strength', 'reading_progress',
+    'time_spent', 'device_type', 'context_data', 'session_id', 'reaction', 'created_at', 'tenant_id',
+})
+INTERACTION_JSON_COLUMNS = frozenset({'context_data'})
+
+
+class PostgresUserRepository:
+    def __init__(self, cursor, scope: Optional[TenantScope] = None):
+        self.cursor = cursor
+        self.scope = scope
+
+    def get_by_id(self, user_id: str, active_only: bool = True) -> Optional[Dict[str, Any]]:
+        query = "SELECT * FROM users WHERE id = %s"
+        if active_only:
+            query += " AND is_active = true"
+        self.cursor.execute(*_scoped(self.scope, query, (str(user_id),)))
+        return _guarded(self.scope, self.cursor.fetchone())
+
+    def get_by_email(self, email: str, active_only: bool = True) -> Optional[Dict[str, Any]]:
+        query = "SELECT * FROM users WHERE email = %s"
+        if active_only:
+            query += " AND is_active = true"
+        self.cursor.execute(*_scoped(self.scope, query, (email,)))
+        return _guarded(self.scope, self.cursor.fetchone())
+
+    def exists(self, email: str, username: str) -> bool:
+        self.cursor.execute("SELECT user_identity_taken(%s, %s) AS taken", (email, username))
+        return self.cursor.fetchone()['taken']
+
+    def create(self, values: Dict[str, Any]) -> Dict[str, Any]:
+        if self.scope:
+            from shared.tenancy import check_quota
+            check_quota(self.cursor, 'users')
+            values = self.scope.stamp(values)
+        query, params = build_insert('users', _json_values(values, USER_JSON_COLUMNS), USER_COLUMNS)
+        self.cursor.execute(query, params)
+        return _guarded(self.scope, self.cursor.fetchone())
+
+    def update(self, user_id: str, values: Dict[str, Any]) -> Optional[Dict[str, Any]]:
+        values = {**values, 'updated_at': datetime.now()}
+        query, params = build_update(
+            'users', _json_values(values, USER_JSON_COLUMNS), USER_COLUMNS, {'id': str(user_id)}, scope=self.scope
+        )
+        self.cursor.execute(query, params)
+        return _guarded(self.scope, self.cursor.fetchone())
+
+    def touch_last_active(self, user_id: str) -> None:
+        self.cursor.execute(*_scoped(
+            self.scope, "UPDATE users SET last_active = %s WHERE id = %s", (datetime.now(), str(user_id))
+        ))
+
+    def members(self, publication_role: Optional[str] = None) -> List[Dict[str, Any]]:
+        """The publication's editors and contributors, or those with `publication_role`"""
+        query = """
+            SELECT id, username, email, role, publication_role, tenant_id, created_at, last_active FROM users
+            WHERE deleted_at IS NULL AND publication_role IS NOT NULL
+        """
+        params = []
+        if publication_role:
+            query += " AND publication_role = %s"
+            params.append(publication_role)
+        query, params = _scoped(self.scope, query, params)
+        self.cursor.execute(query + " ORDER BY publication_role, username", params)
+        return [_guarded(self.scope, row) for row in self.cursor.fetchall()]
+
+
+class PostgresArticleRepository:
+    def __init__(self, cursor, scope: Optional[TenantScope] = None):
+        self.cursor = cursor
+        self.scope = scope
+
+    def get(self, article_id: str, published_only: bool = False) -> Optional[Dict[str, Any]]:
+        query = "SELECT * FROM articles WHERE id = %s"
+        if published_only:
+            query += " AND status = 'published'"
+        self.cursor.execute(*_scoped(self.scope, query, (str(article_id),)))
+        return _guarded(self.scope, self.cursor.fetchone())
+
+    def exists(self, article_id: str) -> bool:
+        self.cursor.execute(*_scoped(self.scope, "SELECT id FROM articles WHERE id = %s", (str(article_id),)))
+        return self.cursor.fetchone() is not None
+
+    def create(self, values: Dict[str, Any]) -> Dict[str, Any]:
+        if self.scope:
+            from shared.tenancy import check_quota
+            check_quota(self.cursor, 'articles')
+            values = self.scope.stamp(values)
+        query, params = build_insert('articles', _json_values(values, ARTICLE_JSON_COLUMNS), ARTICLE_COLUMNS)
+        self.cursor.execute(query, params)
+        return _guarded(self.scope, self.cursor.fetchone())
+
+    def update(self, article_id: str, values: Dict[str, Any]) -> Optional[Dict[str, Any]]:
+        values = {**values, 'updated_at': datetime.now()}
+        query, params = build_update(
+            'articles', _json_values(values, ARTICLE_JSON_COLUMNS), ARTICLE_COLUMNS, {'id': str(article_id)},
+            scope=self.scope
+        )
+        self.cursor.execute(query, params)
+        return _guarded(self.scope, self.cursor.fetchone())
+
+    def increment_counter(self, article_id: str, counter: str, amount: int = 1) -> None:
+        if counter not in ARTICLE_COUNTERS:
+            raise ValueError(f"Unknown article counter '{counter}'")
+        self.cursor.execute(*_scoped(
+            self.scope, f"UPDATE articles SET {counter} = GREATEST({counter} + %s, 0) WHERE id = %s",
+            (amount, str(article_id))
+        ))
+
+    def get_stats(self, article_id: str) -> Optional[Dict[str, Any]]:
+        self.cursor.execute(*_scoped(self.scope, """
+            SELECT like_count, view_count, share_count, comment_count, reaction_counts, clap_count
+            FROM articles WHERE id = %s
+        """, (str(article_id),)))
+        stats = self.cursor.fetchone()
+        return dict(stats) if stats else None
+
+
+class PostgresInteractionRepository:
+    def __init__(self, cursor, scope: Optional[TenantScope] = None):
+        self.cursor = cursor
+        self.scope = scope
+
+    def record(self, user_id: str, article_id: str, interaction_type: str,
+               values: Optional[Dict[str, Any]] = None) -> Dict[str, Any]:
+        row = {
+            'id': str(uuid.uuid4()),
+            'user_id': str(user_id),
+            'article_id': str(article_id),
+            'interaction_type': interaction_type,
+            'interaction_strength': 1.0,
+            'context_data': {},
+            'session_id': generate_session_id(str(user_id)),
+            'created_at': datetime.now(),
+            **(values or {}),
+        }
+        if self.scope:
+            row = self.scope.stamp(row)
+        query, params = build_insert(
+            'user_interactions', _json_values(row, INTERACTION_JSON_COLUMNS), INTERACTION_COLUMNS
+        )
+        self.cursor.execute(query, params)
+        return _guarded(self.scope, self.cursor.fetchone())
+
+    def has(self, user_id: str, article_id: str, interaction_type: str) -> bool:
+        query, params = _scoped(
+            self.scope,
+            "SELECT 1 FROM user_interactions WHERE user_id = %s AND article_id = %s AND interaction_type = %s",
+            (str(user_id), str(article_id), interaction_type)
+        )
+        self.cursor.execute(f"SELECT EXISTS({query})", params)
+        return self.cursor.fetchone()['exists']
+
+    def remove(self, user_id: str, article_id: str, interaction_type: str) -> int:
+        self.cursor.execute(*_scoped(self.scope, """
+            DELETE FROM user_interactions
+            WHERE user_id = %s AND article_id = %s AND interaction_type = %s
+        """, (str(user_id), str(article_id), interaction_type)))
+        return self.cursor.rowcount
+
+    def is_saved(self, user_id: str, article_id: str) -> bool:
+        self.cursor.execute("""
+            SELECT EXISTS(SELECT 1 FROM saved_articles WHERE user_id = %s AND article_id = %s)
+        """, (str(user_id), str(article_id)))
+        return self.cursor.fetchone()['exists']
+
+    def save(self, user_id: str, article_id: str, collection_name: str = 'default') -> None:
+        self.cursor.execute("""
+            INSERT INTO saved_articles (id, user_id, article_id, collection_name, created_at)
+            VALUES (%s, %s, %s, %s, NOW())
+        """, (str(uuid.uuid4()), str(user_id), str(article_id), collection_name))
+
+    def unsave(self, user_id: str, article_id: str) -> int:
+        self.cursor.execute(
+            "DELETE FROM saved_articles WHERE user_id = %s AND article_id = %s",
+            (str(user_id), str(article_id))
+        )
+        return self.cursor.rowcount
+
+
+@dataclass
+class Repositories:
+    """The repositories a handler works with, sharing one transaction"""
+    cursor: Any
+    users: UserRepository
+    articles: ArticleRepository
+    interactions: InteractionRepository
+    scope: Optional[TenantScope] = None
+
+
+def repositories(cursor, scope: Optional[TenantScope] = None) -> Repositories:
+    """PostgreSQL repositories bound to the caller's cursor, scoped to `scope` or else the request's publication"""
+    scope = scope or current_scope()
+    return Repositories(
+        cursor=cursor,
+        users=PostgresUserRepository(cursor, scope),
+        articles=PostgresArticleRepository(cursor, scope),
+        interactions=PostgresInteractionRepository(cursor, scope),
+        scope=scope,
+    )
+
+
+# In-memory implementations for exercising handlers without a database
+def _visible(scope: Optional[TenantScope], row: Optional[Dict[str, Any]]) -> bool:
+    return row is not None and (scope is None or scope.contains(row))
+
+
+class InMemoryUserRepository:
+    def __init__(self, users: Optional[List[Dict[str, Any]]] = None, scope: Optional[TenantScope] = None):
+        self.users: Dict[str, Dict[str, Any]] = {str(user['id']): dict(user) for user in users or []}
+        self.scope = scope
+
+    def get_by_id(self, user_id: str, active_only: bool = True) -> Optional[Dict[str, Any]]:
+        user = self.users.get(str(user_id))
+        if not _visible(self.scope, user) or (active_only and not user.get('is_active', True)):
+            return None
+        return dict(user)
+
+    def get_by_email(self, email: str, active_only: bool = True) -> Optional[Dict[str, Any]]:
+        for user in self.users.values():
+            if user['email'] == email:
+                return self.get_by_id(user['id'], active_only)
+        return None
+
+    def exists(self, email: str, username: str) -> bool:
+        return any(user['email'] == email or user['username'] == username for user in self.users.values())
+
+    def create(self, values: Dict[str, Any]) -> Dict[str, Any]:
+        _check_columns('users', values, USER_COLUMNS)
+        if self.scope:
+            values = self.scope.stamp(values)
+        user = {'is_active': True, 'reputation_score': 0.0, 'verification_status': 'unverified', **values}
+        user['id'] = str(user.get('id') or uuid.uuid4())
+        self.users[user['id']] = user
+        return dict(user)
+
+    def update(self, user_id: str, values: Dict[str, Any]) -> Optional[Dict[str, Any]]:
+        _check_columns('users', values, USER_COLUMNS)
+        user = self.users.get(str(user_id))
+        if not _visible(self.scope, user):
+            return None
+        user.update(values, updated_at=datetime.now())
+        return dict(user)
+
+    def touch_last_active(self, user_id: str) -> None:
+        user = self.users.get(str(user_id))
+        if _visible(self.scope, user):
+            user['last_active'] = datetime.now()
+
+    def members(self, publication_role: Optional[str] = None) -> List[Dict[str, Any]]:
+        members = [
+            dict(user) for user in self.users.values()
+            if _visible(self.scope, user) and user.get('publication_role') and not user.get('deleted_at')
+            and publication_role in (None, user['publication_role'])
+        ]
+        return sorted(members, key=lambda user: (user['publication_role'], user['username']))
+
+
+class InMemoryArticleRepository:
+    def __init__(self, articles: Optional[List[Dict[str, Any]]] = None, scope: Optional[TenantScope] = None):
+        self.articles: Dict[str, Dict[str, Any]] = {
+            str(article['id']): dict(article) for article in articles or []
+        }
+        self.scope = scope
+
+    def get(self, article_id: str, published_only: bool = False) -> Optional[Dict[str, Any]]:
+        article = self.articles.get(str(article_id))
+        if not _visible(self.scope, article) or (published_only and article.get('status') != 'published'):
+            return None
+        return dict(article)
+
+    def exists(self, article_id: str) -> bool:
+        return _visible(self.scope, self.articles.get(str(article_id)))
+
+    def create(self, values: Dict[str, Any]) -> Dict[str, Any]:
+        _check_columns('articles', values, ARTICLE_COLUMNS)
+        article = {counter: 0 for counter in ARTICLE_COUNTERS}
+        article.update({'status': 'draft', 'tags': [], 'metadata': {}, 'reaction_counts': {}, 'clap_count': 0})
+        article.update(self.scope.stamp(values) if self.scope else values)
+        article['id'] = str(article.get('id') or uuid.uuid4())
+        self.articles[article['id']] = article
+        return dict(article)
+
+    def update(self, article_id: str, values: Dict[str, Any]) -> Optional[Dict[str, Any]]:
+        _check_columns('articles', values, ARTICLE_COLUMNS)
+        article = self.articles.get(str(article_id))
+        if not _visible(self.scope, article):
+            return None
+        article.update(values, updated_at=datetime.now())
+        return dict(article)
+
+    def increment_counter(self, article_id: str, counter: str, amount: int = 1) -> None:
+        if counter not in ARTICLE_COUNTERS:
+            raise ValueError(f"Unknown article counter '{counter}'")
+        article = self.articles.get(str(article_id))
+        if _visible(self.scope, article):
+            article[counter] = max((article.get(counter) or 0) + amount, 0)
+
+    def get_stats(self, article_id: str) -> Optional[Dict[str, Any]]:
+        article = self.articles.get(str(article_id))
+        if not _visible(self.scope, article):
+            return None
+        return {
+            key: article.get(key)
+            for key in ('like_count', 'view_count', 'share_count', 'comment_count', 'reaction_counts', 'clap_count')
+        }
+
+
+class InMemoryInteractionRepository:
+    def __init__(self, scope: Optional[TenantScope] = None):
+        self.interactions: List[Dict[str, Any]] = []
+        self.saved: Dict[Tuple[str, str], str] = {}
+        self.scope = scope
+
+    def record(self, user_id: str, article_id: str, interaction_type: str,
+               values: Optional[Dict[str, Any]] = None) -> Dict[str, Any]:
+        interaction = {
+            'id': str(uuid.uuid4()),
+            'user_id': str(user_id),
+            'article_id': str(article_id),
+            'interaction_type': interaction_type,
+            'interaction_strength': 1.0,
+            'context_data': {},
+            'created_at': datetime.now(),
+            **(values or {}),
+        }
+        if self.scope:
+            interaction = self.scope.stamp(interaction)
+        _check_columns('user_interactions', interaction, INTERACTION_COLUMNS)
+        self.interactions.append(interaction)
+        return dict(interaction)
+
+    def has(self, user_id: str, article_id: str, interaction_type: str) -> bool:
+        return any(self._matches(entry, user_id, article_id, interaction_type) for entry in self.interactions)
+
+    def remove(self, user_id: str, article_id: str, interaction_type: str) -> int:
+        before = len(self.interactions)
+        self.interactions = [
+            entry for entry in self.interactions
+            if not self._matches(entry, user_id, article_id, interaction_type)
+        ]
+        return before - len(self.interactions)
+
+    def is_saved(self, user_id: str, article_id: str) -> bool:
+        return (str(user_id), str(article_id)) in self.saved
+
+    def save(self, user_id: str, article_id: str, collection_name: str = 'default') -> None:
+        self.saved[(str(user_id), str(article_id))] = collection_name
+
+    def unsave(self, user_id: str, article_id: str) -> int:
+        return 1 if self.saved.pop((str(user_id), str(article_id)), None) is not None else 0
+
+    def _matches(self, entry: Dict[str, Any], user_id: str, article_id: str, interaction_type: str) -> bool:
+        return (_visible(self.scope, entry) and entry['user_id'] == str(user_id)
+                and entry['article_id'] == str(article_id) and entry['interaction_type'] == interaction_type)
+
+
+class _NullCursor:
+    """Stands in for a database cursor where in-memory repositories are used"""
+
+    rowcount = 0
+
+    def execute(self, *args, **kwargs):
+        pass
+
+    def fetchone(self):
+        return None
+
+    def fetchall(self):
+        return []
+
+
+def in_memory_repositories(users=None, articles=None, scope: Optional[TenantScope] = None) -> Repositories:
+    """Repositories backed by dictionaries, for handler unit tests"""
+    return Repositories(
+        cursor=_NullCursor(),
+        users=InMemoryUserRepository(users, scope),
+        articles=InMemoryArticleRepository(articles, scope),
+        interactions=InMemoryInteractionRepository(scope),
+        scope=scope,
+    )
@@ -0,0 +1,193 @@
+"""
+User reputation engine
+
+A user's `reputation_score` moves only through events recorded here, each
+weighted by the `reputation` settings key:
+
+- `article_published`: the author published an article
+- `upvote_received`: a reader liked the user's article or upvoted their question
+- `report_upheld`: a moderator upheld a report against the user's article
+- `report_confirmed` / `report_dismissed`: a report the user filed was upheld / dismissed
+- `fact_verified`: the author accepted the user's correction
+
+Scores stay between 0 and MAX_SCORE. Each event is kept with the score before
+and after it, and an event about the same thing (`reference_id`) counts once
+per user, so retries and re-likes don't add up. A periodic job decays every
+score toward zero with the configured half-life and records that too.
+"""
+
+import logging
+from decimal import Decimal, ROUND_HALF_UP
+from typing import Any, Dict, List, Optional
+
+from shared.settings import DEFAULT_SETTINGS, get_setting
+
+logger = logging.getLogger(__name__)
+
+ARTICLE_PUBLISHED = 'article_published'
+UPVOTE_RECEIVED = 'upvote_received'
+REPORT_UPHELD = 'report_upheld'
+REPORT_CONFIRMED = 'report_confirmed'
+REPORT_DISMISSED = 'report_dismissed'
+FACT_VERIFIED = 'fact_verified'
+DECAY = 'decay'
+
+EVENTS = [ARTICLE_PUBLISHED, UPVOTE_RECEIVED, REPORT_UPHELD, REPORT_CONFIRMED, REPORT_DISMISSED, FACT_VERIFIED]
+
+MIN_SCORE = Decimal('0.00')
+MAX_SCORE = Decimal('999.99')  # users.reputation_score is DECIMAL(5,2)
+
+
+def config() -> Dict[str, Any]:
+    """The reputation settings, with default weights for events the stored value leaves out"""
+    value = get_setting('reputation')
+    return {**value, 'weights': {**DEFAULT_SETTINGS['reputation']['weights'], **(value.get('weights') or {})}}
+
+
+def record(cursor, user_id: str, event: str, reference_id: Optional[str] = None) -> Optional[Dict[str, Any]]:
+    """Apply an event's weight to a user's score inside the caller's transaction
+
+    Returns the recorded change, or None when the event carries no weight or
+    was already recorded for this reference. A failure is logged and never
+    breaks the caller's change.
+    """
+    if event not in EVENTS:
+        raise ValueError(f"Unknown reputation event '{event}'")
+
+    weight = Decimal(str(config()['weights'].get(event, 0)))
+    if not weight:
+        return None
+
+    try:
+        cursor.execute("SAVEPOINT reputation_event")
+        change = _apply(cursor, user_id, event, weight, reference_id)
+        cursor.execute("RELEASE SAVEPOINT reputation_event")
+        return change
+    except Exception as e:
+        cursor.execute("ROLLBACK TO SAVEPOINT reputation_event")
+        logger.error(f"Failed to record reputation event {event} for user {user_id}: {e}")
+        return None
+
+
+def _apply(cursor, user_id: str, event: str, weight: Decimal, reference_id: Optional[str]) -> Optional[Dict[str, Any]]:
+    cursor.execute("SELECT reputation_score FROM users WHERE id = %s FOR UPDATE", (user_id,))
+    user = cursor.fetchone()
+    if not user:
+        return None
+
+    if reference_id:
+        cursor.execute(
+            "SELECT 1 FROM reputation_events WHERE user_id = %s AND event = %s AND reference_id = %s",
+            (user_id, event, reference_id)
+        )
+        if cursor.fetchone():
+            return None
+
+    before = Decimal(user['reputation_score'] or 0)
+    after = min(max(before + weight, MIN_SCORE), MAX_SCORE).quantize(Decimal('0.01'), rounding=ROUND_HALF_UP)
+    cursor.execute("UPDATE users SET reputation_score = %s WHERE id = %s", (after, user_id))
+    cursor.execute("""
+        INSERT INTO reputation_events (user_id, event, delta, score_before, score_after, reference_id)
+        VALUES (%s, %s, %s, %s, %s, %s)
+        RETURNING *
+    """, (user_id, event, after - before, before, after, reference_id))
+    return serialize(cursor.fetchone())
+
+
+def on_article_published(cursor, article: Dict[str, Any]):
+    """Publish hook: credit the author, once per article"""
+    if article.get('author_id'):
+        record(cursor, str(article['author_id']), ARTICLE_PUBLISHED, str(article['id']))
+
+
+def upvote_received(cursor, author_id: Optional[str], voter_id: str, reference: str):
+    """Credit `author_id` for an upvote, unless they upvoted themselves
+
+    `reference` names what was upvoted, e.g. `article:<id>`; the same voter
+    upvoting it again after taking the vote back doesn't count twice.
+    """
+    if author_id and str(author_id) != str(voter_id):
+        record(cursor, str(author_id), UPVOTE_RECEIVED, f"{reference}:{voter_id}")
+
+
+def article_liked(cursor, article_id: str, voter_id: str):
+    cursor.execute("SELECT author_id FROM articles WHERE id = %s", (article_id,))
+    article = cursor.fetchone()
+    if article:
+        upvote_received(cursor, article['author_id'], voter_id, f"article:{article_id}")
+
+
+def report_resolved(cursor, report: Dict[str, Any], upheld: bool):
+    """Apply a moderator's decision on a report to the author and the reporter"""
+    reference = str(report['id'])
+    if upheld:
+        cursor.execute("SELECT author_id FROM articles WHERE id = %s", (report['article_id'],))
+        article = cursor.fetchone()
+        if article and article['author_id']:
+            record(cursor, str(article['author_id']), REPORT_UPHELD, reference)
+    if report.get('reported_by'):
+        record(cursor, str(report['reported_by']), REPORT_CONFIRMED if upheld else REPORT_DISMISSED, reference)
+
+
+def decay_all(cursor) -> int:
+    """Shrink every positive score by the time since it last decayed; returns how many users changed"""
+    half_life_days = float(config().get('decay_half_life_days') or 0)
+    if half_life_days <= 0:
+        return 0
+
+    cursor.execute("""
+        WITH due AS (
+            SELECT id, reputation_score AS before,
+                   ROUND((reputation_score * power(
+                       0.5, EXTRACT(EPOCH FROM NOW() - reputation_decayed_at) / 86400.0 / %(half_life_days)s
+                   ))::numeric, 2) AS after
+            FROM users
+            WHERE reputation_score > 0 AND reputation_decayed_at <= NOW() - INTERVAL '1 day'
+            FOR UPDATE
+        ),
+        decayed AS (
+            UPDATE users u SET reputation_score = due.after, reputation_decayed_at = NOW()
+            FROM due WHERE u.id = due.id
+            RETURNING u.id
+        )
+        INSERT INTO reputation_events (user_id, event, delta, score_before, score_after)
+        SELECT due.id, %(event)s, due.after - due.before, due.before, due.after
+        FROM due JOIN decayed ON decayed.id = due.id
+        WHERE due.after <> due.before
+    """, {'half_life_days': half_life_days, 'event': DECAY})
+    decayed = cursor.rowcount
+    logger.info(f"Decayed reputation of {decayed} users")
+    return decayed
+
+
+def history(cursor, user_id: str, event: Optional[str] = None, limit: int = 50, offset: int = 0) -> List[Dict[str, Any]]:
+    """A user's score changes, newest first"""
+    query = "SELECT * FROM reputation_events WHERE user_id = %s"
+    params: List[Any] = [user_id]
+    if event:
+        query += " AND event = %s"
+        params.append(event)
+    cursor.execute(query + " ORDER BY created_at DESC, id LIMIT %s OFFSET %s", params + [limit, offset])
+    return [serialize(row) for row in cursor.fetchall()]
+
+
+def summary(cursor, user_id: str) -> List[Dict[str, Any]]:
+    """Total change and count per event for a user"""
+    cursor.execute("""
+        SELECT event, COUNT(*) AS count, COALESCE(SUM(delta), 0) AS total
+        FROM reputation_events WHERE user_id = %s
+        GROUP BY event ORDER BY event
+    """, (user_id,))
+    return [{'event': row['event'], 'count': row['count'], 'total': float(row['total'])} for row in cursor.fetchall()]
+
+
+def serialize(row: Dict[str, Any]) -> Dict[str, Any]:
+    return {
+        'id': str(row['id']),
+        'event': row['event'],
+        'delta': float(row['delta']),
+        'score_before': float(row['score_before']),
+        'score_after': float(row['score_after']),
+        'reference_id': row['reference_id'],
+        'created_at': row['created_at'].isoformat() if row.get('created_at') else None,
+    }
@@ -0,0 +1,151 @@
+"""
+Per-request database context for both Flask and FastAPI backends
+
+A request's deadline and the PostgreSQL connections it has open live in a
+context variable. Every query a request runs is limited to the time the
+request has left, and FastAPI cancels the request's in-flight queries when the
+client disconnects, so abandoned requests stop loading the database.
+"""
+
+import os
+import time
+import asyncio
+import logging
+import threading
+from contextlib import contextmanager
+from contextvars import ContextVar
+from typing import Optional
+
+logger = logging.getLogger(__name__)
+
+
+class RequestDeadlineExceeded(Exception):
+    """Raised when a request has no time left for another query"""
+
+
+class RequestContext:
+    def __init__(self, timeout_ms: Optional[int] = None):
+        self.deadline = time.monotonic() + timeout_ms / 1000 if timeout_ms else None
+        self.cancelled = False
+        self.finished = False
+        self._connections = set()
+        self._lock = threading.Lock()
+
+    def remaining_ms(self) -> Optional[int]:
+        if self.deadline is None:
+            return None
+        return int((self.deadline - time.monotonic()) * 1000)
+
+    def register(self, conn):
+        with self._lock:
+            self._connections.add(conn)
+
+    def unregister(self, conn):
+        with self._lock:
+            self._connections.discard(conn)
+
+    def cancel(self):
+        """Cancel every query the request is currently running"""
+        self.cancelled = True
+        with self._lock:
+            connections = list(self._connections)
+        for conn in connections:
+            try:
+                conn.cancel()
+            except Exception as e:
+                logger.warning(f"Could not cancel query: {e}")
+
+
+_current: ContextVar[Optional[RequestContext]] = ContextVar('request_context', default=None)
+
+
+def request_timeout_ms() -> int:
+    return int(os.getenv('REQUEST_TIMEOUT_MS', 30000))
+
+
+def current_request() -> Optional[RequestContext]:
+    return _current.get()
+
+
+@contextmanager
+def request_scope(timeout_ms: Optional[int] = None):
+    """Run the enclosed request handling under a deadline"""
+    context = RequestContext(request_timeout_ms() if timeout_ms is None else timeout_ms)
+    token = _current.set(context)
+    try:
+        yield context
+    finally:
+        _current.reset(token)
+
+
+def begin_request(timeout_ms: Optional[int] = None) -> RequestContext:
+    """Start a request scope without a with-block (Flask before_request)"""
+    context = RequestContext(request_timeout_ms() if timeout_ms is None else timeout_ms)
+    _current.set(context)
+    return context
+
+
+def end_request():
+    _current.set(None)
+
+
+def effective_timeout_ms(timeout_ms: Optional[int]) -> Optional[int]:
+    """Statement timeout for a query: the configured limit capped by the request's remaining time
+
+    None leaves the server default in place; 0 disables the limit (migrations).
+    """
+    context = current_request()
+    if context is None or context.deadline is None:
+        return timeout_ms
+
+    if context.cancelled:
+        raise RequestDeadlineExceeded("Request was cancelled")
+    remaining = context.remaining_ms()
+    if remaining <= 0:
+        raise RequestDeadlineExceeded("Request deadline exceeded")
+    if not timeout_ms:
+        return remaining
+    return min(timeout_ms, remaining)
+
+
+class QueryCancellationMiddleware:
+    """ASGI middleware giving each HTTP request a deadline and cancelling its queries on disconnect
+
+    The request body is pumped into a queue so a client disconnect is noticed
+    while the handler is still running. Cancellation reaches queries running
+    off the event loop (sync dependencies, asyncio.to_thread); queries run
+    directly inside async handlers are bounded by the deadline instead.
+    """
+
+    def __init__(self, app, long_request_prefixes=('/api/v1/recommendations',)):
+        self.app = app
+        self.long_request_prefixes = tuple(long_request_prefixes)
+
+    async def __call__(self, scope, receive, send):
+        if scope['type'] != 'http':
+            await self.app(scope, receive, send)
+            return
+
+        timeout_ms = request_timeout_ms()
+        if scope['path'].startswith(self.long_request_prefixes):
+            timeout_ms = int(os.getenv('REQUEST_LONG_TIMEOUT_MS', 120000))
+
+        with request_scope(timeout_ms) as context:
+            messages = asyncio.Queue()
+
+            async def pump():
+                while True:
+                    message = await receive()
+                    await messages.put(message)
+                    if message['type'] == 'http.disconnect':
+                        if not context.finished:
+                            logger.info(f"Client disconnected, cancelling queries for {scope['path']}")
+                            context.cancel()
+                        return
+
+            pump_task = asyncio.create_task(pump())
+            try:
+                await self.app(scope, messages.get, send)
+            finally:
+                context.finished = True
+                pump_task.cancel()
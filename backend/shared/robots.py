@@ -0,0 +1,44 @@
+"""
+robots.txt
+
+Rendered from the `robots` settings key, so administrators change crawler
+rules through the settings API without a deploy. Bot-detection honeypot paths
+are always disallowed for every crawler: well-behaved crawlers never request
+them, and scrapers that ignore robots.txt get flagged.
+"""
+
+from typing import Any, Dict, List
+
+from shared.settings import get_setting
+
+
+def render(config: Dict[str, Any]) -> str:
+    from shared.bot_detection import HONEYPOT_PATHS
+
+    rules: List[Dict[str, Any]] = config.get('rules') or []
+    if not any(rule['user_agent'] == '*' for rule in rules):
+        rules = rules + [{'user_agent': '*', 'allow': [], 'disallow': [], 'crawl_delay': None}]
+
+    lines: List[str] = []
+    for rule in rules:
+        lines.append(f"User-agent: {rule['user_agent']}")
+        for path in rule.get('allow') or []:
+            lines.append(f"Allow: {path}")
+        disallow = list(rule.get('disallow') or [])
+        if rule['user_agent'] == '*':
+            disallow += [path for path in HONEYPOT_PATHS if path not in disallow]
+        for path in disallow:
+            lines.append(f"Disallow: {path}")
+        if rule.get('crawl_delay'):
+            lines.append(f"Crawl-delay: {rule['crawl_delay']:g}")
+        lines.append('')
+
+    for sitemap in config.get('sitemaps') or []:
+        lines.append(f"Sitemap: {sitemap}")
+    if config.get('extra'):
+        lines += ['', config['extra'].strip()]
+    return '\n'.join(lines).strip() + '\n'
+
+
+def robots_txt() -> str:
+    return render(get_setting('robots'))
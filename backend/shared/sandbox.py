@@ -0,0 +1,488 @@
+"""
+Sandbox tenant for integrators
+
+Integrators develop against the full API without touching production
+content. A request carrying `X-Sandbox-Key` with an API key issued for the
+`sandbox` scope is served from the sandbox tenant:
+
+- PostgreSQL: the `sandbox` schema, a copy of the public schema's tables,
+  triggers, row-level security policies and views, first on the
+  connection's search_path. A sandbox request never falls through to
+  production tables: if the schema is missing, its connections fail
+  (SandboxUnavailable)
+- Redis: database SANDBOX_REDIS_DB instead of REDIS_DB, so caches, rate
+  limits and sessions are the sandbox's own
+- MongoDB: the database named MONGODB_DB with a `_sandbox` suffix
+
+Tokens issued in the sandbox carry a `tenant` claim and are only accepted in
+the sandbox, and production tokens aren't accepted there. Jobs enqueued by a
+sandbox request run against the sandbox too, except those in OUTBOUND_JOBS,
+which would reach the outside world (email, push, the Fediverse, Arweave)
+and are dropped. Periodic jobs only run for production.
+
+Every SANDBOX_RESET_INTERVAL_SECONDS the schema is rebuilt from the current
+public structure and seeded with fake data: the DEMO_ACCOUNTS, all with the
+password SANDBOX_DEMO_PASSWORD, and SANDBOX_SEED_ARTICLES articles with
+comments, likes, bookmarks and follows. The sandbox's Redis and MongoDB
+databases are emptied at the same time. Each reset is recorded in
+`sandbox_resets`.
+"""
+
+import os
+import json
+import random
+import logging
+from contextlib import contextmanager
+from contextvars import ContextVar
+from datetime import datetime, timedelta
+from typing import Any, Dict, List, Optional
+
+from shared.database import db_manager, get_postgres_cursor, get_redis
+
+logger = logging.getLogger(__name__)
+
+SCHEMA = 'sandbox'
+SCOPE = 'sandbox'
+HEADER = 'X-Sandbox-Key'
+TENANT = 'sandbox'
+RESET_INTERVAL_SECONDS = float(os.getenv('SANDBOX_RESET_INTERVAL_SECONDS', 24 * 60 * 60))
+DEMO_PASSWORD = os.getenv('SANDBOX_DEMO_PASSWORD', 'sandbox-demo-password')
+SEED_ARTICLES = int(os.getenv('SANDBOX_SEED_ARTICLES', 60))
+LOCK_SECONDS = 30 * 60
+
+# Tables copied with their rows, since the API needs them to work
+REFERENCE_TABLES = ('platform_settings', 'subscription_tiers', 'funnels', 'tenants', 'role_permissions')
+# Tables that only exist for the deployment as a whole
+SKIPPED_TABLES = ('schema_migrations', 'sandbox_resets')
+
+# Jobs with effects outside the platform, which sandbox requests don't get to run
+OUTBOUND_JOBS = frozenset({
+    'jobs.send_email', 'jobs.send_newsletter_digest', 'jobs.deliver_push', 'jobs.deliver_activity',
+    'jobs.archive_article', 'jobs.snapshot_article',
+})
+
+DEMO_ACCOUNTS = (
+    ('demo_reader', 'reader'),
+    ('demo_reader_2', 'reader'),
+    ('demo_author', 'author'),
+    ('demo_author_2', 'author'),
+    ('demo_author_3', 'author'),
+    ('demo_admin', 'administrator'),
+)
+
+
+class SandboxUnavailable(Exception):
+    """The sandbox schema doesn't exist, e.g. before its first reset"""
+
+
+_active: ContextVar[bool] = ContextVar('sandbox', default=False)
+
+
+def active() -> bool:
+    """Whether the request or job being handled is in the sandbox"""
+    return _active.get()
+
+
+def tenant() -> Optional[str]:
+    """The `tenant` claim of tokens issued here: TENANT in the sandbox, None in production"""
+    return TENANT if active() else None
+
+
+@contextmanager
+def scope():
+    """Run a block against the sandbox"""
+    token = _active.set(True)
+    try:
+        yield
+    finally:
+        _active.reset(token)
+
+
+def begin():
+    """Serve the rest of the request from the sandbox, without a with-block"""
+    _active.set(True)
+
+
+def end():
+    _active.set(False)
+
+
+def authenticate(api_key: str, ip_address: Optional[str] = None) -> Optional[Dict[str, Any]]:
+    """The API key if it is active and issued for the sandbox scope; always checked against production"""
+    from shared.api_keys import authenticate_api_key
+
+    token = _active.set(False)
+    try:
+        with get_postgres_cursor() as cursor:
+            key = authenticate_api_key(cursor, api_key, ip_address)
+    finally:
+        _active.reset(token)
+    return key if key and SCOPE in (key.get('scopes') or []) else None
+
+
+def ready() -> bool:
+    """Whether the sandbox schema exists"""
+    token = _active.set(False)
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute("SELECT 1 FROM pg_namespace WHERE nspname = %s", (SCHEMA,))
+            return cursor.fetchone() is not None
+    finally:
+        _active.reset(token)
+
+
+def use_schema(cursor):
+    """Point a new connection at the sandbox schema, or raise SandboxUnavailable"""
+    cursor.execute("""
+        SELECT set_config('search_path', %s, false) FROM pg_namespace WHERE nspname = %s
+    """, (f"{SCHEMA}, public", SCHEMA))
+    if cursor.fetchone() is None:
+        raise SandboxUnavailable(f"Schema {SCHEMA} doesn't exist; it is created by the next sandbox reset")
+
+
+def mongodb_name() -> str:
+    return f"{db_manager.mongodb_config['database']}_sandbox"
+
+
+def redis_db() -> int:
+    return db_manager.sandbox_redis_db
+
+
+# Resets
+def reset(trigger: str = 'scheduled', requested_by: Optional[str] = None) -> Optional[Dict[str, Any]]:
+    """Rebuild and reseed the sandbox and record the reset; None if a reset is already going"""
+    if active():
+        raise RuntimeError("The sandbox can't be reset from inside the sandbox")
+    if redis_db() == db_manager.redis_config['db'] or redis_db() == int(os.getenv('JOBS_REDIS_DB', 1)):
+        raise RuntimeError("SANDBOX_REDIS_DB must differ from REDIS_DB and JOBS_REDIS_DB: resets empty it")
+
+    lock_key = 'sandbox_reset_lock'
+    if not get_redis().set(lock_key, 1, nx=True, ex=LOCK_SECONDS):
+        logger.info("Sandbox reset skipped: another reset is in progress")
+        return None
+    try:
+        with get_postgres_cursor() as cursor:
+            cursor.execute("""
+                INSERT INTO sandbox_resets (trigger, requested_by) VALUES (%s, %s) RETURNING id
+            """, (trigger, requested_by))
+            reset_id = cursor.fetchone()['id']
+
+        status, error, tables, seeded = 'completed', None, 0, {}
+        try:
+            # One transaction: until it commits, sandbox requests see yesterday's sandbox
+            with get_postgres_cursor(timeout_ms=0) as cursor:
+                tables = rebuild(cursor)
+                seeded = seed(cursor)
+            clear_stores()
+        except Exception as e:
+            logger.error(f"Sandbox reset failed: {e}")
+            status, error = 'failed', str(e)[:500]
+
+        with get_postgres_cursor() as cursor:
+            cursor.execute("""
+                UPDATE sandbox_resets
+                SET status = %s, error = %s, tables = %s, seeded = %s::jsonb, finished_at = NOW()
+                WHERE id = %s
+                RETURNING *
+            """, (status, error, tables, json.dumps(seeded), reset_id))
+            result = dict(cursor.fetchone())
+    finally:
+        get_redis().delete(lock_key)
+
+    logger.info(f"Sandbox reset {result['id']} {status}: {tables} tables, seeded {seeded}")
+    return result
+
+
+def rebuild(cursor) -> int:
+    """Drop the sandbox schema and recreate it from the public schema's structure, returning the table count"""
+    # Definitions are read with only public on the path, so they name its objects unqualified
+    cursor.execute("SET LOCAL search_path TO public")
+    cursor.execute("""
+        SELECT c.relname AS name FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace
+        WHERE n.nspname = 'public' AND c.relkind IN ('r', 'p') AND NOT c.relispartition
+        ORDER BY c.relname
+    """)
+    tables = [row['name'] for row in cursor.fetchall() if row['name'] not in SKIPPED_TABLES]
+
+    cursor.execute("""
+        SELECT cl.relname AS table_name, con.conname AS name, pg_get_constraintdef(con.oid, true) AS definition
+        FROM pg_constraint con
+        JOIN pg_class cl ON cl.oid = con.conrelid
+        JOIN pg_namespace n ON n.oid = cl.relnamespace
+        WHERE n.nspname = 'public' AND con.contype = 'f'
+    """)
+    foreign_keys = [dict(row) for row in cursor.fetchall() if row['table_name'] in tables]
+
+    cursor.execute("""
+        SELECT cl.relname AS table_name, pg_get_triggerdef(t.oid, true) AS definition
+        FROM pg_trigger t
+        JOIN pg_class cl ON cl.oid = t.tgrelid
+        JOIN pg_namespace n ON n.oid = cl.relnamespace
+        WHERE n.nspname = 'public' AND NOT t.tgisinternal
+    """)
+    triggers = [row['definition'] for row in cursor.fetchall() if row['table_name'] in tables]
+
+    # Row-level security isn't copied by LIKE; the sandbox's tenants are held apart as production's are
+    cursor.execute("""
+        SELECT c.relname AS name, c.relforcerowsecurity AS forced
+        FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace
+        WHERE n.nspname = 'public' AND c.relkind IN ('r', 'p') AND c.relrowsecurity
+    """)
+    secured = [dict(row) for row in cursor.fetchall() if row['name'] in tables]
+    cursor.execute("""
+        SELECT tablename AS table_name, policyname AS name, permissive, cmd, qual, with_check
+        FROM pg_policies WHERE schemaname = 'public'
+    """)
+    policies = [dict(row) for row in cursor.fetchall() if row['table_name'] in tables]
+
+    cursor.execute("""
+        SELECT c.relname AS name, c.relkind AS kind, pg_get_viewdef(c.oid, true) AS definition
+        FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace
+        WHERE n.nspname = 'public' AND c.relkind IN ('v', 'm')
+        ORDER BY c.oid
+    """)
+    views = [dict(row) for row in cursor.fetchall()]
+
+    cursor.execute("""
+        SELECT pg_get_indexdef(i.indexrelid, 0, true) AS definition
+        FROM pg_index i
+        JOIN pg_class c ON c.oid = i.indrelid
+        JOIN pg_namespace n ON n.oid = c.relnamespace
+        WHERE n.nspname = 'public' AND c.relkind = 'm'
+    """)
+    view_indexes = [row['definition'] for row in cursor.fetchall()]
+
+    cursor.execute(f"DROP SCHEMA IF EXISTS {SCHEMA} CASCADE")
+    cursor.execute(f"CREATE SCHEMA {SCHEMA}")
+    for table in tables:
+        cursor.execute(f'CREATE TABLE {SCHEMA}."{table}" (LIKE public."{table}" INCLUDING ALL)')
+    for table in REFERENCE_TABLES:
+        if table in tables:
+            cursor.execute(f'INSERT INTO {SCHEMA}."{table}" SELECT * FROM public."{table}"')
+
+    cursor.execute(f"SET LOCAL search_path TO {SCHEMA}, public")
+    for key in foreign_keys:
+        # Not validated: reference rows may point at production users that the sandbox doesn't have
+        definition = key['definition'].replace(' NOT VALID', '')
+        cursor.execute(f'ALTER TABLE "{key["table_name"]}" ADD CONSTRAINT "{key["name"]}" {definition} NOT VALID')
+    for definition in triggers:
+        cursor.execute(definition)
+    for table in secured:
+        cursor.execute(f'ALTER TABLE "{table["name"]}" ENABLE ROW LEVEL SECURITY')
+        if table['forced']:
+            cursor.execute(f'ALTER TABLE "{table["name"]}" FORCE ROW LEVEL SECURITY')
+    for policy in policies:
+        definition = (
+            f'CREATE POLICY "{policy["name"]}" ON "{policy["table_name"]}" '
+            f'AS {policy["permissive"]} FOR {policy["cmd"]}'
+        )
+        if policy['qual']:
+            definition += f" USING ({policy['qual']})"
+        if policy['with_check']:
+            definition += f" WITH CHECK ({policy['with_check']})"
+        cursor.execute(definition)
+    for view in views:
+        kind = 'MATERIALIZED VIEW' if view['kind'] == 'm' else 'VIEW'
+        cursor.execute(f'CREATE {kind} "{view["name"]}" AS {view["definition"].rstrip().rstrip(";")}')
+    for definition in view_indexes:
+        cursor.execute(definition)
+    return len(tables)
+
+
+# Fake data
+TOPICS = {
+    'technology': ('open protocols', 'battery chemistry', 'edge computing', 'browser privacy', 'chip supply'),
+    'science': ('ocean currents', 'gene editing', 'dark matter', 'soil microbes', 'exoplanet weather'),
+    'business': ('small lenders', 'freight rates', 'remote work', 'co-operatives', 'retail margins'),
+    'politics': ('city budgets', 'transit funding', 'housing policy', 'election audits', 'water rights'),
+    'health': ('sleep research', 'rural clinics', 'air quality', 'nutrition labels', 'vaccine logistics'),
+    'blockchain': ('decentralized identity', 'layer two fees', 'validator economics', 'on-chain voting'),
+}
+ANGLES = (
+    "What we learned about {topic}", "The quiet shift in {topic}", "Five questions about {topic}",
+    "Why {topic} matters this year", "A field guide to {topic}", "Inside the debate over {topic}",
+)
+SENTENCES = (
+    "Researchers and practitioners describe a field that changed faster than anyone expected.",
+    "The data tells a more complicated story than the headlines suggested.",
+    "Local communities have been experimenting with their own approaches.",
+    "Critics argue that the costs have been underestimated from the start.",
+    "Several pilot programs are expected to report results later this year.",
+    "Interviews with a dozen people involved reveal a few recurring themes.",
+    "The numbers only make sense once the historical context is clear.",
+    "Supporters say the early results justify a wider rollout.",
+)
+COMMENTS = (
+    "Great overview, thanks for writing this.", "I'd love a follow-up on the costs.",
+    "Is there a source for the second paragraph?", "This matches what we saw locally.",
+    "Clear and well argued.", "I disagree with the conclusion, but the reporting is solid.",
+)
+
+
+def _paragraphs(rng: random.Random, count: int) -> str:
+    return ''.join(
+        f"<p>{' '.join(rng.sample(SENTENCES, rng.randint(3, 5)))}</p>" for _ in range(count)
+    )
+
+
+def seed(cursor) -> Dict[str, int]:
+    """Fill a freshly rebuilt sandbox with the demo accounts and their articles and interactions"""
+    from shared.auth import hash_password
+    from shared.repositories import repositories
+
+    cursor.execute(f"SET LOCAL search_path TO {SCHEMA}, public")
+    repos = repositories(cursor)
+    # The same content every night; only ids and dates change
+    rng = random.Random(20240101)
+    now = datetime.now()
+
+    password_hash = hash_password(DEMO_PASSWORD)
+    users = [
+        repos.users.create({
+            'username': username, 'email': f"{username}@sandbox.example.com", 'password_hash': password_hash,
+            'role': role, 'profile_data': {'display_name': username.replace('_', ' ').title()},
+        })
+        for username, role in DEMO_ACCOUNTS
+    ]
+    authors = [user for user in users if user['role'] == 'author']
+    readers = [user for user in users if user['role'] != 'author']
+
+    articles = []
+    for index in range(SEED_ARTICLES):
+        category = rng.choice(list(TOPICS))
+        topic = rng.choice(TOPICS[category])
+        published_at = now - timedelta(hours=rng.uniform(1, 24 * 30))
+        articles.append(repos.articles.create({
+            'title': rng.choice(ANGLES).format(topic=topic).capitalize(),
+            'summary': rng.choice(SENTENCES),
+            'content': _paragraphs(rng, rng.randint(3, 7)),
+            'author_id': authors[index % len(authors)]['id'],
+            'category': category,
+            'tags': [topic.replace(' ', '-'), category, 'sandbox'],
+            'language': 'en',
+            # A few drafts so there is something to edit and publish
+            'status': 'draft' if index % 10 == 9 else 'published',
+            'published_at': None if index % 10 == 9 else published_at,
+            'created_at': published_at,
+        }))
+    published = [article for article in articles if article['status'] == 'published']
+
+    counts = {'users': len(users), 'articles': len(articles), 'comments': 0, 'likes': 0, 'bookmarks': 0,
+              'follows': 0}
+    for reader in users:
+        for article in rng.sample(published, min(len(published), 12)):
+            repos.interactions.record(reader['id'], article['id'], 'view')
+            repos.articles.increment_counter(article['id'], 'view_count')
+            if rng.random() < 0.4:
+                repos.interactions.record(reader['id'], article['id'], 'like')
+                repos.articles.increment_counter(article['id'], 'like_count')
+                counts['likes'] += 1
+            if rng.random() < 0.2:
+                repos.interactions.save(reader['id'], article['id'])
+                counts['bookmarks'] += 1
+            if rng.random() < 0.15:
+                cursor.execute("""
+                    INSERT INTO comments (article_id, user_id, content, moderation_status, created_at)
+                    VALUES (%s, %s, %s, 'approved', %s)
+                """, (article['id'], reader['id'], rng.choice(COMMENTS), now - timedelta(hours=rng.uniform(0, 48))))
+                repos.articles.increment_counter(article['id'], 'comment_count')
+                counts['comments'] += 1
+
+    for reader in readers:
+        for author in rng.sample(authors, 2):
+            cursor.execute("INSERT INTO user_follows (follower_id, following_id) VALUES (%s, %s)",
+                           (reader['id'], author['id']))
+            counts['follows'] += 1
+
+    cursor.execute("SELECT matviewname FROM pg_matviews WHERE schemaname = %s", (SCHEMA,))
+    for view in cursor.fetchall():
+        cursor.execute(f'REFRESH MATERIALIZED VIEW "{view["matviewname"]}"')
+    return counts
+
+
+def clear_stores():
+    """Empty the sandbox's Redis and MongoDB databases"""
+    from shared.modules import required_services
+
+    with scope():
+        get_redis().flushdb()
+        if 'mongodb' in required_services():
+            try:
+                db_manager.get_mongodb_client().drop_database(mongodb_name())
+            except Exception as e:
+                logger.warning(f"Sandbox MongoDB database not dropped: {e}")
+
+
+def last_reset(cursor) -> Optional[Dict[str, Any]]:
+    cursor.execute("""
+        SELECT * FROM public.sandbox_resets WHERE status = 'completed' ORDER BY started_at DESC LIMIT 1
+    """)
+    row = cursor.fetchone()
+    return dict(row) if row else None
+
+
+def list_resets(cursor, limit: int = 20) -> List[Dict[str, Any]]:
+    cursor.execute("SELECT * FROM sandbox_resets ORDER BY started_at DESC LIMIT %s", (limit,))
+    return [dict(row) for row in cursor.fetchall()]
+
+
+def describe(cursor) -> Dict[str, Any]:
+    """What an integrator needs to know about the sandbox: accounts to sign in as and when it resets"""
+    reset_row = last_reset(cursor)
+    last = reset_row['finished_at'] if reset_row else None
+    return {
+        'tenant': TENANT,
+        'last_reset_at': last,
+        'next_reset_at': last + timedelta(seconds=RESET_INTERVAL_SECONDS) if last else None,
+        'demo_accounts': [
+            {'username': username, 'email': f"{username}@sandbox.example.com", 'role': role}
+            for username, role in DEMO_ACCOUNTS
+        ],
+        'demo_password': DEMO_PASSWORD,
+        'dropped_jobs': sorted(OUTBOUND_JOBS),
+    }
+
+
+# Request routing
+class SandboxMiddleware:
+    """ASGI middleware serving requests that carry a sandbox key from the sandbox tenant"""
+
+    def __init__(self, app):
+        self.app = app
+
+    async def __call__(self, scope_, receive, send):
+        if scope_['type'] not in ('http', 'websocket'):
+            await self.app(scope_, receive, send)
+            return
+
+        headers = dict(scope_.get('headers') or [])
+        api_key = headers.get(HEADER.lower().encode(), b'').decode('latin-1')
+        if not api_key:
+            await self.app(scope_, receive, send)
+            return
+
+        client = scope_.get('client')
+        # A bad key is refused rather than served from production
+        if not authenticate(api_key, client[0] if client else None):
+            await _respond(send, scope_, 401, "Invalid sandbox key")
+            return
+        if not ready():
+            await _respond(send, scope_, 503, "The sandbox is being set up; try again after its first reset")
+            return
+
+        context_token = _active.set(True)
+        try:
+            await self.app(scope_, receive, send)
+        finally:
+            _active.reset(context_token)
+
+
+async def _respond(send, scope_, status_code: int, message: str):
+    if scope_['type'] == 'websocket':
+        await send({'type': 'websocket.close', 'code': 4401})
+        return
+    body = json.dumps({'success': False, 'message': message, 'error_code': 'SANDBOX'}).encode()
+    await send({'type': 'http.response.start', 'status': status_code, 'headers': [
+        (b'content-type', b'application/json'), (b'content-length', str(len(body)).encode()),
+    ]})
+    await send({'type': 'http.response.body', 'body': body})
@@ -0,0 +1,183 @@
+"""
+Scheduled publishing with cache priming
+
+Authors schedule a draft to go live at a set time, such as when an embargo
+lifts. SCHEDULED_PUBLISH_PRIME_SECONDS before then, a worker renders what the
+first readers will ask for: the share card, the article JSON and every public
+feed the article appears in, staged under pending keys that are not served
+yet. At the publish time the article goes live with the scheduled time as its
+published_at, the staged entries replace the live ones, the signed-out home
+feed is rebuilt and the edge cache is purged and warmed, so the first spike
+of traffic hits warm caches.
+
+Articles are primed and published for their own publication, so caches are
+staged under its keys and feeds are built from its articles only.
+
+Editing a scheduled draft drops its priming; it is primed again with the new
+text if there is still time, and otherwise caches are rebuilt at publish.
+"""
+
+import os
+import logging
+from datetime import datetime
+from typing import Any, Dict, List
+from urllib.parse import quote
+
+from shared import article_cache
+from shared.database import get_postgres_cursor
+from shared.edge_cache import FEED_SURROGATE_KEY, purge_surrogate_keys, warm_urls
+from shared.feed_composer import refresh_anonymous_home_feed
+from shared.instance_policy import REJECT, check_publish
+from shared.og_images import generate_for_article, needs_card
+from shared.public_feeds import feeds_for_article, promote_feed, refresh_feed, stage_feed
+from shared.publishing import on_article_published
+
+logger = logging.getLogger(__name__)
+
+PRIME_LEAD_SECONDS = int(os.getenv('SCHEDULED_PUBLISH_PRIME_SECONDS', 60))
+# Staged entries outlive a publish that runs late
+STAGE_TTL_SECONDS = PRIME_LEAD_SECONDS + 10 * 60
+
+
+def schedule(cursor, article_id: str, publish_at: datetime) -> Dict[str, Any]:
+    cursor.execute("""
+        UPDATE articles SET scheduled_publish_at = %s, cache_primed_at = NULL
+        WHERE id = %s
+        RETURNING *
+    """, (publish_at, article_id))
+    article_cache.invalidate(article_id)
+    return dict(cursor.fetchone())
+
+
+def unschedule(cursor, article_id: str) -> Dict[str, Any]:
+    cursor.execute("""
+        UPDATE articles SET scheduled_publish_at = NULL, cache_primed_at = NULL
+        WHERE id = %s
+        RETURNING *
+    """, (article_id,))
+    article_cache.invalidate(article_id)
+    return dict(cursor.fetchone())
+
+
+def articles_to_prime(cursor) -> List[Dict[str, Any]]:
+    """Scheduled drafts going live within the priming lead time that aren't primed yet"""
+    cursor.execute("""
+        SELECT id, tenant_id, scheduled_publish_at FROM articles
+        WHERE status = 'draft' AND cache_primed_at IS NULL
+          AND scheduled_publish_at > NOW()
+          AND scheduled_publish_at <= NOW() + make_interval(secs => %s)
+        ORDER BY scheduled_publish_at
+    """, (PRIME_LEAD_SECONDS,))
+    return [dict(row) for row in cursor.fetchall()]
+
+
+def articles_due(cursor) -> List[Dict[str, Any]]:
+    cursor.execute("""
+        SELECT id, tenant_id FROM articles
+        WHERE status = 'draft' AND scheduled_publish_at <= NOW()
+        ORDER BY scheduled_publish_at
+    """)
+    return [dict(row) for row in cursor.fetchall()]
+
+
+def prime(article_id: str) -> bool:
+    """Render the share card and stage the article and its feeds as they will look once live"""
+    with get_postgres_cursor() as cursor:
+        cursor.execute("""
+            SELECT * FROM articles
+            WHERE id = %s AND status = 'draft' AND scheduled_publish_at IS NOT NULL
+            FOR UPDATE
+        """, (article_id,))
+        article = cursor.fetchone()
+        if not article:
+            return False
+        article = dict(article)
+
+        if needs_card(article) and not article['og_image_url']:
+            article['og_image_url'] = generate_for_article(cursor, article_id)
+
+        published_at = article['scheduled_publish_at']
+        article_cache.stage(
+            {**article, 'status': 'published', 'published_at': published_at, 'scheduled_publish_at': None},
+            STAGE_TTL_SECONDS
+        )
+        for scope, value in feeds_for_article(article):
+            stage_feed(cursor, scope, value, article_id, published_at, STAGE_TTL_SECONDS)
+        cursor.execute("UPDATE articles SET cache_primed_at = NOW() WHERE id = %s", (article_id,))
+
+    if article['og_image_url']:
+        # Card URLs are content hashes, so warming one doesn't reveal the article early
+        warm_urls([article['og_image_url']])
+    logger.info(f"Primed caches for article {article_id} scheduled at {published_at.isoformat()}")
+    return True
+
+
+def publish(article_id: str) -> bool:
+    """Publish a scheduled draft whose time has come and swap in its primed caches"""
+    with get_postgres_cursor() as cursor:
+        cursor.execute("""
+            SELECT * FROM articles
+            WHERE id = %s AND status = 'draft' AND scheduled_publish_at <= NOW()
+            FOR UPDATE
+        """, (article_id,))
+        article = cursor.fetchone()
+        if not article:
+            return False
+
+        # Moderation review happens when scheduling; only a category blocked since then stops the publish
+        decision = check_publish(dict(article))
+        if decision.action == REJECT:
+            unschedule(cursor, article_id)
+            logger.warning(f"Scheduled publish of article {article_id} refused: {decision.reason}")
+            return False
+
+        cursor.execute("""
+            UPDATE articles
+            SET status = 'published', published_at = scheduled_publish_at, scheduled_publish_at = NULL
+            WHERE id = %s
+            RETURNING *
+        """, (article_id,))
+        published = dict(cursor.fetchone())
+        on_article_published(cursor, published)
+
+        # Feeds staged before another article went live would leave it out
+        feeds_current = False
+        if article['cache_primed_at']:
+            cursor.execute("""
+                SELECT NOT EXISTS (
+                    SELECT 1 FROM articles WHERE status = 'published' AND published_at > %s AND id != %s
+                ) AS current
+            """, (article['cache_primed_at'], article_id))
+            feeds_current = cursor.fetchone()['current']
+
+    try:
+        _warm_caches(published, primed=article['cache_primed_at'] is not None, feeds_current=feeds_current)
+    except Exception as e:
+        logger.warning(f"Warming caches for article {article_id} failed: {e}")
+    logger.info(f"Published scheduled article {article_id}")
+    return True
+
+
+def _warm_caches(article: Dict[str, Any], primed: bool, feeds_current: bool):
+    article_id = str(article['id'])
+    if not (primed and article_cache.promote(article_id)):
+        article_cache.put(article_id, article_cache.render(article))
+
+    feeds = feeds_for_article(article)
+    for scope, value in feeds:
+        if not (primed and feeds_current and promote_feed(scope, value)):
+            refresh_feed(scope, value)
+    refresh_anonymous_home_feed()
+
+    purge_surrogate_keys([FEED_SURROGATE_KEY])
+    paths = []
+    for scope, value in feeds:
+        prefix = {
+            'all': '/feeds',
+            'tag': f"/feeds/tags/{quote(value or '')}",
+            'author': f"/feeds/authors/{value}",
+        }[scope]
+        paths.extend([f"{prefix}/feed.json", f"{prefix}/rss.xml"])
+    if article.get('og_image_url'):
+        paths.append(article['og_image_url'])
+    warm_urls(paths)
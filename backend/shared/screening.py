@@ -0,0 +1,283 @@
+"""
+Spam and toxicity screening for comments and articles
+
+New comments, and with `screen_articles` set the articles being published,
+go through the checks enabled in the `screening` settings key before anyone
+else sees them:
+
+- `rate`: a user commenting more than `max_per_window` times in
+  `window_seconds`, or posting the same text again within
+  `duplicate_window_seconds`
+- `links`: links to a `blocked_domains` domain (or its subdomains), or a
+  comment with more than `max_links` links
+- `toxicity`: a score of at least `threshold` from the model named by
+  TOXICITY_PROVIDER (`perspective` with PERSPECTIVE_API_KEY, `http` for a
+  self-hosted model at TOXICITY_API_URL, or `none`)
+
+Content that no check flags goes live at once. A flagged comment is stored
+with `moderation_status` pending, hidden from the discussion until an
+administrator approves it through /api/v1/admin/screening; its flags are kept
+in `comment_screenings`. A flagged article stays a draft in the article
+review queue with the flags as the reason. Screening fails open: a check
+that errors, such as a toxicity model that is down, is logged and skipped
+rather than holding everything back.
+
+More checks are added with `register_check`.
+"""
+
+import os
+import re
+import json
+import hashlib
+import logging
+from dataclasses import dataclass, field
+from typing import Any, Callable, Dict, Iterable, List, Optional, Protocol, Tuple
+from urllib.parse import urlsplit
+
+import requests
+
+from shared.content import text_of
+from shared.database import get_redis
+from shared.settings import get_setting
+
+logger = logging.getLogger(__name__)
+
+COMMENT = 'comment'
+ARTICLE = 'article'
+
+TOXICITY_PROVIDER = os.getenv('TOXICITY_PROVIDER', 'none')
+REQUEST_TIMEOUT_SECONDS = float(os.getenv('TOXICITY_TIMEOUT_SECONDS', 5))
+URL_PATTERN = re.compile(r'https?://[^\s<>"\']+', re.IGNORECASE)
+
+
+class ToxicityUnavailable(Exception):
+    """Raised when no toxicity model is configured or it fails"""
+
+
+# Toxicity models
+class ToxicityProvider(Protocol):
+    name: str
+
+    def score(self, text: str) -> float:
+        """Probability from 0 to 1 that the text is toxic"""
+        ...
+
+
+class PerspectiveProvider:
+    name = 'perspective'
+
+    def __init__(self):
+        self.api_key = os.getenv('PERSPECTIVE_API_KEY', '')
+        self.url = os.getenv(
+            'PERSPECTIVE_API_URL', 'https://commentanalyzer.googleapis.com/v1alpha1/comments:analyze'
+        )
+
+    def score(self, text: str) -> float:
+        if not self.api_key:
+            raise ToxicityUnavailable("PERSPECTIVE_API_KEY must be set")
+        response = requests.post(self.url, params={'key': self.api_key}, timeout=REQUEST_TIMEOUT_SECONDS, json={
+            'comment': {'text': text},
+            'requestedAttributes': {'TOXICITY': {}},
+            'doNotStore': True,
+        })
+        response.raise_for_status()
+        return float(response.json()['attributeScores']['TOXICITY']['summaryScore']['value'])
+
+
+class HttpProvider:
+    """A self-hosted model answering `{"text": ...}` with `{"score": 0.0-1.0}`"""
+    name = 'http'
+
+    def __init__(self):
+        self.url = os.getenv('TOXICITY_API_URL', '')
+        self.api_key = os.getenv('TOXICITY_API_KEY', '')
+
+    def score(self, text: str) -> float:
+        if not self.url:
+            raise ToxicityUnavailable("TOXICITY_API_URL must be set")
+        headers = {'Authorization': f"Bearer {self.api_key}"} if self.api_key else {}
+        response = requests.post(self.url, json={'text': text}, headers=headers, timeout=REQUEST_TIMEOUT_SECONDS)
+        response.raise_for_status()
+        return float(response.json()['score'])
+
+
+PROVIDERS = {
+    'perspective': PerspectiveProvider,
+    'http': HttpProvider,
+}
+
+_provider: Optional[ToxicityProvider] = None
+
+
+def provider() -> ToxicityProvider:
+    global _provider
+    if _provider is None:
+        if TOXICITY_PROVIDER not in PROVIDERS:
+            raise ToxicityUnavailable("No toxicity model is configured")
+        _provider = PROVIDERS[TOXICITY_PROVIDER]()
+    return _provider
+
+
+# Checks
+@dataclass(frozen=True)
+class Submission:
+    kind: str  # comment or article
+    user_id: str
+    text: str  # Plain text, markup removed
+
+
+@dataclass
+class Screening:
+    flags: List[Dict[str, str]] = field(default_factory=list)
+
+    @property
+    def held(self) -> bool:
+        return bool(self.flags)
+
+    @property
+    def reason(self) -> str:
+        return "; ".join(flag['reason'] for flag in self.flags)
+
+
+@dataclass(frozen=True)
+class Check:
+    name: str
+    description: str
+    # Returns why the submission is flagged, or None; gets the check's section of the settings
+    run: Callable[[Submission, Dict[str, Any]], Optional[str]]
+    kinds: Tuple[str, ...] = (COMMENT, ARTICLE)
+
+
+_checks: Dict[str, Check] = {}
+
+
+def register_check(check: Check) -> Check:
+    """Make a check available; a check registered again under its name replaces the earlier one"""
+    _checks[check.name] = check
+    return check
+
+
+def checks() -> List[Check]:
+    return list(_checks.values())
+
+
+def get_screening_settings() -> Dict[str, Any]:
+    return get_setting('screening')
+
+
+def screen(submission: Submission, config: Optional[Dict[str, Any]] = None) -> Screening:
+    """Run the enabled checks that apply to the submission"""
+    config = config or get_screening_settings()
+    result = Screening()
+    for name in config.get('checks') or []:
+        check = _checks.get(name)
+        if not check or submission.kind not in check.kinds:
+            continue
+        try:
+            reason = check.run(submission, config.get(name) or {})
+        except Exception as e:
+            logger.warning(f"Screening check {name} skipped: {e}")
+            continue
+        if reason:
+            result.flags.append({'check': name, 'reason': reason})
+    return result
+
+
+def screens_articles(config: Optional[Dict[str, Any]] = None) -> bool:
+    return bool((config or get_screening_settings()).get('screen_articles'))
+
+
+def article_submission(article: Dict[str, Any], user_id: str) -> Submission:
+    content = article.get('content') or ''
+    # Link targets are kept so the links check sees them once the markup is gone
+    targets = re.findall(r'href=["\']([^"\']+)', content, re.IGNORECASE)
+    parts = [article.get('title'), article.get('summary'), text_of(content), ' '.join(targets)]
+    return Submission(ARTICLE, str(user_id), "\n".join(part for part in parts if part))
+
+
+def _rate(submission: Submission, options: Dict[str, Any]) -> Optional[str]:
+    redis_client = get_redis()
+    window = int(options.get('window_seconds', 300))
+    counter = f"screening:rate:{submission.user_id}"
+    posted = redis_client.incr(counter)
+    if posted == 1:
+        redis_client.expire(counter, window)
+    limit = int(options.get('max_per_window', 5))
+    if posted > limit:
+        return f"More than {limit} comments in {window} seconds"
+
+    normalized = re.sub(r'\s+', ' ', submission.text).strip().lower()
+    digest = hashlib.sha256(normalized.encode()).hexdigest()
+    first = redis_client.set(
+        f"screening:text:{submission.user_id}:{digest}", 1, nx=True,
+        ex=int(options.get('duplicate_window_seconds', 86400))
+    )
+    if not first:
+        return "Same text posted again"
+    return None
+
+
+def _domain_matches(domain: str, patterns: Iterable[str]) -> bool:
+    return any(domain == pattern or domain.endswith(f".{pattern}") for pattern in patterns)
+
+
+def _links(submission: Submission, options: Dict[str, Any]) -> Optional[str]:
+    urls = URL_PATTERN.findall(submission.text)
+    blocked = [domain.strip().lower() for domain in options.get('blocked_domains') or [] if domain.strip()]
+    hits = sorted({
+        host for host in ((urlsplit(url).hostname or '') for url in urls) if host and _domain_matches(host, blocked)
+    })
+    if hits:
+        return f"Links to blocked domains: {', '.join(hits)}"
+
+    # Articles cite their sources, so only comments are limited
+    limit = options.get('max_links')
+    if submission.kind == COMMENT and limit is not None and len(urls) > int(limit):
+        return f"{len(urls)} links (at most {limit} allowed)"
+    return None
+
+
+def _toxicity(submission: Submission, options: Dict[str, Any]) -> Optional[str]:
+    if TOXICITY_PROVIDER == 'none':
+        return None
+    score = provider().score(submission.text[:20000])
+    threshold = float(options.get('threshold', 0.8))
+    if score >= threshold:
+        return f"Toxicity score {score:.2f} (threshold {threshold:.2f})"
+    return None
+
+
+register_check(Check('rate', "Too many comments in a short time, or the same text again", _rate, kinds=(COMMENT,)))
+register_check(Check('links', "Links to blocked domains, or too many links in a comment", _links))
+register_check(Check('toxicity', "A toxicity model's score at or above the threshold", _toxicity))
+
+
+# Queue
+def hold_comment(cursor, comment: Dict[str, Any], screening: Screening) -> Dict[str, Any]:
+    """Record why a comment was held for review"""
+    cursor.execute("""
+        INSERT INTO comment_screenings (comment_id, article_id, flags)
+        VALUES (%s, %s, %s)
+        RETURNING *
+    """, (comment['id'], comment['article_id'], json.dumps(screening.flags)))
+    return dict(cursor.fetchone())
+
+
+def list_held_comments(cursor, status: str = 'pending', article_id: Optional[str] = None,
+                       limit: int = 50) -> List[Dict[str, Any]]:
+    """Screened comments with their flags, oldest first"""
+    query = """
+        SELECT s.*, c.content, c.parent_comment_id, c.is_anonymous, c.user_id, u.username AS commenter,
+               a.title AS article_title
+        FROM comment_screenings s
+        JOIN comments c ON c.id = s.comment_id
+        JOIN articles a ON a.id = s.article_id
+        LEFT JOIN users u ON u.id = c.user_id
+        WHERE s.status = %s
+    """
+    params: List[Any] = [status]
+    if article_id:
+        query += " AND s.article_id = %s"
+        params.append(article_id)
+    cursor.execute(query + " ORDER BY s.created_at LIMIT %s", params + [limit])
+    return [dict(row) for row in cursor.fetchall()]
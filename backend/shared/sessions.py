@@ -0,0 +1,140 @@
+"""
+Server-side session registry for both Flask and FastAPI backends
+
+Every full-session token belongs to a session (its `sid` claim) recorded in
+Redis with the device it was issued to; refreshing a token keeps the session.
+Revoking a session denylists its id for the lifetime of the longest token it
+could have issued, so its tokens stop verifying on both backends at once.
+Both fail closed while Redis is down: no token is issued for a session that
+couldn't be recorded, and a token whose session can't be checked is refused.
+"""
+
+import json
+import uuid
+import logging
+from datetime import datetime
+from typing import Any, Dict, List, Optional
+
+from shared.auth import auth_manager
+from shared.database import get_redis
+
+logger = logging.getLogger(__name__)
+
+
+class SessionStoreUnavailable(Exception):
+    """Redis couldn't record a new session, so no token is issued for it"""
+
+# Checked in order, so more specific tokens come first (Edge and Opera also say "Chrome")
+BROWSERS = [('Edg/', 'Edge'), ('OPR/', 'Opera'), ('Firefox/', 'Firefox'), ('Chrome/', 'Chrome'), ('Safari/', 'Safari')]
+PLATFORMS = [('Android', 'Android'), ('iPhone', 'iOS'), ('iPad', 'iOS'), ('Windows', 'Windows'),
+             ('Mac OS X', 'macOS'), ('Linux', 'Linux')]
+
+
+def _sessions_key(user_id: str) -> str:
+    return f"sessions:{user_id}"
+
+
+def _revoked_key(session_id: str) -> str:
+    return f"session_revoked:{session_id}"
+
+
+def describe_device(user_agent: Optional[str]) -> str:
+    """Short human-readable device label, e.g. "Firefox on Linux\""""
+    user_agent = user_agent or ''
+    browser = next((name for token, name in BROWSERS if token in user_agent), None)
+    platform = next((name for token, name in PLATFORMS if token in user_agent), None)
+    if not browser and not platform:
+        return (user_agent[:60] or 'Unknown device')
+    return f"{browser or 'Unknown browser'} on {platform or 'unknown OS'}"
+
+
+def issue_session_token(user: Dict[str, Any], ip_address: Optional[str], user_agent: Optional[str],
+                        session_id: Optional[str] = None) -> str:
+    """Create an access token and record the session it belongs to
+
+    Pass the `sid` of the presented token to refresh within the same session.
+    Raises SessionStoreUnavailable when the session can't be recorded.
+    """
+    session_id = session_id or str(uuid.uuid4())
+    token = auth_manager.create_access_token(user, session_id=session_id)
+    now = datetime.now()
+
+    try:
+        redis_client = get_redis()
+        key = _sessions_key(str(user['id']))
+        existing = redis_client.hget(key, session_id)
+        session = json.loads(existing) if existing else {'created_at': now.isoformat()}
+        session.update({
+            'ip_address': ip_address,
+            'user_agent': (user_agent or '')[:300],
+            'device': describe_device(user_agent),
+            'last_issued_at': now.isoformat(),
+            'expires_at': now.timestamp() + auth_manager.access_token_expires,
+        })
+        redis_client.hset(key, session_id, json.dumps(session))
+        redis_client.expire(key, auth_manager.access_token_expires)
+    except Exception as e:
+        logger.error(f"Could not record session {session_id}: {e}")
+        raise SessionStoreUnavailable(f"Could not record session: {e}") from e
+
+    return token
+
+
+def is_session_revoked(session_id: Optional[str]) -> bool:
+    """Whether a session was signed out; fails closed, as revoked, if Redis is unavailable"""
+    if not session_id:
+        return False
+    try:
+        return bool(get_redis().exists(_revoked_key(session_id)))
+    except Exception as e:
+        logger.error(f"Could not check session revocation, refusing the token: {e}")
+        return True
+
+
+def list_sessions(user_id: str, current_session_id: Optional[str] = None) -> List[Dict[str, Any]]:
+    """Sessions that can still be used, most recently active first"""
+    redis_client = get_redis()
+    key = _sessions_key(user_id)
+    now = datetime.now().timestamp()
+
+    # Last activity per session comes from the API usage log (see shared.tokens)
+    last_seen = {}
+    for raw in redis_client.hgetall(f"api_usage:{user_id}:tokens").values():
+        token = json.loads(raw)
+        if token.get('session_id'):
+            last_seen[token['session_id']] = max(last_seen.get(token['session_id'], ''), token['last_seen'])
+
+    sessions = []
+    for session_id, raw in redis_client.hgetall(key).items():
+        session = json.loads(raw)
+        if session['expires_at'] <= now:
+            redis_client.hdel(key, session_id)
+            continue
+        sessions.append({
+            'id': session_id,
+            **session,
+            'last_seen': last_seen.get(session_id) or session['last_issued_at'],
+            'current': session_id == current_session_id,
+        })
+
+    sessions.sort(key=lambda session: session['last_seen'], reverse=True)
+    return sessions
+
+
+def revoke_session(user_id: str, session_id: str) -> bool:
+    """Sign one session out everywhere"""
+    redis_client = get_redis()
+    if not redis_client.hdel(_sessions_key(user_id), session_id):
+        return False
+    redis_client.setex(_revoked_key(session_id), auth_manager.access_token_expires, 1)
+    return True
+
+
+def revoke_other_sessions(user_id: str, keep_session_id: Optional[str] = None) -> int:
+    """Sign out every session except `keep_session_id`; returns how many were revoked"""
+    redis_client = get_redis()
+    session_ids = [session_id for session_id in redis_client.hkeys(_sessions_key(user_id))
+                   if session_id != keep_session_id]
+    for session_id in session_ids:
+        revoke_session(user_id, session_id)
+    return len(session_ids)
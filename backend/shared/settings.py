@@ -0,0 +1,220 @@
+"""
+Shared runtime settings service for both Flask and FastAPI backends
+
+Settings are JSON documents stored per key in PostgreSQL, cached in Redis and
+merged over in-code defaults so a missing row never breaks a caller.
+"""
+
+import copy
+import json
+import logging
+from typing import Any, Dict, Optional
+
+from shared.database import get_postgres_cursor, get_redis, prepare_json_data
+
+logger = logging.getLogger(__name__)
+
+
+# Default values for every known settings key
+DEFAULT_SETTINGS: Dict[str, Any] = {
+    'home_feed': {
+        'shelves': [
+            {'name': 'breaking', 'title': 'Breaking News', 'source': 'breaking',
+             'count': 5, 'position': 0, 'enabled': True, 'options': {'window_hours': 6}},
+            {'name': 'following', 'title': 'From Authors You Follow', 'source': 'following',
+             'count': 10, 'position': 1, 'enabled': True, 'options': {}},
+            {'name': 'trending', 'title': 'Trending Now', 'source': 'trending',
+             'count': 10, 'position': 2, 'enabled': True, 'options': {'window_days': 3}},
+            {'name': 'for-you', 'title': 'For You', 'source': 'for_you',
+             'count': 10, 'position': 3, 'enabled': True, 'options': {}},
+            {'name': 'topics', 'title': 'Popular Topics', 'source': 'topics',
+             'count': 5, 'position': 4, 'enabled': True, 'options': {'articles_per_topic': 3, 'window_days': 7}},
+        ],
+        'deduplicate': True,
+        'cache_ttl_seconds': 60,
+    },
+    'reactions': {
+        'types': [
+            {'key': 'insightful', 'label': 'Insightful', 'emoji': '💡', 'weight': 1.0, 'enabled': True},
+            {'key': 'important', 'label': 'Important', 'emoji': '❗', 'weight': 1.0, 'enabled': True},
+            {'key': 'questionable', 'label': 'Questionable', 'emoji': '🤔', 'weight': -0.5, 'enabled': True},
+        ],
+    },
+    'og_image': {
+        'site_name': 'Decentralized News',
+        'background_color': '#ffffff',
+        'text_color': '#111827',
+        'default_color': '#1f2937',
+        'category_colors': {
+            'blockchain': '#7c3aed', 'business': '#0f766e', 'entertainment': '#db2777', 'health': '#16a34a',
+            'journalism': '#1d4ed8', 'lifestyle': '#ea580c', 'politics': '#b91c1c', 'science': '#0891b2',
+            'sports': '#ca8a04', 'technology': '#4f46e5', 'general': '#1f2937',
+        },
+    },
+    'instance_policy': {
+        'jurisdiction': None,
+        'blocked_categories': [],
+        'moderated_tags': [],
+        'federation': {
+            'accept_remote_content': True,
+            'allowed_instances': [],
+            'blocked_instances': [],
+            'rejected_categories': [],
+        },
+    },
+    'reputation': {
+        'weights': {
+            'article_published': 2.0,
+            'upvote_received': 0.1,
+            'report_upheld': -5.0,
+            'report_confirmed': 1.0,
+            'report_dismissed': -0.5,
+            'fact_verified': 0.5,
+        },
+        'decay_half_life_days': 180,
+    },
+    'telemetry': {
+        'sample_rate': 1.0,  # Share of sessions whose events are kept
+        'type_sample_rates': {'impression': 0.25},  # Overrides per event type
+    },
+    'robots': {
+        'rules': [
+            {
+                'user_agent': '*',
+                'allow': ['/api/v1/articles', '/feeds/'],
+                'disallow': ['/api/v1/admin/', '/api/v1/auth/', '/api/v1/users/', '/api/v1/settings/', '/admin'],
+                'crawl_delay': None,
+            },
+        ],
+        'sitemaps': [],
+        'extra': '',
+    },
+    'governance': {
+        'min_reputation_to_propose': 50,
+        'min_reputation_to_vote': 10,
+        'max_open_per_user': 3,
+        'voting_days': 7,
+        'min_voting_days': 2,
+        'max_voting_days': 30,
+        'quorum': 10,
+        'pass_threshold': 0.5,
+    },
+    'screening': {
+        'checks': ['rate', 'links', 'toxicity'],
+        'screen_articles': False,
+        'rate': {'max_per_window': 5, 'window_seconds': 300, 'duplicate_window_seconds': 86400},
+        'links': {'blocked_domains': [], 'max_links': 3},
+        'toxicity': {'threshold': 0.8},
+    },
+    'editorial': {
+        'require_approval': False,  # Drafts need an editor's approval before they're published or scheduled
+    },
+    'dead_letters': {
+        'alert_threshold': 10,
+        'thresholds': {},
+        'alert_emails': [],
+        'alert_cooldown_minutes': 60,
+    },
+}
+
+
+class SettingsManager:
+    """Centralized settings management with Redis caching"""
+
+    def __init__(self):
+        self.cache_prefix = 'settings:'
+        self.cache_ttl = 300
+
+    def get(self, key: str) -> Any:
+        """Get a settings value, merged over its default"""
+        default = copy.deepcopy(DEFAULT_SETTINGS.get(key))
+        stored = self._get_cached(key)
+
+        if stored is None:
+            stored = self._get_stored(key)
+            if stored is not None:
+                self._set_cached(key, stored)
+
+        if stored is None:
+            return default
+        if isinstance(default, dict) and isinstance(stored, dict):
+            return {**default, **stored}
+        return stored
+
+    def set(self, key: str, value: Any, updated_by: Optional[str] = None,
+            actor: Optional[Dict[str, Any]] = None) -> Any:
+        """Persist a settings value and invalidate the cache; a change by `actor` goes to the admin audit log"""
+        with get_postgres_cursor() as cursor:
+            before = self._stored_for_update(cursor, key) if actor else None
+            cursor.execute("""
+                INSERT INTO platform_settings (key, value, updated_by, updated_at)
+                VALUES (%s, %s, %s, NOW())
+                ON CONFLICT (key) DO UPDATE
+                SET value = EXCLUDED.value, updated_by = EXCLUDED.updated_by, updated_at = NOW()
+            """, (key, prepare_json_data(value), updated_by))
+            if actor:
+                from shared.audit import record_privileged
+                record_privileged(cursor, actor, 'setting_updated', 'setting', key, before=before, after=value)
+
+        self.invalidate(key)
+        return self.get(key)
+
+    def reset(self, key: str, actor: Optional[Dict[str, Any]] = None) -> Any:
+        """Remove a stored value so the default applies again"""
+        with get_postgres_cursor() as cursor:
+            before = self._stored_for_update(cursor, key) if actor else None
+            cursor.execute("DELETE FROM platform_settings WHERE key = %s", (key,))
+            if actor:
+                from shared.audit import record_privileged
+                record_privileged(cursor, actor, 'setting_reset', 'setting', key, before=before)
+
+        self.invalidate(key)
+        return self.get(key)
+
+    def invalidate(self, key: str):
+        """Drop a cached settings value"""
+        try:
+            get_redis().delete(f"{self.cache_prefix}{key}")
+        except Exception as e:
+            logger.warning(f"Settings cache invalidation error: {e}")
+
+    def _stored_for_update(self, cursor, key: str) -> Any:
+        cursor.execute("SELECT value FROM platform_settings WHERE key = %s FOR UPDATE", (key,))
+        row = cursor.fetchone()
+        return row['value'] if row else None
+
+    def _get_stored(self, key: str) -> Any:
+        try:
+            with get_postgres_cursor() as cursor:
+                cursor.execute("SELECT value FROM platform_settings WHERE key = %s", (key,))
+                row = cursor.fetchone()
+            return row['value'] if row else None
+        except Exception as e:
+            logger.error(f"Settings lookup error for {key}: {e}")
+            return None
+
+    def _get_cached(self, key: str) -> Any:
+        try:
+            cached = get_redis().get(f"{self.cache_prefix}{key}")
+            return json.loads(cached) if cached else None
+        except Exception as e:
+            logger.warning(f"Settings cache read error: {e}")
+            return None
+
+    def _set_cached(self, key: str, value: Any):
+        try:
+            get_redis().setex(f"{self.cache_prefix}{key}", self.cache_ttl, json.dumps(value))
+        except Exception as e:
+            logger.warning(f"Settings cache write error: {e}")
+
+
+# Global settings manager instance
+settings_manager = SettingsManager()
+
+
+# Convenience functions
+def get_setting(key: str) -> Any:
+    return settings_manager.get(key)
+
+def set_setting(key: str, value: Any, updated_by: Optional[str] = None) -> Any:
+    return settings_manager.set(key, value, updated_by)
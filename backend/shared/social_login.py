@@ -0,0 +1,282 @@
+"""
+Social login through Google and GitHub
+
+Readers can sign in with an existing Google or GitHub account instead of
+registering a password. The browser is sent to the provider with a PKCE
+challenge; the frontend callback page posts the returned code and state back,
+and the code is exchanged server-side with the verifier kept in Redis. A social
+identity is linked to an existing account only when the provider vouches for
+the email address; otherwise a new reader account is created.
+"""
+
+import os
+import re
+import json
+import base64
+import hashlib
+import secrets
+import logging
+from datetime import datetime
+from typing import Any, Dict, List, Optional, Tuple
+from urllib.parse import urlencode
+
+import requests
+
+from shared.database import get_redis
+from shared.repositories import repositories
+from shared.utils import generate_uuid
+from shared.webhooks import emit_user_registered
+from shared.events import user_registered
+from shared.account_deletion import cancel_deletion, in_grace_period
+
+logger = logging.getLogger(__name__)
+
+PROVIDERS = {
+    'google': {
+        'authorize_url': 'https://accounts.google.com/o/oauth2/v2/auth',
+        'token_url': 'https://oauth2.googleapis.com/token',
+        'userinfo_url': 'https://openidconnect.googleapis.com/v1/userinfo',
+        'scope': 'openid email profile',
+    },
+    'github': {
+        'authorize_url': 'https://github.com/login/oauth/authorize',
+        'token_url': 'https://github.com/login/oauth/access_token',
+        'userinfo_url': 'https://api.github.com/user',
+        'emails_url': 'https://api.github.com/user/emails',
+        'scope': 'read:user user:email',
+    },
+}
+
+REDIRECT_URI = os.getenv(
+    'SOCIAL_LOGIN_REDIRECT_URI', f"{os.getenv('PUBLIC_BASE_URL', 'http://localhost:3000').rstrip('/')}/auth/callback"
+)
+STATE_TTL_SECONDS = 600
+REQUEST_TIMEOUT_SECONDS = 10
+
+
+class SocialLoginError(Exception):
+    def __init__(self, message: str, status_code: int = 400):
+        super().__init__(message)
+        self.message = message
+        self.status_code = status_code
+
+
+def _credentials(provider: str) -> Tuple[Optional[str], Optional[str]]:
+    prefix = provider.upper()
+    return os.getenv(f'{prefix}_CLIENT_ID'), os.getenv(f'{prefix}_CLIENT_SECRET')
+
+
+def enabled_providers() -> List[str]:
+    """Providers with client credentials configured"""
+    return [provider for provider in PROVIDERS if all(_credentials(provider))]
+
+
+def _require_provider(provider: str) -> Dict[str, str]:
+    if provider not in enabled_providers():
+        raise SocialLoginError(f"Sign-in with {provider} is not available", status_code=404)
+    return PROVIDERS[provider]
+
+
+def _state_key(state: str) -> str:
+    return f"social_login_state:{state}"
+
+
+def start_login(provider: str) -> Dict[str, str]:
+    """Provider authorization URL for the browser, bound to a PKCE verifier kept server-side"""
+    config = _require_provider(provider)
+    client_id, _ = _credentials(provider)
+
+    state = secrets.token_urlsafe(24)
+    code_verifier = secrets.token_urlsafe(64)
+    code_challenge = base64.urlsafe_b64encode(hashlib.sha256(code_verifier.encode()).digest()).rstrip(b'=').decode()
+    get_redis().setex(
+        _state_key(state), STATE_TTL_SECONDS, json.dumps({'provider': provider, 'code_verifier': code_verifier})
+    )
+
+    params = {
+        'client_id': client_id,
+        'redirect_uri': REDIRECT_URI,
+        'response_type': 'code',
+        'scope': config['scope'],
+        'state': state,
+        'code_challenge': code_challenge,
+        'code_challenge_method': 'S256',
+    }
+    return {'authorization_url': f"{config['authorize_url']}?{urlencode(params)}", 'state': state}
+
+
+def _consume_state(provider: str, state: str) -> str:
+    """The PKCE verifier for a login attempt; each state can be used once"""
+    key = _state_key(state)
+    redis_client = get_redis()
+    pipe = redis_client.pipeline()
+    pipe.get(key)
+    pipe.delete(key)
+    stored, _ = pipe.execute()
+    if not stored:
+        raise SocialLoginError("Sign-in attempt expired or was already used; please try again")
+    stored = json.loads(stored)
+    if stored['provider'] != provider:
+        raise SocialLoginError("Sign-in state does not match the provider")
+    return stored['code_verifier']
+
+
+def _exchange_code(provider: str, code: str, code_verifier: str) -> str:
+    config = PROVIDERS[provider]
+    client_id, client_secret = _credentials(provider)
+    response = requests.post(config['token_url'], data={
+        'grant_type': 'authorization_code',
+        'code': code,
+        'redirect_uri': REDIRECT_URI,
+        'client_id': client_id,
+        'client_secret': client_secret,
+        'code_verifier': code_verifier,
+    }, headers={'Accept': 'application/json'}, timeout=REQUEST_TIMEOUT_SECONDS)
+    payload = response.json() if response.content else {}
+    if response.status_code != 200 or 'access_token' not in payload:
+        logger.warning(f"{provider} token exchange failed: {payload.get('error') or response.status_code}")
+        raise SocialLoginError("The provider rejected the sign-in; please try again")
+    return payload['access_token']
+
+
+def _fetch_profile(provider: str, access_token: str) -> Dict[str, Any]:
+    """Normalized provider profile: subject, email, email_verified, login, name, avatar_url"""
+    config = PROVIDERS[provider]
+    headers = {'Authorization': f'Bearer {access_token}', 'Accept': 'application/json'}
+    response = requests.get(config['userinfo_url'], headers=headers, timeout=REQUEST_TIMEOUT_SECONDS)
+    response.raise_for_status()
+    info = response.json()
+
+    if provider == 'google':
+        return {
+            'subject': str(info['sub']),
+            'email': info.get('email'),
+            'email_verified': bool(info.get('email_verified')),
+            'login': (info.get('email') or '').split('@')[0],
+            'name': info.get('name'),
+            'avatar_url': info.get('picture'),
+        }
+
+    # GitHub's profile email is optional and unverified; the primary verified address comes from /user/emails
+    response = requests.get(config['emails_url'], headers=headers, timeout=REQUEST_TIMEOUT_SECONDS)
+    response.raise_for_status()
+    primary = next((entry for entry in response.json() if entry.get('primary') and entry.get('verified')), None)
+    return {
+        'subject': str(info['id']),
+        'email': primary['email'] if primary else None,
+        'email_verified': primary is not None,
+        'login': info.get('login'),
+        'name': info.get('name'),
+        'avatar_url': info.get('avatar_url'),
+    }
+
+
+def _available_username(cursor, login: Optional[str]) -> str:
+    base = re.sub(r'[^a-z0-9_]', '', (login or '').lower())[:40] or 'reader'
+    if len(base) < 3:
+        base = f"{base}_reader"
+    candidate = base
+    while True:
+        # Usernames are unique across publications, which row-level security hides from this cursor
+        cursor.execute("SELECT user_identity_taken(NULL, %s) AS taken", (candidate,))
+        if not cursor.fetchone()['taken']:
+            return candidate
+        candidate = f"{base}_{secrets.token_hex(3)}"
+
+
+def _link_identity(cursor, user_id: str, provider: str, profile: Dict[str, Any]) -> None:
+    cursor.execute("""
+        INSERT INTO user_auth_providers (user_id, provider, provider_user_id, email, email_verified, profile)
+        VALUES (%s, %s, %s, %s, %s, %s)
+    """, (
+        user_id, provider, profile['subject'], profile['email'], profile['email_verified'],
+        {'login': profile['login'], 'name': profile['name'], 'avatar_url': profile['avatar_url']}
+    ))
+
+
+def _create_user(cursor, profile: Dict[str, Any]) -> Dict[str, Any]:
+    now = datetime.now()
+    user_record = repositories(cursor).users.create({
+        'id': generate_uuid(),
+        'username': _available_username(cursor, profile['login']),
+        'email': profile['email'],
+        'password_hash': None,
+        'role': 'reader',
+        'profile_data': {key: profile[key] for key in ('name', 'avatar_url') if profile.get(key)},
+        'preferences': {},
+        'created_at': now,
+        'updated_at': now,
+        'last_active': now,
+    })
+    emit_user_registered(cursor, user_record)
+    user_registered(cursor, user_record)
+    return user_record
+
+
+def complete_login(cursor, provider: str, code: str, state: str) -> Tuple[Dict[str, Any], bool]:
+    """Finish a social login; returns the account and whether it was just created"""
+    _require_provider(provider)
+    code_verifier = _consume_state(provider, state)
+    try:
+        profile = _fetch_profile(provider, _exchange_code(provider, code, code_verifier))
+    except requests.RequestException as e:
+        logger.warning(f"{provider} sign-in request failed: {e}")
+        raise SocialLoginError(f"Could not reach {provider}; please try again", status_code=502)
+
+    users = repositories(cursor).users
+    cursor.execute(
+        "SELECT user_id FROM user_auth_providers WHERE provider = %s AND provider_user_id = %s",
+        (provider, profile['subject'])
+    )
+    identity = cursor.fetchone()
+    if identity:
+        user_record = users.get_by_id(identity['user_id'], active_only=False)
+        cursor.execute("""
+            UPDATE user_auth_providers SET last_login_at = NOW(), email = %s, email_verified = %s
+            WHERE provider = %s AND provider_user_id = %s
+        """, (profile['email'], profile['email_verified'], provider, profile['subject']))
+        created = False
+    else:
+        # Only an address the provider has verified may claim an existing account
+        if not profile['email'] or not profile['email_verified']:
+            raise SocialLoginError(f"Your {provider} account has no verified email address")
+        user_record = users.get_by_email(profile['email'], active_only=False)
+        created = user_record is None
+        if created:
+            user_record = _create_user(cursor, profile)
+        _link_identity(cursor, user_record['id'], provider, profile)
+        logger.info(f"Linked {provider} identity {profile['subject']} to user {user_record['id']}")
+
+    if user_record and in_grace_period(user_record):
+        user_record = cancel_deletion(users, user_record)
+    if not user_record or not user_record.get('is_active', True):
+        raise SocialLoginError("This account is disabled", status_code=403)
+    users.touch_last_active(user_record['id'])
+    return user_record, created
+
+
+def list_identities(cursor, user_id: str) -> List[Dict[str, Any]]:
+    cursor.execute("""
+        SELECT provider, email, profile, created_at, last_login_at
+        FROM user_auth_providers WHERE user_id = %s ORDER BY created_at
+    """, (user_id,))
+    return [dict(identity) for identity in cursor.fetchall()]
+
+
+def unlink_identity(cursor, user_id: str, provider: str) -> bool:
+    """Remove a linked identity, refusing to lock out an account with no other way to sign in"""
+    cursor.execute("""
+        SELECT u.password_hash IS NOT NULL AS has_password,
+               (SELECT COUNT(*) FROM user_auth_providers WHERE user_id = u.id) AS identities
+        FROM users u
+        JOIN user_auth_providers p ON p.user_id = u.id AND p.provider = %s
+        WHERE u.id = %s
+    """, (provider, user_id))
+    account = cursor.fetchone()
+    if not account:
+        return False
+    if not account['has_password'] and account['identities'] <= 1:
+        raise SocialLoginError("Link another sign-in method before removing your only one", status_code=409)
+
+    cursor.execute("DELETE FROM user_auth_providers WHERE user_id = %s AND provider = %s", (user_id, provider))
+    return True
@@ -0,0 +1,264 @@
+"""
+Storage drivers for media and article snapshots
+
+Everything the platform stores as files goes through a `StorageDriver`:
+media such as generated share cards, and the JSON snapshots of published
+articles. Each kind picks its driver by name, STORAGE_MEDIA_DRIVER and
+STORAGE_SNAPSHOT_DRIVER:
+
+- `local`: files under MEDIA_ROOT, served from MEDIA_BASE_URL
+- `local_private`: files under PRIVATE_MEDIA_ROOT, which nothing serves; for
+  uploads to drafts, read back through signed media URLs
+- `ipfs`: added and pinned through an IPFS node's HTTP API, referenced by CID
+- `arweave`: permanent transactions signed with an Arweave wallet, referenced by transaction id
+- `s3`: any S3-compatible object store (AWS, MinIO, R2...)
+
+Keys are chosen by the caller; drivers that address content themselves
+(IPFS, Arweave) return their own reference alongside.
+"""
+
+import os
+import hashlib
+import logging
+import tempfile
+from typing import Any, Callable, Dict, Protocol
+
+import requests
+
+from shared.database import get_postgres_cursor
+
+logger = logging.getLogger(__name__)
+
+MEDIA_DRIVER = os.getenv('STORAGE_MEDIA_DRIVER', 'local')
+SNAPSHOT_DRIVER = os.getenv('STORAGE_SNAPSHOT_DRIVER', 'ipfs')
+REQUEST_TIMEOUT_SECONDS = int(os.getenv('STORAGE_REQUEST_TIMEOUT_SECONDS', 60))
+
+
+# Interface
+class StorageDriver(Protocol):
+    name: str
+
+    def put(self, key: str, data: bytes, content_type: str) -> Dict[str, Any]:
+        """Store `data` and return its `key`, driver `ref` and public `url`"""
+        ...
+
+    def get(self, ref: str) -> bytes: ...
+
+    def health(self) -> None:
+        """Raise if the backend can't be reached or written to"""
+        ...
+
+
+# Implementations
+class LocalDriver:
+    name = 'local'
+
+    def __init__(self):
+        self.root = os.getenv('MEDIA_ROOT', '/app/media')
+        self.base_url = os.getenv('MEDIA_BASE_URL', 'http://localhost/media').rstrip('/')
+
+    def _path(self, key: str) -> str:
+        path = os.path.normpath(os.path.join(self.root, key))
+        if not path.startswith(os.path.normpath(self.root) + os.sep):
+            raise ValueError(f"Key escapes the media root: {key}")
+        return path
+
+    def put(self, key: str, data: bytes, content_type: str) -> Dict[str, Any]:
+        path = self._path(key)
+        if not os.path.exists(path):
+            os.makedirs(os.path.dirname(path), exist_ok=True)
+            # Write then rename so a half-written file is never served
+            handle, temporary = tempfile.mkstemp(dir=os.path.dirname(path))
+            try:
+                with os.fdopen(handle, 'wb') as temporary_file:
+                    temporary_file.write(data)
+                os.chmod(temporary, 0o644)
+                os.replace(temporary, path)
+            except Exception:
+                if os.path.exists(temporary):
+                    os.remove(temporary)
+                raise
+        return {'key': key, 'ref': key, 'url': f"{self.base_url}/{key}" if self.base_url else None}
+
+    def get(self, ref: str) -> bytes:
+        with open(self._path(ref), 'rb') as stored:
+            return stored.read()
+
+    def health(self) -> None:
+        os.makedirs(self.root, exist_ok=True)
+        if not os.access(self.root, os.W_OK):
+            raise RuntimeError(f"{self.root} is not writable")
+
+
+class PrivateLocalDriver(LocalDriver):
+    name = 'local_private'
+
+    def __init__(self):
+        self.root = os.getenv('PRIVATE_MEDIA_ROOT', '/app/media-private')
+        self.base_url = None
+
+
+class IPFSDriver:
+    name = 'ipfs'
+
+    def __init__(self):
+        self.api_url = os.getenv('IPFS_API_URL', 'http://localhost:5001').rstrip('/')
+        self.gateway_url = os.getenv('IPFS_GATEWAY_URL', 'https://ipfs.io/ipfs').rstrip('/')
+
+    def put(self, key: str, data: bytes, content_type: str) -> Dict[str, Any]:
+        response = requests.post(
+            f"{self.api_url}/api/v0/add",
+            params={'pin': 'true', 'cid-version': 1},
+            files={'file': (os.path.basename(key), data, content_type)},
+            timeout=REQUEST_TIMEOUT_SECONDS
+        )
+        response.raise_for_status()
+        cid = response.json()['Hash']
+        return {'key': key, 'ref': cid, 'url': f"{self.gateway_url}/{cid}"}
+
+    def get(self, ref: str) -> bytes:
+        response = requests.post(f"{self.api_url}/api/v0/cat", params={'arg': ref}, timeout=REQUEST_TIMEOUT_SECONDS)
+        response.raise_for_status()
+        return response.content
+
+    def health(self) -> None:
+        requests.post(f"{self.api_url}/api/v0/version", timeout=5).raise_for_status()
+
+
+class ArweaveDriver:
+    name = 'arweave'
+
+    def __init__(self):
+        self.gateway_url = os.getenv('ARWEAVE_GATEWAY_URL', 'https://arweave.net').rstrip('/')
+        self.wallet_path = os.getenv('ARWEAVE_WALLET_PATH', '')
+        self._wallet = None
+
+    def _get_wallet(self):
+        import arweave
+
+        if self._wallet is None:
+            if not self.wallet_path:
+                raise RuntimeError("ARWEAVE_WALLET_PATH must point to a JWK wallet file")
+            self._wallet = arweave.Wallet(self.wallet_path)
+            self._wallet.api_url = self.gateway_url
+        return self._wallet
+
+    def put(self, key: str, data: bytes, content_type: str) -> Dict[str, Any]:
+        import arweave
+
+        transaction = arweave.Transaction(self._get_wallet(), data=data)
+        transaction.add_tag('Content-Type', content_type)
+        transaction.add_tag('App-Key', key)
+        transaction.sign()
+        transaction.send()
+        return {'key': key, 'ref': transaction.id, 'url': f"{self.gateway_url}/{transaction.id}"}
+
+    def get(self, ref: str) -> bytes:
+        response = requests.get(f"{self.gateway_url}/{ref}", timeout=REQUEST_TIMEOUT_SECONDS)
+        response.raise_for_status()
+        return response.content
+
+    def health(self) -> None:
+        requests.get(f"{self.gateway_url}/info", timeout=5).raise_for_status()
+        self._get_wallet()
+
+
+class S3Driver:
+    name = 's3'
+
+    def __init__(self):
+        self.bucket = os.getenv('S3_BUCKET', '')
+        self.endpoint_url = os.getenv('S3_ENDPOINT_URL') or None
+        self.public_base_url = (
+            os.getenv('S3_PUBLIC_BASE_URL')
+            or f"{(self.endpoint_url or 'https://s3.amazonaws.com').rstrip('/')}/{self.bucket}"
+        ).rstrip('/')
+        self._client = None
+
+    def _get_client(self):
+        import boto3
+
+        if self._client is None:
+            if not self.bucket:
+                raise RuntimeError("S3_BUCKET must be set")
+            self._client = boto3.client(
+                's3',
+                endpoint_url=self.endpoint_url,
+                region_name=os.getenv('S3_REGION', 'us-east-1'),
+                aws_access_key_id=os.getenv('S3_ACCESS_KEY_ID') or None,
+                aws_secret_access_key=os.getenv('S3_SECRET_ACCESS_KEY') or None,
+            )
+        return self._client
+
+    def put(self, key: str, data: bytes, content_type: str) -> Dict[str, Any]:
+        self._get_client().put_object(Bucket=self.bucket, Key=key, Body=data, ContentType=content_type)
+        return {'key': key, 'ref': key, 'url': f"{self.public_base_url}/{key}"}
+
+    def get(self, ref: str) -> bytes:
+        return self._get_client().get_object(Bucket=self.bucket, Key=ref)['Body'].read()
+
+    def health(self) -> None:
+        self._get_client().head_bucket(Bucket=self.bucket)
+
+
+DRIVERS = {
+    'local': LocalDriver,
+    'local_private': PrivateLocalDriver,
+    'ipfs': IPFSDriver,
+    'arweave': ArweaveDriver,
+    's3': S3Driver,
+}
+
+_drivers: Dict[str, StorageDriver] = {}
+
+
+def get_driver(name: str) -> StorageDriver:
+    if name not in DRIVERS:
+        raise ValueError(f"Unknown storage driver: {name}")
+    if name not in _drivers:
+        _drivers[name] = DRIVERS[name]()
+    return _drivers[name]
+
+
+def media_driver() -> StorageDriver:
+    return get_driver(MEDIA_DRIVER)
+
+
+def snapshot_driver() -> StorageDriver:
+    return get_driver(SNAPSHOT_DRIVER)
+
+
+def snapshots_enabled() -> bool:
+    # IPFS_PINNING_ENABLED is the setting's name from before snapshots could go elsewhere
+    return os.getenv('ARTICLE_SNAPSHOTS_ENABLED', os.getenv('IPFS_PINNING_ENABLED', 'false')).lower() == 'true'
+
+
+def health_checks() -> Dict[str, Callable[[], None]]:
+    """Health check callables for the drivers in use, keyed by service name"""
+    checks = {f"storage_media:{MEDIA_DRIVER}": media_driver().health}
+    if snapshots_enabled():
+        checks[f"storage_snapshots:{SNAPSHOT_DRIVER}"] = snapshot_driver().health
+    return checks
+
+
+def store_snapshot(article_id: str, document: str) -> Dict[str, Any]:
+    """Store a published article's JSON snapshot with the snapshot driver and record where it went"""
+    driver = snapshot_driver()
+    data = document.encode()
+    digest = hashlib.sha256(data).hexdigest()
+    # One key per version, so object stores keep every snapshot rather than overwriting it
+    stored = driver.put(f"articles/{article_id}/{digest[:32]}.json", data, 'application/json')
+
+    with get_postgres_cursor() as cursor:
+        cursor.execute("""
+            INSERT INTO article_snapshots (article_id, driver, ref, url, content_sha256, size)
+            VALUES (%s, %s, %s, %s, %s, %s)
+            RETURNING *
+        """, (article_id, driver.name, stored['ref'], stored['url'], digest, len(data)))
+        snapshot = dict(cursor.fetchone())
+        if driver.name == 'ipfs':
+            cursor.execute(
+                "UPDATE articles SET ipfs_cid = %s, ipfs_pinned_at = NOW() WHERE id = %s", (stored['ref'], article_id)
+            )
+    logger.info(f"Stored snapshot of article {article_id} with the {driver.name} driver: {stored['ref']}")
+    return snapshot
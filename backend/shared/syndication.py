@@ -0,0 +1,165 @@
+"""
+Licensed partner syndication
+
+Partners authenticate with an API key and pull full article payloads. Pulls
+are incremental: every page returns a delta token encoding the last
+(updated_at, id) seen, and the next pull resumes strictly after it. Articles
+that were taken down or relicensed out of a partner's scope since the last
+pull are reported as removed so partners can unpublish their copies. Every
+payload passes through the partner's redaction policy before it leaves.
+"""
+
+import hashlib
+import logging
+import secrets
+from typing import Any, Dict, List, Optional, Tuple
+
+from shared.feed_formats import article_url
+from shared.licensing import license_info, reusable_licenses
+from shared.paywall import public_copy
+from shared.redaction import redact_payload
+from shared.utils import encode_cursor, decode_cursor, deserialize_datetime
+
+logger = logging.getLogger(__name__)
+
+API_KEY_PREFIX = 'synd_'
+
+
+def generate_api_key() -> Tuple[str, str, str]:
+    """Create a new partner key, returning (key, sha256 hash, display prefix)"""
+    api_key = f"{API_KEY_PREFIX}{secrets.token_urlsafe(32)}"
+    return api_key, hash_api_key(api_key), api_key[:12]
+
+
+def hash_api_key(api_key: str) -> str:
+    return hashlib.sha256(api_key.encode()).hexdigest()
+
+
+def authenticate_partner(cursor, api_key: str) -> Optional[Dict[str, Any]]:
+    """Resolve an active partner from its API key"""
+    if not api_key or not api_key.startswith(API_KEY_PREFIX):
+        return None
+
+    cursor.execute("""
+        UPDATE syndication_partners SET last_used_at = NOW()
+        WHERE api_key_hash = %s AND is_active = true
+        RETURNING *
+    """, (hash_api_key(api_key),))
+    partner = cursor.fetchone()
+    return dict(partner) if partner else None
+
+
+def partner_licenses(partner: Dict[str, Any]) -> List[str]:
+    """Licenses a partner may syndicate"""
+    if partner.get('allowed_licenses'):
+        return list(partner['allowed_licenses'])
+    return reusable_licenses(commercial=bool(partner.get('commercial_use')))
+
+
+def record_usage(cursor, partner_id: str, endpoint: str, article_count: int = 0):
+    """Count one request against a partner's daily usage"""
+    cursor.execute("""
+        INSERT INTO syndication_usage (partner_id, usage_date, endpoint, request_count, article_count)
+        VALUES (%s, CURRENT_DATE, %s, 1, %s)
+        ON CONFLICT (partner_id, usage_date, endpoint) DO UPDATE
+        SET request_count = syndication_usage.request_count + 1,
+            article_count = syndication_usage.article_count + EXCLUDED.article_count
+    """, (partner_id, endpoint, article_count))
+
+
+def build_payload(article: Dict[str, Any]) -> Dict[str, Any]:
+    """Full-fidelity syndication payload for one article row"""
+    author = None
+    if not article.get('anonymous_author') and article.get('author_id'):
+        author = {
+            'id': str(article['author_id']),
+            'username': article.get('author_username'),
+            'did_address': article.get('author_did_address'),
+        }
+
+    # Partners republish to their own readers, so premium articles are syndicated as previews
+    premium = bool(article.get('access_policy'))
+    article = public_copy(article)
+    return {
+        'id': str(article['id']),
+        'canonical_url': article_url(article),
+        'title': article['title'],
+        'summary': article.get('summary'),
+        'content': article['content_html'],
+        'content_format': 'html',
+        'premium': premium,
+        'category': article.get('category'),
+        'subcategory': article.get('subcategory'),
+        'tags': list(article.get('tags') or []),
+        'language': article.get('language'),
+        'author': author,
+        'image_urls': list(article.get('image_urls') or []),
+        'metadata': dict(article.get('metadata') or {}),
+        'word_count': article.get('word_count'),
+        'reading_time': article.get('reading_time'),
+        'published_at': article.get('published_at'),
+        'updated_at': article.get('updated_at'),
+        'license': license_info(article),
+    }
+
+
+def pull_delta(cursor, partner: Dict[str, Any], delta_token: Optional[str], limit: int) -> Dict[str, Any]:
+    """Return changes since the delta token, oldest first"""
+    position = decode_cursor(delta_token) if delta_token else {}
+    if position is None:
+        raise ValueError("Invalid delta token")
+
+    since = deserialize_datetime(position.get('updated_at')) if position.get('updated_at') else None
+    if position.get('updated_at') and since is None:
+        raise ValueError("Invalid delta token")
+
+    query = """
+        SELECT a.*, u.username as author_username, u.did_address as author_did_address,
+               (a.status = 'published' AND a.license = ANY(%s)) as syndicated
+        FROM articles a
+        LEFT JOIN users u ON u.id = a.author_id
+        WHERE a.published_at IS NOT NULL
+    """
+    params: List[Any] = [partner_licenses(partner)]
+
+    if since is not None:
+        query += " AND (a.updated_at, a.id) > (%s, %s::uuid)"
+        params.extend([since, position.get('id')])
+
+    query += " ORDER BY a.updated_at ASC, a.id ASC LIMIT %s"
+    params.append(limit + 1)
+
+    cursor.execute(query, params)
+    rows = cursor.fetchall()
+
+    has_more = len(rows) > limit
+    rows = rows[:limit]
+
+    policy = partner.get('redaction_policy')
+    items = [redact_payload(build_payload(row), policy) for row in rows if row['syndicated']]
+    removed = [str(row['id']) for row in rows if not row['syndicated']]
+
+    if rows:
+        last = rows[-1]
+        next_token = encode_cursor({'updated_at': last['updated_at'].isoformat(), 'id': str(last['id'])})
+    else:
+        next_token = delta_token
+
+    return {
+        'items': items,
+        'removed': removed,
+        'delta_token': next_token,
+        'has_more': has_more,
+    }
+
+
+def get_syndicated_article(cursor, partner: Dict[str, Any], article_id: str) -> Optional[Dict[str, Any]]:
+    """Fetch one article if the partner may syndicate it"""
+    cursor.execute("""
+        SELECT a.*, u.username as author_username, u.did_address as author_did_address
+        FROM articles a
+        LEFT JOIN users u ON u.id = a.author_id
+        WHERE a.id = %s AND a.status = 'published' AND a.license = ANY(%s)
+    """, (article_id, partner_licenses(partner)))
+    row = cursor.fetchone()
+    return redact_payload(build_payload(row), partner.get('redaction_policy')) if row else None
@@ -0,0 +1,80 @@
+"""
+Tag and category suggestions
+
+Authors type a few letters and get the tags or categories already in use,
+most used first, so they pick an existing one instead of inventing a near
+duplicate ("blockchain" vs "block-chain"). Usage is counted over published
+articles in the `taxonomy_usage` materialized view, which a worker
+refreshes every TAXONOMY_REFRESH_INTERVAL_SECONDS; a tag first used since
+then is not suggested until the next refresh.
+
+Values compare case-insensitively and are suggested in the spelling most
+articles use. Prefix matches come first, then values that are merely
+similar (pg_trgm similarity of at least TAXONOMY_SIMILARITY_THRESHOLD),
+which is what catches misspellings and variants.
+"""
+
+import os
+import logging
+from typing import Any, Dict, List
+
+logger = logging.getLogger(__name__)
+
+DIMENSIONS = ('tag', 'category')
+SIMILARITY_THRESHOLD = float(os.getenv('TAXONOMY_SIMILARITY_THRESHOLD', 0.3))
+MAX_SUGGESTIONS = 50
+
+
+def _like_prefix(q: str) -> str:
+    return q.replace('\\', '\\\\').replace('%', '\\%').replace('_', '\\_') + '%'
+
+
+def suggest(cursor, dimension: str, q: str, limit: int = 10) -> List[Dict[str, Any]]:
+    """Tags or categories matching `q`, or the most used ones when `q` is empty"""
+    if dimension not in DIMENSIONS:
+        raise ValueError(f"Unknown dimension: {dimension}")
+    q = (q or '').strip().lower()
+    limit = max(1, min(limit, MAX_SUGGESTIONS))
+
+    if not q:
+        cursor.execute("""
+            SELECT display, usage_count, last_used_at FROM taxonomy_usage
+            WHERE dimension = %s
+            ORDER BY usage_count DESC, value
+            LIMIT %s
+        """, (dimension, limit))
+        return [_suggestion(row) for row in cursor.fetchall()]
+
+    # `%` is the similarity operator, which unlike similarity() can use the trigram index
+    cursor.execute("SET LOCAL pg_trgm.similarity_threshold = %s", (SIMILARITY_THRESHOLD,))
+    cursor.execute("""
+        SELECT display, usage_count, last_used_at, value = %(q)s AS exact,
+               value LIKE %(prefix)s AS prefix_match, similarity(value, %(q)s) AS similarity
+        FROM taxonomy_usage
+        WHERE dimension = %(dimension)s
+          AND (value LIKE %(prefix)s OR value %% %(q)s)
+        ORDER BY exact DESC, prefix_match DESC, usage_count DESC, similarity DESC, value
+        LIMIT %(limit)s
+    """, {'q': q, 'prefix': _like_prefix(q), 'dimension': dimension, 'limit': limit})
+    return [_suggestion(row) for row in cursor.fetchall()]
+
+
+def _suggestion(row: Dict[str, Any]) -> Dict[str, Any]:
+    suggestion = {
+        'value': row['display'],
+        'usage_count': int(row['usage_count']),
+        'last_used_at': row['last_used_at'].isoformat() if row['last_used_at'] else None,
+    }
+    if 'similarity' in row:
+        suggestion['exact'] = row['exact']
+        suggestion['similarity'] = round(float(row['similarity']), 3)
+    return suggestion
+
+
+def refresh_usage(cursor) -> int:
+    """Recount tag and category usage without blocking suggestions while it runs"""
+    cursor.execute("REFRESH MATERIALIZED VIEW CONCURRENTLY taxonomy_usage")
+    cursor.execute("SELECT COUNT(*) AS total FROM taxonomy_usage")
+    total = cursor.fetchone()['total']
+    logger.info(f"Refreshed taxonomy usage: {total} tags and categories")
+    return total
@@ -0,0 +1,181 @@
+"""
+Client telemetry events
+
+Readers' browsers report scroll depth, dwell time and impressions in batches
+to `POST /api/v1/events/batch`. Events are stored in the MongoDB
+`telemetry_events` collection, which a TTL index empties after
+TELEMETRY_RETENTION_DAYS.
+
+Each FastAPI process holds accepted events in memory and a flusher writes
+them with unordered bulk inserts every TELEMETRY_FLUSH_SECONDS, or sooner
+once TELEMETRY_BATCH_SIZE are waiting. While MongoDB is unreachable the
+buffer keeps up to TELEMETRY_BUFFER_MAX_EVENTS (oldest dropped first).
+
+The `telemetry` setting sets the share of sessions kept, overall and per
+event type. Sampling is decided by a hash of the session id, so a session is
+kept or dropped as a whole, and each stored event carries its sample rate so
+counts can be scaled back up.
+"""
+
+import os
+import asyncio
+import hashlib
+import logging
+import threading
+from collections import deque
+from datetime import datetime, timedelta, timezone
+from typing import Any, Deque, Dict, List, Optional
+
+from pymongo import ASCENDING
+from pymongo.errors import BulkWriteError
+
+from shared.database import get_mongodb
+from shared.settings import get_setting
+
+logger = logging.getLogger(__name__)
+
+COLLECTION = 'telemetry_events'
+EVENT_TYPES = ('scroll_depth', 'dwell', 'impression')
+
+BATCH_SIZE = int(os.getenv('TELEMETRY_BATCH_SIZE', 1000))
+FLUSH_SECONDS = float(os.getenv('TELEMETRY_FLUSH_SECONDS', 5))
+MAX_BACKOFF_SECONDS = float(os.getenv('TELEMETRY_MAX_BACKOFF_SECONDS', 60))
+BUFFER_MAX_EVENTS = int(os.getenv('TELEMETRY_BUFFER_MAX_EVENTS', 100000))
+RETENTION_DAYS = int(os.getenv('TELEMETRY_RETENTION_DAYS', 30))
+
+# Client clocks drift; events stamped further out than this are rejected
+MAX_FUTURE_SKEW = timedelta(minutes=5)
+MAX_AGE = timedelta(days=1)
+
+_buffer: Deque[Dict[str, Any]] = deque(maxlen=BUFFER_MAX_EVENTS)
+_buffer_lock = threading.Lock()
+_indexes_ready = False
+
+
+def sample_rate(event_type: str) -> float:
+    config = get_setting('telemetry')
+    return float(config.get('type_sample_rates', {}).get(event_type, config.get('sample_rate', 1.0)))
+
+
+def sampled(session_id: str, rate: float) -> bool:
+    """Whether the session falls inside the sampled share"""
+    if rate >= 1:
+        return True
+    if rate <= 0:
+        return False
+    bucket = int(hashlib.sha256(session_id.encode()).hexdigest()[:8], 16) / 0xFFFFFFFF
+    return bucket < rate
+
+
+def accept(session_id: str, user_id: Optional[str], events: List[Dict[str, Any]]) -> Dict[str, int]:
+    """Buffer a batch, returning how many events were accepted, sampled out and rejected as stale"""
+    now = datetime.now(timezone.utc)
+    rates = {event_type: sample_rate(event_type) for event_type in EVENT_TYPES}
+    counts = {'accepted': 0, 'sampled_out': 0, 'rejected': 0}
+
+    documents = []
+    for event in events:
+        occurred_at = event['occurred_at']
+        if occurred_at.tzinfo is None:
+            occurred_at = occurred_at.replace(tzinfo=timezone.utc)
+        if occurred_at > now + MAX_FUTURE_SKEW or occurred_at < now - MAX_AGE:
+            counts['rejected'] += 1
+            continue
+        rate = rates[event['type']]
+        if not sampled(session_id, rate):
+            counts['sampled_out'] += 1
+            continue
+        documents.append({
+            'type': event['type'],
+            'session_id': session_id,
+            'user_id': user_id,
+            'article_id': str(event['article_id']) if event.get('article_id') else None,
+            'value': event.get('value'),
+            'properties': event.get('properties') or {},
+            'occurred_at': occurred_at,
+            'received_at': now,
+            'sample_rate': rate,
+        })
+
+    if documents:
+        with _buffer_lock:
+            _buffer.extend(documents)
+    counts['accepted'] = len(documents)
+    return counts
+
+
+def ensure_indexes(collection):
+    """Create the query and TTL indexes, adjusting the TTL when TELEMETRY_RETENTION_DAYS changes"""
+    global _indexes_ready
+    if _indexes_ready:
+        return
+    collection.create_index([('article_id', ASCENDING), ('type', ASCENDING), ('occurred_at', ASCENDING)])
+    collection.create_index([('session_id', ASCENDING), ('occurred_at', ASCENDING)])
+
+    ttl = RETENTION_DAYS * 86400
+    existing = collection.index_information().get('received_at_1')
+    if existing is None:
+        collection.create_index([('received_at', ASCENDING)], expireAfterSeconds=ttl)
+    elif existing.get('expireAfterSeconds') != ttl:
+        collection.database.command(
+            'collMod', COLLECTION, index={'keyPattern': {'received_at': 1}, 'expireAfterSeconds': ttl}
+        )
+        logger.info(f"Telemetry retention changed to {RETENTION_DAYS} days")
+    _indexes_ready = True
+
+
+def _take(limit: int) -> List[Dict[str, Any]]:
+    with _buffer_lock:
+        return [_buffer.popleft() for _ in range(min(limit, len(_buffer)))]
+
+
+def _put_back(documents: List[Dict[str, Any]]):
+    with _buffer_lock:
+        _buffer.extendleft(reversed(documents))
+
+
+def flush() -> int:
+    """Bulk insert one batch; on failure the batch goes back to the front of the buffer"""
+    documents = _take(BATCH_SIZE)
+    if not documents:
+        return 0
+    try:
+        collection = get_mongodb()[COLLECTION]
+        ensure_indexes(collection)
+        # Unordered so one bad document doesn't stop the rest; insert_many adds _id to each
+        collection.insert_many(documents, ordered=False)
+    except BulkWriteError as e:
+        # Retrying wouldn't help the rejected documents, and the rest were written
+        logger.warning(f"Dropped {len(e.details.get('writeErrors', []))} telemetry events MongoDB rejected")
+    except Exception:
+        for document in documents:
+            document.pop('_id', None)
+        _put_back(documents)
+        raise
+    return len(documents)
+
+
+def buffered() -> int:
+    return len(_buffer)
+
+
+async def run():
+    """Flush the buffer until cancelled, backing off while MongoDB is down, then flush what's left"""
+    backoff = FLUSH_SECONDS
+    try:
+        while True:
+            await asyncio.sleep(backoff)
+            try:
+                while await asyncio.to_thread(flush) >= BATCH_SIZE:
+                    pass
+                backoff = FLUSH_SECONDS
+            except Exception as e:
+                backoff = min(backoff * 2, MAX_BACKOFF_SECONDS)
+                logger.warning(f"Telemetry flush failed, {buffered()} events buffered; retrying in {backoff:.0f}s: {e}")
+    except asyncio.CancelledError:
+        try:
+            while flush():
+                pass
+        except Exception as e:
+            logger.warning(f"Dropping {buffered()} telemetry events on shutdown: {e}")
+        raise
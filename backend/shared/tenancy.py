@@ -0,0 +1,405 @@
+"""
+Row-level multi-tenancy: several publications on one deployment
+
+With TENANCY_ENABLED set, each request is served for one publication (a
+tenant in `tenants`), resolved in this order:
+
+- the `X-Tenant` header, holding a tenant's slug; an unknown or inactive
+  slug gets 404 rather than the default publication
+- the request's Host, matched against the tenants' `domains`
+- otherwise the default publication, whose rows have no tenant
+
+Users, articles, interactions, member invitations and editorial reviews
+carry a `tenant_id`, as do the rows owned through them: comments and their
+screenings, article pins, webhooks with their deliveries, and ActivityPub
+actor keys. The tenant is set on each PostgreSQL connection as
+`app.tenant_id`, which those columns default to, so rows are created in the
+request's publication even by handlers that write their own SQL. Reads are
+scoped twice. The repository layer (shared/repositories.py) adds the
+publication to its queries and refuses a row from another one. Row-level
+security policies on the tables themselves
+(database/postgresql/schemas/65_tenant_isolation.sql) hide other
+publications' rows from every query a connection runs, so a handler that
+forgets the scope still can't reach them. A token issued by one publication
+doesn't authenticate on another. Usernames and emails stay unique across
+the deployment. Jobs enqueued by a request run for its publication.
+Entries in shared caches (rendered articles, public and home feeds, bot
+responses) are keyed by the publication served, so a cache hit never skips
+the checks that keep another publication's rows out of reach.
+
+Connections are held to a publication (`confined`) unless they're made
+`unscoped`: those made while serving a request or a publication's job to
+that publication, and any other to the default publication. Periodic jobs,
+the backends' startup and background workers, and deployment-wide
+administration are unscoped and see every publication. `isolation_report`
+checks the guarantees hold for the running deployment; they don't if it
+connects to PostgreSQL as a superuser or a role with BYPASSRLS, which skip
+row-level security. With tenancy enabled, the backends refuse to start
+until they do (`check_isolation`).
+
+Each tenant has its own branding, served by /api/v1/branding on its
+domains, and quotas: `max_users`, `max_articles` (drafts included) and
+`requests_per_minute`. Exceeding a quota raises QuotaExceeded, or answers
+429 for the request rate. Tenants are managed through /api/v1/admin/tenants
+by administrators of the default publication; each publication manages its
+own editors and contributors (shared/members.py).
+"""
+
+import os
+import time
+import json
+import uuid
+import logging
+from contextlib import contextmanager
+from contextvars import ContextVar
+from typing import Any, Dict, List, Optional, Tuple
+
+from shared.database import get_postgres_cursor, get_redis
+
+logger = logging.getLogger(__name__)
+
+HEADER = 'X-Tenant'
+SETTING = 'app.tenant_id'
+# 'all' lets a connection see every publication's rows; anything else holds it to `app.tenant_id`'s
+SCOPE_SETTING = 'app.tenant_scope'
+# Marks jobs enqueued by the default publication's requests, which have no tenant id
+DEFAULT = 'default'
+
+# Tables with a tenant_id, whose rows row-level security hides from other publications
+ISOLATED_TABLES = (
+    'users', 'articles', 'user_interactions', 'publication_invitations', 'editorial_reviews', 'editorial_events',
+    'article_authors', 'comments', 'comment_screenings', 'article_pins', 'webhooks', 'webhook_deliveries',
+    'webhook_dead_letters', 'ap_actor_keys',
+)
+CACHE_SECONDS = float(os.getenv('TENANT_CACHE_SECONDS', 30))
+
+QUOTAS = ('max_users', 'max_articles', 'requests_per_minute')
+
+
+class UnknownTenant(Exception):
+    """The X-Tenant header names no active tenant"""
+
+
+class QuotaExceeded(Exception):
+    """A publication is at one of its quotas"""
+
+
+class IsolationError(Exception):
+    """The deployment wouldn't keep publications apart"""
+
+
+_current: ContextVar[Optional[Dict[str, Any]]] = ContextVar('tenant', default=None)
+# Fails closed: a connection only sees every publication inside `unscoped`
+_confined: ContextVar[bool] = ContextVar('tenant_confined', default=True)
+
+
+def enabled() -> bool:
+    from shared.modules import is_enabled
+    return is_enabled('tenancy')
+
+
+def current() -> Optional[Dict[str, Any]]:
+    """The publication being served; None for the default publication"""
+    return _current.get()
+
+
+def current_id() -> Optional[str]:
+    tenant = _current.get()
+    return str(tenant['id']) if tenant else None
+
+
+def cache_namespace() -> str:
+    """Segment of shared cache keys naming the publication being served, so entries never cross publications"""
+    return current_id() or DEFAULT
+
+
+@contextmanager
+def scope_of(tenant_id: Optional[str]):
+    """Run a block for the publication with this id (None for the default one); raises UnknownTenant if inactive"""
+    tenant = by_id(tenant_id) if tenant_id else None
+    if tenant_id and not tenant:
+        raise UnknownTenant(f"Publication {tenant_id} is not active")
+    with scope(tenant):
+        yield
+
+
+def confined() -> bool:
+    """Whether connections only see the current publication's rows; False for work across publications"""
+    return _confined.get()
+
+
+@contextmanager
+def scope(tenant: Optional[Dict[str, Any]]):
+    """Run a block for a publication (None for the default one), seeing only its rows"""
+    token = _current.set(tenant)
+    confined_token = _confined.set(True)
+    try:
+        yield
+    finally:
+        _confined.reset(confined_token)
+        _current.reset(token)
+
+
+@contextmanager
+def unscoped():
+    """Run a block across every publication, for deployment-wide administration"""
+    token = _current.set(None)
+    confined_token = _confined.set(False)
+    try:
+        yield
+    finally:
+        _confined.reset(confined_token)
+        _current.reset(token)
+
+
+def begin(tenant: Optional[Dict[str, Any]]):
+    """Serve the rest of the request for a publication, without a with-block"""
+    _current.set(tenant)
+    _confined.set(True)
+
+
+def end():
+    _current.set(None)
+    _confined.set(True)
+
+
+def begin_unscoped():
+    """Work across every publication for the rest of the current context, without a with-block"""
+    _current.set(None)
+    _confined.set(False)
+
+
+def use_tenant(cursor):
+    """Hold a new connection to the current publication's rows, and have the rows it inserts belong to it"""
+    tenant_id = current_id()
+    if enabled() and confined():
+        cursor.execute(
+            "SELECT set_config(%s, %s, false), set_config(%s, 'tenant', false)",
+            (SETTING, tenant_id or '', SCOPE_SETTING)
+        )
+        return
+    cursor.execute("SELECT set_config(%s, 'all', false)", (SCOPE_SETTING,))
+    if tenant_id:
+        cursor.execute("SELECT set_config(%s, %s, false)", (SETTING, tenant_id))
+
+
+# Lookup
+_cache: Dict[Tuple[str, str, bool], Tuple[float, Optional[Dict[str, Any]]]] = {}
+
+
+def _lookup(column: str, value: str) -> Optional[Dict[str, Any]]:
+    from shared import sandbox
+
+    # Lookups run before the request's tenant is set, so they always see every tenant
+    key = (column, value, sandbox.active())
+    cached = _cache.get(key)
+    if cached and cached[0] > time.monotonic():
+        return cached[1]
+
+    with scope(None), get_postgres_cursor() as cursor:
+        if column == 'domain':
+            cursor.execute("SELECT * FROM tenants WHERE %s = ANY(domains) AND is_active = true", (value,))
+        else:
+            cursor.execute(f"SELECT * FROM tenants WHERE {column} = %s AND is_active = true", (value,))
+        row = cursor.fetchone()
+    tenant = dict(row) if row else None
+    _cache[key] = (time.monotonic() + CACHE_SECONDS, tenant)
+    return tenant
+
+
+def by_slug(slug: str) -> Optional[Dict[str, Any]]:
+    return _lookup('slug', slug.strip().lower())
+
+
+def by_id(tenant_id: str) -> Optional[Dict[str, Any]]:
+    return _lookup('id', str(tenant_id))
+
+
+def by_domain(host: Optional[str]) -> Optional[Dict[str, Any]]:
+    from shared.branding import normalize_host
+
+    domain = normalize_host(host)
+    return _lookup('domain', domain) if domain else None
+
+
+def invalidate():
+    """Forget cached tenants in this process; other processes catch up within CACHE_SECONDS"""
+    _cache.clear()
+
+
+def resolve(header: Optional[str], host: Optional[str]) -> Optional[Dict[str, Any]]:
+    """The publication a request is for, or raise UnknownTenant"""
+    if header:
+        tenant = by_slug(header)
+        if not tenant:
+            raise UnknownTenant(f"Unknown publication '{header}'")
+        return tenant
+    return by_domain(host)
+
+
+# Quotas
+def quota(tenant: Optional[Dict[str, Any]], name: str) -> Optional[int]:
+    value = ((tenant or {}).get('quotas') or {}).get(name)
+    return int(value) if value is not None else None
+
+
+def usage(cursor, tenant_id: str) -> Dict[str, int]:
+    cursor.execute("""
+        SELECT
+            (SELECT COUNT(*) FROM users WHERE tenant_id = %s AND deleted_at IS NULL) AS users,
+            (SELECT COUNT(*) FROM articles WHERE tenant_id = %s AND status <> 'archived') AS articles
+    """, (tenant_id, tenant_id))
+    counts = cursor.fetchone()
+    return {'users': counts['users'], 'articles': counts['articles']}
+
+
+def check_quota(cursor, resource: str):
+    """Raise QuotaExceeded if the current publication can't have another user or article"""
+    tenant = current()
+    limit = quota(tenant, f"max_{resource}")
+    if limit is None:
+        return
+    if usage(cursor, str(tenant['id']))[resource] >= limit:
+        raise QuotaExceeded(f"This publication has reached its limit of {limit} {resource}")
+
+
+def request_allowed(tenant: Dict[str, Any]) -> bool:
+    """Count a request against the publication's per-minute quota; fails open if Redis is down"""
+    limit = quota(tenant, 'requests_per_minute')
+    if limit is None:
+        return True
+    window = f"tenant:requests:{tenant['id']}:{int(time.time() // 60)}"
+    try:
+        redis_client = get_redis()
+        count = redis_client.incr(window)
+        if count == 1:
+            redis_client.expire(window, 120)
+    except Exception as e:
+        logger.warning(f"Tenant request quota skipped: {e}")
+        return True
+    return count <= limit
+
+
+def requests_this_minute(tenant_id: str) -> int:
+    try:
+        return int(get_redis().get(f"tenant:requests:{tenant_id}:{int(time.time() // 60)}") or 0)
+    except Exception:
+        return 0
+
+
+# Isolation checks
+def isolation_report(cursor) -> Dict[str, Any]:
+    """Check that connections can't reach another publication's rows or decrypt its values"""
+    from shared import tenant_keys
+
+    problems = []
+    cursor.execute("SELECT rolname, rolsuper, rolbypassrls FROM pg_roles WHERE rolname = current_user")
+    role = cursor.fetchone()
+    if role['rolsuper'] or role['rolbypassrls']:
+        problems.append(
+            f"The database role {role['rolname']} bypasses row-level security; "
+            "connect as a role without SUPERUSER or BYPASSRLS"
+        )
+
+    cursor.execute("""
+        SELECT c.relname, c.relrowsecurity, c.relforcerowsecurity,
+               EXISTS(SELECT 1 FROM pg_policy p WHERE p.polrelid = c.oid) AS has_policy
+        FROM pg_class c
+        WHERE c.oid = ANY(SELECT to_regclass(name) FROM unnest(%s::text[]) AS name)
+    """, (list(ISOLATED_TABLES),))
+    for table in cursor.fetchall():
+        if not (table['relrowsecurity'] and table['relforcerowsecurity'] and table['has_policy']):
+            problems.append(f"Row-level security isn't enforced on {table['relname']}")
+
+    # A publication that doesn't exist must see no rows at all
+    cursor.execute(
+        "SELECT current_setting(%s, true) AS tenant_id, current_setting(%s, true) AS scope", (SETTING, SCOPE_SETTING)
+    )
+    connection = cursor.fetchone()
+    probe = str(uuid.uuid4())
+    cursor.execute(
+        "SELECT set_config(%s, %s, true), set_config(%s, 'tenant', true)", (SETTING, probe, SCOPE_SETTING)
+    )
+    for table in ISOLATED_TABLES:
+        cursor.execute(f"SELECT EXISTS(SELECT 1 FROM {table}) AS visible")
+        if cursor.fetchone()['visible']:
+            problems.append(f"A connection held to one publication can read other publications' {table}")
+    cursor.execute(
+        "SELECT set_config(%s, %s, true), set_config(%s, %s, true)",
+        (SETTING, connection['tenant_id'] or '', SCOPE_SETTING, connection['scope'] or '')
+    )
+
+    try:
+        sealed = tenant_keys.encrypt('isolation-probe', probe)
+    except tenant_keys.MissingMasterKey as e:
+        problems.append(str(e))
+    else:
+        try:
+            tenant_keys.decrypt(sealed, None)
+            problems.append("A value encrypted for one publication can be decrypted by another")
+        except tenant_keys.DecryptionError:
+            pass
+
+    return {'isolated': not problems, 'database_role': role['rolname'], 'problems': problems}
+
+
+def check_isolation():
+    """Run the isolation checks at startup, refusing to go on when publications wouldn't be kept apart"""
+    try:
+        with get_postgres_cursor() as cursor:
+            report = isolation_report(cursor)
+    except Exception as e:
+        raise IsolationError(f"Tenant isolation couldn't be checked: {e}") from e
+    for problem in report['problems']:
+        logger.error(f"Tenant isolation: {problem}")
+    if report['problems']:
+        raise IsolationError(
+            f"Tenancy is enabled but publications aren't kept apart: {'; '.join(report['problems'])}"
+        )
+
+
+# Middleware
+class TenancyMiddleware:
+    """ASGI middleware serving each request for the publication its header or host names"""
+
+    def __init__(self, app):
+        self.app = app
+
+    async def __call__(self, scope_, receive, send):
+        if scope_['type'] not in ('http', 'websocket'):
+            await self.app(scope_, receive, send)
+            return
+
+        headers = dict(scope_.get('headers') or [])
+        header = headers.get(HEADER.lower().encode(), b'').decode('latin-1').strip()
+        host = headers.get(b'host', b'').decode('latin-1')
+        try:
+            tenant = resolve(header, host)
+        except UnknownTenant as e:
+            await _respond(send, scope_, 404, str(e))
+            return
+        if tenant and not request_allowed(tenant):
+            await _respond(send, scope_, 429, "This publication's request quota is used up; try again next minute")
+            return
+
+        context_token = _current.set(tenant)
+        confined_token = _confined.set(True)
+        try:
+            await self.app(scope_, receive, send)
+        finally:
+            _confined.reset(confined_token)
+            _current.reset(context_token)
+
+
+async def _respond(send, scope_, status_code: int, message: str):
+    if scope_['type'] == 'websocket':
+        await send({'type': 'websocket.close', 'code': 4404 if status_code == 404 else 4429})
+        return
+    body = json.dumps({'success': False, 'message': message, 'error_code': 'TENANT'}).encode()
+    headers: List[Tuple[bytes, bytes]] = [
+        (b'content-type', b'application/json'), (b'content-length', str(len(body)).encode()),
+    ]
+    if status_code == 429:
+        headers.append((b'retry-after', str(60 - int(time.time()) % 60).encode()))
+    await send({'type': 'http.response.start', 'status': status_code, 'headers': headers})
+    await send({'type': 'http.response.body', 'body': body})
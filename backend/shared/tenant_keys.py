@@ -0,0 +1,117 @@
+"""
+Per-publication encryption of sensitive fields
+
+Sensitive values kept in PostgreSQL, such as webhook signing secrets and
+ActivityPub actors' private keys, are encrypted with AES-256-GCM under a
+data-encryption key of the publication (shared/tenancy.py) they belong to.
+Keys are derived with HKDF from TENANT_ENCRYPTION_KEY and the publication's
+id, the default publication having its own, so none are stored. The publication's id is also bound to
+each value as associated data: a value copied into another publication's
+rows can't be decrypted there.
+
+Encrypted values read `enc:<key id>:<base64 nonce and ciphertext>`, the key
+id naming the master key. To rotate TENANT_ENCRYPTION_KEY, move the old key
+to TENANT_ENCRYPTION_PREVIOUS_KEYS; values written under it stay readable and
+are written under the new key the next time they change. Values stored
+before they were encrypted are read as they are.
+
+The master key is its own setting, so rotating JWT_SECRET_KEY leaves stored
+values readable. Without TENANT_ENCRYPTION_KEY nothing is encrypted or
+decrypted (MissingMasterKey), unless ENVIRONMENT is `development`, where a
+fixed development key stands in.
+"""
+
+import os
+import base64
+import hashlib
+import logging
+from functools import lru_cache
+from typing import Dict, Optional, Tuple
+
+from cryptography.exceptions import InvalidTag
+from cryptography.hazmat.primitives import hashes
+from cryptography.hazmat.primitives.ciphers.aead import AESGCM
+from cryptography.hazmat.primitives.kdf.hkdf import HKDF
+
+logger = logging.getLogger(__name__)
+
+PREFIX = 'enc:'
+NONCE_BYTES = 12
+
+
+DEVELOPMENT_KEY = 'dev-secret-key'
+
+
+class DecryptionError(Exception):
+    """A value wasn't encrypted for this publication, or its master key is gone"""
+
+
+class MissingMasterKey(DecryptionError):
+    """No master key is configured outside development"""
+
+
+def _master_keys() -> Dict[str, bytes]:
+    """Master keys by key id, the current one first"""
+    current = os.getenv('TENANT_ENCRYPTION_KEY')
+    if not current:
+        if os.getenv('ENVIRONMENT') != 'development':
+            raise MissingMasterKey("Set TENANT_ENCRYPTION_KEY to encrypt publication data")
+        current = DEVELOPMENT_KEY
+    previous = [key.strip() for key in os.getenv('TENANT_ENCRYPTION_PREVIOUS_KEYS', '').split(',') if key.strip()]
+    keys = {}
+    for secret in [current] + previous:
+        keys.setdefault(hashlib.sha256(secret.encode()).hexdigest()[:8], secret.encode())
+    return keys
+
+
+def _context(tenant_id: Optional[str]) -> bytes:
+    return f"tenant:{tenant_id or 'default'}".encode()
+
+
+@lru_cache(maxsize=1024)
+def _derive(master: bytes, tenant_id: Optional[str]) -> bytes:
+    return HKDF(
+        algorithm=hashes.SHA256(), length=32, salt=b'news-app-tenant-keys', info=_context(tenant_id)
+    ).derive(master)
+
+
+def data_key(tenant_id: Optional[str], key_id: Optional[str] = None) -> Tuple[str, bytes]:
+    """A publication's data-encryption key under master key `key_id` (the current one by default)"""
+    keys = _master_keys()
+    key_id = key_id or next(iter(keys))
+    if key_id not in keys:
+        raise DecryptionError(f"Master key {key_id} isn't configured")
+    return key_id, _derive(keys[key_id], str(tenant_id) if tenant_id else None)
+
+
+def is_encrypted(value: Optional[str]) -> bool:
+    return bool(value) and value.startswith(PREFIX)
+
+
+def encrypt(value: str, tenant_id: Optional[str]) -> str:
+    """Encrypt `value` for a publication (None for the default one)"""
+    key_id, key = data_key(tenant_id)
+    nonce = os.urandom(NONCE_BYTES)
+    sealed = AESGCM(key).encrypt(nonce, value.encode(), _context(tenant_id))
+    return f"{PREFIX}{key_id}:{base64.urlsafe_b64encode(nonce + sealed).decode()}"
+
+
+def decrypt(value: Optional[str], tenant_id: Optional[str]) -> Optional[str]:
+    """The plaintext of a value encrypted for a publication; values stored unencrypted come back unchanged"""
+    if not is_encrypted(value):
+        return value
+    try:
+        key_id, payload = value[len(PREFIX):].split(':', 1)
+        raw = base64.urlsafe_b64decode(payload.encode())
+    except ValueError:
+        raise DecryptionError("Malformed encrypted value")
+    _, key = data_key(tenant_id, key_id)
+    try:
+        return AESGCM(key).decrypt(raw[:NONCE_BYTES], raw[NONCE_BYTES:], _context(tenant_id)).decode()
+    except InvalidTag:
+        raise DecryptionError("Value wasn't encrypted for this publication")
+
+
+def needs_rotation(value: Optional[str]) -> bool:
+    """Whether a stored value should be written again under the current master key"""
+    return not is_encrypted(value) or value[len(PREFIX):].split(':', 1)[0] != next(iter(_master_keys()))
@@ -0,0 +1,446 @@
+"""
+Tips and author payouts
+
+Readers tip an author, optionally for a particular article, in one of two ways:
+
+- by card through Stripe: the tip starts `pending` with a PaymentIntent the
+  client confirms, and Stripe's webhook settles it (or fails or refunds it).
+  Settled card tips go to the author's available balance less
+  TIPS_FEE_BASIS_POINTS, and authors request payouts from that balance.
+- on-chain: the reader sends the chain's native currency from any of their
+  linked wallets straight to one of the author's (the primary is the one
+  to show) and submits the transaction hash. These settle as soon as
+  they are verified and never pass through a balance, since the author
+  already has the money.
+
+Every settlement, refund and payout is posted to the ledger. A reconciliation
+job asks Stripe about tips still pending after TIPS_RECONCILE_AFTER_MINUTES,
+in case a webhook never arrived.
+"""
+
+import os
+import hmac
+import json
+import time
+import hashlib
+import logging
+from datetime import datetime, timedelta, timezone
+from decimal import Decimal
+from typing import Any, Dict, List, Optional
+
+import requests
+
+from shared import ledger
+from shared.paywall import CHAIN_ID, PaymentInvalid, verify_transfer
+from shared.wallets import addresses
+
+logger = logging.getLogger(__name__)
+
+STRIPE_API_URL = 'https://api.stripe.com/v1'
+STRIPE_SECRET_KEY = os.getenv('STRIPE_SECRET_KEY', '')
+STRIPE_WEBHOOK_SECRET = os.getenv('STRIPE_WEBHOOK_SECRET', '')
+STRIPE_SIGNATURE_TOLERANCE_SECONDS = 300
+
+FIAT_CURRENCIES = [currency.strip().lower() for currency in os.getenv('TIPS_CURRENCIES', 'usd').split(',') if currency.strip()]
+ONCHAIN_CURRENCY = os.getenv('TIPS_ONCHAIN_CURRENCY', 'eth').lower()
+FEE_BASIS_POINTS = int(os.getenv('TIPS_FEE_BASIS_POINTS', 250))
+MIN_TIP_MINOR = int(os.getenv('TIPS_MIN_AMOUNT_MINOR', 100))
+MIN_PAYOUT_MINOR = int(os.getenv('TIPS_MIN_PAYOUT_MINOR', 1000))
+RECONCILE_AFTER_MINUTES = int(os.getenv('TIPS_RECONCILE_AFTER_MINUTES', 15))
+PENDING_EXPIRY_HOURS = int(os.getenv('TIPS_PENDING_EXPIRY_HOURS', 24))
+
+# Stripe's zero-decimal currencies aside, card currencies have cents
+ZERO_DECIMAL_CURRENCIES = {'jpy', 'krw', 'vnd', 'clp', 'isk', 'ugx', 'xaf', 'xof'}
+
+
+class TipInvalid(Exception):
+    """Raised when a tip or payout can't be made as asked"""
+
+
+class WebhookInvalid(Exception):
+    """Raised when a Stripe webhook's signature doesn't check out"""
+
+
+# Amounts
+def decimals(currency: str) -> int:
+    currency = currency.lower()
+    if currency == ONCHAIN_CURRENCY:
+        return 18
+    return 0 if currency in ZERO_DECIMAL_CURRENCIES else 2
+
+
+def to_minor(amount: Decimal, currency: str) -> int:
+    minor = amount * (Decimal(10) ** decimals(currency))
+    if minor != minor.to_integral_value():
+        raise TipInvalid(f"Too many decimal places for {currency.upper()}")
+    return int(minor)
+
+
+def to_major(amount_minor: int, currency: str) -> str:
+    return str(Decimal(int(amount_minor)).scaleb(-decimals(currency)))
+
+
+def fee_for(amount_minor: int) -> int:
+    return amount_minor * FEE_BASIS_POINTS // 10000
+
+
+def serialize(row: Dict[str, Any]) -> Dict[str, Any]:
+    """A tip or payout for a response, with amounts in both minor units and as decimals"""
+    serialized = dict(row)
+    for field in ('amount', 'fee'):
+        if field in serialized and serialized[field] is not None:
+            serialized[f"{field}_minor"] = str(serialized[field])
+            serialized[field] = to_major(serialized[field], serialized['currency'])
+    return serialized
+
+
+# Stripe
+def _stripe(method: str, path: str, data: Optional[Dict[str, Any]] = None,
+            idempotency_key: Optional[str] = None) -> Dict[str, Any]:
+    if not STRIPE_SECRET_KEY:
+        raise TipInvalid("Card tips are not configured")
+    headers = {'Idempotency-Key': idempotency_key} if idempotency_key else {}
+    response = requests.request(
+        method, f"{STRIPE_API_URL}/{path}", auth=(STRIPE_SECRET_KEY, ''), data=data, headers=headers, timeout=20
+    )
+    response.raise_for_status()
+    return response.json()
+
+
+def verify_stripe_event(payload: bytes, signature_header: Optional[str]) -> Dict[str, Any]:
+    """The webhook event, once its Stripe-Signature header proves Stripe sent it recently"""
+    if not STRIPE_WEBHOOK_SECRET:
+        raise WebhookInvalid("Stripe webhooks are not configured")
+    parts: Dict[str, List[str]] = {}
+    for item in (signature_header or '').split(','):
+        key, _, value = item.partition('=')
+        parts.setdefault(key.strip(), []).append(value.strip())
+    try:
+        timestamp = int(parts['t'][0])
+    except (KeyError, ValueError):
+        raise WebhookInvalid("Missing signature timestamp")
+    if abs(time.time() - timestamp) > STRIPE_SIGNATURE_TOLERANCE_SECONDS:
+        raise WebhookInvalid("Signature timestamp is too old")
+
+    expected = hmac.new(
+        STRIPE_WEBHOOK_SECRET.encode(), f"{timestamp}.".encode() + payload, hashlib.sha256
+    ).hexdigest()
+    if not any(hmac.compare_digest(expected, signature) for signature in parts.get('v1', [])):
+        raise WebhookInvalid("Signature mismatch")
+    return json.loads(payload)
+
+
+# Tips
+def _author(cursor, author_id: str) -> Dict[str, Any]:
+    cursor.execute("SELECT id, username, did_address FROM users WHERE id = %s AND is_active = TRUE", (author_id,))
+    author = cursor.fetchone()
+    if not author:
+        raise TipInvalid("Author not found")
+    return dict(author)
+
+
+def recipient(cursor, author_id: Optional[str], article_id: Optional[str]) -> Dict[str, Any]:
+    """Who a tip goes to: the article's author when an article is given"""
+    if article_id:
+        cursor.execute("SELECT author_id FROM articles WHERE id = %s AND status = 'published'", (article_id,))
+        article = cursor.fetchone()
+        if not article:
+            raise TipInvalid("Article not found")
+        if author_id and str(article['author_id']) != str(author_id):
+            raise TipInvalid("The article is not by this author")
+        author_id = str(article['author_id'])
+    if not author_id:
+        raise TipInvalid("Give an author or an article to tip")
+    return _author(cursor, author_id)
+
+
+def create_card_tip(cursor, author: Dict[str, Any], tipper: Dict[str, Any], article_id: Optional[str],
+                    amount: Decimal, currency: str, message: Optional[str], anonymous: bool) -> Dict[str, Any]:
+    """Record a pending card tip and open the Stripe PaymentIntent the client confirms"""
+    currency = currency.lower()
+    if currency not in FIAT_CURRENCIES:
+        raise TipInvalid(f"Card tips are accepted in {', '.join(c.upper() for c in FIAT_CURRENCIES)}")
+    amount_minor = to_minor(amount, currency)
+    if amount_minor < MIN_TIP_MINOR:
+        raise TipInvalid(f"The smallest tip is {to_major(MIN_TIP_MINOR, currency)} {currency.upper()}")
+
+    cursor.execute("""
+        INSERT INTO tips (author_id, tipper_id, article_id, rail, currency, amount, fee, message, anonymous)
+        VALUES (%s, %s, %s, 'stripe', %s, %s, %s, %s, %s)
+        RETURNING *
+    """, (author['id'], tipper['id'], article_id, currency, amount_minor, fee_for(amount_minor), message, anonymous))
+    tip = dict(cursor.fetchone())
+
+    # Inside the transaction, so a failed Stripe call leaves no tip behind
+    intent = _stripe('POST', 'payment_intents', {
+        'amount': amount_minor,
+        'currency': currency,
+        'automatic_payment_methods[enabled]': 'true',
+        'description': f"Tip for {author['username']}",
+        'metadata[tip_id]': str(tip['id']),
+    }, idempotency_key=f"tip-{tip['id']}")
+    cursor.execute(
+        "UPDATE tips SET stripe_payment_intent_id = %s WHERE id = %s RETURNING *", (intent['id'], tip['id'])
+    )
+    return {**dict(cursor.fetchone()), 'client_secret': intent['client_secret']}
+
+
+def record_onchain_tip(cursor, author: Dict[str, Any], tipper: Dict[str, Any], article_id: Optional[str],
+                       transaction_hash: str, message: Optional[str], anonymous: bool) -> Dict[str, Any]:
+    """Verify a transfer from the tipper's wallet to the author's and record it as a settled tip"""
+    # Tips may come from any of the tipper's linked wallets and go to any of the author's
+    payers = addresses(cursor, str(tipper['id'])) or ([tipper['did_address']] if tipper.get('did_address') else [])
+    if not payers:
+        raise TipInvalid("Add a wallet address to your account first")
+    payees = addresses(cursor, str(author['id'])) or ([author['did_address']] if author.get('did_address') else [])
+    if not payees:
+        raise TipInvalid("The author has no wallet address to tip")
+    try:
+        transaction = verify_transfer(transaction_hash, payers, payees, 1)
+    except PaymentInvalid as e:
+        raise TipInvalid(str(e))
+
+    cursor.execute("""
+        INSERT INTO tips (
+            author_id, tipper_id, article_id, rail, currency, amount, message, anonymous,
+            chain_id, transaction_hash, payer_address, payee_address
+        ) VALUES (%s, %s, %s, 'onchain', %s, %s, %s, %s, %s, %s, %s, %s)
+        ON CONFLICT (transaction_hash) DO NOTHING
+        RETURNING *
+    """, (author['id'], tipper['id'], article_id, ONCHAIN_CURRENCY, transaction['value'], message, anonymous,
+          CHAIN_ID, transaction_hash.lower(), transaction['from'], transaction['to']))
+    tip = cursor.fetchone()
+    if not tip:
+        raise TipInvalid("Transaction was already used for a tip")
+    settle_tip(cursor, dict(tip))
+    return get_tip(cursor, str(tip['id']))
+
+
+def get_tip(cursor, tip_id: str) -> Optional[Dict[str, Any]]:
+    cursor.execute("SELECT * FROM tips WHERE id = %s", (tip_id,))
+    tip = cursor.fetchone()
+    return dict(tip) if tip else None
+
+
+def settle_tip(cursor, tip: Dict[str, Any]) -> bool:
+    """Mark a pending tip as paid and post it to the ledger; False if it was already settled"""
+    cursor.execute("""
+        UPDATE tips SET status = 'succeeded', settled_at = NOW()
+        WHERE id = %s AND status = 'pending'
+        RETURNING *
+    """, (tip['id'],))
+    settled = cursor.fetchone()
+    if not settled:
+        return False
+
+    author_id = str(settled['author_id']) if settled['author_id'] else None
+    currency, amount, fee = settled['currency'], int(settled['amount']), int(settled['fee'])
+    if settled['rail'] == 'onchain':
+        entries = [
+            (ledger.account(cursor, ledger.ONCHAIN_CLEARING, currency), -amount),
+            (ledger.account(cursor, ledger.AUTHOR_RECEIVED_DIRECT, currency, author_id), amount),
+        ]
+    else:
+        entries = [
+            (ledger.account(cursor, ledger.STRIPE_CLEARING, currency), -amount),
+            (ledger.account(cursor, ledger.AUTHOR_AVAILABLE, currency, author_id), amount - fee),
+            (ledger.account(cursor, ledger.PLATFORM_FEES, currency), fee),
+        ]
+    ledger.post(cursor, 'tip', str(settled['id']), entries, f"{settled['rail']} tip")
+    logger.info(f"Tip {settled['id']} of {amount} {currency} to author {author_id} settled")
+    return True
+
+
+def fail_tip(cursor, tip_id: str) -> bool:
+    cursor.execute("UPDATE tips SET status = 'failed' WHERE id = %s AND status = 'pending'", (tip_id,))
+    return cursor.rowcount > 0
+
+
+def refund_tip(cursor, tip_id: str) -> bool:
+    """Reverse a settled tip; the author's balance can go negative if it was already paid out"""
+    cursor.execute("""
+        UPDATE tips SET status = 'refunded'
+        WHERE id = %s AND status = 'succeeded'
+        RETURNING id
+    """, (tip_id,))
+    if not cursor.fetchone():
+        return False
+    ledger.reverse(cursor, 'tip_refund', 'tip', tip_id, "Card tip refunded")
+    logger.info(f"Tip {tip_id} refunded")
+    return True
+
+
+def _tip_for_intent(cursor, payment_intent_id: Optional[str]) -> Optional[Dict[str, Any]]:
+    if not payment_intent_id:
+        return None
+    cursor.execute("SELECT * FROM tips WHERE stripe_payment_intent_id = %s FOR UPDATE", (payment_intent_id,))
+    tip = cursor.fetchone()
+    return dict(tip) if tip else None
+
+
+def handle_stripe_event(cursor, event: Dict[str, Any]) -> str:
+    """Apply a Stripe webhook event to its tip and say what happened; replays are harmless"""
+    event_type = event.get('type')
+    obj = event.get('data', {}).get('object', {})
+
+    if event_type in ('payment_intent.succeeded', 'payment_intent.payment_failed', 'payment_intent.canceled'):
+        tip = _tip_for_intent(cursor, obj.get('id'))
+        if not tip:
+            return 'ignored'
+        if event_type == 'payment_intent.succeeded':
+            if int(obj.get('amount_received', 0)) < int(tip['amount']):
+                logger.warning(f"PaymentIntent {obj.get('id')} received less than tip {tip['id']}")
+                return 'ignored'
+            return 'settled' if settle_tip(cursor, tip) else 'unchanged'
+        return 'failed' if fail_tip(cursor, str(tip['id'])) else 'unchanged'
+
+    if event_type == 'charge.refunded':
+        tip = _tip_for_intent(cursor, obj.get('payment_intent'))
+        if not tip:
+            return 'ignored'
+        if int(obj.get('amount_refunded', 0)) < int(obj.get('amount', 0)):
+            # Partial refunds are rare for tips; they are left for an administrator to handle
+            logger.warning(f"Partial refund on tip {tip['id']} not applied to the ledger")
+            return 'ignored'
+        return 'refunded' if refund_tip(cursor, str(tip['id'])) else 'unchanged'
+
+    return 'ignored'
+
+
+def reconcile_pending(cursor) -> Dict[str, int]:
+    """Settle or fail card tips whose webhook hasn't arrived, by asking Stripe"""
+    counts = {'settled': 0, 'failed': 0, 'pending': 0}
+    if not STRIPE_SECRET_KEY:
+        return counts
+    cursor.execute("""
+        SELECT * FROM tips
+        WHERE status = 'pending' AND rail = 'stripe' AND created_at < NOW() - make_interval(mins => %s)
+        ORDER BY created_at
+        LIMIT 200
+        FOR UPDATE SKIP LOCKED
+    """, (RECONCILE_AFTER_MINUTES,))
+    expiry = datetime.now(timezone.utc) - timedelta(hours=PENDING_EXPIRY_HOURS)
+    for tip in cursor.fetchall():
+        tip = dict(tip)
+        intent = _stripe('GET', f"payment_intents/{tip['stripe_payment_intent_id']}") \
+            if tip['stripe_payment_intent_id'] else None
+        if intent and intent['status'] == 'succeeded' and int(intent['amount_received']) >= int(tip['amount']):
+            settle_tip(cursor, tip)
+            counts['settled'] += 1
+        elif intent is None or intent['status'] == 'canceled' or tip['created_at'] < expiry:
+            fail_tip(cursor, str(tip['id']))
+            counts['failed'] += 1
+        else:
+            counts['pending'] += 1
+    if counts['settled'] or counts['failed']:
+        logger.info(f"Reconciled pending tips: {counts}")
+    return counts
+
+
+def list_tips(cursor, author_id: Optional[str] = None, tipper_id: Optional[str] = None,
+              limit: int = 50, offset: int = 0) -> List[Dict[str, Any]]:
+    """Tips received by an author, with anonymous tippers hidden, or sent by a tipper"""
+    column, user_id = ('author_id', author_id) if author_id else ('tipper_id', tipper_id)
+    cursor.execute(f"""
+        SELECT t.id, t.author_id, t.article_id, a.title AS article_title, t.rail, t.currency, t.amount, t.fee,
+               t.status, t.message, t.anonymous, t.transaction_hash, t.created_at, t.settled_at,
+               CASE WHEN t.anonymous AND %(hide)s THEN NULL ELSE t.tipper_id END AS tipper_id,
+               CASE WHEN t.anonymous AND %(hide)s THEN NULL ELSE u.username END AS tipper_username
+        FROM tips t
+        LEFT JOIN articles a ON a.id = t.article_id
+        LEFT JOIN users u ON u.id = t.tipper_id
+        WHERE t.{column} = %(user_id)s AND t.status != 'failed'
+        ORDER BY t.created_at DESC
+        LIMIT %(limit)s OFFSET %(offset)s
+    """, {'hide': bool(author_id), 'user_id': user_id, 'limit': limit, 'offset': offset})
+    return [serialize(dict(row)) for row in cursor.fetchall()]
+
+
+def author_balance(cursor, author_id: str) -> List[Dict[str, Any]]:
+    """Per currency: what can be paid out, what is waiting to be, what was, and what went straight to the wallet"""
+    names = {
+        ledger.AUTHOR_AVAILABLE: 'available',
+        ledger.AUTHOR_PAYOUTS_PENDING: 'payouts_pending',
+        ledger.PAYOUTS_SENT: 'paid_out',
+        ledger.AUTHOR_RECEIVED_DIRECT: 'received_direct',
+    }
+    return [
+        {'currency': currency, **{names[kind]: to_major(amount, currency) for kind, amount in balances.items()}}
+        for currency, balances in sorted(ledger.user_balances(cursor, author_id).items())
+    ]
+
+
+# Payouts
+def request_payout(cursor, author_id: str, amount: Decimal, currency: str, destination: str) -> Dict[str, Any]:
+    """Move part of an author's available balance into a payout for an administrator to send"""
+    currency = currency.lower()
+    if currency not in FIAT_CURRENCIES:
+        raise TipInvalid(f"Payouts are made in {', '.join(c.upper() for c in FIAT_CURRENCIES)}")
+    amount_minor = to_minor(amount, currency)
+    if amount_minor < MIN_PAYOUT_MINOR:
+        raise TipInvalid(f"The smallest payout is {to_major(MIN_PAYOUT_MINOR, currency)} {currency.upper()}")
+
+    available_account = ledger.account(cursor, ledger.AUTHOR_AVAILABLE, currency, author_id)
+    # Locked so two requests at once can't both spend the same balance
+    if ledger.balance(cursor, available_account, lock=True) < amount_minor:
+        raise TipInvalid("Not enough available balance")
+
+    cursor.execute("""
+        INSERT INTO payout_requests (author_id, currency, amount, destination)
+        VALUES (%s, %s, %s, %s)
+        RETURNING *
+    """, (author_id, currency, amount_minor, destination))
+    payout = dict(cursor.fetchone())
+    ledger.post(cursor, 'payout_request', str(payout['id']), [
+        (available_account, -amount_minor),
+        (ledger.account(cursor, ledger.AUTHOR_PAYOUTS_PENDING, currency, author_id), amount_minor),
+    ], "Payout requested")
+    return serialize(payout)
+
+
+def review_payout(cursor, payout_id: str, paid: bool, reviewer_id: str,
+                  external_ref: Optional[str] = None, note: Optional[str] = None) -> Optional[Dict[str, Any]]:
+    """Record a requested payout as sent, or reject it and return the money to the author's balance"""
+    cursor.execute("""
+        UPDATE payout_requests
+        SET status = %s, external_ref = %s, note = %s, reviewed_by = %s, reviewed_at = NOW()
+        WHERE id = %s AND status = 'requested'
+        RETURNING *
+    """, ('paid' if paid else 'rejected', external_ref, note, reviewer_id, payout_id))
+    payout = cursor.fetchone()
+    if not payout:
+        return None
+
+    author_id = str(payout['author_id']) if payout['author_id'] else None
+    currency, amount = payout['currency'], int(payout['amount'])
+    pending_account = ledger.account(cursor, ledger.AUTHOR_PAYOUTS_PENDING, currency, author_id)
+    if paid:
+        ledger.post(cursor, 'payout_paid', payout_id, [
+            (pending_account, -amount),
+            (ledger.account(cursor, ledger.PAYOUTS_SENT, currency, author_id), amount),
+        ], f"Payout sent: {external_ref or 'no reference'}")
+    else:
+        ledger.post(cursor, 'payout_rejected', payout_id, [
+            (pending_account, -amount),
+            (ledger.account(cursor, ledger.AUTHOR_AVAILABLE, currency, author_id), amount),
+        ], "Payout rejected")
+    return serialize(dict(payout))
+
+
+def list_payouts(cursor, author_id: Optional[str] = None, status: Optional[str] = None,
+                 limit: int = 50, offset: int = 0) -> List[Dict[str, Any]]:
+    query = """
+        SELECT p.*, u.username AS author_username
+        FROM payout_requests p
+        LEFT JOIN users u ON u.id = p.author_id
+        WHERE TRUE
+    """
+    params: List[Any] = []
+    if author_id:
+        query += " AND p.author_id = %s"
+        params.append(author_id)
+    if status:
+        query += " AND p.status = %s"
+        params.append(status)
+    cursor.execute(query + " ORDER BY p.created_at DESC LIMIT %s OFFSET %s", params + [limit, offset])
+    return [serialize(dict(row)) for row in cursor.fetchall()]
@@ -0,0 +1,173 @@
+"""
+Access token usage tracking and introspection for both Flask and FastAPI backends
+
+Every authenticated request is appended to a capped per-user log in Redis, and
+each token (by its `jti`) is tracked with the client it was last used from, so
+users can see where their account is signed in. Introspection follows RFC 7662
+so other services can validate tokens against this platform instead of
+sharing the signing secret.
+"""
+
+import os
+import hmac
+import json
+import logging
+from collections import Counter
+from datetime import datetime
+from typing import Any, Dict, List, Optional
+
+from shared.auth import auth_manager
+from shared.database import get_postgres_cursor, get_redis
+from shared.oauth import has_delegated_access
+
+logger = logging.getLogger(__name__)
+
+MAX_LOGGED_REQUESTS = 200
+USAGE_TTL_SECONDS = 30 * 24 * 60 * 60
+
+
+def _requests_key(user_id: str) -> str:
+    return f"api_usage:{user_id}:requests"
+
+
+def _tokens_key(user_id: str) -> str:
+    return f"api_usage:{user_id}:tokens"
+
+
+def record_request(claims: Dict[str, Any], method: str, path: str, status_code: int,
+                   ip_address: Optional[str], user_agent: Optional[str], duration_ms: float):
+    """Log one authenticated request; never fails the request if Redis is down"""
+    user_id = claims.get('user_id')
+    if not user_id:
+        return
+
+    now = datetime.now()
+    entry = {
+        'method': method,
+        'path': path,
+        'status': status_code,
+        'ip_address': ip_address,
+        'duration_ms': round(duration_ms, 2),
+        'token_id': claims.get('jti'),
+        'at': now.isoformat(),
+    }
+
+    try:
+        redis_client = get_redis()
+        tokens_key = _tokens_key(user_id)
+
+        token = None
+        if claims.get('jti'):
+            existing = redis_client.hget(tokens_key, claims['jti'])
+            token = json.loads(existing) if existing else {'first_seen': now.isoformat()}
+            token.update({
+                'last_seen': now.isoformat(),
+                'ip_address': ip_address,
+                'user_agent': (user_agent or '')[:300],
+                'session_id': claims.get('sid'),
+                'issued_at': claims.get('iat'),
+                'expires_at': claims.get('exp'),
+            })
+
+        pipe = redis_client.pipeline()
+        pipe.lpush(_requests_key(user_id), json.dumps(entry))
+        pipe.ltrim(_requests_key(user_id), 0, MAX_LOGGED_REQUESTS - 1)
+        pipe.expire(_requests_key(user_id), USAGE_TTL_SECONDS)
+        if token:
+            pipe.hset(tokens_key, claims['jti'], json.dumps(token))
+            pipe.expire(tokens_key, USAGE_TTL_SECONDS)
+        pipe.execute()
+    except Exception as e:
+        logger.warning(f"Could not record API usage: {e}")
+
+
+def get_usage(user_id: str, limit: int = 50) -> Dict[str, Any]:
+    """Recent requests, a summary of them and the tokens still in use"""
+    redis_client = get_redis()
+    logged = [json.loads(entry) for entry in redis_client.lrange(_requests_key(user_id), 0, MAX_LOGGED_REQUESTS - 1)]
+
+    now = datetime.now().timestamp()
+    sessions = []
+    for token_id, raw in redis_client.hgetall(_tokens_key(user_id)).items():
+        token = json.loads(raw)
+        if token.get('expires_at') and token['expires_at'] <= now:
+            redis_client.hdel(_tokens_key(user_id), token_id)
+            continue
+        sessions.append({'token_id': token_id, **token})
+    sessions.sort(key=lambda session: session['last_seen'], reverse=True)
+
+    return {
+        'requests': logged[:limit],
+        'summary': {
+            'logged_requests': len(logged),
+            'since': logged[-1]['at'] if logged else None,
+            'by_status': dict(Counter(str(entry['status']) for entry in logged)),
+            'top_paths': [
+                {'path': path, 'count': count}
+                for path, count in Counter(entry['path'] for entry in logged).most_common(10)
+            ],
+        },
+        'sessions': sessions,
+    }
+
+
+def get_issued_api_keys(cursor, user_id: str) -> List[Dict[str, Any]]:
+    """API keys the user has issued (syndication partner and service keys created by an administrator)"""
+    cursor.execute("""
+        SELECT id, name, api_key_prefix, is_active, last_used_at, created_at, 'syndication' AS type
+        FROM syndication_partners
+        WHERE created_by = %s
+        UNION ALL
+        SELECT id, name, key_prefix, is_active, last_used_at, created_at, 'service' AS type
+        FROM api_keys
+        WHERE created_by = %s
+        ORDER BY created_at DESC
+    """, (user_id, user_id))
+    return [dict(key) for key in cursor.fetchall()]
+
+
+def is_introspection_client(secret: Optional[str]) -> bool:
+    """Check the shared secret resource servers present to the introspection endpoint"""
+    expected = os.getenv('TOKEN_INTROSPECTION_SECRET')
+    return bool(expected and secret and hmac.compare_digest(secret, expected))
+
+
+def introspect_token(token: str) -> Dict[str, Any]:
+    """RFC 7662 introspection response for an access token
+
+    Inactive tokens only ever return {"active": false}, whatever the reason.
+    """
+    claims = auth_manager.verify_token(token) if token else None
+    if not claims or not claims.get('user_id'):
+        return {'active': False}
+
+    with get_postgres_cursor() as cursor:
+        cursor.execute(
+            "SELECT id, username, role FROM users WHERE id = %s AND is_active = true",
+            (claims['user_id'],)
+        )
+        user = cursor.fetchone()
+    if not user:
+        return {'active': False}
+
+    if auth_manager.is_delegated(claims):
+        with get_postgres_cursor() as cursor:
+            if not has_delegated_access(cursor, claims, claims['scope'].split()):
+                return {'active': False}
+        scope = claims['scope']
+    else:
+        scope = 'admin' if user['role'] == 'administrator' else 'user'
+
+    return {
+        'active': True,
+        'token_type': 'Bearer',
+        'sub': str(user['id']),
+        'username': user['username'],
+        'role': user['role'],
+        'scope': scope,
+        'client_id': claims.get('client_id'),
+        'iat': claims.get('iat'),
+        'exp': claims.get('exp'),
+        'jti': claims.get('jti'),
+        'iss': os.getenv('PUBLIC_BASE_URL', 'http://localhost:3000'),
+    }
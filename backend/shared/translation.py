@@ -0,0 +1,252 @@
+"""
+Machine translation of articles
+
+`POST /articles/{id}/translate?lang=xx` sends the article's title, summary
+and rendered content to the provider named by TRANSLATION_PROVIDER:
+
+- `libretranslate`: a LibreTranslate server at LIBRETRANSLATE_URL (with
+  LIBRETRANSLATE_API_KEY for the hosted service)
+- `deepl`: the DeepL API with DEEPL_API_KEY (free-tier keys, ending in
+  `:fx`, use the free endpoint)
+- `none`: translation is off
+
+The result is stored in `article_translations` as a variant of the article,
+with its provenance: the provider, the source language, who asked for it,
+when, and a hash of the source text it was made from, so a variant is
+flagged `outdated` once the original is edited. Content is translated as
+HTML and stored sanitized.
+
+Readers of an article get the variant in the first language they accept:
+the `lang` query parameter, then their `languages` preference, then the
+request's Accept-Language. The article's own language wins over any
+variant, and without a matching variant the original is served.
+"""
+
+import os
+import re
+import hashlib
+import logging
+from typing import Any, Dict, List, Optional, Protocol
+
+import requests
+
+from shared.content import HTML, prepare, render
+
+logger = logging.getLogger(__name__)
+
+PROVIDER = os.getenv('TRANSLATION_PROVIDER', 'none')
+REQUEST_TIMEOUT_SECONDS = int(os.getenv('TRANSLATION_TIMEOUT_SECONDS', 60))
+LANGUAGE_PATTERN = re.compile(r'^[a-z]{2,3}(-[A-Za-z]{2,4})?$')
+
+
+class TranslationUnavailable(Exception):
+    """Raised when no provider is configured or the provider fails"""
+
+
+# Interface
+class TranslationProvider(Protocol):
+    name: str
+
+    def translate(self, texts: List[str], source: Optional[str], target: str, html: bool = False) -> List[str]:
+        """Translate each text; `source` None lets the provider detect it"""
+        ...
+
+
+# Implementations
+class LibreTranslateProvider:
+    name = 'libretranslate'
+
+    def __init__(self):
+        self.url = os.getenv('LIBRETRANSLATE_URL', 'http://localhost:5001').rstrip('/')
+        self.api_key = os.getenv('LIBRETRANSLATE_API_KEY', '')
+
+    def translate(self, texts: List[str], source: Optional[str], target: str, html: bool = False) -> List[str]:
+        payload = {
+            'q': texts,
+            'source': (source or 'auto').split('-')[0],
+            'target': target.split('-')[0],
+            'format': 'html' if html else 'text',
+        }
+        if self.api_key:
+            payload['api_key'] = self.api_key
+        response = requests.post(f"{self.url}/translate", json=payload, timeout=REQUEST_TIMEOUT_SECONDS)
+        response.raise_for_status()
+        translated = response.json()['translatedText']
+        return translated if isinstance(translated, list) else [translated]
+
+
+class DeepLProvider:
+    name = 'deepl'
+
+    def __init__(self):
+        self.api_key = os.getenv('DEEPL_API_KEY', '')
+        host = 'api-free.deepl.com' if self.api_key.endswith(':fx') else 'api.deepl.com'
+        self.url = os.getenv('DEEPL_API_URL', f"https://{host}/v2/translate")
+
+    def translate(self, texts: List[str], source: Optional[str], target: str, html: bool = False) -> List[str]:
+        if not self.api_key:
+            raise TranslationUnavailable("DEEPL_API_KEY must be set")
+        payload: Dict[str, Any] = {'text': texts, 'target_lang': target.upper()}
+        if source:
+            # DeepL takes regional variants as targets only
+            payload['source_lang'] = source.split('-')[0].upper()
+        if html:
+            payload['tag_handling'] = 'html'
+        response = requests.post(
+            self.url, json=payload, timeout=REQUEST_TIMEOUT_SECONDS,
+            headers={'Authorization': f"DeepL-Auth-Key {self.api_key}"},
+        )
+        response.raise_for_status()
+        return [item['text'] for item in response.json()['translations']]
+
+
+PROVIDERS = {
+    'libretranslate': LibreTranslateProvider,
+    'deepl': DeepLProvider,
+}
+
+_provider: Optional[TranslationProvider] = None
+
+
+def provider() -> TranslationProvider:
+    global _provider
+    if _provider is None:
+        if PROVIDER not in PROVIDERS:
+            raise TranslationUnavailable("Machine translation is not configured")
+        _provider = PROVIDERS[PROVIDER]()
+    return _provider
+
+
+def normalize_language(language: Optional[str]) -> Optional[str]:
+    """`pt-br` as `pt-BR`; None for anything that isn't a language tag"""
+    language = (language or '').strip()
+    if not LANGUAGE_PATTERN.match(language):
+        return None
+    base, _, region = language.partition('-')
+    return f"{base.lower()}-{region.upper()}" if region else base.lower()
+
+
+def source_hash(article: Dict[str, Any]) -> str:
+    """Hash of the text a translation is made from, to tell when the original changed"""
+    text = '\x1f'.join([article.get('title') or '', article.get('summary') or '', article.get('content') or ''])
+    return hashlib.sha256(text.encode()).hexdigest()
+
+
+def translate_article(article: Dict[str, Any], language: str) -> Dict[str, Any]:
+    """The article's title, summary and content translated by the configured provider"""
+    translator = provider()
+    source = article.get('language')
+    content_html = render(article['content'], article.get('content_format') or HTML)
+    try:
+        title, summary = translator.translate([article['title'], article.get('summary') or ''], source, language)
+        content, = translator.translate([content_html], source, language, html=True)
+    except requests.RequestException as e:
+        raise TranslationUnavailable(f"The {translator.name} translation provider failed: {e}")
+    except (KeyError, ValueError) as e:
+        raise TranslationUnavailable(f"Unexpected response from the {translator.name} translation provider: {e}")
+    return {'title': title, 'summary': summary, 'content': content, 'provider': translator.name}
+
+
+def store_translation(cursor, article: Dict[str, Any], language: str, translated: Dict[str, Any],
+                      requested_by: str) -> Dict[str, Any]:
+    """Save a translation as the article's `language` variant, replacing any earlier one"""
+    prepared = prepare(translated['content'], HTML, strict=False)
+    cursor.execute("""
+        INSERT INTO article_translations (
+            article_id, language, title, summary, content, word_count, reading_time,
+            provider, source_language, source_hash, requested_by
+        ) VALUES (%s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s)
+        ON CONFLICT (article_id, language) DO UPDATE SET
+            title = EXCLUDED.title, summary = EXCLUDED.summary, content = EXCLUDED.content,
+            word_count = EXCLUDED.word_count, reading_time = EXCLUDED.reading_time,
+            provider = EXCLUDED.provider, source_language = EXCLUDED.source_language,
+            source_hash = EXCLUDED.source_hash, requested_by = EXCLUDED.requested_by, translated_at = NOW()
+        RETURNING *
+    """, (
+        article['id'], language, translated['title'][:500], translated['summary'][:1000] or None,
+        prepared['content'], prepared['word_count'], prepared['reading_time'], translated['provider'],
+        article.get('language'), source_hash(article), requested_by,
+    ))
+    translation = dict(cursor.fetchone())
+    cursor.execute("""
+        UPDATE articles SET translations = (
+            SELECT ARRAY_AGG(language ORDER BY language) FROM article_translations WHERE article_id = %s
+        ) WHERE id = %s
+    """, (article['id'], article['id']))
+    return translation
+
+
+def get_translation(cursor, article_id: str, language: str) -> Optional[Dict[str, Any]]:
+    cursor.execute(
+        "SELECT * FROM article_translations WHERE article_id = %s AND language = %s", (article_id, language)
+    )
+    row = cursor.fetchone()
+    return dict(row) if row else None
+
+
+def parse_accept_language(header: Optional[str]) -> List[str]:
+    """Languages in an Accept-Language header, most preferred first"""
+    weighted = []
+    for position, part in enumerate((header or '').split(',')):
+        tag, _, params = part.strip().partition(';')
+        language = normalize_language(tag)
+        if not language:
+            continue
+        quality = 1.0
+        match = re.search(r'q=([0-9.]+)', params)
+        if match:
+            try:
+                quality = float(match.group(1))
+            except ValueError:
+                continue
+        if quality > 0:
+            weighted.append((-quality, position, language))
+    return [language for _, _, language in sorted(weighted)]
+
+
+def preferred_languages(cursor, user_id: Optional[str], requested: Optional[str],
+                        accept_language: Optional[str]) -> List[str]:
+    """What the reader wants to read in: `lang`, then their preferences, then Accept-Language"""
+    languages = [normalize_language(requested)] if requested else []
+    if user_id:
+        cursor.execute("SELECT languages FROM user_preferences WHERE user_id = %s", (user_id,))
+        preferences = cursor.fetchone()
+        languages += [normalize_language(language) for language in (preferences or {}).get('languages') or []]
+    languages += parse_accept_language(accept_language)
+    return [language for language in dict.fromkeys(languages) if language]
+
+
+def choose_language(article: Dict[str, Any], wanted: List[str]) -> Optional[str]:
+    """The variant to serve: the first wanted language the article has a translation in, unless the original comes first"""
+    original = normalize_language(article.get('language'))
+    available = set(article.get('translations') or [])
+    for language in wanted:
+        for candidate in (language, language.split('-')[0]):
+            if candidate == original or (original and candidate.split('-')[0] == original.split('-')[0]):
+                return None
+            if candidate in available:
+                return candidate
+    return None
+
+
+def apply_translation(article: Dict[str, Any], translation: Dict[str, Any]) -> Dict[str, Any]:
+    """The rendered article with the variant's text in place of the original's"""
+    return {
+        **article,
+        'title': translation['title'],
+        'summary': translation['summary'],
+        'content': translation['content'],
+        'content_format': HTML,
+        'content_html': render(translation['content'], HTML),
+        'word_count': translation['word_count'],
+        'reading_time': translation['reading_time'],
+        'language': translation['language'],
+        'translation': {
+            'language': translation['language'],
+            'original_language': article.get('language'),
+            'machine_translated': True,
+            'provider': translation['provider'],
+            'translated_at': translation['translated_at'].isoformat(),
+            'outdated': translation['source_hash'] != source_hash(article),
+        },
+    }
@@ -0,0 +1,131 @@
+"""
+Tag and category trends
+
+A worker rolls publication volume and engagement up into one row per day for
+every tag and category. Articles count on the day they were published;
+views, likes, shares, saves, reading time and comments count on the day they
+happened, under the tags and category the article has when the rollup runs.
+Tags and categories are compared case-insensitively. Trend charts read only
+the rollups, bucketed by day, week or month.
+"""
+
+import os
+import logging
+from datetime import date, datetime, timedelta, timezone
+from typing import Any, Dict, List, Optional
+
+logger = logging.getLogger(__name__)
+
+# Each run recomputes this many days back, so late interactions are counted
+LOOKBACK_DAYS = int(os.getenv('TRENDS_ROLLUP_LOOKBACK_DAYS', 2))
+MAX_POINTS = int(os.getenv('TRENDS_MAX_POINTS', 400))
+
+DIMENSIONS = ('tag', 'category')
+GRANULARITIES = {'day': timedelta(days=30), 'week': timedelta(weeks=26), 'month': timedelta(days=365)}
+METRICS = ('articles_published', 'views', 'likes', 'shares', 'saves', 'comments', 'reading_seconds')
+
+# Each article's category and tags as (dimension, value) rows
+_ARTICLE_DIMENSIONS = """
+    CROSS JOIN LATERAL (
+        SELECT 'category', LOWER(a.category)
+        UNION
+        SELECT 'tag', LOWER(tag) FROM unnest(a.tags) tag
+    ) d(dimension, value)
+"""
+
+
+def roll_up(cursor, start: date, end: date) -> int:
+    """Recompute the rollups for the days from `start` up to, not including, `end`"""
+    cursor.execute("DELETE FROM trend_rollups WHERE bucket >= %s AND bucket < %s", (start, end))
+    cursor.execute(f"""
+        INSERT INTO trend_rollups (
+            bucket, dimension, value, articles_published, views, likes, shares, saves, comments, reading_seconds
+        )
+        SELECT bucket, dimension, LEFT(value, 100),
+               SUM(published), SUM(views), SUM(likes), SUM(shares), SUM(saves), SUM(comments), SUM(reading_seconds)
+        FROM (
+            SELECT (a.published_at AT TIME ZONE 'UTC')::date AS bucket, d.dimension, d.value,
+                   1 AS published, 0 AS views, 0 AS likes, 0 AS shares, 0 AS saves, 0 AS comments,
+                   0 AS reading_seconds
+            FROM articles a {_ARTICLE_DIMENSIONS}
+            WHERE a.status = 'published' AND a.published_at >= %(start)s AND a.published_at < %(end)s
+
+            UNION ALL
+
+            SELECT (i.created_at AT TIME ZONE 'UTC')::date, d.dimension, d.value, 0,
+                   (i.interaction_type = 'view')::int, (i.interaction_type = 'like')::int,
+                   (i.interaction_type = 'share')::int, (i.interaction_type = 'save')::int, 0,
+                   COALESCE(i.time_spent, 0)
+            FROM user_interactions i
+            JOIN articles a ON a.id = i.article_id {_ARTICLE_DIMENSIONS}
+            WHERE a.status = 'published' AND i.created_at >= %(start)s AND i.created_at < %(end)s
+
+            UNION ALL
+
+            SELECT (c.created_at AT TIME ZONE 'UTC')::date, d.dimension, d.value, 0, 0, 0, 0, 0, 1, 0
+            FROM comments c
+            JOIN articles a ON a.id = c.article_id {_ARTICLE_DIMENSIONS}
+            WHERE a.status = 'published' AND NOT c.is_deleted
+              AND c.created_at >= %(start)s AND c.created_at < %(end)s
+        ) events
+        WHERE value IS NOT NULL AND value != ''
+        GROUP BY bucket, dimension, LEFT(value, 100)
+    """, {'start': start, 'end': end})
+    return cursor.rowcount
+
+
+def roll_up_recent(cursor, days: Optional[int] = None) -> int:
+    today = datetime.now(timezone.utc).date()
+    start = today - timedelta(days=(days or LOOKBACK_DAYS) - 1)
+    rows = roll_up(cursor, start, today + timedelta(days=1))
+    logger.info(f"Rolled up trends from {start.isoformat()}: {rows} rows")
+    return rows
+
+
+def default_start(granularity: str, end: date) -> date:
+    return end - GRANULARITIES[granularity]
+
+
+def point_count(granularity: str, start: date, end: date) -> int:
+    days = (end - start).days + 1
+    if granularity == 'day':
+        return days
+    if granularity == 'week':
+        return days // 7 + 1
+    return (end.year - start.year) * 12 + end.month - start.month + 1
+
+
+def series(cursor, dimension: str, values: List[str], granularity: str, start: date, end: date) -> List[Dict[str, Any]]:
+    """Each value's metrics per bucket from `start` to `end` inclusive, with empty buckets as zeros"""
+    values = sorted({value.strip().lower() for value in values if value.strip()})
+    cursor.execute(f"""
+        SELECT v.value, s.bucket::date AS bucket,
+               {', '.join(f'COALESCE(SUM(r.{metric}), 0) AS {metric}' for metric in METRICS)}
+        FROM unnest(%(values)s::text[]) v(value)
+        CROSS JOIN generate_series(
+            date_trunc(%(granularity)s, %(start)s::timestamp),
+            %(end)s::timestamp,
+            ('1 ' || %(granularity)s)::interval
+        ) s(bucket)
+        LEFT JOIN trend_rollups r
+            ON r.dimension = %(dimension)s AND r.value = v.value
+           AND r.bucket >= %(start)s AND r.bucket <= %(end)s
+           AND date_trunc(%(granularity)s, r.bucket::timestamp) = s.bucket
+        GROUP BY v.value, s.bucket
+        ORDER BY v.value, s.bucket
+    """, {'values': values, 'granularity': granularity, 'start': start, 'end': end, 'dimension': dimension})
+
+    points: Dict[str, List[Dict[str, Any]]] = {value: [] for value in values}
+    for row in cursor.fetchall():
+        point = {'bucket': row['bucket'].isoformat(), **{metric: int(row[metric]) for metric in METRICS}}
+        engaged = point['likes'] + point['shares'] + point['saves'] + point['comments']
+        point['engagements'] = engaged
+        point['engagement_rate'] = round(engaged / point['views'], 4) if point['views'] else 0.0
+        points[row['value']].append(point)
+
+    return [{'dimension': dimension, 'value': value, 'points': points[value]} for value in values]
+
+
+def rolled_up_at(cursor) -> Optional[datetime]:
+    cursor.execute("SELECT MAX(updated_at) AS at FROM trend_rollups")
+    return cursor.fetchone()['at']
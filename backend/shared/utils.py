@@ -4,6 +4,7 @@ Shared utility functions for both Flask and FastAPI backends
 
 import re
 import uuid
+import base64
 import hashlib
 from datetime import datetime
 from typing import List, Dict, Any, Optional
@@ -52,8 +53,14 @@ def extract_keywords(content: str, max_keywords: int = 10) -> List[str]:
 
 
 def calculate_engagement_score(views: int, likes: int, shares: int, comments: int, 
-                             reading_time: int, time_spent_avg: float) -> float:
-    """Calculate engagement score based on various metrics"""
+                             reading_time: int, time_spent_avg: float,
+                             reaction_counts: Optional[Dict[str, int]] = None,
+                             reaction_weights: Optional[Dict[str, float]] = None) -> float:
+    """Calculate engagement score based on various metrics
+    
+    Reactions contribute through their configured weights, so a negative
+    reaction such as "questionable" pulls the score down.
+    """
     if views == 0:
         return 0.0
     
@@ -63,15 +70,22 @@ def calculate_engagement_score(views: int, likes: int, shares: int, comments: in
     comment_rate = comments / views if views > 0 else 0
     completion_rate = min(time_spent_avg / (reading_time * 60), 1.0) if reading_time > 0 else 0
     
+    weights = reaction_weights or {}
+    weighted_reactions = sum(
+        count * weights.get(reaction, 0.0) for reaction, count in (reaction_counts or {}).items()
+    )
+    reaction_rate = max(min(weighted_reactions / views, 1.0), -1.0)
+    
     # Weighted score calculation
     engagement_score = (
-        like_rate * 0.3 +
-        share_rate * 0.3 +
+        like_rate * 0.25 +
+        share_rate * 0.25 +
         comment_rate * 0.2 +
-        completion_rate * 0.2
+        completion_rate * 0.15 +
+        reaction_rate * 0.15
     ) * 100
     
-    return round(engagement_score, 2)
+    return round(max(engagement_score, 0.0), 2)
 
 
 def calculate_quality_score(content: str, title: str, summary: Optional[str] = None) -> float:
@@ -154,13 +168,9 @@ def calculate_trending_score(views: int, likes: int, shares: int, comments: int,
 
 
 def sanitize_html(content: str) -> str:
-    """Basic HTML sanitization (remove dangerous tags)"""
-    # Simple implementation - in production, use a proper HTML sanitizer
-    dangerous_tags = ['script', 'iframe', 'object', 'embed', 'form', 'input']
-    for tag in dangerous_tags:
-        content = re.sub(f'<{tag}[^>]*>.*?</{tag}>', '', content, flags=re.IGNORECASE | re.DOTALL)
-        content = re.sub(f'<{tag}[^>]*/?>', '', content, flags=re.IGNORECASE)
-    return content
+    """HTML with everything outside the content allowlist removed (shared/content.py)"""
+    from shared.content import sanitize
+    return sanitize(content)
 
 
 def validate_email(email: str) -> bool:
@@ -197,6 +207,24 @@ def paginate_query_results(results: List[Any], page: int = 1, per_page: int = 20
     }
 
 
+def encode_cursor(values: Dict[str, Any]) -> str:
+    """Encode keyset pagination values into an opaque cursor string"""
+    payload = json.dumps(values, default=str, separators=(',', ':'))
+    return base64.urlsafe_b64encode(payload.encode()).decode().rstrip('=')
+
+
+def decode_cursor(cursor: str) -> Optional[Dict[str, Any]]:
+    """Decode an opaque cursor string, returning None if it is malformed"""
+    if not cursor:
+        return None
+    try:
+        padded = cursor + '=' * (-len(cursor) % 4)
+        values = json.loads(base64.urlsafe_b64decode(padded.encode()).decode())
+        return values if isinstance(values, dict) else None
+    except (ValueError, TypeError):
+        return None
+
+
 def serialize_datetime(dt: datetime) -> str:
     """Serialize datetime to ISO format"""
     return dt.isoformat() if dt else None
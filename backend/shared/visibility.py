@@ -0,0 +1,162 @@
+"""
+Shadow bans and user blocks
+
+A user holding `user:ban` (shared/permissions.py), by default an
+administrator or auditor, can shadow-ban a user. The user keeps posting as
+usual, but their articles and comments are only visible to themselves and
+to those holding `user:ban`. Other readers don't see them in article
+lists, feeds or discussions, and get 404 for the articles directly. Their
+comments don't notify anyone and don't count towards an article's
+comment_count. Lifting the ban brings everything back.
+
+Any user can block another. The blocked user's articles and comments are
+then filtered out of the blocker's article lists, feeds and discussions, and
+the blocker stops following them. The blocked user isn't told.
+
+Handlers filter with `hidden_authors`, the ids of the authors a viewer
+shouldn't see.
+"""
+
+import logging
+from typing import Any, Dict, List, Optional
+
+from shared import feed_versions, permissions
+from shared.audit import record_privileged
+from shared.database import get_redis
+
+logger = logging.getLogger(__name__)
+
+def hidden_authors(cursor, viewer: Optional[Dict[str, Any]]) -> List[str]:
+    """Ids of the authors whose content `viewer` (None when signed out) doesn't see"""
+    viewer_id = str(viewer['id']) if viewer else None
+    hidden = set()
+    # Those who can shadow-ban see the content, to review it
+    if not permissions.allowed(viewer, permissions.USER_BAN):
+        cursor.execute("SELECT id FROM users WHERE shadow_banned_at IS NOT NULL")
+        hidden.update(str(row['id']) for row in cursor.fetchall())
+    if viewer_id:
+        cursor.execute("SELECT blocked_id FROM user_blocks WHERE blocker_id = %s", (viewer_id,))
+        hidden.update(str(row['blocked_id']) for row in cursor.fetchall())
+        # Shadow-banned users still see their own content
+        hidden.discard(viewer_id)
+    return sorted(hidden)
+
+
+def is_shadow_banned(cursor, user_id: str) -> bool:
+    cursor.execute("SELECT shadow_banned_at IS NOT NULL AS banned FROM users WHERE id = %s", (user_id,))
+    row = cursor.fetchone()
+    return bool(row and row['banned'])
+
+
+def recount_comments(cursor, user_id: str):
+    """Recount comment_count on the articles a user commented on, leaving out shadow-banned commenters"""
+    cursor.execute("""
+        UPDATE articles a SET comment_count = (
+            SELECT COUNT(*) FROM comments c
+            JOIN users u ON u.id = c.user_id
+            WHERE c.article_id = a.id AND c.moderation_status = 'approved' AND u.shadow_banned_at IS NULL
+        )
+        WHERE a.id IN (SELECT article_id FROM comments WHERE user_id = %s)
+    """, (user_id,))
+
+
+def _drop_home_feed(viewer: str):
+    """Forget a cached home feed so it's rebuilt without the hidden authors; other viewers' expire on their own"""
+    from shared.feed_composer import home_feed_cache_key
+
+    try:
+        get_redis().delete(home_feed_cache_key(viewer))
+    except Exception as e:
+        logger.warning(f"Home feed cache drop failed for {viewer}: {e}")
+
+
+# Shadow bans
+def _ban_state(cursor, user_id: str) -> Optional[Dict[str, Any]]:
+    cursor.execute(
+        "SELECT shadow_banned_at, shadow_banned_by, shadow_ban_reason FROM users WHERE id = %s FOR UPDATE", (user_id,)
+    )
+    row = cursor.fetchone()
+    return dict(row) if row else None
+
+
+def shadow_ban(cursor, user_id: str, moderator: Dict[str, Any], reason: Optional[str]) -> Optional[Dict[str, Any]]:
+    before = _ban_state(cursor, user_id)
+    if before is None:
+        return None
+    cursor.execute("""
+        UPDATE users
+        SET shadow_banned_at = COALESCE(shadow_banned_at, NOW()), shadow_banned_by = %s, shadow_ban_reason = %s
+        WHERE id = %s
+        RETURNING id, username, shadow_banned_at, shadow_banned_by, shadow_ban_reason
+    """, (moderator['id'], reason, user_id))
+    banned = dict(cursor.fetchone())
+    recount_comments(cursor, user_id)
+    record_privileged(cursor, moderator, 'user_shadow_banned', 'user', user_id, before=before, after={
+        field: banned[field] for field in ('shadow_banned_at', 'shadow_banned_by', 'shadow_ban_reason')
+    })
+    feed_versions.bump()
+    _drop_home_feed('anonymous')
+    return banned
+
+
+def lift_shadow_ban(cursor, user_id: str, moderator: Dict[str, Any]) -> bool:
+    before = _ban_state(cursor, user_id)
+    cursor.execute("""
+        UPDATE users SET shadow_banned_at = NULL, shadow_banned_by = NULL, shadow_ban_reason = NULL
+        WHERE id = %s AND shadow_banned_at IS NOT NULL
+        RETURNING id
+    """, (user_id,))
+    if not cursor.fetchone():
+        return False
+    recount_comments(cursor, user_id)
+    record_privileged(cursor, moderator, 'user_shadow_ban_lifted', 'user', user_id, before=before, after={
+        'shadow_banned_at': None, 'shadow_banned_by': None, 'shadow_ban_reason': None,
+    })
+    feed_versions.bump()
+    _drop_home_feed('anonymous')
+    return True
+
+
+def list_shadow_banned(cursor, limit: int = 50, offset: int = 0) -> List[Dict[str, Any]]:
+    cursor.execute("""
+        SELECT u.id, u.username, u.shadow_banned_at, u.shadow_ban_reason, u.shadow_banned_by,
+               m.username AS shadow_banned_by_username
+        FROM users u
+        LEFT JOIN users m ON m.id = u.shadow_banned_by
+        WHERE u.shadow_banned_at IS NOT NULL
+        ORDER BY u.shadow_banned_at DESC
+        LIMIT %s OFFSET %s
+    """, (limit, offset))
+    return [dict(row) for row in cursor.fetchall()]
+
+
+# Blocks
+def block(cursor, blocker_id: str, blocked_id: str):
+    cursor.execute("""
+        INSERT INTO user_blocks (blocker_id, blocked_id)
+        VALUES (%s, %s)
+        ON CONFLICT (blocker_id, blocked_id) DO NOTHING
+    """, (blocker_id, blocked_id))
+    cursor.execute(
+        "DELETE FROM user_follows WHERE follower_id = %s AND following_id = %s", (blocker_id, blocked_id)
+    )
+    feed_versions.bump(blocker_id)
+    _drop_home_feed(blocker_id)
+
+
+def unblock(cursor, blocker_id: str, blocked_id: str):
+    cursor.execute("DELETE FROM user_blocks WHERE blocker_id = %s AND blocked_id = %s", (blocker_id, blocked_id))
+    feed_versions.bump(blocker_id)
+    _drop_home_feed(blocker_id)
+
+
+def list_blocks(cursor, blocker_id: str, limit: int = 50, offset: int = 0) -> List[Dict[str, Any]]:
+    cursor.execute("""
+        SELECT u.id, u.username, b.created_at AS blocked_at
+        FROM user_blocks b
+        JOIN users u ON u.id = b.blocked_id
+        WHERE b.blocker_id = %s
+        ORDER BY b.created_at DESC
+        LIMIT %s OFFSET %s
+    """, (blocker_id, limit, offset))
+    return [dict(row) for row in cursor.fetchall()]
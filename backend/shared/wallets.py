@@ -0,0 +1,188 @@
+"""
+Linked wallets
+
+An account can link several Ethereum wallets, each proven by a signature:
+the user asks for a challenge for an address, signs the returned message
+with that wallet (EIP-191 `personal_sign`, as every browser wallet offers)
+and submits the signature. Challenges are single-use and expire after
+WALLET_CHALLENGE_TTL_SECONDS. An address can belong to one account only.
+
+Each linked wallet is also a DID, `did:pkh:eip155:<chain id>:<address>`.
+One wallet is the primary: it is mirrored into `users.did_address`, which is
+where tips, purchases and token gates look for an account's wallet, so
+changing the primary changes where the account is paid. On-chain tips and
+purchases may be paid from any linked wallet and to any of the author's.
+Unlinking the primary promotes the most recently linked remaining wallet.
+"""
+
+import os
+import json
+import secrets
+import logging
+from datetime import datetime, timedelta, timezone
+from typing import Any, Dict, List, Optional
+
+from shared.database import get_redis
+from shared.paywall import CHAIN_ID
+
+logger = logging.getLogger(__name__)
+
+CHALLENGE_TTL_SECONDS = int(os.getenv('WALLET_CHALLENGE_TTL_SECONDS', 600))
+MAX_WALLETS = int(os.getenv('WALLET_MAX_PER_ACCOUNT', 10))
+APP_NAME = os.getenv('WALLET_CHALLENGE_APP_NAME', 'Decentralized News')
+
+
+class WalletError(Exception):
+    """A wallet that can't be linked, or a signature that doesn't prove it"""
+    pass
+
+
+def normalize(address: str) -> str:
+    """A lowercase 0x address, or WalletError"""
+    from web3 import Web3
+
+    if not Web3.is_address(address or ''):
+        raise WalletError("Not a valid Ethereum address")
+    return address.lower()
+
+
+def did_for(address: str, chain_id: int = CHAIN_ID) -> str:
+    return f"did:pkh:eip155:{chain_id}:{address.lower()}"
+
+
+def _challenge_key(user_id: str, address: str) -> str:
+    return f"wallet_challenge:{user_id}:{address}"
+
+
+def create_challenge(user_id: str, username: str, address: str) -> Dict[str, Any]:
+    """The message the wallet at `address` has to sign to be linked to the account"""
+    address = normalize(address)
+    issued_at = datetime.now(timezone.utc)
+    expires_at = issued_at + timedelta(seconds=CHALLENGE_TTL_SECONDS)
+    message = (
+        f"{APP_NAME} wants you to link this wallet to the account @{username}.\n\n"
+        f"Address: {address}\n"
+        f"Chain ID: {CHAIN_ID}\n"
+        f"Nonce: {secrets.token_hex(16)}\n"
+        f"Issued At: {issued_at.isoformat()}\n"
+        f"Expires At: {expires_at.isoformat()}"
+    )
+    get_redis().setex(_challenge_key(user_id, address), CHALLENGE_TTL_SECONDS, json.dumps({'message': message}))
+    return {'address': address, 'message': message, 'expires_at': expires_at}
+
+
+def _consume_challenge(user_id: str, address: str) -> str:
+    key = _challenge_key(user_id, address)
+    pipe = get_redis().pipeline()
+    pipe.get(key)
+    pipe.delete(key)
+    stored, _ = pipe.execute()
+    if not stored:
+        raise WalletError("Challenge expired or was already used; request a new one")
+    return json.loads(stored)['message']
+
+
+def recover_signer(message: str, signature: str) -> str:
+    """The address that signed `message` with personal_sign"""
+    from eth_account import Account
+    from eth_account.messages import encode_defunct
+
+    try:
+        return Account.recover_message(encode_defunct(text=message), signature=signature).lower()
+    except Exception as e:
+        raise WalletError(f"Invalid signature: {e}")
+
+
+def list_wallets(cursor, user_id: str) -> List[Dict[str, Any]]:
+    """The account's linked wallets, primary first"""
+    cursor.execute("""
+        SELECT id, address, did, chain_id, label, is_primary, verified_at, created_at
+        FROM user_wallets WHERE user_id = %s
+        ORDER BY is_primary DESC, created_at
+    """, (user_id,))
+    return [dict(row) for row in cursor.fetchall()]
+
+
+def addresses(cursor, user_id: str) -> List[str]:
+    cursor.execute("SELECT address FROM user_wallets WHERE user_id = %s", (user_id,))
+    return [row['address'] for row in cursor.fetchall()]
+
+
+def _sync_primary(cursor, user_id: str):
+    cursor.execute("""
+        UPDATE users SET did_address = (
+            SELECT address FROM user_wallets WHERE user_id = %s AND is_primary
+        ), updated_at = NOW()
+        WHERE id = %s
+    """, (user_id, user_id))
+
+
+def link_wallet(cursor, user_id: str, address: str, signature: str, label: Optional[str] = None,
+                make_primary: bool = False) -> Dict[str, Any]:
+    """Link the wallet that signed the account's challenge; the first wallet becomes the primary"""
+    address = normalize(address)
+    message = _consume_challenge(user_id, address)
+    if recover_signer(message, signature) != address:
+        raise WalletError("The signature was not made by this address")
+
+    cursor.execute("SELECT user_id FROM user_wallets WHERE address = %s", (address,))
+    existing = cursor.fetchone()
+    if existing:
+        raise WalletError(
+            "This wallet is already linked to your account" if str(existing['user_id']) == str(user_id)
+            else "This wallet is linked to another account"
+        )
+    cursor.execute("SELECT id FROM users WHERE did_address = %s AND id != %s", (address, user_id))
+    if cursor.fetchone():
+        raise WalletError("This wallet is linked to another account")
+    cursor.execute("SELECT COUNT(*) AS count FROM user_wallets WHERE user_id = %s", (user_id,))
+    count = cursor.fetchone()['count']
+    if count >= MAX_WALLETS:
+        raise WalletError(f"An account can link at most {MAX_WALLETS} wallets")
+
+    primary = make_primary or count == 0
+    if primary:
+        cursor.execute("UPDATE user_wallets SET is_primary = FALSE WHERE user_id = %s AND is_primary", (user_id,))
+    cursor.execute("""
+        INSERT INTO user_wallets (user_id, address, did, chain_id, label, is_primary, signed_message, signature,
+                                  verified_at)
+        VALUES (%s, %s, %s, %s, %s, %s, %s, %s, NOW())
+        RETURNING id, address, did, chain_id, label, is_primary, verified_at, created_at
+    """, (user_id, address, did_for(address), CHAIN_ID, label, primary, message, signature))
+    wallet = dict(cursor.fetchone())
+    if primary:
+        _sync_primary(cursor, user_id)
+    return wallet
+
+
+def set_primary(cursor, user_id: str, wallet_id: str) -> Optional[Dict[str, Any]]:
+    """Make a linked wallet the primary; None if the account has no such wallet"""
+    cursor.execute("SELECT id FROM user_wallets WHERE id = %s AND user_id = %s", (wallet_id, user_id))
+    if not cursor.fetchone():
+        return None
+    cursor.execute("UPDATE user_wallets SET is_primary = FALSE WHERE user_id = %s AND is_primary", (user_id,))
+    cursor.execute("""
+        UPDATE user_wallets SET is_primary = TRUE WHERE id = %s
+        RETURNING id, address, did, chain_id, label, is_primary, verified_at, created_at
+    """, (wallet_id,))
+    wallet = dict(cursor.fetchone())
+    _sync_primary(cursor, user_id)
+    return wallet
+
+
+def unlink_wallet(cursor, user_id: str, wallet_id: str) -> Optional[Dict[str, Any]]:
+    """Remove a linked wallet, promoting another one if it was the primary; None if there is no such wallet"""
+    cursor.execute(
+        "DELETE FROM user_wallets WHERE id = %s AND user_id = %s RETURNING address, is_primary",
+        (wallet_id, user_id)
+    )
+    removed = cursor.fetchone()
+    if not removed:
+        return None
+    if removed['is_primary']:
+        cursor.execute("""
+            UPDATE user_wallets SET is_primary = TRUE
+            WHERE id = (SELECT id FROM user_wallets WHERE user_id = %s ORDER BY created_at DESC LIMIT 1)
+        """, (user_id,))
+        _sync_primary(cursor, user_id)
+    return dict(removed)
@@ -0,0 +1,437 @@
+"""
+Data warehouse export
+
+A scheduled job copies rows of the `articles`, `interactions` and `users`
+datasets that changed since its last run to the sink chosen by
+WAREHOUSE_SINK, so analysts query the warehouse instead of production:
+
+- `parquet`: one Parquet file per batch, written with WAREHOUSE_STORAGE_DRIVER
+  (usually `s3`) under `warehouse/<dataset>/v<version>/dt=<day>/`
+- `bigquery`: rows appended to `<dataset>_v<version>` in WAREHOUSE_BIGQUERY_DATASET
+- `clickhouse`: rows inserted into `<dataset>_v<version>` over ClickHouse's HTTP interface
+
+Exports are PII-scrubbed: emails, password hashes, wallet addresses, profiles
+and session data are never read, and user ids are replaced by a keyed hash
+(WAREHOUSE_PSEUDONYM_KEY) that still joins across datasets. Authors of
+anonymous articles are left out.
+
+Each dataset has a schema version. Changing a dataset's columns means bumping
+its version, which starts a full export into a new table or prefix next to the
+old one; `_schema.json` next to Parquet files describes each version. Every
+row carries `_schema_version` and `_exported_at`.
+
+Delivery is at least once: a row changed again, or a batch retried after a
+failed run, is exported again, so readers keep the latest row per `id` by
+`updated_at` (or `_exported_at`).
+"""
+
+import io
+import os
+import hmac
+import json
+import hashlib
+import logging
+from dataclasses import dataclass
+from datetime import date, datetime, timezone
+from decimal import Decimal
+from typing import Any, Callable, Dict, List, Optional, Protocol, Tuple
+
+import requests
+
+from shared.database import get_postgres_cursor, get_redis, query_timeout
+
+logger = logging.getLogger(__name__)
+
+SINK = os.getenv('WAREHOUSE_SINK', 'parquet')
+BATCH_SIZE = int(os.getenv('WAREHOUSE_BATCH_SIZE', 50000))
+MAX_BATCHES_PER_RUN = int(os.getenv('WAREHOUSE_MAX_BATCHES_PER_RUN', 20))
+# Rows changed more recently than this wait for the next run, so transactions still committing aren't skipped
+SETTLE_SECONDS = int(os.getenv('WAREHOUSE_SETTLE_SECONDS', 60))
+LOCK_SECONDS = int(os.getenv('WAREHOUSE_LOCK_SECONDS', 3600))
+REQUEST_TIMEOUT_SECONDS = int(os.getenv('WAREHOUSE_REQUEST_TIMEOUT_SECONDS', 120))
+
+
+def enabled() -> bool:
+    return os.getenv('WAREHOUSE_EXPORT_ENABLED', 'false').lower() == 'true'
+
+
+# Datasets
+@dataclass(frozen=True)
+class Dataset:
+    name: str
+    version: int
+    columns: List[Tuple[str, str]]  # (name, type); types: string, int, float, bool, timestamp, date, string[]
+    select: str  # Selects `columns`, plus `_cursor` and `_id` for paging, from rows changed since the watermark
+    pseudonymize: Tuple[str, ...] = ()  # Columns holding a user id
+
+
+DATASETS: Dict[str, Dataset] = {
+    'articles': Dataset(
+        name='articles',
+        version=1,
+        columns=[
+            ('id', 'string'), ('author_key', 'string'), ('title', 'string'), ('summary', 'string'),
+            ('category', 'string'), ('subcategory', 'string'), ('tags', 'string[]'), ('language', 'string'),
+            ('status', 'string'), ('reading_time', 'int'), ('word_count', 'int'), ('source_url', 'string'),
+            ('engagement_score', 'float'), ('quality_score', 'float'), ('trending_score', 'float'),
+            ('view_count', 'int'), ('like_count', 'int'), ('comment_count', 'int'), ('share_count', 'int'),
+            ('published_at', 'timestamp'), ('created_at', 'timestamp'), ('updated_at', 'timestamp'),
+        ],
+        select="""
+            SELECT id::text AS id, CASE WHEN anonymous_author THEN NULL ELSE author_id::text END AS author_key,
+                   title, summary, category, subcategory, tags, language, status::text AS status,
+                   reading_time, word_count, source_url, engagement_score, quality_score, trending_score,
+                   view_count, like_count, comment_count, share_count, published_at, created_at, updated_at,
+                   COALESCE(updated_at, created_at) AS _cursor, id AS _id
+            FROM articles
+        """,
+        pseudonymize=('author_key',),
+    ),
+    'interactions': Dataset(
+        name='interactions',
+        version=1,
+        columns=[
+            ('id', 'string'), ('user_key', 'string'), ('article_id', 'string'), ('interaction_type', 'string'),
+            ('interaction_strength', 'float'), ('reading_progress', 'float'), ('time_spent', 'int'),
+            ('device_type', 'string'), ('created_at', 'timestamp'),
+        ],
+        select="""
+            SELECT id::text AS id, user_id::text AS user_key, article_id::text AS article_id,
+                   interaction_type::text AS interaction_type, interaction_strength, reading_progress,
+                   time_spent, device_type, created_at,
+                   created_at AS _cursor, id AS _id
+            FROM user_interactions
+        """,
+        pseudonymize=('user_key',),
+    ),
+    'users': Dataset(
+        name='users',
+        version=1,
+        columns=[
+            ('user_key', 'string'), ('role', 'string'), ('is_active', 'bool'), ('anonymous_mode', 'bool'),
+            ('verification_status', 'bool'), ('reputation_score', 'float'), ('last_active_date', 'date'),
+            ('created_at', 'timestamp'), ('updated_at', 'timestamp'),
+        ],
+        select="""
+            SELECT id::text AS user_key, role::text AS role, is_active, anonymous_mode, verification_status,
+                   reputation_score, (last_active AT TIME ZONE 'UTC')::date AS last_active_date,
+                   created_at, updated_at,
+                   COALESCE(updated_at, created_at) AS _cursor, id AS _id
+            FROM users
+        """,
+        pseudonymize=('user_key',),
+    ),
+}
+
+
+def pseudonym(user_id: Optional[str]) -> Optional[str]:
+    """Stable keyed hash standing in for a user id"""
+    if user_id is None:
+        return None
+    key = os.getenv('WAREHOUSE_PSEUDONYM_KEY', '')
+    if not key:
+        raise RuntimeError("WAREHOUSE_PSEUDONYM_KEY must be set to export user data")
+    return hmac.new(key.encode(), user_id.encode(), hashlib.sha256).hexdigest()[:32]
+
+
+def schema(dataset: Dataset) -> Dict[str, Any]:
+    """Self-describing schema of a dataset version, written next to its files"""
+    return {
+        'dataset': dataset.name,
+        'version': dataset.version,
+        'columns': [{'name': name, 'type': kind} for name, kind in dataset.columns]
+        + [{'name': '_schema_version', 'type': 'int'}, {'name': '_exported_at', 'type': 'timestamp'}],
+    }
+
+
+def _value(value: Any) -> Any:
+    if isinstance(value, Decimal):
+        return float(value)
+    return value
+
+
+# Sinks
+class WarehouseSink(Protocol):
+    name: str
+
+    def write(self, dataset: Dataset, rows: List[Dict[str, Any]], batch_id: str) -> str:
+        """Write a batch of rows and return where it went"""
+        ...
+
+
+class ParquetSink:
+    name = 'parquet'
+
+    def __init__(self):
+        from shared.storage import get_driver
+
+        self.driver = get_driver(os.getenv('WAREHOUSE_STORAGE_DRIVER', 's3'))
+        self._schemas_written = set()
+
+    def _arrow_schema(self, dataset: Dataset):
+        import pyarrow as pa
+
+        types = {
+            'string': pa.string(), 'int': pa.int64(), 'float': pa.float64(), 'bool': pa.bool_(),
+            'timestamp': pa.timestamp('us', tz='UTC'), 'date': pa.date32(), 'string[]': pa.list_(pa.string()),
+        }
+        return pa.schema(
+            [(name, types[kind]) for name, kind in dataset.columns]
+            + [('_schema_version', pa.int32()), ('_exported_at', pa.timestamp('us', tz='UTC'))]
+        )
+
+    def write(self, dataset: Dataset, rows: List[Dict[str, Any]], batch_id: str) -> str:
+        import pyarrow as pa
+        import pyarrow.parquet as pq
+
+        prefix = f"warehouse/{dataset.name}/v{dataset.version}"
+        if dataset.name not in self._schemas_written:
+            document = json.dumps(schema(dataset), indent=2).encode()
+            self.driver.put(f"{prefix}/_schema.json", document, 'application/json')
+            self._schemas_written.add(dataset.name)
+
+        table = pa.Table.from_pylist(rows, schema=self._arrow_schema(dataset))
+        buffer = io.BytesIO()
+        pq.write_table(table, buffer, compression='snappy')
+        day = rows[0]['_exported_at'].date().isoformat()
+        stored = self.driver.put(
+            f"{prefix}/dt={day}/{batch_id}.parquet", buffer.getvalue(), 'application/vnd.apache.parquet'
+        )
+        return stored['url']
+
+
+class BigQuerySink:
+    name = 'bigquery'
+
+    TYPES = {
+        'string': 'STRING', 'int': 'INT64', 'float': 'FLOAT64', 'bool': 'BOOL',
+        'timestamp': 'TIMESTAMP', 'date': 'DATE', 'string[]': 'STRING',
+    }
+
+    def __init__(self):
+        self.project = os.getenv('WAREHOUSE_BIGQUERY_PROJECT') or None
+        self.dataset = os.getenv('WAREHOUSE_BIGQUERY_DATASET', 'news_warehouse')
+        self._client = None
+
+    def _get_client(self):
+        from google.cloud import bigquery
+
+        if self._client is None:
+            # Credentials come from GOOGLE_APPLICATION_CREDENTIALS
+            self._client = bigquery.Client(project=self.project)
+        return self._client
+
+    def write(self, dataset: Dataset, rows: List[Dict[str, Any]], batch_id: str) -> str:
+        from google.cloud import bigquery
+
+        client = self._get_client()
+        fields = [
+            bigquery.SchemaField(name, self.TYPES[kind], mode='REPEATED' if kind == 'string[]' else 'NULLABLE')
+            for name, kind in dataset.columns
+        ] + [bigquery.SchemaField('_schema_version', 'INT64'), bigquery.SchemaField('_exported_at', 'TIMESTAMP')]
+        table_id = f"{client.project}.{self.dataset}.{dataset.name}_v{dataset.version}"
+        job = client.load_table_from_json(
+            [self._json_row(row) for row in rows], table_id,
+            job_config=bigquery.LoadJobConfig(
+                schema=fields,
+                write_disposition=bigquery.WriteDisposition.WRITE_APPEND,
+                create_disposition=bigquery.CreateDisposition.CREATE_IF_NEEDED,
+            ),
+            job_id=f"warehouse_{batch_id}",
+        )
+        job.result(timeout=REQUEST_TIMEOUT_SECONDS)
+        return table_id
+
+    @staticmethod
+    def _json_row(row: Dict[str, Any]) -> Dict[str, Any]:
+        return {
+            key: value.isoformat() if isinstance(value, (datetime, date)) else value
+            for key, value in row.items()
+        }
+
+
+class ClickHouseSink:
+    name = 'clickhouse'
+
+    TYPES = {
+        'string': 'Nullable(String)', 'int': 'Nullable(Int64)', 'float': 'Nullable(Float64)',
+        'bool': 'Nullable(Bool)', 'timestamp': "Nullable(DateTime64(6, 'UTC'))", 'date': 'Nullable(Date)',
+        'string[]': 'Array(String)',
+    }
+
+    def __init__(self):
+        self.url = os.getenv('WAREHOUSE_CLICKHOUSE_URL', 'http://localhost:8123').rstrip('/')
+        self.database = os.getenv('WAREHOUSE_CLICKHOUSE_DATABASE', 'news_warehouse')
+        self.auth = (os.getenv('WAREHOUSE_CLICKHOUSE_USER', 'default'), os.getenv('WAREHOUSE_CLICKHOUSE_PASSWORD', ''))
+        self._tables_created = set()
+
+    def _query(self, query: str, data: Optional[bytes] = None, settings: Optional[Dict[str, Any]] = None):
+        response = requests.post(
+            self.url, params={'query': query, **(settings or {})}, data=data, auth=self.auth,
+            timeout=REQUEST_TIMEOUT_SECONDS
+        )
+        response.raise_for_status()
+        return response
+
+    def _create_table(self, dataset: Dataset, table: str):
+        columns = ', '.join(f"`{name}` {self.TYPES[kind]}" for name, kind in dataset.columns)
+        order_by = 'id' if any(name == 'id' for name, _ in dataset.columns) else dataset.columns[0][0]
+        self._query(f"CREATE DATABASE IF NOT EXISTS {self.database}")
+        # ReplacingMergeTree keeps the latest export of each row once parts merge
+        self._query(f"""
+            CREATE TABLE IF NOT EXISTS {table} (
+                {columns}, `_schema_version` UInt16, `_exported_at` DateTime64(6, 'UTC')
+            )
+            ENGINE = ReplacingMergeTree(_exported_at)
+            ORDER BY assumeNotNull({order_by})
+        """)
+
+    def write(self, dataset: Dataset, rows: List[Dict[str, Any]], batch_id: str) -> str:
+        table = f"{self.database}.{dataset.name}_v{dataset.version}"
+        if table not in self._tables_created:
+            self._create_table(dataset, table)
+            self._tables_created.add(table)
+
+        body = '\n'.join(json.dumps(BigQuerySink._json_row(row)) for row in rows).encode()
+        # The token makes ClickHouse drop a retried batch it already inserted
+        self._query(
+            f"INSERT INTO {table} FORMAT JSONEachRow", body,
+            {'insert_deduplication_token': batch_id, 'date_time_input_format': 'best_effort'}
+        )
+        return table
+
+
+SINKS: Dict[str, Callable[[], WarehouseSink]] = {
+    'parquet': ParquetSink,
+    'bigquery': BigQuerySink,
+    'clickhouse': ClickHouseSink,
+}
+
+
+def get_sink(name: str = SINK) -> WarehouseSink:
+    if name not in SINKS:
+        raise ValueError(f"Unknown warehouse sink: {name}")
+    return SINKS[name]()
+
+
+# Export
+def _state(cursor, dataset: Dataset) -> Dict[str, Any]:
+    cursor.execute("""
+        INSERT INTO warehouse_exports (dataset, schema_version)
+        VALUES (%s, %s)
+        ON CONFLICT (dataset, schema_version) DO NOTHING
+    """, (dataset.name, dataset.version))
+    cursor.execute(
+        "SELECT * FROM warehouse_exports WHERE dataset = %s AND schema_version = %s", (dataset.name, dataset.version)
+    )
+    return dict(cursor.fetchone())
+
+
+def _fetch_batch(dataset: Dataset, state: Dict[str, Any]) -> List[Dict[str, Any]]:
+    query = f"SELECT * FROM ({dataset.select}) changed WHERE _cursor < NOW() - make_interval(secs => %s)"
+    params: List[Any] = [SETTLE_SECONDS]
+    if state['watermark'] is not None:
+        query += " AND (_cursor, _id) > (%s, %s)"
+        params += [state['watermark'], state['last_id']]
+    with get_postgres_cursor(timeout_ms=query_timeout('analytics')) as cursor:
+        cursor.execute(query + " ORDER BY _cursor, _id LIMIT %s", params + [BATCH_SIZE])
+        return [dict(row) for row in cursor.fetchall()]
+
+
+def _scrub(dataset: Dataset, row: Dict[str, Any], exported_at: datetime) -> Dict[str, Any]:
+    scrubbed = {
+        name: (row.get(name) or []) if kind == 'string[]' else _value(row.get(name)) for name, kind in dataset.columns
+    }
+    for column in dataset.pseudonymize:
+        scrubbed[column] = pseudonym(scrubbed[column])
+    scrubbed['_schema_version'] = dataset.version
+    scrubbed['_exported_at'] = exported_at
+    return scrubbed
+
+
+def export_dataset(dataset: Dataset, sink: WarehouseSink) -> int:
+    """Export a dataset's changed rows batch by batch, advancing its watermark after each; returns rows written"""
+    exported = 0
+    with get_postgres_cursor() as cursor:
+        state = _state(cursor, dataset)
+
+    for _ in range(MAX_BATCHES_PER_RUN):
+        rows = _fetch_batch(dataset, state)
+        if not rows:
+            break
+
+        exported_at = datetime.now(timezone.utc)
+        batch_id = f"{dataset.name}_v{dataset.version}_{exported_at:%Y%m%dT%H%M%S%f}"
+        location = sink.write(dataset, [_scrub(dataset, row, exported_at) for row in rows], batch_id)
+        last = rows[-1]
+        with get_postgres_cursor() as cursor:
+            cursor.execute("""
+                UPDATE warehouse_exports
+                SET watermark = %s, last_id = %s, rows_exported = rows_exported + %s, batches = batches + 1,
+                    sink = %s, last_location = %s, last_exported_at = NOW(), last_error = NULL, updated_at = NOW()
+                WHERE dataset = %s AND schema_version = %s
+                RETURNING *
+            """, (last['_cursor'], last['_id'], len(rows), sink.name, location, dataset.name, dataset.version))
+            state = dict(cursor.fetchone())
+        exported += len(rows)
+        logger.info(f"Exported {len(rows)} {dataset.name} rows to {location}")
+        if len(rows) < BATCH_SIZE:
+            break
+    return exported
+
+
+def export_all(datasets: Optional[List[str]] = None) -> Dict[str, Any]:
+    """Export every dataset (or those named) to the configured sink; one run at a time"""
+    if not enabled():
+        return {}
+    unknown = set(datasets or []) - set(DATASETS)
+    if unknown:
+        raise ValueError(f"Unknown warehouse datasets: {', '.join(sorted(unknown))}")
+
+    lock_key = 'warehouse_export_lock'
+    if not get_redis().set(lock_key, 1, nx=True, ex=LOCK_SECONDS):
+        logger.info("Warehouse export already running")
+        return {}
+    try:
+        sink = get_sink()
+        results: Dict[str, Any] = {}
+        for name in datasets or list(DATASETS):
+            dataset = DATASETS[name]
+            try:
+                results[name] = export_dataset(dataset, sink)
+            except Exception as e:
+                logger.error(f"Warehouse export of {name} failed: {e}")
+                with get_postgres_cursor() as cursor:
+                    cursor.execute("""
+                        UPDATE warehouse_exports SET last_error = %s, updated_at = NOW()
+                        WHERE dataset = %s AND schema_version = %s
+                    """, (str(e)[:1000], name, dataset.version))
+                results[name] = {'error': str(e)[:200]}
+        return results
+    finally:
+        get_redis().delete(lock_key)
+
+
+def status(cursor) -> List[Dict[str, Any]]:
+    """Export state of every dataset's current schema version, with the schema itself"""
+    cursor.execute("SELECT * FROM warehouse_exports")
+    states = {(row['dataset'], row['schema_version']): dict(row) for row in cursor.fetchall()}
+    return [
+        {
+            **schema(dataset),
+            'state': states.get((dataset.name, dataset.version)),
+            'older_versions': sorted(v for (name, v) in states if name == dataset.name and v != dataset.version),
+        }
+        for dataset in DATASETS.values()
+    ]
+
+
+def reset(cursor, name: str) -> bool:
+    """Forget a dataset's watermark so the next run exports it in full again"""
+    dataset = DATASETS[name]
+    cursor.execute("""
+        UPDATE warehouse_exports SET watermark = NULL, last_id = NULL, updated_at = NOW()
+        WHERE dataset = %s AND schema_version = %s
+        RETURNING dataset
+    """, (name, dataset.version))
+    return cursor.fetchone() is not None
@@ -0,0 +1,316 @@
+"""
+Webhook delivery for both Flask and FastAPI backends
+
+Events are fanned out to subscribed webhooks by writing delivery rows with
+the caller's cursor, so a delivery exists exactly when the change that
+caused it commits. The delivery worker claims due rows by pushing their next
+attempt out and committing, so no row lock is held while it waits on
+receivers, then sends them with an HMAC-SHA256 signature to the address
+their host was checked at (a host re-resolving to a private address between
+the check and the request can't redirect the delivery), retries failures
+with exponential backoff and moves deliveries that exhaust their attempts to
+the dead-letter table. Webhooks, their deliveries and dead letters belong to
+their owner's publication, events only reach that publication's webhooks,
+and signing secrets are stored encrypted for it
+(shared/tenant_keys.py).
+"""
+
+import os
+import hmac
+import json
+import socket
+import hashlib
+import ipaddress
+import logging
+import secrets
+import time
+from datetime import datetime, timedelta
+from typing import Any, Dict, Optional
+from urllib.parse import urlparse
+
+import requests
+from requests.adapters import HTTPAdapter
+
+from shared import tenant_keys
+from shared.database import get_postgres_cursor, prepare_json_data
+from shared.utils import generate_uuid, safe_json_dumps
+
+logger = logging.getLogger(__name__)
+
+WEBHOOK_EVENTS = [
+    'article.published',
+    'user.registered',
+    'comment.created',
+]
+
+
+def generate_secret() -> str:
+    return f"whsec_{secrets.token_urlsafe(32)}"
+
+
+def sign_payload(secret: str, timestamp: int, body: str) -> str:
+    """Signature header value: receivers recompute HMAC-SHA256 over "<timestamp>.<body>" """
+    digest = hmac.new(secret.encode(), f"{timestamp}.{body}".encode(), hashlib.sha256).hexdigest()
+    return f"t={timestamp},v1={digest}"
+
+
+def checked_address(url: str) -> Optional[str]:
+    """Address to connect to for `url`; None for non-HTTP(S) targets and, unless explicitly allowed,
+    hosts resolving to private network addresses"""
+    parsed = urlparse(url)
+    if parsed.scheme not in ('http', 'https') or not parsed.hostname:
+        return None
+
+    try:
+        addresses = sorted({info[4][0] for info in socket.getaddrinfo(parsed.hostname, parsed.port or 443)})
+    except (socket.gaierror, UnicodeError):
+        return None
+    if not addresses:
+        return None
+    if os.getenv('WEBHOOK_ALLOW_PRIVATE_TARGETS', 'false').lower() == 'true':
+        return addresses[0]
+
+    for address in addresses:
+        ip = ipaddress.ip_address(address.split('%')[0])
+        if ip.is_private or ip.is_loopback or ip.is_link_local or ip.is_reserved or ip.is_multicast:
+            return None
+    return addresses[0]
+
+
+def is_allowed_target(url: str) -> bool:
+    """Reject non-HTTP(S) targets and, unless explicitly allowed, private network addresses"""
+    return checked_address(url) is not None
+
+
+class PinnedHostAdapter(HTTPAdapter):
+    """HTTPS to a fixed address, verifying the certificate of and sending SNI for the URL's host"""
+
+    def __init__(self, hostname: str):
+        self.hostname = hostname
+        super().__init__()
+
+    def init_poolmanager(self, *args, **kwargs):
+        kwargs['server_hostname'] = self.hostname
+        kwargs['assert_hostname'] = self.hostname
+        super().init_poolmanager(*args, **kwargs)
+
+
+def post_pinned(url: str, address: str, **kwargs) -> requests.Response:
+    """POST to `url` over a connection to `address`, so DNS isn't consulted again after the target was checked"""
+    parsed = urlparse(url)
+    userinfo, _, host = parsed.netloc.rpartition('@')
+    pinned_host = f"[{address}]" if ':' in address else address
+    if parsed.port:
+        pinned_host = f"{pinned_host}:{parsed.port}"
+    pinned_url = parsed._replace(netloc=f"{userinfo}@{pinned_host}" if userinfo else pinned_host).geturl()
+    headers = {**kwargs.pop('headers', {}), 'Host': host}
+
+    with requests.Session() as session:
+        if parsed.scheme == 'https':
+            session.mount('https://', PinnedHostAdapter(parsed.hostname))
+        return session.post(pinned_url, headers=headers, **kwargs)
+
+
+class WebhookDispatcher:
+    """Fans events out to webhooks and delivers them with retries"""
+
+    def __init__(self):
+        self.max_attempts = int(os.getenv('WEBHOOK_MAX_ATTEMPTS', 8))
+        self.backoff_base_seconds = int(os.getenv('WEBHOOK_BACKOFF_BASE_SECONDS', 30))
+        self.backoff_max_seconds = int(os.getenv('WEBHOOK_BACKOFF_MAX_SECONDS', 6 * 60 * 60))
+        self.timeout_seconds = float(os.getenv('WEBHOOK_TIMEOUT_SECONDS', 10))
+        self.batch_size = int(os.getenv('WEBHOOK_BATCH_SIZE', 50))
+        # How long other workers skip a claimed batch; a crashed worker's deliveries are retried after it
+        self.claim_seconds = int(os.getenv('WEBHOOK_CLAIM_SECONDS', 30 * 60))
+
+    def emit(self, cursor, event_type: str, data: Dict[str, Any], tenant_id: Optional[str] = None) -> int:
+        """Queue an event for every active webhook subscribed to it in the event's publication"""
+        if event_type not in WEBHOOK_EVENTS:
+            raise ValueError(f"Unknown webhook event '{event_type}'")
+
+        event_id = generate_uuid()
+        payload = json.loads(safe_json_dumps({
+            'id': event_id,
+            'type': event_type,
+            'created_at': datetime.now().isoformat(),
+            'data': data,
+        }))
+
+        cursor.execute("""
+            INSERT INTO webhook_deliveries (webhook_id, event_id, event_type, payload, tenant_id)
+            SELECT w.id, %s, %s, %s, w.tenant_id FROM webhooks w
+            WHERE w.is_active = true AND %s = ANY(w.events) AND w.tenant_id IS NOT DISTINCT FROM %s
+        """, (event_id, event_type, prepare_json_data(payload), event_type, tenant_id))
+        return cursor.rowcount
+
+    def backoff(self, attempt_count: int) -> timedelta:
+        """Delay before the next attempt: base * 2^(attempts - 1), capped"""
+        seconds = self.backoff_base_seconds * (2 ** max(attempt_count - 1, 0))
+        return timedelta(seconds=min(seconds, self.backoff_max_seconds))
+
+    def deliver_due(self) -> int:
+        """Claim one batch of due deliveries, then send them; returns how many were attempted"""
+        with get_postgres_cursor() as cursor:
+            cursor.execute("""
+                SELECT d.*, w.url, w.secret, u.tenant_id AS owner_tenant_id FROM webhook_deliveries d
+                JOIN webhooks w ON w.id = d.webhook_id
+                JOIN users u ON u.id = w.owner_id
+                WHERE d.status = 'pending' AND d.next_attempt_at <= NOW() AND w.is_active = true
+                ORDER BY d.next_attempt_at ASC
+                LIMIT %s
+                FOR UPDATE OF d SKIP LOCKED
+            """, (self.batch_size,))
+            deliveries = cursor.fetchall()
+            if deliveries:
+                cursor.execute(
+                    "UPDATE webhook_deliveries SET next_attempt_at = %s WHERE id = ANY(%s::uuid[])",
+                    (datetime.now() + timedelta(seconds=self.claim_seconds),
+                     [str(delivery['id']) for delivery in deliveries])
+                )
+
+        # The claim is committed, so receivers are waited on without holding the rows locked
+        for delivery in deliveries:
+            status_code, error = self._send(delivery)
+            with get_postgres_cursor() as cursor:
+                self._record_attempt(cursor, delivery, status_code, error)
+
+        return len(deliveries)
+
+    def _send(self, delivery: Dict[str, Any]):
+        address = checked_address(delivery['url'])
+        if not address:
+            return None, "Target URL is not allowed"
+        try:
+            secret = tenant_keys.decrypt(delivery['secret'], delivery['owner_tenant_id'])
+        except tenant_keys.DecryptionError as e:
+            return None, f"Signing secret can't be decrypted: {e}"
+
+        body = json.dumps(delivery['payload'], separators=(',', ':'))
+        timestamp = int(time.time())
+        headers = {
+            'Content-Type': 'application/json',
+            'User-Agent': 'DecentralizedNews-Webhooks/1.0',
+            'X-Webhook-Id': str(delivery['webhook_id']),
+            'X-Webhook-Event': delivery['event_type'],
+            'X-Webhook-Delivery': str(delivery['id']),
+            'X-Webhook-Signature': sign_payload(secret, timestamp, body),
+        }
+
+        try:
+            response = post_pinned(
+                delivery['url'], address, data=body, headers=headers,
+                timeout=self.timeout_seconds, allow_redirects=False
+            )
+            if 200 <= response.status_code < 300:
+                return response.status_code, None
+            return response.status_code, f"HTTP {response.status_code}: {response.text[:500]}"
+        except requests.RequestException as e:
+            return None, str(e)[:1000]
+
+    def _record_attempt(self, cursor, delivery: Dict[str, Any], status_code: Optional[int], error: Optional[str]):
+        attempt_count = delivery['attempt_count'] + 1
+
+        if error is None:
+            cursor.execute("""
+                UPDATE webhook_deliveries
+                SET status = 'succeeded', attempt_count = %s, last_attempt_at = NOW(),
+                    last_status_code = %s, last_error = NULL, delivered_at = NOW()
+                WHERE id = %s
+            """, (attempt_count, status_code, delivery['id']))
+            return
+
+        if attempt_count >= self.max_attempts:
+            cursor.execute("""
+                UPDATE webhook_deliveries
+                SET status = 'dead', attempt_count = %s, last_attempt_at = NOW(),
+                    last_status_code = %s, last_error = %s
+                WHERE id = %s
+            """, (attempt_count, status_code, error, delivery['id']))
+            cursor.execute("""
+                INSERT INTO webhook_dead_letters (
+                    delivery_id, webhook_id, event_type, payload, attempt_count, last_status_code, last_error, tenant_id
+                ) VALUES (%s, %s, %s, %s, %s, %s, %s, %s)
+            """, (
+                delivery['id'], delivery['webhook_id'], delivery['event_type'],
+                prepare_json_data(delivery['payload']), attempt_count, status_code, error, delivery['tenant_id']
+            ))
+            logger.warning(f"Webhook delivery {delivery['id']} dead-lettered after {attempt_count} attempts")
+            return
+
+        cursor.execute("""
+            UPDATE webhook_deliveries
+            SET attempt_count = %s, last_attempt_at = NOW(), last_status_code = %s,
+                last_error = %s, next_attempt_at = %s
+            WHERE id = %s
+        """, (attempt_count, status_code, error, datetime.now() + self.backoff(attempt_count), delivery['id']))
+
+    def replay_dead_letter(self, cursor, dead_letter_id: str, webhook_id: str) -> bool:
+        """Put a dead-lettered delivery back in the queue with a fresh attempt budget"""
+        cursor.execute("""
+            UPDATE webhook_dead_letters SET replayed_at = NOW()
+            WHERE id = %s AND webhook_id = %s AND replayed_at IS NULL
+            RETURNING delivery_id
+        """, (dead_letter_id, webhook_id))
+        dead_letter = cursor.fetchone()
+        if not dead_letter:
+            return False
+
+        cursor.execute("""
+            UPDATE webhook_deliveries
+            SET status = 'pending', attempt_count = 0, next_attempt_at = NOW()
+            WHERE id = %s
+        """, (dead_letter['delivery_id'],))
+        return True
+
+
+# Global dispatcher instance
+webhook_dispatcher = WebhookDispatcher()
+
+
+# Convenience functions
+def emit_webhook_event(cursor, event_type: str, data: Dict[str, Any], tenant_id: Optional[str] = None) -> int:
+    """Queue an event without letting a webhook failure abort the caller's transaction"""
+    try:
+        cursor.execute("SAVEPOINT webhook_emit")
+        queued = webhook_dispatcher.emit(cursor, event_type, data, tenant_id)
+        cursor.execute("RELEASE SAVEPOINT webhook_emit")
+        return queued
+    except Exception as e:
+        cursor.execute("ROLLBACK TO SAVEPOINT webhook_emit")
+        logger.error(f"Failed to queue webhook event {event_type}: {e}")
+        return 0
+
+
+def emit_user_registered(cursor, user: Dict[str, Any]):
+    emit_webhook_event(cursor, 'user.registered', {
+        'id': str(user['id']),
+        'username': user['username'],
+        'role': user.get('role'),
+        'created_at': user.get('created_at'),
+    }, user.get('tenant_id'))
+
+
+def emit_article_published(cursor, article: Dict[str, Any]):
+    """Publish hook: notify subscribers that an article went live"""
+    emit_webhook_event(cursor, 'article.published', {
+        'id': str(article['id']),
+        'title': article['title'],
+        'summary': article.get('summary'),
+        'category': article.get('category'),
+        'tags': list(article.get('tags') or []),
+        'language': article.get('language'),
+        'author_id': None if article.get('anonymous_author') else str(article.get('author_id')),
+        'published_at': article.get('published_at'),
+    }, article.get('tenant_id'))
+
+
+def emit_comment_created(cursor, comment: Dict[str, Any], article: Optional[Dict[str, Any]]):
+    """Notify subscribers of a comment readers can now see: posted unheld, or approved after review"""
+    emit_webhook_event(cursor, 'comment.created', {
+        'id': str(comment['id']),
+        'article_id': str(comment['article_id']),
+        'parent_comment_id': str(comment['parent_comment_id']) if comment.get('parent_comment_id') else None,
+        'author_id': None if comment.get('is_anonymous') else str(comment['user_id']),
+        'content': comment['content'],
+        'created_at': comment.get('created_at'),
+    }, article.get('tenant_id') if article else None)
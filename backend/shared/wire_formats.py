@@ -0,0 +1,293 @@
+"""
+Binary encodings for high-volume read endpoints
+
+The feeds and the article list answer in Protocol Buffers or MessagePack
+instead of JSON when the Accept header prefers `application/x-protobuf` or
+`application/msgpack`, skipping JSON encoding on the busiest endpoints.
+
+The Protocol Buffers schema is declared once in MESSAGES, with field numbers
+that must never be reused. Message classes are built from it at runtime, and
+`scripts/export_proto.py` writes the same schema to proto/news.proto for the
+mobile clients to generate their code from. Timestamps are ISO 8601 strings
+as in JSON, and free-form objects (such as article metadata) are JSON-encoded
+strings. MessagePack responses have exactly the JSON document's shape.
+"""
+
+import json
+from functools import lru_cache
+from typing import Any, Dict, List, Optional, Tuple
+
+PACKAGE = 'news.v1'
+
+JSON = 'json'
+PROTOBUF = 'protobuf'
+MSGPACK = 'msgpack'
+
+MEDIA_TYPES = {
+    JSON: 'application/json',
+    PROTOBUF: 'application/x-protobuf',
+    MSGPACK: 'application/msgpack',
+}
+ACCEPTED_TYPES = {
+    'application/json': JSON,
+    'application/x-protobuf': PROTOBUF,
+    'application/protobuf': PROTOBUF,
+    'application/vnd.google.protobuf': PROTOBUF,
+    'application/msgpack': MSGPACK,
+    'application/x-msgpack': MSGPACK,
+    'application/vnd.msgpack': MSGPACK,
+}
+
+# Message -> fields as (number, name, type); types are scalars, `json`, another message,
+# `repeated <type>` or `map<string, <scalar>>`
+MESSAGES: Dict[str, List[Tuple[int, str, str]]] = {
+    'Article': [
+        (1, 'id', 'string'),
+        (2, 'author_id', 'string'),
+        (3, 'title', 'string'),
+        (4, 'content', 'string'),
+        (5, 'summary', 'string'),
+        (6, 'category', 'string'),
+        (7, 'subcategory', 'string'),
+        (8, 'tags', 'repeated string'),
+        (9, 'language', 'string'),
+        (10, 'anonymous_author', 'bool'),
+        (11, 'metadata', JSON),
+        (12, 'license', 'string'),
+        (13, 'license_terms', 'string'),
+        (14, 'status', 'string'),
+        (15, 'reading_time', 'int32'),
+        (16, 'word_count', 'int32'),
+        (17, 'published_at', 'string'),
+        (18, 'created_at', 'string'),
+        (19, 'updated_at', 'string'),
+        (20, 'source_url', 'string'),
+        (21, 'image_urls', 'repeated string'),
+        (22, 'og_image_url', 'string'),
+        (23, 'scheduled_publish_at', 'string'),
+        (24, 'seo_keywords', 'repeated string'),
+        (25, 'engagement_score', 'double'),
+        (26, 'quality_score', 'double'),
+        (27, 'trending_score', 'double'),
+        (28, 'view_count', 'int64'),
+        (29, 'like_count', 'int64'),
+        (30, 'comment_count', 'int64'),
+        (31, 'share_count', 'int64'),
+        (32, 'reaction_counts', 'map<string, int64>'),
+        (33, 'clap_count', 'int64'),
+        (34, 'reading_level', 'string'),
+        (35, 'readability', JSON),
+        (36, 'experiment_variants', 'map<string, string>'),
+        (37, 'access_policy', JSON),
+        (38, 'premium', 'bool'),
+        (39, 'paywalled', 'bool'),
+        (40, 'authors', 'repeated ArticleAuthor'),
+        (41, 'link_previews', 'repeated LinkPreview'),
+        (42, 'content_html', 'string'),
+        (43, 'content_format', 'string'),
+        (44, 'translations', 'repeated string'),
+        (45, 'translation', 'ArticleTranslation'),
+        (46, 'slug', 'string'),
+    ],
+    'ArticleAuthor': [
+        (1, 'user_id', 'string'),
+        (2, 'username', 'string'),
+        (3, 'display_name', 'string'),
+        (4, 'role', 'string'),
+    ],
+    'LinkPreview': [
+        (1, 'url', 'string'),
+        (2, 'title', 'string'),
+        (3, 'description', 'string'),
+        (4, 'image_url', 'string'),
+        (5, 'site_name', 'string'),
+    ],
+    'ArticleTranslation': [
+        (1, 'language', 'string'),
+        (2, 'original_language', 'string'),
+        (3, 'machine_translated', 'bool'),
+        (4, 'provider', 'string'),
+        (5, 'translated_at', 'string'),
+        (6, 'outdated', 'bool'),
+    ],
+    'PinnedArticle': [
+        (1, 'pin_id', 'string'),
+        (2, 'pin_type', 'string'),
+        (3, 'position', 'int32'),
+        (4, 'ends_at', 'string'),
+        (5, 'article', 'Article'),
+    ],
+    'FeedShelf': [
+        (1, 'name', 'string'),
+        (2, 'title', 'string'),
+        (3, 'source', 'string'),
+        (4, 'position', 'int32'),
+        (5, 'items', 'repeated Article'),
+        (6, 'topics', JSON),
+    ],
+    # GET /api/v1/feed and /api/v1/feed/following
+    'FeedPage': [
+        (1, 'data', 'repeated Article'),
+        (2, 'next_cursor', 'string'),
+        (3, 'has_more', 'bool'),
+        (4, 'pinned', 'repeated PinnedArticle'),
+    ],
+    # GET /api/v1/feed/home
+    'HomeFeed': [
+        (1, 'pinned', 'repeated PinnedArticle'),
+        (2, 'shelves', 'repeated FeedShelf'),
+        (3, 'generated_at', 'string'),
+    ],
+    # GET /api/v1/articles
+    'ArticlePage': [
+        (1, 'data', 'repeated Article'),
+        (2, 'page', 'int32'),
+        (3, 'per_page', 'int32'),
+        (4, 'total', 'int64'),
+        (5, 'pages', 'int32'),
+        (6, 'has_next', 'bool'),
+        (7, 'has_prev', 'bool'),
+    ],
+}
+
+SCALARS = ('string', 'bool', 'int32', 'int64', 'double')
+
+
+def negotiate(accept: Optional[str]) -> str:
+    """The encoding the Accept header prefers among JSON, Protocol Buffers and MessagePack; JSON on a tie"""
+    best, best_quality = JSON, 0.0
+    for part in (accept or '').split(','):
+        media_type, *params = [piece.strip() for piece in part.split(';')]
+        encoding = ACCEPTED_TYPES.get(media_type.lower())
+        if not encoding:
+            continue
+        quality = 1.0
+        for param in params:
+            if param.startswith('q='):
+                try:
+                    quality = float(param[2:])
+                except ValueError:
+                    quality = 0.0
+        if quality > best_quality or (quality == best_quality and encoding == JSON):
+            best, best_quality = encoding, quality
+    return best
+
+
+def _parse_type(field_type: str) -> Tuple[bool, str, Optional[str]]:
+    """(repeated, type, map value type) for a field type"""
+    if field_type.startswith('map<'):
+        return False, 'map', field_type[len('map<string,'):-1].strip()
+    if field_type.startswith('repeated '):
+        return True, field_type[len('repeated '):], None
+    return False, field_type, None
+
+
+def _entry_name(field_name: str) -> str:
+    return ''.join(part.capitalize() for part in field_name.split('_')) + 'Entry'
+
+
+# Schema rendering
+def proto_source() -> str:
+    """The schema as a .proto file"""
+    lines = [
+        '// Generated by scripts/export_proto.py from shared/wire_formats.py; do not edit.',
+        '// Timestamps are ISO 8601 strings; fields commented JSON hold JSON-encoded objects.',
+        'syntax = "proto3";',
+        '',
+        f'package {PACKAGE};',
+    ]
+    for message, fields in MESSAGES.items():
+        lines += ['', f'message {message} {{']
+        for number, name, field_type in fields:
+            repeated, base, map_value = _parse_type(field_type)
+            if map_value:
+                declared = f'map<string, {map_value}>'
+            else:
+                declared = ('repeated ' if repeated else '') + ('string' if base == JSON else base)
+            comment = '  // JSON' if base == JSON else ''
+            lines.append(f'  {declared} {name} = {number};{comment}')
+        lines.append('}')
+    return '\n'.join(lines) + '\n'
+
+
+# Protocol Buffers
+@lru_cache(maxsize=1)
+def _message_classes() -> Dict[str, Any]:
+    from google.protobuf import descriptor_pb2, descriptor_pool, message_factory
+
+    FieldProto = descriptor_pb2.FieldDescriptorProto
+    scalar_types = {
+        'string': FieldProto.TYPE_STRING, JSON: FieldProto.TYPE_STRING, 'bool': FieldProto.TYPE_BOOL,
+        'int32': FieldProto.TYPE_INT32, 'int64': FieldProto.TYPE_INT64, 'double': FieldProto.TYPE_DOUBLE,
+    }
+
+    file_proto = descriptor_pb2.FileDescriptorProto(name='news.proto', package=PACKAGE, syntax='proto3')
+    for message, fields in MESSAGES.items():
+        message_proto = file_proto.message_type.add(name=message)
+        for number, name, field_type in fields:
+            repeated, base, map_value = _parse_type(field_type)
+            field = message_proto.field.add(name=name, number=number, json_name=name)
+            field.label = FieldProto.LABEL_REPEATED if repeated or map_value else FieldProto.LABEL_OPTIONAL
+            if map_value:
+                entry = message_proto.nested_type.add(name=_entry_name(name))
+                entry.options.map_entry = True
+                entry.field.add(name='key', number=1, type=FieldProto.TYPE_STRING, label=FieldProto.LABEL_OPTIONAL)
+                entry.field.add(name='value', number=2, type=scalar_types[map_value], label=FieldProto.LABEL_OPTIONAL)
+                field.type = FieldProto.TYPE_MESSAGE
+                field.type_name = f'.{PACKAGE}.{message}.{_entry_name(name)}'
+            elif base in scalar_types:
+                field.type = scalar_types[base]
+            else:
+                field.type = FieldProto.TYPE_MESSAGE
+                field.type_name = f'.{PACKAGE}.{base}'
+
+    pool = descriptor_pool.DescriptorPool()
+    pool.Add(file_proto)
+    return {
+        message: message_factory.GetMessageClass(pool.FindMessageTypeByName(f'{PACKAGE}.{message}'))
+        for message in MESSAGES
+    }
+
+
+def _prepare(message: str, document: Dict[str, Any]) -> Dict[str, Any]:
+    """The document with nulls and unknown fields dropped, JSON fields encoded and nested messages prepared"""
+    prepared = {}
+    for _, name, field_type in MESSAGES[message]:
+        value = document.get(name)
+        if value is None:
+            continue
+        repeated, base, _ = _parse_type(field_type)
+        if base == JSON:
+            value = json.dumps(value, separators=(',', ':'))
+        elif base in MESSAGES:
+            value = [_prepare(base, item) for item in value] if repeated else _prepare(base, value)
+        prepared[name] = value
+    return prepared
+
+
+def encode_protobuf(message: str, document: Dict[str, Any]) -> bytes:
+    from google.protobuf import json_format
+
+    return json_format.ParseDict(_prepare(message, document), _message_classes()[message]()).SerializeToString()
+
+
+def encode_msgpack(document: Any) -> bytes:
+    import msgpack
+
+    return msgpack.packb(document, use_bin_type=True)
+
+
+def respond(request, model, message: str):
+    """`model` as FastAPI would return it when the client takes JSON, otherwise encoded as the client prefers"""
+    from fastapi.responses import Response
+    from shared import lite
+
+    encoding = negotiate(request.headers.get('accept'))
+    if encoding == JSON:
+        return model
+
+    document = model.model_dump(mode='json')
+    if lite.is_lite():
+        document = lite.shape(document)
+    body = encode_protobuf(message, document) if encoding == PROTOBUF else encode_msgpack(document)
+    return Response(content=body, media_type=MEDIA_TYPES[encoding], headers={'Vary': 'Accept'})
@@ -0,0 +1,117 @@
+"""
+Shared fixtures for backend tests
+
+Tests run from backend/ against the shared modules and routers directly,
+with databases replaced by in-memory fakes.
+"""
+
+import os
+import sys
+from contextlib import contextmanager
+
+import pytest
+
+sys.path.insert(0, os.path.join(os.path.dirname(__file__), '..'))
+
+
+class FakeCursor:
+    """Stands in for a RealDictCursor: records queries and returns queued results in order"""
+
+    def __init__(self, *results):
+        self.results = list(results)
+        self.queries = []
+
+    def execute(self, query, params=None):
+        self.queries.append((' '.join(query.split()), params))
+
+    def _next(self):
+        return self.results.pop(0) if self.results else None
+
+    def fetchone(self):
+        return self._next()
+
+    def fetchall(self):
+        return self._next() or []
+
+
+class FakeRedis:
+    """The slice of the Redis client the caches use, over a dict; expiry is ignored"""
+
+    def __init__(self):
+        self.data = {}
+
+    def get(self, key):
+        return self.data.get(key)
+
+    def set(self, key, value, ex=None):
+        self.data[key] = value
+
+    def setex(self, key, ttl, value):
+        self.data[key] = value
+
+    def exists(self, key):
+        return int(key in self.data)
+
+    def delete(self, *keys):
+        return sum(self.data.pop(key, None) is not None for key in keys)
+
+    def rename(self, key, new_key):
+        if key not in self.data:
+            raise KeyError(key)
+        self.data[new_key] = self.data.pop(key)
+
+    def expire(self, key, ttl):
+        return int(key in self.data)
+
+    def hset(self, key, mapping):
+        self.data.setdefault(key, {}).update(mapping)
+
+    def hgetall(self, key):
+        return dict(self.data.get(key) or {})
+
+    def pipeline(self):
+        return FakePipeline(self)
+
+
+class FakePipeline:
+    def __init__(self, redis):
+        self.redis = redis
+        self.calls = []
+
+    def __getattr__(self, name):
+        def queue(*args, **kwargs):
+            self.calls.append((name, args, kwargs))
+            return self
+        return queue
+
+    def execute(self):
+        return [getattr(self.redis, name)(*args, **kwargs) for name, args, kwargs in self.calls]
+
+
+@pytest.fixture
+def patch_redis(monkeypatch):
+    """Make `get_redis` of each module return one shared FakeRedis, which is returned"""
+    def patch(*modules):
+        redis = FakeRedis()
+        for module in modules:
+            monkeypatch.setattr(module, 'get_redis', lambda: redis)
+        return redis
+    return patch
+
+
+@pytest.fixture
+def fake_cursor():
+    """Build a FakeCursor from the results its queries should return"""
+    return FakeCursor
+
+
+@pytest.fixture
+def patch_cursor(monkeypatch):
+    """Make `module.get_postgres_cursor` yield `cursor`"""
+    def patch(module, cursor):
+        @contextmanager
+        def get_postgres_cursor(*args, **kwargs):
+            yield cursor
+        monkeypatch.setattr(module, 'get_postgres_cursor', get_postgres_cursor)
+        return cursor
+    return patch
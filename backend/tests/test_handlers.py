@@ -0,0 +1,146 @@
+"""
+Handlers run against in-memory repositories: `get_repositories` is
+overridden, so requests go through the real routes without a database
+"""
+
+import uuid
+from datetime import datetime
+
+import pytest
+from fastapi import FastAPI
+from fastapi.testclient import TestClient
+
+from fastapi_app.dependencies import get_current_user, get_repositories
+from fastapi_app.routers import auth, interactions
+from shared.auth import hash_password
+from shared.repositories import TenantScope, in_memory_repositories
+from shared.sessions import SessionStoreUnavailable
+
+PASSWORD = 'correct horse battery'
+
+READER = {
+    'id': str(uuid.uuid4()), 'username': 'reader', 'email': 'reader@newsroom.org', 'role': 'reader',
+    'password_hash': hash_password(PASSWORD), 'is_active': True, 'verification_status': False,
+    'reputation_score': 0.0, 'profile_data': {}, 'preferences': {}, 'wallets': [],
+    'created_at': datetime(2026, 1, 5), 'updated_at': datetime(2026, 1, 5), 'last_active': datetime(2026, 1, 5),
+}
+ARTICLE = {
+    'id': str(uuid.uuid4()), 'title': 'Council budget', 'content': 'Where the money goes', 'category': 'politics',
+    'author_id': str(uuid.uuid4()), 'status': 'published', 'like_count': 0, 'view_count': 0, 'share_count': 0,
+    'comment_count': 0, 'reaction_counts': {}, 'clap_count': 0,
+}
+
+
+@pytest.fixture
+def repos():
+    return in_memory_repositories([READER], [ARTICLE])
+
+
+def client_for(repos):
+    app = FastAPI()
+    app.include_router(auth.router, prefix="/api/v1/auth")
+    app.include_router(interactions.router, prefix="/api/v1/interactions")
+    app.dependency_overrides[get_repositories] = lambda: repos
+    app.dependency_overrides[get_current_user] = lambda: READER
+    return TestClient(app)
+
+
+@pytest.fixture
+def client(repos):
+    return client_for(repos)
+
+
+# Interactions
+def test_liking_twice_takes_the_like_back(client, repos):
+    liked = client.post(f"/api/v1/interactions/{ARTICLE['id']}/like")
+
+    assert liked.status_code == 200
+    assert liked.json()['liked'] is True
+    assert repos.interactions.has(READER['id'], ARTICLE['id'], 'like')
+    assert repos.articles.get(ARTICLE['id'])['like_count'] == 1
+
+    unliked = client.post(f"/api/v1/interactions/{ARTICLE['id']}/like")
+
+    assert unliked.json()['liked'] is False
+    assert not repos.interactions.has(READER['id'], ARTICLE['id'], 'like')
+    assert repos.articles.get(ARTICLE['id'])['like_count'] == 0
+
+
+def test_bookmarking_saves_the_article_and_records_it(client, repos):
+    response = client.post(f"/api/v1/interactions/{ARTICLE['id']}/bookmark")
+
+    assert response.json()['bookmarked'] is True
+    assert repos.interactions.is_saved(READER['id'], ARTICLE['id'])
+    assert repos.interactions.has(READER['id'], ARTICLE['id'], 'save')
+
+
+def test_interaction_status_reports_the_readers_interactions_and_counters(client):
+    client.post(f"/api/v1/interactions/{ARTICLE['id']}/like")
+    client.post(f"/api/v1/interactions/{ARTICLE['id']}/share", json={'platform': 'mastodon'})
+
+    response = client.get(f"/api/v1/interactions/{ARTICLE['id']}/status")
+
+    assert response.status_code == 200
+    body = response.json()
+    assert body['liked'] is True
+    assert body['bookmarked'] is False
+    assert body['stats']['likes'] == 1
+    assert body['stats']['shares'] == 1
+
+
+def test_interaction_status_of_another_publications_article_is_not_found():
+    other_publication = in_memory_repositories(
+        [READER], [{**ARTICLE, 'tenant_id': str(uuid.uuid4())}], TenantScope(str(uuid.uuid4()))
+    )
+
+    response = client_for(other_publication).get(f"/api/v1/interactions/{ARTICLE['id']}/status")
+
+    assert response.status_code == 404
+
+
+# Sign-in
+@pytest.fixture
+def sessions(monkeypatch):
+    """Token issuing and the security log, which need Redis and PostgreSQL"""
+    issued = []
+
+    def issue_session_token(user, ip_address, user_agent, session_id=None):
+        issued.append(user['id'])
+        return 'issued-token'
+
+    monkeypatch.setattr(auth, 'issue_session_token', issue_session_token)
+    monkeypatch.setattr(auth, 'record_security_event', lambda *args, **kwargs: None)
+    return issued
+
+
+def test_login_issues_a_token_for_the_right_password(client, repos, sessions):
+    response = client.post("/api/v1/auth/login", json={'email': READER['email'], 'password': PASSWORD})
+
+    assert response.status_code == 200
+    body = response.json()
+    assert body['access_token'] == 'issued-token'
+    assert body['user']['username'] == 'reader'
+    assert sessions == [READER['id']]
+    assert repos.users.get_by_id(READER['id'])['last_active'] > READER['last_active']
+
+
+@pytest.mark.parametrize('email, password', [
+    (READER['email'], 'wrong password'),
+    ('nobody@newsroom.org', PASSWORD),
+])
+def test_login_refuses_wrong_credentials(client, sessions, email, password):
+    response = client.post("/api/v1/auth/login", json={'email': email, 'password': password})
+
+    assert response.status_code == 401
+    assert sessions == []
+
+
+def test_login_is_unavailable_while_sessions_cant_be_recorded(client, sessions, monkeypatch):
+    def unavailable(*args, **kwargs):
+        raise SessionStoreUnavailable("Redis is down")
+    monkeypatch.setattr(auth, 'issue_session_token', unavailable)
+
+    response = client.post("/api/v1/auth/login", json={'email': READER['email'], 'password': PASSWORD})
+
+    assert response.status_code == 503
+    assert 'access_token' not in response.json()
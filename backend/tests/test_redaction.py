@@ -0,0 +1,164 @@
+"""
+Syndication redaction: sensitive fields never leave in partner payloads,
+API responses or logs
+"""
+
+import asyncio
+import json
+import logging
+import uuid
+from datetime import datetime, timezone
+
+import pytest
+from fastapi import HTTPException
+
+from shared.redaction import ALWAYS_REDACTED, EMAIL_REPLACEMENT, redact_payload, remove_path
+from shared.syndication import build_payload, get_syndicated_article, pull_delta
+from fastapi_app.routers import syndication as syndication_router
+
+# Values that must never reach a partner
+SECRETS = [
+    'internal-note-7f3a',
+    'editor-note-91bc',
+    'pending-correction-55d2',
+    'desk@newsroom.example',
+    'whistleblower@source.example',
+    '0xdeadbeefcafe',
+    'reporter@newsroom.example',
+]
+
+PARTNER = {
+    'id': str(uuid.uuid4()),
+    'allowed_licenses': ['cc-by-4.0'],
+    'commercial_use': False,
+    'redaction_policy': {'remove_fields': ['author.did_address']},
+}
+
+
+def article_row(**overrides):
+    now = datetime.now(timezone.utc)
+    row = {
+        'id': uuid.uuid4(),
+        'author_id': uuid.uuid4(),
+        'author_username': 'reporter',
+        'author_did_address': '0xdeadbeefcafe',
+        'anonymous_author': False,
+        'title': 'Council approves budget',
+        'summary': 'Questions to reporter@newsroom.example',
+        'content': '<p>Tips go to reporter@newsroom.example</p>',
+        'content_format': 'html',
+        'access_policy': None,
+        'status': 'published',
+        'license': 'cc-by-4.0',
+        'category': 'politics',
+        'tags': ['budget'],
+        'image_urls': [],
+        'metadata': {
+            'source': 'city hall',
+            'internal_notes': 'internal-note-7f3a',
+            'editor_notes': 'editor-note-91bc',
+            'pending_corrections': ['pending-correction-55d2'],
+            'contact_email': 'desk@newsroom.example',
+            'source_contacts': [{'name': 'A', 'email': 'whistleblower@source.example'}],
+            'byline_note': 'Reach the desk at desk@newsroom.example',
+        },
+        'published_at': now,
+        'updated_at': now,
+        'syndicated': True,
+    }
+    row.update(overrides)
+    return row
+
+
+def assert_no_leak(text):
+    for secret in SECRETS:
+        assert secret not in text, f"{secret!r} leaked"
+
+
+def serialized(payload):
+    return json.dumps(payload, default=str)
+
+
+class TestRedactPayload:
+    def test_always_redacted_fields_are_removed_without_a_policy(self):
+        payload = redact_payload(build_payload(article_row()), None)
+        for path in ALWAYS_REDACTED:
+            assert path.split('.', 1)[1] not in payload['metadata']
+        assert payload['metadata']['source'] == 'city hall'
+
+    def test_partner_policy_cannot_opt_back_into_baseline_fields(self):
+        policy = {'allowed_metadata_keys': ['internal_notes', 'contact_email', 'source']}
+        payload = redact_payload(build_payload(article_row()), policy)
+        assert payload['metadata'] == {'source': 'city hall'}
+
+    def test_policy_removes_its_fields(self):
+        payload = redact_payload(build_payload(article_row()), PARTNER['redaction_policy'])
+        assert 'did_address' not in payload['author']
+        assert payload['author']['username'] == 'reporter'
+
+    def test_emails_are_scrubbed_from_metadata_and_text(self):
+        payload = redact_payload(build_payload(article_row()), {})
+        assert payload['metadata']['byline_note'] == f"Reach the desk at {EMAIL_REPLACEMENT}"
+        assert EMAIL_REPLACEMENT in payload['summary']
+        assert EMAIL_REPLACEMENT in payload['content']
+
+    def test_metadata_emails_are_scrubbed_even_when_the_policy_keeps_text(self):
+        payload = redact_payload(build_payload(article_row()), {'scrub_emails': False})
+        assert 'reporter@newsroom.example' in payload['content']
+        assert 'desk@newsroom.example' not in serialized(payload['metadata'])
+
+    def test_does_not_mutate_the_input(self):
+        payload = build_payload(article_row())
+        redact_payload(payload, PARTNER['redaction_policy'])
+        assert payload['metadata']['internal_notes'] == 'internal-note-7f3a'
+        assert payload['author']['did_address'] == '0xdeadbeefcafe'
+
+    def test_invalid_policy_raises_instead_of_sending_unredacted(self):
+        with pytest.raises(ValueError):
+            redact_payload(build_payload(article_row()), {'remove_fields': ['id']})
+
+    def test_remove_path_wildcard(self):
+        payload = {'a': {'x': 1, 'y': 2}, 'b': [{'x': 3}, {'x': 4, 'z': 5}]}
+        remove_path(payload, '*.x')
+        assert payload == {'a': {'y': 2}, 'b': [{}, {'z': 5}]}
+
+
+class TestSyndicationPayloads:
+    def test_pull_delta_never_leaks(self, fake_cursor):
+        rows = [article_row(), article_row(syndicated=False)]
+        delta = pull_delta(fake_cursor(rows), PARTNER, None, 10)
+        assert len(delta['items']) == 1 and len(delta['removed']) == 1
+        assert_no_leak(serialized(delta))
+
+    def test_single_article_never_leaks(self, fake_cursor):
+        article = get_syndicated_article(fake_cursor(article_row()), PARTNER, str(uuid.uuid4()))
+        assert_no_leak(serialized(article))
+
+
+class TestSyndicationRoutes:
+    """Redaction holds all the way out of the API, and nothing sensitive is logged on the way"""
+
+    def test_pull_response_and_logs_never_leak(self, fake_cursor, patch_cursor, caplog):
+        patch_cursor(syndication_router, fake_cursor([article_row(), article_row()]))
+        with caplog.at_level(logging.DEBUG):
+            response = asyncio.run(syndication_router.pull_articles(delta_token=None, limit=10, partner=PARTNER))
+        assert len(response.items) == 2
+        assert_no_leak(response.model_dump_json())
+        assert_no_leak(caplog.text)
+
+    def test_article_response_and_logs_never_leak(self, fake_cursor, patch_cursor, caplog):
+        row = article_row()
+        patch_cursor(syndication_router, fake_cursor(row))
+        with caplog.at_level(logging.DEBUG):
+            response = asyncio.run(syndication_router.get_article(str(row['id']), partner=PARTNER))
+        assert_no_leak(serialized(response))
+        assert_no_leak(caplog.text)
+
+    def test_invalid_policy_withholds_the_article_without_logging_it(self, fake_cursor, patch_cursor, caplog):
+        partner = {**PARTNER, 'redaction_policy': {'remove_fields': ['license']}}
+        patch_cursor(syndication_router, fake_cursor(article_row()))
+        with caplog.at_level(logging.DEBUG), pytest.raises(HTTPException) as error:
+            asyncio.run(syndication_router.get_article(str(uuid.uuid4()), partner=partner))
+        assert error.value.status_code == 500
+        assert_no_leak(str(error.value.detail))
+        assert_no_leak(caplog.text)
@@ -0,0 +1,70 @@
+// SPDX-License-Identifier: MIT
+pragma solidity ^0.8.19;
+
+import "@openzeppelin/contracts/access/Ownable.sol";
+
+/**
+ * @title ArticleAnchor
+ * @dev Records Merkle roots of batches of article hashes
+ * The platform anchors one root per batch instead of one transaction per article;
+ * anyone holding an inclusion proof can check it against an anchored root
+ */
+contract ArticleAnchor is Ownable {
+
+    struct Anchor {
+        uint64 timestamp;
+        uint32 leafCount;
+    }
+
+    // Merkle root => when it was anchored and how many articles it covers
+    mapping(bytes32 => Anchor) public anchors;
+
+    uint256 public totalRoots;
+
+    event RootAnchored(bytes32 indexed root, uint32 leafCount, string batchId, uint64 timestamp);
+
+    /**
+     * @dev Anchor the root of a batch
+     * @param root Merkle root of the batch
+     * @param leafCount Number of articles in the batch
+     * @param batchId Platform identifier of the batch
+     */
+    function anchorRoot(bytes32 root, uint32 leafCount, string calldata batchId) external onlyOwner {
+        require(root != bytes32(0), "Empty root");
+        require(leafCount > 0, "Empty batch");
+        require(anchors[root].timestamp == 0, "Root already anchored");
+
+        anchors[root] = Anchor(uint64(block.timestamp), leafCount);
+        totalRoots++;
+
+        emit RootAnchored(root, leafCount, batchId, uint64(block.timestamp));
+    }
+
+    /**
+     * @dev Check that an article hash is included under an anchored root
+     * Leaves are sha256(0x00 || articleHash) and nodes sha256(0x01 || left || right)
+     * @param root Anchored Merkle root
+     * @param articleHash SHA-256 of the article's canonical JSON
+     * @param proof Sibling hashes from the leaf up to the root
+     * @param siblingOnLeft Whether each sibling is the left child
+     */
+    function verifyInclusion(
+        bytes32 root,
+        bytes32 articleHash,
+        bytes32[] calldata proof,
+        bool[] calldata siblingOnLeft
+    ) external view returns (bool) {
+        require(proof.length == siblingOnLeft.length, "Proof length mismatch");
+        if (anchors[root].timestamp == 0) {
+            return false;
+        }
+
+        bytes32 current = sha256(abi.encodePacked(bytes1(0x00), articleHash));
+        for (uint256 i = 0; i < proof.length; i++) {
+            current = siblingOnLeft[i]
+                ? sha256(abi.encodePacked(bytes1(0x01), proof[i], current))
+                : sha256(abi.encodePacked(bytes1(0x01), current, proof[i]));
+        }
+        return current == root;
+    }
+}
@@ -0,0 +1,84 @@
+// SPDX-License-Identifier: MIT
+pragma solidity ^0.8.19;
+
+import "@openzeppelin/contracts/access/Ownable.sol";
+
+/**
+ * @title GovernanceMirror
+ * @dev Records the final tally of platform governance proposals
+ * Each closed proposal is mirrored once with its vote counts and the Merkle root
+ * of its votes, so any voter can prove their vote was counted
+ */
+contract GovernanceMirror is Ownable {
+
+    struct Result {
+        uint64 timestamp;
+        uint32 yes;
+        uint32 no;
+        uint32 abstain;
+        bool passed;
+        bytes32 votesRoot;
+    }
+
+    // Proposal id (UUID, left-aligned) => its final tally
+    mapping(bytes32 => Result) public results;
+
+    uint256 public totalResults;
+
+    event ResultRecorded(bytes32 indexed proposalId, bytes32 votesRoot, uint32 yes, uint32 no, uint32 abstain, bool passed);
+
+    /**
+     * @dev Record the final tally of a proposal
+     * @param proposalId Platform identifier of the proposal
+     * @param votesRoot Merkle root over the proposal's vote hashes (zero if nobody voted)
+     * @param yes Votes in favour
+     * @param no Votes against
+     * @param abstain Abstentions
+     * @param passed Whether the proposal passed under the platform's quorum and threshold
+     */
+    function recordResult(
+        bytes32 proposalId,
+        bytes32 votesRoot,
+        uint32 yes,
+        uint32 no,
+        uint32 abstain,
+        bool passed
+    ) external onlyOwner {
+        require(proposalId != bytes32(0), "Empty proposal id");
+        require(results[proposalId].timestamp == 0, "Result already recorded");
+
+        results[proposalId] = Result(uint64(block.timestamp), yes, no, abstain, passed, votesRoot);
+        totalResults++;
+
+        emit ResultRecorded(proposalId, votesRoot, yes, no, abstain, passed);
+    }
+
+    /**
+     * @dev Check that a vote hash is included under a proposal's recorded votes root
+     * Leaves are sha256(0x00 || voteHash) and nodes sha256(0x01 || left || right)
+     * @param proposalId Platform identifier of the proposal
+     * @param voteHash SHA-256 of the vote's canonical JSON
+     * @param proof Sibling hashes from the leaf up to the root
+     * @param siblingOnLeft Whether each sibling is the left child
+     */
+    function verifyVote(
+        bytes32 proposalId,
+        bytes32 voteHash,
+        bytes32[] calldata proof,
+        bool[] calldata siblingOnLeft
+    ) external view returns (bool) {
+        require(proof.length == siblingOnLeft.length, "Proof length mismatch");
+        Result storage result = results[proposalId];
+        if (result.timestamp == 0 || result.votesRoot == bytes32(0)) {
+            return false;
+        }
+
+        bytes32 current = sha256(abi.encodePacked(bytes1(0x00), voteHash));
+        for (uint256 i = 0; i < proof.length; i++) {
+            current = siblingOnLeft[i]
+                ? sha256(abi.encodePacked(bytes1(0x01), proof[i], current))
+                : sha256(abi.encodePacked(bytes1(0x01), current, proof[i]));
+        }
+        return current == result.votesRoot;
+    }
+}
@@ -0,0 +1,53 @@
+const { ethers } = require("hardhat");
+const fs = require("fs");
+const path = require("path");
+
+async function main() {
+  console.log("Starting deployment of ArticleAnchor...");
+
+  const [deployer] = await ethers.getSigners();
+  console.log("Deploying contract with account:", deployer.address);
+
+  const ArticleAnchor = await ethers.getContractFactory("ArticleAnchor");
+  const articleAnchor = await ArticleAnchor.deploy();
+  await articleAnchor.waitForDeployment();
+  const address = await articleAnchor.getAddress();
+  console.log("ArticleAnchor deployed to:", address);
+
+  const network = await ethers.provider.getNetwork();
+  const deploymentInfo = {
+    network: network.name,
+    chainId: network.chainId.toString(),
+    deployer: deployer.address,
+    timestamp: new Date().toISOString(),
+    contracts: {
+      ArticleAnchor: {
+        address,
+        transactionHash: articleAnchor.deploymentTransaction().hash
+      }
+    }
+  };
+
+  const deploymentsDir = path.join(__dirname, "../deployments");
+  if (!fs.existsSync(deploymentsDir)) {
+    fs.mkdirSync(deploymentsDir, { recursive: true });
+  }
+  const deploymentFile = path.join(deploymentsDir, `${network.name}-anchor-latest.json`);
+  fs.writeFileSync(deploymentFile, JSON.stringify(deploymentInfo, null, 2));
+  console.log(`Deployment info saved to: ${deploymentFile}`);
+
+  console.log("\nSet these in the backend .env (the key must belong to the deployer, the contract owner):");
+  console.log(`ANCHOR_CONTRACT_ADDRESS=${address}`);
+  console.log(`ANCHOR_CHAIN_ID=${network.chainId}`);
+}
+
+if (require.main === module) {
+  main()
+    .then(() => process.exit(0))
+    .catch((error) => {
+      console.error(error);
+      process.exit(1);
+    });
+}
+
+module.exports = main;
@@ -0,0 +1,53 @@
+const { ethers } = require("hardhat");
+const fs = require("fs");
+const path = require("path");
+
+async function main() {
+  console.log("Starting deployment of GovernanceMirror...");
+
+  const [deployer] = await ethers.getSigners();
+  console.log("Deploying contract with account:", deployer.address);
+
+  const GovernanceMirror = await ethers.getContractFactory("GovernanceMirror");
+  const governanceMirror = await GovernanceMirror.deploy();
+  await governanceMirror.waitForDeployment();
+  const address = await governanceMirror.getAddress();
+  console.log("GovernanceMirror deployed to:", address);
+
+  const network = await ethers.provider.getNetwork();
+  const deploymentInfo = {
+    network: network.name,
+    chainId: network.chainId.toString(),
+    deployer: deployer.address,
+    timestamp: new Date().toISOString(),
+    contracts: {
+      GovernanceMirror: {
+        address,
+        transactionHash: governanceMirror.deploymentTransaction().hash
+      }
+    }
+  };
+
+  const deploymentsDir = path.join(__dirname, "../deployments");
+  if (!fs.existsSync(deploymentsDir)) {
+    fs.mkdirSync(deploymentsDir, { recursive: true });
+  }
+  const deploymentFile = path.join(deploymentsDir, `${network.name}-governance-latest.json`);
+  fs.writeFileSync(deploymentFile, JSON.stringify(deploymentInfo, null, 2));
+  console.log(`Deployment info saved to: ${deploymentFile}`);
+
+  console.log("\nSet these in the backend .env (the key must belong to the deployer, the contract owner):");
+  console.log(`GOVERNANCE_CONTRACT_ADDRESS=${address}`);
+  console.log(`GOVERNANCE_CHAIN_ID=${network.chainId}`);
+}
+
+if (require.main === module) {
+  main()
+    .then(() => process.exit(0))
+    .catch((error) => {
+      console.error(error);
+      process.exit(1);
+    });
+}
+
+module.exports = main;
@@ -414,6 +414,42 @@ db.createCollection("model_performance", {
   }
 });
 
+// Editorial comments and suggestions on drafts
+db.createCollection("draft_comments", {
+  validator: {
+    $jsonSchema: {
+      bsonType: "object",
+      required: ["article_id", "user_id", "kind", "anchor", "body", "status"],
+      properties: {
+        _id: { bsonType: "objectId" },
+        article_id: { bsonType: "string" },
+        user_id: { bsonType: "string" },
+        username: { bsonType: "string" },
+        kind: { bsonType: "string", enum: ["comment", "suggestion"] },
+        anchor: {
+          bsonType: "object",
+          required: ["field", "start", "end", "quote"],
+          properties: {
+            field: { bsonType: "string", enum: ["title", "summary", "content"] },
+            start: { bsonType: "int" },
+            end: { bsonType: "int" },
+            quote: { bsonType: "string" }
+          }
+        },
+        body: { bsonType: "string", minLength: 1, maxLength: 2000 },
+        suggestion: { bsonType: ["string", "null"] },
+        status: { bsonType: "string", enum: ["open", "resolved", "accepted", "rejected"] },
+        replies: { bsonType: "array" },
+        resolved_by: { bsonType: ["string", "null"] },
+        resolved_at: { bsonType: ["date", "null"] },
+        revision_number: { bsonType: ["int", "null"] },
+        created_at: { bsonType: "date" },
+        updated_at: { bsonType: "date" }
+      }
+    }
+  }
+});
+
 // Create indexes for performance optimization
 // Users indexes
 db.users.createIndex({ "email": 1 }, { unique: true });
@@ -464,6 +500,9 @@ db.comments.createIndex({ "article_id": 1, "created_at": -1 });
 db.comments.createIndex({ "user_id": 1 });
 db.comments.createIndex({ "parent_comment_id": 1 });
 
+// Draft comments indexes
+db.draft_comments.createIndex({ "article_id": 1, "status": 1, "created_at": 1 });
+
 // DID identities indexes
 db.did_identities.createIndex({ "user_id": 1 });
 db.did_identities.createIndex({ "did_address": 1 }, { unique: true });
@@ -0,0 +1,13 @@
+-- Runtime platform settings
+-- Key/value JSON documents read through the shared settings service
+
+CREATE TABLE IF NOT EXISTS platform_settings (
+    key VARCHAR(100) PRIMARY KEY,
+    value JSONB NOT NULL DEFAULT '{}',
+    updated_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE OR REPLACE TRIGGER update_platform_settings_updated_at BEFORE UPDATE ON platform_settings
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,16 @@
+-- Category subscriptions
+-- Author follows live in user_follows; readers can also subscribe to whole categories
+
+CREATE TABLE IF NOT EXISTS category_follows (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    category VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(user_id, category)
+);
+
+CREATE INDEX IF NOT EXISTS idx_category_follows_user ON category_follows(user_id);
+CREATE INDEX IF NOT EXISTS idx_category_follows_category ON category_follows(category);
+
+-- Keyset pagination over followed authors' articles
+CREATE INDEX IF NOT EXISTS idx_articles_author_published ON articles(author_id, published_at DESC, id DESC);
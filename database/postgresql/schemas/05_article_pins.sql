@@ -0,0 +1,26 @@
+-- Editorial pinning and featuring
+-- Pins place an article at a fixed position on the home feed or a category page for a time window
+
+CREATE TABLE IF NOT EXISTS article_pins (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    article_id UUID NOT NULL REFERENCES articles(id) ON DELETE CASCADE,
+    scope VARCHAR(20) NOT NULL CHECK (scope IN ('home', 'category')),
+    category VARCHAR(100), -- Required when scope is 'category'
+    pin_type VARCHAR(20) NOT NULL DEFAULT 'pinned' CHECK (pin_type IN ('pinned', 'featured')),
+    position INTEGER NOT NULL DEFAULT 0,
+    starts_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    ends_at TIMESTAMP WITH TIME ZONE, -- NULL pins never expire
+    pinned_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    note TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CHECK (scope = 'home' OR category IS NOT NULL),
+    CHECK (ends_at IS NULL OR ends_at > starts_at)
+);
+
+CREATE INDEX IF NOT EXISTS idx_article_pins_scope ON article_pins(scope, category, position);
+CREATE INDEX IF NOT EXISTS idx_article_pins_window ON article_pins(starts_at, ends_at);
+CREATE INDEX IF NOT EXISTS idx_article_pins_article ON article_pins(article_id);
+
+CREATE OR REPLACE TRIGGER update_article_pins_updated_at BEFORE UPDATE ON article_pins
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
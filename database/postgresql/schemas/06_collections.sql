@@ -0,0 +1,55 @@
+-- Editorial collections and curated topic hubs
+-- Collections group articles into ordered sections, filled manually or by tag/category rules
+
+CREATE TABLE IF NOT EXISTS collections (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    slug VARCHAR(150) UNIQUE NOT NULL,
+    title VARCHAR(300) NOT NULL,
+    description TEXT,
+    cover_image_url VARCHAR(1000),
+    is_published BOOLEAN DEFAULT FALSE,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS collection_sections (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    collection_id UUID NOT NULL REFERENCES collections(id) ON DELETE CASCADE,
+    title VARCHAR(300) NOT NULL,
+    description TEXT,
+    position INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS collection_rules (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    collection_id UUID NOT NULL REFERENCES collections(id) ON DELETE CASCADE,
+    section_id UUID REFERENCES collection_sections(id) ON DELETE SET NULL,
+    rule_type VARCHAR(20) NOT NULL CHECK (rule_type IN ('tag', 'category')),
+    value VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(collection_id, rule_type, value)
+);
+
+CREATE TABLE IF NOT EXISTS collection_articles (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    collection_id UUID NOT NULL REFERENCES collections(id) ON DELETE CASCADE,
+    article_id UUID NOT NULL REFERENCES articles(id) ON DELETE CASCADE,
+    section_id UUID REFERENCES collection_sections(id) ON DELETE SET NULL,
+    position INTEGER NOT NULL DEFAULT 0,
+    source VARCHAR(20) NOT NULL DEFAULT 'manual' CHECK (source IN ('manual', 'rule')),
+    rule_id UUID REFERENCES collection_rules(id) ON DELETE SET NULL,
+    added_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    added_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(collection_id, article_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_collections_published ON collections(is_published, updated_at DESC);
+CREATE INDEX IF NOT EXISTS idx_collection_sections_collection ON collection_sections(collection_id, position);
+CREATE INDEX IF NOT EXISTS idx_collection_rules_lookup ON collection_rules(rule_type, value);
+CREATE INDEX IF NOT EXISTS idx_collection_articles_collection ON collection_articles(collection_id, section_id, position);
+CREATE INDEX IF NOT EXISTS idx_collection_articles_article ON collection_articles(article_id);
+
+CREATE OR REPLACE TRIGGER update_collections_updated_at BEFORE UPDATE ON collections
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
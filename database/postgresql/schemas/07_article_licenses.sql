@@ -0,0 +1,14 @@
+-- Per-article license and reuse terms
+-- Aggregators filter on license to fetch only content they may republish
+
+ALTER TABLE articles ADD COLUMN IF NOT EXISTS license VARCHAR(30) NOT NULL DEFAULT 'all-rights-reserved';
+ALTER TABLE articles ADD COLUMN IF NOT EXISTS license_terms TEXT;
+
+ALTER TABLE articles DROP CONSTRAINT IF EXISTS articles_license_check;
+ALTER TABLE articles ADD CONSTRAINT articles_license_check CHECK (license IN (
+    'cc0-1.0', 'cc-by-4.0', 'cc-by-sa-4.0', 'cc-by-nd-4.0',
+    'cc-by-nc-4.0', 'cc-by-nc-sa-4.0', 'cc-by-nc-nd-4.0',
+    'all-rights-reserved', 'custom'
+));
+
+CREATE INDEX IF NOT EXISTS idx_articles_license ON articles(license, published_at DESC) WHERE status = 'published';
@@ -0,0 +1,32 @@
+-- Licensed partner syndication
+-- Partners pull full article payloads with an API key; usage is counted per day and endpoint
+
+CREATE TABLE IF NOT EXISTS syndication_partners (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name VARCHAR(200) NOT NULL,
+    contact_email VARCHAR(255),
+    api_key_hash VARCHAR(64) UNIQUE NOT NULL, -- SHA-256 of the issued key
+    api_key_prefix VARCHAR(16) NOT NULL, -- Shown to admins to identify a key
+    allowed_licenses TEXT[] DEFAULT '{}', -- Empty means every reusable license
+    commercial_use BOOLEAN DEFAULT FALSE,
+    is_active BOOLEAN DEFAULT TRUE,
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS syndication_usage (
+    partner_id UUID NOT NULL REFERENCES syndication_partners(id) ON DELETE CASCADE,
+    usage_date DATE NOT NULL DEFAULT CURRENT_DATE,
+    endpoint VARCHAR(100) NOT NULL,
+    request_count INTEGER NOT NULL DEFAULT 0,
+    article_count INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (partner_id, usage_date, endpoint)
+);
+
+CREATE INDEX IF NOT EXISTS idx_articles_updated_id ON articles(updated_at, id);
+CREATE INDEX IF NOT EXISTS idx_syndication_usage_date ON syndication_usage(usage_date);
+
+CREATE OR REPLACE TRIGGER update_syndication_partners_updated_at BEFORE UPDATE ON syndication_partners
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
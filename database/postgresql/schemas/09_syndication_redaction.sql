@@ -0,0 +1,3 @@
+-- Per-partner redaction policies applied by the syndication serializer
+
+ALTER TABLE syndication_partners ADD COLUMN IF NOT EXISTS redaction_policy JSONB DEFAULT '{}';
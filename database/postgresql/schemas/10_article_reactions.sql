@@ -0,0 +1,11 @@
+-- Configurable article reactions (insightful, important, questionable, ...)
+-- Reactions are typed interactions; the reaction set itself lives in platform_settings
+
+ALTER TYPE interaction_type ADD VALUE IF NOT EXISTS 'reaction';
+
+ALTER TABLE user_interactions ADD COLUMN IF NOT EXISTS reaction VARCHAR(50);
+ALTER TABLE articles ADD COLUMN IF NOT EXISTS reaction_counts JSONB DEFAULT '{}';
+
+-- One reaction of each kind per reader and article
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_interactions_reaction
+    ON user_interactions(user_id, article_id, reaction) WHERE reaction IS NOT NULL;
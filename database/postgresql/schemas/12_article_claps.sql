@@ -0,0 +1,27 @@
+-- Medium-style claps: up to 50 per reader and article
+-- article_claps holds each reader's running total; article_clap_events keeps every send for analytics
+
+CREATE TABLE IF NOT EXISTS article_claps (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    article_id UUID NOT NULL REFERENCES articles(id) ON DELETE CASCADE,
+    clap_count INTEGER NOT NULL CHECK (clap_count BETWEEN 1 AND 50),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, article_id)
+);
+
+CREATE TABLE IF NOT EXISTS article_clap_events (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    article_id UUID NOT NULL REFERENCES articles(id) ON DELETE CASCADE,
+    claps INTEGER NOT NULL CHECK (claps > 0),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE articles ADD COLUMN IF NOT EXISTS clap_count BIGINT DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_article_claps_article ON article_claps(article_id);
+CREATE INDEX IF NOT EXISTS idx_article_clap_events_article ON article_clap_events(article_id, created_at DESC);
+
+CREATE OR REPLACE TRIGGER update_article_claps_updated_at BEFORE UPDATE ON article_claps
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
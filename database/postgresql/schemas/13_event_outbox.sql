@@ -0,0 +1,17 @@
+-- Transactional outbox for domain events
+-- Events are written with the change that caused them and relayed to the configured broker
+
+CREATE TABLE IF NOT EXISTS event_outbox (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    event_type VARCHAR(100) NOT NULL,
+    aggregate_id VARCHAR(100),
+    payload JSONB NOT NULL,
+    occurred_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    published_at TIMESTAMP WITH TIME ZONE,
+    claimed_until TIMESTAMP WITH TIME ZONE, -- Lease held by the relay currently publishing the event
+    publish_attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_outbox_unpublished ON event_outbox(occurred_at) WHERE published_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_event_outbox_type ON event_outbox(event_type, occurred_at DESC);
@@ -0,0 +1,37 @@
+-- Time-boxed author Q&A sessions on articles
+-- A session is open between opens_at and closes_at unless the author closes it early
+
+CREATE TABLE IF NOT EXISTS qa_sessions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    article_id UUID NOT NULL REFERENCES articles(id) ON DELETE CASCADE,
+    author_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    title VARCHAR(300),
+    opens_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    closes_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    closed_at TIMESTAMP WITH TIME ZONE, -- Set when the author closes the session early
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CHECK (closes_at > opens_at)
+);
+
+CREATE TABLE IF NOT EXISTS qa_questions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    session_id UUID NOT NULL REFERENCES qa_sessions(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    body TEXT NOT NULL,
+    upvote_count INTEGER NOT NULL DEFAULT 0,
+    answer TEXT,
+    answered_at TIMESTAMP WITH TIME ZONE,
+    is_hidden BOOLEAN DEFAULT FALSE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS qa_question_votes (
+    question_id UUID NOT NULL REFERENCES qa_questions(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (question_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_qa_sessions_article ON qa_sessions(article_id, opens_at DESC);
+CREATE INDEX IF NOT EXISTS idx_qa_questions_session ON qa_questions(session_id, upvote_count DESC);
+CREATE INDEX IF NOT EXISTS idx_qa_questions_answered ON qa_questions(session_id, answered_at) WHERE answer IS NOT NULL;
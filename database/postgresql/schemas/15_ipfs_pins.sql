@@ -0,0 +1,4 @@
+-- Content identifiers for articles pinned to IPFS by the background job workers
+
+ALTER TABLE articles ADD COLUMN IF NOT EXISTS ipfs_cid VARCHAR(100);
+ALTER TABLE articles ADD COLUMN IF NOT EXISTS ipfs_pinned_at TIMESTAMP WITH TIME ZONE;
@@ -0,0 +1,38 @@
+-- Reader-submitted corrections and article revision history
+
+CREATE TABLE IF NOT EXISTS article_revisions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    article_id UUID REFERENCES articles(id) ON DELETE CASCADE,
+    revision_number INTEGER NOT NULL,
+    title VARCHAR(500) NOT NULL,
+    summary TEXT,
+    content TEXT NOT NULL,
+    edited_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    change_note TEXT,
+    correction_id UUID,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(article_id, revision_number)
+);
+
+CREATE TABLE IF NOT EXISTS article_corrections (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    article_id UUID REFERENCES articles(id) ON DELETE CASCADE,
+    submitted_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    kind VARCHAR(20) NOT NULL DEFAULT 'typo' CHECK (kind IN ('typo', 'factual', 'clarity', 'other')),
+    passage TEXT NOT NULL,
+    suggestion TEXT NOT NULL,
+    note TEXT,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'accepted', 'dismissed')),
+    reviewed_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    reviewed_at TIMESTAMP WITH TIME ZONE,
+    review_note TEXT,
+    revision_id UUID REFERENCES article_revisions(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Contributor credit for accepted corrections
+ALTER TABLE users ADD COLUMN IF NOT EXISTS accepted_corrections INTEGER DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_article_revisions_article ON article_revisions(article_id, revision_number DESC);
+CREATE INDEX IF NOT EXISTS idx_article_corrections_article_status ON article_corrections(article_id, status);
+CREATE INDEX IF NOT EXISTS idx_article_corrections_submitted_by ON article_corrections(submitted_by, created_at DESC);
@@ -0,0 +1,29 @@
+-- Organizations on custom domains and their branding
+-- The public branding endpoint resolves an organization from the request's Host header
+
+CREATE TABLE IF NOT EXISTS organizations (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    slug VARCHAR(100) UNIQUE NOT NULL,
+    name VARCHAR(200) NOT NULL,
+    custom_domain VARCHAR(255) UNIQUE, -- Lowercase host name without port
+    is_active BOOLEAN DEFAULT TRUE,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS organization_branding (
+    organization_id UUID PRIMARY KEY REFERENCES organizations(id) ON DELETE CASCADE,
+    logo_url TEXT,
+    favicon_url TEXT,
+    colors JSONB DEFAULT '{}', -- primary, secondary, accent, background, text as #rrggbb
+    footer_links JSONB DEFAULT '[]', -- [{"label": ..., "url": ...}]
+    updated_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE OR REPLACE TRIGGER update_organizations_updated_at BEFORE UPDATE ON organizations
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+CREATE OR REPLACE TRIGGER update_organization_branding_updated_at BEFORE UPDATE ON organization_branding
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
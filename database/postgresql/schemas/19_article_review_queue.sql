@@ -0,0 +1,18 @@
+-- Moderator review before publishing, required by the instance policy for certain tags
+-- An article stays a draft while its review is pending; approving it publishes it
+
+CREATE TABLE IF NOT EXISTS article_reviews (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    article_id UUID NOT NULL REFERENCES articles(id) ON DELETE CASCADE,
+    reason TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending', -- pending, approved, rejected
+    requested_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    reviewed_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    review_note TEXT,
+    reviewed_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- At most one open review per article
+CREATE UNIQUE INDEX IF NOT EXISTS idx_article_reviews_pending ON article_reviews(article_id) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_article_reviews_status ON article_reviews(status, created_at);
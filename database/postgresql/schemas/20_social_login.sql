@@ -0,0 +1,20 @@
+-- Social login identities (Google, GitHub) linked to platform accounts
+-- Accounts created through social login have no password until the user sets one
+
+ALTER TABLE users ALTER COLUMN password_hash DROP NOT NULL;
+
+CREATE TABLE IF NOT EXISTS user_auth_providers (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider VARCHAR(20) NOT NULL, -- google, github
+    provider_user_id VARCHAR(255) NOT NULL, -- Stable subject id at the provider
+    email VARCHAR(255),
+    email_verified BOOLEAN DEFAULT FALSE,
+    profile JSONB DEFAULT '{}', -- Display name, avatar and login as last reported by the provider
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    last_login_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(provider, provider_user_id),
+    UNIQUE(user_id, provider)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_auth_providers_user ON user_auth_providers(user_id);
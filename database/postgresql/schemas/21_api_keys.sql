@@ -0,0 +1,21 @@
+-- API keys for service-to-service calls (ML service, crawlers, partners)
+-- Keys carry scopes; rotating a key issues a successor and lets the old one expire after a grace period
+
+CREATE TABLE IF NOT EXISTS api_keys (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name VARCHAR(200) NOT NULL,
+    description TEXT,
+    key_hash VARCHAR(64) UNIQUE NOT NULL, -- SHA-256 of the issued key
+    key_prefix VARCHAR(16) NOT NULL, -- Shown to admins to identify a key
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    is_active BOOLEAN DEFAULT TRUE,
+    expires_at TIMESTAMP WITH TIME ZONE, -- NULL never expires
+    rotated_from UUID REFERENCES api_keys(id) ON DELETE SET NULL,
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    last_used_ip VARCHAR(45),
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_keys_active ON api_keys(is_active, created_at);
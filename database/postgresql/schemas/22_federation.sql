@@ -0,0 +1,49 @@
+-- Federation with peer instances
+-- Articles pulled from a peer's manifest are stored as local articles linked back to their origin;
+-- each peer has its own refresh interval and retention, and removed content leaves a tombstone
+
+CREATE TABLE IF NOT EXISTS federation_peers (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    domain VARCHAR(255) UNIQUE NOT NULL,
+    base_url VARCHAR(500) NOT NULL, -- Where the peer's API is served
+    name VARCHAR(200),
+    is_active BOOLEAN DEFAULT TRUE,
+    refresh_interval_minutes INTEGER NOT NULL DEFAULT 60, -- How often the manifest is pulled and revalidated
+    retention_days INTEGER, -- NULL keeps content for as long as the origin lists it
+    last_synced_at TIMESTAMP WITH TIME ZONE,
+    last_sync_error TEXT,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS federated_articles (
+    article_id UUID PRIMARY KEY REFERENCES articles(id) ON DELETE CASCADE,
+    peer_id UUID NOT NULL REFERENCES federation_peers(id) ON DELETE CASCADE,
+    remote_id VARCHAR(100) NOT NULL, -- Article id on the origin
+    origin_url VARCHAR(1000) NOT NULL,
+    content_hash VARCHAR(64) NOT NULL, -- As listed in the origin's manifest
+    remote_published_at TIMESTAMP WITH TIME ZONE,
+    remote_updated_at TIMESTAMP WITH TIME ZONE,
+    fetched_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    last_verified_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP, -- Last seen in the origin's manifest
+    UNIQUE(peer_id, remote_id)
+);
+
+-- Public record of federated content this instance removed, and why
+CREATE TABLE IF NOT EXISTS federation_tombstones (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    peer_id UUID REFERENCES federation_peers(id) ON DELETE SET NULL,
+    peer_domain VARCHAR(255) NOT NULL,
+    remote_id VARCHAR(100) NOT NULL,
+    origin_url VARCHAR(1000),
+    title VARCHAR(500),
+    content_hash VARCHAR(64),
+    reason VARCHAR(20) NOT NULL, -- retracted, expired, policy, peer_removed
+    detail TEXT,
+    removed_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_federated_articles_peer ON federated_articles(peer_id, remote_published_at);
+CREATE INDEX IF NOT EXISTS idx_federation_tombstones_removed ON federation_tombstones(removed_at DESC);
+CREATE INDEX IF NOT EXISTS idx_federation_tombstones_remote ON federation_tombstones(peer_id, remote_id);
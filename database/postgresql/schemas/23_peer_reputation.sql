@@ -0,0 +1,23 @@
+-- Federation peer reputation
+-- Quality signals per peer feed a reputation score; low scores throttle or pause ingestion unless an admin overrides
+
+ALTER TABLE federation_peers ADD COLUMN IF NOT EXISTS reputation_score DECIMAL(4,3) DEFAULT 1.000;
+ALTER TABLE federation_peers ADD COLUMN IF NOT EXISTS ingestion_state VARCHAR(20) DEFAULT 'normal'; -- normal, throttled, paused (from the score)
+ALTER TABLE federation_peers ADD COLUMN IF NOT EXISTS reputation_override VARCHAR(20); -- Admin-pinned state; NULL follows the score
+ALTER TABLE federation_peers ADD COLUMN IF NOT EXISTS reputation_updated_at TIMESTAMP WITH TIME ZONE;
+
+CREATE TABLE IF NOT EXISTS federation_peer_signals (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    peer_id UUID NOT NULL REFERENCES federation_peers(id) ON DELETE CASCADE,
+    signal VARCHAR(30) NOT NULL, -- accepted_article, spam_report, verification_failure
+    article_id UUID REFERENCES articles(id) ON DELETE SET NULL,
+    remote_id VARCHAR(100),
+    reported_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    detail TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_federation_peer_signals_peer ON federation_peer_signals(peer_id, created_at DESC);
+-- One spam report per reader per article
+CREATE UNIQUE INDEX IF NOT EXISTS idx_federation_peer_signals_spam
+    ON federation_peer_signals(peer_id, remote_id, reported_by) WHERE signal = 'spam_report';
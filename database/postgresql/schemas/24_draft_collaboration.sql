@@ -0,0 +1,22 @@
+-- Collaborative draft editing
+-- A draft being edited together is a Yjs document: a compacted snapshot plus the updates received since,
+-- which are folded into the snapshot periodically and materialized into the article on save
+
+CREATE TABLE IF NOT EXISTS draft_crdt_snapshots (
+    article_id UUID PRIMARY KEY REFERENCES articles(id) ON DELETE CASCADE,
+    state BYTEA NOT NULL, -- Yjs document encoded as a single update
+    updates_compacted BIGINT NOT NULL DEFAULT 0,
+    materialized_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS draft_crdt_updates (
+    id BIGSERIAL PRIMARY KEY,
+    article_id UUID NOT NULL REFERENCES articles(id) ON DELETE CASCADE,
+    update_data BYTEA NOT NULL,
+    user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_draft_crdt_updates_article ON draft_crdt_updates(article_id, id);
@@ -0,0 +1,10 @@
+-- Account deletion
+-- Deleting an account deactivates it and schedules a purge after a grace period; the purge keeps the row
+-- as a scrubbed tombstone so published content, comments and payments stay valid without identifying anyone
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deletion_requested_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deletion_scheduled_for TIMESTAMP WITH TIME ZONE; -- Purge time; signing in before it cancels
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE; -- Set once purged
+
+CREATE INDEX IF NOT EXISTS idx_users_deletion_scheduled ON users(deletion_scheduled_for)
+    WHERE deletion_scheduled_for IS NOT NULL AND deleted_at IS NULL;
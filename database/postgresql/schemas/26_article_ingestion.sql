@@ -0,0 +1,17 @@
+-- Article ingestion from external sources
+-- Each ingested article records where it came from; canonical URL and normalized content hash deduplicate
+-- repeated submissions and the same story arriving from different sources
+
+CREATE TABLE IF NOT EXISTS ingested_articles (
+    article_id UUID PRIMARY KEY REFERENCES articles(id) ON DELETE CASCADE,
+    canonical_url VARCHAR(1000) UNIQUE NOT NULL, -- Also stored as the article's source_url
+    content_hash VARCHAR(64) NOT NULL, -- sha256 of the normalized text
+    source_name VARCHAR(200), -- Publisher or feed the article was taken from
+    external_id VARCHAR(255), -- The source's own identifier, if it has one
+    api_key_id UUID REFERENCES api_keys(id) ON DELETE SET NULL,
+    ingested_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    last_ingested_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_ingested_articles_hash ON ingested_articles(content_hash);
+CREATE INDEX IF NOT EXISTS idx_articles_source_url ON articles(source_url) WHERE source_url IS NOT NULL;
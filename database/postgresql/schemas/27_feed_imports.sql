@@ -0,0 +1,37 @@
+-- Imported RSS and Atom feeds
+-- Administrators register external feeds; the crawler turns new items into drafts for the feed's author,
+-- using conditional requests, and keeps per-feed fetch health for the admin API
+
+CREATE TABLE IF NOT EXISTS feed_sources (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    url VARCHAR(1000) UNIQUE NOT NULL,
+    name VARCHAR(200),
+    author_id UUID REFERENCES users(id) ON DELETE SET NULL, -- Owner of the drafts created from the feed
+    category VARCHAR(100) DEFAULT 'general',
+    language VARCHAR(10), -- NULL takes the feed's declared language
+    is_active BOOLEAN DEFAULT TRUE,
+    refresh_interval_minutes INTEGER NOT NULL DEFAULT 60,
+    etag VARCHAR(500), -- Validators from the last successful fetch, sent back as If-None-Match / If-Modified-Since
+    last_modified VARCHAR(100),
+    last_fetched_at TIMESTAMP WITH TIME ZONE,
+    last_success_at TIMESTAMP WITH TIME ZONE,
+    last_status_code INTEGER,
+    last_error TEXT,
+    consecutive_failures INTEGER NOT NULL DEFAULT 0,
+    items_imported INTEGER NOT NULL DEFAULT 0,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Every item seen, so an item is imported once even after its draft is deleted
+CREATE TABLE IF NOT EXISTS feed_items (
+    feed_id UUID NOT NULL REFERENCES feed_sources(id) ON DELETE CASCADE,
+    guid VARCHAR(1000) NOT NULL, -- The item's id or guid, falling back to its link
+    article_id UUID REFERENCES articles(id) ON DELETE SET NULL,
+    link VARCHAR(1000),
+    imported_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (feed_id, guid)
+);
+
+CREATE INDEX IF NOT EXISTS idx_feed_sources_due ON feed_sources(last_fetched_at) WHERE is_active = true;
@@ -0,0 +1,9 @@
+-- Readability of articles
+-- Sentence and word statistics with a language-adapted Flesch reading-ease score, and the reading level it maps to,
+-- so readers and education-focused clients can filter by level
+
+ALTER TABLE articles ADD COLUMN IF NOT EXISTS readability JSONB;
+ALTER TABLE articles ADD COLUMN IF NOT EXISTS reading_level VARCHAR(20); -- elementary, middle_school, high_school, college, graduate
+
+CREATE INDEX IF NOT EXISTS idx_articles_reading_level ON articles(reading_level, published_at DESC)
+    WHERE status = 'published';
@@ -0,0 +1,36 @@
+-- ActivityPub
+-- Every author is an actor that Fediverse accounts (Mastodon and others) can follow; published articles are delivered
+-- to followers' inboxes, and Likes and Announces of articles received in return count toward engagement
+
+-- RSA keypair each actor signs its deliveries with (HTTP Signatures as Mastodon expects)
+CREATE TABLE IF NOT EXISTS ap_actor_keys (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    public_key_pem TEXT NOT NULL,
+    private_key_pem TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS ap_followers (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE, -- The local author being followed
+    actor_id VARCHAR(1000) NOT NULL, -- The remote follower's actor URL
+    inbox_url VARCHAR(1000) NOT NULL,
+    shared_inbox_url VARCHAR(1000), -- One delivery per server when set
+    follow_activity_id VARCHAR(1000),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(user_id, actor_id)
+);
+
+-- Likes and Announces of local articles by remote actors
+CREATE TABLE IF NOT EXISTS ap_interactions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    article_id UUID NOT NULL REFERENCES articles(id) ON DELETE CASCADE,
+    actor_id VARCHAR(1000) NOT NULL,
+    activity_type VARCHAR(20) NOT NULL, -- like, announce
+    activity_id VARCHAR(1000),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(article_id, actor_id, activity_type)
+);
+
+CREATE INDEX IF NOT EXISTS idx_ap_followers_user ON ap_followers(user_id);
+CREATE INDEX IF NOT EXISTS idx_ap_interactions_activity ON ap_interactions(activity_id);
@@ -0,0 +1,32 @@
+-- Experiments
+-- A/B tests on one aspect of an article (its headline to begin with). Viewers are assigned a variant
+-- deterministically; impressions and conversions are counted per variant and the winner is promoted
+-- once the difference is statistically significant
+
+CREATE TABLE IF NOT EXISTS experiments (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    article_id UUID NOT NULL REFERENCES articles(id) ON DELETE CASCADE,
+    kind VARCHAR(30) NOT NULL, -- headline
+    variants JSONB NOT NULL, -- [{"key": "control", "value": ...}, {"key": "b", "value": ...}, ...]
+    status VARCHAR(20) NOT NULL DEFAULT 'running', -- running, concluded, cancelled
+    winner VARCHAR(20),
+    decision JSONB, -- Results, test statistics and reason at the time the experiment ended
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    started_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    concluded_at TIMESTAMP WITH TIME ZONE
+);
+
+-- Unique viewers counted so far, synced from Redis by the evaluation job
+CREATE TABLE IF NOT EXISTS experiment_results (
+    experiment_id UUID NOT NULL REFERENCES experiments(id) ON DELETE CASCADE,
+    variant VARCHAR(20) NOT NULL,
+    impressions INTEGER NOT NULL DEFAULT 0,
+    conversions INTEGER NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (experiment_id, variant)
+);
+
+-- One running experiment of each kind per article
+CREATE UNIQUE INDEX IF NOT EXISTS idx_experiments_running
+    ON experiments(article_id, kind) WHERE status = 'running';
+CREATE INDEX IF NOT EXISTS idx_experiments_article ON experiments(article_id, started_at DESC);
@@ -0,0 +1,25 @@
+-- Content signatures
+-- Authors register public keys and sign their articles; the detached signature is stored so readers can check
+-- authorship themselves. For anonymously published articles the key fingerprint serves as a stable pseudonym
+
+CREATE TABLE IF NOT EXISTS author_keys (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    algorithm VARCHAR(20) NOT NULL, -- ed25519, secp256k1
+    public_key TEXT NOT NULL, -- Base64 of the raw Ed25519 key or the SEC1-encoded secp256k1 point
+    fingerprint VARCHAR(64) NOT NULL UNIQUE, -- SHA-256 of the public key bytes, hex
+    label VARCHAR(100),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    revoked_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE TABLE IF NOT EXISTS article_signatures (
+    article_id UUID PRIMARY KEY REFERENCES articles(id) ON DELETE CASCADE,
+    key_id UUID NOT NULL REFERENCES author_keys(id) ON DELETE RESTRICT,
+    signature TEXT NOT NULL, -- Base64
+    payload_sha256 VARCHAR(64) NOT NULL, -- Digest of the signed payload, to tell when the article has changed since
+    signed_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_author_keys_user ON author_keys(user_id);
+CREATE INDEX IF NOT EXISTS idx_article_signatures_key ON article_signatures(key_id);
@@ -0,0 +1,4 @@
+-- Generated share cards
+-- Articles published without an image get a branded Open Graph card rendered by a worker and stored in the media store
+
+ALTER TABLE articles ADD COLUMN IF NOT EXISTS og_image_url VARCHAR(1000);
@@ -0,0 +1,31 @@
+-- Merkle-tree batch anchoring
+-- Article hashes are collected into batches; only each batch's Merkle root goes on-chain and every article keeps its inclusion proof
+
+CREATE TABLE IF NOT EXISTS anchor_batches (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    merkle_root VARCHAR(64) NOT NULL UNIQUE,
+    leaf_count INTEGER NOT NULL CHECK (leaf_count > 0),
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'submitted', 'anchored', 'failed')),
+    chain_id INTEGER,
+    contract_address VARCHAR(42),
+    tx_hash VARCHAR(66),
+    block_number BIGINT,
+    error TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    submitted_at TIMESTAMP WITH TIME ZONE,
+    anchored_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE TABLE IF NOT EXISTS article_anchors (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    article_id UUID NOT NULL REFERENCES articles(id) ON DELETE CASCADE,
+    batch_id UUID NOT NULL REFERENCES anchor_batches(id) ON DELETE CASCADE,
+    leaf_index INTEGER NOT NULL,
+    article_hash VARCHAR(64) NOT NULL,
+    proof JSONB NOT NULL DEFAULT '[]',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(batch_id, leaf_index)
+);
+
+CREATE INDEX IF NOT EXISTS idx_anchor_batches_status ON anchor_batches(status, created_at);
+CREATE INDEX IF NOT EXISTS idx_article_anchors_article ON article_anchors(article_id, created_at DESC);
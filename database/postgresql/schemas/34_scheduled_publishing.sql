@@ -0,0 +1,8 @@
+-- Scheduled publishing
+-- Drafts can be scheduled to go live at a set time; caches are primed shortly before so the first readers hit warm caches
+
+ALTER TABLE articles ADD COLUMN IF NOT EXISTS scheduled_publish_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE articles ADD COLUMN IF NOT EXISTS cache_primed_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_articles_scheduled_publish
+    ON articles(scheduled_publish_at) WHERE scheduled_publish_at IS NOT NULL AND status = 'draft';
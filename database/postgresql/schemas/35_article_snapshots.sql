@@ -0,0 +1,22 @@
+-- Article snapshots in pluggable storage
+-- Published articles are stored as JSON with the configured snapshot driver (IPFS, Arweave or S3-compatible storage)
+
+CREATE TABLE IF NOT EXISTS article_snapshots (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    article_id UUID NOT NULL REFERENCES articles(id) ON DELETE CASCADE,
+    driver VARCHAR(20) NOT NULL,
+    ref VARCHAR(1000) NOT NULL,  -- CID, Arweave transaction id or object key
+    url VARCHAR(2000),
+    content_sha256 VARCHAR(64),
+    size INTEGER,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_article_snapshots_article ON article_snapshots(article_id, created_at DESC);
+
+-- Pins made before snapshots had their own table; articles.ipfs_cid keeps the latest IPFS pin
+INSERT INTO article_snapshots (article_id, driver, ref, created_at)
+SELECT id, 'ipfs', ipfs_cid, COALESCE(ipfs_pinned_at, CURRENT_TIMESTAMP)
+FROM articles
+WHERE ipfs_cid IS NOT NULL
+  AND NOT EXISTS (SELECT 1 FROM article_snapshots s WHERE s.article_id = articles.id AND s.ref = articles.ipfs_cid);
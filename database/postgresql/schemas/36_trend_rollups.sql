@@ -0,0 +1,19 @@
+-- Trend rollups
+-- Daily publication volume and engagement per tag and category, for trend charts
+
+CREATE TABLE IF NOT EXISTS trend_rollups (
+    bucket DATE NOT NULL,
+    dimension VARCHAR(10) NOT NULL CHECK (dimension IN ('tag', 'category')),
+    value VARCHAR(100) NOT NULL,
+    articles_published INTEGER NOT NULL DEFAULT 0,
+    views INTEGER NOT NULL DEFAULT 0,
+    likes INTEGER NOT NULL DEFAULT 0,
+    shares INTEGER NOT NULL DEFAULT 0,
+    saves INTEGER NOT NULL DEFAULT 0,
+    comments INTEGER NOT NULL DEFAULT 0,
+    reading_seconds BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (dimension, value, bucket)
+);
+
+CREATE INDEX IF NOT EXISTS idx_trend_rollups_bucket ON trend_rollups(bucket);
@@ -0,0 +1,46 @@
+-- Premium articles
+-- An article with an access policy shows its full content only to readers entitled by a subscription tier,
+-- an on-chain token balance or a one-time purchase; everyone else gets a preview
+
+ALTER TABLE articles ADD COLUMN IF NOT EXISTS access_policy JSONB; -- NULL for free articles
+
+CREATE TABLE IF NOT EXISTS subscription_tiers (
+    key VARCHAR(50) PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    rank INTEGER NOT NULL UNIQUE, -- A subscription to a tier also unlocks articles requiring a lower rank
+    description TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS user_subscriptions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    tier_key VARCHAR(50) NOT NULL REFERENCES subscription_tiers(key) ON DELETE RESTRICT,
+    starts_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP WITH TIME ZONE, -- NULL never expires
+    source VARCHAR(50) NOT NULL DEFAULT 'admin', -- Who or what granted it, e.g. admin or a billing provider
+    external_ref VARCHAR(255),
+    granted_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS article_purchases (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    article_id UUID NOT NULL REFERENCES articles(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    chain_id INTEGER NOT NULL,
+    transaction_hash VARCHAR(66) NOT NULL UNIQUE, -- A payment unlocks one article only
+    payer_address VARCHAR(42) NOT NULL,
+    payee_address VARCHAR(42) NOT NULL,
+    amount_wei NUMERIC(78, 0) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(article_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_subscriptions_user ON user_subscriptions(user_id) WHERE revoked_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_article_purchases_user ON article_purchases(user_id);
+
+INSERT INTO subscription_tiers (key, name, rank, description) VALUES
+    ('premium', 'Premium', 1, 'Full access to premium articles')
+ON CONFLICT (key) DO NOTHING;
@@ -0,0 +1,21 @@
+-- Cohort retention
+-- Days each user was signed in and active, and weekly signup cohorts' retention rolled up from them
+
+CREATE TABLE IF NOT EXISTS user_activity_days (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    day DATE NOT NULL,
+    PRIMARY KEY (user_id, day)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_activity_days_day ON user_activity_days(day);
+
+CREATE TABLE IF NOT EXISTS cohort_retention (
+    cohort_week DATE NOT NULL,
+    week_offset INTEGER NOT NULL CHECK (week_offset >= 0),
+    cohort_size INTEGER NOT NULL,
+    active_users INTEGER NOT NULL DEFAULT 0,
+    returning_users INTEGER NOT NULL DEFAULT 0,
+    interacting_users INTEGER NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (cohort_week, week_offset)
+);
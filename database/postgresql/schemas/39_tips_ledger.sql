@@ -0,0 +1,106 @@
+-- Tips and the payments ledger
+-- Readers tip authors by card (Stripe) or on-chain; every movement of money is recorded as a balanced
+-- double-entry transaction, and authors request payouts from their available balance
+
+CREATE TABLE IF NOT EXISTS tips (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    author_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    tipper_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    article_id UUID REFERENCES articles(id) ON DELETE SET NULL,
+    rail VARCHAR(10) NOT NULL CHECK (rail IN ('stripe', 'onchain')),
+    currency VARCHAR(10) NOT NULL, -- e.g. usd for Stripe, eth on-chain
+    amount NUMERIC(78, 0) NOT NULL CHECK (amount > 0), -- In the currency's smallest unit (cents, wei)
+    fee NUMERIC(78, 0) NOT NULL DEFAULT 0,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'succeeded', 'failed', 'refunded')),
+    message VARCHAR(500),
+    anonymous BOOLEAN NOT NULL DEFAULT FALSE, -- Hide the tipper from the author
+    stripe_payment_intent_id VARCHAR(255) UNIQUE,
+    chain_id INTEGER,
+    transaction_hash VARCHAR(66) UNIQUE,
+    payer_address VARCHAR(42),
+    payee_address VARCHAR(42),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    settled_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE TABLE IF NOT EXISTS payout_requests (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    author_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    currency VARCHAR(10) NOT NULL,
+    amount NUMERIC(78, 0) NOT NULL CHECK (amount > 0),
+    destination VARCHAR(255) NOT NULL, -- Wallet address or payout account reference
+    status VARCHAR(20) NOT NULL DEFAULT 'requested' CHECK (status IN ('requested', 'paid', 'rejected')),
+    external_ref VARCHAR(255), -- Transfer or transaction id once paid
+    note TEXT,
+    reviewed_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    reviewed_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE TABLE IF NOT EXISTS ledger_accounts (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    code VARCHAR(150) NOT NULL UNIQUE, -- e.g. author_available:<user id>:usd, platform_fees:usd
+    kind VARCHAR(30) NOT NULL CHECK (kind IN (
+        'author_available', 'author_payouts_pending', 'author_received_direct',
+        'platform_fees', 'stripe_clearing', 'onchain_clearing', 'payouts_sent'
+    )),
+    user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    currency VARCHAR(10) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS ledger_transactions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    kind VARCHAR(30) NOT NULL,
+    reference_id UUID NOT NULL, -- The tip or payout request it records
+    description TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(kind, reference_id) -- Posting the same event twice (e.g. a retried webhook) is a no-op
+);
+
+CREATE TABLE IF NOT EXISTS ledger_entries (
+    id BIGSERIAL PRIMARY KEY,
+    transaction_id UUID NOT NULL REFERENCES ledger_transactions(id) ON DELETE RESTRICT,
+    account_id UUID NOT NULL REFERENCES ledger_accounts(id) ON DELETE RESTRICT,
+    amount NUMERIC(78, 0) NOT NULL CHECK (amount != 0), -- Positive credits the account, negative debits it
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_tips_author ON tips(author_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_tips_tipper ON tips(tipper_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_tips_pending ON tips(created_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_payout_requests_author ON payout_requests(author_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_payout_requests_status ON payout_requests(status, created_at);
+CREATE INDEX IF NOT EXISTS idx_ledger_accounts_user ON ledger_accounts(user_id);
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_account ON ledger_entries(account_id);
+CREATE INDEX IF NOT EXISTS idx_ledger_entries_transaction ON ledger_entries(transaction_id);
+
+-- Every transaction's entries must sum to zero by the time it commits
+CREATE OR REPLACE FUNCTION check_ledger_transaction_balanced()
+RETURNS TRIGGER AS $$
+DECLARE
+    total NUMERIC;
+BEGIN
+    SELECT COALESCE(SUM(amount), 0) INTO total FROM ledger_entries WHERE transaction_id = NEW.transaction_id;
+    IF total != 0 THEN
+        RAISE EXCEPTION 'Ledger transaction % is unbalanced by %', NEW.transaction_id, total;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS ledger_entries_balanced ON ledger_entries;
+CREATE CONSTRAINT TRIGGER ledger_entries_balanced AFTER INSERT ON ledger_entries
+    DEFERRABLE INITIALLY DEFERRED
+    FOR EACH ROW EXECUTE FUNCTION check_ledger_transaction_balanced();
+
+-- The ledger is append-only; corrections are new transactions
+CREATE OR REPLACE FUNCTION reject_ledger_entry_change()
+RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'Ledger entries cannot be changed or removed';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE TRIGGER ledger_entries_append_only BEFORE UPDATE OR DELETE ON ledger_entries
+    FOR EACH ROW EXECUTE FUNCTION reject_ledger_entry_change();
@@ -0,0 +1,39 @@
+-- Funnels
+-- Ordered steps a reader takes from an article (view, read, interact, follow...), and how many readers
+-- reached each step, per day they entered, per article and per experiment variant
+
+CREATE TABLE IF NOT EXISTS funnels (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    key VARCHAR(50) NOT NULL UNIQUE,
+    name VARCHAR(100) NOT NULL,
+    description TEXT,
+    steps JSONB NOT NULL, -- [{"event": "view"}, {"event": "read", "min_progress": 0.5}, {"event": "follow_author"}]
+    window_hours INTEGER NOT NULL DEFAULT 168, -- Later steps count within this long of entering
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Rows without an experiment are each article's totals; rows with one split the same readers by variant
+CREATE TABLE IF NOT EXISTS funnel_counts (
+    funnel_id UUID NOT NULL REFERENCES funnels(id) ON DELETE CASCADE,
+    day DATE NOT NULL, -- The day readers entered the funnel, UTC
+    article_id UUID NOT NULL REFERENCES articles(id) ON DELETE CASCADE,
+    experiment_id UUID REFERENCES experiments(id) ON DELETE CASCADE,
+    variant VARCHAR(20),
+    step_index SMALLINT NOT NULL,
+    users INTEGER NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_funnel_counts_funnel_day ON funnel_counts(funnel_id, day);
+CREATE INDEX IF NOT EXISTS idx_funnel_counts_experiment ON funnel_counts(experiment_id) WHERE experiment_id IS NOT NULL;
+
+INSERT INTO funnels (key, name, description, steps, window_hours) VALUES (
+    'reader_to_follower',
+    'Reader to follower',
+    'Readers who open an article, read at least half of it, interact with it and then follow its author',
+    '[{"event": "view"}, {"event": "read", "min_progress": 0.5}, {"event": "interact"}, {"event": "follow_author"}]',
+    168
+) ON CONFLICT (key) DO NOTHING;
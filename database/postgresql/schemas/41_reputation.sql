@@ -0,0 +1,40 @@
+-- Reputation
+-- Every change to a user's reputation score with the event behind it, and reader reports on articles
+-- that moderators uphold or dismiss
+
+CREATE TABLE IF NOT EXISTS reputation_events (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    event VARCHAR(30) NOT NULL, -- article_published, upvote_received, report_upheld, report_dismissed, report_confirmed, fact_verified, decay
+    delta DECIMAL(6,2) NOT NULL, -- Applied change, after clamping the score to its bounds
+    score_before DECIMAL(5,2) NOT NULL,
+    score_after DECIMAL(5,2) NOT NULL,
+    reference_id VARCHAR(255), -- What the event concerns: an article, a report, a correction, an upvote
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_reputation_events_user ON reputation_events(user_id, created_at DESC);
+-- An event about the same thing counts once per user
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reputation_events_reference
+    ON reputation_events(user_id, event, reference_id) WHERE reference_id IS NOT NULL;
+
+-- Decay shrinks scores by the time since they last decayed, so existing scores start decaying now
+ALTER TABLE users ADD COLUMN IF NOT EXISTS reputation_decayed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW();
+
+CREATE TABLE IF NOT EXISTS article_reports (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    article_id UUID NOT NULL REFERENCES articles(id) ON DELETE CASCADE,
+    reported_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    reason VARCHAR(30) NOT NULL, -- misinformation, spam, harassment, plagiarism, other
+    details TEXT,
+    status VARCHAR(20) NOT NULL DEFAULT 'open', -- open, upheld, dismissed
+    resolved_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    resolution_note TEXT,
+    resolved_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- One open report per reader per article
+CREATE UNIQUE INDEX IF NOT EXISTS idx_article_reports_open
+    ON article_reports(article_id, reported_by) WHERE status = 'open';
+CREATE INDEX IF NOT EXISTS idx_article_reports_status ON article_reports(status, created_at);
@@ -0,0 +1,23 @@
+-- Data warehouse export
+-- How far each dataset's schema version has been exported: the (cursor, id) of the last row written
+
+CREATE TABLE IF NOT EXISTS warehouse_exports (
+    dataset VARCHAR(50) NOT NULL, -- articles, interactions, users
+    schema_version INTEGER NOT NULL,
+    watermark TIMESTAMP WITH TIME ZONE, -- NULL until the first batch; the next run exports in full
+    last_id UUID,
+    rows_exported BIGINT NOT NULL DEFAULT 0,
+    batches INTEGER NOT NULL DEFAULT 0,
+    sink VARCHAR(20),
+    last_location TEXT, -- File URL or table of the latest batch
+    last_exported_at TIMESTAMP WITH TIME ZONE,
+    last_error TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (dataset, schema_version)
+);
+
+-- Incremental exports page through rows by their change time
+CREATE INDEX IF NOT EXISTS idx_articles_warehouse_cursor ON articles((COALESCE(updated_at, created_at)), id);
+CREATE INDEX IF NOT EXISTS idx_users_warehouse_cursor ON users((COALESCE(updated_at, created_at)), id);
+CREATE INDEX IF NOT EXISTS idx_user_interactions_warehouse_cursor ON user_interactions(created_at, id);
@@ -0,0 +1,22 @@
+-- Fact checks
+-- Auditors' verdicts on claims in published articles, anchored to a character range of one paragraph
+
+CREATE TABLE IF NOT EXISTS fact_checks (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    article_id UUID NOT NULL REFERENCES articles(id) ON DELETE CASCADE,
+    auditor_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    paragraph INTEGER NOT NULL CHECK (paragraph >= 0), -- Index among the article's text paragraphs
+    start_offset INTEGER NOT NULL CHECK (start_offset >= 0),
+    end_offset INTEGER NOT NULL,
+    quote TEXT NOT NULL, -- The checked text when the fact check was written, to notice later edits
+    claim TEXT NOT NULL,
+    verdict VARCHAR(20) NOT NULL CHECK (verdict IN ('true', 'false', 'misleading')),
+    sources JSONB NOT NULL DEFAULT '[]', -- [{"url", "title"}]
+    note TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CHECK (end_offset > start_offset)
+);
+
+CREATE INDEX IF NOT EXISTS idx_fact_checks_article ON fact_checks(article_id, paragraph, start_offset);
+CREATE INDEX IF NOT EXISTS idx_fact_checks_auditor ON fact_checks(auditor_id, created_at DESC);
@@ -0,0 +1,48 @@
+-- Governance
+-- Proposals users with enough reputation put to a vote, their votes, and each closed proposal's on-chain mirror
+
+CREATE TABLE IF NOT EXISTS governance_proposals (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    proposer_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    kind VARCHAR(30) NOT NULL, -- category_addition, moderation_policy, feature, other
+    title VARCHAR(200) NOT NULL,
+    description TEXT NOT NULL,
+    details JSONB NOT NULL DEFAULT '{}', -- Kind-specific specifics, e.g. {"category": "climate"}
+    status VARCHAR(20) NOT NULL DEFAULT 'open', -- open, passed, rejected, cancelled, enacted
+    voting_starts_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    voting_ends_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    -- Rules in force when the proposal was made
+    quorum INTEGER NOT NULL,
+    pass_threshold DECIMAL(4,3) NOT NULL,
+    -- Final tally
+    yes_votes INTEGER NOT NULL DEFAULT 0,
+    no_votes INTEGER NOT NULL DEFAULT 0,
+    abstain_votes INTEGER NOT NULL DEFAULT 0,
+    tallied_at TIMESTAMP WITH TIME ZONE,
+    enacted_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    enacted_at TIMESTAMP WITH TIME ZONE,
+    -- On-chain mirror of the tally
+    votes_root VARCHAR(64),
+    chain_status VARCHAR(20), -- pending, submitted, mirrored, failed; NULL when not mirrored
+    tx_hash VARCHAR(66),
+    block_number BIGINT,
+    mirrored_at TIMESTAMP WITH TIME ZONE,
+    chain_error TEXT,
+    submitted_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CHECK (voting_ends_at > voting_starts_at)
+);
+
+CREATE TABLE IF NOT EXISTS governance_votes (
+    proposal_id UUID NOT NULL REFERENCES governance_proposals(id) ON DELETE CASCADE,
+    voter_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    choice VARCHAR(10) NOT NULL CHECK (choice IN ('yes', 'no', 'abstain')),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (proposal_id, voter_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_governance_proposals_status ON governance_proposals(status, voting_ends_at);
+CREATE INDEX IF NOT EXISTS idx_governance_proposals_proposer ON governance_proposals(proposer_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_governance_proposals_chain ON governance_proposals(chain_status) WHERE chain_status IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_governance_votes_voter ON governance_votes(voter_id, created_at DESC);
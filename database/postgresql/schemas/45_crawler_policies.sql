@@ -0,0 +1,5 @@
+-- Crawler policies
+-- Per API key: the minimum interval between requests and the sections (categories) the key may not fetch
+
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS crawl_delay_seconds DECIMAL(8,2); -- NULL: no delay
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS excluded_sections TEXT[] NOT NULL DEFAULT '{}';
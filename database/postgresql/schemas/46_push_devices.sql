@@ -0,0 +1,29 @@
+-- Push notifications
+-- Browsers (Web Push) and app installs (Firebase Cloud Messaging) that receive a user's notifications,
+-- and the categories a user wants a push for whenever an article is published in them
+
+CREATE TABLE IF NOT EXISTS push_devices (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    platform VARCHAR(10) NOT NULL CHECK (platform IN ('webpush', 'fcm')),
+    token TEXT NOT NULL, -- FCM registration token, or the Web Push subscription endpoint
+    keys JSONB NOT NULL DEFAULT '{}', -- Web Push subscription keys: {"p256dh": ..., "auth": ...}
+    label VARCHAR(100),
+    failure_count INTEGER NOT NULL DEFAULT 0, -- Consecutive failed deliveries; reset by a success
+    last_error TEXT,
+    last_success_at TIMESTAMP WITH TIME ZONE,
+    last_seen_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP, -- Last time the client registered it
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (platform, token)
+);
+
+CREATE TABLE IF NOT EXISTS push_topic_subscriptions (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    category VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, category)
+);
+
+CREATE INDEX IF NOT EXISTS idx_push_devices_user ON push_devices(user_id);
+CREATE INDEX IF NOT EXISTS idx_push_devices_last_seen ON push_devices(last_seen_at);
+CREATE INDEX IF NOT EXISTS idx_push_topic_subscriptions_category ON push_topic_subscriptions(category);
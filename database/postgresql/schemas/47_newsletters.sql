@@ -0,0 +1,27 @@
+-- Email digest newsletters
+-- Who gets a daily or weekly digest, and each digest sent
+
+CREATE TABLE IF NOT EXISTS newsletter_subscriptions (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    frequency VARCHAR(10) NOT NULL CHECK (frequency IN ('daily', 'weekly')),
+    unsubscribed_at TIMESTAMP WITH TIME ZONE, -- Set by the unsubscribe link; NULL while subscribed
+    last_sent_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS newsletter_sends (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    frequency VARCHAR(10) NOT NULL,
+    period_start TIMESTAMP WITH TIME ZONE NOT NULL,
+    article_ids UUID[] NOT NULL DEFAULT '{}',
+    sent_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_newsletter_subscriptions_due ON newsletter_subscriptions(frequency, last_sent_at)
+    WHERE unsubscribed_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_newsletter_sends_user ON newsletter_sends(user_id, sent_at DESC);
+
+CREATE OR REPLACE TRIGGER update_newsletter_subscriptions_updated_at BEFORE UPDATE ON newsletter_subscriptions
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,23 @@
+-- Media uploads
+-- Images users upload, each stored as the re-encoded original plus WebP and thumbnail variants
+
+CREATE TABLE IF NOT EXISTS media_uploads (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    owner_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    article_id UUID REFERENCES articles(id) ON DELETE SET NULL,
+    visibility VARCHAR(10) NOT NULL DEFAULT 'public' CHECK (visibility IN ('public', 'private')),
+    driver VARCHAR(20) NOT NULL, -- Storage driver the variants are in; changes when a private upload is published
+    filename VARCHAR(255),
+    content_type VARCHAR(50) NOT NULL,
+    width INTEGER NOT NULL,
+    height INTEGER NOT NULL,
+    size BIGINT NOT NULL, -- Bytes as uploaded
+    content_sha256 VARCHAR(64) NOT NULL,
+    alt_text VARCHAR(500),
+    variants JSONB NOT NULL DEFAULT '{}', -- {"original": {"ref", "url", "content_type", "width", "height", "size"}, "webp": ..., "thumbnail": ...}
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    published_at TIMESTAMP WITH TIME ZONE -- When a private upload was copied to public storage
+);
+
+CREATE INDEX IF NOT EXISTS idx_media_uploads_owner ON media_uploads(owner_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_media_uploads_article ON media_uploads(article_id) WHERE article_id IS NOT NULL;
@@ -0,0 +1,21 @@
+-- Link previews
+-- Open Graph metadata fetched from an article's source URL and the links in its content, cached per URL,
+-- and the previews of each article so clients can render rich links without scraping pages themselves
+
+CREATE TABLE IF NOT EXISTS link_previews (
+    url VARCHAR(2000) PRIMARY KEY,
+    status VARCHAR(10) NOT NULL CHECK (status IN ('ok', 'failed', 'blocked')), -- blocked: refused by the SSRF checks
+    final_url VARCHAR(2000), -- After redirects
+    title VARCHAR(500),
+    description TEXT,
+    image_url VARCHAR(2000),
+    site_name VARCHAR(200),
+    error TEXT,
+    fetched_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_link_previews_expires ON link_previews(expires_at);
+
+-- [{"url", "title", "description", "image_url", "site_name"}, ...], source URL first
+ALTER TABLE articles ADD COLUMN IF NOT EXISTS link_previews JSONB NOT NULL DEFAULT '[]';
@@ -0,0 +1,4 @@
+-- Account security events
+-- Users list their own security events from audit_logs, newest first, filtered by action
+
+CREATE INDEX IF NOT EXISTS idx_audit_logs_user_action_created ON audit_logs(user_id, action, created_at DESC);
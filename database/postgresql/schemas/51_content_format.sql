@@ -0,0 +1,5 @@
+-- Article content formats
+-- Articles are written as HTML or Markdown; the source is stored as written and rendered to sanitized HTML on read
+
+ALTER TABLE articles ADD COLUMN IF NOT EXISTS content_format VARCHAR(10) NOT NULL DEFAULT 'html'
+    CHECK (content_format IN ('html', 'markdown'));
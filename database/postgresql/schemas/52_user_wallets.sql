@@ -0,0 +1,26 @@
+-- Linked wallets
+-- Wallets an account proved it controls by signing a challenge; the primary one is mirrored into users.did_address
+
+CREATE TABLE IF NOT EXISTS user_wallets (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    address VARCHAR(42) NOT NULL UNIQUE, -- Lowercase 0x address
+    did VARCHAR(255) NOT NULL, -- did:pkh:eip155:<chain id>:<address>
+    chain_id BIGINT NOT NULL,
+    label VARCHAR(100),
+    is_primary BOOLEAN NOT NULL DEFAULT FALSE,
+    signed_message TEXT, -- The challenge and its personal_sign signature, kept as proof of the link
+    signature TEXT,
+    verified_at TIMESTAMP WITH TIME ZONE, -- NULL for addresses set on the account before wallets were linked
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_wallets_user ON user_wallets(user_id, created_at);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_wallets_one_primary ON user_wallets(user_id) WHERE is_primary;
+
+-- Existing account addresses become their primary wallets, on the default PAYWALL_CHAIN_ID
+INSERT INTO user_wallets (user_id, address, did, chain_id, is_primary, created_at)
+SELECT id, LOWER(did_address), 'did:pkh:eip155:31337:' || LOWER(did_address), 31337, TRUE, created_at
+FROM users
+WHERE did_address ~* '^0x[0-9a-f]{40}$'
+ON CONFLICT (address) DO NOTHING;
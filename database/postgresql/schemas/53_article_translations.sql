@@ -0,0 +1,22 @@
+-- Article translations
+-- Machine translations of an article, one per language, with where they came from and the text they were made from
+
+CREATE TABLE IF NOT EXISTS article_translations (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    article_id UUID NOT NULL REFERENCES articles(id) ON DELETE CASCADE,
+    language VARCHAR(10) NOT NULL,
+    title VARCHAR(500) NOT NULL,
+    summary TEXT,
+    content TEXT NOT NULL, -- Sanitized HTML
+    word_count INTEGER NOT NULL DEFAULT 0,
+    reading_time INTEGER NOT NULL DEFAULT 1,
+    provider VARCHAR(50) NOT NULL, -- libretranslate, deepl
+    source_language VARCHAR(10),
+    source_hash CHAR(64) NOT NULL, -- SHA-256 of the original's title, summary and content when translated
+    requested_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    translated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(article_id, language)
+);
+
+-- Languages the article has translations in, so reads only look for a variant when one exists
+ALTER TABLE articles ADD COLUMN IF NOT EXISTS translations TEXT[] NOT NULL DEFAULT '{}';
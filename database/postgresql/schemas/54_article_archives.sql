@@ -0,0 +1,26 @@
+-- Article archives
+-- Bundles of published articles committed to permanent storage networks (Arweave, Filecoin), with their cost and status
+
+CREATE TABLE IF NOT EXISTS article_archives (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    article_id UUID NOT NULL REFERENCES articles(id) ON DELETE CASCADE,
+    provider VARCHAR(20) NOT NULL, -- arweave, filecoin
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'submitted', 'confirmed', 'failed')),
+    archive_id VARCHAR(200), -- Arweave transaction id or Filecoin CID
+    url VARCHAR(2000),
+    cost NUMERIC(36, 18),
+    currency VARCHAR(10),
+    content_sha256 CHAR(64),
+    size INTEGER,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    error TEXT,
+    requested_by UUID REFERENCES users(id) ON DELETE SET NULL, -- NULL when archived on schedule
+    submitted_at TIMESTAMP WITH TIME ZONE,
+    confirmed_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_article_archives_article ON article_archives(article_id, provider, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_article_archives_status ON article_archives(status, created_at)
+    WHERE status != 'confirmed';
@@ -0,0 +1,37 @@
+-- Account migration between instances
+-- Signed exports issued to accounts leaving, imports of accounts arriving, and the aliases that link them
+
+CREATE TABLE IF NOT EXISTS account_migrations (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    direction VARCHAR(10) NOT NULL CHECK (direction IN ('export', 'import')),
+    export_id UUID NOT NULL, -- Chosen by the origin; the same on both instances
+    domain VARCHAR(255) NOT NULL, -- The destination of an export, the origin of an import
+    status VARCHAR(20) NOT NULL CHECK (status IN ('exported', 'imported', 'completed')),
+    document JSONB NOT NULL, -- The signed export
+    receipt JSONB, -- The destination's signed receipt
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    completed_at TIMESTAMP WITH TIME ZONE,
+    UNIQUE(direction, export_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_account_migrations_user ON account_migrations(user_id, created_at DESC);
+
+-- Identities an account had on other instances, for ActivityPub alsoKnownAs and resolving imported follows
+CREATE TABLE IF NOT EXISTS account_aliases (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    domain VARCHAR(255) NOT NULL,
+    remote_user_id VARCHAR(100) NOT NULL,
+    remote_username VARCHAR(50) NOT NULL,
+    remote_url VARCHAR(1000) NOT NULL,
+    migration_id UUID REFERENCES account_migrations(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(domain, remote_user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_account_aliases_user ON account_aliases(user_id);
+
+-- Where an account that moved away went
+ALTER TABLE users ADD COLUMN IF NOT EXISTS moved_to VARCHAR(1000);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS moved_at TIMESTAMP WITH TIME ZONE;
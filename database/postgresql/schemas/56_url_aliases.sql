@@ -0,0 +1,68 @@
+-- URL aliases
+-- Article slugs, and the old usernames and slugs that redirect to where a profile or article lives now
+
+-- Readable article URLs: the title, then part of the id so slugs stay unique
+CREATE OR REPLACE FUNCTION article_slug(title TEXT, article_id UUID)
+RETURNS TEXT AS $$
+    SELECT COALESCE(
+        NULLIF(left(trim(BOTH '-' FROM regexp_replace(lower(COALESCE(title, '')), '[^a-z0-9]+', '-', 'g')), 80), ''),
+        'article'
+    ) || '-' || left(replace(article_id::text, '-', ''), 12);
+$$ LANGUAGE sql IMMUTABLE;
+
+ALTER TABLE articles ADD COLUMN IF NOT EXISTS slug VARCHAR(100);
+UPDATE articles SET slug = article_slug(title, id) WHERE slug IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_articles_slug ON articles(slug);
+
+CREATE TABLE IF NOT EXISTS url_aliases (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    kind VARCHAR(10) NOT NULL CHECK (kind IN ('user', 'article')),
+    alias VARCHAR(100) NOT NULL, -- An old username or article slug
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    article_id UUID REFERENCES articles(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(kind, alias),
+    CHECK ((kind = 'user') = (user_id IS NOT NULL) AND (kind = 'article') = (article_id IS NOT NULL))
+);
+
+-- A renamed account keeps its old name as an alias until someone else takes it;
+-- deleted accounts (renamed deleted-...) lose theirs
+CREATE OR REPLACE FUNCTION record_username_alias()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF NEW.username LIKE 'deleted-%' THEN
+        DELETE FROM url_aliases WHERE kind = 'user' AND user_id = NEW.id;
+        RETURN NEW;
+    END IF;
+    DELETE FROM url_aliases WHERE kind = 'user' AND alias = NEW.username;
+    IF TG_OP = 'UPDATE' AND NEW.username IS DISTINCT FROM OLD.username THEN
+        INSERT INTO url_aliases (kind, alias, user_id) VALUES ('user', OLD.username, NEW.id)
+        ON CONFLICT (kind, alias) DO UPDATE SET user_id = EXCLUDED.user_id, created_at = CURRENT_TIMESTAMP;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS users_username_alias ON users;
+CREATE TRIGGER users_username_alias AFTER INSERT OR UPDATE OF username ON users
+    FOR EACH ROW EXECUTE FUNCTION record_username_alias();
+
+-- Slugs follow the title; once published, an article's old slug keeps working as an alias
+CREATE OR REPLACE FUNCTION set_article_slug()
+RETURNS TRIGGER AS $$
+BEGIN
+    NEW.slug := article_slug(NEW.title, NEW.id);
+    IF TG_OP = 'UPDATE' AND OLD.slug IS NOT NULL AND NEW.slug IS DISTINCT FROM OLD.slug THEN
+        DELETE FROM url_aliases WHERE kind = 'article' AND alias = NEW.slug;
+        IF OLD.status = 'published' THEN
+            INSERT INTO url_aliases (kind, alias, article_id) VALUES ('article', OLD.slug, NEW.id)
+            ON CONFLICT (kind, alias) DO NOTHING;
+        END IF;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS articles_slug ON articles;
+CREATE TRIGGER articles_slug BEFORE INSERT OR UPDATE OF title ON articles
+    FOR EACH ROW EXECUTE FUNCTION set_article_slug();
@@ -0,0 +1,23 @@
+-- Tag and category suggestions
+-- How often each tag and category is used on published articles, refreshed by jobs.refresh_taxonomy_usage
+
+CREATE MATERIALIZED VIEW IF NOT EXISTS taxonomy_usage AS
+SELECT d.dimension,
+       LOWER(d.value) AS value,
+       -- The spelling most articles use, shown in suggestions
+       mode() WITHIN GROUP (ORDER BY d.value) AS display,
+       COUNT(*) AS usage_count,
+       MAX(a.published_at) AS last_used_at
+FROM articles a
+CROSS JOIN LATERAL (
+    SELECT 'category', BTRIM(a.category)
+    UNION
+    SELECT 'tag', BTRIM(tag) FROM unnest(a.tags) tag
+) d(dimension, value)
+WHERE a.status = 'published' AND d.value IS NOT NULL AND d.value != ''
+GROUP BY d.dimension, LOWER(d.value);
+
+-- Unique, so the view can be refreshed concurrently
+CREATE UNIQUE INDEX IF NOT EXISTS idx_taxonomy_usage_value ON taxonomy_usage(dimension, value);
+CREATE INDEX IF NOT EXISTS idx_taxonomy_usage_trgm ON taxonomy_usage USING GIN(value gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_taxonomy_usage_popular ON taxonomy_usage(dimension, usage_count DESC);
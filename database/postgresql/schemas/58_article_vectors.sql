@@ -0,0 +1,16 @@
+-- Article embeddings for related articles (pgvector)
+-- Needs the vector extension, which the pgvector/pgvector images ship with
+
+CREATE EXTENSION IF NOT EXISTS vector;
+
+CREATE TABLE IF NOT EXISTS article_vectors (
+    article_id UUID PRIMARY KEY REFERENCES articles(id) ON DELETE CASCADE,
+    provider VARCHAR(30) NOT NULL,
+    model VARCHAR(100) NOT NULL,
+    embedding vector(768) NOT NULL, -- shared/embeddings.py DIMENSIONS
+    content_hash VARCHAR(64) NOT NULL, -- SHA-256 of the embedded text
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_article_vectors_embedding ON article_vectors USING hnsw (embedding vector_cosine_ops);
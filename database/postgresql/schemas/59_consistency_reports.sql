@@ -0,0 +1,17 @@
+-- Database consistency checks
+-- One report per run of the checks in shared/consistency.py, with each check's violations
+
+CREATE TABLE IF NOT EXISTS consistency_reports (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    trigger VARCHAR(20) NOT NULL CHECK (trigger IN ('scheduled', 'manual')),
+    repair BOOLEAN NOT NULL DEFAULT FALSE, -- Whether repairable violations were repaired
+    requested_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'running' CHECK (status IN ('running', 'completed', 'failed')),
+    results JSONB NOT NULL DEFAULT '[]', -- Per check: violations, a sample of them, repaired
+    violations INTEGER NOT NULL DEFAULT 0,
+    repaired INTEGER NOT NULL DEFAULT 0,
+    started_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    finished_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_consistency_reports_started ON consistency_reports(started_at DESC);
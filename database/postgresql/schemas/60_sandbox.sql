@@ -0,0 +1,16 @@
+-- Sandbox tenant
+-- One row per rebuild of the `sandbox` schema (shared/sandbox.py); the schema itself is created by the reset job
+
+CREATE TABLE IF NOT EXISTS sandbox_resets (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    trigger VARCHAR(20) NOT NULL CHECK (trigger IN ('scheduled', 'manual')),
+    requested_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'running' CHECK (status IN ('running', 'completed', 'failed')),
+    tables INTEGER NOT NULL DEFAULT 0, -- Tables copied from the public schema
+    seeded JSONB NOT NULL DEFAULT '{}', -- Fake rows created, by kind
+    error TEXT,
+    started_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    finished_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_sandbox_resets_started ON sandbox_resets(started_at DESC);
@@ -0,0 +1,21 @@
+-- Comment screening
+-- Comments held by the spam and toxicity checks (shared/screening.py), with what flagged them and the moderator's decision
+
+-- Comments were shown unless rejected; only approved ones are shown now, so existing comments stay up
+UPDATE comments SET moderation_status = 'approved' WHERE moderation_status = 'pending';
+
+CREATE INDEX IF NOT EXISTS idx_comments_article_status ON comments(article_id, moderation_status);
+
+CREATE TABLE IF NOT EXISTS comment_screenings (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    comment_id UUID NOT NULL UNIQUE REFERENCES comments(id) ON DELETE CASCADE,
+    article_id UUID NOT NULL REFERENCES articles(id) ON DELETE CASCADE,
+    flags JSONB NOT NULL DEFAULT '[]', -- [{check, reason}]
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected')),
+    reviewed_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    review_note TEXT,
+    reviewed_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_comment_screenings_status ON comment_screenings(status, created_at);
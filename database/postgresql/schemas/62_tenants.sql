@@ -0,0 +1,29 @@
+-- Tenants
+-- Publications sharing one deployment (shared/tenancy.py); users, articles and interactions belong to one or to none
+
+CREATE TABLE IF NOT EXISTS tenants (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    slug VARCHAR(50) NOT NULL UNIQUE,
+    name VARCHAR(200) NOT NULL,
+    domains TEXT[] NOT NULL DEFAULT '{}',
+    branding JSONB NOT NULL DEFAULT '{}', -- {logo_url, favicon_url, colors, footer_links}
+    quotas JSONB NOT NULL DEFAULT '{}', -- {max_users, max_articles, requests_per_minute}
+    is_active BOOLEAN NOT NULL DEFAULT true,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_tenants_domains ON tenants USING GIN(domains);
+
+-- Rows default to the connection's tenant, so handlers writing their own SQL create them in the right publication
+ALTER TABLE users ADD COLUMN IF NOT EXISTS tenant_id UUID
+    DEFAULT NULLIF(current_setting('app.tenant_id', true), '')::uuid REFERENCES tenants(id);
+ALTER TABLE articles ADD COLUMN IF NOT EXISTS tenant_id UUID
+    DEFAULT NULLIF(current_setting('app.tenant_id', true), '')::uuid REFERENCES tenants(id);
+ALTER TABLE user_interactions ADD COLUMN IF NOT EXISTS tenant_id UUID
+    DEFAULT NULLIF(current_setting('app.tenant_id', true), '')::uuid REFERENCES tenants(id);
+
+CREATE INDEX IF NOT EXISTS idx_users_tenant ON users(tenant_id);
+CREATE INDEX IF NOT EXISTS idx_articles_tenant ON articles(tenant_id, status);
+CREATE INDEX IF NOT EXISTS idx_user_interactions_tenant ON user_interactions(tenant_id);
@@ -0,0 +1,19 @@
+-- Shadow bans and user blocks
+-- Shadow-banned users' content is only visible to themselves and moderators; blocked users' content is hidden from the blocker
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS shadow_banned_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS shadow_banned_by UUID REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS shadow_ban_reason TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_users_shadow_banned ON users(shadow_banned_at) WHERE shadow_banned_at IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS user_blocks (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    blocker_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    blocked_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(blocker_id, blocked_id),
+    CHECK (blocker_id != blocked_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_blocks_blocked ON user_blocks(blocked_id);
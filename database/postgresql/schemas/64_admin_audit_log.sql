@@ -0,0 +1,44 @@
+-- Admin audit log
+-- Append-only record of what administrators and auditors do (shared/audit.py); each entry hashes the one before it
+
+CREATE TABLE IF NOT EXISTS admin_audit_log (
+    sequence BIGINT PRIMARY KEY,
+    previous_hash CHAR(64) NOT NULL,
+    entry_hash CHAR(64) NOT NULL UNIQUE,
+    -- No foreign keys: entries outlive the accounts and resources they name
+    actor_id UUID,
+    actor_username VARCHAR(50),
+    actor_role VARCHAR(20),
+    action VARCHAR(100) NOT NULL,
+    resource_type VARCHAR(50) NOT NULL,
+    resource_id TEXT,
+    before JSONB,
+    after JSONB,
+    ip_address TEXT,
+    user_agent TEXT,
+    tenant_id UUID,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_admin_audit_log_actor ON admin_audit_log(actor_id, sequence DESC);
+CREATE INDEX IF NOT EXISTS idx_admin_audit_log_resource ON admin_audit_log(resource_type, resource_id, sequence DESC);
+CREATE INDEX IF NOT EXISTS idx_admin_audit_log_action ON admin_audit_log(action, sequence DESC);
+CREATE INDEX IF NOT EXISTS idx_admin_audit_log_created_at ON admin_audit_log(created_at);
+CREATE INDEX IF NOT EXISTS idx_admin_audit_log_tenant ON admin_audit_log(tenant_id, sequence DESC) WHERE tenant_id IS NOT NULL;
+
+CREATE OR REPLACE FUNCTION reject_admin_audit_log_change()
+RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'admin_audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS admin_audit_log_append_only ON admin_audit_log;
+CREATE TRIGGER admin_audit_log_append_only
+    BEFORE UPDATE OR DELETE ON admin_audit_log
+    FOR EACH ROW EXECUTE FUNCTION reject_admin_audit_log_change();
+
+DROP TRIGGER IF EXISTS admin_audit_log_no_truncate ON admin_audit_log;
+CREATE TRIGGER admin_audit_log_no_truncate
+    BEFORE TRUNCATE ON admin_audit_log
+    FOR EACH STATEMENT EXECUTE FUNCTION reject_admin_audit_log_change();
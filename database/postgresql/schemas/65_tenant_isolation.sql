@@ -0,0 +1,114 @@
+-- Tenant isolation
+-- Row-level security holding each connection to its publication's rows (shared/tenancy.py)
+
+-- Visible when the connection works across publications (app.tenant_scope 'all') or the row is in its publication;
+-- a connection that set neither only sees the default publication's rows
+CREATE OR REPLACE FUNCTION tenant_row_visible(row_tenant UUID) RETURNS BOOLEAN
+LANGUAGE sql STABLE AS $$
+    SELECT current_setting('app.tenant_scope', true) = 'all'
+        OR row_tenant IS NOT DISTINCT FROM NULLIF(current_setting('app.tenant_id', true), '')::uuid
+$$;
+
+-- Comments, held comments, pins, webhooks and actor keys belong to the publication of their article or owner; they're
+-- backfilled before the policies below hide other publications' articles and users
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS tenant_id UUID
+    DEFAULT NULLIF(current_setting('app.tenant_id', true), '')::uuid REFERENCES tenants(id);
+ALTER TABLE comment_screenings ADD COLUMN IF NOT EXISTS tenant_id UUID
+    DEFAULT NULLIF(current_setting('app.tenant_id', true), '')::uuid REFERENCES tenants(id);
+ALTER TABLE article_pins ADD COLUMN IF NOT EXISTS tenant_id UUID
+    DEFAULT NULLIF(current_setting('app.tenant_id', true), '')::uuid REFERENCES tenants(id);
+ALTER TABLE webhooks ADD COLUMN IF NOT EXISTS tenant_id UUID
+    DEFAULT NULLIF(current_setting('app.tenant_id', true), '')::uuid REFERENCES tenants(id);
+ALTER TABLE webhook_deliveries ADD COLUMN IF NOT EXISTS tenant_id UUID
+    DEFAULT NULLIF(current_setting('app.tenant_id', true), '')::uuid REFERENCES tenants(id);
+ALTER TABLE webhook_dead_letters ADD COLUMN IF NOT EXISTS tenant_id UUID
+    DEFAULT NULLIF(current_setting('app.tenant_id', true), '')::uuid REFERENCES tenants(id);
+ALTER TABLE ap_actor_keys ADD COLUMN IF NOT EXISTS tenant_id UUID
+    DEFAULT NULLIF(current_setting('app.tenant_id', true), '')::uuid REFERENCES tenants(id);
+
+UPDATE comments c SET tenant_id = a.tenant_id FROM articles a WHERE a.id = c.article_id AND a.tenant_id IS NOT NULL;
+UPDATE comment_screenings s SET tenant_id = a.tenant_id
+    FROM articles a WHERE a.id = s.article_id AND a.tenant_id IS NOT NULL;
+UPDATE article_pins p SET tenant_id = a.tenant_id FROM articles a WHERE a.id = p.article_id AND a.tenant_id IS NOT NULL;
+UPDATE webhooks w SET tenant_id = u.tenant_id FROM users u WHERE u.id = w.owner_id AND u.tenant_id IS NOT NULL;
+UPDATE webhook_deliveries d SET tenant_id = w.tenant_id
+    FROM webhooks w WHERE w.id = d.webhook_id AND w.tenant_id IS NOT NULL;
+UPDATE webhook_dead_letters l SET tenant_id = w.tenant_id
+    FROM webhooks w WHERE w.id = l.webhook_id AND w.tenant_id IS NOT NULL;
+UPDATE ap_actor_keys k SET tenant_id = u.tenant_id FROM users u WHERE u.id = k.user_id AND u.tenant_id IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_comments_tenant ON comments(tenant_id);
+CREATE INDEX IF NOT EXISTS idx_comment_screenings_tenant ON comment_screenings(tenant_id, status);
+CREATE INDEX IF NOT EXISTS idx_article_pins_tenant ON article_pins(tenant_id);
+CREATE INDEX IF NOT EXISTS idx_webhooks_tenant ON webhooks(tenant_id);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_tenant ON webhook_deliveries(tenant_id);
+
+-- FORCE applies the policies to the tables' owner too; superusers and BYPASSRLS roles still skip them
+ALTER TABLE users ENABLE ROW LEVEL SECURITY;
+ALTER TABLE users FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON users;
+CREATE POLICY tenant_isolation ON users
+    USING (tenant_row_visible(tenant_id)) WITH CHECK (tenant_row_visible(tenant_id));
+
+ALTER TABLE articles ENABLE ROW LEVEL SECURITY;
+ALTER TABLE articles FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON articles;
+CREATE POLICY tenant_isolation ON articles
+    USING (tenant_row_visible(tenant_id)) WITH CHECK (tenant_row_visible(tenant_id));
+
+ALTER TABLE user_interactions ENABLE ROW LEVEL SECURITY;
+ALTER TABLE user_interactions FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON user_interactions;
+CREATE POLICY tenant_isolation ON user_interactions
+    USING (tenant_row_visible(tenant_id)) WITH CHECK (tenant_row_visible(tenant_id));
+
+ALTER TABLE comments ENABLE ROW LEVEL SECURITY;
+ALTER TABLE comments FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON comments;
+CREATE POLICY tenant_isolation ON comments
+    USING (tenant_row_visible(tenant_id)) WITH CHECK (tenant_row_visible(tenant_id));
+
+ALTER TABLE comment_screenings ENABLE ROW LEVEL SECURITY;
+ALTER TABLE comment_screenings FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON comment_screenings;
+CREATE POLICY tenant_isolation ON comment_screenings
+    USING (tenant_row_visible(tenant_id)) WITH CHECK (tenant_row_visible(tenant_id));
+
+ALTER TABLE article_pins ENABLE ROW LEVEL SECURITY;
+ALTER TABLE article_pins FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON article_pins;
+CREATE POLICY tenant_isolation ON article_pins
+    USING (tenant_row_visible(tenant_id)) WITH CHECK (tenant_row_visible(tenant_id));
+
+ALTER TABLE webhooks ENABLE ROW LEVEL SECURITY;
+ALTER TABLE webhooks FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON webhooks;
+CREATE POLICY tenant_isolation ON webhooks
+    USING (tenant_row_visible(tenant_id)) WITH CHECK (tenant_row_visible(tenant_id));
+
+ALTER TABLE webhook_deliveries ENABLE ROW LEVEL SECURITY;
+ALTER TABLE webhook_deliveries FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON webhook_deliveries;
+CREATE POLICY tenant_isolation ON webhook_deliveries
+    USING (tenant_row_visible(tenant_id)) WITH CHECK (tenant_row_visible(tenant_id));
+
+ALTER TABLE webhook_dead_letters ENABLE ROW LEVEL SECURITY;
+ALTER TABLE webhook_dead_letters FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON webhook_dead_letters;
+CREATE POLICY tenant_isolation ON webhook_dead_letters
+    USING (tenant_row_visible(tenant_id)) WITH CHECK (tenant_row_visible(tenant_id));
+
+ALTER TABLE ap_actor_keys ENABLE ROW LEVEL SECURITY;
+ALTER TABLE ap_actor_keys FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON ap_actor_keys;
+CREATE POLICY tenant_isolation ON ap_actor_keys
+    USING (tenant_row_visible(tenant_id)) WITH CHECK (tenant_row_visible(tenant_id));
+
+-- Usernames and emails are unique across publications, so registration checks them all
+CREATE OR REPLACE FUNCTION user_identity_taken(candidate_email TEXT, candidate_username TEXT) RETURNS BOOLEAN
+LANGUAGE sql STABLE SET app.tenant_scope = 'all' AS $$
+    SELECT EXISTS(SELECT 1 FROM users WHERE email = candidate_email OR username = candidate_username)
+$$;
+
+-- Signing secrets are stored encrypted for their owner's publication (shared/tenant_keys.py)
+ALTER TABLE webhooks ALTER COLUMN secret TYPE TEXT;
@@ -0,0 +1,36 @@
+-- Event replays
+-- Historical events from the outbox fed to a projection to rebuild its read model (shared/replay.py)
+
+CREATE TABLE IF NOT EXISTS event_replays (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    projection VARCHAR(100) NOT NULL,
+    event_types TEXT[] NOT NULL,
+    since_at TIMESTAMP WITH TIME ZONE,
+    until_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    reset BOOLEAN NOT NULL DEFAULT false,
+    rate_per_second NUMERIC(10, 2) CHECK (rate_per_second > 0), -- NULL for no limit
+    batch_size INTEGER NOT NULL CHECK (batch_size > 0),
+    status VARCHAR(20) NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'running', 'paused', 'completed', 'failed', 'cancelled')),
+    -- The last event applied, in (occurred_at, id) order
+    checkpoint_at TIMESTAMP WITH TIME ZONE,
+    checkpoint_id UUID,
+    total_events BIGINT NOT NULL DEFAULT 0,
+    processed_events BIGINT NOT NULL DEFAULT 0,
+    changed_events BIGINT NOT NULL DEFAULT 0,
+    running_seconds DOUBLE PRECISION NOT NULL DEFAULT 0,
+    last_error TEXT,
+    requested_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    started_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    finished_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_replays_created ON event_replays(created_at DESC);
+-- One active replay per projection
+CREATE UNIQUE INDEX IF NOT EXISTS idx_event_replays_active ON event_replays(projection)
+    WHERE status IN ('pending', 'running');
+
+-- Replays walk the outbox in (occurred_at, id) order
+CREATE INDEX IF NOT EXISTS idx_event_outbox_replay ON event_outbox(occurred_at, id);
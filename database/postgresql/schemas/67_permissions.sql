@@ -0,0 +1,39 @@
+-- Permissions
+-- Fine-grained permissions held through a user's role and granted to or revoked from single users (shared/permissions.py)
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role user_role NOT NULL,
+    permission VARCHAR(64) NOT NULL,
+    granted_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (role, permission)
+);
+
+-- Overrides of what a user's role gives them: granted adds a permission, revoked takes it away
+CREATE TABLE IF NOT EXISTS user_permissions (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    permission VARCHAR(64) NOT NULL,
+    granted BOOLEAN NOT NULL,
+    granted_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, permission)
+);
+
+-- What each role could do when its checks were hardcoded
+INSERT INTO role_permissions (role, permission) VALUES
+    ('reader', 'article:publish'),
+    ('author', 'article:publish'),
+    ('auditor', 'article:publish'),
+    ('auditor', 'user:ban'),
+    ('auditor', 'audit:read'),
+    ('administrator', 'article:publish'),
+    ('administrator', 'article:edit_any'),
+    ('administrator', 'article:moderate'),
+    ('administrator', 'comment:moderate'),
+    ('administrator', 'user:ban'),
+    ('administrator', 'user:manage'),
+    ('administrator', 'analytics:read'),
+    ('administrator', 'audit:read'),
+    ('administrator', 'settings:manage'),
+    ('administrator', 'permissions:manage')
+ON CONFLICT (role, permission) DO NOTHING;
@@ -0,0 +1,27 @@
+-- Event dead letters
+-- Events a consumer couldn't process, quarantined for an administrator to inspect, fix and retry or discard
+-- (shared/dead_letters.py)
+
+-- Set when the relay quarantines an event, which it then stops trying to publish
+ALTER TABLE event_outbox ADD COLUMN IF NOT EXISTS dead_lettered_at TIMESTAMP WITH TIME ZONE;
+
+CREATE TABLE IF NOT EXISTS event_dead_letters (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    consumer VARCHAR(50) NOT NULL,
+    event_id UUID NOT NULL,
+    event_type VARCHAR(100) NOT NULL,
+    aggregate_id VARCHAR(100),
+    payload JSONB NOT NULL, -- Edited in place before a retry
+    original_payload JSONB, -- The payload as quarantined, once it has been edited
+    error TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'quarantined' CHECK (status IN ('quarantined', 'retried', 'discarded')),
+    retry_count INTEGER NOT NULL DEFAULT 0,
+    resolved_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    resolution_note TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    resolved_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_dead_letters_status ON event_dead_letters(status, consumer, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_event_dead_letters_event ON event_dead_letters(event_id);
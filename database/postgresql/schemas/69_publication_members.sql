@@ -0,0 +1,37 @@
+-- Publication members
+-- Member roles within a publication, and invitations to join it with one (shared/members.py)
+
+-- Editors run a publication's newsroom; contributors write for it
+ALTER TABLE users ADD COLUMN IF NOT EXISTS publication_role VARCHAR(20)
+    CHECK (publication_role IN ('editor', 'contributor'));
+
+CREATE INDEX IF NOT EXISTS idx_users_publication_role ON users(tenant_id, publication_role)
+    WHERE publication_role IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS publication_invitations (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id UUID DEFAULT NULLIF(current_setting('app.tenant_id', true), '')::uuid
+        REFERENCES tenants(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL,
+    role VARCHAR(20) NOT NULL CHECK (role IN ('editor', 'contributor')),
+    token_hash VARCHAR(64) NOT NULL UNIQUE, -- SHA-256 of the token emailed to the invitee
+    invited_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    accepted_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    accepted_at TIMESTAMP WITH TIME ZONE,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_publication_invitations_email ON publication_invitations(tenant_id, lower(email));
+
+-- Invitations are only seen, and accepted, in their own publication
+ALTER TABLE publication_invitations ENABLE ROW LEVEL SECURITY;
+ALTER TABLE publication_invitations FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON publication_invitations;
+CREATE POLICY tenant_isolation ON publication_invitations
+    USING (tenant_row_visible(tenant_id)) WITH CHECK (tenant_row_visible(tenant_id));
+
+INSERT INTO role_permissions (role, permission) VALUES
+    ('administrator', 'members:manage')
+ON CONFLICT (role, permission) DO NOTHING;
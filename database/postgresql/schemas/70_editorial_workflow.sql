@@ -0,0 +1,62 @@
+-- Editorial workflow
+-- Drafts submitted to editors, their assignments and decisions, and every transition (shared/editorial.py)
+
+CREATE TABLE IF NOT EXISTS editorial_reviews (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id UUID DEFAULT NULLIF(current_setting('app.tenant_id', true), '')::uuid
+        REFERENCES tenants(id) ON DELETE CASCADE,
+    article_id UUID NOT NULL REFERENCES articles(id) ON DELETE CASCADE,
+    submitted_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    assigned_to UUID REFERENCES users(id) ON DELETE SET NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'submitted'
+        CHECK (status IN ('submitted', 'changes_requested', 'approved', 'rejected', 'withdrawn')),
+    note TEXT, -- The author's note to the editors
+    decided_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    decision_note TEXT,
+    decided_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- An article has at most one review in progress
+CREATE UNIQUE INDEX IF NOT EXISTS idx_editorial_reviews_open ON editorial_reviews(article_id)
+    WHERE status IN ('submitted', 'changes_requested');
+CREATE INDEX IF NOT EXISTS idx_editorial_reviews_queue ON editorial_reviews(tenant_id, status, created_at);
+CREATE INDEX IF NOT EXISTS idx_editorial_reviews_assignee ON editorial_reviews(assigned_to, status)
+    WHERE assigned_to IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS editorial_events (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id UUID DEFAULT NULLIF(current_setting('app.tenant_id', true), '')::uuid
+        REFERENCES tenants(id) ON DELETE CASCADE,
+    review_id UUID NOT NULL REFERENCES editorial_reviews(id) ON DELETE CASCADE,
+    article_id UUID NOT NULL REFERENCES articles(id) ON DELETE CASCADE,
+    actor_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    -- submitted, resubmitted, assigned, changes_requested, approved, rejected or withdrawn
+    action VARCHAR(30) NOT NULL,
+    from_status VARCHAR(20),
+    to_status VARCHAR(20) NOT NULL,
+    assigned_to UUID REFERENCES users(id) ON DELETE SET NULL,
+    note TEXT,
+    comment_ids TEXT[] NOT NULL DEFAULT '{}', -- Inline draft comments made with the transition
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_editorial_events_article ON editorial_events(article_id, created_at);
+
+-- Reviews and their history are only seen in their own publication
+ALTER TABLE editorial_reviews ENABLE ROW LEVEL SECURITY;
+ALTER TABLE editorial_reviews FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON editorial_reviews;
+CREATE POLICY tenant_isolation ON editorial_reviews
+    USING (tenant_row_visible(tenant_id)) WITH CHECK (tenant_row_visible(tenant_id));
+
+ALTER TABLE editorial_events ENABLE ROW LEVEL SECURITY;
+ALTER TABLE editorial_events FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON editorial_events;
+CREATE POLICY tenant_isolation ON editorial_events
+    USING (tenant_row_visible(tenant_id)) WITH CHECK (tenant_row_visible(tenant_id));
+
+INSERT INTO role_permissions (role, permission) VALUES
+    ('administrator', 'article:review')
+ON CONFLICT (role, permission) DO NOTHING;
@@ -0,0 +1,74 @@
+-- Article authors
+-- Everyone credited on an article with their role, and invitations to co-author it (shared/coauthors.py)
+
+CREATE TABLE IF NOT EXISTS article_authors (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    tenant_id UUID DEFAULT NULLIF(current_setting('app.tenant_id', true), '')::uuid
+        REFERENCES tenants(id) ON DELETE CASCADE,
+    article_id UUID NOT NULL REFERENCES articles(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role VARCHAR(20) NOT NULL CHECK (role IN ('lead', 'contributor', 'translator')),
+    status VARCHAR(20) NOT NULL DEFAULT 'invited' CHECK (status IN ('invited', 'accepted', 'declined')),
+    position INTEGER NOT NULL DEFAULT 0, -- Order in the byline, after the lead
+    invited_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    responded_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (article_id, user_id)
+);
+
+-- The lead is the article's author_id, kept here by the trigger below
+CREATE UNIQUE INDEX IF NOT EXISTS idx_article_authors_lead ON article_authors(article_id) WHERE role = 'lead';
+CREATE INDEX IF NOT EXISTS idx_article_authors_user ON article_authors(user_id, status);
+
+-- Accepted co-authors besides the lead, so permission checks and author filters read them off the article
+ALTER TABLE articles ADD COLUMN IF NOT EXISTS coauthor_ids UUID[] NOT NULL DEFAULT '{}';
+CREATE INDEX IF NOT EXISTS idx_articles_coauthors ON articles USING GIN (coauthor_ids);
+
+CREATE OR REPLACE FUNCTION sync_article_coauthors()
+RETURNS TRIGGER AS $$
+DECLARE
+    target UUID := CASE WHEN TG_OP = 'DELETE' THEN OLD.article_id ELSE NEW.article_id END;
+BEGIN
+    UPDATE articles SET coauthor_ids = COALESCE((
+        SELECT array_agg(user_id ORDER BY position, created_at) FROM article_authors
+        WHERE article_id = target AND status = 'accepted' AND role <> 'lead'
+    ), '{}')
+    WHERE id = target;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS article_authors_sync ON article_authors;
+CREATE TRIGGER article_authors_sync AFTER INSERT OR UPDATE OR DELETE ON article_authors
+    FOR EACH ROW EXECUTE FUNCTION sync_article_coauthors();
+
+-- Whoever becomes an article's author_id is its lead; a co-author made lead gives up their other credit
+CREATE OR REPLACE FUNCTION set_article_lead_author()
+RETURNS TRIGGER AS $$
+BEGIN
+    DELETE FROM article_authors
+    WHERE article_id = NEW.id AND role = 'lead' AND user_id IS DISTINCT FROM NEW.author_id;
+    IF NEW.author_id IS NOT NULL THEN
+        INSERT INTO article_authors (tenant_id, article_id, user_id, role, status, responded_at)
+        VALUES (NEW.tenant_id, NEW.id, NEW.author_id, 'lead', 'accepted', CURRENT_TIMESTAMP)
+        ON CONFLICT (article_id, user_id) DO UPDATE
+            SET role = 'lead', status = 'accepted', position = 0, responded_at = CURRENT_TIMESTAMP;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS articles_lead_author ON articles;
+CREATE TRIGGER articles_lead_author AFTER INSERT OR UPDATE OF author_id ON articles
+    FOR EACH ROW EXECUTE FUNCTION set_article_lead_author();
+
+INSERT INTO article_authors (tenant_id, article_id, user_id, role, status, responded_at)
+SELECT tenant_id, id, author_id, 'lead', 'accepted', created_at FROM articles WHERE author_id IS NOT NULL
+ON CONFLICT (article_id, user_id) DO NOTHING;
+
+-- Credits are only seen in their own publication
+ALTER TABLE article_authors ENABLE ROW LEVEL SECURITY;
+ALTER TABLE article_authors FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS tenant_isolation ON article_authors;
+CREATE POLICY tenant_isolation ON article_authors
+    USING (tenant_row_visible(tenant_id)) WITH CHECK (tenant_row_visible(tenant_id));
@@ -0,0 +1,6 @@
+-- Curation permission
+-- Pinning and featuring articles is checked as `article:curate` rather than the administrator role (shared/permissions.py)
+
+INSERT INTO role_permissions (role, permission) VALUES
+    ('administrator', 'article:curate')
+ON CONFLICT (role, permission) DO NOTHING;
@@ -0,0 +1,19 @@
+-- Revert 01_core_tables.sql
+
+DROP TABLE IF EXISTS audit_logs CASCADE;
+DROP TABLE IF EXISTS author_payments CASCADE;
+DROP TABLE IF EXISTS did_identities CASCADE;
+DROP TABLE IF EXISTS user_follows CASCADE;
+DROP TABLE IF EXISTS saved_articles CASCADE;
+DROP TABLE IF EXISTS comments CASCADE;
+DROP TABLE IF EXISTS user_interactions CASCADE;
+DROP TABLE IF EXISTS articles CASCADE;
+DROP TABLE IF EXISTS user_preferences CASCADE;
+DROP TABLE IF EXISTS users CASCADE;
+
+DROP FUNCTION IF EXISTS update_updated_at_column() CASCADE;
+
+DROP TYPE IF EXISTS recommendation_model;
+DROP TYPE IF EXISTS interaction_type;
+DROP TYPE IF EXISTS article_status;
+DROP TYPE IF EXISTS user_role;
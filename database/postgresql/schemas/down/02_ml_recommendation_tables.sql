@@ -0,0 +1,15 @@
+-- Revert 02_ml_recommendation_tables.sql
+
+DROP TABLE IF EXISTS feature_importance CASCADE;
+DROP TABLE IF EXISTS recommendation_ab_tests CASCADE;
+DROP TABLE IF EXISTS model_performance CASCADE;
+DROP TABLE IF EXISTS recommendation_cache CASCADE;
+DROP TABLE IF EXISTS reranking_results CASCADE;
+DROP TABLE IF EXISTS candidate_generation CASCADE;
+DROP TABLE IF EXISTS attention_features CASCADE;
+DROP TABLE IF EXISTS gnn_graph_features CASCADE;
+DROP TABLE IF EXISTS rnn_user_sequences CASCADE;
+DROP TABLE IF EXISTS cnn_article_features CASCADE;
+DROP TABLE IF EXISTS two_tower_interactions CASCADE;
+DROP TABLE IF EXISTS article_embeddings CASCADE;
+DROP TABLE IF EXISTS user_embeddings CASCADE;
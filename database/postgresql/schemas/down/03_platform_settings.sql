@@ -0,0 +1,3 @@
+-- Revert 03_platform_settings.sql
+
+DROP TABLE IF EXISTS platform_settings CASCADE;
@@ -0,0 +1,3 @@
+-- Revert 04_category_follows.sql
+
+DROP TABLE IF EXISTS category_follows CASCADE;
@@ -0,0 +1,3 @@
+-- Revert 05_article_pins.sql
+
+DROP TABLE IF EXISTS article_pins CASCADE;
@@ -0,0 +1,6 @@
+-- Revert 06_collections.sql
+
+DROP TABLE IF EXISTS collection_articles CASCADE;
+DROP TABLE IF EXISTS collection_rules CASCADE;
+DROP TABLE IF EXISTS collection_sections CASCADE;
+DROP TABLE IF EXISTS collections CASCADE;
@@ -0,0 +1,5 @@
+-- Revert 07_article_licenses.sql
+
+ALTER TABLE articles DROP CONSTRAINT IF EXISTS articles_license_check;
+ALTER TABLE articles DROP COLUMN IF EXISTS license_terms;
+ALTER TABLE articles DROP COLUMN IF EXISTS license;
@@ -0,0 +1,4 @@
+-- Revert 08_syndication.sql
+
+DROP TABLE IF EXISTS syndication_usage CASCADE;
+DROP TABLE IF EXISTS syndication_partners CASCADE;
@@ -0,0 +1,3 @@
+-- Revert 09_syndication_redaction.sql
+
+ALTER TABLE syndication_partners DROP COLUMN IF EXISTS redaction_policy;
@@ -0,0 +1,7 @@
+-- Revert 10_article_reactions.sql
+-- PostgreSQL cannot drop an enum value, so 'reaction' stays in interaction_type;
+-- reaction rows are removed so nothing references it
+
+DELETE FROM user_interactions WHERE interaction_type = 'reaction';
+ALTER TABLE user_interactions DROP COLUMN IF EXISTS reaction;
+ALTER TABLE articles DROP COLUMN IF EXISTS reaction_counts;
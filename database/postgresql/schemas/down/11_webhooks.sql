@@ -0,0 +1,5 @@
+-- Revert 11_webhooks.sql
+
+DROP TABLE IF EXISTS webhook_dead_letters CASCADE;
+DROP TABLE IF EXISTS webhook_deliveries CASCADE;
+DROP TABLE IF EXISTS webhooks CASCADE;
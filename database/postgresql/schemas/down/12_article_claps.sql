@@ -0,0 +1,5 @@
+-- Revert 12_article_claps.sql
+
+ALTER TABLE articles DROP COLUMN IF EXISTS clap_count;
+DROP TABLE IF EXISTS article_clap_events CASCADE;
+DROP TABLE IF EXISTS article_claps CASCADE;
@@ -0,0 +1,3 @@
+-- Revert 13_event_outbox.sql
+
+DROP TABLE IF EXISTS event_outbox CASCADE;
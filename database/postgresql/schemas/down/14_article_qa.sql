@@ -0,0 +1,5 @@
+-- Revert 14_article_qa.sql
+
+DROP TABLE IF EXISTS qa_question_votes CASCADE;
+DROP TABLE IF EXISTS qa_questions CASCADE;
+DROP TABLE IF EXISTS qa_sessions CASCADE;
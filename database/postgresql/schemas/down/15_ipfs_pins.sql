@@ -0,0 +1,4 @@
+-- Revert 15_ipfs_pins.sql
+
+ALTER TABLE articles DROP COLUMN IF EXISTS ipfs_pinned_at;
+ALTER TABLE articles DROP COLUMN IF EXISTS ipfs_cid;
@@ -0,0 +1,5 @@
+-- Revert 16_article_corrections.sql
+
+ALTER TABLE users DROP COLUMN IF EXISTS accepted_corrections;
+DROP TABLE IF EXISTS article_corrections CASCADE;
+DROP TABLE IF EXISTS article_revisions CASCADE;
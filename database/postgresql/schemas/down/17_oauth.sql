@@ -0,0 +1,5 @@
+-- Revert 17_oauth.sql
+
+DROP TABLE IF EXISTS oauth_authorization_codes CASCADE;
+DROP TABLE IF EXISTS oauth_grants CASCADE;
+DROP TABLE IF EXISTS oauth_clients CASCADE;
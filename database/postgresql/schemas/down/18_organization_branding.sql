@@ -0,0 +1,4 @@
+-- Revert 18_organization_branding.sql
+
+DROP TABLE IF EXISTS organization_branding CASCADE;
+DROP TABLE IF EXISTS organizations CASCADE;
@@ -0,0 +1,3 @@
+-- Revert 19_article_review_queue.sql
+
+DROP TABLE IF EXISTS article_reviews CASCADE;
@@ -0,0 +1,7 @@
+-- Revert 20_social_login.sql
+
+DROP TABLE IF EXISTS user_auth_providers CASCADE;
+
+-- Social-only accounts get an unusable password so the column can be required again
+UPDATE users SET password_hash = '!' WHERE password_hash IS NULL;
+ALTER TABLE users ALTER COLUMN password_hash SET NOT NULL;
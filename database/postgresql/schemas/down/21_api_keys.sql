@@ -0,0 +1,3 @@
+-- Revert 21_api_keys.sql
+
+DROP TABLE IF EXISTS api_keys CASCADE;
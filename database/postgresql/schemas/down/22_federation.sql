@@ -0,0 +1,8 @@
+-- Revert 22_federation.sql
+
+-- Federated copies are only meaningful while linked to their origin
+DELETE FROM articles WHERE id IN (SELECT article_id FROM federated_articles);
+
+DROP TABLE IF EXISTS federation_tombstones CASCADE;
+DROP TABLE IF EXISTS federated_articles CASCADE;
+DROP TABLE IF EXISTS federation_peers CASCADE;
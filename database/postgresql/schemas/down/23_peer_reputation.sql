@@ -0,0 +1,8 @@
+-- Revert 23_peer_reputation.sql
+
+DROP TABLE IF EXISTS federation_peer_signals CASCADE;
+
+ALTER TABLE federation_peers DROP COLUMN IF EXISTS reputation_updated_at;
+ALTER TABLE federation_peers DROP COLUMN IF EXISTS reputation_override;
+ALTER TABLE federation_peers DROP COLUMN IF EXISTS ingestion_state;
+ALTER TABLE federation_peers DROP COLUMN IF EXISTS reputation_score;
@@ -0,0 +1,4 @@
+-- Revert 24_draft_collaboration.sql
+
+DROP TABLE IF EXISTS draft_crdt_updates CASCADE;
+DROP TABLE IF EXISTS draft_crdt_snapshots CASCADE;
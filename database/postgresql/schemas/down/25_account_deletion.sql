@@ -0,0 +1,7 @@
+-- Revert 25_account_deletion.sql
+
+DROP INDEX IF EXISTS idx_users_deletion_scheduled;
+
+ALTER TABLE users DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE users DROP COLUMN IF EXISTS deletion_scheduled_for;
+ALTER TABLE users DROP COLUMN IF EXISTS deletion_requested_at;
@@ -0,0 +1,4 @@
+-- Revert 26_article_ingestion.sql
+
+DROP INDEX IF EXISTS idx_articles_source_url;
+DROP TABLE IF EXISTS ingested_articles CASCADE;
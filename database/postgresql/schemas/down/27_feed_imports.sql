@@ -0,0 +1,4 @@
+-- Revert 27_feed_imports.sql
+
+DROP TABLE IF EXISTS feed_items CASCADE;
+DROP TABLE IF EXISTS feed_sources CASCADE;
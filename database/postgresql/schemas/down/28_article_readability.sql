@@ -0,0 +1,5 @@
+-- Revert 28_article_readability.sql
+
+DROP INDEX IF EXISTS idx_articles_reading_level;
+ALTER TABLE articles DROP COLUMN IF EXISTS reading_level;
+ALTER TABLE articles DROP COLUMN IF EXISTS readability;
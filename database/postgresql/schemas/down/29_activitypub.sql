@@ -0,0 +1,5 @@
+-- Revert 29_activitypub.sql
+
+DROP TABLE IF EXISTS ap_interactions CASCADE;
+DROP TABLE IF EXISTS ap_followers CASCADE;
+DROP TABLE IF EXISTS ap_actor_keys CASCADE;
@@ -0,0 +1,4 @@
+-- Revert 30_experiments.sql
+
+DROP TABLE IF EXISTS experiment_results CASCADE;
+DROP TABLE IF EXISTS experiments CASCADE;
@@ -0,0 +1,4 @@
+-- Revert 31_content_signatures.sql
+
+DROP TABLE IF EXISTS article_signatures CASCADE;
+DROP TABLE IF EXISTS author_keys CASCADE;
@@ -0,0 +1,3 @@
+-- Revert 32_og_images.sql
+
+ALTER TABLE articles DROP COLUMN IF EXISTS og_image_url;
@@ -0,0 +1,4 @@
+-- Revert 33_merkle_anchoring.sql
+
+DROP TABLE IF EXISTS article_anchors CASCADE;
+DROP TABLE IF EXISTS anchor_batches CASCADE;
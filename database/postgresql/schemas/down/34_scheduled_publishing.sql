@@ -0,0 +1,5 @@
+-- Revert 34_scheduled_publishing.sql
+
+DROP INDEX IF EXISTS idx_articles_scheduled_publish;
+ALTER TABLE articles DROP COLUMN IF EXISTS cache_primed_at;
+ALTER TABLE articles DROP COLUMN IF EXISTS scheduled_publish_at;
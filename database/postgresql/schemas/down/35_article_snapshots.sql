@@ -0,0 +1,3 @@
+-- Revert 35_article_snapshots.sql
+
+DROP TABLE IF EXISTS article_snapshots CASCADE;
@@ -0,0 +1,3 @@
+-- Revert 36_trend_rollups.sql
+
+DROP TABLE IF EXISTS trend_rollups;
@@ -0,0 +1,6 @@
+-- Revert 37_paywall.sql
+
+DROP TABLE IF EXISTS article_purchases;
+DROP TABLE IF EXISTS user_subscriptions;
+DROP TABLE IF EXISTS subscription_tiers;
+ALTER TABLE articles DROP COLUMN IF EXISTS access_policy;
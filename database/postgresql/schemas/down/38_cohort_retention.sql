@@ -0,0 +1,4 @@
+-- Revert 38_cohort_retention.sql
+
+DROP TABLE IF EXISTS cohort_retention;
+DROP TABLE IF EXISTS user_activity_days;
@@ -0,0 +1,9 @@
+-- Revert 39_tips_ledger.sql
+
+DROP TABLE IF EXISTS ledger_entries;
+DROP TABLE IF EXISTS ledger_transactions;
+DROP TABLE IF EXISTS ledger_accounts;
+DROP TABLE IF EXISTS payout_requests;
+DROP TABLE IF EXISTS tips;
+DROP FUNCTION IF EXISTS check_ledger_transaction_balanced();
+DROP FUNCTION IF EXISTS reject_ledger_entry_change();
@@ -0,0 +1,4 @@
+-- Revert 40_funnels.sql
+
+DROP TABLE IF EXISTS funnel_counts;
+DROP TABLE IF EXISTS funnels;
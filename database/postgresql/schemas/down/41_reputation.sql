@@ -0,0 +1,5 @@
+-- Revert 41_reputation.sql
+
+DROP TABLE IF EXISTS article_reports;
+ALTER TABLE users DROP COLUMN IF EXISTS reputation_decayed_at;
+DROP TABLE IF EXISTS reputation_events;
@@ -0,0 +1,6 @@
+-- Revert 42_warehouse_exports.sql
+
+DROP INDEX IF EXISTS idx_user_interactions_warehouse_cursor;
+DROP INDEX IF EXISTS idx_users_warehouse_cursor;
+DROP INDEX IF EXISTS idx_articles_warehouse_cursor;
+DROP TABLE IF EXISTS warehouse_exports;
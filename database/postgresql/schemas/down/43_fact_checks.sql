@@ -0,0 +1,3 @@
+-- Revert 43_fact_checks.sql
+
+DROP TABLE IF EXISTS fact_checks;
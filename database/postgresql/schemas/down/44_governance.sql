@@ -0,0 +1,4 @@
+-- Revert 44_governance.sql
+
+DROP TABLE IF EXISTS governance_votes;
+DROP TABLE IF EXISTS governance_proposals;
@@ -0,0 +1,4 @@
+-- Revert 45_crawler_policies.sql
+
+ALTER TABLE api_keys DROP COLUMN IF EXISTS excluded_sections;
+ALTER TABLE api_keys DROP COLUMN IF EXISTS crawl_delay_seconds;
@@ -0,0 +1,4 @@
+-- Revert 46_push_devices.sql
+
+DROP TABLE IF EXISTS push_topic_subscriptions;
+DROP TABLE IF EXISTS push_devices;
@@ -0,0 +1,4 @@
+-- Revert 47_newsletters.sql
+
+DROP TABLE IF EXISTS newsletter_sends;
+DROP TABLE IF EXISTS newsletter_subscriptions;
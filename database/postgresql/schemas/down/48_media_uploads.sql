@@ -0,0 +1,3 @@
+-- Revert 48_media_uploads.sql
+
+DROP TABLE IF EXISTS media_uploads;
@@ -0,0 +1,4 @@
+-- Revert 49_link_previews.sql
+
+ALTER TABLE articles DROP COLUMN IF EXISTS link_previews;
+DROP TABLE IF EXISTS link_previews;
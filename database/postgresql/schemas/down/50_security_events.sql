@@ -0,0 +1,3 @@
+-- Revert 50_security_events.sql
+
+DROP INDEX IF EXISTS idx_audit_logs_user_action_created;
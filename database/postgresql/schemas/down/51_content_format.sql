@@ -0,0 +1,3 @@
+-- Revert 51_content_format.sql
+
+ALTER TABLE articles DROP COLUMN IF EXISTS content_format;
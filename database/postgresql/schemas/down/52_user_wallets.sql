@@ -0,0 +1,3 @@
+-- Revert 52_user_wallets.sql
+
+DROP TABLE IF EXISTS user_wallets;
@@ -0,0 +1,4 @@
+-- Revert 53_article_translations.sql
+
+ALTER TABLE articles DROP COLUMN IF EXISTS translations;
+DROP TABLE IF EXISTS article_translations;
@@ -0,0 +1,3 @@
+-- Revert 54_article_archives.sql
+
+DROP TABLE IF EXISTS article_archives;
@@ -0,0 +1,6 @@
+-- Revert 55_account_migrations.sql
+
+ALTER TABLE users DROP COLUMN IF EXISTS moved_at;
+ALTER TABLE users DROP COLUMN IF EXISTS moved_to;
+DROP TABLE IF EXISTS account_aliases;
+DROP TABLE IF EXISTS account_migrations;
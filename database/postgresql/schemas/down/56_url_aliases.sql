@@ -0,0 +1,10 @@
+-- Revert 56_url_aliases.sql
+
+DROP TRIGGER IF EXISTS articles_slug ON articles;
+DROP FUNCTION IF EXISTS set_article_slug();
+DROP TRIGGER IF EXISTS users_username_alias ON users;
+DROP FUNCTION IF EXISTS record_username_alias();
+DROP TABLE IF EXISTS url_aliases;
+DROP INDEX IF EXISTS idx_articles_slug;
+ALTER TABLE articles DROP COLUMN IF EXISTS slug;
+DROP FUNCTION IF EXISTS article_slug(TEXT, UUID);
@@ -0,0 +1,3 @@
+-- Revert 57_taxonomy_usage.sql
+
+DROP MATERIALIZED VIEW IF EXISTS taxonomy_usage;
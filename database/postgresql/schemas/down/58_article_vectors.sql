@@ -0,0 +1,4 @@
+-- Revert 58_article_vectors.sql
+
+DROP TABLE IF EXISTS article_vectors;
+DROP EXTENSION IF EXISTS vector;
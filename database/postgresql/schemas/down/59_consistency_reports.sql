@@ -0,0 +1,3 @@
+-- Revert 59_consistency_reports.sql
+
+DROP TABLE IF EXISTS consistency_reports;
@@ -0,0 +1,4 @@
+-- Revert 60_sandbox.sql
+
+DROP SCHEMA IF EXISTS sandbox CASCADE;
+DROP TABLE IF EXISTS sandbox_resets;
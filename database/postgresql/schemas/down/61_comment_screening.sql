@@ -0,0 +1,4 @@
+-- Revert 61_comment_screening.sql
+
+DROP TABLE IF EXISTS comment_screenings;
+DROP INDEX IF EXISTS idx_comments_article_status;
@@ -0,0 +1,6 @@
+-- Revert 62_tenants.sql
+
+ALTER TABLE user_interactions DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE articles DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE users DROP COLUMN IF EXISTS tenant_id;
+DROP TABLE IF EXISTS tenants;
@@ -0,0 +1,7 @@
+-- Revert 63_shadow_bans_and_blocks.sql
+
+DROP TABLE IF EXISTS user_blocks;
+DROP INDEX IF EXISTS idx_users_shadow_banned;
+ALTER TABLE users DROP COLUMN IF EXISTS shadow_ban_reason;
+ALTER TABLE users DROP COLUMN IF EXISTS shadow_banned_by;
+ALTER TABLE users DROP COLUMN IF EXISTS shadow_banned_at;
@@ -0,0 +1,4 @@
+-- Revert 64_admin_audit_log.sql
+
+DROP TABLE IF EXISTS admin_audit_log;
+DROP FUNCTION IF EXISTS reject_admin_audit_log_change();
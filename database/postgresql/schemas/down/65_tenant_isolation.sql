@@ -0,0 +1,54 @@
+-- Revert 65_tenant_isolation.sql
+-- Encrypted webhook secrets are kept as they are
+
+ALTER TABLE webhooks ALTER COLUMN secret TYPE VARCHAR(128);
+DROP FUNCTION IF EXISTS user_identity_taken(TEXT, TEXT);
+
+DROP POLICY IF EXISTS tenant_isolation ON ap_actor_keys;
+ALTER TABLE ap_actor_keys NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE ap_actor_keys DISABLE ROW LEVEL SECURITY;
+ALTER TABLE ap_actor_keys DROP COLUMN IF EXISTS tenant_id;
+
+DROP POLICY IF EXISTS tenant_isolation ON webhook_dead_letters;
+ALTER TABLE webhook_dead_letters NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE webhook_dead_letters DISABLE ROW LEVEL SECURITY;
+ALTER TABLE webhook_dead_letters DROP COLUMN IF EXISTS tenant_id;
+
+DROP POLICY IF EXISTS tenant_isolation ON webhook_deliveries;
+ALTER TABLE webhook_deliveries NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE webhook_deliveries DISABLE ROW LEVEL SECURITY;
+ALTER TABLE webhook_deliveries DROP COLUMN IF EXISTS tenant_id;
+
+DROP POLICY IF EXISTS tenant_isolation ON webhooks;
+ALTER TABLE webhooks NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE webhooks DISABLE ROW LEVEL SECURITY;
+ALTER TABLE webhooks DROP COLUMN IF EXISTS tenant_id;
+
+DROP POLICY IF EXISTS tenant_isolation ON article_pins;
+ALTER TABLE article_pins NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE article_pins DISABLE ROW LEVEL SECURITY;
+ALTER TABLE article_pins DROP COLUMN IF EXISTS tenant_id;
+
+DROP POLICY IF EXISTS tenant_isolation ON comment_screenings;
+ALTER TABLE comment_screenings NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE comment_screenings DISABLE ROW LEVEL SECURITY;
+ALTER TABLE comment_screenings DROP COLUMN IF EXISTS tenant_id;
+
+DROP POLICY IF EXISTS tenant_isolation ON comments;
+ALTER TABLE comments NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE comments DISABLE ROW LEVEL SECURITY;
+ALTER TABLE comments DROP COLUMN IF EXISTS tenant_id;
+
+DROP POLICY IF EXISTS tenant_isolation ON user_interactions;
+ALTER TABLE user_interactions NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE user_interactions DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS tenant_isolation ON articles;
+ALTER TABLE articles NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE articles DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS tenant_isolation ON users;
+ALTER TABLE users NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE users DISABLE ROW LEVEL SECURITY;
+
+DROP FUNCTION IF EXISTS tenant_row_visible(UUID);
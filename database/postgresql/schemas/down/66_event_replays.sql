@@ -0,0 +1,4 @@
+-- Revert 66_event_replays.sql
+
+DROP INDEX IF EXISTS idx_event_outbox_replay;
+DROP TABLE IF EXISTS event_replays;
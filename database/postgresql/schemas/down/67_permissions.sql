@@ -0,0 +1,4 @@
+-- Revert 67_permissions.sql
+
+DROP TABLE IF EXISTS user_permissions;
+DROP TABLE IF EXISTS role_permissions;
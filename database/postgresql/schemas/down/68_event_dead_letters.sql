@@ -0,0 +1,4 @@
+-- Revert 68_event_dead_letters.sql
+
+DROP TABLE IF EXISTS event_dead_letters;
+ALTER TABLE event_outbox DROP COLUMN IF EXISTS dead_lettered_at;
@@ -0,0 +1,7 @@
+-- Revert 69_publication_members.sql
+
+DELETE FROM role_permissions WHERE permission = 'members:manage';
+DELETE FROM user_permissions WHERE permission = 'members:manage';
+DROP TABLE IF EXISTS publication_invitations;
+DROP INDEX IF EXISTS idx_users_publication_role;
+ALTER TABLE users DROP COLUMN IF EXISTS publication_role;
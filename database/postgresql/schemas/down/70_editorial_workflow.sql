@@ -0,0 +1,6 @@
+-- Revert 70_editorial_workflow.sql
+
+DELETE FROM role_permissions WHERE permission = 'article:review';
+DELETE FROM user_permissions WHERE permission = 'article:review';
+DROP TABLE IF EXISTS editorial_events;
+DROP TABLE IF EXISTS editorial_reviews;
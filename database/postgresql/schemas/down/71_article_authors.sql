@@ -0,0 +1,8 @@
+-- Revert 71_article_authors.sql
+
+DROP TRIGGER IF EXISTS articles_lead_author ON articles;
+DROP FUNCTION IF EXISTS set_article_lead_author();
+DROP TABLE IF EXISTS article_authors;
+DROP FUNCTION IF EXISTS sync_article_coauthors();
+DROP INDEX IF EXISTS idx_articles_coauthors;
+ALTER TABLE articles DROP COLUMN IF EXISTS coauthor_ids;
@@ -0,0 +1,4 @@
+-- Revert 72_curation_permission.sql
+
+DELETE FROM role_permissions WHERE permission = 'article:curate';
+DELETE FROM user_permissions WHERE permission = 'article:curate';
@@ -63,8 +63,10 @@ setup_postgresql() {
     
     # Run schema creation scripts
     echo "Creating PostgreSQL schemas..."
-    PGPASSWORD="$POSTGRES_PASSWORD" psql -h "$POSTGRES_HOST" -p "$POSTGRES_PORT" -U "$POSTGRES_USER" -d "$POSTGRES_DB" -f "$SCRIPT_DIR/postgresql/schemas/01_core_tables.sql"
-    PGPASSWORD="$POSTGRES_PASSWORD" psql -h "$POSTGRES_HOST" -p "$POSTGRES_PORT" -U "$POSTGRES_USER" -d "$POSTGRES_DB" -f "$SCRIPT_DIR/postgresql/schemas/02_ml_recommendation_tables.sql"
+    # Schema files are numbered and applied in order
+    for schema_file in "$SCRIPT_DIR"/postgresql/schemas/*.sql; do
+        PGPASSWORD="$POSTGRES_PASSWORD" psql -h "$POSTGRES_HOST" -p "$POSTGRES_PORT" -U "$POSTGRES_USER" -d "$POSTGRES_DB" -f "$schema_file"
+    done
     
     echo -e "${GREEN}✓ PostgreSQL schemas created successfully${NC}"
 }
@@ -30,10 +30,12 @@ export default function AdminPage() {
     flaggedContent: 0,
     activeUsers: 0,
     newUsersToday: 0,
-    articlesThisWeek: 0
+    articlesThisWeek: 0,
+    readingNow: 0
   });
   const [recentUsers, setRecentUsers] = useState<any[]>([]);
   const [flaggedContent, setFlaggedContent] = useState<any[]>([]);
+  const [liveArticles, setLiveArticles] = useState<any[]>([]);
   const [loading, setLoading] = useState(true);
   const [error, setError] = useState('');
 
@@ -46,10 +48,11 @@ export default function AdminPage() {
     setError('');
     
     try {
-      const [adminStatsResponse, recentUsersResponse, flaggedContentResponse] = await Promise.all([
+      const [adminStatsResponse, recentUsersResponse, flaggedContentResponse, liveArticlesResponse] = await Promise.all([
         analyticsAPI.getAdminStats(),
         analyticsAPI.getRecentUsers(),
-        analyticsAPI.getFlaggedContent()
+        analyticsAPI.getFlaggedContent(),
+        analyticsAPI.getLiveArticles()
       ]);
 
       setStats(adminStatsResponse.stats || {
@@ -59,10 +62,12 @@ export default function AdminPage() {
         flaggedContent: 0,
         activeUsers: 0,
         newUsersToday: 0,
-        articlesThisWeek: 0
+        articlesThisWeek: 0,
+        readingNow: 0
       });
       setRecentUsers(recentUsersResponse.users || []);
       setFlaggedContent(flaggedContentResponse.content || []);
+      setLiveArticles(liveArticlesResponse.articles || []);
     } catch (error) {
       console.error('Error loading admin data:', error);
       setError('Failed to load admin data');
@@ -404,6 +409,11 @@ export default function AdminPage() {
                     <p className="text-2xl font-bold">{stats.articlesThisWeek}</p>
                     <p className="text-xs text-green-600">This week</p>
                   </div>
+                  <div className="space-y-2">
+                    <p className="text-sm font-medium">Reading Now</p>
+                    <p className="text-2xl font-bold">{stats.readingNow}</p>
+                    <p className="text-xs text-muted-foreground">Across all articles</p>
+                  </div>
                   <div className="space-y-2">
                     <p className="text-sm font-medium">User Engagement</p>
                     <p className="text-2xl font-bold">87%</p>
@@ -412,6 +422,31 @@ export default function AdminPage() {
                 </div>
               </CardContent>
             </Card>
+
+            <Card>
+              <CardHeader>
+                <CardTitle>Reading Now</CardTitle>
+                <CardDescription>Articles with the most readers right now</CardDescription>
+              </CardHeader>
+              <CardContent>
+                <div className="space-y-4">
+                  {liveArticles.length > 0 ? liveArticles.map((article) => (
+                    <div key={article.id} className="flex items-center justify-between">
+                      <div>
+                        <p className="font-medium text-sm">{article.title}</p>
+                        <p className="text-xs text-muted-foreground">by {article.author}</p>
+                      </div>
+                      <Badge variant="secondary">
+                        <Eye className="h-3 w-3 mr-1" />
+                        {article.readingNow} reading
+                      </Badge>
+                    </div>
+                  )) : (
+                    <p className="text-center py-4 text-muted-foreground">Nobody is reading right now</p>
+                  )}
+                </div>
+              </CardContent>
+            </Card>
           </TabsContent>
         </Tabs>
       </div>
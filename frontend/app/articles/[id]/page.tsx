@@ -16,7 +16,8 @@ import {
   User, 
   ArrowLeft,
   Eye,
-  ThumbsUp
+  ThumbsUp,
+  Lock
 } from 'lucide-react';
 import { Article, useStore } from '@/lib/store';
 import { articlesAPI, interactionsAPI } from '@/lib/api';
@@ -66,6 +67,11 @@ export default function ArticlePage() {
             await loadInteractionStatus(params.id as string);
           }
           
+          // Opening the article counts the view for signed-in readers; count it for everyone else
+          if (!user) {
+            articlesAPI.countView(params.id as string).catch(() => {});
+          }
+
           // Track view after a short delay if user is authenticated
           if (user && !viewTracked) {
             setTimeout(() => {
@@ -342,6 +348,34 @@ export default function ArticlePage() {
         </div>
       </article>
 
+      {article.paywalled && (
+        <Card className="mt-8">
+          <CardContent className="p-6 space-y-3">
+            <div className="flex items-center space-x-2">
+              <Lock className="w-5 h-5" />
+              <h3 className="text-lg font-semibold">Premium article</h3>
+            </div>
+            <p className="text-muted-foreground">
+              {user ? 'Unlock the full article with any of these:' : 'Sign in to unlock the full article with any of these:'}
+            </p>
+            <ul className="list-disc pl-6 space-y-1 text-sm">
+              {article.access_policy?.subscription_tier && (
+                <li>A <strong>{article.access_policy.subscription_tier}</strong> subscription</li>
+              )}
+              {article.access_policy?.token && (
+                <li>
+                  Holding {article.access_policy.token.min_balance} {article.access_policy.token.standard.toUpperCase()} token
+                  {article.access_policy.token.min_balance === 1 ? '' : 's'} from {article.access_policy.token.contract} in your wallet
+                </li>
+              )}
+              {article.access_policy?.purchase && (
+                <li>A one-time payment of {article.access_policy.purchase.price} ETH</li>
+              )}
+            </ul>
+          </CardContent>
+        </Card>
+      )}
+
       <Separator className="my-12" />
 
       {/* Article Footer */}
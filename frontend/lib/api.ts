@@ -7,6 +7,16 @@ import { Article, useStore } from './store';
 const FLASK_BASE_URL = process.env.NEXT_PUBLIC_BACKEND_URL || 'http://localhost:5000';
 const FASTAPI_BASE_URL = process.env.NEXT_PUBLIC_BACKEND_URL || 'http://localhost:8000';
 
+// Stable id for signed-out readers, so their views are counted once per article
+const viewSessionId = (): string => {
+  let id = localStorage.getItem('view_session_id');
+  if (!id) {
+    id = crypto.randomUUID();
+    localStorage.setItem('view_session_id', id);
+  }
+  return id;
+};
+
 // Create API instances
 const flaskAPI = axios.create({
   baseURL: FLASK_BASE_URL,
@@ -81,6 +91,11 @@ export const usersAPI = {
   getUserStats: async (userId: string) => {
     const response = await fastAPI.get(`/api/v1/users/${userId}/stats`);
     return response.data;
+  },
+
+  getReputationHistory: async (userId: string, params: { event?: string; limit?: number; offset?: number } = {}) => {
+    const response = await fastAPI.get(`/api/v1/users/${userId}/reputation/history`, { params });
+    return response.data;
   }
 };
 
@@ -95,16 +110,38 @@ export const articlesAPI = {
     return data.data;
   },
   
-  getById: async (id: string): Promise<Article | null> => {
-    const response = await fastAPI.get(`/api/v1/articles/${id}`);
+  getById: async (id: string, include?: 'fact_checks'): Promise<Article | null> => {
+    const response = await fastAPI.get(`/api/v1/articles/${id}`, { params: include ? { include } : undefined });
     const {data} = response
     return data;
   },
   
+  countView: async (id: string) => {
+    const response = await fastAPI.post(`/api/v1/articles/${id}/view`, null, {
+      headers: { 'X-Session-Id': viewSessionId() }
+    });
+    return response.data;
+  },
+
   getRelated: async (id: string): Promise<Article[]> => {
     const response = await fastAPI.get(`/api/v1/articles/${id}/related`);
     return response.data;
   },
+
+  getAccess: async (id: string) => {
+    const response = await fastAPI.get(`/api/v1/articles/${id}/access`);
+    return response.data;
+  },
+
+  purchase: async (id: string, transactionHash: string) => {
+    const response = await fastAPI.post(`/api/v1/articles/${id}/purchase`, { transaction_hash: transactionHash });
+    return response.data;
+  },
+
+  report: async (id: string, reason: 'misinformation' | 'spam' | 'harassment' | 'plagiarism' | 'other', details?: string) => {
+    const response = await fastAPI.post(`/api/v1/articles/${id}/report`, { reason, details });
+    return response.data;
+  },
   
   create: async (article: any) => {
     const response = await fastAPI.post('/api/v1/articles', article);
@@ -209,6 +246,30 @@ export const analyticsAPI = {
   getFlaggedContent: async () => {
     const response = await fastAPI.get('/api/v1/analytics/admin/flagged-content');
     return response.data;
+  },
+
+  getLiveArticles: async () => {
+    const response = await fastAPI.get('/api/v1/analytics/admin/live-articles');
+    return response.data;
+  },
+
+  getTrends: async (params: { tag?: string[]; category?: string[]; granularity?: 'day' | 'week' | 'month'; start?: string; end?: string }) => {
+    const response = await fastAPI.get('/api/v1/analytics/trends', {
+      params,
+      // Repeat tag and category rather than using tag[]=
+      paramsSerializer: { indexes: null }
+    });
+    return response.data;
+  },
+
+  getCohorts: async (params: { weeks?: number; metric?: 'active_users' | 'returning_users' | 'interacting_users' } = {}) => {
+    const response = await fastAPI.get('/api/v1/admin/analytics/cohorts', { params });
+    return response.data;
+  },
+
+  getFunnelResults: async (key: string, params: { by?: 'total' | 'article' | 'category' | 'variant'; start?: string; end?: string; experiment_id?: string; category?: string } = {}) => {
+    const response = await fastAPI.get(`/api/v1/admin/analytics/funnels/${key}/results`, { params });
+    return response.data;
   }
 };
 
@@ -245,4 +306,61 @@ export const interactionsAPI = {
     });
     return response.data;
   }
-};
\ No newline at end of file
+};
+// Telemetry API (FastAPI)
+export type TelemetryEvent = {
+  type: 'scroll_depth' | 'dwell' | 'impression';
+  occurred_at: string;
+  article_id?: string;
+  value?: number;
+  properties?: Record<string, string | number | boolean>;
+};
+
+export const eventsAPI = {
+  sendBatch: async (events: TelemetryEvent[]) => {
+    const response = await fastAPI.post('/api/v1/events/batch', { session_id: viewSessionId(), events });
+    return response.data;
+  }
+};
+
+// Tips API (FastAPI)
+export const tipsAPI = {
+  tip: async (tip: {
+    author_id?: string;
+    article_id?: string;
+    rail: 'stripe' | 'onchain';
+    amount?: string;
+    currency?: string;
+    transaction_hash?: string;
+    message?: string;
+    anonymous?: boolean;
+  }) => {
+    const response = await fastAPI.post('/api/v1/tips', tip);
+    return response.data;
+  },
+
+  getReceived: async (params: { limit?: number; offset?: number } = {}) => {
+    const response = await fastAPI.get('/api/v1/tips/received', { params });
+    return response.data;
+  },
+
+  getSent: async (params: { limit?: number; offset?: number } = {}) => {
+    const response = await fastAPI.get('/api/v1/tips/sent', { params });
+    return response.data;
+  },
+
+  getBalance: async () => {
+    const response = await fastAPI.get('/api/v1/tips/balance');
+    return response.data;
+  },
+
+  requestPayout: async (payout: { amount: string; currency: string; destination: string }) => {
+    const response = await fastAPI.post('/api/v1/tips/payouts', payout);
+    return response.data;
+  },
+
+  getPayouts: async (status?: 'requested' | 'paid' | 'rejected') => {
+    const response = await fastAPI.get('/api/v1/tips/payouts', { params: { status } });
+    return response.data;
+  }
+};
@@ -0,0 +1,150 @@
+// Verification of signed API responses (RFC 9421)
+//
+// Article and manifest responses from a node with a signing key carry
+// Content-Digest, Signature-Input and Signature headers. A mirror that keeps
+// the body and these headers lets readers check the content against the
+// origin's Ed25519 key, published at /api/v1/node/keys.
+
+export interface NodeKey {
+  keyid: string;
+  alg: string;
+  public_key: string;
+}
+
+export interface VerifyOptions {
+  // Path and query the origin served the response at; default to the response URL
+  path?: string;
+  query?: string;
+  // Reject signatures older than this; unset accepts any age
+  maxAgeSeconds?: number;
+}
+
+export async function fetchNodeKeys(originUrl: string): Promise<NodeKey[]> {
+  const response = await fetch(`${originUrl.replace(/\/$/, '')}/api/v1/node/keys`);
+  if (!response.ok) {
+    throw new Error(`Could not fetch node keys: ${response.status}`);
+  }
+  const data = await response.json();
+  return data.keys || [];
+}
+
+const base64ToBytes = (value: string): Uint8Array => {
+  const binary = atob(value);
+  const bytes = new Uint8Array(binary.length);
+  for (let i = 0; i < binary.length; i++) {
+    bytes[i] = binary.charCodeAt(i);
+  }
+  return bytes;
+};
+
+const bytesToBase64 = (bytes: Uint8Array): string => {
+  let binary = '';
+  for (let i = 0; i < bytes.length; i++) {
+    binary += String.fromCharCode(bytes[i]);
+  }
+  return btoa(binary);
+};
+
+// Members of a dictionary header as label -> raw value; values here never contain commas
+const parseDictionary = (header: string): Record<string, string> => {
+  const members: Record<string, string> = {};
+  header.split(',').forEach((member) => {
+    const separator = member.indexOf('=');
+    if (separator > 0) {
+      members[member.slice(0, separator).trim()] = member.slice(separator + 1).trim();
+    }
+  });
+  return members;
+};
+
+const parseParams = (signatureParams: string): Record<string, string> => {
+  const params: Record<string, string> = {};
+  const pattern = /;([a-z]+)=("([^"]*)"|\d+)/g;
+  let match;
+  while ((match = pattern.exec(signatureParams.slice(signatureParams.indexOf(')')))) !== null) {
+    params[match[1]] = match[3] !== undefined ? match[3] : match[2];
+  }
+  return params;
+};
+
+const componentValue = (
+  component: string,
+  response: Response,
+  digest: string,
+  path: string,
+  query: string
+): string | null => {
+  switch (component) {
+    case '"@status"':
+      return String(response.status);
+    case '"@path";req':
+      return path;
+    case '"@query";req':
+      return `?${query}`;
+    case '"content-digest"':
+      return digest;
+    default: {
+      const name = component.match(/^"([a-z0-9-]+)"$/);
+      return name ? response.headers.get(name[1]) : null;
+    }
+  }
+};
+
+// True when the response body and covered headers were signed by one of `keys`
+export async function verifySignedResponse(
+  response: Response,
+  keys: NodeKey[],
+  options: VerifyOptions = {}
+): Promise<boolean> {
+  const signatureInput = response.headers.get('Signature-Input');
+  const signatureHeader = response.headers.get('Signature');
+  const digestHeader = response.headers.get('Content-Digest');
+  if (!signatureInput || !signatureHeader || !digestHeader) {
+    return false;
+  }
+
+  const inputs = parseDictionary(signatureInput);
+  const signatures = parseDictionary(signatureHeader);
+  const label = Object.keys(inputs).find((name) => signatures[name]);
+  if (!label) {
+    return false;
+  }
+  const signatureParams = inputs[label];
+  const params = parseParams(signatureParams);
+  const key = keys.find((candidate) => candidate.keyid === params.keyid && candidate.alg === 'ed25519');
+  if (!key || params.alg !== 'ed25519') {
+    return false;
+  }
+  if (options.maxAgeSeconds !== undefined && Date.now() / 1000 - Number(params.created) > options.maxAgeSeconds) {
+    return false;
+  }
+
+  const body = new Uint8Array(await response.clone().arrayBuffer());
+  const digest = `sha-256=:${bytesToBase64(new Uint8Array(await crypto.subtle.digest('SHA-256', body)))}:`;
+  if (digestHeader !== digest) {
+    return false;
+  }
+
+  const url = new URL(response.url || 'http://localhost/');
+  const path = options.path !== undefined ? options.path : url.pathname;
+  const query = options.query !== undefined ? options.query : url.search.replace(/^\?/, '');
+
+  const components = signatureParams.slice(1, signatureParams.indexOf(')')).match(/"[^"]+"(;[a-z]+)*/g) || [];
+  const lines: string[] = [];
+  for (const component of components) {
+    const value = componentValue(component, response, digest, path, query);
+    if (value === null) {
+      return false;
+    }
+    lines.push(`${component}: ${value}`);
+  }
+  lines.push(`"@signature-params": ${signatureParams}`);
+
+  const publicKey = await crypto.subtle.importKey('raw', base64ToBytes(key.public_key), { name: 'Ed25519' }, false, ['verify']);
+  return crypto.subtle.verify(
+    { name: 'Ed25519' },
+    publicKey,
+    base64ToBytes(signatures[label].replace(/^:|:$/g, '')),
+    new TextEncoder().encode(lines.join('\n'))
+  );
+}
@@ -40,6 +40,13 @@ export interface Article {
   view_count?: number;
   comment_count?: number;
   share_count?: number;
+  premium?: boolean;
+  paywalled?: boolean; // content is a preview until the reader unlocks the article
+  access_policy?: {
+    subscription_tier?: string;
+    token?: { standard: string; contract: string; token_id?: number; min_balance: number };
+    purchase?: { price: number };
+  };
 }
 
 interface AppState {